@@ -0,0 +1,165 @@
+//go:build !custom || inputs || inputs.opcua || inputs.opcua_listener
+
+// Command handling for the OPC UA "plugins opcua" command
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/urfave/cli/v2"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/logger"
+	"github.com/influxdata/telegraf/plugins/common/opcua"
+)
+
+func init() {
+	pluginSubcommands = append(pluginSubcommands, getOPCUACommand)
+}
+
+func getOPCUACommand(outputBuffer io.Writer) *cli.Command {
+	return &cli.Command{
+		Name:  "opcua",
+		Usage: "commands for OPC UA servers",
+		Subcommands: []*cli.Command{
+			{
+				Name:  "browse",
+				Usage: "browse an OPC UA server and print the node configuration",
+				Description: `
+The 'browse' command connects to the given OPC UA server, browses all
+objects below the given node and prints the variables found as node group
+configuration for the 'opcua' or 'opcua_listener' input plugin. The display
+names of the variables are used as field names.
+
+To print the configuration for all variables below the 'Objects' folder of
+a local server use
+
+> telegraf plugins opcua browse --endpoint "opc.tcp://localhost:4840"
+
+To print the configuration for the listener plugin for the variables of a
+single object use
+
+> telegraf plugins opcua browse --node "ns=3;s=Machine1" --plugin opcua_listener
+`,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "endpoint",
+						Usage: "OPC UA endpoint URL",
+						Value: "opc.tcp://localhost:4840",
+					},
+					&cli.StringFlag{
+						Name:  "node",
+						Usage: "node ID of the object to browse",
+						Value: "i=85",
+					},
+					&cli.IntFlag{
+						Name:  "depth",
+						Usage: "maximum depth of objects to browse, zero means unlimited",
+						Value: 0,
+					},
+					&cli.StringFlag{
+						Name:  "plugin",
+						Usage: "input plugin to generate the configuration for, 'opcua' or 'opcua_listener'",
+						Value: "opcua",
+					},
+					&cli.StringFlag{
+						Name:  "name",
+						Usage: "metric name of the generated group",
+						Value: "opcua",
+					},
+					&cli.StringFlag{
+						Name:  "security-policy",
+						Usage: "security policy, one of 'None', 'Basic128Rsa15', 'Basic256', 'Basic256Sha256', or 'auto'",
+						Value: "auto",
+					},
+					&cli.StringFlag{
+						Name:  "security-mode",
+						Usage: "security mode, one of 'None', 'Sign', 'SignAndEncrypt', or 'auto'",
+						Value: "auto",
+					},
+					&cli.StringFlag{
+						Name:  "certificate",
+						Usage: "path to the client certificate, a self-signed certificate is generated if empty",
+					},
+					&cli.StringFlag{
+						Name:  "private-key",
+						Usage: "path to the client private key, a key is generated if empty",
+					},
+					&cli.StringFlag{
+						Name:  "server-cert-thumbprint",
+						Usage: "hex-encoded SHA-1 or SHA-256 thumbprint of the server certificate to accept",
+					},
+					&cli.StringFlag{
+						Name:  "auth-method",
+						Usage: "authentication method, one of 'Certificate', 'UserName', or 'Anonymous'",
+						Value: "Anonymous",
+					},
+					&cli.StringFlag{
+						Name:  "username",
+						Usage: "username for the 'UserName' authentication method",
+					},
+					&cli.StringFlag{
+						Name:  "password",
+						Usage: "password for the 'UserName' authentication method",
+					},
+					&cli.DurationFlag{
+						Name:  "timeout",
+						Usage: "timeout for connecting and each request",
+						Value: 10 * time.Second,
+					},
+				},
+				Action: func(cCtx *cli.Context) error {
+					switch cCtx.String("plugin") {
+					case "opcua", "opcua_listener":
+					default:
+						return fmt.Errorf("invalid plugin %q", cCtx.String("plugin"))
+					}
+
+					root, err := ua.ParseNodeID(cCtx.String("node"))
+					if err != nil {
+						return fmt.Errorf("invalid node %q: %w", cCtx.String("node"), err)
+					}
+
+					username := config.NewSecret([]byte(cCtx.String("username")))
+					password := config.NewSecret([]byte(cCtx.String("password")))
+					cfg := &opcua.OpcUAClientConfig{
+						Endpoint:       cCtx.String("endpoint"),
+						SecurityPolicy: cCtx.String("security-policy"),
+						SecurityMode:   cCtx.String("security-mode"),
+						Certificate:    cCtx.String("certificate"),
+						PrivateKey:     cCtx.String("private-key"),
+						AuthMethod:     cCtx.String("auth-method"),
+						Username:       username,
+						Password:       password,
+						ConnectTimeout: config.Duration(cCtx.Duration("timeout")),
+						RequestTimeout: config.Duration(cCtx.Duration("timeout")),
+					}
+					client, err := cfg.CreateClient(logger.New("opcua", "browse", ""))
+					if err != nil {
+						return err
+					}
+
+					ctx := context.Background()
+					if err := client.Connect(ctx); err != nil {
+						return err
+					}
+					defer client.Disconnect(ctx) //nolint:errcheck // ignore error on exit
+
+					variables, err := client.BrowseVariables(ctx, root, cCtx.Int("depth"))
+					if err != nil {
+						return err
+					}
+					if len(variables) == 0 {
+						return fmt.Errorf("no variables found below %q", root)
+					}
+
+					return opcua.WriteNodeConfig(outputBuffer, cCtx.String("plugin"), cCtx.String("name"), variables)
+				},
+			},
+		},
+	}
+}
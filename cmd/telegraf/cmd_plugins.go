@@ -27,8 +27,12 @@ func pluginNames[M ~map[string]V, V any](m M, prefix string) []byte {
 	return []byte(strings.Join(names, ""))
 }
 
+// pluginSubcommands contains the constructors of plugin specific commands
+// registered depending on the plugins included in the build
+var pluginSubcommands []func(io.Writer) *cli.Command
+
 func getPluginCommands(outputBuffer io.Writer) []*cli.Command {
-	return []*cli.Command{
+	commands := []*cli.Command{
 		{
 			Name:  "plugins",
 			Usage: "commands for printing available plugins",
@@ -189,4 +193,10 @@ func getPluginCommands(outputBuffer io.Writer) []*cli.Command {
 			},
 		},
 	}
+
+	for _, subcommand := range pluginSubcommands {
+		commands[0].Subcommands = append(commands[0].Subcommands, subcommand(outputBuffer))
+	}
+
+	return commands
 }
@@ -0,0 +1,45 @@
+package fuzz
+
+// LineProtocolDictionary contains the syntax elements of the InfluxDB line
+// protocol
+var LineProtocolDictionary = []string{
+	" ",
+	",",
+	"=",
+	"\\",
+	"\\ ",
+	"\\,",
+	"\\=",
+	"\"",
+	"\\\"",
+	"\n",
+	"\r\n",
+	"#",
+	"i",
+	"u",
+	"t",
+	"f",
+	"true",
+	"false",
+	"T",
+	"F",
+	"-",
+	"+",
+	".",
+	"e",
+	"E",
+	"1e308",
+	"NaN",
+	"Inf",
+	"9223372036854775807i",
+	"-9223372036854775808i",
+	"9223372036854775808i",
+	"18446744073709551615u",
+	"18446744073709551616u",
+	"9223372036854775807",
+	"-9223372036854775808",
+	"cpu",
+	"cpu,host=a",
+	"cpu value=1",
+	"value=\"\"",
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/sleepinggenius2/gosmi"
 
@@ -13,10 +14,16 @@ import (
 
 // must init, append path for each directory, load module for every file
 // or gosmi will fail without saying why
-var m sync.Mutex
+var m sync.RWMutex
 var once sync.Once
 var cache = make(map[string]bool)
 
+// Folders appended to the search path, the module files loaded and the
+// modification time of files failed to load for reloading MIBs
+var appended = make(map[string]bool)
+var loaded = make(map[string]bool)
+var failed = make(map[string]time.Time)
+
 type MibLoader interface {
 	// appendPath takes the path of a directory
 	appendPath(path string)
@@ -49,8 +56,41 @@ func LoadMibsFromPath(paths []string, log telegraf.Logger, loader MibLoader) err
 	if err != nil {
 		return err
 	}
+	loadFolders(folders, log, loader)
+	return nil
+}
+
+// ReloadMibsFromPath walks the given paths again and loads the modules of
+// files added since the paths were loaded, e.g. when deploying new MIBs at
+// runtime. Modules already loaded are not updated. The function returns the
+// number of newly loaded modules.
+func ReloadMibsFromPath(paths []string, log telegraf.Logger, loader MibLoader) (int, error) {
+	m.Lock()
+	for _, mibPath := range paths {
+		delete(cache, mibPath)
+	}
+	m.Unlock()
+
+	folders, err := walkPaths(paths, log)
+	if err != nil {
+		return 0, err
+	}
+	return loadFolders(folders, log, loader), nil
+}
+
+// loadFolders appends the folders to the search path and loads the modules
+// not loaded before returning the number of loaded modules
+func loadFolders(folders []string, log telegraf.Logger, loader MibLoader) int {
+	var count int
 	for _, path := range folders {
-		loader.appendPath(path)
+		m.Lock()
+		isAppended := appended[path]
+		appended[path] = true
+		m.Unlock()
+		if !isAppended {
+			loader.appendPath(path)
+		}
+
 		modules, err := os.ReadDir(path)
 		if err != nil {
 			log.Warnf("Can't read directory %v", modules)
@@ -58,6 +98,7 @@ func LoadMibsFromPath(paths []string, log telegraf.Logger, loader MibLoader) err
 		}
 
 		for _, entry := range modules {
+			fn := filepath.Join(path, entry.Name())
 			info, err := entry.Info()
 			if err != nil {
 				log.Warnf("Couldn't get info for %v: %v", entry.Name(), err)
@@ -77,16 +118,37 @@ func LoadMibsFromPath(paths []string, log telegraf.Logger, loader MibLoader) err
 					continue
 				}
 			}
-			if info.Mode().IsRegular() {
-				err := loader.loadModule(info.Name())
-				if err != nil {
-					log.Warnf("Couldn't load module %v: %v", info.Name(), err)
-					continue
-				}
+			if !info.Mode().IsRegular() {
+				continue
+			}
+
+			// Skip modules already loaded and modules failed to load
+			// unless the file was modified since
+			m.RLock()
+			isLoaded := loaded[fn]
+			failedAt, isFailed := failed[fn]
+			m.RUnlock()
+			if isLoaded || isFailed && info.ModTime().Equal(failedAt) {
+				continue
 			}
+
+			err = loader.loadModule(info.Name())
+			m.Lock()
+			if err != nil {
+				failed[fn] = info.ModTime()
+			} else {
+				loaded[fn] = true
+				delete(failed, fn)
+			}
+			m.Unlock()
+			if err != nil {
+				log.Warnf("Couldn't load module %v: %v", info.Name(), err)
+				continue
+			}
+			count++
 		}
 	}
-	return nil
+	return count
 }
 
 // should walk the paths given and find all folders
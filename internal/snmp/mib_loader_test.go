@@ -1,6 +1,7 @@
 package snmp
 
 import (
+	"os"
 	"path/filepath"
 	"runtime"
 	"testing"
@@ -72,6 +73,33 @@ func TestFolderLookup(t *testing.T) {
 	}
 }
 
+func TestReloadMibsFromPath(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "vendor")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "FIRST-MIB"), nil, 0600))
+
+	loader := TestingMibLoader{}
+	require.NoError(t, LoadMibsFromPath([]string{dir}, testutil.Logger{}, &loader))
+	require.Equal(t, []string{dir}, loader.folders)
+	require.Equal(t, []string{"FIRST-MIB"}, loader.files)
+
+	// Nothing changed
+	n, err := ReloadMibsFromPath([]string{dir}, testutil.Logger{}, &loader)
+	require.NoError(t, err)
+	require.Zero(t, n)
+
+	// Deploy new MIBs into the existing and a new folder
+	require.NoError(t, os.Mkdir(sub, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "SECOND-MIB"), nil, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(sub, "VENDOR-MIB"), nil, 0600))
+
+	n, err = ReloadMibsFromPath([]string{dir}, testutil.Logger{}, &loader)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, []string{dir, sub}, loader.folders)
+	require.Equal(t, []string{"FIRST-MIB", "SECOND-MIB", "VENDOR-MIB"}, loader.files)
+}
+
 func TestMissingMibPath(t *testing.T) {
 	log := testutil.Logger{}
 	path := []string{"non-existing-directory"}
@@ -213,6 +213,10 @@ type MibEntry struct {
 }
 
 func TrapLookup(oid string) (e MibEntry, err error) {
+	// Prevent lookups while modules are being loaded
+	m.RLock()
+	defer m.RUnlock()
+
 	var givenOid types.Oid
 	if givenOid, err = types.OidFromString(oid); err != nil {
 		return e, fmt.Errorf("could not convert OID %s: %w", oid, err)
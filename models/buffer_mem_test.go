@@ -7,6 +7,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
 )
 
 func TestMemoryBufferAcceptCallsMetricAccept(t *testing.T) {
@@ -44,3 +45,25 @@ func BenchmarkMemoryBufferAddMetrics(b *testing.B) {
 		buf.Add(m)
 	}
 }
+
+func BenchmarkMemoryBufferAddHighCardinality(b *testing.B) {
+	buf, err := NewBuffer("test", "123", "", 10000, "memory", "")
+	require.NoError(b, err)
+	buf.Stats().MetricsAdded.Set(0)
+	buf.Stats().MetricsWritten.Set(0)
+	buf.Stats().MetricsDropped.Set(0)
+	defer buf.Close()
+
+	g := &testutil.MetricGenerator{
+		Series:     10000,
+		Tags:       4,
+		Fields:     4,
+		FieldTypes: []testutil.FieldType{testutil.FieldFloat, testutil.FieldInt, testutil.FieldString, testutil.FieldBool},
+		Timestamps: testutil.TimestampsJitter,
+	}
+	metrics := g.Generate(10000)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		buf.Add(metrics[n%len(metrics)])
+	}
+}
@@ -83,6 +83,24 @@ func (r *RunningParser) Parse(buf []byte) ([]telegraf.Metric, error) {
 	return m, err
 }
 
+// ParseWithTopic passes the topic to the parser if it implements the
+// telegraf.TopicParser interface and parses the buffer without the topic
+// otherwise
+func (r *RunningParser) ParseWithTopic(topic string, buf []byte) ([]telegraf.Metric, error) {
+	p, ok := r.Parser.(telegraf.TopicParser)
+	if !ok {
+		return r.Parse(buf)
+	}
+
+	start := time.Now()
+	m, err := p.ParseWithTopic(topic, buf)
+	elapsed := time.Since(start)
+	r.ParseTime.Incr(elapsed.Nanoseconds())
+	r.MetricsParsed.Incr(int64(len(m)))
+
+	return m, err
+}
+
 func (r *RunningParser) ParseLine(line string) (telegraf.Metric, error) {
 	start := time.Now()
 	m, err := r.Parser.ParseLine(line)
@@ -0,0 +1,76 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/models"
+)
+
+func TestRunningParserParseWithTopic(t *testing.T) {
+	mock := &mockTopicParser{}
+	rp := models.NewRunningParser(mock, &models.ParserConfig{DataFormat: "topic_mock", Parent: t.Name()})
+
+	// Inputs only see the running parser so it must pass the topic on
+	var parser telegraf.Parser = rp
+	tp, ok := parser.(telegraf.TopicParser)
+	require.True(t, ok)
+
+	metrics, err := tp.ParseWithTopic("devices/dev1", []byte("42"))
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	require.Equal(t, []string{"devices/dev1"}, mock.topics)
+	require.Equal(t, "devices/dev1", metrics[0].Tags()["topic"])
+	require.Equal(t, int64(1), rp.MetricsParsed.Get())
+}
+
+func TestRunningParserParseWithTopicFallback(t *testing.T) {
+	mock := &mockParser{}
+	rp := models.NewRunningParser(mock, &models.ParserConfig{DataFormat: "mock", Parent: t.Name()})
+
+	// Parsers not requiring the topic parse the buffer only
+	metrics, err := rp.ParseWithTopic("devices/dev1", []byte("42"))
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	require.Empty(t, metrics[0].Tags())
+	require.Equal(t, 1, mock.calls)
+	require.Equal(t, int64(1), rp.MetricsParsed.Get())
+}
+
+type mockParser struct {
+	calls int
+}
+
+func (p *mockParser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	p.calls++
+	m := metric.New("mock", map[string]string{}, map[string]interface{}{"value": string(buf)}, time.Unix(0, 0))
+	return []telegraf.Metric{m}, nil
+}
+
+func (p *mockParser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+	return metrics[0], nil
+}
+
+func (*mockParser) SetDefaultTags(map[string]string) {}
+
+type mockTopicParser struct {
+	mockParser
+	topics []string
+}
+
+func (p *mockTopicParser) ParseWithTopic(topic string, buf []byte) ([]telegraf.Metric, error) {
+	p.topics = append(p.topics, topic)
+	metrics, err := p.Parse(buf)
+	for _, m := range metrics {
+		m.AddTag("topic", topic)
+	}
+	return metrics, err
+}
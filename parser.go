@@ -22,6 +22,18 @@ type Parser interface {
 	SetDefaultTags(tags map[string]string)
 }
 
+// TopicParser is an interface for parsers requiring the topic a message was
+// received on, e.g. because the topic identifies the source of the message.
+// Plugins receiving messages on topics should prefer this function if the
+// parser implements it.
+type TopicParser interface {
+	// ParseWithTopic parses the buffer received on the given topic into
+	// telegraf metrics.
+	//
+	// Must be thread-safe.
+	ParseWithTopic(topic string, buf []byte) ([]Metric, error)
+}
+
 // ParserFunc is a function to create a new instance of a parser
 type ParserFunc func() (Parser, error)
 
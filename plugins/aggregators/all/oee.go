@@ -0,0 +1,5 @@
+//go:build !custom || aggregators || aggregators.oee
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/aggregators/oee" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || aggregators || aggregators.session
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/aggregators/session" // register plugin
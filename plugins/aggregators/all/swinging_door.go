@@ -0,0 +1,5 @@
+//go:build !custom || aggregators || aggregators.swinging_door
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/aggregators/swinging_door" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || aggregators || aggregators.tdigest
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/aggregators/tdigest" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || aggregators || aggregators.timeweighted
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/aggregators/timeweighted" // register plugin
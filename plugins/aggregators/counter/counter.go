@@ -0,0 +1,161 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package counter
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/plugins/aggregators"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Counter struct {
+	Suffix   string          `toml:"suffix"`
+	MaxValue float64         `toml:"max_value"`
+	OnReset  string          `toml:"on_reset"`
+	Log      telegraf.Logger `toml:"-"`
+
+	cache map[uint64]*series
+}
+
+// series holds the last counter values of a series across periods, the
+// fields are exported to allow persisting the state
+type series struct {
+	Name string             `json:"name"`
+	Tags map[string]string  `json:"tags"`
+	Last map[string]float64 `json:"last"`
+
+	deltas map[string]float64
+}
+
+func (*Counter) SampleConfig() string {
+	return sampleConfig
+}
+
+func (a *Counter) Init() error {
+	if a.Suffix == "" {
+		return errors.New("'suffix' must not be empty")
+	}
+	if a.MaxValue < 0 {
+		return errors.New("'max_value' must not be negative")
+	}
+	if a.OnReset == "" {
+		a.OnReset = "count"
+	}
+	if err := choice.Check(a.OnReset, []string{"count", "skip"}); err != nil {
+		return fmt.Errorf("invalid 'on_reset': %w", err)
+	}
+
+	a.cache = make(map[uint64]*series)
+
+	return nil
+}
+
+func (a *Counter) Add(in telegraf.Metric) {
+	id := in.HashID()
+	s, found := a.cache[id]
+	if !found {
+		s = &series{
+			Name: in.Name(),
+			Tags: in.Tags(),
+			Last: make(map[string]float64),
+		}
+		a.cache[id] = s
+	}
+	if s.deltas == nil {
+		s.deltas = make(map[string]float64)
+	}
+
+	for _, field := range in.FieldList() {
+		v, ok := convert(field.Value)
+		if !ok {
+			continue
+		}
+		last, found := s.Last[field.Key]
+		s.Last[field.Key] = v
+		if !found {
+			// The first value only serves as baseline
+			continue
+		}
+		s.deltas[field.Key] += a.delta(last, v, in.Name(), field.Key)
+	}
+}
+
+func (a *Counter) Push(acc telegraf.Accumulator) {
+	for _, s := range a.cache {
+		if len(s.deltas) == 0 {
+			continue
+		}
+		fields := make(map[string]interface{}, len(s.deltas))
+		for key, delta := range s.deltas {
+			fields[key+a.Suffix] = delta
+		}
+		acc.AddFields(s.Name, fields, s.Tags)
+	}
+}
+
+func (a *Counter) Reset() {
+	for _, s := range a.cache {
+		s.deltas = nil
+	}
+}
+
+func (a *Counter) GetState() interface{} {
+	return a.cache
+}
+
+func (a *Counter) SetState(state interface{}) error {
+	s, ok := state.(map[uint64]*series)
+	if !ok {
+		return fmt.Errorf("state has wrong type %T", state)
+	}
+	a.cache = s
+	return nil
+}
+
+// delta returns the increase of the counter between the last and the current
+// value taking rollovers and resets into account
+func (a *Counter) delta(last, current float64, name, key string) float64 {
+	if current >= last {
+		return current - last
+	}
+
+	// Consider a decrease a rollover if the counter was in the upper half of
+	// its range and the wrapped increase is plausible, i.e. less than half of
+	// the range
+	if a.MaxValue > 0 && last > a.MaxValue/2 && last <= a.MaxValue {
+		if wrapped := a.MaxValue - last + current + 1; wrapped < a.MaxValue/2 {
+			return wrapped
+		}
+	}
+
+	a.Log.Debugf("Reset of counter %q in %q detected", key, name)
+	if a.OnReset == "skip" {
+		return 0
+	}
+	return current
+}
+
+func convert(in interface{}) (float64, bool) {
+	switch v := in.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func init() {
+	aggregators.Add("counter", func() telegraf.Aggregator {
+		return &Counter{Suffix: "_delta"}
+	})
+}
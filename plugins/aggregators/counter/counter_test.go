@@ -0,0 +1,186 @@
+package counter
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Counter
+		expected string
+	}{
+		{
+			name:     "no suffix",
+			plugin:   &Counter{},
+			expected: "'suffix' must not be empty",
+		},
+		{
+			name:     "negative max value",
+			plugin:   &Counter{Suffix: "_delta", MaxValue: -1},
+			expected: "'max_value' must not be negative",
+		},
+		{
+			name:     "invalid reset handling",
+			plugin:   &Counter{Suffix: "_delta", OnReset: "drop"},
+			expected: "invalid 'on_reset'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := func(fields map[string]interface{}) telegraf.Metric {
+		return metric.New("meter", map[string]string{"id": "m1"}, fields, now)
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *Counter
+		periods  [][]telegraf.Metric
+		expected [][]telegraf.Metric
+	}{
+		{
+			name:   "deltas",
+			plugin: &Counter{},
+			periods: [][]telegraf.Metric{
+				{
+					m(map[string]interface{}{"energy": uint64(100), "status": "ok"}),
+					m(map[string]interface{}{"energy": uint64(110)}),
+					m(map[string]interface{}{"energy": uint64(125), "parts": int64(7)}),
+				},
+				{
+					m(map[string]interface{}{"energy": uint64(130), "parts": int64(7)}),
+				},
+				{},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					m(map[string]interface{}{"energy_delta": 25.0}),
+				},
+				{
+					m(map[string]interface{}{"energy_delta": 5.0, "parts_delta": 0.0}),
+				},
+				nil,
+			},
+		},
+		{
+			name:   "rollover",
+			plugin: &Counter{MaxValue: 65535},
+			periods: [][]telegraf.Metric{
+				{
+					m(map[string]interface{}{"count": int64(65000)}),
+					m(map[string]interface{}{"count": int64(65530)}),
+					m(map[string]interface{}{"count": int64(100)}),
+				},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					m(map[string]interface{}{"count_delta": 636.0}),
+				},
+			},
+		},
+		{
+			name:   "reset",
+			plugin: &Counter{MaxValue: 65535},
+			periods: [][]telegraf.Metric{
+				{
+					m(map[string]interface{}{"count": int64(40000)}),
+					m(map[string]interface{}{"count": int64(40020)}),
+					m(map[string]interface{}{"count": int64(39000)}),
+					m(map[string]interface{}{"count": int64(39010)}),
+				},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					m(map[string]interface{}{"count_delta": 39030.0}),
+				},
+			},
+		},
+		{
+			name:   "skip reset",
+			plugin: &Counter{OnReset: "skip", Suffix: "_consumption"},
+			periods: [][]telegraf.Metric{
+				{
+					m(map[string]interface{}{"energy": 500.5}),
+					m(map[string]interface{}{"energy": 520.5}),
+					m(map[string]interface{}{"energy": 10.0}),
+					m(map[string]interface{}{"energy": 12.5}),
+				},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					m(map[string]interface{}{"energy_consumption": 22.5}),
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.plugin.Suffix == "" {
+				tt.plugin.Suffix = "_delta"
+			}
+			tt.plugin.Log = &testutil.Logger{}
+			require.NoError(t, tt.plugin.Init())
+
+			for i, period := range tt.periods {
+				var acc testutil.Accumulator
+				for _, m := range period {
+					tt.plugin.Add(m)
+				}
+				tt.plugin.Push(&acc)
+				tt.plugin.Reset()
+
+				testutil.RequireMetricsEqual(t, tt.expected[i], acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+			}
+		})
+	}
+}
+
+func TestStatePersistence(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	plugin := &Counter{Suffix: "_delta", Log: &testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+	plugin.Add(metric.New("meter", map[string]string{"id": "m1"}, map[string]interface{}{"energy": int64(100)}, now))
+	plugin.Add(metric.New("meter", map[string]string{"id": "m1"}, map[string]interface{}{"energy": int64(150)}, now))
+
+	// Serialize the state as done by the persister
+	var pi telegraf.StatefulPlugin = plugin
+	serialized, err := json.Marshal(pi.GetState())
+	require.NoError(t, err)
+
+	// Restore the state in a new instance
+	restored := &Counter{Suffix: "_delta", Log: &testutil.Logger{}}
+	require.NoError(t, restored.Init())
+	var state map[uint64]*series
+	require.NoError(t, json.Unmarshal(serialized, &state))
+	require.NoError(t, restored.SetState(state))
+
+	// The delta must continue from the last value before the restart
+	restored.Add(metric.New("meter", map[string]string{"id": "m1"}, map[string]interface{}{"energy": int64(175)}, now))
+	var acc testutil.Accumulator
+	restored.Push(&acc)
+
+	expected := []telegraf.Metric{
+		metric.New("meter", map[string]string{"id": "m1"}, map[string]interface{}{"energy_delta": 25.0}, now),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+
+	require.ErrorContains(t, restored.SetState("foo"), "state has wrong type string")
+}
@@ -0,0 +1,165 @@
+package histogram
+
+import (
+	"math"
+)
+
+// defaultMaxBuckets is the default maximum number of buckets per sign of an
+// exponential histogram as recommended by OpenTelemetry
+const defaultMaxBuckets = 160
+
+// maxScale is the initial scale of exponential histograms, the histogram is
+// downscaled automatically to fit the values into the maximum bucket number
+const maxScale = 20
+
+// exponentialHistogram is a base-2 exponential histogram as defined by
+// OpenTelemetry. The boundaries of bucket index i are (base^i, base^(i+1)]
+// with base = 2^(2^-scale).
+type exponentialHistogram struct {
+	maxBuckets int
+	scale      int
+	count      uint64
+	sum        float64
+	zeroCount  uint64
+	positive   exponentialBuckets
+	negative   exponentialBuckets
+}
+
+// exponentialBuckets contains the counts of consecutive bucket indices
+// starting at offset
+type exponentialBuckets struct {
+	offset int
+	counts []uint64
+}
+
+func newExponentialHistogram(maxBuckets int) *exponentialHistogram {
+	if maxBuckets == 0 {
+		maxBuckets = defaultMaxBuckets
+	}
+	return &exponentialHistogram{maxBuckets: maxBuckets, scale: maxScale}
+}
+
+// add records the value in the histogram, downscaling if necessary
+func (h *exponentialHistogram) add(value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+
+	h.count++
+	h.sum += value
+	if value == 0 {
+		h.zeroCount++
+		return
+	}
+
+	b := &h.positive
+	if value < 0 {
+		b = &h.negative
+		value = -value
+	}
+
+	index := mapToIndex(value, h.scale)
+	if len(b.counts) > 0 {
+		low, high := min(b.offset, index), max(b.offset+len(b.counts)-1, index)
+		var change int
+		for (high>>change)-(low>>change)+1 > h.maxBuckets {
+			change++
+		}
+		if change > 0 {
+			h.downscale(change)
+			index >>= change
+		}
+	}
+	b.increment(index)
+}
+
+// downscale reduces the scale by the given change merging neighboring buckets
+func (h *exponentialHistogram) downscale(change int) {
+	h.scale -= change
+	h.positive.downscale(change)
+	h.negative.downscale(change)
+}
+
+// exponentialBucket identifies a bucket of an exponential histogram by the
+// sign of the values and the bucket index, the zero value identifies the
+// summary of the histogram
+type exponentialBucket struct {
+	sign  string
+	index int
+}
+
+// fields returns the fields of the histogram for the given field name grouped
+// by bucket. Each bucket contains the scale, the offset of the buckets with
+// the same sign and the (non-cumulative) count of the bucket. The summary
+// contains the scale, sum, count and the count of zero values.
+func (h *exponentialHistogram) fields(field string) map[exponentialBucket]map[string]interface{} {
+	grouped := make(map[exponentialBucket]map[string]interface{}, len(h.positive.counts)+len(h.negative.counts)+1)
+	grouped[exponentialBucket{}] = map[string]interface{}{
+		field + "_scale":      int64(h.scale),
+		field + "_sum":        h.sum,
+		field + "_count":      int64(h.count),
+		field + "_zero_count": int64(h.zeroCount),
+	}
+	for sign, b := range map[string]*exponentialBuckets{"positive": &h.positive, "negative": &h.negative} {
+		for i, count := range b.counts {
+			grouped[exponentialBucket{sign: sign, index: b.offset + i}] = map[string]interface{}{
+				field + "_scale":  int64(h.scale),
+				field + "_offset": int64(b.offset),
+				field + "_bucket": int64(count),
+			}
+		}
+	}
+	return grouped
+}
+
+func (b *exponentialBuckets) increment(index int) {
+	switch {
+	case len(b.counts) == 0:
+		b.offset = index
+		b.counts = []uint64{0}
+	case index < b.offset:
+		counts := make([]uint64, b.offset-index, b.offset-index+len(b.counts))
+		b.counts = append(counts, b.counts...)
+		b.offset = index
+	case index >= b.offset+len(b.counts):
+		b.counts = append(b.counts, make([]uint64, index-b.offset-len(b.counts)+1)...)
+	}
+	b.counts[index-b.offset]++
+}
+
+func (b *exponentialBuckets) downscale(change int) {
+	if len(b.counts) == 0 {
+		return
+	}
+	offset := b.offset >> change
+	counts := make([]uint64, ((b.offset+len(b.counts)-1)>>change)-offset+1)
+	for i, count := range b.counts {
+		counts[((b.offset+i)>>change)-offset] += count
+	}
+	b.offset = offset
+	b.counts = counts
+}
+
+// mapToIndex returns the index of the bucket containing the given positive
+// value at the given scale
+func mapToIndex(value float64, scale int) int {
+	frac, exp := math.Frexp(value)
+	// Exact powers of two are the inclusive upper boundary of a bucket
+	exact := frac == 0.5
+	if scale <= 0 {
+		exp--
+		if exact {
+			exp--
+		}
+		return exp >> -scale
+	}
+	if exact {
+		return ((exp - 1) << scale) - 1
+	}
+	return int(math.Ceil(math.Log(value)*math.Ldexp(math.Log2E, scale))) - 1
+}
+
+// lowerBoundary returns the lower boundary of the bucket with the given index
+func lowerBoundary(index, scale int) float64 {
+	return math.Exp2(math.Ldexp(float64(index), -scale))
+}
@@ -0,0 +1,96 @@
+package histogram
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExponentialMapToIndex(t *testing.T) {
+	tests := []struct {
+		value    float64
+		scale    int
+		expected int
+	}{
+		{value: 1, scale: 0, expected: -1},
+		{value: 1.5, scale: 0, expected: 0},
+		{value: 2, scale: 0, expected: 0},
+		{value: 3, scale: 0, expected: 1},
+		{value: 0.25, scale: 0, expected: -3},
+		{value: 2, scale: 1, expected: 1},
+		{value: 1.5, scale: 1, expected: 1},
+		{value: 1.3, scale: 1, expected: 0},
+		{value: 4, scale: -1, expected: 0},
+		{value: 5, scale: -1, expected: 1},
+		{value: 1000, scale: -2, expected: 2},
+		{value: 1, scale: 20, expected: -1},
+		{value: 2, scale: 20, expected: 1<<20 - 1},
+	}
+
+	for _, tt := range tests {
+		index := mapToIndex(tt.value, tt.scale)
+		require.Equal(t, tt.expected, index, "value %v at scale %d", tt.value, tt.scale)
+
+		// The value must be within the boundaries of the bucket
+		require.Greater(t, tt.value, lowerBoundary(index, tt.scale)*(1-1e-12))
+		require.LessOrEqual(t, tt.value, lowerBoundary(index+1, tt.scale)*(1+1e-12))
+	}
+}
+
+func TestExponentialDownscale(t *testing.T) {
+	h := newExponentialHistogram(4)
+	for _, v := range []float64{1, 2, 4, 8, 16, 32, 0, -3, math.NaN()} {
+		h.add(v)
+	}
+
+	// Values from 1 to 32 require six buckets at scale zero, so two
+	// neighboring buckets need to be merged
+	require.Equal(t, -1, h.scale)
+	require.Equal(t, uint64(8), h.count)
+	require.Equal(t, uint64(1), h.zeroCount)
+	require.InDelta(t, 60.0, h.sum, 1e-9)
+	require.Equal(t, exponentialBuckets{offset: -1, counts: []uint64{1, 2, 2, 1}}, h.positive)
+	require.Equal(t, exponentialBuckets{offset: 0, counts: []uint64{1}}, h.negative)
+
+	fields := h.fields("value")
+	require.Len(t, fields, 6)
+	require.Equal(t, map[string]interface{}{
+		"value_scale":      int64(-1),
+		"value_sum":        60.0,
+		"value_count":      int64(8),
+		"value_zero_count": int64(1),
+	}, fields[exponentialBucket{}])
+	require.Equal(t, map[string]interface{}{
+		"value_scale":  int64(-1),
+		"value_offset": int64(-1),
+		"value_bucket": int64(2),
+	}, fields[exponentialBucket{sign: "positive", index: 1}])
+	require.Equal(t, map[string]interface{}{
+		"value_scale":  int64(-1),
+		"value_offset": int64(0),
+		"value_bucket": int64(1),
+	}, fields[exponentialBucket{sign: "negative", index: 0}])
+}
+
+func TestExponentialNegativePowers(t *testing.T) {
+	h := newExponentialHistogram(4)
+	for _, v := range []float64{-2, -4, -8, -8} {
+		h.add(v)
+	}
+
+	// The magnitude of exact powers of the base is the inclusive upper
+	// boundary of a bucket for negative values as well
+	require.Equal(t, 0, h.scale)
+	require.Empty(t, h.positive.counts)
+	require.Equal(t, exponentialBuckets{offset: 0, counts: []uint64{1, 1, 2}}, h.negative)
+	for i, magnitude := range []float64{2, 4, 8} {
+		index := h.negative.offset + i
+		require.Less(t, lowerBoundary(index, h.scale), magnitude)
+		require.Equal(t, lowerBoundary(index+1, h.scale), magnitude)
+	}
+
+	fields := h.fields("value")
+	require.Len(t, fields, 4)
+	require.Equal(t, int64(2), fields[exponentialBucket{sign: "negative", index: 2}]["value_bucket"])
+}
@@ -3,6 +3,8 @@ package histogram
 
 import (
 	_ "embed"
+	"errors"
+	"fmt"
 	"sort"
 	"strconv"
 	"time"
@@ -27,6 +29,14 @@ const bucketLeftTag = "gt"
 // bucketNegInf is the left bucket border for infinite values
 const bucketNegInf = "-Inf"
 
+// bucketSignTag is the tag, which contains the sign of the values of an
+// exponential histogram bucket
+const bucketSignTag = "sign"
+
+// bucketIndexTag is the tag, which contains the index of an exponential
+// histogram bucket
+const bucketIndexTag = "index"
+
 // HistogramAggregator is aggregator with histogram configs and particular histograms for defined metrics
 type HistogramAggregator struct {
 	Configs            []bucketConfig  `toml:"config"`
@@ -41,9 +51,11 @@ type HistogramAggregator struct {
 
 // bucketConfig is the config, which contains name, field of metric and histogram buckets.
 type bucketConfig struct {
-	Metric  string   `toml:"measurement_name"`
-	Fields  []string `toml:"fields"`
-	Buckets buckets  `toml:"buckets"`
+	Metric     string   `toml:"measurement_name"`
+	Fields     []string `toml:"fields"`
+	Buckets    buckets  `toml:"buckets"`
+	Type       string   `toml:"type"`
+	MaxBuckets int      `toml:"max_buckets"`
 }
 
 // bucketsByMetrics contains the buckets grouped by metric and field name
@@ -58,6 +70,7 @@ type buckets []float64
 // metricHistogramCollection aggregates the histogram data
 type metricHistogramCollection struct {
 	histogramCollection map[string]counts
+	exponential         map[string]*exponentialHistogram
 	name                string
 	tags                map[string]string
 	expireTime          time.Time
@@ -91,19 +104,42 @@ func (*HistogramAggregator) SampleConfig() string {
 	return sampleConfig
 }
 
+func (h *HistogramAggregator) Init() error {
+	for _, cfg := range h.Configs {
+		switch cfg.Type {
+		case "", "explicit":
+		case "exponential":
+			if len(cfg.Buckets) > 0 {
+				return fmt.Errorf("'buckets' not allowed for exponential histogram of %q", cfg.Metric)
+			}
+		default:
+			return fmt.Errorf("invalid 'type' %q for %q", cfg.Type, cfg.Metric)
+		}
+		if cfg.MaxBuckets < 0 || cfg.MaxBuckets == 1 {
+			return errors.New("'max_buckets' must be at least two")
+		}
+	}
+	return nil
+}
+
 // Add adds new hit to the buckets
 func (h *HistogramAggregator) Add(in telegraf.Metric) {
 	addTime := timeNow()
 
 	bucketsByField := make(map[string][]float64)
+	exponentialByField := make(map[string]*bucketConfig)
 	for field := range in.Fields() {
+		if cfg := h.getExponentialConfig(in.Name(), field); cfg != nil {
+			exponentialByField[field] = cfg
+			continue
+		}
 		buckets := h.getBuckets(in.Name(), field)
 		if buckets != nil {
 			bucketsByField[field] = buckets
 		}
 	}
 
-	if len(bucketsByField) == 0 {
+	if len(bucketsByField) == 0 && len(exponentialByField) == 0 {
 		return
 	}
 
@@ -114,10 +150,26 @@ func (h *HistogramAggregator) Add(in telegraf.Metric) {
 			name:                in.Name(),
 			tags:                in.Tags(),
 			histogramCollection: make(map[string]counts),
+			exponential:         make(map[string]*exponentialHistogram),
 		}
 	}
 
 	for field, value := range in.Fields() {
+		if cfg, ok := exponentialByField[field]; ok {
+			if agr.exponential[field] == nil {
+				agr.exponential[field] = newExponentialHistogram(cfg.MaxBuckets)
+			}
+
+			if value, ok := convert(value); ok {
+				agr.exponential[field].add(value)
+			}
+			if h.ExpirationInterval != 0 {
+				agr.expireTime = addTime.Add(time.Duration(h.ExpirationInterval))
+			}
+			agr.updated = true
+			continue
+		}
+
 		if buckets, ok := bucketsByField[field]; ok {
 			if agr.histogramCollection[field] == nil {
 				agr.histogramCollection[field] = make(counts, len(buckets)+1)
@@ -154,6 +206,7 @@ func (h *HistogramAggregator) Push(acc telegraf.Accumulator) {
 		for field, counts := range aggregate.histogramCollection {
 			h.groupFieldsByBuckets(&metricsWithGroupedFields, aggregate.name, field, copyTags(aggregate.tags), counts)
 		}
+		pushExponential(acc, aggregate)
 	}
 
 	for _, metric := range metricsWithGroupedFields {
@@ -161,6 +214,31 @@ func (h *HistogramAggregator) Push(acc telegraf.Accumulator) {
 	}
 }
 
+// pushExponential adds the exponential histograms of the aggregate with one
+// metric per bucket, fields of the same bucket are grouped
+func pushExponential(acc telegraf.Accumulator, aggregate metricHistogramCollection) {
+	grouped := make(map[exponentialBucket]map[string]interface{})
+	for field, histogram := range aggregate.exponential {
+		for bucket, fields := range histogram.fields(field) {
+			if grouped[bucket] == nil {
+				grouped[bucket] = make(map[string]interface{}, len(fields))
+			}
+			for k, v := range fields {
+				grouped[bucket][k] = v
+			}
+		}
+	}
+
+	for bucket, fields := range grouped {
+		tags := copyTags(aggregate.tags)
+		if bucket.sign != "" {
+			tags[bucketSignTag] = bucket.sign
+			tags[bucketIndexTag] = strconv.Itoa(bucket.index)
+		}
+		acc.AddFields(aggregate.name, fields, tags)
+	}
+}
+
 // groupFieldsByBuckets groups fields by metric buckets which are represented as tags
 func (h *HistogramAggregator) groupFieldsByBuckets(
 	metricsWithGroupedFields *[]groupedByCountFields, name, field string, tags map[string]string, counts []int64,
@@ -229,7 +307,7 @@ func (h *HistogramAggregator) getBuckets(metric, field string) []float64 {
 	}
 
 	for _, cfg := range h.Configs {
-		if cfg.Metric == metric {
+		if cfg.Metric == metric && cfg.Type != "exponential" {
 			if !isBucketExists(field, cfg) {
 				continue
 			}
@@ -245,6 +323,17 @@ func (h *HistogramAggregator) getBuckets(metric, field string) []float64 {
 	return h.buckets[metric][field]
 }
 
+// getExponentialConfig returns the config of the exponential histogram for
+// the passed metric and field if any
+func (h *HistogramAggregator) getExponentialConfig(metric, field string) *bucketConfig {
+	for i, cfg := range h.Configs {
+		if cfg.Metric == metric && cfg.Type == "exponential" && isBucketExists(field, cfg) {
+			return &h.Configs[i]
+		}
+	}
+	return nil
+}
+
 // isBucketExists checks if buckets exists for the passed field
 func isBucketExists(field string, cfg bucketConfig) bool {
 	if len(cfg.Fields) == 0 {
@@ -485,6 +485,91 @@ func TestHistogramMetricExpiration(t *testing.T) {
 	)
 }
 
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      bucketConfig
+		expected string
+	}{
+		{
+			name:     "invalid type",
+			cfg:      bucketConfig{Metric: "first_metric_name", Type: "linear"},
+			expected: `invalid 'type' "linear"`,
+		},
+		{
+			name:     "exponential with buckets",
+			cfg:      bucketConfig{Metric: "first_metric_name", Type: "exponential", Buckets: []float64{0.0, 10.0}},
+			expected: "'buckets' not allowed for exponential histogram",
+		},
+		{
+			name:     "too few buckets",
+			cfg:      bucketConfig{Metric: "first_metric_name", Type: "exponential", MaxBuckets: 1},
+			expected: "'max_buckets' must be at least two",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			histogram := NewHistogramAggregator()
+			histogram.Configs = []bucketConfig{tt.cfg}
+			require.ErrorContains(t, histogram.Init(), tt.expected)
+		})
+	}
+}
+
+// TestHistogramExponential tests exponential histograms emitted with one metric per bucket
+func TestHistogramExponential(t *testing.T) {
+	histogram := NewHistogramAggregator()
+	histogram.Configs = []bucketConfig{
+		{Metric: "http", Fields: []string{"latency", "size"}, Type: "exponential", MaxBuckets: 2},
+		{Metric: "http", Fields: []string{"status"}, Buckets: []float64{200, 300}},
+	}
+	require.NoError(t, histogram.Init())
+
+	now := time.Unix(0, 0)
+	for _, v := range []float64{1, 2, 4, 8} {
+		histogram.Add(metric.New("http", tags{"path": "/"}, fields{"latency": v, "size": int64(3), "status": int64(200)}, now))
+	}
+
+	acc := &testutil.Accumulator{}
+	histogram.Push(acc)
+
+	// At scale -2 the latency value 1 is the upper boundary of bucket -1
+	// while the remaining values share bucket 0. The constant size stays in
+	// a single bucket at the maximum scale.
+	expected := []telegraf.Metric{
+		metric.New("http", tags{"path": "/"}, fields{
+			"latency_scale":      int64(-2),
+			"latency_sum":        15.0,
+			"latency_count":      int64(4),
+			"latency_zero_count": int64(0),
+			"size_scale":         int64(20),
+			"size_sum":           12.0,
+			"size_count":         int64(4),
+			"size_zero_count":    int64(0),
+		}, now),
+		metric.New("http", tags{"path": "/", "sign": "positive", "index": "-1"}, fields{
+			"latency_scale":  int64(-2),
+			"latency_offset": int64(-1),
+			"latency_bucket": int64(1),
+		}, now),
+		metric.New("http", tags{"path": "/", "sign": "positive", "index": "0"}, fields{
+			"latency_scale":  int64(-2),
+			"latency_offset": int64(-1),
+			"latency_bucket": int64(3),
+		}, now),
+		metric.New("http", tags{"path": "/", "sign": "positive", "index": "1661953"}, fields{
+			"size_scale":  int64(20),
+			"size_offset": int64(1661953),
+			"size_bucket": int64(4),
+		}, now),
+		metric.New("http", tags{"path": "/", "le": "200"}, fields{"status_bucket": int64(4)}, now),
+		metric.New("http", tags{"path": "/", "le": "300"}, fields{"status_bucket": int64(4)}, now),
+		metric.New("http", tags{"path": "/", "le": "+Inf"}, fields{"status_bucket": int64(4)}, now),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime(), testutil.SortMetrics())
+}
+
 // assertContainsTaggedField is help functions to test histogram data
 func assertContainsTaggedField(t *testing.T, acc *testutil.Accumulator, metricName string, fields map[string]interface{}, tags map[string]string) {
 	acc.Lock()
@@ -0,0 +1,284 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package oee
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/aggregators"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type OEE struct {
+	MachineTags         []string        `toml:"machine_tags"`
+	RunningField        string          `toml:"running_field"`
+	RunningStates       []string        `toml:"running_states"`
+	IdealCycleTime      config.Duration `toml:"ideal_cycle_time"`
+	IdealCycleTimeField string          `toml:"ideal_cycle_time_field"`
+	TotalCountField     string          `toml:"total_count_field"`
+	GoodCountField      string          `toml:"good_count_field"`
+	CountType           string          `toml:"count_type"`
+	MaxGap              config.Duration `toml:"max_gap"`
+	Measurement         string          `toml:"measurement"`
+	Log                 telegraf.Logger `toml:"-"`
+
+	runningStates map[string]bool
+	machines      map[uint64]*machine
+}
+
+// machine keeps the last state of a machine across periods and the values
+// accumulated within the current period
+type machine struct {
+	tags map[string]string
+
+	running        bool
+	stateTime      time.Time
+	hasState       bool
+	lastTotal      float64
+	hasTotal       bool
+	lastGood       float64
+	hasGood        bool
+	idealCycleTime float64
+
+	updated     bool
+	plannedTime float64
+	runningTime float64
+	total       float64
+	good        float64
+}
+
+func (*OEE) SampleConfig() string {
+	return sampleConfig
+}
+
+func (a *OEE) Init() error {
+	if a.RunningField == "" {
+		return errors.New("'running_field' must not be empty")
+	}
+	if a.TotalCountField == "" || a.GoodCountField == "" {
+		return errors.New("'total_count_field' and 'good_count_field' must not be empty")
+	}
+	if a.IdealCycleTime < 0 {
+		return errors.New("'ideal_cycle_time' must not be negative")
+	}
+	if a.IdealCycleTime == 0 && a.IdealCycleTimeField == "" {
+		return errors.New("either 'ideal_cycle_time' or 'ideal_cycle_time_field' required")
+	}
+	if a.CountType == "" {
+		a.CountType = "counter"
+	}
+	if err := choice.Check(a.CountType, []string{"counter", "delta"}); err != nil {
+		return fmt.Errorf("invalid 'count_type': %w", err)
+	}
+	if a.MaxGap < 0 {
+		return errors.New("'max_gap' must not be negative")
+	}
+	if a.Measurement == "" {
+		a.Measurement = "oee"
+	}
+
+	a.runningStates = make(map[string]bool, len(a.RunningStates))
+	for _, s := range a.RunningStates {
+		a.runningStates[s] = true
+	}
+	a.machines = make(map[uint64]*machine)
+
+	return nil
+}
+
+func (a *OEE) Add(in telegraf.Metric) {
+	id, tags, found := a.machine(in)
+	if !found {
+		return
+	}
+	m, found := a.machines[id]
+	if !found {
+		m = &machine{tags: tags}
+		a.machines[id] = m
+	}
+	ts := in.Time()
+
+	if v, found := in.GetField(a.RunningField); found {
+		if running, ok := a.isRunning(v); ok {
+			a.addState(m, running, ts)
+		} else {
+			a.Log.Debugf("Ignoring running state of type %T", v)
+		}
+	}
+
+	if v, found := in.GetField(a.TotalCountField); found {
+		if count, ok := toFloat(v); ok {
+			m.total += a.increase(count, &m.lastTotal, &m.hasTotal)
+			m.updated = true
+		}
+	}
+	if v, found := in.GetField(a.GoodCountField); found {
+		if count, ok := toFloat(v); ok {
+			m.good += a.increase(count, &m.lastGood, &m.hasGood)
+			m.updated = true
+		}
+	}
+
+	if a.IdealCycleTimeField != "" {
+		if v, found := in.GetField(a.IdealCycleTimeField); found {
+			if seconds, ok := toFloat(v); ok && seconds > 0 {
+				m.idealCycleTime = seconds
+			}
+		}
+	}
+}
+
+func (a *OEE) Push(acc telegraf.Accumulator) {
+	for _, m := range a.machines {
+		if !m.updated {
+			continue
+		}
+
+		fields := map[string]interface{}{
+			"planned_time": m.plannedTime,
+			"running_time": m.runningTime,
+			"total_count":  m.total,
+			"good_count":   m.good,
+		}
+
+		idealCycleTime := time.Duration(a.IdealCycleTime).Seconds()
+		if m.idealCycleTime > 0 {
+			idealCycleTime = m.idealCycleTime
+		}
+
+		var components int
+		availability, performance, quality := 0.0, 0.0, 0.0
+		if m.plannedTime > 0 {
+			availability = m.runningTime / m.plannedTime
+			fields["availability"] = availability
+			components++
+		}
+		if m.runningTime > 0 && idealCycleTime > 0 {
+			performance = idealCycleTime * m.total / m.runningTime
+			fields["performance"] = performance
+			components++
+		}
+		if m.total > 0 {
+			quality = m.good / m.total
+			fields["quality"] = quality
+			components++
+		}
+		if components == 3 {
+			fields["oee"] = availability * performance * quality
+		}
+
+		acc.AddFields(a.Measurement, fields, m.tags)
+	}
+}
+
+func (a *OEE) Reset() {
+	for _, m := range a.machines {
+		m.updated = false
+		m.plannedTime = 0
+		m.runningTime = 0
+		m.total = 0
+		m.good = 0
+	}
+}
+
+// machine returns the ID and tags of the machine of the metric, metrics
+// missing one of the machine tags are ignored
+func (a *OEE) machine(in telegraf.Metric) (uint64, map[string]string, bool) {
+	if len(a.MachineTags) == 0 {
+		return in.HashID(), in.Tags(), true
+	}
+
+	tags := make(map[string]string, len(a.MachineTags))
+	for _, key := range a.MachineTags {
+		v, found := in.GetTag(key)
+		if !found {
+			return 0, nil, false
+		}
+		tags[key] = v
+	}
+	return metric.New(a.Measurement, tags, nil, time.Time{}).HashID(), tags, true
+}
+
+// addState accounts the time since the last state of the machine
+func (a *OEE) addState(m *machine, running bool, ts time.Time) {
+	m.updated = true
+	if m.hasState {
+		// Ignore states older than the last state
+		if ts.Before(m.stateTime) {
+			return
+		}
+		elapsed := ts.Sub(m.stateTime)
+		if a.MaxGap == 0 || elapsed <= time.Duration(a.MaxGap) {
+			m.plannedTime += elapsed.Seconds()
+			if m.running {
+				m.runningTime += elapsed.Seconds()
+			}
+		}
+	}
+	m.running = running
+	m.stateTime = ts
+	m.hasState = true
+}
+
+// increase returns the number of parts produced according to the count type
+func (a *OEE) increase(count float64, last *float64, known *bool) float64 {
+	if a.CountType == "delta" {
+		return count
+	}
+
+	var increase float64
+	if *known {
+		increase = count - *last
+		// Counter reset, assume counting started from zero
+		if increase < 0 {
+			increase = count
+		}
+	}
+	*last = count
+	*known = true
+	return increase
+}
+
+// isRunning converts the value of the running field to the running state
+func (a *OEE) isRunning(v interface{}) (running, ok bool) {
+	switch v := v.(type) {
+	case bool:
+		return v, true
+	case string:
+		return a.runningStates[v], true
+	}
+	if f, ok := toFloat(v); ok {
+		return f != 0, true
+	}
+	return false, false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func init() {
+	aggregators.Add("oee", func() telegraf.Aggregator {
+		return &OEE{
+			RunningField:    "running",
+			TotalCountField: "total_count",
+			GoodCountField:  "good_count",
+		}
+	})
+}
@@ -0,0 +1,223 @@
+package oee
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *OEE
+		expected string
+	}{
+		{
+			name:     "no running field",
+			plugin:   &OEE{},
+			expected: "'running_field' must not be empty",
+		},
+		{
+			name:     "no count fields",
+			plugin:   &OEE{RunningField: "running", TotalCountField: "total"},
+			expected: "'total_count_field' and 'good_count_field' must not be empty",
+		},
+		{
+			name: "negative ideal cycle time",
+			plugin: &OEE{
+				RunningField:    "running",
+				TotalCountField: "total",
+				GoodCountField:  "good",
+				IdealCycleTime:  config.Duration(-time.Second),
+			},
+			expected: "'ideal_cycle_time' must not be negative",
+		},
+		{
+			name:     "no ideal cycle time",
+			plugin:   &OEE{RunningField: "running", TotalCountField: "total", GoodCountField: "good"},
+			expected: "either 'ideal_cycle_time' or 'ideal_cycle_time_field' required",
+		},
+		{
+			name: "invalid count type",
+			plugin: &OEE{
+				RunningField:    "running",
+				TotalCountField: "total",
+				GoodCountField:  "good",
+				IdealCycleTime:  config.Duration(time.Second),
+				CountType:       "gauge",
+			},
+			expected: "invalid 'count_type'",
+		},
+		{
+			name: "negative max gap",
+			plugin: &OEE{
+				RunningField:    "running",
+				TotalCountField: "total",
+				GoodCountField:  "good",
+				IdealCycleTime:  config.Duration(time.Second),
+				MaxGap:          config.Duration(-time.Second),
+			},
+			expected: "'max_gap' must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	at := func(sec int) time.Time {
+		return base.Add(time.Duration(sec) * time.Second)
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *OEE
+		periods  [][]telegraf.Metric
+		expected [][]telegraf.Metric
+	}{
+		{
+			name: "counters",
+			plugin: &OEE{
+				MachineTags:    []string{"machine"},
+				IdealCycleTime: config.Duration(time.Second),
+			},
+			periods: [][]telegraf.Metric{
+				{
+					metric.New("state", map[string]string{"machine": "m1", "source": "plc"}, map[string]interface{}{"running": true}, at(0)),
+					metric.New("counts", map[string]string{"machine": "m1"}, map[string]interface{}{"total_count": int64(100), "good_count": int64(95)}, at(0)),
+					metric.New("state", map[string]string{"machine": "m1", "source": "plc"}, map[string]interface{}{"running": false}, at(60)),
+					metric.New("state", map[string]string{"machine": "m1", "source": "plc"}, map[string]interface{}{"running": true}, at(90)),
+					metric.New("state", map[string]string{"machine": "m1", "source": "plc"}, map[string]interface{}{"running": true}, at(120)),
+					metric.New("counts", map[string]string{"machine": "m1"}, map[string]interface{}{"total_count": int64(160), "good_count": int64(151)}, at(120)),
+					metric.New("state", map[string]string{"source": "plc"}, map[string]interface{}{"running": true}, at(120)),
+				},
+				{
+					metric.New("state", map[string]string{"machine": "m1", "source": "plc"}, map[string]interface{}{"running": true}, at(180)),
+					metric.New("counts", map[string]string{"machine": "m1"}, map[string]interface{}{"total_count": int64(10), "good_count": int64(10)}, at(180)),
+				},
+				{},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					metric.New("oee", map[string]string{"machine": "m1"}, map[string]interface{}{
+						"planned_time": 120.0,
+						"running_time": 90.0,
+						"total_count":  60.0,
+						"good_count":   56.0,
+						"availability": 0.75,
+						"performance":  60.0 / 90.0,
+						"quality":      56.0 / 60.0,
+						"oee":          0.75 * 60.0 / 90.0 * 56.0 / 60.0,
+					}, time.Unix(0, 0)),
+				},
+				{
+					metric.New("oee", map[string]string{"machine": "m1"}, map[string]interface{}{
+						"planned_time": 60.0,
+						"running_time": 60.0,
+						"total_count":  10.0,
+						"good_count":   10.0,
+						"availability": 1.0,
+						"performance":  10.0 / 60.0,
+						"quality":      1.0,
+						"oee":          10.0 / 60.0,
+					}, time.Unix(0, 0)),
+				},
+				nil,
+			},
+		},
+		{
+			name: "deltas and states",
+			plugin: &OEE{
+				RunningField:        "state",
+				RunningStates:       []string{"EXECUTE"},
+				IdealCycleTimeField: "cycle_time",
+				TotalCountField:     "parts",
+				GoodCountField:      "good",
+				CountType:           "delta",
+				MaxGap:              config.Duration(time.Minute),
+			},
+			periods: [][]telegraf.Metric{
+				{
+					metric.New("press", map[string]string{}, map[string]interface{}{"state": "EXECUTE", "cycle_time": 2.0}, at(0)),
+					metric.New("press", map[string]string{}, map[string]interface{}{"state": "EXECUTE", "parts": int64(20), "good": int64(20)}, at(50)),
+					metric.New("press", map[string]string{}, map[string]interface{}{"state": "HELD", "parts": int64(5), "good": int64(4)}, at(100)),
+					metric.New("press", map[string]string{}, map[string]interface{}{"state": "EXECUTE"}, at(200)),
+				},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					metric.New("oee", map[string]string{}, map[string]interface{}{
+						"planned_time": 100.0,
+						"running_time": 100.0,
+						"total_count":  25.0,
+						"good_count":   24.0,
+						"availability": 1.0,
+						"performance":  0.5,
+						"quality":      0.96,
+						"oee":          0.48,
+					}, time.Unix(0, 0)),
+				},
+			},
+		},
+		{
+			name: "incomplete",
+			plugin: &OEE{
+				IdealCycleTime: config.Duration(time.Second),
+			},
+			periods: [][]telegraf.Metric{
+				{
+					metric.New("m", map[string]string{}, map[string]interface{}{"running": int64(0)}, at(0)),
+					metric.New("m", map[string]string{}, map[string]interface{}{"running": int64(1)}, at(10)),
+					metric.New("m", map[string]string{}, map[string]interface{}{"running": "yes"}, at(20)),
+				},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					metric.New("oee", map[string]string{}, map[string]interface{}{
+						"planned_time": 20.0,
+						"running_time": 10.0,
+						"total_count":  0.0,
+						"good_count":   0.0,
+						"availability": 0.5,
+						"performance":  0.0,
+					}, time.Unix(0, 0)),
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.plugin.RunningField == "" {
+				tt.plugin.RunningField = "running"
+				tt.plugin.TotalCountField = "total_count"
+				tt.plugin.GoodCountField = "good_count"
+			}
+			tt.plugin.Log = &testutil.Logger{}
+			require.NoError(t, tt.plugin.Init())
+
+			for i, period := range tt.periods {
+				var acc testutil.Accumulator
+				for _, m := range period {
+					tt.plugin.Add(m)
+				}
+				tt.plugin.Push(&acc)
+				tt.plugin.Reset()
+
+				testutil.RequireMetricsEqual(t, tt.expected[i], acc.GetTelegrafMetrics(), testutil.IgnoreTime(), cmpopts.EquateApprox(0, 1e-9))
+			}
+		})
+	}
+}
@@ -0,0 +1,161 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package session
+
+import (
+	_ "embed"
+	"errors"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/aggregators"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Session struct {
+	Key    string          `toml:"key"`
+	Gap    config.Duration `toml:"gap"`
+	Suffix string          `toml:"suffix"`
+	Log    telegraf.Logger `toml:"-"`
+
+	now      func() time.Time
+	sessions map[uint64]*session
+	closed   []telegraf.Metric
+}
+
+// session is the currently open session of a series
+type session struct {
+	name  string
+	tags  map[string]string
+	key   string
+	start time.Time
+	end   time.Time
+	count int64
+}
+
+func (*Session) SampleConfig() string {
+	return sampleConfig
+}
+
+func (a *Session) Init() error {
+	if a.Gap < 0 {
+		return errors.New("'gap' must not be negative")
+	}
+	if a.Key == "" && a.Gap == 0 {
+		return errors.New("'gap' required if no 'key' is set")
+	}
+	if a.Suffix == "" {
+		return errors.New("'suffix' must not be empty")
+	}
+
+	if a.now == nil {
+		a.now = time.Now
+	}
+	a.sessions = make(map[uint64]*session)
+
+	return nil
+}
+
+func (a *Session) Add(in telegraf.Metric) {
+	id, tags := in.HashID(), in.Tags()
+	var key string
+	if a.Key != "" {
+		if v, found := in.GetTag(a.Key); found {
+			// Exclude the key from the series identity
+			delete(tags, a.Key)
+			id = metric.New(in.Name(), tags, nil, time.Time{}).HashID()
+			key = v
+		} else if v, found := in.GetField(a.Key); found {
+			s, err := internal.ToString(v)
+			if err != nil {
+				a.Log.Debugf("Ignoring session key of type %T: %v", v, err)
+				return
+			}
+			key = s
+		} else {
+			return
+		}
+	}
+
+	ts := in.Time()
+	s, found := a.sessions[id]
+	if found && (s.key != key || a.expired(s, ts)) {
+		a.close(s)
+		found = false
+	}
+	if !found {
+		a.sessions[id] = &session{
+			name:  in.Name(),
+			tags:  tags,
+			key:   key,
+			start: ts,
+			end:   ts,
+			count: 1,
+		}
+		return
+	}
+
+	if ts.Before(s.start) {
+		s.start = ts
+	}
+	if ts.After(s.end) {
+		s.end = ts
+	}
+	s.count++
+}
+
+func (a *Session) Push(acc telegraf.Accumulator) {
+	// Close all sessions without events for longer than the gap
+	now := a.now()
+	for id, s := range a.sessions {
+		if a.expired(s, now) {
+			a.close(s)
+			delete(a.sessions, id)
+		}
+	}
+
+	for _, m := range a.closed {
+		acc.AddMetric(m)
+	}
+}
+
+func (a *Session) Reset() {
+	a.closed = nil
+}
+
+// expired checks if the given time is beyond the gap after the last event
+func (a *Session) expired(s *session, ts time.Time) bool {
+	return a.Gap > 0 && ts.Sub(s.end) > time.Duration(a.Gap)
+}
+
+// close creates the metric of the given session for the next push
+func (a *Session) close(s *session) {
+	tags := s.tags
+	if a.Key != "" {
+		tags = make(map[string]string, len(s.tags)+1)
+		for k, v := range s.tags {
+			tags[k] = v
+		}
+		tags[a.Key] = s.key
+	}
+	fields := map[string]interface{}{
+		"start":    s.start.UnixNano(),
+		"end":      s.end.UnixNano(),
+		"duration": s.end.Sub(s.start).Seconds(),
+		"count":    s.count,
+	}
+	a.closed = append(a.closed, metric.New(s.name+a.Suffix, tags, fields, s.start))
+}
+
+func init() {
+	aggregators.Add("session", func() telegraf.Aggregator {
+		return &Session{
+			Gap:    config.Duration(5 * time.Minute),
+			Suffix: "_session",
+		}
+	})
+}
@@ -0,0 +1,179 @@
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Session
+		expected string
+	}{
+		{
+			name:     "negative gap",
+			plugin:   &Session{Gap: config.Duration(-time.Second)},
+			expected: "'gap' must not be negative",
+		},
+		{
+			name:     "no gap and key",
+			plugin:   &Session{},
+			expected: "'gap' required if no 'key' is set",
+		},
+		{
+			name:     "no suffix",
+			plugin:   &Session{Key: "batch"},
+			expected: "'suffix' must not be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	at := func(sec int) time.Time {
+		return base.Add(time.Duration(sec) * time.Second)
+	}
+	session := func(name string, tags map[string]string, start, end int, count int64) telegraf.Metric {
+		fields := map[string]interface{}{
+			"start":    at(start).UnixNano(),
+			"end":      at(end).UnixNano(),
+			"duration": float64(end - start),
+			"count":    count,
+		}
+		return metric.New(name, tags, fields, at(start))
+	}
+
+	type period struct {
+		input []telegraf.Metric
+		end   time.Time
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *Session
+		periods  []period
+		expected [][]telegraf.Metric
+	}{
+		{
+			name:   "gap",
+			plugin: &Session{Gap: config.Duration(30 * time.Second)},
+			periods: []period{
+				{
+					input: []telegraf.Metric{
+						metric.New("door", map[string]string{"id": "d1"}, map[string]interface{}{"open": true}, at(0)),
+						metric.New("door", map[string]string{"id": "d1"}, map[string]interface{}{"open": true}, at(20)),
+						metric.New("door", map[string]string{"id": "d1"}, map[string]interface{}{"open": true}, at(10)),
+						metric.New("door", map[string]string{"id": "d2"}, map[string]interface{}{"open": true}, at(15)),
+						metric.New("door", map[string]string{"id": "d1"}, map[string]interface{}{"open": true}, at(100)),
+					},
+					end: at(110),
+				},
+				{
+					end: at(200),
+				},
+				{
+					end: at(300),
+				},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					session("door_session", map[string]string{"id": "d1"}, 0, 20, 3),
+					session("door_session", map[string]string{"id": "d2"}, 15, 15, 1),
+				},
+				{
+					session("door_session", map[string]string{"id": "d1"}, 100, 100, 1),
+				},
+				nil,
+			},
+		},
+		{
+			name:   "key tag",
+			plugin: &Session{Key: "alarm_id"},
+			periods: []period{
+				{
+					input: []telegraf.Metric{
+						metric.New("alarm", map[string]string{"machine": "m1", "alarm_id": "A"}, map[string]interface{}{"active": true}, at(0)),
+						metric.New("alarm", map[string]string{"machine": "m1", "alarm_id": "A"}, map[string]interface{}{"active": true}, at(5)),
+						metric.New("alarm", map[string]string{"machine": "m1", "alarm_id": "B"}, map[string]interface{}{"active": true}, at(7)),
+						metric.New("alarm", map[string]string{"machine": "m1", "alarm_id": "B"}, map[string]interface{}{"active": true}, at(9)),
+						metric.New("alarm", map[string]string{"machine": "m1"}, map[string]interface{}{"active": true}, at(9)),
+					},
+					end: at(10),
+				},
+				{
+					input: []telegraf.Metric{
+						metric.New("alarm", map[string]string{"machine": "m1", "alarm_id": "A"}, map[string]interface{}{"active": true}, at(11)),
+					},
+					end: at(20),
+				},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					session("alarm_session", map[string]string{"machine": "m1", "alarm_id": "A"}, 0, 5, 2),
+				},
+				{
+					session("alarm_session", map[string]string{"machine": "m1", "alarm_id": "B"}, 7, 9, 2),
+				},
+			},
+		},
+		{
+			name:   "key field with timeout",
+			plugin: &Session{Key: "batch", Gap: config.Duration(time.Minute), Suffix: "_batch"},
+			periods: []period{
+				{
+					input: []telegraf.Metric{
+						metric.New("press", map[string]string{}, map[string]interface{}{"batch": int64(42), "parts": 1}, at(0)),
+						metric.New("press", map[string]string{}, map[string]interface{}{"batch": int64(42), "parts": 2}, at(30)),
+						metric.New("press", map[string]string{}, map[string]interface{}{"batch": int64(42), "parts": 3}, at(120)),
+						metric.New("press", map[string]string{}, map[string]interface{}{"batch": int64(43), "parts": 1}, at(150)),
+					},
+					end: at(160),
+				},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					session("press_batch", map[string]string{"batch": "42"}, 0, 30, 2),
+					session("press_batch", map[string]string{"batch": "42"}, 120, 120, 1),
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var now time.Time
+			if tt.plugin.Suffix == "" {
+				tt.plugin.Suffix = "_session"
+			}
+			tt.plugin.Log = &testutil.Logger{}
+			tt.plugin.now = func() time.Time { return now }
+			require.NoError(t, tt.plugin.Init())
+
+			for i, p := range tt.periods {
+				var acc testutil.Accumulator
+				for _, m := range p.input {
+					tt.plugin.Add(m)
+				}
+				now = p.end
+				tt.plugin.Push(&acc)
+				tt.plugin.Reset()
+
+				testutil.RequireMetricsEqual(t, tt.expected[i], acc.GetTelegrafMetrics(), testutil.SortMetrics())
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package swinging_door
+
+import (
+	_ "embed"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/aggregators"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type SwingingDoor struct {
+	Deviation   float64         `toml:"deviation"`
+	MaxInterval config.Duration `toml:"max_interval"`
+	Log         telegraf.Logger `toml:"-"`
+
+	cache   map[uint64]*aggregate
+	grouper *metric.SeriesGrouper
+}
+
+type aggregate struct {
+	name   string
+	tags   map[string]string
+	fields map[string]*door
+}
+
+// door keeps the last archived point of a field, the last received point not
+// yet archived and the slopes of the doors, i.e. the range of slopes of lines
+// from the archived point staying within the deviation of all points since
+type door struct {
+	archived point
+	held     *point
+	minSlope float64
+	maxSlope float64
+}
+
+type point struct {
+	time  time.Time
+	raw   interface{}
+	value float64
+}
+
+func (*SwingingDoor) SampleConfig() string {
+	return sampleConfig
+}
+
+func (a *SwingingDoor) Init() error {
+	if a.Deviation < 0 {
+		return errors.New("'deviation' must not be negative")
+	}
+	if a.MaxInterval < 0 {
+		return errors.New("'max_interval' must not be negative")
+	}
+
+	a.cache = make(map[uint64]*aggregate)
+	a.grouper = metric.NewSeriesGrouper()
+
+	return nil
+}
+
+func (a *SwingingDoor) Add(in telegraf.Metric) {
+	id := in.HashID()
+	agg, found := a.cache[id]
+	if !found {
+		agg = &aggregate{
+			name:   in.Name(),
+			tags:   in.Tags(),
+			fields: make(map[string]*door),
+		}
+		a.cache[id] = agg
+	}
+
+	ts := in.Time()
+	for _, field := range in.FieldList() {
+		p := point{time: ts, raw: field.Value}
+		v, numeric := convert(field.Value)
+		p.value = v
+
+		d, found := agg.fields[field.Key]
+		if !found {
+			agg.fields[field.Key] = &door{archived: p}
+			a.archive(agg, field.Key, p)
+			continue
+		}
+
+		last := d.archived.time
+		if d.held != nil {
+			last = d.held.time
+		}
+		if !ts.After(last) {
+			a.Log.Debugf("Ignoring out-of-order value of field %q in %q", field.Key, in.Name())
+			continue
+		}
+
+		// Non-numeric values are emitted on change only
+		if !numeric {
+			if field.Value != d.archived.raw {
+				d.archived = p
+				a.archive(agg, field.Key, p)
+			}
+			continue
+		}
+
+		a.update(agg, field.Key, d, p)
+	}
+}
+
+func (a *SwingingDoor) Push(acc telegraf.Accumulator) {
+	// Always use nanosecond precision to keep the original timestamps
+	acc.SetPrecision(time.Nanosecond)
+
+	for _, m := range a.grouper.Metrics() {
+		acc.AddMetric(m)
+	}
+}
+
+func (a *SwingingDoor) Reset() {
+	a.grouper = metric.NewSeriesGrouper()
+}
+
+// update processes a new numeric point of the field. The new point replaces
+// the held point if the line from the archived point to the new point stays
+// within the doors of all points in between, otherwise the held point is
+// archived and starts a new segment.
+func (a *SwingingDoor) update(agg *aggregate, key string, d *door, p point) {
+	if d.held != nil {
+		expired := a.MaxInterval > 0 && p.time.Sub(d.archived.time) > time.Duration(a.MaxInterval)
+		slope := (p.value - d.archived.value) / p.time.Sub(d.archived.time).Seconds()
+		if expired || slope < d.minSlope || slope > d.maxSlope {
+			d.archived = *d.held
+			a.archive(agg, key, d.archived)
+			d.held = nil
+		}
+	}
+
+	// Narrow the doors by the deviation band around the new point
+	low, high := a.slopes(d, p)
+	if d.held == nil {
+		d.minSlope, d.maxSlope = low, high
+	} else {
+		d.minSlope, d.maxSlope = math.Max(d.minSlope, low), math.Min(d.maxSlope, high)
+	}
+	d.held = &p
+}
+
+// slopes returns the slopes of the lines from the archived point to the lower
+// and upper end of the deviation band around the given point
+func (a *SwingingDoor) slopes(d *door, p point) (low, high float64) {
+	dt := p.time.Sub(d.archived.time).Seconds()
+	dv := p.value - d.archived.value
+	return (dv - a.Deviation) / dt, (dv + a.Deviation) / dt
+}
+
+func (a *SwingingDoor) archive(agg *aggregate, key string, p point) {
+	a.grouper.Add(agg.name, agg.tags, p.time, key, p.raw)
+}
+
+func convert(in interface{}) (float64, bool) {
+	switch v := in.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func init() {
+	aggregators.Add("swinging_door", func() telegraf.Aggregator {
+		return &SwingingDoor{}
+	})
+}
@@ -0,0 +1,149 @@
+package swinging_door
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *SwingingDoor
+		expected string
+	}{
+		{
+			name:     "negative deviation",
+			plugin:   &SwingingDoor{Deviation: -0.1},
+			expected: "'deviation' must not be negative",
+		},
+		{
+			name:     "negative max interval",
+			plugin:   &SwingingDoor{MaxInterval: config.Duration(-time.Second)},
+			expected: "'max_interval' must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	m := func(sec int, fields map[string]interface{}) telegraf.Metric {
+		return metric.New("opcua", map[string]string{"id": "s1"}, fields, base.Add(time.Duration(sec)*time.Second))
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *SwingingDoor
+		periods  [][]telegraf.Metric
+		expected [][]telegraf.Metric
+	}{
+		{
+			name:   "ramp",
+			plugin: &SwingingDoor{Deviation: 0.5},
+			periods: [][]telegraf.Metric{
+				{
+					m(0, map[string]interface{}{"value": 0.0, "status": "ok"}),
+					m(1, map[string]interface{}{"value": 1.1}),
+					m(2, map[string]interface{}{"value": 1.9}),
+					m(3, map[string]interface{}{"value": 3.0, "status": "ok"}),
+					m(3, map[string]interface{}{"value": 100.0}),
+					m(4, map[string]interface{}{"value": 4.0}),
+					m(5, map[string]interface{}{"value": 4.2, "status": "warn"}),
+					m(6, map[string]interface{}{"value": 4.0}),
+				},
+				{
+					m(7, map[string]interface{}{"value": 2.0}),
+				},
+				{},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					m(0, map[string]interface{}{"value": 0.0, "status": "ok"}),
+					m(4, map[string]interface{}{"value": 4.0}),
+					m(5, map[string]interface{}{"status": "warn"}),
+				},
+				{
+					m(6, map[string]interface{}{"value": 4.0}),
+				},
+				nil,
+			},
+		},
+		{
+			name:   "max interval",
+			plugin: &SwingingDoor{Deviation: 1, MaxInterval: config.Duration(4 * time.Second)},
+			periods: [][]telegraf.Metric{
+				{
+					m(0, map[string]interface{}{"value": int64(5)}),
+					m(1, map[string]interface{}{"value": int64(5)}),
+					m(2, map[string]interface{}{"value": int64(5)}),
+					m(3, map[string]interface{}{"value": int64(5)}),
+					m(4, map[string]interface{}{"value": int64(5)}),
+					m(5, map[string]interface{}{"value": int64(5)}),
+					m(6, map[string]interface{}{"value": int64(5)}),
+					m(7, map[string]interface{}{"value": int64(5)}),
+					m(8, map[string]interface{}{"value": int64(5)}),
+					m(9, map[string]interface{}{"value": int64(5)}),
+					m(10, map[string]interface{}{"value": int64(5)}),
+				},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					m(0, map[string]interface{}{"value": int64(5)}),
+					m(4, map[string]interface{}{"value": int64(5)}),
+					m(8, map[string]interface{}{"value": int64(5)}),
+				},
+			},
+		},
+		{
+			name:   "exact",
+			plugin: &SwingingDoor{},
+			periods: [][]telegraf.Metric{
+				{
+					m(0, map[string]interface{}{"value": uint64(0)}),
+					m(1, map[string]interface{}{"value": uint64(2)}),
+					m(2, map[string]interface{}{"value": uint64(4)}),
+					m(3, map[string]interface{}{"value": uint64(5)}),
+					m(4, map[string]interface{}{"value": uint64(6)}),
+					m(5, map[string]interface{}{"value": uint64(0)}),
+				},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					m(0, map[string]interface{}{"value": uint64(0)}),
+					m(2, map[string]interface{}{"value": uint64(4)}),
+					m(4, map[string]interface{}{"value": uint64(6)}),
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			require.NoError(t, tt.plugin.Init())
+
+			for i, period := range tt.periods {
+				var acc testutil.Accumulator
+				for _, m := range period {
+					tt.plugin.Add(m)
+				}
+				tt.plugin.Push(&acc)
+				tt.plugin.Reset()
+
+				testutil.RequireMetricsEqual(t, tt.expected[i], acc.GetTelegrafMetrics(), testutil.SortMetrics())
+			}
+		})
+	}
+}
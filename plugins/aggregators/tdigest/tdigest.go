@@ -0,0 +1,186 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package tdigest
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	td "github.com/caio/go-tdigest"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/aggregators"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+const digestSuffix = "_tdigest"
+
+type TDigest struct {
+	Percentiles  []float64       `toml:"percentiles"`
+	Compression  float64         `toml:"compression"`
+	EmitDigest   bool            `toml:"emit_digest"`
+	MergeDigests bool            `toml:"merge_digests"`
+	Log          telegraf.Logger `toml:"-"`
+
+	suffixes []string
+	cache    map[uint64]*aggregate
+}
+
+type aggregate struct {
+	name   string
+	tags   map[string]string
+	fields map[string]*td.TDigest
+}
+
+func (*TDigest) SampleConfig() string {
+	return sampleConfig
+}
+
+func (a *TDigest) Init() error {
+	if a.Compression < 1 {
+		return errors.New("'compression' must be greater or equal to one")
+	}
+	if len(a.Percentiles) == 0 {
+		a.Percentiles = []float64{50, 90, 99}
+	}
+
+	a.suffixes = make([]string, 0, len(a.Percentiles))
+	seen := make(map[float64]bool, len(a.Percentiles))
+	for _, p := range a.Percentiles {
+		if p < 0 || p > 100 {
+			return fmt.Errorf("percentile %v out of range", p)
+		}
+		if seen[p] {
+			return fmt.Errorf("duplicate percentile %v", p)
+		}
+		seen[p] = true
+		a.suffixes = append(a.suffixes, "_p"+strconv.FormatFloat(p, 'f', -1, 64))
+	}
+
+	a.Reset()
+
+	return nil
+}
+
+func (a *TDigest) Add(in telegraf.Metric) {
+	id := in.HashID()
+	agg, found := a.cache[id]
+	if !found {
+		agg = &aggregate{
+			name:   in.Name(),
+			tags:   in.Tags(),
+			fields: make(map[string]*td.TDigest),
+		}
+		a.cache[id] = agg
+	}
+
+	for _, field := range in.FieldList() {
+		if a.MergeDigests {
+			if s, ok := field.Value.(string); ok && strings.HasSuffix(field.Key, digestSuffix) {
+				key := strings.TrimSuffix(field.Key, digestSuffix)
+				if err := a.merge(agg, key, s); err != nil {
+					a.Log.Errorf("Merging digest of field %q failed: %v", key, err)
+				}
+				continue
+			}
+		}
+
+		v, ok := convert(field.Value)
+		if !ok {
+			continue
+		}
+		digest, err := a.digest(agg, field.Key)
+		if err != nil {
+			a.Log.Errorf("Creating digest for field %q failed: %v", field.Key, err)
+			continue
+		}
+		if err := digest.Add(v); err != nil {
+			a.Log.Errorf("Adding value of field %q failed: %v", field.Key, err)
+		}
+	}
+}
+
+func (a *TDigest) Push(acc telegraf.Accumulator) {
+	for _, agg := range a.cache {
+		fields := make(map[string]interface{}, len(agg.fields)*(len(a.Percentiles)+1))
+		for key, digest := range agg.fields {
+			if digest.Count() == 0 {
+				continue
+			}
+			for i, p := range a.Percentiles {
+				fields[key+a.suffixes[i]] = digest.Quantile(p / 100)
+			}
+			if a.EmitDigest {
+				buf, err := digest.AsBytes()
+				if err != nil {
+					a.Log.Errorf("Serializing digest of field %q failed: %v", key, err)
+					continue
+				}
+				fields[key+digestSuffix] = base64.StdEncoding.EncodeToString(buf)
+			}
+		}
+		if len(fields) > 0 {
+			acc.AddFields(agg.name, fields, agg.tags)
+		}
+	}
+}
+
+func (a *TDigest) Reset() {
+	a.cache = make(map[uint64]*aggregate)
+}
+
+// digest returns the digest of the given field creating it if necessary
+func (a *TDigest) digest(agg *aggregate, key string) (*td.TDigest, error) {
+	if digest, found := agg.fields[key]; found {
+		return digest, nil
+	}
+	digest, err := td.New(td.Compression(a.Compression))
+	if err != nil {
+		return nil, err
+	}
+	agg.fields[key] = digest
+	return digest, nil
+}
+
+// merge decodes the serialized digest and merges it into the digest of the
+// given field
+func (a *TDigest) merge(agg *aggregate, key, encoded string) error {
+	buf, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("decoding failed: %w", err)
+	}
+	other, err := td.FromBytes(bytes.NewReader(buf), td.Compression(a.Compression))
+	if err != nil {
+		return fmt.Errorf("deserializing failed: %w", err)
+	}
+	digest, err := a.digest(agg, key)
+	if err != nil {
+		return err
+	}
+	return digest.Merge(other)
+}
+
+func convert(in interface{}) (float64, bool) {
+	switch v := in.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func init() {
+	aggregators.Add("tdigest", func() telegraf.Aggregator {
+		return &TDigest{Compression: 100}
+	})
+}
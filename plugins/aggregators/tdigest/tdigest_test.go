@@ -0,0 +1,163 @@
+package tdigest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *TDigest
+		expected string
+	}{
+		{
+			name:     "invalid compression",
+			plugin:   &TDigest{Compression: 0.5},
+			expected: "'compression' must be greater or equal to one",
+		},
+		{
+			name:     "percentile out of range",
+			plugin:   &TDigest{Compression: 100, Percentiles: []float64{50, 101}},
+			expected: "percentile 101 out of range",
+		},
+		{
+			name:     "duplicate percentile",
+			plugin:   &TDigest{Compression: 100, Percentiles: []float64{50, 99.9, 99.9}},
+			expected: "duplicate percentile 99.9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		plugin   *TDigest
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name:   "default percentiles",
+			plugin: &TDigest{Compression: 100},
+			input:  series("http", map[string]string{"path": "/"}, 1, 100, now),
+			expected: []telegraf.Metric{
+				metric.New("http", map[string]string{"path": "/"}, map[string]interface{}{
+					"latency_p50": 50.5,
+					"latency_p90": 90.5,
+					"latency_p99": 99.5,
+				}, now),
+			},
+		},
+		{
+			name:   "custom percentiles",
+			plugin: &TDigest{Compression: 100, Percentiles: []float64{0, 99.9, 100}},
+			input:  series("http", map[string]string{}, 1, 1000, now),
+			expected: []telegraf.Metric{
+				metric.New("http", map[string]string{}, map[string]interface{}{
+					"latency_p0":    1.0,
+					"latency_p99.9": 999.5,
+					"latency_p100":  1000.0,
+				}, now),
+			},
+		},
+		{
+			name:   "series",
+			plugin: &TDigest{Compression: 100, Percentiles: []float64{50}},
+			input: append(
+				series("http", map[string]string{"path": "/a"}, 1, 9, now),
+				series("http", map[string]string{"path": "/b"}, 11, 19, now)...,
+			),
+			expected: []telegraf.Metric{
+				metric.New("http", map[string]string{"path": "/a"}, map[string]interface{}{"latency_p50": 5.0}, now),
+				metric.New("http", map[string]string{"path": "/b"}, map[string]interface{}{"latency_p50": 15.0}, now),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			require.NoError(t, tt.plugin.Init())
+
+			var acc testutil.Accumulator
+			for _, m := range tt.input {
+				tt.plugin.Add(m)
+			}
+			tt.plugin.Push(&acc)
+
+			testutil.RequireMetricsEqual(t, tt.expected, acc.GetTelegrafMetrics(),
+				testutil.IgnoreTime(), testutil.SortMetrics(), cmpopts.EquateApprox(0, 0.5))
+		})
+	}
+}
+
+func TestMergeDigests(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	// Compute partial digests as done by two different instances
+	var partial testutil.Accumulator
+	for _, input := range [][]telegraf.Metric{
+		series("http", map[string]string{}, 1, 500, now),
+		series("http", map[string]string{}, 501, 1000, now),
+	} {
+		plugin := &TDigest{Compression: 100, EmitDigest: true, Log: &testutil.Logger{}}
+		require.NoError(t, plugin.Init())
+		for _, m := range input {
+			plugin.Add(m)
+		}
+		plugin.Push(&partial)
+	}
+	digests := partial.GetTelegrafMetrics()
+	require.Len(t, digests, 2)
+	// Only forward the digests as done with 'fieldinclude'
+	for _, m := range digests {
+		require.True(t, m.HasField("latency_tdigest"))
+		for _, key := range []string{"latency_p50", "latency_p90", "latency_p99"} {
+			m.RemoveField(key)
+		}
+	}
+
+	// Merge the digests in a central instance
+	plugin := &TDigest{Compression: 100, MergeDigests: true, Log: &testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+	for _, m := range digests {
+		plugin.Add(m)
+	}
+	plugin.Add(metric.New("http", map[string]string{}, map[string]interface{}{"invalid_tdigest": "not base64"}, now))
+
+	var acc testutil.Accumulator
+	plugin.Push(&acc)
+
+	expected := []telegraf.Metric{
+		metric.New("http", map[string]string{}, map[string]interface{}{
+			"latency_p50": 500.5,
+			"latency_p90": 900.5,
+			"latency_p99": 990.5,
+		}, now),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime(), cmpopts.EquateApprox(0, 1))
+}
+
+// series creates one metric per latency value in the given range
+func series(name string, tags map[string]string, from, to int, ts time.Time) []telegraf.Metric {
+	metrics := make([]telegraf.Metric, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		metrics = append(metrics, metric.New(name, tags, map[string]interface{}{"latency": int64(i), "method": "GET"}, ts))
+	}
+	return metrics
+}
@@ -0,0 +1,231 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package timeweighted
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/plugins/aggregators"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+var availableStats = []string{"mean", "min", "max", "integral", "duration"}
+
+type TimeWeighted struct {
+	Stats         []string        `toml:"stats"`
+	Interpolation string          `toml:"interpolation"`
+	IntegralUnit  config.Duration `toml:"integral_unit"`
+	MaxGap        config.Duration `toml:"max_gap"`
+	Log           telegraf.Logger `toml:"-"`
+
+	now   func() time.Time
+	cache map[uint64]*aggregate
+}
+
+type aggregate struct {
+	name   string
+	tags   map[string]string
+	fields map[string]*series
+}
+
+// series keeps the last sample of a field across periods and the statistics
+// accumulated within the current period
+type series struct {
+	value  float64
+	sample time.Time
+	from   time.Time
+
+	min      float64
+	max      float64
+	integral float64
+	duration time.Duration
+}
+
+func (*TimeWeighted) SampleConfig() string {
+	return sampleConfig
+}
+
+func (a *TimeWeighted) Init() error {
+	if len(a.Stats) == 0 {
+		a.Stats = []string{"mean", "min", "max"}
+	}
+	if err := choice.CheckSlice(a.Stats, availableStats); err != nil {
+		return fmt.Errorf("invalid 'stats': %w", err)
+	}
+	if a.Interpolation == "" {
+		a.Interpolation = "previous"
+	}
+	if err := choice.Check(a.Interpolation, []string{"previous", "linear"}); err != nil {
+		return fmt.Errorf("invalid 'interpolation': %w", err)
+	}
+	if a.IntegralUnit <= 0 {
+		return errors.New("'integral_unit' must be positive")
+	}
+	if a.MaxGap < 0 {
+		return errors.New("'max_gap' must not be negative")
+	}
+
+	if a.now == nil {
+		a.now = time.Now
+	}
+	a.cache = make(map[uint64]*aggregate)
+
+	return nil
+}
+
+func (a *TimeWeighted) Add(in telegraf.Metric) {
+	id := in.HashID()
+	agg, found := a.cache[id]
+	if !found {
+		agg = &aggregate{
+			name:   in.Name(),
+			tags:   in.Tags(),
+			fields: make(map[string]*series),
+		}
+		a.cache[id] = agg
+	}
+
+	ts := in.Time()
+	for _, field := range in.FieldList() {
+		v, ok := convert(field.Value)
+		if !ok {
+			continue
+		}
+		s, found := agg.fields[field.Key]
+		if !found {
+			agg.fields[field.Key] = &series{value: v, sample: ts, from: ts}
+			continue
+		}
+		if ts.Before(s.sample) {
+			a.Log.Debugf("Ignoring out-of-order sample of field %q in %q", field.Key, in.Name())
+			continue
+		}
+		// Samples slightly older than the end of the last period only update
+		// the value as the time up to the period end is already accounted
+		a.segment(s, ts, v)
+		s.value = v
+		s.sample = ts
+		if ts.After(s.from) {
+			s.from = ts
+		}
+	}
+}
+
+func (a *TimeWeighted) Push(acc telegraf.Accumulator) {
+	now := a.now()
+	for _, agg := range a.cache {
+		fields := make(map[string]interface{}, len(agg.fields)*len(a.Stats))
+		for key, s := range agg.fields {
+			// Hold the last value until the end of the period as the next
+			// sample is unknown for linear interpolation
+			if a.Interpolation == "previous" && now.After(s.from) {
+				a.segment(s, now, s.value)
+				s.from = now
+			}
+			if s.duration <= 0 {
+				continue
+			}
+
+			for _, stat := range a.Stats {
+				switch stat {
+				case "mean":
+					fields[key+"_mean"] = s.integral / s.duration.Seconds()
+				case "min":
+					fields[key+"_min"] = s.min
+				case "max":
+					fields[key+"_max"] = s.max
+				case "integral":
+					fields[key+"_integral"] = s.integral / time.Duration(a.IntegralUnit).Seconds()
+				case "duration":
+					fields[key+"_duration"] = s.duration.Seconds()
+				}
+			}
+		}
+		if len(fields) > 0 {
+			acc.AddFields(agg.name, fields, agg.tags)
+		}
+	}
+}
+
+func (a *TimeWeighted) Reset() {
+	now := a.now()
+	for id, agg := range a.cache {
+		for key, s := range agg.fields {
+			// Forget series not receiving samples for longer than the gap
+			if a.MaxGap > 0 && now.Sub(s.sample) >= time.Duration(a.MaxGap) {
+				delete(agg.fields, key)
+				continue
+			}
+			s.integral = 0
+			s.duration = 0
+		}
+		if len(agg.fields) == 0 {
+			delete(a.cache, id)
+		}
+	}
+}
+
+// segment accounts the time between the last accounted time of the series
+// and the given end time, for linear interpolation the value changes from the
+// last sample to the given value
+func (a *TimeWeighted) segment(s *series, end time.Time, value float64) {
+	if a.Interpolation == "previous" {
+		value = s.value
+	}
+
+	// Limit the validity of the last sample to the maximum gap
+	if a.MaxGap > 0 {
+		limit := s.sample.Add(time.Duration(a.MaxGap))
+		if end.After(limit) {
+			if a.Interpolation == "linear" {
+				return
+			}
+			end = limit
+		}
+	}
+
+	elapsed := end.Sub(s.from)
+	if elapsed <= 0 {
+		return
+	}
+
+	first, last := s.value, value
+	if s.duration == 0 {
+		s.min = math.Min(first, last)
+		s.max = math.Max(first, last)
+	} else {
+		s.min = math.Min(s.min, math.Min(first, last))
+		s.max = math.Max(s.max, math.Max(first, last))
+	}
+	s.integral += (first + last) / 2 * elapsed.Seconds()
+	s.duration += elapsed
+}
+
+func convert(in interface{}) (float64, bool) {
+	switch v := in.(type) {
+	case float64:
+		return v, true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func init() {
+	aggregators.Add("timeweighted", func() telegraf.Aggregator {
+		return &TimeWeighted{
+			IntegralUnit: config.Duration(time.Second),
+		}
+	})
+}
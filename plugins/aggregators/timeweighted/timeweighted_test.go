@@ -0,0 +1,242 @@
+package timeweighted
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *TimeWeighted
+		expected string
+	}{
+		{
+			name:     "invalid stat",
+			plugin:   &TimeWeighted{Stats: []string{"mean", "stdev"}},
+			expected: "invalid 'stats'",
+		},
+		{
+			name:     "invalid interpolation",
+			plugin:   &TimeWeighted{Interpolation: "next"},
+			expected: "invalid 'interpolation'",
+		},
+		{
+			name:     "no integral unit",
+			plugin:   &TimeWeighted{},
+			expected: "'integral_unit' must be positive",
+		},
+		{
+			name: "negative max gap",
+			plugin: &TimeWeighted{
+				IntegralUnit: config.Duration(time.Second),
+				MaxGap:       config.Duration(-time.Second),
+			},
+			expected: "'max_gap' must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	at := func(sec int) time.Time {
+		return base.Add(time.Duration(sec) * time.Second)
+	}
+	all := []string{"mean", "min", "max", "integral", "duration"}
+
+	type period struct {
+		input []telegraf.Metric
+		end   time.Time
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *TimeWeighted
+		periods  []period
+		expected [][]telegraf.Metric
+	}{
+		{
+			name:   "previous",
+			plugin: &TimeWeighted{Stats: all},
+			periods: []period{
+				{
+					input: []telegraf.Metric{
+						metric.New("opcua", map[string]string{"id": "s1"}, map[string]interface{}{"value": 10.0, "quality": "Good"}, at(0)),
+						metric.New("opcua", map[string]string{"id": "s1"}, map[string]interface{}{"value": int64(20)}, at(10)),
+						metric.New("opcua", map[string]string{"id": "s1"}, map[string]interface{}{"value": uint64(0)}, at(40)),
+					},
+					end: at(60),
+				},
+				{
+					end: at(120),
+				},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					metric.New("opcua", map[string]string{"id": "s1"}, map[string]interface{}{
+						"value_mean":     700.0 / 60.0,
+						"value_min":      0.0,
+						"value_max":      20.0,
+						"value_integral": 700.0,
+						"value_duration": 60.0,
+					}, time.Unix(0, 0)),
+				},
+				{
+					metric.New("opcua", map[string]string{"id": "s1"}, map[string]interface{}{
+						"value_mean":     0.0,
+						"value_min":      0.0,
+						"value_max":      0.0,
+						"value_integral": 0.0,
+						"value_duration": 60.0,
+					}, time.Unix(0, 0)),
+				},
+			},
+		},
+		{
+			name:   "linear",
+			plugin: &TimeWeighted{Interpolation: "linear", Stats: all, IntegralUnit: config.Duration(time.Minute)},
+			periods: []period{
+				{
+					input: []telegraf.Metric{
+						metric.New("flow", map[string]string{}, map[string]interface{}{"rate": 0.0}, at(0)),
+						metric.New("flow", map[string]string{}, map[string]interface{}{"rate": 10.0}, at(10)),
+						metric.New("flow", map[string]string{}, map[string]interface{}{"rate": 0.0}, at(20)),
+					},
+					end: at(30),
+				},
+				{
+					input: []telegraf.Metric{
+						metric.New("flow", map[string]string{}, map[string]interface{}{"rate": 6.0}, at(40)),
+					},
+					end: at(60),
+				},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					metric.New("flow", map[string]string{}, map[string]interface{}{
+						"rate_mean":     5.0,
+						"rate_min":      0.0,
+						"rate_max":      10.0,
+						"rate_integral": 100.0 / 60.0,
+						"rate_duration": 20.0,
+					}, time.Unix(0, 0)),
+				},
+				{
+					metric.New("flow", map[string]string{}, map[string]interface{}{
+						"rate_mean":     3.0,
+						"rate_min":      0.0,
+						"rate_max":      6.0,
+						"rate_integral": 1.0,
+						"rate_duration": 20.0,
+					}, time.Unix(0, 0)),
+				},
+			},
+		},
+		{
+			name:   "max gap",
+			plugin: &TimeWeighted{MaxGap: config.Duration(10 * time.Second)},
+			periods: []period{
+				{
+					input: []telegraf.Metric{
+						metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, at(0)),
+						metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0}, at(30)),
+					},
+					end: at(35),
+				},
+				{
+					end: at(60),
+				},
+				{
+					end: at(90),
+				},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					metric.New("m", map[string]string{}, map[string]interface{}{
+						"value_mean": 20.0 / 15.0,
+						"value_min":  1.0,
+						"value_max":  2.0,
+					}, time.Unix(0, 0)),
+				},
+				{
+					metric.New("m", map[string]string{}, map[string]interface{}{
+						"value_mean": 2.0,
+						"value_min":  2.0,
+						"value_max":  2.0,
+					}, time.Unix(0, 0)),
+				},
+				nil,
+			},
+		},
+		{
+			name:   "late sample",
+			plugin: &TimeWeighted{Stats: []string{"mean", "duration"}},
+			periods: []period{
+				{
+					input: []telegraf.Metric{
+						metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, at(0)),
+					},
+					end: at(10),
+				},
+				{
+					input: []telegraf.Metric{
+						metric.New("m", map[string]string{}, map[string]interface{}{"value": 3.0}, at(9)),
+					},
+					end: at(20),
+				},
+			},
+			expected: [][]telegraf.Metric{
+				{
+					metric.New("m", map[string]string{}, map[string]interface{}{
+						"value_mean":     1.0,
+						"value_duration": 10.0,
+					}, time.Unix(0, 0)),
+				},
+				{
+					metric.New("m", map[string]string{}, map[string]interface{}{
+						"value_mean":     3.0,
+						"value_duration": 10.0,
+					}, time.Unix(0, 0)),
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var now time.Time
+			if tt.plugin.IntegralUnit == 0 {
+				tt.plugin.IntegralUnit = config.Duration(time.Second)
+			}
+			tt.plugin.Log = &testutil.Logger{}
+			tt.plugin.now = func() time.Time { return now }
+			require.NoError(t, tt.plugin.Init())
+
+			for i, p := range tt.periods {
+				var acc testutil.Accumulator
+				for _, m := range p.input {
+					tt.plugin.Add(m)
+				}
+				now = p.end
+				tt.plugin.Push(&acc)
+				tt.plugin.Reset()
+
+				testutil.RequireMetricsEqual(t, tt.expected[i], acc.GetTelegrafMetrics(), testutil.IgnoreTime(), cmpopts.EquateApprox(0, 1e-9))
+			}
+		})
+	}
+}
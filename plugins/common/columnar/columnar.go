@@ -0,0 +1,151 @@
+package columnar
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+)
+
+// Logical column types of the Parquet files written by the outputs
+const (
+	TypeString    = "string"
+	TypeLong      = "long"
+	TypeUnsigned  = "unsigned"
+	TypeDouble    = "double"
+	TypeBoolean   = "boolean"
+	TypeTimestamp = "timestamp"
+	TypeDate      = "date"
+)
+
+// Compressions maps the names of the supported compression codecs to the
+// codecs used for writing Parquet files
+var Compressions = map[string]compress.Compression{
+	"none":   compress.Codecs.Uncompressed,
+	"snappy": compress.Codecs.Snappy,
+	"gzip":   compress.Codecs.Gzip,
+	"zstd":   compress.Codecs.Zstd,
+}
+
+// ColumnType returns the type of the column storing the given field value.
+// Unsigned integers result in an unsigned column, use a long column for
+// formats without unsigned types.
+func ColumnType(value interface{}) (string, bool) {
+	switch value.(type) {
+	case string:
+		return TypeString, true
+	case int64:
+		return TypeLong, true
+	case uint64:
+		return TypeUnsigned, true
+	case float64:
+		return TypeDouble, true
+	case bool:
+		return TypeBoolean, true
+	}
+	return "", false
+}
+
+// ArrowType returns the Arrow type used for the column type or nil if the
+// type is unknown
+func ArrowType(typ string) arrow.DataType {
+	switch typ {
+	case TypeString:
+		return arrow.BinaryTypes.String
+	case TypeLong:
+		return arrow.PrimitiveTypes.Int64
+	case TypeUnsigned:
+		return arrow.PrimitiveTypes.Uint64
+	case TypeDouble:
+		return arrow.PrimitiveTypes.Float64
+	case TypeBoolean:
+		return arrow.FixedWidthTypes.Boolean
+	case TypeTimestamp:
+		return &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+	case TypeDate:
+		return arrow.FixedWidthTypes.Date32
+	}
+	return nil
+}
+
+// Convert returns the value as the given column type if possible without
+// loss, e.g. unsigned integers above the maximum of a long column cannot be
+// converted
+func Convert(value interface{}, typ string) (interface{}, bool) {
+	switch typ {
+	case TypeString:
+		v, ok := value.(string)
+		return v, ok
+	case TypeLong:
+		switch v := value.(type) {
+		case int64:
+			return v, true
+		case uint64:
+			if v <= math.MaxInt64 {
+				return int64(v), true
+			}
+		}
+	case TypeUnsigned:
+		switch v := value.(type) {
+		case uint64:
+			return v, true
+		case int64:
+			if v >= 0 {
+				return uint64(v), true
+			}
+		}
+	case TypeDouble:
+		switch v := value.(type) {
+		case float64:
+			return v, true
+		case int64:
+			return float64(v), true
+		case uint64:
+			return float64(v), true
+		}
+	case TypeBoolean:
+		v, ok := value.(bool)
+		return v, ok
+	}
+	return nil, false
+}
+
+// Append adds the value to the builder of a column of the given type. Null
+// is appended if the value cannot be converted to the column type.
+func Append(b array.Builder, typ string, value interface{}) {
+	v, ok := Convert(value, typ)
+	if !ok {
+		b.AppendNull()
+		return
+	}
+
+	switch typ {
+	case TypeString:
+		b.(*array.StringBuilder).Append(v.(string))
+	case TypeLong:
+		b.(*array.Int64Builder).Append(v.(int64))
+	case TypeUnsigned:
+		b.(*array.Uint64Builder).Append(v.(uint64))
+	case TypeDouble:
+		b.(*array.Float64Builder).Append(v.(float64))
+	case TypeBoolean:
+		b.(*array.BooleanBuilder).Append(v.(bool))
+	}
+}
+
+// EscapePartition escapes the characters not allowed in Hive-style partition
+// directory names
+func EscapePartition(value string) string {
+	var buf strings.Builder
+	for _, r := range []byte(value) {
+		if r < 0x20 || r == 0x7f || strings.IndexByte("\"#%'*/:=?\\{[]^", r) >= 0 {
+			fmt.Fprintf(&buf, "%%%02X", r)
+			continue
+		}
+		buf.WriteByte(r)
+	}
+	return buf.String()
+}
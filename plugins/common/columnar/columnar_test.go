@@ -0,0 +1,96 @@
+package columnar
+
+import (
+	"math"
+	"testing"
+
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		typ      string
+		expected interface{}
+		ok       bool
+	}{
+		{
+			name:     "unsigned as long",
+			value:    uint64(42),
+			typ:      TypeLong,
+			expected: int64(42),
+			ok:       true,
+		},
+		{
+			name:  "unsigned exceeding long",
+			value: uint64(math.MaxInt64) + 1,
+			typ:   TypeLong,
+		},
+		{
+			name:     "integer as unsigned",
+			value:    int64(42),
+			typ:      TypeUnsigned,
+			expected: uint64(42),
+			ok:       true,
+		},
+		{
+			name:  "negative integer as unsigned",
+			value: int64(-1),
+			typ:   TypeUnsigned,
+		},
+		{
+			name:     "unsigned as double",
+			value:    uint64(math.MaxUint64),
+			typ:      TypeDouble,
+			expected: float64(math.MaxUint64),
+			ok:       true,
+		},
+		{
+			name:  "string as long",
+			value: "42",
+			typ:   TypeLong,
+		},
+		{
+			name:  "timestamp",
+			value: int64(42),
+			typ:   TypeTimestamp,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, ok := Convert(tt.value, tt.typ)
+			require.Equal(t, tt.ok, ok)
+			if tt.ok {
+				require.Equal(t, tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestAppend(t *testing.T) {
+	b := array.NewInt64Builder(memory.DefaultAllocator)
+	defer b.Release()
+
+	Append(b, TypeLong, int64(1))
+	Append(b, TypeLong, uint64(2))
+	Append(b, TypeLong, uint64(math.MaxUint64))
+	Append(b, TypeLong, "3")
+
+	values := b.NewInt64Array()
+	defer values.Release()
+	require.Equal(t, 4, values.Len())
+	require.Equal(t, int64(1), values.Value(0))
+	require.Equal(t, int64(2), values.Value(1))
+	require.True(t, values.IsNull(2))
+	require.True(t, values.IsNull(3))
+}
+
+func TestEscapePartition(t *testing.T) {
+	require.Equal(t, "cpu", EscapePartition("cpu"))
+	require.Equal(t, "a%2Fb%3Dc", EscapePartition("a/b=c"))
+	require.Equal(t, "line%0A%25", EscapePartition("line\n%"))
+}
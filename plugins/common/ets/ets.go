@@ -0,0 +1,150 @@
+// Package ets imports the group addresses of KNX installations from exports
+// of the ETS engineering tool.
+package ets
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/vapourismo/knx-go/knx/cemi"
+)
+
+// Datapoint-types are specified as main-type only, e.g. "DPT-9", or including
+// the sub-type, e.g. "DPST-9-1"
+var regexDPT = regexp.MustCompile(`^DPS?T-(\d+)(?:-(\d+))?$`)
+
+// Project files are located in a directory named after the project ID, for
+// password-protected projects this directory is an encrypted archive
+var (
+	regexProjectFile      = regexp.MustCompile(`^P-[0-9A-F]+/0\.xml$`)
+	regexProtectedProject = regexp.MustCompile(`^P-[0-9A-F]+\.zip$`)
+)
+
+// GroupAddress is a group address defined in the ETS project
+type GroupAddress struct {
+	// Address in three-level notation, e.g. "1/2/3"
+	Address string
+	// Name assigned to the group address in the project
+	Name string
+	// Datapoint-type in the notation used by knx-go, e.g. "9.001", or empty
+	// if the project does not specify the sub-type
+	DPT string
+}
+
+// Load reads the group addresses from the given file. Supported formats are
+// the group-address XML export (".xml") and unprotected project files
+// (".knxproj").
+func Load(fn string) ([]GroupAddress, error) {
+	switch strings.ToLower(filepath.Ext(fn)) {
+	case ".xml":
+		f, err := os.Open(fn)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return Parse(f)
+	case ".knxproj":
+		return loadProject(fn)
+	}
+	return nil, fmt.Errorf("unsupported file type of %q", fn)
+}
+
+// Parse decodes the group addresses of a group-address XML export or a
+// project XML file
+func Parse(r io.Reader) ([]GroupAddress, error) {
+	var addresses []GroupAddress
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			return addresses, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		element, ok := token.(xml.StartElement)
+		if !ok || element.Name.Local != "GroupAddress" {
+			continue
+		}
+
+		var address, name, dpts string
+		for _, attr := range element.Attr {
+			switch attr.Name.Local {
+			case "Address":
+				address = attr.Value
+			case "Name":
+				name = attr.Value
+			case "DPTs", "DatapointType":
+				dpts = attr.Value
+			}
+		}
+		ga, err := parseAddress(address)
+		if err != nil {
+			return nil, fmt.Errorf("group address %q: %w", name, err)
+		}
+		addresses = append(addresses, GroupAddress{
+			Address: ga.String(),
+			Name:    name,
+			DPT:     ConvertDPT(dpts),
+		})
+	}
+}
+
+// ConvertDPT converts the ETS notation of a datapoint-type, e.g. "DPST-9-1",
+// to the notation of knx-go, e.g. "9.001". Multiple types are separated by
+// spaces or commas in which case the first one is used. An empty string is
+// returned for types without sub-type.
+func ConvertDPT(s string) string {
+	s, _, _ = strings.Cut(strings.TrimSpace(strings.ReplaceAll(s, ",", " ")), " ")
+	match := regexDPT.FindStringSubmatch(s)
+	if match == nil || match[2] == "" {
+		return ""
+	}
+	sub, err := strconv.ParseUint(match[2], 10, 16)
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s.%03d", match[1], sub)
+}
+
+// parseAddress decodes group addresses in the notation of the export, i.e.
+// free, two- or three-level, or the integer value used in project files
+func parseAddress(s string) (cemi.GroupAddr, error) {
+	if v, err := strconv.ParseUint(s, 10, 16); err == nil {
+		return cemi.GroupAddr(v), nil
+	}
+	return cemi.NewGroupAddrString(s)
+}
+
+func loadProject(fn string) ([]GroupAddress, error) {
+	archive, err := zip.OpenReader(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	for _, f := range archive.File {
+		if regexProtectedProject.MatchString(f.Name) {
+			return nil, errors.New("password-protected projects are not supported")
+		}
+		if !regexProjectFile.MatchString(path.Clean(f.Name)) {
+			continue
+		}
+		r, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return Parse(r)
+	}
+	return nil, errors.New("no project found in file")
+}
@@ -0,0 +1,107 @@
+package ets
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertDPT(t *testing.T) {
+	tests := map[string]string{
+		"DPST-1-1":           "1.001",
+		"DPST-9-1":           "9.001",
+		"DPST-232-600":       "232.600",
+		"DPST-5-1 DPST-5-10": "5.001",
+		"DPST-14-68,DPT-14":  "14.068",
+		"DPT-9":              "",
+		"":                   "",
+		"9.001":              "",
+	}
+	for input, expected := range tests {
+		require.Equalf(t, expected, ConvertDPT(input), "input %q", input)
+	}
+}
+
+func TestLoadExport(t *testing.T) {
+	addresses, err := Load(filepath.Join("testdata", "export.xml"))
+	require.NoError(t, err)
+
+	expected := []GroupAddress{
+		{Address: "1/0/0", Name: "Ceiling light switch", DPT: "1.001"},
+		{Address: "1/0/1", Name: "Ceiling light status", DPT: "1.011"},
+		{Address: "1/0/2", Name: "Ceiling light dimming"},
+		{Address: "2/0/0", Name: "Temperature", DPT: "9.001"},
+		{Address: "2/0/1", Name: "Setpoint"},
+	}
+	require.Equal(t, expected, addresses)
+}
+
+func TestLoadProject(t *testing.T) {
+	project := `<?xml version="1.0" encoding="utf-8"?>
+<KNX xmlns="http://knx.org/xml/project/21">
+  <Project Id="P-0A1B">
+    <Installations>
+      <Installation Name="">
+        <GroupAddresses>
+          <GroupRanges>
+            <GroupRange Id="P-0A1B-0_GR-1" RangeStart="2048" RangeEnd="4095" Name="Lighting">
+              <GroupAddress Id="P-0A1B-0_GA-1" Address="2048" Name="Ceiling light switch" DatapointType="DPST-1-1" />
+              <GroupAddress Id="P-0A1B-0_GA-2" Address="2049" Name="Ceiling light status" />
+            </GroupRange>
+          </GroupRanges>
+        </GroupAddresses>
+      </Installation>
+    </Installations>
+  </Project>
+</KNX>`
+	fn := createArchive(t, map[string]string{
+		"knx_master.xml": "<KNX/>",
+		"P-0A1B/0.xml":   project,
+	})
+
+	addresses, err := Load(fn)
+	require.NoError(t, err)
+	expected := []GroupAddress{
+		{Address: "1/0/0", Name: "Ceiling light switch", DPT: "1.001"},
+		{Address: "1/0/1", Name: "Ceiling light status"},
+	}
+	require.Equal(t, expected, addresses)
+}
+
+func TestLoadFail(t *testing.T) {
+	_, err := Load(filepath.Join("testdata", "export.csv"))
+	require.ErrorContains(t, err, "unsupported file type")
+
+	fn := createArchive(t, map[string]string{"P-0A1B.zip": "encrypted"})
+	_, err = Load(fn)
+	require.ErrorContains(t, err, "password-protected projects are not supported")
+
+	fn = createArchive(t, map[string]string{"knx_master.xml": "<KNX/>"})
+	_, err = Load(fn)
+	require.ErrorContains(t, err, "no project found")
+
+	fn = filepath.Join(t.TempDir(), "invalid.xml")
+	require.NoError(t, os.WriteFile(fn, []byte(`<GroupAddress Name="Broken" Address="1/2/3/4" />`), 0600))
+	_, err = Load(fn)
+	require.ErrorContains(t, err, `group address "Broken"`)
+}
+
+func createArchive(t *testing.T, files map[string]string) string {
+	fn := filepath.Join(t.TempDir(), "test.knxproj")
+	f, err := os.Create(fn)
+	require.NoError(t, err)
+	defer f.Close()
+
+	archive := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := archive.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, archive.Close())
+	return fn
+}
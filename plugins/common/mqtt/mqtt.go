@@ -39,8 +39,23 @@ type MqttConfig struct {
 
 	tls.ClientConfig
 
-	AutoReconnect    bool        `toml:"-"`
-	OnConnectionLost func(error) `toml:"-"`
+	AutoReconnect    bool         `toml:"-"`
+	OnConnectionLost func(error)  `toml:"-"`
+	Will             *WillMessage `toml:"-"`
+
+	// Enhanced authentication, only supported by MQTT v5. The data is
+	// requested on each connection attempt to allow for expiring tokens.
+	AuthMethod string                 `toml:"-"`
+	AuthData   func() ([]byte, error) `toml:"-"`
+}
+
+// WillMessage is the last-will message published by the broker on behalf of
+// the client if the connection is lost unexpectedly
+type WillMessage struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
 }
 
 // Client is a protocol neutral MQTT client for connecting,
@@ -69,6 +84,9 @@ func NewClient(cfg *MqttConfig) (Client, error) {
 
 	switch cfg.Protocol {
 	case "", "3.1.1":
+		if cfg.AuthMethod != "" {
+			return nil, errors.New("enhanced authentication requires protocol \"5\"")
+		}
 		return NewMQTTv311Client(cfg)
 	case "5":
 		return NewMQTTv5Client(cfg)
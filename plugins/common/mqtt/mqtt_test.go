@@ -1,9 +1,13 @@
 package mqtt
 
 import (
+	"errors"
 	"testing"
 
+	mqttv5 "github.com/eclipse/paho.golang/paho"
 	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/testutil"
 )
 
 // Test that default client has random ID
@@ -24,3 +28,52 @@ func TestRandomClientID(t *testing.T) {
 	options2 := client2.client.OptionsReader()
 	require.NotEqual(t, options1.ClientID(), options2.ClientID())
 }
+
+func TestEnhancedAuthentication(t *testing.T) {
+	var calls int
+	cfg := &MqttConfig{
+		Servers:    []string{"tcp://localhost:1883"},
+		AuthMethod: "K8S-SAT",
+		AuthData: func() ([]byte, error) {
+			calls++
+			return []byte("token"), nil
+		},
+	}
+
+	_, err := NewClient(cfg)
+	require.ErrorContains(t, err, "enhanced authentication requires protocol \"5\"")
+
+	client, err := NewMQTTv5Client(cfg)
+	require.NoError(t, err)
+
+	// The authentication data is requested for each connection attempt
+	for range 2 {
+		c, err := client.options.ConnectPacketBuilder(&mqttv5.Connect{}, nil)
+		require.NoError(t, err)
+		require.Equal(t, "K8S-SAT", c.Properties.AuthMethod)
+		require.Equal(t, []byte("token"), c.Properties.AuthData)
+	}
+	require.Equal(t, 2, calls)
+
+	auth := client.options.AuthHandler.Authenticate(&mqttv5.Auth{ReasonCode: 0x18})
+	require.Equal(t, byte(0x18), auth.ReasonCode)
+	require.Equal(t, "K8S-SAT", auth.Properties.AuthMethod)
+	require.Equal(t, []byte("token"), auth.Properties.AuthData)
+}
+
+func TestEnhancedAuthenticationDataFailure(t *testing.T) {
+	log := &testutil.CaptureLogger{}
+	handler := &auther{
+		method: "K8S-SAT",
+		data: func() ([]byte, error) {
+			return nil, errors.New("token expired")
+		},
+		log: log,
+	}
+
+	auth := handler.Authenticate(&mqttv5.Auth{ReasonCode: 0x18})
+	require.Equal(t, byte(0x18), auth.ReasonCode)
+	require.Empty(t, auth.Properties.AuthData)
+
+	log.RequireErrorContains(t, "token expired")
+}
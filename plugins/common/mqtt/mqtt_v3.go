@@ -32,6 +32,9 @@ func NewMQTTv311Client(cfg *MqttConfig) (*mqttv311Client, error) {
 		opts.SetConnectionLostHandler(onConnectionLost)
 	}
 	opts.SetAutoReconnect(cfg.AutoReconnect)
+	if cfg.Will != nil {
+		opts.SetBinaryWill(cfg.Will.Topic, cfg.Will.Payload, cfg.Will.QoS, cfg.Will.Retain)
+	}
 
 	if cfg.ClientID != "" {
 		opts.SetClientID(cfg.ClientID)
@@ -10,6 +10,7 @@ import (
 	mqttv5 "github.com/eclipse/paho.golang/paho"
 	paho "github.com/eclipse/paho.mqtt.golang"
 
+	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/logger"
@@ -34,8 +35,34 @@ func NewMQTTv5Client(cfg *MqttConfig) (*mqttv5Client, error) {
 	}
 	opts.ConnectPacketBuilder = func(c *mqttv5.Connect, _ *url.URL) (*mqttv5.Connect, error) {
 		c.CleanStart = cfg.PersistentSession
+		if cfg.Will != nil {
+			c.WillMessage = &mqttv5.WillMessage{
+				Topic:   cfg.Will.Topic,
+				Payload: cfg.Will.Payload,
+				QoS:     cfg.Will.QoS,
+				Retain:  cfg.Will.Retain,
+			}
+		}
+		if cfg.AuthMethod != "" {
+			data, err := cfg.AuthData()
+			if err != nil {
+				return nil, fmt.Errorf("getting authentication data failed: %w", err)
+			}
+			if c.Properties == nil {
+				c.Properties = &mqttv5.ConnectProperties{}
+			}
+			c.Properties.AuthMethod = cfg.AuthMethod
+			c.Properties.AuthData = data
+		}
 		return c, nil
 	}
+	if cfg.AuthMethod != "" {
+		opts.AuthHandler = &auther{
+			method: cfg.AuthMethod,
+			data:   cfg.AuthData,
+			log:    logger.New("mqtt", "", ""),
+		}
+	}
 
 	if time.Duration(cfg.ConnectionTimeout) >= 1*time.Second {
 		opts.ConnectTimeout = time.Duration(cfg.ConnectionTimeout)
@@ -163,3 +190,30 @@ func (*mqttv5Client) AddRoute(topic string, callback paho.MessageHandler) {
 func (m *mqttv5Client) Close() error {
 	return m.client.Disconnect(context.Background())
 }
+
+// auther answers authentication challenges of the broker during enhanced
+// authentication with the current authentication data
+type auther struct {
+	method string
+	data   func() ([]byte, error)
+	log    telegraf.Logger
+}
+
+func (a *auther) Authenticate(*mqttv5.Auth) *mqttv5.Auth {
+	// Reason code 0x18 continues the authentication, the broker rejects
+	// the connection if the data could not be retrieved. The client cannot
+	// abort the exchange itself, so log the reason for the rejection.
+	data, err := a.data()
+	if err != nil {
+		a.log.Errorf("Getting authentication data for method %q failed: %v", a.method, err)
+	}
+	return &mqttv5.Auth{
+		ReasonCode: 0x18,
+		Properties: &mqttv5.AuthProperties{
+			AuthMethod: a.method,
+			AuthData:   data,
+		},
+	}
+}
+
+func (*auther) Authenticated() {}
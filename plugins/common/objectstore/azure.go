@@ -0,0 +1,120 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+)
+
+type azureStore struct {
+	client    *azblob.Client
+	account   string
+	container string
+	prefix    string
+}
+
+func (c *Config) newAzureStore(account, container, prefix string) (*azureStore, error) {
+	if account == "" {
+		return nil, errors.New("missing storage account")
+	}
+	serviceURL := "https://" + account + ".blob.core.windows.net/"
+
+	// Use the account key if given and fall back to the environment,
+	// workload or managed identity otherwise
+	var client *azblob.Client
+	if !c.AzureAccountKey.Empty() {
+		key, err := c.AzureAccountKey.Get()
+		if err != nil {
+			return nil, fmt.Errorf("getting account key failed: %w", err)
+		}
+		defer key.Destroy()
+		cred, err := azblob.NewSharedKeyCredential(account, key.String())
+		if err != nil {
+			return nil, fmt.Errorf("creating credential failed: %w", err)
+		}
+		if client, err = azblob.NewClientWithSharedKeyCredential(serviceURL, cred, nil); err != nil {
+			return nil, fmt.Errorf("creating client failed: %w", err)
+		}
+	} else {
+		cred, err := azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return nil, fmt.Errorf("creating credential failed: %w", err)
+		}
+		if client, err = azblob.NewClient(serviceURL, cred, nil); err != nil {
+			return nil, fmt.Errorf("creating client failed: %w", err)
+		}
+	}
+
+	return &azureStore{client: client, account: account, container: container, prefix: prefix}, nil
+}
+
+func (s *azureStore) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.UploadBuffer(ctx, s.container, join(s.prefix, key), data, nil)
+	return err
+}
+
+func (s *azureStore) PutIfAbsent(ctx context.Context, key string, data []byte) error {
+	etag := azcore.ETagAny
+	options := &azblob.UploadBufferOptions{
+		AccessConditions: &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: &etag},
+		},
+	}
+	_, err := s.client.UploadBuffer(ctx, s.container, join(s.prefix, key), data, options)
+	if bloberror.HasCode(err, bloberror.BlobAlreadyExists, bloberror.ConditionNotMet) {
+		return ErrExists
+	}
+	return err
+}
+
+func (s *azureStore) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.client.DownloadStream(ctx, s.container, join(s.prefix, key), nil)
+	if err != nil {
+		if bloberror.HasCode(err, bloberror.BlobNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (s *azureStore) List(ctx context.Context, prefix string) ([]string, error) {
+	p := join(s.prefix, prefix)
+	pager := s.client.NewListBlobsFlatPager(s.container, &azblob.ListBlobsFlatOptions{Prefix: &p})
+
+	var keys []string
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Segment.BlobItems {
+			if item.Name == nil {
+				continue
+			}
+			key := *item.Name
+			if s.prefix != "" {
+				key = strings.TrimPrefix(key, s.prefix+"/")
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *azureStore) URL(key string) string {
+	return "abfss://" + s.container + "@" + s.account + ".dfs.core.windows.net/" + join(s.prefix, key)
+}
+
+func (*azureStore) Close() error {
+	return nil
+}
@@ -0,0 +1,129 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type fileStore struct {
+	root string
+}
+
+func newFileStore(root string) (*fileStore, error) {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("determining absolute path failed: %w", err)
+	}
+	if err := os.MkdirAll(root, 0750); err != nil {
+		return nil, fmt.Errorf("creating directory failed: %w", err)
+	}
+	return &fileStore{root: root}, nil
+}
+
+func (s *fileStore) Put(_ context.Context, key string, data []byte) error {
+	tmp, err := s.writeTemp(key, data)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path(key)); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func (s *fileStore) PutIfAbsent(_ context.Context, key string, data []byte) error {
+	tmp, err := s.writeTemp(key, data)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	// Hard-linking fails atomically if the target already exists
+	if err := os.Link(tmp, s.path(key)); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return ErrExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *fileStore) Get(_ context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *fileStore) List(_ context.Context, prefix string) ([]string, error) {
+	// Only walk the directory containing the prefix
+	dir := s.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		dir = s.path(prefix[:i])
+	}
+
+	var keys []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+func (s *fileStore) URL(key string) string {
+	return "file://" + filepath.ToSlash(s.path(key))
+}
+
+func (*fileStore) Close() error {
+	return nil
+}
+
+func (s *fileStore) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+// writeTemp writes the data to a temporary file in the directory of the key
+// to allow for atomically moving it in place
+func (s *fileStore) writeTemp(key string, data []byte) (string, error) {
+	dir := filepath.Dir(s.path(key))
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return "", fmt.Errorf("creating directory failed: %w", err)
+	}
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("creating file failed: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", fmt.Errorf("writing file failed: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("closing file failed: %w", err)
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,102 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/option"
+)
+
+type gcsStore struct {
+	client *storage.Client
+	bucket string
+	prefix string
+}
+
+func (c *Config) newGCSStore(bucket, prefix string) (*gcsStore, error) {
+	// Use the credentials file if given and fall back to the application
+	// default credentials otherwise
+	var options []option.ClientOption
+	if c.GCSCredentialsFile != "" {
+		options = append(options, option.WithCredentialsFile(c.GCSCredentialsFile))
+	}
+	client, err := storage.NewClient(context.Background(), options...)
+	if err != nil {
+		return nil, fmt.Errorf("creating client failed: %w", err)
+	}
+	return &gcsStore{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+func (s *gcsStore) Put(ctx context.Context, key string, data []byte) error {
+	return s.write(ctx, s.client.Bucket(s.bucket).Object(join(s.prefix, key)), data)
+}
+
+func (s *gcsStore) PutIfAbsent(ctx context.Context, key string, data []byte) error {
+	obj := s.client.Bucket(s.bucket).Object(join(s.prefix, key)).If(storage.Conditions{DoesNotExist: true})
+	err := s.write(ctx, obj, data)
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return ErrExists
+	}
+	return err
+}
+
+func (*gcsStore) write(ctx context.Context, obj *storage.ObjectHandle, data []byte) error {
+	// Cancel the context on errors to abort the upload
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w := obj.NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+func (s *gcsStore) Get(ctx context.Context, key string) ([]byte, error) {
+	r, err := s.client.Bucket(s.bucket).Object(join(s.prefix, key)).NewReader(ctx)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func (s *gcsStore) List(ctx context.Context, prefix string) ([]string, error) {
+	it := s.client.Bucket(s.bucket).Objects(ctx, &storage.Query{Prefix: join(s.prefix, prefix)})
+
+	var keys []string
+	for {
+		attrs, err := it.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		key := attrs.Name
+		if s.prefix != "" {
+			key = strings.TrimPrefix(key, s.prefix+"/")
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (s *gcsStore) URL(key string) string {
+	return "gs://" + s.bucket + "/" + join(s.prefix, key)
+}
+
+func (s *gcsStore) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,112 @@
+package objectstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/influxdata/telegraf/config"
+	common_aws "github.com/influxdata/telegraf/plugins/common/aws"
+)
+
+var (
+	// ErrExists is returned when conditionally creating an existing object
+	ErrExists = errors.New("object already exists")
+
+	// ErrNotFound is returned when reading a non-existing object
+	ErrNotFound = errors.New("object not found")
+)
+
+// Store provides access to the objects below a location in a local
+// directory or an object store. The keys are relative to the location and
+// use '/' as separator.
+type Store interface {
+	// Put creates or replaces the object
+	Put(ctx context.Context, key string, data []byte) error
+
+	// PutIfAbsent atomically creates the object and returns ErrExists if
+	// the object already exists
+	PutIfAbsent(ctx context.Context, key string, data []byte) error
+
+	// Get returns the content of the object or ErrNotFound
+	Get(ctx context.Context, key string) ([]byte, error)
+
+	// List returns the keys of all objects starting with the given prefix
+	List(ctx context.Context, prefix string) ([]string, error)
+
+	// URL returns the absolute location of the object
+	URL(key string) string
+
+	Close() error
+}
+
+// Config contains the credentials for accessing the object stores
+type Config struct {
+	AzureAccountKey    config.Secret `toml:"azure_account_key"`
+	GCSCredentialsFile string        `toml:"gcs_credentials_file"`
+	common_aws.CredentialConfig
+}
+
+// NewStore creates a store for the given location. Supported are local paths
+// ("/path" or "file:///path"), S3 ("s3://bucket/path"), Google Cloud Storage
+// ("gs://bucket/path") and Azure Blob Storage or Data Lake Storage
+// ("abfss://container@account.dfs.core.windows.net/path" or
+// "https://account.blob.core.windows.net/container/path").
+func (c *Config) NewStore(location string) (Store, error) {
+	if location == "" {
+		return nil, errors.New("empty location")
+	}
+	if !strings.Contains(location, "://") {
+		return newFileStore(location)
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("parsing location failed: %w", err)
+	}
+	prefix := strings.Trim(u.Path, "/")
+	switch u.Scheme {
+	case "file":
+		return newFileStore(filepath.FromSlash(u.Path))
+	case "s3", "s3a":
+		if u.Host == "" {
+			return nil, errors.New("missing bucket")
+		}
+		return c.newS3Store(u.Host, prefix)
+	case "gs":
+		if u.Host == "" {
+			return nil, errors.New("missing bucket")
+		}
+		return c.newGCSStore(u.Host, prefix)
+	case "abfs", "abfss":
+		// The host has the form "<container>@<account>.dfs.core.windows.net"
+		if u.User == nil || u.User.Username() == "" {
+			return nil, errors.New("missing container")
+		}
+		account, _, _ := strings.Cut(u.Host, ".")
+		return c.newAzureStore(account, u.User.Username(), prefix)
+	case "https":
+		// The host has the form "<account>.blob.core.windows.net"
+		account, suffix, _ := strings.Cut(u.Host, ".")
+		if !strings.HasPrefix(suffix, "blob.") && !strings.HasPrefix(suffix, "dfs.") {
+			return nil, fmt.Errorf("unsupported host %q", u.Host)
+		}
+		container, prefix, _ := strings.Cut(prefix, "/")
+		if container == "" {
+			return nil, errors.New("missing container")
+		}
+		return c.newAzureStore(account, container, prefix)
+	}
+	return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+}
+
+// join appends the key to the prefix of an object store
+func join(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "/" + key
+}
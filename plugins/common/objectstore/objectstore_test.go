@@ -0,0 +1,96 @@
+package objectstore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStoreFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		location string
+		expected string
+	}{
+		{
+			name:     "empty",
+			expected: "empty location",
+		},
+		{
+			name:     "unsupported scheme",
+			location: "ftp://example.com/data",
+			expected: "unsupported scheme \"ftp\"",
+		},
+		{
+			name:     "no bucket",
+			location: "s3:///data",
+			expected: "missing bucket",
+		},
+		{
+			name:     "no gcs bucket",
+			location: "gs:///data",
+			expected: "missing bucket",
+		},
+		{
+			name:     "no container",
+			location: "abfss://account.dfs.core.windows.net/data",
+			expected: "missing container",
+		},
+		{
+			name:     "unsupported host",
+			location: "https://example.com/container/data",
+			expected: "unsupported host \"example.com\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{}
+			_, err := cfg.NewStore(tt.location)
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &Config{}
+	store, err := cfg.NewStore("file://" + filepath.ToSlash(dir))
+	require.NoError(t, err)
+	defer store.Close()
+
+	ctx := t.Context()
+	_, err = store.Get(ctx, "log/0.json")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	// Objects can only be created once conditionally
+	require.NoError(t, store.PutIfAbsent(ctx, "log/0.json", []byte("first")))
+	require.ErrorIs(t, store.PutIfAbsent(ctx, "log/0.json", []byte("second")), ErrExists)
+	data, err := store.Get(ctx, "log/0.json")
+	require.NoError(t, err)
+	require.Equal(t, "first", string(data))
+
+	// Objects are replaced unconditionally
+	require.NoError(t, store.Put(ctx, "log/0.json", []byte("third")))
+	data, err = store.Get(ctx, "log/0.json")
+	require.NoError(t, err)
+	require.Equal(t, "third", string(data))
+
+	require.NoError(t, store.Put(ctx, "log/1.json", nil))
+	require.NoError(t, store.Put(ctx, "data/a/b.parquet", []byte("data")))
+
+	keys, err := store.List(ctx, "log/")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"log/0.json", "log/1.json"}, keys)
+
+	keys, err = store.List(ctx, "")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"log/0.json", "log/1.json", "data/a/b.parquet"}, keys)
+
+	keys, err = store.List(ctx, "missing/")
+	require.NoError(t, err)
+	require.Empty(t, keys)
+
+	require.Equal(t, "file://"+filepath.ToSlash(filepath.Join(dir, "data", "a", "b.parquet")), store.URL("data/a/b.parquet"))
+}
@@ -0,0 +1,105 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+type s3Store struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+func (c *Config) newS3Store(bucket, prefix string) (*s3Store, error) {
+	cfg, err := c.CredentialConfig.Credentials()
+	if err != nil {
+		return nil, fmt.Errorf("loading credentials failed: %w", err)
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		// S3 compatible stores usually require path-style addressing
+		if c.EndpointURL != "" {
+			o.BaseEndpoint = aws.String(c.EndpointURL)
+			o.UsePathStyle = true
+		}
+	})
+	return &s3Store{client: client, bucket: bucket, prefix: prefix}, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(join(s.prefix, key)),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
+
+func (s *s3Store) PutIfAbsent(ctx context.Context, key string, data []byte) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(join(s.prefix, key)),
+		Body:        bytes.NewReader(data),
+		IfNoneMatch: aws.String("*"),
+	})
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "PreconditionFailed" || apiErr.ErrorCode() == "ConditionalRequestConflict") {
+		return ErrExists
+	}
+	return err
+}
+
+func (s *s3Store) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(join(s.prefix, key)),
+	})
+	if err != nil {
+		var notFound *types.NoSuchKey
+		if errors.As(err, &notFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+func (s *s3Store) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	paginator := s3.NewListObjectsV2Paginator(s.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(join(s.prefix, prefix)),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			if s.prefix != "" {
+				key = strings.TrimPrefix(key, s.prefix+"/")
+			}
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func (s *s3Store) URL(key string) string {
+	return "s3://" + s.bucket + "/" + join(s.prefix, key)
+}
+
+func (*s3Store) Close() error {
+	return nil
+}
@@ -0,0 +1,155 @@
+package opcua
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+)
+
+// BrowsedVariable is a variable found when browsing the server
+type BrowsedVariable struct {
+	NodeID      *ua.NodeID
+	BrowseName  string
+	DisplayName string
+	// Path contains the display names of the objects between the browsed
+	// root node and the variable
+	Path []string
+}
+
+// BrowseVariables returns all variables below the given root node following
+// hierarchical references up to the given depth, zero means unlimited.
+// Properties of the nodes are not considered.
+func (o *OpcUAClient) BrowseVariables(ctx context.Context, root *ua.NodeID, maxDepth int) ([]BrowsedVariable, error) {
+	type entry struct {
+		nodeID *ua.NodeID
+		path   []string
+	}
+
+	visited := map[string]bool{root.String(): true}
+	queue := []entry{{nodeID: root}}
+
+	var found []BrowsedVariable
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		refs, err := o.Client.Node(current.nodeID).References(ctx, id.HierarchicalReferences, ua.BrowseDirectionForward,
+			ua.NodeClassObject|ua.NodeClassVariable, true)
+		if err != nil {
+			return nil, fmt.Errorf("browsing %q failed: %w", current.nodeID, err)
+		}
+		for _, ref := range refs {
+			if ref.NodeID == nil || ref.NodeID.NodeID == nil || ref.NodeID.ServerIndex != 0 {
+				continue
+			}
+			if ref.ReferenceTypeID != nil && ref.ReferenceTypeID.IntID() == id.HasProperty {
+				continue
+			}
+			nid := ref.NodeID.NodeID
+			if visited[nid.String()] {
+				continue
+			}
+			visited[nid.String()] = true
+
+			var browseName, displayName string
+			if ref.BrowseName != nil {
+				browseName = ref.BrowseName.Name
+			}
+			displayName = browseName
+			if ref.DisplayName != nil && ref.DisplayName.Text != "" {
+				displayName = ref.DisplayName.Text
+			}
+
+			if maxDepth == 0 || len(current.path) < maxDepth-1 {
+				path := make([]string, len(current.path), len(current.path)+1)
+				copy(path, current.path)
+				queue = append(queue, entry{nodeID: nid, path: append(path, displayName)})
+			}
+
+			if ref.NodeClass == ua.NodeClassVariable {
+				found = append(found, BrowsedVariable{
+					NodeID:      nid,
+					BrowseName:  browseName,
+					DisplayName: displayName,
+					Path:        current.path,
+				})
+			}
+		}
+	}
+
+	return found, nil
+}
+
+// NodeIDParts splits the node id into the namespace, identifier type and
+// identifier as used in the node configuration of the plugins
+func NodeIDParts(nid *ua.NodeID) (namespace, idType, identifier string, err error) {
+	switch nid.Type() {
+	case ua.NodeIDTypeTwoByte, ua.NodeIDTypeFourByte, ua.NodeIDTypeNumeric:
+		idType = "i"
+	case ua.NodeIDTypeString:
+		idType = "s"
+	case ua.NodeIDTypeGUID:
+		idType = "g"
+	case ua.NodeIDTypeByteString:
+		idType = "b"
+	default:
+		return "", "", "", fmt.Errorf("unsupported type of node id %q", nid)
+	}
+
+	namespace = strconv.Itoa(int(nid.Namespace()))
+
+	// Strip the namespace and the identifier type from the node id string
+	identifier = nid.String()
+	if nid.Namespace() != 0 {
+		identifier = strings.TrimPrefix(identifier, "ns="+namespace+";")
+	}
+	identifier = strings.TrimPrefix(identifier, idType+"=")
+
+	return namespace, idType, identifier, nil
+}
+
+// WriteNodeConfig writes the given variables as group of the given plugin
+// in TOML format. The display names are used as field names, duplicates are
+// made unique by prefixing the path of the variable.
+func WriteNodeConfig(w io.Writer, plugin, metricName string, variables []BrowsedVariable) error {
+	counts := make(map[string]int, len(variables))
+	for _, v := range variables {
+		counts[v.DisplayName]++
+	}
+
+	table := "inputs." + plugin + ".group"
+	if _, err := fmt.Fprintf(w, "[[%s]]\n  name = %q\n", table, metricName); err != nil {
+		return err
+	}
+
+	used := make(map[string]bool, len(variables))
+	for _, v := range variables {
+		namespace, idType, identifier, err := NodeIDParts(v.NodeID)
+		if err != nil {
+			return err
+		}
+
+		name := v.DisplayName
+		if counts[name] > 1 && len(v.Path) > 0 {
+			name = strings.Join(v.Path, ".") + "." + name
+		}
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s_%d", v.DisplayName, i)
+		}
+		used[name] = true
+
+		path := strings.Join(append(append([]string{}, v.Path...), v.DisplayName), "/")
+		path = strings.NewReplacer("\r", " ", "\n", " ").Replace(path)
+		if _, err := fmt.Fprintf(w, "\n  # %s\n  [[%s.nodes]]\n    name = %q\n    namespace = %q\n    identifier_type = %q\n    identifier = %q\n",
+			path, table, name, namespace, idType, identifier); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package opcua
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestBrowseVariables(t *testing.T) {
+	port := testutil.FreeTCPPort(t)
+
+	srv := server.New(
+		server.EndPoint("127.0.0.1", port),
+		server.EnableSecurity("None", ua.MessageSecurityModeNone),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+	)
+	ns := server.NewNodeNameSpace(srv, "urn:test")
+	addObject := func(parent *server.Node, nodeID, name string) *server.Node {
+		node := ns.AddNode(server.NewNode(
+			ua.NewStringNodeID(ns.ID(), nodeID),
+			map[ua.AttributeID]*ua.DataValue{
+				ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassObject)),
+				ua.AttributeIDBrowseName:  server.DataValueFromValue(&ua.QualifiedName{NamespaceIndex: ns.ID(), Name: name}),
+				ua.AttributeIDDisplayName: server.DataValueFromValue(&ua.LocalizedText{EncodingMask: ua.LocalizedTextText, Text: name}),
+			},
+			nil,
+			nil,
+		))
+		parent.AddRef(node, id.Organizes, true)
+		return node
+	}
+	addVariable := func(parent *server.Node, nodeID, name string, value interface{}) {
+		node := ns.AddNode(server.NewVariableNode(ua.NewStringNodeID(ns.ID(), nodeID), name, value))
+		parent.AddRef(node, id.HasComponent, true)
+	}
+	machine := addObject(ns.Objects(), "machine", "Machine")
+	addVariable(machine, "machine.temperature", "Temperature", 21.5)
+	motor1 := addObject(machine, "motor1", "Motor1")
+	addVariable(motor1, "motor1.speed", "Speed", int32(1500))
+	motor2 := addObject(machine, "motor2", "Motor2")
+	addVariable(motor2, "motor2.speed", "Speed", int32(1200))
+	// Cancelling the server context crashes the server, so use the background context
+	require.NoError(t, srv.Start(context.Background()))
+	defer srv.Close()
+
+	cfg := &OpcUAClientConfig{
+		Endpoint:       fmt.Sprintf("opc.tcp://127.0.0.1:%d", port),
+		SecurityPolicy: "None",
+		SecurityMode:   "None",
+		AuthMethod:     "Anonymous",
+		ConnectTimeout: config.Duration(5 * time.Second),
+		RequestTimeout: config.Duration(5 * time.Second),
+	}
+	client, err := cfg.CreateClient(testutil.Logger{})
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(t.Context()))
+	defer client.Disconnect(context.Background()) //nolint:errcheck // ignore error on cleanup
+
+	root := ua.NewStringNodeID(ns.ID(), "machine")
+
+	// Only browse the direct children
+	variables, err := client.BrowseVariables(t.Context(), root, 1)
+	require.NoError(t, err)
+	require.Len(t, variables, 1)
+	require.Equal(t, "Temperature", variables[0].DisplayName)
+
+	variables, err = client.BrowseVariables(t.Context(), root, 0)
+	require.NoError(t, err)
+	require.Len(t, variables, 3)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteNodeConfig(&buf, "opcua", "machine", variables))
+	expected := fmt.Sprintf(`[[inputs.opcua.group]]
+  name = "machine"
+
+  # Temperature
+  [[inputs.opcua.group.nodes]]
+    name = "Temperature"
+    namespace = "%[1]d"
+    identifier_type = "s"
+    identifier = "machine.temperature"
+
+  # Motor1/Speed
+  [[inputs.opcua.group.nodes]]
+    name = "Motor1.Speed"
+    namespace = "%[1]d"
+    identifier_type = "s"
+    identifier = "motor1.speed"
+
+  # Motor2/Speed
+  [[inputs.opcua.group.nodes]]
+    name = "Motor2.Speed"
+    namespace = "%[1]d"
+    identifier_type = "s"
+    identifier = "motor2.speed"
+`, ns.ID())
+	require.Equal(t, expected, buf.String())
+}
+
+func TestNodeIDParts(t *testing.T) {
+	for _, nid := range []string{
+		"ns=0;i=85",
+		"ns=3;s=a;b=c",
+		"ns=2;i=5",
+		"ns=1;g=5DF8E6B4-9B6F-4E31-89A9-1FAF6D0F8D15",
+		"ns=1;b=aGVsbG8=",
+	} {
+		parsed, err := ua.ParseNodeID(nid)
+		require.NoError(t, err)
+		namespace, idType, identifier, err := NodeIDParts(parsed)
+		require.NoError(t, err)
+		require.Equal(t, nid, "ns="+namespace+";"+idType+"="+identifier)
+	}
+}
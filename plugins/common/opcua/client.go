@@ -1,21 +1,29 @@
 package opcua
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha1" //nolint:gosec // G505: Blocklisted import crypto/sha1: weak cryptographic primitive - sha1 is the OPC UA thumbprint algorithm
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"log" //nolint:depguard // just for debug
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gopcua/opcua"
 	"github.com/gopcua/opcua/debug"
 	"github.com/gopcua/opcua/ua"
+	krbclient "github.com/jcmturner/gokrb5/v8/client"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
 	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/selfstat"
 )
 
 type OpcUAWorkarounds struct {
@@ -49,9 +57,20 @@ type OpcUAClientConfig struct {
 	RequestTimeout config.Duration `toml:"request_timeout"`
 	ClientTrace    bool            `toml:"client_trace"`
 
+	ServerCertThumbprint string `toml:"server_cert_thumbprint"`
+
+	KerberosConfigPath       string `toml:"kerberos_config_path"`
+	KerberosKeytabPath       string `toml:"kerberos_keytab_path"`
+	KerberosRealm            string `toml:"kerberos_realm"`
+	KerberosServicePrincipal string `toml:"kerberos_service_principal"`
+	KerberosDisablePAFXFAST  bool   `toml:"kerberos_disable_pafxfast"`
+
 	OptionalFields []string         `toml:"optional_fields"`
 	Workarounds    OpcUAWorkarounds `toml:"workarounds"`
 	SessionTimeout config.Duration  `toml:"session_timeout"`
+	MaxSessions    int              `toml:"max_sessions_per_endpoint"`
+
+	DiagnosticsAddress string `toml:"diagnostics_address"`
 }
 
 func (o *OpcUAClientConfig) Validate() error {
@@ -59,6 +78,25 @@ func (o *OpcUAClientConfig) Validate() error {
 		return fmt.Errorf("invalid 'optional_fields': %w", err)
 	}
 
+	if o.MaxSessions < 0 {
+		return errors.New("'max_sessions_per_endpoint' must not be negative")
+	}
+
+	if o.ServerCertThumbprint != "" {
+		if _, err := parseThumbprint(o.ServerCertThumbprint); err != nil {
+			return fmt.Errorf("invalid 'server_cert_thumbprint': %w", err)
+		}
+		if o.SecurityPolicy == "None" || o.SecurityMode == "None" {
+			return errors.New("'server_cert_thumbprint' requires a secure connection but security policy or mode is 'None'")
+		}
+	}
+
+	if strings.EqualFold(o.AuthMethod, "Kerberos") {
+		if err := o.validateKerberos(); err != nil {
+			return err
+		}
+	}
+
 	return o.validateEndpoint()
 }
 
@@ -119,8 +157,20 @@ type OpcUAClient struct {
 
 	Client *opcua.Client
 
-	opts  []opcua.Option
-	codes []ua.StatusCode
+	opts        []opcua.Option
+	codes       []ua.StatusCode
+	session     bool
+	sessionless bool
+
+	// DER encoded certificates used for the last connection
+	clientCert []byte
+	serverCert []byte
+
+	// connection information for the diagnostics
+	conn connectionInfo
+
+	// Kerberos client of the current connection, destroyed on disconnect
+	krb *krbclient.Client
 }
 
 // / setupOptions read the endpoints from the specified server and setup all authentication
@@ -198,16 +248,31 @@ func (o *OpcUAClient) Connect(ctx context.Context) error {
 			}
 		}
 
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(o.Config.ConnectTimeout))
+		defer cancel()
+
+		// Reserve a session unless we still hold the one of the previous
+		// connection
+		if !o.session {
+			if err := sessions.acquire(ctx, o.Config.Endpoint, o.Config.MaxSessions); err != nil {
+				return fmt.Errorf("waiting for a free session failed: %w", err)
+			}
+			o.session = true
+		}
+
+		o.sessionless = false
 		o.Client, err = opcua.NewClient(o.Config.Endpoint, o.opts...)
 		if err != nil {
+			o.releaseSession()
 			return fmt.Errorf("error in new client: %w", err)
 		}
-		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(o.Config.ConnectTimeout))
-		defer cancel()
 		if err := o.Client.Connect(ctx); err != nil {
+			o.releaseSession()
 			return fmt.Errorf("error in Client Connection: %w", err)
 		}
 		o.Log.Debug("Connected to OPC UA Server")
+		o.setConnectionInfo(o.Client, false)
+		o.updateCertificateExpiry()
 
 	default:
 		return fmt.Errorf("unsupported scheme %q in endpoint. Expected opc.tcp", u.Scheme)
@@ -215,6 +280,50 @@ func (o *OpcUAClient) Connect(ctx context.Context) error {
 	return nil
 }
 
+// ConnectSessionless only opens a secure channel to the OPC UA device without
+// creating a session. Services sent via the client are invoked session-less
+// as defined in OPC UA 1.05 using anonymous access. The connection does not
+// count against the session limit of the endpoint.
+func (o *OpcUAClient) ConnectSessionless(ctx context.Context) error {
+	o.Log.Debug("Connecting OPC UA Client to server without session")
+	u, err := url.Parse(o.Config.Endpoint)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "opc.tcp" {
+		return fmt.Errorf("unsupported scheme %q in endpoint. Expected opc.tcp", u.Scheme)
+	}
+
+	if err := o.SetupOptions(); err != nil {
+		return err
+	}
+
+	if o.Client != nil {
+		o.Log.Warnf("Closing connection to %q as already connected", u)
+		if err := o.Client.Close(ctx); err != nil {
+			o.Log.Errorf("Closing connection failed: %v", err)
+		}
+		o.releaseSession()
+	}
+
+	o.Client, err = opcua.NewClient(o.Config.Endpoint, o.opts...)
+	if err != nil {
+		return fmt.Errorf("error in new client: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(o.Config.ConnectTimeout))
+	defer cancel()
+	if err := o.Client.Dial(ctx); err != nil {
+		o.Client = nil
+		return fmt.Errorf("error opening secure channel: %w", err)
+	}
+	o.sessionless = true
+	o.Log.Debug("Opened secure channel to OPC UA Server")
+	o.setConnectionInfo(o.Client, true)
+	o.updateCertificateExpiry()
+
+	return nil
+}
+
 func (o *OpcUAClient) Disconnect(ctx context.Context) error {
 	o.Log.Debug("Disconnecting from OPC UA Server")
 	u, err := url.Parse(o.Config.Endpoint)
@@ -227,15 +336,111 @@ func (o *OpcUAClient) Disconnect(ctx context.Context) error {
 		// We can't do anything about failing to close a connection
 		err := o.Client.Close(ctx)
 		o.Client = nil
+		o.setConnectionInfo(nil, false)
+		o.sessionless = false
+		o.releaseSession()
+		o.destroyKerberos()
 		return err
 	default:
 		return errors.New("invalid controller")
 	}
 }
 
+// releaseSession returns the session reserved for the client, if any, to
+// the connection manager
+func (o *OpcUAClient) releaseSession() {
+	if o.session {
+		sessions.release(o.Config.Endpoint)
+		o.session = false
+	}
+}
+
+// updateCertificateExpiry reports the number of days until the client and
+// server certificates of the connection expire as internal metrics
+func (o *OpcUAClient) updateCertificateExpiry() {
+	tags := map[string]string{"endpoint": o.Config.Endpoint}
+	for field, der := range map[string][]byte{
+		"client_certificate_expiry_days": o.clientCert,
+		"server_certificate_expiry_days": o.serverCert,
+	} {
+		if len(der) == 0 {
+			continue
+		}
+		certs, err := x509.ParseCertificates(der)
+		if err != nil || len(certs) == 0 {
+			o.Log.Debugf("Parsing certificate for %q failed: %v", field, err)
+			continue
+		}
+		days := int64(time.Until(certs[0].NotAfter).Hours() / 24)
+		selfstat.Register("opcua", field, tags).Set(days)
+		if days < 0 {
+			o.Log.Warnf("Certificate %q expired on %s", certs[0].Subject, certs[0].NotAfter)
+		}
+	}
+}
+
+// parseThumbprint decodes the hex-encoded SHA-1 or SHA-256 thumbprint of a
+// certificate allowing colons or spaces as separators
+func parseThumbprint(s string) ([]byte, error) {
+	s = strings.NewReplacer(":", "", " ", "").Replace(s)
+	thumbprint, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("decoding %q failed: %w", s, err)
+	}
+	switch len(thumbprint) {
+	case sha1.Size, sha256.Size:
+	default:
+		return nil, fmt.Errorf("expected a SHA-1 or SHA-256 thumbprint but got %d bytes", len(thumbprint))
+	}
+	return thumbprint, nil
+}
+
+// verifyServerCertificate checks that the certificate of the given endpoint
+// matches the configured thumbprint
+func (o *OpcUAClient) verifyServerCertificate(endpoint *ua.EndpointDescription) error {
+	expected, err := parseThumbprint(o.Config.ServerCertThumbprint)
+	if err != nil {
+		return err
+	}
+	if endpoint.SecurityMode == ua.MessageSecurityModeNone {
+		return fmt.Errorf("pinning the server certificate requires a secure connection but endpoint %s uses security mode None",
+			endpoint.EndpointURL)
+	}
+
+	// The server might send a certificate chain with its own certificate first
+	certs, err := x509.ParseCertificates(endpoint.ServerCertificate)
+	if err != nil || len(certs) == 0 {
+		return fmt.Errorf("parsing server certificate of endpoint %s failed: %w", endpoint.EndpointURL, err)
+	}
+
+	var actual []byte
+	if len(expected) == sha1.Size {
+		sum := sha1.Sum(certs[0].Raw)
+		actual = sum[:]
+	} else {
+		sum := sha256.Sum256(certs[0].Raw)
+		actual = sum[:]
+	}
+	if !bytes.Equal(actual, expected) {
+		return fmt.Errorf("thumbprint %X of server certificate %q does not match the configured 'server_cert_thumbprint'",
+			actual, certs[0].Subject)
+	}
+	o.Log.Debugf("Server certificate %q matches the configured thumbprint", certs[0].Subject)
+
+	return nil
+}
+
 func (o *OpcUAClient) State() ConnectionState {
 	if o.Client == nil {
 		return Disconnected
 	}
+	// Without session the client state is never updated, so rely on the
+	// secure channel instead
+	if o.sessionless {
+		if o.Client.SecureChannel() == nil {
+			return Disconnected
+		}
+		return Connected
+	}
 	return ConnectionState(o.Client.State())
 }
@@ -1,10 +1,22 @@
 package opcua
 
 import (
+	"crypto/sha1" //nolint:gosec // G505: Blocklisted import crypto/sha1: weak cryptographic primitive - sha1 is the OPC UA thumbprint algorithm
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gopcua/opcua/ua"
 	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/selfstat"
+	"github.com/influxdata/telegraf/testutil"
 )
 
 func TestSetupWorkarounds(t *testing.T) {
@@ -31,3 +43,192 @@ func TestCheckStatusCode(t *testing.T) {
 	o.codes = []ua.StatusCode{ua.StatusCode(0), ua.StatusCode(192), ua.StatusCode(11141120)}
 	require.True(t, o.StatusCodeOK(ua.StatusCode(192)))
 }
+
+func TestConvertTo(t *testing.T) {
+	tests := []struct {
+		dataType string
+		input    interface{}
+		expected interface{}
+	}{
+		{"", int64(42), int64(42)},
+		{"Boolean", int64(1), true},
+		{"SByte", int64(-3), int8(-3)},
+		{"Byte", uint64(3), uint8(3)},
+		{"Int16", 3.0, int16(3)},
+		{"UInt16", int64(3), uint16(3)},
+		{"Int32", "42", int32(42)},
+		{"UInt32", int64(42), uint32(42)},
+		{"Int64", uint64(42), int64(42)},
+		{"UInt64", int64(42), uint64(42)},
+		{"Float", 1.5, float32(1.5)},
+		{"Double", int64(2), 2.0},
+		{"String", 1.5, "1.5"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dataType, func(t *testing.T) {
+			actual, err := ConvertTo(tt.input, tt.dataType)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+
+	_, err := ConvertTo(int64(1000), "Byte")
+	require.ErrorIs(t, err, internal.ErrOutOfRange)
+}
+
+func TestCertificateExpiry(t *testing.T) {
+	certFile, keyFile, err := generateCert("urn:telegraf:test", 2048, "", "", 30*24*time.Hour)
+	require.NoError(t, err)
+	defer os.Remove(certFile)
+	defer os.Remove(keyFile)
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+
+	o := OpcUAClient{
+		Config:     &OpcUAClientConfig{Endpoint: "opc.tcp://cert-expiry-test:4840"},
+		Log:        testutil.Logger{},
+		clientCert: cert.Certificate[0],
+	}
+	o.updateCertificateExpiry()
+
+	var found bool
+	for _, m := range selfstat.Metrics() {
+		if m.Name() != "internal_opcua" || m.Tags()["endpoint"] != o.Config.Endpoint {
+			continue
+		}
+		days, ok := m.GetField("client_certificate_expiry_days")
+		require.True(t, ok)
+		require.Equal(t, int64(29), days)
+		require.False(t, m.HasField("server_certificate_expiry_days"))
+		found = true
+	}
+	require.True(t, found)
+}
+
+func TestValidateServerCertThumbprint(t *testing.T) {
+	cfg := &OpcUAClientConfig{
+		Endpoint:             "opc.tcp://localhost:4840",
+		SecurityPolicy:       "auto",
+		SecurityMode:         "auto",
+		ServerCertThumbprint: "00:11:22:33:44:55:66:77:88:99:aa:bb:cc:dd:ee:ff:00:11:22:33",
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.ServerCertThumbprint = "0011"
+	require.ErrorContains(t, cfg.Validate(), "expected a SHA-1 or SHA-256 thumbprint but got 2 bytes")
+
+	cfg.ServerCertThumbprint = "xyz"
+	require.ErrorContains(t, cfg.Validate(), "invalid 'server_cert_thumbprint'")
+
+	cfg.ServerCertThumbprint = strings.Repeat("ab", sha256.Size)
+	cfg.SecurityMode = "None"
+	require.ErrorContains(t, cfg.Validate(), "requires a secure connection")
+}
+
+func TestVerifyServerCertificate(t *testing.T) {
+	certFile, keyFile, err := generateCert("urn:telegraf:test", 2048, "", "", 24*time.Hour)
+	require.NoError(t, err)
+	defer os.Remove(certFile)
+	defer os.Remove(keyFile)
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+	der := cert.Certificate[0]
+	sha1Sum := sha1.Sum(der)
+	sha256Sum := sha256.Sum256(der)
+
+	endpoint := &ua.EndpointDescription{
+		EndpointURL:       "opc.tcp://localhost:4840",
+		SecurityMode:      ua.MessageSecurityModeSignAndEncrypt,
+		ServerCertificate: der,
+	}
+
+	for _, thumbprint := range []string{
+		hex.EncodeToString(sha1Sum[:]),
+		strings.ToUpper(hex.EncodeToString(sha256Sum[:])),
+	} {
+		o := OpcUAClient{
+			Config: &OpcUAClientConfig{ServerCertThumbprint: thumbprint},
+			Log:    testutil.Logger{},
+		}
+		require.NoError(t, o.verifyServerCertificate(endpoint))
+	}
+
+	o := OpcUAClient{
+		Config: &OpcUAClientConfig{ServerCertThumbprint: strings.Repeat("00", sha1.Size)},
+		Log:    testutil.Logger{},
+	}
+	require.ErrorContains(t, o.verifyServerCertificate(endpoint), "does not match the configured 'server_cert_thumbprint'")
+
+	o.Config.ServerCertThumbprint = hex.EncodeToString(sha1Sum[:])
+	endpoint.SecurityMode = ua.MessageSecurityModeNone
+	require.ErrorContains(t, o.verifyServerCertificate(endpoint), "requires a secure connection")
+}
+
+func TestValidateKerberos(t *testing.T) {
+	cfg := &OpcUAClientConfig{
+		Endpoint:                 "opc.tcp://localhost:4840",
+		SecurityPolicy:           "auto",
+		SecurityMode:             "auto",
+		AuthMethod:               "Kerberos",
+		Username:                 config.NewSecret([]byte("telegraf")),
+		Password:                 config.NewSecret([]byte("secret")),
+		KerberosRealm:            "EXAMPLE.COM",
+		KerberosServicePrincipal: "opcua/plc.example.com",
+	}
+	require.NoError(t, cfg.Validate())
+
+	cfg.Password = config.NewSecret(nil)
+	require.ErrorContains(t, cfg.Validate(), "either 'password' or 'kerberos_keytab_path' is required")
+	cfg.KerberosKeytabPath = "/etc/telegraf/telegraf.keytab"
+	require.NoError(t, cfg.Validate())
+
+	cfg.KerberosServicePrincipal = ""
+	require.ErrorContains(t, cfg.Validate(), "'kerberos_service_principal' is required")
+
+	cfg.KerberosRealm = ""
+	require.ErrorContains(t, cfg.Validate(), "'kerberos_realm' is required")
+
+	cfg.Username = config.NewSecret(nil)
+	require.ErrorContains(t, cfg.Validate(), "'username' is required")
+}
+
+func TestKerberosPolicyID(t *testing.T) {
+	endpoint := &ua.EndpointDescription{
+		EndpointURL: "opc.tcp://localhost:4840",
+		UserIdentityTokens: []*ua.UserTokenPolicy{
+			{PolicyID: "anonymous", TokenType: ua.UserTokenTypeAnonymous},
+			{PolicyID: "jwt", TokenType: ua.UserTokenTypeIssuedToken, IssuedTokenType: "http://opcfoundation.org/UA/UserToken#JWT"},
+			{PolicyID: "kerberos", TokenType: ua.UserTokenTypeIssuedToken, IssuedTokenType: KerberosTokenType},
+		},
+	}
+	id, err := kerberosPolicyID(endpoint)
+	require.NoError(t, err)
+	require.Equal(t, "kerberos", id)
+
+	endpoint.UserIdentityTokens = endpoint.UserIdentityTokens[:2]
+	_, err = kerberosPolicyID(endpoint)
+	require.ErrorContains(t, err, "does not support Kerberos user tokens")
+}
+
+func TestKerberosTokenInvalidConfig(t *testing.T) {
+	cfg := &OpcUAClientConfig{
+		Endpoint:                 "opc.tcp://localhost:4840",
+		SecurityPolicy:           "None",
+		SecurityMode:             "None",
+		AuthMethod:               "Kerberos",
+		Username:                 config.NewSecret([]byte("telegraf")),
+		Password:                 config.NewSecret([]byte("secret")),
+		KerberosConfigPath:       "testdata/non-existing-krb5.conf",
+		KerberosRealm:            "EXAMPLE.COM",
+		KerberosServicePrincipal: "opcua/plc.example.com",
+	}
+	client, err := cfg.CreateClient(testutil.Logger{})
+	require.NoError(t, err)
+
+	_, err = client.kerberosToken()
+	require.ErrorContains(t, err, "loading kerberos config")
+	require.Nil(t, client.krb)
+}
@@ -0,0 +1,57 @@
+package opcua
+
+import (
+	"fmt"
+
+	"github.com/influxdata/telegraf/internal"
+)
+
+// DataTypes are the OPC UA built-in types values can be converted to
+var DataTypes = []string{
+	"Boolean",
+	"SByte",
+	"Byte",
+	"Int16",
+	"UInt16",
+	"Int32",
+	"UInt32",
+	"Int64",
+	"UInt64",
+	"Float",
+	"Double",
+	"String",
+}
+
+// ConvertTo converts the given value to the Go type corresponding to the
+// given OPC UA data type. An empty data type returns the value unchanged.
+func ConvertTo(v interface{}, dataType string) (interface{}, error) {
+	switch dataType {
+	case "":
+		return v, nil
+	case "Boolean":
+		return internal.ToBool(v)
+	case "SByte":
+		return internal.ToInt8(v)
+	case "Byte":
+		return internal.ToUint8(v)
+	case "Int16":
+		return internal.ToInt16(v)
+	case "UInt16":
+		return internal.ToUint16(v)
+	case "Int32":
+		return internal.ToInt32(v)
+	case "UInt32":
+		return internal.ToUint32(v)
+	case "Int64":
+		return internal.ToInt64(v)
+	case "UInt64":
+		return internal.ToUint64(v)
+	case "Float":
+		return internal.ToFloat32(v)
+	case "Double":
+		return internal.ToFloat64(v)
+	case "String":
+		return internal.ToString(v)
+	}
+	return nil, fmt.Errorf("unknown data type %q", dataType)
+}
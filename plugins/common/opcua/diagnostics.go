@@ -0,0 +1,173 @@
+package opcua
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gopcua/opcua"
+)
+
+// DiagnosticsPath is the path of the diagnostics endpoint served on the
+// address configured via 'diagnostics_address'
+const DiagnosticsPath = "/debug/opcua"
+
+// ClientDiagnostics contains the connection state of a client
+type ClientDiagnostics struct {
+	Endpoint       string     `json:"endpoint"`
+	State          string     `json:"state"`
+	SecurityPolicy string     `json:"security_policy,omitempty"`
+	SecurityMode   string     `json:"security_mode,omitempty"`
+	RemoteAddress  string     `json:"remote_address,omitempty"`
+	Session        bool       `json:"session"`
+	SessionTimeout string     `json:"session_timeout,omitempty"`
+	ConnectedSince *time.Time `json:"connected_since,omitempty"`
+}
+
+// connectionInfo is the information on the current connection of a client
+// kept for the diagnostics as the client is replaced on reconnects
+type connectionInfo struct {
+	sync.Mutex
+	client         *opcua.Client
+	securityPolicy string
+	securityMode   string
+	sessionless    bool
+	connectedSince time.Time
+}
+
+func (o *OpcUAClient) setConnectionInfo(client *opcua.Client, sessionless bool) {
+	o.conn.Lock()
+	defer o.conn.Unlock()
+
+	o.conn.client = client
+	o.conn.sessionless = sessionless
+	o.conn.connectedSince = time.Now()
+}
+
+// Diagnostics returns the state of the current connection
+func (o *OpcUAClient) Diagnostics() ClientDiagnostics {
+	o.conn.Lock()
+	defer o.conn.Unlock()
+
+	d := ClientDiagnostics{
+		Endpoint:       o.Config.Endpoint,
+		State:          Disconnected.String(),
+		SecurityPolicy: o.conn.securityPolicy,
+		SecurityMode:   o.conn.securityMode,
+	}
+	if o.conn.client == nil {
+		return d
+	}
+
+	d.State = ConnectionState(o.conn.client.State()).String()
+	if d.State != Connected.String() {
+		return d
+	}
+	connectedSince := o.conn.connectedSince
+	d.ConnectedSince = &connectedSince
+	if sc := o.conn.client.SecureChannel(); sc != nil {
+		d.RemoteAddress = sc.RemoteAddr().String()
+	}
+	if s := o.conn.client.Session(); s != nil && !o.conn.sessionless {
+		d.Session = true
+		d.SessionTimeout = s.RevisedTimeout().String()
+	}
+	return d
+}
+
+var diagnostics = &diagnosticsRegistry{servers: make(map[string]*diagnosticsServer)}
+
+type diagnosticsRegistry struct {
+	sync.Mutex
+	servers map[string]*diagnosticsServer
+	next    int
+}
+
+// diagnosticsServer serves the diagnostics of the plugin instances
+// registered for the address of the server
+type diagnosticsServer struct {
+	server    *http.Server
+	listener  net.Listener
+	providers map[string]func() interface{}
+}
+
+// RegisterDiagnostics adds a function providing the diagnostics of a plugin
+// instance to the diagnostics endpoint listening on the given address. The
+// endpoint is started with the first instance registered for the address
+// and stopped once all instances are removed. An empty address disables the
+// diagnostics of the instance. The returned function removes the instance
+// again and has to be called when stopping the plugin.
+func RegisterDiagnostics(address, name string, provider func() interface{}) (unregister func(), err error) {
+	if address == "" {
+		return func() {}, nil
+	}
+
+	diagnostics.Lock()
+	defer diagnostics.Unlock()
+
+	srv, found := diagnostics.servers[address]
+	if !found {
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			return nil, fmt.Errorf("starting diagnostics endpoint failed: %w", err)
+		}
+		srv = &diagnosticsServer{
+			listener:  listener,
+			providers: make(map[string]func() interface{}),
+		}
+		mux := http.NewServeMux()
+		mux.HandleFunc(DiagnosticsPath, srv.serve)
+		srv.server = &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			_ = srv.server.Serve(listener)
+		}()
+		diagnostics.servers[address] = srv
+	}
+
+	diagnostics.next++
+	key := name + "#" + strconv.Itoa(diagnostics.next)
+	srv.providers[key] = provider
+
+	return func() {
+		diagnostics.Lock()
+		defer diagnostics.Unlock()
+
+		delete(srv.providers, key)
+		if len(srv.providers) == 0 && diagnostics.servers[address] == srv {
+			delete(diagnostics.servers, address)
+			_ = srv.server.Close()
+		}
+	}, nil
+}
+
+// diagnostics returns the diagnostics of all plugin instances registered
+// for the server indexed by the instance name
+func (s *diagnosticsServer) diagnostics() map[string]interface{} {
+	diagnostics.Lock()
+	providers := make(map[string]func() interface{}, len(s.providers))
+	for k, p := range s.providers {
+		providers[k] = p
+	}
+	diagnostics.Unlock()
+
+	// Collect the data without holding the lock to not block registrations
+	dump := make(map[string]interface{}, len(providers))
+	for k, p := range providers {
+		dump[k] = p()
+	}
+	return dump
+}
+
+func (s *diagnosticsServer) serve(w http.ResponseWriter, _ *http.Request) {
+	buf, err := json.MarshalIndent(s.diagnostics(), "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(buf)
+}
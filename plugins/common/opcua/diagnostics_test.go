@@ -0,0 +1,61 @@
+package opcua
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnosticsEndpoint(t *testing.T) {
+	client := &OpcUAClient{Config: &OpcUAClientConfig{Endpoint: "opc.tcp://diagnostics-test:4840"}}
+	unregister, err := RegisterDiagnostics("127.0.0.1:0", "inputs.test", func() interface{} { return client.Diagnostics() })
+	require.NoError(t, err)
+
+	diagnostics.Lock()
+	srv := diagnostics.servers["127.0.0.1:0"]
+	diagnostics.Unlock()
+	require.NotNil(t, srv)
+	addr := srv.listener.Addr().String()
+
+	resp, err := http.Get("http://" + addr + DiagnosticsPath)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var dump map[string]ClientDiagnostics
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&dump))
+	require.Len(t, dump, 1)
+	for name, d := range dump {
+		require.Contains(t, name, "inputs.test#")
+		require.Equal(t, ClientDiagnostics{Endpoint: "opc.tcp://diagnostics-test:4840", State: "Disconnected"}, d)
+	}
+
+	// Removing the last instance stops the endpoint
+	unregister()
+	require.Empty(t, srv.diagnostics())
+	_, err = net.Dial("tcp", addr)
+	require.Error(t, err)
+}
+
+func TestDiagnosticsDisabled(t *testing.T) {
+	unregister, err := RegisterDiagnostics("", "inputs.test", func() interface{} { return nil })
+	require.NoError(t, err)
+	unregister()
+
+	diagnostics.Lock()
+	defer diagnostics.Unlock()
+	require.Empty(t, diagnostics.servers)
+}
+
+func TestDiagnosticsNotOnDefaultServeMux(t *testing.T) {
+	// The diagnostics must only be served on the configured address and not
+	// on the default mux used e.g. by the pprof server
+	recorder := httptest.NewRecorder()
+	http.DefaultServeMux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, DiagnosticsPath, nil))
+	require.Equal(t, http.StatusNotFound, recorder.Code)
+}
@@ -0,0 +1,126 @@
+package input
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// CheckAccessLevels reads the AccessLevel and UserAccessLevel attributes of
+// all configured nodes and reports the nodes that cannot be read by the
+// authenticated user. Depending on the 'access_level_check' setting, the
+// problems are logged as warnings or returned as error. The check is only
+// performed until it succeeded once, i.e. on startup.
+func (o *OpcUAInputClient) CheckAccessLevels(ctx context.Context) error {
+	switch o.Config.AccessLevelCheck {
+	case "", "none":
+		return nil
+	}
+	if o.accessLevelsChecked || len(o.NodeIDs) == 0 {
+		return nil
+	}
+
+	problems, err := o.probeAccessLevels(ctx)
+	if err != nil {
+		return fmt.Errorf("probing access levels failed: %w", err)
+	}
+	if len(problems) == 0 {
+		o.Log.Debugf("All %d nodes are readable", len(o.NodeIDs))
+		o.accessLevelsChecked = true
+		return nil
+	}
+
+	if o.Config.AccessLevelCheck == "error" {
+		return fmt.Errorf("%d of %d nodes cannot be read:\n%s", len(problems), len(o.NodeIDs), strings.Join(problems, "\n"))
+	}
+
+	o.Log.Warnf("%d of %d nodes cannot be read, reading them will fail:", len(problems), len(o.NodeIDs))
+	for _, p := range problems {
+		o.Log.Warn(p)
+	}
+	o.accessLevelsChecked = true
+
+	return nil
+}
+
+// probeAccessLevels returns a description for each node the user is not
+// allowed to read
+func (o *OpcUAInputClient) probeAccessLevels(ctx context.Context) ([]string, error) {
+	if o.Client == nil {
+		return nil, errors.New("not connected")
+	}
+
+	req := &ua.ReadRequest{
+		NodesToRead:        make([]*ua.ReadValueID, 0, 2*len(o.NodeIDs)),
+		TimestampsToReturn: ua.TimestampsToReturnNeither,
+	}
+	for _, nid := range o.NodeIDs {
+		req.NodesToRead = append(req.NodesToRead,
+			&ua.ReadValueID{NodeID: nid, AttributeID: ua.AttributeIDAccessLevel},
+			&ua.ReadValueID{NodeID: nid, AttributeID: ua.AttributeIDUserAccessLevel},
+		)
+	}
+
+	resp, err := o.Client.Read(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != len(req.NodesToRead) {
+		return nil, fmt.Errorf("expected %d results but got %d", len(req.NodesToRead), len(resp.Results))
+	}
+
+	var problems []string
+	for i, nid := range o.NodeIDs {
+		node := fmt.Sprintf("node %q (%s)", o.NodeMetricMapping[i].Tag.FieldName, nid.String())
+		access, user := resp.Results[2*i], resp.Results[2*i+1]
+
+		switch access.Status {
+		case ua.StatusOK:
+			if level, ok := accessLevel(access); ok && level&ua.AccessLevelTypeCurrentRead == 0 {
+				problems = append(problems, node+": node is not readable at all (AccessLevel lacks CurrentRead)")
+				continue
+			}
+		case ua.StatusBadNodeIDUnknown, ua.StatusBadNodeIDInvalid:
+			problems = append(problems, fmt.Sprintf("%s: node does not exist on the server (%v)", node, access.Status))
+			continue
+		case ua.StatusBadAttributeIDInvalid:
+			// The server does not expose the attribute, e.g. for non-variable
+			// nodes, so we cannot tell
+		default:
+			o.Log.Debugf("Reading AccessLevel of %s failed: %v", node, access.Status)
+		}
+
+		switch user.Status {
+		case ua.StatusOK:
+			if level, ok := accessLevel(user); ok && level&ua.AccessLevelTypeCurrentRead == 0 {
+				problems = append(problems, fmt.Sprintf(
+					"%s: node is not readable by the authenticated user (auth_method %q); check the user's permissions on the server",
+					node, o.Config.AuthMethod,
+				))
+			}
+		case ua.StatusBadUserAccessDenied:
+			problems = append(problems, fmt.Sprintf(
+				"%s: access denied for the authenticated user (auth_method %q); check the user's permissions on the server",
+				node, o.Config.AuthMethod,
+			))
+		case ua.StatusBadAttributeIDInvalid:
+			// The server does not expose the attribute so we cannot tell
+		default:
+			o.Log.Debugf("Reading UserAccessLevel of %s failed: %v", node, user.Status)
+		}
+	}
+
+	return problems, nil
+}
+
+// accessLevel extracts the access level from the given data value
+func accessLevel(v *ua.DataValue) (ua.AccessLevelType, bool) {
+	if v.Value == nil {
+		return 0, false
+	}
+	level, ok := v.Value.Value().(byte)
+	return ua.AccessLevelType(level), ok
+}
@@ -0,0 +1,95 @@
+package input
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/common/opcua"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestCheckAccessLevels(t *testing.T) {
+	port := testutil.FreeTCPPort(t)
+
+	srv := server.New(
+		server.EndPoint("127.0.0.1", port),
+		server.EnableSecurity("None", ua.MessageSecurityModeNone),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+	)
+	ns := server.NewNodeNameSpace(srv, "urn:test")
+	for name, levels := range map[string][2]ua.AccessLevelType{
+		"readable":   {ua.AccessLevelTypeCurrentRead, ua.AccessLevelTypeCurrentRead},
+		"userdenied": {ua.AccessLevelTypeCurrentRead, ua.AccessLevelTypeNone},
+		"writeonly":  {ua.AccessLevelTypeCurrentWrite, ua.AccessLevelTypeCurrentWrite},
+	} {
+		ns.AddNode(server.NewNode(
+			ua.NewStringNodeID(ns.ID(), name),
+			map[ua.AttributeID]*ua.DataValue{
+				ua.AttributeIDNodeClass:       server.DataValueFromValue(uint32(ua.NodeClassVariable)),
+				ua.AttributeIDAccessLevel:     server.DataValueFromValue(byte(levels[0])),
+				ua.AttributeIDUserAccessLevel: server.DataValueFromValue(byte(levels[1])),
+			},
+			nil,
+			func() *ua.DataValue { return server.DataValueFromValue(int32(42)) },
+		))
+	}
+	// Cancelling the server context crashes the server, so use the background context
+	require.NoError(t, srv.Start(context.Background()))
+	defer srv.Close()
+
+	namespace := strconv.Itoa(int(ns.ID()))
+	cfg := InputClientConfig{
+		OpcUAClientConfig: opcua.OpcUAClientConfig{
+			Endpoint:       fmt.Sprintf("opc.tcp://127.0.0.1:%d", port),
+			SecurityPolicy: "None",
+			SecurityMode:   "None",
+			AuthMethod:     "Anonymous",
+			ConnectTimeout: config.Duration(5 * time.Second),
+			RequestTimeout: config.Duration(5 * time.Second),
+		},
+		MetricName: "testing",
+		RootNodes: []NodeSettings{
+			{FieldName: "readable", Namespace: namespace, IdentifierType: "s", Identifier: "readable"},
+			{FieldName: "userdenied", Namespace: namespace, IdentifierType: "s", Identifier: "userdenied"},
+			{FieldName: "writeonly", Namespace: namespace, IdentifierType: "s", Identifier: "writeonly"},
+			{FieldName: "missing", Namespace: namespace, IdentifierType: "s", Identifier: "missing"},
+		},
+		AccessLevelCheck: "error",
+	}
+
+	client, err := cfg.CreateInputClient(testutil.Logger{})
+	require.NoError(t, err)
+	require.NoError(t, client.InitNodeIDs())
+	require.NoError(t, client.Connect(t.Context()))
+	defer client.Disconnect(context.Background()) //nolint:errcheck // ignore error on cleanup
+
+	err = client.CheckAccessLevels(t.Context())
+	require.ErrorContains(t, err, "3 of 4 nodes cannot be read")
+	require.ErrorContains(t, err, `node "userdenied" (ns=`+namespace+`;s=userdenied): node is not readable by the authenticated user`)
+	require.ErrorContains(t, err, `node "writeonly" (ns=`+namespace+`;s=writeonly): node is not readable at all`)
+	require.ErrorContains(t, err, `node "missing" (ns=`+namespace+`;s=missing): node does not exist`)
+	require.NotContains(t, err.Error(), `"readable"`)
+	require.False(t, client.accessLevelsChecked)
+
+	// Only warn about unreadable nodes and skip the check afterwards
+	client.Config.AccessLevelCheck = "warn"
+	require.NoError(t, client.CheckAccessLevels(t.Context()))
+	require.True(t, client.accessLevelsChecked)
+}
+
+func TestAccessLevelCheckInvalid(t *testing.T) {
+	cfg := InputClientConfig{
+		MetricName:       "testing",
+		RootNodes:        []NodeSettings{{FieldName: "foo", Namespace: "1", IdentifierType: "s", Identifier: "foo"}},
+		AccessLevelCheck: "fail",
+	}
+	require.ErrorContains(t, cfg.Validate(), "invalid 'access_level_check'")
+}
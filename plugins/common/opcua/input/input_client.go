@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/benbjohnson/clock"
 	"github.com/gopcua/opcua/id"
 	"github.com/gopcua/opcua/ua"
 
@@ -38,6 +39,10 @@ type DataChangeFilter struct {
 	Trigger       Trigger      `toml:"trigger"`
 	DeadbandType  DeadbandType `toml:"deadband_type"`
 	DeadbandValue *float64     `toml:"deadband_value"`
+	ClientSide    bool         `toml:"client_side"` // Apply percent deadband on the client instead of the server
+
+	// Node providing the deadband value, overrides the static value
+	DeadbandValueNode string `toml:"deadband_value_node"`
 }
 
 type MonitoringParameters struct {
@@ -74,6 +79,22 @@ type NodeGroupSettings struct {
 	TagsSlice        [][]string        `toml:"tags" deprecated:"1.26.0;1.35.0;use default_tags"`
 	DefaultTags      map[string]string `toml:"default_tags"`
 	SamplingInterval config.Duration   `toml:"sampling_interval"` // Can be overridden by monitoring parameters
+	Browse           *BrowseSettings   `toml:"browse"`
+}
+
+// BrowseSettings selects all variables below an object node by browsing the
+// object instead of listing the nodes explicitly
+type BrowseSettings struct {
+	Namespace      string   `toml:"namespace"`       // Defaults to the group setting
+	IdentifierType string   `toml:"identifier_type"` // Defaults to the group setting
+	Identifier     string   `toml:"identifier"`
+	Include        []string `toml:"include"`
+	Exclude        []string `toml:"exclude"`
+}
+
+// NodeID returns the OPC UA node id of the object to browse
+func (b *BrowseSettings) NodeID() string {
+	return "ns=" + b.Namespace + ";" + b.IdentifierType + "=" + b.Identifier
 }
 
 type EventNodeSettings struct {
@@ -167,6 +188,14 @@ type InputClientConfig struct {
 	RootNodes       []NodeSettings       `toml:"nodes"`
 	Groups          []NodeGroupSettings  `toml:"group"`
 	EventGroups     []EventGroupSettings `toml:"events"`
+
+	AccessLevelCheck string `toml:"access_level_check"`
+
+	// Endpoints of identical servers sharing the node configuration, the
+	// endpoint is added as 'source' tag to the metrics
+	Endpoints []string `toml:"endpoints"`
+
+	sourceTag bool
 }
 
 func (o *InputClientConfig) Validate() error {
@@ -179,14 +208,32 @@ func (o *InputClientConfig) Validate() error {
 		return err
 	}
 
+	if err := choice.Check(o.AccessLevelCheck, []string{"", "none", "warn", "error"}); err != nil {
+		return fmt.Errorf("invalid 'access_level_check': %w", err)
+	}
+
 	if o.TimestampFormat == "" {
 		o.TimestampFormat = time.RFC3339Nano
 	}
 
+	seen := make(map[string]bool, len(o.Endpoints))
+	for _, endpoint := range o.Endpoints {
+		if seen[endpoint] {
+			return fmt.Errorf("duplicate endpoint %q in 'endpoints'", endpoint)
+		}
+		seen[endpoint] = true
+	}
+
 	if len(o.Groups) == 0 && len(o.RootNodes) == 0 && o.EventGroups == nil {
 		return errors.New("no groups, root nodes or events provided to gather from")
 	}
 	for _, group := range o.Groups {
+		if group.Browse != nil {
+			if group.Browse.Identifier == "" {
+				return errors.New("group has no identifier of the object to browse")
+			}
+			continue
+		}
 		if len(group.Nodes) == 0 {
 			return errors.New("group has no nodes to collect from")
 		}
@@ -195,6 +242,25 @@ func (o *InputClientConfig) Validate() error {
 	return nil
 }
 
+// EndpointConfigs returns a configuration for each of the configured
+// 'endpoints' adding the endpoint as 'source' tag to the node metrics. If no
+// endpoint list is configured the configuration itself is returned.
+func (o *InputClientConfig) EndpointConfigs() []InputClientConfig {
+	if len(o.Endpoints) == 0 {
+		return []InputClientConfig{*o}
+	}
+
+	configs := make([]InputClientConfig, 0, len(o.Endpoints))
+	for _, endpoint := range o.Endpoints {
+		cfg := *o
+		cfg.Endpoint = endpoint
+		cfg.Endpoints = nil
+		cfg.sourceTag = true
+		configs = append(configs, cfg)
+	}
+	return configs
+}
+
 func (o *InputClientConfig) CreateInputClient(log telegraf.Logger) (*OpcUAInputClient, error) {
 	if err := o.Validate(); err != nil {
 		return nil, err
@@ -220,6 +286,7 @@ func (o *InputClientConfig) CreateInputClient(log telegraf.Logger) (*OpcUAInputC
 		Log:         log,
 		Config:      *o,
 		EventGroups: o.EventGroups,
+		Clock:       clock.New(),
 	}
 
 	log.Debug("Initialising node to metric mapping")
@@ -297,12 +364,25 @@ type OpcUAInputClient struct {
 	*opcua.OpcUAClient
 	Config InputClientConfig
 	Log    telegraf.Logger
+	// Clock used for timestamps if the Telegraf time is selected, defaults
+	// to the wall clock
+	Clock clock.Clock
 
 	NodeMetricMapping      []NodeMetricMapping
 	NodeIDs                []*ua.NodeID
 	LastReceivedData       []NodeValue
 	EventGroups            []EventGroupSettings
 	EventNodeMetricMapping []EventNodeMetricMapping
+
+	accessLevelsChecked bool
+}
+
+// Now returns the current time of the client's clock
+func (o *OpcUAInputClient) Now() time.Time {
+	if o.Clock == nil {
+		return time.Now()
+	}
+	return o.Clock.Now()
 }
 
 // Stop the connection to the client
@@ -539,15 +619,103 @@ func (o *OpcUAInputClient) UpdateNodeValue(nodeIdx int, d *ua.DataValue) {
 	o.LastReceivedData[nodeIdx].SourceTime = d.SourceTimestamp
 }
 
-func (o *OpcUAInputClient) MetricForNode(nodeIdx int) telegraf.Metric {
+// NodeState is the persisted last received value of a node
+type NodeState struct {
+	NodeID     string    `json:"node_id"`
+	Field      string    `json:"field"`
+	Value      []byte    `json:"value,omitempty"`
+	DataType   ua.TypeID `json:"data_type"`
+	Quality    uint32    `json:"quality"`
+	ServerTime time.Time `json:"server_time"`
+	SourceTime time.Time `json:"source_time"`
+}
+
+// GetState returns the last received values of all nodes that received
+// data with the values encoded as OPC UA variants to preserve their type
+func (o *OpcUAInputClient) GetState() []NodeState {
+	state := make([]NodeState, 0, len(o.LastReceivedData))
+	for i, last := range o.LastReceivedData {
+		if last.ServerTime.IsZero() && last.SourceTime.IsZero() && last.Value == nil {
+			continue
+		}
+		nmm := &o.NodeMetricMapping[i]
+		ns := NodeState{
+			NodeID:     nmm.idStr,
+			Field:      nmm.Tag.FieldName,
+			DataType:   last.DataType,
+			Quality:    uint32(last.Quality),
+			ServerTime: last.ServerTime,
+			SourceTime: last.SourceTime,
+		}
+		if last.Value != nil {
+			v, err := ua.NewVariant(last.Value)
+			if err != nil {
+				o.Log.Debugf("Cannot persist value of node %q: %v", nmm.idStr, err)
+				continue
+			}
+			if ns.Value, err = v.Encode(); err != nil {
+				o.Log.Debugf("Cannot persist value of node %q: %v", nmm.idStr, err)
+				continue
+			}
+		}
+		state = append(state, ns)
+	}
+	return state
+}
+
+// SetState restores the last received values of the nodes. Nodes not
+// matching the configuration, e.g. because the configuration changed, are
+// ignored.
+func (o *OpcUAInputClient) SetState(state []NodeState) error {
+	indices := make(map[string]int, len(o.NodeMetricMapping))
+	for i := range o.NodeMetricMapping {
+		nmm := &o.NodeMetricMapping[i]
+		indices[nmm.idStr+"\x00"+nmm.Tag.FieldName] = i
+	}
+
+	for _, ns := range state {
+		i, found := indices[ns.NodeID+"\x00"+ns.Field]
+		if !found {
+			o.Log.Debugf("Ignoring state of unknown node %q with field %q", ns.NodeID, ns.Field)
+			continue
+		}
+
+		var value interface{}
+		if len(ns.Value) > 0 {
+			var v ua.Variant
+			if _, err := v.Decode(ns.Value); err != nil {
+				return fmt.Errorf("decoding value of node %q failed: %w", ns.NodeID, err)
+			}
+			value = v.Value()
+		}
+		o.LastReceivedData[i].Value = value
+		o.LastReceivedData[i].DataType = ns.DataType
+		o.LastReceivedData[i].Quality = ua.StatusCode(ns.Quality)
+		o.LastReceivedData[i].ServerTime = ns.ServerTime
+		o.LastReceivedData[i].SourceTime = ns.SourceTime
+	}
+	return nil
+}
+
+// TagsForNode returns the tags of the metrics emitted for the node
+func (o *OpcUAInputClient) TagsForNode(nodeIdx int) map[string]string {
 	nmm := &o.NodeMetricMapping[nodeIdx]
-	fields := make(map[string]interface{})
 	tags := map[string]string{
 		"id": nmm.idStr,
 	}
+	if o.Config.sourceTag {
+		tags["source"] = o.Config.Endpoint
+	}
 	for k, v := range nmm.MetricTags {
 		tags[k] = v
 	}
+	return tags
+}
+
+func (o *OpcUAInputClient) MetricForNode(nodeIdx int) telegraf.Metric {
+	nmm := &o.NodeMetricMapping[nodeIdx]
+	fields := make(map[string]interface{})
+	tags := o.TagsForNode(nodeIdx)
 
 	fields[nmm.Tag.FieldName] = o.LastReceivedData[nodeIdx].Value
 	fields["Quality"] = strings.TrimSpace(o.LastReceivedData[nodeIdx].Quality.Error())
@@ -567,7 +735,7 @@ func (o *OpcUAInputClient) MetricForNode(nodeIdx int) telegraf.Metric {
 	case TimestampSourceSource:
 		t = o.LastReceivedData[nodeIdx].SourceTime
 	default:
-		t = time.Now()
+		t = o.Now()
 	}
 
 	return metric.New(nmm.metricName, tags, fields, t)
@@ -605,7 +773,7 @@ func (o *OpcUAInputClient) MetricForEvent(nodeIdx int, event *ua.EventFieldList)
 	case TimestampSourceSource:
 		t = o.LastReceivedData[nodeIdx].SourceTime
 	default:
-		t = time.Now()
+		t = o.Now()
 	}
 
 	return metric.New("opcua_event", tags, fields, t)
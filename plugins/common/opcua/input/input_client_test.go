@@ -1,6 +1,7 @@
 package input
 
 import (
+	"encoding/json"
 	"errors"
 	"testing"
 	"time"
@@ -838,3 +839,202 @@ func TestMetricForNode(t *testing.T) {
 		})
 	}
 }
+
+func TestMetricForNodeTelegrafTimestamp(t *testing.T) {
+	conf := &opcua.OpcUAClientConfig{
+		Endpoint:       "opc.tcp://localhost:4930",
+		SecurityPolicy: "None",
+		SecurityMode:   "None",
+		ConnectTimeout: config.Duration(2 * time.Second),
+		RequestTimeout: config.Duration(2 * time.Second),
+	}
+	c, err := conf.CreateClient(testutil.Logger{})
+	require.NoError(t, err)
+
+	now := time.Date(2022, 03, 17, 8, 55, 0, 0, time.UTC)
+	clock := testutil.NewFakeClock(now)
+	o := OpcUAInputClient{
+		Config: InputClientConfig{
+			Timestamp: TimestampSourceTelegraf,
+		},
+		OpcUAClient: c,
+		Log:         testutil.Logger{},
+		Clock:       clock,
+		NodeMetricMapping: []NodeMetricMapping{
+			{idStr: "ns=3;s=hi", metricName: "testingmetric", Tag: NodeSettings{FieldName: "fn"}},
+		},
+		LastReceivedData: []NodeValue{
+			{
+				Value:      int64(16),
+				Quality:    ua.StatusOK,
+				SourceTime: now.Add(-time.Hour),
+				ServerTime: now.Add(-time.Minute),
+			},
+		},
+	}
+
+	require.Equal(t, now, o.MetricForNode(0).Time())
+
+	// The timestamp follows the clock and not the server or source time
+	clock.Add(10 * time.Second)
+	require.Equal(t, now.Add(10*time.Second), o.MetricForNode(0).Time())
+}
+
+func TestMetricForNodeTimestampSource(t *testing.T) {
+	conf := &opcua.OpcUAClientConfig{
+		Endpoint:       "opc.tcp://localhost:4930",
+		SecurityPolicy: "None",
+		SecurityMode:   "None",
+		ConnectTimeout: config.Duration(2 * time.Second),
+		RequestTimeout: config.Duration(2 * time.Second),
+	}
+	c, err := conf.CreateClient(testutil.Logger{})
+	require.NoError(t, err)
+
+	// Device timestamps lie in the past to distinguish them from the gather time
+	sourceTime := time.Now().Add(-time.Hour)
+	serverTime := time.Now().Add(-time.Minute)
+
+	for _, source := range []TimestampSource{TimestampSourceTelegraf, TimestampSourceServer, TimestampSourceSource} {
+		t.Run(string(source), func(t *testing.T) {
+			o := OpcUAInputClient{
+				Config: InputClientConfig{
+					Timestamp: source,
+				},
+				OpcUAClient: c,
+				Log:         testutil.Logger{},
+				NodeMetricMapping: []NodeMetricMapping{
+					{idStr: "ns=3;s=hi", metricName: "testingmetric", Tag: NodeSettings{FieldName: "fn"}},
+				},
+				LastReceivedData: make([]NodeValue, 1),
+			}
+
+			var metrics []telegraf.Metric
+			var windows []testutil.TimeWindow
+			for i := range 3 {
+				o.LastReceivedData[0] = NodeValue{
+					Value:      int64(i),
+					Quality:    ua.StatusOK,
+					SourceTime: sourceTime.Add(time.Duration(i) * time.Second),
+					ServerTime: serverTime.Add(time.Duration(i) * time.Second),
+				}
+				windows = append(windows, testutil.MeasureWindow(func() {
+					metrics = append(metrics, o.MetricForNode(0))
+				}))
+			}
+			testutil.RequireTimestampsMonotonic(t, metrics)
+
+			for i, m := range metrics {
+				switch source {
+				case TimestampSourceTelegraf:
+					testutil.RequireTimestampsInWindow(t, windows[i], []telegraf.Metric{m})
+				case TimestampSourceServer:
+					testutil.RequireTimestampsNotInWindow(t, windows[i], []telegraf.Metric{m})
+					require.Equal(t, serverTime.Add(time.Duration(i)*time.Second), m.Time())
+				case TimestampSourceSource:
+					testutil.RequireTimestampsNotInWindow(t, windows[i], []telegraf.Metric{m})
+					require.Equal(t, sourceTime.Add(time.Duration(i)*time.Second), m.Time())
+				}
+			}
+		})
+	}
+}
+
+func TestState(t *testing.T) {
+	conf := &opcua.OpcUAClientConfig{
+		Endpoint:       "opc.tcp://localhost:4930",
+		SecurityPolicy: "None",
+		SecurityMode:   "None",
+		ConnectTimeout: config.Duration(2 * time.Second),
+		RequestTimeout: config.Duration(2 * time.Second),
+	}
+	c, err := conf.CreateClient(testutil.Logger{})
+	require.NoError(t, err)
+
+	newClient := func() *OpcUAInputClient {
+		o := &OpcUAInputClient{
+			OpcUAClient: c,
+			Log:         testutil.Logger{},
+			NodeMetricMapping: []NodeMetricMapping{
+				{idStr: "ns=1;s=a", Tag: NodeSettings{FieldName: "a"}},
+				{idStr: "ns=1;s=b", Tag: NodeSettings{FieldName: "b"}},
+				{idStr: "ns=1;s=c", Tag: NodeSettings{FieldName: "c"}},
+			},
+		}
+		o.initLastReceivedValues()
+		return o
+	}
+
+	ts := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	o := newClient()
+	o.UpdateNodeValue(0, &ua.DataValue{Value: ua.MustVariant(int16(42)), SourceTimestamp: ts, ServerTimestamp: ts})
+	o.UpdateNodeValue(1, &ua.DataValue{Value: ua.MustVariant("ok"), SourceTimestamp: ts, ServerTimestamp: ts})
+
+	// Only nodes with received values are persisted and the state survives
+	// serialization
+	state := o.GetState()
+	require.Len(t, state, 2)
+	buf, err := json.Marshal(state)
+	require.NoError(t, err)
+	var restored []NodeState
+	require.NoError(t, json.Unmarshal(buf, &restored))
+
+	// Unknown nodes are ignored
+	restored = append(restored, NodeState{NodeID: "ns=1;s=unknown", Field: "x"})
+
+	actual := newClient()
+	require.NoError(t, actual.SetState(restored))
+	require.Equal(t, o.LastReceivedData, actual.LastReceivedData)
+	require.Equal(t, int16(42), actual.LastReceivedData[0].Value)
+	require.Equal(t, ua.TypeIDInt16, actual.LastReceivedData[0].DataType)
+	require.Nil(t, actual.LastReceivedData[2].Value)
+}
+
+func TestEndpointConfigs(t *testing.T) {
+	cfg := InputClientConfig{
+		OpcUAClientConfig: opcua.OpcUAClientConfig{
+			Endpoint:       "opc.tcp://localhost:4840",
+			SecurityPolicy: "None",
+			SecurityMode:   "None",
+		},
+		MetricName: "testing",
+		RootNodes: []NodeSettings{
+			{FieldName: "a", Namespace: "1", IdentifierType: "s", Identifier: "a"},
+		},
+	}
+
+	// Without a list of endpoints the configuration is used as is
+	configs := cfg.EndpointConfigs()
+	require.Len(t, configs, 1)
+	require.Equal(t, "opc.tcp://localhost:4840", configs[0].Endpoint)
+	require.False(t, configs[0].sourceTag)
+
+	cfg.Endpoints = []string{"opc.tcp://machine1:4840", "opc.tcp://machine2:4840"}
+	require.NoError(t, cfg.Validate())
+	configs = cfg.EndpointConfigs()
+	require.Len(t, configs, 2)
+	for i, c := range configs {
+		require.Equal(t, cfg.Endpoints[i], c.Endpoint)
+		require.Empty(t, c.Endpoints)
+		require.True(t, c.sourceTag)
+		require.Equal(t, "testing", c.MetricName)
+	}
+
+	// The endpoint is added as tag to the metrics
+	client, err := configs[1].OpcUAClientConfig.CreateClient(testutil.Logger{})
+	require.NoError(t, err)
+	o := OpcUAInputClient{
+		Config:      configs[1],
+		OpcUAClient: client,
+		Log:         testutil.Logger{},
+		NodeMetricMapping: []NodeMetricMapping{
+			{idStr: "ns=1;s=a", Tag: NodeSettings{FieldName: "a"}, metricName: "testing"},
+		},
+	}
+	o.initLastReceivedValues()
+	o.UpdateNodeValue(0, &ua.DataValue{Value: ua.MustVariant(int32(1)), Status: ua.StatusOK})
+	require.Equal(t, "opc.tcp://machine2:4840", o.MetricForNode(0).Tags()["source"])
+
+	cfg.Endpoints = append(cfg.Endpoints, "opc.tcp://machine1:4840")
+	require.ErrorContains(t, cfg.Validate(), `duplicate endpoint "opc.tcp://machine1:4840"`)
+}
@@ -0,0 +1,126 @@
+package opcua
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gopcua/opcua/ua"
+	krbclient "github.com/jcmturner/gokrb5/v8/client"
+	krbconfig "github.com/jcmturner/gokrb5/v8/config"
+	"github.com/jcmturner/gokrb5/v8/gssapi"
+	"github.com/jcmturner/gokrb5/v8/keytab"
+	"github.com/jcmturner/gokrb5/v8/spnego"
+)
+
+// KerberosTokenType is the issued token type of the Kerberos user token
+// profile (see OPC UA Part 6, 6.4)
+const KerberosTokenType = "http://opcfoundation.org/UA/UserToken#Kerberos"
+
+const defaultKerberosConfigPath = "/etc/krb5.conf"
+
+func (o *OpcUAClientConfig) validateKerberos() error {
+	if o.Username.Empty() {
+		return errors.New("'username' is required for auth_method \"Kerberos\"")
+	}
+	if o.KerberosRealm == "" {
+		return errors.New("'kerberos_realm' is required for auth_method \"Kerberos\"")
+	}
+	if o.KerberosServicePrincipal == "" {
+		return errors.New("'kerberos_service_principal' is required for auth_method \"Kerberos\"")
+	}
+	if o.KerberosKeytabPath == "" && o.Password.Empty() {
+		return errors.New("either 'password' or 'kerberos_keytab_path' is required for auth_method \"Kerberos\"")
+	}
+	return nil
+}
+
+// kerberosToken returns the token data for authenticating the user via
+// Kerberos. A new token is created for each connection attempt. The Kerberos
+// client is kept until disconnecting so the tickets are cached, expired
+// service tickets are requested again and the ticket granting ticket is
+// renewed in the background by the Kerberos client.
+func (o *OpcUAClient) kerberosToken() ([]byte, error) {
+	if o.krb == nil {
+		cl, err := o.newKerberosClient()
+		if err != nil {
+			return nil, err
+		}
+		if err := cl.Login(); err != nil {
+			cl.Destroy()
+			return nil, fmt.Errorf("kerberos login failed: %w", err)
+		}
+		o.krb = cl
+	}
+
+	ticket, key, err := o.krb.GetServiceTicket(o.Config.KerberosServicePrincipal)
+	if err != nil {
+		// The session might not be renewable anymore so login again
+		o.Log.Debugf("Getting Kerberos service ticket failed, logging in again: %v", err)
+		if err := o.krb.Login(); err != nil {
+			return nil, fmt.Errorf("kerberos login failed: %w", err)
+		}
+		ticket, key, err = o.krb.GetServiceTicket(o.Config.KerberosServicePrincipal)
+		if err != nil {
+			return nil, fmt.Errorf("getting service ticket for %q failed: %w", o.Config.KerberosServicePrincipal, err)
+		}
+	}
+
+	flags := []int{gssapi.ContextFlagInteg, gssapi.ContextFlagConf}
+	token, err := spnego.NewKRB5TokenAPREQ(o.krb, ticket, key, flags, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating kerberos token failed: %w", err)
+	}
+	return token.Marshal()
+}
+
+func (o *OpcUAClient) newKerberosClient() (*krbclient.Client, error) {
+	path := o.Config.KerberosConfigPath
+	if path == "" {
+		path = defaultKerberosConfigPath
+	}
+	cfg, err := krbconfig.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading kerberos config %q failed: %w", path, err)
+	}
+
+	user, err := o.Config.Username.Get()
+	if err != nil {
+		return nil, fmt.Errorf("error reading the username input: %w", err)
+	}
+	defer user.Destroy()
+
+	settings := krbclient.DisablePAFXFAST(o.Config.KerberosDisablePAFXFAST)
+	if o.Config.KerberosKeytabPath != "" {
+		kt, err := keytab.Load(o.Config.KerberosKeytabPath)
+		if err != nil {
+			return nil, fmt.Errorf("loading keytab %q failed: %w", o.Config.KerberosKeytabPath, err)
+		}
+		return krbclient.NewWithKeytab(user.String(), o.Config.KerberosRealm, kt, cfg, settings), nil
+	}
+
+	passwd, err := o.Config.Password.Get()
+	if err != nil {
+		return nil, fmt.Errorf("error reading the password input: %w", err)
+	}
+	defer passwd.Destroy()
+	return krbclient.NewWithPassword(user.String(), o.Config.KerberosRealm, passwd.String(), cfg, settings), nil
+}
+
+// destroyKerberos logs out the Kerberos client, if any
+func (o *OpcUAClient) destroyKerberos() {
+	if o.krb != nil {
+		o.krb.Destroy()
+		o.krb = nil
+	}
+}
+
+// kerberosPolicyID returns the ID of the Kerberos user token policy of the
+// endpoint
+func kerberosPolicyID(endpoint *ua.EndpointDescription) (string, error) {
+	for _, t := range endpoint.UserIdentityTokens {
+		if t.TokenType == ua.UserTokenTypeIssuedToken && t.IssuedTokenType == KerberosTokenType {
+			return t.PolicyID, nil
+		}
+	}
+	return "", fmt.Errorf("endpoint %q does not support Kerberos user tokens", endpoint.EndpointURL)
+}
@@ -0,0 +1,83 @@
+package opcua
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// sessions is the global connection manager shared by all plugin instances
+var sessions = &connectionManager{endpoints: make(map[string]*endpointSessions)}
+
+// connectionManager limits the number of concurrent sessions per endpoint
+// across all plugin instances. Connect attempts exceeding the limit are
+// queued and served in order once a session is released.
+type connectionManager struct {
+	endpoints map[string]*endpointSessions
+	sync.Mutex
+}
+
+type endpointSessions struct {
+	limit   int
+	active  int
+	waiters []chan struct{}
+}
+
+// acquire reserves a session for the given endpoint, waiting for a session
+// to be released if the limit is reached. The smallest non-zero limit
+// requested for an endpoint is enforced, a limit of zero means unlimited.
+func (m *connectionManager) acquire(ctx context.Context, endpoint string, limit int) error {
+	m.Lock()
+	e, found := m.endpoints[endpoint]
+	if !found {
+		e = &endpointSessions{}
+		m.endpoints[endpoint] = e
+	}
+	if limit > 0 && (e.limit == 0 || limit < e.limit) {
+		e.limit = limit
+	}
+	if e.limit == 0 || e.active < e.limit {
+		e.active++
+		m.Unlock()
+		return nil
+	}
+	waiter := make(chan struct{})
+	e.waiters = append(e.waiters, waiter)
+	m.Unlock()
+
+	select {
+	case <-waiter:
+		return nil
+	case <-ctx.Done():
+		m.Lock()
+		defer m.Unlock()
+		if idx := slices.Index(e.waiters, waiter); idx >= 0 {
+			e.waiters = slices.Delete(e.waiters, idx, idx+1)
+			return ctx.Err()
+		}
+		// The session was handed over while we were giving up, so pass it on
+		m.releaseLocked(e)
+		return ctx.Err()
+	}
+}
+
+// release frees a session of the given endpoint handing it over to the
+// longest waiting connect attempt if any
+func (m *connectionManager) release(endpoint string) {
+	m.Lock()
+	defer m.Unlock()
+	if e, found := m.endpoints[endpoint]; found {
+		m.releaseLocked(e)
+	}
+}
+
+func (*connectionManager) releaseLocked(e *endpointSessions) {
+	if len(e.waiters) > 0 {
+		close(e.waiters[0])
+		e.waiters = e.waiters[1:]
+		return
+	}
+	if e.active > 0 {
+		e.active--
+	}
+}
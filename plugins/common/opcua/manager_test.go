@@ -0,0 +1,77 @@
+package opcua
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnectionManagerLimit(t *testing.T) {
+	m := &connectionManager{endpoints: make(map[string]*endpointSessions)}
+	endpoint := "opc.tcp://localhost:4840"
+
+	require.NoError(t, m.acquire(t.Context(), endpoint, 2))
+	require.NoError(t, m.acquire(t.Context(), endpoint, 0))
+
+	// Other endpoints are not affected
+	require.NoError(t, m.acquire(t.Context(), "opc.tcp://otherhost:4840", 1))
+
+	// The limit is reached so the attempt times out
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, m.acquire(ctx, endpoint, 2), context.DeadlineExceeded)
+	require.Empty(t, m.endpoints[endpoint].waiters)
+
+	// Queued attempts are served in order when releasing sessions
+	order := make(chan int, 2)
+	for i := range 2 {
+		go func() {
+			if err := m.acquire(t.Context(), endpoint, 2); err == nil {
+				order <- i
+			}
+		}()
+		require.Eventually(t, func() bool {
+			m.Lock()
+			defer m.Unlock()
+			return len(m.endpoints[endpoint].waiters) == i+1
+		}, time.Second, 10*time.Millisecond)
+	}
+	m.release(endpoint)
+	require.Equal(t, 0, <-order)
+	m.release(endpoint)
+	require.Equal(t, 1, <-order)
+
+	// Releasing all sessions frees the endpoint
+	m.release(endpoint)
+	m.release(endpoint)
+	require.Zero(t, m.endpoints[endpoint].active)
+}
+
+func TestConnectionManagerSmallestLimit(t *testing.T) {
+	m := &connectionManager{endpoints: make(map[string]*endpointSessions)}
+	endpoint := "opc.tcp://localhost:4840"
+
+	require.NoError(t, m.acquire(t.Context(), endpoint, 5))
+
+	// A smaller limit applies to all instances connecting to the endpoint
+	ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, m.acquire(ctx, endpoint, 1), context.DeadlineExceeded)
+	require.Equal(t, 1, m.endpoints[endpoint].limit)
+
+	ctx, cancel = context.WithTimeout(t.Context(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, m.acquire(ctx, endpoint, 5), context.DeadlineExceeded)
+}
+
+func TestValidateMaxSessions(t *testing.T) {
+	cfg := &OpcUAClientConfig{
+		Endpoint:       "opc.tcp://localhost:4840",
+		SecurityPolicy: "None",
+		SecurityMode:   "None",
+		MaxSessions:    -1,
+	}
+	require.ErrorContains(t, cfg.Validate(), "'max_sessions_per_endpoint' must not be negative")
+}
@@ -165,6 +165,8 @@ func (o *OpcUAClient) generateClientOpts(endpoints []*ua.EndpointDescription) ([
 		opts = append(opts, opcua.SessionTimeout(time.Duration(o.Config.SessionTimeout)))
 	}
 
+	o.clientCert, o.serverCert = nil, nil
+
 	certFile := o.Config.Certificate
 	keyFile := o.Config.PrivateKey
 	policy := o.Config.SecurityPolicy
@@ -191,6 +193,7 @@ func (o *OpcUAClient) generateClientOpts(endpoints []*ua.EndpointDescription) ([
 				return nil, errors.New("invalid private key")
 			}
 			cert = c.Certificate[0]
+			o.clientCert = cert
 			opts = append(opts, opcua.PrivateKey(pk), opcua.Certificate(cert))
 		}
 	}
@@ -295,7 +298,33 @@ func (o *OpcUAClient) generateClientOpts(endpoints []*ua.EndpointDescription) ([
 		return nil, fmt.Errorf("error validating input: %w", err)
 	}
 
+	if o.Config.ServerCertThumbprint != "" {
+		if err := o.verifyServerCertificate(serverEndpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	o.serverCert = serverEndpoint.ServerCertificate
+	o.conn.Lock()
+	o.conn.securityPolicy = strings.TrimPrefix(secPolicy, ua.SecurityPolicyURIPrefix)
+	o.conn.securityMode = strings.TrimPrefix(secMode.String(), "MessageSecurityMode")
+	o.conn.Unlock()
 	opts = append(opts, opcua.SecurityFromEndpoint(serverEndpoint, authMode))
+
+	// The server might offer multiple issued token policies so select the
+	// one for Kerberos explicitly
+	if strings.EqualFold(o.Config.AuthMethod, "Kerberos") {
+		policyID, err := kerberosPolicyID(serverEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, opcua.AuthPolicyID(policyID))
+
+		// The Kerberos token is only valid for a limited time, so disable the
+		// automatic reconnect of the library which would reuse the token of
+		// the initial connection. Reconnecting via Connect creates a new one.
+		opts = append(opts, opcua.AutoReconnect(false))
+	}
 	return opts, nil
 }
 
@@ -331,6 +360,13 @@ func (o *OpcUAClient) generateAuth(a string, cert []byte, user, passwd config.Se
 	case "certificate":
 		authMode = ua.UserTokenTypeCertificate
 		authOption = opcua.AuthCertificate(cert)
+	case "kerberos":
+		authMode = ua.UserTokenTypeIssuedToken
+		token, err := o.kerberosToken()
+		if err != nil {
+			return 0, nil, err
+		}
+		authOption = opcua.AuthIssuedToken(token)
 	case "issuedtoken":
 		// todo: this is unsupported, fail here or fail in the opcua package?
 		authMode = ua.UserTokenTypeIssuedToken
@@ -0,0 +1,302 @@
+package pubsub
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/influxdata/telegraf/plugins/common/opcua"
+)
+
+// JSON message types as defined in Part 14, 7.2.5
+const (
+	messageTypeData     = "ua-data"
+	messageTypeMetaData = "ua-metadata"
+	messageKeepAlive    = "ua-keepalive"
+)
+
+type jsonVersion struct {
+	MajorVersion uint32 `json:"MajorVersion"`
+	MinorVersion uint32 `json:"MinorVersion"`
+}
+
+type jsonFieldMetaData struct {
+	Name        string `json:"Name"`
+	BuiltInType uint8  `json:"BuiltInType"`
+}
+
+type jsonMetaData struct {
+	Name                 string              `json:"Name"`
+	Fields               []jsonFieldMetaData `json:"Fields"`
+	ConfigurationVersion jsonVersion         `json:"ConfigurationVersion"`
+}
+
+type jsonNetworkMessage struct {
+	MessageType string          `json:"MessageType"`
+	PublisherID json.RawMessage `json:"PublisherId"`
+	Messages    json.RawMessage `json:"Messages"`
+
+	// Only present for metadata messages
+	DataSetWriterID uint16        `json:"DataSetWriterId"`
+	MetaData        *jsonMetaData `json:"MetaData"`
+
+	// Only present if the message is a single DataSetMessage without
+	// network message header
+	Payload map[string]json.RawMessage `json:"Payload"`
+}
+
+type jsonDataSetMessage struct {
+	DataSetWriterID   uint16                     `json:"DataSetWriterId"`
+	DataSetWriterName string                     `json:"DataSetWriterName"`
+	PublisherID       json.RawMessage            `json:"PublisherId"`
+	SequenceNumber    uint32                     `json:"SequenceNumber"`
+	MetaDataVersion   *jsonVersion               `json:"MetaDataVersion"`
+	Timestamp         *time.Time                 `json:"Timestamp"`
+	Status            json.RawMessage            `json:"Status"`
+	MessageType       string                     `json:"MessageType"`
+	Payload           map[string]json.RawMessage `json:"Payload"`
+}
+
+// JSONDecoder decodes PubSub messages using the JSON message mapping
+// (Part 14, 7.2.5). Metadata messages are stored and used to determine the
+// type of the dataset fields.
+type JSONDecoder struct {
+	MetaData *MetaDataStore
+}
+
+// NewJSONDecoder creates a decoder with an empty metadata store
+func NewJSONDecoder() *JSONDecoder {
+	return &JSONDecoder{MetaData: NewMetaDataStore()}
+}
+
+// Decode the given message returning the contained datasets. Metadata
+// messages are added to the decoder's store and do not produce datasets.
+func (d *JSONDecoder) Decode(buf []byte) ([]*DataSet, error) {
+	buf = bytes.TrimSpace(buf)
+	if len(buf) == 0 {
+		return nil, nil
+	}
+
+	// Without a network message header the message might be an array
+	// of DataSetMessages
+	if buf[0] == '[' {
+		return d.decodeDataSetMessages(buf, "")
+	}
+
+	var msg jsonNetworkMessage
+	if err := json.Unmarshal(buf, &msg); err != nil {
+		return nil, fmt.Errorf("decoding network message failed: %w", err)
+	}
+	publisherID := rawToString(msg.PublisherID)
+
+	switch {
+	case msg.MessageType == messageTypeMetaData:
+		if msg.MetaData == nil {
+			return nil, errors.New("metadata message without metadata")
+		}
+		d.MetaData.Add(publisherID, msg.DataSetWriterID, msg.MetaData.convert())
+		return nil, nil
+	case msg.Payload != nil:
+		return d.decodeDataSetMessages(append(append([]byte{'['}, buf...), ']'), "")
+	case msg.MessageType == messageTypeData || len(msg.Messages) > 0:
+		return d.decodeDataSetMessages(msg.Messages, publisherID)
+	}
+
+	return nil, fmt.Errorf("unknown message type %q", msg.MessageType)
+}
+
+func (d *JSONDecoder) decodeDataSetMessages(buf []byte, publisherID string) ([]*DataSet, error) {
+	if len(buf) == 0 {
+		return nil, nil
+	}
+
+	var messages []jsonDataSetMessage
+	if err := json.Unmarshal(buf, &messages); err != nil {
+		return nil, fmt.Errorf("decoding dataset messages failed: %w", err)
+	}
+
+	datasets := make([]*DataSet, 0, len(messages))
+	for _, m := range messages {
+		if m.MessageType == messageKeepAlive || len(m.Payload) == 0 {
+			continue
+		}
+
+		ds := &DataSet{
+			PublisherID:     publisherID,
+			DataSetWriterID: m.DataSetWriterID,
+			Name:            m.DataSetWriterName,
+			SequenceNumber:  m.SequenceNumber,
+			Fields:          make(map[string]interface{}, len(m.Payload)),
+		}
+		if id := rawToString(m.PublisherID); id != "" {
+			ds.PublisherID = id
+		}
+		if m.Timestamp != nil {
+			ds.Timestamp = *m.Timestamp
+		}
+		if len(m.Status) > 0 {
+			status, err := decodeStatus(m.Status)
+			if err != nil {
+				return nil, fmt.Errorf("decoding status of writer %d failed: %w", m.DataSetWriterID, err)
+			}
+			ds.Status = &status
+		}
+
+		md, hasMetaData := d.MetaData.Get(ds.PublisherID, ds.DataSetWriterID)
+		if hasMetaData && md.Name != "" {
+			ds.Name = md.Name
+		}
+
+		for name, raw := range m.Payload {
+			var builtin ua.TypeID
+			if hasMetaData {
+				if f, found := md.Field(name); found {
+					builtin = f.BuiltInType
+				}
+			}
+			v, err := decodeValue(raw, builtin)
+			if err != nil {
+				return nil, fmt.Errorf("decoding field %q of writer %d failed: %w", name, m.DataSetWriterID, err)
+			}
+			if v != nil {
+				ds.Fields[name] = v
+			}
+		}
+		datasets = append(datasets, ds)
+	}
+
+	return datasets, nil
+}
+
+func (md *jsonMetaData) convert() *MetaData {
+	fields := make([]FieldMetaData, 0, len(md.Fields))
+	for _, f := range md.Fields {
+		fields = append(fields, FieldMetaData{Name: f.Name, BuiltInType: ua.TypeID(f.BuiltInType)})
+	}
+	return &MetaData{
+		Name:         md.Name,
+		Fields:       fields,
+		MajorVersion: md.ConfigurationVersion.MajorVersion,
+		MinorVersion: md.ConfigurationVersion.MinorVersion,
+	}
+}
+
+// decodeValue decodes a field value either in its non-reversible form (i.e.
+// a plain JSON value) or in its reversible form as Variant or DataValue
+// object. The type of the value is taken from the given built-in type if
+// specified, otherwise from the variant encoding if present.
+func decodeValue(raw json.RawMessage, builtin ua.TypeID) (interface{}, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	if raw[0] == '{' {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil, err
+		}
+
+		// Variant encoding of OPC UA v1.04 using "Type" and "Body"
+		if body, found := obj["Body"]; found {
+			if builtin == 0 {
+				builtin = decodeTypeID(obj["Type"])
+			}
+			return decodeValue(body, builtin)
+		}
+
+		// Variant or DataValue encoding of OPC UA v1.05 using "UaType"
+		// and "Value" or DataValue encoding of v1.04 with a nested variant
+		if value, found := obj["Value"]; found {
+			if builtin == 0 {
+				builtin = decodeTypeID(obj["UaType"])
+			}
+			return decodeValue(value, builtin)
+		}
+
+		// Localized text
+		if text, found := obj["Text"]; found {
+			return decodeValue(text, ua.TypeIDString)
+		}
+
+		// Other structures cannot be represented as fields
+		return nil, nil
+	}
+
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	switch value := v.(type) {
+	case json.Number:
+		if name, found := dataTypeNames[builtin]; found {
+			return opcua.ConvertTo(value.String(), name)
+		}
+		if i, err := value.Int64(); err == nil {
+			return i, nil
+		}
+		return value.Float64()
+	case string:
+		if name, found := dataTypeNames[builtin]; found && builtin != ua.TypeIDString {
+			return opcua.ConvertTo(value, name)
+		}
+		return value, nil
+	case bool:
+		return value, nil
+	}
+
+	// Arrays and other structures cannot be represented as fields
+	return nil, nil
+}
+
+func decodeTypeID(raw json.RawMessage) ua.TypeID {
+	var id uint8
+	if err := json.Unmarshal(raw, &id); err != nil {
+		return 0
+	}
+	return ua.TypeID(id)
+}
+
+// decodeStatus decodes a status code either encoded as number (v1.04) or
+// as object containing the code (v1.05)
+func decodeStatus(raw json.RawMessage) (ua.StatusCode, error) {
+	var code uint32
+	if err := json.Unmarshal(raw, &code); err == nil {
+		return ua.StatusCode(code), nil
+	}
+
+	var obj struct {
+		Code uint32 `json:"Code"`
+	}
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return 0, err
+	}
+	return ua.StatusCode(obj.Code), nil
+}
+
+// rawToString converts identifiers that might be encoded as string or number
+func rawToString(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return ""
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+
+	var n uint64
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return strconv.FormatUint(n, 10)
+	}
+
+	return string(raw)
+}
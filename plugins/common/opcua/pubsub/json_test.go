@@ -0,0 +1,246 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestJSONDecodeNetworkMessage(t *testing.T) {
+	msg := `{
+		"MessageId": "32235546-05d9-4fd7-97df-ea3ff3408574",
+		"MessageType": "ua-data",
+		"PublisherId": "MyPublisher",
+		"Messages": [
+			{
+				"DataSetWriterId": 1,
+				"SequenceNumber": 224,
+				"MetaDataVersion": {"MajorVersion": 1, "MinorVersion": 1},
+				"Timestamp": "2024-01-01T12:00:00Z",
+				"Payload": {
+					"Temperature": 21.5,
+					"Counter": 42,
+					"Running": true,
+					"State": "ok",
+					"Array": [1, 2, 3]
+				}
+			},
+			{
+				"DataSetWriterId": 2,
+				"MessageType": "ua-keepalive"
+			}
+		]
+	}`
+
+	decoder := NewJSONDecoder()
+	datasets, err := decoder.Decode([]byte(msg))
+	require.NoError(t, err)
+
+	expected := []*DataSet{
+		{
+			PublisherID:     "MyPublisher",
+			DataSetWriterID: 1,
+			SequenceNumber:  224,
+			Timestamp:       time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+			Fields: map[string]interface{}{
+				"Temperature": 21.5,
+				"Counter":     int64(42),
+				"Running":     true,
+				"State":       "ok",
+			},
+		},
+	}
+	require.Equal(t, expected, datasets)
+}
+
+func TestJSONDecodeWithMetaData(t *testing.T) {
+	metadata := `{
+		"MessageId": "1",
+		"MessageType": "ua-metadata",
+		"PublisherId": 4711,
+		"DataSetWriterId": 1,
+		"MetaData": {
+			"Name": "Boiler",
+			"Fields": [
+				{"Name": "Temperature", "BuiltInType": 10},
+				{"Name": "Counter", "BuiltInType": 7},
+				{"Name": "Setpoint", "BuiltInType": 11}
+			],
+			"ConfigurationVersion": {"MajorVersion": 1, "MinorVersion": 2}
+		}
+	}`
+	data := `{
+		"MessageId": "2",
+		"MessageType": "ua-data",
+		"PublisherId": 4711,
+		"Messages": [
+			{
+				"DataSetWriterId": 1,
+				"Status": 1073741824,
+				"Payload": {"Temperature": 21.5, "Counter": 42, "Setpoint": 20}
+			}
+		]
+	}`
+
+	decoder := NewJSONDecoder()
+	datasets, err := decoder.Decode([]byte(metadata))
+	require.NoError(t, err)
+	require.Empty(t, datasets)
+
+	md, found := decoder.MetaData.Get("4711", 1)
+	require.True(t, found)
+	require.Equal(t, "Boiler", md.Name)
+	require.Equal(t, uint32(2), md.MinorVersion)
+
+	datasets, err = decoder.Decode([]byte(data))
+	require.NoError(t, err)
+	require.Len(t, datasets, 1)
+
+	ds := datasets[0]
+	require.Equal(t, "Boiler", ds.Name)
+	require.Equal(t, "4711", ds.PublisherID)
+	require.NotNil(t, ds.Status)
+	require.Equal(t, ua.StatusUncertain, *ds.Status)
+	require.Equal(t, map[string]interface{}{
+		"Temperature": float32(21.5),
+		"Counter":     uint32(42),
+		"Setpoint":    float64(20),
+	}, ds.Fields)
+}
+
+func TestJSONDecodeReversibleEncoding(t *testing.T) {
+	msg := `{
+		"MessageId": "1",
+		"MessageType": "ua-data",
+		"PublisherId": "MyPublisher",
+		"Messages": [
+			{
+				"DataSetWriterId": 1,
+				"Status": {"Code": 0, "Symbol": "Good"},
+				"Payload": {
+					"v104": {"Type": 4, "Body": 17},
+					"v105": {"UaType": 9, "Value": "18446744073709551615"},
+					"datavalue": {"Value": {"Type": 11, "Body": 1.5}, "SourceTimestamp": "2024-01-01T12:00:00Z"},
+					"text": {"Type": 21, "Body": {"Locale": "en", "Text": "hello"}},
+					"structure": {"Type": 22, "Body": {"Foo": 1}},
+					"null": null
+				}
+			}
+		]
+	}`
+
+	decoder := NewJSONDecoder()
+	datasets, err := decoder.Decode([]byte(msg))
+	require.NoError(t, err)
+	require.Len(t, datasets, 1)
+	require.Equal(t, ua.StatusOK, *datasets[0].Status)
+	require.Equal(t, map[string]interface{}{
+		"v104":      int16(17),
+		"v105":      uint64(18446744073709551615),
+		"datavalue": float64(1.5),
+		"text":      "hello",
+	}, datasets[0].Fields)
+}
+
+func TestJSONDecodeWithoutNetworkHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  string
+	}{
+		{
+			name: "single message",
+			msg:  `{"DataSetWriterId": 3, "PublisherId": "pub", "Payload": {"value": 1}}`,
+		},
+		{
+			name: "message array",
+			msg:  `[{"DataSetWriterId": 3, "PublisherId": "pub", "Payload": {"value": 1}}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := NewJSONDecoder()
+			datasets, err := decoder.Decode([]byte(tt.msg))
+			require.NoError(t, err)
+			require.Equal(t, []*DataSet{
+				{
+					PublisherID:     "pub",
+					DataSetWriterID: 3,
+					Fields:          map[string]interface{}{"value": int64(1)},
+				},
+			}, datasets)
+		})
+	}
+}
+
+func TestJSONDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      string
+		expected string
+	}{
+		{
+			name:     "no json",
+			msg:      "foo",
+			expected: "decoding network message failed",
+		},
+		{
+			name:     "unknown type",
+			msg:      `{"MessageType": "ua-foo"}`,
+			expected: `unknown message type "ua-foo"`,
+		},
+		{
+			name:     "metadata missing",
+			msg:      `{"MessageType": "ua-metadata", "DataSetWriterId": 1}`,
+			expected: "metadata message without metadata",
+		},
+		{
+			name:     "invalid messages",
+			msg:      `{"MessageType": "ua-data", "Messages": {"foo": 1}}`,
+			expected: "decoding dataset messages failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := NewJSONDecoder()
+			_, err := decoder.Decode([]byte(tt.msg))
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestDataSetMetric(t *testing.T) {
+	status := ua.StatusOK
+	ds := &DataSet{
+		PublisherID:     "pub",
+		WriterGroupID:   5,
+		DataSetWriterID: 3,
+		Name:            "Boiler",
+		Timestamp:       time.Unix(1700000000, 0),
+		Status:          &status,
+		Fields:          map[string]interface{}{"value": int64(1)},
+	}
+
+	expected := metric.New(
+		"opcua_pubsub",
+		map[string]string{
+			"publisher_id":    "pub",
+			"writer_group_id": "5",
+			"writer_id":       "3",
+			"dataset":         "Boiler",
+		},
+		map[string]interface{}{
+			"value":   int64(1),
+			"Quality": "The operation succeeded. StatusGood (0x0)",
+		},
+		time.Unix(1700000000, 0),
+	)
+	testutil.RequireMetricsEqual(t, []telegraf.Metric{expected}, []telegraf.Metric{ds.Metric("opcua_pubsub")})
+}
@@ -0,0 +1,172 @@
+// Package pubsub implements decoding of OPC UA PubSub (Part 14) messages.
+package pubsub
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// DataSet is a decoded DataSetMessage
+type DataSet struct {
+	PublisherID     string
+	WriterGroupID   uint16
+	DataSetWriterID uint16
+	Name            string
+	SequenceNumber  uint32
+	Timestamp       time.Time
+	Status          *ua.StatusCode
+	Fields          map[string]interface{}
+}
+
+// Metric converts the dataset to a metric with the given name. The current
+// time is used if the message does not contain a timestamp.
+func (ds *DataSet) Metric(name string) telegraf.Metric {
+	tags := map[string]string{
+		"publisher_id": ds.PublisherID,
+		"writer_id":    strconv.FormatUint(uint64(ds.DataSetWriterID), 10),
+	}
+	if ds.WriterGroupID != 0 {
+		tags["writer_group_id"] = strconv.FormatUint(uint64(ds.WriterGroupID), 10)
+	}
+	if ds.Name != "" {
+		tags["dataset"] = ds.Name
+	}
+
+	fields := make(map[string]interface{}, len(ds.Fields)+1)
+	for k, v := range ds.Fields {
+		fields[k] = v
+	}
+	if ds.Status != nil {
+		fields["Quality"] = strings.TrimSpace(ds.Status.Error())
+	}
+
+	t := ds.Timestamp
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	return metric.New(name, tags, fields, t)
+}
+
+// FieldMetaData describes a single field of a dataset
+type FieldMetaData struct {
+	Name        string
+	BuiltInType ua.TypeID
+	Array       bool
+}
+
+// MetaData describes the layout of a dataset as published via
+// DataSetMetaData messages
+type MetaData struct {
+	Name         string
+	Fields       []FieldMetaData
+	MajorVersion uint32
+	MinorVersion uint32
+}
+
+// Field returns the metadata of the field with the given name
+func (md *MetaData) Field(name string) (FieldMetaData, bool) {
+	for _, f := range md.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return FieldMetaData{}, false
+}
+
+type metaDataKey struct {
+	publisherID string
+	writerID    uint16
+}
+
+// MetaDataStore keeps the latest metadata received for each dataset writer.
+// The store is safe for concurrent use.
+type MetaDataStore struct {
+	entries map[metaDataKey]*MetaData
+	sync.RWMutex
+}
+
+// NewMetaDataStore creates an empty store
+func NewMetaDataStore() *MetaDataStore {
+	return &MetaDataStore{entries: make(map[metaDataKey]*MetaData)}
+}
+
+// Add the metadata for the given publisher and dataset writer replacing
+// any previous version
+func (s *MetaDataStore) Add(publisherID string, writerID uint16, md *MetaData) {
+	s.Lock()
+	defer s.Unlock()
+	s.entries[metaDataKey{publisherID, writerID}] = md
+}
+
+// Get the metadata for the given publisher and dataset writer
+func (s *MetaDataStore) Get(publisherID string, writerID uint16) (*MetaData, bool) {
+	s.RLock()
+	defer s.RUnlock()
+	md, found := s.entries[metaDataKey{publisherID, writerID}]
+	return md, found
+}
+
+// TypeIDByName returns the built-in type for the given data type name as
+// used in the configuration, e.g. "Int32"
+func TypeIDByName(name string) (ua.TypeID, bool) {
+	for id, n := range dataTypeNames {
+		if n == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+// fieldValue converts a decoded variant value to a type usable as metric
+// field. Nil is returned for values that cannot be represented as field.
+func fieldValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case bool, string, float32, float64,
+		int8, int16, int32, int64, uint8, uint16, uint32, uint64:
+		return v
+	case *ua.LocalizedText:
+		if v == nil {
+			return nil
+		}
+		return v.Text
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case ua.StatusCode:
+		return uint32(v)
+	case *ua.GUID:
+		if v == nil {
+			return nil
+		}
+		return v.String()
+	case *ua.NodeID:
+		if v == nil {
+			return nil
+		}
+		return v.String()
+	}
+	return nil
+}
+
+// dataTypeNames maps the built-in types to the names used for conversion
+var dataTypeNames = map[ua.TypeID]string{
+	ua.TypeIDBoolean: "Boolean",
+	ua.TypeIDSByte:   "SByte",
+	ua.TypeIDByte:    "Byte",
+	ua.TypeIDInt16:   "Int16",
+	ua.TypeIDUint16:  "UInt16",
+	ua.TypeIDInt32:   "Int32",
+	ua.TypeIDUint32:  "UInt32",
+	ua.TypeIDInt64:   "Int64",
+	ua.TypeIDUint64:  "UInt64",
+	ua.TypeIDFloat:   "Float",
+	ua.TypeIDDouble:  "Double",
+	ua.TypeIDString:  "String",
+}
@@ -0,0 +1,435 @@
+package pubsub
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+)
+
+// UADP NetworkMessage flags as defined in Part 14, 7.2.4.4.2
+const (
+	uadpVersionMask           = 0x0f
+	uadpFlagPublisherID       = 0x10
+	uadpFlagGroupHeader       = 0x20
+	uadpFlagPayloadHeader     = 0x40
+	uadpFlagExtendedFlags1    = 0x80
+	uadpExt1PublisherIDMask   = 0x07
+	uadpExt1DataSetClassID    = 0x08
+	uadpExt1Security          = 0x10
+	uadpExt1Timestamp         = 0x20
+	uadpExt1PicoSeconds       = 0x40
+	uadpExt1ExtendedFlags2    = 0x80
+	uadpExt2Chunk             = 0x01
+	uadpExt2PromotedFields    = 0x02
+	uadpExt2MessageTypeMask   = 0x1c
+	uadpGroupWriterGroupID    = 0x01
+	uadpGroupVersion          = 0x02
+	uadpGroupNetworkMessageNo = 0x04
+	uadpGroupSequenceNumber   = 0x08
+)
+
+// UADP NetworkMessage types stored in ExtendedFlags2
+const (
+	uadpMessageTypeData              = 0x00
+	uadpMessageTypeDiscoveryRequest  = 0x04
+	uadpMessageTypeDiscoveryResponse = 0x08
+)
+
+// UADP discovery response types, Part 14, 7.2.4.6.4
+const uadpDiscoveryResponseMetaData = 2
+
+// UADP DataSetMessage flags as defined in Part 14, 7.2.4.5.4
+const (
+	uadpDataSetValid           = 0x01
+	uadpDataSetEncodingMask    = 0x06
+	uadpDataSetSequenceNumber  = 0x08
+	uadpDataSetStatus          = 0x10
+	uadpDataSetMajorVersion    = 0x20
+	uadpDataSetMinorVersion    = 0x40
+	uadpDataSetFlags2          = 0x80
+	uadpDataSet2TypeMask       = 0x0f
+	uadpDataSet2Timestamp      = 0x10
+	uadpDataSet2PicoSeconds    = 0x20
+	uadpFieldEncodingVariant   = 0x00
+	uadpFieldEncodingRawData   = 0x02
+	uadpFieldEncodingDataValue = 0x04
+	uadpDataSetKeyFrame        = 0x00
+	uadpDataSetDeltaFrame      = 0x01
+	uadpDataSetEvent           = 0x02
+	uadpDataSetKeepAlive       = 0x03
+)
+
+type uadpHeader struct {
+	publisherID   string
+	writerGroupID uint16
+	sequenceNo    uint16
+	timestamp     time.Time
+	messageType   byte
+	writerIDs     []uint16
+}
+
+// UADPDecoder decodes PubSub NetworkMessages using the UADP binary message
+// mapping (Part 14, 7.2.4). DataSetMetaData received via discovery
+// responses is stored and used to determine the name and type of fields.
+// Signed or encrypted messages as well as chunked messages are not supported.
+type UADPDecoder struct {
+	MetaData *MetaDataStore
+}
+
+// NewUADPDecoder creates a decoder with an empty metadata store
+func NewUADPDecoder() *UADPDecoder {
+	return &UADPDecoder{MetaData: NewMetaDataStore()}
+}
+
+// Decode the given NetworkMessage returning the contained datasets.
+// Discovery messages update the decoder's metadata store and do not produce
+// datasets.
+func (d *UADPDecoder) Decode(buf []byte) ([]*DataSet, error) {
+	b := ua.NewBuffer(buf)
+
+	hdr, err := decodeUADPHeader(b)
+	if err != nil {
+		return nil, err
+	}
+
+	switch hdr.messageType {
+	case uadpMessageTypeData:
+	case uadpMessageTypeDiscoveryRequest:
+		return nil, nil
+	case uadpMessageTypeDiscoveryResponse:
+		return nil, d.decodeDiscoveryResponse(b, hdr)
+	default:
+		return nil, fmt.Errorf("unknown network message type %d", hdr.messageType>>2)
+	}
+
+	// Without payload header the message contains exactly one DataSetMessage
+	// of an unknown writer
+	if len(hdr.writerIDs) == 0 {
+		ds, err := d.decodeDataSetMessage(b.Bytes(), hdr, 0)
+		if err != nil || ds == nil {
+			return nil, err
+		}
+		return []*DataSet{ds}, nil
+	}
+
+	// Multiple messages are preceded by their sizes
+	sizes := make([]int, len(hdr.writerIDs))
+	if len(hdr.writerIDs) > 1 {
+		for i := range sizes {
+			sizes[i] = int(b.ReadUint16())
+		}
+		if err := b.Error(); err != nil {
+			return nil, fmt.Errorf("decoding payload sizes failed: %w", err)
+		}
+	} else {
+		sizes[0] = b.Len()
+	}
+
+	datasets := make([]*DataSet, 0, len(hdr.writerIDs))
+	for i, writerID := range hdr.writerIDs {
+		raw := b.ReadN(sizes[i])
+		if err := b.Error(); err != nil {
+			return nil, fmt.Errorf("reading message of writer %d failed: %w", writerID, err)
+		}
+		ds, err := d.decodeDataSetMessage(raw, hdr, writerID)
+		if err != nil {
+			return nil, fmt.Errorf("decoding message of writer %d failed: %w", writerID, err)
+		}
+		if ds != nil {
+			datasets = append(datasets, ds)
+		}
+	}
+
+	return datasets, nil
+}
+
+func decodeUADPHeader(b *ua.Buffer) (*uadpHeader, error) {
+	var hdr uadpHeader
+
+	flags := b.ReadByte()
+	if version := flags & uadpVersionMask; version != 1 {
+		return nil, fmt.Errorf("unsupported UADP version %d", version)
+	}
+	var ext1, ext2 byte
+	if flags&uadpFlagExtendedFlags1 != 0 {
+		ext1 = b.ReadByte()
+	}
+	if ext1&uadpExt1ExtendedFlags2 != 0 {
+		ext2 = b.ReadByte()
+	}
+	if ext2&uadpExt2Chunk != 0 {
+		return nil, errors.New("chunked messages are not supported")
+	}
+	if ext1&uadpExt1Security != 0 {
+		return nil, errors.New("secured messages are not supported")
+	}
+	hdr.messageType = ext2 & uadpExt2MessageTypeMask
+
+	if flags&uadpFlagPublisherID != 0 {
+		switch t := ext1 & uadpExt1PublisherIDMask; t {
+		case 0:
+			hdr.publisherID = strconv.FormatUint(uint64(b.ReadByte()), 10)
+		case 1:
+			hdr.publisherID = strconv.FormatUint(uint64(b.ReadUint16()), 10)
+		case 2:
+			hdr.publisherID = strconv.FormatUint(uint64(b.ReadUint32()), 10)
+		case 3:
+			hdr.publisherID = strconv.FormatUint(b.ReadUint64(), 10)
+		case 4:
+			hdr.publisherID = b.ReadString()
+		default:
+			return nil, fmt.Errorf("invalid publisher ID type %d", t)
+		}
+	}
+	if ext1&uadpExt1DataSetClassID != 0 {
+		b.ReadN(16)
+	}
+
+	if flags&uadpFlagGroupHeader != 0 {
+		group := b.ReadByte()
+		if group&uadpGroupWriterGroupID != 0 {
+			hdr.writerGroupID = b.ReadUint16()
+		}
+		if group&uadpGroupVersion != 0 {
+			b.ReadUint32()
+		}
+		if group&uadpGroupNetworkMessageNo != 0 {
+			b.ReadUint16()
+		}
+		if group&uadpGroupSequenceNumber != 0 {
+			hdr.sequenceNo = b.ReadUint16()
+		}
+	}
+
+	if flags&uadpFlagPayloadHeader != 0 && hdr.messageType == uadpMessageTypeData {
+		count := int(b.ReadByte())
+		hdr.writerIDs = make([]uint16, 0, count)
+		for range count {
+			hdr.writerIDs = append(hdr.writerIDs, b.ReadUint16())
+		}
+	}
+
+	if ext1&uadpExt1Timestamp != 0 {
+		hdr.timestamp = b.ReadTime()
+	}
+	if ext1&uadpExt1PicoSeconds != 0 {
+		b.ReadUint16()
+	}
+	if ext2&uadpExt2PromotedFields != 0 {
+		b.ReadN(int(b.ReadUint16()))
+	}
+
+	if err := b.Error(); err != nil {
+		return nil, fmt.Errorf("decoding network message header failed: %w", err)
+	}
+	return &hdr, nil
+}
+
+func (d *UADPDecoder) decodeDiscoveryResponse(b *ua.Buffer, hdr *uadpHeader) error {
+	responseType := b.ReadByte()
+	b.ReadUint16() // sequence number
+	if responseType != uadpDiscoveryResponseMetaData {
+		return b.Error()
+	}
+
+	writerID := b.ReadUint16()
+	if err := b.Error(); err != nil {
+		return fmt.Errorf("decoding discovery response header failed: %w", err)
+	}
+
+	var md ua.DataSetMetaDataType
+	if _, err := ua.Decode(b.Bytes(), &md); err != nil {
+		return fmt.Errorf("decoding metadata of writer %d failed: %w", writerID, err)
+	}
+
+	fields := make([]FieldMetaData, 0, len(md.Fields))
+	for _, f := range md.Fields {
+		fields = append(fields, FieldMetaData{
+			Name:        f.Name,
+			BuiltInType: ua.TypeID(f.BuiltInType),
+			Array:       f.ValueRank >= 0,
+		})
+	}
+	metadata := &MetaData{Name: md.Name, Fields: fields}
+	if md.ConfigurationVersion != nil {
+		metadata.MajorVersion = md.ConfigurationVersion.MajorVersion
+		metadata.MinorVersion = md.ConfigurationVersion.MinorVersion
+	}
+	d.MetaData.Add(hdr.publisherID, writerID, metadata)
+
+	return nil
+}
+
+func (d *UADPDecoder) decodeDataSetMessage(buf []byte, hdr *uadpHeader, writerID uint16) (*DataSet, error) {
+	b := ua.NewBuffer(buf)
+
+	flags1 := b.ReadByte()
+	if flags1&uadpDataSetValid == 0 {
+		return nil, b.Error()
+	}
+	var flags2 byte
+	if flags1&uadpDataSetFlags2 != 0 {
+		flags2 = b.ReadByte()
+	}
+
+	ds := &DataSet{
+		PublisherID:     hdr.publisherID,
+		WriterGroupID:   hdr.writerGroupID,
+		DataSetWriterID: writerID,
+		Timestamp:       hdr.timestamp,
+		SequenceNumber:  uint32(hdr.sequenceNo),
+	}
+	if flags1&uadpDataSetSequenceNumber != 0 {
+		ds.SequenceNumber = uint32(b.ReadUint16())
+	}
+	if flags2&uadpDataSet2Timestamp != 0 {
+		ds.Timestamp = b.ReadTime()
+	}
+	if flags2&uadpDataSet2PicoSeconds != 0 {
+		b.ReadUint16()
+	}
+	if flags1&uadpDataSetStatus != 0 {
+		// Only the upper 16 bits of the status code are transmitted
+		status := ua.StatusCode(uint32(b.ReadUint16()) << 16)
+		ds.Status = &status
+	}
+	var majorVersion uint32
+	hasMajorVersion := flags1&uadpDataSetMajorVersion != 0
+	if hasMajorVersion {
+		majorVersion = b.ReadUint32()
+	}
+	if flags1&uadpDataSetMinorVersion != 0 {
+		b.ReadUint32()
+	}
+	if err := b.Error(); err != nil {
+		return nil, fmt.Errorf("decoding dataset message header failed: %w", err)
+	}
+
+	messageType := flags2 & uadpDataSet2TypeMask
+	switch messageType {
+	case uadpDataSetKeyFrame, uadpDataSetDeltaFrame, uadpDataSetEvent:
+	case uadpDataSetKeepAlive:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown dataset message type %d", messageType)
+	}
+
+	// Ignore outdated metadata as the field layout might have changed.
+	// Versions are derived from the configuration time so a zero version
+	// denotes unversioned, e.g. user-provided, metadata.
+	md, hasMetaData := d.MetaData.Get(ds.PublisherID, writerID)
+	if hasMetaData && hasMajorVersion && md.MajorVersion != 0 && md.MajorVersion != majorVersion {
+		hasMetaData = false
+	}
+	if hasMetaData {
+		ds.Name = md.Name
+	}
+
+	encoding := flags1 & uadpDataSetEncodingMask
+	if messageType == uadpDataSetEvent {
+		encoding = uadpFieldEncodingVariant
+	}
+	if encoding == uadpFieldEncodingRawData && !hasMetaData {
+		return nil, errors.New("raw data encoding requires metadata")
+	}
+
+	count := int(b.ReadUint16())
+	ds.Fields = make(map[string]interface{}, count)
+	for i := range count {
+		idx := i
+		if messageType == uadpDataSetDeltaFrame {
+			idx = int(b.ReadUint16())
+		}
+
+		var field FieldMetaData
+		if hasMetaData {
+			if idx >= len(md.Fields) {
+				return nil, fmt.Errorf("field index %d exceeds metadata with %d fields", idx, len(md.Fields))
+			}
+			field = md.Fields[idx]
+		} else {
+			field.Name = "field_" + strconv.Itoa(idx)
+		}
+
+		v, err := decodeUADPField(b, encoding, field)
+		if err != nil {
+			return nil, fmt.Errorf("decoding field %q failed: %w", field.Name, err)
+		}
+		if v := fieldValue(v); v != nil {
+			ds.Fields[field.Name] = v
+		}
+	}
+
+	return ds, nil
+}
+
+func decodeUADPField(b *ua.Buffer, encoding byte, field FieldMetaData) (interface{}, error) {
+	switch encoding {
+	case uadpFieldEncodingVariant:
+		var v ua.Variant
+		b.ReadStruct(&v)
+		return v.Value(), b.Error()
+	case uadpFieldEncodingDataValue:
+		var v ua.DataValue
+		b.ReadStruct(&v)
+		if v.Value == nil {
+			return nil, b.Error()
+		}
+		return v.Value.Value(), b.Error()
+	case uadpFieldEncodingRawData:
+		return decodeRaw(b, field)
+	}
+	return nil, fmt.Errorf("invalid field encoding %d", encoding>>1)
+}
+
+// decodeRaw decodes a value in RawData encoding where the type of the field
+// is only known from the metadata
+func decodeRaw(b *ua.Buffer, field FieldMetaData) (interface{}, error) {
+	if field.Array {
+		return nil, errors.New("arrays are not supported in raw data encoding")
+	}
+
+	var v interface{}
+	switch field.BuiltInType {
+	case ua.TypeIDBoolean:
+		v = b.ReadBool()
+	case ua.TypeIDSByte:
+		v = b.ReadInt8()
+	case ua.TypeIDByte:
+		v = b.ReadByte()
+	case ua.TypeIDInt16:
+		v = b.ReadInt16()
+	case ua.TypeIDUint16:
+		v = b.ReadUint16()
+	case ua.TypeIDInt32:
+		v = b.ReadInt32()
+	case ua.TypeIDUint32:
+		v = b.ReadUint32()
+	case ua.TypeIDInt64:
+		v = b.ReadInt64()
+	case ua.TypeIDUint64:
+		v = b.ReadUint64()
+	case ua.TypeIDFloat:
+		v = b.ReadFloat32()
+	case ua.TypeIDDouble:
+		v = b.ReadFloat64()
+	case ua.TypeIDString:
+		v = b.ReadString()
+	case ua.TypeIDDateTime:
+		v = b.ReadTime()
+	case ua.TypeIDStatusCode:
+		v = ua.StatusCode(b.ReadUint32())
+	case ua.TypeIDGUID:
+		guid := new(ua.GUID)
+		b.ReadStruct(guid)
+		v = guid
+	case ua.TypeIDByteString:
+		b.ReadBytes()
+	default:
+		return nil, fmt.Errorf("built-in type %d not supported in raw data encoding", field.BuiltInType)
+	}
+	return v, b.Error()
+}
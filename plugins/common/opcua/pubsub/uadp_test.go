@@ -0,0 +1,230 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+)
+
+func writeVariant(t *testing.T, buf *ua.Buffer, v interface{}) {
+	t.Helper()
+	raw, err := ua.MustVariant(v).Encode()
+	require.NoError(t, err)
+	buf.Write(raw)
+}
+
+func metaDataMessage(t *testing.T, major uint32) []byte {
+	t.Helper()
+
+	field := func(name string, builtin ua.TypeID) *ua.FieldMetaData {
+		return &ua.FieldMetaData{
+			Name:           name,
+			Description:    &ua.LocalizedText{},
+			BuiltInType:    uint8(builtin),
+			DataType:       ua.NewNumericNodeID(0, uint32(builtin)),
+			ValueRank:      -1,
+			DataSetFieldID: ua.NewGUID("00000000-0000-0000-0000-000000000000"),
+		}
+	}
+
+	md := &ua.DataSetMetaDataType{
+		Name:                 "Boiler",
+		Description:          &ua.LocalizedText{},
+		Fields:               []*ua.FieldMetaData{field("Temperature", ua.TypeIDFloat), field("Counter", ua.TypeIDUint32)},
+		DataSetClassID:       ua.NewGUID("00000000-0000-0000-0000-000000000000"),
+		ConfigurationVersion: &ua.ConfigurationVersionDataType{MajorVersion: major, MinorVersion: 1},
+	}
+	raw, err := ua.Encode(md)
+	require.NoError(t, err)
+
+	buf := ua.NewBuffer(nil)
+	buf.WriteByte(0x91)   // version 1, publisher ID, extended flags 1
+	buf.WriteByte(0x81)   // UInt16 publisher ID, extended flags 2
+	buf.WriteByte(0x08)   // discovery response
+	buf.WriteUint16(4711) // publisher ID
+	buf.WriteByte(2)      // metadata response
+	buf.WriteUint16(1)    // sequence number
+	buf.WriteUint16(1)    // writer ID
+	buf.Write(raw)        // metadata
+	buf.WriteUint32(0)    // status code
+	return buf.Bytes()
+}
+
+func TestUADPDecodeVariant(t *testing.T) {
+	ts := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	msg1 := ua.NewBuffer(nil)
+	msg1.WriteByte(0x19) // valid, variant encoding, sequence number, status
+	msg1.WriteUint16(7)
+	msg1.WriteUint16(0x4000)
+	msg1.WriteUint16(3)
+	writeVariant(t, msg1, int32(42))
+	writeVariant(t, msg1, "ok")
+	writeVariant(t, msg1, []int32{1, 2})
+
+	msg2 := ua.NewBuffer(nil)
+	msg2.WriteByte(0x81) // valid, flags 2
+	msg2.WriteByte(0x03) // keep alive
+
+	buf := ua.NewBuffer(nil)
+	buf.WriteByte(0xf1)   // version 1, publisher ID, group header, payload header, extended flags 1
+	buf.WriteByte(0x21)   // UInt16 publisher ID, timestamp
+	buf.WriteUint16(4711) // publisher ID
+	buf.WriteByte(0x09)   // writer group ID, sequence number
+	buf.WriteUint16(5)
+	buf.WriteUint16(10)
+	buf.WriteByte(2) // payload header with two writers
+	buf.WriteUint16(1)
+	buf.WriteUint16(2)
+	buf.WriteTime(ts)
+	buf.WriteUint16(uint16(len(msg1.Bytes())))
+	buf.WriteUint16(uint16(len(msg2.Bytes())))
+	buf.Write(msg1.Bytes())
+	buf.Write(msg2.Bytes())
+
+	decoder := NewUADPDecoder()
+	datasets, err := decoder.Decode(buf.Bytes())
+	require.NoError(t, err)
+
+	status := ua.StatusUncertain
+	expected := []*DataSet{
+		{
+			PublisherID:     "4711",
+			WriterGroupID:   5,
+			DataSetWriterID: 1,
+			SequenceNumber:  7,
+			Timestamp:       ts,
+			Status:          &status,
+			Fields: map[string]interface{}{
+				"field_0": int32(42),
+				"field_1": "ok",
+			},
+		},
+	}
+	require.Equal(t, expected, datasets)
+}
+
+func TestUADPDecodeWithMetaData(t *testing.T) {
+	decoder := NewUADPDecoder()
+	datasets, err := decoder.Decode(metaDataMessage(t, 1))
+	require.NoError(t, err)
+	require.Empty(t, datasets)
+
+	md, found := decoder.MetaData.Get("4711", 1)
+	require.True(t, found)
+	require.Equal(t, "Boiler", md.Name)
+	require.Equal(t, []FieldMetaData{
+		{Name: "Temperature", BuiltInType: ua.TypeIDFloat},
+		{Name: "Counter", BuiltInType: ua.TypeIDUint32},
+	}, md.Fields)
+
+	// Key frame in raw data encoding
+	buf := ua.NewBuffer(nil)
+	buf.WriteByte(0xd1)   // version 1, publisher ID, payload header, extended flags 1
+	buf.WriteByte(0x01)   // UInt16 publisher ID
+	buf.WriteUint16(4711) // publisher ID
+	buf.WriteByte(1)      // payload header with one writer
+	buf.WriteUint16(1)
+	buf.WriteByte(0x23) // valid, raw data encoding, major version
+	buf.WriteUint32(1)
+	buf.WriteUint16(2)
+	buf.WriteFloat32(21.5)
+	buf.WriteUint32(42)
+
+	datasets, err = decoder.Decode(buf.Bytes())
+	require.NoError(t, err)
+	require.Len(t, datasets, 1)
+	require.Equal(t, "Boiler", datasets[0].Name)
+	require.Equal(t, map[string]interface{}{
+		"Temperature": float32(21.5),
+		"Counter":     uint32(42),
+	}, datasets[0].Fields)
+
+	// Delta frame in variant encoding
+	buf = ua.NewBuffer(nil)
+	buf.WriteByte(0xd1)
+	buf.WriteByte(0x01)
+	buf.WriteUint16(4711)
+	buf.WriteByte(1)
+	buf.WriteUint16(1)
+	buf.WriteByte(0x81) // valid, variant encoding, flags 2
+	buf.WriteByte(0x01) // delta frame
+	buf.WriteUint16(1)
+	buf.WriteUint16(1) // field index
+	writeVariant(t, buf, uint32(43))
+
+	datasets, err = decoder.Decode(buf.Bytes())
+	require.NoError(t, err)
+	require.Len(t, datasets, 1)
+	require.Equal(t, map[string]interface{}{"Counter": uint32(43)}, datasets[0].Fields)
+
+	// A new major version invalidates the metadata
+	buf = ua.NewBuffer(nil)
+	buf.WriteByte(0xd1)
+	buf.WriteByte(0x01)
+	buf.WriteUint16(4711)
+	buf.WriteByte(1)
+	buf.WriteUint16(1)
+	buf.WriteByte(0x23)
+	buf.WriteUint32(2)
+	buf.WriteUint16(2)
+	buf.WriteFloat32(21.5)
+	buf.WriteUint32(42)
+
+	_, err = decoder.Decode(buf.Bytes())
+	require.ErrorContains(t, err, "raw data encoding requires metadata")
+}
+
+func TestUADPDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      []byte
+		expected string
+	}{
+		{
+			name:     "empty",
+			msg:      []byte{},
+			expected: "unsupported UADP version 0",
+		},
+		{
+			name:     "wrong version",
+			msg:      []byte{0x02},
+			expected: "unsupported UADP version 2",
+		},
+		{
+			name:     "secured",
+			msg:      []byte{0x81, 0x10},
+			expected: "secured messages are not supported",
+		},
+		{
+			name:     "chunked",
+			msg:      []byte{0x81, 0x80, 0x01},
+			expected: "chunked messages are not supported",
+		},
+		{
+			name:     "truncated header",
+			msg:      []byte{0x91, 0x01, 0x01},
+			expected: "decoding network message header failed",
+		},
+		{
+			name:     "truncated payload",
+			msg:      []byte{0x41, 0x02, 0x01, 0x00, 0x02, 0x00, 0x10, 0x00},
+			expected: "decoding payload sizes failed",
+		},
+		{
+			name:     "raw without metadata",
+			msg:      []byte{0x01, 0x03, 0x01, 0x00},
+			expected: "raw data encoding requires metadata",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder := NewUADPDecoder()
+			_, err := decoder.Decode(tt.msg)
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
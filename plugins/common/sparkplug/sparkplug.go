@@ -0,0 +1,51 @@
+package sparkplug
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Namespace is the first element of all Sparkplug B topics
+const Namespace = "spBv1.0"
+
+// Sparkplug B data types as defined in the specification
+const (
+	TypeUnknown  uint32 = 0
+	TypeInt8     uint32 = 1
+	TypeInt16    uint32 = 2
+	TypeInt32    uint32 = 3
+	TypeInt64    uint32 = 4
+	TypeUInt8    uint32 = 5
+	TypeUInt16   uint32 = 6
+	TypeUInt32   uint32 = 7
+	TypeUInt64   uint32 = 8
+	TypeFloat    uint32 = 9
+	TypeDouble   uint32 = 10
+	TypeBoolean  uint32 = 11
+	TypeString   uint32 = 12
+	TypeDateTime uint32 = 13
+	TypeText     uint32 = 14
+	TypeUUID     uint32 = 15
+)
+
+// Protobuf field numbers of the org.eclipse.tahu.protobuf.Payload message
+const (
+	PayloadTimestamp protowire.Number = 1
+	PayloadMetrics   protowire.Number = 2
+	PayloadSeq       protowire.Number = 3
+)
+
+// Protobuf field numbers of the Payload.Metric message
+const (
+	MetricName         protowire.Number = 1
+	MetricAlias        protowire.Number = 2
+	MetricTimestamp    protowire.Number = 3
+	MetricDatatype     protowire.Number = 4
+	MetricIsNull       protowire.Number = 7
+	MetricIntValue     protowire.Number = 10
+	MetricLongValue    protowire.Number = 11
+	MetricFloatValue   protowire.Number = 12
+	MetricDoubleValue  protowire.Number = 13
+	MetricBooleanValue protowire.Number = 14
+	MetricStringValue  protowire.Number = 15
+	MetricBytesValue   protowire.Number = 16
+)
@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.bacnet
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/bacnet" // register plugin
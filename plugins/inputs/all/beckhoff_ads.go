@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.beckhoff_ads
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/beckhoff_ads" // register plugin
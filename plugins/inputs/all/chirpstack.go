@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.chirpstack
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/chirpstack" // register plugin
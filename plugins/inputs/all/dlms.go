@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.dlms
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/dlms" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.ethercat
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/ethercat" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.euromap63
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/euromap63" // register plugin
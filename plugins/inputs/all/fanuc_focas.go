@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.fanuc_focas
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/fanuc_focas" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.hart_ip
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/hart_ip" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.iec61850
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/iec61850" // register plugin
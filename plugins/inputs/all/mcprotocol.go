@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.mcprotocol
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/mcprotocol" // register plugin
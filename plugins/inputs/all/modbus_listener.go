@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.modbus_listener
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/modbus_listener" // register plugin
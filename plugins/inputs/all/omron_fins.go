@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.omron_fins
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/omron_fins" // register plugin
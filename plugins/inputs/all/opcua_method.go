@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.opcua_method
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/opcua_method" // register plugin
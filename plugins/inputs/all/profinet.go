@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.profinet
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/profinet" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || inputs || inputs.secs_gem
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/inputs/secs_gem" // register plugin
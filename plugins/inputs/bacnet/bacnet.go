@@ -0,0 +1,561 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package bacnet
+
+import (
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Object types discovered by default
+var defaultDiscoverTypes = []string{
+	"analog-input",
+	"analog-output",
+	"analog-value",
+	"binary-input",
+	"binary-output",
+	"binary-value",
+	"multi-state-input",
+	"multi-state-output",
+	"multi-state-value",
+}
+
+type BACnet struct {
+	LocalAddress         string             `toml:"local_address"`
+	BroadcastAddress     string             `toml:"broadcast_address"`
+	Timeout              config.Duration    `toml:"timeout"`
+	Retries              int                `toml:"retries"`
+	MaxObjectsPerRequest int                `toml:"max_objects_per_request"`
+	COVLifetime          config.Duration    `toml:"cov_lifetime"`
+	Devices              []deviceDefinition `toml:"device"`
+	Log                  telegraf.Logger    `toml:"-"`
+
+	acc     telegraf.Accumulator
+	client  *client
+	devices []*device
+	sync.Mutex
+}
+
+type deviceDefinition struct {
+	Address       string             `toml:"address"`
+	Instance      *uint32            `toml:"instance"`
+	Measurement   string             `toml:"measurement"`
+	Discover      bool               `toml:"discover"`
+	DiscoverTypes []string           `toml:"discover_types"`
+	Objects       []objectDefinition `toml:"objects"`
+	Tags          map[string]string  `toml:"tags"`
+}
+
+type objectDefinition struct {
+	Type     string `toml:"type"`
+	Instance uint32 `toml:"instance"`
+	Name     string `toml:"name"`
+	COV      bool   `toml:"cov"`
+}
+
+// device is the runtime state of a configured device. The address, the
+// instance and the objects are guarded by the plugin's lock as they are
+// updated by received messages and the discovery.
+type device struct {
+	name          string
+	measurement   string
+	tags          map[string]string
+	processID     uint32
+	discover      bool
+	discoverTypes map[uint16]bool
+	discovered    bool
+
+	addr     *net.UDPAddr
+	instance uint32
+	objects  []*object
+}
+
+type object struct {
+	id         objectIdentifier
+	name       string
+	cov        bool
+	subscribed bool
+	renewAt    time.Time
+}
+
+func (*BACnet) SampleConfig() string {
+	return sampleConfig
+}
+
+func (b *BACnet) Init() error {
+	if len(b.Devices) == 0 {
+		return errors.New("no devices defined")
+	}
+	if b.Timeout <= 0 {
+		return errors.New("'timeout' must be positive")
+	}
+	if b.Retries < 0 {
+		return errors.New("'retries' must not be negative")
+	}
+	if b.MaxObjectsPerRequest < 1 {
+		return errors.New("'max_objects_per_request' must be positive")
+	}
+	if b.COVLifetime < 0 || time.Duration(b.COVLifetime).Seconds() > math.MaxUint32 {
+		return errors.New("invalid 'cov_lifetime'")
+	}
+
+	b.devices = make([]*device, 0, len(b.Devices))
+	for i, def := range b.Devices {
+		d, err := newDevice(def, uint32(i+1))
+		if err != nil {
+			return fmt.Errorf("device %d: %w", i+1, err)
+		}
+		b.devices = append(b.devices, d)
+	}
+
+	return nil
+}
+
+func newDevice(def deviceDefinition, processID uint32) (*device, error) {
+	d := &device{
+		measurement: def.Measurement,
+		tags:        def.Tags,
+		processID:   processID,
+		discover:    def.Discover,
+		instance:    wildcardInstance,
+	}
+	if d.measurement == "" {
+		d.measurement = "bacnet"
+	}
+
+	switch {
+	case def.Address != "":
+		address := def.Address
+		var nerr *net.AddrError
+		if _, _, err := net.SplitHostPort(address); errors.As(err, &nerr) && strings.Contains(nerr.Err, "missing port") {
+			address += ":47808"
+		}
+		addr, err := net.ResolveUDPAddr("udp4", address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", def.Address, err)
+		}
+		d.addr = addr
+		d.name = addr.String()
+	case def.Instance != nil:
+		d.name = "instance " + strconv.FormatUint(uint64(*def.Instance), 10)
+	default:
+		return nil, errors.New("either 'address' or 'instance' must be specified")
+	}
+	if def.Instance != nil {
+		if *def.Instance >= wildcardInstance {
+			return nil, fmt.Errorf("invalid instance %d", *def.Instance)
+		}
+		d.instance = *def.Instance
+	}
+
+	if len(def.Objects) == 0 && !def.Discover {
+		return nil, errors.New("no objects defined")
+	}
+	seen := make(map[objectIdentifier]bool, len(def.Objects))
+	for _, o := range def.Objects {
+		typ, found := objectTypes[o.Type]
+		if !found {
+			return nil, fmt.Errorf("invalid object type %q", o.Type)
+		}
+		if o.Instance >= wildcardInstance {
+			return nil, fmt.Errorf("invalid instance %d for object type %q", o.Instance, o.Type)
+		}
+		id := objectIdentifier{typ: typ, instance: o.Instance}
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate object %s:%d", o.Type, o.Instance)
+		}
+		seen[id] = true
+		d.objects = append(d.objects, &object{id: id, name: o.Name, cov: o.COV})
+	}
+
+	if def.Discover {
+		types := def.DiscoverTypes
+		if len(types) == 0 {
+			types = defaultDiscoverTypes
+		}
+		d.discoverTypes = make(map[uint16]bool, len(types))
+		for _, name := range types {
+			typ, found := objectTypes[name]
+			if !found {
+				return nil, fmt.Errorf("invalid object type %q in 'discover_types'", name)
+			}
+			d.discoverTypes[typ] = true
+		}
+	}
+
+	return d, nil
+}
+
+func (b *BACnet) Start(acc telegraf.Accumulator) error {
+	b.acc = acc
+
+	laddr, err := net.ResolveUDPAddr("udp4", b.LocalAddress)
+	if err != nil {
+		return fmt.Errorf("invalid local address %q: %w", b.LocalAddress, err)
+	}
+	broadcast, err := net.ResolveUDPAddr("udp4", b.BroadcastAddress)
+	if err != nil {
+		return fmt.Errorf("invalid broadcast address %q: %w", b.BroadcastAddress, err)
+	}
+	conn, err := net.ListenUDP("udp4", laddr)
+	if err != nil {
+		return fmt.Errorf("listening on %q failed: %w", b.LocalAddress, err)
+	}
+	b.Log.Debugf("Listening on %s", conn.LocalAddr())
+
+	b.client = &client{
+		conn:      conn,
+		broadcast: broadcast,
+		timeout:   time.Duration(b.Timeout),
+		retries:   b.Retries,
+		log:       b.Log,
+		onIAm:     b.onIAm,
+		onCOV:     b.onCOV,
+	}
+	b.client.start()
+
+	// Locate the devices only specified by their instance
+	for _, d := range b.devices {
+		if d.addr == nil {
+			if err := b.client.whoIs(d.instance); err != nil {
+				b.Log.Errorf("Locating device %s failed: %v", d.name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (b *BACnet) Gather(acc telegraf.Accumulator) error {
+	var wg sync.WaitGroup
+	for _, d := range b.devices {
+		wg.Add(1)
+		go func(d *device) {
+			defer wg.Done()
+			if err := b.gatherDevice(acc, d); err != nil {
+				acc.AddError(fmt.Errorf("device %s: %w", d.name, err))
+			}
+		}(d)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (b *BACnet) Stop() {
+	if b.client == nil {
+		return
+	}
+
+	// Cancel the subscriptions to stop the notifications
+	for _, d := range b.devices {
+		b.Lock()
+		addr, objects := d.addr, d.objects
+		b.Unlock()
+		for _, o := range objects {
+			if !o.subscribed {
+				continue
+			}
+			if err := b.client.subscribeCOV(addr, d.processID, o.id, 0, true); err != nil {
+				b.Log.Debugf("Cancelling subscription of %s on device %s failed: %v", o, d.name, err)
+			}
+		}
+	}
+
+	b.client.close()
+}
+
+func (b *BACnet) gatherDevice(acc telegraf.Accumulator, d *device) error {
+	b.Lock()
+	addr := d.addr
+	b.Unlock()
+	if addr == nil {
+		if err := b.client.whoIs(d.instance); err != nil {
+			return fmt.Errorf("sending Who-Is failed: %w", err)
+		}
+		return errors.New("device not found")
+	}
+
+	if d.discover && !d.discovered {
+		if err := b.discover(d, addr); err != nil {
+			return fmt.Errorf("discovering objects failed: %w", err)
+		}
+	}
+
+	b.Lock()
+	objects := d.objects
+	b.Unlock()
+
+	// Subscribe to changes of the objects with COV enabled and renew the
+	// subscriptions after half of their lifetime. Objects where the
+	// subscription fails are polled instead.
+	now := time.Now()
+	lifetime := time.Duration(b.COVLifetime)
+	poll := make([]*object, 0, len(objects))
+	for _, o := range objects {
+		if !o.cov {
+			poll = append(poll, o)
+			continue
+		}
+		if o.subscribed && (lifetime == 0 || now.Before(o.renewAt)) {
+			continue
+		}
+		if err := b.client.subscribeCOV(addr, d.processID, o.id, uint32(lifetime.Seconds()), false); err != nil {
+			acc.AddError(fmt.Errorf("device %s: subscribing to %s failed: %w", d.name, o, err))
+			poll = append(poll, o)
+			continue
+		}
+		o.subscribed = true
+		o.renewAt = now.Add(lifetime / 2)
+	}
+
+	// Poll the remaining objects
+	for start := 0; start < len(poll); start += b.MaxObjectsPerRequest {
+		chunk := poll[start:min(start+b.MaxObjectsPerRequest, len(poll))]
+		specs := make([]readAccessSpec, 0, len(chunk))
+		for _, o := range chunk {
+			specs = append(specs, readAccessSpec{
+				object: o.id,
+				properties: []propertyReference{
+					{id: propPresentValue, index: arrayAll},
+					{id: propStatusFlags, index: arrayAll},
+				},
+			})
+		}
+
+		results, err := b.client.readPropertyMultiple(addr, specs)
+		if err != nil {
+			return fmt.Errorf("reading objects failed: %w", err)
+		}
+		timestamp := time.Now()
+
+		values := make(map[objectIdentifier]map[uint32][]interface{}, len(chunk))
+		for _, r := range results {
+			if r.err != nil {
+				b.Log.Debugf("Reading property %d of %s on device %s failed: %v", r.property.id, r.object, d.name, r.err)
+				continue
+			}
+			if _, found := values[r.object]; !found {
+				values[r.object] = make(map[uint32][]interface{})
+			}
+			values[r.object][r.property.id] = r.values
+		}
+		for _, o := range chunk {
+			b.addMetric(acc, d, addr, o, values[o.id], timestamp)
+		}
+	}
+
+	return nil
+}
+
+// discover reads the object-list and the names of the objects of the device
+// and adds the objects of the requested types
+func (b *BACnet) discover(d *device, addr *net.UDPAddr) error {
+	b.Lock()
+	dev := objectIdentifier{typ: objectTypeDevice, instance: d.instance}
+	configured := make(map[objectIdentifier]bool, len(d.objects))
+	for _, o := range d.objects {
+		configured[o.id] = true
+	}
+	b.Unlock()
+
+	// Read the object-list element by element as the whole list might
+	// exceed the size of a response
+	values, err := b.client.readProperty(addr, dev, propertyReference{id: propObjectList, index: 0})
+	if err != nil {
+		return fmt.Errorf("reading object-list length failed: %w", err)
+	}
+	if len(values) != 1 {
+		return errors.New("invalid object-list length")
+	}
+	count, ok := values[0].(uint64)
+	if !ok {
+		return fmt.Errorf("invalid object-list length %v", values[0])
+	}
+
+	instance := d.instance
+	var found []*object
+	for start := uint64(1); start <= count; start += uint64(b.MaxObjectsPerRequest) {
+		refs := make([]propertyReference, 0, b.MaxObjectsPerRequest)
+		for i := start; i <= count && i < start+uint64(b.MaxObjectsPerRequest); i++ {
+			refs = append(refs, propertyReference{id: propObjectList, index: uint32(i)})
+		}
+		results, err := b.client.readPropertyMultiple(addr, []readAccessSpec{{object: dev, properties: refs}})
+		if err != nil {
+			return fmt.Errorf("reading object-list failed: %w", err)
+		}
+		for _, r := range results {
+			if r.err != nil {
+				return fmt.Errorf("reading object-list element %d failed: %w", r.property.index, r.err)
+			}
+			if len(r.values) != 1 {
+				return fmt.Errorf("invalid object-list element %d", r.property.index)
+			}
+			id, ok := r.values[0].(objectIdentifier)
+			if !ok {
+				return fmt.Errorf("invalid object-list element %d: %v", r.property.index, r.values[0])
+			}
+			if id.typ == objectTypeDevice {
+				instance = id.instance
+				continue
+			}
+			if d.discoverTypes[id.typ] && !configured[id] {
+				found = append(found, &object{id: id})
+			}
+		}
+	}
+
+	// Read the object names
+	for start := 0; start < len(found); start += b.MaxObjectsPerRequest {
+		chunk := found[start:min(start+b.MaxObjectsPerRequest, len(found))]
+		specs := make([]readAccessSpec, 0, len(chunk))
+		for _, o := range chunk {
+			specs = append(specs, readAccessSpec{
+				object:     o.id,
+				properties: []propertyReference{{id: propObjectName, index: arrayAll}},
+			})
+		}
+		results, err := b.client.readPropertyMultiple(addr, specs)
+		if err != nil {
+			return fmt.Errorf("reading object names failed: %w", err)
+		}
+		names := make(map[objectIdentifier]string, len(results))
+		for _, r := range results {
+			if r.err == nil && len(r.values) == 1 {
+				if name, ok := r.values[0].(string); ok {
+					names[r.object] = name
+				}
+			}
+		}
+		for _, o := range chunk {
+			o.name = names[o.id]
+		}
+	}
+	b.Log.Debugf("Discovered %d objects on device %s", len(found), d.name)
+
+	b.Lock()
+	d.objects = append(slices.Clip(d.objects), found...)
+	d.instance = instance
+	d.discovered = true
+	b.Unlock()
+
+	return nil
+}
+
+// onIAm completes the devices with the address or the instance announced
+func (b *BACnet) onIAm(id objectIdentifier, addr *net.UDPAddr) {
+	b.Lock()
+	defer b.Unlock()
+
+	for _, d := range b.devices {
+		switch {
+		case d.addr == nil && d.instance == id.instance:
+			b.Log.Debugf("Found device %s at %s", d.name, addr)
+			d.addr = addr
+		case d.addr != nil && d.instance == wildcardInstance && d.addr.String() == addr.String():
+			d.instance = id.instance
+		}
+	}
+}
+
+// onCOV adds the values reported by a change-of-value notification
+func (b *BACnet) onCOV(addr *net.UDPAddr, n *covNotification) {
+	timestamp := time.Now()
+
+	b.Lock()
+	if n.processID == 0 || int(n.processID) > len(b.devices) {
+		b.Unlock()
+		b.Log.Debugf("Ignoring notification for unknown subscription %d from %s", n.processID, addr)
+		return
+	}
+	d := b.devices[n.processID-1]
+	var subscribed *object
+	for _, o := range d.objects {
+		if o.id == n.object && o.cov {
+			subscribed = o
+			break
+		}
+	}
+	b.Unlock()
+
+	if subscribed == nil {
+		b.Log.Debugf("Ignoring notification for unknown object %s from %s", n.object, addr)
+		return
+	}
+	b.addMetric(b.acc, d, addr, subscribed, n.values, timestamp)
+}
+
+func (b *BACnet) addMetric(acc telegraf.Accumulator, d *device, addr *net.UDPAddr, o *object, values map[uint32][]interface{}, timestamp time.Time) {
+	fields := make(map[string]interface{}, 5)
+	if v := values[propPresentValue]; len(v) > 0 {
+		switch x := v[0].(type) {
+		case nil, []bool:
+		case objectIdentifier:
+			fields["present_value"] = x.String()
+		default:
+			fields["present_value"] = x
+		}
+	}
+	if v := values[propStatusFlags]; len(v) > 0 {
+		if flags, ok := v[0].([]bool); ok && len(flags) >= 4 {
+			fields["in_alarm"] = flags[0]
+			fields["fault"] = flags[1]
+			fields["overridden"] = flags[2]
+			fields["out_of_service"] = flags[3]
+		}
+	}
+	if len(fields) == 0 {
+		b.Log.Debugf("No values for %s on device %s", o, d.name)
+		return
+	}
+
+	tags := make(map[string]string, len(d.tags)+5)
+	for k, v := range d.tags {
+		tags[k] = v
+	}
+	tags["address"] = addr.String()
+	b.Lock()
+	if d.instance != wildcardInstance {
+		tags["device_instance"] = strconv.FormatUint(uint64(d.instance), 10)
+	}
+	b.Unlock()
+	tags["object_type"] = objectTypeName(o.id.typ)
+	tags["object_instance"] = strconv.FormatUint(uint64(o.id.instance), 10)
+	if o.name != "" {
+		tags["object_name"] = o.name
+	}
+
+	acc.AddFields(d.measurement, fields, tags, timestamp)
+}
+
+func (o *object) String() string {
+	return o.id.String()
+}
+
+func init() {
+	inputs.Add("bacnet", func() telegraf.Input {
+		return &BACnet{
+			LocalAddress:         ":47808",
+			BroadcastAddress:     "255.255.255.255:47808",
+			Timeout:              config.Duration(5 * time.Second),
+			Retries:              2,
+			MaxObjectsPerRequest: 20,
+			COVLifetime:          config.Duration(5 * time.Minute),
+		}
+	})
+}
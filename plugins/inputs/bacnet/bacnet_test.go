@@ -0,0 +1,585 @@
+package bacnet
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	instance := uint32(wildcardInstance)
+	tests := []struct {
+		name     string
+		devices  []deviceDefinition
+		expected string
+	}{
+		{
+			name:     "no devices",
+			expected: "no devices defined",
+		},
+		{
+			name:     "no address",
+			devices:  []deviceDefinition{{Objects: []objectDefinition{{Type: "analog-input"}}}},
+			expected: "either 'address' or 'instance' must be specified",
+		},
+		{
+			name:     "invalid instance",
+			devices:  []deviceDefinition{{Instance: &instance, Discover: true}},
+			expected: "invalid instance",
+		},
+		{
+			name:     "no objects",
+			devices:  []deviceDefinition{{Address: "127.0.0.1"}},
+			expected: "no objects defined",
+		},
+		{
+			name:     "invalid object type",
+			devices:  []deviceDefinition{{Address: "127.0.0.1", Objects: []objectDefinition{{Type: "analog"}}}},
+			expected: `invalid object type "analog"`,
+		},
+		{
+			name: "duplicate object",
+			devices: []deviceDefinition{{Address: "127.0.0.1", Objects: []objectDefinition{
+				{Type: "analog-input", Instance: 1},
+				{Type: "analog-input", Instance: 1, Name: "foo"},
+			}}},
+			expected: "duplicate object",
+		},
+		{
+			name:     "invalid discover type",
+			devices:  []deviceDefinition{{Address: "127.0.0.1", Discover: true, DiscoverTypes: []string{"analog"}}},
+			expected: "in 'discover_types'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &BACnet{
+				Timeout:              config.Duration(time.Second),
+				MaxObjectsPerRequest: 20,
+				Devices:              tt.devices,
+				Log:                  testutil.Logger{},
+			}
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestDecodeApplicationValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected interface{}
+	}{
+		{"null", []byte{0x00}, nil},
+		{"boolean", []byte{0x11}, true},
+		{"unsigned", []byte{0x22, 0x01, 0x00}, uint64(256)},
+		{"signed", []byte{0x31, 0xFE}, int64(-2)},
+		{"real", []byte{0x44, 0x41, 0xAC, 0x00, 0x00}, float64(21.5)},
+		{"double", []byte{0x55, 0x08, 0x40, 0x09, 0x21, 0xFB, 0x54, 0x44, 0x2D, 0x18}, math.Pi},
+		{"character string", []byte{0x75, 0x04, 0x00, 'f', 'o', 'o'}, "foo"},
+		{"bit string", []byte{0x82, 0x04, 0x50}, []bool{false, true, false, true}},
+		{"enumerated", []byte{0x91, 0x01}, uint64(1)},
+		{"object identifier", []byte{0xC4, 0x00, 0x00, 0x00, 0x01}, objectIdentifier{typ: 0, instance: 1}},
+		{"date", []byte{0xA4, 0x7A, 0x01, 0x01, 0x01}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := &decoder{buf: tt.data}
+			v, err := d.applicationValue()
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, v)
+			require.True(t, d.done())
+		})
+	}
+}
+
+func TestPolling(t *testing.T) {
+	dev := newFakeDevice(t, 1234)
+	dev.set(objectIdentifier{typ: 0, instance: 1}, propPresentValue, appendReal(nil, 21.5))
+	dev.set(objectIdentifier{typ: 0, instance: 1}, propStatusFlags, appendBitString(nil, false, true, false, false))
+	dev.set(objectIdentifier{typ: 5, instance: 3}, propPresentValue, appendUnsigned(nil, tagEnumerated, false, 1))
+	dev.set(objectIdentifier{typ: 5, instance: 3}, propStatusFlags, appendBitString(nil, false, false, false, false))
+
+	plugin := &BACnet{
+		LocalAddress:         "127.0.0.1:0",
+		BroadcastAddress:     "127.0.0.1:47808",
+		Timeout:              config.Duration(time.Second),
+		MaxObjectsPerRequest: 1,
+		Devices: []deviceDefinition{
+			{
+				Address: dev.addr().String(),
+				Objects: []objectDefinition{
+					{Type: "analog-input", Instance: 1, Name: "outdoor_temperature"},
+					{Type: "binary-value", Instance: 3},
+					{Type: "analog-value", Instance: 7},
+				},
+				Tags: map[string]string{"building": "north"},
+			},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	require.NoError(t, plugin.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	address := dev.addr().String()
+	expected := []telegraf.Metric{
+		metric.New(
+			"bacnet",
+			map[string]string{
+				"address":         address,
+				"building":        "north",
+				"object_type":     "analog-input",
+				"object_instance": "1",
+				"object_name":     "outdoor_temperature",
+			},
+			map[string]interface{}{
+				"present_value":  float64(21.5),
+				"in_alarm":       false,
+				"fault":          true,
+				"overridden":     false,
+				"out_of_service": false,
+			},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"bacnet",
+			map[string]string{
+				"address":         address,
+				"building":        "north",
+				"object_type":     "binary-value",
+				"object_instance": "3",
+			},
+			map[string]interface{}{
+				"present_value":  uint64(1),
+				"in_alarm":       false,
+				"fault":          false,
+				"overridden":     false,
+				"out_of_service": false,
+			},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime(), testutil.SortMetrics())
+}
+
+func TestDiscoveryAndCOV(t *testing.T) {
+	dev := newFakeDevice(t, 1234)
+	ai1 := objectIdentifier{typ: 0, instance: 1}
+	ai2 := objectIdentifier{typ: 0, instance: 2}
+	bv3 := objectIdentifier{typ: 5, instance: 3}
+	sch := objectIdentifier{typ: 17, instance: 1}
+	dev.objectList = []objectIdentifier{{typ: objectTypeDevice, instance: 1234}, ai1, ai2, bv3, sch}
+	dev.set(ai1, propObjectName, appendCharacterString(nil, "supply_temperature"))
+	dev.set(ai1, propPresentValue, appendReal(nil, 45))
+	dev.set(ai2, propObjectName, appendCharacterString(nil, "return_temperature"))
+	dev.set(ai2, propPresentValue, appendReal(nil, 30))
+	dev.set(bv3, propPresentValue, appendUnsigned(nil, tagEnumerated, false, 1))
+	dev.set(bv3, propStatusFlags, appendBitString(nil, false, false, true, false))
+
+	instance := uint32(1234)
+	plugin := &BACnet{
+		LocalAddress:         "127.0.0.1:0",
+		BroadcastAddress:     dev.addr().String(),
+		Timeout:              config.Duration(time.Second),
+		MaxObjectsPerRequest: 2,
+		COVLifetime:          config.Duration(time.Minute),
+		Devices: []deviceDefinition{
+			{
+				Instance:    &instance,
+				Measurement: "hvac",
+				Discover:    true,
+				Objects: []objectDefinition{
+					{Type: "binary-value", Instance: 3, Name: "pump_running", COV: true},
+				},
+			},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+
+	// Wait for the device to be located
+	require.Eventually(t, func() bool {
+		plugin.Lock()
+		defer plugin.Unlock()
+		return plugin.devices[0].addr != nil
+	}, 3*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, plugin.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 3
+	}, 3*time.Second, 10*time.Millisecond)
+
+	address := dev.addr().String()
+	expected := []telegraf.Metric{
+		metric.New(
+			"hvac",
+			map[string]string{
+				"address":         address,
+				"device_instance": "1234",
+				"object_type":     "analog-input",
+				"object_instance": "1",
+				"object_name":     "supply_temperature",
+			},
+			map[string]interface{}{"present_value": float64(45)},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"hvac",
+			map[string]string{
+				"address":         address,
+				"device_instance": "1234",
+				"object_type":     "analog-input",
+				"object_instance": "2",
+				"object_name":     "return_temperature",
+			},
+			map[string]interface{}{"present_value": float64(30)},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"hvac",
+			map[string]string{
+				"address":         address,
+				"device_instance": "1234",
+				"object_type":     "binary-value",
+				"object_instance": "3",
+				"object_name":     "pump_running",
+			},
+			map[string]interface{}{
+				"present_value":  uint64(1),
+				"in_alarm":       false,
+				"fault":          false,
+				"overridden":     true,
+				"out_of_service": false,
+			},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime(), testutil.SortMetrics())
+
+	// The subscription is not renewed before half of the lifetime passed
+	acc.ClearMetrics()
+	require.NoError(t, plugin.Gather(&acc))
+	require.Equal(t, []subscription{{processID: 1, object: bv3, lifetime: 60}}, dev.subscriptions())
+
+	// Subscriptions are cancelled when stopping
+	plugin.Stop()
+	require.Equal(t, []subscription{
+		{processID: 1, object: bv3, lifetime: 60},
+		{processID: 1, object: bv3, cancel: true},
+	}, dev.subscriptions())
+}
+
+func TestDeviceNotFound(t *testing.T) {
+	dev := newFakeDevice(t, 1234)
+
+	instance := uint32(42)
+	plugin := &BACnet{
+		LocalAddress:         "127.0.0.1:0",
+		BroadcastAddress:     dev.addr().String(),
+		Timeout:              config.Duration(100 * time.Millisecond),
+		MaxObjectsPerRequest: 20,
+		Devices: []deviceDefinition{
+			{
+				Instance: &instance,
+				Objects:  []objectDefinition{{Type: "analog-input", Instance: 1}},
+			},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	require.NoError(t, plugin.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.ErrorContains(t, acc.Errors[0], "device instance 42: device not found")
+}
+
+// Helpers for encoding application values
+func appendReal(buf []byte, v float32) []byte {
+	buf = appendTag(buf, tagReal, false, 4)
+	return binary.BigEndian.AppendUint32(buf, math.Float32bits(v))
+}
+
+func appendCharacterString(buf []byte, v string) []byte {
+	buf = appendTag(buf, tagCharacterString, false, len(v)+1)
+	return append(append(buf, 0), v...)
+}
+
+func appendBitString(buf []byte, bits ...bool) []byte {
+	data := make([]byte, 1+(len(bits)+7)/8)
+	data[0] = byte(len(data)*8 - 8 - len(bits))
+	for i, b := range bits {
+		if b {
+			data[1+i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	buf = appendTag(buf, tagBitString, false, len(data))
+	return append(buf, data...)
+}
+
+type subscription struct {
+	processID uint32
+	object    objectIdentifier
+	lifetime  uint32
+	cancel    bool
+}
+
+// fakeDevice simulates a BACnet/IP device
+type fakeDevice struct {
+	conn       *net.UDPConn
+	instance   uint32
+	objectList []objectIdentifier
+	properties map[objectIdentifier]map[uint32][]byte
+	subscribed []subscription
+	sync.Mutex
+}
+
+func newFakeDevice(t *testing.T, instance uint32) *fakeDevice {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	dev := &fakeDevice{
+		conn:       conn,
+		instance:   instance,
+		properties: make(map[objectIdentifier]map[uint32][]byte),
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if err := dev.handle(addr, buf[:n]); err != nil {
+				t.Logf("handling request failed: %v", err)
+			}
+		}
+	}()
+	t.Cleanup(func() {
+		conn.Close()
+		wg.Wait()
+	})
+
+	return dev
+}
+
+func (f *fakeDevice) addr() *net.UDPAddr {
+	return f.conn.LocalAddr().(*net.UDPAddr)
+}
+
+func (f *fakeDevice) set(object objectIdentifier, property uint32, value []byte) {
+	f.Lock()
+	defer f.Unlock()
+	if _, found := f.properties[object]; !found {
+		f.properties[object] = make(map[uint32][]byte)
+	}
+	f.properties[object][property] = value
+}
+
+func (f *fakeDevice) subscriptions() []subscription {
+	f.Lock()
+	defer f.Unlock()
+	return append([]subscription(nil), f.subscribed...)
+}
+
+func (f *fakeDevice) value(object objectIdentifier, ref propertyReference) []byte {
+	f.Lock()
+	defer f.Unlock()
+
+	if object.typ == objectTypeDevice && ref.id == propObjectList {
+		switch ref.index {
+		case arrayAll:
+		case 0:
+			return appendUnsigned(nil, tagUnsigned, false, uint32(len(f.objectList)))
+		default:
+			if int(ref.index) <= len(f.objectList) {
+				return appendObjectIdentifier(nil, tagObjectID, false, f.objectList[ref.index-1])
+			}
+		}
+		return nil
+	}
+	return f.properties[object][ref.id]
+}
+
+func (f *fakeDevice) send(addr *net.UDPAddr, apdu []byte) error {
+	_, err := f.conn.WriteToUDP(encodeFrame(false, false, apdu), addr)
+	return err
+}
+
+func (f *fakeDevice) handle(addr *net.UDPAddr, frame []byte) error {
+	apdu, _, err := decodeFrame(frame)
+	if err != nil {
+		return err
+	}
+
+	if apdu[0] == pduUnconfirmedRequest {
+		if apdu[1] != serviceWhoIs {
+			return nil
+		}
+		d := &decoder{buf: apdu[2:]}
+		low, err := d.contextUnsigned(0)
+		if err != nil {
+			return err
+		}
+		high, err := d.contextUnsigned(1)
+		if err != nil {
+			return err
+		}
+		if f.instance < low || f.instance > high {
+			return nil
+		}
+		var payload []byte
+		payload = appendObjectIdentifier(payload, tagObjectID, false, objectIdentifier{typ: objectTypeDevice, instance: f.instance})
+		payload = appendUnsigned(payload, tagUnsigned, false, 1476)
+		payload = appendUnsigned(payload, tagEnumerated, false, 3)
+		payload = appendUnsigned(payload, tagUnsigned, false, 999)
+		return f.send(addr, encodeUnconfirmedRequest(serviceIAm, payload))
+	}
+	if apdu[0] != pduConfirmedRequest || len(apdu) < 4 {
+		return errors.New("unexpected APDU")
+	}
+
+	invokeID, service := apdu[2], apdu[3]
+	d := &decoder{buf: apdu[4:]}
+	ack := []byte{pduComplexAck, invokeID, service}
+	switch service {
+	case serviceReadProperty:
+		object, err := d.contextObjectIdentifier(0)
+		if err != nil {
+			return err
+		}
+		ref := propertyReference{index: arrayAll}
+		if ref.id, err = d.contextUnsigned(1); err != nil {
+			return err
+		}
+		if d.isContext(2) {
+			if ref.index, err = d.contextUnsigned(2); err != nil {
+				return err
+			}
+		}
+		value := f.value(object, ref)
+		if value == nil {
+			return f.send(addr, []byte{pduError, invokeID, service, 0x91, 0x01, 0x91, 0x20})
+		}
+		ack = appendObjectIdentifier(ack, 0, true, object)
+		ack = appendUnsigned(ack, 1, true, ref.id)
+		if ref.index != arrayAll {
+			ack = appendUnsigned(ack, 2, true, ref.index)
+		}
+		ack = appendOpeningTag(ack, 3)
+		ack = append(ack, value...)
+		ack = appendClosingTag(ack, 3)
+		return f.send(addr, ack)
+	case serviceReadPropertyMultiple:
+		for !d.done() {
+			object, err := d.contextObjectIdentifier(0)
+			if err != nil {
+				return err
+			}
+			if err := d.opening(1); err != nil {
+				return err
+			}
+			ack = appendObjectIdentifier(ack, 0, true, object)
+			ack = appendOpeningTag(ack, 1)
+			for !d.isClosing(1) {
+				ref := propertyReference{index: arrayAll}
+				if ref.id, err = d.contextUnsigned(0); err != nil {
+					return err
+				}
+				if d.isContext(1) {
+					if ref.index, err = d.contextUnsigned(1); err != nil {
+						return err
+					}
+				}
+				ack = appendUnsigned(ack, 2, true, ref.id)
+				if ref.index != arrayAll {
+					ack = appendUnsigned(ack, 3, true, ref.index)
+				}
+				if value := f.value(object, ref); value != nil {
+					ack = appendOpeningTag(ack, 4)
+					ack = append(ack, value...)
+					ack = appendClosingTag(ack, 4)
+				} else {
+					ack = appendOpeningTag(ack, 5)
+					ack = appendUnsigned(ack, tagEnumerated, false, 1)
+					ack = appendUnsigned(ack, tagEnumerated, false, 31)
+					ack = appendClosingTag(ack, 5)
+				}
+			}
+			if err := d.closing(1); err != nil {
+				return err
+			}
+			ack = appendClosingTag(ack, 1)
+		}
+		return f.send(addr, ack)
+	case serviceSubscribeCOV:
+		var s subscription
+		if s.processID, err = d.contextUnsigned(0); err != nil {
+			return err
+		}
+		if s.object, err = d.contextObjectIdentifier(1); err != nil {
+			return err
+		}
+		s.cancel = d.done()
+		if !s.cancel {
+			if _, err := d.contextUnsigned(2); err != nil {
+				return err
+			}
+			if s.lifetime, err = d.contextUnsigned(3); err != nil {
+				return err
+			}
+		}
+		f.Lock()
+		f.subscribed = append(f.subscribed, s)
+		f.Unlock()
+		if err := f.send(addr, []byte{pduSimpleAck, invokeID, service}); err != nil || s.cancel {
+			return err
+		}
+
+		// Send the initial notification
+		var payload []byte
+		payload = appendUnsigned(payload, 0, true, s.processID)
+		payload = appendObjectIdentifier(payload, 1, true, objectIdentifier{typ: objectTypeDevice, instance: f.instance})
+		payload = appendObjectIdentifier(payload, 2, true, s.object)
+		payload = appendUnsigned(payload, 3, true, s.lifetime)
+		payload = appendOpeningTag(payload, 4)
+		for _, property := range []uint32{propPresentValue, propStatusFlags} {
+			payload = appendUnsigned(payload, 0, true, property)
+			payload = appendOpeningTag(payload, 2)
+			payload = append(payload, f.value(s.object, propertyReference{id: property, index: arrayAll})...)
+			payload = appendClosingTag(payload, 2)
+		}
+		payload = appendClosingTag(payload, 4)
+		return f.send(addr, encodeUnconfirmedRequest(serviceUnconfirmedCOVNotification, payload))
+	}
+
+	return f.send(addr, []byte{pduReject, invokeID, 0x09})
+}
@@ -0,0 +1,196 @@
+package bacnet
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// client sends BACnet/IP requests and dispatches the received responses and
+// unconfirmed requests
+type client struct {
+	conn      *net.UDPConn
+	broadcast *net.UDPAddr
+	timeout   time.Duration
+	retries   int
+	log       telegraf.Logger
+
+	onIAm func(objectIdentifier, *net.UDPAddr)
+	onCOV func(*net.UDPAddr, *covNotification)
+
+	invokeID byte
+	pending  map[string]chan []byte
+	wg       sync.WaitGroup
+	sync.Mutex
+}
+
+func pendingKey(addr *net.UDPAddr, invokeID byte) string {
+	return addr.String() + "/" + strconv.Itoa(int(invokeID))
+}
+
+func (c *client) start() {
+	c.pending = make(map[string]chan []byte)
+
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.receive()
+	}()
+}
+
+func (c *client) close() {
+	c.conn.Close()
+	c.wg.Wait()
+}
+
+func (c *client) receive() {
+	buf := make([]byte, 1500)
+	for {
+		n, addr, err := c.conn.ReadFromUDP(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				c.log.Errorf("Receiving failed: %v", err)
+			}
+			return
+		}
+		c.handle(addr, append([]byte(nil), buf[:n]...))
+	}
+}
+
+func (c *client) handle(addr *net.UDPAddr, frame []byte) {
+	apdu, source, err := decodeFrame(frame)
+	if err != nil {
+		c.log.Debugf("Ignoring invalid frame from %s: %v", addr, err)
+		return
+	}
+	if len(apdu) < 2 {
+		return
+	}
+	if source != nil {
+		addr = source
+	}
+
+	switch apdu[0] & 0xF0 {
+	case pduUnconfirmedRequest:
+		switch apdu[1] {
+		case serviceIAm:
+			id, err := decodeIAm(apdu[2:])
+			if err != nil {
+				c.log.Debugf("Ignoring invalid I-Am from %s: %v", addr, err)
+				return
+			}
+			c.onIAm(id, addr)
+		case serviceUnconfirmedCOVNotification:
+			n, err := decodeCOVNotification(apdu[2:])
+			if err != nil {
+				c.log.Errorf("Decoding COV notification from %s failed: %v", addr, err)
+				return
+			}
+			c.onCOV(addr, n)
+		}
+	case pduConfirmedRequest:
+		// Only confirmed COV notifications are handled which are not
+		// requested by the plugin but acknowledged for robustness
+		if apdu[0]&0x08 != 0 || len(apdu) < 4 || apdu[3] != serviceConfirmedCOVNotification {
+			return
+		}
+		n, err := decodeCOVNotification(apdu[4:])
+		if err != nil {
+			c.log.Errorf("Decoding COV notification from %s failed: %v", addr, err)
+			return
+		}
+		ack := encodeFrame(false, false, []byte{pduSimpleAck, apdu[2], apdu[3]})
+		if _, err := c.conn.WriteToUDP(ack, addr); err != nil {
+			c.log.Errorf("Acknowledging COV notification to %s failed: %v", addr, err)
+		}
+		c.onCOV(addr, n)
+	case pduSimpleAck, pduComplexAck, pduError, pduReject, pduAbort:
+		c.Lock()
+		ch, found := c.pending[pendingKey(addr, apdu[1])]
+		c.Unlock()
+		if !found {
+			c.log.Debugf("Ignoring unexpected response from %s", addr)
+			return
+		}
+		select {
+		case ch <- apdu:
+		default:
+		}
+	}
+}
+
+// request sends the confirmed request to the given device and returns the
+// data of the acknowledgement
+func (c *client) request(addr *net.UDPAddr, service byte, payload []byte) ([]byte, error) {
+	ch := make(chan []byte, 1)
+	c.Lock()
+	invokeID := c.invokeID
+	c.invokeID++
+	key := pendingKey(addr, invokeID)
+	c.pending[key] = ch
+	c.Unlock()
+
+	defer func() {
+		c.Lock()
+		delete(c.pending, key)
+		c.Unlock()
+	}()
+
+	frame := encodeFrame(false, true, encodeConfirmedRequest(invokeID, service, payload))
+	for range c.retries + 1 {
+		if _, err := c.conn.WriteToUDP(frame, addr); err != nil {
+			return nil, err
+		}
+
+		select {
+		case apdu := <-ch:
+			switch apdu[0] & 0xF0 {
+			case pduSimpleAck:
+				return nil, nil
+			case pduComplexAck:
+				if apdu[0]&0x08 != 0 {
+					return nil, errors.New("segmented responses are not supported")
+				}
+				if len(apdu) < 3 || apdu[2] != service {
+					return nil, errors.New("invalid acknowledgement")
+				}
+				return apdu[3:], nil
+			}
+			return nil, decodeError(apdu)
+		case <-time.After(c.timeout):
+		}
+	}
+	return nil, fmt.Errorf("request to %s timed out", addr)
+}
+
+func (c *client) readProperty(addr *net.UDPAddr, object objectIdentifier, ref propertyReference) ([]interface{}, error) {
+	resp, err := c.request(addr, serviceReadProperty, encodeReadProperty(object, ref))
+	if err != nil {
+		return nil, err
+	}
+	return decodeReadPropertyAck(resp)
+}
+
+func (c *client) readPropertyMultiple(addr *net.UDPAddr, specs []readAccessSpec) ([]readAccessResult, error) {
+	resp, err := c.request(addr, serviceReadPropertyMultiple, encodeReadPropertyMultiple(specs))
+	if err != nil {
+		return nil, err
+	}
+	return decodeReadPropertyMultipleAck(resp)
+}
+
+func (c *client) subscribeCOV(addr *net.UDPAddr, processID uint32, object objectIdentifier, lifetime uint32, cancel bool) error {
+	_, err := c.request(addr, serviceSubscribeCOV, encodeSubscribeCOV(processID, object, lifetime, cancel))
+	return err
+}
+
+// whoIs broadcasts a request for the address of the given device
+func (c *client) whoIs(instance uint32) error {
+	_, err := c.conn.WriteToUDP(encodeFrame(true, false, encodeWhoIs(instance)), c.broadcast)
+	return err
+}
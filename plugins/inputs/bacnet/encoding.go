@@ -0,0 +1,408 @@
+package bacnet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Application tag numbers
+const (
+	tagNull            byte = 0
+	tagBoolean         byte = 1
+	tagUnsigned        byte = 2
+	tagSigned          byte = 3
+	tagReal            byte = 4
+	tagDouble          byte = 5
+	tagOctetString     byte = 6
+	tagCharacterString byte = 7
+	tagBitString       byte = 8
+	tagEnumerated      byte = 9
+	tagDate            byte = 10
+	tagTime            byte = 11
+	tagObjectID        byte = 12
+)
+
+// Maximum instance number of an object, also used as wildcard for the
+// device object
+const wildcardInstance = 0x3FFFFF
+
+type objectIdentifier struct {
+	typ      uint16
+	instance uint32
+}
+
+func (o objectIdentifier) encode() uint32 {
+	return uint32(o.typ)<<22 | o.instance&wildcardInstance
+}
+
+func (o objectIdentifier) String() string {
+	return objectTypeName(o.typ) + ":" + strconv.FormatUint(uint64(o.instance), 10)
+}
+
+func decodeObjectIdentifier(v uint32) objectIdentifier {
+	return objectIdentifier{typ: uint16(v >> 22), instance: v & wildcardInstance}
+}
+
+// tag is the header of an encoded primitive or constructed value
+type tag struct {
+	number  byte
+	context bool
+	opening bool
+	closing bool
+	length  int
+}
+
+func appendTag(buf []byte, number byte, context bool, length int) []byte {
+	var header byte
+	var extra []byte
+	if context {
+		header |= 0x08
+	}
+	if number < 15 {
+		header |= number << 4
+	} else {
+		header |= 0xF0
+		extra = append(extra, number)
+	}
+	switch {
+	case length < 5:
+		header |= byte(length)
+	case length < 254:
+		header |= 5
+		extra = append(extra, byte(length))
+	case length < 65536:
+		header |= 5
+		extra = append(extra, 254)
+		extra = binary.BigEndian.AppendUint16(extra, uint16(length))
+	default:
+		header |= 5
+		extra = append(extra, 255)
+		extra = binary.BigEndian.AppendUint32(extra, uint32(length))
+	}
+	return append(append(buf, header), extra...)
+}
+
+func appendOpeningTag(buf []byte, number byte) []byte {
+	return append(buf, number<<4|0x0E)
+}
+
+func appendClosingTag(buf []byte, number byte) []byte {
+	return append(buf, number<<4|0x0F)
+}
+
+// unsignedBytes returns the minimal big-endian encoding of the value
+func unsignedBytes(v uint32) []byte {
+	switch {
+	case v < 1<<8:
+		return []byte{byte(v)}
+	case v < 1<<16:
+		return binary.BigEndian.AppendUint16(nil, uint16(v))
+	case v < 1<<24:
+		return []byte{byte(v >> 16), byte(v >> 8), byte(v)}
+	}
+	return binary.BigEndian.AppendUint32(nil, v)
+}
+
+func appendUnsigned(buf []byte, number byte, context bool, v uint32) []byte {
+	data := unsignedBytes(v)
+	buf = appendTag(buf, number, context, len(data))
+	return append(buf, data...)
+}
+
+func appendContextBoolean(buf []byte, number byte, v bool) []byte {
+	buf = appendTag(buf, number, true, 1)
+	if v {
+		return append(buf, 1)
+	}
+	return append(buf, 0)
+}
+
+func appendObjectIdentifier(buf []byte, number byte, context bool, o objectIdentifier) []byte {
+	buf = appendTag(buf, number, context, 4)
+	return binary.BigEndian.AppendUint32(buf, o.encode())
+}
+
+// decoder sequentially decodes tagged values of a service request or response
+type decoder struct {
+	buf []byte
+	pos int
+}
+
+func (d *decoder) done() bool {
+	return d.pos >= len(d.buf)
+}
+
+// peek decodes the tag at the current position without consuming it and
+// returns the size of the tag header
+func (d *decoder) peek() (tag, int, error) {
+	buf := d.buf[d.pos:]
+	if len(buf) == 0 {
+		return tag{}, 0, errors.New("unexpected end of data")
+	}
+
+	t := tag{
+		number:  buf[0] >> 4,
+		context: buf[0]&0x08 != 0,
+	}
+	n := 1
+	if t.number == 15 {
+		if len(buf) < 2 {
+			return tag{}, 0, errors.New("truncated tag")
+		}
+		t.number = buf[1]
+		n++
+	}
+
+	switch lvt := buf[0] & 0x07; {
+	case t.context && lvt == 6:
+		t.opening = true
+	case t.context && lvt == 7:
+		t.closing = true
+	case lvt == 5:
+		if len(buf) < n+1 {
+			return tag{}, 0, errors.New("truncated tag length")
+		}
+		switch buf[n] {
+		case 254:
+			if len(buf) < n+3 {
+				return tag{}, 0, errors.New("truncated tag length")
+			}
+			t.length = int(binary.BigEndian.Uint16(buf[n+1:]))
+			n += 3
+		case 255:
+			if len(buf) < n+5 {
+				return tag{}, 0, errors.New("truncated tag length")
+			}
+			t.length = int(binary.BigEndian.Uint32(buf[n+1:]))
+			n += 5
+		default:
+			t.length = int(buf[n])
+			n++
+		}
+	default:
+		t.length = int(lvt)
+	}
+	return t, n, nil
+}
+
+// next decodes the tag at the current position and returns the tag along
+// with its data
+func (d *decoder) next() (tag, []byte, error) {
+	t, n, err := d.peek()
+	if err != nil {
+		return tag{}, nil, err
+	}
+	d.pos += n
+
+	// Opening and closing tags as well as application booleans do not carry
+	// any data
+	if t.opening || t.closing || (!t.context && t.number == tagBoolean) {
+		return t, nil, nil
+	}
+	if t.length > len(d.buf)-d.pos {
+		return tag{}, nil, fmt.Errorf("tag length %d exceeds data", t.length)
+	}
+	data := d.buf[d.pos : d.pos+t.length]
+	d.pos += t.length
+	return t, data, nil
+}
+
+// isContext checks if the next tag is the given context tag
+func (d *decoder) isContext(number byte) bool {
+	if d.done() {
+		return false
+	}
+	t, _, err := d.peek()
+	return err == nil && t.context && !t.opening && !t.closing && t.number == number
+}
+
+func (d *decoder) isOpening(number byte) bool {
+	if d.done() {
+		return false
+	}
+	t, _, err := d.peek()
+	return err == nil && t.opening && t.number == number
+}
+
+func (d *decoder) isClosing(number byte) bool {
+	if d.done() {
+		return false
+	}
+	t, _, err := d.peek()
+	return err == nil && t.closing && t.number == number
+}
+
+func (d *decoder) opening(number byte) error {
+	t, _, err := d.next()
+	if err != nil {
+		return err
+	}
+	if !t.opening || t.number != number {
+		return fmt.Errorf("expected opening tag %d", number)
+	}
+	return nil
+}
+
+func (d *decoder) closing(number byte) error {
+	t, _, err := d.next()
+	if err != nil {
+		return err
+	}
+	if !t.closing || t.number != number {
+		return fmt.Errorf("expected closing tag %d", number)
+	}
+	return nil
+}
+
+func (d *decoder) contextUnsigned(number byte) (uint32, error) {
+	t, data, err := d.next()
+	if err != nil {
+		return 0, err
+	}
+	if !t.context || t.opening || t.closing || t.number != number {
+		return 0, fmt.Errorf("expected context tag %d", number)
+	}
+	if len(data) == 0 || len(data) > 4 {
+		return 0, fmt.Errorf("invalid unsigned length %d", len(data))
+	}
+	return uint32(decodeUnsigned(data)), nil
+}
+
+func (d *decoder) contextObjectIdentifier(number byte) (objectIdentifier, error) {
+	t, data, err := d.next()
+	if err != nil {
+		return objectIdentifier{}, err
+	}
+	if !t.context || t.opening || t.closing || t.number != number || len(data) != 4 {
+		return objectIdentifier{}, fmt.Errorf("expected object identifier with context tag %d", number)
+	}
+	return decodeObjectIdentifier(binary.BigEndian.Uint32(data)), nil
+}
+
+func (d *decoder) applicationValue() (interface{}, error) {
+	t, data, err := d.next()
+	if err != nil {
+		return nil, err
+	}
+	if t.context {
+		return nil, fmt.Errorf("expected application tag but got context tag %d", t.number)
+	}
+	return decodeApplicationValue(t, data)
+}
+
+// values decodes all application-tagged values up to the closing tag with
+// the given number. Values nested in constructed data are skipped.
+func (d *decoder) values(number byte) ([]interface{}, error) {
+	var values []interface{}
+	depth := 0
+	for {
+		t, data, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case t.opening:
+			depth++
+		case t.closing && depth == 0:
+			if t.number != number {
+				return nil, fmt.Errorf("expected closing tag %d", number)
+			}
+			return values, nil
+		case t.closing:
+			depth--
+		case depth == 0 && !t.context:
+			v, err := decodeApplicationValue(t, data)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+	}
+}
+
+func decodeUnsigned(data []byte) uint64 {
+	var v uint64
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func decodeSigned(data []byte) int64 {
+	if len(data) == 0 {
+		return 0
+	}
+	v := int64(int8(data[0]))
+	for _, b := range data[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v
+}
+
+// decodeApplicationValue converts the application-tagged data to a value
+// usable as field. Unsupported types such as dates and times are returned
+// as nil.
+func decodeApplicationValue(t tag, data []byte) (interface{}, error) {
+	switch t.number {
+	case tagNull:
+		return nil, nil
+	case tagBoolean:
+		return t.length != 0, nil
+	case tagUnsigned, tagEnumerated:
+		if len(data) == 0 || len(data) > 8 {
+			return nil, fmt.Errorf("invalid unsigned length %d", len(data))
+		}
+		return decodeUnsigned(data), nil
+	case tagSigned:
+		if len(data) == 0 || len(data) > 8 {
+			return nil, fmt.Errorf("invalid signed length %d", len(data))
+		}
+		return decodeSigned(data), nil
+	case tagReal:
+		if len(data) != 4 {
+			return nil, fmt.Errorf("invalid real length %d", len(data))
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data))), nil
+	case tagDouble:
+		if len(data) != 8 {
+			return nil, fmt.Errorf("invalid double length %d", len(data))
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(data)), nil
+	case tagOctetString:
+		return fmt.Sprintf("%x", data), nil
+	case tagCharacterString:
+		if len(data) == 0 {
+			return nil, errors.New("missing character set")
+		}
+		// Only UTF-8 (and its ASCII subset) is supported
+		if data[0] != 0 {
+			return nil, fmt.Errorf("unsupported character set %d", data[0])
+		}
+		return string(data[1:]), nil
+	case tagBitString:
+		if len(data) == 0 {
+			return nil, errors.New("missing unused bits")
+		}
+		n := 8*(len(data)-1) - int(data[0])
+		if n < 0 {
+			return nil, errors.New("invalid bit string")
+		}
+		bits := make([]bool, n)
+		for i := range bits {
+			bits[i] = data[1+i/8]&(0x80>>(i%8)) != 0
+		}
+		return bits, nil
+	case tagObjectID:
+		if len(data) != 4 {
+			return nil, fmt.Errorf("invalid object identifier length %d", len(data))
+		}
+		return decodeObjectIdentifier(binary.BigEndian.Uint32(data)), nil
+	case tagDate, tagTime:
+		// Dates and times are not supported as field values
+		return nil, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,431 @@
+package bacnet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// BACnet virtual link control functions
+const (
+	bvlcTypeIP            byte = 0x81
+	bvlcForwardedNPDU     byte = 0x04
+	bvlcOriginalUnicast   byte = 0x0A
+	bvlcOriginalBroadcast byte = 0x0B
+)
+
+// APDU types
+const (
+	pduConfirmedRequest   byte = 0x00
+	pduUnconfirmedRequest byte = 0x10
+	pduSimpleAck          byte = 0x20
+	pduComplexAck         byte = 0x30
+	pduError              byte = 0x50
+	pduReject             byte = 0x60
+	pduAbort              byte = 0x70
+)
+
+// Service choices
+const (
+	serviceConfirmedCOVNotification   byte = 1
+	serviceSubscribeCOV               byte = 5
+	serviceReadProperty               byte = 12
+	serviceReadPropertyMultiple       byte = 14
+	serviceIAm                        byte = 0
+	serviceUnconfirmedCOVNotification byte = 2
+	serviceWhoIs                      byte = 8
+)
+
+// Property identifiers
+const (
+	propObjectList   uint32 = 76
+	propObjectName   uint32 = 77
+	propPresentValue uint32 = 85
+	propStatusFlags  uint32 = 111
+)
+
+// Array index denoting the whole property
+const arrayAll = ^uint32(0)
+
+const objectTypeDevice uint16 = 8
+
+// Object types by their name as used in the configuration
+var objectTypes = map[string]uint16{
+	"analog-input":           0,
+	"analog-output":          1,
+	"analog-value":           2,
+	"binary-input":           3,
+	"binary-output":          4,
+	"binary-value":           5,
+	"calendar":               6,
+	"command":                7,
+	"device":                 8,
+	"event-enrollment":       9,
+	"file":                   10,
+	"group":                  11,
+	"loop":                   12,
+	"multi-state-input":      13,
+	"multi-state-output":     14,
+	"notification-class":     15,
+	"program":                16,
+	"schedule":               17,
+	"averaging":              18,
+	"multi-state-value":      19,
+	"trend-log":              20,
+	"accumulator":            23,
+	"pulse-converter":        24,
+	"characterstring-value":  40,
+	"integer-value":          45,
+	"large-analog-value":     46,
+	"positive-integer-value": 48,
+}
+
+var objectTypeNames = func() map[uint16]string {
+	names := make(map[uint16]string, len(objectTypes))
+	for name, typ := range objectTypes {
+		names[typ] = name
+	}
+	return names
+}()
+
+func objectTypeName(typ uint16) string {
+	if name, found := objectTypeNames[typ]; found {
+		return name
+	}
+	return strconv.FormatUint(uint64(typ), 10)
+}
+
+// encodeFrame wraps the APDU into the network and virtual link layers
+func encodeFrame(broadcast, expectReply bool, apdu []byte) []byte {
+	function := bvlcOriginalUnicast
+	if broadcast {
+		function = bvlcOriginalBroadcast
+	}
+	control := byte(0x00)
+	if expectReply {
+		control = 0x04
+	}
+
+	frame := make([]byte, 0, 6+len(apdu))
+	frame = append(frame, bvlcTypeIP, function)
+	frame = binary.BigEndian.AppendUint16(frame, uint16(6+len(apdu)))
+	frame = append(frame, 0x01, control)
+	return append(frame, apdu...)
+}
+
+// decodeFrame extracts the APDU from the virtual link and network layers.
+// For forwarded messages the address of the original sender is returned.
+// Frames not containing an APDU, e.g. network layer messages, result in
+// an empty APDU.
+func decodeFrame(buf []byte) ([]byte, *net.UDPAddr, error) {
+	if len(buf) < 4 || buf[0] != bvlcTypeIP {
+		return nil, nil, errors.New("not a BACnet/IP frame")
+	}
+	if int(binary.BigEndian.Uint16(buf[2:])) != len(buf) {
+		return nil, nil, errors.New("length mismatch")
+	}
+
+	var npdu []byte
+	var source *net.UDPAddr
+	switch buf[1] {
+	case bvlcOriginalUnicast, bvlcOriginalBroadcast:
+		npdu = buf[4:]
+	case bvlcForwardedNPDU:
+		if len(buf) < 10 {
+			return nil, nil, errors.New("truncated forwarded frame")
+		}
+		source = &net.UDPAddr{
+			IP:   net.IPv4(buf[4], buf[5], buf[6], buf[7]),
+			Port: int(binary.BigEndian.Uint16(buf[8:])),
+		}
+		npdu = buf[10:]
+	default:
+		return nil, nil, nil
+	}
+
+	if len(npdu) < 2 || npdu[0] != 0x01 {
+		return nil, nil, errors.New("invalid network layer header")
+	}
+	control := npdu[1]
+	pos := 2
+	// Skip the destination and source specifiers of routed messages
+	if control&0x20 != 0 {
+		if len(npdu) < pos+3 {
+			return nil, nil, errors.New("truncated destination specifier")
+		}
+		pos += 3 + int(npdu[pos+2])
+	}
+	if control&0x08 != 0 {
+		if len(npdu) < pos+3 {
+			return nil, nil, errors.New("truncated source specifier")
+		}
+		pos += 3 + int(npdu[pos+2])
+	}
+	if control&0x20 != 0 {
+		pos++ // hop count
+	}
+	if pos > len(npdu) {
+		return nil, nil, errors.New("truncated network layer header")
+	}
+	if control&0x80 != 0 {
+		return nil, source, nil
+	}
+	return npdu[pos:], source, nil
+}
+
+func encodeConfirmedRequest(invokeID, service byte, payload []byte) []byte {
+	// Segmentation is not supported and the maximum APDU size is 1476 bytes
+	apdu := []byte{pduConfirmedRequest, 0x05, invokeID, service}
+	return append(apdu, payload...)
+}
+
+func encodeUnconfirmedRequest(service byte, payload []byte) []byte {
+	return append([]byte{pduUnconfirmedRequest, service}, payload...)
+}
+
+// decodeError returns the error contained in a error, reject or abort APDU
+func decodeError(apdu []byte) error {
+	switch apdu[0] & 0xF0 {
+	case pduError:
+		if len(apdu) < 3 {
+			return errors.New("truncated error")
+		}
+		d := &decoder{buf: apdu[3:]}
+		// Some services wrap the error into a context tag
+		if d.isOpening(0) {
+			if err := d.opening(0); err != nil {
+				return err
+			}
+		}
+		class, err := d.applicationValue()
+		if err != nil {
+			return fmt.Errorf("decoding error class failed: %w", err)
+		}
+		code, err := d.applicationValue()
+		if err != nil {
+			return fmt.Errorf("decoding error code failed: %w", err)
+		}
+		return fmt.Errorf("error class %v, code %v", class, code)
+	case pduReject:
+		if len(apdu) < 3 {
+			return errors.New("truncated reject")
+		}
+		return fmt.Errorf("request rejected with reason %d", apdu[2])
+	case pduAbort:
+		if len(apdu) < 3 {
+			return errors.New("truncated abort")
+		}
+		return fmt.Errorf("request aborted with reason %d", apdu[2])
+	}
+	return fmt.Errorf("unexpected APDU type 0x%02x", apdu[0])
+}
+
+func encodeWhoIs(instance uint32) []byte {
+	var payload []byte
+	payload = appendUnsigned(payload, 0, true, instance)
+	payload = appendUnsigned(payload, 1, true, instance)
+	return encodeUnconfirmedRequest(serviceWhoIs, payload)
+}
+
+func decodeIAm(buf []byte) (objectIdentifier, error) {
+	d := &decoder{buf: buf}
+	v, err := d.applicationValue()
+	if err != nil {
+		return objectIdentifier{}, err
+	}
+	id, ok := v.(objectIdentifier)
+	if !ok || id.typ != objectTypeDevice {
+		return objectIdentifier{}, errors.New("expected device identifier")
+	}
+	return id, nil
+}
+
+// propertyReference denotes a property and optionally an element of an
+// array property to read
+type propertyReference struct {
+	id    uint32
+	index uint32
+}
+
+func encodeReadProperty(object objectIdentifier, ref propertyReference) []byte {
+	var payload []byte
+	payload = appendObjectIdentifier(payload, 0, true, object)
+	payload = appendUnsigned(payload, 1, true, ref.id)
+	if ref.index != arrayAll {
+		payload = appendUnsigned(payload, 2, true, ref.index)
+	}
+	return payload
+}
+
+func decodeReadPropertyAck(buf []byte) ([]interface{}, error) {
+	d := &decoder{buf: buf}
+	if _, err := d.contextObjectIdentifier(0); err != nil {
+		return nil, err
+	}
+	if _, err := d.contextUnsigned(1); err != nil {
+		return nil, err
+	}
+	if d.isContext(2) {
+		if _, err := d.contextUnsigned(2); err != nil {
+			return nil, err
+		}
+	}
+	if err := d.opening(3); err != nil {
+		return nil, err
+	}
+	return d.values(3)
+}
+
+// readAccessSpec denotes the properties of an object to read
+type readAccessSpec struct {
+	object     objectIdentifier
+	properties []propertyReference
+}
+
+// readAccessResult is the result of reading a property of an object
+type readAccessResult struct {
+	object   objectIdentifier
+	property propertyReference
+	values   []interface{}
+	err      error
+}
+
+func encodeReadPropertyMultiple(specs []readAccessSpec) []byte {
+	var payload []byte
+	for _, spec := range specs {
+		payload = appendObjectIdentifier(payload, 0, true, spec.object)
+		payload = appendOpeningTag(payload, 1)
+		for _, ref := range spec.properties {
+			payload = appendUnsigned(payload, 0, true, ref.id)
+			if ref.index != arrayAll {
+				payload = appendUnsigned(payload, 1, true, ref.index)
+			}
+		}
+		payload = appendClosingTag(payload, 1)
+	}
+	return payload
+}
+
+func decodeReadPropertyMultipleAck(buf []byte) ([]readAccessResult, error) {
+	var results []readAccessResult
+
+	d := &decoder{buf: buf}
+	for !d.done() {
+		object, err := d.contextObjectIdentifier(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := d.opening(1); err != nil {
+			return nil, err
+		}
+		for !d.isClosing(1) {
+			result := readAccessResult{object: object, property: propertyReference{index: arrayAll}}
+			if result.property.id, err = d.contextUnsigned(2); err != nil {
+				return nil, err
+			}
+			if d.isContext(3) {
+				if result.property.index, err = d.contextUnsigned(3); err != nil {
+					return nil, err
+				}
+			}
+
+			switch {
+			case d.isOpening(4):
+				if err := d.opening(4); err != nil {
+					return nil, err
+				}
+				if result.values, err = d.values(4); err != nil {
+					return nil, err
+				}
+			case d.isOpening(5):
+				if err := d.opening(5); err != nil {
+					return nil, err
+				}
+				values, err := d.values(5)
+				if err != nil {
+					return nil, err
+				}
+				result.err = fmt.Errorf("error %v", values)
+			default:
+				return nil, errors.New("missing property value or error")
+			}
+			results = append(results, result)
+		}
+		if err := d.closing(1); err != nil {
+			return nil, err
+		}
+	}
+
+	return results, nil
+}
+
+// encodeSubscribeCOV creates a subscription for unconfirmed notifications
+// with the given lifetime in seconds or cancels the subscription
+func encodeSubscribeCOV(processID uint32, object objectIdentifier, lifetime uint32, cancel bool) []byte {
+	var payload []byte
+	payload = appendUnsigned(payload, 0, true, processID)
+	payload = appendObjectIdentifier(payload, 1, true, object)
+	if !cancel {
+		payload = appendContextBoolean(payload, 2, false)
+		payload = appendUnsigned(payload, 3, true, lifetime)
+	}
+	return payload
+}
+
+// covNotification contains the values of a change-of-value notification
+type covNotification struct {
+	processID uint32
+	device    objectIdentifier
+	object    objectIdentifier
+	values    map[uint32][]interface{}
+}
+
+func decodeCOVNotification(buf []byte) (*covNotification, error) {
+	var err error
+	n := &covNotification{values: make(map[uint32][]interface{})}
+
+	d := &decoder{buf: buf}
+	if n.processID, err = d.contextUnsigned(0); err != nil {
+		return nil, err
+	}
+	if n.device, err = d.contextObjectIdentifier(1); err != nil {
+		return nil, err
+	}
+	if n.object, err = d.contextObjectIdentifier(2); err != nil {
+		return nil, err
+	}
+	if _, err := d.contextUnsigned(3); err != nil {
+		return nil, err
+	}
+	if err := d.opening(4); err != nil {
+		return nil, err
+	}
+	for !d.isClosing(4) {
+		property, err := d.contextUnsigned(0)
+		if err != nil {
+			return nil, err
+		}
+		if d.isContext(1) {
+			if _, err := d.contextUnsigned(1); err != nil {
+				return nil, err
+			}
+		}
+		if err := d.opening(2); err != nil {
+			return nil, err
+		}
+		values, err := d.values(2)
+		if err != nil {
+			return nil, err
+		}
+		if d.isContext(3) {
+			if _, err := d.contextUnsigned(3); err != nil {
+				return nil, err
+			}
+		}
+		n.values[property] = values
+	}
+
+	return n, d.closing(4)
+}
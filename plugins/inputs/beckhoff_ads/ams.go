@@ -0,0 +1,412 @@
+package beckhoff_ads
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// ADS commands
+const (
+	cmdRead                     = 2
+	cmdAddDeviceNotification    = 6
+	cmdDeleteDeviceNotification = 7
+	cmdDeviceNotification       = 8
+	cmdReadWrite                = 9
+)
+
+// AMS state flags
+const (
+	stateResponse = 0x0001
+	stateCommand  = 0x0004
+)
+
+const amsHeaderLength = 32
+
+// Descriptions of common ADS error codes
+var adsErrors = map[uint32]string{
+	0x006: "target port not found",
+	0x007: "target machine not found",
+	0x701: "service not supported",
+	0x702: "invalid index group",
+	0x703: "invalid index offset",
+	0x704: "reading or writing not permitted",
+	0x705: "parameter size not correct",
+	0x706: "invalid data values",
+	0x707: "device not ready",
+	0x708: "device busy",
+	0x70C: "notification client not registered",
+	0x70D: "no further notification handle",
+	0x710: "symbol not found",
+	0x745: "timeout",
+	0x751: "too many notifications",
+}
+
+type netID [6]byte
+
+func parseNetID(s string) (netID, error) {
+	var id netID
+	parts := strings.Split(s, ".")
+	if len(parts) != 6 {
+		return id, fmt.Errorf("invalid AMS net ID %q", s)
+	}
+	for i, p := range parts {
+		v, err := strconv.ParseUint(p, 10, 8)
+		if err != nil {
+			return id, fmt.Errorf("invalid AMS net ID %q", s)
+		}
+		id[i] = byte(v)
+	}
+	return id, nil
+}
+
+func (id netID) String() string {
+	parts := make([]string, 0, len(id))
+	for _, b := range id {
+		parts = append(parts, strconv.Itoa(int(b)))
+	}
+	return strings.Join(parts, ".")
+}
+
+// amsAddress is the address of an ADS device
+type amsAddress struct {
+	netID netID
+	port  uint16
+}
+
+type amsPacket struct {
+	target   amsAddress
+	source   amsAddress
+	command  uint16
+	flags    uint16
+	errCode  uint32
+	invokeID uint32
+	data     []byte
+}
+
+func adsError(code uint32) error {
+	if msg, found := adsErrors[code]; found {
+		return fmt.Errorf("ADS error 0x%x: %s", code, msg)
+	}
+	return fmt.Errorf("ADS error 0x%x", code)
+}
+
+func (p *amsPacket) encode() []byte {
+	buf := make([]byte, 6+amsHeaderLength, 6+amsHeaderLength+len(p.data))
+	binary.LittleEndian.PutUint32(buf[2:], uint32(amsHeaderLength+len(p.data)))
+	h := buf[6:]
+	copy(h[0:], p.target.netID[:])
+	binary.LittleEndian.PutUint16(h[6:], p.target.port)
+	copy(h[8:], p.source.netID[:])
+	binary.LittleEndian.PutUint16(h[14:], p.source.port)
+	binary.LittleEndian.PutUint16(h[16:], p.command)
+	binary.LittleEndian.PutUint16(h[18:], p.flags)
+	binary.LittleEndian.PutUint32(h[20:], uint32(len(p.data)))
+	binary.LittleEndian.PutUint32(h[24:], p.errCode)
+	binary.LittleEndian.PutUint32(h[28:], p.invokeID)
+	return append(buf, p.data...)
+}
+
+func readPacket(r io.Reader) (*amsPacket, error) {
+	header := make([]byte, 6)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	length := binary.LittleEndian.Uint32(header[2:])
+	if length < amsHeaderLength || length > 16*1024*1024 {
+		return nil, fmt.Errorf("invalid AMS packet length %d", length)
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+
+	p := &amsPacket{
+		command:  binary.LittleEndian.Uint16(buf[16:]),
+		flags:    binary.LittleEndian.Uint16(buf[18:]),
+		errCode:  binary.LittleEndian.Uint32(buf[24:]),
+		invokeID: binary.LittleEndian.Uint32(buf[28:]),
+	}
+	copy(p.target.netID[:], buf[0:6])
+	p.target.port = binary.LittleEndian.Uint16(buf[6:])
+	copy(p.source.netID[:], buf[8:14])
+	p.source.port = binary.LittleEndian.Uint16(buf[14:])
+	if dataLength := binary.LittleEndian.Uint32(buf[20:]); int(dataLength) != len(buf)-amsHeaderLength {
+		return nil, fmt.Errorf("data length %d does not match packet length %d", dataLength, length)
+	}
+	p.data = buf[amsHeaderLength:]
+	return p, nil
+}
+
+// notificationSample is a value reported by a device notification
+type notificationSample struct {
+	handle    uint32
+	timestamp time.Time
+	data      []byte
+}
+
+// client sends ADS requests over AMS/TCP and dispatches the responses and
+// the device notifications
+type client struct {
+	conn           net.Conn
+	target         amsAddress
+	source         amsAddress
+	timeout        time.Duration
+	log            telegraf.Logger
+	onNotification func([]notificationSample)
+
+	invokeID uint32
+	pending  map[uint32]chan *amsPacket
+	err      error
+	done     chan struct{}
+	wg       sync.WaitGroup
+	sync.Mutex
+}
+
+func dial(address string, target, source amsAddress, timeout time.Duration, log telegraf.Logger, onNotification func([]notificationSample)) (*client, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	// Derive the source net ID from the local address as done by the ADS
+	// router if not specified
+	if source.netID == (netID{}) {
+		if addr, ok := conn.LocalAddr().(*net.TCPAddr); ok && addr.IP.To4() != nil {
+			copy(source.netID[:], addr.IP.To4())
+			source.netID[4], source.netID[5] = 1, 1
+		}
+	}
+
+	c := &client{
+		conn:           conn,
+		target:         target,
+		source:         source,
+		timeout:        timeout,
+		log:            log,
+		onNotification: onNotification,
+		pending:        make(map[uint32]chan *amsPacket),
+		done:           make(chan struct{}),
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.receive()
+	}()
+	return c, nil
+}
+
+func (c *client) close() {
+	c.conn.Close()
+	c.wg.Wait()
+}
+
+// closed returns the error terminating the connection if any
+func (c *client) closed() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.err
+}
+
+func (c *client) receive() {
+	defer close(c.done)
+	for {
+		p, err := readPacket(c.conn)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) || errors.Is(err, io.EOF) {
+				err = errors.New("connection closed")
+			}
+			c.Lock()
+			c.err = err
+			c.Unlock()
+			return
+		}
+
+		if p.command == cmdDeviceNotification && p.flags&stateResponse == 0 {
+			samples, err := decodeNotification(p.data)
+			if err != nil {
+				c.log.Errorf("Decoding notification failed: %v", err)
+				continue
+			}
+			c.onNotification(samples)
+			continue
+		}
+
+		c.Lock()
+		ch, found := c.pending[p.invokeID]
+		c.Unlock()
+		if !found {
+			c.log.Debugf("Ignoring unexpected response with invoke ID %d", p.invokeID)
+			continue
+		}
+		select {
+		case ch <- p:
+		default:
+		}
+	}
+}
+
+// request sends the command and returns the data of the response after
+// checking the result code
+func (c *client) request(command uint16, data []byte) ([]byte, error) {
+	ch := make(chan *amsPacket, 1)
+	c.Lock()
+	if c.err != nil {
+		c.Unlock()
+		return nil, c.err
+	}
+	c.invokeID++
+	invokeID := c.invokeID
+	c.pending[invokeID] = ch
+	c.Unlock()
+
+	defer func() {
+		c.Lock()
+		delete(c.pending, invokeID)
+		c.Unlock()
+	}()
+
+	p := &amsPacket{
+		target:   c.target,
+		source:   c.source,
+		command:  command,
+		flags:    stateCommand,
+		invokeID: invokeID,
+		data:     data,
+	}
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := c.conn.Write(p.encode()); err != nil {
+		return nil, err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.errCode != 0 {
+			return nil, adsError(resp.errCode)
+		}
+		if resp.command != command {
+			return nil, fmt.Errorf("unexpected response to command %d", resp.command)
+		}
+		if len(resp.data) < 4 {
+			return nil, errors.New("truncated response")
+		}
+		if result := binary.LittleEndian.Uint32(resp.data); result != 0 {
+			return nil, adsError(result)
+		}
+		return resp.data[4:], nil
+	case <-c.done:
+		return nil, c.closed()
+	case <-time.After(c.timeout):
+		return nil, errors.New("request timed out")
+	}
+}
+
+func (c *client) read(group, offset, length uint32) ([]byte, error) {
+	req := make([]byte, 12)
+	binary.LittleEndian.PutUint32(req[0:], group)
+	binary.LittleEndian.PutUint32(req[4:], offset)
+	binary.LittleEndian.PutUint32(req[8:], length)
+	resp, err := c.request(cmdRead, req)
+	if err != nil {
+		return nil, err
+	}
+	return decodeLengthPrefixed(resp)
+}
+
+func (c *client) readWrite(group, offset, readLength uint32, data []byte) ([]byte, error) {
+	req := make([]byte, 16, 16+len(data))
+	binary.LittleEndian.PutUint32(req[0:], group)
+	binary.LittleEndian.PutUint32(req[4:], offset)
+	binary.LittleEndian.PutUint32(req[8:], readLength)
+	binary.LittleEndian.PutUint32(req[12:], uint32(len(data)))
+	resp, err := c.request(cmdReadWrite, append(req, data...))
+	if err != nil {
+		return nil, err
+	}
+	return decodeLengthPrefixed(resp)
+}
+
+func (c *client) addNotification(group, offset, length, mode uint32, maxDelay, cycleTime time.Duration) (uint32, error) {
+	req := make([]byte, 40)
+	binary.LittleEndian.PutUint32(req[0:], group)
+	binary.LittleEndian.PutUint32(req[4:], offset)
+	binary.LittleEndian.PutUint32(req[8:], length)
+	binary.LittleEndian.PutUint32(req[12:], mode)
+	binary.LittleEndian.PutUint32(req[16:], uint32(maxDelay/100))
+	binary.LittleEndian.PutUint32(req[20:], uint32(cycleTime/100))
+	resp, err := c.request(cmdAddDeviceNotification, req)
+	if err != nil {
+		return 0, err
+	}
+	if len(resp) < 4 {
+		return 0, errors.New("truncated notification handle")
+	}
+	return binary.LittleEndian.Uint32(resp), nil
+}
+
+func (c *client) deleteNotification(handle uint32) error {
+	req := make([]byte, 4)
+	binary.LittleEndian.PutUint32(req, handle)
+	_, err := c.request(cmdDeleteDeviceNotification, req)
+	return err
+}
+
+func decodeLengthPrefixed(buf []byte) ([]byte, error) {
+	if len(buf) < 4 {
+		return nil, errors.New("truncated response data")
+	}
+	length := binary.LittleEndian.Uint32(buf)
+	if uint32(len(buf)-4) < length {
+		return nil, errors.New("response data exceeds packet")
+	}
+	return buf[4 : 4+length], nil
+}
+
+// decodeNotification returns the samples of a device notification
+func decodeNotification(buf []byte) ([]notificationSample, error) {
+	if len(buf) < 8 {
+		return nil, errors.New("truncated notification")
+	}
+	stamps := binary.LittleEndian.Uint32(buf[4:])
+	buf = buf[8:]
+
+	var samples []notificationSample
+	for range stamps {
+		if len(buf) < 12 {
+			return nil, errors.New("truncated notification stamp")
+		}
+		timestamp := fileTime(binary.LittleEndian.Uint64(buf))
+		count := binary.LittleEndian.Uint32(buf[8:])
+		buf = buf[12:]
+		for range count {
+			if len(buf) < 8 {
+				return nil, errors.New("truncated notification sample")
+			}
+			handle := binary.LittleEndian.Uint32(buf)
+			size := binary.LittleEndian.Uint32(buf[4:])
+			if uint32(len(buf)-8) < size {
+				return nil, errors.New("notification sample exceeds data")
+			}
+			samples = append(samples, notificationSample{handle: handle, timestamp: timestamp, data: buf[8 : 8+size]})
+			buf = buf[8+size:]
+		}
+	}
+	return samples, nil
+}
+
+// fileTime converts the Windows file time in 100ns intervals since
+// 1601-01-01 to a timestamp
+func fileTime(v uint64) time.Time {
+	const epochDelta = 116444736000000000
+	return time.Unix(0, int64(v-epochDelta)*100).UTC()
+}
@@ -0,0 +1,367 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package beckhoff_ads
+
+import (
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Transmission modes of device notifications
+var transmissionModes = map[string]uint32{
+	"cyclic":    3,
+	"on-change": 4,
+}
+
+type BeckhoffADS struct {
+	Address       string                   `toml:"address"`
+	NetID         string                   `toml:"net_id"`
+	Port          uint16                   `toml:"port"`
+	SourceNetID   string                   `toml:"source_net_id"`
+	SourcePort    uint16                   `toml:"source_port"`
+	Timeout       config.Duration          `toml:"timeout"`
+	Measurement   string                   `toml:"measurement"`
+	Symbols       []symbolDefinition       `toml:"symbols"`
+	Notifications []notificationDefinition `toml:"notifications"`
+	Log           telegraf.Logger          `toml:"-"`
+
+	acc           telegraf.Accumulator
+	client        *client
+	target        amsAddress
+	source        amsAddress
+	tags          map[string]string
+	polled        []*variable
+	notifications []*notification
+	handles       map[uint32]*notification
+	registering   bool
+	early         []notificationSample
+	sync.Mutex
+}
+
+type symbolDefinition struct {
+	Name  string `toml:"name"`
+	Field string `toml:"field"`
+}
+
+type notificationDefinition struct {
+	Name      string          `toml:"name"`
+	Field     string          `toml:"field"`
+	Mode      string          `toml:"mode"`
+	CycleTime config.Duration `toml:"cycle_time"`
+	MaxDelay  config.Duration `toml:"max_delay"`
+}
+
+// variable is a symbol of the PLC mapped to a field
+type variable struct {
+	name   string
+	field  string
+	symbol *symbol
+}
+
+type notification struct {
+	variable
+	mode      uint32
+	cycleTime time.Duration
+	maxDelay  time.Duration
+}
+
+func (*BeckhoffADS) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *BeckhoffADS) Init() error {
+	if p.Address == "" {
+		return errors.New("'address' must be specified")
+	}
+	var nerr *net.AddrError
+	if _, _, err := net.SplitHostPort(p.Address); errors.As(err, &nerr) && strings.Contains(nerr.Err, "missing port") {
+		p.Address += ":48898"
+	}
+	if p.NetID == "" {
+		return errors.New("'net_id' must be specified")
+	}
+	id, err := parseNetID(p.NetID)
+	if err != nil {
+		return err
+	}
+	p.target = amsAddress{netID: id, port: p.Port}
+	if p.SourceNetID != "" {
+		id, err := parseNetID(p.SourceNetID)
+		if err != nil {
+			return fmt.Errorf("invalid 'source_net_id': %w", err)
+		}
+		p.source.netID = id
+	}
+	p.source.port = p.SourcePort
+	if p.Timeout <= 0 {
+		return errors.New("'timeout' must be positive")
+	}
+	if p.Measurement == "" {
+		p.Measurement = "beckhoff_ads"
+	}
+	if len(p.Symbols) == 0 && len(p.Notifications) == 0 {
+		return errors.New("no symbols or notifications defined")
+	}
+
+	fields := make(map[string]bool, len(p.Symbols))
+	p.polled = make([]*variable, 0, len(p.Symbols))
+	for _, def := range p.Symbols {
+		v, err := newVariable(def.Name, def.Field)
+		if err != nil {
+			return err
+		}
+		if fields[v.field] {
+			return fmt.Errorf("duplicate field %q", v.field)
+		}
+		fields[v.field] = true
+		p.polled = append(p.polled, v)
+	}
+
+	p.notifications = make([]*notification, 0, len(p.Notifications))
+	for _, def := range p.Notifications {
+		v, err := newVariable(def.Name, def.Field)
+		if err != nil {
+			return err
+		}
+		if def.Mode == "" {
+			def.Mode = "on-change"
+		}
+		mode, found := transmissionModes[def.Mode]
+		if !found {
+			return fmt.Errorf("invalid mode %q for notification %q", def.Mode, def.Name)
+		}
+		if def.CycleTime < 0 || def.MaxDelay < 0 {
+			return fmt.Errorf("negative times for notification %q", def.Name)
+		}
+		if mode == transmissionModes["cyclic"] && def.CycleTime == 0 {
+			return fmt.Errorf("'cycle_time' required for cyclic notification %q", def.Name)
+		}
+		p.notifications = append(p.notifications, &notification{
+			variable:  *v,
+			mode:      mode,
+			cycleTime: time.Duration(def.CycleTime),
+			maxDelay:  time.Duration(def.MaxDelay),
+		})
+	}
+
+	p.tags = map[string]string{
+		"net_id": p.target.netID.String(),
+		"port":   strconv.FormatUint(uint64(p.target.port), 10),
+	}
+	return nil
+}
+
+func newVariable(name, field string) (*variable, error) {
+	if name == "" {
+		return nil, errors.New("missing symbol name")
+	}
+	if field == "" {
+		field = name
+	}
+	return &variable{name: name, field: field}, nil
+}
+
+func (p *BeckhoffADS) Start(acc telegraf.Accumulator) error {
+	p.acc = acc
+	return p.connect()
+}
+
+func (p *BeckhoffADS) Gather(acc telegraf.Accumulator) error {
+	if p.client == nil || p.client.closed() != nil {
+		if p.client != nil {
+			p.Log.Warnf("Connection closed: %v", p.client.closed())
+			p.client.close()
+			p.client = nil
+		}
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+
+	if len(p.polled) == 0 {
+		return nil
+	}
+
+	symbols := make([]*symbol, 0, len(p.polled))
+	for _, v := range p.polled {
+		symbols = append(symbols, v.symbol)
+	}
+	data, errs, err := p.client.sumRead(symbols)
+	if err != nil {
+		return fmt.Errorf("reading symbols failed: %w", err)
+	}
+	timestamp := time.Now()
+
+	fields := make(map[string]interface{}, len(p.polled))
+	for i, v := range p.polled {
+		if errs[i] != nil {
+			acc.AddError(fmt.Errorf("reading symbol %q failed: %w", v.name, errs[i]))
+			continue
+		}
+		value, err := v.symbol.decode(data[i])
+		if err != nil {
+			acc.AddError(fmt.Errorf("decoding symbol %q failed: %w", v.name, err))
+			continue
+		}
+		fields[v.field] = value
+	}
+	if len(fields) > 0 {
+		acc.AddFields(p.Measurement, fields, p.newTags(), timestamp)
+	}
+
+	return nil
+}
+
+func (p *BeckhoffADS) Stop() {
+	if p.client == nil {
+		return
+	}
+
+	// Delete the notifications to release the resources on the device
+	p.Lock()
+	handles := make([]uint32, 0, len(p.handles))
+	for h := range p.handles {
+		handles = append(handles, h)
+	}
+	p.handles = nil
+	p.Unlock()
+	for _, h := range handles {
+		if err := p.client.deleteNotification(h); err != nil {
+			p.Log.Debugf("Deleting notification %d failed: %v", h, err)
+		}
+	}
+	p.client.close()
+	p.client = nil
+}
+
+// connect establishes the connection, resolves the symbols and registers
+// the notifications
+func (p *BeckhoffADS) connect() error {
+	c, err := dial(p.Address, p.target, p.source, time.Duration(p.Timeout), p.Log, p.onNotification)
+	if err != nil {
+		return fmt.Errorf("connecting to %s failed: %w", p.Address, err)
+	}
+	p.Log.Debugf("Connected to %s", p.Address)
+
+	table, err := c.uploadSymbols()
+	if err != nil {
+		c.close()
+		return fmt.Errorf("uploading symbols failed: %w", err)
+	}
+	p.Log.Debugf("Uploaded %d symbols", len(table))
+
+	resolve := func(v *variable) error {
+		s, found := table[strings.ToUpper(v.name)]
+		if !found {
+			return fmt.Errorf("symbol %q not found", v.name)
+		}
+		if err := s.supported(); err != nil {
+			return fmt.Errorf("symbol %q: %w", v.name, err)
+		}
+		v.symbol = s
+		return nil
+	}
+	for _, v := range p.polled {
+		if err := resolve(v); err != nil {
+			c.close()
+			return err
+		}
+	}
+	for _, n := range p.notifications {
+		if err := resolve(&n.variable); err != nil {
+			c.close()
+			return err
+		}
+	}
+
+	// Notifications might arrive before the handle is returned, so keep
+	// those samples until all notifications are registered
+	p.Lock()
+	p.handles = make(map[uint32]*notification, len(p.notifications))
+	p.registering = true
+	p.Unlock()
+	defer func() {
+		p.Lock()
+		p.registering = false
+		p.early = nil
+		p.Unlock()
+	}()
+	for _, n := range p.notifications {
+		s := n.symbol
+		handle, err := c.addNotification(s.group, s.offset, s.size, n.mode, n.maxDelay, n.cycleTime)
+		if err != nil {
+			c.close()
+			return fmt.Errorf("adding notification for %q failed: %w", n.name, err)
+		}
+		p.Lock()
+		p.handles[handle] = n
+		p.Unlock()
+	}
+
+	p.Lock()
+	early := p.early
+	p.registering = false
+	p.early = nil
+	p.Unlock()
+	if len(early) > 0 {
+		p.onNotification(early)
+	}
+
+	p.client = c
+	return nil
+}
+
+// onNotification adds the values of received device notifications
+func (p *BeckhoffADS) onNotification(samples []notificationSample) {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, sample := range samples {
+		n, found := p.handles[sample.handle]
+		if !found {
+			if p.registering {
+				p.early = append(p.early, sample)
+			} else {
+				p.Log.Debugf("Ignoring notification with unknown handle %d", sample.handle)
+			}
+			continue
+		}
+		value, err := n.symbol.decode(sample.data)
+		if err != nil {
+			p.acc.AddError(fmt.Errorf("decoding notification for %q failed: %w", n.name, err))
+			continue
+		}
+		p.acc.AddFields(p.Measurement, map[string]interface{}{n.field: value}, p.newTags(), sample.timestamp)
+	}
+}
+
+func (p *BeckhoffADS) newTags() map[string]string {
+	tags := make(map[string]string, len(p.tags))
+	for k, v := range p.tags {
+		tags[k] = v
+	}
+	return tags
+}
+
+func init() {
+	inputs.Add("beckhoff_ads", func() telegraf.Input {
+		return &BeckhoffADS{
+			Port:       851,
+			SourcePort: 32905,
+			Timeout:    config.Duration(5 * time.Second),
+		}
+	})
+}
@@ -0,0 +1,439 @@
+package beckhoff_ads
+
+import (
+	"encoding/binary"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *BeckhoffADS
+		expected string
+	}{
+		{
+			name:     "no address",
+			plugin:   &BeckhoffADS{},
+			expected: "'address' must be specified",
+		},
+		{
+			name:     "no net ID",
+			plugin:   &BeckhoffADS{Address: "127.0.0.1"},
+			expected: "'net_id' must be specified",
+		},
+		{
+			name:     "invalid net ID",
+			plugin:   &BeckhoffADS{Address: "127.0.0.1", NetID: "127.0.0.1"},
+			expected: `invalid AMS net ID "127.0.0.1"`,
+		},
+		{
+			name:     "nothing to read",
+			plugin:   &BeckhoffADS{Address: "127.0.0.1", NetID: "127.0.0.1.1.1"},
+			expected: "no symbols or notifications defined",
+		},
+		{
+			name: "duplicate field",
+			plugin: &BeckhoffADS{
+				Address: "127.0.0.1",
+				NetID:   "127.0.0.1.1.1",
+				Symbols: []symbolDefinition{{Name: "MAIN.a", Field: "x"}, {Name: "MAIN.b", Field: "x"}},
+			},
+			expected: `duplicate field "x"`,
+		},
+		{
+			name: "invalid mode",
+			plugin: &BeckhoffADS{
+				Address:       "127.0.0.1",
+				NetID:         "127.0.0.1.1.1",
+				Notifications: []notificationDefinition{{Name: "MAIN.a", Mode: "foo"}},
+			},
+			expected: `invalid mode "foo" for notification "MAIN.a"`,
+		},
+		{
+			name: "cyclic without cycle time",
+			plugin: &BeckhoffADS{
+				Address:       "127.0.0.1",
+				NetID:         "127.0.0.1.1.1",
+				Notifications: []notificationDefinition{{Name: "MAIN.a", Mode: "cyclic"}},
+			},
+			expected: `'cycle_time' required for cyclic notification "MAIN.a"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Timeout = config.Duration(time.Second)
+			tt.plugin.Log = testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestDecodeSymbolTable(t *testing.T) {
+	table := append(symbolEntry("MAIN.counter", "DINT", 0x4020, 0, 4, typeInt32), symbolEntry("GVL.Speed", "LREAL", 0x4020, 8, 8, typeReal64)...)
+	symbols, err := decodeSymbolTable(table)
+	require.NoError(t, err)
+	require.Len(t, symbols, 2)
+	require.Equal(t, &symbol{name: "GVL.Speed", group: 0x4020, offset: 8, size: 8, dataType: typeReal64, typeName: "LREAL"}, symbols["GVL.SPEED"])
+
+	_, err = decodeSymbolTable(table[:40])
+	require.Error(t, err)
+}
+
+func TestFileTime(t *testing.T) {
+	require.Equal(t, time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC), fileTime(133444736000000000))
+}
+
+func TestGather(t *testing.T) {
+	dev := newFakeDevice(t)
+	dev.addSymbol("MAIN.counter", "DINT", 0, 4, typeInt32)
+	dev.addSymbol("MAIN.running", "BOOL", 4, 1, typeBit)
+	dev.addSymbol("GVL.speed", "LREAL", 8, 8, typeReal64)
+	dev.addSymbol("MAIN.name", "STRING(80)", 16, 81, typeString)
+	dev.addSymbol("MAIN.axis", "ST_Axis", 100, 32, typeBigType)
+	dev.write(0, binary.LittleEndian.AppendUint32(nil, uint32(0xFFFFFFFE)))
+	dev.write(4, []byte{1})
+	dev.write(8, binary.LittleEndian.AppendUint64(nil, math.Float64bits(1.5)))
+	dev.write(16, []byte("line 1\x00"))
+
+	plugin := &BeckhoffADS{
+		Address: dev.addr(),
+		NetID:   "10.0.0.1.1.1",
+		Port:    851,
+		Timeout: config.Duration(time.Second),
+		Symbols: []symbolDefinition{
+			{Name: "main.counter", Field: "counter"},
+			{Name: "MAIN.running", Field: "running"},
+			{Name: "GVL.speed"},
+			{Name: "MAIN.name", Field: "name"},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	require.NoError(t, plugin.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"beckhoff_ads",
+			map[string]string{"net_id": "10.0.0.1.1.1", "port": "851"},
+			map[string]interface{}{
+				"counter":   int64(-2),
+				"running":   true,
+				"GVL.speed": float64(1.5),
+				"name":      "line 1",
+			},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+	require.Equal(t, "127.0.0.1.1.1", dev.source().String())
+
+	// Structured types cannot be read
+	plugin.Stop()
+	plugin.Symbols = []symbolDefinition{{Name: "MAIN.axis"}}
+	require.NoError(t, plugin.Init())
+	require.ErrorContains(t, plugin.Start(&acc), `symbol "MAIN.axis": structured type "ST_Axis" is not supported`)
+}
+
+func TestNotifications(t *testing.T) {
+	dev := newFakeDevice(t)
+	dev.addSymbol("MAIN.state", "UINT", 0, 2, typeUint16)
+	dev.write(0, []byte{3, 0})
+
+	plugin := &BeckhoffADS{
+		Address:     dev.addr(),
+		NetID:       "10.0.0.1.1.1",
+		Port:        851,
+		SourceNetID: "10.0.0.2.1.1",
+		Timeout:     config.Duration(time.Second),
+		Notifications: []notificationDefinition{
+			{Name: "MAIN.state", Field: "state", CycleTime: config.Duration(100 * time.Millisecond)},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	require.Equal(t, "10.0.0.2.1.1", dev.source().String())
+
+	// The device sends the current value immediately after registration
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 1
+	}, 3*time.Second, 50*time.Millisecond)
+
+	dev.write(0, []byte{5, 0})
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 2
+	}, 3*time.Second, 50*time.Millisecond)
+
+	timestamp := time.Date(2023, 11, 14, 22, 13, 20, 0, time.UTC)
+	tags := map[string]string{"net_id": "10.0.0.1.1.1", "port": "851"}
+	expected := []telegraf.Metric{
+		metric.New("beckhoff_ads", tags, map[string]interface{}{"state": uint64(3)}, timestamp),
+		metric.New("beckhoff_ads", tags, map[string]interface{}{"state": uint64(5)}, timestamp),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+
+	mode, cycleTime := dev.notificationSettings()
+	require.Equal(t, uint32(4), mode)
+	require.Equal(t, uint32(1000000), cycleTime)
+
+	plugin.Stop()
+	require.Eventually(t, func() bool {
+		return dev.notificationCount() == 0
+	}, 3*time.Second, 50*time.Millisecond)
+}
+
+func TestReconnect(t *testing.T) {
+	dev := newFakeDevice(t)
+	dev.addSymbol("MAIN.counter", "UDINT", 0, 4, typeUint32)
+	dev.write(0, []byte{42, 0, 0, 0})
+
+	plugin := &BeckhoffADS{
+		Address: dev.addr(),
+		NetID:   "10.0.0.1.1.1",
+		Timeout: config.Duration(time.Second),
+		Symbols: []symbolDefinition{{Name: "MAIN.counter"}},
+		Log:     testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	dev.disconnect()
+	require.Eventually(t, func() bool {
+		return plugin.client.closed() != nil
+	}, 3*time.Second, 50*time.Millisecond)
+
+	require.NoError(t, plugin.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Equal(t, uint64(42), acc.GetTelegrafMetrics()[0].Fields()["MAIN.counter"])
+}
+
+// fakeDevice is a minimal ADS server with a single memory area holding the
+// values of all symbols
+type fakeDevice struct {
+	listener net.Listener
+	table    []byte
+	memory   []byte
+	conns    []net.Conn
+	client   amsAddress
+	handles  map[uint32][2]uint32
+	settings [2]uint32
+	nextID   uint32
+	conn     net.Conn
+	sync.Mutex
+}
+
+func newFakeDevice(t *testing.T) *fakeDevice {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	d := &fakeDevice{
+		listener: listener,
+		memory:   make([]byte, 256),
+		handles:  make(map[uint32][2]uint32),
+	}
+	t.Cleanup(func() {
+		listener.Close()
+		d.disconnect()
+	})
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			d.Lock()
+			d.conns = append(d.conns, conn)
+			d.Unlock()
+			go d.serve(conn)
+		}
+	}()
+	return d
+}
+
+func (d *fakeDevice) addr() string {
+	return d.listener.Addr().String()
+}
+
+func (d *fakeDevice) addSymbol(name, typeName string, offset, size, dataType uint32) {
+	d.Lock()
+	defer d.Unlock()
+	d.table = append(d.table, symbolEntry(name, typeName, 0x4020, offset, size, dataType)...)
+}
+
+// write changes the memory and sends notifications for the affected handles
+func (d *fakeDevice) write(offset uint32, data []byte) {
+	d.Lock()
+	defer d.Unlock()
+	copy(d.memory[offset:], data)
+	for handle, area := range d.handles {
+		if area[0] < offset+uint32(len(data)) && offset < area[0]+area[1] {
+			d.notify(handle)
+		}
+	}
+}
+
+func (d *fakeDevice) source() netID {
+	d.Lock()
+	defer d.Unlock()
+	return d.client.netID
+}
+
+func (d *fakeDevice) notificationSettings() (mode, cycleTime uint32) {
+	d.Lock()
+	defer d.Unlock()
+	return d.settings[0], d.settings[1]
+}
+
+func (d *fakeDevice) notificationCount() int {
+	d.Lock()
+	defer d.Unlock()
+	return len(d.handles)
+}
+
+func (d *fakeDevice) disconnect() {
+	d.Lock()
+	defer d.Unlock()
+	for _, c := range d.conns {
+		c.Close()
+	}
+	d.conns = nil
+	d.handles = make(map[uint32][2]uint32)
+}
+
+func (d *fakeDevice) serve(conn net.Conn) {
+	for {
+		p, err := readPacket(conn)
+		if err != nil {
+			return
+		}
+
+		d.Lock()
+		d.conn = conn
+		d.client = p.source
+		result := make([]byte, 4)
+		var notify uint32
+		switch p.command {
+		case cmdRead:
+			group := binary.LittleEndian.Uint32(p.data)
+			offset := binary.LittleEndian.Uint32(p.data[4:])
+			length := binary.LittleEndian.Uint32(p.data[8:])
+			var data []byte
+			switch group {
+			case groupSymbolUploadInfo:
+				data = make([]byte, 24)
+				binary.LittleEndian.PutUint32(data[4:], uint32(len(d.table)))
+			case groupSymbolUpload:
+				data = d.table
+			default:
+				data = d.memory[offset : offset+length]
+			}
+			result = binary.LittleEndian.AppendUint32(result, uint32(len(data)))
+			result = append(result, data...)
+		case cmdReadWrite:
+			group := binary.LittleEndian.Uint32(p.data)
+			count := binary.LittleEndian.Uint32(p.data[4:])
+			if group != groupSumRead {
+				binary.LittleEndian.PutUint32(result, 0x702)
+				break
+			}
+			var codes, data []byte
+			for i := range count {
+				req := p.data[16+12*i:]
+				offset := binary.LittleEndian.Uint32(req[4:])
+				length := binary.LittleEndian.Uint32(req[8:])
+				codes = binary.LittleEndian.AppendUint32(codes, 0)
+				data = append(data, d.memory[offset:offset+length]...)
+			}
+			result = binary.LittleEndian.AppendUint32(result, uint32(len(codes)+len(data)))
+			result = append(append(result, codes...), data...)
+		case cmdAddDeviceNotification:
+			d.nextID++
+			offset := binary.LittleEndian.Uint32(p.data[4:])
+			length := binary.LittleEndian.Uint32(p.data[8:])
+			d.handles[d.nextID] = [2]uint32{offset, length}
+			d.settings = [2]uint32{binary.LittleEndian.Uint32(p.data[12:]), binary.LittleEndian.Uint32(p.data[20:])}
+			result = binary.LittleEndian.AppendUint32(result, d.nextID)
+			notify = d.nextID
+		case cmdDeleteDeviceNotification:
+			delete(d.handles, binary.LittleEndian.Uint32(p.data))
+		}
+
+		resp := &amsPacket{
+			target:   p.source,
+			source:   p.target,
+			command:  p.command,
+			flags:    stateResponse | stateCommand,
+			invokeID: p.invokeID,
+			data:     result,
+		}
+		_, err = conn.Write(resp.encode())
+		if err == nil && notify != 0 {
+			d.notify(notify)
+		}
+		d.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// notify sends the current value for the handle, the lock must be held
+func (d *fakeDevice) notify(handle uint32) {
+	area := d.handles[handle]
+	data := make([]byte, 8, 32+area[1])
+	binary.LittleEndian.PutUint32(data[4:], 1)
+	data = binary.LittleEndian.AppendUint64(data, 133444736000000000)
+	data = binary.LittleEndian.AppendUint32(data, 1)
+	data = binary.LittleEndian.AppendUint32(data, handle)
+	data = binary.LittleEndian.AppendUint32(data, area[1])
+	data = append(data, d.memory[area[0]:area[0]+area[1]]...)
+	binary.LittleEndian.PutUint32(data, uint32(len(data)-4))
+
+	p := &amsPacket{
+		target:  d.client,
+		command: cmdDeviceNotification,
+		flags:   stateCommand,
+		data:    data,
+	}
+	if d.conn != nil {
+		//nolint:errcheck // Errors are detected when reading the next request
+		d.conn.Write(p.encode())
+	}
+}
+
+func symbolEntry(name, typeName string, group, offset, size, dataType uint32) []byte {
+	entry := make([]byte, 30, 30+len(name)+len(typeName)+3)
+	binary.LittleEndian.PutUint32(entry[4:], group)
+	binary.LittleEndian.PutUint32(entry[8:], offset)
+	binary.LittleEndian.PutUint32(entry[12:], size)
+	binary.LittleEndian.PutUint32(entry[16:], dataType)
+	binary.LittleEndian.PutUint16(entry[24:], uint16(len(name)))
+	binary.LittleEndian.PutUint16(entry[26:], uint16(len(typeName)))
+	entry = append(entry, name...)
+	entry = append(entry, 0)
+	entry = append(entry, typeName...)
+	entry = append(entry, 0, 0)
+	binary.LittleEndian.PutUint32(entry, uint32(len(entry)))
+	return entry
+}
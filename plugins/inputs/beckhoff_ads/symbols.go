@@ -0,0 +1,181 @@
+package beckhoff_ads
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Index groups of the symbol services
+const (
+	groupSymbolUpload     = 0xF00B
+	groupSymbolUploadInfo = 0xF00F
+	groupSumRead          = 0xF080
+)
+
+// ADS data types
+const (
+	typeInt16   = 2
+	typeInt32   = 3
+	typeReal32  = 4
+	typeReal64  = 5
+	typeInt8    = 16
+	typeUint8   = 17
+	typeUint16  = 18
+	typeUint32  = 19
+	typeInt64   = 20
+	typeUint64  = 21
+	typeString  = 30
+	typeBit     = 33
+	typeBigType = 65
+)
+
+// symbol is an entry of the symbol table of the device
+type symbol struct {
+	name     string
+	group    uint32
+	offset   uint32
+	size     uint32
+	dataType uint32
+	typeName string
+}
+
+// decodeSymbolTable parses the uploaded symbol table into a map indexed by
+// the upper-case symbol names as TwinCAT names are case-insensitive
+func decodeSymbolTable(buf []byte) (map[string]*symbol, error) {
+	symbols := make(map[string]*symbol)
+	for len(buf) > 0 {
+		if len(buf) < 30 {
+			return nil, errors.New("truncated symbol entry")
+		}
+		length := binary.LittleEndian.Uint32(buf)
+		if length < 30 || uint32(len(buf)) < length {
+			return nil, fmt.Errorf("invalid symbol entry length %d", length)
+		}
+		entry := buf[:length]
+		buf = buf[length:]
+
+		s := &symbol{
+			group:    binary.LittleEndian.Uint32(entry[4:]),
+			offset:   binary.LittleEndian.Uint32(entry[8:]),
+			size:     binary.LittleEndian.Uint32(entry[12:]),
+			dataType: binary.LittleEndian.Uint32(entry[16:]),
+		}
+		nameLength := int(binary.LittleEndian.Uint16(entry[24:]))
+		typeLength := int(binary.LittleEndian.Uint16(entry[26:]))
+		if len(entry) < 30+nameLength+1+typeLength+1 {
+			return nil, errors.New("symbol name exceeds entry")
+		}
+		s.name = string(entry[30 : 30+nameLength])
+		s.typeName = string(entry[30+nameLength+1 : 30+nameLength+1+typeLength])
+		symbols[strings.ToUpper(s.name)] = s
+	}
+	return symbols, nil
+}
+
+// uploadSymbols reads the symbol table of the device
+func (c *client) uploadSymbols() (map[string]*symbol, error) {
+	info, err := c.read(groupSymbolUploadInfo, 0, 24)
+	if err != nil {
+		return nil, fmt.Errorf("reading upload info failed: %w", err)
+	}
+	if len(info) < 8 {
+		return nil, errors.New("truncated upload info")
+	}
+	length := binary.LittleEndian.Uint32(info[4:])
+	table, err := c.read(groupSymbolUpload, 0, length)
+	if err != nil {
+		return nil, fmt.Errorf("reading symbol table failed: %w", err)
+	}
+	return decodeSymbolTable(table)
+}
+
+// sumRead reads the given symbols using a single request and returns the
+// data or the error for each symbol
+func (c *client) sumRead(symbols []*symbol) ([][]byte, []error, error) {
+	req := make([]byte, 0, 12*len(symbols))
+	var length uint32
+	for _, s := range symbols {
+		req = binary.LittleEndian.AppendUint32(req, s.group)
+		req = binary.LittleEndian.AppendUint32(req, s.offset)
+		req = binary.LittleEndian.AppendUint32(req, s.size)
+		length += 4 + s.size
+	}
+	resp, err := c.readWrite(groupSumRead, uint32(len(symbols)), length, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if uint32(len(resp)) < length {
+		return nil, nil, errors.New("truncated sum read response")
+	}
+
+	data := make([][]byte, len(symbols))
+	errs := make([]error, len(symbols))
+	pos := 4 * len(symbols)
+	for i, s := range symbols {
+		if code := binary.LittleEndian.Uint32(resp[4*i:]); code != 0 {
+			errs[i] = adsError(code)
+		} else {
+			data[i] = resp[pos : pos+int(s.size)]
+		}
+		pos += int(s.size)
+	}
+	return data, errs, nil
+}
+
+// supported checks if the value of the symbol can be converted to a field
+func (s *symbol) supported() error {
+	switch s.dataType {
+	case typeBit, typeInt8, typeUint8, typeInt16, typeUint16, typeInt32, typeUint32,
+		typeInt64, typeUint64, typeReal32, typeReal64, typeString:
+		return nil
+	case typeBigType:
+		return fmt.Errorf("structured type %q is not supported", s.typeName)
+	}
+	return fmt.Errorf("type %q is not supported", s.typeName)
+}
+
+// decode converts the data of the symbol to a field value
+func (s *symbol) decode(data []byte) (interface{}, error) {
+	sizes := map[uint32]int{
+		typeBit: 1, typeInt8: 1, typeUint8: 1, typeInt16: 2, typeUint16: 2, typeInt32: 4,
+		typeUint32: 4, typeReal32: 4, typeInt64: 8, typeUint64: 8, typeReal64: 8,
+	}
+	if size, found := sizes[s.dataType]; found && len(data) < size {
+		return nil, fmt.Errorf("data of %q too short", s.name)
+	}
+
+	switch s.dataType {
+	case typeBit:
+		return data[0] != 0, nil
+	case typeInt8:
+		return int64(int8(data[0])), nil
+	case typeUint8:
+		return uint64(data[0]), nil
+	case typeInt16:
+		return int64(int16(binary.LittleEndian.Uint16(data))), nil
+	case typeUint16:
+		return uint64(binary.LittleEndian.Uint16(data)), nil
+	case typeInt32:
+		return int64(int32(binary.LittleEndian.Uint32(data))), nil
+	case typeUint32:
+		return uint64(binary.LittleEndian.Uint32(data)), nil
+	case typeInt64:
+		return int64(binary.LittleEndian.Uint64(data)), nil
+	case typeUint64:
+		return binary.LittleEndian.Uint64(data), nil
+	case typeReal32:
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(data))), nil
+	case typeReal64:
+		return math.Float64frombits(binary.LittleEndian.Uint64(data)), nil
+	case typeString:
+		if i := bytes.IndexByte(data, 0); i >= 0 {
+			data = data[:i]
+		}
+		return string(data), nil
+	}
+	return nil, s.supported()
+}
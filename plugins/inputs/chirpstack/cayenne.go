@@ -0,0 +1,126 @@
+package chirpstack
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// lppType describes a Cayenne Low Power Payload data type consisting of one
+// or more big-endian values of the given size. The values are divided by the
+// scale to get the physical value.
+type lppType struct {
+	name       string
+	size       int
+	signed     bool
+	scale      float64
+	components []string
+}
+
+// Data types of the Cayenne LPP specification including the extensions of
+// the widely used ElectronicCats implementation
+var lppTypes = map[byte]lppType{
+	0:   {name: "digital_input", size: 1, scale: 1},
+	1:   {name: "digital_output", size: 1, scale: 1},
+	2:   {name: "analog_input", size: 2, signed: true, scale: 100},
+	3:   {name: "analog_output", size: 2, signed: true, scale: 100},
+	100: {name: "generic", size: 4, scale: 1},
+	101: {name: "illuminance", size: 2, scale: 1},
+	102: {name: "presence", size: 1, scale: 1},
+	103: {name: "temperature", size: 2, signed: true, scale: 10},
+	104: {name: "humidity", size: 1, scale: 2},
+	113: {name: "accelerometer", size: 2, signed: true, scale: 1000, components: []string{"x", "y", "z"}},
+	115: {name: "barometer", size: 2, scale: 10},
+	116: {name: "voltage", size: 2, scale: 100},
+	117: {name: "current", size: 2, scale: 1000},
+	118: {name: "frequency", size: 4, scale: 1},
+	120: {name: "percentage", size: 1, scale: 1},
+	121: {name: "altitude", size: 2, signed: true, scale: 1},
+	125: {name: "concentration", size: 2, scale: 1},
+	128: {name: "power", size: 2, scale: 1},
+	130: {name: "distance", size: 4, scale: 1000},
+	131: {name: "energy", size: 4, scale: 1000},
+	132: {name: "direction", size: 2, scale: 1},
+	133: {name: "unixtime", size: 4, scale: 1},
+	134: {name: "gyrometer", size: 2, signed: true, scale: 100, components: []string{"x", "y", "z"}},
+	142: {name: "switch", size: 1, scale: 1},
+}
+
+// The GPS location uses three values of three bytes with different
+// resolutions and is therefore handled separately
+const lppGPS = 136
+
+// decodeCayenneLPP decodes the payload consisting of channel, type and value
+// triples. The fields are named after the type and channel, e.g.
+// "temperature_1".
+func decodeCayenneLPP(payload []byte) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	for len(payload) > 0 {
+		if len(payload) < 2 {
+			return nil, fmt.Errorf("incomplete header of %d byte(s)", len(payload))
+		}
+		channel, typ := strconv.Itoa(int(payload[0])), payload[1]
+		payload = payload[2:]
+
+		if typ == lppGPS {
+			if len(payload) < 9 {
+				return nil, fmt.Errorf("insufficient data for GPS location on channel %s", channel)
+			}
+			prefix := "gps_" + channel + "_"
+			fields[prefix+"latitude"] = float64(int24(payload[0:3])) / 10000
+			fields[prefix+"longitude"] = float64(int24(payload[3:6])) / 10000
+			fields[prefix+"altitude"] = float64(int24(payload[6:9])) / 100
+			payload = payload[9:]
+			continue
+		}
+
+		t, found := lppTypes[typ]
+		if !found {
+			return nil, fmt.Errorf("unknown data type %d on channel %s", typ, channel)
+		}
+		components := t.components
+		if len(components) == 0 {
+			components = []string{""}
+		}
+		if len(payload) < t.size*len(components) {
+			return nil, fmt.Errorf("insufficient data for %s on channel %s", t.name, channel)
+		}
+		for _, c := range components {
+			name := t.name + "_" + channel
+			if c != "" {
+				name += "_" + c
+			}
+			fields[name] = t.value(payload[:t.size])
+			payload = payload[t.size:]
+		}
+	}
+	return fields, nil
+}
+
+func (t *lppType) value(buf []byte) interface{} {
+	var raw uint64
+	for _, b := range buf {
+		raw = raw<<8 | uint64(b)
+	}
+
+	var v float64
+	if t.signed {
+		shift := 64 - 8*len(buf)
+		v = float64(int64(raw<<shift) >> shift)
+	} else {
+		v = float64(raw)
+	}
+
+	// Keep integer values for types without fractional resolution
+	if t.scale == 1 {
+		if t.signed {
+			return int64(v)
+		}
+		return uint64(v)
+	}
+	return v / t.scale
+}
+
+func int24(buf []byte) int32 {
+	return int32(binary.BigEndian.Uint32([]byte{buf[0], buf[1], buf[2], 0})) >> 8
+}
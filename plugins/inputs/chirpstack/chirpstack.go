@@ -0,0 +1,355 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package chirpstack
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/mqtt"
+	"github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Maximum size of uplink events received via HTTP
+const maxBodySize = 1024 * 1024
+
+type ChirpStack struct {
+	MetricName        string          `toml:"name"`
+	Servers           []string        `toml:"servers"`
+	Topics            []string        `toml:"topics"`
+	Username          config.Secret   `toml:"username"`
+	Password          config.Secret   `toml:"password"`
+	QoS               int             `toml:"qos"`
+	ClientID          string          `toml:"client_id"`
+	ConnectionTimeout config.Duration `toml:"connection_timeout"`
+	KeepAlive         int64           `toml:"keep_alive"`
+	PersistentSession bool            `toml:"persistent_session"`
+	ClientTrace       bool            `toml:"client_trace"`
+	ServiceAddress    string          `toml:"service_address"`
+	Codecs            []*codec        `toml:"codec"`
+	Log               telegraf.Logger `toml:"-"`
+	tls.ClientConfig
+
+	acc       telegraf.Accumulator
+	cfg       *mqtt.MqttConfig
+	client    mqtt.Client
+	connected bool
+	listener  net.Listener
+	server    *http.Server
+	wg        sync.WaitGroup
+	sync.Mutex
+}
+
+func (*ChirpStack) SampleConfig() string {
+	return sampleConfig
+}
+
+func (c *ChirpStack) Init() error {
+	if c.MetricName == "" {
+		return errors.New("metric name is empty")
+	}
+	if len(c.Servers) == 0 && c.ServiceAddress == "" {
+		return errors.New("neither 'servers' nor 'service_address' specified")
+	}
+
+	for i, cd := range c.Codecs {
+		if err := cd.init(c.Log); err != nil {
+			return fmt.Errorf("codec %d: %w", i+1, err)
+		}
+	}
+
+	if len(c.Servers) == 0 {
+		return nil
+	}
+	if len(c.Topics) == 0 {
+		return errors.New("no topics specified")
+	}
+
+	if c.ClientID == "" {
+		id, err := internal.RandomString(5)
+		if err != nil {
+			return fmt.Errorf("generating random client ID failed: %w", err)
+		}
+		c.ClientID = "Telegraf-ChirpStack-" + id
+	}
+
+	c.cfg = &mqtt.MqttConfig{
+		Servers:           c.Servers,
+		Protocol:          "3.1.1",
+		Username:          c.Username,
+		Password:          c.Password,
+		ConnectionTimeout: c.ConnectionTimeout,
+		QoS:               c.QoS,
+		ClientID:          c.ClientID,
+		KeepAlive:         c.KeepAlive,
+		PersistentSession: c.PersistentSession,
+		ClientTrace:       c.ClientTrace,
+		ClientConfig:      c.ClientConfig,
+		OnConnectionLost:  c.onConnectionLost,
+	}
+
+	// Check the settings by creating a client
+	if _, err := mqtt.NewClient(c.cfg); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *ChirpStack) Start(acc telegraf.Accumulator) error {
+	c.acc = acc
+
+	if c.cfg != nil {
+		if err := c.connect(); err != nil {
+			return &internal.StartupError{Err: err, Retry: true}
+		}
+	}
+
+	if c.ServiceAddress != "" {
+		listener, err := net.Listen("tcp", c.ServiceAddress)
+		if err != nil {
+			return fmt.Errorf("listening on %q failed: %w", c.ServiceAddress, err)
+		}
+		c.server = &http.Server{
+			Handler:           http.HandlerFunc(c.serveHTTP),
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		c.listener = listener
+		c.Log.Infof("Listening on %s", listener.Addr())
+
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			if err := c.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				acc.AddError(fmt.Errorf("serving HTTP failed: %w", err))
+			}
+		}()
+	}
+
+	return nil
+}
+
+func (c *ChirpStack) Gather(telegraf.Accumulator) error {
+	if c.cfg == nil {
+		return nil
+	}
+
+	c.Lock()
+	connected := c.connected
+	c.Unlock()
+
+	if !connected {
+		c.Log.Debugf("Connecting %v", c.Servers)
+		return c.connect()
+	}
+	return nil
+}
+
+func (c *ChirpStack) Stop() {
+	if c.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := c.server.Shutdown(ctx); err != nil {
+			c.Log.Errorf("Shutting down HTTP server failed: %v", err)
+		}
+		c.wg.Wait()
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.client == nil {
+		return
+	}
+	if err := c.client.Close(); err != nil {
+		c.Log.Errorf("Closing connection failed: %v", err)
+	}
+	c.connected = false
+}
+
+func (c *ChirpStack) connect() error {
+	client, err := mqtt.NewClient(c.cfg)
+	if err != nil {
+		return err
+	}
+
+	// Add the routes in case we find a persistent session containing
+	// subscriptions, so persisted messages are dispatched correctly
+	for _, topic := range c.Topics {
+		client.AddRoute(topic, c.onMessage)
+	}
+
+	sessionPresent, err := client.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to %v failed: %w", c.Servers, err)
+	}
+	c.Log.Infof("Connected %v", c.Servers)
+
+	c.Lock()
+	c.client = client
+	c.connected = true
+	c.Unlock()
+
+	// Persistent sessions should skip subscription if a session is present,
+	// as the subscriptions are stored by the server.
+	if sessionPresent {
+		c.Log.Debugf("Session found %v", c.Servers)
+		return nil
+	}
+
+	topics := make(map[string]byte, len(c.Topics))
+	for _, topic := range c.Topics {
+		topics[topic] = byte(c.QoS)
+	}
+	if err := client.SubscribeMultiple(topics, c.onMessage); err != nil {
+		return fmt.Errorf("subscribing to %v failed: %w", c.Topics, err)
+	}
+
+	return nil
+}
+
+func (c *ChirpStack) onConnectionLost(err error) {
+	c.Lock()
+	c.connected = false
+	c.Unlock()
+
+	c.acc.AddError(fmt.Errorf("connection lost: %w", err))
+}
+
+func (c *ChirpStack) onMessage(_ paho.Client, msg paho.Message) {
+	if err := c.handle(msg.Payload(), time.Now()); err != nil {
+		c.acc.AddError(fmt.Errorf("handling message on topic %q failed: %w", msg.Topic(), err))
+	}
+}
+
+// serveHTTP handles the requests of the HTTP integration. The integration
+// posts all events to the same URL with the event type as query parameter.
+func (c *ChirpStack) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	if event := r.URL.Query().Get("event"); event != "" && event != "up" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/octet-stream") {
+		http.Error(w, "only JSON encoding is supported", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "reading body failed", http.StatusBadRequest)
+		return
+	}
+	if err := c.handle(body, time.Now()); err != nil {
+		c.Log.Debugf("Handling request from %s failed: %v", r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handle decodes the uplink event and adds the resulting metric. Errors of
+// the payload codec are reported but the metric is still added.
+func (c *ChirpStack) handle(payload []byte, received time.Time) error {
+	u, err := parseUplink(payload)
+	if err != nil {
+		return fmt.Errorf("decoding uplink failed: %w", err)
+	}
+
+	info := u.DeviceInfo
+	tags := map[string]string{
+		"dev_eui":          strings.ToLower(info.DevEUI),
+		"device_name":      info.DeviceName,
+		"application_id":   info.ApplicationID,
+		"application_name": info.ApplicationName,
+		"device_profile":   info.DeviceProfileName,
+	}
+	for k, v := range info.Tags {
+		if _, found := tags[k]; !found {
+			tags[k] = v
+		}
+	}
+
+	fields := make(map[string]interface{})
+	if u.FPort > 0 {
+		decoded, err := c.codecFor(u).decode(u)
+		if err != nil {
+			c.acc.AddError(fmt.Errorf("decoding payload of device %q failed: %w", info.DevEUI, err))
+		}
+		for k, v := range decoded {
+			fields[k] = v
+		}
+	}
+
+	// Add the radio parameters
+	fields["f_port"] = uint64(u.FPort)
+	fields["confirmed"] = u.Confirmed
+	if u.FCnt != nil {
+		fields["f_cnt"] = uint64(*u.FCnt)
+	}
+	if u.DR != nil {
+		fields["dr"] = int64(*u.DR)
+	}
+	if u.TxInfo.Frequency > 0 {
+		fields["frequency"] = uint64(u.TxInfo.Frequency)
+	}
+	if lora := u.TxInfo.Modulation.LoRa; lora != nil {
+		fields["spreading_factor"] = uint64(lora.SpreadingFactor)
+		fields["bandwidth"] = uint64(lora.Bandwidth)
+	}
+	if gw := u.bestGateway(); gw != nil {
+		tags["gateway_id"] = gw.GatewayID
+		fields["rssi"] = int64(gw.RSSI)
+		fields["snr"] = gw.SNR
+		fields["gateways"] = uint64(len(u.RxInfo))
+	}
+
+	timestamp := u.timestamp()
+	if timestamp.IsZero() {
+		timestamp = received
+	}
+	c.acc.AddFields(c.MetricName, fields, tags, timestamp)
+
+	return nil
+}
+
+// codecFor returns the first codec matching the uplink or the default codec
+// using the object decoded by ChirpStack
+func (c *ChirpStack) codecFor(u *uplink) *codec {
+	for _, cd := range c.Codecs {
+		if cd.matches(u) {
+			return cd
+		}
+	}
+	return &codec{Type: "object"}
+}
+
+func init() {
+	inputs.Add("chirpstack", func() telegraf.Input {
+		return &ChirpStack{
+			MetricName:        "chirpstack",
+			Topics:            []string{"application/+/device/+/event/up"},
+			ConnectionTimeout: config.Duration(30 * time.Second),
+			KeepAlive:         60,
+		}
+	})
+}
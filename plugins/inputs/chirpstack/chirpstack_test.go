@@ -0,0 +1,330 @@
+package chirpstack
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *ChirpStack
+		expected string
+	}{
+		{
+			name:     "no source",
+			plugin:   &ChirpStack{},
+			expected: "neither 'servers' nor 'service_address' specified",
+		},
+		{
+			name:     "no topics",
+			plugin:   &ChirpStack{Servers: []string{"tcp://127.0.0.1:1883"}},
+			expected: "no topics specified",
+		},
+		{
+			name: "invalid codec type",
+			plugin: &ChirpStack{
+				ServiceAddress: "127.0.0.1:0",
+				Codecs:         []*codec{{Type: "javascript"}},
+			},
+			expected: "codec 1: invalid type",
+		},
+		{
+			name: "builtin codecs",
+			plugin: &ChirpStack{
+				ServiceAddress: "127.0.0.1:0",
+				Codecs:         []*codec{{Type: "cayenne_lpp"}, {Type: "object"}},
+			},
+			expected: "",
+		},
+		{
+			name: "starlark codec without source",
+			plugin: &ChirpStack{
+				ServiceAddress: "127.0.0.1:0",
+				Codecs: []*codec{{
+					Type: "starlark",
+				}},
+			},
+			expected: "codec 1: one of source or script must be set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.MetricName = "chirpstack"
+			tt.plugin.Log = testutil.Logger{}
+			if tt.expected == "" {
+				require.NoError(t, tt.plugin.Init())
+				return
+			}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestInitStarlarkFail(t *testing.T) {
+	plugin := &ChirpStack{
+		MetricName:     "chirpstack",
+		ServiceAddress: "127.0.0.1:0",
+		Codecs:         []*codec{{Type: "object"}},
+		Log:            testutil.Logger{},
+	}
+	plugin.Codecs[0].Source = "def decode(fport, payload):\n    return {}\n"
+	require.ErrorContains(t, plugin.Init(), "codec 1: source and script are only supported for starlark codecs")
+
+	plugin.Codecs[0] = &codec{Type: "starlark"}
+	plugin.Codecs[0].Source = "def apply(metric):\n    return metric\n"
+	require.ErrorContains(t, plugin.Init(), "codec 1: decode is not defined")
+}
+
+func TestCayenneLPP(t *testing.T) {
+	payload := []byte{
+		0x03, 0x67, 0xff, 0xd7, // temperature -4.1 °C
+		0x05, 0x00, 0x01, // digital input
+		0x06, 0x71, 0x04, 0xd2, 0xfb, 0x2e, 0x00, 0x00, // accelerometer
+		0x01, 0x88, 0x06, 0x76, 0x5f, 0xf2, 0x96, 0x0a, 0x00, 0x03, 0xe8, // GPS
+		0x07, 0x65, 0x01, 0x2c, // illuminance 300 lux
+		0x08, 0x73, 0x27, 0x6f, // barometer 1009.5 hPa
+	}
+	fields, err := decodeCayenneLPP(payload)
+	require.NoError(t, err)
+
+	expected := map[string]interface{}{
+		"temperature_3":     float64(-4.1),
+		"digital_input_5":   uint64(1),
+		"accelerometer_6_x": float64(1.234),
+		"accelerometer_6_y": float64(-1.234),
+		"accelerometer_6_z": float64(0),
+		"gps_1_latitude":    float64(42.3519),
+		"gps_1_longitude":   float64(-87.9094),
+		"gps_1_altitude":    float64(10),
+		"illuminance_7":     uint64(300),
+		"barometer_8":       float64(1009.5),
+	}
+	require.Equal(t, expected, fields)
+
+	_, err = decodeCayenneLPP([]byte{0x01, 0x67, 0x00})
+	require.EqualError(t, err, "insufficient data for temperature on channel 1")
+	_, err = decodeCayenneLPP([]byte{0x01, 0xff, 0x00})
+	require.EqualError(t, err, "unknown data type 255 on channel 1")
+	_, err = decodeCayenneLPP([]byte{0x01})
+	require.EqualError(t, err, "incomplete header of 1 byte(s)")
+}
+
+func TestHandle(t *testing.T) {
+	payload, err := os.ReadFile(filepath.Join("testdata", "uplink.json"))
+	require.NoError(t, err)
+
+	tags := map[string]string{
+		"dev_eui":          "0101010101010101",
+		"device_name":      "office-sensor",
+		"application_id":   "17c82e96-be03-4f38-aef3-f83d48582d97",
+		"application_name": "Environment",
+		"device_profile":   "Environment sensor",
+		"building":         "hq",
+		"gateway_id":       "0016c001f153a14d",
+	}
+	radio := map[string]interface{}{
+		"f_port":           uint64(1),
+		"f_cnt":            uint64(42),
+		"confirmed":        false,
+		"dr":               int64(5),
+		"frequency":        uint64(868100000),
+		"spreading_factor": uint64(7),
+		"bandwidth":        uint64(125000),
+		"rssi":             int64(-57),
+		"snr":              float64(9.25),
+		"gateways":         uint64(2),
+	}
+	withRadio := func(fields map[string]interface{}) map[string]interface{} {
+		for k, v := range radio {
+			fields[k] = v
+		}
+		return fields
+	}
+	timestamp := time.Date(2024, 3, 15, 8, 30, 15, 123456000, time.UTC)
+
+	tests := []struct {
+		name     string
+		codecs   []*codec
+		expected map[string]interface{}
+	}{
+		{
+			name: "object",
+			expected: withRadio(map[string]interface{}{
+				"temperature":   float64(23.5),
+				"humidity":      float64(40),
+				"battery_level": float64(98),
+				"battery_low":   false,
+				"labels_0":      "office",
+			}),
+		},
+		{
+			name: "cayenne lpp",
+			codecs: []*codec{
+				{Type: "cayenne_lpp", DevEUIs: []string{"0202020202020202"}},
+				{Type: "cayenne_lpp", DevEUIs: []string{"0101010101010101"}, FPorts: []int{1}},
+			},
+			expected: withRadio(map[string]interface{}{
+				"temperature_1": float64(23.5),
+				"humidity_2":    float64(40),
+			}),
+		},
+		{
+			name: "starlark",
+			codecs: []*codec{
+				{Type: "cayenne_lpp", DeviceProfiles: []string{"Door sensor"}},
+				newStarlarkCodec(`
+def decode(fport, payload):
+    return {
+        "port": fport,
+        "temperature": (payload[2] << 8 | payload[3]) / 10.0,
+        "raw": {"length": len(payload), "first": payload[0]},
+    }
+`),
+			},
+			expected: withRadio(map[string]interface{}{
+				"port":        int64(1),
+				"temperature": float64(23.5),
+				"raw_length":  int64(7),
+				"raw_first":   int64(1),
+			}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &ChirpStack{
+				MetricName:     "chirpstack",
+				ServiceAddress: "127.0.0.1:0",
+				Codecs:         tt.codecs,
+				Log:            testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			plugin.acc = &acc
+			require.NoError(t, plugin.handle(payload, time.Now()))
+			require.Empty(t, acc.Errors)
+
+			expected := []telegraf.Metric{metric.New("chirpstack", tags, tt.expected, timestamp)}
+			testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+		})
+	}
+}
+
+func TestHandleCodecError(t *testing.T) {
+	plugin := &ChirpStack{
+		MetricName:     "chirpstack",
+		ServiceAddress: "127.0.0.1:0",
+		Codecs:         []*codec{newStarlarkCodec("def decode(fport, payload):\n    return [fport]\n")},
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	plugin.acc = &acc
+
+	// Invalid events are rejected
+	require.ErrorContains(t, plugin.handle([]byte(`{"deviceInfo": {}}`), time.Now()), "missing device EUI")
+	require.ErrorContains(t, plugin.handle([]byte(`{"deviceInfo":`), time.Now()), "decoding uplink failed")
+
+	// Codec errors are reported but the radio parameters are still added
+	received := time.Unix(1710491415, 0)
+	uplink := `{"deviceInfo": {"devEui": "0101010101010101"}, "fPort": 2, "fCnt": 1, "data": "AQ=="}`
+	require.NoError(t, plugin.handle([]byte(uplink), received))
+	require.Len(t, acc.Errors, 1)
+	require.ErrorContains(t, acc.Errors[0], "decode function returned list instead of a dictionary")
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"chirpstack",
+			map[string]string{
+				"dev_eui":          "0101010101010101",
+				"device_name":      "",
+				"application_id":   "",
+				"application_name": "",
+				"device_profile":   "",
+			},
+			map[string]interface{}{
+				"f_port":    uint64(2),
+				"f_cnt":     uint64(1),
+				"confirmed": false,
+			},
+			received,
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestHTTP(t *testing.T) {
+	payload, err := os.ReadFile(filepath.Join("testdata", "uplink.json"))
+	require.NoError(t, err)
+
+	plugin := &ChirpStack{
+		MetricName:     "chirpstack",
+		ServiceAddress: "127.0.0.1:0",
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	url := "http://" + plugin.listener.Addr().String() + "/chirpstack"
+
+	post := func(event, contentType string, body []byte) int {
+		resp, err := http.Post(url+"?event="+event, contentType, bytes.NewReader(body))
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Other events are acknowledged but ignored
+	require.Equal(t, http.StatusNoContent, post("join", "application/json", []byte(`{}`)))
+	require.Equal(t, http.StatusUnsupportedMediaType, post("up", "application/octet-stream", []byte{0x0a}))
+	require.Equal(t, http.StatusBadRequest, post("up", "application/json", []byte(`{}`)))
+	require.Equal(t, http.StatusNoContent, post("up", "application/json", payload))
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	require.Equal(t, 1, int(acc.NMetrics()))
+	m := acc.GetTelegrafMetrics()[0]
+	require.Equal(t, "0101010101010101", m.Tags()["dev_eui"])
+	require.Equal(t, map[string]interface{}{
+		"temperature":      float64(23.5),
+		"humidity":         float64(40),
+		"battery_level":    float64(98),
+		"battery_low":      false,
+		"labels_0":         "office",
+		"f_port":           uint64(1),
+		"f_cnt":            uint64(42),
+		"confirmed":        false,
+		"dr":               int64(5),
+		"frequency":        uint64(868100000),
+		"spreading_factor": uint64(7),
+		"bandwidth":        uint64(125000),
+		"rssi":             int64(-57),
+		"snr":              float64(9.25),
+		"gateways":         uint64(2),
+	}, m.Fields())
+}
+
+func newStarlarkCodec(source string) *codec {
+	c := &codec{Type: "starlark"}
+	c.Source = source
+	return c
+}
@@ -0,0 +1,160 @@
+package chirpstack
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.starlark.net/starlark"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/choice"
+	common "github.com/influxdata/telegraf/plugins/common/starlark"
+)
+
+// codec decodes the payload of the matching uplinks
+type codec struct {
+	Type           string   `toml:"type"`
+	DevEUIs        []string `toml:"dev_euis"`
+	DeviceProfiles []string `toml:"device_profiles"`
+	FPorts         []int    `toml:"fports"`
+	common.Common
+
+	// Starlark threads must not be used concurrently
+	sync.Mutex
+}
+
+func (c *codec) init(log telegraf.Logger) error {
+	if err := choice.Check(c.Type, []string{"object", "cayenne_lpp", "starlark"}); err != nil {
+		return fmt.Errorf("invalid type: %w", err)
+	}
+	for i, eui := range c.DevEUIs {
+		c.DevEUIs[i] = strings.ToLower(eui)
+	}
+
+	if c.Type != "starlark" {
+		if c.Source != "" || c.Script != "" {
+			return errors.New("source and script are only supported for starlark codecs")
+		}
+		return nil
+	}
+
+	c.Log = log
+	c.StarlarkLoadFunc = common.LoadFunc
+	if err := c.Common.Init(); err != nil {
+		return err
+	}
+	// The script must define a function decoding the payload of the given
+	// port, passed as list of byte values, into a dictionary of fields
+	return c.AddFunction("decode", starlark.MakeInt(0), starlark.NewList(nil))
+}
+
+func (c *codec) matches(u *uplink) bool {
+	if len(c.DevEUIs) > 0 && !slices.Contains(c.DevEUIs, strings.ToLower(u.DeviceInfo.DevEUI)) {
+		return false
+	}
+	if len(c.DeviceProfiles) > 0 && !slices.Contains(c.DeviceProfiles, u.DeviceInfo.DeviceProfileName) {
+		return false
+	}
+	if len(c.FPorts) > 0 && !slices.Contains(c.FPorts, int(u.FPort)) {
+		return false
+	}
+	return true
+}
+
+func (c *codec) decode(u *uplink) (map[string]interface{}, error) {
+	switch c.Type {
+	case "cayenne_lpp":
+		return decodeCayenneLPP(u.Data)
+	case "starlark":
+		return c.decodeStarlark(u)
+	}
+
+	// Use the object decoded by the codec of the ChirpStack device profile
+	fields := make(map[string]interface{})
+	flatten(fields, "", u.Object)
+	return fields, nil
+}
+
+func (c *codec) decodeStarlark(u *uplink) (map[string]interface{}, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	parameters, found := c.GetParameters("decode")
+	if !found {
+		return nil, errors.New("the parameters of the decode function could not be found")
+	}
+	parameters[0] = starlark.MakeInt(int(u.FPort))
+	payload := make([]starlark.Value, 0, len(u.Data))
+	for _, b := range u.Data {
+		payload = append(payload, starlark.MakeInt(int(b)))
+	}
+	parameters[1] = starlark.NewList(payload)
+
+	rv, err := c.Call("decode")
+	if err != nil {
+		c.LogError(err)
+		return nil, err
+	}
+	value, err := fromStarlark(rv)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := value.(map[string]interface{}); !ok && value != nil {
+		return nil, fmt.Errorf("decode function returned %s instead of a dictionary", rv.Type())
+	}
+
+	fields := make(map[string]interface{})
+	flatten(fields, "", value)
+	return fields, nil
+}
+
+// fromStarlark converts the returned value to the equivalent of a decoded
+// JSON object
+func fromStarlark(value starlark.Value) (interface{}, error) {
+	switch v := value.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.Int:
+		n, ok := v.Int64()
+		if !ok {
+			return nil, fmt.Errorf("cannot represent integer %v as int64", v)
+		}
+		return n, nil
+	case starlark.Float:
+		return float64(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Bytes:
+		return string(v), nil
+	case *starlark.Dict:
+		result := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, fmt.Errorf("invalid key type %s", item[0].Type())
+			}
+			x, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			result[key] = x
+		}
+		return result, nil
+	case starlark.Indexable:
+		result := make([]interface{}, 0, v.Len())
+		for i := range v.Len() {
+			x, err := fromStarlark(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, x)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", value.Type())
+}
@@ -0,0 +1,115 @@
+package chirpstack
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// uplink is the JSON encoded uplink event of ChirpStack v4 as sent via the
+// MQTT and HTTP integrations
+type uplink struct {
+	Time       *time.Time `json:"time"`
+	DeviceInfo struct {
+		ApplicationID     string            `json:"applicationId"`
+		ApplicationName   string            `json:"applicationName"`
+		DeviceProfileName string            `json:"deviceProfileName"`
+		DeviceName        string            `json:"deviceName"`
+		DevEUI            string            `json:"devEui"`
+		Tags              map[string]string `json:"tags"`
+	} `json:"deviceInfo"`
+	DevAddr   string                 `json:"devAddr"`
+	DR        *int                   `json:"dr"`
+	FCnt      *uint32                `json:"fCnt"`
+	FPort     uint8                  `json:"fPort"`
+	Confirmed bool                   `json:"confirmed"`
+	Data      []byte                 `json:"data"`
+	Object    map[string]interface{} `json:"object"`
+	RxInfo    []rxInfo               `json:"rxInfo"`
+	TxInfo    struct {
+		Frequency  uint32 `json:"frequency"`
+		Modulation struct {
+			LoRa *struct {
+				Bandwidth       uint32 `json:"bandwidth"`
+				SpreadingFactor uint32 `json:"spreadingFactor"`
+			} `json:"lora"`
+		} `json:"modulation"`
+	} `json:"txInfo"`
+}
+
+type rxInfo struct {
+	GatewayID string     `json:"gatewayId"`
+	GwTime    *time.Time `json:"gwTime"`
+	RSSI      int        `json:"rssi"`
+	SNR       float64    `json:"snr"`
+}
+
+func parseUplink(buf []byte) (*uplink, error) {
+	var u uplink
+	if err := json.Unmarshal(buf, &u); err != nil {
+		return nil, err
+	}
+	if u.DeviceInfo.DevEUI == "" {
+		return nil, errors.New("missing device EUI")
+	}
+	return &u, nil
+}
+
+// bestGateway returns the reception with the best signal quality, i.e. the
+// highest SNR and RSSI
+func (u *uplink) bestGateway() *rxInfo {
+	if len(u.RxInfo) == 0 {
+		return nil
+	}
+	best := slices.MaxFunc(u.RxInfo, func(a, b rxInfo) int {
+		if a.SNR != b.SNR {
+			if a.SNR < b.SNR {
+				return -1
+			}
+			return 1
+		}
+		return a.RSSI - b.RSSI
+	})
+	return &best
+}
+
+// timestamp returns the time the uplink was received by the network server
+// or the gateway, or the zero time if unknown
+func (u *uplink) timestamp() time.Time {
+	if u.Time != nil {
+		return *u.Time
+	}
+	if gw := u.bestGateway(); gw != nil && gw.GwTime != nil {
+		return *gw.GwTime
+	}
+	return time.Time{}
+}
+
+// flatten adds the values of the nested structure as fields with the keys
+// joined by underscores
+func flatten(fields map[string]interface{}, prefix string, value interface{}) {
+	key := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+		return prefix + "_" + k
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			flatten(fields, key(k), item)
+		}
+	case []interface{}:
+		for i, item := range v {
+			flatten(fields, key(strconv.Itoa(i)), item)
+		}
+	case nil:
+	default:
+		if prefix != "" {
+			fields[prefix] = v
+		}
+	}
+}
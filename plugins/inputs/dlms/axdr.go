@@ -0,0 +1,227 @@
+package dlms
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Tags of the A-XDR encoded COSEM data types
+const (
+	tagNull               = 0
+	tagArray              = 1
+	tagStructure          = 2
+	tagBoolean            = 3
+	tagBitString          = 4
+	tagDoubleLong         = 5
+	tagDoubleLongUnsigned = 6
+	tagOctetString        = 9
+	tagVisibleString      = 10
+	tagUTF8String         = 12
+	tagBCD                = 13
+	tagInteger            = 15
+	tagLong               = 16
+	tagUnsigned           = 17
+	tagLongUnsigned       = 18
+	tagLong64             = 20
+	tagLong64Unsigned     = 21
+	tagEnum               = 22
+	tagFloat32            = 23
+	tagFloat64            = 24
+	tagDateTime           = 25
+	tagDate               = 26
+	tagTime               = 27
+)
+
+var errShort = errors.New("data too short")
+
+// Sizes of the fixed-length data types
+var fixedSizes = map[byte]int{
+	tagBoolean:            1,
+	tagDoubleLong:         4,
+	tagDoubleLongUnsigned: 4,
+	tagBCD:                1,
+	tagInteger:            1,
+	tagLong:               2,
+	tagUnsigned:           1,
+	tagLongUnsigned:       2,
+	tagLong64:             8,
+	tagLong64Unsigned:     8,
+	tagEnum:               1,
+	tagFloat32:            4,
+	tagFloat64:            8,
+	tagDateTime:           12,
+	tagDate:               5,
+	tagTime:               4,
+}
+
+// decodeLength decodes the variable-length encoded length of arrays and
+// strings returning the length and the number of bytes consumed
+func decodeLength(buf []byte) (int, int, error) {
+	if len(buf) < 1 {
+		return 0, 0, errShort
+	}
+	if buf[0] < 0x80 {
+		return int(buf[0]), 1, nil
+	}
+	n := int(buf[0] & 0x7f)
+	if n == 0 || n > 4 || len(buf) < 1+n {
+		return 0, 0, fmt.Errorf("invalid length encoding 0x%02x", buf[0])
+	}
+	var length int
+	for _, b := range buf[1 : 1+n] {
+		length = length<<8 | int(b)
+	}
+	return length, 1 + n, nil
+}
+
+func appendLength(buf []byte, length int) []byte {
+	switch {
+	case length < 0x80:
+		return append(buf, byte(length))
+	case length <= 0xff:
+		return append(buf, 0x81, byte(length))
+	}
+	return append(buf, 0x82, byte(length>>8), byte(length))
+}
+
+// decodeData decodes a COSEM data value returning the value and the number
+// of bytes consumed. Values are returned as nil, bool, int64, uint64,
+// float64, string, []byte, time.Time or []interface{} for arrays and
+// structures.
+func decodeData(buf []byte) (interface{}, int, error) {
+	if len(buf) < 1 {
+		return nil, 0, errShort
+	}
+	tag, data := buf[0], buf[1:]
+
+	switch tag {
+	case tagNull:
+		return nil, 1, nil
+	case tagArray, tagStructure:
+		count, n, err := decodeLength(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset := 1 + n
+		items := make([]interface{}, 0, count)
+		for range count {
+			v, n, err := decodeData(buf[offset:])
+			if err != nil {
+				return nil, 0, err
+			}
+			items = append(items, v)
+			offset += n
+		}
+		return items, offset, nil
+	case tagBitString:
+		bits, n, err := decodeLength(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		size := (bits + 7) / 8
+		if len(data) < n+size {
+			return nil, 0, errShort
+		}
+		var v uint64
+		for _, b := range data[n : n+size] {
+			v = v<<8 | uint64(b)
+		}
+		return v, 1 + n + size, nil
+	case tagOctetString, tagVisibleString, tagUTF8String:
+		length, n, err := decodeLength(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(data) < n+length {
+			return nil, 0, errShort
+		}
+		if tag == tagOctetString {
+			return append([]byte(nil), data[n:n+length]...), 1 + n + length, nil
+		}
+		return string(data[n : n+length]), 1 + n + length, nil
+	}
+
+	size, found := fixedSizes[tag]
+	if !found {
+		return nil, 0, fmt.Errorf("unsupported data type %d", tag)
+	}
+	if len(data) < size {
+		return nil, 0, errShort
+	}
+	data = data[:size]
+
+	var v interface{}
+	switch tag {
+	case tagBoolean:
+		v = data[0] != 0
+	case tagDoubleLong:
+		v = int64(int32(binary.BigEndian.Uint32(data)))
+	case tagDoubleLongUnsigned:
+		v = uint64(binary.BigEndian.Uint32(data))
+	case tagBCD:
+		v = int64(data[0]>>4)*10 + int64(data[0]&0x0f)
+	case tagInteger:
+		v = int64(int8(data[0]))
+	case tagLong:
+		v = int64(int16(binary.BigEndian.Uint16(data)))
+	case tagUnsigned, tagEnum:
+		v = uint64(data[0])
+	case tagLongUnsigned:
+		v = uint64(binary.BigEndian.Uint16(data))
+	case tagLong64:
+		v = int64(binary.BigEndian.Uint64(data))
+	case tagLong64Unsigned:
+		v = binary.BigEndian.Uint64(data)
+	case tagFloat32:
+		v = float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case tagFloat64:
+		v = math.Float64frombits(binary.BigEndian.Uint64(data))
+	case tagDateTime:
+		t, err := decodeDateTime(data)
+		if err != nil {
+			return nil, 0, err
+		}
+		v = t
+	default:
+		// Date and time only values are kept in their raw form
+		v = append([]byte(nil), data...)
+	}
+	return v, 1 + size, nil
+}
+
+// decodeDateTime decodes the 12-byte COSEM date-time consisting of year,
+// month, day, weekday, hour, minute, second, hundredths, deviation from UTC
+// in minutes and clock status
+func decodeDateTime(buf []byte) (time.Time, error) {
+	if len(buf) != 12 {
+		return time.Time{}, fmt.Errorf("invalid date-time length %d", len(buf))
+	}
+	year := int(binary.BigEndian.Uint16(buf[0:2]))
+	month, day := int(buf[2]), int(buf[3])
+	hour, minute, second := int(buf[5]), int(buf[6]), int(buf[7])
+	if year == 0xffff || month > 12 || day > 31 || hour > 23 || minute > 59 || second > 59 {
+		return time.Time{}, errors.New("date-time not specified")
+	}
+	var nsec int
+	if buf[8] != 0xff {
+		nsec = int(buf[8]) * int(10*time.Millisecond)
+	}
+
+	// The deviation is the offset of local time to UTC in minutes with the
+	// opposite sign, e.g. -60 for CET
+	loc := time.UTC
+	if deviation := int16(binary.BigEndian.Uint16(buf[9:11])); deviation != -32768 {
+		loc = time.FixedZone("", -int(deviation)*60)
+	}
+	return time.Date(year, time.Month(month), day, hour, minute, second, nsec, loc), nil
+}
+
+// encodeOctetString encodes the value as A-XDR octet-string
+func encodeOctetString(buf, value []byte) []byte {
+	buf = append(buf, tagOctetString)
+	buf = appendLength(buf, len(value))
+	return append(buf, value...)
+}
@@ -0,0 +1,360 @@
+package dlms
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// xDLMS APDU tags
+const (
+	apduInitiateRequest     = 0x01
+	apduInitiateResponse    = 0x08
+	apduConfirmedServiceErr = 0x0e
+	apduAARQ                = 0x60
+	apduAARE                = 0x61
+	apduRLRQ                = 0x62
+	apduRLRE                = 0x63
+	apduGetRequest          = 0xc0
+	apduGetResponse         = 0xc4
+	apduExceptionResponse   = 0xd8
+)
+
+// Types of the GET service
+const (
+	getNormal        = 0x01
+	getNext          = 0x02
+	getWithDatablock = 0x02
+)
+
+// Invoke ID 1 with confirmed service class and high priority
+const invokeIDAndPriority = 0xc1
+
+// Application context name of logical name referencing without ciphering
+var contextLNNoCiphering = []byte{0x60, 0x85, 0x74, 0x05, 0x08, 0x01, 0x01}
+
+// Name of the low level security (password) authentication mechanism
+var mechanismLowLevel = []byte{0x60, 0x85, 0x74, 0x05, 0x08, 0x02, 0x01}
+
+// Conformance block proposing get, set, action, selective access and block
+// transfer with get
+var proposedConformance = []byte{0x00, 0x7e, 0x1f}
+
+// Maximum PDU size proposed to the meter
+const proposedMaxPDUSize = 0xffff
+
+// Descriptions of the data access results
+var dataAccessResults = map[byte]string{
+	1:   "hardware fault",
+	2:   "temporary failure",
+	3:   "read-write denied",
+	4:   "object undefined",
+	9:   "object class inconsistent",
+	11:  "object unavailable",
+	12:  "type unmatched",
+	13:  "scope of access violated",
+	14:  "data block unavailable",
+	15:  "long get aborted",
+	16:  "no long get in progress",
+	19:  "data block number invalid",
+	250: "other reason",
+}
+
+// Descriptions of the diagnostics of the association result
+var associationDiagnostics = map[byte]string{
+	1:  "no reason given",
+	2:  "application context name not supported",
+	11: "authentication mechanism name not recognised",
+	12: "authentication mechanism name required",
+	13: "authentication failure",
+	14: "authentication required",
+}
+
+// accessError is the data access result of a failed request. The
+// association is still valid after such errors.
+type accessError struct {
+	result byte
+}
+
+func (e *accessError) Error() string {
+	if desc, found := dataAccessResults[e.result]; found {
+		return desc
+	}
+	return fmt.Sprintf("data access result %d", e.result)
+}
+
+// obis is the logical name of a COSEM object
+type obis [6]byte
+
+// parseOBIS parses an OBIS code in the reduced "A-B:C.D.E*F" notation or as
+// six dot-separated values. The billing period F defaults to 255 if omitted.
+func parseOBIS(s string) (obis, error) {
+	var code obis
+	parts := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '.' || r == '-' || r == ':' || r == '*'
+	})
+	if len(parts) == 5 {
+		parts = append(parts, "255")
+	}
+	if len(parts) != 6 {
+		return code, errors.New("expected six value groups")
+	}
+	for i, p := range parts {
+		v, err := strconv.ParseUint(p, 10, 8)
+		if err != nil {
+			return code, fmt.Errorf("invalid value group %q", p)
+		}
+		code[i] = byte(v)
+	}
+	return code, nil
+}
+
+func (o obis) String() string {
+	return fmt.Sprintf("%d-%d:%d.%d.%d.%d", o[0], o[1], o[2], o[3], o[4], o[5])
+}
+
+// client implements the COSEM application layer on top of a transport
+type client struct {
+	transport
+}
+
+// associate establishes the application association using the given
+// authentication mechanism
+func (c *client) associate(authentication string, password []byte) error {
+	if err := c.send(encodeAARQ(authentication, password)); err != nil {
+		return err
+	}
+	resp, err := c.receive()
+	if err != nil {
+		return err
+	}
+	return decodeAARE(resp)
+}
+
+// release releases the application association
+func (c *client) release() error {
+	if err := c.send([]byte{apduRLRQ, 0x03, 0x80, 0x01, 0x00}); err != nil {
+		return err
+	}
+	resp, err := c.receive()
+	if err != nil {
+		return err
+	}
+	if len(resp) == 0 {
+		return errors.New("empty response to release request")
+	}
+	if resp[0] != apduRLRE {
+		return fmt.Errorf("unexpected response 0x%02x to release request", resp[0])
+	}
+	return nil
+}
+
+// get reads the attribute of the object collecting the data blocks of long
+// responses
+func (c *client) get(classID uint16, name obis, attribute int8) (interface{}, error) {
+	req := []byte{apduGetRequest, getNormal, invokeIDAndPriority}
+	req = binary.BigEndian.AppendUint16(req, classID)
+	req = append(req, name[:]...)
+	req = append(req, byte(attribute), 0x00)
+
+	var data []byte
+	for block := uint32(1); ; block++ {
+		if err := c.send(req); err != nil {
+			return nil, err
+		}
+		resp, err := c.receive()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkResponse(resp); err != nil {
+			return nil, err
+		}
+		if len(resp) < 4 || resp[2] != invokeIDAndPriority {
+			return nil, errors.New("invalid GET response")
+		}
+
+		switch resp[1] {
+		case getNormal:
+			if resp[3] != 0 {
+				if len(resp) < 5 {
+					return nil, errors.New("invalid GET response")
+				}
+				return nil, &accessError{result: resp[4]}
+			}
+			v, _, err := decodeData(resp[4:])
+			return v, err
+		case getWithDatablock:
+			last, raw, err := decodeDatablock(resp[3:], block)
+			if err != nil {
+				return nil, err
+			}
+			data = append(data, raw...)
+			if last {
+				v, _, err := decodeData(data)
+				return v, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported GET response type %d", resp[1])
+		}
+
+		// Request the next block
+		req = []byte{apduGetRequest, getNext, invokeIDAndPriority}
+		req = binary.BigEndian.AppendUint32(req, block)
+	}
+}
+
+// decodeDatablock decodes the data block of a long GET response returning
+// whether it is the last block and the raw data
+func decodeDatablock(buf []byte, expected uint32) (bool, []byte, error) {
+	if len(buf) < 7 {
+		return false, nil, errors.New("invalid data block")
+	}
+	last := buf[0] != 0
+	if n := binary.BigEndian.Uint32(buf[1:5]); n != expected {
+		return false, nil, fmt.Errorf("unexpected block number %d, expected %d", n, expected)
+	}
+	if buf[5] != 0 {
+		return false, nil, &accessError{result: buf[6]}
+	}
+	length, n, err := decodeLength(buf[6:])
+	if err != nil {
+		return false, nil, err
+	}
+	if len(buf) < 6+n+length {
+		return false, nil, errShort
+	}
+	return last, buf[6+n : 6+n+length], nil
+}
+
+// checkResponse returns an error for responses reporting a failure of the
+// service instead of the result
+func checkResponse(resp []byte) error {
+	if len(resp) == 0 {
+		return errors.New("empty response")
+	}
+	switch resp[0] {
+	case apduGetResponse:
+		return nil
+	case apduExceptionResponse:
+		if len(resp) < 3 {
+			return errors.New("exception response")
+		}
+		return fmt.Errorf("exception response with state error %d and service error %d", resp[1], resp[2])
+	case apduConfirmedServiceErr:
+		return errors.New("confirmed service error")
+	}
+	return fmt.Errorf("unexpected response 0x%02x", resp[0])
+}
+
+// encodeAARQ encodes the association request consisting of the BER encoded
+// ACSE fields and the xDLMS initiate request as user information
+func encodeAARQ(authentication string, password []byte) []byte {
+	var body []byte
+	body = appendTLV(body, 0xa1, appendTLV(nil, 0x06, contextLNNoCiphering))
+	if authentication == "low" {
+		// Sender ACSE requirements with the authentication functional unit
+		body = appendTLV(body, 0x8a, []byte{0x07, 0x80})
+		body = appendTLV(body, 0x8b, mechanismLowLevel)
+		body = appendTLV(body, 0xac, appendTLV(nil, 0x80, password))
+	}
+
+	initiate := []byte{apduInitiateRequest, 0x00, 0x00, 0x00, 0x06, 0x5f, 0x1f, 0x04, 0x00}
+	initiate = append(initiate, proposedConformance...)
+	initiate = binary.BigEndian.AppendUint16(initiate, proposedMaxPDUSize)
+	body = appendTLV(body, 0xbe, appendTLV(nil, 0x04, initiate))
+
+	return appendTLV(nil, apduAARQ, body)
+}
+
+// decodeAARE decodes the association response returning an error if the
+// association or the proposed xDLMS context was rejected
+func decodeAARE(buf []byte) error {
+	tag, body, _, err := readTLV(buf)
+	if err != nil {
+		return fmt.Errorf("decoding association response failed: %w", err)
+	}
+	if tag != apduAARE {
+		return fmt.Errorf("unexpected response 0x%02x to association request", tag)
+	}
+
+	result, diagnostic := -1, byte(0)
+	var info []byte
+	for len(body) > 0 {
+		tag, value, n, err := readTLV(body)
+		if err != nil {
+			return fmt.Errorf("decoding association response failed: %w", err)
+		}
+		body = body[n:]
+
+		switch tag {
+		case 0xa2:
+			// Association result as integer
+			if len(value) == 3 && value[0] == 0x02 {
+				result = int(value[2])
+			}
+		case 0xa3:
+			// Diagnostic of the service user or provider as integer
+			if len(value) == 5 {
+				diagnostic = value[4]
+			}
+		case 0xbe:
+			// User information as octet string
+			if _, v, _, err := readTLV(value); err == nil {
+				info = v
+			}
+		}
+	}
+
+	if result != 0 {
+		reason := associationDiagnostics[diagnostic]
+		if reason == "" {
+			reason = "diagnostic " + strconv.Itoa(int(diagnostic))
+		}
+		return fmt.Errorf("association rejected: %s", reason)
+	}
+
+	if len(info) == 0 {
+		return errors.New("missing initiate response")
+	}
+	if info[0] == apduConfirmedServiceErr {
+		return errors.New("initiate request rejected")
+	}
+	if info[0] != apduInitiateResponse {
+		return fmt.Errorf("unexpected user information 0x%02x", info[0])
+	}
+
+	// Skip the optional negotiated quality of service
+	pos := 2
+	if len(info) > 1 && info[1] != 0 {
+		pos++
+	}
+	// DLMS version, conformance block and maximum PDU size
+	if len(info) < pos+10 || info[pos+1] != 0x5f || info[pos+2] != 0x1f {
+		return errors.New("invalid initiate response")
+	}
+	return nil
+}
+
+func appendTLV(buf []byte, tag byte, value []byte) []byte {
+	buf = append(buf, tag)
+	buf = appendLength(buf, len(value))
+	return append(buf, value...)
+}
+
+// readTLV reads a BER encoded tag, length and value returning the number of
+// bytes consumed
+func readTLV(buf []byte) (byte, []byte, int, error) {
+	if len(buf) < 2 {
+		return 0, nil, 0, errShort
+	}
+	length, n, err := decodeLength(buf[1:])
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	if len(buf) < 1+n+length {
+		return 0, nil, 0, errShort
+	}
+	return buf[0], buf[1+n : 1+n+length], 1 + n + length, nil
+}
@@ -0,0 +1,439 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package dlms
+
+import (
+	"bufio"
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// COSEM interface classes with special handling
+const (
+	classRegister         = 3
+	classExtendedRegister = 4
+	classDemandRegister   = 5
+	classClock            = 8
+)
+
+// Symbols of the physical units of the COSEM unit enumeration
+var units = map[uint64]string{
+	1:  "a",
+	2:  "mo",
+	3:  "wk",
+	4:  "d",
+	5:  "h",
+	6:  "min",
+	7:  "s",
+	8:  "deg",
+	9:  "degC",
+	10: "currency",
+	11: "m",
+	12: "m/s",
+	13: "m3",
+	14: "m3",
+	15: "m3/h",
+	16: "m3/h",
+	17: "m3/d",
+	18: "m3/d",
+	19: "l",
+	20: "kg",
+	21: "N",
+	22: "Nm",
+	23: "Pa",
+	24: "bar",
+	25: "J",
+	26: "J/h",
+	27: "W",
+	28: "VA",
+	29: "var",
+	30: "Wh",
+	31: "VAh",
+	32: "varh",
+	33: "A",
+	34: "C",
+	35: "V",
+	36: "V/m",
+	37: "F",
+	38: "ohm",
+	39: "ohm m2/m",
+	40: "Wb",
+	41: "T",
+	42: "A/m",
+	43: "H",
+	44: "Hz",
+	45: "1/(Wh)",
+	46: "1/(varh)",
+	47: "1/(VAh)",
+	48: "V2h",
+	49: "A2h",
+	50: "kg/s",
+	51: "S",
+	52: "K",
+	53: "1/(V2h)",
+	54: "1/(A2h)",
+	55: "1/m3",
+	56: "%",
+	57: "Ah",
+	60: "Wh/m3",
+	61: "J/m3",
+	62: "mol%",
+	63: "g/m3",
+	64: "Pa s",
+	65: "J/kg",
+	70: "dBm",
+	71: "dBuV",
+	72: "dB",
+	// Other and count
+	254: "",
+	255: "",
+}
+
+type DLMS struct {
+	Address         string          `toml:"address"`
+	Transport       string          `toml:"transport"`
+	ClientAddress   uint16          `toml:"client_address"`
+	ServerAddress   uint16          `toml:"server_address"`
+	PhysicalAddress uint16          `toml:"physical_address"`
+	Authentication  string          `toml:"authentication"`
+	Password        config.Secret   `toml:"password"`
+	Timeout         config.Duration `toml:"timeout"`
+	Objects         []object        `toml:"object"`
+	Log             telegraf.Logger `toml:"-"`
+
+	host    string
+	scalers map[int]*scalerUnit
+}
+
+// object is a COSEM object attribute to read
+type object struct {
+	Name      string `toml:"name"`
+	OBIS      string `toml:"obis"`
+	ClassID   uint16 `toml:"class_id"`
+	Attribute int8   `toml:"attribute"`
+
+	code obis
+}
+
+// scalerUnit is the decimal scaler and the physical unit of a register value
+type scalerUnit struct {
+	scaler int64
+	unit   string
+}
+
+func (*DLMS) SampleConfig() string {
+	return sampleConfig
+}
+
+func (d *DLMS) Init() error {
+	if d.Address == "" {
+		return errors.New("'address' must be specified")
+	}
+	var nerr *net.AddrError
+	if _, _, err := net.SplitHostPort(d.Address); errors.As(err, &nerr) && strings.Contains(nerr.Err, "missing port") {
+		d.Address += ":4059"
+	}
+	host, _, err := net.SplitHostPort(d.Address)
+	if err != nil {
+		return fmt.Errorf("invalid 'address' %q: %w", d.Address, err)
+	}
+	d.host = host
+
+	if d.Transport == "" {
+		d.Transport = "wrapper"
+	}
+	if err := choice.Check(d.Transport, []string{"wrapper", "hdlc"}); err != nil {
+		return fmt.Errorf("invalid 'transport': %w", err)
+	}
+	if d.Transport == "hdlc" && (d.ClientAddress > 0x7f || d.ServerAddress > 0x3fff || d.PhysicalAddress > 0x3fff) {
+		return errors.New("address exceeds the range of HDLC addresses")
+	}
+
+	if d.Authentication == "" {
+		d.Authentication = "none"
+	}
+	if err := choice.Check(d.Authentication, []string{"none", "low"}); err != nil {
+		return fmt.Errorf("invalid 'authentication': %w", err)
+	}
+	if d.Authentication == "low" && d.Password.Empty() {
+		return errors.New("'password' required for low level authentication")
+	}
+	if d.Timeout <= 0 {
+		return errors.New("'timeout' must be positive")
+	}
+
+	if len(d.Objects) == 0 {
+		return errors.New("no objects configured")
+	}
+	names := make(map[string]bool, len(d.Objects))
+	for i := range d.Objects {
+		o := &d.Objects[i]
+		code, err := parseOBIS(o.OBIS)
+		if err != nil {
+			return fmt.Errorf("invalid OBIS code %q of object %d: %w", o.OBIS, i+1, err)
+		}
+		o.code = code
+		if o.Name == "" {
+			o.Name = code.String()
+		}
+		if names[o.Name] {
+			return fmt.Errorf("duplicate object name %q", o.Name)
+		}
+		names[o.Name] = true
+		if o.ClassID == 0 {
+			o.ClassID = classRegister
+		}
+		if o.Attribute == 0 {
+			o.Attribute = 2
+		}
+	}
+
+	d.scalers = make(map[int]*scalerUnit)
+
+	return nil
+}
+
+func (d *DLMS) Gather(acc telegraf.Accumulator) error {
+	c, err := d.connect()
+	if err != nil {
+		return fmt.Errorf("connecting to %s failed: %w", d.Address, err)
+	}
+	defer c.close()
+
+	if err := d.associate(c); err != nil {
+		return fmt.Errorf("association with %s failed: %w", d.Address, err)
+	}
+
+	fields := make(map[string]interface{}, len(d.Objects))
+	for i, o := range d.Objects {
+		if err := d.read(c, i, fields); err != nil {
+			acc.AddError(fmt.Errorf("reading %q (%s) failed: %w", o.Name, o.code, err))
+			// The connection is unusable after errors other than a denied
+			// access to the object
+			var aerr *accessError
+			if !errors.As(err, &aerr) {
+				break
+			}
+		}
+	}
+
+	if err := c.release(); err != nil {
+		d.Log.Debugf("Releasing association with %s failed: %v", d.Address, err)
+	}
+
+	if len(fields) > 0 {
+		acc.AddFields("dlms", fields, map[string]string{"address": d.host}, time.Now())
+	}
+	return nil
+}
+
+func (d *DLMS) connect() (*client, error) {
+	timeout := time.Duration(d.Timeout)
+	conn, err := net.DialTimeout("tcp", d.Address, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Transport == "wrapper" {
+		return &client{
+			transport: &wrapper{
+				conn:    conn,
+				timeout: timeout,
+				client:  d.ClientAddress,
+				server:  d.ServerAddress,
+			},
+		}, nil
+	}
+
+	h := &hdlc{
+		conn:    conn,
+		reader:  bufio.NewReader(conn),
+		timeout: timeout,
+		client:  []byte{byte(d.ClientAddress)<<1 | 1},
+		server:  hdlcAddress(d.ServerAddress, d.PhysicalAddress),
+	}
+	if err := h.connect(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &client{transport: h}, nil
+}
+
+func (d *DLMS) associate(c *client) error {
+	if d.Authentication != "low" {
+		return c.associate(d.Authentication, nil)
+	}
+
+	password, err := d.Password.Get()
+	if err != nil {
+		return fmt.Errorf("getting password failed: %w", err)
+	}
+	defer password.Destroy()
+	return c.associate(d.Authentication, password.Bytes())
+}
+
+// read reads the object with the given index and adds the resulting fields
+func (d *DLMS) read(c *client, idx int, fields map[string]interface{}) error {
+	o := &d.Objects[idx]
+
+	// Values of registers are scaled by the scaler of the scaler-unit
+	// attribute, the attribute is static and only read once
+	var su *scalerUnit
+	if attr := scalerAttribute(o.ClassID, o.Attribute); attr > 0 {
+		su = d.scalers[idx]
+		if su == nil {
+			v, err := c.get(o.ClassID, o.code, attr)
+			if err != nil {
+				return fmt.Errorf("reading scaler and unit failed: %w", err)
+			}
+			if su, err = decodeScalerUnit(v); err != nil {
+				return err
+			}
+			d.scalers[idx] = su
+		}
+	}
+
+	v, err := c.get(o.ClassID, o.code, o.Attribute)
+	if err != nil {
+		return err
+	}
+
+	// The time of clock objects is transferred as octet-string
+	if raw, ok := v.([]byte); ok && o.ClassID == classClock && o.Attribute == 2 {
+		if t, err := decodeDateTime(raw); err == nil {
+			v = t
+		}
+	}
+
+	if su != nil {
+		v = scale(v, su.scaler)
+		if su.unit != "" {
+			fields[o.Name+"_unit"] = su.unit
+		}
+	}
+	addFields(fields, o.Name, v)
+	return nil
+}
+
+// scalerAttribute returns the scaler-unit attribute for values of registers
+// or zero if the attribute has no scaler
+func scalerAttribute(classID uint16, attribute int8) int8 {
+	switch classID {
+	case classRegister, classExtendedRegister:
+		if attribute == 2 {
+			return 3
+		}
+	case classDemandRegister:
+		if attribute == 2 || attribute == 3 {
+			return 4
+		}
+	}
+	return 0
+}
+
+func decodeScalerUnit(v interface{}) (*scalerUnit, error) {
+	items, ok := v.([]interface{})
+	if !ok || len(items) != 2 {
+		return nil, errors.New("invalid scaler and unit")
+	}
+	scaler, ok := items[0].(int64)
+	if !ok {
+		return nil, errors.New("invalid scaler")
+	}
+	code, ok := items[1].(uint64)
+	if !ok {
+		return nil, errors.New("invalid unit")
+	}
+	unit, found := units[code]
+	if !found {
+		unit = strconv.FormatUint(code, 10)
+	}
+	return &scalerUnit{scaler: scaler, unit: unit}, nil
+}
+
+// scale applies the decimal scaler to numeric values
+func scale(v interface{}, scaler int64) interface{} {
+	if scaler == 0 {
+		return v
+	}
+	var x float64
+	switch n := v.(type) {
+	case int64:
+		x = float64(n)
+	case uint64:
+		x = float64(n)
+	case float64:
+		x = n
+	default:
+		return v
+	}
+	// Divide for negative scalers to avoid rounding errors of the inexact
+	// representation of the factor
+	if scaler < 0 {
+		return x / math.Pow10(int(-scaler))
+	}
+	return x * math.Pow10(int(scaler))
+}
+
+// addFields adds the value using the given name. Arrays and structures are
+// flattened using the index of the element as suffix.
+func addFields(fields map[string]interface{}, name string, v interface{}) {
+	switch x := v.(type) {
+	case nil:
+	case []interface{}:
+		for i, item := range x {
+			addFields(fields, name+"_"+strconv.Itoa(i), item)
+		}
+	case []byte:
+		// Octet strings often contain printable identifiers such as the
+		// serial number, other content is represented as hex string
+		if isPrintable(x) {
+			fields[name] = string(x)
+		} else {
+			fields[name] = hex.EncodeToString(x)
+		}
+	case time.Time:
+		fields[name] = x.UTC().Format(time.RFC3339Nano)
+	default:
+		fields[name] = v
+	}
+}
+
+func isPrintable(buf []byte) bool {
+	if len(buf) == 0 {
+		return false
+	}
+	for _, b := range buf {
+		if b > unicode.MaxASCII || !unicode.IsPrint(rune(b)) {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	inputs.Add("dlms", func() telegraf.Input {
+		return &DLMS{
+			ClientAddress:   16,
+			ServerAddress:   1,
+			PhysicalAddress: 17,
+			Timeout:         config.Duration(5 * time.Second),
+		}
+	})
+}
@@ -0,0 +1,504 @@
+package dlms
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *DLMS
+		expected string
+	}{
+		{
+			name:     "no address",
+			plugin:   &DLMS{},
+			expected: "'address' must be specified",
+		},
+		{
+			name:     "invalid transport",
+			plugin:   &DLMS{Address: "127.0.0.1", Transport: "udp"},
+			expected: "invalid 'transport'",
+		},
+		{
+			name:     "invalid HDLC address",
+			plugin:   &DLMS{Address: "127.0.0.1", Transport: "hdlc", ClientAddress: 256},
+			expected: "address exceeds the range of HDLC addresses",
+		},
+		{
+			name:     "invalid authentication",
+			plugin:   &DLMS{Address: "127.0.0.1", Authentication: "high"},
+			expected: "invalid 'authentication'",
+		},
+		{
+			name:     "missing password",
+			plugin:   &DLMS{Address: "127.0.0.1", Authentication: "low"},
+			expected: "'password' required for low level authentication",
+		},
+		{
+			name:     "no objects",
+			plugin:   &DLMS{Address: "127.0.0.1"},
+			expected: "no objects configured",
+		},
+		{
+			name: "invalid OBIS code",
+			plugin: &DLMS{
+				Address: "127.0.0.1",
+				Objects: []object{{OBIS: "1-0:1.8"}},
+			},
+			expected: `invalid OBIS code "1-0:1.8" of object 1: expected six value groups`,
+		},
+		{
+			name: "duplicate name",
+			plugin: &DLMS{
+				Address: "127.0.0.1",
+				Objects: []object{{OBIS: "1-0:1.8.0.255"}, {OBIS: "1.0.1.8.0.255"}},
+			},
+			expected: `duplicate object name "1-0:1.8.0.255"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Timeout = config.Duration(time.Second)
+			tt.plugin.Log = testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestParseOBIS(t *testing.T) {
+	for _, s := range []string{"1-0:1.8.0.255", "1-0:1.8.0*255", "1.0.1.8.0.255", "1-0:1.8.0"} {
+		code, err := parseOBIS(s)
+		require.NoError(t, err, s)
+		require.Equal(t, obis{1, 0, 1, 8, 0, 255}, code, s)
+	}
+	_, err := parseOBIS("1-0:1.8.0.256")
+	require.EqualError(t, err, `invalid value group "256"`)
+}
+
+func TestDecodeData(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected interface{}
+	}{
+		{
+			name:     "double-long",
+			data:     []byte{tagDoubleLong, 0xff, 0xff, 0xff, 0xfe},
+			expected: int64(-2),
+		},
+		{
+			name:     "long-unsigned",
+			data:     []byte{tagLongUnsigned, 0x09, 0x29},
+			expected: uint64(2345),
+		},
+		{
+			name:     "float32",
+			data:     []byte{tagFloat32, 0x42, 0x48, 0x00, 0x00},
+			expected: float64(50),
+		},
+		{
+			name:     "visible-string",
+			data:     []byte{tagVisibleString, 0x03, 'L', 'G', 'Z'},
+			expected: "LGZ",
+		},
+		{
+			name:     "structure",
+			data:     []byte{tagStructure, 0x02, tagInteger, 0xfd, tagEnum, 0x1e},
+			expected: []interface{}{int64(-3), uint64(30)},
+		},
+		{
+			name: "date-time",
+			data: []byte{
+				tagDateTime, 0x07, 0xe8, 0x03, 0x0f, 0x05, 0x0a, 0x1e, 0x00, 0x32, 0xff, 0xc4, 0x00,
+			},
+			expected: time.Date(2024, 3, 15, 10, 30, 0, 500000000, time.FixedZone("", 3600)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v, n, err := decodeData(tt.data)
+			require.NoError(t, err)
+			require.Equal(t, len(tt.data), n)
+			require.Equal(t, tt.expected, v)
+		})
+	}
+
+	_, _, err := decodeData([]byte{tagLong64, 0x00})
+	require.ErrorIs(t, err, errShort)
+	_, _, err = decodeData([]byte{0x63})
+	require.EqualError(t, err, "unsupported data type 99")
+}
+
+func TestGather(t *testing.T) {
+	for _, transport := range []string{"wrapper", "hdlc"} {
+		t.Run(transport, func(t *testing.T) {
+			meter := newMeter(t, transport, "secret")
+			defer meter.close()
+
+			plugin := &DLMS{
+				Address:         meter.address(),
+				Transport:       transport,
+				ClientAddress:   1,
+				ServerAddress:   1,
+				PhysicalAddress: 17,
+				Authentication:  "low",
+				Password:        config.NewSecret([]byte("secret")),
+				Timeout:         config.Duration(time.Second),
+				Objects: []object{
+					{Name: "energy", OBIS: "1-0:1.8.0.255"},
+					{Name: "power", OBIS: "1-0:1.7.0.255"},
+					{Name: "serial_number", OBIS: "0-0:96.1.0.255", ClassID: 1},
+					{Name: "missing", OBIS: "1-0:99.99.0.255", ClassID: 1},
+					{Name: "clock", OBIS: "0-0:1.0.0.255", ClassID: 8},
+					{Name: "firmware", OBIS: "1-0:0.2.0.255", ClassID: 1},
+				},
+				Log: testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			// Read twice to check the cached scalers are applied
+			var acc testutil.Accumulator
+			require.NoError(t, plugin.Gather(&acc))
+			require.NoError(t, plugin.Gather(&acc))
+			require.Len(t, acc.Errors, 2)
+			require.ErrorContains(t, acc.Errors[0], `reading "missing" (1-0:99.99.0.255) failed: object undefined`)
+
+			expected := metric.New(
+				"dlms",
+				map[string]string{"address": "127.0.0.1"},
+				map[string]interface{}{
+					"energy":        float64(12345.678),
+					"energy_unit":   "Wh",
+					"power":         float64(1520),
+					"power_unit":    "W",
+					"serial_number": "12345678",
+					"clock":         "2024-03-15T09:30:00Z",
+					"firmware":      "V1.2.3 build 2024-01-31 for the DLMS test meter with a long description",
+				},
+				time.Unix(0, 0),
+			)
+			testutil.RequireMetricsEqual(t,
+				[]telegraf.Metric{expected, expected},
+				acc.GetTelegrafMetrics(),
+				testutil.IgnoreTime(),
+			)
+			require.Equal(t, 2, meter.associations())
+		})
+	}
+}
+
+func TestGatherAuthenticationFailure(t *testing.T) {
+	meter := newMeter(t, "wrapper", "secret")
+	defer meter.close()
+
+	plugin := &DLMS{
+		Address:        meter.address(),
+		ClientAddress:  1,
+		ServerAddress:  1,
+		Authentication: "low",
+		Password:       config.NewSecret([]byte("wrong")),
+		Timeout:        config.Duration(time.Second),
+		Objects:        []object{{OBIS: "1-0:1.8.0.255"}},
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.EqualError(t, plugin.Gather(&acc), "association with "+meter.address()+" failed: association rejected: authentication failure")
+	require.Empty(t, acc.GetTelegrafMetrics())
+}
+
+// meter simulates a DLMS/COSEM server
+type meter struct {
+	t         *testing.T
+	listener  net.Listener
+	transport string
+	password  string
+	objects   map[string][]byte
+	wg        sync.WaitGroup
+
+	sync.Mutex
+	associated int
+}
+
+// Response to invalid requests with the service error "other reason"
+var exceptionResponse = []byte{apduExceptionResponse, 0x01, 0x02}
+
+// Maximum sizes of data blocks and HDLC segments sent by the meter
+const (
+	meterBlockSize   = 48
+	meterSegmentSize = 32
+)
+
+func newMeter(t *testing.T, transport, password string) *meter {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	scalerUnit := func(scaler int8, unit byte) []byte {
+		return []byte{tagStructure, 0x02, tagInteger, byte(scaler), tagEnum, unit}
+	}
+	firmware := []byte("V1.2.3 build 2024-01-31 for the DLMS test meter with a long description")
+
+	m := &meter{
+		t:         t,
+		listener:  listener,
+		transport: transport,
+		password:  password,
+		objects: map[string][]byte{
+			// Energy of 12345678 Wh with scaler -3 and power of 152 W with scaler 1
+			"3/1-0:1.8.0.255/2":  {tagDoubleLongUnsigned, 0x00, 0xbc, 0x61, 0x4e},
+			"3/1-0:1.8.0.255/3":  scalerUnit(-3, 30),
+			"3/1-0:1.7.0.255/2":  {tagLongUnsigned, 0x00, 0x98},
+			"3/1-0:1.7.0.255/3":  scalerUnit(1, 27),
+			"1/0-0:96.1.0.255/2": encodeOctetString(nil, []byte("12345678")),
+			"8/0-0:1.0.0.255/2": encodeOctetString(nil, []byte{
+				0x07, 0xe8, 0x03, 0x0f, 0x05, 0x0a, 0x1e, 0x00, 0x00, 0xff, 0xc4, 0x00,
+			}),
+			"1/1-0:0.2.0.255/2": append([]byte{tagVisibleString, byte(len(firmware))}, firmware...),
+		},
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			m.serve(conn)
+		}
+	}()
+	return m
+}
+
+func (m *meter) address() string {
+	return m.listener.Addr().String()
+}
+
+func (m *meter) close() {
+	m.listener.Close()
+	m.wg.Wait()
+}
+
+func (m *meter) associations() int {
+	m.Lock()
+	defer m.Unlock()
+	return m.associated
+}
+
+func (m *meter) serve(conn net.Conn) {
+	defer conn.Close()
+
+	var err error
+	if m.transport == "wrapper" {
+		err = m.serveWrapper(conn)
+	} else {
+		err = m.serveHDLC(conn)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		m.t.Errorf("serving connection failed: %v", err)
+	}
+}
+
+func (m *meter) serveWrapper(conn net.Conn) error {
+	for {
+		header := make([]byte, wrapperHeaderLength)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return err
+		}
+		req := make([]byte, binary.BigEndian.Uint16(header[6:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return err
+		}
+
+		resp := m.handle(req)
+		buf := binary.BigEndian.AppendUint16(nil, wrapperVersion)
+		buf = append(buf, header[4:6]...)
+		buf = append(buf, header[2:4]...)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(len(resp)))
+		if _, err := conn.Write(append(buf, resp...)); err != nil {
+			return err
+		}
+	}
+}
+
+func (m *meter) serveHDLC(conn net.Conn) error {
+	reader := bufio.NewReader(conn)
+	server := hdlcAddress(1, 17)
+	var sendSeq, recvSeq byte
+
+	write := func(f *frame) error {
+		f.dest, f.src = []byte{1<<1 | 1}, server
+		_, err := conn.Write(f.encode())
+		return err
+	}
+
+	for {
+		f, err := readFrame(reader)
+		if err != nil {
+			return err
+		}
+		if string(f.dest) != string(server) {
+			return fmt.Errorf("unexpected destination address %x", f.dest)
+		}
+
+		switch {
+		case f.control == hdlcSNRM|hdlcPoll:
+			sendSeq, recvSeq = 0, 0
+			// Negotiate the maximum information field lengths
+			params := []byte{0x81, 0x80, 0x06, 0x05, 0x01, meterSegmentSize, 0x06, 0x01, 0x80}
+			if err := write(&frame{control: hdlcUA | hdlcPoll, info: params}); err != nil {
+				return err
+			}
+		case f.control == hdlcDISC|hdlcPoll:
+			return write(&frame{control: hdlcUA | hdlcPoll})
+		case f.control&0x01 == 0:
+			if seq := f.control >> 1 & 0x07; seq != recvSeq {
+				return fmt.Errorf("unexpected send sequence number %d", seq)
+			}
+			recvSeq = (recvSeq + 1) % 8
+			if len(f.info) < 3 || string(f.info[:3]) != string(llcRequest) {
+				return errors.New("invalid LLC header")
+			}
+
+			info := append(append([]byte(nil), llcResponse...), m.handle(f.info[3:])...)
+			for len(info) > 0 {
+				n := min(len(info), meterSegmentSize)
+				segment := &frame{
+					control:   recvSeq<<5 | hdlcPoll | sendSeq<<1,
+					info:      info[:n],
+					segmented: n < len(info),
+				}
+				sendSeq = (sendSeq + 1) % 8
+				if err := write(segment); err != nil {
+					return err
+				}
+				info = info[n:]
+				if len(info) == 0 {
+					break
+				}
+				// Wait for the client to request the next segment
+				rr, err := readFrame(reader)
+				if err != nil {
+					return err
+				}
+				if rr.control&0x0f != hdlcRR || rr.control>>5 != sendSeq {
+					return fmt.Errorf("unexpected frame 0x%02x instead of receive ready", rr.control)
+				}
+			}
+		default:
+			return fmt.Errorf("unexpected frame 0x%02x", f.control)
+		}
+	}
+}
+
+// handle processes a request APDU returning the response APDU
+func (m *meter) handle(req []byte) []byte {
+	switch req[0] {
+	case apduAARQ:
+		return m.associate(req)
+	case apduRLRQ:
+		return []byte{apduRLRE, 0x03, 0x80, 0x01, 0x00}
+	case apduGetRequest:
+		return m.get(req)
+	}
+	return exceptionResponse
+}
+
+func (m *meter) associate(req []byte) []byte {
+	_, body, _, err := readTLV(req)
+	if err != nil {
+		return exceptionResponse
+	}
+
+	var password []byte
+	for len(body) > 0 {
+		tag, value, n, err := readTLV(body)
+		if err != nil {
+			return exceptionResponse
+		}
+		if tag == 0xac {
+			if _, password, _, err = readTLV(value); err != nil {
+				return exceptionResponse
+			}
+		}
+		body = body[n:]
+	}
+
+	context := appendTLV(nil, 0xa1, appendTLV(nil, 0x06, contextLNNoCiphering))
+	if string(password) != m.password {
+		// Rejected permanently due to an authentication failure
+		resp := append(context, 0xa2, 0x03, 0x02, 0x01, 0x01, 0xa3, 0x05, 0xa1, 0x03, 0x02, 0x01, 13)
+		return appendTLV(nil, apduAARE, resp)
+	}
+
+	m.Lock()
+	m.associated++
+	m.Unlock()
+
+	initiate := []byte{apduInitiateResponse, 0x00, 0x06, 0x5f, 0x1f, 0x04, 0x00, 0x00, 0x10, 0x1d, 0x01, 0x00, 0x00, 0x07}
+	resp := append(context, 0xa2, 0x03, 0x02, 0x01, 0x00, 0xa3, 0x05, 0xa1, 0x03, 0x02, 0x01, 0x00)
+	resp = appendTLV(resp, 0xbe, appendTLV(nil, 0x04, initiate))
+	return appendTLV(nil, apduAARE, resp)
+}
+
+// get responds to GET requests sending values exceeding the block size as
+// data blocks and answering requests for the next block
+func (m *meter) get(req []byte) []byte {
+	var data []byte
+	var block uint32
+	switch req[1] {
+	case getNormal:
+		if len(req) != 13 {
+			return exceptionResponse
+		}
+		var code obis
+		copy(code[:], req[5:11])
+		key := fmt.Sprintf("%d/%s/%d", binary.BigEndian.Uint16(req[3:5]), code, req[11])
+		v, found := m.objects[key]
+		if !found {
+			return []byte{apduGetResponse, getNormal, req[2], 0x01, 0x04}
+		}
+		if len(v) <= meterBlockSize {
+			return append([]byte{apduGetResponse, getNormal, req[2], 0x00}, v...)
+		}
+		data, block = v, 1
+	case getNext:
+		// Requests for the next block are only used for the firmware
+		if len(req) != 7 {
+			return exceptionResponse
+		}
+		data = m.objects["1/1-0:0.2.0.255/2"]
+		block = binary.BigEndian.Uint32(req[3:]) + 1
+	}
+
+	start := int(block-1) * meterBlockSize
+	end := min(len(data), start+meterBlockSize)
+	last := byte(0)
+	if end == len(data) {
+		last = 1
+	}
+	resp := []byte{apduGetResponse, getWithDatablock, req[2], last}
+	resp = binary.BigEndian.AppendUint32(resp, block)
+	resp = append(resp, 0x00)
+	return append(appendLength(resp, end-start), data[start:end]...)
+}
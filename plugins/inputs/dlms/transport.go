@@ -0,0 +1,380 @@
+package dlms
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// transport exchanges application protocol data units (APDUs) with the meter
+type transport interface {
+	send(apdu []byte) error
+	receive() ([]byte, error)
+	close() error
+}
+
+const (
+	wrapperVersion      = 1
+	wrapperHeaderLength = 8
+)
+
+// wrapper implements the TCP transport of IEC 62056-47 prefixing each APDU
+// with a header containing the version, the source and destination ports
+// and the length of the APDU
+type wrapper struct {
+	conn    net.Conn
+	timeout time.Duration
+	client  uint16
+	server  uint16
+}
+
+func (w *wrapper) send(apdu []byte) error {
+	buf := make([]byte, 0, wrapperHeaderLength+len(apdu))
+	buf = binary.BigEndian.AppendUint16(buf, wrapperVersion)
+	buf = binary.BigEndian.AppendUint16(buf, w.client)
+	buf = binary.BigEndian.AppendUint16(buf, w.server)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(apdu)))
+	buf = append(buf, apdu...)
+
+	if err := w.conn.SetWriteDeadline(time.Now().Add(w.timeout)); err != nil {
+		return err
+	}
+	_, err := w.conn.Write(buf)
+	return err
+}
+
+func (w *wrapper) receive() ([]byte, error) {
+	if err := w.conn.SetReadDeadline(time.Now().Add(w.timeout)); err != nil {
+		return nil, err
+	}
+	header := make([]byte, wrapperHeaderLength)
+	if _, err := io.ReadFull(w.conn, header); err != nil {
+		return nil, err
+	}
+	if v := binary.BigEndian.Uint16(header[0:2]); v != wrapperVersion {
+		return nil, fmt.Errorf("unsupported wrapper version %d", v)
+	}
+	if src := binary.BigEndian.Uint16(header[2:4]); src != w.server {
+		return nil, fmt.Errorf("unexpected source port %d", src)
+	}
+	apdu := make([]byte, binary.BigEndian.Uint16(header[6:8]))
+	if _, err := io.ReadFull(w.conn, apdu); err != nil {
+		return nil, err
+	}
+	return apdu, nil
+}
+
+func (w *wrapper) close() error {
+	return w.conn.Close()
+}
+
+// HDLC frame control fields
+const (
+	hdlcFlag    = 0x7e
+	hdlcFormat  = 0xa0
+	hdlcSegment = 0x08
+	hdlcPoll    = 0x10
+	hdlcSNRM    = 0x83
+	hdlcDISC    = 0x43
+	hdlcUA      = 0x63
+	hdlcDM      = 0x0f
+	hdlcRR      = 0x01
+)
+
+// Logical link control headers of requests and responses
+var (
+	llcRequest  = []byte{0xe6, 0xe6, 0x00}
+	llcResponse = []byte{0xe6, 0xe7, 0x00}
+)
+
+// Default maximum length of the information field if not negotiated
+const hdlcDefaultMaxInfo = 128
+
+// frame is a HDLC frame of IEC 62056-46
+type frame struct {
+	dest      []byte
+	src       []byte
+	control   byte
+	info      []byte
+	segmented bool
+}
+
+// hdlc implements the connection-oriented HDLC data link layer of
+// IEC 62056-46 on top of a byte stream such as a TCP connection to a
+// serial-to-ethernet converter or a meter's optical port gateway
+type hdlc struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	client  []byte
+	server  []byte
+	maxInfo int
+	sendSeq byte
+	recvSeq byte
+}
+
+// hdlcAddress encodes the server address consisting of the upper address
+// identifying the logical device and the lower address identifying the
+// physical device using the two or four byte format
+func hdlcAddress(logical, physical uint16) []byte {
+	if logical < 0x80 && physical < 0x80 {
+		return []byte{byte(logical) << 1, byte(physical)<<1 | 1}
+	}
+	return []byte{
+		byte(logical>>7&0x7f) << 1,
+		byte(logical&0x7f) << 1,
+		byte(physical>>7&0x7f) << 1,
+		byte(physical&0x7f)<<1 | 1,
+	}
+}
+
+// connect sets up the data link by sending a SNRM frame and applies the
+// parameters negotiated in the UA response
+func (h *hdlc) connect() error {
+	h.maxInfo = hdlcDefaultMaxInfo
+	if err := h.write(&frame{control: hdlcSNRM | hdlcPoll}); err != nil {
+		return err
+	}
+	f, err := h.read()
+	if err != nil {
+		return err
+	}
+	switch f.control &^ hdlcPoll {
+	case hdlcUA:
+	case hdlcDM:
+		return errors.New("meter is in disconnected mode")
+	default:
+		return fmt.Errorf("unexpected response 0x%02x to SNRM", f.control)
+	}
+
+	// The UA response contains the negotiated parameters with the maximum
+	// length of the information field the meter is able to receive
+	if len(f.info) > 3 && f.info[0] == 0x81 && f.info[1] == 0x80 {
+		params := f.info[3:]
+		for len(params) >= 2 && len(params) >= 2+int(params[1]) {
+			id, value := params[0], params[2:2+params[1]]
+			if id == 0x06 {
+				var n int
+				for _, b := range value {
+					n = n<<8 | int(b)
+				}
+				if n > 0 {
+					h.maxInfo = n
+				}
+			}
+			params = params[2+len(value):]
+		}
+	}
+	return nil
+}
+
+func (h *hdlc) send(apdu []byte) error {
+	info := make([]byte, 0, len(llcRequest)+len(apdu))
+	info = append(info, llcRequest...)
+	info = append(info, apdu...)
+	if len(info) > h.maxInfo {
+		return fmt.Errorf("request of %d bytes exceeds maximum information length %d", len(info), h.maxInfo)
+	}
+
+	control := h.recvSeq<<5 | hdlcPoll | h.sendSeq<<1
+	h.sendSeq = (h.sendSeq + 1) % 8
+	return h.write(&frame{control: control, info: info})
+}
+
+// receive reads the information frames of the response acknowledging
+// segments until the last segment was received
+func (h *hdlc) receive() ([]byte, error) {
+	var info []byte
+	for {
+		f, err := h.read()
+		if err != nil {
+			return nil, err
+		}
+		if f.control&0x01 != 0 {
+			if f.control&^hdlcPoll == hdlcDM {
+				return nil, errors.New("meter disconnected")
+			}
+			return nil, fmt.Errorf("unexpected frame 0x%02x", f.control)
+		}
+		if seq := f.control >> 1 & 0x07; seq != h.recvSeq {
+			return nil, fmt.Errorf("unexpected send sequence number %d, expected %d", seq, h.recvSeq)
+		}
+		h.recvSeq = (h.recvSeq + 1) % 8
+		info = append(info, f.info...)
+
+		if !f.segmented {
+			break
+		}
+		// Request the next segment
+		if err := h.write(&frame{control: h.recvSeq<<5 | hdlcPoll | hdlcRR}); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(info) < len(llcResponse) || info[0] != llcResponse[0] || info[1] != llcResponse[1] {
+		return nil, errors.New("invalid LLC header")
+	}
+	return info[len(llcResponse):], nil
+}
+
+// close disconnects the data link and closes the connection
+func (h *hdlc) close() error {
+	if err := h.write(&frame{control: hdlcDISC | hdlcPoll}); err == nil {
+		// Meters respond with UA or DM, we close the connection anyway
+		_, _ = h.read()
+	}
+	return h.conn.Close()
+}
+
+func (h *hdlc) write(f *frame) error {
+	f.dest, f.src = h.server, h.client
+	if err := h.conn.SetWriteDeadline(time.Now().Add(h.timeout)); err != nil {
+		return err
+	}
+	_, err := h.conn.Write(f.encode())
+	return err
+}
+
+func (h *hdlc) read() (*frame, error) {
+	if err := h.conn.SetReadDeadline(time.Now().Add(h.timeout)); err != nil {
+		return nil, err
+	}
+	for {
+		f, err := readFrame(h.reader)
+		if err != nil {
+			return nil, err
+		}
+		// Ignore frames addressed to other clients on a shared line
+		if string(f.dest) == string(h.client) {
+			return f, nil
+		}
+	}
+}
+
+// encode serializes the frame including the opening and closing flags
+func (f *frame) encode() []byte {
+	length := 2 + len(f.dest) + len(f.src) + 1 + 2
+	if len(f.info) > 0 {
+		length += len(f.info) + 2
+	}
+
+	format := uint16(hdlcFormat)<<8 | uint16(length)
+	if f.segmented {
+		format |= hdlcSegment << 8
+	}
+	buf := make([]byte, 0, length+2)
+	buf = append(buf, hdlcFlag)
+	buf = binary.BigEndian.AppendUint16(buf, format)
+	buf = append(buf, f.dest...)
+	buf = append(buf, f.src...)
+	buf = append(buf, f.control)
+	if len(f.info) > 0 {
+		buf = binary.LittleEndian.AppendUint16(buf, crc16(buf[1:]))
+		buf = append(buf, f.info...)
+	}
+	buf = binary.LittleEndian.AppendUint16(buf, crc16(buf[1:]))
+	return append(buf, hdlcFlag)
+}
+
+// readFrame reads the next frame skipping any data up to the opening flag
+func readFrame(r *bufio.Reader) (*frame, error) {
+	// Skip data up to the opening flag and adjacent flags
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != hdlcFlag {
+			continue
+		}
+		next, err := r.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if next[0] != hdlcFlag {
+			break
+		}
+	}
+
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	format := binary.BigEndian.Uint16(header[:])
+	if format>>12 != hdlcFormat>>4 {
+		return nil, fmt.Errorf("invalid frame format 0x%04x", format)
+	}
+	length := int(format & 0x07ff)
+	if length < 7 {
+		return nil, fmt.Errorf("invalid frame length %d", length)
+	}
+	buf := make([]byte, length+1)
+	copy(buf, header[:])
+	if _, err := io.ReadFull(r, buf[2:]); err != nil {
+		return nil, err
+	}
+	if buf[length] != hdlcFlag {
+		return nil, errors.New("missing closing flag")
+	}
+	buf = buf[:length]
+	if crc16(buf[:length-2]) != binary.LittleEndian.Uint16(buf[length-2:]) {
+		return nil, errors.New("frame check sequence mismatch")
+	}
+
+	f := &frame{segmented: format&(hdlcSegment<<8) != 0}
+	pos := 2
+	var err error
+	if f.dest, pos, err = readAddress(buf, pos); err != nil {
+		return nil, err
+	}
+	if f.src, pos, err = readAddress(buf, pos); err != nil {
+		return nil, err
+	}
+	if pos >= length-2 {
+		return nil, errors.New("missing control field")
+	}
+	f.control = buf[pos]
+	pos++
+
+	// Frames with information field contain an additional header check
+	// sequence
+	if pos < length-2 {
+		if pos+2 > length-2 || crc16(buf[:pos]) != binary.LittleEndian.Uint16(buf[pos:]) {
+			return nil, errors.New("header check sequence mismatch")
+		}
+		f.info = buf[pos+2 : length-2]
+	}
+	return f, nil
+}
+
+// readAddress returns the address terminated by a byte with the least
+// significant bit set
+func readAddress(buf []byte, pos int) ([]byte, int, error) {
+	for i := pos; i < len(buf) && i < pos+4; i++ {
+		if buf[i]&0x01 != 0 {
+			return buf[pos : i+1], i + 1, nil
+		}
+	}
+	return nil, 0, errors.New("invalid address field")
+}
+
+// crc16 computes the CRC-16/X-25 used for the header and frame check
+// sequences
+func crc16(data []byte) uint16 {
+	crc := uint16(0xffff)
+	for _, b := range data {
+		crc ^= uint16(b)
+		for range 8 {
+			if crc&0x01 != 0 {
+				crc = crc>>1 ^ 0x8408
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return ^crc
+}
@@ -0,0 +1,483 @@
+//go:generate ../../../tools/readme_config_includer/generator
+//go:build linux
+
+package ethercat
+
+import (
+	"bufio"
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// execCommand is used to mock commands in tests.
+var execCommand = exec.Command
+
+// Address of the DC system time difference register of the slaves
+const regSystemTimeDifference = "0x092c"
+
+// Codes of the application layer states of the slaves
+var alStates = map[string]int{
+	"INIT":   0x01,
+	"PREOP":  0x02,
+	"BOOT":   0x03,
+	"SAFEOP": 0x04,
+	"OP":     0x08,
+}
+
+// Fields of the master and device statistics
+var masterFields = map[string]string{
+	"Slaves":          "slaves",
+	"Tx frames":       "tx_frames",
+	"Tx bytes":        "tx_bytes",
+	"Rx frames":       "rx_frames",
+	"Rx bytes":        "rx_bytes",
+	"Tx errors":       "tx_errors",
+	"Lost frames":     "lost_frames",
+	"Loss rate [1/s]": "loss_rate",
+	"Frame loss [%]":  "frame_loss_percent",
+}
+
+type EtherCAT struct {
+	Binary   string          `toml:"binary"`
+	UseSudo  bool            `toml:"use_sudo"`
+	Masters  []int           `toml:"masters"`
+	Collect  []string        `toml:"collect"`
+	DCSlaves []int           `toml:"dc_slaves"`
+	Timeout  config.Duration `toml:"timeout"`
+	Log      telegraf.Logger `toml:"-"`
+
+	path     string
+	wcErrors map[string]uint64
+}
+
+func (*EtherCAT) SampleConfig() string {
+	return sampleConfig
+}
+
+func (e *EtherCAT) Init() error {
+	if len(e.Masters) == 0 {
+		e.Masters = []int{0}
+	}
+	for _, m := range e.Masters {
+		if m < 0 {
+			return fmt.Errorf("invalid master index %d", m)
+		}
+	}
+	for _, p := range e.DCSlaves {
+		if p < 0 {
+			return fmt.Errorf("invalid slave position %d in 'dc_slaves'", p)
+		}
+	}
+	if err := choice.CheckSlice(e.Collect, []string{"master", "slaves", "domains"}); err != nil {
+		return fmt.Errorf("invalid 'collect': %w", err)
+	}
+	if e.Timeout <= 0 {
+		return errors.New("'timeout' must be positive")
+	}
+
+	if e.path == "" {
+		binary := e.Binary
+		if binary == "" {
+			binary = "ethercat"
+		}
+		path, err := exec.LookPath(binary)
+		if err != nil {
+			return fmt.Errorf("looking up %q failed: %w", binary, err)
+		}
+		e.path = path
+	}
+
+	e.wcErrors = make(map[string]uint64)
+
+	return nil
+}
+
+func (e *EtherCAT) Gather(acc telegraf.Accumulator) error {
+	for _, m := range e.Masters {
+		master := strconv.Itoa(m)
+		if choice.Contains("master", e.Collect) {
+			if err := e.gatherMaster(acc, master); err != nil {
+				acc.AddError(fmt.Errorf("gathering master %s failed: %w", master, err))
+			}
+		}
+		if choice.Contains("slaves", e.Collect) {
+			if err := e.gatherSlaves(acc, master); err != nil {
+				acc.AddError(fmt.Errorf("gathering slaves of master %s failed: %w", master, err))
+			}
+		}
+		if choice.Contains("domains", e.Collect) {
+			if err := e.gatherDomains(acc, master); err != nil {
+				acc.AddError(fmt.Errorf("gathering domains of master %s failed: %w", master, err))
+			}
+		}
+		for _, p := range e.DCSlaves {
+			if err := e.gatherDC(acc, master, p); err != nil {
+				acc.AddError(fmt.Errorf("gathering DC statistics of slave %d of master %s failed: %w", p, master, err))
+			}
+		}
+	}
+	return nil
+}
+
+func (e *EtherCAT) gatherMaster(acc telegraf.Accumulator, master string) error {
+	out, err := e.run(master, "master")
+	if err != nil {
+		return err
+	}
+	fields, err := parseMaster(out)
+	if err != nil {
+		return err
+	}
+	acc.AddFields("ethercat_master", fields, map[string]string{"master": master})
+	return nil
+}
+
+func (e *EtherCAT) gatherSlaves(acc telegraf.Accumulator, master string) error {
+	out, err := e.run(master, "slaves")
+	if err != nil {
+		return err
+	}
+	slaves, err := parseSlaves(out)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, s := range slaves {
+		tags := map[string]string{
+			"master":   master,
+			"position": strconv.Itoa(s.position),
+			"alias":    strconv.Itoa(s.alias),
+		}
+		if s.name != "" {
+			tags["name"] = s.name
+		}
+		fields := map[string]interface{}{
+			"state":      s.state,
+			"state_code": s.stateCode,
+			"error":      s.err,
+		}
+		acc.AddFields("ethercat_slave", fields, tags, now)
+	}
+	return nil
+}
+
+func (e *EtherCAT) gatherDomains(acc telegraf.Accumulator, master string) error {
+	out, err := e.run(master, "domains")
+	if err != nil {
+		return err
+	}
+	domains, err := parseDomains(out)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, d := range domains {
+		// The working counter errors are counted across gathers as the
+		// master only reports the current value
+		key := master + "/" + d.index
+		mismatch := d.workingCounter != d.expectedWorkingCounter
+		if mismatch {
+			e.wcErrors[key]++
+		}
+
+		tags := map[string]string{
+			"master": master,
+			"domain": d.index,
+		}
+		fields := map[string]interface{}{
+			"size":                     d.size,
+			"working_counter":          d.workingCounter,
+			"expected_working_counter": d.expectedWorkingCounter,
+			"working_counter_mismatch": mismatch,
+			"working_counter_errors":   e.wcErrors[key],
+			"data_state":               d.dataState,
+		}
+		acc.AddFields("ethercat_domain", fields, tags, now)
+	}
+	return nil
+}
+
+func (e *EtherCAT) gatherDC(acc telegraf.Accumulator, master string, position int) error {
+	out, err := e.run(master, "reg_read", "--position", strconv.Itoa(position), "--type", "uint32", regSystemTimeDifference)
+	if err != nil {
+		return err
+	}
+	diff, err := parseSystemTimeDifference(out)
+	if err != nil {
+		return err
+	}
+
+	tags := map[string]string{
+		"master":   master,
+		"position": strconv.Itoa(position),
+	}
+	fields := map[string]interface{}{
+		"system_time_difference": diff,
+	}
+	acc.AddFields("ethercat_dc", fields, tags)
+	return nil
+}
+
+// run executes the command of the EtherCAT tool for the given master
+func (e *EtherCAT) run(master string, args ...string) ([]byte, error) {
+	args = append([]string{args[0], "--master", master}, args[1:]...)
+
+	name := e.path
+	if e.UseSudo {
+		args = append([]string{"-n", name}, args...)
+		name = "sudo"
+	}
+	cmd := execCommand(name, args...)
+	out, err := internal.StdOutputTimeout(cmd, time.Duration(e.Timeout))
+	if err != nil {
+		return nil, fmt.Errorf("running %q failed: %w", strings.Join(cmd.Args, " "), err)
+	}
+	return out, nil
+}
+
+// parseMaster parses the output of the 'ethercat master' command containing
+// the state of the master, the statistics of the main and backup devices and
+// the common statistics as well as the distributed clocks information.
+func parseMaster(out []byte) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+
+	var prefix string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		// Sections of the device statistics start with the device's MAC
+		// address which contains colons, so handle them first
+		switch {
+		case strings.HasPrefix(line, "Main:"):
+			prefix = "main_"
+			continue
+		case strings.HasPrefix(line, "Backup:"):
+			prefix = "backup_"
+			continue
+		case line == "Common:":
+			prefix = ""
+			continue
+		case line == "Distributed clocks:":
+			prefix = "dc_"
+			continue
+		}
+
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		switch key {
+		case "Phase":
+			fields["phase"] = value
+		case "Active":
+			fields["active"] = value == "yes"
+		case "Link":
+			fields[prefix+"link_up"] = value == "UP"
+		case "Reference clock":
+			if pos, found := strings.CutPrefix(value, "Slave "); found {
+				if v, err := strconv.ParseInt(pos, 10, 64); err == nil {
+					fields["dc_reference_clock"] = v
+				}
+			}
+		case "DC reference time":
+			if v, err := strconv.ParseUint(value, 10, 64); err == nil {
+				fields["dc_reference_time"] = v
+			}
+		case "Application time":
+			if v, err := strconv.ParseUint(value, 10, 64); err == nil {
+				fields["dc_application_time"] = v
+			}
+		default:
+			name, found := masterFields[key]
+			if !found {
+				continue
+			}
+			// Rates are reported as averages over multiple periods, use
+			// the shortest one
+			parts := strings.Fields(value)
+			if len(parts) == 0 {
+				continue
+			}
+			if v, err := strconv.ParseUint(parts[0], 10, 64); err == nil {
+				fields[prefix+name] = v
+			} else if v, err := strconv.ParseFloat(parts[0], 64); err == nil {
+				fields[prefix+name] = v
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, found := fields["phase"]; !found {
+		return nil, errors.New("no master information found")
+	}
+	return fields, nil
+}
+
+type slave struct {
+	position  int
+	alias     int
+	state     string
+	stateCode int
+	err       bool
+	name      string
+}
+
+// parseSlaves parses the output of the 'ethercat slaves' command listing
+// the position, the alias and relative position, the application layer
+// state, the error flag and the name of each slave, e.g.
+//
+//	0  0:0  PREOP  +  EK1100 EtherCAT-Koppler (2A E-Bus)
+func parseSlaves(out []byte) ([]slave, error) {
+	var slaves []slave
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		parts := strings.Fields(scanner.Text())
+		if len(parts) == 0 {
+			continue
+		}
+		if len(parts) < 4 {
+			return nil, fmt.Errorf("invalid slave line %q", scanner.Text())
+		}
+
+		position, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid position %q", parts[0])
+		}
+		aliasStr, _, _ := strings.Cut(parts[1], ":")
+		alias, err := strconv.Atoi(aliasStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid alias %q", parts[1])
+		}
+
+		// The state is suffixed by '+ERROR' if the slave signals an error
+		state, code := parts[2], 0
+		if s, found := strings.CutSuffix(state, "+ERROR"); found {
+			code = 0x10
+			state = s
+		}
+		code |= alStates[state]
+
+		slaves = append(slaves, slave{
+			position:  position,
+			alias:     alias,
+			state:     parts[2],
+			stateCode: code,
+			err:       parts[3] == "E",
+			name:      strings.Join(parts[4:], " "),
+		})
+	}
+	return slaves, scanner.Err()
+}
+
+type domain struct {
+	index                  string
+	size                   uint64
+	workingCounter         uint64
+	expectedWorkingCounter uint64
+	dataState              string
+}
+
+// parseDomains parses the output of the 'ethercat domains' command, e.g.
+//
+//	Domain0: LogBaseAddr 0x00000000, Size   6, WorkingCounter 3/3, DataState COMPLETE
+func parseDomains(out []byte) ([]domain, error) {
+	var domains []domain
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		// Ignore the process data of the verbose output
+		if !strings.HasPrefix(line, "Domain") {
+			continue
+		}
+		index, attributes, found := strings.Cut(strings.TrimPrefix(line, "Domain"), ":")
+		if !found {
+			return nil, fmt.Errorf("invalid domain line %q", line)
+		}
+
+		d := domain{index: index}
+		for _, attr := range strings.Split(attributes, ",") {
+			key, value, found := strings.Cut(strings.TrimSpace(attr), " ")
+			if !found {
+				continue
+			}
+			value = strings.TrimSpace(value)
+
+			var err error
+			switch key {
+			case "Size":
+				d.size, err = strconv.ParseUint(value, 10, 64)
+			case "WorkingCounter":
+				actual, expected, found := strings.Cut(value, "/")
+				if !found {
+					return nil, fmt.Errorf("invalid working counter %q of domain %s", value, index)
+				}
+				if d.workingCounter, err = strconv.ParseUint(actual, 10, 64); err != nil {
+					break
+				}
+				d.expectedWorkingCounter, err = strconv.ParseUint(expected, 10, 64)
+			case "DataState":
+				d.dataState = value
+			}
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s %q of domain %s", key, value, index)
+			}
+		}
+		domains = append(domains, d)
+	}
+	return domains, scanner.Err()
+}
+
+// parseSystemTimeDifference parses the output of reading the system time
+// difference register, e.g. '0x80000010 2147483664'. The register holds the
+// difference between the local copy of the system time and the system time
+// received from the reference clock in nanoseconds, coded as sign and
+// magnitude with the sign in the most significant bit.
+func parseSystemTimeDifference(out []byte) (int64, error) {
+	parts := strings.Fields(string(out))
+	if len(parts) == 0 {
+		return 0, errors.New("empty register value")
+	}
+	raw, err := strconv.ParseUint(parts[len(parts)-1], 10, 32)
+	if err != nil {
+		if raw, err = strconv.ParseUint(strings.TrimPrefix(parts[0], "0x"), 16, 32); err != nil {
+			return 0, fmt.Errorf("invalid register value %q", strings.TrimSpace(string(out)))
+		}
+	}
+
+	diff := int64(raw & 0x7fffffff)
+	if raw&0x80000000 != 0 {
+		diff = -diff
+	}
+	return diff, nil
+}
+
+func init() {
+	inputs.Add("ethercat", func() telegraf.Input {
+		return &EtherCAT{
+			Collect: []string{"master", "slaves", "domains"},
+			Timeout: config.Duration(5 * time.Second),
+		}
+	})
+}
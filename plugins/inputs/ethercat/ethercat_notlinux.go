@@ -0,0 +1,33 @@
+//go:generate ../../../tools/readme_config_includer/generator
+//go:build !linux
+
+package ethercat
+
+import (
+	_ "embed"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type EtherCAT struct {
+	Log telegraf.Logger `toml:"-"`
+}
+
+func (*EtherCAT) SampleConfig() string { return sampleConfig }
+
+func (e *EtherCAT) Init() error {
+	e.Log.Warn("Current platform is not supported")
+	return nil
+}
+
+func (*EtherCAT) Gather(telegraf.Accumulator) error { return nil }
+
+func init() {
+	inputs.Add("ethercat", func() telegraf.Input {
+		return &EtherCAT{}
+	})
+}
@@ -0,0 +1,323 @@
+//go:build linux
+
+package ethercat
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *EtherCAT
+		expected string
+	}{
+		{
+			name:     "invalid master",
+			plugin:   &EtherCAT{Masters: []int{-1}},
+			expected: "invalid master index -1",
+		},
+		{
+			name:     "invalid dc slave",
+			plugin:   &EtherCAT{DCSlaves: []int{1, -2}},
+			expected: "invalid slave position -2",
+		},
+		{
+			name:     "invalid collect",
+			plugin:   &EtherCAT{Collect: []string{"master", "foo"}},
+			expected: "invalid 'collect'",
+		},
+		{
+			name:     "invalid timeout",
+			plugin:   &EtherCAT{},
+			expected: "'timeout' must be positive",
+		},
+		{
+			name: "binary not found",
+			plugin: &EtherCAT{
+				Binary:  "/nonexistent/ethercat",
+				Timeout: config.Duration(time.Second),
+			},
+			expected: "looking up",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestGather(t *testing.T) {
+	plugin := &EtherCAT{
+		Collect:  []string{"master", "slaves", "domains"},
+		DCSlaves: []int{2},
+		Timeout:  config.Duration(5 * time.Second),
+		Log:      &testutil.Logger{},
+		path:     "ethercat",
+	}
+	commands := mockCommands(t)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"ethercat_master",
+			map[string]string{"master": "0"},
+			map[string]interface{}{
+				"phase":               "Operation",
+				"active":              true,
+				"slaves":              uint64(4),
+				"main_link_up":        true,
+				"main_tx_frames":      uint64(1482205),
+				"main_tx_bytes":       uint64(112647580),
+				"main_rx_frames":      uint64(1482204),
+				"main_rx_bytes":       uint64(112647504),
+				"main_tx_errors":      uint64(0),
+				"tx_frames":           uint64(1482205),
+				"tx_bytes":            uint64(112647580),
+				"rx_frames":           uint64(1482204),
+				"rx_bytes":            uint64(112647504),
+				"lost_frames":         uint64(0),
+				"loss_rate":           uint64(0),
+				"frame_loss_percent":  float64(0),
+				"dc_reference_clock":  int64(1),
+				"dc_reference_time":   uint64(780220800000000000),
+				"dc_application_time": uint64(780221282123456789),
+			},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"ethercat_slave",
+			map[string]string{"master": "0", "position": "0", "alias": "0", "name": "EK1100 EtherCAT-Koppler (2A E-Bus)"},
+			map[string]interface{}{"state": "OP", "state_code": 8, "error": false},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"ethercat_slave",
+			map[string]string{"master": "0", "position": "1", "alias": "0", "name": "EL2008 8K. Dig. Ausgang 24V, 0.5A"},
+			map[string]interface{}{"state": "OP", "state_code": 8, "error": false},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"ethercat_slave",
+			map[string]string{"master": "0", "position": "2", "alias": "5", "name": "EL3102 2K.Ana. Eingang +/-10V, DIFF"},
+			map[string]interface{}{"state": "SAFEOP+ERROR", "state_code": 0x14, "error": true},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"ethercat_slave",
+			map[string]string{"master": "0", "position": "3", "alias": "5", "name": "EL5101"},
+			map[string]interface{}{"state": "PREOP", "state_code": 2, "error": false},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"ethercat_domain",
+			map[string]string{"master": "0", "domain": "0"},
+			map[string]interface{}{
+				"size":                     uint64(6),
+				"working_counter":          uint64(3),
+				"expected_working_counter": uint64(3),
+				"working_counter_mismatch": false,
+				"working_counter_errors":   uint64(0),
+				"data_state":               "COMPLETE",
+			},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"ethercat_domain",
+			map[string]string{"master": "0", "domain": "1"},
+			map[string]interface{}{
+				"size":                     uint64(10),
+				"working_counter":          uint64(1),
+				"expected_working_counter": uint64(3),
+				"working_counter_mismatch": true,
+				"working_counter_errors":   uint64(1),
+				"data_state":               "INCOMPLETE",
+			},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"ethercat_dc",
+			map[string]string{"master": "0", "position": "2"},
+			map[string]interface{}{"system_time_difference": int64(-16)},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime(), testutil.SortMetrics())
+
+	require.Equal(t, []string{
+		"ethercat master --master 0",
+		"ethercat slaves --master 0",
+		"ethercat domains --master 0",
+		"ethercat reg_read --master 0 --position 2 --type uint32 0x092c",
+	}, *commands)
+}
+
+func TestGatherSudo(t *testing.T) {
+	plugin := &EtherCAT{
+		UseSudo: true,
+		Masters: []int{1},
+		Collect: []string{"domains"},
+		Timeout: config.Duration(5 * time.Second),
+		Log:     &testutil.Logger{},
+		path:    "/usr/bin/ethercat",
+	}
+	commands := mockCommands(t)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Gather(&acc))
+	require.Empty(t, acc.Errors)
+	require.Len(t, acc.GetTelegrafMetrics(), 2)
+
+	require.Equal(t, []string{"sudo -n /usr/bin/ethercat domains --master 1"}, *commands)
+}
+
+func TestWorkingCounterErrors(t *testing.T) {
+	plugin := &EtherCAT{
+		Collect: []string{"domains"},
+		Timeout: config.Duration(5 * time.Second),
+		Log:     &testutil.Logger{},
+		path:    "ethercat",
+	}
+	mockCommands(t)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	for range 3 {
+		require.NoError(t, plugin.Gather(&acc))
+	}
+	require.Empty(t, acc.Errors)
+
+	var errs []uint64
+	for _, m := range acc.GetTelegrafMetrics() {
+		if m.Tags()["domain"] != "1" {
+			continue
+		}
+		v, found := m.GetField("working_counter_errors")
+		require.True(t, found)
+		errs = append(errs, v.(uint64))
+	}
+	require.Equal(t, []uint64{1, 2, 3}, errs)
+}
+
+func TestGatherCommandFailure(t *testing.T) {
+	plugin := &EtherCAT{
+		Masters: []int{7},
+		Collect: []string{"master"},
+		Timeout: config.Duration(5 * time.Second),
+		Log:     &testutil.Logger{},
+		path:    "ethercat",
+	}
+	mockCommands(t)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.ErrorContains(t, acc.Errors[0], "gathering master 7 failed")
+	require.Empty(t, acc.GetTelegrafMetrics())
+}
+
+func TestParseSystemTimeDifference(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected int64
+	}{
+		{
+			name:     "positive",
+			output:   "0x0000002a 42\n",
+			expected: 42,
+		},
+		{
+			name:     "negative",
+			output:   "0x80000010 2147483664\n",
+			expected: -16,
+		},
+		{
+			name:     "hex only",
+			output:   "0x800003e8\n",
+			expected: -1000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := parseSystemTimeDifference([]byte(tt.output))
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+
+	_, err := parseSystemTimeDifference([]byte("Failed to read register\n"))
+	require.Error(t, err)
+}
+
+// mockCommands replaces the command execution and returns the executed
+// commands
+func mockCommands(t *testing.T) *[]string {
+	var commands []string
+	execCommand = func(command string, args ...string) *exec.Cmd {
+		commands = append(commands, strings.Join(append([]string{command}, args...), " "))
+
+		cs := []string{"-test.run=TestHelperProcess", "--", command}
+		cs = append(cs, args...)
+		cmd := exec.Command(os.Args[0], cs...)
+		cmd.Env = []string{"GO_WANT_HELPER_PROCESS=1"}
+		return cmd
+	}
+	t.Cleanup(func() { execCommand = exec.Command })
+	return &commands
+}
+
+// TestHelperProcess isn't a real test. It's used to mock exec.Command and
+// prints the output of the EtherCAT tool stored in the testdata directory.
+func TestHelperProcess(*testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
+	}
+
+	// Previous arguments are tests stuff, that looks like :
+	// /tmp/go-build970079519/…/_test/integration.test -test.run=TestHelperProcess --
+	cmd, args := os.Args[3], os.Args[4:]
+	if cmd == "sudo" {
+		args = args[2:]
+	}
+
+	// Only master 0 and 1 exist
+	if len(args) < 3 || args[1] != "--master" || (args[2] != "0" && args[2] != "1") {
+		fmt.Fprint(os.Stderr, "Master index out of range")
+		//nolint:revive // error code is important for this "test"
+		os.Exit(1)
+	}
+
+	buf, err := os.ReadFile(filepath.Join("testdata", args[0]+".txt"))
+	if err != nil {
+		fmt.Fprint(os.Stderr, err)
+		//nolint:revive // error code is important for this "test"
+		os.Exit(1)
+	}
+	fmt.Fprint(os.Stdout, string(buf))
+	//nolint:revive // error code is important for this "test"
+	os.Exit(0)
+}
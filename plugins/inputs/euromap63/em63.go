@@ -0,0 +1,180 @@
+package euromap63
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Parameters of the report used to determine the timestamp of the values
+const (
+	paramDate = "DATE"
+	paramTime = "TIME"
+)
+
+// encodeJob creates a job file requesting a one-time report of the given
+// parameters written to the report file
+func encodeJob(name, logFile, reportFile string, parameters []string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "JOB %s RESPONSE \"%s\";\r\n", name, logFile)
+	fmt.Fprintf(&buf, "REPORT %s REWRITE \"%s\"\r\n", name, reportFile)
+	buf.WriteString("START IMMEDIATE\r\n")
+	buf.WriteString("PARAMETERS\r\n")
+	for i, p := range parameters {
+		buf.WriteString(p)
+		if i < len(parameters)-1 {
+			buf.WriteString(",\r\n")
+		} else {
+			buf.WriteString(";\r\n")
+		}
+	}
+	return buf.Bytes()
+}
+
+// encodeRequest creates a session request file executing the job file
+func encodeRequest(id uint32, jobFile string) []byte {
+	return fmt.Appendf(nil, "%08d EXECUTE \"%s\";\r\n", id, jobFile)
+}
+
+// tokenize splits a statement into words and quoted strings with the quotes
+// removed. The terminating semicolon is dropped.
+func tokenize(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimSuffix(line, ";")
+
+	var tokens []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			if quoted {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+			quoted = !quoted
+		case !quoted && unicode.IsSpace(r):
+			if current.Len() > 0 {
+				tokens = append(tokens, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		tokens = append(tokens, current.String())
+	}
+	return tokens
+}
+
+// parseResponse checks the session response file for the result of the
+// command with the given ID
+func parseResponse(r io.Reader, id uint32) error {
+	expected := fmt.Sprintf("%08d", id)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		tokens := tokenize(scanner.Text())
+		if len(tokens) < 3 || tokens[0] != expected {
+			continue
+		}
+		// Responses contain either PROCESSED or ERROR followed by an
+		// optional error code and a description
+		for i, t := range tokens[2:] {
+			switch strings.ToUpper(t) {
+			case "PROCESSED":
+				return nil
+			case "ERROR":
+				return fmt.Errorf("command failed: %s", strings.Join(tokens[i+3:], " "))
+			}
+		}
+		return fmt.Errorf("invalid response %q", scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("no response for command %s", expected)
+}
+
+// parseLog returns the errors contained in a job log file
+func parseLog(r io.Reader) []error {
+	var errs []error
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		tokens := tokenize(scanner.Text())
+		for i, t := range tokens {
+			if strings.EqualFold(t, "ERROR") {
+				errs = append(errs, errors.New(strings.Join(tokens[i+1:], " ")))
+				break
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// parseReport decodes the report file consisting of a header line with the
+// parameter names and a line with the values. The timestamp is determined
+// from the DATE and TIME parameters if present.
+func parseReport(r io.Reader, loc *time.Location) (map[string]interface{}, time.Time, error) {
+	reader := csv.NewReader(r)
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("reading header failed: %w", err)
+	}
+	values, err := reader.Read()
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("reading values failed: %w", err)
+	}
+	if len(values) != len(header) {
+		return nil, time.Time{}, fmt.Errorf("%d values for %d parameters", len(values), len(header))
+	}
+
+	fields := make(map[string]interface{}, len(header))
+	var date, clock string
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		value := strings.TrimSpace(values[i])
+		switch strings.ToUpper(name) {
+		case paramDate:
+			date = value
+			continue
+		case paramTime:
+			clock = value
+			continue
+		}
+		fields[name] = parseValue(value)
+	}
+
+	var timestamp time.Time
+	if date != "" && clock != "" {
+		timestamp, err = time.ParseInLocation("20060102 15:04:05", date+" "+clock, loc)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("invalid timestamp: %w", err)
+		}
+	}
+	return fields, timestamp, nil
+}
+
+func parseValue(s string) interface{} {
+	if v, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return v
+	}
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v
+	}
+	return s
+}
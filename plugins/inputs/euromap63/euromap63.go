@@ -0,0 +1,241 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package euromap63
+
+import (
+	"bytes"
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Parameter names consist of letters, digits and underscores, e.g.
+// "ActCntCyc" or "@ActTimCyc" for vendor-specific parameters
+var regexParameter = regexp.MustCompile(`^@?[A-Za-z][A-Za-z0-9_]*$`)
+
+type Euromap63 struct {
+	Directory        string          `toml:"directory"`
+	MachineDirectory string          `toml:"machine_directory"`
+	Session          uint16          `toml:"session"`
+	Parameters       []string        `toml:"parameters"`
+	Timezone         string          `toml:"timezone"`
+	Timeout          config.Duration `toml:"timeout"`
+	PollInterval     config.Duration `toml:"poll_interval"`
+	Log              telegraf.Logger `toml:"-"`
+
+	location  *time.Location
+	session   string
+	job       string
+	commandID uint32
+}
+
+func (*Euromap63) SampleConfig() string {
+	return sampleConfig
+}
+
+func (e *Euromap63) Init() error {
+	if e.Directory == "" {
+		return errors.New("'directory' must be specified")
+	}
+	if e.Session > 9999 {
+		return fmt.Errorf("invalid 'session' %d", e.Session)
+	}
+	if len(e.Parameters) == 0 {
+		return errors.New("no parameters defined")
+	}
+	for _, p := range e.Parameters {
+		if !regexParameter.MatchString(p) {
+			return fmt.Errorf("invalid parameter %q", p)
+		}
+	}
+	if e.Timeout <= 0 {
+		return errors.New("'timeout' must be positive")
+	}
+	if e.PollInterval <= 0 {
+		return errors.New("'poll_interval' must be positive")
+	}
+
+	loc, err := time.LoadLocation(e.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid 'timezone' %q: %w", e.Timezone, err)
+	}
+	e.location = loc
+
+	e.session = fmt.Sprintf("SESS%04d", e.Session)
+	e.job = fmt.Sprintf("TLGF%04d", e.Session)
+
+	// Request the date and time of the values for the metric timestamp
+	params := make([]string, 0, len(e.Parameters)+2)
+	params = append(params, paramDate, paramTime)
+	for _, p := range e.Parameters {
+		if !strings.EqualFold(p, paramDate) && !strings.EqualFold(p, paramTime) {
+			params = append(params, p)
+		}
+	}
+	e.Parameters = params
+
+	return nil
+}
+
+func (e *Euromap63) Gather(acc telegraf.Accumulator) error {
+	requestFile := e.path(e.session + ".REQ")
+	responseFile := e.path(e.session + ".RSP")
+	jobFile := e.path(e.job + ".JOB")
+	logFile := e.path(e.job + ".LOG")
+	reportFile := e.path(e.job + ".DAT")
+
+	// The machine removes the request file after processing, so a remaining
+	// file indicates the machine not processing requests
+	if _, err := os.Stat(requestFile); err == nil {
+		return fmt.Errorf("previous request %q not processed by the machine", requestFile)
+	}
+	for _, fn := range []string{responseFile, logFile, reportFile} {
+		if err := os.Remove(fn); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("removing stale file failed: %w", err)
+		}
+	}
+
+	job := encodeJob(e.job, e.machinePath(e.job+".LOG"), e.machinePath(e.job+".DAT"), e.Parameters)
+	if err := os.WriteFile(jobFile, job, 0640); err != nil {
+		return fmt.Errorf("writing job file failed: %w", err)
+	}
+	defer os.Remove(jobFile)
+
+	e.commandID = e.commandID%99999999 + 1
+	if err := writeAtomic(requestFile, encodeRequest(e.commandID, e.machinePath(e.job+".JOB"))); err != nil {
+		return fmt.Errorf("writing request file failed: %w", err)
+	}
+
+	response, err := e.waitFor(responseFile)
+	if err != nil {
+		// Withdraw the request to avoid processing it later
+		if rerr := os.Remove(requestFile); rerr != nil && !errors.Is(rerr, os.ErrNotExist) {
+			e.Log.Errorf("Removing request file failed: %v", rerr)
+		}
+		return fmt.Errorf("waiting for response failed: %w", err)
+	}
+	defer os.Remove(responseFile)
+	if err := parseResponse(bytes.NewReader(response), e.commandID); err != nil {
+		return e.withLogErrors(logFile, err)
+	}
+
+	// The report is written after the job was accepted
+	report, err := e.waitFor(reportFile)
+	if err != nil {
+		return e.withLogErrors(logFile, fmt.Errorf("waiting for report failed: %w", err))
+	}
+	defer os.Remove(reportFile)
+	fields, timestamp, err := parseReport(bytes.NewReader(report), e.location)
+	if err != nil {
+		return fmt.Errorf("parsing report failed: %w", err)
+	}
+
+	// Errors of single parameters are reported in the log while the other
+	// values are still reported
+	for _, err := range e.readLog(logFile) {
+		acc.AddError(fmt.Errorf("machine reported: %w", err))
+	}
+
+	tags := map[string]string{"session": e.session}
+	if timestamp.IsZero() {
+		acc.AddFields("euromap63", fields, tags)
+	} else {
+		acc.AddFields("euromap63", fields, tags, timestamp)
+	}
+	return nil
+}
+
+// waitFor polls for the file until the timeout and returns the content once
+// it did not change between two polls, i.e. the machine finished writing
+func (e *Euromap63) waitFor(fn string) ([]byte, error) {
+	deadline := time.Now().Add(time.Duration(e.Timeout))
+	var previous []byte
+	for {
+		buf, err := os.ReadFile(fn)
+		if err == nil && len(buf) > 0 && bytes.Equal(buf, previous) {
+			return buf, nil
+		}
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		previous = buf
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout waiting for %q", fn)
+		}
+		time.Sleep(time.Duration(e.PollInterval))
+	}
+}
+
+// readLog returns the errors of the job log file and removes the file
+func (e *Euromap63) readLog(fn string) []error {
+	buf, err := os.ReadFile(fn)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return []error{err}
+		}
+		return nil
+	}
+	if err := os.Remove(fn); err != nil {
+		e.Log.Errorf("Removing log file failed: %v", err)
+	}
+	return parseLog(bytes.NewReader(buf))
+}
+
+// withLogErrors adds the errors of the job log file to the error
+func (e *Euromap63) withLogErrors(fn string, err error) error {
+	return errors.Join(append([]error{err}, e.readLog(fn)...)...)
+}
+
+func (e *Euromap63) path(name string) string {
+	return filepath.Join(e.Directory, name)
+}
+
+// machinePath returns the path of the file as used by the machine in the
+// session and job files
+func (e *Euromap63) machinePath(name string) string {
+	if e.MachineDirectory == "" {
+		return name
+	}
+	// Keep the path separator used by the machine
+	sep := "/"
+	if strings.Contains(e.MachineDirectory, `\`) {
+		sep = `\`
+	}
+	return strings.TrimRight(e.MachineDirectory, `\/`) + sep + name
+}
+
+// writeAtomic writes the file using a temporary file so the machine never
+// sees a partially written file
+func writeAtomic(fn string, content []byte) error {
+	tmp := fn + ".tmp"
+	if err := os.WriteFile(tmp, content, 0640); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, fn); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func init() {
+	inputs.Add("euromap63", func() telegraf.Input {
+		return &Euromap63{
+			Session:      1,
+			Timeout:      config.Duration(30 * time.Second),
+			PollInterval: config.Duration(500 * time.Millisecond),
+		}
+	})
+}
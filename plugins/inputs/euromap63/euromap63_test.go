@@ -0,0 +1,294 @@
+package euromap63
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Euromap63
+		expected string
+	}{
+		{
+			name:     "no directory",
+			plugin:   &Euromap63{},
+			expected: "'directory' must be specified",
+		},
+		{
+			name:     "invalid session",
+			plugin:   &Euromap63{Directory: "testdata", Session: 10000},
+			expected: "invalid 'session' 10000",
+		},
+		{
+			name:     "no parameters",
+			plugin:   &Euromap63{Directory: "testdata"},
+			expected: "no parameters defined",
+		},
+		{
+			name:     "invalid parameter",
+			plugin:   &Euromap63{Directory: "testdata", Parameters: []string{"ActCntCyc;"}},
+			expected: `invalid parameter "ActCntCyc;"`,
+		},
+		{
+			name:     "invalid timezone",
+			plugin:   &Euromap63{Directory: "testdata", Parameters: []string{"ActCntCyc"}, Timezone: "Mars/Olympus"},
+			expected: `invalid 'timezone' "Mars/Olympus"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Timeout = config.Duration(time.Second)
+			tt.plugin.PollInterval = config.Duration(time.Second)
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	require.Equal(t,
+		[]string{"00000001", "COMMAND", "1", "PROCESSED", "EXECUTE command"},
+		tokenize(`00000001 COMMAND 1 PROCESSED "EXECUTE command";`),
+	)
+	require.Equal(t,
+		[]string{"00000002", "EXECUTE", `C:\EM63\SESS0001\TLGF0001.JOB`},
+		tokenize(`00000002 EXECUTE "C:\EM63\SESS0001\TLGF0001.JOB";`),
+	)
+}
+
+func TestParseResponse(t *testing.T) {
+	response := "00000001 COMMAND 1 PROCESSED \"EXECUTE command\";\r\n00000002 COMMAND 1 ERROR 12 \"Job file not found\";\r\n"
+	require.NoError(t, parseResponse(strings.NewReader(response), 1))
+	require.EqualError(t, parseResponse(strings.NewReader(response), 2), "command failed: 12 Job file not found")
+	require.EqualError(t, parseResponse(strings.NewReader(response), 3), "no response for command 00000003")
+}
+
+func TestParseReport(t *testing.T) {
+	report := "DATE,TIME,ActCntCyc,ActTimCyc,ActStsMach\r\n20240315,08:30:15,12345,35.25,\"0A000\"\r\n"
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	fields, timestamp, err := parseReport(strings.NewReader(report), loc)
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"ActCntCyc":  int64(12345),
+		"ActTimCyc":  float64(35.25),
+		"ActStsMach": "0A000",
+	}, fields)
+	require.Equal(t, time.Date(2024, 3, 15, 8, 30, 15, 0, loc), timestamp)
+
+	_, _, err = parseReport(strings.NewReader("DATE,TIME,ActCntCyc\r\n20240315,08:30:15\r\n"), loc)
+	require.EqualError(t, err, "2 values for 3 parameters")
+}
+
+func TestGather(t *testing.T) {
+	dir := t.TempDir()
+	machine := &fakeMachine{
+		dir: dir,
+		values: map[string]string{
+			"DATE":       "20240315",
+			"TIME":       "08:30:15",
+			"ActCntCyc":  "12345",
+			"ActTimCyc":  "35.25",
+			"ActStsMach": `"0A000"`,
+		},
+	}
+	machine.start(t)
+
+	plugin := &Euromap63{
+		Directory:        dir,
+		MachineDirectory: `C:\EM63\`,
+		Session:          1,
+		Parameters:       []string{"ActCntCyc", "ActTimCyc", "ActStsMach"},
+		Timezone:         "UTC",
+		Timeout:          config.Duration(3 * time.Second),
+		PollInterval:     config.Duration(10 * time.Millisecond),
+		Log:              testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"euromap63",
+			map[string]string{"session": "SESS0001"},
+			map[string]interface{}{
+				"ActCntCyc":  int64(12345),
+				"ActTimCyc":  float64(35.25),
+				"ActStsMach": "0A000",
+			},
+			time.Date(2024, 3, 15, 8, 30, 15, 0, time.UTC),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+
+	// The machine must see its own paths and all files must be removed
+	require.Equal(t, `C:\EM63\TLGF0001.JOB`, machine.lastJob())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestGatherError(t *testing.T) {
+	dir := t.TempDir()
+	machine := &fakeMachine{dir: dir, fail: true}
+	machine.start(t)
+
+	plugin := &Euromap63{
+		Directory:    dir,
+		Session:      1,
+		Parameters:   []string{"ActCntCyc"},
+		Timeout:      config.Duration(3 * time.Second),
+		PollInterval: config.Duration(10 * time.Millisecond),
+		Log:          testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	err := plugin.Gather(&acc)
+	require.ErrorContains(t, err, "command failed: 3 Error in job file")
+	require.ErrorContains(t, err, "00012 Unknown parameter ActCntCyc")
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestGatherTimeout(t *testing.T) {
+	dir := t.TempDir()
+	plugin := &Euromap63{
+		Directory:    dir,
+		Parameters:   []string{"ActCntCyc"},
+		Timeout:      config.Duration(50 * time.Millisecond),
+		PollInterval: config.Duration(10 * time.Millisecond),
+		Log:          testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.ErrorContains(t, plugin.Gather(&acc), "timeout waiting for")
+
+	// The request must be withdrawn
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+// fakeMachine processes session requests in the directory by writing the
+// report and the response
+type fakeMachine struct {
+	dir    string
+	values map[string]string
+	fail   bool
+	job    chan string
+}
+
+func (m *fakeMachine) start(t *testing.T) {
+	m.job = make(chan string, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			request, err := os.ReadFile(filepath.Join(m.dir, "SESS0001.REQ"))
+			if err != nil {
+				continue
+			}
+			if err := m.process(string(request)); err != nil {
+				t.Errorf("processing request failed: %v", err)
+				return
+			}
+		}
+	}()
+}
+
+func (m *fakeMachine) lastJob() string {
+	select {
+	case job := <-m.job:
+		return job
+	default:
+		return ""
+	}
+}
+
+func (m *fakeMachine) process(request string) error {
+	tokens := tokenize(request)
+	if len(tokens) != 3 || tokens[1] != "EXECUTE" {
+		return fmt.Errorf("invalid request %q", request)
+	}
+	m.job <- tokens[2]
+	if err := os.Remove(filepath.Join(m.dir, "SESS0001.REQ")); err != nil {
+		return err
+	}
+
+	// Use the file names only as the machine directory is not accessible
+	name := func(fn string) string {
+		return filepath.Join(m.dir, fn[strings.LastIndexAny(fn, `\/`)+1:])
+	}
+	job, err := os.ReadFile(name(tokens[2]))
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(strings.ReplaceAll(string(job), "\r\n", "\n"), "\n")
+	logFile, reportFile := tokenize(lines[0])[3], tokenize(lines[1])[3]
+
+	if m.fail {
+		log := "20240315 08:30:15 ERROR 00012 \"Unknown parameter ActCntCyc\";\r\n"
+		if err := os.WriteFile(name(logFile), []byte(log), 0600); err != nil {
+			return err
+		}
+		response := tokens[0] + " COMMAND 1 ERROR 3 \"Error in job file\";\r\n"
+		return os.WriteFile(filepath.Join(m.dir, "SESS0001.RSP"), []byte(response), 0600)
+	}
+
+	response := tokens[0] + " COMMAND 1 PROCESSED \"EXECUTE command\";\r\n"
+	if err := os.WriteFile(filepath.Join(m.dir, "SESS0001.RSP"), []byte(response), 0600); err != nil {
+		return err
+	}
+
+	// Report the requested parameters in the order of the job
+	var names, values []string
+	start := false
+	for _, line := range lines {
+		line = strings.TrimRight(strings.TrimSpace(line), ",;")
+		if line == "PARAMETERS" {
+			start = true
+			continue
+		}
+		if start && line != "" {
+			names = append(names, line)
+			values = append(values, m.values[line])
+		}
+	}
+	report := strings.Join(names, ",") + "\r\n" + strings.Join(values, ",") + "\r\n"
+	return os.WriteFile(name(reportFile), []byte(report), 0600)
+}
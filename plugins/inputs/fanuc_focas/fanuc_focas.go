@@ -0,0 +1,405 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package fanuc_focas
+
+import (
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+var availableCollections = []string{"status", "program", "axes", "spindles", "alarms", "counters"}
+
+// Names of the automatic operation modes
+var modes = []string{"mdi", "memory", "none", "edit", "handle", "jog", "teach-jog", "teach-handle", "incremental", "reference", "remote"}
+
+// Names of the run states of automatic operation
+var runStates = []string{"reset", "stop", "hold", "start", "mstr"}
+
+// Names of the axis motion states
+var motionStates = []string{"none", "motion", "dwell"}
+
+// Parameters of the part counters and operating times
+const (
+	paramPartsCount    = 6711
+	paramPartsTotal    = 6712
+	paramPartsRequired = 6713
+	paramTimesStart    = 6750
+	paramTimesEnd      = 6758
+)
+
+// Operating times consisting of the minutes and milliseconds parameters
+var operatingTimes = []struct {
+	field        string
+	minutes      int32
+	milliseconds int32
+}{
+	{"power_on_time", 6750, 0},
+	{"operating_time", 6752, 6751},
+	{"cutting_time", 6754, 6753},
+	{"cycle_time", 6758, 6757},
+}
+
+type FanucFOCAS struct {
+	Address string          `toml:"address"`
+	Timeout config.Duration `toml:"timeout"`
+	Collect []string        `toml:"collect"`
+	Log     telegraf.Logger `toml:"-"`
+}
+
+// collection is a set of function calls and the handler adding the results
+type collection struct {
+	name     string
+	requests []request
+	handler  func(*gatherer, []result) error
+}
+
+// gatherer collects the values of one gather cycle
+type gatherer struct {
+	acc       telegraf.Accumulator
+	tags      map[string]string
+	fields    map[string]interface{}
+	timestamp time.Time
+}
+
+func (*FanucFOCAS) SampleConfig() string {
+	return sampleConfig
+}
+
+func (f *FanucFOCAS) Init() error {
+	if f.Address == "" {
+		return errors.New("'address' must be specified")
+	}
+	var nerr *net.AddrError
+	if _, _, err := net.SplitHostPort(f.Address); errors.As(err, &nerr) && strings.Contains(nerr.Err, "missing port") {
+		f.Address += ":8193"
+	}
+	if f.Timeout <= 0 {
+		return errors.New("'timeout' must be positive")
+	}
+	if len(f.Collect) == 0 {
+		f.Collect = availableCollections
+	}
+	for _, c := range f.Collect {
+		if !slices.Contains(availableCollections, c) {
+			return fmt.Errorf("invalid collection %q", c)
+		}
+	}
+	return nil
+}
+
+func (f *FanucFOCAS) Gather(acc telegraf.Accumulator) error {
+	c, err := connect(f.Address, time.Duration(f.Timeout))
+	if err != nil {
+		return fmt.Errorf("connecting to %s failed: %w", f.Address, err)
+	}
+	defer c.close()
+
+	results, err := c.call(request{function: funcSystemInfo})
+	if err != nil {
+		return fmt.Errorf("reading system info failed: %w", err)
+	}
+	if results[0].err != nil {
+		return fmt.Errorf("reading system info failed: %w", results[0].err)
+	}
+	info, err := decodeSystemInfo(results[0].data)
+	if err != nil {
+		return err
+	}
+
+	host, _, _ := net.SplitHostPort(f.Address)
+	g := &gatherer{
+		acc: acc,
+		tags: map[string]string{
+			"address":  host,
+			"cnc_type": info.cncType,
+			"series":   info.series,
+			"version":  info.version,
+		},
+		fields: make(map[string]interface{}),
+	}
+
+	// Read all selected collections using a single request
+	collections := make([]collection, 0, len(f.Collect))
+	requests := make([]request, 0, 16)
+	for _, name := range f.Collect {
+		col := newCollection(name)
+		collections = append(collections, col)
+		requests = append(requests, col.requests...)
+	}
+	results, err = c.call(requests...)
+	if err != nil {
+		return fmt.Errorf("reading data failed: %w", err)
+	}
+	g.timestamp = time.Now()
+
+	for _, col := range collections {
+		n := len(col.requests)
+		if err := col.handler(g, results[:n]); err != nil {
+			acc.AddError(fmt.Errorf("reading %s failed: %w", col.name, err))
+		}
+		results = results[n:]
+	}
+	if len(g.fields) > 0 {
+		acc.AddFields("fanuc_focas", g.fields, g.tags, g.timestamp)
+	}
+
+	return nil
+}
+
+func newCollection(name string) collection {
+	switch name {
+	case "status":
+		return collection{
+			name: name,
+			requests: []request{
+				{function: funcStatusInfo},
+				{function: funcFeedRate},
+				{function: funcSpindleSpeed},
+			},
+			handler: (*gatherer).addStatus,
+		}
+	case "program":
+		return collection{
+			name:     name,
+			requests: []request{{function: funcProgram}},
+			handler:  (*gatherer).addProgram,
+		}
+	case "axes":
+		return collection{
+			name: name,
+			requests: []request{
+				{function: funcAxisName, args: [5]int32{maxNames}},
+				{function: funcPosition, args: [5]int32{positionAbsolute, -1}},
+				{function: funcPosition, args: [5]int32{positionMachine, -1}},
+				{function: funcPosition, args: [5]int32{positionRelative, -1}},
+				{function: funcPosition, args: [5]int32{positionDistance, -1}},
+			},
+			handler: (*gatherer).addAxes,
+		}
+	case "spindles":
+		return collection{
+			name: name,
+			requests: []request{
+				{function: funcSpindleName, args: [5]int32{maxNames}},
+				{function: funcSpindleMeter, args: [5]int32{0, -1}},
+				{function: funcSpindleMeter, args: [5]int32{1, -1}},
+			},
+			handler: (*gatherer).addSpindles,
+		}
+	case "alarms":
+		return collection{
+			name: name,
+			requests: []request{
+				{function: funcAlarmStatus},
+				{function: funcAlarmMessage, args: [5]int32{-1, maxAlarms}},
+			},
+			handler: (*gatherer).addAlarms,
+		}
+	case "counters":
+		return collection{
+			name: name,
+			requests: []request{
+				{function: funcParameter, args: [5]int32{paramPartsCount, paramPartsRequired}},
+				{function: funcParameter, args: [5]int32{paramTimesStart, paramTimesEnd}},
+			},
+			handler: (*gatherer).addCounters,
+		}
+	}
+	panic("unknown collection " + name)
+}
+
+func (g *gatherer) addStatus(results []result) error {
+	if err := firstError(results); err != nil {
+		return err
+	}
+	s, err := decodeStatusInfo(results[0].data)
+	if err != nil {
+		return err
+	}
+	g.fields["mode"] = stateName(modes, s.mode)
+	g.fields["run_state"] = stateName(runStates, s.run)
+	g.fields["motion"] = stateName(motionStates, s.motion)
+	g.fields["emergency"] = s.emergency != 0
+	g.fields["alarm"] = s.alarm != 0
+	g.fields["edit"] = int64(s.edit)
+
+	if len(results[1].data) >= 8 {
+		g.fields["feed_rate"] = decodeValue(results[1].data)
+	}
+	if len(results[2].data) >= 8 {
+		g.fields["spindle_speed"] = decodeValue(results[2].data)
+	}
+	return nil
+}
+
+func (g *gatherer) addProgram(results []result) error {
+	if err := firstError(results); err != nil {
+		return err
+	}
+	data := results[0].data
+	if len(data) < 8 {
+		return errors.New("truncated program number")
+	}
+	g.fields["program_number"] = int64(int32(binary.BigEndian.Uint32(data)))
+	g.fields["main_program_number"] = int64(int32(binary.BigEndian.Uint32(data[4:])))
+	return nil
+}
+
+func (g *gatherer) addAxes(results []result) error {
+	if err := firstError(results); err != nil {
+		return err
+	}
+	names := decodeNames(results[0].data)
+	positions := []string{"absolute", "machine", "relative", "distance_to_go"}
+	for i, name := range names {
+		fields := make(map[string]interface{}, len(positions))
+		for j, pos := range positions {
+			if values := decodeValues(results[j+1].data); i < len(values) {
+				fields[pos] = values[i]
+			}
+		}
+		tags := g.newTags()
+		tags["axis"] = name
+		g.acc.AddFields("fanuc_focas_axis", fields, tags, g.timestamp)
+	}
+	return nil
+}
+
+func (g *gatherer) addSpindles(results []result) error {
+	if err := firstError(results); err != nil {
+		return err
+	}
+	names := decodeNames(results[0].data)
+	loads := decodeValues(results[1].data)
+	speeds := decodeValues(results[2].data)
+	for i, name := range names {
+		fields := make(map[string]interface{}, 2)
+		if i < len(loads) {
+			fields["load"] = loads[i]
+		}
+		if i < len(speeds) {
+			fields["motor_speed"] = speeds[i]
+		}
+		tags := g.newTags()
+		tags["spindle"] = name
+		g.acc.AddFields("fanuc_focas_spindle", fields, tags, g.timestamp)
+	}
+	return nil
+}
+
+func (g *gatherer) addAlarms(results []result) error {
+	if err := firstError(results); err != nil {
+		return err
+	}
+	if len(results[0].data) < 4 {
+		return errors.New("truncated alarm status")
+	}
+	g.fields["alarm_status"] = uint64(binary.BigEndian.Uint32(results[0].data))
+
+	alarms := decodeAlarms(results[1].data)
+	g.fields["alarm_count"] = len(alarms)
+	for _, a := range alarms {
+		tags := g.newTags()
+		tags["number"] = strconv.FormatInt(int64(a.number), 10)
+		tags["type"] = strconv.FormatInt(int64(a.typ), 10)
+		if a.axis > 0 {
+			tags["axis"] = strconv.FormatInt(int64(a.axis), 10)
+		}
+		g.acc.AddFields("fanuc_focas_alarm", map[string]interface{}{"message": a.message}, tags, g.timestamp)
+	}
+	return nil
+}
+
+func (g *gatherer) addCounters(results []result) error {
+	if err := firstError(results); err != nil {
+		return err
+	}
+	params := decodeParameters(results[0].data)
+	for k, v := range decodeParameters(results[1].data) {
+		params[k] = v
+	}
+
+	counters := []struct {
+		field  string
+		number int32
+	}{
+		{"parts_count", paramPartsCount},
+		{"parts_total", paramPartsTotal},
+		{"parts_required", paramPartsRequired},
+	}
+	for _, c := range counters {
+		if v, found := params[c.number]; found {
+			g.fields[c.field] = int64(v)
+		}
+	}
+
+	// Operating times are reported in seconds
+	for _, t := range operatingTimes {
+		minutes, found := params[t.minutes]
+		if !found {
+			continue
+		}
+		seconds := minutes * 60
+		if t.milliseconds != 0 {
+			seconds += params[t.milliseconds] / 1000
+		}
+		g.fields[t.field] = seconds
+	}
+	return nil
+}
+
+// decodeParameters decodes the parameter entries consisting of the number
+// and the value
+func decodeParameters(buf []byte) map[int32]float64 {
+	params := make(map[int32]float64, len(buf)/12)
+	for ; len(buf) >= 12; buf = buf[12:] {
+		params[int32(binary.BigEndian.Uint32(buf))] = decodeValue(buf[4:])
+	}
+	return params
+}
+
+func (g *gatherer) newTags() map[string]string {
+	tags := make(map[string]string, len(g.tags)+3)
+	for k, v := range g.tags {
+		tags[k] = v
+	}
+	return tags
+}
+
+func firstError(results []result) error {
+	for _, r := range results {
+		if r.err != nil {
+			return r.err
+		}
+	}
+	return nil
+}
+
+func stateName(names []string, state int16) string {
+	if state >= 0 && int(state) < len(names) {
+		return names[state]
+	}
+	return strconv.Itoa(int(state))
+}
+
+func init() {
+	inputs.Add("fanuc_focas", func() telegraf.Input {
+		return &FanucFOCAS{
+			Timeout: config.Duration(5 * time.Second),
+		}
+	})
+}
@@ -0,0 +1,336 @@
+package fanuc_focas
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *FanucFOCAS
+		expected string
+	}{
+		{
+			name:     "no address",
+			plugin:   &FanucFOCAS{},
+			expected: "'address' must be specified",
+		},
+		{
+			name:     "invalid collection",
+			plugin:   &FanucFOCAS{Address: "127.0.0.1", Collect: []string{"status", "foo"}},
+			expected: `invalid collection "foo"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Timeout = config.Duration(time.Second)
+			tt.plugin.Log = testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestDecodeValue(t *testing.T) {
+	require.InDelta(t, 120.5, decodeValue(value(120500, 10, 3)), 1e-9)
+	require.InDelta(t, -230.125, decodeValue(value(-230125, 10, 3)), 1e-9)
+	require.InDelta(t, 42.0, decodeValue(value(42, 0, 0)), 1e-9)
+}
+
+func TestGather(t *testing.T) {
+	cnc := newFakeCNC(t)
+	cnc.set(funcSystemInfo, 0, []byte{0, 0, 0, 4, '3', '1', ' ', 'M', 'G', '4', '2', '1', '3', '0', '.', '0', ' ', '3'})
+	cnc.set(funcStatusInfo, 0, int16s(1, 3, 1, 0, 0, 1, 0))
+	cnc.set(funcFeedRate, 0, value(1200, 0, 0))
+	cnc.set(funcSpindleSpeed, 0, value(8000, 0, 0))
+	cnc.set(funcProgram, 0, append(int32s(1001), int32s(1000)...))
+	cnc.set(funcAxisName, maxNames, []byte{'X', 0, 0, 0, 'Z', 0, 0, 0})
+	cnc.set(funcPosition, positionAbsolute, append(value(120500, 10, 3), value(-5000, 10, 3)...))
+	cnc.set(funcPosition, positionMachine, append(value(-230125, 10, 3), value(-105000, 10, 3)...))
+	cnc.set(funcPosition, positionRelative, append(value(120500, 10, 3), value(-5000, 10, 3)...))
+	cnc.set(funcPosition, positionDistance, append(value(10000, 10, 3), value(0, 10, 3)...))
+	cnc.set(funcSpindleName, maxNames, []byte{'S', '1', 0, 0})
+	cnc.set(funcSpindleMeter, 0, value(35, 0, 0))
+	cnc.set(funcSpindleMeter, 1, value(8000, 0, 0))
+	cnc.set(funcAlarmStatus, 0, int32s(0x0100))
+	cnc.set(funcAlarmMessage, -1, alarmEntry(1001, 2, 0, "SPINDLE OVERHEAT"))
+	cnc.set(funcParameter, paramPartsCount, append(append(param(6711, 1523), param(6712, 48211)...), param(6713, 2000)...))
+	cnc.setError(funcParameter, paramTimesStart, 6)
+
+	plugin := &FanucFOCAS{
+		Address: cnc.addr(),
+		Timeout: config.Duration(time.Second),
+		Log:     testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.ErrorContains(t, acc.Errors[0], "reading counters failed: FOCAS error 6: no option")
+	require.Equal(t, 2, cnc.dataRequests())
+
+	tags := func(extra ...string) map[string]string {
+		t := map[string]string{
+			"address":  "127.0.0.1",
+			"cnc_type": "31",
+			"series":   "G421",
+			"version":  "30.0",
+		}
+		for i := 0; i < len(extra); i += 2 {
+			t[extra[i]] = extra[i+1]
+		}
+		return t
+	}
+	expected := []telegraf.Metric{
+		metric.New(
+			"fanuc_focas_axis",
+			tags("axis", "X"),
+			map[string]interface{}{"absolute": 120.5, "machine": -230.125, "relative": 120.5, "distance_to_go": 10.0},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"fanuc_focas_axis",
+			tags("axis", "Z"),
+			map[string]interface{}{"absolute": -5.0, "machine": -105.0, "relative": -5.0, "distance_to_go": 0.0},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"fanuc_focas_spindle",
+			tags("spindle", "S1"),
+			map[string]interface{}{"load": 35.0, "motor_speed": 8000.0},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"fanuc_focas_alarm",
+			tags("number", "1001", "type", "2"),
+			map[string]interface{}{"message": "SPINDLE OVERHEAT"},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"fanuc_focas",
+			tags(),
+			map[string]interface{}{
+				"mode":                "memory",
+				"run_state":           "start",
+				"motion":              "motion",
+				"emergency":           false,
+				"alarm":               true,
+				"edit":                int64(0),
+				"feed_rate":           1200.0,
+				"spindle_speed":       8000.0,
+				"program_number":      int64(1001),
+				"main_program_number": int64(1000),
+				"alarm_status":        uint64(0x0100),
+				"alarm_count":         1,
+			},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
+
+func TestCounters(t *testing.T) {
+	cnc := newFakeCNC(t)
+	cnc.set(funcSystemInfo, 0, make([]byte, 18))
+	cnc.set(funcParameter, paramPartsCount, param(6711, 12))
+	times := make([]byte, 0, 9*12)
+	for i, v := range []int32{6000, 500, 1440, 250, 60, 0, 0, 1500, 2} {
+		times = append(times, param(paramTimesStart+int32(i), v)...)
+	}
+	cnc.set(funcParameter, paramTimesStart, times)
+
+	plugin := &FanucFOCAS{
+		Address: cnc.addr(),
+		Timeout: config.Duration(time.Second),
+		Collect: []string{"counters"},
+		Log:     testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"fanuc_focas",
+			map[string]string{"address": "127.0.0.1", "cnc_type": "", "series": "", "version": ""},
+			map[string]interface{}{
+				"parts_count":    int64(12),
+				"power_on_time":  360000.0,
+				"operating_time": 86400.5,
+				"cutting_time":   3600.25,
+				"cycle_time":     121.5,
+			},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
+
+func TestTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	plugin := &FanucFOCAS{
+		Address: listener.Addr().String(),
+		Timeout: config.Duration(100 * time.Millisecond),
+		Log:     testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.ErrorContains(t, plugin.Gather(&acc), "opening session failed")
+}
+
+// fakeCNC answers the function calls with the configured data indexed by
+// the function code and the first argument
+type fakeCNC struct {
+	listener net.Listener
+	data     map[[2]int32][]byte
+	errors   map[[2]int32]int16
+	calls    int
+	sync.Mutex
+}
+
+func newFakeCNC(t *testing.T) *fakeCNC {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	c := &fakeCNC{
+		listener: listener,
+		data:     make(map[[2]int32][]byte),
+		errors:   make(map[[2]int32]int16),
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go c.serve(conn)
+		}
+	}()
+	return c
+}
+
+func (c *fakeCNC) addr() string {
+	return c.listener.Addr().String()
+}
+
+func (c *fakeCNC) set(function uint16, arg int32, data []byte) {
+	c.Lock()
+	defer c.Unlock()
+	c.data[[2]int32{int32(function), arg}] = data
+}
+
+func (c *fakeCNC) setError(function uint16, arg int32, code int16) {
+	c.Lock()
+	defer c.Unlock()
+	c.errors[[2]int32{int32(function), arg}] = code
+}
+
+// dataRequests returns the number of data requests received
+func (c *fakeCNC) dataRequests() int {
+	c.Lock()
+	defer c.Unlock()
+	return c.calls
+}
+
+func (c *fakeCNC) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		ftype, payload, err := readFrame(conn)
+		if err != nil {
+			return
+		}
+
+		var resp []byte
+		switch ftype {
+		case frameOpenRequest:
+			resp = encodeFrame(frameOpenResponse, nil)
+		case frameCloseRequest:
+			resp = encodeFrame(0x0202, nil)
+		case frameDataRequest:
+			resp = encodeFrame(frameDataResponse, c.respond(payload))
+		}
+		if _, err := conn.Write(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (c *fakeCNC) respond(payload []byte) []byte {
+	c.Lock()
+	defer c.Unlock()
+	c.calls++
+
+	count := binary.BigEndian.Uint16(payload)
+	resp := binary.BigEndian.AppendUint16(nil, count)
+	payload = payload[2:]
+	for range count {
+		length := binary.BigEndian.Uint16(payload)
+		req := payload[2:length]
+		payload = payload[length:]
+
+		function := binary.BigEndian.Uint16(req[4:])
+		key := [2]int32{int32(function), int32(binary.BigEndian.Uint32(req[6:]))}
+		block := make([]byte, 14)
+		copy(block, req[:6])
+		binary.BigEndian.PutUint16(block[6:], uint16(c.errors[key]))
+		data := c.data[key]
+		binary.BigEndian.PutUint16(block[12:], uint16(len(data)))
+		block = append(block, data...)
+		resp = binary.BigEndian.AppendUint16(resp, uint16(len(block)+2))
+		resp = append(resp, block...)
+	}
+	return resp
+}
+
+func value(v int32, base, exponent int16) []byte {
+	buf := binary.BigEndian.AppendUint32(nil, uint32(v))
+	buf = binary.BigEndian.AppendUint16(buf, uint16(base))
+	return binary.BigEndian.AppendUint16(buf, uint16(exponent))
+}
+
+func param(number, v int32) []byte {
+	return append(binary.BigEndian.AppendUint32(nil, uint32(number)), value(v, 0, 0)...)
+}
+
+func int16s(values ...int16) []byte {
+	buf := make([]byte, 0, 2*len(values))
+	for _, v := range values {
+		buf = binary.BigEndian.AppendUint16(buf, uint16(v))
+	}
+	return buf
+}
+
+func int32s(values ...int32) []byte {
+	buf := make([]byte, 0, 4*len(values))
+	for _, v := range values {
+		buf = binary.BigEndian.AppendUint32(buf, uint32(v))
+	}
+	return buf
+}
+
+func alarmEntry(number, typ, axis int32, message string) []byte {
+	buf := int32s(number, typ, axis)
+	buf = binary.BigEndian.AppendUint16(buf, 0)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(message)))
+	msg := make([]byte, 32)
+	copy(msg, message)
+	return append(buf, msg...)
+}
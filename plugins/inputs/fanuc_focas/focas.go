@@ -0,0 +1,333 @@
+package fanuc_focas
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"time"
+)
+
+// Frame types of the FOCAS Ethernet protocol
+const (
+	frameOpenRequest  = 0x0101
+	frameOpenResponse = 0x0102
+	frameCloseRequest = 0x0201
+	frameDataRequest  = 0x2101
+	frameDataResponse = 0x2102
+)
+
+// Function codes of the CNC library functions
+const (
+	funcParameter    = 0x0E
+	funcSystemInfo   = 0x18
+	funcStatusInfo   = 0x19
+	funcAlarmStatus  = 0x1A
+	funcProgram      = 0x1C
+	funcAlarmMessage = 0x23
+	funcFeedRate     = 0x24
+	funcSpindleSpeed = 0x25
+	funcPosition     = 0x26
+	funcSpindleMeter = 0x40
+	funcAxisName     = 0x89
+	funcSpindleName  = 0x8A
+)
+
+// Position types of the position function
+const (
+	positionAbsolute = iota
+	positionMachine
+	positionRelative
+	positionDistance
+)
+
+// Maximum number of alarm messages and of axis or spindle names read at once
+const (
+	maxAlarms = 10
+	maxNames  = 32
+)
+
+var frameMagic = []byte{0xA0, 0xA0, 0xA0, 0xA0}
+
+// Descriptions of the FOCAS return codes
+var focasErrors = map[int16]string{
+	-1: "CNC busy",
+	1:  "function not executed or not available",
+	2:  "data block length error",
+	3:  "number error",
+	4:  "data attribute error",
+	5:  "data error",
+	6:  "no option",
+	7:  "write protection",
+	8:  "handle number error",
+	9:  "CNC parameter error",
+	10: "buffer empty or full",
+	11: "path number error",
+	12: "CNC mode error",
+	13: "CNC execution rejection",
+	14: "data server error",
+	15: "alarm state",
+	16: "stop state",
+	17: "data protected",
+}
+
+// request is a single CNC function call of a data request frame
+type request struct {
+	function uint16
+	args     [5]int32
+}
+
+func (r *request) encode() []byte {
+	buf := make([]byte, 26)
+	binary.BigEndian.PutUint16(buf[0:], 1)
+	binary.BigEndian.PutUint16(buf[2:], 1)
+	binary.BigEndian.PutUint16(buf[4:], r.function)
+	for i, a := range r.args {
+		binary.BigEndian.PutUint32(buf[6+4*i:], uint32(a))
+	}
+	return buf
+}
+
+// result is the outcome of a single function call
+type result struct {
+	data []byte
+	err  error
+}
+
+// conn is a FOCAS session with a CNC
+type conn struct {
+	conn    net.Conn
+	timeout time.Duration
+}
+
+func connect(address string, timeout time.Duration) (*conn, error) {
+	c, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	s := &conn{conn: c, timeout: timeout}
+
+	ftype, _, err := s.exchange(frameOpenRequest, []byte{0x00, 0x02})
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("opening session failed: %w", err)
+	}
+	if ftype != frameOpenResponse {
+		c.Close()
+		return nil, fmt.Errorf("unexpected response 0x%04x to open request", ftype)
+	}
+	return s, nil
+}
+
+func (c *conn) close() {
+	//nolint:errcheck // The session is terminated anyway
+	c.exchange(frameCloseRequest, nil)
+	c.conn.Close()
+}
+
+// exchange sends a frame and returns the type and payload of the response
+func (c *conn) exchange(ftype uint16, payload []byte) (uint16, []byte, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return 0, nil, err
+	}
+	if _, err := c.conn.Write(encodeFrame(ftype, payload)); err != nil {
+		return 0, nil, err
+	}
+	return readFrame(c.conn)
+}
+
+// call executes the given functions using a single data request and returns
+// the result of each function
+func (c *conn) call(requests ...request) ([]result, error) {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(len(requests)))
+	for _, r := range requests {
+		data := r.encode()
+		payload = binary.BigEndian.AppendUint16(payload, uint16(len(data)+2))
+		payload = append(payload, data...)
+	}
+
+	ftype, resp, err := c.exchange(frameDataRequest, payload)
+	if err != nil {
+		return nil, err
+	}
+	if ftype != frameDataResponse {
+		return nil, fmt.Errorf("unexpected response 0x%04x to data request", ftype)
+	}
+	return decodeResults(resp, requests)
+}
+
+func encodeFrame(ftype uint16, payload []byte) []byte {
+	buf := make([]byte, 10, 10+len(payload))
+	copy(buf, frameMagic)
+	binary.BigEndian.PutUint16(buf[4:], 1)
+	binary.BigEndian.PutUint16(buf[6:], ftype)
+	binary.BigEndian.PutUint16(buf[8:], uint16(len(payload)))
+	return append(buf, payload...)
+}
+
+func readFrame(r io.Reader) (uint16, []byte, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	if !bytes.Equal(header[:4], frameMagic) {
+		return 0, nil, errors.New("invalid frame header")
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[8:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return binary.BigEndian.Uint16(header[6:]), payload, nil
+}
+
+// decodeResults splits the payload of a data response into the results of
+// the requested functions
+func decodeResults(buf []byte, requests []request) ([]result, error) {
+	if len(buf) < 2 {
+		return nil, errors.New("truncated data response")
+	}
+	if count := int(binary.BigEndian.Uint16(buf)); count != len(requests) {
+		return nil, fmt.Errorf("received %d results for %d requests", count, len(requests))
+	}
+	buf = buf[2:]
+
+	results := make([]result, 0, len(requests))
+	for _, r := range requests {
+		if len(buf) < 2 {
+			return nil, errors.New("truncated result")
+		}
+		length := int(binary.BigEndian.Uint16(buf))
+		if length < 16 || len(buf) < length {
+			return nil, fmt.Errorf("invalid result length %d", length)
+		}
+		block := buf[2:length]
+		buf = buf[length:]
+
+		if binary.BigEndian.Uint16(block[4:]) != r.function {
+			return nil, fmt.Errorf("result does not match function 0x%02x", r.function)
+		}
+		if code := int16(binary.BigEndian.Uint16(block[6:])); code != 0 {
+			results = append(results, result{err: focasError(code)})
+			continue
+		}
+		size := int(binary.BigEndian.Uint16(block[12:]))
+		if len(block)-14 < size {
+			return nil, errors.New("result data exceeds block")
+		}
+		results = append(results, result{data: block[14 : 14+size]})
+	}
+	return results, nil
+}
+
+func focasError(code int16) error {
+	if msg, found := focasErrors[code]; found {
+		return fmt.Errorf("FOCAS error %d: %s", code, msg)
+	}
+	return fmt.Errorf("FOCAS error %d", code)
+}
+
+// decodeValue converts the value with the scaling of the CNC, consisting of
+// the raw value, the base and the number of decimal places
+func decodeValue(buf []byte) float64 {
+	value := float64(int32(binary.BigEndian.Uint32(buf)))
+	base := int16(binary.BigEndian.Uint16(buf[4:]))
+	exponent := int16(binary.BigEndian.Uint16(buf[6:]))
+	if base == 0 || exponent == 0 {
+		return value
+	}
+	return value / math.Pow(float64(base), float64(exponent))
+}
+
+// decodeValues decodes the values of all axes or spindles
+func decodeValues(buf []byte) []float64 {
+	values := make([]float64, 0, len(buf)/8)
+	for ; len(buf) >= 8; buf = buf[8:] {
+		values = append(values, decodeValue(buf))
+	}
+	return values
+}
+
+// decodeNames decodes the names of the axes or spindles consisting of the
+// name and suffix characters
+func decodeNames(buf []byte) []string {
+	names := make([]string, 0, len(buf)/4)
+	for ; len(buf) >= 4; buf = buf[4:] {
+		names = append(names, trimString(buf[:2]))
+	}
+	return names
+}
+
+// systemInfo describes the CNC
+type systemInfo struct {
+	cncType string
+	series  string
+	version string
+}
+
+func decodeSystemInfo(buf []byte) (*systemInfo, error) {
+	if len(buf) < 18 {
+		return nil, errors.New("truncated system info")
+	}
+	return &systemInfo{
+		cncType: trimString(buf[4:6]),
+		series:  trimString(buf[8:12]),
+		version: trimString(buf[12:16]),
+	}, nil
+}
+
+// statusInfo is the operating state of the CNC
+type statusInfo struct {
+	mode      int16
+	run       int16
+	motion    int16
+	emergency int16
+	alarm     int16
+	edit      int16
+}
+
+func decodeStatusInfo(buf []byte) (*statusInfo, error) {
+	if len(buf) < 14 {
+		return nil, errors.New("truncated status info")
+	}
+	v := func(i int) int16 { return int16(binary.BigEndian.Uint16(buf[2*i:])) }
+	return &statusInfo{
+		mode:      v(0),
+		run:       v(1),
+		motion:    v(2),
+		emergency: v(4),
+		alarm:     v(5),
+		edit:      v(6),
+	}, nil
+}
+
+type alarm struct {
+	number  int32
+	typ     int32
+	axis    int32
+	message string
+}
+
+// decodeAlarms decodes the alarm messages consisting of the number, type,
+// axis, message length and the message text
+func decodeAlarms(buf []byte) []alarm {
+	const size = 48
+	alarms := make([]alarm, 0, len(buf)/size)
+	for ; len(buf) >= size; buf = buf[size:] {
+		length := min(int(binary.BigEndian.Uint16(buf[14:])), 32)
+		alarms = append(alarms, alarm{
+			number:  int32(binary.BigEndian.Uint32(buf[0:])),
+			typ:     int32(binary.BigEndian.Uint32(buf[4:])),
+			axis:    int32(binary.BigEndian.Uint32(buf[8:])),
+			message: trimString(buf[16 : 16+length]),
+		})
+	}
+	return alarms
+}
+
+func trimString(buf []byte) string {
+	return strings.Trim(string(buf), " \x00")
+}
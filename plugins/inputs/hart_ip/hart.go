@@ -0,0 +1,403 @@
+package hart_ip
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"time"
+)
+
+// HART-IP message types
+const (
+	msgTypeRequest  = 0
+	msgTypeResponse = 1
+	msgTypeError    = 3
+	msgTypeNAK      = 15
+)
+
+// HART-IP message IDs
+const (
+	msgIDSessionInitiate = 0
+	msgIDSessionClose    = 1
+	msgIDPassThrough     = 3
+)
+
+// HART commands
+const (
+	cmdReadUniqueIdentifier    = 0
+	cmdReadDynamicVariables    = 3
+	cmdReadLongTag             = 20
+	cmdReadAdditionalStatus    = 48
+	cmdReadIOSystemCapability  = 74
+	cmdReadSubDeviceIdentities = 84
+)
+
+// Delimiters of the HART PDU
+const (
+	delimiterSTX     = 0x02
+	delimiterACK     = 0x06
+	delimiterLongBit = 0x80
+)
+
+const (
+	headerLength = 8
+	version      = 1
+	primaryHost  = 0x80
+)
+
+// Descriptions of the command response codes being errors
+var responseCodes = map[byte]string{
+	2:  "invalid selection",
+	3:  "passed parameter too large",
+	4:  "passed parameter too small",
+	5:  "too few data bytes received",
+	6:  "device-specific command error",
+	7:  "in write protect mode",
+	16: "access restricted",
+	32: "busy",
+	33: "delayed response initiated",
+	34: "delayed response running",
+	35: "delayed response dead",
+	36: "delayed response conflict",
+	64: "command not implemented",
+}
+
+// Descriptions of the HART-IP status codes
+var statusCodes = map[byte]string{
+	2:  "invalid selection",
+	5:  "too few data bytes received",
+	6:  "device-specific command error",
+	14: "version not supported",
+	15: "all available sessions in use",
+	16: "access restricted",
+	32: "busy",
+	33: "session already established",
+}
+
+// identity holds the information of a field device as returned by the
+// read unique identifier command
+type identity struct {
+	address          [5]byte
+	manufacturerID   uint16
+	deviceType       uint16
+	deviceID         uint32
+	universalVersion uint8
+}
+
+// tags returns the tags identifying the device
+func (id *identity) tags() map[string]string {
+	return map[string]string{
+		"unique_id":       fmt.Sprintf("%010X", id.address[:]),
+		"manufacturer_id": fmt.Sprintf("0x%04X", id.manufacturerID),
+		"device_type":     fmt.Sprintf("0x%04X", id.deviceType),
+	}
+}
+
+// response is a decoded HART PDU returned by a field device
+type response struct {
+	command uint8
+	code    uint8
+	status  uint8
+	data    []byte
+}
+
+// client exchanges HART-IP messages with a gateway or device
+type client struct {
+	conn     net.Conn
+	packet   net.PacketConn
+	remote   *net.UDPAddr
+	timeout  time.Duration
+	sequence uint16
+}
+
+func dial(transport, address string, timeout time.Duration) (*client, error) {
+	c := &client{timeout: timeout}
+	if transport == "udp" {
+		remote, err := net.ResolveUDPAddr("udp", address)
+		if err != nil {
+			return nil, err
+		}
+		// Servers may answer the session initiation from a new port used for
+		// the remainder of the session, so do not connect the socket
+		pc, err := net.ListenPacket("udp", ":0")
+		if err != nil {
+			return nil, err
+		}
+		c.packet, c.remote = pc, remote
+	} else {
+		conn, err := net.DialTimeout(transport, address, timeout)
+		if err != nil {
+			return nil, err
+		}
+		c.conn = conn
+	}
+
+	// Request an inactivity timeout well above the request timeout
+	inactivity := uint32(min(max(4*timeout, 10*time.Second).Milliseconds(), math.MaxUint32))
+	body := binary.BigEndian.AppendUint32([]byte{1}, inactivity)
+	if _, err := c.exchange(msgIDSessionInitiate, body); err != nil {
+		c.closeConn()
+		return nil, fmt.Errorf("initiating session failed: %w", err)
+	}
+	return c, nil
+}
+
+func (c *client) close() {
+	// Closing the session is a courtesy to the server freeing the session
+	// early, the server drops it after the inactivity timeout otherwise
+	_, _ = c.exchange(msgIDSessionClose, nil)
+	c.closeConn()
+}
+
+func (c *client) closeConn() {
+	if c.packet != nil {
+		c.packet.Close()
+	} else {
+		c.conn.Close()
+	}
+}
+
+// exchange sends a request message and returns the body of the response
+// after checking the status
+func (c *client) exchange(id uint8, body []byte) ([]byte, error) {
+	c.sequence++
+	msg := make([]byte, headerLength, headerLength+len(body))
+	msg[0] = version
+	msg[1] = msgTypeRequest
+	msg[2] = id
+	binary.BigEndian.PutUint16(msg[4:], c.sequence)
+	binary.BigEndian.PutUint16(msg[6:], uint16(headerLength+len(body)))
+	msg = append(msg, body...)
+
+	deadline := time.Now().Add(c.timeout)
+	if c.packet != nil {
+		if err := c.packet.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+		if _, err := c.packet.WriteTo(msg, c.remote); err != nil {
+			return nil, err
+		}
+	} else {
+		if err := c.conn.SetDeadline(deadline); err != nil {
+			return nil, err
+		}
+		if _, err := c.conn.Write(msg); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		resp, err := c.read(id == msgIDSessionInitiate)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp) < headerLength {
+			return nil, errors.New("truncated message")
+		}
+		// Skip stale responses of previous requests, e.g. after a timeout
+		if resp[2] != id || binary.BigEndian.Uint16(resp[4:]) != c.sequence {
+			continue
+		}
+		switch resp[1] {
+		case msgTypeResponse:
+		case msgTypeError, msgTypeNAK:
+			return nil, fmt.Errorf("request rejected with %s", describeStatus(resp[3]))
+		default:
+			return nil, fmt.Errorf("unexpected message type %d", resp[1])
+		}
+		// Status code 8 is a warning indicating a modified inactivity timeout
+		if status := resp[3]; status != 0 && status != 8 {
+			return nil, fmt.Errorf("request failed with %s", describeStatus(status))
+		}
+		return resp[headerLength:], nil
+	}
+}
+
+// read returns the next message received from the server. The remote port
+// is updated with the origin of the response during session initiation.
+func (c *client) read(initiate bool) ([]byte, error) {
+	if c.packet == nil {
+		header := make([]byte, headerLength)
+		if _, err := io.ReadFull(c.conn, header); err != nil {
+			return nil, err
+		}
+		length := int(binary.BigEndian.Uint16(header[6:]))
+		if length < headerLength {
+			return nil, fmt.Errorf("invalid message length %d", length)
+		}
+		msg := make([]byte, length)
+		copy(msg, header)
+		if _, err := io.ReadFull(c.conn, msg[headerLength:]); err != nil {
+			return nil, err
+		}
+		return msg, nil
+	}
+
+	buf := make([]byte, 8192)
+	for {
+		n, addr, err := c.packet.ReadFrom(buf)
+		if err != nil {
+			return nil, err
+		}
+		origin, ok := addr.(*net.UDPAddr)
+		if !ok || !origin.IP.Equal(c.remote.IP) || !initiate && origin.Port != c.remote.Port {
+			continue
+		}
+		if initiate {
+			c.remote = origin
+		}
+		if n >= headerLength && int(binary.BigEndian.Uint16(buf[6:])) < n {
+			n = int(binary.BigEndian.Uint16(buf[6:]))
+		}
+		return buf[:n], nil
+	}
+}
+
+// command sends a HART command to the device with the given address being
+// either a one byte polling address or a five byte unique address
+func (c *client) command(address []byte, command uint8, data []byte) (*response, error) {
+	delimiter := byte(delimiterSTX)
+	addr := make([]byte, len(address))
+	copy(addr, address)
+	if len(addr) == 5 {
+		delimiter |= delimiterLongBit
+		addr[0] &= 0x3F
+	}
+	addr[0] |= primaryHost
+
+	pdu := make([]byte, 0, 4+len(addr)+len(data))
+	pdu = append(pdu, delimiter)
+	pdu = append(pdu, addr...)
+	pdu = append(pdu, command, byte(len(data)))
+	pdu = append(pdu, data...)
+	pdu = append(pdu, checksum(pdu))
+
+	body, err := c.exchange(msgIDPassThrough, pdu)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := decodePDU(body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.command != command {
+		return nil, fmt.Errorf("response does not match command %d", command)
+	}
+	if resp.code&0x80 != 0 {
+		return nil, fmt.Errorf("communication error 0x%02X", resp.code)
+	}
+	if msg, found := responseCodes[resp.code]; found {
+		return nil, fmt.Errorf("response code %d: %s", resp.code, msg)
+	}
+	return resp, nil
+}
+
+func decodePDU(buf []byte) (*response, error) {
+	if len(buf) < 1 {
+		return nil, errors.New("empty PDU")
+	}
+	delimiter := buf[0]
+	if delimiter&0x07 != delimiterACK {
+		return nil, fmt.Errorf("unexpected delimiter 0x%02X", delimiter)
+	}
+	pos := 2
+	if delimiter&delimiterLongBit != 0 {
+		pos = 6
+	}
+	// Skip expansion bytes
+	pos += int(delimiter>>5) & 0x03
+	if len(buf) < pos+2 {
+		return nil, errors.New("truncated PDU")
+	}
+	command, count := buf[pos], int(buf[pos+1])
+	pos += 2
+	if count < 2 || len(buf) < pos+count+1 {
+		return nil, errors.New("truncated PDU")
+	}
+	if checksum(buf[:pos+count]) != buf[pos+count] {
+		return nil, errors.New("checksum mismatch")
+	}
+	return &response{
+		command: command,
+		code:    buf[pos],
+		status:  buf[pos+1],
+		data:    buf[pos+2 : pos+count],
+	}, nil
+}
+
+func checksum(buf []byte) byte {
+	var sum byte
+	for _, b := range buf {
+		sum ^= b
+	}
+	return sum
+}
+
+func describeStatus(status byte) string {
+	if msg, found := statusCodes[status]; found {
+		return fmt.Sprintf("status %d (%s)", status, msg)
+	}
+	return fmt.Sprintf("status %d", status)
+}
+
+// decodeIdentity decodes the response of the read unique identifier command
+func decodeIdentity(data []byte) (*identity, error) {
+	if len(data) < 12 || data[0] != 254 {
+		return nil, errors.New("invalid unique identifier")
+	}
+	id := &identity{
+		manufacturerID:   uint16(data[1]),
+		deviceType:       uint16(data[2]),
+		deviceID:         uint32(data[9])<<16 | uint32(data[10])<<8 | uint32(data[11]),
+		universalVersion: data[4],
+	}
+	// Starting with revision 7 the device type is expanded to two bytes and
+	// the manufacturer is reported separately
+	if id.universalVersion >= 7 && len(data) >= 19 {
+		id.deviceType = binary.BigEndian.Uint16(data[1:])
+		id.manufacturerID = binary.BigEndian.Uint16(data[17:])
+	}
+	id.address = [5]byte{data[1] & 0x3F, data[2], data[9], data[10], data[11]}
+	return id, nil
+}
+
+// decodeSubDevice decodes the response of the read sub-device identity
+// summary command
+func decodeSubDevice(data []byte) (*identity, uint8, uint8, string, error) {
+	if len(data) < 12 {
+		return nil, 0, 0, "", errors.New("invalid sub-device identity")
+	}
+	card, channel := data[2], data[3]
+	id := &identity{
+		manufacturerID:   binary.BigEndian.Uint16(data[4:]),
+		deviceType:       binary.BigEndian.Uint16(data[6:]),
+		deviceID:         uint32(data[8])<<16 | uint32(data[9])<<8 | uint32(data[10]),
+		universalVersion: data[11],
+	}
+	id.address = [5]byte{data[6] & 0x3F, data[7], data[8], data[9], data[10]}
+	var tag string
+	if len(data) >= 44 {
+		tag = trimString(data[12:44])
+	}
+	return id, card, channel, tag, nil
+}
+
+// decodeFloat returns the big-endian IEEE 754 value and whether the value is
+// valid, i.e. not the "not a number" marker of unavailable variables
+func decodeFloat(buf []byte) (float64, bool) {
+	v := float64(math.Float32frombits(binary.BigEndian.Uint32(buf)))
+	return v, !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+func trimString(buf []byte) string {
+	// Strings use ISO Latin-1 encoding
+	runes := make([]rune, 0, len(buf))
+	for _, b := range buf {
+		runes = append(runes, rune(b))
+	}
+	return strings.Trim(string(runes), " \x00")
+}
@@ -0,0 +1,355 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package hart_ip
+
+import (
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+var availableCollections = []string{"dynamic_variables", "additional_status"}
+
+// Names of the dynamic variables in the order returned by command 3
+var dynamicVariables = []string{"pv", "sv", "tv", "qv"}
+
+// Names of the status bytes returned by command 48 starting at the extended
+// device status
+var additionalStatus = []string{
+	"extended_device_status",
+	"device_operating_mode",
+	"standardized_status_0",
+	"standardized_status_1",
+	"analog_channel_saturated",
+	"standardized_status_2",
+	"standardized_status_3",
+	"analog_channel_fixed",
+}
+
+// Symbols of common engineering units of the HART common tables
+var units = map[byte]string{
+	1:   "inH2O",
+	2:   "inHg",
+	3:   "ftH2O",
+	4:   "mmH2O",
+	5:   "mmHg",
+	6:   "psi",
+	7:   "bar",
+	8:   "mbar",
+	9:   "g/cm2",
+	10:  "kg/cm2",
+	11:  "Pa",
+	12:  "kPa",
+	13:  "torr",
+	14:  "atm",
+	15:  "ft3/min",
+	16:  "gal/min",
+	17:  "l/min",
+	19:  "m3/h",
+	20:  "ft/s",
+	21:  "m/s",
+	32:  "degC",
+	33:  "degF",
+	34:  "degR",
+	35:  "K",
+	36:  "mV",
+	37:  "ohm",
+	38:  "Hz",
+	39:  "mA",
+	40:  "gal",
+	41:  "l",
+	43:  "m3",
+	44:  "ft",
+	45:  "m",
+	47:  "in",
+	48:  "cm",
+	49:  "mm",
+	50:  "min",
+	51:  "s",
+	52:  "h",
+	53:  "d",
+	57:  "%",
+	58:  "V",
+	59:  "pH",
+	60:  "g",
+	61:  "kg",
+	70:  "g/s",
+	73:  "kg/s",
+	75:  "kg/h",
+	237: "MPa",
+	// Not used and none
+	250: "",
+	251: "",
+}
+
+type HARTIP struct {
+	Address            string          `toml:"address"`
+	Transport          string          `toml:"transport"`
+	PollingAddresses   []uint8         `toml:"polling_addresses"`
+	UniqueIDs          []string        `toml:"unique_ids"`
+	DiscoverSubDevices bool            `toml:"discover_subdevices"`
+	Collect            []string        `toml:"collect"`
+	Timeout            config.Duration `toml:"timeout"`
+	Log                telegraf.Logger `toml:"-"`
+
+	host      string
+	uniqueIDs [][]byte
+	longTags  map[[5]byte]string
+}
+
+// device is a field device to read with its identifying tags
+type device struct {
+	id   *identity
+	tags map[string]string
+}
+
+func (*HARTIP) SampleConfig() string {
+	return sampleConfig
+}
+
+func (h *HARTIP) Init() error {
+	if h.Address == "" {
+		return errors.New("'address' must be specified")
+	}
+	var nerr *net.AddrError
+	if _, _, err := net.SplitHostPort(h.Address); errors.As(err, &nerr) && strings.Contains(nerr.Err, "missing port") {
+		h.Address += ":5094"
+	}
+	host, _, err := net.SplitHostPort(h.Address)
+	if err != nil {
+		return fmt.Errorf("invalid 'address' %q: %w", h.Address, err)
+	}
+	h.host = host
+
+	switch h.Transport {
+	case "":
+		h.Transport = "udp"
+	case "udp", "tcp":
+	default:
+		return fmt.Errorf("invalid 'transport' %q", h.Transport)
+	}
+	if h.Timeout <= 0 {
+		return errors.New("'timeout' must be positive")
+	}
+
+	for _, a := range h.PollingAddresses {
+		if a > 63 {
+			return fmt.Errorf("invalid polling address %d", a)
+		}
+	}
+	h.uniqueIDs = make([][]byte, 0, len(h.UniqueIDs))
+	for _, s := range h.UniqueIDs {
+		id, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(s), "0x"))
+		if err != nil || len(id) != 5 {
+			return fmt.Errorf("invalid unique ID %q, expected 10 hexadecimal digits", s)
+		}
+		h.uniqueIDs = append(h.uniqueIDs, id)
+	}
+	if len(h.PollingAddresses) == 0 && len(h.uniqueIDs) == 0 && !h.DiscoverSubDevices {
+		h.PollingAddresses = []uint8{0}
+	}
+
+	if len(h.Collect) == 0 {
+		h.Collect = availableCollections
+	}
+	for _, c := range h.Collect {
+		if !slices.Contains(availableCollections, c) {
+			return fmt.Errorf("invalid collection %q", c)
+		}
+	}
+
+	h.longTags = make(map[[5]byte]string)
+
+	return nil
+}
+
+func (h *HARTIP) Gather(acc telegraf.Accumulator) error {
+	c, err := dial(h.Transport, h.Address, time.Duration(h.Timeout))
+	if err != nil {
+		return fmt.Errorf("connecting to %s failed: %w", h.Address, err)
+	}
+	defer c.close()
+
+	devices := make([]*device, 0, len(h.PollingAddresses)+len(h.uniqueIDs))
+	for _, a := range h.PollingAddresses {
+		dev, err := h.identify(c, []byte{a})
+		if err != nil {
+			acc.AddError(fmt.Errorf("identifying device at polling address %d failed: %w", a, err))
+			continue
+		}
+		devices = append(devices, dev)
+	}
+	for _, a := range h.uniqueIDs {
+		dev, err := h.identify(c, a)
+		if err != nil {
+			acc.AddError(fmt.Errorf("identifying device %X failed: %w", a, err))
+			continue
+		}
+		devices = append(devices, dev)
+	}
+	if h.DiscoverSubDevices {
+		subdevices, err := h.discover(c)
+		if err != nil {
+			acc.AddError(fmt.Errorf("discovering sub-devices failed: %w", err))
+		}
+		// Skip sub-devices already configured explicitly
+		for _, sub := range subdevices {
+			if !slices.ContainsFunc(devices, func(dev *device) bool { return dev.id.address == sub.id.address }) {
+				devices = append(devices, sub)
+			}
+		}
+	}
+
+	for _, dev := range devices {
+		fields, err := h.read(c, dev)
+		if err != nil {
+			acc.AddError(fmt.Errorf("reading device %s failed: %w", dev.tags["unique_id"], err))
+		}
+		if len(fields) > 0 {
+			acc.AddFields("hart_ip", fields, dev.tags, time.Now())
+		}
+	}
+
+	return nil
+}
+
+// identify reads the unique identifier and the long tag of the device
+func (h *HARTIP) identify(c *client, address []byte) (*device, error) {
+	resp, err := c.command(address, cmdReadUniqueIdentifier, nil)
+	if err != nil {
+		return nil, err
+	}
+	id, err := decodeIdentity(resp.data)
+	if err != nil {
+		return nil, err
+	}
+
+	tag, found := h.longTags[id.address]
+	if !found && id.universalVersion >= 6 {
+		resp, err := c.command(id.address[:], cmdReadLongTag, nil)
+		if err != nil {
+			h.Log.Debugf("Reading long tag of device %X failed: %v", id.address, err)
+		} else if len(resp.data) >= 32 {
+			tag = trimString(resp.data[:32])
+			h.longTags[id.address] = tag
+		}
+	}
+
+	return h.newDevice(id, tag), nil
+}
+
+// discover returns the sub-devices connected to the I/O system of a
+// gateway or multiplexer at polling address 0
+func (h *HARTIP) discover(c *client) ([]*device, error) {
+	resp, err := c.command([]byte{0}, cmdReadIOSystemCapability, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.data) < 5 {
+		return nil, errors.New("invalid I/O system capabilities")
+	}
+	// The number of devices includes the I/O system itself at index 0
+	count := binary.BigEndian.Uint16(resp.data[3:])
+
+	devices := make([]*device, 0, count)
+	for i := uint16(1); i < count; i++ {
+		resp, err := c.command([]byte{0}, cmdReadSubDeviceIdentities, binary.BigEndian.AppendUint16(nil, i))
+		if err != nil {
+			return devices, fmt.Errorf("reading sub-device %d failed: %w", i, err)
+		}
+		id, card, channel, tag, err := decodeSubDevice(resp.data)
+		if err != nil {
+			return devices, fmt.Errorf("decoding sub-device %d failed: %w", i, err)
+		}
+		dev := h.newDevice(id, tag)
+		dev.tags["card"] = strconv.Itoa(int(card))
+		dev.tags["channel"] = strconv.Itoa(int(channel))
+		devices = append(devices, dev)
+	}
+	return devices, nil
+}
+
+func (h *HARTIP) newDevice(id *identity, tag string) *device {
+	tags := id.tags()
+	tags["address"] = h.host
+	if tag != "" {
+		tags["tag"] = tag
+	}
+	return &device{id: id, tags: tags}
+}
+
+// read collects the fields of the device. Fields read before an error
+// occurred are returned along with the error.
+func (h *HARTIP) read(c *client, dev *device) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	for _, name := range h.Collect {
+		switch name {
+		case "dynamic_variables":
+			resp, err := c.command(dev.id.address[:], cmdReadDynamicVariables, nil)
+			if err != nil {
+				return fields, fmt.Errorf("reading dynamic variables failed: %w", err)
+			}
+			if len(resp.data) < 4 {
+				return fields, errors.New("invalid dynamic variables")
+			}
+			if v, ok := decodeFloat(resp.data); ok {
+				fields["loop_current"] = v
+			}
+			// Devices return only the variables they support
+			for i, pos := 0, 4; i < len(dynamicVariables) && pos+5 <= len(resp.data); i, pos = i+1, pos+5 {
+				v, ok := decodeFloat(resp.data[pos+1:])
+				if !ok {
+					continue
+				}
+				fields[dynamicVariables[i]] = v
+				if u := unit(resp.data[pos]); u != "" {
+					fields[dynamicVariables[i]+"_unit"] = u
+				}
+			}
+			fields["device_status"] = uint64(resp.status)
+		case "additional_status":
+			resp, err := c.command(dev.id.address[:], cmdReadAdditionalStatus, nil)
+			if err != nil {
+				return fields, fmt.Errorf("reading additional status failed: %w", err)
+			}
+			if len(resp.data) < 6 {
+				return fields, errors.New("invalid additional status")
+			}
+			fields["device_specific_status"] = hex.EncodeToString(resp.data[:6])
+			for i, v := range resp.data[6:min(len(resp.data), 6+len(additionalStatus))] {
+				fields[additionalStatus[i]] = uint64(v)
+			}
+			fields["device_status"] = uint64(resp.status)
+		}
+	}
+	return fields, nil
+}
+
+func unit(code byte) string {
+	if symbol, found := units[code]; found {
+		return symbol
+	}
+	return strconv.Itoa(int(code))
+}
+
+func init() {
+	inputs.Add("hart_ip", func() telegraf.Input {
+		return &HARTIP{
+			Timeout: config.Duration(5 * time.Second),
+		}
+	})
+}
@@ -0,0 +1,406 @@
+package hart_ip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *HARTIP
+		expected string
+	}{
+		{
+			name:     "no address",
+			plugin:   &HARTIP{},
+			expected: "'address' must be specified",
+		},
+		{
+			name:     "invalid transport",
+			plugin:   &HARTIP{Address: "127.0.0.1", Transport: "serial"},
+			expected: `invalid 'transport' "serial"`,
+		},
+		{
+			name:     "invalid polling address",
+			plugin:   &HARTIP{Address: "127.0.0.1", PollingAddresses: []uint8{64}},
+			expected: "invalid polling address 64",
+		},
+		{
+			name:     "invalid unique ID",
+			plugin:   &HARTIP{Address: "127.0.0.1", UniqueIDs: []string{"0x2606"}},
+			expected: `invalid unique ID "0x2606"`,
+		},
+		{
+			name:     "invalid collection",
+			plugin:   &HARTIP{Address: "127.0.0.1", Collect: []string{"variables"}},
+			expected: `invalid collection "variables"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Timeout = config.Duration(time.Second)
+			tt.plugin.Log = testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestDecodePDU(t *testing.T) {
+	// Response of a short frame with the checksum being the XOR of all bytes
+	pdu := []byte{0x06, 0x80, 0x03, 0x04, 0x00, 0x40, 0x12, 0x34}
+	pdu = append(pdu, checksum(pdu))
+	resp, err := decodePDU(pdu)
+	require.NoError(t, err)
+	require.Equal(t, &response{command: 3, code: 0, status: 0x40, data: []byte{0x12, 0x34}}, resp)
+
+	pdu[len(pdu)-1] ^= 0xFF
+	_, err = decodePDU(pdu)
+	require.ErrorContains(t, err, "checksum mismatch")
+}
+
+func TestGather(t *testing.T) {
+	for _, transport := range []string{"udp", "tcp"} {
+		t.Run(transport, func(t *testing.T) {
+			gw := newFakeGateway(t, transport)
+
+			plugin := &HARTIP{
+				Address:            gw.addr(),
+				Transport:          transport,
+				DiscoverSubDevices: true,
+				UniqueIDs:          []string{"1A2B000001"},
+				Timeout:            config.Duration(time.Second),
+				Log:                testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, plugin.Gather(&acc))
+			require.Empty(t, acc.Errors)
+
+			expected := []telegraf.Metric{
+				metric.New(
+					"hart_ip",
+					map[string]string{
+						"address":         "127.0.0.1",
+						"unique_id":       "1A2B000001",
+						"manufacturer_id": "0x0026",
+						"device_type":     "0x1A2B",
+						"tag":             "PT-101",
+					},
+					map[string]interface{}{
+						"loop_current":             float64(12),
+						"pv":                       float64(2.5),
+						"pv_unit":                  "bar",
+						"sv":                       float64(21.5),
+						"sv_unit":                  "degC",
+						"device_status":            uint64(0x10),
+						"device_specific_status":   "010000000000",
+						"extended_device_status":   uint64(2),
+						"device_operating_mode":    uint64(0),
+						"standardized_status_0":    uint64(0x20),
+						"standardized_status_1":    uint64(0),
+						"analog_channel_saturated": uint64(0),
+					},
+					time.Unix(0, 0),
+				),
+				metric.New(
+					"hart_ip",
+					map[string]string{
+						"address":         "127.0.0.1",
+						"unique_id":       "1A2B000002",
+						"manufacturer_id": "0x0026",
+						"device_type":     "0x1A2B",
+						"tag":             "TT-102",
+						"card":            "1",
+						"channel":         "3",
+					},
+					map[string]interface{}{
+						"loop_current":           float64(4),
+						"pv":                     float64(-10),
+						"pv_unit":                "degC",
+						"device_status":          uint64(0),
+						"device_specific_status": "000000000000",
+						"extended_device_status": uint64(0),
+					},
+					time.Unix(0, 0),
+				),
+			}
+			testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime(), testutil.SortMetrics())
+			require.Equal(t, 0, gw.sessions())
+
+			// The long tag is cached after the first gather
+			acc.ClearMetrics()
+			require.NoError(t, plugin.Gather(&acc))
+			require.Len(t, acc.GetTelegrafMetrics(), 2)
+			require.Equal(t, 1, gw.longTagRequests())
+		})
+	}
+}
+
+func TestDeviceError(t *testing.T) {
+	gw := newFakeGateway(t, "tcp")
+
+	plugin := &HARTIP{
+		Address:   gw.addr(),
+		Transport: "tcp",
+		UniqueIDs: []string{"1A2B000009"},
+		Timeout:   config.Duration(time.Second),
+		Log:       testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.ErrorContains(t, acc.Errors[0], "identifying device 1A2B000009 failed: communication error 0x84")
+	require.Empty(t, acc.GetTelegrafMetrics())
+}
+
+// fakeDevice is a HART 7 field device behind the fake gateway
+type fakeDevice struct {
+	address [5]byte
+	tag     string
+	current float32
+	units   []byte
+	values  []float32
+	status  byte
+	extra   []byte
+	card    byte
+	channel byte
+}
+
+// fakeGateway is a HART-IP server answering commands for the devices
+type fakeGateway struct {
+	addrFunc func() string
+	devices  []*fakeDevice
+	active   int
+	longTags int
+	sync.Mutex
+}
+
+func newFakeGateway(t *testing.T, transport string) *fakeGateway {
+	g := &fakeGateway{
+		devices: []*fakeDevice{
+			{
+				address: [5]byte{0x1A, 0x2B, 0, 0, 1},
+				tag:     "PT-101",
+				current: 12,
+				units:   []byte{7, 32, 250},
+				values:  []float32{2.5, 21.5, float32(math.NaN())},
+				status:  0x10,
+				extra:   []byte{1, 0, 0, 0, 0, 0, 2, 0, 0x20, 0, 0},
+			},
+			{
+				address: [5]byte{0x1A, 0x2B, 0, 0, 2},
+				tag:     "TT-102",
+				current: 4,
+				units:   []byte{32},
+				values:  []float32{-10},
+				extra:   []byte{0, 0, 0, 0, 0, 0, 0},
+				card:    1,
+				channel: 3,
+			},
+		},
+	}
+
+	if transport == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		g.addrFunc = conn.LocalAddr().String
+		go func() {
+			buf := make([]byte, 1024)
+			for {
+				n, addr, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				// Answer the session initiation from a new port serving the
+				// session as done by real servers
+				session, err := net.ListenPacket("udp", "127.0.0.1:0")
+				if err != nil {
+					return
+				}
+				if err := session.SetDeadline(time.Now().Add(5 * time.Second)); err != nil {
+					return
+				}
+				msg := slices.Clone(buf[:n])
+				go func() {
+					defer session.Close()
+					buf := make([]byte, 1024)
+					for {
+						resp, closed := g.handle(msg)
+						if _, err := session.WriteTo(resp, addr); err != nil || closed {
+							return
+						}
+						n, _, err := session.ReadFrom(buf)
+						if err != nil {
+							return
+						}
+						msg = buf[:n]
+					}
+				}()
+			}
+		}()
+		return g
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	g.addrFunc = listener.Addr().String
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go g.serve(conn)
+		}
+	}()
+	return g
+}
+
+func (g *fakeGateway) addr() string {
+	return g.addrFunc()
+}
+
+func (g *fakeGateway) sessions() int {
+	g.Lock()
+	defer g.Unlock()
+	return g.active
+}
+
+func (g *fakeGateway) longTagRequests() int {
+	g.Lock()
+	defer g.Unlock()
+	return g.longTags
+}
+
+func (g *fakeGateway) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		header := make([]byte, headerLength)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		msg := make([]byte, binary.BigEndian.Uint16(header[6:]))
+		copy(msg, header)
+		if _, err := io.ReadFull(conn, msg[headerLength:]); err != nil {
+			return
+		}
+		resp, closed := g.handle(msg)
+		if _, err := conn.Write(resp); err != nil || closed {
+			return
+		}
+	}
+}
+
+// handle returns the response to the HART-IP message and whether the
+// session was closed
+func (g *fakeGateway) handle(msg []byte) ([]byte, bool) {
+	g.Lock()
+	defer g.Unlock()
+
+	var body []byte
+	switch msg[2] {
+	case msgIDSessionInitiate:
+		g.active++
+		body = msg[headerLength:]
+	case msgIDSessionClose:
+		g.active--
+	case msgIDPassThrough:
+		body = g.command(msg[headerLength:])
+	}
+
+	resp := make([]byte, headerLength, headerLength+len(body))
+	copy(resp, msg[:6])
+	resp[1] = msgTypeResponse
+	binary.BigEndian.PutUint16(resp[6:], uint16(headerLength+len(body)))
+	return append(resp, body...), msg[2] == msgIDSessionClose
+}
+
+// command executes the HART command and returns the response PDU
+func (g *fakeGateway) command(pdu []byte) []byte {
+	var addr []byte
+	var pos int
+	if pdu[0]&delimiterLongBit != 0 {
+		addr, pos = pdu[1:6], 6
+	} else {
+		addr, pos = pdu[1:2], 2
+	}
+	cmd, data := pdu[pos], pdu[pos+2:pos+2+int(pdu[pos+1])]
+
+	// The gateway itself is identified by polling address 0
+	var dev *fakeDevice
+	for _, d := range g.devices {
+		if len(addr) == 5 && bytes.Equal(addr[1:], d.address[1:]) && addr[0]&0x3F == d.address[0] {
+			dev = d
+		}
+	}
+
+	code, status := byte(0), byte(0)
+	var payload []byte
+	switch {
+	case len(addr) == 1 && cmd == cmdReadIOSystemCapability:
+		payload = []byte{4, 8, 1, 0, byte(len(g.devices) + 1), 1, 1, 3}
+	case len(addr) == 1 && cmd == cmdReadSubDeviceIdentities:
+		index := int(binary.BigEndian.Uint16(data))
+		d := g.devices[index-1]
+		payload = binary.BigEndian.AppendUint16(nil, uint16(index))
+		payload = append(payload, d.card, d.channel, 0x00, 0x26, d.address[0], d.address[1])
+		payload = append(payload, d.address[2:]...)
+		payload = append(payload, 7)
+		payload = append(payload, padTag(d.tag)...)
+	case dev == nil:
+		// No response from the device
+		code = 0x84
+	case cmd == cmdReadUniqueIdentifier:
+		payload = []byte{254, dev.address[0], dev.address[1], 5, 7, 1, 1, 0x08, 0, dev.address[2], dev.address[3], dev.address[4], 5, 4, 0, 1, 0, 0x00, 0x26, 0, 0, 0}
+		status = dev.status
+	case cmd == cmdReadLongTag:
+		g.longTags++
+		payload = padTag(dev.tag)
+		status = dev.status
+	case cmd == cmdReadDynamicVariables:
+		payload = binary.BigEndian.AppendUint32(nil, math.Float32bits(dev.current))
+		for i, v := range dev.values {
+			payload = append(payload, dev.units[i])
+			payload = binary.BigEndian.AppendUint32(payload, math.Float32bits(v))
+		}
+		status = dev.status
+	case cmd == cmdReadAdditionalStatus:
+		payload = dev.extra
+		status = dev.status
+	default:
+		code = 64
+	}
+
+	resp := []byte{delimiterACK | pdu[0]&delimiterLongBit}
+	resp = append(resp, addr...)
+	resp = append(resp, cmd, byte(2+len(payload)), code, status)
+	resp = append(resp, payload...)
+	return append(resp, checksum(resp))
+}
+
+func padTag(tag string) []byte {
+	buf := bytes.Repeat([]byte{' '}, 32)
+	copy(buf, tag)
+	return buf
+}
@@ -0,0 +1,141 @@
+package iec61850
+
+import (
+	"errors"
+	"fmt"
+)
+
+// element is a BER encoded element with single-byte tag
+type element struct {
+	tag     byte
+	content []byte
+}
+
+func (e element) constructed() bool {
+	return e.tag&0x20 != 0
+}
+
+// children parses the content of a constructed element
+func (e element) children() ([]element, error) {
+	if !e.constructed() {
+		return nil, fmt.Errorf("element 0x%02x is not constructed", e.tag)
+	}
+	return parseBER(e.content)
+}
+
+// tlv encodes the content with the given tag
+func tlv(tag byte, content ...[]byte) []byte {
+	var length int
+	for _, c := range content {
+		length += len(c)
+	}
+
+	buf := make([]byte, 0, length+6)
+	buf = append(buf, tag)
+	switch {
+	case length < 0x80:
+		buf = append(buf, byte(length))
+	case length < 0x100:
+		buf = append(buf, 0x81, byte(length))
+	case length < 0x10000:
+		buf = append(buf, 0x82, byte(length>>8), byte(length))
+	default:
+		buf = append(buf, 0x83, byte(length>>16), byte(length>>8), byte(length))
+	}
+	for _, c := range content {
+		buf = append(buf, c...)
+	}
+	return buf
+}
+
+// berUnsigned encodes the value as minimal two's complement integer content
+func berUnsigned(v uint64) []byte {
+	buf := []byte{byte(v)}
+	for v >>= 8; v > 0; v >>= 8 {
+		buf = append([]byte{byte(v)}, buf...)
+	}
+	if buf[0]&0x80 != 0 {
+		buf = append([]byte{0}, buf...)
+	}
+	return buf
+}
+
+func decodeBERUnsigned(buf []byte) (uint64, error) {
+	if len(buf) == 0 || len(buf) > 9 || (len(buf) == 9 && buf[0] != 0) {
+		return 0, fmt.Errorf("invalid unsigned length %d", len(buf))
+	}
+	var v uint64
+	for _, b := range buf {
+		v = v<<8 | uint64(b)
+	}
+	return v, nil
+}
+
+func decodeBERSigned(buf []byte) (int64, error) {
+	if len(buf) == 0 || len(buf) > 8 {
+		return 0, fmt.Errorf("invalid integer length %d", len(buf))
+	}
+	v := int64(int8(buf[0]))
+	for _, b := range buf[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+// parseBER splits the buffer into its BER elements
+func parseBER(buf []byte) ([]element, error) {
+	var elements []element
+	for len(buf) > 0 {
+		if len(buf) < 2 {
+			return nil, errors.New("truncated element")
+		}
+		tag := buf[0]
+		if tag&0x1F == 0x1F {
+			return nil, fmt.Errorf("unsupported multi-byte tag 0x%02x", tag)
+		}
+
+		length := int(buf[1])
+		pos := 2
+		if length&0x80 != 0 {
+			n := length & 0x7F
+			if n == 0 || n > 3 || len(buf) < pos+n {
+				return nil, errors.New("invalid element length")
+			}
+			length = 0
+			for _, b := range buf[pos : pos+n] {
+				length = length<<8 | int(b)
+			}
+			pos += n
+		}
+		if len(buf) < pos+length {
+			return nil, fmt.Errorf("element 0x%02x exceeds data", tag)
+		}
+		elements = append(elements, element{tag: tag, content: buf[pos : pos+length]})
+		buf = buf[pos+length:]
+	}
+	return elements, nil
+}
+
+// find follows the given path of tags starting at the elements in the
+// buffer and returns the last element of the path
+func find(buf []byte, path ...byte) (element, error) {
+	var current element
+	for i, tag := range path {
+		elements, err := parseBER(buf)
+		if err != nil {
+			return element{}, err
+		}
+		found := false
+		for _, e := range elements {
+			if e.tag == tag {
+				current, found = e, true
+				break
+			}
+		}
+		if !found {
+			return element{}, fmt.Errorf("element 0x%02x not found at level %d", tag, i)
+		}
+		buf = current.content
+	}
+	return current, nil
+}
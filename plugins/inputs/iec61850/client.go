@@ -0,0 +1,206 @@
+package iec61850
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// client sends MMS requests over an association and dispatches the received
+// responses and information reports
+type client struct {
+	conn     *isoConn
+	timeout  time.Duration
+	log      telegraf.Logger
+	onReport func([]accessResult)
+
+	invokeID uint32
+	pending  map[uint32]chan element
+	err      error
+	done     chan struct{}
+	wg       sync.WaitGroup
+	sync.Mutex
+}
+
+func connect(address string, timeout time.Duration, log telegraf.Logger, onReport func([]accessResult)) (*client, error) {
+	conn, resp, err := dialISO(address, timeout, encodeInitiateRequest())
+	if err != nil {
+		return nil, err
+	}
+	switch resp.tag {
+	case pduInitiateResponse:
+	case pduInitiateError:
+		conn.close()
+		return nil, errors.New("initiate rejected")
+	default:
+		conn.close()
+		return nil, fmt.Errorf("unexpected initiate response 0x%02x", resp.tag)
+	}
+
+	c := &client{
+		conn:     conn,
+		timeout:  timeout,
+		log:      log,
+		onReport: onReport,
+		pending:  make(map[uint32]chan element),
+		done:     make(chan struct{}),
+	}
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		c.receive()
+	}()
+	return c, nil
+}
+
+func (c *client) close() {
+	c.conn.close()
+	c.wg.Wait()
+}
+
+// closed returns the error terminating the association if any
+func (c *client) closed() error {
+	c.Lock()
+	defer c.Unlock()
+	return c.err
+}
+
+func (c *client) receive() {
+	defer close(c.done)
+	for {
+		pdu, err := c.conn.receive()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				err = errors.New("connection closed")
+			}
+			c.Lock()
+			c.err = err
+			c.Unlock()
+			return
+		}
+
+		switch pdu.tag {
+		case pduUnconfirmed:
+			results, isReport, err := decodeInformationReport(pdu)
+			if err != nil {
+				c.log.Errorf("Decoding information report failed: %v", err)
+				continue
+			}
+			if isReport {
+				c.onReport(results)
+			}
+		case pduConfirmedResponse, pduConfirmedError, pduReject:
+			id, _, err := decodeConfirmedResponse(pdu)
+			if id == 0 {
+				c.log.Debugf("Ignoring invalid response: %v", err)
+				continue
+			}
+			c.Lock()
+			ch, found := c.pending[id]
+			c.Unlock()
+			if !found {
+				c.log.Debugf("Ignoring unexpected response with invoke ID %d", id)
+				continue
+			}
+			select {
+			case ch <- pdu:
+			default:
+			}
+		default:
+			c.log.Debugf("Ignoring unexpected PDU 0x%02x", pdu.tag)
+		}
+	}
+}
+
+// request sends the confirmed service request and returns the service
+// response
+func (c *client) request(service []byte) (element, error) {
+	ch := make(chan element, 1)
+	c.Lock()
+	if c.err != nil {
+		c.Unlock()
+		return element{}, c.err
+	}
+	c.invokeID++
+	invokeID := c.invokeID
+	c.pending[invokeID] = ch
+	c.Unlock()
+
+	defer func() {
+		c.Lock()
+		delete(c.pending, invokeID)
+		c.Unlock()
+	}()
+
+	if err := c.conn.send(encodeConfirmedRequest(invokeID, service)); err != nil {
+		return element{}, err
+	}
+
+	select {
+	case pdu := <-ch:
+		_, resp, err := decodeConfirmedResponse(pdu)
+		return resp, err
+	case <-c.done:
+		return element{}, c.closed()
+	case <-time.After(c.timeout):
+		return element{}, errors.New("request timed out")
+	}
+}
+
+func (c *client) read(names ...objectName) ([]accessResult, error) {
+	resp, err := c.request(encodeRead(names...))
+	if err != nil {
+		return nil, err
+	}
+	results, err := decodeReadResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) != len(names) {
+		return nil, fmt.Errorf("received %d results for %d variables", len(results), len(names))
+	}
+	return results, nil
+}
+
+func (c *client) readDataset(dataset objectName, members int) ([]accessResult, error) {
+	resp, err := c.request(encodeReadList(dataset))
+	if err != nil {
+		return nil, err
+	}
+	results, err := decodeReadResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) != members {
+		return nil, fmt.Errorf("received %d results for %d members", len(results), members)
+	}
+	return results, nil
+}
+
+func (c *client) write(name objectName, data []byte) error {
+	resp, err := c.request(encodeWrite(name, data))
+	if err != nil {
+		return err
+	}
+	return decodeWriteResponse(resp)
+}
+
+func (c *client) variableType(name objectName) (*typeSpec, error) {
+	resp, err := c.request(encodeGetVariableAccessAttributes(name))
+	if err != nil {
+		return nil, err
+	}
+	return decodeVariableAccessAttributes(resp)
+}
+
+func (c *client) datasetMembers(dataset objectName) ([]objectName, error) {
+	resp, err := c.request(encodeGetNamedVariableListAttributes(dataset))
+	if err != nil {
+		return nil, err
+	}
+	return decodeNamedVariableListAttributes(resp)
+}
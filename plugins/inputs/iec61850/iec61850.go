@@ -0,0 +1,671 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package iec61850
+
+import (
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Bits of the trigger options of report control blocks
+var triggerOptions = map[string]int{
+	"data-change":           1,
+	"quality-change":        2,
+	"data-update":           3,
+	"integrity":             4,
+	"general-interrogation": 5,
+}
+
+// Bits of the optional fields of reports
+const (
+	optSequenceNumber = 1
+	optTimestamp      = 2
+	optReason         = 3
+	optDataset        = 4
+	optDataReference  = 5
+	optBufferOverflow = 6
+	optEntryID        = 7
+	optConfRevision   = 8
+	optSegmentation   = 9
+)
+
+// Validity of the quality attribute
+var validities = []string{"good", "invalid", "reserved", "questionable"}
+
+type IEC61850 struct {
+	Address     string                `toml:"address"`
+	Timeout     config.Duration       `toml:"timeout"`
+	Measurement string                `toml:"measurement"`
+	Timestamp   string                `toml:"timestamp"`
+	Attributes  []attributeDefinition `toml:"attributes"`
+	Datasets    []string              `toml:"datasets"`
+	Reports     []reportDefinition    `toml:"report"`
+	Log         telegraf.Logger       `toml:"-"`
+
+	acc        telegraf.Accumulator
+	client     *client
+	attributes []*variable
+	datasets   []*dataset
+	reports    []*report
+	sync.Mutex
+}
+
+type attributeDefinition struct {
+	Reference string `toml:"reference"`
+	FC        string `toml:"fc"`
+}
+
+type reportDefinition struct {
+	Reference            string          `toml:"reference"`
+	TriggerOptions       []string        `toml:"trigger_options"`
+	IntegrityPeriod      config.Duration `toml:"integrity_period"`
+	GeneralInterrogation bool            `toml:"general_interrogation"`
+}
+
+// variable is a functionally constrained data object or data attribute
+type variable struct {
+	name objectName
+	ln   string
+	fc   string
+	do   string
+	path string
+	typ  *typeSpec
+}
+
+type dataset struct {
+	name    objectName
+	members []*variable
+}
+
+// report is the runtime state of a report control block. The report ID,
+// members and entry ID are guarded by the plugin's lock as they are used by
+// the received reports.
+type report struct {
+	name            objectName
+	buffered        bool
+	triggers        bitString
+	integrityPeriod time.Duration
+	gi              bool
+
+	rptID   string
+	members []*variable
+	entryID []byte
+}
+
+func (*IEC61850) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *IEC61850) Init() error {
+	if p.Address == "" {
+		return errors.New("'address' must be specified")
+	}
+	var nerr *net.AddrError
+	if _, _, err := net.SplitHostPort(p.Address); errors.As(err, &nerr) && strings.Contains(nerr.Err, "missing port") {
+		p.Address += ":102"
+	}
+	if p.Timeout <= 0 {
+		return errors.New("'timeout' must be positive")
+	}
+	if p.Measurement == "" {
+		p.Measurement = "iec61850"
+	}
+	switch p.Timestamp {
+	case "":
+		p.Timestamp = "gather"
+	case "gather", "source":
+	default:
+		return fmt.Errorf("invalid 'timestamp' %q", p.Timestamp)
+	}
+	if len(p.Attributes) == 0 && len(p.Datasets) == 0 && len(p.Reports) == 0 {
+		return errors.New("no attributes, datasets or reports defined")
+	}
+
+	p.attributes = make([]*variable, 0, len(p.Attributes))
+	for _, def := range p.Attributes {
+		name, err := parseAttributeReference(def.Reference, def.FC)
+		if err != nil {
+			return err
+		}
+		v, err := newVariable(name)
+		if err != nil {
+			return err
+		}
+		p.attributes = append(p.attributes, v)
+	}
+
+	p.datasets = make([]*dataset, 0, len(p.Datasets))
+	for _, ref := range p.Datasets {
+		name, err := parseReference(ref)
+		if err != nil {
+			return fmt.Errorf("invalid dataset: %w", err)
+		}
+		p.datasets = append(p.datasets, &dataset{name: name})
+	}
+
+	p.reports = make([]*report, 0, len(p.Reports))
+	for _, def := range p.Reports {
+		r, err := newReport(def)
+		if err != nil {
+			return fmt.Errorf("report %q: %w", def.Reference, err)
+		}
+		p.reports = append(p.reports, r)
+	}
+
+	return nil
+}
+
+func newReport(def reportDefinition) (*report, error) {
+	name, err := parseReference(def.Reference)
+	if err != nil {
+		return nil, err
+	}
+	parts := strings.Split(name.item, "$")
+	if len(parts) != 3 || (parts[1] != "BR" && parts[1] != "RP") {
+		return nil, errors.New("reference must be in '<LD>/<LN>.<BR|RP>.<name>' format")
+	}
+	if def.IntegrityPeriod < 0 {
+		return nil, errors.New("'integrity_period' must not be negative")
+	}
+
+	triggers := make([]bool, 6)
+	options := def.TriggerOptions
+	if len(options) == 0 {
+		options = []string{"data-change", "quality-change", "general-interrogation"}
+	}
+	for _, o := range options {
+		bit, found := triggerOptions[o]
+		if !found {
+			return nil, fmt.Errorf("invalid trigger option %q", o)
+		}
+		triggers[bit] = true
+	}
+	if def.IntegrityPeriod > 0 {
+		triggers[triggerOptions["integrity"]] = true
+	}
+
+	return &report{
+		name:            name,
+		buffered:        parts[1] == "BR",
+		triggers:        newBitString(triggers...),
+		integrityPeriod: time.Duration(def.IntegrityPeriod),
+		gi:              def.GeneralInterrogation,
+	}, nil
+}
+
+func (p *IEC61850) Start(acc telegraf.Accumulator) error {
+	p.acc = acc
+	return p.connect()
+}
+
+func (p *IEC61850) Gather(acc telegraf.Accumulator) error {
+	if p.client == nil || p.client.closed() != nil {
+		if p.client != nil {
+			p.Log.Warnf("Association closed: %v", p.client.closed())
+			p.client.close()
+			p.client = nil
+		}
+		if err := p.connect(); err != nil {
+			return err
+		}
+	}
+
+	if len(p.attributes) > 0 {
+		names := make([]objectName, 0, len(p.attributes))
+		for _, v := range p.attributes {
+			names = append(names, v.name)
+		}
+		results, err := p.client.read(names...)
+		if err != nil {
+			acc.AddError(fmt.Errorf("reading attributes failed: %w", err))
+		} else {
+			p.addValues(acc, p.attributes, results, time.Now())
+		}
+	}
+
+	for _, ds := range p.datasets {
+		results, err := p.client.readDataset(ds.name, len(ds.members))
+		if err != nil {
+			acc.AddError(fmt.Errorf("reading dataset %s failed: %w", ds.name, err))
+			continue
+		}
+		p.addValues(acc, ds.members, results, time.Now())
+	}
+
+	return nil
+}
+
+func (p *IEC61850) Stop() {
+	if p.client == nil {
+		return
+	}
+
+	// Disable the reports to release the control blocks
+	for _, r := range p.reports {
+		if err := p.client.write(r.attribute("RptEna"), encodeBooleanData(false)); err != nil {
+			p.Log.Debugf("Disabling report %s failed: %v", r.name, err)
+		}
+	}
+	p.client.close()
+	p.client = nil
+}
+
+// connect establishes the association and resolves the types of the
+// variables before enabling the reports
+func (p *IEC61850) connect() error {
+	c, err := connect(p.Address, time.Duration(p.Timeout), p.Log, p.onReport)
+	if err != nil {
+		return fmt.Errorf("connecting to %s failed: %w", p.Address, err)
+	}
+	p.Log.Debugf("Connected to %s", p.Address)
+
+	types := make(map[objectName]*typeSpec)
+	for _, v := range p.attributes {
+		if err := p.resolve(c, v, types); err != nil {
+			c.close()
+			return err
+		}
+	}
+	for _, ds := range p.datasets {
+		members, err := p.members(c, ds.name, types)
+		if err != nil {
+			c.close()
+			return fmt.Errorf("dataset %s: %w", ds.name, err)
+		}
+		ds.members = members
+	}
+	for _, r := range p.reports {
+		if err := p.enable(c, r, types); err != nil {
+			c.close()
+			return fmt.Errorf("enabling report %s failed: %w", r.name, err)
+		}
+	}
+
+	p.client = c
+	return nil
+}
+
+func (p *IEC61850) resolve(c *client, v *variable, types map[objectName]*typeSpec) error {
+	if typ, found := types[v.name]; found {
+		v.typ = typ
+		return nil
+	}
+	typ, err := c.variableType(v.name)
+	if err != nil {
+		return fmt.Errorf("reading type of %s failed: %w", v.name, err)
+	}
+	types[v.name] = typ
+	v.typ = typ
+	return nil
+}
+
+func (p *IEC61850) members(c *client, name objectName, types map[objectName]*typeSpec) ([]*variable, error) {
+	names, err := c.datasetMembers(name)
+	if err != nil {
+		return nil, fmt.Errorf("reading members failed: %w", err)
+	}
+	members := make([]*variable, 0, len(names))
+	for _, n := range names {
+		v, err := newVariable(n)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.resolve(c, v, types); err != nil {
+			return nil, err
+		}
+		members = append(members, v)
+	}
+	return members, nil
+}
+
+// enable configures and enables the report control block. Buffered reports
+// resume at the last received entry after reconnecting.
+func (p *IEC61850) enable(c *client, r *report, types map[objectName]*typeSpec) error {
+	results, err := c.read(r.attribute("RptID"), r.attribute("DatSet"))
+	if err != nil {
+		return err
+	}
+	for _, res := range results {
+		if res.err != nil {
+			return res.err
+		}
+	}
+	rptID, ok := results[0].value.(string)
+	if !ok {
+		return fmt.Errorf("invalid report ID %v", results[0].value)
+	}
+	if rptID == "" {
+		rptID = r.name.String()
+	}
+	dsRef, ok := results[1].value.(string)
+	if !ok || dsRef == "" {
+		return errors.New("no dataset assigned")
+	}
+	dsName, err := parseObjectName(dsRef)
+	if err != nil {
+		return err
+	}
+	members, err := p.members(c, dsName, types)
+	if err != nil {
+		return fmt.Errorf("dataset %s: %w", dsName, err)
+	}
+
+	p.Lock()
+	r.rptID = rptID
+	r.members = members
+	entryID := r.entryID
+	p.Unlock()
+
+	// Disable the control block to allow changing its configuration
+	if err := c.write(r.attribute("RptEna"), encodeBooleanData(false)); err != nil {
+		return fmt.Errorf("disabling failed: %w", err)
+	}
+	if !r.buffered {
+		if err := c.write(r.attribute("Resv"), encodeBooleanData(true)); err != nil {
+			return fmt.Errorf("reserving failed: %w", err)
+		}
+	}
+
+	optional := make([]bool, 10)
+	optional[optSequenceNumber] = true
+	optional[optTimestamp] = true
+	optional[optDataset] = true
+	optional[optConfRevision] = true
+	if r.buffered {
+		optional[optBufferOverflow] = true
+		optional[optEntryID] = true
+	}
+	if err := c.write(r.attribute("OptFlds"), encodeBitStringData(newBitString(optional...))); err != nil {
+		return fmt.Errorf("setting optional fields failed: %w", err)
+	}
+	if err := c.write(r.attribute("TrgOps"), encodeBitStringData(r.triggers)); err != nil {
+		return fmt.Errorf("setting trigger options failed: %w", err)
+	}
+	if r.integrityPeriod > 0 {
+		if err := c.write(r.attribute("IntgPd"), encodeUnsignedData(uint64(r.integrityPeriod.Milliseconds()))); err != nil {
+			return fmt.Errorf("setting integrity period failed: %w", err)
+		}
+	}
+	if r.buffered && entryID != nil {
+		if err := c.write(r.attribute("EntryID"), encodeOctetStringData(entryID)); err != nil {
+			p.Log.Warnf("Resuming report %s at entry %x failed: %v", r.name, entryID, err)
+		}
+	}
+	if err := c.write(r.attribute("RptEna"), encodeBooleanData(true)); err != nil {
+		return err
+	}
+	if r.gi {
+		if err := c.write(r.attribute("GI"), encodeBooleanData(true)); err != nil {
+			return fmt.Errorf("triggering general interrogation failed: %w", err)
+		}
+	}
+	p.Log.Debugf("Enabled report %s with ID %q for dataset %s", r.name, rptID, dsName)
+
+	return nil
+}
+
+// onReport adds the values of a received report
+func (p *IEC61850) onReport(results []accessResult) {
+	timestamp := time.Now()
+
+	values := make([]interface{}, 0, len(results))
+	for _, res := range results {
+		if res.err != nil {
+			p.Log.Errorf("Received report with invalid value: %v", res.err)
+			return
+		}
+		values = append(values, res.value)
+	}
+	if len(values) < 2 {
+		p.Log.Error("Received truncated report")
+		return
+	}
+	rptID, ok1 := values[0].(string)
+	optional, ok2 := values[1].(bitString)
+	if !ok1 || !ok2 {
+		p.Log.Error("Received report with invalid header")
+		return
+	}
+
+	p.Lock()
+	var r *report
+	for _, candidate := range p.reports {
+		if candidate.rptID == rptID {
+			r = candidate
+			break
+		}
+	}
+	var members []*variable
+	if r != nil {
+		members = r.members
+	}
+	p.Unlock()
+	if r == nil {
+		p.Log.Debugf("Ignoring report with unknown ID %q", rptID)
+		return
+	}
+
+	// Skip the optional header fields
+	pos := 2
+	var entryID []byte
+	for bit := optSequenceNumber; bit <= optSegmentation; bit++ {
+		if !optional.bit(bit) {
+			continue
+		}
+		switch bit {
+		case optReason, optDataReference:
+			continue
+		case optBufferOverflow:
+			if pos < len(values) && values[pos] == true {
+				p.Log.Warnf("Buffer overflow for report %s, reports were lost", r.name)
+			}
+		case optEntryID:
+			if pos < len(values) {
+				if s, ok := values[pos].(string); ok {
+					entryID, _ = hex.DecodeString(s)
+				}
+			}
+		case optSegmentation:
+			pos++
+		}
+		pos++
+	}
+	if pos >= len(values) {
+		p.Log.Errorf("Received truncated report %q", rptID)
+		return
+	}
+	inclusion, ok := values[pos].(bitString)
+	if !ok || inclusion.length != len(members) {
+		p.Log.Errorf("Report %q does not match the members of the dataset", rptID)
+		return
+	}
+	pos++
+
+	included := make([]*variable, 0, len(members))
+	for i, m := range members {
+		if inclusion.bit(i) {
+			included = append(included, m)
+		}
+	}
+	if optional.bit(optDataReference) {
+		pos += len(included)
+	}
+	if len(values) < pos+len(included) {
+		p.Log.Errorf("Received truncated report %q", rptID)
+		return
+	}
+	p.addValues(p.acc, included, results[pos:pos+len(included)], timestamp)
+
+	if entryID != nil {
+		p.Lock()
+		r.entryID = entryID
+		p.Unlock()
+	}
+}
+
+// addValues adds a metric per data object of the given variables
+func (p *IEC61850) addValues(acc telegraf.Accumulator, variables []*variable, results []accessResult, timestamp time.Time) {
+	type group struct {
+		tags   map[string]string
+		fields map[string]interface{}
+		source time.Time
+	}
+	var order []string
+	groups := make(map[string]*group)
+	for i, v := range variables {
+		if results[i].err != nil {
+			acc.AddError(fmt.Errorf("reading %s failed: %w", v.name, results[i].err))
+			continue
+		}
+
+		key := v.name.domain + "/" + v.ln + "$" + v.fc + "$" + v.do
+		g, found := groups[key]
+		if !found {
+			g = &group{
+				tags: map[string]string{
+					"address":        p.Address,
+					"logical_device": v.name.domain,
+					"logical_node":   v.ln,
+					"fc":             v.fc,
+					"data_object":    v.do,
+				},
+				fields: make(map[string]interface{}),
+			}
+			groups[key] = g
+			order = append(order, key)
+		}
+		flatten(g.fields, &g.source, v.path, v.typ, results[i].value)
+	}
+
+	for _, key := range order {
+		g := groups[key]
+		if len(g.fields) == 0 {
+			continue
+		}
+		t := timestamp
+		if p.Timestamp == "source" && !g.source.IsZero() {
+			t = g.source
+		}
+		acc.AddFields(p.Measurement, g.fields, g.tags, t)
+	}
+}
+
+// flatten adds the value as fields named by the path of the attribute
+// relative to the data object. Quality attributes additionally produce
+// a validity field and the timestamp attribute of the data object is
+// returned as source time.
+func flatten(fields map[string]interface{}, source *time.Time, path string, typ *typeSpec, value interface{}) {
+	switch v := value.(type) {
+	case []interface{}:
+		for i, item := range v {
+			var itemType *typeSpec
+			name := strconv.Itoa(i)
+			switch {
+			case typ != nil && typ.tag == typeStructure && len(typ.components) == len(v):
+				name, itemType = typ.components[i].name, typ.components[i].typ
+			case typ != nil && typ.tag == typeArray:
+				itemType = typ.element
+			}
+			if path != "" {
+				name = path + "." + name
+			}
+			flatten(fields, source, name, itemType, item)
+		}
+	case bitString:
+		if path == "" {
+			path = "value"
+		}
+		fields[path] = v.uint64()
+		if (path == "q" || strings.HasSuffix(path, ".q")) && v.length == 13 {
+			validity := 0
+			if v.bit(0) {
+				validity |= 2
+			}
+			if v.bit(1) {
+				validity |= 1
+			}
+			fields[path+"_validity"] = validities[validity]
+		}
+	case time.Time:
+		if path == "t" {
+			*source = v
+		}
+	case time.Duration:
+	default:
+		if path == "" {
+			path = "value"
+		}
+		fields[path] = v
+	}
+}
+
+func (r *report) attribute(name string) objectName {
+	return objectName{domain: r.name.domain, item: r.name.item + "$" + name}
+}
+
+// newVariable splits the MMS item of a variable into its logical node,
+// functional constraint, data object and attribute path
+func newVariable(name objectName) (*variable, error) {
+	parts := strings.Split(name.item, "$")
+	if len(parts) < 3 {
+		return nil, fmt.Errorf("variable %s is not a functionally constrained data object", name)
+	}
+	return &variable{
+		name: name,
+		ln:   parts[0],
+		fc:   parts[1],
+		do:   parts[2],
+		path: strings.Join(parts[3:], "."),
+	}, nil
+}
+
+// parseReference converts an IEC 61850 object reference in
+// "<LD>/<LN>.<name>[.<name>]" format to the MMS object name
+func parseReference(ref string) (objectName, error) {
+	name, err := parseObjectName(ref)
+	if err != nil {
+		return objectName{}, err
+	}
+	name.item = strings.ReplaceAll(name.item, ".", "$")
+	if !strings.Contains(name.item, "$") {
+		return objectName{}, fmt.Errorf("invalid object reference %q", ref)
+	}
+	return name, nil
+}
+
+// parseAttributeReference converts a reference in "<LD>/<LN>.<DO>[.<DA>]"
+// format with the given functional constraint to the MMS object name
+func parseAttributeReference(ref, fc string) (objectName, error) {
+	if len(fc) != 2 || strings.ToUpper(fc) != fc {
+		return objectName{}, fmt.Errorf("invalid functional constraint %q for %q", fc, ref)
+	}
+	name, err := parseReference(ref)
+	if err != nil {
+		return objectName{}, err
+	}
+	ln, rest, _ := strings.Cut(name.item, "$")
+	name.item = ln + "$" + fc + "$" + rest
+	return name, nil
+}
+
+func init() {
+	inputs.Add("iec61850", func() telegraf.Input {
+		return &IEC61850{
+			Timeout: config.Duration(10 * time.Second),
+		}
+	})
+}
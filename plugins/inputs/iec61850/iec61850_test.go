@@ -0,0 +1,535 @@
+package iec61850
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *IEC61850
+		expected string
+	}{
+		{
+			name:     "no address",
+			plugin:   &IEC61850{},
+			expected: "'address' must be specified",
+		},
+		{
+			name:     "nothing to read",
+			plugin:   &IEC61850{Address: "127.0.0.1"},
+			expected: "no attributes, datasets or reports defined",
+		},
+		{
+			name:     "invalid timestamp",
+			plugin:   &IEC61850{Address: "127.0.0.1", Timestamp: "server"},
+			expected: "invalid 'timestamp'",
+		},
+		{
+			name: "invalid attribute reference",
+			plugin: &IEC61850{
+				Address:    "127.0.0.1",
+				Attributes: []attributeDefinition{{Reference: "MMXU1.TotW", FC: "MX"}},
+			},
+			expected: `invalid object reference "MMXU1.TotW"`,
+		},
+		{
+			name: "invalid functional constraint",
+			plugin: &IEC61850{
+				Address:    "127.0.0.1",
+				Attributes: []attributeDefinition{{Reference: "LD0/MMXU1.TotW", FC: "mx"}},
+			},
+			expected: `invalid functional constraint "mx"`,
+		},
+		{
+			name: "invalid report reference",
+			plugin: &IEC61850{
+				Address: "127.0.0.1",
+				Reports: []reportDefinition{{Reference: "LD0/LLN0.brcb01"}},
+			},
+			expected: "reference must be in",
+		},
+		{
+			name: "invalid trigger option",
+			plugin: &IEC61850{
+				Address: "127.0.0.1",
+				Reports: []reportDefinition{{Reference: "LD0/LLN0.RP.urcb01", TriggerOptions: []string{"change"}}},
+			},
+			expected: `invalid trigger option "change"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Timeout = config.Duration(time.Second)
+			tt.plugin.Log = testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestParseAttributeReference(t *testing.T) {
+	name, err := parseAttributeReference("IED1LD0/MMXU1.PhV.phsA.cVal", "MX")
+	require.NoError(t, err)
+	require.Equal(t, objectName{domain: "IED1LD0", item: "MMXU1$MX$PhV$phsA$cVal"}, name)
+
+	v, err := newVariable(name)
+	require.NoError(t, err)
+	require.Equal(t, "MMXU1", v.ln)
+	require.Equal(t, "MX", v.fc)
+	require.Equal(t, "PhV", v.do)
+	require.Equal(t, "phsA.cVal", v.path)
+}
+
+func TestDecodeData(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected interface{}
+	}{
+		{"boolean", []byte{0x83, 0x01, 0xFF}, true},
+		{"bit-string", []byte{0x84, 0x03, 0x03, 0x40, 0x00}, bitString{length: 13, data: []byte{0x40, 0x00}}},
+		{"integer", []byte{0x85, 0x01, 0xFE}, int64(-2)},
+		{"unsigned", []byte{0x86, 0x02, 0x01, 0x00}, uint64(256)},
+		{"float", []byte{0x87, 0x05, 0x08, 0x41, 0xAC, 0x00, 0x00}, float64(21.5)},
+		{"double", []byte{0x87, 0x09, 0x0B, 0x40, 0x09, 0x21, 0xFB, 0x54, 0x44, 0x2D, 0x18}, math.Pi},
+		{"octet-string", []byte{0x89, 0x02, 0xCA, 0xFE}, "cafe"},
+		{"visible-string", []byte{0x8A, 0x03, 'f', 'o', 'o'}, "foo"},
+		{"utc-time", []byte{0x91, 0x08, 0x65, 0x53, 0xF1, 0x00, 0x80, 0x00, 0x00, 0x0A}, time.Unix(1700000000, 500000000).UTC()},
+		{"structure", []byte{0xA2, 0x06, 0x83, 0x01, 0x00, 0x86, 0x01, 0x07}, []interface{}{false, uint64(7)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			elements, err := parseBER(tt.data)
+			require.NoError(t, err)
+			require.Len(t, elements, 1)
+			v, err := decodeData(elements[0])
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, v)
+		})
+	}
+}
+
+func TestPolling(t *testing.T) {
+	server := newFakeServer(t)
+	server.set("LD0", "MMXU1$MX$TotW", analogueType(), analogue(1520.5, quality(false), time.Unix(1700000000, 0)))
+	server.set("LD0", "MMXU1$MX$Hz", analogueType(), analogue(49.98, quality(true), time.Unix(1700000001, 0)))
+	server.set("LD0", "XCBR1$ST$Pos$stVal", typeOf(0x84, 0x02), tlv(0x84, []byte{0x06, 0x40}))
+	server.set("LD0", "XCBR1$ST$Pos$q", typeOf(0x84, 0x0D), quality(false))
+	server.datasets["LD0/LLN0$Position"] = []objectName{
+		{domain: "LD0", item: "XCBR1$ST$Pos$stVal"},
+		{domain: "LD0", item: "XCBR1$ST$Pos$q"},
+	}
+
+	plugin := &IEC61850{
+		Address:   server.addr(),
+		Timeout:   config.Duration(time.Second),
+		Timestamp: "source",
+		Attributes: []attributeDefinition{
+			{Reference: "LD0/MMXU1.TotW", FC: "MX"},
+			{Reference: "LD0/MMXU1.Hz", FC: "MX"},
+			{Reference: "LD0/MMXU1.PF", FC: "MX"},
+		},
+		Datasets: []string{"LD0/LLN0.Position"},
+		Log:      testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// The type of a missing variable cannot be resolved
+	var acc testutil.Accumulator
+	require.ErrorContains(t, plugin.Start(&acc), "reading type of LD0/MMXU1$MX$PF failed")
+
+	plugin.Attributes = plugin.Attributes[:2]
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	require.NoError(t, plugin.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	tags := func(ln, fc, do string) map[string]string {
+		return map[string]string{
+			"address":        server.addr(),
+			"logical_device": "LD0",
+			"logical_node":   ln,
+			"fc":             fc,
+			"data_object":    do,
+		}
+	}
+	expected := []telegraf.Metric{
+		metric.New(
+			"iec61850",
+			tags("MMXU1", "MX", "TotW"),
+			map[string]interface{}{"mag.f": float64(1520.5), "q": uint64(0), "q_validity": "good"},
+			time.Unix(1700000000, 0),
+		),
+		metric.New(
+			"iec61850",
+			tags("MMXU1", "MX", "Hz"),
+			map[string]interface{}{"mag.f": float64(float32(49.98)), "q": uint64(3), "q_validity": "questionable"},
+			time.Unix(1700000001, 0),
+		),
+		metric.New(
+			"iec61850",
+			tags("XCBR1", "ST", "Pos"),
+			map[string]interface{}{"stVal": uint64(2), "q": uint64(0), "q_validity": "good"},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected[:2], acc.GetTelegrafMetrics()[:2])
+	testutil.RequireMetricsEqual(t, expected[2:], acc.GetTelegrafMetrics()[2:], testutil.IgnoreTime())
+}
+
+func TestReports(t *testing.T) {
+	server := newFakeServer(t)
+	server.set("LD0", "MMXU1$MX$TotW", analogueType(), analogue(1520.5, quality(false), time.Unix(1700000000, 0)))
+	server.set("LD0", "MMXU1$MX$Hz", analogueType(), analogue(50, quality(false), time.Unix(1700000000, 0)))
+	server.set("LD0", "LLN0$BR$brcb01$RptID", typeOf(0x8A, 0x41), tlv(0x8A, []byte("meas")))
+	server.set("LD0", "LLN0$BR$brcb01$DatSet", typeOf(0x8A, 0x41), tlv(0x8A, []byte("LD0/LLN0$Measurements")))
+	server.datasets["LD0/LLN0$Measurements"] = []objectName{
+		{domain: "LD0", item: "MMXU1$MX$TotW"},
+		{domain: "LD0", item: "MMXU1$MX$Hz"},
+	}
+
+	plugin := &IEC61850{
+		Address: server.addr(),
+		Timeout: config.Duration(time.Second),
+		Reports: []reportDefinition{
+			{
+				Reference:            "LD0/LLN0.BR.brcb01",
+				IntegrityPeriod:      config.Duration(10 * time.Second),
+				GeneralInterrogation: true,
+			},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	optional := encodeBitStringData(newBitString(false, true, true, false, true, false, true, true, true, false))
+	require.Equal(t, []string{
+		"LLN0$BR$brcb01$RptEna=830100",
+		"LLN0$BR$brcb01$OptFlds=8403066b80",
+		"LLN0$BR$brcb01$TrgOps=8402026c",
+		"LLN0$BR$brcb01$IntgPd=86022710",
+		"LLN0$BR$brcb01$RptEna=8301ff",
+		"LLN0$BR$brcb01$GI=8301ff",
+	}, server.written())
+
+	// Report only containing the frequency with sequence number, time of
+	// entry, data set, buffer overflow, entry ID and configuration revision
+	server.report(
+		tlv(0x8A, []byte("meas")),
+		optional,
+		tlv(0x86, []byte{0x01}),
+		tlv(0x8C, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00}),
+		tlv(0x8A, []byte("LD0/LLN0$Measurements")),
+		tlv(0x83, []byte{0x00}),
+		tlv(0x89, []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x2A}),
+		tlv(0x86, []byte{0x01}),
+		encodeBitStringData(newBitString(false, true)),
+		analogue(49.5, quality(false), time.Unix(1700000010, 0)),
+	)
+	require.Eventually(t, func() bool { return acc.NMetrics() > 0 }, 3*time.Second, 50*time.Millisecond)
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"iec61850",
+			map[string]string{
+				"address":        server.addr(),
+				"logical_device": "LD0",
+				"logical_node":   "MMXU1",
+				"fc":             "MX",
+				"data_object":    "Hz",
+			},
+			map[string]interface{}{"mag.f": float64(49.5), "q": uint64(0), "q_validity": "good"},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+
+	// Reconnecting resumes the buffered report at the last entry
+	server.disconnect()
+	require.Eventually(t, func() bool { return plugin.client.closed() != nil }, 3*time.Second, 50*time.Millisecond)
+	server.reset()
+	require.NoError(t, plugin.Gather(&acc))
+	require.Contains(t, server.written(), "LLN0$BR$brcb01$EntryID=8908000000000000002a")
+}
+
+// analogueType returns the type of a MV data object with the magnitude,
+// quality and timestamp attributes
+func analogueType() []byte {
+	return tlv(0xA2, tlv(0xA1,
+		tlv(0x30, tlv(0x80, []byte("mag")), tlv(0xA1, tlv(0xA2, tlv(0xA1,
+			tlv(0x30, tlv(0x80, []byte("f")), tlv(0xA1, tlv(0x87, []byte{0x08}))),
+		)))),
+		tlv(0x30, tlv(0x80, []byte("q")), tlv(0xA1, tlv(0x84, []byte{0x0D}))),
+		tlv(0x30, tlv(0x80, []byte("t")), tlv(0xA1, tlv(0x91, nil))),
+	))
+}
+
+func typeOf(tag, size byte) []byte {
+	return tlv(tag, []byte{size})
+}
+
+func analogue(v float32, q []byte, t time.Time) []byte {
+	f := make([]byte, 5)
+	f[0] = 0x08
+	binary.BigEndian.PutUint32(f[1:], math.Float32bits(v))
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint32(ts, uint32(t.Unix()))
+	return tlv(0xA2, tlv(0xA2, tlv(0x87, f)), q, tlv(0x91, ts))
+}
+
+func quality(questionable bool) []byte {
+	if questionable {
+		return tlv(0x84, []byte{0x03, 0xC0, 0x00})
+	}
+	return tlv(0x84, []byte{0x03, 0x00, 0x00})
+}
+
+type fakeVariable struct {
+	typ   []byte
+	value []byte
+}
+
+// fakeServer is a minimal MMS server handling a single association
+type fakeServer struct {
+	listener  net.Listener
+	variables map[objectName]fakeVariable
+	datasets  map[string][]objectName
+
+	conn   *isoConn
+	writes []string
+	sync.Mutex
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &fakeServer{
+		listener:  listener,
+		variables: make(map[objectName]fakeVariable),
+		datasets:  make(map[string][]objectName),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				if err := s.serve(conn); err != nil && !errors.Is(err, net.ErrClosed) {
+					t.Logf("serving failed: %v", err)
+				}
+			}()
+		}
+	}()
+	t.Cleanup(func() {
+		listener.Close()
+		s.disconnect()
+	})
+	return s
+}
+
+func (s *fakeServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeServer) set(domain, item string, typ, value []byte) {
+	s.variables[objectName{domain: domain, item: item}] = fakeVariable{typ: typ, value: value}
+}
+
+func (s *fakeServer) written() []string {
+	s.Lock()
+	defer s.Unlock()
+	return append([]string(nil), s.writes...)
+}
+
+func (s *fakeServer) reset() {
+	s.Lock()
+	s.writes = nil
+	s.Unlock()
+}
+
+func (s *fakeServer) disconnect() {
+	s.Lock()
+	defer s.Unlock()
+	if s.conn != nil {
+		s.conn.close()
+		s.conn = nil
+	}
+}
+
+func (s *fakeServer) report(values ...[]byte) {
+	s.Lock()
+	conn := s.conn
+	s.Unlock()
+	pdu := tlv(pduUnconfirmed, tlv(serviceInformationReport,
+		tlv(0xA1, tlv(0x80, []byte("RPT"))),
+		tlv(0xA0, values...),
+	))
+	if conn != nil {
+		conn.send(pdu) //nolint:errcheck // failures are detected by the missing metrics
+	}
+}
+
+func (s *fakeServer) serve(raw net.Conn) error {
+	conn := &isoConn{conn: raw, tpduSize: 1024}
+	defer conn.close()
+
+	// Confirm the transport connection with a TPDU size of 1024 bytes
+	if _, err := conn.readTPKT(); err != nil {
+		return err
+	}
+	if err := conn.writeTPKT([]byte{0x09, 0xD0, 0x00, 0x01, 0x00, 0x01, 0x00, 0xC0, 0x01, 0x0A}); err != nil {
+		return err
+	}
+
+	// Accept the association
+	if _, err := conn.readData(); err != nil {
+		return err
+	}
+	aare := tlv(0x61,
+		tlv(0xA2, tlv(0x02, []byte{0x00})),
+		tlv(0xBE, tlv(0x28, tlv(0x02, []byte{contextMMS}), tlv(0xA0, tlv(pduInitiateResponse)))),
+	)
+	cpa := tlv(0x31, tlv(0xA2, tlv(0x61, tlv(0x30, tlv(0x02, []byte{contextACSE}), tlv(0xA0, aare)))))
+	if err := conn.writeData(spdu(0x0E, spdu(0xC1, cpa))); err != nil {
+		return err
+	}
+
+	s.Lock()
+	s.conn = conn
+	s.Unlock()
+
+	for {
+		pdu, err := conn.receive()
+		if err != nil {
+			return err
+		}
+		elements, err := pdu.children()
+		if err != nil {
+			return err
+		}
+		resp, err := s.handle(elements[1])
+		if err != nil {
+			return err
+		}
+		if resp == nil {
+			// Report an object-non-existent service error
+			resp := tlv(pduConfirmedError, tlv(0x80, elements[0].content), tlv(0xA2, tlv(0xA0, tlv(0x87, []byte{0x02}))))
+			if err := conn.send(resp); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := conn.send(tlv(pduConfirmedResponse, tlv(0x02, elements[0].content), resp)); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *fakeServer) handle(req element) ([]byte, error) {
+	switch req.tag {
+	case serviceRead:
+		spec, err := find(req.content, 0xA1)
+		if err != nil {
+			return nil, err
+		}
+		var names []objectName
+		if list, err := find(spec.content, 0xA1, 0xA1); err == nil {
+			members, found := s.datasets[decodeName(list).String()]
+			if !found {
+				return nil, errors.New("unknown dataset")
+			}
+			names = members
+		} else {
+			list, err := find(spec.content, 0xA0)
+			if err != nil {
+				return nil, err
+			}
+			variables, err := list.children()
+			if err != nil {
+				return nil, err
+			}
+			for _, v := range variables {
+				e, err := find(v.content, 0xA0, 0xA1)
+				if err != nil {
+					return nil, err
+				}
+				names = append(names, decodeName(e))
+			}
+		}
+		results := make([][]byte, 0, len(names))
+		for _, n := range names {
+			if v, found := s.variables[n]; found {
+				results = append(results, v.value)
+			} else {
+				results = append(results, tlv(dataAccessFailure, []byte{10}))
+			}
+		}
+		return tlv(serviceRead, tlv(0xA1, results...)), nil
+	case serviceWrite:
+		e, err := find(req.content, 0xA0, 0x30, 0xA0, 0xA1)
+		if err != nil {
+			return nil, err
+		}
+		elements, err := parseBER(req.content)
+		if err != nil || len(elements) != 2 {
+			return nil, errors.New("invalid write request")
+		}
+		s.Lock()
+		s.writes = append(s.writes, decodeName(e).item+"="+hex.EncodeToString(elements[1].content))
+		s.Unlock()
+		return tlv(serviceWrite, tlv(0x81)), nil
+	case serviceGetVariableAccessAttributes:
+		e, err := find(req.content, 0xA0, 0xA1)
+		if err != nil {
+			return nil, err
+		}
+		v, found := s.variables[decodeName(e)]
+		if !found {
+			return nil, nil
+		}
+		return tlv(serviceGetVariableAccessAttributes, tlv(0x80, []byte{0x00}), tlv(0xA2, v.typ)), nil
+	case serviceGetNamedVariableListAttributes:
+		e, err := find(req.content, 0xA1)
+		if err != nil {
+			return nil, err
+		}
+		members := s.datasets[decodeName(e).String()]
+		variables := make([][]byte, 0, len(members))
+		for _, m := range members {
+			variables = append(variables, tlv(0x30, tlv(0xA0, m.encode())))
+		}
+		return tlv(serviceGetNamedVariableListAttributes, tlv(0x80, []byte{0x00}), tlv(0xA1, variables...)), nil
+	}
+	return nil, errors.New("unsupported service")
+}
+
+func decodeName(e element) objectName {
+	elements, err := e.children()
+	if err != nil || len(elements) != 2 {
+		return objectName{}
+	}
+	return objectName{domain: string(elements[0].content), item: string(elements[1].content)}
+}
@@ -0,0 +1,321 @@
+package iec61850
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Object identifiers used in the association
+var (
+	oidMMSContext  = []byte{0x28, 0xCA, 0x22, 0x02, 0x03} // 1.0.9506.2.3
+	oidMMSSyntax   = []byte{0x28, 0xCA, 0x22, 0x02, 0x01} // 1.0.9506.2.1
+	oidACSESyntax  = []byte{0x52, 0x01, 0x00, 0x01}       // 2.2.1.0.1
+	oidBERSyntax   = []byte{0x51, 0x01}                   // 2.1.1
+	oidCalledTitle = []byte{0x29, 0x01, 0x87, 0x67, 0x01} // 1.1.1.999.1
+	oidCallingTile = []byte{0x29, 0x01, 0x87, 0x67}       // 1.1.1.999
+)
+
+// Presentation context identifiers
+const (
+	contextACSE = 1
+	contextMMS  = 3
+)
+
+// isoConn transfers MMS PDUs using the ISO transport (RFC 1006), session
+// and presentation layers
+type isoConn struct {
+	conn     net.Conn
+	tpduSize int
+	sync.Mutex
+}
+
+// dialISO connects to the server and establishes the association with the
+// given MMS initiate request. The initiate response is returned.
+func dialISO(address string, timeout time.Duration, initiate []byte) (*isoConn, element, error) {
+	conn, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, element{}, err
+	}
+	c := &isoConn{conn: conn, tpduSize: 128}
+
+	initiateResponse, err := c.associate(timeout, initiate)
+	if err != nil {
+		conn.Close()
+		return nil, element{}, err
+	}
+	return c, initiateResponse, nil
+}
+
+func (c *isoConn) associate(timeout time.Duration, initiate []byte) (element, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return element{}, err
+	}
+	defer c.conn.SetDeadline(time.Time{}) //nolint:errcheck // resetting the deadline of a broken connection is irrelevant
+
+	// Connect the transport layer proposing a TPDU size of 2048 bytes and
+	// using the default transport selectors
+	request := []byte{0x11, 0xE0, 0x00, 0x00, 0x00, 0x01, 0x00, 0xC0, 0x01, 0x0B, 0xC1, 0x02, 0x00, 0x01, 0xC2, 0x02, 0x00, 0x01}
+	if err := c.writeTPKT(request); err != nil {
+		return element{}, fmt.Errorf("sending connection request failed: %w", err)
+	}
+	confirm, err := c.readTPKT()
+	if err != nil {
+		return element{}, fmt.Errorf("reading connection confirm failed: %w", err)
+	}
+	if len(confirm) < 7 || confirm[1]&0xF0 != 0xD0 {
+		return element{}, errors.New("connection not confirmed")
+	}
+	params := confirm[7:min(len(confirm), int(confirm[0])+1)]
+	for len(params) >= 2 && len(params) >= 2+int(params[1]) {
+		if params[0] == 0xC0 && params[1] == 1 {
+			c.tpduSize = 1 << params[2]
+		}
+		params = params[2+int(params[1]):]
+	}
+
+	// Establish the session, presentation and application association
+	if err := c.writeData(encodeConnectSPDU(initiate)); err != nil {
+		return element{}, fmt.Errorf("sending association request failed: %w", err)
+	}
+	accept, err := c.readData()
+	if err != nil {
+		return element{}, fmt.Errorf("reading association response failed: %w", err)
+	}
+	return decodeAcceptSPDU(accept)
+}
+
+func (c *isoConn) close() error {
+	return c.conn.Close()
+}
+
+// send transmits the MMS PDU
+func (c *isoConn) send(pdu []byte) error {
+	ppdu := tlv(0x61, tlv(0x30, tlv(0x02, []byte{contextMMS}), tlv(0xA0, pdu)))
+
+	// Give-tokens and data-transfer SPDUs without parameters
+	return c.writeData(append([]byte{0x01, 0x00, 0x01, 0x00}, ppdu...))
+}
+
+// receive returns the next MMS PDU
+func (c *isoConn) receive() (element, error) {
+	buf, err := c.readData()
+	if err != nil {
+		return element{}, err
+	}
+	if len(buf) < 4 {
+		return element{}, errors.New("truncated session data")
+	}
+	switch buf[0] {
+	case 0x01:
+	case 0x09, 0x19:
+		return element{}, errors.New("association closed by server")
+	default:
+		return element{}, fmt.Errorf("unexpected SPDU 0x%02x", buf[0])
+	}
+	pdv, err := find(buf[4:], 0x61, 0x30, 0xA0)
+	if err != nil {
+		return element{}, fmt.Errorf("decoding presentation data failed: %w", err)
+	}
+	elements, err := parseBER(pdv.content)
+	if err != nil {
+		return element{}, err
+	}
+	if len(elements) != 1 {
+		return element{}, errors.New("invalid MMS PDU")
+	}
+	return elements[0], nil
+}
+
+func (c *isoConn) writeTPKT(payload []byte) error {
+	frame := make([]byte, 4, 4+len(payload))
+	frame[0] = 0x03
+	binary.BigEndian.PutUint16(frame[2:], uint16(4+len(payload)))
+	_, err := c.conn.Write(append(frame, payload...))
+	return err
+}
+
+func (c *isoConn) readTPKT() ([]byte, error) {
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(c.conn, header); err != nil {
+		return nil, err
+	}
+	if header[0] != 0x03 {
+		return nil, fmt.Errorf("invalid TPKT version %d", header[0])
+	}
+	length := int(binary.BigEndian.Uint16(header[2:]))
+	if length < 7 {
+		return nil, fmt.Errorf("invalid TPKT length %d", length)
+	}
+	payload := make([]byte, length-4)
+	if _, err := io.ReadFull(c.conn, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// writeData sends the data in one or more data TPDUs
+func (c *isoConn) writeData(data []byte) error {
+	c.Lock()
+	defer c.Unlock()
+
+	size := c.tpduSize - 3
+	for {
+		n := min(size, len(data))
+		flags := byte(0x00)
+		if n == len(data) {
+			flags = 0x80
+		}
+		if err := c.writeTPKT(append([]byte{0x02, 0xF0, flags}, data[:n]...)); err != nil {
+			return err
+		}
+		data = data[n:]
+		if len(data) == 0 {
+			return nil
+		}
+	}
+}
+
+// readData receives data TPDUs until the end of the data unit
+func (c *isoConn) readData() ([]byte, error) {
+	var data []byte
+	for {
+		tpdu, err := c.readTPKT()
+		if err != nil {
+			return nil, err
+		}
+		if tpdu[1] != 0xF0 || len(tpdu) < 3 {
+			return nil, fmt.Errorf("unexpected TPDU 0x%02x", tpdu[1])
+		}
+		data = append(data, tpdu[int(tpdu[0])+1:]...)
+		if tpdu[2]&0x80 != 0 {
+			return data, nil
+		}
+	}
+}
+
+// spdu encodes a session protocol data unit or parameter
+func spdu(code byte, content []byte) []byte {
+	if len(content) < 255 {
+		return append([]byte{code, byte(len(content))}, content...)
+	}
+	buf := []byte{code, 0xFF, byte(len(content) >> 8), byte(len(content))}
+	return append(buf, content...)
+}
+
+func encodeConnectSPDU(initiate []byte) []byte {
+	aarq := tlv(0x60,
+		tlv(0xA1, tlv(0x06, oidMMSContext)),
+		tlv(0xA2, tlv(0x06, oidCalledTitle)),
+		tlv(0xA3, tlv(0x02, []byte{12})),
+		tlv(0xA6, tlv(0x06, oidCallingTile)),
+		tlv(0xA7, tlv(0x02, []byte{12})),
+		tlv(0xBE, tlv(0x28, tlv(0x02, []byte{contextMMS}), tlv(0xA0, initiate))),
+	)
+
+	cp := tlv(0x31,
+		tlv(0xA0, tlv(0x80, []byte{0x01})),
+		tlv(0xA2,
+			tlv(0x81, []byte{0x00, 0x00, 0x00, 0x01}),
+			tlv(0x82, []byte{0x00, 0x00, 0x00, 0x01}),
+			tlv(0xA4,
+				tlv(0x30, tlv(0x02, []byte{contextACSE}), tlv(0x06, oidACSESyntax), tlv(0x30, tlv(0x06, oidBERSyntax))),
+				tlv(0x30, tlv(0x02, []byte{contextMMS}), tlv(0x06, oidMMSSyntax), tlv(0x30, tlv(0x06, oidBERSyntax))),
+			),
+			tlv(0x61, tlv(0x30, tlv(0x02, []byte{contextACSE}), tlv(0xA0, aarq))),
+		),
+	)
+
+	// Protocol options and version 2, duplex functional unit as well as the
+	// calling and called session selectors followed by the user data
+	params := []byte{
+		0x05, 0x06, 0x13, 0x01, 0x00, 0x16, 0x01, 0x02,
+		0x14, 0x02, 0x00, 0x02,
+		0x33, 0x02, 0x00, 0x01,
+		0x34, 0x02, 0x00, 0x01,
+	}
+	params = append(params, spdu(0xC1, cp)...)
+	return spdu(0x0D, params)
+}
+
+// decodeAcceptSPDU checks the association response and returns the
+// contained MMS initiate response
+func decodeAcceptSPDU(buf []byte) (element, error) {
+	if len(buf) < 2 {
+		return element{}, errors.New("truncated session response")
+	}
+	switch buf[0] {
+	case 0x0E:
+	case 0x0C:
+		return element{}, errors.New("session refused")
+	default:
+		return element{}, fmt.Errorf("unexpected session response 0x%02x", buf[0])
+	}
+
+	params, _, err := spduContent(buf)
+	if err != nil {
+		return element{}, err
+	}
+	var userData []byte
+	for len(params) > 0 {
+		code := params[0]
+		value, rest, err := spduContent(params)
+		if err != nil {
+			return element{}, err
+		}
+		if code == 0xC1 {
+			userData = value
+		}
+		params = rest
+	}
+	if userData == nil {
+		return element{}, errors.New("missing presentation data")
+	}
+
+	aare, err := find(userData, 0x31, 0xA2, 0x61, 0x30, 0xA0, 0x61)
+	if err != nil {
+		return element{}, fmt.Errorf("decoding presentation response failed: %w", err)
+	}
+	result, err := find(aare.content, 0xA2, 0x02)
+	if err != nil {
+		return element{}, fmt.Errorf("decoding association result failed: %w", err)
+	}
+	if len(result.content) != 1 || result.content[0] != 0 {
+		return element{}, fmt.Errorf("association rejected with result %v", result.content)
+	}
+	data, err := find(aare.content, 0xBE, 0x28, 0xA0)
+	if err != nil {
+		return element{}, fmt.Errorf("decoding association user data failed: %w", err)
+	}
+	elements, err := parseBER(data.content)
+	if err != nil {
+		return element{}, err
+	}
+	if len(elements) != 1 {
+		return element{}, errors.New("invalid initiate response")
+	}
+	return elements[0], nil
+}
+
+// spduContent returns the content of the unit or parameter at the start of
+// the buffer along with the remaining data
+func spduContent(buf []byte) (content, rest []byte, err error) {
+	if len(buf) < 2 {
+		return nil, nil, errors.New("truncated session parameter")
+	}
+	length, pos := int(buf[1]), 2
+	if length == 0xFF {
+		if len(buf) < 4 {
+			return nil, nil, errors.New("truncated session parameter")
+		}
+		length, pos = int(binary.BigEndian.Uint16(buf[2:])), 4
+	}
+	if len(buf) < pos+length {
+		return nil, nil, errors.New("session parameter exceeds data")
+	}
+	return buf[pos : pos+length], buf[pos+length:], nil
+}
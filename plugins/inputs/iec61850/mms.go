@@ -0,0 +1,520 @@
+package iec61850
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// MMS PDU tags
+const (
+	pduConfirmedRequest  = 0xA0
+	pduConfirmedResponse = 0xA1
+	pduConfirmedError    = 0xA2
+	pduUnconfirmed       = 0xA3
+	pduReject            = 0xA4
+	pduInitiateRequest   = 0xA8
+	pduInitiateResponse  = 0xA9
+	pduInitiateError     = 0xAA
+)
+
+// MMS confirmed service tags
+const (
+	serviceGetVariableAccessAttributes    = 0xA6
+	serviceRead                           = 0xA4
+	serviceWrite                          = 0xA5
+	serviceGetNamedVariableListAttributes = 0xAC
+)
+
+// MMS unconfirmed service tag
+const serviceInformationReport = 0xA0
+
+// MMS data tags
+const (
+	dataArray         = 0xA1
+	dataStructure     = 0xA2
+	dataBoolean       = 0x83
+	dataBitString     = 0x84
+	dataInteger       = 0x85
+	dataUnsigned      = 0x86
+	dataFloatingPoint = 0x87
+	dataOctetString   = 0x89
+	dataVisibleString = 0x8A
+	dataGeneralized   = 0x8B
+	dataBinaryTime    = 0x8C
+	dataBCD           = 0x8D
+	dataMMSString     = 0x90
+	dataUTCTime       = 0x91
+)
+
+// Tags of failures and type specifications
+const (
+	dataAccessFailure  = 0x80
+	writeResultFailure = 0x80
+	typeArray          = 0xA1
+	typeStructure      = 0xA2
+)
+
+// Descriptions of the data access errors
+var dataAccessErrors = []string{
+	"object-invalidated",
+	"hardware-fault",
+	"temporarily-unavailable",
+	"object-access-denied",
+	"object-undefined",
+	"invalid-address",
+	"type-unsupported",
+	"type-inconsistent",
+	"object-attribute-inconsistent",
+	"object-access-unsupported",
+	"object-non-existent",
+	"object-value-invalid",
+}
+
+// objectName is a domain-specific MMS object name
+type objectName struct {
+	domain string
+	item   string
+}
+
+func (n objectName) String() string {
+	return n.domain + "/" + n.item
+}
+
+func (n objectName) encode() []byte {
+	return tlv(0xA1, tlv(0x1A, []byte(n.domain)), tlv(0x1A, []byte(n.item)))
+}
+
+// parseObjectName splits a MMS object reference in "<domain>/<item>" format
+func parseObjectName(ref string) (objectName, error) {
+	domain, item, found := strings.Cut(ref, "/")
+	if !found || domain == "" || item == "" {
+		return objectName{}, fmt.Errorf("invalid object reference %q", ref)
+	}
+	return objectName{domain: domain, item: item}, nil
+}
+
+// bitString is a MMS bit-string with the first bit being the most
+// significant bit of the first byte
+type bitString struct {
+	length int
+	data   []byte
+}
+
+func newBitString(bits ...bool) bitString {
+	b := bitString{length: len(bits), data: make([]byte, (len(bits)+7)/8)}
+	for i, set := range bits {
+		if set {
+			b.data[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return b
+}
+
+func (b bitString) bit(i int) bool {
+	if i >= b.length {
+		return false
+	}
+	return b.data[i/8]&(0x80>>(i%8)) != 0
+}
+
+// uint64 returns the bits with the first bit being the least significant
+// one as used in the IEC 61850 bit numbering
+func (b bitString) uint64() uint64 {
+	var v uint64
+	for i := range min(b.length, 64) {
+		if b.bit(i) {
+			v |= 1 << i
+		}
+	}
+	return v
+}
+
+// accessResult is a read value or the error reported for the variable
+type accessResult struct {
+	value interface{}
+	err   error
+}
+
+// typeSpec describes the type of a MMS variable
+type typeSpec struct {
+	tag        byte
+	components []component
+	element    *typeSpec
+	elements   int
+}
+
+type component struct {
+	name string
+	typ  *typeSpec
+}
+
+func encodeInitiateRequest() []byte {
+	return tlv(pduInitiateRequest,
+		tlv(0x80, berUnsigned(65000)),
+		tlv(0x81, []byte{0x05}),
+		tlv(0x82, []byte{0x05}),
+		tlv(0x83, []byte{0x0A}),
+		tlv(0xA4,
+			tlv(0x80, []byte{0x01}),
+			// Parameter CBB str1, str2, vnam, valt, vlis
+			tlv(0x81, []byte{0x05, 0xF1, 0x00}),
+			// Services supported by the client: read, write,
+			// getVariableAccessAttributes, getNamedVariableListAttributes and
+			// informationReport
+			tlv(0x82, []byte{0x03, 0x0E, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x00}),
+		),
+	)
+}
+
+func encodeConfirmedRequest(invokeID uint32, service []byte) []byte {
+	return tlv(pduConfirmedRequest, tlv(0x02, berUnsigned(uint64(invokeID))), service)
+}
+
+func encodeRead(names ...objectName) []byte {
+	variables := make([][]byte, 0, len(names))
+	for _, n := range names {
+		variables = append(variables, tlv(0x30, tlv(0xA0, n.encode())))
+	}
+	return tlv(serviceRead, tlv(0xA1, tlv(0xA0, variables...)))
+}
+
+func encodeReadList(list objectName) []byte {
+	return tlv(serviceRead, tlv(0xA1, tlv(0xA1, list.encode())))
+}
+
+func encodeWrite(name objectName, data []byte) []byte {
+	return tlv(serviceWrite, tlv(0xA0, tlv(0x30, tlv(0xA0, name.encode()))), tlv(0xA0, data))
+}
+
+func encodeGetVariableAccessAttributes(name objectName) []byte {
+	return tlv(serviceGetVariableAccessAttributes, tlv(0xA0, name.encode()))
+}
+
+func encodeGetNamedVariableListAttributes(list objectName) []byte {
+	return tlv(serviceGetNamedVariableListAttributes, list.encode())
+}
+
+func encodeBooleanData(v bool) []byte {
+	if v {
+		return tlv(dataBoolean, []byte{0xFF})
+	}
+	return tlv(dataBoolean, []byte{0x00})
+}
+
+func encodeUnsignedData(v uint64) []byte {
+	return tlv(dataUnsigned, berUnsigned(v))
+}
+
+func encodeBitStringData(b bitString) []byte {
+	return tlv(dataBitString, []byte{byte(len(b.data)*8 - b.length)}, b.data)
+}
+
+func encodeOctetStringData(v []byte) []byte {
+	return tlv(dataOctetString, v)
+}
+
+// decodeConfirmedResponse returns the invoke ID and the service response of
+// a confirmed response, error or reject PDU
+func decodeConfirmedResponse(pdu element) (uint32, element, error) {
+	elements, err := pdu.children()
+	if err != nil {
+		return 0, element{}, err
+	}
+	if len(elements) < 1 {
+		return 0, element{}, errors.New("missing invoke ID")
+	}
+	id, err := decodeBERUnsigned(elements[0].content)
+	if err != nil || id > math.MaxUint32 {
+		return 0, element{}, fmt.Errorf("invalid invoke ID %v", elements[0].content)
+	}
+	if pdu.tag == pduConfirmedResponse && len(elements) == 2 {
+		return uint32(id), elements[1], nil
+	}
+	switch pdu.tag {
+	case pduConfirmedError:
+		return uint32(id), element{}, errors.New("service error")
+	case pduReject:
+		return uint32(id), element{}, errors.New("request rejected")
+	}
+	return uint32(id), element{}, errors.New("invalid response")
+}
+
+// decodeReadResponse returns the access results of a read response
+func decodeReadResponse(resp element) ([]accessResult, error) {
+	if resp.tag != serviceRead {
+		return nil, fmt.Errorf("unexpected response 0x%02x", resp.tag)
+	}
+	list, err := find(resp.content, 0xA1)
+	if err != nil {
+		return nil, err
+	}
+	return decodeAccessResults(list.content)
+}
+
+func decodeAccessResults(buf []byte) ([]accessResult, error) {
+	elements, err := parseBER(buf)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]accessResult, 0, len(elements))
+	for _, e := range elements {
+		if e.tag == dataAccessFailure {
+			results = append(results, accessResult{err: decodeDataAccessError(e.content)})
+			continue
+		}
+		v, err := decodeData(e)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, accessResult{value: v})
+	}
+	return results, nil
+}
+
+func decodeDataAccessError(buf []byte) error {
+	code, err := decodeBERUnsigned(buf)
+	if err != nil || code >= uint64(len(dataAccessErrors)) {
+		return fmt.Errorf("data access error %v", buf)
+	}
+	return errors.New(dataAccessErrors[code])
+}
+
+// decodeWriteResponse returns the error of the first written variable
+func decodeWriteResponse(resp element) error {
+	if resp.tag != serviceWrite {
+		return fmt.Errorf("unexpected response 0x%02x", resp.tag)
+	}
+	elements, err := resp.children()
+	if err != nil {
+		return err
+	}
+	if len(elements) != 1 {
+		return errors.New("invalid write response")
+	}
+	if elements[0].tag == writeResultFailure {
+		return decodeDataAccessError(elements[0].content)
+	}
+	return nil
+}
+
+// decodeNamedVariableListAttributes returns the members of a data set
+func decodeNamedVariableListAttributes(resp element) ([]objectName, error) {
+	if resp.tag != serviceGetNamedVariableListAttributes {
+		return nil, fmt.Errorf("unexpected response 0x%02x", resp.tag)
+	}
+	list, err := find(resp.content, 0xA1)
+	if err != nil {
+		return nil, err
+	}
+	variables, err := list.children()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]objectName, 0, len(variables))
+	for _, v := range variables {
+		e, err := find(v.content, 0xA0, 0xA1)
+		if err != nil {
+			return nil, fmt.Errorf("unsupported data set member: %w", err)
+		}
+		identifiers, err := e.children()
+		if err != nil {
+			return nil, err
+		}
+		if len(identifiers) != 2 {
+			return nil, errors.New("invalid data set member name")
+		}
+		names = append(names, objectName{domain: string(identifiers[0].content), item: string(identifiers[1].content)})
+	}
+	return names, nil
+}
+
+// decodeVariableAccessAttributes returns the type of the variable
+func decodeVariableAccessAttributes(resp element) (*typeSpec, error) {
+	if resp.tag != serviceGetVariableAccessAttributes {
+		return nil, fmt.Errorf("unexpected response 0x%02x", resp.tag)
+	}
+	spec, err := find(resp.content, 0xA2)
+	if err != nil {
+		return nil, err
+	}
+	elements, err := spec.children()
+	if err != nil {
+		return nil, err
+	}
+	if len(elements) != 1 {
+		return nil, errors.New("invalid type specification")
+	}
+	return decodeTypeSpec(elements[0])
+}
+
+func decodeTypeSpec(e element) (*typeSpec, error) {
+	t := &typeSpec{tag: e.tag}
+	switch e.tag {
+	case typeStructure:
+		list, err := find(e.content, 0xA1)
+		if err != nil {
+			return nil, err
+		}
+		members, err := list.children()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range members {
+			fields, err := m.children()
+			if err != nil {
+				return nil, err
+			}
+			var c component
+			for _, f := range fields {
+				switch f.tag {
+				case 0x80:
+					c.name = string(f.content)
+				case 0xA1:
+					inner, err := f.children()
+					if err != nil {
+						return nil, err
+					}
+					if len(inner) != 1 {
+						return nil, errors.New("invalid component type")
+					}
+					if c.typ, err = decodeTypeSpec(inner[0]); err != nil {
+						return nil, err
+					}
+				}
+			}
+			if c.typ == nil {
+				return nil, fmt.Errorf("missing type of component %q", c.name)
+			}
+			t.components = append(t.components, c)
+		}
+	case typeArray:
+		fields, err := e.children()
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			switch f.tag {
+			case 0x81:
+				n, err := decodeBERUnsigned(f.content)
+				if err != nil {
+					return nil, err
+				}
+				t.elements = int(n)
+			case 0xA2:
+				inner, err := f.children()
+				if err != nil {
+					return nil, err
+				}
+				if len(inner) != 1 {
+					return nil, errors.New("invalid element type")
+				}
+				if t.element, err = decodeTypeSpec(inner[0]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if t.element == nil {
+			return nil, errors.New("missing array element type")
+		}
+	case 0xA0:
+		return nil, errors.New("named types are not supported")
+	}
+	return t, nil
+}
+
+// decodeData converts the MMS data element to a Go value. Structures and
+// arrays are returned as slices of their elements.
+func decodeData(e element) (interface{}, error) {
+	switch e.tag {
+	case dataArray, dataStructure:
+		children, err := e.children()
+		if err != nil {
+			return nil, err
+		}
+		values := make([]interface{}, 0, len(children))
+		for _, c := range children {
+			v, err := decodeData(c)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	case dataBoolean:
+		if len(e.content) != 1 {
+			return nil, errors.New("invalid boolean")
+		}
+		return e.content[0] != 0, nil
+	case dataBitString:
+		if len(e.content) < 1 || e.content[0] > 7 || (len(e.content) == 1 && e.content[0] != 0) {
+			return nil, errors.New("invalid bit-string")
+		}
+		return bitString{length: (len(e.content)-1)*8 - int(e.content[0]), data: e.content[1:]}, nil
+	case dataInteger:
+		return decodeBERSigned(e.content)
+	case dataUnsigned:
+		if len(e.content) > 0 && e.content[0]&0x80 != 0 {
+			return nil, errors.New("invalid unsigned")
+		}
+		return decodeBERUnsigned(e.content)
+	case dataFloatingPoint:
+		switch {
+		case len(e.content) == 5 && e.content[0] == 8:
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(e.content[1:]))), nil
+		case len(e.content) == 9 && e.content[0] == 11:
+			return math.Float64frombits(binary.BigEndian.Uint64(e.content[1:])), nil
+		}
+		return nil, fmt.Errorf("unsupported floating-point format %v", e.content)
+	case dataOctetString:
+		return hex.EncodeToString(e.content), nil
+	case dataVisibleString, dataMMSString, dataGeneralized:
+		return string(e.content), nil
+	case dataUTCTime:
+		if len(e.content) != 8 {
+			return nil, errors.New("invalid utc-time")
+		}
+		seconds := int64(binary.BigEndian.Uint32(e.content))
+		fraction := int64(e.content[4])<<16 | int64(e.content[5])<<8 | int64(e.content[6])
+		return time.Unix(seconds, fraction*int64(time.Second)>>24).UTC(), nil
+	case dataBinaryTime:
+		if len(e.content) != 4 && len(e.content) != 6 {
+			return nil, errors.New("invalid binary-time")
+		}
+		ms := time.Duration(binary.BigEndian.Uint32(e.content)) * time.Millisecond
+		if len(e.content) == 4 {
+			return ms, nil
+		}
+		days := int(binary.BigEndian.Uint16(e.content[4:]))
+		return time.Date(1984, 1, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, days).Add(ms), nil
+	case dataBCD:
+		return decodeBERSigned(e.content)
+	}
+	return nil, fmt.Errorf("unsupported data type 0x%02x", e.tag)
+}
+
+// decodeInformationReport returns the access results of an information
+// report sent for the "RPT" variable list
+func decodeInformationReport(pdu element) ([]accessResult, bool, error) {
+	report, err := find(pdu.content, serviceInformationReport)
+	if err != nil {
+		return nil, false, err
+	}
+	elements, err := report.children()
+	if err != nil {
+		return nil, false, err
+	}
+	if len(elements) != 2 || elements[0].tag != 0xA1 {
+		return nil, false, nil
+	}
+	name, err := find(elements[0].content, 0x80)
+	if err != nil || string(name.content) != "RPT" {
+		return nil, false, nil //nolint:nilerr // reports for other variables are ignored
+	}
+	results, err := decodeAccessResults(elements[1].content)
+	return results, true, err
+}
@@ -5,20 +5,28 @@ import (
 )
 
 type knxDummyInterface struct {
-	inbound chan knx.GroupEvent
+	inbound  chan knx.GroupEvent
+	outbound chan knx.GroupEvent
 }
 
 func newDummyInterface() knxDummyInterface {
 	di := knxDummyInterface{}
 	di.inbound = make(chan knx.GroupEvent)
+	di.outbound = make(chan knx.GroupEvent, 100)
 
 	return di
 }
 
-func (di *knxDummyInterface) Send(event knx.GroupEvent) {
+// Inject simulates receiving the event from the bus
+func (di *knxDummyInterface) Inject(event knx.GroupEvent) {
 	di.inbound <- event
 }
 
+func (di *knxDummyInterface) Send(event knx.GroupEvent) error {
+	di.outbound <- event
+	return nil
+}
+
 func (di *knxDummyInterface) Inbound() <-chan knx.GroupEvent {
 	return di.inbound
 }
@@ -10,9 +10,11 @@ import (
 	"sync/atomic"
 
 	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
 	"github.com/vapourismo/knx-go/knx/dpt"
 
 	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/common/ets"
 	"github.com/influxdata/telegraf/plugins/inputs"
 )
 
@@ -22,12 +24,16 @@ var sampleConfig string
 type KNXListener struct {
 	ServiceType    string          `toml:"service_type"`
 	ServiceAddress string          `toml:"service_address"`
+	ETSFile        string          `toml:"ets_file"`
+	ETSMeasurement string          `toml:"ets_measurement"`
+	ReadAddresses  []string        `toml:"read_addresses"`
 	Measurements   []measurement   `toml:"measurement"`
 	Log            telegraf.Logger `toml:"-"`
 
 	client      knxInterface
 	gaTargetMap map[string]addressTarget
 	gaLogbook   map[string]bool
+	readGAs     []cemi.GroupAddr
 
 	wg        sync.WaitGroup
 	connected atomic.Bool
@@ -42,11 +48,13 @@ type measurement struct {
 
 type addressTarget struct {
 	measurement string
+	name        string
 	asstring    bool
 	datapoint   dpt.Datapoint
 }
 
 type knxInterface interface {
+	Send(event knx.GroupEvent) error
 	Inbound() <-chan knx.GroupEvent
 	Close()
 }
@@ -77,6 +85,46 @@ func (kl *KNXListener) Init() error {
 		}
 	}
 
+	// Add the group addresses of the ETS project not configured explicitly
+	// and use the names of the project for all addresses
+	if kl.ETSFile != "" {
+		addresses, err := ets.Load(kl.ETSFile)
+		if err != nil {
+			return fmt.Errorf("importing ETS file %q failed: %w", kl.ETSFile, err)
+		}
+		for _, a := range addresses {
+			if target, ok := kl.gaTargetMap[a.Address]; ok {
+				target.name = a.Name
+				kl.gaTargetMap[a.Address] = target
+				continue
+			}
+			if a.DPT == "" {
+				kl.Log.Debugf("Ignoring group-address %q (%s) without datapoint-type", a.Address, a.Name)
+				continue
+			}
+			d, ok := dpt.Produce(a.DPT)
+			if !ok {
+				kl.Log.Debugf("Ignoring group-address %q (%s) with unsupported datapoint-type %q", a.Address, a.Name, a.DPT)
+				continue
+			}
+			kl.gaTargetMap[a.Address] = addressTarget{measurement: kl.ETSMeasurement, name: a.Name, datapoint: d}
+		}
+		kl.Log.Debugf("Imported %d group-addresses from %q", len(addresses), kl.ETSFile)
+	}
+
+	// Check the addresses to request the values for
+	kl.readGAs = make([]cemi.GroupAddr, 0, len(kl.ReadAddresses))
+	for _, a := range kl.ReadAddresses {
+		ga, err := cemi.NewGroupAddrString(a)
+		if err != nil {
+			return fmt.Errorf("invalid read address %q: %w", a, err)
+		}
+		if _, ok := kl.gaTargetMap[ga.String()]; !ok {
+			return fmt.Errorf("read address %q not assigned to a measurement", a)
+		}
+		kl.readGAs = append(kl.readGAs, ga)
+	}
+
 	return nil
 }
 
@@ -136,6 +184,15 @@ func (kl *KNXListener) Gather(acc telegraf.Accumulator) error {
 		}
 	}
 
+	// Request the current values, the devices respond with GroupValue_Response
+	// messages handled by the listener
+	for _, ga := range kl.readGAs {
+		event := knx.GroupEvent{Command: knx.GroupRead, Destination: ga}
+		if err := kl.client.Send(event); err != nil {
+			acc.AddError(fmt.Errorf("requesting value of %q failed: %w", ga, err))
+		}
+	}
+
 	return nil
 }
 
@@ -204,12 +261,19 @@ func (kl *KNXListener) listen(acc telegraf.Accumulator) {
 			"unit":         target.datapoint.(dpt.DatapointMeta).Unit(),
 			"source":       msg.Source.String(),
 		}
+		if target.name != "" {
+			tags["name"] = target.name
+		}
 		acc.AddFields(target.measurement, fields, tags)
 	}
 }
 
 func init() {
-	inputs.Add("knx_listener", func() telegraf.Input { return &KNXListener{ServiceType: "tunnel"} })
+	inputs.Add("knx_listener", func() telegraf.Input {
+		return &KNXListener{ServiceType: "tunnel", ETSMeasurement: "knx"}
+	})
 	// Register for backward compatibility
-	inputs.Add("KNXListener", func() telegraf.Input { return &KNXListener{ServiceType: "tunnel"} })
+	inputs.Add("KNXListener", func() telegraf.Input {
+		return &KNXListener{ServiceType: "tunnel", ETSMeasurement: "knx"}
+	})
 }
@@ -11,6 +11,8 @@ import (
 	"github.com/vapourismo/knx-go/knx/cemi"
 	"github.com/vapourismo/knx-go/knx/dpt"
 
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
 	"github.com/influxdata/telegraf/testutil"
 )
 
@@ -137,7 +139,7 @@ func TestRegularReceives_DPT(t *testing.T) {
 	// Send the defined test data
 	for _, testcase := range testcases {
 		event := produceKnxEvent(t, testcase.address, testcase.dpt, testcase.value)
-		client.Send(*event)
+		client.Inject(*event)
 	}
 
 	// Give the accumulator some time to collect the data
@@ -190,7 +192,7 @@ func TestRegularReceives_MultipleMessages(t *testing.T) {
 
 	for _, testcase := range testMessages {
 		event := produceKnxEvent(t, testcase.address, testcase.dpt, testcase.value)
-		client.Send(*event)
+		client.Inject(*event)
 	}
 
 	// Give the accumulator some time to collect the data
@@ -243,7 +245,7 @@ func TestReconnect(t *testing.T) {
 
 	for _, testcase := range testMessages {
 		event := produceKnxEvent(t, testcase.address, testcase.dpt, testcase.value)
-		client.Send(*event)
+		client.Inject(*event)
 	}
 
 	// Give the accumulator some time to collect the data
@@ -270,7 +272,7 @@ func TestReconnect(t *testing.T) {
 
 	for _, testcase := range testMessages {
 		event := produceKnxEvent(t, testcase.address, testcase.dpt, testcase.value)
-		client.Send(*event)
+		client.Inject(*event)
 	}
 
 	// Give the accumulator some time to collect the data
@@ -278,3 +280,135 @@ func TestReconnect(t *testing.T) {
 		return acc.NMetrics() >= 2
 	}, 3*time.Second, 100*time.Millisecond, "expected 2 metric but got %d", acc.NMetrics())
 }
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *KNXListener
+		expected string
+	}{
+		{
+			name: "duplicate address",
+			plugin: &KNXListener{
+				Measurements: []measurement{
+					{Name: "temperature", Dpt: "9.001", Addresses: []string{"1/0/0"}},
+					{Name: "humidity", Dpt: "9.007", Addresses: []string{"1/0/0"}},
+				},
+			},
+			expected: `duplicate specification of address "1/0/0"`,
+		},
+		{
+			name:     "missing ETS file",
+			plugin:   &KNXListener{ETSFile: "testdata/missing.xml"},
+			expected: `importing ETS file "testdata/missing.xml" failed`,
+		},
+		{
+			name:     "invalid read address",
+			plugin:   &KNXListener{ReadAddresses: []string{"1/2/3/4"}},
+			expected: `invalid read address "1/2/3/4"`,
+		},
+		{
+			name:     "unknown read address",
+			plugin:   &KNXListener{ETSFile: "testdata/project.xml", ReadAddresses: []string{"1/0/2"}},
+			expected: `read address "1/0/2" not assigned to a measurement`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestETSImport(t *testing.T) {
+	listener := KNXListener{
+		ServiceType:    "dummy",
+		ETSFile:        "testdata/project.xml",
+		ETSMeasurement: "knx",
+		Measurements: []measurement{
+			{Name: "window", Dpt: "1.019", AsString: true, Addresses: []string{"1/0/1"}},
+		},
+		Log: testutil.Logger{Name: "knx_listener"},
+	}
+	require.NoError(t, listener.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, listener.Start(&acc))
+	defer listener.Stop()
+	client := listener.client.(*knxDummyInterface)
+
+	client.Inject(*produceKnxEvent(t, "1/0/0", "9.001", 21.5))
+	client.Inject(*produceKnxEvent(t, "1/0/1", "1.019", true))
+	// Addresses without sub-type in the project are ignored
+	client.Inject(*produceKnxEvent(t, "1/0/2", "5.001", 50.0))
+	acc.Wait(2)
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"knx",
+			map[string]string{
+				"groupaddress": "1/0/0",
+				"name":         "Temperature",
+				"source":       "0.0.0",
+				"unit":         "°C",
+			},
+			map[string]interface{}{"value": float64(21.5)},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"window",
+			map[string]string{
+				"groupaddress": "1/0/1",
+				"name":         "Window contact",
+				"source":       "0.0.0",
+				"unit":         "",
+			},
+			map[string]interface{}{"value": "open"},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
+
+func TestReadAddresses(t *testing.T) {
+	listener := KNXListener{
+		ServiceType: "dummy",
+		Measurements: []measurement{
+			{Name: "temperature", Dpt: "9.001", Addresses: []string{"1/0/0", "1/0/1"}},
+		},
+		ReadAddresses: []string{"1/0/1", "1/0/0"},
+		Log:           testutil.Logger{Name: "knx_listener"},
+	}
+	require.NoError(t, listener.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, listener.Start(&acc))
+	defer listener.Stop()
+	client := listener.client.(*knxDummyInterface)
+
+	// Each gather cycle requests the values of the addresses
+	require.NoError(t, listener.Gather(&acc))
+	for _, expected := range []string{"1/0/1", "1/0/0"} {
+		event := <-client.outbound
+		require.Equal(t, knx.GroupRead, event.Command)
+		require.Equal(t, expected, event.Destination.String())
+	}
+
+	// The responses are reported like written values while requests of
+	// other participants are ignored
+	request := produceKnxEvent(t, "1/0/0", "9.001", 0.0)
+	request.Command = knx.GroupRead
+	request.Data = nil
+	client.Inject(*request)
+	response := produceKnxEvent(t, "1/0/0", "9.001", 21.5)
+	response.Command = knx.GroupResponse
+	client.Inject(*response)
+	acc.Wait(1)
+
+	require.Len(t, acc.Metrics, 1)
+	require.Equal(t, "temperature", acc.Metrics[0].Measurement)
+	require.Equal(t, "1/0/0", acc.Metrics[0].Tags["groupaddress"])
+	require.InDelta(t, 21.5, acc.Metrics[0].Fields["value"], epsilon)
+}
@@ -0,0 +1,337 @@
+package mcprotocol
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Commands and subcommands
+const (
+	cmdBatchRead   = 0x0401
+	subWordUnits   = 0x0000
+	subWordUnitsRX = 0x0002
+)
+
+// Maximum number of words of a batch read
+const maxBatchWords = 960
+
+// device describes a device type of the PLC
+type device struct {
+	code byte
+	// Device code for the extended iQ-R format
+	codeRX uint16
+	name   string
+	// Device numbers are specified in hexadecimal
+	hex bool
+	// Bit devices are read in units of 16 points
+	bit bool
+}
+
+var devices = map[string]*device{
+	"X":  {code: 0x9C, codeRX: 0x009C, name: "X", hex: true, bit: true},
+	"Y":  {code: 0x9D, codeRX: 0x009D, name: "Y", hex: true, bit: true},
+	"M":  {code: 0x90, codeRX: 0x0090, name: "M", bit: true},
+	"L":  {code: 0x92, codeRX: 0x0092, name: "L", bit: true},
+	"F":  {code: 0x93, codeRX: 0x0093, name: "F", bit: true},
+	"B":  {code: 0xA0, codeRX: 0x00A0, name: "B", hex: true, bit: true},
+	"SM": {code: 0x91, codeRX: 0x0091, name: "SM", bit: true},
+	"SB": {code: 0xA1, codeRX: 0x00A1, name: "SB", hex: true, bit: true},
+	"D":  {code: 0xA8, codeRX: 0x00A8, name: "D"},
+	"W":  {code: 0xB4, codeRX: 0x00B4, name: "W", hex: true},
+	"R":  {code: 0xAF, codeRX: 0x00AF, name: "R"},
+	"ZR": {code: 0xB0, codeRX: 0x00B0, name: "ZR", hex: true},
+	"SD": {code: 0xA9, codeRX: 0x00A9, name: "SD"},
+	"SW": {code: 0xB5, codeRX: 0x00B5, name: "SW", hex: true},
+	"TN": {code: 0xC2, codeRX: 0x00C2, name: "TN"},
+	"CN": {code: 0xC5, codeRX: 0x00C5, name: "CN"},
+}
+
+// Descriptions of common end codes
+var endCodes = map[uint16]string{
+	0xC050: "ASCII data cannot be converted to binary",
+	0xC051: "number of points out of range",
+	0xC052: "number of points out of range",
+	0xC053: "number of points out of range",
+	0xC054: "number of points out of range",
+	0xC056: "device out of range",
+	0xC058: "request data length mismatch",
+	0xC059: "command or subcommand not supported",
+	0xC05B: "device cannot be accessed",
+	0xC05C: "invalid request content",
+	0xC05F: "request not executable for the target",
+	0xC060: "invalid request content for the device",
+	0xC061: "request data length mismatch",
+	0xC070: "device memory extension not supported",
+	0xCEE1: "request message size exceeded",
+	0xCEE2: "response message size exceeded",
+}
+
+// frameConfig defines the frame format and the route to the target station
+type frameConfig struct {
+	frame         string
+	ascii         bool
+	rx            bool
+	network       byte
+	station       byte
+	moduleIO      uint16
+	moduleStation byte
+	timer         uint16
+}
+
+// client sends batch read requests to the PLC
+type client struct {
+	frameConfig
+	conn    net.Conn
+	stream  bool
+	timeout time.Duration
+	serial  uint16
+}
+
+func dial(transport, address string, cfg frameConfig, timeout time.Duration) (*client, error) {
+	conn, err := net.DialTimeout(transport, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return &client{
+		frameConfig: cfg,
+		conn:        conn,
+		stream:      transport == "tcp",
+		timeout:     timeout,
+	}, nil
+}
+
+func (c *client) close() {
+	c.conn.Close()
+}
+
+// readWords reads the given number of words starting at the device number
+func (c *client) readWords(dev *device, number uint32, count uint16) ([]uint16, error) {
+	c.serial++
+	req := c.encodeRequest(c.serial, cmdBatchRead, c.subcommand(), c.encodeBatchRead(dev, number, count))
+
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+	if _, err := c.conn.Write(req); err != nil {
+		return nil, err
+	}
+	data, err := c.readResponse(c.serial)
+	if err != nil {
+		return nil, err
+	}
+	return c.decodeWords(data, count)
+}
+
+func (c *client) subcommand() uint16 {
+	if c.rx {
+		return subWordUnitsRX
+	}
+	return subWordUnits
+}
+
+// encodeBatchRead encodes the device and number of points of a batch read
+func (c *frameConfig) encodeBatchRead(dev *device, number uint32, count uint16) []byte {
+	if c.ascii {
+		var b strings.Builder
+		code, digits := dev.name, 6
+		if c.rx {
+			code, digits = fmt.Sprintf("%-4s", dev.name), 8
+			code = strings.ReplaceAll(code, " ", "*")
+		} else if len(code) == 1 {
+			code += "*"
+		}
+		b.WriteString(code)
+		if dev.hex {
+			fmt.Fprintf(&b, "%0*X", digits, number)
+		} else {
+			fmt.Fprintf(&b, "%0*d", digits, number)
+		}
+		fmt.Fprintf(&b, "%04X", count)
+		return []byte(b.String())
+	}
+
+	if c.rx {
+		buf := binary.LittleEndian.AppendUint32(nil, number)
+		buf = binary.LittleEndian.AppendUint16(buf, dev.codeRX)
+		return binary.LittleEndian.AppendUint16(buf, count)
+	}
+	buf := []byte{byte(number), byte(number >> 8), byte(number >> 16), dev.code}
+	return binary.LittleEndian.AppendUint16(buf, count)
+}
+
+// encodeRequest builds the request message of the configured frame format
+func (c *frameConfig) encodeRequest(serial, command, subcommand uint16, data []byte) []byte {
+	if c.ascii {
+		var b strings.Builder
+		if c.frame == "4E" {
+			fmt.Fprintf(&b, "5400%04X0000", serial)
+		} else {
+			b.WriteString("5000")
+		}
+		fmt.Fprintf(&b, "%02X%02X%04X%02X", c.network, c.station, c.moduleIO, c.moduleStation)
+		fmt.Fprintf(&b, "%04X%04X%04X%04X", 12+len(data), c.timer, command, subcommand)
+		b.Write(data)
+		return []byte(b.String())
+	}
+
+	var buf []byte
+	if c.frame == "4E" {
+		buf = []byte{0x54, 0x00}
+		buf = binary.LittleEndian.AppendUint16(buf, serial)
+		buf = append(buf, 0x00, 0x00)
+	} else {
+		buf = []byte{0x50, 0x00}
+	}
+	buf = append(buf, c.network, c.station)
+	buf = binary.LittleEndian.AppendUint16(buf, c.moduleIO)
+	buf = append(buf, c.moduleStation)
+	buf = binary.LittleEndian.AppendUint16(buf, uint16(6+len(data)))
+	buf = binary.LittleEndian.AppendUint16(buf, c.timer)
+	buf = binary.LittleEndian.AppendUint16(buf, command)
+	buf = binary.LittleEndian.AppendUint16(buf, subcommand)
+	return append(buf, data...)
+}
+
+// responseHeaderLength returns the length of the response header up to and
+// including the response data length
+func (c *frameConfig) responseHeaderLength() int {
+	n := 9
+	if c.frame == "4E" {
+		n += 4
+	}
+	if c.ascii {
+		n *= 2
+	}
+	return n
+}
+
+// readResponse reads the response and returns the data after checking the
+// header and the end code
+func (c *client) readResponse(serial uint16) ([]byte, error) {
+	var msg []byte
+	if c.stream {
+		header := make([]byte, c.responseHeaderLength())
+		if _, err := io.ReadFull(c.conn, header); err != nil {
+			return nil, err
+		}
+		length, err := c.dataLength(header)
+		if err != nil {
+			return nil, err
+		}
+		msg = make([]byte, len(header)+length)
+		copy(msg, header)
+		if _, err := io.ReadFull(c.conn, msg[len(header):]); err != nil {
+			return nil, err
+		}
+	} else {
+		buf := make([]byte, 8192)
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		msg = buf[:n]
+	}
+	return c.decodeResponse(msg, serial)
+}
+
+// dataLength returns the response data length given in the header
+func (c *frameConfig) dataLength(header []byte) (int, error) {
+	if c.ascii {
+		v, err := strconv.ParseUint(string(header[len(header)-4:]), 16, 16)
+		if err != nil {
+			return 0, fmt.Errorf("invalid response data length %q", header[len(header)-4:])
+		}
+		return int(v), nil
+	}
+	return int(binary.LittleEndian.Uint16(header[len(header)-2:])), nil
+}
+
+func (c *frameConfig) decodeResponse(msg []byte, serial uint16) ([]byte, error) {
+	headerLength := c.responseHeaderLength()
+	if len(msg) < headerLength {
+		return nil, errors.New("truncated response header")
+	}
+	header := msg[:headerLength]
+
+	var subheader []byte
+	switch {
+	case c.ascii && c.frame == "4E":
+		subheader = []byte(fmt.Sprintf("D400%04X0000", serial))
+	case c.ascii:
+		subheader = []byte("D000")
+	case c.frame == "4E":
+		subheader = binary.LittleEndian.AppendUint16([]byte{0xD4, 0x00}, serial)
+		subheader = append(subheader, 0x00, 0x00)
+	default:
+		subheader = []byte{0xD0, 0x00}
+	}
+	if !bytes.Equal(header[:len(subheader)], subheader) {
+		return nil, fmt.Errorf("unexpected response header %q", header[:len(subheader)])
+	}
+
+	length, err := c.dataLength(header)
+	if err != nil {
+		return nil, err
+	}
+	data := msg[headerLength:]
+	endLength := 2
+	if c.ascii {
+		endLength = 4
+	}
+	if len(data) < length || length < endLength {
+		return nil, errors.New("truncated response data")
+	}
+	data = data[:length]
+
+	var code uint16
+	if c.ascii {
+		v, err := strconv.ParseUint(string(data[:4]), 16, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid end code %q", data[:4])
+		}
+		code = uint16(v)
+	} else {
+		code = binary.LittleEndian.Uint16(data)
+	}
+	if code != 0 {
+		if msg, found := endCodes[code]; found {
+			return nil, fmt.Errorf("end code 0x%04X: %s", code, msg)
+		}
+		return nil, fmt.Errorf("end code 0x%04X", code)
+	}
+	return data[endLength:], nil
+}
+
+// decodeWords converts the response data to words
+func (c *frameConfig) decodeWords(data []byte, count uint16) ([]uint16, error) {
+	words := make([]uint16, count)
+	if c.ascii {
+		if len(data) < 4*int(count) {
+			return nil, errors.New("truncated word data")
+		}
+		buf := make([]byte, 2*count)
+		if _, err := hex.Decode(buf, data[:4*int(count)]); err != nil {
+			return nil, fmt.Errorf("invalid word data: %w", err)
+		}
+		for i := range words {
+			words[i] = binary.BigEndian.Uint16(buf[2*i:])
+		}
+		return words, nil
+	}
+
+	if len(data) < 2*int(count) {
+		return nil, errors.New("truncated word data")
+	}
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return words, nil
+}
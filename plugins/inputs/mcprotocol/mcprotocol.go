@@ -0,0 +1,387 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package mcprotocol
+
+import (
+	"cmp"
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+var regexAddr = regexp.MustCompile(`^([A-Z]{1,2}?)([0-9A-F]+)(?:\.([0-9A-F]))?$`)
+
+// Maximum number of words between two fields to still read them in the same
+// batch. Reading those words is cheaper than the overhead of another request.
+const maxMergeGap = 64
+
+// Number of words occupied by the data types
+var typeWords = map[string]int{
+	"BOOL":    1,
+	"INT16":   1,
+	"UINT16":  1,
+	"INT32":   2,
+	"UINT32":  2,
+	"FLOAT32": 2,
+	"FLOAT64": 4,
+}
+
+type MCProtocol struct {
+	Address       string             `toml:"address"`
+	Transport     string             `toml:"transport"`
+	Frame         string             `toml:"frame"`
+	Format        string             `toml:"format"`
+	Series        string             `toml:"series"`
+	Network       uint8              `toml:"network"`
+	Station       uint8              `toml:"station"`
+	ModuleIO      uint16             `toml:"module_io"`
+	ModuleStation uint8              `toml:"module_station"`
+	Timeout       config.Duration    `toml:"timeout"`
+	Metrics       []metricDefinition `toml:"metric"`
+	Log           telegraf.Logger    `toml:"-"`
+
+	cfg     frameConfig
+	client  *client
+	batches []*batch
+}
+
+type metricDefinition struct {
+	Name   string            `toml:"name"`
+	Fields []fieldDefinition `toml:"fields"`
+	Tags   map[string]string `toml:"tags"`
+}
+
+type fieldDefinition struct {
+	Name    string `toml:"name"`
+	Address string `toml:"address"`
+	Type    string `toml:"type"`
+	Length  int    `toml:"length"`
+}
+
+// batch is a range of words of a device read with a single request along
+// with the fields located in the data
+type batch struct {
+	device   *device
+	start    uint32
+	count    int
+	mappings []*fieldMapping
+}
+
+type fieldMapping struct {
+	measurement string
+	field       string
+	tags        map[string]string
+	device      *device
+	start       uint32
+	words       int
+	bit         int
+	dtype       string
+}
+
+func (*MCProtocol) SampleConfig() string {
+	return sampleConfig
+}
+
+func (m *MCProtocol) Init() error {
+	if m.Address == "" {
+		return errors.New("'address' must be specified")
+	}
+	if _, _, err := net.SplitHostPort(m.Address); err != nil {
+		return fmt.Errorf("invalid 'address' %q: %w", m.Address, err)
+	}
+	switch m.Transport {
+	case "":
+		m.Transport = "tcp"
+	case "tcp", "udp":
+	default:
+		return fmt.Errorf("invalid 'transport' %q", m.Transport)
+	}
+	switch m.Frame {
+	case "":
+		m.Frame = "3E"
+	case "3E", "4E":
+	default:
+		return fmt.Errorf("invalid 'frame' %q", m.Frame)
+	}
+	switch m.Format {
+	case "":
+		m.Format = "binary"
+	case "binary", "ascii":
+	default:
+		return fmt.Errorf("invalid 'format' %q", m.Format)
+	}
+	switch m.Series {
+	case "":
+		m.Series = "q"
+	case "q", "iqr":
+	default:
+		return fmt.Errorf("invalid 'series' %q", m.Series)
+	}
+	if m.Timeout <= 0 {
+		return errors.New("'timeout' must be positive")
+	}
+	if len(m.Metrics) == 0 {
+		return errors.New("no metric defined")
+	}
+
+	// The monitoring timer is specified in units of 250ms
+	timer := max(time.Duration(m.Timeout)/(250*time.Millisecond), 1)
+	m.cfg = frameConfig{
+		frame:         m.Frame,
+		ascii:         m.Format == "ascii",
+		rx:            m.Series == "iqr",
+		network:       m.Network,
+		station:       m.Station,
+		moduleIO:      m.ModuleIO,
+		moduleStation: m.ModuleStation,
+		timer:         uint16(min(timer, math.MaxUint16)),
+	}
+
+	return m.createBatches()
+}
+
+func (m *MCProtocol) createBatches() error {
+	seen := make(map[string]bool)
+	var mappings []*fieldMapping
+	for _, def := range m.Metrics {
+		if def.Name == "" {
+			def.Name = "mcprotocol"
+		}
+		if len(def.Fields) == 0 {
+			return fmt.Errorf("no fields defined for metric %q", def.Name)
+		}
+		for _, f := range def.Fields {
+			if f.Name == "" {
+				return fmt.Errorf("unnamed field in metric %q", def.Name)
+			}
+			id := def.Name + "\x00" + f.Name + "\x00" + fmt.Sprint(def.Tags)
+			if seen[id] {
+				return fmt.Errorf("duplicate field %q in metric %q", f.Name, def.Name)
+			}
+			seen[id] = true
+
+			fm, err := newFieldMapping(f)
+			if err != nil {
+				return fmt.Errorf("field %q of metric %q: %w", f.Name, def.Name, err)
+			}
+			fm.measurement = def.Name
+			fm.tags = def.Tags
+			mappings = append(mappings, fm)
+		}
+	}
+
+	// Merge fields of the same device being close to each other into one
+	// batch read
+	slices.SortStableFunc(mappings, func(a, b *fieldMapping) int {
+		return cmp.Or(
+			cmp.Compare(a.device.name, b.device.name),
+			cmp.Compare(a.start, b.start),
+		)
+	})
+	m.batches = nil
+	var current *batch
+	for _, fm := range mappings {
+		end := int(fm.start) + fm.words
+		if current == nil ||
+			current.device != fm.device ||
+			int(fm.start) > int(current.start)+current.count+maxMergeGap ||
+			max(end, int(current.start)+current.count)-int(current.start) > maxBatchWords {
+			current = &batch{device: fm.device, start: fm.start}
+			m.batches = append(m.batches, current)
+		}
+		current.count = max(current.count, end-int(current.start))
+		current.mappings = append(current.mappings, fm)
+	}
+
+	return nil
+}
+
+// newFieldMapping parses the address and type of the field and determines
+// the words to read. The start of bit devices is given in words of 16 points.
+func newFieldMapping(def fieldDefinition) (*fieldMapping, error) {
+	match := regexAddr.FindStringSubmatch(strings.ToUpper(def.Address))
+	if match == nil {
+		return nil, fmt.Errorf("invalid address %q", def.Address)
+	}
+	// Device names may end with a hexadecimal digit, e.g. "SB" or "SD", so
+	// prefer the longest known device name
+	name, digits := match[1], match[2]
+	if _, found := devices[name+digits[:1]]; found && len(digits) > 1 {
+		name, digits = name+digits[:1], digits[1:]
+	}
+	dev, found := devices[name]
+	if !found {
+		return nil, fmt.Errorf("unknown device in address %q", def.Address)
+	}
+	base := 10
+	if dev.hex {
+		base = 16
+	}
+	number, err := strconv.ParseUint(digits, base, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid device number in address %q", def.Address)
+	}
+
+	fm := &fieldMapping{field: def.Name, device: dev, bit: -1, dtype: strings.ToUpper(def.Type)}
+	if match[3] != "" {
+		if dev.bit {
+			return nil, fmt.Errorf("bit of bit device in address %q", def.Address)
+		}
+		bit, err := strconv.ParseUint(match[3], 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bit in address %q", def.Address)
+		}
+		fm.bit = int(bit)
+	}
+	if fm.dtype == "" {
+		fm.dtype = "INT16"
+		if dev.bit || fm.bit >= 0 {
+			fm.dtype = "BOOL"
+		}
+	}
+	if fm.bit >= 0 && fm.dtype != "BOOL" {
+		return nil, fmt.Errorf("type %q cannot be used with a bit address", def.Type)
+	}
+	if fm.bit < 0 && fm.dtype == "BOOL" && !dev.bit {
+		return nil, fmt.Errorf("bit required in address %q", def.Address)
+	}
+
+	switch fm.dtype {
+	case "STRING":
+		if def.Length <= 0 {
+			return nil, errors.New("'length' required for strings")
+		}
+		fm.words = (def.Length + 1) / 2
+	default:
+		words, found := typeWords[fm.dtype]
+		if !found {
+			return nil, fmt.Errorf("invalid type %q", def.Type)
+		}
+		fm.words = words
+	}
+
+	fm.start = uint32(number)
+	if dev.bit {
+		if fm.dtype == "BOOL" {
+			fm.bit = int(number % 16)
+		} else if number%16 != 0 {
+			return nil, fmt.Errorf("address %q must be a multiple of 16 for type %q", def.Address, fm.dtype)
+		}
+		fm.start = uint32(number / 16)
+	}
+	if fm.words > maxBatchWords {
+		return nil, fmt.Errorf("field exceeds the maximum of %d words", maxBatchWords)
+	}
+	return fm, nil
+}
+
+func (m *MCProtocol) Start(telegraf.Accumulator) error {
+	return m.connect()
+}
+
+func (m *MCProtocol) Gather(acc telegraf.Accumulator) error {
+	if m.client == nil {
+		if err := m.connect(); err != nil {
+			return err
+		}
+	}
+
+	timestamp := time.Now()
+	grouper := metric.NewSeriesGrouper()
+	for _, b := range m.batches {
+		start := b.start
+		if b.device.bit {
+			start *= 16
+		}
+		words, err := m.client.readWords(b.device, start, uint16(b.count))
+		if err != nil {
+			// Reconnect on the next gather cycle as the state of the
+			// connection is unknown
+			m.client.close()
+			m.client = nil
+			return fmt.Errorf("reading %d words from %s%d failed: %w", b.count, b.device.name, start, err)
+		}
+		for _, fm := range b.mappings {
+			offset := int(fm.start - b.start)
+			value := fm.convert(words[offset : offset+fm.words])
+			grouper.Add(fm.measurement, fm.tags, timestamp, fm.field, value)
+		}
+	}
+
+	for _, x := range grouper.Metrics() {
+		acc.AddMetric(x)
+	}
+
+	return nil
+}
+
+func (m *MCProtocol) Stop() {
+	if m.client != nil {
+		m.client.close()
+		m.client = nil
+	}
+}
+
+func (m *MCProtocol) connect() error {
+	c, err := dial(m.Transport, m.Address, m.cfg, time.Duration(m.Timeout))
+	if err != nil {
+		return fmt.Errorf("connecting to %s failed: %w", m.Address, err)
+	}
+	m.client = c
+	return nil
+}
+
+// convert decodes the value of the field from the words with the least
+// significant word first
+func (fm *fieldMapping) convert(words []uint16) interface{} {
+	buf := make([]byte, 0, 2*len(words))
+	for _, w := range words {
+		buf = binary.LittleEndian.AppendUint16(buf, w)
+	}
+
+	switch fm.dtype {
+	case "BOOL":
+		return words[0]&(1<<fm.bit) != 0
+	case "INT16":
+		return int64(int16(words[0]))
+	case "UINT16":
+		return uint64(words[0])
+	case "INT32":
+		return int64(int32(binary.LittleEndian.Uint32(buf)))
+	case "UINT32":
+		return uint64(binary.LittleEndian.Uint32(buf))
+	case "FLOAT32":
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(buf)))
+	case "FLOAT64":
+		return math.Float64frombits(binary.LittleEndian.Uint64(buf))
+	case "STRING":
+		return strings.TrimRight(string(buf), "\x00")
+	}
+	return nil
+}
+
+func init() {
+	inputs.Add("mcprotocol", func() telegraf.Input {
+		return &MCProtocol{
+			Station:  0xFF,
+			ModuleIO: 0x03FF,
+			Timeout:  config.Duration(5 * time.Second),
+		}
+	})
+}
@@ -0,0 +1,456 @@
+package mcprotocol
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *MCProtocol
+		expected string
+	}{
+		{
+			name:     "no address",
+			plugin:   &MCProtocol{},
+			expected: "'address' must be specified",
+		},
+		{
+			name:     "no port",
+			plugin:   &MCProtocol{Address: "127.0.0.1"},
+			expected: `invalid 'address' "127.0.0.1"`,
+		},
+		{
+			name:     "invalid frame",
+			plugin:   &MCProtocol{Address: "127.0.0.1:5007", Frame: "1E"},
+			expected: `invalid 'frame' "1E"`,
+		},
+		{
+			name:     "no metrics",
+			plugin:   &MCProtocol{Address: "127.0.0.1:5007"},
+			expected: "no metric defined",
+		},
+		{
+			name:     "unknown device",
+			plugin:   newTestPlugin("127.0.0.1:5007", fieldDefinition{Name: "a", Address: "Q10"}),
+			expected: `unknown device in address "Q10"`,
+		},
+		{
+			name:     "invalid decimal number",
+			plugin:   newTestPlugin("127.0.0.1:5007", fieldDefinition{Name: "a", Address: "D1F"}),
+			expected: `invalid device number in address "D1F"`,
+		},
+		{
+			name:     "bit of bit device",
+			plugin:   newTestPlugin("127.0.0.1:5007", fieldDefinition{Name: "a", Address: "M10.1"}),
+			expected: `bit of bit device in address "M10.1"`,
+		},
+		{
+			name:     "bit without bit number",
+			plugin:   newTestPlugin("127.0.0.1:5007", fieldDefinition{Name: "a", Address: "D10", Type: "BOOL"}),
+			expected: `bit required in address "D10"`,
+		},
+		{
+			name:     "unaligned bit device word",
+			plugin:   newTestPlugin("127.0.0.1:5007", fieldDefinition{Name: "a", Address: "M10", Type: "UINT16"}),
+			expected: `address "M10" must be a multiple of 16`,
+		},
+		{
+			name:     "string without length",
+			plugin:   newTestPlugin("127.0.0.1:5007", fieldDefinition{Name: "a", Address: "D10", Type: "STRING"}),
+			expected: "'length' required for strings",
+		},
+		{
+			name: "duplicate field",
+			plugin: newTestPlugin("127.0.0.1:5007",
+				fieldDefinition{Name: "a", Address: "D10"},
+				fieldDefinition{Name: "a", Address: "D11"},
+			),
+			expected: `duplicate field "a"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Timeout = config.Duration(time.Second)
+			tt.plugin.Log = testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		device  string
+		start   uint32
+		bit     int
+		dtype   string
+	}{
+		{address: "D100", device: "D", start: 100, bit: -1, dtype: "INT16"},
+		{address: "d100.f", device: "D", start: 100, bit: 15, dtype: "BOOL"},
+		{address: "SD203", device: "SD", start: 203, bit: -1, dtype: "INT16"},
+		{address: "SB1F", device: "SB", start: 1, bit: 15, dtype: "BOOL"},
+		{address: "B1F", device: "B", start: 1, bit: 15, dtype: "BOOL"},
+		{address: "X1A", device: "X", start: 1, bit: 10, dtype: "BOOL"},
+		{address: "M35", device: "M", start: 2, bit: 3, dtype: "BOOL"},
+		{address: "ZR1A0", device: "ZR", start: 0x1A0, bit: -1, dtype: "INT16"},
+		{address: "W10", device: "W", start: 0x10, bit: -1, dtype: "INT16"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			fm, err := newFieldMapping(fieldDefinition{Name: "a", Address: tt.address})
+			require.NoError(t, err)
+			require.Equal(t, tt.device, fm.device.name)
+			require.Equal(t, tt.start, fm.start)
+			require.Equal(t, tt.bit, fm.bit)
+			require.Equal(t, tt.dtype, fm.dtype)
+		})
+	}
+}
+
+func TestBatches(t *testing.T) {
+	plugin := newTestPlugin("127.0.0.1:5007",
+		fieldDefinition{Name: "a", Address: "D100", Type: "FLOAT32"},
+		fieldDefinition{Name: "b", Address: "D150"},
+		fieldDefinition{Name: "c", Address: "D1000"},
+		fieldDefinition{Name: "d", Address: "M5"},
+		fieldDefinition{Name: "e", Address: "M20"},
+		fieldDefinition{Name: "f", Address: "D102", Type: "STRING", Length: 5},
+	)
+	plugin.Timeout = config.Duration(time.Second)
+	require.NoError(t, plugin.Init())
+
+	batches := make([]string, 0, len(plugin.batches))
+	for _, b := range plugin.batches {
+		batches = append(batches, fmt.Sprintf("%s%d+%d:%d", b.device.name, b.start, b.count, len(b.mappings)))
+	}
+	require.Equal(t, []string{"D100+51:3", "D1000+1:1", "M0+2:2"}, batches)
+}
+
+func TestGather(t *testing.T) {
+	tests := []struct {
+		transport string
+		frame     string
+		format    string
+		series    string
+	}{
+		{transport: "tcp", frame: "3E", format: "binary", series: "q"},
+		{transport: "tcp", frame: "4E", format: "binary", series: "q"},
+		{transport: "tcp", frame: "3E", format: "ascii", series: "q"},
+		{transport: "tcp", frame: "4E", format: "ascii", series: "iqr"},
+		{transport: "tcp", frame: "3E", format: "binary", series: "iqr"},
+		{transport: "udp", frame: "3E", format: "binary", series: "q"},
+		{transport: "udp", frame: "4E", format: "ascii", series: "q"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.transport+"_"+tt.frame+"_"+tt.format+"_"+tt.series, func(t *testing.T) {
+			plc := newFakePLC(t, tt.transport, tt.frame == "4E", tt.format == "ascii", tt.series == "iqr")
+			speed := math.Float32bits(1.5)
+			plc.set("D", 100, uint16(speed), uint16(speed>>16), 12345&0xFFFF, 0, 0x1C)
+			plc.set("D", 200, 'A'|'L'<<8, '-'|'1'<<8, '2')
+			plc.set("M", 0, 0x0400)
+			plc.set("X", 1, 0x0002)
+
+			plugin := newTestPlugin(plc.addr(),
+				fieldDefinition{Name: "speed", Address: "D100", Type: "FLOAT32"},
+				fieldDefinition{Name: "counter", Address: "D102", Type: "UINT16"},
+				fieldDefinition{Name: "alarm", Address: "D104.3"},
+				fieldDefinition{Name: "ready", Address: "D104.0"},
+				fieldDefinition{Name: "recipe", Address: "D200", Type: "STRING", Length: 6},
+				fieldDefinition{Name: "running", Address: "M10"},
+				fieldDefinition{Name: "sensor", Address: "X11"},
+			)
+			plugin.Transport = tt.transport
+			plugin.Frame = tt.frame
+			plugin.Format = tt.format
+			plugin.Series = tt.series
+			plugin.Timeout = config.Duration(time.Second)
+			plugin.Metrics[0].Tags = map[string]string{"machine": "press-1"}
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, plugin.Start(&acc))
+			defer plugin.Stop()
+			require.NoError(t, plugin.Gather(&acc))
+
+			expected := []telegraf.Metric{
+				metric.New(
+					"mcprotocol",
+					map[string]string{"machine": "press-1"},
+					map[string]interface{}{
+						"speed":   float64(1.5),
+						"counter": uint64(12345),
+						"alarm":   true,
+						"ready":   false,
+						"recipe":  "AL-12",
+						"running": true,
+						"sensor":  true,
+					},
+					time.Unix(0, 0),
+				),
+			}
+			testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+			require.Equal(t, 4, plc.requests())
+		})
+	}
+}
+
+func TestEndCode(t *testing.T) {
+	plc := newFakePLC(t, "tcp", false, false, false)
+	plugin := newTestPlugin(plc.addr(), fieldDefinition{Name: "a", Address: "D9000"})
+	plugin.Timeout = config.Duration(time.Second)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	require.ErrorContains(t, plugin.Gather(&acc), "reading 1 words from D9000 failed: end code 0xC056: device out of range")
+	require.Nil(t, plugin.client)
+}
+
+func newTestPlugin(address string, fields ...fieldDefinition) *MCProtocol {
+	return &MCProtocol{
+		Address:  address,
+		Station:  0xFF,
+		ModuleIO: 0x03FF,
+		Metrics:  []metricDefinition{{Fields: fields}},
+		Log:      testutil.Logger{},
+	}
+}
+
+// fakePLC answers batch reads from words indexed by device name and word
+// offset, i.e. the device number divided by 16 for bit devices
+type fakePLC struct {
+	addrFunc func() string
+	frame4E  bool
+	ascii    bool
+	rx       bool
+	memory   map[string]map[uint32]uint16
+	count    int
+	sync.Mutex
+}
+
+func newFakePLC(t *testing.T, transport string, frame4E, ascii, rx bool) *fakePLC {
+	p := &fakePLC{
+		frame4E: frame4E,
+		ascii:   ascii,
+		rx:      rx,
+		memory:  make(map[string]map[uint32]uint16),
+	}
+
+	if transport == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		p.addrFunc = conn.LocalAddr().String
+		go func() {
+			buf := make([]byte, 8192)
+			for {
+				n, addr, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				if _, err := conn.WriteTo(p.handle(buf[:n]), addr); err != nil {
+					return
+				}
+			}
+		}()
+		return p
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	p.addrFunc = listener.Addr().String
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+	return p
+}
+
+func (p *fakePLC) addr() string {
+	return p.addrFunc()
+}
+
+func (p *fakePLC) set(device string, offset uint32, words ...uint16) {
+	p.Lock()
+	defer p.Unlock()
+	if p.memory[device] == nil {
+		p.memory[device] = make(map[uint32]uint16)
+	}
+	for i, w := range words {
+		p.memory[device][offset+uint32(i)] = w
+	}
+}
+
+func (p *fakePLC) requests() int {
+	p.Lock()
+	defer p.Unlock()
+	return p.count
+}
+
+func (p *fakePLC) serve(conn net.Conn) {
+	defer conn.Close()
+	// Read the header up to and including the request data length
+	headerLength := 9
+	if p.frame4E {
+		headerLength += 4
+	}
+	if p.ascii {
+		headerLength *= 2
+	}
+	for {
+		header := make([]byte, headerLength)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		var length int
+		if p.ascii {
+			v, _ := strconv.ParseUint(string(header[headerLength-4:]), 16, 16)
+			length = int(v)
+		} else {
+			length = int(binary.LittleEndian.Uint16(header[headerLength-2:]))
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(conn, body); err != nil {
+			return
+		}
+		if _, err := conn.Write(p.handle(append(header, body...))); err != nil {
+			return
+		}
+	}
+}
+
+// handle decodes the batch read request and returns the response
+func (p *fakePLC) handle(req []byte) []byte {
+	p.Lock()
+	defer p.Unlock()
+	p.count++
+
+	var serial string
+	var code string
+	var number, count uint64
+	if p.ascii {
+		pos := 4
+		if p.frame4E {
+			serial = string(req[4:8])
+			pos = 12
+		}
+		data := string(req[pos+26:])
+		codeLength, digits := 2, 6
+		if p.rx {
+			codeLength, digits = 4, 8
+		}
+		code = data[:codeLength]
+		dev := findDevice(code, true, p.rx)
+		base := 10
+		if dev.hex {
+			base = 16
+		}
+		number, _ = strconv.ParseUint(data[codeLength:codeLength+digits], base, 32)
+		count, _ = strconv.ParseUint(data[codeLength+digits:codeLength+digits+4], 16, 16)
+		code = dev.name
+	} else {
+		pos := 2
+		if p.frame4E {
+			serial = fmt.Sprintf("%04X", binary.LittleEndian.Uint16(req[2:]))
+			pos = 6
+		}
+		data := req[pos+13:]
+		if p.rx {
+			number = uint64(binary.LittleEndian.Uint32(data))
+			code = findDevice(string(data[4:6]), false, true).name
+			count = uint64(binary.LittleEndian.Uint16(data[6:]))
+		} else {
+			number = uint64(data[0]) | uint64(data[1])<<8 | uint64(data[2])<<16
+			code = findDevice(string(data[3:4]), false, false).name
+			count = uint64(binary.LittleEndian.Uint16(data[4:]))
+		}
+	}
+
+	var endCode uint16
+	words := make([]uint16, 0, count)
+	offset := uint32(number)
+	if devices[code].bit {
+		offset /= 16
+	}
+	if offset > 1000 {
+		endCode = 0xC056
+	}
+	for i := range uint32(count) {
+		words = append(words, p.memory[code][offset+i])
+	}
+
+	if p.ascii {
+		subheader := "D000"
+		if p.frame4E {
+			subheader = "D400" + serial + "0000"
+		}
+		data := fmt.Sprintf("%04X", endCode)
+		if endCode == 0 {
+			buf := make([]byte, 0, 2*len(words))
+			for _, w := range words {
+				buf = binary.BigEndian.AppendUint16(buf, w)
+			}
+			data += fmt.Sprintf("%X", buf)
+		}
+		return []byte(fmt.Sprintf("%s00FF03FF00%04X%s", subheader, len(data), data))
+	}
+
+	resp := []byte{0xD0, 0x00}
+	if p.frame4E {
+		s, _ := hex.DecodeString(serial)
+		resp = []byte{0xD4, 0x00, s[1], s[0], 0x00, 0x00}
+	}
+	data := binary.LittleEndian.AppendUint16(nil, endCode)
+	if endCode == 0 {
+		for _, w := range words {
+			data = binary.LittleEndian.AppendUint16(data, w)
+		}
+	}
+	resp = append(resp, 0x00, 0xFF, 0xFF, 0x03, 0x00)
+	resp = binary.LittleEndian.AppendUint16(resp, uint16(len(data)))
+	return append(resp, data...)
+}
+
+func findDevice(code string, ascii, rx bool) *device {
+	for _, dev := range devices {
+		switch {
+		case ascii && rx && code == strings.ReplaceAll(fmt.Sprintf("%-4s", dev.name), " ", "*"):
+			return dev
+		case ascii && !rx && (code == dev.name || code == dev.name+"*"):
+			return dev
+		case !ascii && rx && binary.LittleEndian.Uint16([]byte(code)) == dev.codeRX:
+			return dev
+		case !ascii && !rx && code[0] == dev.code:
+			return dev
+		}
+	}
+	panic("unknown device code " + code)
+}
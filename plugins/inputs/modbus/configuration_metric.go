@@ -14,14 +14,16 @@ import (
 var sampleConfigPartPerMetric string
 
 type metricFieldDefinition struct {
-	RegisterType string  `toml:"register"`
-	Address      uint16  `toml:"address"`
-	Length       uint16  `toml:"length"`
-	Name         string  `toml:"name"`
-	InputType    string  `toml:"type"`
-	Scale        float64 `toml:"scale"`
-	OutputType   string  `toml:"output"`
-	Bit          uint8   `toml:"bit"`
+	RegisterType string   `toml:"register"`
+	Address      uint16   `toml:"address"`
+	Length       uint16   `toml:"length"`
+	Name         string   `toml:"name"`
+	InputType    string   `toml:"type"`
+	Scale        float64  `toml:"scale"`
+	OutputType   string   `toml:"output"`
+	Bit          uint8    `toml:"bit"`
+	DeadbandType string   `toml:"deadband_type"`
+	Deadband     *float64 `toml:"deadband"`
 }
 
 type metricDefinition struct {
@@ -273,11 +275,13 @@ func (c *configurationPerMetric) newField(def metricFieldDefinition, mdef metric
 
 	// Initialize the field
 	f := field{
-		measurement: mdef.Measurement,
-		name:        def.Name,
-		address:     def.Address,
-		length:      fieldLength,
-		tags:        mdef.Tags,
+		measurement:  mdef.Measurement,
+		name:         def.Name,
+		address:      def.Address,
+		length:       fieldLength,
+		tags:         mdef.Tags,
+		deadbandType: def.DeadbandType,
+		deadband:     def.Deadband,
 	}
 
 	// Handle type conversions for coil and discrete registers
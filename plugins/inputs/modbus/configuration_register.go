@@ -12,13 +12,15 @@ import (
 var sampleConfigPartPerRegister string
 
 type fieldDefinition struct {
-	Measurement string   `toml:"measurement"`
-	Name        string   `toml:"name"`
-	ByteOrder   string   `toml:"byte_order"`
-	DataType    string   `toml:"data_type"`
-	Scale       float64  `toml:"scale"`
-	Address     []uint16 `toml:"address"`
-	Bit         uint8    `toml:"bit"`
+	Measurement  string   `toml:"measurement"`
+	Name         string   `toml:"name"`
+	ByteOrder    string   `toml:"byte_order"`
+	DataType     string   `toml:"data_type"`
+	Scale        float64  `toml:"scale"`
+	Address      []uint16 `toml:"address"`
+	Bit          uint8    `toml:"bit"`
+	DeadbandType string   `toml:"deadband_type"`
+	Deadband     *float64 `toml:"deadband"`
 }
 
 type configurationOriginal struct {
@@ -143,10 +145,12 @@ func (c *configurationOriginal) newFieldFromDefinition(def fieldDefinition, type
 
 	// Initialize the field
 	f := field{
-		measurement: def.Measurement,
-		name:        def.Name,
-		address:     def.Address[0],
-		length:      uint16(len(def.Address)),
+		measurement:  def.Measurement,
+		name:         def.Name,
+		address:      def.Address[0],
+		length:       uint16(len(def.Address)),
+		deadbandType: def.DeadbandType,
+		deadband:     def.Deadband,
 	}
 
 	// Handle coil and discrete registers which do have a limited datatype set
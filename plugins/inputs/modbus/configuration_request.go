@@ -15,15 +15,17 @@ import (
 var sampleConfigPartPerRequest string
 
 type requestFieldDefinition struct {
-	Address     uint16  `toml:"address"`
-	Name        string  `toml:"name"`
-	InputType   string  `toml:"type"`
-	Length      uint16  `toml:"length"`
-	Scale       float64 `toml:"scale"`
-	OutputType  string  `toml:"output"`
-	Measurement string  `toml:"measurement"`
-	Omit        bool    `toml:"omit"`
-	Bit         uint8   `toml:"bit"`
+	Address      uint16   `toml:"address"`
+	Name         string   `toml:"name"`
+	InputType    string   `toml:"type"`
+	Length       uint16   `toml:"length"`
+	Scale        float64  `toml:"scale"`
+	OutputType   string   `toml:"output"`
+	Measurement  string   `toml:"measurement"`
+	Omit         bool     `toml:"omit"`
+	Bit          uint8    `toml:"bit"`
+	DeadbandType string   `toml:"deadband_type"`
+	Deadband     *float64 `toml:"deadband"`
 }
 
 type requestDefinition struct {
@@ -312,11 +314,13 @@ func (c *configurationPerRequest) newFieldFromDefinition(def requestFieldDefinit
 
 	// Initialize the field
 	f := field{
-		measurement: def.Measurement,
-		name:        def.Name,
-		address:     def.Address,
-		length:      fieldLength,
-		omit:        def.Omit,
+		measurement:  def.Measurement,
+		name:         def.Name,
+		address:      def.Address,
+		length:       fieldLength,
+		omit:         def.Omit,
+		deadbandType: def.DeadbandType,
+		deadband:     def.Deadband,
 	}
 
 	// Handle type conversions for coil and discrete registers
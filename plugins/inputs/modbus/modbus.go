@@ -50,6 +50,10 @@ type Modbus struct {
 	Workarounds            workarounds     `toml:"workarounds"`
 	ConfigurationType      string          `toml:"configuration_type"`
 	ExcludeRegisterTypeTag bool            `toml:"exclude_register_type_tag"`
+	ReportByException      bool            `toml:"report_by_exception"`
+	DeadbandType           string          `toml:"deadband_type"`
+	Deadband               float64         `toml:"deadband"`
+	ReportMaxAge           config.Duration `toml:"report_max_age"`
 	Log                    telegraf.Logger `toml:"-"`
 
 	// configuration type specific settings
@@ -109,6 +113,12 @@ type field struct {
 	converter   fieldConverterFunc
 	value       interface{}
 	tags        map[string]string
+
+	// Report-by-exception settings and state
+	deadbandType string
+	deadband     *float64
+	reported     interface{}
+	reportedAt   time.Time
 }
 
 func (m *Modbus) SampleConfig() string {
@@ -169,6 +179,10 @@ func (m *Modbus) Init() error {
 	}
 	m.requests = r
 
+	if err := m.initReportByException(); err != nil {
+		return fmt.Errorf("invalid report-by-exception settings for device %q: %w", m.Name, err)
+	}
+
 	// Setup client
 	if err := m.initClient(); err != nil {
 		return fmt.Errorf("initializing client failed for controller %q: %w", m.Controller, err)
@@ -251,22 +265,22 @@ func (m *Modbus) Gather(acc telegraf.Accumulator) error {
 		if !m.ExcludeRegisterTypeTag {
 			tags["type"] = cCoils
 		}
-		collectFields(grouper, timestamp, tags, requests.coil)
+		m.collectFields(grouper, timestamp, tags, requests.coil)
 
 		if !m.ExcludeRegisterTypeTag {
 			tags["type"] = cDiscreteInputs
 		}
-		collectFields(grouper, timestamp, tags, requests.discrete)
+		m.collectFields(grouper, timestamp, tags, requests.discrete)
 
 		if !m.ExcludeRegisterTypeTag {
 			tags["type"] = cHoldingRegisters
 		}
-		collectFields(grouper, timestamp, tags, requests.holding)
+		m.collectFields(grouper, timestamp, tags, requests.holding)
 
 		if !m.ExcludeRegisterTypeTag {
 			tags["type"] = cInputRegisters
 		}
-		collectFields(grouper, timestamp, tags, requests.input)
+		m.collectFields(grouper, timestamp, tags, requests.input)
 
 		// Add the metrics grouped by series to the accumulator
 		for _, x := range grouper.Metrics() {
@@ -532,9 +546,15 @@ func (m *Modbus) gatherRequestsInput(requests []request) error {
 	return nil
 }
 
-func collectFields(grouper *metric.SeriesGrouper, timestamp time.Time, tags map[string]string, requests []request) {
+func (m *Modbus) collectFields(grouper *metric.SeriesGrouper, timestamp time.Time, tags map[string]string, requests []request) {
 	for _, request := range requests {
-		for _, field := range request.fields {
+		for i := range request.fields {
+			field := &request.fields[i]
+			// Skip fields without relevant changes if reporting by exception
+			if m.ReportByException && !field.reportable(timestamp, time.Duration(m.ReportMaxAge)) {
+				continue
+			}
+
 			// Collect tags from global and per-request
 			ftags := make(map[string]string, len(tags)+len(field.tags))
 			for k, v := range tags {
@@ -147,33 +147,19 @@ func TestControllers(t *testing.T) {
 }
 
 func TestRetrySuccessful(t *testing.T) {
-	retries := 0
 	maxretries := 2
 	value := 1
 
-	serv := mbserver.NewServer()
-	require.NoError(t, serv.ListenTCP("localhost:1502"))
-	defer serv.Close()
-
 	// Make read on coil-registers fail for some trials by making the device to appear busy
-	serv.RegisterFunctionHandler(1,
-		func(*mbserver.Server, mbserver.Framer) ([]byte, *mbserver.Exception) {
-			data := make([]byte, 2)
-			data[0] = byte(1)
-			data[1] = byte(value)
-
-			except := &mbserver.SlaveDeviceBusy
-			if retries >= maxretries {
-				except = &mbserver.Success
-			}
-			retries++
-
-			return data, except
-		})
+	sim := testutil.NewModbusServer(1)
+	require.NoError(t, sim.Start())
+	defer sim.Close()
+	sim.SetCoils(1, 0, value == 1)
+	sim.InjectFault(testutil.FaultError, maxretries)
 
 	modbus := Modbus{
 		Name:       "TestRetry",
-		Controller: "tcp://localhost:1502",
+		Controller: "tcp://" + sim.Addr(),
 		Retries:    maxretries,
 		Log:        testutil.Logger{Quiet: true},
 	}
@@ -205,28 +191,21 @@ func TestRetrySuccessful(t *testing.T) {
 	acc.Wait(len(expected))
 
 	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+	require.Equal(t, maxretries+1, sim.Requests())
 }
 
 func TestRetryFailExhausted(t *testing.T) {
 	maxretries := 2
 
-	serv := mbserver.NewServer()
-	require.NoError(t, serv.ListenTCP("localhost:1502"))
-	defer serv.Close()
-
 	// Make the read on coils fail with busy
-	serv.RegisterFunctionHandler(1,
-		func(*mbserver.Server, mbserver.Framer) ([]byte, *mbserver.Exception) {
-			data := make([]byte, 2)
-			data[0] = byte(1)
-			data[1] = byte(0)
-
-			return data, &mbserver.SlaveDeviceBusy
-		})
+	sim := testutil.NewModbusServer(1)
+	require.NoError(t, sim.Start())
+	defer sim.Close()
+	sim.InjectFault(testutil.FaultError, 0)
 
 	modbus := Modbus{
 		Name:       "TestRetryFailExhausted",
-		Controller: "tcp://localhost:1502",
+		Controller: "tcp://" + sim.Addr(),
 		Retries:    maxretries,
 		Log:        testutil.Logger{Quiet: true},
 	}
@@ -244,7 +223,8 @@ func TestRetryFailExhausted(t *testing.T) {
 
 	require.NoError(t, modbus.Gather(&acc))
 	require.Len(t, acc.Errors, 1)
-	require.ErrorContains(t, acc.FirstError(), `slave 1 on controller "tcp://localhost:1502": modbus: exception '6' (server device busy)`)
+	require.ErrorContains(t, acc.FirstError(), `slave 1 on controller "tcp://`+sim.Addr()+`": modbus: exception '6' (server device busy)`)
+	require.Equal(t, maxretries+1, sim.Requests())
 }
 
 func TestRetryFailIllegal(t *testing.T) {
@@ -742,3 +722,132 @@ func TestWorkaroundsStringRegisterLocationInvalid(t *testing.T) {
 	}
 	require.ErrorContains(t, plugin.Init(), `invalid 'string_register_location'`)
 }
+
+func TestReportByException(t *testing.T) {
+	sim := testutil.NewModbusServer(1)
+	require.NoError(t, sim.Start())
+	defer sim.Close()
+	sim.SetHoldingRegisters(1, 0, 100, 100)
+
+	plugin := Modbus{
+		Name:              "TestReportByException",
+		Controller:        "tcp://" + sim.Addr(),
+		ReportByException: true,
+		Deadband:          5,
+		Log:               testutil.Logger{},
+	}
+	plugin.SlaveID = 1
+	percent := 10.0
+	plugin.HoldingRegisters = []fieldDefinition{
+		{ByteOrder: "AB", DataType: "INT16", Name: "absolute", Address: []uint16{0}, Scale: 1.0},
+		{ByteOrder: "AB", DataType: "INT16", Name: "percent", Address: []uint16{1}, Scale: 1.0, DeadbandType: "percent", Deadband: &percent},
+	}
+	require.NoError(t, plugin.Init())
+
+	tags := map[string]string{
+		"type":     cHoldingRegisters,
+		"slave_id": "1",
+		"name":     plugin.Name,
+	}
+	gather := func(absolute, percent uint16) []telegraf.Metric {
+		sim.SetHoldingRegisters(1, 0, absolute, percent)
+		var acc testutil.Accumulator
+		require.NoError(t, plugin.Gather(&acc))
+		return acc.GetTelegrafMetrics()
+	}
+
+	// The first values are always reported
+	expected := []telegraf.Metric{
+		metric.New("modbus", tags, map[string]interface{}{"absolute": int64(100), "percent": int64(100)}, time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, gather(100, 100), testutil.IgnoreTime())
+
+	// Changes within the deadband are suppressed
+	require.Empty(t, gather(105, 110))
+
+	// Changes beyond the deadband of the last reported value are reported
+	expected = []telegraf.Metric{
+		metric.New("modbus", tags, map[string]interface{}{"absolute": int64(106)}, time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, gather(106, 109), testutil.IgnoreTime())
+
+	expected = []telegraf.Metric{
+		metric.New("modbus", tags, map[string]interface{}{"percent": int64(89)}, time.Unix(0, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, gather(102, 89), testutil.IgnoreTime())
+}
+
+func TestReportByExceptionMaxAge(t *testing.T) {
+	deadband := 1.0
+	f := &field{deadbandType: "absolute", deadband: &deadband}
+	start := time.Unix(1700000000, 0)
+
+	f.value = int64(10)
+	require.True(t, f.reportable(start, time.Minute))
+	require.False(t, f.reportable(start.Add(30*time.Second), time.Minute))
+	require.True(t, f.reportable(start.Add(time.Minute), time.Minute))
+	require.False(t, f.reportable(start.Add(90*time.Second), time.Minute))
+
+	// Without maximum age unchanged values are never reported again
+	require.False(t, f.reportable(start.Add(time.Hour), 0))
+
+	// Non-numeric values are reported on any change
+	s := &field{deadbandType: "absolute", deadband: &deadband, value: "abc"}
+	require.True(t, s.reportable(start, 0))
+	require.False(t, s.reportable(start, 0))
+	s.value = "abd"
+	require.True(t, s.reportable(start, 0))
+}
+
+func TestReportByExceptionInitFail(t *testing.T) {
+	negative := -1.0
+	tests := []struct {
+		name     string
+		enabled  bool
+		dbType   string
+		deadband *float64
+		expected string
+	}{
+		{
+			name:     "deadband without option",
+			deadband: &negative,
+			expected: "requires 'report_by_exception'",
+		},
+		{
+			name:     "invalid type",
+			enabled:  true,
+			dbType:   "relative",
+			expected: "invalid deadband type",
+		},
+		{
+			name:     "negative deadband",
+			enabled:  true,
+			deadband: &negative,
+			expected: "must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := Modbus{
+				Name:              "TestReportByException",
+				Controller:        "tcp://localhost:502",
+				ReportByException: tt.enabled,
+				Log:               testutil.Logger{},
+			}
+			plugin.SlaveID = 1
+			plugin.HoldingRegisters = []fieldDefinition{
+				{
+					ByteOrder:    "AB",
+					DataType:     "INT16",
+					Name:         "value",
+					Address:      []uint16{0},
+					Scale:        1.0,
+					DeadbandType: tt.dbType,
+					Deadband:     tt.deadband,
+				},
+			}
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
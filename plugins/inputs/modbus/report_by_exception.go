@@ -0,0 +1,130 @@
+package modbus
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// initReportByException checks the deadband settings and applies the
+// defaults to all fields without their own settings
+func (m *Modbus) initReportByException() error {
+	if !m.ReportByException {
+		for _, requests := range m.requests {
+			for _, f := range requests.fields() {
+				if f.deadband != nil || f.deadbandType != "" {
+					return fmt.Errorf("deadband of field %q requires 'report_by_exception'", f.name)
+				}
+			}
+		}
+		return nil
+	}
+
+	switch m.DeadbandType {
+	case "":
+		m.DeadbandType = "absolute"
+	case "absolute", "percent":
+	default:
+		return fmt.Errorf("invalid 'deadband_type' %q", m.DeadbandType)
+	}
+	if m.Deadband < 0 {
+		return errors.New("'deadband' must not be negative")
+	}
+	if m.ReportMaxAge < 0 {
+		return errors.New("'report_max_age' must not be negative")
+	}
+
+	for _, requests := range m.requests {
+		for _, f := range requests.fields() {
+			switch f.deadbandType {
+			case "":
+				f.deadbandType = m.DeadbandType
+			case "absolute", "percent":
+			default:
+				return fmt.Errorf("invalid deadband type %q for field %q", f.deadbandType, f.name)
+			}
+			if f.deadband == nil {
+				f.deadband = &m.Deadband
+			} else if *f.deadband < 0 {
+				return fmt.Errorf("deadband of field %q must not be negative", f.name)
+			}
+		}
+	}
+	return nil
+}
+
+// fields returns references to the fields of all requests in the set
+func (r requestSet) fields() []*field {
+	var fields []*field
+	for _, requests := range [][]request{r.coil, r.discrete, r.holding, r.input} {
+		for _, rq := range requests {
+			for i := range rq.fields {
+				fields = append(fields, &rq.fields[i])
+			}
+		}
+	}
+	return fields
+}
+
+// reportable checks if the current value of the field has to be reported,
+// i.e. if it changed beyond the deadband since the last reported value or
+// if the last report is older than the given maximum age. The reported
+// state is updated if the field is reportable.
+func (f *field) reportable(timestamp time.Time, maxAge time.Duration) bool {
+	report := f.reportedAt.IsZero() || (maxAge > 0 && timestamp.Sub(f.reportedAt) >= maxAge)
+	if !report {
+		report = f.exceedsDeadband()
+	}
+	if report {
+		f.reported = f.value
+		f.reportedAt = timestamp
+	}
+	return report
+}
+
+func (f *field) exceedsDeadband() bool {
+	current, ok := toFloat(f.value)
+	if !ok {
+		return f.value != f.reported
+	}
+	last, ok := toFloat(f.reported)
+	if !ok {
+		return true
+	}
+
+	threshold := *f.deadband
+	if f.deadbandType == "percent" {
+		threshold = *f.deadband / 100.0 * math.Abs(last)
+	}
+	if threshold == 0 {
+		return current != last
+	}
+	return math.Abs(current-last) > threshold
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case int8:
+		return float64(x), true
+	case int16:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint8:
+		return float64(x), true
+	case uint16:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	}
+	return 0, false
+}
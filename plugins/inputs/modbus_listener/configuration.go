@@ -0,0 +1,186 @@
+package modbus_listener
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+type fieldDefinition struct {
+	RegisterType string  `toml:"register"`
+	Address      uint16  `toml:"address"`
+	Name         string  `toml:"name"`
+	InputType    string  `toml:"type"`
+	Scale        float64 `toml:"scale"`
+}
+
+type metricDefinition struct {
+	SlaveID     byte              `toml:"slave_id"`
+	ByteOrder   string            `toml:"byte_order"`
+	Measurement string            `toml:"measurement"`
+	Fields      []fieldDefinition `toml:"fields"`
+	Tags        map[string]string `toml:"tags"`
+}
+
+type fieldConverterFunc func(registers []uint16) interface{}
+
+// field is a field mapped to the coils or the holding registers of a slave
+type field struct {
+	metric    *metricDefinition
+	name      string
+	coil      bool
+	address   uint16
+	length    uint16
+	converter fieldConverterFunc
+}
+
+// covered checks if a write of the given number of registers or coils
+// starting at the address covers all registers of the field
+func (f *field) covered(coil bool, address, quantity uint16) bool {
+	if f.coil != coil {
+		return false
+	}
+	start, end := uint32(address), uint32(address)+uint32(quantity)
+	return uint32(f.address) >= start && uint32(f.address)+uint32(f.length) <= end
+}
+
+func (def *metricDefinition) check() error {
+	switch def.ByteOrder {
+	case "":
+		def.ByteOrder = "ABCD"
+	case "ABCD", "DCBA", "BADC", "CDAB":
+	default:
+		return fmt.Errorf("unknown byte-order %q", def.ByteOrder)
+	}
+
+	if def.Measurement == "" {
+		def.Measurement = "modbus"
+	}
+	if len(def.Fields) == 0 {
+		return errors.New("no fields defined")
+	}
+
+	seen := make(map[string]bool, len(def.Fields))
+	for _, f := range def.Fields {
+		if f.Name == "" {
+			return errors.New("empty field name")
+		}
+		if seen[f.Name] {
+			return fmt.Errorf("duplicate field name %q", f.Name)
+		}
+		seen[f.Name] = true
+	}
+	return nil
+}
+
+// fields returns the fields of the metric definition with their converters
+func (def *metricDefinition) fields() ([]*field, error) {
+	fields := make([]*field, 0, len(def.Fields))
+	for _, fdef := range def.Fields {
+		f := &field{metric: def, name: fdef.Name, address: fdef.Address}
+		switch fdef.RegisterType {
+		case "coil":
+			f.coil = true
+			f.length = 1
+			fields = append(fields, f)
+			continue
+		case "", "holding":
+		default:
+			return nil, fmt.Errorf("invalid register type %q for field %q", fdef.RegisterType, fdef.Name)
+		}
+
+		length, err := registerLength(fdef.InputType)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", fdef.Name, err)
+		}
+		if uint32(fdef.Address)+uint32(length) > math.MaxUint16+1 {
+			return nil, fmt.Errorf("field %q exceeds the address range", fdef.Name)
+		}
+		f.length = length
+		f.converter = determineConverter(fdef.InputType, def.ByteOrder, fdef.Scale)
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func registerLength(inType string) (uint16, error) {
+	switch inType {
+	case "INT16", "UINT16":
+		return 1, nil
+	case "INT32", "UINT32", "FLOAT32":
+		return 2, nil
+	case "INT64", "UINT64", "FLOAT64":
+		return 4, nil
+	case "":
+		return 0, errors.New("missing type")
+	}
+	return 0, fmt.Errorf("invalid type %q", inType)
+}
+
+// determineConverter returns a converter decoding the registers in the given
+// byte order. Scaled values are returned as float, all others as the 64-bit
+// variant of the input type.
+func determineConverter(inType, byteOrder string, scale float64) fieldConverterFunc {
+	var decode func([]byte) interface{}
+	switch inType {
+	case "INT16":
+		decode = func(b []byte) interface{} { return int64(int16(binary.BigEndian.Uint16(b))) }
+	case "UINT16":
+		decode = func(b []byte) interface{} { return uint64(binary.BigEndian.Uint16(b)) }
+	case "INT32":
+		decode = func(b []byte) interface{} { return int64(int32(binary.BigEndian.Uint32(b))) }
+	case "UINT32":
+		decode = func(b []byte) interface{} { return uint64(binary.BigEndian.Uint32(b)) }
+	case "INT64":
+		decode = func(b []byte) interface{} { return int64(binary.BigEndian.Uint64(b)) }
+	case "UINT64":
+		decode = func(b []byte) interface{} { return binary.BigEndian.Uint64(b) }
+	case "FLOAT32":
+		decode = func(b []byte) interface{} { return float64(math.Float32frombits(binary.BigEndian.Uint32(b))) }
+	case "FLOAT64":
+		decode = func(b []byte) interface{} { return math.Float64frombits(binary.BigEndian.Uint64(b)) }
+	}
+
+	return func(registers []uint16) interface{} {
+		v := decode(normalize(registers, byteOrder))
+		if scale == 0.0 {
+			return v
+		}
+		switch x := v.(type) {
+		case int64:
+			return float64(x) * scale
+		case uint64:
+			return float64(x) * scale
+		case float64:
+			return x * scale
+		}
+		return v
+	}
+}
+
+// normalize converts the registers to a big-endian byte sequence
+func normalize(registers []uint16, byteOrder string) []byte {
+	buf := make([]byte, 0, 2*len(registers))
+	switch byteOrder {
+	case "DCBA":
+		for _, r := range registers {
+			buf = binary.BigEndian.AppendUint16(buf, r)
+		}
+		slices.Reverse(buf)
+	case "BADC":
+		for _, r := range registers {
+			buf = binary.LittleEndian.AppendUint16(buf, r)
+		}
+	case "CDAB":
+		for _, r := range slices.Backward(registers) {
+			buf = binary.BigEndian.AppendUint16(buf, r)
+		}
+	default:
+		for _, r := range registers {
+			buf = binary.BigEndian.AppendUint16(buf, r)
+		}
+	}
+	return buf
+}
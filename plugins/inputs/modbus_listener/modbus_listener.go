@@ -0,0 +1,351 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package modbus_listener
+
+import (
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/grid-x/serial"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Time of silence after which an incomplete RTU frame is discarded
+const rtuFrameTimeout = 100 * time.Millisecond
+
+type ModbusListener struct {
+	ServiceAddress string             `toml:"service_address"`
+	BaudRate       int                `toml:"baud_rate"`
+	DataBits       int                `toml:"data_bits"`
+	Parity         string             `toml:"parity"`
+	StopBits       int                `toml:"stop_bits"`
+	Metrics        []metricDefinition `toml:"metric"`
+	Log            telegraf.Logger    `toml:"-"`
+
+	openPort func(*serial.Config) (io.ReadWriteCloser, error)
+
+	acc      telegraf.Accumulator
+	listener net.Listener
+	port     io.ReadWriteCloser
+	conns    map[net.Conn]bool
+	slaves   map[byte]*slave
+	wg       sync.WaitGroup
+	sync.Mutex
+}
+
+func (*ModbusListener) SampleConfig() string {
+	return sampleConfig
+}
+
+func (m *ModbusListener) Init() error {
+	if m.ServiceAddress == "" {
+		return errors.New("missing 'service_address'")
+	}
+	if len(m.Metrics) == 0 {
+		return errors.New("no metrics defined")
+	}
+
+	m.slaves = make(map[byte]*slave)
+	for i := range m.Metrics {
+		def := &m.Metrics[i]
+		if def.SlaveID == 0 || def.SlaveID > 247 {
+			return fmt.Errorf("invalid slave ID %d in metric %d", def.SlaveID, i+1)
+		}
+		if err := def.check(); err != nil {
+			return fmt.Errorf("metric %d: %w", i+1, err)
+		}
+		fields, err := def.fields()
+		if err != nil {
+			return fmt.Errorf("metric %d: %w", i+1, err)
+		}
+
+		s, found := m.slaves[def.SlaveID]
+		if !found {
+			s = newSlave()
+			m.slaves[def.SlaveID] = s
+		}
+		s.fields = append(s.fields, fields...)
+	}
+
+	if m.openPort == nil {
+		m.openPort = func(cfg *serial.Config) (io.ReadWriteCloser, error) {
+			return serial.Open(cfg)
+		}
+	}
+
+	return nil
+}
+
+func (m *ModbusListener) Start(acc telegraf.Accumulator) error {
+	m.acc = acc
+
+	u, err := url.Parse(m.ServiceAddress)
+	if err != nil {
+		return fmt.Errorf("parsing service address failed: %w", err)
+	}
+
+	switch u.Scheme {
+	case "tcp":
+		m.listener, err = net.Listen("tcp", u.Host)
+		if err != nil {
+			return err
+		}
+		m.conns = make(map[net.Conn]bool)
+		m.Log.Infof("Listening on %s", m.listener.Addr())
+
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.listenTCP()
+		}()
+	case "", "file":
+		path := filepath.Join(u.Host, u.Path)
+		if path == "" {
+			return fmt.Errorf("invalid path for service address %q", m.ServiceAddress)
+		}
+		m.port, err = m.openPort(&serial.Config{
+			Address:  path,
+			BaudRate: m.BaudRate,
+			DataBits: m.DataBits,
+			Parity:   m.Parity,
+			StopBits: m.StopBits,
+			Timeout:  rtuFrameTimeout,
+		})
+		if err != nil {
+			return fmt.Errorf("opening serial port failed: %w", err)
+		}
+		m.Log.Infof("Listening on serial port %s", path)
+
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.listenRTU()
+		}()
+	default:
+		return fmt.Errorf("invalid service address %q", m.ServiceAddress)
+	}
+
+	return nil
+}
+
+func (*ModbusListener) Gather(telegraf.Accumulator) error {
+	return nil
+}
+
+func (m *ModbusListener) Stop() {
+	if m.listener != nil {
+		m.listener.Close()
+	}
+	if m.port != nil {
+		m.port.Close()
+	}
+
+	m.Lock()
+	for conn := range m.conns {
+		conn.Close()
+	}
+	m.Unlock()
+
+	m.wg.Wait()
+}
+
+func (m *ModbusListener) listenTCP() {
+	for {
+		conn, err := m.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				m.acc.AddError(fmt.Errorf("accepting connection failed: %w", err))
+			}
+			return
+		}
+
+		m.Lock()
+		m.conns[conn] = true
+		m.Unlock()
+
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			m.serveTCP(conn)
+
+			m.Lock()
+			delete(m.conns, conn)
+			m.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// serveTCP handles the Modbus/TCP requests of a master connection until the
+// connection is closed
+func (m *ModbusListener) serveTCP(conn net.Conn) {
+	header := make([]byte, 7)
+	for {
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+
+		// Check the MBAP header containing the transaction ID, the protocol
+		// ID, the length of the following data and the unit ID
+		length := binary.BigEndian.Uint16(header[4:])
+		if binary.BigEndian.Uint16(header[2:]) != 0 || length < 2 || length > 254 {
+			m.Log.Errorf("Invalid frame received from %s, closing connection", conn.RemoteAddr())
+			return
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+
+		unit := header[6]
+		m.Lock()
+		s, found := m.slaves[unit]
+		var resp []byte
+		if found {
+			resp = m.process(unit, s, pdu)
+		} else {
+			resp = exception(pdu[0], exGatewayTargetNoResponse)
+		}
+		m.Unlock()
+
+		frame := make([]byte, 0, 7+len(resp))
+		frame = append(frame, header[:4]...)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(resp)+1))
+		frame = append(frame, unit)
+		frame = append(frame, resp...)
+		if _, err := conn.Write(frame); err != nil {
+			m.Log.Errorf("Sending response to %s failed: %v", conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+// listenRTU handles the requests received on the serial line. Frames are
+// delimited by their length determined from the function code or, for
+// unknown functions, by a period of silence on the line.
+func (m *ModbusListener) listenRTU() {
+	var buf []byte
+	var resync bool
+	chunk := make([]byte, 256)
+	for {
+		n, err := m.port.Read(chunk)
+		buf = append(buf, chunk[:n]...)
+		if err != nil {
+			if !errors.Is(err, serial.ErrTimeout) && !errors.Is(err, os.ErrDeadlineExceeded) {
+				if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrClosed) && !errors.Is(err, io.ErrClosedPipe) {
+					m.acc.AddError(fmt.Errorf("reading from serial port failed: %w", err))
+				}
+				return
+			}
+			// Try to process the data received before the silence as a
+			// frame of unknown length and discard it otherwise
+			if len(buf) >= 4 && crc16(buf) == 0 {
+				m.handleRTU(buf[:len(buf)-2])
+			} else if len(buf) > 0 {
+				m.Log.Debugf("Discarding incomplete frame of %d bytes", len(buf))
+			}
+			buf = buf[:0]
+			resync = false
+			continue
+		}
+
+		for len(buf) >= 2 {
+			length := rtuRequestLength(buf)
+			if length < 0 && resync {
+				buf = buf[1:]
+				continue
+			}
+			if length <= 0 || len(buf) < length {
+				break
+			}
+			// Resynchronize on checksum errors by skipping bytes until a
+			// valid frame is found
+			if crc16(buf[:length]) != 0 {
+				buf = buf[1:]
+				resync = true
+				continue
+			}
+			resync = false
+			m.handleRTU(buf[:length-2])
+			buf = buf[length:]
+		}
+	}
+}
+
+// handleRTU handles a RTU frame without checksum. Requests for other slaves
+// are ignored as they share the bus and broadcast requests are not answered.
+func (m *ModbusListener) handleRTU(frame []byte) {
+	unit, pdu := frame[0], frame[1:]
+
+	m.Lock()
+	var resp []byte
+	if unit == 0 {
+		for id, s := range m.slaves {
+			m.process(id, s, pdu)
+		}
+	} else if s, found := m.slaves[unit]; found {
+		resp = m.process(unit, s, pdu)
+	}
+	m.Unlock()
+
+	if resp == nil || unit == 0 {
+		return
+	}
+
+	out := make([]byte, 0, len(resp)+3)
+	out = append(out, unit)
+	out = append(out, resp...)
+	out = binary.LittleEndian.AppendUint16(out, crc16(out))
+	if _, err := m.port.Write(out); err != nil {
+		m.Log.Errorf("Sending response failed: %v", err)
+	}
+}
+
+// process handles the request for the given slave and adds the fields
+// covered by write requests as metrics. The caller must hold the lock.
+func (m *ModbusListener) process(unit byte, s *slave, pdu []byte) []byte {
+	resp, written := s.handle(pdu)
+	if len(resp) > 1 && resp[0]&0x80 != 0 {
+		m.Log.Debugf("Request of function %d for slave %d failed with exception %d", pdu[0], unit, resp[1])
+	}
+	if written == nil {
+		return resp
+	}
+
+	now := time.Now()
+	for def, fields := range s.values(written) {
+		tags := make(map[string]string, len(def.Tags)+1)
+		for k, v := range def.Tags {
+			tags[k] = v
+		}
+		tags["slave_id"] = strconv.Itoa(int(unit))
+		m.acc.AddFields(def.Measurement, fields, tags, now)
+	}
+	return resp
+}
+
+func init() {
+	inputs.Add("modbus_listener", func() telegraf.Input {
+		return &ModbusListener{
+			BaudRate: 9600,
+			DataBits: 8,
+			Parity:   "N",
+			StopBits: 1,
+		}
+	})
+}
@@ -0,0 +1,249 @@
+package modbus_listener
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	mb "github.com/grid-x/modbus"
+	"github.com/grid-x/serial"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		metrics  []metricDefinition
+		expected string
+	}{
+		{
+			name:     "no metrics",
+			expected: "no metrics defined",
+		},
+		{
+			name:     "invalid slave",
+			metrics:  []metricDefinition{{SlaveID: 0, Fields: []fieldDefinition{{Name: "a", InputType: "INT16"}}}},
+			expected: "invalid slave ID 0",
+		},
+		{
+			name:     "invalid byte order",
+			metrics:  []metricDefinition{{SlaveID: 1, ByteOrder: "AB", Fields: []fieldDefinition{{Name: "a", InputType: "INT16"}}}},
+			expected: "unknown byte-order",
+		},
+		{
+			name:     "no fields",
+			metrics:  []metricDefinition{{SlaveID: 1}},
+			expected: "no fields defined",
+		},
+		{
+			name: "duplicate field",
+			metrics: []metricDefinition{{SlaveID: 1, Fields: []fieldDefinition{
+				{Name: "a", InputType: "INT16"},
+				{Name: "a", Address: 1, InputType: "INT16"},
+			}}},
+			expected: "duplicate field name",
+		},
+		{
+			name:     "missing type",
+			metrics:  []metricDefinition{{SlaveID: 1, Fields: []fieldDefinition{{Name: "a"}}}},
+			expected: "missing type",
+		},
+		{
+			name:     "invalid register",
+			metrics:  []metricDefinition{{SlaveID: 1, Fields: []fieldDefinition{{Name: "a", RegisterType: "input", InputType: "INT16"}}}},
+			expected: "invalid register type",
+		},
+		{
+			name:     "address range",
+			metrics:  []metricDefinition{{SlaveID: 1, Fields: []fieldDefinition{{Name: "a", Address: 65535, InputType: "FLOAT32"}}}},
+			expected: "exceeds the address range",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &ModbusListener{ServiceAddress: "tcp://:502", Metrics: tt.metrics}
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestConverters(t *testing.T) {
+	tests := []struct {
+		inType    string
+		byteOrder string
+		scale     float64
+		registers []uint16
+		expected  interface{}
+	}{
+		{"INT16", "ABCD", 0, []uint16{0xFFFE}, int64(-2)},
+		{"INT16", "BADC", 0, []uint16{0xFEFF}, int64(-2)},
+		{"UINT16", "ABCD", 0.1, []uint16{1234}, 123.4},
+		{"INT32", "ABCD", 0, []uint16{0xFFFF, 0xFFFE}, int64(-2)},
+		{"INT32", "CDAB", 0, []uint16{0xFFFE, 0xFFFF}, int64(-2)},
+		{"UINT32", "DCBA", 0, []uint16{0x7856, 0x3412}, uint64(0x12345678)},
+		{"UINT32", "BADC", 0, []uint16{0x3412, 0x7856}, uint64(0x12345678)},
+		{"FLOAT32", "ABCD", 0, []uint16{0x4248, 0x0000}, float64(50)},
+		{"INT64", "ABCD", 0, []uint16{0xFFFF, 0xFFFF, 0xFFFF, 0xFFFE}, int64(-2)},
+		{"UINT64", "CDAB", 0, []uint16{0x0708, 0x0506, 0x0304, 0x0102}, uint64(0x0102030405060708)},
+		{"FLOAT64", "ABCD", 2, []uint16{0x4009, 0x21FB, 0x5444, 0x2D18}, 2 * math.Pi},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.inType+"_"+tt.byteOrder, func(t *testing.T) {
+			length, err := registerLength(tt.inType)
+			require.NoError(t, err)
+			require.Len(t, tt.registers, int(length))
+
+			converter := determineConverter(tt.inType, tt.byteOrder, tt.scale)
+			require.Equal(t, tt.expected, converter(tt.registers))
+		})
+	}
+}
+
+func TestTCP(t *testing.T) {
+	plugin := &ModbusListener{
+		ServiceAddress: "tcp://127.0.0.1:0",
+		Metrics: []metricDefinition{
+			{
+				SlaveID:     1,
+				Measurement: "machine",
+				Fields: []fieldDefinition{
+					{Name: "voltage", Address: 0, InputType: "INT16"},
+					{Name: "current", Address: 1, InputType: "INT32", Scale: 0.001},
+					{Name: "energy", Address: 3, InputType: "FLOAT32"},
+					{Name: "door_open", RegisterType: "coil", Address: 2},
+				},
+				Tags: map[string]string{"location": "hall"},
+			},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	handler := mb.NewTCPClientHandler(plugin.listener.Addr().String())
+	handler.SlaveID = 1
+	handler.Timeout = 3 * time.Second
+	require.NoError(t, handler.Connect())
+	defer handler.Close()
+	client := mb.NewClient(handler)
+
+	// Write all registers at once
+	values := []byte{0x00, 0xE6, 0x00, 0x00, 0x30, 0x39, 0x42, 0x48, 0x00, 0x00}
+	_, err := client.WriteMultipleRegisters(0, 5, values)
+	require.NoError(t, err)
+
+	// Only fields covered completely by the write produce values
+	_, err = client.WriteSingleRegister(4, 0x8000)
+	require.NoError(t, err)
+	_, err = client.WriteSingleRegister(0, 0xFF38)
+	require.NoError(t, err)
+	_, err = client.WriteSingleCoil(2, 0xFF00)
+	require.NoError(t, err)
+
+	// Masters can read back the written values
+	registers, err := client.ReadHoldingRegisters(0, 5)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xFF, 0x38, 0x00, 0x00, 0x30, 0x39, 0x42, 0x48, 0x80, 0x00}, registers)
+	coils, err := client.ReadCoils(0, 3)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x04}, coils)
+
+	// Unsupported functions and unknown slaves result in exceptions
+	_, err = client.ReadInputRegisters(0, 1)
+	var mbErr *mb.Error
+	require.ErrorAs(t, err, &mbErr)
+	require.Equal(t, exIllegalFunction, mbErr.ExceptionCode)
+
+	handler.SlaveID = 2
+	_, err = client.WriteSingleRegister(0, 1)
+	require.ErrorAs(t, err, &mbErr)
+	require.Equal(t, exGatewayTargetNoResponse, mbErr.ExceptionCode)
+
+	tags := map[string]string{"location": "hall", "slave_id": "1"}
+	expected := []telegraf.Metric{
+		metric.New("machine", tags, map[string]interface{}{
+			"voltage": int64(230),
+			"current": 12.345,
+			"energy":  float64(50),
+		}, time.Unix(0, 0)),
+		metric.New("machine", tags, map[string]interface{}{"voltage": int64(-200)}, time.Unix(0, 0)),
+		metric.New("machine", tags, map[string]interface{}{"door_open": true}, time.Unix(0, 0)),
+	}
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= uint64(len(expected))
+	}, 3*time.Second, 10*time.Millisecond)
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
+
+func rtuFrame(unit byte, pdu ...byte) []byte {
+	frame := append([]byte{unit}, pdu...)
+	return binary.LittleEndian.AppendUint16(frame, crc16(frame))
+}
+
+func TestRTU(t *testing.T) {
+	master, port := net.Pipe()
+	defer master.Close()
+
+	plugin := &ModbusListener{
+		ServiceAddress: "file:///dev/ttyUSB0",
+		Metrics: []metricDefinition{
+			{
+				SlaveID:   3,
+				ByteOrder: "CDAB",
+				Fields:    []fieldDefinition{{Name: "counter", Address: 10, InputType: "UINT32"}},
+			},
+		},
+		Log: testutil.Logger{},
+		openPort: func(cfg *serial.Config) (io.ReadWriteCloser, error) {
+			require.Equal(t, "/dev/ttyUSB0", cfg.Address)
+			return port, nil
+		},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	// Requests for other slaves on the bus and broadcasts are not answered,
+	// corrupted frames are skipped
+	corrupted := rtuFrame(3, fcWriteSingleRegister, 0x00, 0x0A, 0x00, 0x01)
+	corrupted[len(corrupted)-1] ^= 0xFF
+	_, err := master.Write(corrupted)
+	require.NoError(t, err)
+	_, err = master.Write(rtuFrame(4, fcWriteSingleRegister, 0x00, 0x0A, 0x00, 0x01))
+	require.NoError(t, err)
+	_, err = master.Write(rtuFrame(0, fcWriteMultipleRegisters, 0x00, 0x0A, 0x00, 0x02, 0x04, 0x00, 0x01, 0x00, 0x00))
+	require.NoError(t, err)
+
+	request := rtuFrame(3, fcWriteMultipleRegisters, 0x00, 0x0A, 0x00, 0x02, 0x04, 0x00, 0x02, 0x00, 0x01)
+	_, err = master.Write(request)
+	require.NoError(t, err)
+
+	response := make([]byte, 8)
+	require.NoError(t, master.SetReadDeadline(time.Now().Add(3*time.Second)))
+	_, err = io.ReadFull(master, response)
+	require.NoError(t, err)
+	require.Equal(t, rtuFrame(3, fcWriteMultipleRegisters, 0x00, 0x0A, 0x00, 0x02), response)
+
+	expected := []telegraf.Metric{
+		metric.New("modbus", map[string]string{"slave_id": "3"}, map[string]interface{}{"counter": uint64(1)}, time.Unix(0, 0)),
+		metric.New("modbus", map[string]string{"slave_id": "3"}, map[string]interface{}{"counter": uint64(0x00010002)}, time.Unix(0, 0)),
+	}
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= uint64(len(expected))
+	}, 3*time.Second, 10*time.Millisecond)
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
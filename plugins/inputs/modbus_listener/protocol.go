@@ -0,0 +1,206 @@
+package modbus_listener
+
+import (
+	"encoding/binary"
+)
+
+// Modbus function codes supported by the listener
+const (
+	fcReadCoils              byte = 0x01
+	fcReadHoldingRegisters   byte = 0x03
+	fcWriteSingleCoil        byte = 0x05
+	fcWriteSingleRegister    byte = 0x06
+	fcWriteMultipleCoils     byte = 0x0F
+	fcWriteMultipleRegisters byte = 0x10
+)
+
+// Modbus exception codes
+const (
+	exIllegalFunction         byte = 0x01
+	exIllegalDataAddress      byte = 0x02
+	exIllegalDataValue        byte = 0x03
+	exGatewayTargetNoResponse byte = 0x0B
+)
+
+// slave is the register image of a simulated slave device
+type slave struct {
+	coils   []bool
+	holding []uint16
+	fields  []*field
+}
+
+// span denotes the coils or registers modified by a write request
+type span struct {
+	coil     bool
+	address  uint16
+	quantity uint16
+}
+
+func newSlave() *slave {
+	return &slave{
+		coils:   make([]bool, 65536),
+		holding: make([]uint16, 65536),
+	}
+}
+
+func exception(function, code byte) []byte {
+	return []byte{function | 0x80, code}
+}
+
+// handle processes the request PDU and returns the response PDU as well as
+// the coils or registers modified by the request, if any
+func (s *slave) handle(pdu []byte) ([]byte, *span) {
+	if len(pdu) == 0 {
+		return nil, nil
+	}
+	function := pdu[0]
+	data := pdu[1:]
+
+	switch function {
+	case fcReadCoils, fcReadHoldingRegisters:
+		if len(data) != 4 {
+			return exception(function, exIllegalDataValue), nil
+		}
+		address := binary.BigEndian.Uint16(data[0:])
+		quantity := binary.BigEndian.Uint16(data[2:])
+		limit := uint16(2000)
+		if function == fcReadHoldingRegisters {
+			limit = 125
+		}
+		if quantity == 0 || quantity > limit {
+			return exception(function, exIllegalDataValue), nil
+		}
+		if uint32(address)+uint32(quantity) > 65536 {
+			return exception(function, exIllegalDataAddress), nil
+		}
+
+		if function == fcReadCoils {
+			buf := make([]byte, (quantity+7)/8)
+			for i := range quantity {
+				if s.coils[address+i] {
+					buf[i/8] |= 1 << (i % 8)
+				}
+			}
+			return append([]byte{function, byte(len(buf))}, buf...), nil
+		}
+		resp := []byte{function, byte(2 * quantity)}
+		for _, v := range s.holding[int(address) : int(address)+int(quantity)] {
+			resp = binary.BigEndian.AppendUint16(resp, v)
+		}
+		return resp, nil
+	case fcWriteSingleCoil:
+		if len(data) != 4 {
+			return exception(function, exIllegalDataValue), nil
+		}
+		address := binary.BigEndian.Uint16(data[0:])
+		switch binary.BigEndian.Uint16(data[2:]) {
+		case 0xFF00:
+			s.coils[address] = true
+		case 0x0000:
+			s.coils[address] = false
+		default:
+			return exception(function, exIllegalDataValue), nil
+		}
+		return pdu, &span{coil: true, address: address, quantity: 1}
+	case fcWriteSingleRegister:
+		if len(data) != 4 {
+			return exception(function, exIllegalDataValue), nil
+		}
+		address := binary.BigEndian.Uint16(data[0:])
+		s.holding[address] = binary.BigEndian.Uint16(data[2:])
+		return pdu, &span{address: address, quantity: 1}
+	case fcWriteMultipleCoils, fcWriteMultipleRegisters:
+		if len(data) < 5 {
+			return exception(function, exIllegalDataValue), nil
+		}
+		address := binary.BigEndian.Uint16(data[0:])
+		quantity := binary.BigEndian.Uint16(data[2:])
+		count := int(data[4])
+		values := data[5:]
+
+		expected, limit := int(quantity+7)/8, uint16(1968)
+		if function == fcWriteMultipleRegisters {
+			expected, limit = 2*int(quantity), 123
+		}
+		if quantity == 0 || quantity > limit || count != expected || len(values) != count {
+			return exception(function, exIllegalDataValue), nil
+		}
+		if uint32(address)+uint32(quantity) > 65536 {
+			return exception(function, exIllegalDataAddress), nil
+		}
+
+		if function == fcWriteMultipleCoils {
+			for i := range quantity {
+				s.coils[address+i] = values[i/8]&(1<<(i%8)) != 0
+			}
+		} else {
+			for i := range quantity {
+				s.holding[address+i] = binary.BigEndian.Uint16(values[2*i:])
+			}
+		}
+		resp := []byte{function}
+		resp = append(resp, data[:4]...)
+		return resp, &span{coil: function == fcWriteMultipleCoils, address: address, quantity: quantity}
+	}
+
+	return exception(function, exIllegalFunction), nil
+}
+
+// values returns the field values of all fields covered by the given span
+// grouped by metric definition
+func (s *slave) values(w *span) map[*metricDefinition]map[string]interface{} {
+	result := make(map[*metricDefinition]map[string]interface{})
+	for _, f := range s.fields {
+		if !f.covered(w.coil, w.address, w.quantity) {
+			continue
+		}
+
+		var value interface{}
+		if f.coil {
+			value = s.coils[f.address]
+		} else {
+			value = f.converter(s.holding[f.address : uint32(f.address)+uint32(f.length)])
+		}
+
+		if _, found := result[f.metric]; !found {
+			result[f.metric] = make(map[string]interface{})
+		}
+		result[f.metric][f.name] = value
+	}
+	return result
+}
+
+// rtuRequestLength returns the length of the RTU request frame including
+// the slave ID and CRC determined from the given frame start. Zero is
+// returned if more data is required and -1 for unknown functions.
+func rtuRequestLength(buf []byte) int {
+	if len(buf) < 2 {
+		return 0
+	}
+	switch buf[1] {
+	case fcReadCoils, 0x02, fcReadHoldingRegisters, 0x04, fcWriteSingleCoil, fcWriteSingleRegister:
+		return 8
+	case fcWriteMultipleCoils, fcWriteMultipleRegisters:
+		if len(buf) < 7 {
+			return 0
+		}
+		return 9 + int(buf[6])
+	}
+	return -1
+}
+
+// crc16 computes the Modbus RTU checksum
+func crc16(buf []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range buf {
+		crc ^= uint16(b)
+		for range 8 {
+			if crc&1 != 0 {
+				crc = (crc >> 1) ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
@@ -3,6 +3,7 @@ package mqtt_consumer
 import (
 	"fmt"
 	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -569,6 +570,126 @@ func TestTopicTag(t *testing.T) {
 	}
 }
 
+// ackMessage is a message recording acknowledgements
+type ackMessage struct {
+	message
+	acked atomic.Bool
+}
+
+func (m *ackMessage) Ack() {
+	m.acked.Store(true)
+}
+
+func TestPersistentSessionAckOnDelivery(t *testing.T) {
+	var handler mqtt.MessageHandler
+	fClient := &fakeClient{
+		connectF: func() mqtt.Token {
+			return &fakeToken{}
+		},
+		addRouteF: func(callback mqtt.MessageHandler) {
+			handler = callback
+		},
+		subscribeMultipleF: func() mqtt.Token {
+			return &fakeToken{}
+		},
+		disconnectF: func() {
+		},
+	}
+
+	plugin := newMQTTConsumer(func(*mqtt.ClientOptions) client {
+		return fClient
+	})
+	plugin.Log = testutil.Logger{}
+	plugin.Topics = []string{"telegraf"}
+	plugin.PersistentSession = true
+	plugin.ClientID = "telegraf-test"
+
+	parser := &influx.Parser{}
+	require.NoError(t, parser.Init())
+	plugin.SetParser(parser)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	msgs := make([]*ackMessage, 0, 3)
+	for range 3 {
+		msg := &ackMessage{message: message{topic: "telegraf"}}
+		handler(nil, msg)
+		msgs = append(msgs, msg)
+	}
+	require.Equal(t, 3, acc.NPending())
+
+	// Deliver the metrics out-of-order, only the messages of delivered
+	// metrics must be acknowledged
+	acc.HoldDeliveries()
+	acc.Deliver(testutil.DeliveryAccept, testutil.DeliveryReject, testutil.DeliveryDrop)
+	acc.ReleaseDeliveries(2, 1, 0)
+
+	ids := acc.TrackingIDs()
+	acc.RequireDeliveries(t,
+		testutil.Delivery{ID: ids[2], Delivered: true},
+		testutil.Delivery{ID: ids[1], Delivered: false},
+		testutil.Delivery{ID: ids[0], Delivered: true},
+	)
+	require.Eventually(t, func() bool {
+		plugin.messagesMutex.Lock()
+		defer plugin.messagesMutex.Unlock()
+		return len(plugin.messages) == 0
+	}, time.Second, 10*time.Millisecond)
+	require.True(t, msgs[0].acked.Load())
+	require.False(t, msgs[1].acked.Load())
+	require.True(t, msgs[2].acked.Load())
+}
+
+// recordingParser is a parser recording the topics of the parsed messages
+type recordingParser struct {
+	influx.Parser
+	topics []string
+}
+
+func (p *recordingParser) ParseWithTopic(topic string, buf []byte) ([]telegraf.Metric, error) {
+	p.topics = append(p.topics, topic)
+	return p.Parse(buf)
+}
+
+func TestTopicPassedToParser(t *testing.T) {
+	var handler mqtt.MessageHandler
+	fClient := &fakeClient{
+		connectF: func() mqtt.Token {
+			return &fakeToken{}
+		},
+		addRouteF: func(callback mqtt.MessageHandler) {
+			handler = callback
+		},
+		subscribeMultipleF: func() mqtt.Token {
+			return &fakeToken{}
+		},
+		disconnectF: func() {
+		},
+	}
+
+	plugin := newMQTTConsumer(func(*mqtt.ClientOptions) client {
+		return fClient
+	})
+	plugin.Log = testutil.Logger{}
+	plugin.Topics = []string{"spBv1.0/#"}
+
+	parser := &recordingParser{}
+	require.NoError(t, parser.Init())
+	plugin.SetParser(parser)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	handler(nil, &message{topic: "spBv1.0/plant/NDATA/line1"})
+	require.Equal(t, []string{"spBv1.0/plant/NDATA/line1"}, parser.topics)
+	require.Len(t, acc.GetTelegrafMetrics(), 1)
+}
+
 func TestAddRouteCalledForEachTopic(t *testing.T) {
 	fClient := &fakeClient{
 		connectF: func() mqtt.Token {
@@ -0,0 +1,287 @@
+package omron_fins
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Command codes
+const (
+	cmdMemoryAreaRead         = 0x0101
+	cmdMultipleMemoryAreaRead = 0x0104
+)
+
+// Commands of the FINS/TCP header
+const (
+	tcpClientNodeAddress = 0
+	tcpServerNodeAddress = 1
+	tcpFrame             = 2
+)
+
+const finsHeaderLength = 10
+
+var tcpMagic = []byte("FINS")
+
+// Memory area codes for word access
+var areas = map[string]byte{
+	"CIO": 0xB0,
+	"W":   0xB1,
+	"H":   0xB2,
+	"A":   0xB3,
+	"D":   0x82,
+}
+
+// Descriptions of the main response codes
+var mainResponseCodes = map[byte]string{
+	0x01: "local node error",
+	0x02: "destination node error",
+	0x03: "communications controller error",
+	0x04: "service unsupported",
+	0x05: "routing table error",
+	0x10: "command format error",
+	0x11: "parameter error",
+	0x20: "read not possible",
+	0x21: "write not possible",
+	0x22: "not executable in current mode",
+	0x23: "no such device",
+	0x24: "cannot start or stop",
+	0x25: "unit error",
+	0x26: "command error",
+	0x30: "access right error",
+	0x40: "abort",
+}
+
+// node is the FINS address of a node
+type node struct {
+	network byte
+	node    byte
+	unit    byte
+}
+
+// item is a word of a memory area
+type item struct {
+	area    byte
+	address uint16
+}
+
+// client sends FINS commands via UDP or TCP
+type client struct {
+	conn        net.Conn
+	stream      bool
+	destination node
+	source      node
+	timeout     time.Duration
+	sid         byte
+}
+
+func dial(transport, address string, destination, source node, timeout time.Duration) (*client, error) {
+	conn, err := net.DialTimeout(transport, address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &client{
+		conn:        conn,
+		stream:      transport == "tcp",
+		destination: destination,
+		source:      source,
+		timeout:     timeout,
+	}
+
+	if c.stream {
+		if err := c.exchangeNodeAddress(); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("exchanging node addresses failed: %w", err)
+		}
+	} else if c.source.node == 0 {
+		// Use the last byte of the local IP address as node number as
+		// done by the automatic IP address conversion of the PLC
+		if addr, ok := conn.LocalAddr().(*net.UDPAddr); ok {
+			if ip := addr.IP.To4(); ip != nil {
+				c.source.node = ip[3]
+			}
+		}
+	}
+	return c, nil
+}
+
+func (c *client) close() {
+	c.conn.Close()
+}
+
+// exchangeNodeAddress requests the node address assigned by the PLC to the
+// client as required by FINS/TCP
+func (c *client) exchangeNodeAddress() error {
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	if _, err := c.conn.Write(encodeTCPFrame(tcpClientNodeAddress, []byte{0, 0, 0, c.source.node})); err != nil {
+		return err
+	}
+	cmd, data, err := readTCPFrame(c.conn)
+	if err != nil {
+		return err
+	}
+	if cmd != tcpServerNodeAddress || len(data) < 8 {
+		return fmt.Errorf("unexpected response command %d", cmd)
+	}
+	c.source.node = data[3]
+	if c.destination.node == 0 {
+		c.destination.node = data[7]
+	}
+	return nil
+}
+
+// command sends the command and returns the response data after checking
+// the end code
+func (c *client) command(code uint16, params []byte) ([]byte, error) {
+	c.sid++
+	frame := make([]byte, finsHeaderLength, finsHeaderLength+2+len(params))
+	frame[0] = 0x80 // command requiring a response
+	frame[2] = 0x02 // gateway count
+	frame[3] = c.destination.network
+	frame[4] = c.destination.node
+	frame[5] = c.destination.unit
+	frame[6] = c.source.network
+	frame[7] = c.source.node
+	frame[8] = c.source.unit
+	frame[9] = c.sid
+	frame = binary.BigEndian.AppendUint16(frame, code)
+	frame = append(frame, params...)
+
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+	if c.stream {
+		frame = encodeTCPFrame(tcpFrame, frame)
+	}
+	if _, err := c.conn.Write(frame); err != nil {
+		return nil, err
+	}
+
+	for {
+		resp, err := c.readResponse()
+		if err != nil {
+			return nil, err
+		}
+		if len(resp) < finsHeaderLength+4 {
+			return nil, errors.New("truncated response")
+		}
+		// Skip responses of previous requests, e.g. after a timeout
+		if resp[9] != c.sid {
+			continue
+		}
+		if resp[0]&0x40 == 0 {
+			return nil, errors.New("received command instead of response")
+		}
+		if binary.BigEndian.Uint16(resp[10:]) != code {
+			return nil, fmt.Errorf("response does not match command %04x", code)
+		}
+		// Bits of the end code indicating a network relay error, a fatal
+		// or a non-fatal CPU error are ignored
+		main, sub := resp[12]&0x7F, resp[13]&0x3F
+		if main != 0 || sub != 0 {
+			if msg, found := mainResponseCodes[main]; found {
+				return nil, fmt.Errorf("end code %02x%02x: %s", main, sub, msg)
+			}
+			return nil, fmt.Errorf("end code %02x%02x", main, sub)
+		}
+		return resp[14:], nil
+	}
+}
+
+func (c *client) readResponse() ([]byte, error) {
+	if c.stream {
+		for {
+			cmd, data, err := readTCPFrame(c.conn)
+			if err != nil {
+				return nil, err
+			}
+			if cmd == tcpFrame {
+				return data, nil
+			}
+		}
+	}
+	buf := make([]byte, 2048)
+	n, err := c.conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+// readArea reads the given number of consecutive words of a memory area
+func (c *client) readArea(area byte, address, count uint16) ([]uint16, error) {
+	params := []byte{area, byte(address >> 8), byte(address), 0}
+	params = binary.BigEndian.AppendUint16(params, count)
+	data, err := c.command(cmdMemoryAreaRead, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 2*int(count) {
+		return nil, errors.New("truncated memory area data")
+	}
+	words := make([]uint16, count)
+	for i := range words {
+		words[i] = binary.BigEndian.Uint16(data[2*i:])
+	}
+	return words, nil
+}
+
+// readItems reads the words of possibly different memory areas using a
+// single multiple memory area read
+func (c *client) readItems(items []item) ([]uint16, error) {
+	params := make([]byte, 0, 4*len(items))
+	for _, it := range items {
+		params = append(params, it.area, byte(it.address>>8), byte(it.address), 0)
+	}
+	data, err := c.command(cmdMultipleMemoryAreaRead, params)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 3*len(items) {
+		return nil, errors.New("truncated multiple memory area data")
+	}
+	words := make([]uint16, len(items))
+	for i, it := range items {
+		if data[3*i] != it.area {
+			return nil, fmt.Errorf("unexpected area code 0x%02x", data[3*i])
+		}
+		words[i] = binary.BigEndian.Uint16(data[3*i+1:])
+	}
+	return words, nil
+}
+
+func encodeTCPFrame(command uint32, data []byte) []byte {
+	buf := make([]byte, 16, 16+len(data))
+	copy(buf, tcpMagic)
+	binary.BigEndian.PutUint32(buf[4:], uint32(8+len(data)))
+	binary.BigEndian.PutUint32(buf[8:], command)
+	return append(buf, data...)
+}
+
+func readTCPFrame(r io.Reader) (uint32, []byte, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	if !bytes.Equal(header[:4], tcpMagic) {
+		return 0, nil, errors.New("invalid FINS/TCP header")
+	}
+	length := binary.BigEndian.Uint32(header[4:])
+	if length < 8 || length > 8192 {
+		return 0, nil, fmt.Errorf("invalid FINS/TCP length %d", length)
+	}
+	if code := binary.BigEndian.Uint32(header[12:]); code != 0 {
+		return 0, nil, fmt.Errorf("FINS/TCP error code %d", code)
+	}
+	data := make([]byte, length-8)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	return binary.BigEndian.Uint32(header[8:]), data, nil
+}
@@ -0,0 +1,401 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package omron_fins
+
+import (
+	"cmp"
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+var regexAddr = regexp.MustCompile(`^(CIO|DM|D|W|H|A)(\d+)(?:\.(\d{1,2}))?$`)
+
+const (
+	// Maximum number of words read with a single memory area read
+	maxAreaWords = 990
+	// Maximum number of words read with a single multiple memory area read
+	maxItems = 128
+	// Maximum number of words between two fields to still read them in the
+	// same range
+	maxMergeGap = 8
+	// Ranges up to this size are read as single words using multiple memory
+	// area reads to combine them with words of other areas
+	maxItemRange = 4
+)
+
+// Number of words occupied by the data types
+var typeWords = map[string]int{
+	"BOOL":  1,
+	"INT":   1,
+	"UINT":  1,
+	"WORD":  1,
+	"BCD":   1,
+	"DINT":  2,
+	"UDINT": 2,
+	"DWORD": 2,
+	"BCD32": 2,
+	"REAL":  2,
+	"LREAL": 4,
+}
+
+type OmronFINS struct {
+	Address            string             `toml:"address"`
+	Transport          string             `toml:"transport"`
+	DestinationNetwork uint8              `toml:"destination_network"`
+	DestinationNode    uint8              `toml:"destination_node"`
+	DestinationUnit    uint8              `toml:"destination_unit"`
+	SourceNetwork      uint8              `toml:"source_network"`
+	SourceNode         uint8              `toml:"source_node"`
+	SourceUnit         uint8              `toml:"source_unit"`
+	Timeout            config.Duration    `toml:"timeout"`
+	Metrics            []metricDefinition `toml:"metric"`
+	Log                telegraf.Logger    `toml:"-"`
+
+	client   *client
+	mappings []*fieldMapping
+	ranges   []*wordRange
+	requests [][]item
+}
+
+type metricDefinition struct {
+	Name   string            `toml:"name"`
+	Fields []fieldDefinition `toml:"fields"`
+	Tags   map[string]string `toml:"tags"`
+}
+
+type fieldDefinition struct {
+	Name    string `toml:"name"`
+	Address string `toml:"address"`
+	Type    string `toml:"type"`
+	Length  int    `toml:"length"`
+}
+
+type fieldMapping struct {
+	measurement string
+	field       string
+	tags        map[string]string
+	area        byte
+	start       uint16
+	words       int
+	bit         int
+	dtype       string
+}
+
+// wordRange is a range of consecutive words of an area read with a single
+// memory area read
+type wordRange struct {
+	area  byte
+	start uint16
+	count int
+}
+
+func (*OmronFINS) SampleConfig() string {
+	return sampleConfig
+}
+
+func (o *OmronFINS) Init() error {
+	if o.Address == "" {
+		return errors.New("'address' must be specified")
+	}
+	var nerr *net.AddrError
+	if _, _, err := net.SplitHostPort(o.Address); errors.As(err, &nerr) && strings.Contains(nerr.Err, "missing port") {
+		o.Address += ":9600"
+	}
+	switch o.Transport {
+	case "":
+		o.Transport = "udp"
+	case "udp", "tcp":
+	default:
+		return fmt.Errorf("invalid 'transport' %q", o.Transport)
+	}
+	if o.Timeout <= 0 {
+		return errors.New("'timeout' must be positive")
+	}
+	if len(o.Metrics) == 0 {
+		return errors.New("no metric defined")
+	}
+
+	seen := make(map[string]bool)
+	o.mappings = nil
+	for _, def := range o.Metrics {
+		if def.Name == "" {
+			def.Name = "omron_fins"
+		}
+		if len(def.Fields) == 0 {
+			return fmt.Errorf("no fields defined for metric %q", def.Name)
+		}
+		for _, f := range def.Fields {
+			if f.Name == "" {
+				return fmt.Errorf("unnamed field in metric %q", def.Name)
+			}
+			id := def.Name + "\x00" + f.Name + "\x00" + fmt.Sprint(def.Tags)
+			if seen[id] {
+				return fmt.Errorf("duplicate field %q in metric %q", f.Name, def.Name)
+			}
+			seen[id] = true
+
+			fm, err := newFieldMapping(f)
+			if err != nil {
+				return fmt.Errorf("field %q of metric %q: %w", f.Name, def.Name, err)
+			}
+			fm.measurement = def.Name
+			fm.tags = def.Tags
+			o.mappings = append(o.mappings, fm)
+		}
+	}
+	o.createRequests()
+
+	return nil
+}
+
+func newFieldMapping(def fieldDefinition) (*fieldMapping, error) {
+	match := regexAddr.FindStringSubmatch(strings.ToUpper(def.Address))
+	if match == nil {
+		return nil, fmt.Errorf("invalid address %q", def.Address)
+	}
+	name := match[1]
+	if name == "DM" {
+		name = "D"
+	}
+	address, err := strconv.ParseUint(match[2], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid word address in %q", def.Address)
+	}
+
+	fm := &fieldMapping{field: def.Name, area: areas[name], start: uint16(address), bit: -1, dtype: strings.ToUpper(def.Type)}
+	if match[3] != "" {
+		bit, err := strconv.ParseUint(match[3], 10, 8)
+		if err != nil || bit > 15 {
+			return nil, fmt.Errorf("invalid bit in address %q", def.Address)
+		}
+		fm.bit = int(bit)
+	}
+	if fm.dtype == "" {
+		fm.dtype = "INT"
+		if fm.bit >= 0 {
+			fm.dtype = "BOOL"
+		}
+	}
+	if (fm.bit >= 0) != (fm.dtype == "BOOL") {
+		return nil, fmt.Errorf("type %q does not match address %q", fm.dtype, def.Address)
+	}
+
+	if fm.dtype == "STRING" {
+		if def.Length <= 0 {
+			return nil, errors.New("'length' required for strings")
+		}
+		fm.words = (def.Length + 1) / 2
+	} else {
+		words, found := typeWords[fm.dtype]
+		if !found {
+			return nil, fmt.Errorf("invalid type %q", def.Type)
+		}
+		fm.words = words
+	}
+	if int(fm.start)+fm.words > math.MaxUint16+1 || fm.words > maxAreaWords {
+		return nil, fmt.Errorf("address %q out of range", def.Address)
+	}
+	return fm, nil
+}
+
+// createRequests merges the words of the fields into ranges and reads large
+// ranges with memory area reads while the words of small ranges of all areas
+// are combined into multiple memory area reads
+func (o *OmronFINS) createRequests() {
+	sorted := slices.Clone(o.mappings)
+	slices.SortStableFunc(sorted, func(a, b *fieldMapping) int {
+		return cmp.Or(cmp.Compare(a.area, b.area), cmp.Compare(a.start, b.start))
+	})
+
+	var ranges []*wordRange
+	var current *wordRange
+	for _, fm := range sorted {
+		end := int(fm.start) + fm.words
+		if current == nil ||
+			current.area != fm.area ||
+			int(fm.start) > int(current.start)+current.count+maxMergeGap ||
+			max(end, int(current.start)+current.count)-int(current.start) > maxAreaWords {
+			current = &wordRange{area: fm.area, start: fm.start}
+			ranges = append(ranges, current)
+		}
+		current.count = max(current.count, end-int(current.start))
+	}
+
+	o.ranges = nil
+	o.requests = nil
+	var items []item
+	for _, r := range ranges {
+		if r.count > maxItemRange {
+			o.ranges = append(o.ranges, r)
+			continue
+		}
+		for i := range r.count {
+			items = append(items, item{area: r.area, address: r.start + uint16(i)})
+		}
+	}
+	for len(items) > 0 {
+		n := min(len(items), maxItems)
+		o.requests = append(o.requests, items[:n])
+		items = items[n:]
+	}
+}
+
+func (o *OmronFINS) Start(telegraf.Accumulator) error {
+	return o.connect()
+}
+
+func (o *OmronFINS) Gather(acc telegraf.Accumulator) error {
+	if o.client == nil {
+		if err := o.connect(); err != nil {
+			return err
+		}
+	}
+
+	timestamp := time.Now()
+	memory, err := o.read()
+	if err != nil {
+		// Reconnect on the next gather cycle as the state of the connection
+		// is unknown
+		o.client.close()
+		o.client = nil
+		return err
+	}
+
+	grouper := metric.NewSeriesGrouper()
+	for _, fm := range o.mappings {
+		words := make([]uint16, 0, fm.words)
+		for i := range fm.words {
+			words = append(words, memory[item{area: fm.area, address: fm.start + uint16(i)}])
+		}
+		value, err := fm.convert(words)
+		if err != nil {
+			acc.AddError(fmt.Errorf("converting field %q failed: %w", fm.field, err))
+			continue
+		}
+		grouper.Add(fm.measurement, fm.tags, timestamp, fm.field, value)
+	}
+	for _, x := range grouper.Metrics() {
+		acc.AddMetric(x)
+	}
+
+	return nil
+}
+
+func (o *OmronFINS) Stop() {
+	if o.client != nil {
+		o.client.close()
+		o.client = nil
+	}
+}
+
+func (o *OmronFINS) connect() error {
+	destination := node{network: o.DestinationNetwork, node: o.DestinationNode, unit: o.DestinationUnit}
+	source := node{network: o.SourceNetwork, node: o.SourceNode, unit: o.SourceUnit}
+	c, err := dial(o.Transport, o.Address, destination, source, time.Duration(o.Timeout))
+	if err != nil {
+		return fmt.Errorf("connecting to %s failed: %w", o.Address, err)
+	}
+	o.client = c
+	return nil
+}
+
+// read executes all requests and returns the words read
+func (o *OmronFINS) read() (map[item]uint16, error) {
+	memory := make(map[item]uint16)
+	for _, r := range o.ranges {
+		words, err := o.client.readArea(r.area, r.start, uint16(r.count))
+		if err != nil {
+			return nil, fmt.Errorf("reading %d words of area 0x%02x at %d failed: %w", r.count, r.area, r.start, err)
+		}
+		for i, w := range words {
+			memory[item{area: r.area, address: r.start + uint16(i)}] = w
+		}
+	}
+	for _, items := range o.requests {
+		words, err := o.client.readItems(items)
+		if err != nil {
+			return nil, fmt.Errorf("reading %d words of multiple areas failed: %w", len(items), err)
+		}
+		for i, w := range words {
+			memory[items[i]] = w
+		}
+	}
+	return memory, nil
+}
+
+// convert decodes the value from the words where multi-word values are
+// stored with the least significant word first
+func (fm *fieldMapping) convert(words []uint16) (interface{}, error) {
+	var v uint64
+	for i, w := range words {
+		if i < 4 {
+			v |= uint64(w) << (16 * i)
+		}
+	}
+
+	switch fm.dtype {
+	case "BOOL":
+		return words[0]&(1<<fm.bit) != 0, nil
+	case "INT":
+		return int64(int16(v)), nil
+	case "UINT", "WORD":
+		return v, nil
+	case "DINT":
+		return int64(int32(v)), nil
+	case "UDINT", "DWORD":
+		return v, nil
+	case "REAL":
+		return float64(math.Float32frombits(uint32(v))), nil
+	case "LREAL":
+		return math.Float64frombits(v), nil
+	case "BCD", "BCD32":
+		return decodeBCD(v, 4*fm.words)
+	case "STRING":
+		buf := make([]byte, 0, 2*len(words))
+		for _, w := range words {
+			buf = binary.BigEndian.AppendUint16(buf, w)
+		}
+		if i := slices.Index(buf, 0); i >= 0 {
+			buf = buf[:i]
+		}
+		return string(buf), nil
+	}
+	return nil, fmt.Errorf("unsupported type %q", fm.dtype)
+}
+
+func decodeBCD(v uint64, digits int) (uint64, error) {
+	var result uint64
+	for i := digits - 1; i >= 0; i-- {
+		digit := (v >> (4 * i)) & 0x0F
+		if digit > 9 {
+			return 0, fmt.Errorf("invalid BCD value 0x%x", v)
+		}
+		result = 10*result + digit
+	}
+	return result, nil
+}
+
+func init() {
+	inputs.Add("omron_fins", func() telegraf.Input {
+		return &OmronFINS{
+			Timeout: config.Duration(5 * time.Second),
+		}
+	})
+}
@@ -0,0 +1,396 @@
+package omron_fins
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *OmronFINS
+		expected string
+	}{
+		{
+			name:     "no address",
+			plugin:   &OmronFINS{},
+			expected: "'address' must be specified",
+		},
+		{
+			name:     "invalid transport",
+			plugin:   &OmronFINS{Address: "127.0.0.1", Transport: "serial"},
+			expected: `invalid 'transport' "serial"`,
+		},
+		{
+			name:     "no metrics",
+			plugin:   &OmronFINS{Address: "127.0.0.1"},
+			expected: "no metric defined",
+		},
+		{
+			name:     "unknown area",
+			plugin:   newTestPlugin("127.0.0.1", fieldDefinition{Name: "a", Address: "E10"}),
+			expected: `invalid address "E10"`,
+		},
+		{
+			name:     "address out of range",
+			plugin:   newTestPlugin("127.0.0.1", fieldDefinition{Name: "a", Address: "D70000"}),
+			expected: `invalid word address in "D70000"`,
+		},
+		{
+			name:     "invalid bit",
+			plugin:   newTestPlugin("127.0.0.1", fieldDefinition{Name: "a", Address: "CIO1.16"}),
+			expected: `invalid bit in address "CIO1.16"`,
+		},
+		{
+			name:     "bit without bit number",
+			plugin:   newTestPlugin("127.0.0.1", fieldDefinition{Name: "a", Address: "D10", Type: "BOOL"}),
+			expected: `type "BOOL" does not match address "D10"`,
+		},
+		{
+			name:     "word type with bit number",
+			plugin:   newTestPlugin("127.0.0.1", fieldDefinition{Name: "a", Address: "D10.1", Type: "INT"}),
+			expected: `type "INT" does not match address "D10.1"`,
+		},
+		{
+			name:     "invalid type",
+			plugin:   newTestPlugin("127.0.0.1", fieldDefinition{Name: "a", Address: "D10", Type: "INT16"}),
+			expected: `invalid type "INT16"`,
+		},
+		{
+			name:     "string without length",
+			plugin:   newTestPlugin("127.0.0.1", fieldDefinition{Name: "a", Address: "D10", Type: "STRING"}),
+			expected: "'length' required for strings",
+		},
+		{
+			name: "duplicate field",
+			plugin: newTestPlugin("127.0.0.1",
+				fieldDefinition{Name: "a", Address: "D10"},
+				fieldDefinition{Name: "a", Address: "D11"},
+			),
+			expected: `duplicate field "a"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Timeout = config.Duration(time.Second)
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		area    byte
+		start   uint16
+		bit     int
+		dtype   string
+	}{
+		{address: "D100", area: 0x82, start: 100, bit: -1, dtype: "INT"},
+		{address: "dm100", area: 0x82, start: 100, bit: -1, dtype: "INT"},
+		{address: "CIO0.05", area: 0xB0, start: 0, bit: 5, dtype: "BOOL"},
+		{address: "W10.15", area: 0xB1, start: 10, bit: 15, dtype: "BOOL"},
+		{address: "H3", area: 0xB2, start: 3, bit: -1, dtype: "INT"},
+		{address: "A261", area: 0xB3, start: 261, bit: -1, dtype: "INT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			fm, err := newFieldMapping(fieldDefinition{Name: "a", Address: tt.address})
+			require.NoError(t, err)
+			require.Equal(t, tt.area, fm.area)
+			require.Equal(t, tt.start, fm.start)
+			require.Equal(t, tt.bit, fm.bit)
+			require.Equal(t, tt.dtype, fm.dtype)
+		})
+	}
+}
+
+func TestRequests(t *testing.T) {
+	plugin := newTestPlugin("127.0.0.1",
+		fieldDefinition{Name: "a", Address: "D100", Type: "REAL"},
+		fieldDefinition{Name: "b", Address: "D110"},
+		fieldDefinition{Name: "c", Address: "D1000"},
+		fieldDefinition{Name: "d", Address: "CIO0.05"},
+		fieldDefinition{Name: "e", Address: "CIO0.07"},
+		fieldDefinition{Name: "f", Address: "H5", Type: "DINT"},
+	)
+	plugin.Timeout = config.Duration(time.Second)
+	require.NoError(t, plugin.Init())
+
+	ranges := make([]string, 0, len(plugin.ranges))
+	for _, r := range plugin.ranges {
+		ranges = append(ranges, fmt.Sprintf("%02X:%d+%d", r.area, r.start, r.count))
+	}
+	require.Equal(t, []string{"82:100+11"}, ranges)
+
+	require.Len(t, plugin.requests, 1)
+	items := make([]string, 0, len(plugin.requests[0]))
+	for _, it := range plugin.requests[0] {
+		items = append(items, fmt.Sprintf("%02X:%d", it.area, it.address))
+	}
+	require.Equal(t, []string{"82:1000", "B0:0", "B2:5", "B2:6"}, items)
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		dtype    string
+		bit      int
+		words    []uint16
+		expected interface{}
+	}{
+		{dtype: "BOOL", bit: 3, words: []uint16{0x0008}, expected: true},
+		{dtype: "INT", words: []uint16{0xFFFE}, expected: int64(-2)},
+		{dtype: "UINT", words: []uint16{0xFFFE}, expected: uint64(65534)},
+		{dtype: "DINT", words: []uint16{0xFFFF, 0xFFFF}, expected: int64(-1)},
+		{dtype: "UDINT", words: []uint16{0x0001, 0x0002}, expected: uint64(0x00020001)},
+		{dtype: "BCD", words: []uint16{0x1234}, expected: uint64(1234)},
+		{dtype: "BCD32", words: []uint16{0x5678, 0x1234}, expected: uint64(12345678)},
+		{dtype: "LREAL", words: []uint16{0, 0, 0, 0x3FF8}, expected: float64(1.5)},
+		{dtype: "STRING", words: []uint16{'A'<<8 | 'B', 'C' << 8}, expected: "ABC"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dtype, func(t *testing.T) {
+			fm := &fieldMapping{dtype: tt.dtype, bit: tt.bit, words: len(tt.words)}
+			value, err := fm.convert(tt.words)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, value)
+		})
+	}
+
+	fm := &fieldMapping{dtype: "BCD", words: 1}
+	_, err := fm.convert([]uint16{0x12A4})
+	require.ErrorContains(t, err, "invalid BCD value 0x12a4")
+}
+
+func TestGather(t *testing.T) {
+	for _, transport := range []string{"udp", "tcp"} {
+		t.Run(transport, func(t *testing.T) {
+			plc := newFakePLC(t, transport)
+			speed := math.Float32bits(1.5)
+			plc.set(0x82, 100, uint16(speed), uint16(speed>>16), 0x3039, 0x0001, 0x0008)
+			plc.set(0x82, 110, 'P'<<8|'R', 'O'<<8|'G', '1'<<8)
+			plc.set(0xB0, 0, 0x0020)
+			plc.set(0xB1, 10, 0x0420)
+			plc.set(0xB2, 5, 0xFFFF, 0xFFFF)
+
+			plugin := newTestPlugin(plc.addr(),
+				fieldDefinition{Name: "speed", Address: "D100", Type: "REAL"},
+				fieldDefinition{Name: "counter", Address: "D102", Type: "UDINT"},
+				fieldDefinition{Name: "alarm", Address: "D104.03"},
+				fieldDefinition{Name: "ready", Address: "D104.00"},
+				fieldDefinition{Name: "program", Address: "D110", Type: "STRING", Length: 6},
+				fieldDefinition{Name: "running", Address: "CIO0.05"},
+				fieldDefinition{Name: "recipe", Address: "W10", Type: "BCD"},
+				fieldDefinition{Name: "offset", Address: "H5", Type: "DINT"},
+			)
+			plugin.Transport = transport
+			plugin.DestinationNode = 1
+			plugin.Timeout = config.Duration(time.Second)
+			plugin.Metrics[0].Tags = map[string]string{"machine": "press-1"}
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, plugin.Start(&acc))
+			defer plugin.Stop()
+			require.NoError(t, plugin.Gather(&acc))
+			require.Empty(t, acc.Errors)
+
+			expected := []telegraf.Metric{
+				metric.New(
+					"omron_fins",
+					map[string]string{"machine": "press-1"},
+					map[string]interface{}{
+						"speed":   float64(1.5),
+						"counter": uint64(0x00013039),
+						"alarm":   true,
+						"ready":   false,
+						"program": "PROG1",
+						"running": true,
+						"recipe":  uint64(420),
+						"offset":  int64(-1),
+					},
+					time.Unix(0, 0),
+				),
+			}
+			testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+			require.Equal(t, []uint16{cmdMemoryAreaRead, cmdMultipleMemoryAreaRead}, plc.commands())
+			if transport == "tcp" {
+				// The node number assigned by the PLC must be used as source
+				require.Equal(t, byte(0x22), plc.sourceNode())
+			}
+		})
+	}
+}
+
+func TestEndCode(t *testing.T) {
+	plc := newFakePLC(t, "udp")
+	plugin := newTestPlugin(plc.addr(), fieldDefinition{Name: "a", Address: "D40000"})
+	plugin.Timeout = config.Duration(time.Second)
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	require.ErrorContains(t, plugin.Gather(&acc), "end code 1103: parameter error")
+	require.Nil(t, plugin.client)
+}
+
+func newTestPlugin(address string, fields ...fieldDefinition) *OmronFINS {
+	return &OmronFINS{
+		Address: address,
+		Metrics: []metricDefinition{{Fields: fields}},
+		Log:     testutil.Logger{},
+	}
+}
+
+// fakePLC answers memory area reads from words indexed by area code and
+// address where addresses of 32768 and above are out of range
+type fakePLC struct {
+	addrFunc func() string
+	memory   map[item]uint16
+	received []uint16
+	source   byte
+	sync.Mutex
+}
+
+func newFakePLC(t *testing.T, transport string) *fakePLC {
+	p := &fakePLC{memory: make(map[item]uint16)}
+
+	if transport == "udp" {
+		conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		p.addrFunc = conn.LocalAddr().String
+		go func() {
+			buf := make([]byte, 2048)
+			for {
+				n, addr, err := conn.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				if _, err := conn.WriteTo(p.handle(buf[:n]), addr); err != nil {
+					return
+				}
+			}
+		}()
+		return p
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	p.addrFunc = listener.Addr().String
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go p.serve(conn)
+		}
+	}()
+	return p
+}
+
+func (p *fakePLC) addr() string {
+	return p.addrFunc()
+}
+
+func (p *fakePLC) set(area byte, address uint16, words ...uint16) {
+	p.Lock()
+	defer p.Unlock()
+	for i, w := range words {
+		p.memory[item{area: area, address: address + uint16(i)}] = w
+	}
+}
+
+func (p *fakePLC) commands() []uint16 {
+	p.Lock()
+	defer p.Unlock()
+	return append([]uint16(nil), p.received...)
+}
+
+func (p *fakePLC) sourceNode() byte {
+	p.Lock()
+	defer p.Unlock()
+	return p.source
+}
+
+// serve handles a FINS/TCP connection assigning node 0x22 to the client
+func (p *fakePLC) serve(conn net.Conn) {
+	defer conn.Close()
+	for {
+		cmd, data, err := readTCPFrame(conn)
+		if err != nil {
+			return
+		}
+		var resp []byte
+		switch cmd {
+		case tcpClientNodeAddress:
+			resp = encodeTCPFrame(tcpServerNodeAddress, []byte{0, 0, 0, 0x22, 0, 0, 0, 0x01})
+		case tcpFrame:
+			resp = encodeTCPFrame(tcpFrame, p.handle(data))
+		default:
+			return
+		}
+		if _, err := conn.Write(resp); err != nil {
+			return
+		}
+	}
+}
+
+// handle decodes the FINS command and returns the response
+func (p *fakePLC) handle(req []byte) []byte {
+	p.Lock()
+	defer p.Unlock()
+
+	code := binary.BigEndian.Uint16(req[10:])
+	p.received = append(p.received, code)
+	p.source = req[7]
+
+	// Swap destination and source addresses
+	resp := []byte{0xC0, 0, 0x02, req[6], req[7], req[8], req[3], req[4], req[5], req[9]}
+	resp = binary.BigEndian.AppendUint16(resp, code)
+
+	params := req[12:]
+	var data []byte
+	switch code {
+	case cmdMemoryAreaRead:
+		address := binary.BigEndian.Uint16(params[1:])
+		count := binary.BigEndian.Uint16(params[4:])
+		for i := range count {
+			if int(address)+int(i) >= 32768 {
+				return append(resp, 0x11, 0x03)
+			}
+			data = binary.BigEndian.AppendUint16(data, p.memory[item{area: params[0], address: address + i}])
+		}
+	case cmdMultipleMemoryAreaRead:
+		for i := 0; i+4 <= len(params); i += 4 {
+			it := item{area: params[i], address: binary.BigEndian.Uint16(params[i+1:])}
+			if it.address >= 32768 {
+				return append(resp, 0x11, 0x03)
+			}
+			data = append(data, it.area)
+			data = binary.BigEndian.AppendUint16(data, p.memory[it])
+		}
+	default:
+		return append(resp, 0x04, 0x01)
+	}
+	resp = append(resp, 0, 0)
+	return append(resp, data...)
+}
@@ -0,0 +1,29 @@
+package opcua
+
+import (
+	"github.com/influxdata/telegraf/plugins/common/opcua"
+)
+
+// readDiagnostics is the state of the client reported by the diagnostics
+// endpoint
+type readDiagnostics struct {
+	Sessions []sessionDiagnostics `json:"sessions"`
+}
+
+// sessionDiagnostics is the state of the connection of a session shard
+type sessionDiagnostics struct {
+	opcua.ClientDiagnostics
+	Nodes int `json:"nodes"`
+}
+
+// diagnostics returns the current state of the client
+func (o *readClient) diagnostics() interface{} {
+	d := &readDiagnostics{Sessions: make([]sessionDiagnostics, 0, len(o.shards))}
+	for _, shard := range o.shards {
+		d.Sessions = append(d.Sessions, sessionDiagnostics{
+			ClientDiagnostics: shard.client.Diagnostics(),
+			Nodes:             shard.count,
+		})
+	}
+	return d
+}
@@ -3,6 +3,8 @@ package opcua
 
 import (
 	_ "embed"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/influxdata/telegraf"
@@ -19,30 +21,90 @@ type OpcUA struct {
 	readClientConfig
 	Log telegraf.Logger `toml:"-"`
 
-	client *readClient
+	clients []*readClient
+
+	unregisterDiagnostics []func()
 }
 
 func (*OpcUA) SampleConfig() string {
 	return sampleConfig
 }
 
-func (o *OpcUA) Init() (err error) {
-	o.client, err = o.readClientConfig.createReadClient(o.Log)
-	return err
+func (o *OpcUA) Init() error {
+	// Create a client for each of the configured endpoints sharing the
+	// node configuration
+	for _, cfg := range o.readClientConfig.EndpointConfigs() {
+		rc := o.readClientConfig
+		rc.InputClientConfig = cfg
+		client, err := rc.createReadClient(o.Log)
+		if err != nil {
+			if len(o.Endpoints) > 0 {
+				return fmt.Errorf("creating client for endpoint %q failed: %w", cfg.Endpoint, err)
+			}
+			return err
+		}
+		o.clients = append(o.clients, client)
+	}
+	return nil
+}
+
+func (o *OpcUA) Start(telegraf.Accumulator) error {
+	for _, client := range o.clients {
+		unregister, err := opcua.RegisterDiagnostics(o.DiagnosticsAddress, "inputs.opcua", client.diagnostics)
+		if err != nil {
+			o.stopDiagnostics()
+			return err
+		}
+		o.unregisterDiagnostics = append(o.unregisterDiagnostics, unregister)
+	}
+	return nil
+}
+
+func (o *OpcUA) Stop() {
+	o.stopDiagnostics()
+	for _, client := range o.clients {
+		if err := client.disconnect(); err != nil {
+			o.Log.Errorf("Disconnecting from %q failed: %v", client.Config.Endpoint, err)
+		}
+	}
+}
+
+func (o *OpcUA) stopDiagnostics() {
+	for _, unregister := range o.unregisterDiagnostics {
+		unregister()
+	}
+	o.unregisterDiagnostics = nil
 }
 
 func (o *OpcUA) Gather(acc telegraf.Accumulator) error {
-	// Will (re)connect if the client is disconnected
-	metrics, err := o.client.currentValues()
-	if err != nil {
-		return err
+	if len(o.clients) == 1 {
+		return o.gatherClient(acc, o.clients[0])
+	}
+
+	// Query the servers concurrently to not add up the latencies
+	var wg sync.WaitGroup
+	for _, client := range o.clients {
+		wg.Add(1)
+		go func(client *readClient) {
+			defer wg.Done()
+			if err := o.gatherClient(acc, client); err != nil {
+				acc.AddError(fmt.Errorf("gathering from %q failed: %w", client.Config.Endpoint, err))
+			}
+		}(client)
 	}
+	wg.Wait()
+
+	return nil
+}
 
-	// Parse the resulting data into metrics
+func (*OpcUA) gatherClient(acc telegraf.Accumulator, client *readClient) error {
+	// Will (re)connect if the client is disconnected. In case of an error
+	// the metrics might still contain stale values.
+	metrics, err := client.currentValues()
 	for _, m := range metrics {
 		acc.AddMetric(m)
 	}
-	return nil
+	return err
 }
 
 // Add this plugin to telegraf
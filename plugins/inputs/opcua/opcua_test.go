@@ -1,11 +1,16 @@
 package opcua
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/docker/go-connections/nat"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go/wait"
 
@@ -430,10 +435,497 @@ use_unregistered_reads = true
 	require.Equal(t, []string{"DataType"}, o.readClientConfig.OptionalFields)
 	err = o.Init()
 	require.NoError(t, err)
-	require.Len(t, o.client.NodeMetricMapping, 5, "incorrect number of nodes")
-	require.EqualValues(t, map[string]string{"tag0": "val0"}, o.client.NodeMetricMapping[0].MetricTags)
-	require.EqualValues(t, map[string]string{"tag6": "val6"}, o.client.NodeMetricMapping[1].MetricTags)
-	require.EqualValues(t, map[string]string{"tag1": "val1", "tag2": "val2", "tag3": "val3"}, o.client.NodeMetricMapping[2].MetricTags)
-	require.EqualValues(t, map[string]string{"tag1": "override", "tag2": "val2"}, o.client.NodeMetricMapping[3].MetricTags)
-	require.EqualValues(t, map[string]string{"tag1": "val1", "tag2": "val2"}, o.client.NodeMetricMapping[4].MetricTags)
+	require.Len(t, o.clients[0].NodeMetricMapping, 5, "incorrect number of nodes")
+	require.EqualValues(t, map[string]string{"tag0": "val0"}, o.clients[0].NodeMetricMapping[0].MetricTags)
+	require.EqualValues(t, map[string]string{"tag6": "val6"}, o.clients[0].NodeMetricMapping[1].MetricTags)
+	require.EqualValues(t, map[string]string{"tag1": "val1", "tag2": "val2", "tag3": "val3"}, o.clients[0].NodeMetricMapping[2].MetricTags)
+	require.EqualValues(t, map[string]string{"tag1": "override", "tag2": "val2"}, o.clients[0].NodeMetricMapping[3].MetricTags)
+	require.EqualValues(t, map[string]string{"tag1": "val1", "tag2": "val2"}, o.clients[0].NodeMetricMapping[4].MetricTags)
+}
+
+func TestReadClientSuppressUnchanged(t *testing.T) {
+	tests := []struct {
+		name     string
+		absolute float64
+		percent  float64
+		maxAge   time.Duration
+		values   []interface{}
+		expected []bool
+	}{
+		{
+			name:     "exact",
+			values:   []interface{}{int32(1), int32(1), int32(2), int32(2), int32(1)},
+			expected: []bool{true, false, true, false, true},
+		},
+		{
+			name:     "strings",
+			values:   []interface{}{"a", "a", "b", "b"},
+			expected: []bool{true, false, true, false},
+		},
+		{
+			name:     "absolute tolerance",
+			absolute: 0.5,
+			values:   []interface{}{10.0, 10.3, 10.5, 10.6, 10.0},
+			expected: []bool{true, false, false, true, true},
+		},
+		{
+			name:     "absolute tolerance drift",
+			absolute: 0.5,
+			values:   []interface{}{10.0, 10.3, 10.4, 10.51, 10.6},
+			expected: []bool{true, false, false, true, false},
+		},
+		{
+			name:     "absolute tolerance boundary",
+			absolute: 2,
+			values:   []interface{}{int64(10), int64(12), int64(8), int64(13), int64(15)},
+			expected: []bool{true, false, false, true, false},
+		},
+		{
+			name:     "percent tolerance",
+			percent:  10,
+			values:   []interface{}{100.0, 109.0, 91.0, 111.0, 115.0},
+			expected: []bool{true, false, false, true, false},
+		},
+		{
+			name:     "percent tolerance boundary",
+			percent:  25,
+			values:   []interface{}{int32(8), int32(10), int32(6), int32(11), int32(14)},
+			expected: []bool{true, false, false, true, true},
+		},
+		{
+			name:     "max age",
+			maxAge:   2 * time.Second,
+			values:   []interface{}{uint16(3), uint16(3), uint16(3), uint16(3), uint16(3)},
+			expected: []bool{true, false, true, false, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := &unchangedFilter{
+				absolute: tt.absolute,
+				percent:  tt.percent,
+				maxAge:   tt.maxAge,
+				last:     make([]emittedValue, 1),
+			}
+
+			start := time.Now()
+			actual := make([]bool, 0, len(tt.values))
+			for i, v := range tt.values {
+				now := start.Add(time.Duration(i) * time.Second)
+				actual = append(actual, f.update(0, &input.NodeValue{Value: v}, now))
+			}
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestReadClientSuppressUnchangedQuality(t *testing.T) {
+	f := &unchangedFilter{last: make([]emittedValue, 1)}
+
+	now := time.Now()
+	require.True(t, f.update(0, &input.NodeValue{Value: 1.0, Quality: ua.StatusOK}, now))
+	require.False(t, f.update(0, &input.NodeValue{Value: 1.0, Quality: ua.StatusOK}, now))
+	require.True(t, f.update(0, &input.NodeValue{Value: 1.0, Quality: ua.StatusUncertain}, now))
+}
+
+func TestReadClientSuppressUnchangedInvalidConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   readClientConfig
+		expected string
+	}{
+		{
+			name:     "negative tolerance",
+			config:   readClientConfig{SuppressUnchanged: true, SuppressToleranceAbsolute: -1},
+			expected: "'suppress_tolerance_absolute' must not be negative",
+		},
+		{
+			name:     "absolute tolerance without suppression",
+			config:   readClientConfig{SuppressToleranceAbsolute: 1},
+			expected: "'suppress_tolerance_absolute' requires 'suppress_unchanged' to be enabled",
+		},
+		{
+			name:     "percent tolerance without suppression",
+			config:   readClientConfig{SuppressTolerancePercent: 5},
+			expected: "'suppress_tolerance_percent' requires 'suppress_unchanged' to be enabled",
+		},
+		{
+			name:     "max age without suppression",
+			config:   readClientConfig{SuppressMaxAge: config.Duration(time.Minute)},
+			expected: "'suppress_max_age' requires 'suppress_unchanged' to be enabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readConfig := tt.config
+			readConfig.InputClientConfig = input.InputClientConfig{
+				OpcUAClientConfig: opcua.OpcUAClientConfig{
+					Endpoint:       "opc.tcp://localhost:4840",
+					SecurityPolicy: "None",
+					SecurityMode:   "None",
+					AuthMethod:     "Anonymous",
+				},
+				MetricName: "testing",
+				RootNodes: []input.NodeSettings{
+					{FieldName: "node", Namespace: "1", IdentifierType: "i", Identifier: "1"},
+				},
+			}
+
+			_, err := readConfig.createReadClient(testutil.Logger{})
+			require.ErrorContains(t, err, tt.expected)
+		})
+	}
+}
+
+func TestReadClientSessionShards(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		expected [][2]int
+	}{
+		{
+			name:     "disabled",
+			expected: [][2]int{{0, 5}},
+		},
+		{
+			name:     "larger than nodes",
+			size:     10,
+			expected: [][2]int{{0, 5}},
+		},
+		{
+			name:     "exact",
+			size:     5,
+			expected: [][2]int{{0, 5}},
+		},
+		{
+			name:     "uneven",
+			size:     2,
+			expected: [][2]int{{0, 2}, {2, 2}, {4, 1}},
+		},
+		{
+			name:     "single node per session",
+			size:     1,
+			expected: [][2]int{{0, 1}, {1, 1}, {2, 1}, {3, 1}, {4, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			readConfig := readClientConfig{
+				SessionShardSize: tt.size,
+				InputClientConfig: input.InputClientConfig{
+					OpcUAClientConfig: opcua.OpcUAClientConfig{
+						Endpoint:       "opc.tcp://localhost:4840",
+						SecurityPolicy: "None",
+						SecurityMode:   "None",
+						AuthMethod:     "Anonymous",
+					},
+					MetricName: "testing",
+				},
+			}
+			for i := range 5 {
+				readConfig.RootNodes = append(readConfig.RootNodes, input.NodeSettings{
+					FieldName:      fmt.Sprintf("node%d", i),
+					Namespace:      "1",
+					IdentifierType: "i",
+					Identifier:     strconv.Itoa(i),
+				})
+			}
+
+			client, err := readConfig.createReadClient(testutil.Logger{})
+			require.NoError(t, err)
+
+			actual := make([][2]int, 0, len(client.shards))
+			for _, shard := range client.shards {
+				actual = append(actual, [2]int{shard.offset, shard.count})
+			}
+			require.Equal(t, tt.expected, actual)
+			require.Same(t, client.OpcUAClient, client.shards[0].client)
+		})
+	}
+}
+
+func TestReadClientStaleValues(t *testing.T) {
+	readConfig := readClientConfig{
+		StaleGracePeriod:  config.Duration(10 * time.Second),
+		SuppressUnchanged: true,
+		InputClientConfig: input.InputClientConfig{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       "opc.tcp://localhost:4840",
+				SecurityPolicy: "None",
+				SecurityMode:   "None",
+				AuthMethod:     "Anonymous",
+			},
+			MetricName: "testing",
+			Timestamp:  input.TimestampSourceSource,
+			RootNodes: []input.NodeSettings{
+				{FieldName: "fresh", Namespace: "1", IdentifierType: "i", Identifier: "1"},
+				{FieldName: "old", Namespace: "1", IdentifierType: "i", Identifier: "2"},
+				{FieldName: "never", Namespace: "1", IdentifierType: "i", Identifier: "3"},
+			},
+		},
+	}
+
+	client, err := readConfig.createReadClient(testutil.Logger{})
+	require.NoError(t, err)
+
+	now := time.Now()
+	source := time.Unix(1700000000, 0)
+	client.lastGood[0] = goodValue{
+		value: input.NodeValue{TagName: "fresh", Value: 42.0, Quality: ua.StatusOK, SourceTime: source},
+		time:  now.Add(-3 * time.Second),
+	}
+	client.lastGood[1] = goodValue{
+		value: input.NodeValue{TagName: "old", Value: 23.0, Quality: ua.StatusOK, SourceTime: source},
+		time:  now.Add(-11 * time.Second),
+	}
+	client.unchanged.last[0] = emittedValue{valid: true, value: 42.0, time: now}
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"testing",
+			map[string]string{"id": "ns=1;i=1", "stale": "true"},
+			map[string]interface{}{
+				"fresh":   42.0,
+				"Quality": "The operation succeeded. StatusGood (0x0)",
+				"Age":     3.0,
+			},
+			source,
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, client.staleValues(now))
+	require.False(t, client.unchanged.last[0].valid)
+}
+
+func TestReadClientSessionlessInvalidConfig(t *testing.T) {
+	readConfig := readClientConfig{
+		Sessionless: true,
+		InputClientConfig: input.InputClientConfig{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       "opc.tcp://localhost:4840",
+				SecurityPolicy: "None",
+				SecurityMode:   "None",
+				AuthMethod:     "UserName",
+			},
+			MetricName: "testing",
+			RootNodes: []input.NodeSettings{
+				{FieldName: "node", Namespace: "1", IdentifierType: "i", Identifier: "1"},
+			},
+		},
+	}
+
+	_, err := readConfig.createReadClient(testutil.Logger{})
+	require.ErrorContains(t, err, "'sessionless' requires anonymous authentication")
+}
+
+func TestReadClientSessionless(t *testing.T) {
+	// Start a local server, the server does not require sessions for reading
+	port := testutil.FreeTCPPort(t)
+
+	srv := server.New(
+		server.EndPoint("127.0.0.1", port),
+		server.EnableSecurity("None", ua.MessageSecurityModeNone),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+	)
+	ns := server.NewNodeNameSpace(srv, "urn:test")
+	ns.AddNode(server.NewVariableNode(ua.NewStringNodeID(ns.ID(), "value"), "value", int32(42)))
+	// Cancelling the server context crashes the server, so use the background context
+	require.NoError(t, srv.Start(context.Background()))
+	defer srv.Close()
+
+	readConfig := readClientConfig{
+		Sessionless: true,
+		InputClientConfig: input.InputClientConfig{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       fmt.Sprintf("opc.tcp://127.0.0.1:%d", port),
+				SecurityPolicy: "None",
+				SecurityMode:   "None",
+				AuthMethod:     "Anonymous",
+				ConnectTimeout: config.Duration(5 * time.Second),
+				RequestTimeout: config.Duration(5 * time.Second),
+			},
+			MetricName: "testing",
+			RootNodes: []input.NodeSettings{
+				{FieldName: "value", Namespace: strconv.Itoa(int(ns.ID())), IdentifierType: "s", Identifier: "value"},
+			},
+		},
+	}
+
+	client, err := readConfig.createReadClient(testutil.Logger{})
+	require.NoError(t, err)
+	defer client.disconnect() //nolint:errcheck // ignore error on cleanup
+
+	metrics, err := client.currentValues()
+	require.NoError(t, err)
+	require.Len(t, metrics, 1)
+	v, found := metrics[0].GetField("value")
+	require.True(t, found)
+	require.Equal(t, int64(42), v)
+
+	// The values must have been read without creating a session
+	require.True(t, client.sessionless)
+	require.Equal(t, opcua.Connected, client.state())
+	require.Nil(t, client.shards[0].client.Client.Session())
+}
+
+func TestReadClientSessionShardsPartialConnect(t *testing.T) {
+	port := testutil.FreeTCPPort(t)
+
+	srv := server.New(
+		server.EndPoint("127.0.0.1", port),
+		server.EnableSecurity("None", ua.MessageSecurityModeNone),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+	)
+	ns := server.NewNodeNameSpace(srv, "urn:test")
+	ns.AddNode(server.NewVariableNode(ua.NewStringNodeID(ns.ID(), "a"), "a", int32(1)))
+	ns.AddNode(server.NewVariableNode(ua.NewStringNodeID(ns.ID(), "b"), "b", int32(2)))
+	// Cancelling the server context crashes the server, so use the background context
+	require.NoError(t, srv.Start(context.Background()))
+	defer srv.Close()
+
+	// Limit the sessions so the second shard cannot connect
+	readConfig := readClientConfig{
+		SessionShardSize: 1,
+		InputClientConfig: input.InputClientConfig{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       fmt.Sprintf("opc.tcp://127.0.0.1:%d", port),
+				SecurityPolicy: "None",
+				SecurityMode:   "None",
+				AuthMethod:     "Anonymous",
+				ConnectTimeout: config.Duration(time.Second),
+				RequestTimeout: config.Duration(5 * time.Second),
+				MaxSessions:    1,
+			},
+			MetricName: "testing",
+			RootNodes: []input.NodeSettings{
+				{FieldName: "a", Namespace: strconv.Itoa(int(ns.ID())), IdentifierType: "s", Identifier: "a"},
+				{FieldName: "b", Namespace: strconv.Itoa(int(ns.ID())), IdentifierType: "s", Identifier: "b"},
+			},
+		},
+	}
+
+	client, err := readConfig.createReadClient(testutil.Logger{})
+	require.NoError(t, err)
+	require.Len(t, client.shards, 2)
+
+	require.ErrorContains(t, client.connect(), "connect failed for session 2")
+	require.Equal(t, opcua.Connected, client.shards[0].client.State())
+	require.Nil(t, client.shards[1].client.Client)
+	require.Equal(t, opcua.Disconnected, client.state())
+
+	// The diagnostics report the state of each session
+	d, ok := client.diagnostics().(*readDiagnostics)
+	require.True(t, ok)
+	require.Len(t, d.Sessions, 2)
+	require.Equal(t, "Connected", d.Sessions[0].State)
+	require.Equal(t, 1, d.Sessions[0].Nodes)
+	require.Equal(t, "Disconnected", d.Sessions[1].State)
+	require.Equal(t, 1, d.Sessions[1].Nodes)
+
+	// Retrying must keep the working connection of the first shard
+	connected := client.shards[0].client.Client
+	require.ErrorContains(t, client.connect(), "connect failed for session 2")
+	require.Same(t, connected, client.shards[0].client.Client)
+	require.Equal(t, opcua.Connected, client.shards[0].client.State())
+
+	// Disconnecting closes all sessions
+	require.NoError(t, client.disconnect())
+	require.Nil(t, client.shards[0].client.Client)
+	require.Equal(t, opcua.Disconnected, client.state())
+}
+
+func TestStopDisconnectsShards(t *testing.T) {
+	port := testutil.FreeTCPPort(t)
+
+	srv := server.New(
+		server.EndPoint("127.0.0.1", port),
+		server.EnableSecurity("None", ua.MessageSecurityModeNone),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+	)
+	ns := server.NewNodeNameSpace(srv, "urn:test")
+	ns.AddNode(server.NewVariableNode(ua.NewStringNodeID(ns.ID(), "a"), "a", int32(1)))
+	ns.AddNode(server.NewVariableNode(ua.NewStringNodeID(ns.ID(), "b"), "b", int32(2)))
+	// Cancelling the server context crashes the server, so use the background context
+	require.NoError(t, srv.Start(context.Background()))
+	defer srv.Close()
+
+	plugin := &OpcUA{
+		readClientConfig: readClientConfig{
+			SessionShardSize:      1,
+			ReadClientWorkarounds: readClientWorkarounds{UseUnregisteredReads: true},
+			InputClientConfig: input.InputClientConfig{
+				OpcUAClientConfig: opcua.OpcUAClientConfig{
+					Endpoint:       fmt.Sprintf("opc.tcp://127.0.0.1:%d", port),
+					SecurityPolicy: "None",
+					SecurityMode:   "None",
+					AuthMethod:     "Anonymous",
+					ConnectTimeout: config.Duration(5 * time.Second),
+					RequestTimeout: config.Duration(5 * time.Second),
+				},
+				MetricName: "testing",
+				RootNodes: []input.NodeSettings{
+					{FieldName: "a", Namespace: strconv.Itoa(int(ns.ID())), IdentifierType: "s", Identifier: "a"},
+					{FieldName: "b", Namespace: strconv.Itoa(int(ns.ID())), IdentifierType: "s", Identifier: "b"},
+				},
+			},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	require.NoError(t, plugin.Gather(&acc))
+	require.Len(t, acc.GetTelegrafMetrics(), 2)
+
+	shards := plugin.clients[0].shards
+	require.Len(t, shards, 2)
+	for _, shard := range shards {
+		require.NotNil(t, shard.client.Client)
+	}
+
+	plugin.Stop()
+	for _, shard := range shards {
+		require.Nil(t, shard.client.Client)
+	}
+}
+
+func TestSessionlessUnsupported(t *testing.T) {
+	require.True(t, sessionlessUnsupported(fmt.Errorf("reading failed: %w", ua.StatusBadSessionIDInvalid)))
+	require.True(t, sessionlessUnsupported(ua.StatusBadServiceUnsupported))
+	require.True(t, sessionlessUnsupported(ua.StatusBadSessionNotActivated))
+	require.False(t, sessionlessUnsupported(ua.StatusBadTimeout))
+	require.False(t, sessionlessUnsupported(ua.StatusBadUserAccessDenied))
+	require.False(t, sessionlessUnsupported(ua.StatusBadIdentityTokenInvalid))
+	require.False(t, sessionlessUnsupported(ua.StatusBadIdentityTokenRejected))
+	require.False(t, sessionlessUnsupported(errors.New("connection refused")))
+}
+
+func TestMultipleEndpoints(t *testing.T) {
+	plugin := &OpcUA{
+		readClientConfig: readClientConfig{
+			InputClientConfig: input.InputClientConfig{
+				OpcUAClientConfig: opcua.OpcUAClientConfig{
+					SecurityPolicy: "None",
+					SecurityMode:   "None",
+					AuthMethod:     "Anonymous",
+				},
+				MetricName: "testing",
+				RootNodes: []input.NodeSettings{
+					{FieldName: "a", Namespace: "1", IdentifierType: "i", Identifier: "1"},
+				},
+				Endpoints: []string{"opc.tcp://machine1:4840", "opc.tcp://machine2:4840"},
+			},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.Len(t, plugin.clients, 2)
+	for i, client := range plugin.clients {
+		require.Equal(t, plugin.Endpoints[i], client.Config.Endpoint)
+		require.Equal(t, plugin.Endpoints[i], client.OpcUAClient.Config.Endpoint)
+		require.Len(t, client.NodeMetricMapping, 1)
+	}
 }
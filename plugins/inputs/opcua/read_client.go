@@ -4,12 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
+	"reflect"
 	"time"
 
 	"github.com/gopcua/opcua/ua"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
 	"github.com/influxdata/telegraf/plugins/common/opcua"
 	"github.com/influxdata/telegraf/plugins/common/opcua/input"
 	"github.com/influxdata/telegraf/selfstat"
@@ -20,9 +23,16 @@ type readClientWorkarounds struct {
 }
 
 type readClientConfig struct {
-	ReadRetryTimeout      config.Duration       `toml:"read_retry_timeout"`
-	ReadRetries           uint64                `toml:"read_retry_count"`
-	ReadClientWorkarounds readClientWorkarounds `toml:"request_workarounds"`
+	ReadRetryTimeout          config.Duration       `toml:"read_retry_timeout"`
+	ReadRetries               uint64                `toml:"read_retry_count"`
+	SuppressUnchanged         bool                  `toml:"suppress_unchanged"`
+	SuppressToleranceAbsolute float64               `toml:"suppress_tolerance_absolute"`
+	SuppressTolerancePercent  float64               `toml:"suppress_tolerance_percent"`
+	SuppressMaxAge            config.Duration       `toml:"suppress_max_age"`
+	SessionShardSize          int                   `toml:"session_shard_size"`
+	StaleGracePeriod          config.Duration       `toml:"stale_grace_period"`
+	Sessionless               bool                  `toml:"sessionless"`
+	ReadClientWorkarounds     readClientWorkarounds `toml:"request_workarounds"`
 	input.InputClientConfig
 }
 
@@ -37,11 +47,35 @@ type readClient struct {
 	Workarounds      readClientWorkarounds
 
 	// internal values
+	shards      []*readShard
+	ctx         context.Context
+	unchanged   *unchangedFilter
+	staleGrace  time.Duration
+	lastGood    []goodValue
+	sessionless bool
+}
+
+// goodValue is the last value with a good quality received for a node
+type goodValue struct {
+	value input.NodeValue
+	time  time.Time
+}
+
+// readShard is a consecutive range of nodes read using a dedicated session
+type readShard struct {
+	client *opcua.OpcUAClient
+	offset int
+	count  int
 	reqIDs []*ua.ReadValueID
-	ctx    context.Context
 }
 
 func (rc *readClientConfig) createReadClient(log telegraf.Logger) (*readClient, error) {
+	for _, group := range rc.Groups {
+		if group.Browse != nil {
+			return nil, errors.New("browsing groups is only supported by the 'opcua_listener' plugin")
+		}
+	}
+
 	inputClient, err := rc.InputClientConfig.CreateInputClient(log)
 	if err != nil {
 		return nil, err
@@ -55,6 +89,62 @@ func (rc *readClientConfig) createReadClient(log telegraf.Logger) (*readClient,
 		rc.ReadRetryTimeout = config.Duration(100 * time.Millisecond)
 	}
 
+	var unchanged *unchangedFilter
+	if !rc.SuppressUnchanged {
+		switch {
+		case rc.SuppressToleranceAbsolute != 0:
+			return nil, errors.New("'suppress_tolerance_absolute' requires 'suppress_unchanged' to be enabled")
+		case rc.SuppressTolerancePercent != 0:
+			return nil, errors.New("'suppress_tolerance_percent' requires 'suppress_unchanged' to be enabled")
+		case rc.SuppressMaxAge != 0:
+			return nil, errors.New("'suppress_max_age' requires 'suppress_unchanged' to be enabled")
+		}
+	} else {
+		if rc.SuppressToleranceAbsolute < 0 {
+			return nil, errors.New("'suppress_tolerance_absolute' must not be negative")
+		}
+		if rc.SuppressTolerancePercent < 0 {
+			return nil, errors.New("'suppress_tolerance_percent' must not be negative")
+		}
+		unchanged = &unchangedFilter{
+			absolute: rc.SuppressToleranceAbsolute,
+			percent:  rc.SuppressTolerancePercent,
+			maxAge:   time.Duration(rc.SuppressMaxAge),
+			last:     make([]emittedValue, len(inputClient.NodeMetricMapping)),
+		}
+	}
+
+	if rc.Sessionless && rc.AuthMethod != "" && rc.AuthMethod != "Anonymous" {
+		return nil, errors.New("'sessionless' requires anonymous authentication")
+	}
+
+	if rc.SessionShardSize < 0 {
+		return nil, errors.New("'session_shard_size' must not be negative")
+	}
+
+	// Split the nodes into shards each using its own session. The first shard
+	// always uses the client of the input.
+	count := len(inputClient.NodeMetricMapping)
+	size := rc.SessionShardSize
+	if size == 0 || size > count {
+		size = count
+	}
+	shards := []*readShard{{client: inputClient.OpcUAClient, count: size}}
+	for offset := size; offset < count; offset += size {
+		client, err := rc.InputClientConfig.OpcUAClientConfig.CreateClient(log)
+		if err != nil {
+			return nil, fmt.Errorf("creating client for session %d failed: %w", len(shards)+1, err)
+		}
+		shards = append(shards, &readShard{
+			client: client,
+			offset: offset,
+			count:  min(size, count-offset),
+		})
+	}
+	if len(shards) > 1 {
+		log.Debugf("Splitting %d nodes across %d sessions", count, len(shards))
+	}
+
 	return &readClient{
 		OpcUAInputClient: inputClient,
 		ReadRetryTimeout: time.Duration(rc.ReadRetryTimeout),
@@ -62,14 +152,34 @@ func (rc *readClientConfig) createReadClient(log telegraf.Logger) (*readClient,
 		ReadSuccess:      selfstat.Register("opcua", "read_success", tags),
 		ReadError:        selfstat.Register("opcua", "read_error", tags),
 		Workarounds:      rc.ReadClientWorkarounds,
+		shards:           shards,
+		unchanged:        unchanged,
+		staleGrace:       time.Duration(rc.StaleGracePeriod),
+		lastGood:         make([]goodValue, count),
+		sessionless:      rc.Sessionless,
 	}, nil
 }
 
 func (o *readClient) connect() error {
 	o.ctx = context.Background()
 
-	if err := o.OpcUAClient.Connect(o.ctx); err != nil {
-		return fmt.Errorf("connect failed: %w", err)
+	for i, shard := range o.shards {
+		// Keep the sessions of shards still connected, e.g. after a partial
+		// failure, instead of dropping working connections. Those sessions
+		// are reused on retry and closed when stopping the plugin.
+		if shard.client.State() == opcua.Connected {
+			continue
+		}
+		connect := shard.client.Connect
+		if o.sessionless {
+			connect = shard.client.ConnectSessionless
+		}
+		if err := connect(o.ctx); err != nil {
+			if len(o.shards) == 1 {
+				return fmt.Errorf("connect failed: %w", err)
+			}
+			return fmt.Errorf("connect failed for session %d: %w", i+1, err)
+		}
 	}
 
 	// Make sure we setup the node-ids correctly after reconnect
@@ -78,75 +188,191 @@ func (o *readClient) connect() error {
 		return fmt.Errorf("initializing node IDs failed: %w", err)
 	}
 
-	o.reqIDs = make([]*ua.ReadValueID, 0, len(o.NodeIDs))
-	if o.Workarounds.UseUnregisteredReads {
-		for _, nid := range o.NodeIDs {
-			o.reqIDs = append(o.reqIDs, &ua.ReadValueID{NodeID: nid})
+	if err := o.OpcUAInputClient.CheckAccessLevels(o.ctx); err != nil {
+		return err
+	}
+
+	for _, shard := range o.shards {
+		nodeIDs := o.NodeIDs[shard.offset : shard.offset+shard.count]
+		shard.reqIDs = make([]*ua.ReadValueID, 0, len(nodeIDs))
+		// Registering nodes requires a session
+		if o.Workarounds.UseUnregisteredReads || o.sessionless {
+			for _, nid := range nodeIDs {
+				shard.reqIDs = append(shard.reqIDs, &ua.ReadValueID{NodeID: nid})
+			}
+			continue
 		}
-	} else {
-		regResp, err := o.Client.RegisterNodes(o.ctx, &ua.RegisterNodesRequest{
-			NodesToRegister: o.NodeIDs,
+
+		regResp, err := shard.client.Client.RegisterNodes(o.ctx, &ua.RegisterNodesRequest{
+			NodesToRegister: nodeIDs,
 		})
 		if err != nil {
 			return fmt.Errorf("registering nodes failed: %w", err)
 		}
 
 		for _, v := range regResp.RegisteredNodeIDs {
-			o.reqIDs = append(o.reqIDs, &ua.ReadValueID{NodeID: v})
+			shard.reqIDs = append(shard.reqIDs, &ua.ReadValueID{NodeID: v})
 		}
 	}
 
 	if err := o.read(); err != nil {
+		if o.sessionless && sessionlessUnsupported(err) {
+			o.Log.Infof("Server does not support session-less reads (%v), falling back to sessions", err)
+			o.sessionless = false
+			if derr := o.disconnect(); derr != nil {
+				o.Log.Debug("Error while disconnecting: ", derr)
+			}
+			return o.connect()
+		}
 		return fmt.Errorf("get data failed: %w", err)
 	}
 
 	return nil
 }
 
+// sessionlessUnsupported returns true if the error indicates the server
+// does not allow session-less service invocation. Permission and credential
+// errors are not considered as those would also fail with a session.
+func sessionlessUnsupported(err error) bool {
+	for _, code := range []ua.StatusCode{
+		ua.StatusBadSessionIDInvalid,
+		ua.StatusBadSessionNotActivated,
+		ua.StatusBadServiceUnsupported,
+	} {
+		if errors.Is(err, code) {
+			return true
+		}
+	}
+	return false
+}
+
+func (o *readClient) disconnect() error {
+	var errs []error
+	for _, shard := range o.shards {
+		if shard.client.Client == nil {
+			continue
+		}
+		if err := shard.client.Disconnect(context.Background()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// state returns the "worst" connection state of all sessions
+func (o *readClient) state() opcua.ConnectionState {
+	for _, shard := range o.shards {
+		if state := shard.client.State(); state != opcua.Connected {
+			return state
+		}
+	}
+	return opcua.Connected
+}
+
 func (o *readClient) ensureConnected() error {
-	if o.State() == opcua.Disconnected || o.State() == opcua.Closed {
+	if state := o.state(); state == opcua.Disconnected || state == opcua.Closed {
 		return o.connect()
 	}
 	return nil
 }
 
+// currentValues returns the metrics of the current read cycle. In case
+// reading fails, the last good values are returned marked as stale
+// if a grace period is configured.
 func (o *readClient) currentValues() ([]telegraf.Metric, error) {
+	now := o.Now()
+	if err := o.readValues(); err != nil {
+		return o.staleValues(now), err
+	}
+
+	metrics := make([]telegraf.Metric, 0, len(o.NodeMetricMapping))
+	// Parse the resulting data into metrics
+	for i := range o.NodeIDs {
+		if !o.StatusCodeOK(o.LastReceivedData[i].Quality) {
+			continue
+		}
+
+		if o.staleGrace > 0 {
+			o.lastGood[i] = goodValue{value: o.LastReceivedData[i], time: now}
+		}
+
+		// Skip values that did not change since they were last reported
+		if o.unchanged != nil && !o.unchanged.update(i, &o.LastReceivedData[i], now) {
+			continue
+		}
+
+		metrics = append(metrics, o.MetricForNode(i))
+	}
+
+	return metrics, nil
+}
+
+func (o *readClient) readValues() error {
 	if err := o.ensureConnected(); err != nil {
-		return nil, err
+		return err
 	}
 
-	if state := o.State(); state != opcua.Connected {
-		return nil, fmt.Errorf("not connected, in state %q", state)
+	if state := o.state(); state != opcua.Connected {
+		return fmt.Errorf("not connected, in state %q", state)
 	}
 
 	if err := o.read(); err != nil {
 		// We do not return the disconnect error, as this would mask the
 		// original problem, but we do log it
-		if derr := o.Disconnect(context.Background()); derr != nil {
+		if derr := o.disconnect(); derr != nil {
 			o.Log.Debug("Error while disconnecting: ", derr)
 		}
 
-		return nil, err
+		return err
 	}
 
-	metrics := make([]telegraf.Metric, 0, len(o.NodeMetricMapping))
-	// Parse the resulting data into metrics
-	for i := range o.NodeIDs {
-		if !o.StatusCodeOK(o.LastReceivedData[i].Quality) {
+	return nil
+}
+
+// staleValues returns the last good value of each node received within the
+// grace period tagged as stale and with the age of the value in seconds.
+func (o *readClient) staleValues(now time.Time) []telegraf.Metric {
+	if o.staleGrace <= 0 {
+		return nil
+	}
+
+	metrics := make([]telegraf.Metric, 0, len(o.lastGood))
+	for i, last := range o.lastGood {
+		age := now.Sub(last.time)
+		if last.time.IsZero() || age > o.staleGrace {
 			continue
 		}
 
-		metrics = append(metrics, o.MetricForNode(i))
+		// Restore the last good value as the current value might be invalid
+		o.LastReceivedData[i] = last.value
+		m := o.MetricForNode(i)
+		m.AddTag("stale", "true")
+		m.AddField("Age", age.Seconds())
+		metrics = append(metrics, m)
+
+		// Make sure the value is reported after recovering from the failure
+		if o.unchanged != nil {
+			o.unchanged.last[i].valid = false
+		}
 	}
 
-	return metrics, nil
+	return metrics
 }
 
 func (o *readClient) read() error {
+	for _, shard := range o.shards {
+		if err := o.readShard(shard); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *readClient) readShard(shard *readShard) error {
 	req := &ua.ReadRequest{
 		MaxAge:             2000,
 		TimestampsToReturn: ua.TimestampsToReturnBoth,
-		NodesToRead:        o.reqIDs,
+		NodesToRead:        shard.reqIDs,
 	}
 
 	var count uint64
@@ -154,12 +380,12 @@ func (o *readClient) read() error {
 		count++
 
 		// Try to update the values for all registered nodes
-		resp, err := o.Client.Read(o.ctx, req)
+		resp, err := shard.client.Client.Read(o.ctx, req)
 		if err == nil {
 			// Success, update the node values and exit
 			o.ReadSuccess.Incr(1)
 			for i, d := range resp.Results {
-				o.UpdateNodeValue(i, d)
+				o.UpdateNodeValue(shard.offset+i, d)
 			}
 			return nil
 		}
@@ -181,3 +407,61 @@ func (o *readClient) read() error {
 		}
 	}
 }
+
+// emittedValue is the last value reported for a node
+type emittedValue struct {
+	valid   bool
+	value   interface{}
+	quality ua.StatusCode
+	time    time.Time
+}
+
+// unchangedFilter implements report-by-exception by suppressing values that
+// did not change (within the given tolerances) since they were last reported.
+type unchangedFilter struct {
+	absolute float64
+	percent  float64
+	maxAge   time.Duration
+	last     []emittedValue
+}
+
+// update returns true if the value of the given node should be reported and
+// remembers the value as the last reported one in this case.
+func (f *unchangedFilter) update(idx int, v *input.NodeValue, now time.Time) bool {
+	last := &f.last[idx]
+	if last.valid && last.quality == v.Quality && !f.expired(last, now) && f.equal(last.value, v.Value) {
+		return false
+	}
+
+	last.valid = true
+	last.value = v.Value
+	last.quality = v.Quality
+	last.time = now
+	return true
+}
+
+func (f *unchangedFilter) expired(last *emittedValue, now time.Time) bool {
+	return f.maxAge > 0 && now.Sub(last.time) >= f.maxAge
+}
+
+func (f *unchangedFilter) equal(previous, current interface{}) bool {
+	p, pok := toNumber(previous)
+	c, cok := toNumber(current)
+	if !pok || !cok {
+		return reflect.DeepEqual(previous, current)
+	}
+
+	diff := math.Abs(c - p)
+	return diff <= f.absolute || diff <= math.Abs(p)*f.percent/100.0
+}
+
+// toNumber converts numeric values to float, other types such as strings,
+// booleans or arrays are compared verbatim.
+func toNumber(v interface{}) (float64, bool) {
+	switch v.(type) {
+	case int8, int16, int32, int64, uint8, uint16, uint32, uint64, float32, float64:
+		f, err := internal.ToFloat64(v)
+		return f, err == nil
+	}
+	return 0, false
+}
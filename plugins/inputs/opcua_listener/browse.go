@@ -0,0 +1,232 @@
+package opcua_listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/influxdata/telegraf/filter"
+	opcuaclient "github.com/influxdata/telegraf/plugins/common/opcua"
+	"github.com/influxdata/telegraf/plugins/common/opcua/input"
+)
+
+// browseGroup is a group subscribing to all variables found below an object
+type browseGroup struct {
+	root       *ua.NodeID
+	filter     filter.Filter
+	metricName string
+	tags       map[string]string
+	params     input.MonitoringParameters
+
+	// nodes found while browsing indexed by the node id
+	nodes map[string]*browsedNode
+}
+
+// browsedNode is a variable found while browsing
+type browsedNode struct {
+	handle    uint32
+	itemID    uint32
+	monitored bool
+}
+
+func newBrowseGroup(metricName string, group input.NodeGroupSettings) (*browseGroup, error) {
+	settings := *group.Browse
+	if settings.Namespace == "" {
+		settings.Namespace = group.Namespace
+	}
+	if settings.IdentifierType == "" {
+		settings.IdentifierType = group.IdentifierType
+	}
+	root, err := ua.ParseNodeID(settings.NodeID())
+	if err != nil {
+		return nil, fmt.Errorf("invalid node to browse %q: %w", settings.NodeID(), err)
+	}
+
+	f, err := filter.NewIncludeExcludeFilter(settings.Include, settings.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("creating browse name filter for %q failed: %w", settings.NodeID(), err)
+	}
+
+	if group.MetricName != "" {
+		metricName = group.MetricName
+	}
+
+	return &browseGroup{
+		root:       root,
+		filter:     f,
+		metricName: metricName,
+		tags:       group.DefaultTags,
+		params:     input.MonitoringParameters{SamplingInterval: group.SamplingInterval},
+		nodes:      make(map[string]*browsedNode),
+	}, nil
+}
+
+// browse returns all variables below the object of the group matching the
+// filter
+func (o *subscribeClient) browse(ctx context.Context, g *browseGroup) ([]opcuaclient.BrowsedVariable, error) {
+	variables, err := o.BrowseVariables(ctx, g.root, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]opcuaclient.BrowsedVariable, 0, len(variables))
+	for _, v := range variables {
+		if g.filter.Match(v.BrowseName) {
+			found = append(found, v)
+		}
+	}
+	return found, nil
+}
+
+// syncBrowsed browses all groups and adjusts the monitored items to the
+// variables found. If resubscribe is set, the monitored items of all
+// variables are created as the subscription was replaced.
+func (o *subscribeClient) syncBrowsed(ctx context.Context, resubscribe bool) error {
+	o.browseLock.Lock()
+	defer o.browseLock.Unlock()
+
+	for _, g := range o.browseGroups {
+		variables, err := o.browse(ctx, g)
+		if err != nil {
+			return err
+		}
+
+		if resubscribe {
+			for _, n := range g.nodes {
+				n.monitored = false
+			}
+		}
+
+		// Create monitored items for new variables
+		seen := make(map[string]bool, len(variables))
+		reqs := make([]*ua.MonitoredItemCreateRequest, 0, len(variables))
+		added := make([]*browsedNode, 0, len(variables))
+		for _, v := range variables {
+			key := v.NodeID.String()
+			seen[key] = true
+
+			n, found := g.nodes[key]
+			if !found {
+				n, err = o.addBrowsedNode(g, v)
+				if err != nil {
+					return err
+				}
+				g.nodes[key] = n
+			}
+			if n.monitored {
+				continue
+			}
+
+			req := opcua.NewMonitoredItemCreateRequestWithDefaults(v.NodeID, ua.AttributeIDValue, n.handle)
+			if err := assignConfigValuesToRequest(req, &g.params); err != nil {
+				return err
+			}
+			reqs = append(reqs, req)
+			added = append(added, n)
+		}
+
+		// Remove monitored items of variables that disappeared
+		var removed []uint32
+		for key, n := range g.nodes {
+			if !seen[key] && n.monitored {
+				removed = append(removed, n.itemID)
+				n.monitored = false
+			}
+		}
+
+		if len(reqs) > 0 {
+			resp, err := o.sub.Monitor(ctx, ua.TimestampsToReturnBoth, reqs...)
+			if err != nil {
+				return fmt.Errorf("failed to start monitoring browsed items: %w", err)
+			}
+			if len(resp.Results) != len(reqs) {
+				return fmt.Errorf("expected %d monitoring results but got %d", len(reqs), len(resp.Results))
+			}
+			for i, res := range resp.Results {
+				if !o.StatusCodeOK(res.StatusCode) {
+					o.Log.Warnf("Failed to create monitored item for node %q: %v", reqs[i].ItemToMonitor.NodeID, res.StatusCode)
+					continue
+				}
+				added[i].itemID = res.MonitoredItemID
+				added[i].monitored = true
+			}
+			o.checkRevisedSamplingIntervals(reqs, resp.Results)
+		}
+
+		if len(removed) > 0 {
+			if _, err := o.sub.Unmonitor(ctx, removed...); err != nil {
+				o.Log.Warnf("Failed to stop monitoring items removed below %q: %v", g.root, err)
+			}
+		}
+		o.Log.Debugf("Browsing %q found %d variables, added %d and removed %d monitored items",
+			g.root, len(variables), len(reqs), len(removed))
+	}
+
+	var monitored int
+	for _, g := range o.browseGroups {
+		for _, n := range g.nodes {
+			if n.monitored {
+				monitored++
+			}
+		}
+	}
+	o.setMonitoredItems(func(items *monitoredItemsDiagnostics) { items.Browsed = monitored })
+
+	return nil
+}
+
+// addBrowsedNode registers the variable with the input client using the next
+// free handle
+func (o *subscribeClient) addBrowsedNode(g *browseGroup, v opcuaclient.BrowsedVariable) (*browsedNode, error) {
+	namespace, idType, identifier, err := opcuaclient.NodeIDParts(v.NodeID)
+	if err != nil {
+		return nil, err
+	}
+	node := input.NodeSettings{
+		FieldName:        v.BrowseName,
+		Namespace:        namespace,
+		IdentifierType:   idType,
+		Identifier:       identifier,
+		MonitoringParams: g.params,
+	}
+
+	nmm, err := input.NewNodeMetricMapping(g.metricName, node, g.tags)
+	if err != nil {
+		return nil, err
+	}
+
+	o.nodesLock.Lock()
+	defer o.nodesLock.Unlock()
+
+	handle := uint32(len(o.NodeMetricMapping))
+	o.NodeMetricMapping = append(o.NodeMetricMapping, *nmm)
+	o.NodeIDs = append(o.NodeIDs, v.NodeID)
+	o.LastReceivedData = append(o.LastReceivedData, input.NodeValue{TagName: node.FieldName})
+
+	return &browsedNode{handle: handle}, nil
+}
+
+// rebrowse periodically synchronizes the monitored items with the variables
+// found when browsing until the client is stopped
+func (o *subscribeClient) rebrowse(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.ctx.Done():
+			return
+		case <-ticker.C:
+			if o.State() != opcuaclient.Connected {
+				continue
+			}
+			if err := o.syncBrowsed(o.ctx, false); err != nil && !errors.Is(err, context.Canceled) {
+				o.Log.Errorf("Re-browsing failed: %v", err)
+			}
+		}
+	}
+}
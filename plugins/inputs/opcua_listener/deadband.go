@@ -0,0 +1,112 @@
+package opcua_listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/opcua/input"
+)
+
+// deadband is a percent deadband applied on the client side for servers
+// rejecting percent deadband filters
+type deadband struct {
+	threshold float64
+	last      float64
+	reported  bool
+
+	// span of the EURange of the node
+	span float64
+}
+
+// setPercent sets the threshold to the given percentage of the EURange
+func (d *deadband) setPercent(percent float64) {
+	d.threshold = percent / 100 * d.span
+}
+
+// exceeded returns true if the value has to be reported, i.e. if the value
+// changed by more than the threshold since it was last reported or if the
+// quality changed
+func (d *deadband) exceeded(quality ua.StatusCode, dv *ua.DataValue) bool {
+	if dv.Value == nil {
+		return true
+	}
+	v, err := internal.ToFloat64(dv.Value.Value())
+	if err != nil {
+		return true
+	}
+	if d.reported && dv.Status == quality && math.Abs(v-d.last) <= d.threshold {
+		return false
+	}
+	d.last = v
+	d.reported = true
+	return true
+}
+
+// checkPercentDeadbands verifies that all nodes using a percent deadband
+// provide the EURange property of AnalogItems and sets up the client side
+// deadbands
+func (o *subscribeClient) checkPercentDeadbands(ctx context.Context) error {
+	for i := range o.monitoredItemsReqs {
+		node := &o.NodeMetricMapping[i].Tag
+		f := node.MonitoringParams.DataChangeFilter
+		if f == nil || f.DeadbandType != input.Percent {
+			continue
+		}
+
+		euRange, err := o.readEURange(ctx, o.NodeIDs[i])
+		if err != nil {
+			return fmt.Errorf("node %q (%s) uses a percent deadband but %w", node.FieldName, o.NodeIDs[i], err)
+		}
+		if !f.ClientSide {
+			continue
+		}
+
+		o.nodesLock.Lock()
+		d, found := o.deadbands[i]
+		if !found {
+			d = &deadband{}
+			o.deadbands[i] = d
+		}
+		d.span = euRange.High - euRange.Low
+		d.setPercent(o.deadbandValue(i))
+		o.nodesLock.Unlock()
+	}
+
+	return nil
+}
+
+// readEURange reads the EURange property of the given node
+func (o *subscribeClient) readEURange(ctx context.Context, nid *ua.NodeID) (*ua.Range, error) {
+	refs, err := o.Client.Node(nid).References(ctx, id.HasProperty, ua.BrowseDirectionForward, ua.NodeClassVariable, true)
+	if err != nil {
+		return nil, fmt.Errorf("browsing its properties failed: %w", err)
+	}
+
+	for _, ref := range refs {
+		if ref.BrowseName == nil || ref.BrowseName.Name != "EURange" || ref.NodeID == nil {
+			continue
+		}
+
+		v, err := o.Client.Node(ref.NodeID.NodeID).Value(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("reading its EURange failed: %w", err)
+		}
+		if eo, ok := v.Value().(*ua.ExtensionObject); ok {
+			if r, ok := eo.Value.(*ua.Range); ok {
+				if r.High <= r.Low {
+					return nil, fmt.Errorf("its EURange [%v, %v] is empty", r.Low, r.High)
+				}
+				return r, nil
+			}
+		}
+		return nil, fmt.Errorf("its EURange has unexpected type %T", v.Value())
+	}
+
+	return nil, errors.New("has no EURange property; percent deadbands require AnalogItem nodes")
+}
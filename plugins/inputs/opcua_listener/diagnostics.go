@@ -0,0 +1,76 @@
+package opcua_listener
+
+import (
+	opcuaclient "github.com/influxdata/telegraf/plugins/common/opcua"
+)
+
+// diagnostics is the state of the client reported by the diagnostics endpoint
+type diagnostics struct {
+	opcuaclient.ClientDiagnostics
+	Subscription   *subscriptionDiagnostics     `json:"subscription,omitempty"`
+	MonitoredItems monitoredItemsDiagnostics    `json:"monitored_items"`
+	Buffers        map[string]bufferDiagnostics `json:"buffers"`
+}
+
+type subscriptionDiagnostics struct {
+	ID                 uint32 `json:"id"`
+	PublishingInterval string `json:"publishing_interval"`
+	LifetimeCount      uint32 `json:"lifetime_count"`
+	MaxKeepAliveCount  uint32 `json:"max_keep_alive_count"`
+}
+
+type monitoredItemsDiagnostics struct {
+	Nodes   int `json:"nodes"`
+	Browsed int `json:"browsed"`
+	Events  int `json:"events"`
+}
+
+type bufferDiagnostics struct {
+	Length   int `json:"length"`
+	Capacity int `json:"capacity"`
+}
+
+// setSubscription records the current subscription for the diagnostics
+func (o *subscribeClient) setSubscription(sub *subscription) {
+	o.diagLock.Lock()
+	defer o.diagLock.Unlock()
+
+	if sub == nil {
+		o.diagSubscription = nil
+		return
+	}
+	o.diagSubscription = &subscriptionDiagnostics{
+		ID:                 sub.SubscriptionID,
+		PublishingInterval: sub.RevisedPublishingInterval.String(),
+		LifetimeCount:      sub.RevisedLifetimeCount,
+		MaxKeepAliveCount:  sub.RevisedMaxKeepAliveCount,
+	}
+}
+
+// setMonitoredItems records the number of monitored items for the diagnostics
+func (o *subscribeClient) setMonitoredItems(f func(items *monitoredItemsDiagnostics)) {
+	o.diagLock.Lock()
+	defer o.diagLock.Unlock()
+
+	f(&o.diagMonitoredItems)
+}
+
+// diagnostics returns the current state of the client
+func (o *subscribeClient) diagnostics() interface{} {
+	o.diagLock.Lock()
+	defer o.diagLock.Unlock()
+
+	d := &diagnostics{
+		ClientDiagnostics: o.OpcUAClient.Diagnostics(),
+		MonitoredItems:    o.diagMonitoredItems,
+		Buffers: map[string]bufferDiagnostics{
+			"data_notifications": {Length: len(o.dataNotifications), Capacity: cap(o.dataNotifications)},
+			"metrics":            {Length: len(o.metrics), Capacity: cap(o.metrics)},
+		},
+	}
+	if o.diagSubscription != nil {
+		sub := *o.diagSubscription
+		d.Subscription = &sub
+	}
+	return d
+}
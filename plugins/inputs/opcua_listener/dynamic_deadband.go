@@ -0,0 +1,191 @@
+package opcua_listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/influxdata/telegraf/internal"
+	opcuaclient "github.com/influxdata/telegraf/plugins/common/opcua"
+	"github.com/influxdata/telegraf/plugins/common/opcua/input"
+)
+
+// dynamicDeadband is a deadband whose value is read from a node of the server
+type dynamicDeadband struct {
+	source *ua.NodeID
+	value  float64
+	known  bool
+}
+
+// deadbandValue returns the current deadband value of the node with the
+// given handle
+func (o *subscribeClient) deadbandValue(idx int) float64 {
+	if d, found := o.dynamicDeadbands[idx]; found && d.known {
+		return d.value
+	}
+	if f := o.NodeMetricMapping[idx].Tag.MonitoringParams.DataChangeFilter; f != nil && f.DeadbandValue != nil {
+		return *f.DeadbandValue
+	}
+	return 0
+}
+
+// readDynamicDeadbands reads the deadband values from the source nodes and
+// returns the handles of the nodes with a changed value. Nodes without a
+// readable value keep their previous value or fall back to the static
+// 'deadband_value', an error is returned if neither exists. The caller must
+// hold the deadband lock.
+func (o *subscribeClient) readDynamicDeadbands(ctx context.Context) ([]int, error) {
+	if len(o.dynamicDeadbands) == 0 {
+		return nil, nil
+	}
+
+	handles := make([]int, 0, len(o.dynamicDeadbands))
+	req := &ua.ReadRequest{
+		NodesToRead:        make([]*ua.ReadValueID, 0, len(o.dynamicDeadbands)),
+		TimestampsToReturn: ua.TimestampsToReturnNeither,
+	}
+	for idx, d := range o.dynamicDeadbands {
+		handles = append(handles, idx)
+		req.NodesToRead = append(req.NodesToRead, &ua.ReadValueID{NodeID: d.source, AttributeID: ua.AttributeIDValue})
+	}
+
+	resp, err := o.Client.Read(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("reading deadband values failed: %w", err)
+	}
+	if len(resp.Results) != len(handles) {
+		return nil, fmt.Errorf("expected %d deadband values but got %d", len(handles), len(resp.Results))
+	}
+
+	var changed []int
+	for i, res := range resp.Results {
+		idx := handles[i]
+		d := o.dynamicDeadbands[idx]
+		node := &o.NodeMetricMapping[idx].Tag
+
+		value, err := deadbandFromDataValue(res, node.MonitoringParams.DataChangeFilter.DeadbandType)
+		if err != nil {
+			if !d.known && node.MonitoringParams.DataChangeFilter.DeadbandValue == nil {
+				return nil, fmt.Errorf("node %q: deadband value from %q: %w", node.FieldName, d.source, err)
+			}
+			o.Log.Warnf("Node %q: keeping current deadband value as reading it from %q failed: %v", node.FieldName, d.source, err)
+			continue
+		}
+		if d.known && d.value == value {
+			continue
+		}
+		o.Log.Debugf("Node %q: deadband value changed to %v", node.FieldName, value)
+		d.value = value
+		d.known = true
+		changed = append(changed, idx)
+	}
+
+	return changed, nil
+}
+
+func deadbandFromDataValue(dv *ua.DataValue, deadbandType input.DeadbandType) (float64, error) {
+	if dv.Status != ua.StatusOK {
+		return 0, fmt.Errorf("reading failed: %w", dv.Status)
+	}
+	if dv.Value == nil {
+		return 0, errors.New("no value")
+	}
+	value, err := internal.ToFloat64(dv.Value.Value())
+	if err != nil {
+		return 0, fmt.Errorf("invalid value: %w", err)
+	}
+	if value < 0 {
+		return 0, fmt.Errorf("negative value %v not supported", value)
+	}
+	if deadbandType == input.Percent && value > 100 {
+		return 0, fmt.Errorf("value %v above 100 percent not supported", value)
+	}
+	return value, nil
+}
+
+// applyDynamicDeadbands updates the monitored item requests and the client
+// side deadbands of the given nodes to the current deadband value. If
+// modify is set, the monitored items are modified on the server. The caller
+// must hold the deadband lock.
+func (o *subscribeClient) applyDynamicDeadbands(ctx context.Context, handles []int, modify bool) error {
+	items := make([]*ua.MonitoredItemModifyRequest, 0, len(handles))
+	for _, idx := range handles {
+		value := o.deadbandValue(idx)
+
+		o.nodesLock.Lock()
+		if d, found := o.deadbands[idx]; found {
+			d.setPercent(value)
+		}
+		o.nodesLock.Unlock()
+
+		params := o.monitoredItemsReqs[idx].RequestedParameters
+		filter, ok := params.Filter.Value.(*ua.DataChangeFilter)
+		if !ok || filter.DeadbandType == uint32(ua.DeadbandTypeNone) {
+			// Client side deadband
+			continue
+		}
+		filter.DeadbandValue = value
+		if modify && o.monitoredItemIDs[idx] != 0 {
+			items = append(items, &ua.MonitoredItemModifyRequest{
+				MonitoredItemID:     o.monitoredItemIDs[idx],
+				RequestedParameters: params,
+			})
+		}
+	}
+
+	if len(items) == 0 {
+		return nil
+	}
+
+	resp, err := o.sub.ModifyMonitoredItems(ctx, ua.TimestampsToReturnBoth, items...)
+	if err != nil {
+		return fmt.Errorf("modifying monitored items failed: %w", err)
+	}
+	if resp.ResponseHeader != nil && resp.ResponseHeader.ServiceResult != ua.StatusOK {
+		return fmt.Errorf("modifying monitored items failed: %w", resp.ResponseHeader.ServiceResult)
+	}
+	for i, res := range resp.Results {
+		if !o.StatusCodeOK(res.StatusCode) {
+			handle := items[i].RequestedParameters.ClientHandle
+			o.Log.Warnf("Modifying deadband of node %q failed: %v", o.NodeMetricMapping[handle].Tag.FieldName, res.StatusCode)
+		}
+	}
+	return nil
+}
+
+// refreshDynamicDeadbands periodically re-reads the deadband values and
+// modifies the monitored items on changes until the client is stopped
+func (o *subscribeClient) refreshDynamicDeadbands(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := o.refreshDeadbands(o.ctx); err != nil && !errors.Is(err, context.Canceled) {
+				o.Log.Errorf("Refreshing deadband values failed: %v", err)
+			}
+		}
+	}
+}
+
+// refreshDeadbands re-reads the deadband values and modifies the monitored
+// items of the current subscription on changes
+func (o *subscribeClient) refreshDeadbands(ctx context.Context) error {
+	o.deadbandLock.Lock()
+	defer o.deadbandLock.Unlock()
+
+	if o.State() != opcuaclient.Connected {
+		return nil
+	}
+	changed, err := o.readDynamicDeadbands(ctx)
+	if err != nil {
+		return err
+	}
+	return o.applyDynamicDeadbands(ctx, changed, true)
+}
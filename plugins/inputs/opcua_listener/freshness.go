@@ -0,0 +1,58 @@
+package opcua_listener
+
+import (
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// nodeFreshness tracks the updates received for a node independent of
+// whether the update resulted in a metric
+type nodeFreshness struct {
+	lastUpdate time.Time
+	updates    int64
+}
+
+// recordUpdate registers an update of the node with the given handle, the
+// caller has to hold the nodes lock
+func (o *subscribeClient) recordUpdate(idx int, t time.Time) {
+	if o.Config.FreshnessMetric == "" {
+		return
+	}
+	// Nodes found by browsing are added after the start
+	for len(o.freshness) <= idx {
+		o.freshness = append(o.freshness, nodeFreshness{})
+	}
+	o.freshness[idx].lastUpdate = t
+	o.freshness[idx].updates++
+}
+
+// freshnessMetrics returns a metric per monitored node reporting the age
+// of the last update and the number of updates since the previous call
+func (o *subscribeClient) freshnessMetrics(now time.Time) []telegraf.Metric {
+	o.nodesLock.Lock()
+	defer o.nodesLock.Unlock()
+
+	for len(o.freshness) < len(o.NodeMetricMapping) {
+		o.freshness = append(o.freshness, nodeFreshness{})
+	}
+
+	metrics := make([]telegraf.Metric, 0, len(o.NodeMetricMapping))
+	for i := range o.NodeMetricMapping {
+		f := &o.freshness[i]
+		tags := o.TagsForNode(i)
+		tags["field"] = o.NodeMetricMapping[i].Tag.FieldName
+		fields := map[string]interface{}{
+			"updates": f.updates,
+		}
+		// Nodes without any update so far have no age
+		if !f.lastUpdate.IsZero() {
+			fields["age_ms"] = now.Sub(f.lastUpdate).Milliseconds()
+		}
+		f.updates = 0
+
+		metrics = append(metrics, metric.New(o.Config.FreshnessMetric, tags, fields, now))
+	}
+	return metrics
+}
@@ -0,0 +1,88 @@
+package opcua_listener
+
+import (
+	"math"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/influxdata/telegraf"
+)
+
+// toMilliseconds converts the duration to (fractional) milliseconds as used
+// by OPC UA for intervals without truncating sub-millisecond precision
+func toMilliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// fromMilliseconds converts the (fractional) OPC UA milliseconds to a duration
+func fromMilliseconds(ms float64) time.Duration {
+	return time.Duration(math.Round(ms * float64(time.Millisecond)))
+}
+
+// checkIntervals warns about interval settings which cannot be achieved
+// with the given configuration
+func (sc *subscribeClientConfig) checkIntervals(log telegraf.Logger, reqs []*ua.MonitoredItemCreateRequest) {
+	subscriptionInterval := time.Duration(sc.SubscriptionInterval)
+	if subscriptionInterval%time.Millisecond != 0 {
+		log.Warnf("The client library only supports full milliseconds for the subscription interval, %s will be requested as %s",
+			subscriptionInterval, subscriptionInterval.Truncate(time.Millisecond))
+	}
+
+	for _, req := range reqs {
+		params := req.RequestedParameters
+		if params.SamplingInterval <= 0 || subscriptionInterval <= 0 {
+			continue
+		}
+		sampling := fromMilliseconds(params.SamplingInterval)
+		if sampling >= subscriptionInterval {
+			continue
+		}
+
+		// Number of samples which may be taken in between two publishing
+		// cycles, all exceeding the queue size are discarded by the server
+		samples := uint64(math.Ceil(float64(subscriptionInterval) / float64(sampling)))
+		queueSize := params.QueueSize
+		if queueSize == 0 {
+			queueSize = 1
+		}
+		if samples > uint64(queueSize) {
+			log.Warnf("Node %q is sampled every %s but published every %s; up to %d values per publishing interval "+
+				"exceed the queue size of %d and will be discarded",
+				req.ItemToMonitor.NodeID, sampling, subscriptionInterval, samples, queueSize)
+		}
+	}
+}
+
+// checkRevisedSamplingIntervals logs the sampling intervals revised by the
+// server if they differ from the requested ones
+func (o *subscribeClient) checkRevisedSamplingIntervals(reqs []*ua.MonitoredItemCreateRequest, results []*ua.MonitoredItemCreateResult) {
+	var revised int
+	for i, res := range results {
+		if i >= len(reqs) || !o.StatusCodeOK(res.StatusCode) {
+			continue
+		}
+		requested := reqs[i].RequestedParameters.SamplingInterval
+		// A sampling interval of zero requests the fastest rate supported
+		// by the server so any revision is expected
+		if requested <= 0 || res.RevisedSamplingInterval == requested {
+			continue
+		}
+		revised++
+		o.Log.Debugf("Server revised sampling interval of node %q from %s to %s",
+			reqs[i].ItemToMonitor.NodeID, fromMilliseconds(requested), fromMilliseconds(res.RevisedSamplingInterval))
+	}
+	if revised > 0 {
+		o.Log.Warnf("Server revised the sampling interval of %d monitored items, see debug output for details", revised)
+	}
+}
+
+// checkRevisedPublishingInterval warns if the server revised the requested
+// publishing interval of the subscription
+func (o *subscribeClient) checkRevisedPublishingInterval() {
+	requested := time.Duration(o.Config.SubscriptionInterval).Truncate(time.Millisecond)
+	if requested <= 0 || o.sub == nil || o.sub.RevisedPublishingInterval == requested {
+		return
+	}
+	o.Log.Warnf("Server revised the subscription interval from %s to %s", requested, o.sub.RevisedPublishingInterval)
+}
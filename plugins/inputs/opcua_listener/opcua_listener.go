@@ -4,6 +4,7 @@ package opcua_listener
 import (
 	"context"
 	_ "embed"
+	"errors"
 	"fmt"
 	"time"
 
@@ -16,8 +17,10 @@ import (
 
 type OpcUaListener struct {
 	subscribeClientConfig
-	client *subscribeClient
-	Log    telegraf.Logger `toml:"-"`
+	clients []*subscribeClient
+	Log     telegraf.Logger `toml:"-"`
+
+	unregisterDiagnostics []func()
 }
 
 //go:embed sample.conf
@@ -27,7 +30,7 @@ func (*OpcUaListener) SampleConfig() string {
 	return sampleConfig
 }
 
-func (o *OpcUaListener) Init() (err error) {
+func (o *OpcUaListener) Init() error {
 	switch o.ConnectFailBehavior {
 	case "":
 		o.ConnectFailBehavior = "error"
@@ -36,35 +39,150 @@ func (o *OpcUaListener) Init() (err error) {
 	default:
 		return fmt.Errorf("unknown setting %q for 'connect_fail_behavior'", o.ConnectFailBehavior)
 	}
-	o.client, err = o.subscribeClientConfig.createSubscribeClient(o.Log)
-	return err
+
+	// Create a client for each of the configured endpoints sharing the
+	// node configuration
+	for _, cfg := range o.subscribeClientConfig.EndpointConfigs() {
+		sc := o.subscribeClientConfig
+		sc.InputClientConfig = cfg
+		client, err := sc.createSubscribeClient(o.Log)
+		if err != nil {
+			if len(o.Endpoints) > 0 {
+				return fmt.Errorf("creating client for endpoint %q failed: %w", cfg.Endpoint, err)
+			}
+			return err
+		}
+		o.clients = append(o.clients, client)
+	}
+	return nil
 }
 
 func (o *OpcUaListener) Start(acc telegraf.Accumulator) error {
-	return o.connect(acc)
+	for _, client := range o.clients {
+		unregister, err := opcua.RegisterDiagnostics(o.DiagnosticsAddress, "inputs.opcua_listener", client.diagnostics)
+		if err != nil {
+			o.stopDiagnostics()
+			return err
+		}
+		o.unregisterDiagnostics = append(o.unregisterDiagnostics, unregister)
+	}
+
+	if len(o.clients) == 1 {
+		return o.connect(acc, o.clients[0])
+	}
+
+	// Do not prevent the startup if only some of the servers are unavailable,
+	// the remaining servers are connected on the next gather cycles
+	var failed int
+	for _, client := range o.clients {
+		if err := o.connect(acc, client); err != nil {
+			acc.AddError(fmt.Errorf("connecting to %q failed: %w", client.Config.Endpoint, err))
+			failed++
+		}
+	}
+	if failed == len(o.clients) {
+		return errors.New("connecting to all endpoints failed")
+	}
+	return nil
 }
 
 func (o *OpcUaListener) Gather(acc telegraf.Accumulator) error {
-	if o.client.State() == opcua.Connected || o.subscribeClientConfig.ConnectFailBehavior == "ignore" {
+	if o.FreshnessMetric != "" {
+		for _, client := range o.clients {
+			for _, m := range client.freshnessMetrics(client.Now()) {
+				acc.AddMetric(m)
+			}
+		}
+	}
+
+	if o.subscribeClientConfig.ConnectFailBehavior == "ignore" {
 		return nil
 	}
-	return o.connect(acc)
+
+	for _, client := range o.clients {
+		// Do not interfere while the client library reconnects as it restores
+		// the session and the subscription is transferred to it, unless the
+		// subscription could not be restored
+		switch client.State() {
+		case opcua.Connected, opcua.Reconnecting:
+			if !client.subscriptionLost.Load() {
+				continue
+			}
+		}
+		err := o.connect(acc, client)
+		if err != nil && len(o.clients) == 1 {
+			return err
+		}
+		if err != nil {
+			acc.AddError(fmt.Errorf("connecting to %q failed: %w", client.Config.Endpoint, err))
+		}
+	}
+	return nil
 }
 
 func (o *OpcUaListener) Stop() {
+	o.stopDiagnostics()
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	select {
-	case <-o.client.stop(ctx):
-		o.Log.Infof("Unsubscribed OPC UA successfully")
-	case <-ctx.Done(): // Timeout context
-		o.Log.Warn("Timeout while stopping OPC UA subscription")
+	defer cancel()
+	for _, client := range o.clients {
+		select {
+		case <-client.stop(ctx):
+			o.Log.Infof("Unsubscribed OPC UA from %q successfully", client.Config.Endpoint)
+		case <-ctx.Done(): // Timeout context
+			o.Log.Warnf("Timeout while stopping OPC UA subscription of %q", client.Config.Endpoint)
+		}
+	}
+}
+
+func (o *OpcUaListener) stopDiagnostics() {
+	for _, unregister := range o.unregisterDiagnostics {
+		unregister()
+	}
+	o.unregisterDiagnostics = nil
+}
+
+func (o *OpcUaListener) GetState() interface{} {
+	if len(o.clients) == 1 {
+		return o.clients[0].GetState()
 	}
-	cancel()
+
+	// Keep the node states of multiple endpoints separated by endpoint
+	state := make(map[string][]input.NodeState, len(o.clients))
+	for _, client := range o.clients {
+		state[client.Config.Endpoint] = client.GetState()
+	}
+	return state
+}
+
+func (o *OpcUaListener) SetState(state interface{}) error {
+	if len(o.clients) == 1 {
+		nodes, ok := state.([]input.NodeState)
+		if !ok {
+			return errors.New("state has to be of type '[]input.NodeState'")
+		}
+		return o.clients[0].SetState(nodes)
+	}
+
+	endpoints, ok := state.(map[string][]input.NodeState)
+	if !ok {
+		return errors.New("state has to be of type 'map[string][]input.NodeState'")
+	}
+	for _, client := range o.clients {
+		nodes, found := endpoints[client.Config.Endpoint]
+		if !found {
+			continue
+		}
+		if err := client.SetState(nodes); err != nil {
+			return fmt.Errorf("restoring state of %q failed: %w", client.Config.Endpoint, err)
+		}
+	}
+	return nil
 }
 
-func (o *OpcUaListener) connect(acc telegraf.Accumulator) error {
+func (o *OpcUaListener) connect(acc telegraf.Accumulator, client *subscribeClient) error {
 	ctx := context.Background()
-	ch, err := o.client.startMonitoring(ctx)
+	ch, err := client.startMonitoring(ctx)
 	if err != nil {
 		return err
 	}
@@ -101,7 +219,9 @@ func init() {
 					MetricName: "opcua",
 					Timestamp:  input.TimestampSourceTelegraf,
 				},
-				SubscriptionInterval: config.Duration(100 * time.Millisecond),
+				SubscriptionInterval:    config.Duration(100 * time.Millisecond),
+				DeadbandRefreshInterval: config.Duration(time.Minute),
+				RepublishOnReconnect:    true,
 			},
 		}
 	})
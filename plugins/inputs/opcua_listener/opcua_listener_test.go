@@ -2,11 +2,18 @@ package opcua_listener
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"reflect"
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/docker/go-connections/nat"
+	gopcua "github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
 	"github.com/gopcua/opcua/ua"
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go/wait"
@@ -99,7 +106,7 @@ func TestStartPlugin(t *testing.T) {
 	plugin.subscribeClientConfig.ConnectFailBehavior = "ignore"
 	require.NoError(t, plugin.Init())
 	require.NoError(t, plugin.Start(acc))
-	require.Equal(t, opcua.Disconnected, plugin.client.OpcUAClient.State())
+	require.Equal(t, opcua.Disconnected, plugin.clients[0].OpcUAClient.State())
 	plugin.Stop()
 
 	container := testutil.Container{
@@ -113,18 +120,18 @@ func TestStartPlugin(t *testing.T) {
 	plugin.subscribeClientConfig.ConnectFailBehavior = "retry"
 	require.NoError(t, plugin.Init())
 	require.NoError(t, plugin.Start(acc))
-	require.Equal(t, opcua.Disconnected, plugin.client.OpcUAClient.State())
+	require.Equal(t, opcua.Disconnected, plugin.clients[0].OpcUAClient.State())
 
 	err = container.Start()
 	require.NoError(t, err, "failed to start container")
 
 	defer container.Terminate()
 	newEndpoint := fmt.Sprintf("opc.tcp://%s:%s", container.Address, container.Ports[servicePort])
-	plugin.client.Config.Endpoint = newEndpoint
-	plugin.client.OpcUAClient.Config.Endpoint = newEndpoint
+	plugin.clients[0].Config.Endpoint = newEndpoint
+	plugin.clients[0].OpcUAClient.Config.Endpoint = newEndpoint
 	err = plugin.Gather(acc)
 	require.NoError(t, err)
-	require.Equal(t, opcua.Connected, plugin.client.OpcUAClient.State())
+	require.Equal(t, opcua.Connected, plugin.clients[0].OpcUAClient.State())
 }
 
 func TestSubscribeClientIntegration(t *testing.T) {
@@ -1293,3 +1300,995 @@ func TestSubscribeClientConfigValidEventStreamingDefaultNodeParams(t *testing.T)
 	require.Equal(t, "i", o.IdentifierType)
 	require.Equal(t, "3", o.Namespace)
 }
+
+func TestState(t *testing.T) {
+	newPlugin := func() *OpcUaListener {
+		plugin := &OpcUaListener{
+			subscribeClientConfig: subscribeClientConfig{
+				InputClientConfig: input.InputClientConfig{
+					OpcUAClientConfig: opcua.OpcUAClientConfig{
+						Endpoint:       "opc.tcp://localhost:4840",
+						SecurityPolicy: "None",
+						SecurityMode:   "None",
+						ConnectTimeout: config.Duration(5 * time.Second),
+						RequestTimeout: config.Duration(10 * time.Second),
+					},
+					MetricName: "opcua",
+					Timestamp:  input.TimestampSourceTelegraf,
+					RootNodes: []input.NodeSettings{
+						mapOPCTag(opcTags{"ProductName", "0", "i", "2261", nil}),
+						mapOPCTag(opcTags{"ManufacturerName", "0", "i", "2263", nil}),
+					},
+				},
+				SubscriptionInterval: config.Duration(100 * time.Millisecond),
+			},
+			Log: testutil.Logger{},
+		}
+		require.NoError(t, plugin.Init())
+		return plugin
+	}
+
+	plugin := newPlugin()
+	ts := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	plugin.clients[0].UpdateNodeValue(1, &ua.DataValue{
+		Value:           ua.MustVariant("open62541"),
+		SourceTimestamp: ts,
+		ServerTimestamp: ts,
+	})
+
+	// Mimic the serialization done by the persister
+	buf, err := json.Marshal(plugin.GetState())
+	require.NoError(t, err)
+	state := reflect.New(reflect.TypeOf(plugin.GetState())).Interface()
+	require.NoError(t, json.Unmarshal(buf, &state))
+
+	restored := newPlugin()
+	require.NoError(t, restored.SetState(reflect.ValueOf(state).Elem().Interface()))
+	require.Equal(t, plugin.clients[0].LastReceivedData, restored.clients[0].LastReceivedData)
+	require.Equal(t, "open62541", restored.clients[0].LastReceivedData[1].Value)
+
+	require.ErrorContains(t, restored.SetState(map[string]int64{}), "state has to be of type")
+}
+
+func TestStateMultipleEndpoints(t *testing.T) {
+	newPlugin := func() *OpcUaListener {
+		plugin := &OpcUaListener{
+			subscribeClientConfig: subscribeClientConfig{
+				InputClientConfig: input.InputClientConfig{
+					OpcUAClientConfig: opcua.OpcUAClientConfig{
+						SecurityPolicy: "None",
+						SecurityMode:   "None",
+						ConnectTimeout: config.Duration(5 * time.Second),
+						RequestTimeout: config.Duration(10 * time.Second),
+					},
+					Endpoints:  []string{"opc.tcp://machine1:4840", "opc.tcp://machine2:4840"},
+					MetricName: "opcua",
+					Timestamp:  input.TimestampSourceTelegraf,
+					RootNodes: []input.NodeSettings{
+						mapOPCTag(opcTags{"ProductName", "0", "i", "2261", nil}),
+					},
+				},
+				SubscriptionInterval: config.Duration(100 * time.Millisecond),
+			},
+			Log: testutil.Logger{},
+		}
+		require.NoError(t, plugin.Init())
+		return plugin
+	}
+
+	plugin := newPlugin()
+	require.Len(t, plugin.clients, 2)
+	for i, client := range plugin.clients {
+		require.Equal(t, plugin.Endpoints[i], client.Config.Endpoint)
+		client.UpdateNodeValue(0, &ua.DataValue{Value: ua.MustVariant(fmt.Sprintf("machine%d", i+1))})
+	}
+
+	// Mimic the serialization done by the persister
+	buf, err := json.Marshal(plugin.GetState())
+	require.NoError(t, err)
+	state := reflect.New(reflect.TypeOf(plugin.GetState())).Interface()
+	require.NoError(t, json.Unmarshal(buf, &state))
+
+	restored := newPlugin()
+	require.NoError(t, restored.SetState(reflect.ValueOf(state).Elem().Interface()))
+	require.Equal(t, "machine1", restored.clients[0].LastReceivedData[0].Value)
+	require.Equal(t, "machine2", restored.clients[1].LastReceivedData[0].Value)
+
+	require.ErrorContains(t, restored.SetState([]input.NodeState{}), "state has to be of type")
+}
+
+func TestSubscribeClientBrowse(t *testing.T) {
+	port := testutil.FreeTCPPort(t)
+
+	srv := server.New(
+		server.EndPoint("127.0.0.1", port),
+		server.EnableSecurity("None", ua.MessageSecurityModeNone),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+	)
+	ns := server.NewNodeNameSpace(srv, "urn:test")
+	machine := addTestNode(ns, ns.Objects(), "machine", "Machine", nil)
+	addTestNode(ns, machine, "temperature", "Temperature", 21.5)
+	addTestNode(ns, machine, "internal", "Internal", int32(1))
+	motor := addTestNode(ns, machine, "motor", "Motor", nil)
+	addTestNode(ns, motor, "speed", "Speed", int32(1500))
+	// Cancelling the server context crashes the server, so use the background context
+	require.NoError(t, srv.Start(context.Background()))
+	defer srv.Close()
+
+	subscribeConfig := subscribeClientConfig{
+		InputClientConfig: input.InputClientConfig{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       fmt.Sprintf("opc.tcp://127.0.0.1:%d", port),
+				SecurityPolicy: "None",
+				SecurityMode:   "None",
+				AuthMethod:     "Anonymous",
+				ConnectTimeout: config.Duration(5 * time.Second),
+				RequestTimeout: config.Duration(5 * time.Second),
+			},
+			MetricName: "testing",
+			Groups: []input.NodeGroupSettings{
+				{
+					MetricName:     "machine",
+					Namespace:      strconv.Itoa(int(ns.ID())),
+					IdentifierType: "s",
+					DefaultTags:    map[string]string{"line": "1"},
+					Browse: &input.BrowseSettings{
+						Identifier: "machine",
+						Exclude:    []string{"Internal"},
+					},
+				},
+			},
+		},
+		SubscriptionInterval: config.Duration(100 * time.Millisecond),
+	}
+
+	client, err := subscribeConfig.createSubscribeClient(testutil.Logger{})
+	require.NoError(t, err)
+	defer client.stop(context.Background())
+
+	ch, err := client.startMonitoring(t.Context())
+	require.NoError(t, err)
+
+	// The initial values of all browsed variables are reported
+	fields := make(map[string]interface{})
+	for len(fields) < 2 {
+		select {
+		case m := <-ch:
+			require.Equal(t, "machine", m.Name())
+			require.Equal(t, "1", m.Tags()["line"])
+			for k, v := range m.Fields() {
+				if k != "Quality" {
+					fields[k] = v
+				}
+			}
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timeout waiting for metrics", "got %v", fields)
+		}
+	}
+	require.Equal(t, map[string]interface{}{"Temperature": 21.5, "Speed": int64(1500)}, fields)
+
+	// Variables added to the server are picked up when re-browsing
+	addTestNode(ns, motor, "current", "Current", 3.2)
+	require.NoError(t, client.syncBrowsed(t.Context(), false))
+	require.Len(t, client.NodeIDs, 3)
+	require.Eventually(t, func() bool {
+		select {
+		case m := <-ch:
+			_, found := m.GetField("Current")
+			return found
+		default:
+			return false
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+
+	d, ok := client.diagnostics().(*diagnostics)
+	require.True(t, ok)
+	require.Equal(t, "Connected", d.State)
+	require.Equal(t, "None", d.SecurityMode)
+	require.True(t, d.Session)
+	require.NotNil(t, d.Subscription)
+	require.Equal(t, client.sub.SubscriptionID, d.Subscription.ID)
+	require.Equal(t, monitoredItemsDiagnostics{Browsed: 3}, d.MonitoredItems)
+	require.Equal(t, 100, d.Buffers["metrics"].Capacity)
+}
+
+// addTestNode adds an object, or a variable if a value is given, below the
+// parent node
+func addTestNode(ns *server.NodeNameSpace, parent *server.Node, nodeID, name string, value interface{}) *server.Node {
+	if value != nil {
+		node := ns.AddNode(server.NewVariableNode(ua.NewStringNodeID(ns.ID(), nodeID), name, value))
+		parent.AddRef(node, id.HasComponent, true)
+		return node
+	}
+
+	node := ns.AddNode(server.NewNode(
+		ua.NewStringNodeID(ns.ID(), nodeID),
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassObject)),
+			ua.AttributeIDBrowseName:  server.DataValueFromValue(&ua.QualifiedName{NamespaceIndex: ns.ID(), Name: name}),
+			ua.AttributeIDDisplayName: server.DataValueFromValue(&ua.LocalizedText{EncodingMask: ua.LocalizedTextText, Text: name}),
+		},
+		nil,
+		nil,
+	))
+	parent.AddRef(node, id.Organizes, true)
+	return node
+}
+
+func TestBrowseGroupInvalid(t *testing.T) {
+	cfg := input.InputClientConfig{
+		MetricName: "testing",
+		Groups: []input.NodeGroupSettings{
+			{Namespace: "1", IdentifierType: "s", Browse: &input.BrowseSettings{}},
+		},
+	}
+	require.ErrorContains(t, cfg.Validate(), "group has no identifier of the object to browse")
+}
+
+func TestSubscribeClientConfigInvalidClientSideDeadband(t *testing.T) {
+	deadbandValue := 1.0
+	subscribeConfig := subscribeClientConfig{
+		InputClientConfig: input.InputClientConfig{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       "opc.tcp://localhost:4840",
+				SecurityPolicy: "None",
+				SecurityMode:   "None",
+				AuthMethod:     "Anonymous",
+			},
+			MetricName: "testing",
+			RootNodes: []input.NodeSettings{
+				{
+					FieldName:      "foo",
+					Namespace:      "3",
+					Identifier:     "1",
+					IdentifierType: "i",
+					MonitoringParams: input.MonitoringParameters{
+						DataChangeFilter: &input.DataChangeFilter{
+							Trigger:       "StatusValue",
+							DeadbandType:  "Absolute",
+							DeadbandValue: &deadbandValue,
+							ClientSide:    true,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	_, err := subscribeConfig.createSubscribeClient(testutil.Logger{})
+	require.ErrorContains(t, err, "client_side is only supported for deadband_type 'Percent', node 'ns=3;i=1'")
+}
+
+func TestCheckPercentDeadbands(t *testing.T) {
+	port := testutil.FreeTCPPort(t)
+
+	srv := server.New(
+		server.EndPoint("127.0.0.1", port),
+		server.EnableSecurity("None", ua.MessageSecurityModeNone),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+	)
+	ns := server.NewNodeNameSpace(srv, "urn:test")
+	level := addTestNode(ns, ns.Objects(), "level", "Level", 50.0)
+	euRange := ns.AddNode(server.NewVariableNode(ua.NewStringNodeID(ns.ID(), "level.eurange"), "EURange",
+		ua.NewExtensionObject(&ua.Range{Low: 0, High: 200})))
+	level.AddRef(euRange, id.HasProperty, true)
+	addTestNode(ns, ns.Objects(), "counter", "Counter", int32(1))
+	// Cancelling the server context crashes the server, so use the background context
+	require.NoError(t, srv.Start(context.Background()))
+	defer srv.Close()
+
+	deadbandValue := 10.0
+	newConfig := func(identifier string) subscribeClientConfig {
+		return subscribeClientConfig{
+			InputClientConfig: input.InputClientConfig{
+				OpcUAClientConfig: opcua.OpcUAClientConfig{
+					Endpoint:       fmt.Sprintf("opc.tcp://127.0.0.1:%d", port),
+					SecurityPolicy: "None",
+					SecurityMode:   "None",
+					AuthMethod:     "Anonymous",
+					ConnectTimeout: config.Duration(5 * time.Second),
+					RequestTimeout: config.Duration(5 * time.Second),
+				},
+				MetricName: "testing",
+				RootNodes: []input.NodeSettings{
+					{
+						FieldName:      identifier,
+						Namespace:      strconv.Itoa(int(ns.ID())),
+						IdentifierType: "s",
+						Identifier:     identifier,
+						MonitoringParams: input.MonitoringParameters{
+							DataChangeFilter: &input.DataChangeFilter{
+								Trigger:       "StatusValue",
+								DeadbandType:  "Percent",
+								DeadbandValue: &deadbandValue,
+								ClientSide:    true,
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	// The deadband is relative to the EURange of the node
+	cfg := newConfig("level")
+	client, err := cfg.createSubscribeClient(testutil.Logger{})
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(t.Context()))
+	defer client.Disconnect(context.Background()) //nolint:errcheck // ignore error on cleanup
+	require.NoError(t, client.checkPercentDeadbands(t.Context()))
+	require.Contains(t, client.deadbands, 0)
+	require.InDelta(t, 20.0, client.deadbands[0].threshold, 1e-9)
+
+	// Nodes without EURange cannot use percent deadbands
+	cfg = newConfig("counter")
+	client, err = cfg.createSubscribeClient(testutil.Logger{})
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(t.Context()))
+	defer client.Disconnect(context.Background()) //nolint:errcheck // ignore error on cleanup
+	require.ErrorContains(t, client.checkPercentDeadbands(t.Context()), `node "counter" (ns=`)
+	require.ErrorContains(t, client.checkPercentDeadbands(t.Context()), "uses a percent deadband but has no EURange property")
+}
+
+func TestClientSideDeadband(t *testing.T) {
+	d := &deadband{threshold: 2}
+	value := func(v float64, status ua.StatusCode) *ua.DataValue {
+		return &ua.DataValue{Value: ua.MustVariant(v), Status: status}
+	}
+
+	require.True(t, d.exceeded(ua.StatusOK, value(10, ua.StatusOK)))
+	require.False(t, d.exceeded(ua.StatusOK, value(11, ua.StatusOK)))
+	require.False(t, d.exceeded(ua.StatusOK, value(12, ua.StatusOK)))
+	require.True(t, d.exceeded(ua.StatusOK, value(12.5, ua.StatusOK)))
+	require.False(t, d.exceeded(ua.StatusOK, value(11, ua.StatusOK)))
+	// Quality changes are always reported
+	require.True(t, d.exceeded(ua.StatusOK, value(11, ua.StatusUncertain)))
+}
+
+func TestSubscribeClientConfigSubMillisecondIntervals(t *testing.T) {
+	queueSize := uint32(2)
+	subscribeConfig := subscribeClientConfig{
+		InputClientConfig: input.InputClientConfig{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       "opc.tcp://localhost:4840",
+				SecurityPolicy: "None",
+				SecurityMode:   "None",
+				AuthMethod:     "Anonymous",
+			},
+			MetricName: "testing",
+			RootNodes: []input.NodeSettings{
+				{
+					FieldName:      "fast",
+					Namespace:      "3",
+					Identifier:     "1",
+					IdentifierType: "i",
+					MonitoringParams: input.MonitoringParameters{
+						SamplingInterval: config.Duration(250 * time.Microsecond),
+						QueueSize:        &queueSize,
+					},
+				},
+				{
+					FieldName:      "slow",
+					Namespace:      "3",
+					Identifier:     "2",
+					IdentifierType: "i",
+					MonitoringParams: input.MonitoringParameters{
+						SamplingInterval: config.Duration(1500 * time.Microsecond),
+						QueueSize:        &queueSize,
+					},
+				},
+			},
+		},
+		SubscriptionInterval: config.Duration(2500 * time.Microsecond),
+	}
+
+	logger := &testutil.CaptureLogger{}
+	subClient, err := subscribeConfig.createSubscribeClient(logger)
+	require.NoError(t, err)
+	require.InDelta(t, 0.25, subClient.monitoredItemsReqs[0].RequestedParameters.SamplingInterval, 1e-9)
+	require.InDelta(t, 1.5, subClient.monitoredItemsReqs[1].RequestedParameters.SamplingInterval, 1e-9)
+
+	warnings := logger.Warnings()
+	require.Len(t, warnings, 2)
+	require.Contains(t, warnings[0], "2.5ms will be requested as 2ms")
+	require.Contains(t, warnings[1], `Node "ns=3;i=1" is sampled every 250µs but published every 2.5ms`)
+}
+
+func TestRevisedSamplingIntervalWarning(t *testing.T) {
+	subscribeConfig := subscribeClientConfig{
+		InputClientConfig: input.InputClientConfig{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       "opc.tcp://localhost:4840",
+				SecurityPolicy: "None",
+				SecurityMode:   "None",
+				AuthMethod:     "Anonymous",
+			},
+			MetricName: "testing",
+			RootNodes: []input.NodeSettings{
+				{
+					FieldName:        "a",
+					Namespace:        "3",
+					Identifier:       "1",
+					IdentifierType:   "i",
+					MonitoringParams: input.MonitoringParameters{SamplingInterval: config.Duration(100 * time.Millisecond)},
+				},
+				{
+					FieldName:        "b",
+					Namespace:        "3",
+					Identifier:       "2",
+					IdentifierType:   "i",
+					MonitoringParams: input.MonitoringParameters{SamplingInterval: config.Duration(100 * time.Millisecond)},
+				},
+			},
+		},
+		SubscriptionInterval: config.Duration(time.Second),
+	}
+
+	logger := &testutil.CaptureLogger{}
+	subClient, err := subscribeConfig.createSubscribeClient(logger)
+	require.NoError(t, err)
+	logger.RequireNoWarnings(t)
+
+	// The server degrades the sampling of the second item
+	results := []*ua.MonitoredItemCreateResult{
+		{StatusCode: ua.StatusOK, RevisedSamplingInterval: 100},
+		{StatusCode: ua.StatusOK, RevisedSamplingInterval: 500},
+	}
+	subClient.checkRevisedSamplingIntervals(subClient.monitoredItemsReqs, results)
+	require.Equal(t, 1, logger.Count(testutil.LevelWarn))
+	logger.RequireWarnContains(t, "revised the sampling interval of 1 monitored items")
+	logger.RequireDebugContains(t, `node "ns=3;i=2" from 100ms to 500ms`)
+	logger.RequireNoErrors(t)
+}
+
+func TestMillisecondConversion(t *testing.T) {
+	for _, d := range []time.Duration{0, time.Microsecond, 250 * time.Microsecond, 1500 * time.Microsecond, time.Second} {
+		require.Equal(t, d, fromMilliseconds(toMilliseconds(d)))
+	}
+}
+
+func TestDynamicDeadband(t *testing.T) {
+	port := testutil.FreeTCPPort(t)
+
+	srv := server.New(
+		server.EndPoint("127.0.0.1", port),
+		server.EnableSecurity("None", ua.MessageSecurityModeNone),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+	)
+	ns := server.NewNodeNameSpace(srv, "urn:test")
+	level := addTestNode(ns, ns.Objects(), "level", "Level", 42.0)
+	euRange := ns.AddNode(server.NewVariableNode(ua.NewStringNodeID(ns.ID(), "level.eurange"), "EURange",
+		ua.NewExtensionObject(&ua.Range{Low: 0, High: 200})))
+	level.AddRef(euRange, id.HasProperty, true)
+	addTestNode(ns, ns.Objects(), "temperature", "Temperature", 21.5)
+	addTestNode(ns, ns.Objects(), "level.band", "LevelBand", 5.0)
+	addTestNode(ns, ns.Objects(), "temperature.band", "TemperatureBand", 0.5)
+	// Cancelling the server context crashes the server, so use the background context
+	require.NoError(t, srv.Start(context.Background()))
+	defer srv.Close()
+
+	namespace := strconv.Itoa(int(ns.ID()))
+	subscribeConfig := subscribeClientConfig{
+		InputClientConfig: input.InputClientConfig{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       fmt.Sprintf("opc.tcp://127.0.0.1:%d", port),
+				SecurityPolicy: "None",
+				SecurityMode:   "None",
+				AuthMethod:     "Anonymous",
+				ConnectTimeout: config.Duration(5 * time.Second),
+				RequestTimeout: config.Duration(5 * time.Second),
+			},
+			MetricName: "testing",
+			RootNodes: []input.NodeSettings{
+				{
+					FieldName:      "level",
+					Namespace:      namespace,
+					IdentifierType: "s",
+					Identifier:     "level",
+					MonitoringParams: input.MonitoringParameters{
+						DataChangeFilter: &input.DataChangeFilter{
+							Trigger:           "StatusValue",
+							DeadbandType:      "Percent",
+							ClientSide:        true,
+							DeadbandValueNode: "ns=" + namespace + ";s=level.band",
+						},
+					},
+				},
+				{
+					FieldName:      "temperature",
+					Namespace:      namespace,
+					IdentifierType: "s",
+					Identifier:     "temperature",
+					MonitoringParams: input.MonitoringParameters{
+						DataChangeFilter: &input.DataChangeFilter{
+							Trigger:           "StatusValue",
+							DeadbandType:      "Absolute",
+							DeadbandValueNode: "ns=" + namespace + ";s=temperature.band",
+						},
+					},
+				},
+			},
+		},
+		SubscriptionInterval: config.Duration(100 * time.Millisecond),
+	}
+
+	client, err := subscribeConfig.createSubscribeClient(testutil.Logger{})
+	require.NoError(t, err)
+	defer client.stop(context.Background())
+
+	_, err = client.startMonitoring(t.Context())
+	require.NoError(t, err)
+
+	// The initial deadbands are read from the server
+	require.InDelta(t, 10.0, client.deadbands[0].threshold, 1e-9)
+	filter, ok := client.monitoredItemsReqs[1].RequestedParameters.Filter.Value.(*ua.DataChangeFilter)
+	require.True(t, ok)
+	require.InDelta(t, 0.5, filter.DeadbandValue, 1e-9)
+
+	// Unchanged values do not cause updates
+	changed, err := client.readDynamicDeadbands(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, changed)
+
+	// Invalid values keep the current deadband
+	ns.SetAttribute(ua.NewStringNodeID(ns.ID(), "level.band"), ua.AttributeIDValue, server.DataValueFromValue(150.0))
+	changed, err = client.readDynamicDeadbands(t.Context())
+	require.NoError(t, err)
+	require.Empty(t, changed)
+	require.InDelta(t, 5.0, client.deadbandValue(0), 1e-9)
+
+	// Changed values are applied, the test server does not support modifying
+	// monitored items though
+	ns.SetAttribute(ua.NewStringNodeID(ns.ID(), "level.band"), ua.AttributeIDValue, server.DataValueFromValue(10.0))
+	ns.SetAttribute(ua.NewStringNodeID(ns.ID(), "temperature.band"), ua.AttributeIDValue, server.DataValueFromValue(1.5))
+	changed, err = client.readDynamicDeadbands(t.Context())
+	require.NoError(t, err)
+	require.ElementsMatch(t, []int{0, 1}, changed)
+	require.ErrorContains(t, client.applyDynamicDeadbands(t.Context(), changed, true), "modifying monitored items failed")
+	require.InDelta(t, 20.0, client.deadbands[0].threshold, 1e-9)
+	require.InDelta(t, 1.5, filter.DeadbandValue, 1e-9)
+}
+
+func TestDynamicDeadbandRefreshWhileReconnecting(t *testing.T) {
+	port := testutil.FreeTCPPort(t)
+
+	srv := server.New(
+		server.EndPoint("127.0.0.1", port),
+		server.EnableSecurity("None", ua.MessageSecurityModeNone),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+	)
+	ns := server.NewNodeNameSpace(srv, "urn:test")
+	addTestNode(ns, ns.Objects(), "temperature", "Temperature", 21.5)
+	addTestNode(ns, ns.Objects(), "temperature.band", "TemperatureBand", 0.5)
+	// Cancelling the server context crashes the server, so use the background context
+	require.NoError(t, srv.Start(context.Background()))
+	defer srv.Close()
+
+	namespace := strconv.Itoa(int(ns.ID()))
+	subscribeConfig := subscribeClientConfig{
+		InputClientConfig: input.InputClientConfig{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       fmt.Sprintf("opc.tcp://127.0.0.1:%d", port),
+				SecurityPolicy: "None",
+				SecurityMode:   "None",
+				AuthMethod:     "Anonymous",
+				ConnectTimeout: config.Duration(5 * time.Second),
+				RequestTimeout: config.Duration(5 * time.Second),
+			},
+			MetricName: "testing",
+			RootNodes: []input.NodeSettings{
+				{
+					FieldName:      "temperature",
+					Namespace:      namespace,
+					IdentifierType: "s",
+					Identifier:     "temperature",
+					MonitoringParams: input.MonitoringParameters{
+						DataChangeFilter: &input.DataChangeFilter{
+							Trigger:           "StatusValue",
+							DeadbandType:      "Absolute",
+							DeadbandValueNode: "ns=" + namespace + ";s=temperature.band",
+						},
+					},
+				},
+			},
+		},
+		SubscriptionInterval:    config.Duration(100 * time.Millisecond),
+		DeadbandRefreshInterval: config.Duration(time.Millisecond),
+	}
+
+	client, err := subscribeConfig.createSubscribeClient(testutil.Logger{})
+	require.NoError(t, err)
+	defer client.stop(context.Background())
+
+	_, err = client.startMonitoring(t.Context())
+	require.NoError(t, err)
+	require.True(t, client.refreshingDeadbands)
+
+	// Resubscribe while the deadbands are refreshed and modified in the
+	// background, the race detector reports unprotected accesses
+	for i := range 5 {
+		band := float64(i + 1)
+		ns.SetAttribute(ua.NewStringNodeID(ns.ID(), "temperature.band"), ua.AttributeIDValue, server.DataValueFromValue(band))
+		_, err = client.startMonitoring(t.Context())
+		require.NoError(t, err)
+
+		client.deadbandLock.Lock()
+		require.NotZero(t, client.monitoredItemIDs[0])
+		client.deadbandLock.Unlock()
+	}
+
+	// The refresh picks up the last deadband value
+	require.Eventually(t, func() bool {
+		client.deadbandLock.Lock()
+		defer client.deadbandLock.Unlock()
+		return client.deadbandValue(0) == 5.0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSubscribeClientConfigInvalidDeadbandValueNode(t *testing.T) {
+	subscribeConfig := subscribeClientConfig{
+		InputClientConfig: input.InputClientConfig{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       "opc.tcp://localhost:4840",
+				SecurityPolicy: "None",
+				SecurityMode:   "None",
+				AuthMethod:     "Anonymous",
+			},
+			MetricName: "testing",
+			RootNodes: []input.NodeSettings{
+				{
+					FieldName:      "foo",
+					Namespace:      "3",
+					Identifier:     "1",
+					IdentifierType: "i",
+					MonitoringParams: input.MonitoringParameters{
+						DataChangeFilter: &input.DataChangeFilter{
+							Trigger:           "StatusValue",
+							DeadbandType:      "Absolute",
+							DeadbandValueNode: "ns=invalid;i=1",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	_, err := subscribeConfig.createSubscribeClient(testutil.Logger{})
+	require.ErrorContains(t, err, `invalid deadband_value_node "ns=invalid;i=1"`)
+}
+
+func TestMissingSequenceNumbers(t *testing.T) {
+	require.Zero(t, missingSequenceNumbers(nil))
+	require.Zero(t, missingSequenceNumbers([]uint32{5}))
+	require.Zero(t, missingSequenceNumbers([]uint32{5, 6, 7}))
+	require.Equal(t, int64(3), missingSequenceNumbers([]uint32{5, 7, 8, 11}))
+}
+
+func TestRecoverSubscriptionUnsupported(t *testing.T) {
+	port := testutil.FreeTCPPort(t)
+
+	srv := server.New(
+		server.EndPoint("127.0.0.1", port),
+		server.EnableSecurity("None", ua.MessageSecurityModeNone),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+	)
+	ns := server.NewNodeNameSpace(srv, "urn:test")
+	addTestNode(ns, ns.Objects(), "temperature", "Temperature", 21.5)
+	// Cancelling the server context crashes the server, so use the background context
+	require.NoError(t, srv.Start(context.Background()))
+	defer srv.Close()
+
+	subscribeConfig := subscribeClientConfig{
+		InputClientConfig: input.InputClientConfig{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       fmt.Sprintf("opc.tcp://127.0.0.1:%d", port),
+				SecurityPolicy: "None",
+				SecurityMode:   "None",
+				AuthMethod:     "Anonymous",
+				ConnectTimeout: config.Duration(5 * time.Second),
+				RequestTimeout: config.Duration(5 * time.Second),
+			},
+			MetricName: "testing",
+			RootNodes: []input.NodeSettings{
+				{
+					FieldName:      "temperature",
+					Namespace:      strconv.Itoa(int(ns.ID())),
+					IdentifierType: "s",
+					Identifier:     "temperature",
+				},
+			},
+		},
+		SubscriptionInterval: config.Duration(100 * time.Millisecond),
+		RepublishOnReconnect: true,
+	}
+
+	logger := &testutil.CaptureLogger{}
+	client, err := subscribeConfig.createSubscribeClient(logger)
+	require.NoError(t, err)
+	defer client.stop(context.Background())
+
+	_, err = client.startMonitoring(t.Context())
+	require.NoError(t, err)
+	require.True(t, client.processing.Load())
+
+	// The test server does not support transferring subscriptions so the
+	// notifications cannot be recovered
+	client.recoverSubscription(t.Context(), client.sub.SubscriptionID)
+	warnings := logger.Warnings()
+	require.Len(t, warnings, 1)
+	require.Contains(t, warnings[0], "Recovering notifications of previous subscription")
+}
+
+func TestRestartProcessingOnReconnect(t *testing.T) {
+	port := testutil.FreeTCPPort(t)
+
+	srv := server.New(
+		server.EndPoint("127.0.0.1", port),
+		server.EnableSecurity("None", ua.MessageSecurityModeNone),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+	)
+	ns := server.NewNodeNameSpace(srv, "urn:test")
+	addTestNode(ns, ns.Objects(), "temperature", "Temperature", 21.5)
+	// Cancelling the server context crashes the server, so use the background context
+	require.NoError(t, srv.Start(context.Background()))
+	defer srv.Close()
+
+	subscribeConfig := subscribeClientConfig{
+		InputClientConfig: input.InputClientConfig{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       fmt.Sprintf("opc.tcp://127.0.0.1:%d", port),
+				SecurityPolicy: "None",
+				SecurityMode:   "None",
+				AuthMethod:     "Anonymous",
+				ConnectTimeout: config.Duration(5 * time.Second),
+				RequestTimeout: config.Duration(5 * time.Second),
+			},
+			MetricName: "testing",
+			RootNodes: []input.NodeSettings{
+				{
+					FieldName:      "temperature",
+					Namespace:      strconv.Itoa(int(ns.ID())),
+					IdentifierType: "s",
+					Identifier:     "temperature",
+				},
+			},
+		},
+		SubscriptionInterval: config.Duration(100 * time.Millisecond),
+	}
+
+	client, err := subscribeConfig.createSubscribeClient(testutil.Logger{})
+	require.NoError(t, err)
+	defer client.stop(context.Background())
+
+	ch, err := client.startMonitoring(t.Context())
+	require.NoError(t, err)
+	require.True(t, client.processing.Load())
+
+	// Wait for the initial value before terminating the processing
+	select {
+	case <-ch:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timeout waiting for initial value")
+	}
+
+	// An invalid notification stops the processing goroutine
+	client.dataNotifications <- &gopcua.PublishNotificationData{}
+	require.Eventually(t, func() bool {
+		return !client.processing.Load()
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Reconnecting must restart the processing and deliver values again
+	require.NoError(t, client.Disconnect(t.Context()))
+	ch, err = client.startMonitoring(t.Context())
+	require.NoError(t, err)
+	require.True(t, client.processing.Load())
+
+	select {
+	case m := <-ch:
+		require.Equal(t, 21.5, m.Fields()["temperature"])
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "no value received after reconnect")
+	}
+}
+
+func TestSubscriptionRepublishGaps(t *testing.T) {
+	value := func(v float64) *ua.ExtensionObject {
+		return ua.NewExtensionObject(&ua.DataChangeNotification{
+			MonitoredItems: []*ua.MonitoredItemNotification{
+				{ClientHandle: 0, Value: &ua.DataValue{Value: ua.MustVariant(v)}},
+			},
+		})
+	}
+
+	// Message 2 and 3 are missed during a healthy session, message 5 while
+	// the client library restores the connection with a new session. The
+	// server did not retain message 3.
+	sender := &fakePublishSender{
+		published: []interface{}{
+			&ua.NotificationMessage{SequenceNumber: 1, NotificationData: []*ua.ExtensionObject{value(1)}},
+			&ua.NotificationMessage{SequenceNumber: 4, NotificationData: []*ua.ExtensionObject{value(4)}},
+			ua.StatusBadNoSubscription,
+			&ua.NotificationMessage{SequenceNumber: 6},
+		},
+		retained: map[uint32]*ua.NotificationMessage{
+			2: {SequenceNumber: 2, NotificationData: []*ua.ExtensionObject{value(2)}},
+			5: {SequenceNumber: 5, NotificationData: []*ua.ExtensionObject{value(5)}},
+		},
+	}
+
+	notifs := make(chan *gopcua.PublishNotificationData, 10)
+	sub, err := createSubscription(t.Context(), sender, &subscriptionParameters{
+		endpoint:       "opc.tcp://republish.test:4840",
+		interval:       100 * time.Millisecond,
+		requestTimeout: 5 * time.Second,
+		republish:      true,
+		notifs:         notifs,
+	}, testutil.Logger{})
+	require.NoError(t, err)
+	require.Equal(t, uint32(25), sender.keepAlive)
+	// The internal statistics are shared by all subscriptions of the endpoint
+	republished, lost := sub.statRepublished.Get(), sub.statLost.Get()
+	sub.start(t.Context())
+
+	var values []float64
+	for range 4 {
+		select {
+		case n := <-notifs:
+			require.NoError(t, n.Error)
+			dcn, ok := n.Value.(*ua.DataChangeNotification)
+			require.True(t, ok)
+			values = append(values, dcn.MonitoredItems[0].Value.Value.Value().(float64))
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timeout waiting for notifications")
+		}
+	}
+	sub.stop()
+
+	require.Equal(t, []float64{1, 2, 4, 5}, values)
+	require.Equal(t, []uint32{2, 3, 5}, sender.republished)
+	require.Equal(t, []uint32{1}, sender.transferred)
+	require.Equal(t, []uint32{1, 2, 4, 5}, sender.acked)
+	require.Equal(t, republished+2, sub.statRepublished.Get())
+	require.Equal(t, lost+1, sub.statLost.Get())
+}
+
+func TestSubscriptionTransferFailed(t *testing.T) {
+	sender := &fakePublishSender{
+		published:      []interface{}{ua.StatusBadNoSubscription},
+		transferStatus: ua.StatusBadSubscriptionIDInvalid,
+	}
+
+	var lost atomic.Bool
+	sub, err := createSubscription(t.Context(), sender, &subscriptionParameters{
+		endpoint: "opc.tcp://transfer.test:4840",
+		notifs:   make(chan *gopcua.PublishNotificationData),
+		lost:     func() { lost.Store(true) },
+	}, testutil.Logger{})
+	require.NoError(t, err)
+	sub.start(t.Context())
+
+	require.Eventually(t, lost.Load, 5*time.Second, 10*time.Millisecond)
+	sub.stop()
+	require.Equal(t, []uint32{1}, sender.transferred)
+}
+
+func TestFreshnessMetric(t *testing.T) {
+	plugin := &OpcUaListener{
+		subscribeClientConfig: subscribeClientConfig{
+			InputClientConfig: input.InputClientConfig{
+				OpcUAClientConfig: opcua.OpcUAClientConfig{
+					Endpoint:       "opc.tcp://localhost:4840",
+					SecurityPolicy: "None",
+					SecurityMode:   "None",
+					ConnectTimeout: config.Duration(5 * time.Second),
+					RequestTimeout: config.Duration(10 * time.Second),
+				},
+				MetricName: "opcua",
+				Timestamp:  input.TimestampSourceTelegraf,
+				RootNodes: []input.NodeSettings{
+					{FieldName: "speed", Namespace: "3", IdentifierType: "s", Identifier: "speed", TagsSlice: [][]string{{"line", "1"}}},
+					{FieldName: "state", Namespace: "3", IdentifierType: "s", Identifier: "state"},
+				},
+			},
+			SubscriptionInterval: config.Duration(100 * time.Millisecond),
+			ConnectFailBehavior:  "ignore",
+			FreshnessMetric:      "opcua_freshness",
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// Simulate three updates of the first node, the second node is stalled
+	client := plugin.clients[0]
+	clock := testutil.NewFakeClock(time.Unix(1700000000, 0))
+	client.Clock = clock
+	last := clock.Now()
+	clock.Add(2 * time.Second)
+	client.nodesLock.Lock()
+	client.recordUpdate(0, last.Add(-time.Second))
+	client.recordUpdate(0, last.Add(-500*time.Millisecond))
+	client.recordUpdate(0, last)
+	client.nodesLock.Unlock()
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Gather(&acc))
+	metrics := acc.GetTelegrafMetrics()
+	require.Len(t, metrics, 2)
+
+	expected := []telegraf.Metric{
+		metric.New("opcua_freshness",
+			map[string]string{"id": "ns=3;s=speed", "field": "speed", "line": "1"},
+			map[string]interface{}{"updates": int64(3)},
+			time.Unix(0, 0),
+		),
+		metric.New("opcua_freshness",
+			map[string]string{"id": "ns=3;s=state", "field": "state"},
+			map[string]interface{}{"updates": int64(0)},
+			time.Unix(0, 0),
+		),
+	}
+	expected[0].AddField("age_ms", int64(2000))
+	testutil.RequireMetricsEqual(t, expected, metrics, testutil.IgnoreTime())
+
+	// The update count restarts for each interval
+	acc.ClearMetrics()
+	require.NoError(t, plugin.Gather(&acc))
+	for _, m := range acc.GetTelegrafMetrics() {
+		updates, found := m.GetField("updates")
+		require.True(t, found)
+		require.Equal(t, int64(0), updates)
+	}
+}
+
+// fakePublishSender answers the publish requests with the given notification
+// messages or errors and republishes the retained messages
+type fakePublishSender struct {
+	published      []interface{}
+	retained       map[uint32]*ua.NotificationMessage
+	transferStatus ua.StatusCode
+
+	keepAlive   uint32
+	acked       []uint32
+	republished []uint32
+	transferred []uint32
+}
+
+func (f *fakePublishSender) Send(ctx context.Context, req ua.Request, h func(ua.Response) error) error {
+	switch r := req.(type) {
+	case *ua.CreateSubscriptionRequest:
+		f.keepAlive = r.RequestedMaxKeepAliveCount
+		return h(&ua.CreateSubscriptionResponse{
+			SubscriptionID:            1,
+			RevisedPublishingInterval: r.RequestedPublishingInterval,
+			RevisedLifetimeCount:      r.RequestedLifetimeCount,
+			RevisedMaxKeepAliveCount:  r.RequestedMaxKeepAliveCount,
+		})
+	case *ua.PublishRequest:
+		for _, ack := range r.SubscriptionAcknowledgements {
+			f.acked = append(f.acked, ack.SequenceNumber)
+		}
+		if len(f.published) == 0 {
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		next := f.published[0]
+		f.published = f.published[1:]
+		if err, ok := next.(error); ok {
+			return err
+		}
+		return h(&ua.PublishResponse{SubscriptionID: 1, NotificationMessage: next.(*ua.NotificationMessage)})
+	case *ua.RepublishRequest:
+		f.republished = append(f.republished, r.RetransmitSequenceNumber)
+		msg, found := f.retained[r.RetransmitSequenceNumber]
+		if !found {
+			return ua.StatusBadMessageNotAvailable
+		}
+		return h(&ua.RepublishResponse{NotificationMessage: msg})
+	case *ua.TransferSubscriptionsRequest:
+		f.transferred = append(f.transferred, r.SubscriptionIDs...)
+		return h(&ua.TransferSubscriptionsResponse{
+			Results: []*ua.TransferResult{{StatusCode: f.transferStatus}},
+		})
+	}
+	return fmt.Errorf("unexpected request %T", req)
+}
@@ -0,0 +1,140 @@
+package opcua_listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// recoverSubscription transfers the subscription of the previous connection
+// to the current session and republishes the notifications retained by the
+// server. Afterwards the subscription is deleted as it is replaced by a new
+// one. Gaps in the sequence numbers of the retained notifications are
+// reported as lost notifications.
+func (o *subscribeClient) recoverSubscription(ctx context.Context, subID uint32) {
+	tags := map[string]string{"endpoint": o.Config.Endpoint}
+	republished := selfstat.Register("opcua", "notifications_republished", tags)
+	lost := selfstat.Register("opcua", "notifications_lost", tags)
+
+	available, err := transferSubscription(ctx, o.Client, subID)
+	if err != nil {
+		o.Log.Warnf("Recovering notifications of previous subscription %d failed, notifications might be lost: %v", subID, err)
+		return
+	}
+
+	seqs := slices.Clone(available)
+	slices.Sort(seqs)
+	o.Log.Debugf("Transferred previous subscription %d with %d retained notifications", subID, len(seqs))
+	if missing := missingSequenceNumbers(seqs); missing > 0 {
+		o.Log.Warnf("Server did not retain %d notifications of previous subscription %d", missing, subID)
+		lost.Incr(missing)
+	}
+
+	var count int64
+	for i, seq := range seqs {
+		msg, err := republishMessage(ctx, o.Client, subID, seq)
+		if errors.Is(err, ua.StatusBadMessageNotAvailable) {
+			lost.Incr(1)
+			continue
+		}
+		if err != nil {
+			o.Log.Warnf("Republishing notification %d of previous subscription %d failed: %v", seq, subID, err)
+			lost.Incr(int64(len(seqs) - i))
+			break
+		}
+
+		for _, data := range msg.NotificationData {
+			if data == nil {
+				continue
+			}
+			select {
+			case o.dataNotifications <- &opcua.PublishNotificationData{SubscriptionID: subID, Value: data.Value}:
+			case <-ctx.Done():
+				return
+			}
+		}
+		count++
+	}
+	republished.Incr(count)
+	if count > 0 {
+		o.Log.Infof("Recovered %d notifications of previous subscription %d", count, subID)
+	}
+
+	// Delete the transferred subscription so the server stops publishing it
+	var deleted *ua.DeleteSubscriptionsResponse
+	err = o.Client.Send(ctx, &ua.DeleteSubscriptionsRequest{SubscriptionIDs: []uint32{subID}}, func(v ua.Response) error {
+		return assignResponse(v, &deleted)
+	})
+	if err != nil {
+		o.Log.Debugf("Deleting previous subscription %d failed: %v", subID, err)
+	}
+}
+
+// transferSubscription transfers the given subscription to the current
+// session and returns the sequence numbers of the notification messages
+// retained by the server
+func transferSubscription(ctx context.Context, client requestSender, subID uint32) ([]uint32, error) {
+	var resp *ua.TransferSubscriptionsResponse
+	err := client.Send(ctx, &ua.TransferSubscriptionsRequest{
+		SubscriptionIDs:   []uint32{subID},
+		SendInitialValues: false,
+	}, func(v ua.Response) error {
+		return assignResponse(v, &resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Results) != 1 || resp.Results[0] == nil {
+		return nil, fmt.Errorf("expected one result but got %d", len(resp.Results))
+	}
+	if resp.Results[0].StatusCode != ua.StatusOK {
+		return nil, resp.Results[0].StatusCode
+	}
+	return resp.Results[0].AvailableSequenceNumbers, nil
+}
+
+// republishMessage requests the notification message with the given
+// sequence number from the retransmission queue of the server
+func republishMessage(ctx context.Context, client requestSender, subID, seq uint32) (*ua.NotificationMessage, error) {
+	var resp *ua.RepublishResponse
+	err := client.Send(ctx, &ua.RepublishRequest{
+		SubscriptionID:           subID,
+		RetransmitSequenceNumber: seq,
+	}, func(v ua.Response) error {
+		return assignResponse(v, &resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.NotificationMessage == nil {
+		return nil, ua.StatusBadMessageNotAvailable
+	}
+	return resp.NotificationMessage, nil
+}
+
+// missingSequenceNumbers returns the number of gaps in the given sorted
+// sequence numbers
+func missingSequenceNumbers(seqs []uint32) int64 {
+	var missing int64
+	for i := 1; i < len(seqs); i++ {
+		if seqs[i] > seqs[i-1]+1 {
+			missing += int64(seqs[i] - seqs[i-1] - 1)
+		}
+	}
+	return missing
+}
+
+func assignResponse[T ua.Response](v ua.Response, target *T) error {
+	r, ok := v.(T)
+	if !ok {
+		return fmt.Errorf("unexpected response type %T", v)
+	}
+	*target = r
+	return nil
+}
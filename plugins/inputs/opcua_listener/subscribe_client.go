@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gopcua/opcua"
@@ -20,23 +22,70 @@ type subscribeClientConfig struct {
 	input.InputClientConfig
 	SubscriptionInterval config.Duration `toml:"subscription_interval"`
 	ConnectFailBehavior  string          `toml:"connect_fail_behavior"`
+	RebrowseInterval     config.Duration `toml:"rebrowse_interval"`
+
+	DeadbandRefreshInterval config.Duration `toml:"deadband_refresh_interval"`
+	RepublishOnReconnect    bool            `toml:"republish_on_reconnect"`
+	FreshnessMetric         string          `toml:"freshness_metric"`
 }
 
 type subscribeClient struct {
 	*input.OpcUAInputClient
 	Config subscribeClientConfig
 
-	sub                *opcua.Subscription
+	sub                *subscription
 	monitoredItemsReqs []*ua.MonitoredItemCreateRequest
 	eventItemsReqs     []*ua.MonitoredItemCreateRequest
 	dataNotifications  chan *opcua.PublishNotificationData
 	metrics            chan telegraf.Metric
 
+	browseGroups []*browseGroup
+	browseLock   sync.Mutex
+	rebrowsing   bool
+
+	// nodesLock protects the node arrays of the input client which grow
+	// when browsing finds new variables
+	nodesLock sync.Mutex
+
+	// deadbands applied on the client side indexed by the node handle
+	deadbands map[int]*deadband
+
+	// deadbands sourced from server nodes indexed by the node handle
+	dynamicDeadbands    map[int]*dynamicDeadband
+	monitoredItemIDs    []uint32
+	refreshingDeadbands bool
+
+	// deadbandLock protects the dynamic deadbands, the monitored item
+	// requests and IDs as well as the subscription, so refreshing the
+	// deadbands pauses while (re)subscribing
+	deadbandLock sync.Mutex
+
+	// processing is set while the goroutine handling the notifications runs
+	processing atomic.Bool
+
+	// subscriptionLost is set if the subscription could not be restored
+	// after the client library reconnected, requiring to resubscribe
+	subscriptionLost atomic.Bool
+
+	// updates received per node indexed by the node handle
+	freshness []nodeFreshness
+
+	// state reported by the diagnostics endpoint
+	diagLock           sync.Mutex
+	diagSubscription   *subscriptionDiagnostics
+	diagMonitoredItems monitoredItemsDiagnostics
+
 	ctx    context.Context
 	cancel context.CancelFunc
 }
 
 func checkDataChangeFilterParameters(params *input.DataChangeFilter) error {
+	if params.DeadbandValueNode != "" {
+		if _, err := ua.ParseNodeID(params.DeadbandValueNode); err != nil {
+			return fmt.Errorf("invalid deadband_value_node %q: %w", params.DeadbandValueNode, err)
+		}
+	}
+
 	switch {
 	case params.Trigger != input.Status &&
 		params.Trigger != input.StatusValue &&
@@ -45,17 +94,21 @@ func checkDataChangeFilterParameters(params *input.DataChangeFilter) error {
 	case params.DeadbandType != input.Absolute &&
 		params.DeadbandType != input.Percent:
 		return fmt.Errorf("deadband_type '%s' not supported", params.DeadbandType)
-	case params.DeadbandValue == nil:
+	case params.DeadbandValue == nil && params.DeadbandValueNode == "":
 		return errors.New("deadband_value was not set")
-	case *params.DeadbandValue < 0:
+	case params.DeadbandValue != nil && *params.DeadbandValue < 0:
 		return errors.New("negative deadband_value not supported")
+	case params.DeadbandValue != nil && params.DeadbandType == input.Percent && *params.DeadbandValue > 100:
+		return errors.New("deadband_value above 100 percent not supported")
+	case params.ClientSide && params.DeadbandType != input.Percent:
+		return errors.New("client_side is only supported for deadband_type 'Percent'")
 	default:
 		return nil
 	}
 }
 
 func assignConfigValuesToRequest(req *ua.MonitoredItemCreateRequest, monParams *input.MonitoringParameters) error {
-	req.RequestedParameters.SamplingInterval = float64(time.Duration(monParams.SamplingInterval) / time.Millisecond)
+	req.RequestedParameters.SamplingInterval = toMilliseconds(time.Duration(monParams.SamplingInterval))
 
 	if monParams.QueueSize != nil {
 		req.RequestedParameters.QueueSize = *monParams.QueueSize
@@ -70,13 +123,22 @@ func assignConfigValuesToRequest(req *ua.MonitoredItemCreateRequest, monParams *
 			return fmt.Errorf(err.Error()+", node '%s'", req.ItemToMonitor.NodeID)
 		}
 
-		req.RequestedParameters.Filter = ua.NewExtensionObject(
-			&ua.DataChangeFilter{
-				Trigger:       ua.DataChangeTriggerFromString(string(monParams.DataChangeFilter.Trigger)),
-				DeadbandType:  uint32(ua.DeadbandTypeFromString(string(monParams.DataChangeFilter.DeadbandType))),
-				DeadbandValue: *monParams.DataChangeFilter.DeadbandValue,
-			},
-		)
+		// The value of dynamic deadbands is set when reading the source node
+		var deadbandValue float64
+		if monParams.DataChangeFilter.DeadbandValue != nil {
+			deadbandValue = *monParams.DataChangeFilter.DeadbandValue
+		}
+		filter := &ua.DataChangeFilter{
+			Trigger:       ua.DataChangeTriggerFromString(string(monParams.DataChangeFilter.Trigger)),
+			DeadbandType:  uint32(ua.DeadbandTypeFromString(string(monParams.DataChangeFilter.DeadbandType))),
+			DeadbandValue: deadbandValue,
+		}
+		// The deadband is applied when receiving the values
+		if monParams.DataChangeFilter.ClientSide {
+			filter.DeadbandType = uint32(ua.DeadbandTypeNone)
+			filter.DeadbandValue = 0
+		}
+		req.RequestedParameters.Filter = ua.NewExtensionObject(filter)
 	}
 
 	return nil
@@ -108,6 +170,9 @@ func (sc *subscribeClientConfig) createSubscribeClient(log telegraf.Logger) (*su
 		// the same time. It could be made dependent on the number of nodes subscribed to and the subscription interval.
 		dataNotifications: make(chan *opcua.PublishNotificationData, 100),
 		metrics:           make(chan telegraf.Metric, 100),
+		deadbands:         make(map[int]*deadband),
+		dynamicDeadbands:  make(map[int]*dynamicDeadband),
+		monitoredItemIDs:  make([]uint32, len(client.NodeIDs)),
 		ctx:               processingCtx,
 		cancel:            processingCancel,
 	}
@@ -120,13 +185,33 @@ func (sc *subscribeClientConfig) createSubscribeClient(log telegraf.Logger) (*su
 			return nil, err
 		}
 		subClient.monitoredItemsReqs[i] = req
+
+		if f := client.NodeMetricMapping[i].Tag.MonitoringParams.DataChangeFilter; f != nil && f.DeadbandValueNode != "" {
+			source, err := ua.ParseNodeID(f.DeadbandValueNode)
+			if err != nil {
+				return nil, fmt.Errorf("invalid deadband_value_node %q: %w", f.DeadbandValueNode, err)
+			}
+			subClient.dynamicDeadbands[i] = &dynamicDeadband{source: source}
+		}
+	}
+	sc.checkIntervals(log, subClient.monitoredItemsReqs)
+
+	for _, group := range sc.Groups {
+		if group.Browse == nil {
+			continue
+		}
+		g, err := newBrowseGroup(sc.MetricName, group)
+		if err != nil {
+			return nil, err
+		}
+		subClient.browseGroups = append(subClient.browseGroups, g)
 	}
 
 	log.Debugf("Creating event streaming items")
 	for i, node := range client.EventNodeMetricMapping {
 		req := opcua.NewMonitoredItemCreateRequestWithDefaults(node.NodeID, ua.AttributeIDEventNotifier, uint32(i))
 		if node.SamplingInterval != nil {
-			req.RequestedParameters.SamplingInterval = float64(time.Duration(*node.SamplingInterval) / time.Millisecond)
+			req.RequestedParameters.SamplingInterval = toMilliseconds(time.Duration(*node.SamplingInterval))
 		}
 		if node.QueueSize != nil {
 			req.RequestedParameters.QueueSize = *node.QueueSize
@@ -143,26 +228,56 @@ func (sc *subscribeClientConfig) createSubscribeClient(log telegraf.Logger) (*su
 }
 
 func (o *subscribeClient) connect() error {
+	// Stop publishing the previous subscription before replacing the client
+	if o.sub != nil {
+		o.sub.stop()
+	}
+
 	err := o.OpcUAClient.Connect(o.ctx)
 	if err != nil {
 		return err
 	}
 
+	// Recover the notifications of the previous subscription if it is still
+	// known by the server, this requires the processing to run as the
+	// notifications are forwarded to it
+	if o.sub != nil && o.processing.Load() && o.Config.RepublishOnReconnect && !o.subscriptionLost.Load() {
+		o.recoverSubscription(o.ctx, o.sub.SubscriptionID)
+	}
+	o.subscriptionLost.Store(false)
+
+	// The monitored items of the previous subscription are gone
+	clear(o.monitoredItemIDs)
+
 	o.Log.Debugf("Creating OPC UA subscription")
-	o.sub, err = o.Client.Subscribe(o.ctx, &opcua.SubscriptionParameters{
-		Interval: time.Duration(o.Config.SubscriptionInterval),
-	}, o.dataNotifications)
+	o.sub, err = createSubscription(o.ctx, o.Client, &subscriptionParameters{
+		endpoint:       o.Config.Endpoint,
+		interval:       time.Duration(o.Config.SubscriptionInterval),
+		requestTimeout: time.Duration(o.Config.RequestTimeout),
+		republish:      o.Config.RepublishOnReconnect,
+		notifs:         o.dataNotifications,
+		lost:           func() { o.subscriptionLost.Store(true) },
+	}, o.Log)
 	if err != nil {
 		o.Log.Error("Failed to create subscription")
 		return err
 	}
+	o.sub.start(o.ctx)
 
 	o.Log.Debugf("Subscribed with subscription ID %d", o.sub.SubscriptionID)
+	o.setSubscription(o.sub)
+	o.checkRevisedPublishingInterval()
 	return nil
 }
 
 func (o *subscribeClient) stop(ctx context.Context) <-chan struct{} {
 	o.Log.Debugf("Stopping OPC subscription...")
+	o.deadbandLock.Lock()
+	defer o.deadbandLock.Unlock()
+
+	if o.sub != nil {
+		o.sub.stop()
+	}
 	if o.State() != opcuaclient.Connected {
 		return nil
 	}
@@ -170,6 +285,7 @@ func (o *subscribeClient) stop(ctx context.Context) <-chan struct{} {
 		if err := o.sub.Cancel(ctx); err != nil {
 			o.Log.Warn("Cancelling OPC UA subscription failed with error ", err)
 		}
+		o.setSubscription(nil)
 	}
 	closing := o.OpcUAInputClient.Stop(ctx)
 	o.cancel()
@@ -177,6 +293,9 @@ func (o *subscribeClient) stop(ctx context.Context) <-chan struct{} {
 }
 
 func (o *subscribeClient) startMonitoring(ctx context.Context) (<-chan telegraf.Metric, error) {
+	o.deadbandLock.Lock()
+	defer o.deadbandLock.Unlock()
+
 	err := o.connect()
 	if err != nil {
 		switch o.Config.ConnectFailBehavior {
@@ -190,6 +309,22 @@ func (o *subscribeClient) startMonitoring(ctx context.Context) (<-chan telegraf.
 		return nil, err
 	}
 
+	if err := o.OpcUAInputClient.CheckAccessLevels(ctx); err != nil {
+		return nil, err
+	}
+
+	changed, err := o.readDynamicDeadbands(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := o.applyDynamicDeadbands(ctx, changed, false); err != nil {
+		return nil, err
+	}
+
+	if err := o.checkPercentDeadbands(ctx); err != nil {
+		return nil, err
+	}
+
 	if len(o.monitoredItemsReqs) != 0 {
 		resp, err := o.sub.Monitor(ctx, ua.TimestampsToReturnBoth, o.monitoredItemsReqs...)
 		if err != nil {
@@ -206,9 +341,36 @@ func (o *subscribeClient) startMonitoring(ctx context.Context) (<-chan telegraf.
 				} else {
 					o.Log.Debugf("Failed to create monitored item for node %v (%v)", o.OpcUAInputClient.NodeMetricMapping[idx].Tag.FieldName, '?')
 				}
+				if f := o.NodeMetricMapping[idx].Tag.MonitoringParams.DataChangeFilter; f != nil && f.DeadbandType == input.Percent && !f.ClientSide {
+					switch res.StatusCode {
+					case ua.StatusBadMonitoredItemFilterUnsupported, ua.StatusBadFilterNotAllowed, ua.StatusBadDeadbandFilterInvalid:
+						return nil, fmt.Errorf("server rejected percent deadband of node %q, consider setting 'client_side = true': %w",
+							o.NodeMetricMapping[idx].Tag.FieldName, res.StatusCode)
+					}
+				}
 				return nil, fmt.Errorf("creating monitored item failed with status code: %w", res.StatusCode)
 			}
 		}
+		for idx, res := range resp.Results {
+			o.monitoredItemIDs[idx] = res.MonitoredItemID
+		}
+		o.checkRevisedSamplingIntervals(o.monitoredItemsReqs, resp.Results)
+		o.setMonitoredItems(func(items *monitoredItemsDiagnostics) { items.Nodes = len(resp.Results) })
+	}
+
+	if len(o.browseGroups) != 0 {
+		if err := o.syncBrowsed(ctx, true); err != nil {
+			return nil, err
+		}
+		if o.Config.RebrowseInterval > 0 && !o.rebrowsing {
+			o.rebrowsing = true
+			go o.rebrowse(time.Duration(o.Config.RebrowseInterval))
+		}
+	}
+
+	if len(o.dynamicDeadbands) != 0 && o.Config.DeadbandRefreshInterval > 0 && !o.refreshingDeadbands {
+		o.refreshingDeadbands = true
+		go o.refreshDynamicDeadbands(time.Duration(o.Config.DeadbandRefreshInterval))
 	}
 
 	if len(o.eventItemsReqs) != 0 {
@@ -223,14 +385,20 @@ func (o *subscribeClient) startMonitoring(ctx context.Context) (<-chan telegraf.
 				return nil, fmt.Errorf("creating monitored event streaming item failed with status code: %w", res.StatusCode)
 			}
 		}
+		o.setMonitoredItems(func(items *monitoredItemsDiagnostics) { items.Events = len(resp.Results) })
 	}
 
-	go o.processReceivedNotifications()
+	if o.processing.CompareAndSwap(false, true) {
+		go o.processReceivedNotifications()
+	}
 
 	return o.metrics, nil
 }
 
 func (o *subscribeClient) processReceivedNotifications() {
+	// Allow restarting the processing on the next reconnect
+	defer o.processing.Store(false)
+
 	for {
 		select {
 		case <-o.ctx.Done():
@@ -257,11 +425,19 @@ func (o *subscribeClient) processReceivedNotifications() {
 				// It is assumed the notifications are ordered chronologically
 				for _, monitoredItemNotif := range notif.MonitoredItems {
 					i := int(monitoredItemNotif.ClientHandle)
+					o.nodesLock.Lock()
+					o.recordUpdate(i, o.Now())
+					if d, found := o.deadbands[i]; found && !d.exceeded(o.LastReceivedData[i].Quality, monitoredItemNotif.Value) {
+						o.nodesLock.Unlock()
+						continue
+					}
 					oldValue := o.LastReceivedData[i].Value
 					o.UpdateNodeValue(i, monitoredItemNotif.Value)
 					o.Log.Debugf("Data change notification: node %q value changed from %v to %v",
 						o.NodeIDs[i].String(), oldValue, o.LastReceivedData[i].Value)
-					o.metrics <- o.MetricForNode(i)
+					m := o.MetricForNode(i)
+					o.nodesLock.Unlock()
+					o.metrics <- m
 				}
 			case *ua.EventNotificationList:
 				o.Log.Debugf("Processing event notification with %d events", len(notif.Events))
@@ -0,0 +1,330 @@
+package opcua_listener
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/selfstat"
+)
+
+// Interval for retrying to publish while the connection is unavailable
+const publishRetryInterval = time.Second
+
+// requestSender sends OPC UA service requests, e.g. via the client library
+type requestSender interface {
+	Send(ctx context.Context, req ua.Request, h func(ua.Response) error) error
+}
+
+// subscription is an OPC UA subscription running its own publish loop. The
+// publish loop of the client library does not expose the sequence numbers
+// of the notification messages, so missed messages could not be detected.
+// Here, the sequence numbers are tracked and gaps are republished from the
+// retransmission queue of the server.
+type subscription struct {
+	SubscriptionID            uint32
+	RevisedPublishingInterval time.Duration
+	RevisedLifetimeCount      uint32
+	RevisedMaxKeepAliveCount  uint32
+
+	client    requestSender
+	notifs    chan<- *opcua.PublishNotificationData
+	republish bool
+	log       telegraf.Logger
+
+	// lost is called if the subscription cannot be used anymore, e.g. if it
+	// cannot be transferred to the session restored by the client library
+	lost func()
+
+	statRepublished selfstat.Stat
+	statLost        selfstat.Stat
+
+	// sequence number of the next notification message expected and the
+	// acknowledgements to send with the next publish request, both are
+	// only accessed by the publish loop
+	nextSeq uint32
+	acks    []*ua.SubscriptionAcknowledgement
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// subscriptionParameters are the settings for creating a subscription
+type subscriptionParameters struct {
+	endpoint       string
+	interval       time.Duration
+	requestTimeout time.Duration
+	republish      bool
+	notifs         chan<- *opcua.PublishNotificationData
+	lost           func()
+}
+
+// createSubscription creates a subscription on the server. The publish loop
+// is started separately once the subscription is set up.
+func createSubscription(ctx context.Context, client requestSender, params *subscriptionParameters, log telegraf.Logger) (*subscription, error) {
+	interval := params.interval
+	if interval <= 0 {
+		interval = opcua.DefaultSubscriptionInterval
+	}
+
+	var resp *ua.CreateSubscriptionResponse
+	err := client.Send(ctx, &ua.CreateSubscriptionRequest{
+		RequestedPublishingInterval: float64(interval / time.Millisecond),
+		RequestedLifetimeCount:      opcua.DefaultSubscriptionLifetimeCount,
+		RequestedMaxKeepAliveCount:  keepAliveCount(interval, params.requestTimeout),
+		MaxNotificationsPerPublish:  opcua.DefaultSubscriptionMaxNotificationsPerPublish,
+		PublishingEnabled:           true,
+		Priority:                    opcua.DefaultSubscriptionPriority,
+	}, func(v ua.Response) error {
+		return assignResponse(v, &resp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.SubscriptionID == 0 {
+		return nil, ua.StatusBadSubscriptionIDInvalid
+	}
+
+	tags := map[string]string{"endpoint": params.endpoint}
+	return &subscription{
+		SubscriptionID:            resp.SubscriptionID,
+		RevisedPublishingInterval: time.Duration(resp.RevisedPublishingInterval) * time.Millisecond,
+		RevisedLifetimeCount:      resp.RevisedLifetimeCount,
+		RevisedMaxKeepAliveCount:  resp.RevisedMaxKeepAliveCount,
+		client:                    client,
+		notifs:                    params.notifs,
+		republish:                 params.republish,
+		log:                       log,
+		lost:                      params.lost,
+		statRepublished:           selfstat.Register("opcua", "notifications_republished", tags),
+		statLost:                  selfstat.Register("opcua", "notifications_lost", tags),
+		nextSeq:                   1,
+	}, nil
+}
+
+// keepAliveCount returns the number of publishing intervals in between two
+// keep-alive messages so publish requests are answered within the request
+// timeout even if no data changes
+func keepAliveCount(interval, timeout time.Duration) uint32 {
+	if timeout <= 0 {
+		return opcua.DefaultSubscriptionMaxKeepAliveCount
+	}
+	count := int64(timeout / (2 * interval))
+	return uint32(max(1, min(count, opcua.DefaultSubscriptionMaxKeepAliveCount)))
+}
+
+// Monitor creates the given monitored items in the subscription
+func (s *subscription) Monitor(ctx context.Context, ts ua.TimestampsToReturn, items ...*ua.MonitoredItemCreateRequest) (*ua.CreateMonitoredItemsResponse, error) {
+	var resp *ua.CreateMonitoredItemsResponse
+	err := s.client.Send(ctx, &ua.CreateMonitoredItemsRequest{
+		SubscriptionID:     s.SubscriptionID,
+		TimestampsToReturn: ts,
+		ItemsToCreate:      items,
+	}, func(v ua.Response) error {
+		return assignResponse(v, &resp)
+	})
+	return resp, err
+}
+
+// Unmonitor deletes the given monitored items from the subscription
+func (s *subscription) Unmonitor(ctx context.Context, ids ...uint32) (*ua.DeleteMonitoredItemsResponse, error) {
+	var resp *ua.DeleteMonitoredItemsResponse
+	err := s.client.Send(ctx, &ua.DeleteMonitoredItemsRequest{
+		SubscriptionID:   s.SubscriptionID,
+		MonitoredItemIDs: ids,
+	}, func(v ua.Response) error {
+		return assignResponse(v, &resp)
+	})
+	return resp, err
+}
+
+// ModifyMonitoredItems modifies the parameters of the given monitored items
+func (s *subscription) ModifyMonitoredItems(ctx context.Context, ts ua.TimestampsToReturn, items ...*ua.MonitoredItemModifyRequest) (*ua.ModifyMonitoredItemsResponse, error) {
+	var resp *ua.ModifyMonitoredItemsResponse
+	err := s.client.Send(ctx, &ua.ModifyMonitoredItemsRequest{
+		SubscriptionID:     s.SubscriptionID,
+		TimestampsToReturn: ts,
+		ItemsToModify:      items,
+	}, func(v ua.Response) error {
+		return assignResponse(v, &resp)
+	})
+	return resp, err
+}
+
+// Cancel stops the publish loop and deletes the subscription on the server
+func (s *subscription) Cancel(ctx context.Context) error {
+	s.stop()
+
+	var resp *ua.DeleteSubscriptionsResponse
+	err := s.client.Send(ctx, &ua.DeleteSubscriptionsRequest{SubscriptionIDs: []uint32{s.SubscriptionID}}, func(v ua.Response) error {
+		return assignResponse(v, &resp)
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Results) != 1 {
+		return fmt.Errorf("expected one result but got %d", len(resp.Results))
+	}
+	if resp.Results[0] != ua.StatusOK {
+		return resp.Results[0]
+	}
+	return nil
+}
+
+// start runs the publish loop until the subscription is stopped
+func (s *subscription) start(ctx context.Context) {
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go s.run(ctx)
+}
+
+// stop terminates the publish loop and waits for it to finish
+func (s *subscription) stop() {
+	if s.cancel == nil {
+		return
+	}
+	s.cancel()
+	<-s.done
+}
+
+func (s *subscription) run(ctx context.Context) {
+	defer close(s.done)
+
+	for {
+		var resp *ua.PublishResponse
+		err := s.client.Send(ctx, &ua.PublishRequest{
+			SubscriptionAcknowledgements: s.acks,
+		}, func(v ua.Response) error {
+			return assignResponse(v, &resp)
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		switch {
+		case err == nil:
+			s.acks = nil
+			s.handle(ctx, resp.NotificationMessage)
+		case errors.Is(err, ua.StatusBadTimeout):
+			// The server did not answer within the request timeout, a
+			// message sent later on is detected as gap and republished
+		case errors.Is(err, ua.StatusBadNoSubscription), errors.Is(err, ua.StatusBadSubscriptionIDInvalid):
+			// The client library restored the connection with a new session
+			// not owning the subscription anymore, the messages missed in
+			// the meantime are republished as gap after the transfer
+			if _, err := transferSubscription(ctx, s.client, s.SubscriptionID); err != nil {
+				s.log.Errorf("Transferring subscription %d to the restored session failed: %v", s.SubscriptionID, err)
+				if s.lost != nil {
+					s.lost()
+				}
+				return
+			}
+			s.log.Debugf("Transferred subscription %d to the restored session", s.SubscriptionID)
+			s.acks = nil
+		default:
+			// The client library is reconnecting
+			s.log.Debugf("Publishing subscription %d failed: %v", s.SubscriptionID, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(publishRetryInterval):
+			}
+		}
+	}
+}
+
+// handle checks the sequence number of the given message for gaps before
+// forwarding the contained notifications. Keep-alive messages do not
+// contain notifications but carry the sequence number of the next message.
+func (s *subscription) handle(ctx context.Context, msg *ua.NotificationMessage) {
+	if msg == nil {
+		s.forward(ctx, &opcua.PublishNotificationData{
+			SubscriptionID: s.SubscriptionID,
+			Error:          errors.New("empty notification message"),
+		})
+		return
+	}
+
+	if msg.SequenceNumber > s.nextSeq {
+		s.recover(ctx, s.nextSeq, msg.SequenceNumber-1)
+		s.nextSeq = msg.SequenceNumber
+	}
+
+	if len(msg.NotificationData) == 0 {
+		return
+	}
+
+	s.acks = append(s.acks, &ua.SubscriptionAcknowledgement{
+		SubscriptionID: s.SubscriptionID,
+		SequenceNumber: msg.SequenceNumber,
+	})
+	// Messages delivered late might have been republished already
+	if msg.SequenceNumber < s.nextSeq {
+		return
+	}
+	s.nextSeq = msg.SequenceNumber + 1
+	s.notify(ctx, msg)
+}
+
+// recover republishes the messages with the given range of sequence numbers
+func (s *subscription) recover(ctx context.Context, first, last uint32) {
+	missing := int64(last - first + 1)
+	if !s.republish {
+		s.log.Warnf("Missed %d notification messages of subscription %d", missing, s.SubscriptionID)
+		s.statLost.Incr(missing)
+		return
+	}
+
+	s.log.Debugf("Missed notification messages %d to %d of subscription %d, requesting republish", first, last, s.SubscriptionID)
+	var count, lost int64
+	for seq := first; seq <= last; seq++ {
+		msg, err := republishMessage(ctx, s.client, s.SubscriptionID, seq)
+		if errors.Is(err, ua.StatusBadMessageNotAvailable) {
+			lost++
+			continue
+		}
+		if err != nil {
+			s.log.Warnf("Republishing notification %d of subscription %d failed: %v", seq, s.SubscriptionID, err)
+			lost += int64(last-seq) + 1
+			break
+		}
+
+		s.acks = append(s.acks, &ua.SubscriptionAcknowledgement{
+			SubscriptionID: s.SubscriptionID,
+			SequenceNumber: seq,
+		})
+		s.notify(ctx, msg)
+		count++
+	}
+	s.statRepublished.Incr(count)
+	s.statLost.Incr(lost)
+	if lost > 0 {
+		s.log.Warnf("Server did not retain %d of %d missed notification messages of subscription %d", lost, missing, s.SubscriptionID)
+	}
+}
+
+// notify forwards the notifications contained in the given message
+func (s *subscription) notify(ctx context.Context, msg *ua.NotificationMessage) {
+	for _, data := range msg.NotificationData {
+		if data == nil || data.Value == nil {
+			s.forward(ctx, &opcua.PublishNotificationData{
+				SubscriptionID: s.SubscriptionID,
+				Error:          errors.New("missing notification data"),
+			})
+			continue
+		}
+		s.forward(ctx, &opcua.PublishNotificationData{SubscriptionID: s.SubscriptionID, Value: data.Value})
+	}
+}
+
+func (s *subscription) forward(ctx context.Context, data *opcua.PublishNotificationData) {
+	select {
+	case s.notifs <- data:
+	case <-ctx.Done():
+	}
+}
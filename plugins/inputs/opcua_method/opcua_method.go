@@ -0,0 +1,330 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package opcua_method
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/common/opcua"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// NodeSettings describes a OPC UA node
+type NodeSettings struct {
+	Namespace      string `toml:"namespace"`
+	IdentifierType string `toml:"identifier_type"`
+	Identifier     string `toml:"identifier"`
+}
+
+// NodeID returns the OPC UA node id
+func (n *NodeSettings) NodeID() string {
+	return "ns=" + n.Namespace + ";" + n.IdentifierType + "=" + n.Identifier
+}
+
+func (n *NodeSettings) parse() (*ua.NodeID, error) {
+	if n.Namespace == "" {
+		return nil, errors.New("namespace must be set")
+	}
+	if n.Identifier == "" {
+		return nil, errors.New("identifier must be set")
+	}
+	if err := choice.Check(n.IdentifierType, []string{"s", "i", "g", "b"}); err != nil {
+		return nil, fmt.Errorf("invalid identifier type: %w", err)
+	}
+	return ua.ParseNodeID(n.NodeID())
+}
+
+// ArgumentSettings describes a templated input argument of a method
+type ArgumentSettings struct {
+	Value    string `toml:"value"`
+	DataType string `toml:"data_type"`
+
+	tmpl *template.Template
+}
+
+// MethodSettings describes a method to call and how to map the result
+type MethodSettings struct {
+	Name        string             `toml:"name"`
+	Object      NodeSettings       `toml:"object"`
+	Method      NodeSettings       `toml:"method"`
+	Inputs      []ArgumentSettings `toml:"inputs"`
+	Outputs     []string           `toml:"outputs"`
+	DefaultTags map[string]string  `toml:"default_tags"`
+
+	objectID *ua.NodeID
+	methodID *ua.NodeID
+	lastCall time.Time
+}
+
+// templateData is the data available for rendering input arguments
+type templateData struct {
+	Name     string
+	Time     time.Time
+	LastTime time.Time
+}
+
+type OpcUAMethod struct {
+	opcua.OpcUAClientConfig
+	MetricName string           `toml:"name"`
+	Methods    []MethodSettings `toml:"methods"`
+	Log        telegraf.Logger  `toml:"-"`
+
+	client                *opcua.OpcUAClient
+	unregisterDiagnostics func()
+}
+
+func (*OpcUAMethod) SampleConfig() string {
+	return sampleConfig
+}
+
+func (o *OpcUAMethod) Init() error {
+	if o.MetricName == "" {
+		return errors.New("metric name is empty")
+	}
+	if len(o.Methods) == 0 {
+		return errors.New("no methods configured")
+	}
+
+	names := make(map[string]bool, len(o.Methods))
+	for i := range o.Methods {
+		m := &o.Methods[i]
+		if m.Name == "" {
+			return fmt.Errorf("empty name for method %q", m.Method.NodeID())
+		}
+		if names[m.Name] {
+			return fmt.Errorf("duplicate method name %q", m.Name)
+		}
+		names[m.Name] = true
+
+		var err error
+		if m.objectID, err = m.Object.parse(); err != nil {
+			return fmt.Errorf("invalid object for method %q: %w", m.Name, err)
+		}
+		if m.methodID, err = m.Method.parse(); err != nil {
+			return fmt.Errorf("invalid method for method %q: %w", m.Name, err)
+		}
+
+		for j := range m.Inputs {
+			arg := &m.Inputs[j]
+			if err := choice.Check(arg.DataType, opcua.DataTypes); err != nil {
+				return fmt.Errorf("invalid data type for input %d of method %q: %w", j+1, m.Name, err)
+			}
+			arg.tmpl, err = template.New(m.Name).Funcs(sprig.TxtFuncMap()).Parse(arg.Value)
+			if err != nil {
+				return fmt.Errorf("parsing template for input %d of method %q failed: %w", j+1, m.Name, err)
+			}
+		}
+	}
+
+	client, err := o.OpcUAClientConfig.CreateClient(o.Log)
+	if err != nil {
+		return err
+	}
+	o.client = client
+
+	return nil
+}
+
+func (o *OpcUAMethod) Start(telegraf.Accumulator) error {
+	unregister, err := opcua.RegisterDiagnostics(o.DiagnosticsAddress, "inputs.opcua_method", func() interface{} {
+		return o.client.Diagnostics()
+	})
+	if err != nil {
+		return err
+	}
+	o.unregisterDiagnostics = unregister
+	return nil
+}
+
+func (o *OpcUAMethod) Stop() {
+	if o.unregisterDiagnostics != nil {
+		o.unregisterDiagnostics()
+	}
+	if o.client.State() == opcua.Connected {
+		if err := o.client.Disconnect(context.Background()); err != nil {
+			o.Log.Errorf("Disconnecting failed: %v", err)
+		}
+	}
+}
+
+func (o *OpcUAMethod) Gather(acc telegraf.Accumulator) error {
+	// Will (re)connect if the client is disconnected
+	if state := o.client.State(); state == opcua.Disconnected || state == opcua.Closed {
+		if err := o.client.Connect(context.Background()); err != nil {
+			return fmt.Errorf("connect failed: %w", err)
+		}
+	}
+
+	for i := range o.Methods {
+		method := &o.Methods[i]
+		now := time.Now()
+
+		// Failing to create the arguments is a local problem so skip the
+		// method but keep the connection
+		args, err := o.renderInputs(method, now)
+		if err != nil {
+			acc.AddError(err)
+			continue
+		}
+
+		m, err := o.call(method, args, now)
+		if err != nil {
+			// We do not return the disconnect error, as this would mask the
+			// original problem, but we do log it
+			if derr := o.client.Disconnect(context.Background()); derr != nil {
+				o.Log.Debug("Error while disconnecting: ", derr)
+			}
+			return err
+		}
+		acc.AddMetric(m)
+	}
+
+	return nil
+}
+
+func (o *OpcUAMethod) call(method *MethodSettings, args []*ua.Variant, now time.Time) (telegraf.Metric, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(o.RequestTimeout))
+	defer cancel()
+	result, err := o.client.Client.Call(ctx, &ua.CallMethodRequest{
+		ObjectID:       method.objectID,
+		MethodID:       method.methodID,
+		InputArguments: args,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("calling method %q failed: %w", method.Name, err)
+	}
+	method.lastCall = now
+
+	return o.metricForResult(method, result, now), nil
+}
+
+// renderInputs creates the input arguments of the method from the templates
+func (*OpcUAMethod) renderInputs(method *MethodSettings, now time.Time) ([]*ua.Variant, error) {
+	data := templateData{
+		Name:     method.Name,
+		Time:     now,
+		LastTime: method.lastCall,
+	}
+	if data.LastTime.IsZero() {
+		data.LastTime = now
+	}
+
+	args := make([]*ua.Variant, 0, len(method.Inputs))
+	for i, arg := range method.Inputs {
+		var buf strings.Builder
+		if err := arg.tmpl.Execute(&buf, data); err != nil {
+			return nil, fmt.Errorf("rendering input %d of method %q failed: %w", i+1, method.Name, err)
+		}
+		value, err := opcua.ConvertTo(buf.String(), arg.DataType)
+		if err != nil {
+			return nil, fmt.Errorf("converting input %d of method %q failed: %w", i+1, method.Name, err)
+		}
+		variant, err := ua.NewVariant(value)
+		if err != nil {
+			return nil, fmt.Errorf("creating variant for input %d of method %q failed: %w", i+1, method.Name, err)
+		}
+		args = append(args, variant)
+	}
+
+	return args, nil
+}
+
+func (o *OpcUAMethod) metricForResult(method *MethodSettings, result *ua.CallMethodResult, t time.Time) telegraf.Metric {
+	tags := map[string]string{
+		"method": method.Name,
+		"id":     method.Method.NodeID(),
+	}
+	for k, v := range method.DefaultTags {
+		tags[k] = v
+	}
+
+	fields := map[string]interface{}{
+		"Quality": strings.TrimSpace(result.StatusCode.Error()),
+	}
+	if !o.client.StatusCodeOK(result.StatusCode) {
+		o.Log.Errorf("status not OK for method %q: %v", method.Name, result.StatusCode)
+		return metric.New(o.MetricName, tags, fields, t)
+	}
+
+	for i, output := range result.OutputArguments {
+		name := "output_" + strconv.Itoa(i+1)
+		if i < len(method.Outputs) && method.Outputs[i] != "" {
+			name = method.Outputs[i]
+		}
+
+		if output == nil {
+			o.Log.Debugf("Output %q of method %q has no value", name, method.Name)
+			continue
+		}
+		o.addOutput(fields, method.Name, name, output.Value())
+	}
+
+	return metric.New(o.MetricName, tags, fields, t)
+}
+
+// addOutput adds the value of an output argument as field. Arrays are
+// flattened to one field per element suffixed by the element index.
+func (o *OpcUAMethod) addOutput(fields map[string]interface{}, method, name string, value interface{}) {
+	switch v := value.(type) {
+	case nil:
+		o.Log.Debugf("Output %q of method %q has no value", name, method)
+	case *ua.Variant:
+		if v == nil {
+			o.Log.Debugf("Output %q of method %q has no value", name, method)
+			return
+		}
+		o.addOutput(fields, method, name, v.Value())
+	case *ua.LocalizedText:
+		if v == nil {
+			o.Log.Debugf("Output %q of method %q has no value", name, method)
+			return
+		}
+		fields[name] = v.Text
+	case time.Time:
+		fields[name] = v.Format(time.RFC3339Nano)
+	case []byte:
+		fields[name] = v
+	default:
+		rv := reflect.ValueOf(v)
+		if rv.Kind() != reflect.Slice {
+			fields[name] = v
+			return
+		}
+		for i := range rv.Len() {
+			o.addOutput(fields, method, name+"_"+strconv.Itoa(i), rv.Index(i).Interface())
+		}
+	}
+}
+
+func init() {
+	inputs.Add("opcua_method", func() telegraf.Input {
+		return &OpcUAMethod{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       "opc.tcp://localhost:4840",
+				SecurityPolicy: "auto",
+				SecurityMode:   "auto",
+				AuthMethod:     "Anonymous",
+				ConnectTimeout: config.Duration(5 * time.Second),
+				RequestTimeout: config.Duration(10 * time.Second),
+			},
+			MetricName: "opcua_method",
+		}
+	})
+}
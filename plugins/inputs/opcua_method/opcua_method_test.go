@@ -0,0 +1,329 @@
+package opcua_method
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/common/opcua"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	object := NodeSettings{Namespace: "1", IdentifierType: "s", Identifier: "Machine"}
+	method := NodeSettings{Namespace: "1", IdentifierType: "s", Identifier: "Machine.Counters"}
+
+	tests := []struct {
+		name     string
+		methods  []MethodSettings
+		expected string
+	}{
+		{
+			name:     "no methods",
+			expected: "no methods configured",
+		},
+		{
+			name:     "no name",
+			methods:  []MethodSettings{{Object: object, Method: method}},
+			expected: "empty name",
+		},
+		{
+			name: "duplicate name",
+			methods: []MethodSettings{
+				{Name: "counters", Object: object, Method: method},
+				{Name: "counters", Object: object, Method: method},
+			},
+			expected: "duplicate method name",
+		},
+		{
+			name:     "invalid object",
+			methods:  []MethodSettings{{Name: "counters", Method: method}},
+			expected: "invalid object",
+		},
+		{
+			name: "invalid method",
+			methods: []MethodSettings{{
+				Name:   "counters",
+				Object: object,
+				Method: NodeSettings{Namespace: "1", IdentifierType: "x", Identifier: "a"},
+			}},
+			expected: "invalid method",
+		},
+		{
+			name: "invalid data type",
+			methods: []MethodSettings{{
+				Name:   "counters",
+				Object: object,
+				Method: method,
+				Inputs: []ArgumentSettings{{Value: "1", DataType: "Decimal"}},
+			}},
+			expected: "invalid data type for input 1",
+		},
+		{
+			name: "invalid template",
+			methods: []MethodSettings{{
+				Name:   "counters",
+				Object: object,
+				Method: method,
+				Inputs: []ArgumentSettings{{Value: "{{ .Time", DataType: "String"}},
+			}},
+			expected: "parsing template for input 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &OpcUAMethod{
+				OpcUAClientConfig: opcua.OpcUAClientConfig{
+					Endpoint:       "opc.tcp://localhost:4840",
+					SecurityPolicy: "None",
+					SecurityMode:   "None",
+					AuthMethod:     "Anonymous",
+				},
+				MetricName: "opcua_method",
+				Methods:    tt.methods,
+				Log:        testutil.Logger{},
+			}
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestConfig(t *testing.T) {
+	toml := `
+[[inputs.opcua_method]]
+  endpoint = "opc.tcp://localhost:4840"
+  security_policy = "None"
+  security_mode = "None"
+
+  [[inputs.opcua_method.methods]]
+    name = "counters"
+    object = { namespace = "2", identifier_type = "s", identifier = "Machine" }
+    method = { namespace = "2", identifier_type = "s", identifier = "Machine.GetCounters" }
+    outputs = ["good", "bad"]
+    default_tags = { line = "1" }
+
+    [[inputs.opcua_method.methods.inputs]]
+      value = "{{ .LastTime.Unix }}"
+      data_type = "Int64"
+`
+	c := config.NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(toml), config.EmptySourcePath))
+	require.Len(t, c.Inputs, 1)
+
+	plugin, ok := c.Inputs[0].Input.(*OpcUAMethod)
+	require.True(t, ok)
+	require.NoError(t, plugin.Init())
+
+	require.Equal(t, "opcua_method", plugin.MetricName)
+	require.Len(t, plugin.Methods, 1)
+	m := plugin.Methods[0]
+	require.Equal(t, "counters", m.Name)
+	require.Equal(t, "ns=2;s=Machine", m.objectID.String())
+	require.Equal(t, "ns=2;s=Machine.GetCounters", m.methodID.String())
+	require.Equal(t, []string{"good", "bad"}, m.Outputs)
+	require.Equal(t, map[string]string{"line": "1"}, m.DefaultTags)
+	require.Len(t, m.Inputs, 1)
+	require.Equal(t, "Int64", m.Inputs[0].DataType)
+}
+
+func TestMetricForResult(t *testing.T) {
+	plugin := &OpcUAMethod{
+		OpcUAClientConfig: opcua.OpcUAClientConfig{
+			Endpoint:       "opc.tcp://localhost:4840",
+			SecurityPolicy: "None",
+			SecurityMode:   "None",
+			AuthMethod:     "Anonymous",
+		},
+		MetricName: "opcua_method",
+		Methods: []MethodSettings{
+			{
+				Name:        "counters",
+				Object:      NodeSettings{Namespace: "2", IdentifierType: "s", Identifier: "Machine"},
+				Method:      NodeSettings{Namespace: "2", IdentifierType: "s", Identifier: "Machine.GetCounters"},
+				Outputs:     []string{"good"},
+				DefaultTags: map[string]string{"line": "1"},
+			},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	ts := time.Unix(1700000000, 0)
+	tags := map[string]string{
+		"method": "counters",
+		"id":     "ns=2;s=Machine.GetCounters",
+		"line":   "1",
+	}
+
+	tests := []struct {
+		name     string
+		result   *ua.CallMethodResult
+		expected telegraf.Metric
+	}{
+		{
+			name: "good",
+			result: &ua.CallMethodResult{
+				StatusCode: ua.StatusOK,
+				OutputArguments: []*ua.Variant{
+					ua.MustVariant(int32(4711)),
+					ua.MustVariant(&ua.LocalizedText{Text: "running"}),
+					ua.MustVariant(time.Unix(1600000000, 0).UTC()),
+				},
+			},
+			expected: metric.New(
+				"opcua_method",
+				tags,
+				map[string]interface{}{
+					"Quality":  "The operation succeeded. StatusGood (0x0)",
+					"good":     int64(4711),
+					"output_2": "running",
+					"output_3": "2020-09-13T12:26:40Z",
+				},
+				ts,
+			),
+		},
+		{
+			name: "arrays",
+			result: &ua.CallMethodResult{
+				StatusCode: ua.StatusOK,
+				OutputArguments: []*ua.Variant{
+					ua.MustVariant([]int32{1, 2}),
+					ua.MustVariant([]string{"a", "b", "c"}),
+					ua.MustVariant([]*ua.LocalizedText{{Text: "x"}, nil}),
+				},
+			},
+			expected: metric.New(
+				"opcua_method",
+				tags,
+				map[string]interface{}{
+					"Quality":    "The operation succeeded. StatusGood (0x0)",
+					"good_0":     int64(1),
+					"good_1":     int64(2),
+					"output_2_0": "a",
+					"output_2_1": "b",
+					"output_2_2": "c",
+					"output_3_0": "x",
+				},
+				ts,
+			),
+		},
+		{
+			name: "bad",
+			result: &ua.CallMethodResult{
+				StatusCode:      ua.StatusBadMethodInvalid,
+				OutputArguments: []*ua.Variant{ua.MustVariant(int32(4711))},
+			},
+			expected: metric.New(
+				"opcua_method",
+				tags,
+				map[string]interface{}{
+					"Quality": "The method id does not refer to a method for the specified object. StatusBadMethodInvalid (0x80750000)",
+				},
+				ts,
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := plugin.metricForResult(&plugin.Methods[0], tt.result, ts)
+			testutil.RequireMetricEqual(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestRenderInputs(t *testing.T) {
+	plugin := &OpcUAMethod{
+		OpcUAClientConfig: opcua.OpcUAClientConfig{
+			Endpoint:       "opc.tcp://localhost:4840",
+			SecurityPolicy: "None",
+			SecurityMode:   "None",
+			AuthMethod:     "Anonymous",
+		},
+		MetricName: "opcua_method",
+		Methods: []MethodSettings{
+			{
+				Name:   "counters",
+				Object: NodeSettings{Namespace: "2", IdentifierType: "s", Identifier: "Machine"},
+				Method: NodeSettings{Namespace: "2", IdentifierType: "s", Identifier: "Machine.GetCounters"},
+				Inputs: []ArgumentSettings{
+					{Value: "{{ .LastTime.Unix }}", DataType: "Int64"},
+					{Value: "{{ .Name | upper }}", DataType: "String"},
+					{Value: "{{ sub .Time.Unix .LastTime.Unix }}", DataType: "UInt32"},
+				},
+			},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	m := &plugin.Methods[0]
+	m.lastCall = time.Unix(1700000000, 0)
+	args, err := plugin.renderInputs(m, time.Unix(1700000010, 0))
+	require.NoError(t, err)
+	require.Len(t, args, 3)
+	require.Equal(t, int64(1700000000), args[0].Value())
+	require.Equal(t, "COUNTERS", args[1].Value())
+	require.Equal(t, uint32(10), args[2].Value())
+}
+
+func TestGatherRenderErrorKeepsConnection(t *testing.T) {
+	port := testutil.FreeTCPPort(t)
+
+	srv := server.New(
+		server.EndPoint("127.0.0.1", port),
+		server.EnableSecurity("None", ua.MessageSecurityModeNone),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+	)
+	// Cancelling the server context crashes the server, so use the background context
+	require.NoError(t, srv.Start(context.Background()))
+	defer srv.Close()
+
+	plugin := &OpcUAMethod{
+		OpcUAClientConfig: opcua.OpcUAClientConfig{
+			Endpoint:       fmt.Sprintf("opc.tcp://127.0.0.1:%d", port),
+			SecurityPolicy: "None",
+			SecurityMode:   "None",
+			AuthMethod:     "Anonymous",
+			ConnectTimeout: config.Duration(5 * time.Second),
+			RequestTimeout: config.Duration(5 * time.Second),
+		},
+		MetricName: "opcua_method",
+		Methods: []MethodSettings{
+			{
+				Name:   "counters",
+				Object: NodeSettings{Namespace: "2", IdentifierType: "s", Identifier: "Machine"},
+				Method: NodeSettings{Namespace: "2", IdentifierType: "s", Identifier: "Machine.GetCounters"},
+				Inputs: []ArgumentSettings{{Value: "{{ .Name }}", DataType: "Int32"}},
+			},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	require.NoError(t, plugin.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.ErrorContains(t, acc.Errors[0], `converting input 1 of method "counters" failed`)
+	require.Empty(t, acc.GetTelegrafMetrics())
+
+	// The connection is kept and reused for the next gather
+	require.Equal(t, opcua.Connected, plugin.client.State())
+	connected := plugin.client.Client
+	require.NoError(t, plugin.Gather(&acc))
+	require.Same(t, connected, plugin.client.Client)
+
+	// Stopping the plugin closes the connection
+	plugin.Stop()
+	require.Equal(t, opcua.Disconnected, plugin.client.State())
+}
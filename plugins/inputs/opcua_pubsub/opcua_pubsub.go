@@ -0,0 +1,209 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package opcua_pubsub
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/mqtt"
+	"github.com/influxdata/telegraf/plugins/common/opcua/pubsub"
+	"github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type OpcUAPubSub struct {
+	MetricName        string          `toml:"name"`
+	Servers           []string        `toml:"servers"`
+	Topics            []string        `toml:"topics"`
+	TopicTag          string          `toml:"topic_tag"`
+	Username          config.Secret   `toml:"username"`
+	Password          config.Secret   `toml:"password"`
+	QoS               int             `toml:"qos"`
+	ClientID          string          `toml:"client_id"`
+	ConnectionTimeout config.Duration `toml:"connection_timeout"`
+	KeepAlive         int64           `toml:"keep_alive"`
+	PersistentSession bool            `toml:"persistent_session"`
+	ClientTrace       bool            `toml:"client_trace"`
+	Log               telegraf.Logger `toml:"-"`
+	tls.ClientConfig
+
+	acc       telegraf.Accumulator
+	cfg       *mqtt.MqttConfig
+	client    mqtt.Client
+	decoder   *pubsub.JSONDecoder
+	connected bool
+	sync.Mutex
+}
+
+func (*OpcUAPubSub) SampleConfig() string {
+	return sampleConfig
+}
+
+func (o *OpcUAPubSub) Init() error {
+	if o.MetricName == "" {
+		return errors.New("metric name is empty")
+	}
+	if len(o.Topics) == 0 {
+		return errors.New("no topics specified")
+	}
+
+	if o.ClientID == "" {
+		id, err := internal.RandomString(5)
+		if err != nil {
+			return fmt.Errorf("generating random client ID failed: %w", err)
+		}
+		o.ClientID = "Telegraf-OPCUA-PubSub-" + id
+	}
+
+	o.cfg = &mqtt.MqttConfig{
+		Servers:           o.Servers,
+		Protocol:          "3.1.1",
+		Username:          o.Username,
+		Password:          o.Password,
+		ConnectionTimeout: o.ConnectionTimeout,
+		QoS:               o.QoS,
+		ClientID:          o.ClientID,
+		KeepAlive:         o.KeepAlive,
+		PersistentSession: o.PersistentSession,
+		ClientTrace:       o.ClientTrace,
+		ClientConfig:      o.ClientConfig,
+		OnConnectionLost:  o.onConnectionLost,
+	}
+
+	// Check the settings by creating a client
+	if _, err := mqtt.NewClient(o.cfg); err != nil {
+		return err
+	}
+
+	o.decoder = pubsub.NewJSONDecoder()
+
+	return nil
+}
+
+func (o *OpcUAPubSub) Start(acc telegraf.Accumulator) error {
+	o.acc = acc
+	if err := o.connect(); err != nil {
+		return &internal.StartupError{Err: err, Retry: true}
+	}
+	return nil
+}
+
+func (o *OpcUAPubSub) Gather(telegraf.Accumulator) error {
+	o.Lock()
+	connected := o.connected
+	o.Unlock()
+
+	if !connected {
+		o.Log.Debugf("Connecting %v", o.Servers)
+		return o.connect()
+	}
+	return nil
+}
+
+func (o *OpcUAPubSub) Stop() {
+	o.Lock()
+	defer o.Unlock()
+
+	if o.client == nil {
+		return
+	}
+	if err := o.client.Close(); err != nil {
+		o.Log.Errorf("Closing connection failed: %v", err)
+	}
+	o.connected = false
+}
+
+func (o *OpcUAPubSub) connect() error {
+	client, err := mqtt.NewClient(o.cfg)
+	if err != nil {
+		return err
+	}
+
+	// Add the routes in case we find a persistent session containing
+	// subscriptions, so persisted messages are dispatched correctly
+	for _, topic := range o.Topics {
+		client.AddRoute(topic, o.onMessage)
+	}
+
+	sessionPresent, err := client.Connect()
+	if err != nil {
+		return fmt.Errorf("connecting to %v failed: %w", o.Servers, err)
+	}
+	o.Log.Infof("Connected %v", o.Servers)
+
+	o.Lock()
+	o.client = client
+	o.connected = true
+	o.Unlock()
+
+	// Persistent sessions should skip subscription if a session is present,
+	// as the subscriptions are stored by the server.
+	if sessionPresent {
+		o.Log.Debugf("Session found %v", o.Servers)
+		return nil
+	}
+
+	topics := make(map[string]byte, len(o.Topics))
+	for _, topic := range o.Topics {
+		topics[topic] = byte(o.QoS)
+	}
+	if err := client.SubscribeMultiple(topics, o.onMessage); err != nil {
+		return fmt.Errorf("subscribing to %v failed: %w", o.Topics, err)
+	}
+
+	return nil
+}
+
+func (o *OpcUAPubSub) onConnectionLost(err error) {
+	o.Lock()
+	o.connected = false
+	o.Unlock()
+
+	o.acc.AddError(fmt.Errorf("connection lost: %w", err))
+}
+
+func (o *OpcUAPubSub) onMessage(_ paho.Client, msg paho.Message) {
+	o.handle(msg.Topic(), msg.Payload(), time.Now())
+}
+
+func (o *OpcUAPubSub) handle(topic string, payload []byte, received time.Time) {
+	datasets, err := o.decoder.Decode(payload)
+	if err != nil {
+		o.acc.AddError(fmt.Errorf("decoding message on topic %q failed: %w", topic, err))
+		return
+	}
+
+	for _, ds := range datasets {
+		if ds.Timestamp.IsZero() {
+			ds.Timestamp = received
+		}
+		m := ds.Metric(o.MetricName)
+		if o.TopicTag != "" {
+			m.AddTag(o.TopicTag, topic)
+		}
+		o.acc.AddMetric(m)
+	}
+}
+
+func init() {
+	inputs.Add("opcua_pubsub", func() telegraf.Input {
+		return &OpcUAPubSub{
+			MetricName:        "opcua_pubsub",
+			Topics:            []string{"opcua/json/data/#", "opcua/json/metadata/#"},
+			TopicTag:          "topic",
+			ConnectionTimeout: config.Duration(30 * time.Second),
+			KeepAlive:         60,
+		}
+	})
+}
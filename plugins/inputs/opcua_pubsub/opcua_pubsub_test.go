@@ -0,0 +1,95 @@
+package opcua_pubsub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	plugin := &OpcUAPubSub{
+		MetricName: "opcua_pubsub",
+		Servers:    []string{"tcp://127.0.0.1:1883"},
+		Log:        testutil.Logger{},
+	}
+	require.ErrorContains(t, plugin.Init(), "no topics specified")
+}
+
+func TestHandle(t *testing.T) {
+	plugin := &OpcUAPubSub{
+		MetricName: "opcua_pubsub",
+		Servers:    []string{"tcp://127.0.0.1:1883"},
+		Topics:     []string{"opcua/json/#"},
+		TopicTag:   "topic",
+		Log:        testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	plugin.acc = &acc
+
+	metadata := `{
+		"MessageId": "1",
+		"MessageType": "ua-metadata",
+		"PublisherId": "MyPublisher",
+		"DataSetWriterId": 1,
+		"MetaData": {
+			"Name": "Boiler",
+			"Fields": [
+				{"Name": "Temperature", "BuiltInType": 11},
+				{"Name": "Counter", "BuiltInType": 8}
+			]
+		}
+	}`
+	data := `{
+		"MessageId": "2",
+		"MessageType": "ua-data",
+		"PublisherId": "MyPublisher",
+		"Messages": [
+			{"DataSetWriterId": 1, "Timestamp": "2024-01-01T12:00:00Z", "Payload": {"Temperature": 21.5, "Counter": 42}},
+			{"DataSetWriterId": 2, "Payload": {"Level": 3}}
+		]
+	}`
+
+	received := time.Unix(1700000000, 0)
+	plugin.handle("opcua/json/metadata/MyPublisher/1", []byte(metadata), received)
+	plugin.handle("opcua/json/data/MyPublisher", []byte(data), received)
+	plugin.handle("opcua/json/data/MyPublisher", []byte("foo"), received)
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"opcua_pubsub",
+			map[string]string{
+				"publisher_id": "MyPublisher",
+				"writer_id":    "1",
+				"dataset":      "Boiler",
+				"topic":        "opcua/json/data/MyPublisher",
+			},
+			map[string]interface{}{
+				"Temperature": float64(21.5),
+				"Counter":     int64(42),
+			},
+			time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		),
+		metric.New(
+			"opcua_pubsub",
+			map[string]string{
+				"publisher_id": "MyPublisher",
+				"writer_id":    "2",
+				"topic":        "opcua/json/data/MyPublisher",
+			},
+			map[string]interface{}{
+				"Level": int64(3),
+			},
+			received,
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+	require.Len(t, acc.Errors, 1)
+	require.ErrorContains(t, acc.Errors[0], "decoding message on topic")
+}
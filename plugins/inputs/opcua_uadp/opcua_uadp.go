@@ -0,0 +1,197 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package opcua_uadp
+
+import (
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/common/opcua/pubsub"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type OpcUAUADP struct {
+	ServiceAddress string          `toml:"service_address"`
+	Interface      string          `toml:"interface"`
+	ReadBufferSize config.Size     `toml:"read_buffer_size"`
+	MetricName     string          `toml:"name"`
+	DataSets       []DataSet       `toml:"dataset"`
+	Log            telegraf.Logger `toml:"-"`
+
+	network string
+	addr    *net.UDPAddr
+	conn    *net.UDPConn
+	decoder *pubsub.UADPDecoder
+	wg      sync.WaitGroup
+}
+
+// DataSet provides the metadata of a dataset for publishers not sending
+// DataSetMetaData messages
+type DataSet struct {
+	PublisherID string         `toml:"publisher_id"`
+	WriterID    uint16         `toml:"writer_id"`
+	Name        string         `toml:"name"`
+	Fields      []DataSetField `toml:"fields"`
+}
+
+type DataSetField struct {
+	Name     string `toml:"name"`
+	DataType string `toml:"data_type"`
+}
+
+func (*OpcUAUADP) SampleConfig() string {
+	return sampleConfig
+}
+
+func (o *OpcUAUADP) Init() error {
+	if o.ServiceAddress == "" {
+		return errors.New("service_address required")
+	}
+	if o.MetricName == "" {
+		return errors.New("metric name is empty")
+	}
+
+	u, err := url.Parse(o.ServiceAddress)
+	if err != nil {
+		return fmt.Errorf("invalid service address %q: %w", o.ServiceAddress, err)
+	}
+	switch u.Scheme {
+	case "opc.udp", "udp":
+		o.network = "udp"
+	case "udp4", "udp6":
+		o.network = u.Scheme
+	default:
+		return fmt.Errorf("invalid scheme %q, should be 'opc.udp', 'udp', 'udp4' or 'udp6'", u.Scheme)
+	}
+	o.addr, err = net.ResolveUDPAddr(o.network, u.Host)
+	if err != nil {
+		return fmt.Errorf("resolving service address %q failed: %w", o.ServiceAddress, err)
+	}
+	if o.Interface != "" && !o.addr.IP.IsMulticast() {
+		return errors.New("interface can only be set for multicast addresses")
+	}
+
+	o.decoder = pubsub.NewUADPDecoder()
+	for i, ds := range o.DataSets {
+		fields := make([]pubsub.FieldMetaData, 0, len(ds.Fields))
+		for j, f := range ds.Fields {
+			if f.Name == "" {
+				return fmt.Errorf("empty name for field %d of dataset %d", j+1, i+1)
+			}
+			builtin, found := pubsub.TypeIDByName(f.DataType)
+			if !found {
+				return fmt.Errorf("invalid data type %q for field %q of dataset %d", f.DataType, f.Name, i+1)
+			}
+			fields = append(fields, pubsub.FieldMetaData{Name: f.Name, BuiltInType: builtin})
+		}
+		o.decoder.MetaData.Add(ds.PublisherID, ds.WriterID, &pubsub.MetaData{Name: ds.Name, Fields: fields})
+	}
+
+	return nil
+}
+
+func (o *OpcUAUADP) Start(acc telegraf.Accumulator) error {
+	var conn *net.UDPConn
+	if o.addr.IP.IsMulticast() {
+		var iface *net.Interface
+		if o.Interface != "" {
+			var err error
+			if iface, err = net.InterfaceByName(o.Interface); err != nil {
+				return fmt.Errorf("resolving interface %q failed: %w", o.Interface, err)
+			}
+		}
+		c, err := net.ListenMulticastUDP(o.network, iface, o.addr)
+		if err != nil {
+			return err
+		}
+		conn = c
+	} else {
+		c, err := net.ListenUDP(o.network, o.addr)
+		if err != nil {
+			return err
+		}
+		conn = c
+	}
+	o.conn = conn
+
+	if o.ReadBufferSize > 0 {
+		if err := conn.SetReadBuffer(int(o.ReadBufferSize)); err != nil {
+			return err
+		}
+	}
+	o.Log.Infof("Listening on %s://%s", conn.LocalAddr().Network(), o.addr.String())
+
+	o.wg.Add(1)
+	go func() {
+		defer o.wg.Done()
+		o.read(acc)
+	}()
+
+	return nil
+}
+
+func (*OpcUAUADP) Gather(telegraf.Accumulator) error {
+	return nil
+}
+
+func (o *OpcUAUADP) Stop() {
+	if o.conn != nil {
+		_ = o.conn.Close()
+	}
+	o.wg.Wait()
+}
+
+func (o *OpcUAUADP) read(acc telegraf.Accumulator) {
+	buf := make([]byte, 64*1024) // 64kB
+	for {
+		count, src, err := o.conn.ReadFromUDP(buf)
+		if err != nil {
+			if !strings.HasSuffix(err.Error(), ": use of closed network connection") {
+				acc.AddError(err)
+			}
+			break
+		}
+		if count < 1 {
+			continue
+		}
+		if o.Log.Level().Includes(telegraf.Trace) {
+			o.Log.Tracef("raw data from %s: %s", src.String(), hex.EncodeToString(buf[:count]))
+		}
+		o.handle(acc, buf[:count], time.Now())
+	}
+}
+
+func (o *OpcUAUADP) handle(acc telegraf.Accumulator, buf []byte, received time.Time) {
+	datasets, err := o.decoder.Decode(buf)
+	if err != nil {
+		acc.AddError(fmt.Errorf("decoding message failed: %w", err))
+		return
+	}
+
+	for _, ds := range datasets {
+		if ds.Timestamp.IsZero() {
+			ds.Timestamp = received
+		}
+		acc.AddMetric(ds.Metric(o.MetricName))
+	}
+}
+
+func init() {
+	inputs.Add("opcua_uadp", func() telegraf.Input {
+		return &OpcUAUADP{
+			ServiceAddress: "opc.udp://224.0.2.14:4840",
+			MetricName:     "opcua_uadp",
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package opcua_uadp
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		address  string
+		iface    string
+		datasets []DataSet
+		expected string
+	}{
+		{
+			name:     "invalid scheme",
+			address:  "tcp://127.0.0.1:4840",
+			expected: "invalid scheme",
+		},
+		{
+			name:     "interface for unicast",
+			address:  "udp://127.0.0.1:4840",
+			iface:    "eth0",
+			expected: "interface can only be set for multicast addresses",
+		},
+		{
+			name:    "invalid data type",
+			address: "opc.udp://224.0.2.14:4840",
+			datasets: []DataSet{
+				{PublisherID: "1", WriterID: 1, Fields: []DataSetField{{Name: "value", DataType: "Decimal"}}},
+			},
+			expected: `invalid data type "Decimal" for field "value" of dataset 1`,
+		},
+		{
+			name:    "empty field name",
+			address: "opc.udp://224.0.2.14:4840",
+			datasets: []DataSet{
+				{PublisherID: "1", WriterID: 1, Fields: []DataSetField{{DataType: "Float"}}},
+			},
+			expected: "empty name for field 1 of dataset 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &OpcUAUADP{
+				ServiceAddress: tt.address,
+				Interface:      tt.iface,
+				MetricName:     "opcua_uadp",
+				DataSets:       tt.datasets,
+				Log:            testutil.Logger{},
+			}
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestConfig(t *testing.T) {
+	toml := `
+[[inputs.opcua_uadp]]
+  service_address = "opc.udp://224.0.2.14:4840"
+
+  [[inputs.opcua_uadp.dataset]]
+    publisher_id = "4711"
+    writer_id = 1
+    name = "Boiler"
+    fields = [
+      { name = "Temperature", data_type = "Float" },
+      { name = "Counter", data_type = "UInt32" },
+    ]
+`
+	c := config.NewConfig()
+	require.NoError(t, c.LoadConfigData([]byte(toml), config.EmptySourcePath))
+	require.Len(t, c.Inputs, 1)
+
+	plugin, ok := c.Inputs[0].Input.(*OpcUAUADP)
+	require.True(t, ok)
+	require.NoError(t, plugin.Init())
+
+	require.Equal(t, "opcua_uadp", plugin.MetricName)
+	require.Equal(t, []DataSet{
+		{
+			PublisherID: "4711",
+			WriterID:    1,
+			Name:        "Boiler",
+			Fields: []DataSetField{
+				{Name: "Temperature", DataType: "Float"},
+				{Name: "Counter", DataType: "UInt32"},
+			},
+		},
+	}, plugin.DataSets)
+
+	md, found := plugin.decoder.MetaData.Get("4711", 1)
+	require.True(t, found)
+	require.Equal(t, "Boiler", md.Name)
+	require.Len(t, md.Fields, 2)
+}
+
+func TestReceive(t *testing.T) {
+	plugin := &OpcUAUADP{
+		ServiceAddress: "udp://127.0.0.1:0",
+		MetricName:     "opcua_uadp",
+		DataSets: []DataSet{
+			{
+				PublisherID: "4711",
+				WriterID:    1,
+				Name:        "Boiler",
+				Fields: []DataSetField{
+					{Name: "Temperature", DataType: "Float"},
+					{Name: "Counter", DataType: "UInt32"},
+				},
+			},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	ts := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	buf := ua.NewBuffer(nil)
+	buf.WriteByte(0xd1)   // version 1, publisher ID, payload header, extended flags 1
+	buf.WriteByte(0x01)   // UInt16 publisher ID
+	buf.WriteUint16(4711) // publisher ID
+	buf.WriteByte(1)      // payload header with one writer
+	buf.WriteUint16(1)
+	buf.WriteByte(0x83) // valid, raw data encoding, flags 2
+	buf.WriteByte(0x10) // key frame with timestamp
+	buf.WriteTime(ts)
+	buf.WriteUint16(2)
+	buf.WriteFloat32(21.5)
+	buf.WriteUint32(42)
+
+	conn, err := net.Dial("udp", plugin.conn.LocalAddr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write(buf.Bytes())
+	require.NoError(t, err)
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"opcua_uadp",
+			map[string]string{
+				"publisher_id": "4711",
+				"writer_id":    "1",
+				"dataset":      "Boiler",
+			},
+			map[string]interface{}{
+				"Temperature": float32(21.5),
+				"Counter":     uint32(42),
+			},
+			ts,
+		),
+	}
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= uint64(len(expected))
+	}, 3*time.Second, 100*time.Millisecond)
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
@@ -0,0 +1,349 @@
+package profinet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const opnumReadImplicit = 5
+
+// Block types
+const (
+	blockReadRequest   = 0x0009
+	blockReadResponse  = 0x8009
+	blockDiagnosisData = 0x0010
+	blockIM0           = 0x0020
+	blockIM1           = 0x0021
+	blockIM2           = 0x0022
+	blockIM3           = 0x0023
+)
+
+// Record indices
+const (
+	indexIM0             = 0xAFF0
+	indexIM1             = 0xAFF1
+	indexIM2             = 0xAFF2
+	indexIM3             = 0xAFF3
+	indexDeviceDiagnosis = 0xF80C
+)
+
+// User structure identifiers of the diagnosis data
+const (
+	usiChannelDiagnosis          = 0x8000
+	usiExtChannelDiagnosis       = 0x8002
+	usiQualifiedChannelDiagnosis = 0x8003
+)
+
+const readHeaderLength = 64
+
+// Descriptions of the PNIORW error codes of negative read responses
+var readErrors = map[byte]string{
+	0xB0: "invalid index",
+	0xB1: "write length error",
+	0xB2: "invalid slot/subslot",
+	0xB3: "type conflict",
+	0xB4: "invalid area/API",
+	0xB5: "state conflict",
+	0xB6: "access denied",
+	0xB7: "invalid range",
+	0xB8: "invalid parameter",
+	0xB9: "invalid type",
+	0xC2: "resource busy",
+	0xC3: "resource unavailable",
+}
+
+// Channel error types defined by IEC 61158-6-10
+var channelErrorTypes = map[uint16]string{
+	0x0001: "short circuit",
+	0x0002: "undervoltage",
+	0x0003: "overvoltage",
+	0x0004: "overload",
+	0x0005: "overtemperature",
+	0x0006: "line break",
+	0x0007: "upper limit value exceeded",
+	0x0008: "lower limit value exceeded",
+	0x0009: "error",
+	0x000A: "simulation active",
+	0x000F: "parameter missing",
+	0x0010: "parameterization fault",
+	0x0011: "power supply fault",
+	0x0012: "fuse blown",
+	0x0013: "communication fault",
+	0x0014: "ground error",
+	0x0015: "reference point lost",
+	0x0016: "process event lost",
+	0x0017: "threshold warning",
+	0x0018: "output disabled",
+	0x0019: "functional safety event",
+	0x001A: "external fault",
+	0x001F: "temporary fault",
+	0x8000: "data transmission impossible",
+	0x8001: "remote mismatch",
+	0x8002: "media redundancy mismatch",
+	0x8003: "sync mismatch",
+	0x8004: "isochronous mode mismatch",
+	0x8005: "multicast CR mismatch",
+	0x8007: "fiber optic mismatch",
+	0x8008: "network component function mismatch",
+	0x8009: "time mismatch",
+}
+
+// Maintenance states of the channel properties
+var severities = []string{"fault", "maintenance-required", "maintenance-demanded", "qualified"}
+
+// Directions of the channel properties
+var directions = []string{"manufacturer-specific", "input", "output", "bidirectional"}
+
+// recordAddress addresses a record of a submodule
+type recordAddress struct {
+	api     uint32
+	slot    uint16
+	subslot uint16
+	index   uint16
+}
+
+type block struct {
+	typ     uint16
+	version uint16
+	body    []byte
+}
+
+type identification struct {
+	vendorID         uint16
+	orderID          string
+	serialNumber     string
+	hardwareRevision uint16
+	softwareRevision string
+	revisionCounter  uint16
+	profileID        uint16
+	profileType      uint16
+	version          string
+	supported        uint16
+}
+
+type diagnosis struct {
+	api           uint32
+	slot          uint16
+	subslot       uint16
+	channel       uint16
+	properties    uint16
+	usi           uint16
+	errorType     uint16
+	extErrorType  uint16
+	extAddValue   uint32
+	hasErrorType  bool
+	hasExtensions bool
+}
+
+// encodeReadImplicit encodes the NDR data of a read request without
+// application relation
+func encodeReadImplicit(seq uint16, addr recordAddress, length uint32) []byte {
+	buf := make([]byte, 20+readHeaderLength)
+	binary.LittleEndian.PutUint32(buf[0:], readHeaderLength+length)
+	binary.LittleEndian.PutUint32(buf[4:], readHeaderLength)
+	binary.LittleEndian.PutUint32(buf[8:], readHeaderLength+length)
+	binary.LittleEndian.PutUint32(buf[16:], readHeaderLength)
+
+	header := buf[20:]
+	binary.BigEndian.PutUint16(header[0:], blockReadRequest)
+	binary.BigEndian.PutUint16(header[2:], readHeaderLength-4)
+	header[4] = 1
+	binary.BigEndian.PutUint16(header[6:], seq)
+	binary.BigEndian.PutUint32(header[24:], addr.api)
+	binary.BigEndian.PutUint16(header[28:], addr.slot)
+	binary.BigEndian.PutUint16(header[30:], addr.subslot)
+	binary.BigEndian.PutUint16(header[34:], addr.index)
+	binary.BigEndian.PutUint32(header[36:], length)
+	return buf
+}
+
+// decodeReadResponse checks the status of the read response and returns the
+// record data
+func decodeReadResponse(buf []byte, addr recordAddress) ([]byte, error) {
+	if len(buf) < 20 {
+		return nil, errors.New("truncated response")
+	}
+	if status := buf[:4]; !bytes.Equal(status, []byte{0, 0, 0, 0}) {
+		if msg, found := readErrors[status[2]]; found && status[1] == 0x80 {
+			return nil, fmt.Errorf("read failed: %s (status 0x%x)", msg, status)
+		}
+		return nil, fmt.Errorf("read failed with status 0x%x", status)
+	}
+	data := buf[20:]
+	if len(data) < readHeaderLength {
+		return nil, errors.New("truncated read response header")
+	}
+	if typ := binary.BigEndian.Uint16(data); typ != blockReadResponse {
+		return nil, fmt.Errorf("unexpected block type 0x%04x", typ)
+	}
+	if binary.BigEndian.Uint32(data[24:]) != addr.api || binary.BigEndian.Uint16(data[28:]) != addr.slot ||
+		binary.BigEndian.Uint16(data[30:]) != addr.subslot || binary.BigEndian.Uint16(data[34:]) != addr.index {
+		return nil, errors.New("response does not match the requested record")
+	}
+	length := binary.BigEndian.Uint32(data[36:])
+	if uint32(len(data)-readHeaderLength) < length {
+		return nil, errors.New("truncated record data")
+	}
+	return data[readHeaderLength : readHeaderLength+int(length)], nil
+}
+
+// parseBlocks splits the record data into its blocks
+func parseBlocks(buf []byte) ([]block, error) {
+	var blocks []block
+	for len(buf) > 0 {
+		if len(buf) < 6 {
+			return nil, errors.New("truncated block header")
+		}
+		typ := binary.BigEndian.Uint16(buf)
+		length := int(binary.BigEndian.Uint16(buf[2:]))
+		if length < 2 || len(buf) < 4+length {
+			return nil, fmt.Errorf("invalid length of block 0x%04x", typ)
+		}
+		blocks = append(blocks, block{
+			typ:     typ,
+			version: binary.BigEndian.Uint16(buf[4:]),
+			body:    buf[6 : 4+length],
+		})
+		buf = buf[4+length:]
+	}
+	return blocks, nil
+}
+
+// findBlock returns the body of the first block of the given type
+func findBlock(buf []byte, typ uint16) ([]byte, error) {
+	blocks, err := parseBlocks(buf)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range blocks {
+		if b.typ == typ {
+			return b.body, nil
+		}
+	}
+	return nil, fmt.Errorf("block 0x%04x not found", typ)
+}
+
+func decodeIM0(buf []byte) (*identification, error) {
+	body, err := findBlock(buf, blockIM0)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < 54 {
+		return nil, errors.New("truncated I&M0 data")
+	}
+	return &identification{
+		vendorID:         binary.BigEndian.Uint16(body[0:]),
+		orderID:          visibleString(body[2:22]),
+		serialNumber:     visibleString(body[22:38]),
+		hardwareRevision: binary.BigEndian.Uint16(body[38:]),
+		softwareRevision: fmt.Sprintf("%c%d.%d.%d", body[40], body[41], body[42], body[43]),
+		revisionCounter:  binary.BigEndian.Uint16(body[44:]),
+		profileID:        binary.BigEndian.Uint16(body[46:]),
+		profileType:      binary.BigEndian.Uint16(body[48:]),
+		version:          fmt.Sprintf("%d.%d", body[50], body[51]),
+		supported:        binary.BigEndian.Uint16(body[52:]),
+	}, nil
+}
+
+// decodeIMStrings returns the visible strings of the given lengths of the
+// I&M1 to I&M3 records
+func decodeIMStrings(buf []byte, typ uint16, lengths ...int) ([]string, error) {
+	body, err := findBlock(buf, typ)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, 0, len(lengths))
+	for _, l := range lengths {
+		if len(body) < l {
+			return nil, fmt.Errorf("truncated I&M%d data", typ-blockIM0)
+		}
+		values = append(values, visibleString(body[:l]))
+		body = body[l:]
+	}
+	return values, nil
+}
+
+func decodeDiagnosis(buf []byte) ([]diagnosis, error) {
+	blocks, err := parseBlocks(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []diagnosis
+	for _, b := range blocks {
+		if b.typ != blockDiagnosisData {
+			continue
+		}
+		body := b.body
+		var api uint32
+		if b.version&0xFF >= 1 {
+			if len(body) < 4 {
+				return nil, errors.New("truncated diagnosis data")
+			}
+			api = binary.BigEndian.Uint32(body)
+			body = body[4:]
+		}
+		if len(body) < 10 {
+			return nil, errors.New("truncated diagnosis data")
+		}
+		header := diagnosis{
+			api:        api,
+			slot:       binary.BigEndian.Uint16(body[0:]),
+			subslot:    binary.BigEndian.Uint16(body[2:]),
+			channel:    binary.BigEndian.Uint16(body[4:]),
+			properties: binary.BigEndian.Uint16(body[6:]),
+			usi:        binary.BigEndian.Uint16(body[8:]),
+		}
+		body = body[10:]
+
+		var size int
+		switch header.usi {
+		case usiChannelDiagnosis:
+			size = 6
+		case usiExtChannelDiagnosis:
+			size = 12
+		case usiQualifiedChannelDiagnosis:
+			size = 16
+		default:
+			// Manufacturer specific diagnosis
+			entries = append(entries, header)
+			continue
+		}
+		for ; len(body) >= size; body = body[size:] {
+			d := header
+			d.channel = binary.BigEndian.Uint16(body[0:])
+			d.properties = binary.BigEndian.Uint16(body[2:])
+			d.errorType = binary.BigEndian.Uint16(body[4:])
+			d.hasErrorType = true
+			if size > 6 {
+				d.extErrorType = binary.BigEndian.Uint16(body[6:])
+				d.extAddValue = binary.BigEndian.Uint32(body[8:])
+				d.hasExtensions = true
+			}
+			entries = append(entries, d)
+		}
+	}
+	return entries, nil
+}
+
+func (d *diagnosis) severity() string {
+	return severities[(d.properties>>9)&0x03]
+}
+
+func (d *diagnosis) direction() string {
+	if dir := int(d.properties>>13) & 0x07; dir < len(directions) {
+		return directions[dir]
+	}
+	return "reserved"
+}
+
+func (d *diagnosis) accumulative() bool {
+	return d.properties&0x0100 != 0
+}
+
+func visibleString(buf []byte) string {
+	return strings.TrimRight(string(buf), " \x00")
+}
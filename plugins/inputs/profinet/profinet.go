@@ -0,0 +1,359 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package profinet
+
+import (
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Maximum record length fitting into an unfragmented response
+const maxRecordLength = 1300
+
+type Profinet struct {
+	Timeout config.Duration    `toml:"timeout"`
+	Retries int                `toml:"retries"`
+	Devices []deviceDefinition `toml:"device"`
+	Log     telegraf.Logger    `toml:"-"`
+
+	devices []*device
+}
+
+type deviceDefinition struct {
+	Address        string             `toml:"address"`
+	Name           string             `toml:"name"`
+	VendorID       uint16             `toml:"vendor_id"`
+	DeviceID       uint16             `toml:"device_id"`
+	Instance       uint16             `toml:"instance"`
+	Identification bool               `toml:"identification"`
+	Diagnosis      bool               `toml:"diagnosis"`
+	Records        []recordDefinition `toml:"records"`
+	Tags           map[string]string  `toml:"tags"`
+}
+
+type recordDefinition struct {
+	Name    string `toml:"name"`
+	API     uint32 `toml:"api"`
+	Slot    uint16 `toml:"slot"`
+	Subslot uint16 `toml:"subslot"`
+	Index   uint16 `toml:"index"`
+	Length  uint32 `toml:"length"`
+}
+
+type device struct {
+	addr           *net.UDPAddr
+	name           string
+	object         uuid.UUID
+	identification bool
+	diagnosis      bool
+	records        []recordDefinition
+	tags           map[string]string
+}
+
+// session performs the requests of one gather cycle using the same
+// activity
+type session struct {
+	conn     *net.UDPConn
+	addr     *net.UDPAddr
+	header   rpcHeader
+	timeout  time.Duration
+	retries  int
+	sequence uint16
+}
+
+func (*Profinet) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *Profinet) Init() error {
+	if len(p.Devices) == 0 {
+		return errors.New("no devices defined")
+	}
+	if p.Timeout <= 0 {
+		return errors.New("'timeout' must be positive")
+	}
+	if p.Retries < 0 {
+		return errors.New("'retries' must not be negative")
+	}
+
+	p.devices = make([]*device, 0, len(p.Devices))
+	for i, def := range p.Devices {
+		d, err := newDevice(def)
+		if err != nil {
+			return fmt.Errorf("device %d: %w", i+1, err)
+		}
+		p.devices = append(p.devices, d)
+	}
+	return nil
+}
+
+func newDevice(def deviceDefinition) (*device, error) {
+	if def.Address == "" {
+		return nil, errors.New("'address' must be specified")
+	}
+	address := def.Address
+	var nerr *net.AddrError
+	if _, _, err := net.SplitHostPort(address); errors.As(err, &nerr) && strings.Contains(nerr.Err, "missing port") {
+		address += ":34964"
+	}
+	addr, err := net.ResolveUDPAddr("udp4", address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w", def.Address, err)
+	}
+	if !def.Identification && !def.Diagnosis && len(def.Records) == 0 {
+		return nil, errors.New("no identification, diagnosis or records requested")
+	}
+
+	records := make([]recordDefinition, 0, len(def.Records))
+	for _, r := range def.Records {
+		if r.Name == "" {
+			return nil, fmt.Errorf("missing name for record %d", r.Index)
+		}
+		if r.Length == 0 {
+			r.Length = maxRecordLength
+		}
+		if r.Length > maxRecordLength {
+			return nil, fmt.Errorf("length of record %q exceeds %d bytes", r.Name, maxRecordLength)
+		}
+		records = append(records, r)
+	}
+
+	instance := def.Instance
+	if instance == 0 {
+		instance = 1
+	}
+	return &device{
+		addr:           addr,
+		name:           def.Name,
+		object:         objectUUID(instance, def.DeviceID, def.VendorID),
+		identification: def.Identification,
+		diagnosis:      def.Diagnosis,
+		records:        records,
+		tags:           def.Tags,
+	}, nil
+}
+
+func (p *Profinet) Gather(acc telegraf.Accumulator) error {
+	var wg sync.WaitGroup
+	for _, d := range p.devices {
+		wg.Add(1)
+		go func(d *device) {
+			defer wg.Done()
+			if err := p.gatherDevice(acc, d); err != nil {
+				acc.AddError(fmt.Errorf("device %s: %w", d.addr, err))
+			}
+		}(d)
+	}
+	wg.Wait()
+
+	return nil
+}
+
+func (p *Profinet) gatherDevice(acc telegraf.Accumulator, d *device) error {
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	s := &session{
+		conn: conn,
+		addr: d.addr,
+		header: rpcHeader{
+			ptype:    rpcRequest,
+			flags:    flagIdempotent,
+			object:   d.object,
+			iface:    interfaceDevice,
+			activity: uuid.New(),
+			opnum:    opnumReadImplicit,
+		},
+		timeout: time.Duration(p.Timeout),
+		retries: p.Retries,
+	}
+
+	if d.identification {
+		if err := p.gatherIdentification(acc, d, s); err != nil {
+			acc.AddError(fmt.Errorf("device %s: reading identification failed: %w", d.addr, err))
+		}
+	}
+
+	if d.diagnosis {
+		if err := p.gatherDiagnosis(acc, d, s); err != nil {
+			acc.AddError(fmt.Errorf("device %s: reading diagnosis failed: %w", d.addr, err))
+		}
+	}
+
+	for _, r := range d.records {
+		addr := recordAddress{api: r.API, slot: r.Slot, subslot: r.Subslot, index: r.Index}
+		data, err := s.read(addr, r.Length)
+		if err != nil {
+			acc.AddError(fmt.Errorf("device %s: reading record %q failed: %w", d.addr, r.Name, err))
+			continue
+		}
+		tags := d.newSubmoduleTags(r.API, r.Slot, r.Subslot)
+		tags["record"] = r.Name
+		tags["index"] = strconv.FormatUint(uint64(r.Index), 10)
+		fields := map[string]interface{}{
+			"length": len(data),
+			"data":   hex.EncodeToString(data),
+		}
+		acc.AddFields("profinet_record", fields, tags)
+	}
+
+	return nil
+}
+
+// gatherDiagnosis reads the diagnosis of all submodules of the device
+func (*Profinet) gatherDiagnosis(acc telegraf.Accumulator, d *device, s *session) error {
+	data, err := s.read(recordAddress{index: indexDeviceDiagnosis}, maxRecordLength)
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now()
+	entries, err := decodeDiagnosis(data)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		tags := d.newSubmoduleTags(e.api, e.slot, e.subslot)
+		tags["channel"] = strconv.FormatUint(uint64(e.channel), 10)
+		tags["severity"] = e.severity()
+		tags["direction"] = e.direction()
+		fields := map[string]interface{}{
+			"accumulative":              e.accumulative(),
+			"user_structure_identifier": uint64(e.usi),
+		}
+		if e.hasErrorType {
+			fields["error_type"] = uint64(e.errorType)
+			if text, found := channelErrorTypes[e.errorType]; found {
+				fields["error_text"] = text
+			}
+		}
+		if e.hasExtensions {
+			fields["ext_error_type"] = uint64(e.extErrorType)
+			fields["ext_add_value"] = uint64(e.extAddValue)
+		}
+		acc.AddFields("profinet_diagnosis", fields, tags, timestamp)
+	}
+	acc.AddFields("profinet_device", map[string]interface{}{"diagnosis_count": len(entries)}, d.newTags(), timestamp)
+
+	return nil
+}
+
+// gatherIdentification reads the I&M records of the device access point
+// supported by the device
+func (*Profinet) gatherIdentification(acc telegraf.Accumulator, d *device, s *session) error {
+	addr := recordAddress{slot: 0, subslot: 1, index: indexIM0}
+	data, err := s.read(addr, 128)
+	if err != nil {
+		return err
+	}
+	im, err := decodeIM0(data)
+	if err != nil {
+		return err
+	}
+
+	fields := map[string]interface{}{
+		"vendor_id":         uint64(im.vendorID),
+		"order_id":          im.orderID,
+		"serial_number":     im.serialNumber,
+		"hardware_revision": uint64(im.hardwareRevision),
+		"software_revision": im.softwareRevision,
+		"revision_counter":  uint64(im.revisionCounter),
+		"profile_id":        uint64(im.profileID),
+		"im_version":        im.version,
+	}
+
+	// Read the optional records announced as supported by I&M0
+	optional := []struct {
+		bit     uint16
+		index   uint16
+		typ     uint16
+		names   []string
+		lengths []int
+	}{
+		{0x0002, indexIM1, blockIM1, []string{"function_tag", "location_tag"}, []int{32, 22}},
+		{0x0004, indexIM2, blockIM2, []string{"installation_date"}, []int{16}},
+		{0x0008, indexIM3, blockIM3, []string{"descriptor"}, []int{54}},
+	}
+	for _, o := range optional {
+		if im.supported&o.bit == 0 {
+			continue
+		}
+		addr.index = o.index
+		data, err := s.read(addr, 128)
+		if err != nil {
+			acc.AddError(fmt.Errorf("device %s: reading I&M%d failed: %w", d.addr, o.typ-blockIM0, err))
+			continue
+		}
+		values, err := decodeIMStrings(data, o.typ, o.lengths...)
+		if err != nil {
+			acc.AddError(fmt.Errorf("device %s: decoding I&M%d failed: %w", d.addr, o.typ-blockIM0, err))
+			continue
+		}
+		for i, name := range o.names {
+			fields[name] = values[i]
+		}
+	}
+
+	acc.AddFields("profinet_identification", fields, d.newSubmoduleTags(0, addr.slot, addr.subslot))
+	return nil
+}
+
+func (d *device) newTags() map[string]string {
+	tags := make(map[string]string, len(d.tags)+8)
+	for k, v := range d.tags {
+		tags[k] = v
+	}
+	tags["address"] = d.addr.IP.String()
+	if d.name != "" {
+		tags["device"] = d.name
+	}
+	return tags
+}
+
+func (d *device) newSubmoduleTags(api uint32, slot, subslot uint16) map[string]string {
+	tags := d.newTags()
+	tags["api"] = strconv.FormatUint(uint64(api), 10)
+	tags["slot"] = strconv.FormatUint(uint64(slot), 10)
+	tags["subslot"] = strconv.FormatUint(uint64(subslot), 10)
+	return tags
+}
+
+// read reads the record with the given maximum length using an implicit
+// application relation
+func (s *session) read(addr recordAddress, length uint32) ([]byte, error) {
+	s.sequence++
+	s.header.sequence++
+	body := encodeReadImplicit(s.sequence, addr, length)
+	resp, err := call(s.conn, s.addr, &s.header, body, s.timeout, s.retries)
+	if err != nil {
+		return nil, err
+	}
+	return decodeReadResponse(resp, addr)
+}
+
+func init() {
+	inputs.Add("profinet", func() telegraf.Input {
+		return &Profinet{
+			Timeout: config.Duration(2 * time.Second),
+			Retries: 1,
+		}
+	})
+}
@@ -0,0 +1,348 @@
+package profinet
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		devices  []deviceDefinition
+		expected string
+	}{
+		{
+			name:     "no devices",
+			expected: "no devices defined",
+		},
+		{
+			name:     "no address",
+			devices:  []deviceDefinition{{Diagnosis: true}},
+			expected: "'address' must be specified",
+		},
+		{
+			name:     "nothing to read",
+			devices:  []deviceDefinition{{Address: "127.0.0.1"}},
+			expected: "no identification, diagnosis or records requested",
+		},
+		{
+			name:     "record without name",
+			devices:  []deviceDefinition{{Address: "127.0.0.1", Records: []recordDefinition{{Index: 1}}}},
+			expected: "missing name for record 1",
+		},
+		{
+			name:     "record too long",
+			devices:  []deviceDefinition{{Address: "127.0.0.1", Records: []recordDefinition{{Name: "foo", Length: 4096}}}},
+			expected: `length of record "foo" exceeds`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &Profinet{
+				Timeout: config.Duration(time.Second),
+				Devices: tt.devices,
+				Log:     testutil.Logger{},
+			}
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestUUIDEncoding(t *testing.T) {
+	buf := make([]byte, 16)
+	putUUID(buf, interfaceDevice)
+	require.Equal(t, []byte{0x01, 0x00, 0xA0, 0xDE, 0x97, 0x6C, 0xD1, 0x11, 0x82, 0x71, 0x00, 0xA0, 0x24, 0x42, 0xDF, 0x7D}, buf)
+	require.Equal(t, interfaceDevice, getUUID(buf))
+
+	require.Equal(t, "dea00000-6c97-11d1-8271-00010313002a", objectUUID(1, 0x0313, 0x002A).String())
+}
+
+func TestDecodeDiagnosis(t *testing.T) {
+	// Extended channel diagnosis with line break on an input channel and
+	// a manufacturer specific diagnosis of slot 2
+	data := diagnosisBlock(0, 1, 1, 0x8000, 0x0800, 0x8002,
+		0x00, 0x02, 0x28, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00)
+	data = append(data, diagnosisBlock(0, 2, 1, 0x8000, 0x0000, 0x1234, 0xCA, 0xFE)...)
+
+	entries, err := decodeDiagnosis(data)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	require.Equal(t, uint16(1), entries[0].slot)
+	require.Equal(t, uint16(2), entries[0].channel)
+	require.Equal(t, uint16(6), entries[0].errorType)
+	require.True(t, entries[0].hasExtensions)
+	require.Equal(t, "fault", entries[0].severity())
+	require.Equal(t, "input", entries[0].direction())
+
+	require.Equal(t, uint16(2), entries[1].slot)
+	require.Equal(t, uint16(0x1234), entries[1].usi)
+	require.False(t, entries[1].hasErrorType)
+}
+
+func TestGather(t *testing.T) {
+	dev := newFakeDevice(t)
+	dev.set(recordAddress{slot: 0, subslot: 1, index: indexIM0}, im0Block())
+	dev.set(recordAddress{slot: 0, subslot: 1, index: indexIM1}, encodeBlock(blockIM1, append(padded("conveyor", 32), padded("hall 2", 22)...)))
+	dev.set(recordAddress{index: indexDeviceDiagnosis}, diagnosisBlock(0, 1, 1, 0x8000, 0x0800, 0x8002,
+		0x00, 0x02, 0x28, 0x00, 0x00, 0x06, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00))
+	dev.set(recordAddress{slot: 1, subslot: 1, index: 0x1000}, []byte{0x01, 0x02, 0x03})
+
+	plugin := &Profinet{
+		Timeout: config.Duration(time.Second),
+		Devices: []deviceDefinition{
+			{
+				Address:        dev.addr(),
+				Name:           "et200sp-1",
+				VendorID:       0x002A,
+				DeviceID:       0x0313,
+				Identification: true,
+				Diagnosis:      true,
+				Records: []recordDefinition{
+					{Name: "io_status", Slot: 1, Subslot: 1, Index: 0x1000, Length: 16},
+					{Name: "missing", Slot: 9, Subslot: 1, Index: 0x1000},
+				},
+				Tags: map[string]string{"line": "assembly"},
+			},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.ErrorContains(t, acc.Errors[0], `reading record "missing" failed: read failed: invalid slot/subslot`)
+	require.Equal(t, objectUUID(1, 0x0313, 0x002A), dev.lastObject())
+
+	tags := func(slot, subslot string, extra ...string) map[string]string {
+		t := map[string]string{
+			"address": "127.0.0.1",
+			"device":  "et200sp-1",
+			"line":    "assembly",
+			"api":     "0",
+			"slot":    slot,
+			"subslot": subslot,
+		}
+		for i := 0; i < len(extra); i += 2 {
+			t[extra[i]] = extra[i+1]
+		}
+		return t
+	}
+	expected := []telegraf.Metric{
+		metric.New(
+			"profinet_identification",
+			tags("0", "1"),
+			map[string]interface{}{
+				"vendor_id":         uint64(42),
+				"order_id":          "6ES7 155-6AU01-0BN0",
+				"serial_number":     "SC-X4U421302017",
+				"hardware_revision": uint64(3),
+				"software_revision": "V4.2.0",
+				"revision_counter":  uint64(0),
+				"profile_id":        uint64(0),
+				"im_version":        "1.1",
+				"function_tag":      "conveyor",
+				"location_tag":      "hall 2",
+			},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"profinet_diagnosis",
+			tags("1", "1", "channel", "2", "severity", "fault", "direction", "input"),
+			map[string]interface{}{
+				"accumulative":              false,
+				"user_structure_identifier": uint64(0x8002),
+				"error_type":                uint64(6),
+				"error_text":                "line break",
+				"ext_error_type":            uint64(0),
+				"ext_add_value":             uint64(0),
+			},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"profinet_device",
+			map[string]string{"address": "127.0.0.1", "device": "et200sp-1", "line": "assembly"},
+			map[string]interface{}{"diagnosis_count": 1},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"profinet_record",
+			tags("1", "1", "record", "io_status", "index", "4096"),
+			map[string]interface{}{"length": 3, "data": "010203"},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
+
+func TestTimeout(t *testing.T) {
+	dev := newFakeDevice(t)
+	dev.Lock()
+	dev.silent = true
+	dev.Unlock()
+
+	plugin := &Profinet{
+		Timeout: config.Duration(100 * time.Millisecond),
+		Retries: 1,
+		Devices: []deviceDefinition{{Address: dev.addr(), Diagnosis: true}},
+		Log:     testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Gather(&acc))
+	require.Len(t, acc.Errors, 1)
+	require.ErrorContains(t, acc.Errors[0], "timed out")
+	require.Equal(t, 2, dev.requests())
+}
+
+func diagnosisBlock(api uint32, slot, subslot, channel, properties, usi uint16, data ...byte) []byte {
+	body := make([]byte, 14, 14+len(data))
+	binary.BigEndian.PutUint32(body[0:], api)
+	binary.BigEndian.PutUint16(body[4:], slot)
+	binary.BigEndian.PutUint16(body[6:], subslot)
+	binary.BigEndian.PutUint16(body[8:], channel)
+	binary.BigEndian.PutUint16(body[10:], properties)
+	binary.BigEndian.PutUint16(body[12:], usi)
+	return encodeBlock(blockDiagnosisData, append(body, data...), 0x01)
+}
+
+func im0Block() []byte {
+	body := make([]byte, 0, 54)
+	body = append(body, 0x00, 0x2A)
+	body = append(body, padded("6ES7 155-6AU01-0BN0", 20)...)
+	body = append(body, padded("SC-X4U421302017", 16)...)
+	body = append(body, 0x00, 0x03, 'V', 4, 2, 0, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x01, 0x00, 0x02)
+	return encodeBlock(blockIM0, body)
+}
+
+// encodeBlock encodes a block with version 1.x
+func encodeBlock(typ uint16, body []byte, minor ...byte) []byte {
+	buf := make([]byte, 6, 6+len(body))
+	binary.BigEndian.PutUint16(buf, typ)
+	binary.BigEndian.PutUint16(buf[2:], uint16(len(body)+2))
+	buf[4] = 1
+	if len(minor) > 0 {
+		buf[5] = minor[0]
+	}
+	return append(buf, body...)
+}
+
+func padded(s string, length int) []byte {
+	buf := []byte(s)
+	for len(buf) < length {
+		buf = append(buf, ' ')
+	}
+	return buf
+}
+
+// fakeDevice answers read implicit requests for the configured records
+type fakeDevice struct {
+	conn    *net.UDPConn
+	records map[recordAddress][]byte
+	silent  bool
+
+	object uuid.UUID
+	count  int
+	sync.Mutex
+}
+
+func newFakeDevice(t *testing.T) *fakeDevice {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+
+	d := &fakeDevice{conn: conn, records: make(map[recordAddress][]byte)}
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if resp := d.handle(buf[:n]); resp != nil {
+				conn.WriteToUDP(resp, addr) //nolint:errcheck // failures are detected by the missing responses
+			}
+		}
+	}()
+	t.Cleanup(func() { conn.Close() })
+	return d
+}
+
+func (d *fakeDevice) addr() string {
+	return d.conn.LocalAddr().String()
+}
+
+func (d *fakeDevice) set(addr recordAddress, data []byte) {
+	d.Lock()
+	defer d.Unlock()
+	d.records[addr] = data
+}
+
+func (d *fakeDevice) lastObject() uuid.UUID {
+	d.Lock()
+	defer d.Unlock()
+	return d.object
+}
+
+func (d *fakeDevice) requests() int {
+	d.Lock()
+	defer d.Unlock()
+	return d.count
+}
+
+func (d *fakeDevice) handle(packet []byte) []byte {
+	h, body, err := decodeRPCHeader(packet)
+	if err != nil || h.opnum != opnumReadImplicit || h.iface != interfaceDevice || len(body) < 20+readHeaderLength {
+		return nil
+	}
+
+	d.Lock()
+	defer d.Unlock()
+	d.object = h.object
+	d.count++
+	if d.silent {
+		return nil
+	}
+
+	req := body[20:]
+	addr := recordAddress{
+		api:     binary.BigEndian.Uint32(req[24:]),
+		slot:    binary.BigEndian.Uint16(req[28:]),
+		subslot: binary.BigEndian.Uint16(req[30:]),
+		index:   binary.BigEndian.Uint16(req[34:]),
+	}
+
+	resp := make([]byte, 20+readHeaderLength)
+	data, found := d.records[addr]
+	if found {
+		data = data[:min(len(data), int(binary.BigEndian.Uint32(req[36:])))]
+		header := resp[20:]
+		binary.BigEndian.PutUint16(header[0:], blockReadResponse)
+		binary.BigEndian.PutUint16(header[2:], readHeaderLength-4)
+		header[4] = 1
+		copy(header[6:], req[6:36])
+		binary.BigEndian.PutUint32(header[36:], uint32(len(data)))
+		resp = append(resp, data...)
+	} else {
+		copy(resp, []byte{0xDE, 0x80, 0xB2, 0x00})
+		resp = resp[:20]
+	}
+	binary.LittleEndian.PutUint32(resp[4:], uint32(len(resp)-20))
+
+	h.ptype = rpcResponse
+	h.flags = 0
+	return h.encode(resp)
+}
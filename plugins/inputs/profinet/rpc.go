@@ -0,0 +1,164 @@
+package profinet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DCE/RPC connectionless packet types
+const (
+	rpcRequest  = 0
+	rpcResponse = 2
+	rpcFault    = 3
+	rpcWorking  = 4
+	rpcReject   = 6
+)
+
+// DCE/RPC connectionless flags
+const (
+	flagFragment   = 0x04
+	flagIdempotent = 0x20
+)
+
+const rpcHeaderLength = 80
+
+// PNIO-CM device interface DEA00001-6C97-11D1-8271-00A02442DF7D
+var interfaceDevice = uuid.UUID{0xDE, 0xA0, 0x00, 0x01, 0x6C, 0x97, 0x11, 0xD1, 0x82, 0x71, 0x00, 0xA0, 0x24, 0x42, 0xDF, 0x7D}
+
+// rpcHeader is the header of a connectionless DCE/RPC packet
+type rpcHeader struct {
+	ptype    byte
+	flags    byte
+	object   uuid.UUID
+	iface    uuid.UUID
+	activity uuid.UUID
+	sequence uint32
+	opnum    uint16
+	length   uint16
+	fragment uint16
+}
+
+// objectUUID returns the PNIO object UUID identifying the device
+func objectUUID(instance, deviceID, vendorID uint16) uuid.UUID {
+	id := uuid.UUID{0xDE, 0xA0, 0x00, 0x00, 0x6C, 0x97, 0x11, 0xD1, 0x82, 0x71}
+	binary.BigEndian.PutUint16(id[10:], instance)
+	binary.BigEndian.PutUint16(id[12:], deviceID)
+	binary.BigEndian.PutUint16(id[14:], vendorID)
+	return id
+}
+
+// putUUID encodes the UUID with the first three fields in little-endian
+// order as used with little-endian data representation
+func putUUID(buf []byte, id uuid.UUID) {
+	copy(buf, id[:])
+	buf[0], buf[1], buf[2], buf[3] = id[3], id[2], id[1], id[0]
+	buf[4], buf[5] = id[5], id[4]
+	buf[6], buf[7] = id[7], id[6]
+}
+
+func getUUID(buf []byte) uuid.UUID {
+	var id uuid.UUID
+	putUUID(id[:], uuid.UUID(buf[:16]))
+	return id
+}
+
+func (h *rpcHeader) encode(body []byte) []byte {
+	buf := make([]byte, rpcHeaderLength, rpcHeaderLength+len(body))
+	buf[0] = 4
+	buf[1] = h.ptype
+	buf[2] = h.flags
+	buf[4] = 0x10 // little-endian integers, ASCII characters, IEEE floats
+	putUUID(buf[8:], h.object)
+	putUUID(buf[24:], h.iface)
+	putUUID(buf[40:], h.activity)
+	binary.LittleEndian.PutUint32(buf[60:], 1) // interface version 1.0
+	binary.LittleEndian.PutUint32(buf[64:], h.sequence)
+	binary.LittleEndian.PutUint16(buf[68:], h.opnum)
+	binary.LittleEndian.PutUint16(buf[70:], 0xFFFF)
+	binary.LittleEndian.PutUint16(buf[72:], 0xFFFF)
+	binary.LittleEndian.PutUint16(buf[74:], uint16(len(body)))
+	binary.LittleEndian.PutUint16(buf[76:], h.fragment)
+	return append(buf, body...)
+}
+
+func decodeRPCHeader(buf []byte) (*rpcHeader, []byte, error) {
+	if len(buf) < rpcHeaderLength {
+		return nil, nil, errors.New("truncated RPC header")
+	}
+	if buf[0] != 4 {
+		return nil, nil, fmt.Errorf("unsupported RPC version %d", buf[0])
+	}
+	if buf[4]&0xF0 != 0x10 {
+		return nil, nil, errors.New("big-endian data representation is not supported")
+	}
+	h := &rpcHeader{
+		ptype:    buf[1],
+		flags:    buf[2],
+		object:   getUUID(buf[8:]),
+		iface:    getUUID(buf[24:]),
+		activity: getUUID(buf[40:]),
+		sequence: binary.LittleEndian.Uint32(buf[64:]),
+		opnum:    binary.LittleEndian.Uint16(buf[68:]),
+		length:   binary.LittleEndian.Uint16(buf[74:]),
+		fragment: binary.LittleEndian.Uint16(buf[76:]),
+	}
+	if len(buf) < rpcHeaderLength+int(h.length) {
+		return nil, nil, errors.New("truncated RPC body")
+	}
+	return h, buf[rpcHeaderLength : rpcHeaderLength+int(h.length)], nil
+}
+
+// call sends the request to the device and waits for the response with the
+// same activity and sequence number. Working packets of the device extend
+// the waiting time.
+func call(conn *net.UDPConn, addr *net.UDPAddr, h *rpcHeader, body []byte, timeout time.Duration, retries int) ([]byte, error) {
+	request := h.encode(body)
+	buf := make([]byte, 2048)
+	for range retries + 1 {
+		if _, err := conn.WriteToUDP(request, addr); err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(timeout)
+		for {
+			if err := conn.SetReadDeadline(deadline); err != nil {
+				return nil, err
+			}
+			n, from, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				var nerr net.Error
+				if errors.As(err, &nerr) && nerr.Timeout() {
+					break
+				}
+				return nil, err
+			}
+			if !from.IP.Equal(addr.IP) {
+				continue
+			}
+			resp, payload, err := decodeRPCHeader(buf[:n])
+			if err != nil || resp.activity != h.activity || resp.sequence != h.sequence {
+				continue
+			}
+			switch resp.ptype {
+			case rpcResponse:
+				if resp.flags&flagFragment != 0 {
+					return nil, errors.New("fragmented responses are not supported")
+				}
+				return append([]byte(nil), payload...), nil
+			case rpcWorking:
+				deadline = time.Now().Add(timeout)
+			case rpcFault, rpcReject:
+				var status uint32
+				if len(payload) >= 4 {
+					status = binary.LittleEndian.Uint32(payload)
+				}
+				return nil, fmt.Errorf("request rejected with status 0x%08x", status)
+			}
+		}
+	}
+	return nil, fmt.Errorf("request to %s timed out", addr)
+}
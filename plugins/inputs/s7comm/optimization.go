@@ -0,0 +1,105 @@
+package s7comm
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/robinson/gos7"
+)
+
+const (
+	// Data available for items in a response of the minimum PDU size of 240
+	// bytes supported by all CPUs
+	maxResponseData = 240 - 14
+	// Maximum size of a merged item including the item header
+	maxMergedItemSize = maxResponseData - 4
+	// Maximum number of bytes between two items to still merge them. Reading
+	// those bytes is cheaper than the overhead of an additional item.
+	maxMergeGap = 16
+)
+
+// readItem is an item to read along with the fields contained in the data
+type readItem struct {
+	item     gos7.S7DataItem
+	mappings []fieldMapping
+}
+
+// itemSize returns the number of bytes read for the given item
+func itemSize(item *gos7.S7DataItem) int {
+	switch item.WordLen {
+	case 0x01: // bit
+		return 1
+	case 0x04, 0x05: // word, int
+		return 2 * item.Amount
+	case 0x06, 0x07, 0x08: // dword, dint, real
+		return 4 * item.Amount
+	case 0x0F: // date and time
+		return 8 * item.Amount
+	}
+	return item.Amount
+}
+
+// mergeItems packs items in the same area being close to each other into
+// one byte-array item containing all fields of the original items
+func mergeItems(items []readItem) []readItem {
+	// Counters and timers are not byte-addressed so keep them as they are
+	var mergeable, result []readItem
+	for _, ri := range items {
+		if ri.item.Area == areaMap["C"] || ri.item.Area == areaMap["T"] {
+			result = append(result, ri)
+		} else {
+			mergeable = append(mergeable, ri)
+		}
+	}
+	slices.SortStableFunc(mergeable, func(a, b readItem) int {
+		return cmp.Or(
+			cmp.Compare(a.item.Area, b.item.Area),
+			cmp.Compare(a.item.DBNumber, b.item.DBNumber),
+			cmp.Compare(a.item.Start, b.item.Start),
+		)
+	})
+
+	var merged []readItem
+	var current *readItem
+	for _, ri := range mergeable {
+		size := itemSize(&ri.item)
+		end := ri.item.Start + size
+
+		// Start a new item if the item is not in the same block, too far
+		// away or exceeds the maximum item size
+		if current == nil ||
+			current.item.Area != ri.item.Area ||
+			current.item.DBNumber != ri.item.DBNumber ||
+			ri.item.Start > current.item.Start+current.item.Amount+maxMergeGap ||
+			max(end, current.item.Start+current.item.Amount)-current.item.Start > maxMergedItemSize {
+			merged = append(merged, readItem{
+				item: gos7.S7DataItem{
+					Area:     ri.item.Area,
+					WordLen:  wordLenMap["B"],
+					DBNumber: ri.item.DBNumber,
+					Start:    ri.item.Start,
+				},
+			})
+			current = &merged[len(merged)-1]
+		}
+		current.item.Amount = max(current.item.Amount, end-current.item.Start)
+
+		// Locate the fields in the merged data
+		for _, m := range ri.mappings {
+			m.offset = ri.item.Start - current.item.Start
+			m.length = size
+			if ri.item.WordLen == wordLenMap["X"] {
+				bit := ri.item.Bit
+				m.convert = func(buf []byte) interface{} {
+					return buf[0]&(1<<bit) != 0
+				}
+			}
+			current.mappings = append(current.mappings, m)
+		}
+	}
+	for i := range merged {
+		merged[i].item.Data = make([]byte, merged[i].item.Amount)
+	}
+
+	return append(merged, result...)
+}
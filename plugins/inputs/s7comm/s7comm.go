@@ -66,6 +66,8 @@ type S7comm struct {
 	Slot            int                `toml:"slot"`
 	ConnectionType  string             `toml:"connection_type"`
 	BatchMaxSize    int                `toml:"pdu_size"`
+	Optimization    string             `toml:"optimization"`
+	SymbolFiles     []string           `toml:"symbol_files"`
 	Timeout         config.Duration    `toml:"timeout"`
 	DebugConnection bool               `toml:"debug_connection" deprecated:"1.35.0;use 'log_level' 'trace' instead"`
 	Configs         []metricDefinition `toml:"metric"`
@@ -85,6 +87,7 @@ type metricDefinition struct {
 type metricFieldDefinition struct {
 	Name    string `toml:"name"`
 	Address string `toml:"address"`
+	Symbol  string `toml:"symbol"`
 }
 
 type batch struct {
@@ -97,6 +100,9 @@ type fieldMapping struct {
 	field       string
 	tags        map[string]string
 	convert     converterFunc
+	item        int
+	offset      int
+	length      int
 }
 
 type converterFunc func([]byte) interface{}
@@ -125,6 +131,13 @@ func (s *S7comm) Init() error {
 	if len(s.Configs) == 0 {
 		return errors.New("no metric defined")
 	}
+	switch s.Optimization {
+	case "":
+		s.Optimization = "none"
+	case "none", "merge":
+	default:
+		return fmt.Errorf("invalid 'optimization' %q", s.Optimization)
+	}
 
 	// Set default port to 102 if none is given
 	var nerr *net.AddrError
@@ -175,11 +188,12 @@ func (s *S7comm) Gather(acc telegraf.Accumulator) error {
 		}
 
 		// Dissect the received data into fields
-		for j, m := range b.mappings {
+		for _, m := range b.mappings {
 			// Convert the data
-			buf := b.items[j].Data
+			item := b.items[m.item]
+			buf := item.Data[m.offset : m.offset+m.length]
 			value := m.convert(buf)
-			s.Log.Debugf("  got %v for field %q @ %d --> %v (%T)", buf, m.field, b.items[j].Start, value, value)
+			s.Log.Debugf("  got %v for field %q @ %d --> %v (%T)", buf, m.field, item.Start+m.offset, value, value)
 
 			// Group the data by series
 			grouper.Add(m.measurement, m.tags, timestamp, m.field, value)
@@ -202,11 +216,15 @@ func (s *S7comm) Stop() {
 }
 
 func (s *S7comm) createRequests() error {
+	symbols, err := loadSymbols(s.SymbolFiles)
+	if err != nil {
+		return err
+	}
+
 	seed := maphash.MakeSeed()
 	seenFields := make(map[uint64]bool)
-	s.batches = make([]batch, 0)
 
-	current := batch{}
+	var items []readItem
 	for i, cfg := range s.Configs {
 		// Set the defaults
 		if cfg.Name == "" {
@@ -218,13 +236,33 @@ func (s *S7comm) createRequests() error {
 			return fmt.Errorf("no fields defined for metric %q", cfg.Name)
 		}
 
-		// Create requests for all fields  and add it to the current slot
-		for _, f := range cfg.Fields {
+		// Create requests for all fields
+		for j := range cfg.Fields {
+			f := &cfg.Fields[j]
+
+			// Resolve symbolic addresses
+			address := f.Address
+			if f.Symbol != "" {
+				if f.Address != "" {
+					return fmt.Errorf("field %q of metric %q: 'address' and 'symbol' are mutually exclusive", f.Name, cfg.Name)
+				}
+				sym, found := symbols[f.Symbol]
+				if !found {
+					return fmt.Errorf("unknown symbol %q in metric %q", f.Symbol, cfg.Name)
+				}
+				if sym.err != nil {
+					return fmt.Errorf("symbol %q in metric %q: %w", f.Symbol, cfg.Name, sym.err)
+				}
+				address = sym.address
+				if f.Name == "" {
+					f.Name = f.Symbol
+				}
+			}
 			if f.Name == "" {
 				return fmt.Errorf("unnamed field in metric %q", cfg.Name)
 			}
 
-			item, cfunc, err := handleFieldAddress(f.Address)
+			item, cfunc, err := handleFieldAddress(address)
 			if err != nil {
 				return fmt.Errorf("field %q of metric %q: %w", f.Name, cfg.Name, err)
 			}
@@ -233,18 +271,12 @@ func (s *S7comm) createRequests() error {
 				field:       f.Name,
 				tags:        s.Configs[i].Tags,
 				convert:     cfunc,
+				length:      len(item.Data),
 			}
-			current.items = append(current.items, *item)
-			current.mappings = append(current.mappings, m)
-
-			// If the batch is full, start a new one
-			if len(current.items) == s.BatchMaxSize {
-				s.batches = append(s.batches, current)
-				current = batch{}
-			}
+			items = append(items, readItem{item: *item, mappings: []fieldMapping{m}})
 
 			// Check for duplicate field definitions
-			id := fieldID(seed, cfg, f)
+			id := fieldID(seed, cfg, *f)
 			if seenFields[id] {
 				return fmt.Errorf("duplicate field definition field %q in metric %q", f.Name, cfg.Name)
 			}
@@ -255,6 +287,36 @@ func (s *S7comm) createRequests() error {
 		s.Configs[i] = cfg
 	}
 
+	// Pack the items as requested
+	limitData := false
+	if s.Optimization == "merge" {
+		items = mergeItems(items)
+		limitData = true
+	}
+
+	// Split the items into batches
+	s.batches = make([]batch, 0)
+	current := batch{}
+	var size int
+	for _, ri := range items {
+		// Responses contain a header and the data padded to even length
+		itemData := 4 + itemSize(&ri.item) + itemSize(&ri.item)%2
+
+		// If the batch is full, start a new one
+		if len(current.items) > 0 && (len(current.items) == s.BatchMaxSize || limitData && size+itemData > maxResponseData) {
+			s.batches = append(s.batches, current)
+			current = batch{}
+			size = 0
+		}
+
+		for _, m := range ri.mappings {
+			m.item = len(current.items)
+			current.mappings = append(current.mappings, m)
+		}
+		current.items = append(current.items, ri.item)
+		size += itemData
+	}
+
 	// Add the last batch if any
 	if len(current.items) > 0 {
 		s.batches = append(s.batches, current)
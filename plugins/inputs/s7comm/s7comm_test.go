@@ -3,6 +3,7 @@ package s7comm
 import (
 	_ "embed"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"net"
 	"sync/atomic"
@@ -747,6 +748,64 @@ func TestConnectionLoss(t *testing.T) {
 	require.Equal(t, uint32(3), server.connectionAttempts.Load())
 }
 
+func TestGatherSimulator(t *testing.T) {
+	sim := testutil.NewS7Server()
+	require.NoError(t, sim.Start())
+	defer sim.Close()
+	sim.SetDB(1, 0, []byte{0x00, 0x2A, 0x42, 0x28, 0x00, 0x00, 0x05})
+	sim.SetDB(2, 0, []byte{0xFF, 0xFE, 0x05, 0x03, 'f', 'o', 'o'})
+
+	plugin := &S7comm{
+		Server:  sim.Addr(),
+		Rack:    0,
+		Slot:    2,
+		Timeout: config.Duration(100 * time.Millisecond),
+		Configs: []metricDefinition{
+			{
+				Name: "plc",
+				Fields: []metricFieldDefinition{
+					{Name: "counter", Address: "DB1.W0"},
+					{Name: "temperature", Address: "DB1.R2"},
+					{Name: "running", Address: "DB1.X6.2"},
+					{Name: "offset", Address: "DB2.I0"},
+					{Name: "label", Address: "DB2.S2.5"},
+				},
+			},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	require.NoError(t, plugin.Gather(&acc))
+
+	expected := testutil.MetricMatcher{
+		Name: "plc",
+		Fields: map[string]interface{}{
+			"counter":     uint64(42),
+			"temperature": 42.0,
+			"running":     true,
+			"offset":      int64(-2),
+			"label":       "foo",
+		},
+	}
+	testutil.RequireMetricsMatch(t, []testutil.MetricMatcher{expected}, acc.GetTelegrafMetrics())
+
+	// Losing the connection skips the gather cycle and reconnects
+	acc.ClearMetrics()
+	sim.InjectFault(testutil.FaultDisconnect, 1)
+	require.NoError(t, plugin.Gather(&acc))
+	require.Empty(t, acc.GetTelegrafMetrics())
+
+	// Changed values are picked up after the reconnect
+	sim.SetDB(1, 0, []byte{0x00, 0x2B})
+	require.NoError(t, plugin.Gather(&acc))
+	expected.Fields["counter"] = uint64(43)
+	testutil.RequireMetricsMatch(t, []testutil.MetricMatcher{expected}, acc.GetTelegrafMetrics())
+}
+
 func TestStartupErrorBehaviorError(t *testing.T) {
 	// Create fake S7 comm server that can accept connects
 	server, err := newMockServer()
@@ -961,3 +1020,264 @@ func (s *mockServer) start() {
 		}
 	}()
 }
+
+func TestSymbolAddressConversion(t *testing.T) {
+	tests := []struct {
+		address  string
+		datatype string
+		expected string
+		errmsg   string
+	}{
+		{address: "%DB1.DBX2.1", datatype: "Bool", expected: "DB1.X2.1"},
+		{address: "%DB1.DBB2", datatype: "Byte", expected: "DB1.B2"},
+		{address: "%DB1.DBB3", datatype: "Char", expected: "DB1.C3"},
+		{address: "%DB10.DBW4", datatype: "UInt", expected: "DB10.W4"},
+		{address: "%DB10.DBW4", datatype: "Int", expected: "DB10.I4"},
+		{address: "%DB1.DBD8", datatype: "DWord", expected: "DB1.DW8"},
+		{address: "%DB1.DBD8", datatype: "DInt", expected: "DB1.DI8"},
+		{address: "%DB1.DBD8", datatype: "Real", expected: "DB1.R8"},
+		{address: "%DB2.DBB10", datatype: "Date_And_Time", expected: "DB2.DT10"},
+		{address: "%DB2.DBX20.0", datatype: "String[32]", expected: "DB2.S20.34"},
+		{address: "%DB2.DBB20", datatype: "String", expected: "DB2.S20.256"},
+		{address: "%I0.1", datatype: "Bool", expected: "PE0.X0.1"},
+		{address: "%E0.1", datatype: "Bool", expected: "PE0.X0.1"},
+		{address: "%QW4", datatype: "Word", expected: "PA0.W4"},
+		{address: "%AB4", datatype: "Byte", expected: "PA0.B4"},
+		{address: "%MD100", datatype: "Real", expected: "MK0.R100"},
+		{address: "MW     10", datatype: "INT       ", expected: "MK0.I10"},
+		{address: "%MW10", datatype: "Real", errmsg: "does not match data type"},
+		{address: "%M0.8", datatype: "Bool", errmsg: "out of range"},
+		{address: "%MB1.1", datatype: "Byte", errmsg: "invalid bit address"},
+		{address: "%M1", datatype: "Bool", errmsg: "invalid bit address"},
+		{address: "%DB2.DBX20.1", datatype: "String[32]", errmsg: "requires a byte address"},
+		{address: "%MD100", datatype: "LReal", errmsg: "unsupported data type"},
+		{address: "DB 2", datatype: "DB 2", errmsg: "unsupported address"},
+		{address: "", datatype: "Real", errmsg: "unsupported address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address+"_"+tt.datatype, func(t *testing.T) {
+			actual, err := convertSymbolAddress(tt.address, tt.datatype)
+			if tt.errmsg != "" {
+				require.ErrorContains(t, err, tt.errmsg)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, actual)
+
+			// The converted address must be valid
+			_, _, err = handleFieldAddress(actual)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestSymbolFiles(t *testing.T) {
+	symbols, err := loadSymbols([]string{"testdata/tia.csv", "testdata/step7.sdf"})
+	require.NoError(t, err)
+
+	expected := map[string]string{
+		"Motor_Speed":    "DB1.R2",
+		"Motor_Running":  "DB1.X6.2",
+		"Counter":        "DB1.W0",
+		"Emergency_Stop": "PE0.X0.1",
+		"Output_Word":    "PA0.W4",
+		"Offset":         "MK0.I10",
+	}
+	for name, address := range expected {
+		require.Contains(t, symbols, name)
+		require.NoError(t, symbols[name].err, name)
+		require.Equal(t, address, symbols[name].address, name)
+	}
+	require.ErrorContains(t, symbols["Timestamp"].err, "unsupported data type")
+	require.ErrorContains(t, symbols["Recipes"].err, "unsupported address")
+
+	// Loading the same symbols twice must fail
+	_, err = loadSymbols([]string{"testdata/tia.csv", "testdata/tia.csv"})
+	require.ErrorContains(t, err, "duplicate symbol")
+}
+
+func TestSymbolInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		field    metricFieldDefinition
+		expected string
+	}{
+		{
+			name:     "unknown symbol",
+			field:    metricFieldDefinition{Symbol: "Motor_Torque"},
+			expected: `unknown symbol "Motor_Torque"`,
+		},
+		{
+			name:     "unsupported symbol",
+			field:    metricFieldDefinition{Symbol: "Timestamp"},
+			expected: `symbol "Timestamp" in metric "test": unsupported data type`,
+		},
+		{
+			name:     "symbol and address",
+			field:    metricFieldDefinition{Name: "speed", Symbol: "Motor_Speed", Address: "DB1.R2"},
+			expected: "mutually exclusive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &S7comm{
+				Server:       "127.0.0.1:102",
+				Rack:         0,
+				Slot:         0,
+				BatchMaxSize: 20,
+				SymbolFiles:  []string{"testdata/tia.csv"},
+				Configs: []metricDefinition{
+					{
+						Name:   "test",
+						Fields: []metricFieldDefinition{tt.field},
+					},
+				},
+				Log: &testutil.Logger{},
+			}
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestMergeItems(t *testing.T) {
+	plugin := &S7comm{
+		Server:       "127.0.0.1:102",
+		Rack:         0,
+		Slot:         0,
+		BatchMaxSize: 20,
+		Optimization: "merge",
+		Configs: []metricDefinition{
+			{
+				Name: "test",
+				Fields: []metricFieldDefinition{
+					{Name: "real", Address: "DB1.R2"},
+					{Name: "word", Address: "DB1.W0"},
+					{Name: "bit", Address: "DB1.X6.2"},
+					{Name: "far", Address: "DB1.B100"},
+					{Name: "other_db", Address: "DB2.B7"},
+					{Name: "marker", Address: "MK0.B7"},
+					{Name: "string", Address: "DB2.S8.5"},
+				},
+			},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.Len(t, plugin.batches, 1)
+
+	expected := []gos7.S7DataItem{
+		{Area: 0x83, WordLen: 0x02, DBNumber: 0, Start: 7, Amount: 1, Data: make([]byte, 1)},
+		{Area: 0x84, WordLen: 0x02, DBNumber: 1, Start: 0, Amount: 7, Data: make([]byte, 7)},
+		{Area: 0x84, WordLen: 0x02, DBNumber: 1, Start: 100, Amount: 1, Data: make([]byte, 1)},
+		{Area: 0x84, WordLen: 0x02, DBNumber: 2, Start: 7, Amount: 6, Data: make([]byte, 6)},
+	}
+	require.Equal(t, expected, plugin.batches[0].items)
+
+	// Check the location of the fields in the merged items
+	locations := make(map[string][3]int)
+	for _, m := range plugin.batches[0].mappings {
+		locations[m.field] = [3]int{m.item, m.offset, m.length}
+	}
+	expectedLocations := map[string][3]int{
+		"marker":   {0, 0, 1},
+		"word":     {1, 0, 2},
+		"real":     {1, 2, 4},
+		"bit":      {1, 6, 1},
+		"far":      {2, 0, 1},
+		"other_db": {3, 0, 1},
+		"string":   {3, 1, 5},
+	}
+	require.Equal(t, expectedLocations, locations)
+}
+
+func TestMergeItemsLimits(t *testing.T) {
+	// Create more fields than fitting into one item
+	fields := make([]metricFieldDefinition, 0, 100)
+	for i := range 100 {
+		fields = append(fields, metricFieldDefinition{
+			Name:    fmt.Sprintf("field_%d", i),
+			Address: fmt.Sprintf("DB1.DW%d", 4*i),
+		})
+	}
+
+	plugin := &S7comm{
+		Server:       "127.0.0.1:102",
+		Rack:         0,
+		Slot:         0,
+		BatchMaxSize: 20,
+		Optimization: "merge",
+		Configs:      []metricDefinition{{Name: "test", Fields: fields}},
+		Log:          &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var total int
+	for _, b := range plugin.batches {
+		var size int
+		for _, item := range b.items {
+			require.LessOrEqual(t, item.Amount, maxMergedItemSize)
+			size += 4 + item.Amount + item.Amount%2
+		}
+		require.LessOrEqual(t, size, maxResponseData)
+		total += len(b.mappings)
+	}
+	require.Len(t, plugin.batches, 2)
+	require.Equal(t, 100, total)
+}
+
+func TestGatherSimulatorSymbolsMerged(t *testing.T) {
+	sim := testutil.NewS7Server()
+	require.NoError(t, sim.Start())
+	defer sim.Close()
+	sim.SetDB(1, 0, []byte{0x00, 0x2A, 0x42, 0x28, 0x00, 0x00, 0x05})
+	sim.SetArea("PE", 0, []byte{0x02})
+	sim.SetArea("PA", 4, []byte{0x12, 0x34})
+	sim.SetArea("MK", 10, []byte{0xFF, 0xFE})
+
+	plugin := &S7comm{
+		Server:       sim.Addr(),
+		Rack:         0,
+		Slot:         2,
+		BatchMaxSize: 20,
+		Optimization: "merge",
+		SymbolFiles:  []string{"testdata/tia.csv", "testdata/step7.sdf"},
+		Timeout:      config.Duration(100 * time.Millisecond),
+		Configs: []metricDefinition{
+			{
+				Name: "plc",
+				Fields: []metricFieldDefinition{
+					{Symbol: "Counter"},
+					{Name: "temperature", Symbol: "Motor_Speed"},
+					{Symbol: "Motor_Running"},
+					{Symbol: "Emergency_Stop"},
+					{Symbol: "Output_Word"},
+					{Symbol: "Offset"},
+				},
+			},
+		},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.Len(t, plugin.batches, 1)
+	require.Len(t, plugin.batches[0].items, 4)
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+	require.NoError(t, plugin.Gather(&acc))
+
+	expected := testutil.MetricMatcher{
+		Name: "plc",
+		Fields: map[string]interface{}{
+			"Counter":        uint64(42),
+			"temperature":    42.0,
+			"Motor_Running":  true,
+			"Emergency_Stop": true,
+			"Output_Word":    uint64(0x1234),
+			"Offset":         int64(-2),
+		},
+	}
+	testutil.RequireMetricsMatch(t, []testutil.MetricMatcher{expected}, acc.GetTelegrafMetrics())
+}
@@ -0,0 +1,208 @@
+package s7comm
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	regexSymbolDB     = regexp.MustCompile(`^DB([0-9]+)\.DB([XBWD])([0-9]+)(?:\.([0-9]+))?$`)
+	regexSymbolArea   = regexp.MustCompile(`^([IEQAM])([XBWD]?)([0-9]+)(?:\.([0-9]+))?$`)
+	regexSymbolString = regexp.MustCompile(`^STRING(?:\[([0-9]+)\])?$`)
+
+	// Mapping of the (English and German) operand identifiers to the areas
+	symbolAreaMap = map[string]string{
+		"I": "PE",
+		"E": "PE",
+		"Q": "PA",
+		"A": "PA",
+		"M": "MK",
+	}
+)
+
+type symbol struct {
+	address string
+	err     error
+}
+
+// loadSymbols reads all given symbol files and returns the symbols found
+func loadSymbols(filenames []string) (map[string]symbol, error) {
+	symbols := make(map[string]symbol)
+	for _, fn := range filenames {
+		entries, err := readSymbolFile(fn)
+		if err != nil {
+			return nil, fmt.Errorf("reading symbol file %q failed: %w", fn, err)
+		}
+		for name, sym := range entries {
+			if _, found := symbols[name]; found {
+				return nil, fmt.Errorf("duplicate symbol %q in file %q", name, fn)
+			}
+			symbols[name] = sym
+		}
+	}
+	return symbols, nil
+}
+
+// readSymbolFile parses a symbol table in CSV format. Both, tag tables
+// exported from TIA Portal with a header containing the "Name",
+// "Data Type" and "Logical Address" columns as well as STEP 7 symbol files
+// (SDF) without header containing the symbol, address and data type in this
+// order are supported.
+func readSymbolFile(filename string) (map[string]symbol, error) {
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	buf = bytes.TrimPrefix(buf, []byte("\xef\xbb\xbf"))
+
+	// Determine the delimiter from the first line as the exports depend on
+	// the locale settings
+	line, _, _ := bytes.Cut(buf, []byte("\n"))
+	delimiter := ','
+	for _, c := range []rune{';', '\t'} {
+		if bytes.Count(line, []byte(string(c))) > bytes.Count(line, []byte(string(delimiter))) {
+			delimiter = c
+		}
+	}
+
+	reader := csv.NewReader(bytes.NewReader(buf))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("no symbols found")
+	}
+
+	// Check for a header and determine the columns
+	nameCol, addrCol, typeCol := 0, 1, 2
+	header := make(map[string]int, len(records[0]))
+	for i, h := range records[0] {
+		header[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if idx, found := header["name"]; found {
+		nameCol = idx
+		if addrCol, found = header["logical address"]; !found {
+			return nil, errors.New("missing 'Logical Address' column")
+		}
+		if typeCol, found = header["data type"]; !found {
+			return nil, errors.New("missing 'Data Type' column")
+		}
+		records = records[1:]
+	}
+
+	symbols := make(map[string]symbol, len(records))
+	for i, record := range records {
+		if len(record) <= max(nameCol, addrCol, typeCol) {
+			continue
+		}
+		name := strings.TrimSpace(record[nameCol])
+		if name == "" {
+			continue
+		}
+		if _, found := symbols[name]; found {
+			return nil, fmt.Errorf("duplicate symbol %q in line %d", name, i+1)
+		}
+
+		// Keep unsupported symbols to report the reason if they are used
+		address, err := convertSymbolAddress(record[addrCol], record[typeCol])
+		symbols[name] = symbol{address: address, err: err}
+	}
+
+	return symbols, nil
+}
+
+// convertSymbolAddress converts the absolute address and data type of a
+// symbol to the address notation used in the field definitions
+func convertSymbolAddress(address, datatype string) (string, error) {
+	address = strings.TrimPrefix(strings.TrimSpace(address), "%")
+	address = strings.ToUpper(strings.ReplaceAll(address, " ", ""))
+	datatype = strings.ToUpper(strings.TrimSpace(datatype))
+
+	// Split the address into its parts
+	var area, size, start, bit string
+	dbno := "0"
+	if parts := regexSymbolDB.FindStringSubmatch(address); parts != nil {
+		area, dbno, size, start, bit = "DB", parts[1], parts[2], parts[3], parts[4]
+	} else if parts := regexSymbolArea.FindStringSubmatch(address); parts != nil {
+		area, size, start, bit = symbolAreaMap[parts[1]], parts[2], parts[3], parts[4]
+	} else {
+		return "", fmt.Errorf("unsupported address %q", address)
+	}
+	if size == "" {
+		size = "X"
+	}
+	if (size == "X") != (bit != "") {
+		return "", fmt.Errorf("invalid bit address in %q", address)
+	}
+	if bit != "" {
+		if n, err := strconv.Atoi(bit); err != nil || n > 7 {
+			return "", fmt.Errorf("bit address %q out of range in %q", bit, address)
+		}
+	}
+
+	// Determine the type and check that it fits the size of the address
+	var dtype, extra, required string
+	switch datatype {
+	case "BOOL":
+		dtype, extra, required = "X", bit, "X"
+	case "BYTE", "USINT":
+		dtype, required = "B", "B"
+	case "CHAR":
+		dtype, required = "C", "B"
+	case "WORD", "UINT":
+		dtype, required = "W", "W"
+	case "INT":
+		dtype, required = "I", "W"
+	case "DWORD", "UDINT":
+		dtype, required = "DW", "D"
+	case "DINT":
+		dtype, required = "DI", "D"
+	case "REAL":
+		dtype, required = "R", "D"
+	case "DATE_AND_TIME", "DT":
+		dtype = "DT"
+	default:
+		parts := regexSymbolString.FindStringSubmatch(datatype)
+		if parts == nil {
+			return "", fmt.Errorf("unsupported data type %q", datatype)
+		}
+		// Strings consist of the maximum and actual length followed by the
+		// characters and default to 254 characters
+		length := 254
+		if parts[1] != "" {
+			length, _ = strconv.Atoi(parts[1])
+		}
+		if length < 1 || length > 254 {
+			return "", fmt.Errorf("invalid string length %d", length)
+		}
+		dtype, extra = "S", strconv.Itoa(length+2)
+	}
+
+	switch required {
+	case "":
+		// Data types spanning multiple bytes must start at a byte boundary
+		if size == "X" && bit != "0" {
+			return "", fmt.Errorf("data type %q requires a byte address but got %q", datatype, address)
+		}
+	case size:
+	default:
+		return "", fmt.Errorf("address %q does not match data type %q", address, datatype)
+	}
+
+	converted := area + dbno + "." + dtype + start
+	if extra != "" {
+		converted += "." + extra
+	}
+	return converted, nil
+}
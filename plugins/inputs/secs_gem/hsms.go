@@ -0,0 +1,315 @@
+package secs_gem
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Session types of the HSMS header
+const (
+	sTypeData         = 0
+	sTypeSelectReq    = 1
+	sTypeSelectRsp    = 2
+	sTypeDeselectReq  = 3
+	sTypeDeselectRsp  = 4
+	sTypeLinktestReq  = 5
+	sTypeLinktestRsp  = 6
+	sTypeRejectReq    = 7
+	sTypeSeparateReq  = 9
+	headerLength      = 10
+	maxMessageLength  = 16 * 1024 * 1024
+	replyBit          = 0x80
+	selectStatusReady = 0
+)
+
+var errClosed = errors.New("connection closed")
+
+// message is an HSMS message with the SECS-II body decoded for data messages
+type message struct {
+	session  uint16
+	stream   byte
+	function byte
+	wait     bool
+	sType    byte
+	system   uint32
+	body     *item
+}
+
+func (m *message) String() string {
+	return fmt.Sprintf("S%dF%d", m.stream, m.function)
+}
+
+// conn is an HSMS connection in active mode dispatching replies to the
+// waiting requests and primary messages to the handler
+type conn struct {
+	conn    net.Conn
+	session uint16
+	timeout time.Duration
+	handler func(*conn, *message)
+
+	system  uint32
+	pending map[uint32]chan *message
+	err     error
+	done    chan struct{}
+	sync.Mutex
+	writeLock sync.Mutex
+}
+
+func dial(address string, session uint16, timeout time.Duration, handler func(*conn, *message)) (*conn, error) {
+	nc, err := net.DialTimeout("tcp", address, timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{
+		conn:    nc,
+		session: session,
+		timeout: timeout,
+		handler: handler,
+		pending: make(map[uint32]chan *message),
+		done:    make(chan struct{}),
+	}
+	go c.receive()
+
+	resp, err := c.request(&message{session: 0xFFFF, sType: sTypeSelectReq})
+	if err != nil {
+		c.close()
+		return nil, fmt.Errorf("select failed: %w", err)
+	}
+	if resp.sType != sTypeSelectRsp {
+		c.close()
+		return nil, fmt.Errorf("select rejected with session type %d", resp.sType)
+	}
+	if status := resp.function; status != selectStatusReady {
+		c.close()
+		return nil, fmt.Errorf("select failed with status %d", status)
+	}
+	return c, nil
+}
+
+// close separates the session and closes the connection
+func (c *conn) close() {
+	_ = c.send(&message{session: 0xFFFF, sType: sTypeSeparateReq, system: c.nextSystem()})
+	c.conn.Close()
+	<-c.done
+}
+
+// closed returns a channel being closed when the connection terminates
+func (c *conn) closed() <-chan struct{} {
+	return c.done
+}
+
+// linktest checks the connection to be alive
+func (c *conn) linktest() error {
+	resp, err := c.request(&message{session: 0xFFFF, sType: sTypeLinktestReq})
+	if err != nil {
+		return err
+	}
+	if resp.sType != sTypeLinktestRsp {
+		return fmt.Errorf("linktest rejected with session type %d", resp.sType)
+	}
+	return nil
+}
+
+// call sends a primary data message expecting a reply and returns the body
+// of the reply
+func (c *conn) call(stream, function byte, body *item) (*item, error) {
+	req := &message{session: c.session, stream: stream, function: function, wait: true, body: body}
+	resp, err := c.request(req)
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", req, err)
+	}
+	if resp.sType != sTypeData {
+		return nil, fmt.Errorf("%s rejected with session type %d", req, resp.sType)
+	}
+	if resp.stream != stream || resp.function != function+1 {
+		if resp.function == 0 {
+			return nil, fmt.Errorf("%s aborted by equipment", req)
+		}
+		return nil, fmt.Errorf("unexpected reply %s to %s", resp, req)
+	}
+	return resp.body, nil
+}
+
+// reply sends the secondary message for the given primary message
+func (c *conn) reply(primary *message, function byte, body *item) error {
+	return c.send(&message{
+		session:  c.session,
+		stream:   primary.stream,
+		function: function,
+		system:   primary.system,
+		body:     body,
+	})
+}
+
+func (c *conn) nextSystem() uint32 {
+	c.Lock()
+	defer c.Unlock()
+	c.system++
+	return c.system
+}
+
+// request sends the message and waits for the reply with the same system
+// bytes within the reply timeout
+func (c *conn) request(req *message) (*message, error) {
+	ch := make(chan *message, 1)
+	c.Lock()
+	if c.err != nil {
+		err := c.err
+		c.Unlock()
+		return nil, err
+	}
+	c.system++
+	req.system = c.system
+	c.pending[req.system] = ch
+	c.Unlock()
+
+	defer func() {
+		c.Lock()
+		delete(c.pending, req.system)
+		c.Unlock()
+	}()
+
+	if err := c.send(req); err != nil {
+		return nil, err
+	}
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-c.done:
+		return nil, c.err
+	case <-timer.C:
+		return nil, errors.New("reply timeout")
+	}
+}
+
+func (c *conn) send(m *message) error {
+	var body []byte
+	if m.body != nil {
+		body = m.body.encode(nil)
+	}
+	buf := make([]byte, 4+headerLength, 4+headerLength+len(body))
+	binary.BigEndian.PutUint32(buf, uint32(headerLength+len(body)))
+	binary.BigEndian.PutUint16(buf[4:], m.session)
+	buf[6] = m.stream
+	if m.wait {
+		buf[6] |= replyBit
+	}
+	buf[7] = m.function
+	buf[9] = m.sType
+	binary.BigEndian.PutUint32(buf[10:], m.system)
+	buf = append(buf, body...)
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	if err := c.conn.SetWriteDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// receive reads messages until the connection fails and terminates all
+// pending requests afterwards
+func (c *conn) receive() {
+	err := c.receiveLoop()
+
+	c.Lock()
+	c.err = err
+	c.Unlock()
+	c.conn.Close()
+	close(c.done)
+}
+
+func (c *conn) receiveLoop() error {
+	for {
+		m, err := c.readMessage()
+		if err != nil {
+			return err
+		}
+
+		switch m.sType {
+		case sTypeData:
+			if m.session != c.session {
+				continue
+			}
+		case sTypeLinktestReq:
+			if err := c.send(&message{session: 0xFFFF, sType: sTypeLinktestRsp, system: m.system}); err != nil {
+				return err
+			}
+			continue
+		case sTypeSeparateReq:
+			return errors.New("separated by equipment")
+		case sTypeSelectReq:
+			// Only the active side may select, reject the request
+			if err := c.send(&message{session: m.session, stream: sTypeSelectReq, function: 1, sType: sTypeRejectReq, system: m.system}); err != nil {
+				return err
+			}
+			continue
+		case sTypeDeselectReq:
+			if err := c.send(&message{session: m.session, sType: sTypeDeselectRsp, system: m.system}); err != nil {
+				return err
+			}
+			return errors.New("deselected by equipment")
+		}
+
+		// Replies to requests are identified by the system bytes, data
+		// messages with an odd function are primary messages
+		if m.sType != sTypeData || m.function%2 == 0 {
+			c.Lock()
+			ch, found := c.pending[m.system]
+			c.Unlock()
+			if found {
+				ch <- m
+			}
+			continue
+		}
+		c.handler(c, m)
+	}
+}
+
+func (c *conn) readMessage() (*message, error) {
+	// No read deadline as the equipment sends messages at arbitrary times,
+	// the connection is supervised using linktests
+	var length [4]byte
+	if _, err := io.ReadFull(c.conn, length[:]); err != nil {
+		if errors.Is(err, net.ErrClosed) {
+			return nil, errClosed
+		}
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(length[:])
+	if n < headerLength || n > maxMessageLength {
+		return nil, fmt.Errorf("invalid message length %d", n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(c.conn, buf); err != nil {
+		return nil, err
+	}
+
+	m := &message{
+		session:  binary.BigEndian.Uint16(buf),
+		stream:   buf[2] &^ replyBit,
+		wait:     buf[2]&replyBit != 0,
+		function: buf[3],
+		sType:    buf[5],
+		system:   binary.BigEndian.Uint32(buf[6:]),
+	}
+	if buf[4] != 0 {
+		return nil, fmt.Errorf("unsupported presentation type %d", buf[4])
+	}
+	if m.sType == sTypeData && len(buf) > headerLength {
+		body, _, err := decodeItem(buf[headerLength:])
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s failed: %w", m, err)
+		}
+		m.body = body
+	}
+	return m, nil
+}
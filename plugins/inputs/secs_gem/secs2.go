@@ -0,0 +1,311 @@
+package secs_gem
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Format codes of SECS-II items
+const (
+	formatList    = 0o00
+	formatBinary  = 0o10
+	formatBoolean = 0o11
+	formatASCII   = 0o20
+	formatJIS8    = 0o21
+	formatI8      = 0o30
+	formatI1      = 0o31
+	formatI2      = 0o32
+	formatI4      = 0o34
+	formatF8      = 0o40
+	formatF4      = 0o44
+	formatU8      = 0o50
+	formatU1      = 0o51
+	formatU2      = 0o52
+	formatU4      = 0o54
+)
+
+// Maximum nesting depth of lists accepted when decoding
+const maxDepth = 16
+
+// Size in bytes of the elements of numeric formats
+var formatSizes = map[byte]int{
+	formatBinary:  1,
+	formatBoolean: 1,
+	formatI8:      8,
+	formatI1:      1,
+	formatI2:      2,
+	formatI4:      4,
+	formatF8:      8,
+	formatF4:      4,
+	formatU8:      8,
+	formatU1:      1,
+	formatU2:      2,
+	formatU4:      4,
+}
+
+// Format codes usable for identifiers in the configuration
+var idFormats = map[string]byte{
+	"A":  formatASCII,
+	"I1": formatI1,
+	"I2": formatI2,
+	"I4": formatI4,
+	"I8": formatI8,
+	"U1": formatU1,
+	"U2": formatU2,
+	"U4": formatU4,
+	"U8": formatU8,
+}
+
+// item is a SECS-II data item being either a list of items, a string or an
+// array of numeric or boolean values
+type item struct {
+	format byte
+	list   []*item
+	text   string
+	ints   []int64
+	uints  []uint64
+	floats []float64
+	bools  []bool
+}
+
+func listItem(items ...*item) *item {
+	if items == nil {
+		items = make([]*item, 0)
+	}
+	return &item{format: formatList, list: items}
+}
+
+func asciiItem(s string) *item {
+	return &item{format: formatASCII, text: s}
+}
+
+func binaryItem(values ...byte) *item {
+	v := make([]uint64, 0, len(values))
+	for _, b := range values {
+		v = append(v, uint64(b))
+	}
+	return &item{format: formatBinary, uints: v}
+}
+
+func booleanItem(values ...bool) *item {
+	return &item{format: formatBoolean, bools: values}
+}
+
+// idItem creates an identifier item of the given format where identifiers
+// of the ASCII format are encoded as decimal strings
+func idItem(format byte, id uint64) *item {
+	switch format {
+	case formatASCII:
+		return asciiItem(strconv.FormatUint(id, 10))
+	case formatI1, formatI2, formatI4, formatI8:
+		return &item{format: format, ints: []int64{int64(id)}}
+	}
+	return &item{format: format, uints: []uint64{id}}
+}
+
+// id returns the identifier contained in the item
+func (it *item) id() (uint64, error) {
+	switch {
+	case it.format == formatASCII:
+		return strconv.ParseUint(strings.TrimSpace(it.text), 10, 64)
+	case len(it.uints) == 1:
+		return it.uints[0], nil
+	case len(it.ints) == 1 && it.ints[0] >= 0:
+		return uint64(it.ints[0]), nil
+	}
+	return 0, fmt.Errorf("item with format %#o is no identifier", it.format)
+}
+
+// values returns the values of the item converted to the types used for
+// fields, i.e. one value per array element and per item of a list
+func (it *item) values() []interface{} {
+	switch it.format {
+	case formatList:
+		var values []interface{}
+		for _, sub := range it.list {
+			values = append(values, sub.values()...)
+		}
+		return values
+	case formatASCII, formatJIS8:
+		return []interface{}{it.text}
+	}
+	values := make([]interface{}, 0, len(it.uints)+len(it.ints)+len(it.floats)+len(it.bools))
+	for _, v := range it.uints {
+		values = append(values, v)
+	}
+	for _, v := range it.ints {
+		values = append(values, v)
+	}
+	for _, v := range it.floats {
+		values = append(values, v)
+	}
+	for _, v := range it.bools {
+		values = append(values, v)
+	}
+	return values
+}
+
+func (it *item) encode(buf []byte) []byte {
+	var data []byte
+	length := 0
+	switch it.format {
+	case formatList:
+		length = len(it.list)
+	case formatASCII, formatJIS8:
+		data = []byte(it.text)
+	case formatBoolean:
+		for _, v := range it.bools {
+			if v {
+				data = append(data, 1)
+			} else {
+				data = append(data, 0)
+			}
+		}
+	case formatBinary, formatU1:
+		for _, v := range it.uints {
+			data = append(data, byte(v))
+		}
+	case formatU2:
+		for _, v := range it.uints {
+			data = binary.BigEndian.AppendUint16(data, uint16(v))
+		}
+	case formatU4:
+		for _, v := range it.uints {
+			data = binary.BigEndian.AppendUint32(data, uint32(v))
+		}
+	case formatU8:
+		for _, v := range it.uints {
+			data = binary.BigEndian.AppendUint64(data, v)
+		}
+	case formatI1:
+		for _, v := range it.ints {
+			data = append(data, byte(v))
+		}
+	case formatI2:
+		for _, v := range it.ints {
+			data = binary.BigEndian.AppendUint16(data, uint16(v))
+		}
+	case formatI4:
+		for _, v := range it.ints {
+			data = binary.BigEndian.AppendUint32(data, uint32(v))
+		}
+	case formatI8:
+		for _, v := range it.ints {
+			data = binary.BigEndian.AppendUint64(data, uint64(v))
+		}
+	case formatF4:
+		for _, v := range it.floats {
+			data = binary.BigEndian.AppendUint32(data, math.Float32bits(float32(v)))
+		}
+	case formatF8:
+		for _, v := range it.floats {
+			data = binary.BigEndian.AppendUint64(data, math.Float64bits(v))
+		}
+	}
+	if it.format != formatList {
+		length = len(data)
+	}
+
+	// Use the minimal number of length bytes
+	switch {
+	case length <= 0xFF:
+		buf = append(buf, it.format<<2|1, byte(length))
+	case length <= 0xFFFF:
+		buf = append(buf, it.format<<2|2, byte(length>>8), byte(length))
+	default:
+		buf = append(buf, it.format<<2|3, byte(length>>16), byte(length>>8), byte(length))
+	}
+	buf = append(buf, data...)
+	for _, sub := range it.list {
+		buf = sub.encode(buf)
+	}
+	return buf
+}
+
+// decodeItem decodes the item at the start of the buffer and returns the
+// number of bytes consumed
+func decodeItem(buf []byte) (*item, int, error) {
+	return decodeItemDepth(buf, 0)
+}
+
+func decodeItemDepth(buf []byte, depth int) (*item, int, error) {
+	if depth > maxDepth {
+		return nil, 0, errors.New("lists nested too deeply")
+	}
+	if len(buf) < 1 {
+		return nil, 0, errors.New("missing item")
+	}
+	format, nlen := buf[0]>>2, int(buf[0]&0x03)
+	if nlen == 0 || len(buf) < 1+nlen {
+		return nil, 0, errors.New("invalid item length")
+	}
+	var length int
+	for _, b := range buf[1 : 1+nlen] {
+		length = length<<8 | int(b)
+	}
+	pos := 1 + nlen
+
+	it := &item{format: format}
+	if format == formatList {
+		it.list = make([]*item, 0, min(length, 256))
+		for range length {
+			sub, n, err := decodeItemDepth(buf[pos:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			it.list = append(it.list, sub)
+			pos += n
+		}
+		return it, pos, nil
+	}
+
+	if len(buf) < pos+length {
+		return nil, 0, errors.New("truncated item")
+	}
+	data := buf[pos : pos+length]
+	pos += length
+
+	if format == formatASCII || format == formatJIS8 {
+		it.text = string(data)
+		return it, pos, nil
+	}
+	size, found := formatSizes[format]
+	if !found {
+		return nil, 0, fmt.Errorf("unknown item format %#o", format)
+	}
+	if length%size != 0 {
+		return nil, 0, fmt.Errorf("invalid length %d for item format %#o", length, format)
+	}
+	for i := 0; i < length; i += size {
+		v := data[i : i+size]
+		switch format {
+		case formatBoolean:
+			it.bools = append(it.bools, v[0] != 0)
+		case formatBinary, formatU1:
+			it.uints = append(it.uints, uint64(v[0]))
+		case formatU2:
+			it.uints = append(it.uints, uint64(binary.BigEndian.Uint16(v)))
+		case formatU4:
+			it.uints = append(it.uints, uint64(binary.BigEndian.Uint32(v)))
+		case formatU8:
+			it.uints = append(it.uints, binary.BigEndian.Uint64(v))
+		case formatI1:
+			it.ints = append(it.ints, int64(int8(v[0])))
+		case formatI2:
+			it.ints = append(it.ints, int64(int16(binary.BigEndian.Uint16(v))))
+		case formatI4:
+			it.ints = append(it.ints, int64(int32(binary.BigEndian.Uint32(v))))
+		case formatI8:
+			it.ints = append(it.ints, int64(binary.BigEndian.Uint64(v)))
+		case formatF4:
+			it.floats = append(it.floats, float64(math.Float32frombits(binary.BigEndian.Uint32(v))))
+		case formatF8:
+			it.floats = append(it.floats, math.Float64frombits(binary.BigEndian.Uint64(v)))
+		}
+	}
+	return it, pos, nil
+}
@@ -0,0 +1,428 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package secs_gem
+
+import (
+	"context"
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/inputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type SECSGEM struct {
+	Address           string            `toml:"address"`
+	SessionID         uint16            `toml:"session_id"`
+	IDFormat          string            `toml:"id_format"`
+	Timeout           config.Duration   `toml:"timeout"`
+	LinktestInterval  config.Duration   `toml:"linktest_interval"`
+	ReconnectInterval config.Duration   `toml:"reconnect_interval"`
+	StatusVariables   []variable        `toml:"status_variables"`
+	Events            []eventDefinition `toml:"event"`
+	Log               telegraf.Logger   `toml:"-"`
+
+	idFormat byte
+	events   map[uint64]*eventDefinition
+	host     string
+
+	acc    telegraf.Accumulator
+	conn   *conn
+	tags   map[string]string
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	sync.Mutex
+}
+
+type variable struct {
+	ID   uint64 `toml:"id"`
+	Name string `toml:"name"`
+}
+
+type eventDefinition struct {
+	ID        uint64     `toml:"id"`
+	Name      string     `toml:"name"`
+	Variables []variable `toml:"variables"`
+}
+
+func (*SECSGEM) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *SECSGEM) Init() error {
+	if s.Address == "" {
+		return errors.New("'address' must be specified")
+	}
+	host, _, err := net.SplitHostPort(s.Address)
+	if err != nil {
+		return fmt.Errorf("invalid 'address' %q: %w", s.Address, err)
+	}
+	s.host = host
+
+	if s.IDFormat == "" {
+		s.IDFormat = "U4"
+	}
+	format, found := idFormats[strings.ToUpper(s.IDFormat)]
+	if !found {
+		return fmt.Errorf("invalid 'id_format' %q", s.IDFormat)
+	}
+	s.idFormat = format
+
+	if s.Timeout <= 0 {
+		return errors.New("'timeout' must be positive")
+	}
+	if s.ReconnectInterval <= 0 {
+		return errors.New("'reconnect_interval' must be positive")
+	}
+	if len(s.StatusVariables) == 0 && len(s.Events) == 0 {
+		return errors.New("neither status variables nor events defined")
+	}
+
+	if err := checkVariables(s.StatusVariables); err != nil {
+		return fmt.Errorf("status variables: %w", err)
+	}
+	s.events = make(map[uint64]*eventDefinition, len(s.Events))
+	for i := range s.Events {
+		e := &s.Events[i]
+		if e.Name == "" {
+			e.Name = strconv.FormatUint(e.ID, 10)
+		}
+		if _, found := s.events[e.ID]; found {
+			return fmt.Errorf("duplicate event %d", e.ID)
+		}
+		if err := checkVariables(e.Variables); err != nil {
+			return fmt.Errorf("event %q: %w", e.Name, err)
+		}
+		s.events[e.ID] = e
+	}
+
+	return nil
+}
+
+func checkVariables(variables []variable) error {
+	seen := make(map[string]bool, len(variables))
+	for _, v := range variables {
+		if v.Name == "" {
+			return fmt.Errorf("unnamed variable %d", v.ID)
+		}
+		if seen[v.Name] {
+			return fmt.Errorf("duplicate variable name %q", v.Name)
+		}
+		seen[v.Name] = true
+	}
+	return nil
+}
+
+func (s *SECSGEM) Start(acc telegraf.Accumulator) error {
+	s.acc = acc
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			if err := s.run(ctx); err != nil && ctx.Err() == nil {
+				acc.AddError(fmt.Errorf("session with %s failed: %w", s.Address, err))
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Duration(s.ReconnectInterval)):
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (s *SECSGEM) Gather(acc telegraf.Accumulator) error {
+	if len(s.StatusVariables) == 0 {
+		return nil
+	}
+
+	s.Lock()
+	c, tags := s.conn, s.tags
+	s.Unlock()
+	if c == nil {
+		return fmt.Errorf("not connected to %s", s.Address)
+	}
+
+	ids := make([]*item, 0, len(s.StatusVariables))
+	for _, v := range s.StatusVariables {
+		ids = append(ids, idItem(s.idFormat, v.ID))
+	}
+	resp, err := c.call(1, 3, listItem(ids...))
+	if err != nil {
+		return fmt.Errorf("reading status variables failed: %w", err)
+	}
+	if resp == nil || resp.format != formatList || len(resp.list) != len(s.StatusVariables) {
+		return errors.New("invalid status variable values")
+	}
+
+	fields := make(map[string]interface{}, len(s.StatusVariables))
+	for i, v := range s.StatusVariables {
+		addValues(fields, v.Name, resp.list[i])
+	}
+	if len(fields) > 0 {
+		acc.AddFields("secs_gem", fields, tags)
+	}
+	return nil
+}
+
+func (s *SECSGEM) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// run establishes a session with the equipment, subscribes to the events
+// and supervises the connection until it fails or the context is cancelled
+func (s *SECSGEM) run(ctx context.Context) error {
+	c, err := dial(s.Address, s.SessionID, time.Duration(s.Timeout), s.handle)
+	if err != nil {
+		return err
+	}
+	defer c.close()
+	// Abort pending requests when stopping
+	stop := context.AfterFunc(ctx, func() { c.conn.Close() })
+	defer stop()
+
+	tags, err := s.establishCommunication(c)
+	if err != nil {
+		return err
+	}
+	// Events might be reported as soon as they are enabled
+	s.Lock()
+	s.tags = tags
+	s.Unlock()
+	if err := s.subscribe(c); err != nil {
+		return err
+	}
+
+	s.Lock()
+	s.conn = c
+	s.Unlock()
+	defer func() {
+		s.Lock()
+		s.conn = nil
+		s.Unlock()
+	}()
+
+	var linktest <-chan time.Time
+	if s.LinktestInterval > 0 {
+		ticker := time.NewTicker(time.Duration(s.LinktestInterval))
+		defer ticker.Stop()
+		linktest = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-c.closed():
+			return c.err
+		case <-linktest:
+			if err := c.linktest(); err != nil {
+				return fmt.Errorf("linktest failed: %w", err)
+			}
+		}
+	}
+}
+
+// establishCommunication sends the establish communications request and
+// returns the tags identifying the equipment
+func (s *SECSGEM) establishCommunication(c *conn) (map[string]string, error) {
+	resp, err := c.call(1, 13, listItem())
+	if err != nil {
+		return nil, fmt.Errorf("establishing communication failed: %w", err)
+	}
+	if resp == nil || resp.format != formatList || len(resp.list) != 2 {
+		return nil, errors.New("invalid establish communications acknowledge")
+	}
+	if ack := resp.list[0].values(); len(ack) != 1 || ack[0] != uint64(0) {
+		return nil, fmt.Errorf("communication denied with %v", ack)
+	}
+
+	tags := map[string]string{"address": s.host}
+	// Equipment may omit the model and software revision
+	if info := resp.list[1]; info.format == formatList && len(info.list) == 2 {
+		tags["model"] = strings.TrimSpace(info.list[0].text)
+		tags["software_revision"] = strings.TrimSpace(info.list[1].text)
+	}
+	return tags, nil
+}
+
+// subscribe defines a report for each event with variables, links the
+// report to the event and enables the events. The events' IDs are used as
+// report IDs.
+func (s *SECSGEM) subscribe(c *conn) error {
+	if len(s.Events) == 0 {
+		return nil
+	}
+
+	var dataID uint64
+	ack := func(stream, function byte, body *item) error {
+		dataID++
+		resp, err := c.call(stream, function, listItem(idItem(s.idFormat, dataID), body))
+		if err != nil {
+			return err
+		}
+		return checkAcknowledge(resp)
+	}
+
+	// Delete previous definitions of the reports which also removes the
+	// links to events
+	deletions := make([]*item, 0, len(s.Events))
+	definitions := make([]*item, 0, len(s.Events))
+	links := make([]*item, 0, len(s.Events))
+	ceids := make([]*item, 0, len(s.Events))
+	for _, e := range s.Events {
+		id := idItem(s.idFormat, e.ID)
+		ceids = append(ceids, id)
+		if len(e.Variables) == 0 {
+			continue
+		}
+		vids := make([]*item, 0, len(e.Variables))
+		for _, v := range e.Variables {
+			vids = append(vids, idItem(s.idFormat, v.ID))
+		}
+		deletions = append(deletions, listItem(id, listItem()))
+		definitions = append(definitions, listItem(id, listItem(vids...)))
+		links = append(links, listItem(id, listItem(id)))
+	}
+
+	if len(definitions) > 0 {
+		if err := ack(2, 33, listItem(deletions...)); err != nil {
+			return fmt.Errorf("deleting reports failed: %w", err)
+		}
+		if err := ack(2, 33, listItem(definitions...)); err != nil {
+			return fmt.Errorf("defining reports failed: %w", err)
+		}
+		if err := ack(2, 35, listItem(links...)); err != nil {
+			return fmt.Errorf("linking reports failed: %w", err)
+		}
+	}
+
+	resp, err := c.call(2, 37, listItem(booleanItem(true), listItem(ceids...)))
+	if err != nil {
+		return fmt.Errorf("enabling events failed: %w", err)
+	}
+	if err := checkAcknowledge(resp); err != nil {
+		return fmt.Errorf("enabling events failed: %w", err)
+	}
+	return nil
+}
+
+// checkAcknowledge checks the acknowledge code of a reply to be zero
+func checkAcknowledge(resp *item) error {
+	if resp == nil || resp.format != formatBinary || len(resp.uints) != 1 {
+		return errors.New("invalid acknowledge")
+	}
+	if code := resp.uints[0]; code != 0 {
+		return fmt.Errorf("denied with code %d", code)
+	}
+	return nil
+}
+
+// handle processes primary messages sent by the equipment
+func (s *SECSGEM) handle(c *conn, m *message) {
+	var err error
+	switch {
+	case m.stream == 6 && m.function == 11:
+		s.addEvent(m.body)
+		if m.wait {
+			err = c.reply(m, m.function+1, binaryItem(0))
+		}
+	case m.stream == 1 && m.function == 1:
+		err = c.reply(m, m.function+1, listItem())
+	case m.stream == 5 && m.function == 1:
+		err = c.reply(m, m.function+1, binaryItem(0))
+	case m.wait:
+		// Abort the transaction of unsupported messages
+		s.Log.Debugf("Aborting unsupported message %s", m)
+		err = c.reply(m, 0, nil)
+	}
+	if err != nil {
+		s.Log.Errorf("Replying to %s failed: %v", m, err)
+	}
+}
+
+// addEvent converts the event report to a metric
+func (s *SECSGEM) addEvent(body *item) {
+	if body == nil || body.format != formatList || len(body.list) != 3 {
+		s.acc.AddError(errors.New("invalid event report"))
+		return
+	}
+	ceid, err := body.list[1].id()
+	if err != nil {
+		s.acc.AddError(fmt.Errorf("invalid event report: %w", err))
+		return
+	}
+	e, found := s.events[ceid]
+	if !found {
+		s.Log.Debugf("Ignoring report of unconfigured event %d", ceid)
+		return
+	}
+
+	fields := map[string]interface{}{"ceid": ceid}
+	for _, report := range body.list[2].list {
+		if report.format != formatList || len(report.list) != 2 {
+			continue
+		}
+		if rptid, err := report.list[0].id(); err != nil || rptid != e.ID {
+			continue
+		}
+		values := report.list[1].list
+		if len(values) != len(e.Variables) {
+			s.acc.AddError(fmt.Errorf("event %q reported %d instead of %d variables", e.Name, len(values), len(e.Variables)))
+			return
+		}
+		for i, v := range e.Variables {
+			addValues(fields, v.Name, values[i])
+		}
+	}
+
+	s.Lock()
+	tags := make(map[string]string, len(s.tags)+1)
+	for k, v := range s.tags {
+		tags[k] = v
+	}
+	s.Unlock()
+	tags["event"] = e.Name
+	s.acc.AddFields("secs_gem_event", fields, tags)
+}
+
+// addValues adds the values of the item as fields where arrays and lists
+// result in one field per element suffixed by the index
+func addValues(fields map[string]interface{}, name string, it *item) {
+	values := it.values()
+	if len(values) == 1 {
+		fields[name] = values[0]
+		return
+	}
+	for i, v := range values {
+		fields[name+"_"+strconv.Itoa(i)] = v
+	}
+}
+
+func init() {
+	inputs.Add("secs_gem", func() telegraf.Input {
+		return &SECSGEM{
+			Timeout:           config.Duration(45 * time.Second),
+			LinktestInterval:  config.Duration(30 * time.Second),
+			ReconnectInterval: config.Duration(10 * time.Second),
+		}
+	})
+}
@@ -0,0 +1,336 @@
+package secs_gem
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *SECSGEM
+		expected string
+	}{
+		{
+			name:     "no address",
+			plugin:   &SECSGEM{},
+			expected: "'address' must be specified",
+		},
+		{
+			name:     "no port",
+			plugin:   &SECSGEM{Address: "127.0.0.1"},
+			expected: `invalid 'address' "127.0.0.1"`,
+		},
+		{
+			name:     "invalid id format",
+			plugin:   &SECSGEM{Address: "127.0.0.1:5000", IDFormat: "F4"},
+			expected: `invalid 'id_format' "F4"`,
+		},
+		{
+			name:     "nothing to collect",
+			plugin:   &SECSGEM{Address: "127.0.0.1:5000"},
+			expected: "neither status variables nor events defined",
+		},
+		{
+			name: "unnamed status variable",
+			plugin: &SECSGEM{
+				Address:         "127.0.0.1:5000",
+				StatusVariables: []variable{{ID: 1001}},
+			},
+			expected: "unnamed variable 1001",
+		},
+		{
+			name: "duplicate event",
+			plugin: &SECSGEM{
+				Address: "127.0.0.1:5000",
+				Events:  []eventDefinition{{ID: 100}, {ID: 100}},
+			},
+			expected: "duplicate event 100",
+		},
+		{
+			name: "duplicate event variable",
+			plugin: &SECSGEM{
+				Address: "127.0.0.1:5000",
+				Events: []eventDefinition{{
+					ID:        100,
+					Name:      "lot_start",
+					Variables: []variable{{ID: 1, Name: "lot"}, {ID: 2, Name: "lot"}},
+				}},
+			},
+			expected: `event "lot_start": duplicate variable name "lot"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Timeout = config.Duration(time.Second)
+			tt.plugin.ReconnectInterval = config.Duration(time.Second)
+			tt.plugin.Log = testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestItemEncoding(t *testing.T) {
+	long := make([]byte, 300)
+	original := listItem(
+		asciiItem("PRESS-1"),
+		binaryItem(0, 1),
+		booleanItem(true, false),
+		&item{format: formatI2, ints: []int64{-2, 300}},
+		&item{format: formatU4, uints: []uint64{70000}},
+		&item{format: formatF4, floats: []float64{1.5}},
+		&item{format: formatF8, floats: []float64{-0.25}},
+		binaryItem(long...),
+		listItem(),
+	)
+	buf := original.encode(nil)
+	// The binary item with 300 bytes requires two length bytes
+	require.Equal(t, []byte{formatList<<2 | 1, 9, formatASCII<<2 | 1, 7}, buf[:4])
+
+	decoded, n, err := decodeItem(buf)
+	require.NoError(t, err)
+	require.Equal(t, len(buf), n)
+	require.Equal(t, original, decoded)
+
+	_, _, err = decodeItem(buf[:len(buf)-5])
+	require.Error(t, err)
+}
+
+func TestIDItems(t *testing.T) {
+	for _, format := range idFormats {
+		it := idItem(format, 42)
+		decoded, _, err := decodeItem(it.encode(nil))
+		require.NoError(t, err)
+		id, err := decoded.id()
+		require.NoError(t, err)
+		require.Equal(t, uint64(42), id)
+	}
+}
+
+func TestSession(t *testing.T) {
+	eq := newFakeEquipment(t)
+
+	plugin := &SECSGEM{
+		Address:           eq.addr(),
+		SessionID:         1,
+		Timeout:           config.Duration(time.Second),
+		LinktestInterval:  config.Duration(50 * time.Millisecond),
+		ReconnectInterval: config.Duration(100 * time.Millisecond),
+		StatusVariables: []variable{
+			{ID: 1001, Name: "chamber_pressure"},
+			{ID: 1002, Name: "recipe"},
+			{ID: 1003, Name: "temperatures"},
+		},
+		Events: []eventDefinition{
+			{
+				ID:   100,
+				Name: "lot_start",
+				Variables: []variable{
+					{ID: 2001, Name: "lot_id"},
+					{ID: 2002, Name: "wafer_count"},
+				},
+			},
+			{ID: 101, Name: "door_open"},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	// Wait for the equipment to report the event after the subscription
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= 2
+	}, 3*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		plugin.Lock()
+		defer plugin.Unlock()
+		return plugin.conn != nil
+	}, 3*time.Second, 10*time.Millisecond)
+	require.NoError(t, plugin.Gather(&acc))
+	require.Empty(t, acc.Errors)
+
+	tags := map[string]string{
+		"address":           "127.0.0.1",
+		"model":             "ETCHER",
+		"software_revision": "1.2.3",
+	}
+	eventTags := func(name string) map[string]string {
+		t := map[string]string{"event": name}
+		for k, v := range tags {
+			t[k] = v
+		}
+		return t
+	}
+	expected := []telegraf.Metric{
+		metric.New(
+			"secs_gem_event",
+			eventTags("lot_start"),
+			map[string]interface{}{
+				"ceid":        uint64(100),
+				"lot_id":      "LOT-42",
+				"wafer_count": uint64(25),
+			},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"secs_gem_event",
+			eventTags("door_open"),
+			map[string]interface{}{"ceid": uint64(101)},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"secs_gem",
+			tags,
+			map[string]interface{}{
+				"chamber_pressure": float64(0.5),
+				"recipe":           "OXIDE-ETCH",
+				"temperatures_0":   int64(21),
+				"temperatures_1":   int64(-3),
+			},
+			time.Unix(0, 0),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+
+	require.Eventually(t, func() bool {
+		eq.Lock()
+		defer eq.Unlock()
+		return len(eq.received) == 9 && eq.linktests > 0
+	}, 3*time.Second, 10*time.Millisecond)
+
+	// The reply to "are you there" and the status variable request might be
+	// received in any order
+	eq.Lock()
+	defer eq.Unlock()
+	require.Equal(t, []string{"S1F13", "S2F33", "S2F33", "S2F35", "S2F37", "S6F12", "S6F12"}, eq.received[:7])
+	require.ElementsMatch(t, []string{"S1F2", "S1F3"}, eq.received[7:])
+}
+
+// fakeEquipment is a passive HSMS entity answering the GEM messages of the
+// host and reporting events after they were enabled
+type fakeEquipment struct {
+	listener  net.Listener
+	received  []string
+	reports   map[uint64][]uint64
+	linktests int
+	sync.Mutex
+}
+
+func newFakeEquipment(t *testing.T) *fakeEquipment {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	eq := &fakeEquipment{listener: listener, reports: make(map[uint64][]uint64)}
+	go func() {
+		for {
+			nc, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go eq.serve(&conn{conn: nc, session: 1, timeout: time.Second})
+		}
+	}()
+	return eq
+}
+
+func (eq *fakeEquipment) addr() string {
+	return eq.listener.Addr().String()
+}
+
+func (eq *fakeEquipment) serve(c *conn) {
+	defer c.conn.Close()
+	for {
+		m, err := c.readMessage()
+		if err != nil {
+			return
+		}
+		switch m.sType {
+		case sTypeSelectReq:
+			err = c.send(&message{session: m.session, sType: sTypeSelectRsp, system: m.system})
+		case sTypeLinktestReq:
+			eq.Lock()
+			eq.linktests++
+			eq.Unlock()
+			err = c.send(&message{session: m.session, sType: sTypeLinktestRsp, system: m.system})
+		case sTypeSeparateReq:
+			return
+		case sTypeData:
+			err = eq.handle(c, m)
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (eq *fakeEquipment) handle(c *conn, m *message) error {
+	eq.Lock()
+	eq.received = append(eq.received, m.String())
+	eq.Unlock()
+
+	switch m.String() {
+	case "S1F13":
+		return c.reply(m, 14, listItem(binaryItem(0), listItem(asciiItem("ETCHER"), asciiItem("1.2.3"))))
+	case "S2F33":
+		// Record the report definitions, empty ones delete the report
+		for _, report := range m.body.list[1].list {
+			rptid, _ := report.list[0].id()
+			vids := make([]uint64, 0, len(report.list[1].list))
+			for _, v := range report.list[1].list {
+				vid, _ := v.id()
+				vids = append(vids, vid)
+			}
+			eq.Lock()
+			eq.reports[rptid] = vids
+			eq.Unlock()
+		}
+		return c.reply(m, 34, binaryItem(0))
+	case "S2F35":
+		return c.reply(m, 36, binaryItem(0))
+	case "S2F37":
+		if err := c.reply(m, 38, binaryItem(0)); err != nil {
+			return err
+		}
+		eq.Lock()
+		vids := eq.reports[100]
+		eq.Unlock()
+		if len(vids) != 2 || vids[0] != 2001 || vids[1] != 2002 {
+			return nil
+		}
+		report := listItem(idItem(formatU4, 100), listItem(asciiItem("LOT-42"), &item{format: formatU2, uints: []uint64{25}}))
+		events := []*message{
+			{session: 1, stream: 6, function: 11, wait: true, system: 1001, body: listItem(idItem(formatU4, 1), idItem(formatU4, 100), listItem(report))},
+			{session: 1, stream: 6, function: 11, wait: true, system: 1002, body: listItem(idItem(formatU4, 2), idItem(formatU4, 101), listItem())},
+			// Events not configured are ignored
+			{session: 1, stream: 6, function: 11, system: 1003, body: listItem(idItem(formatU4, 3), idItem(formatU4, 999), listItem())},
+			{session: 1, stream: 1, function: 1, wait: true, system: 1004},
+		}
+		for _, e := range events {
+			if err := c.send(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "S1F3":
+		return c.reply(m, 4, listItem(
+			&item{format: formatF4, floats: []float64{0.5}},
+			asciiItem("OXIDE-ETCH"),
+			&item{format: formatI4, ints: []int64{21, -3}},
+		))
+	}
+	return nil
+}
@@ -6,16 +6,23 @@ import (
 )
 
 type gosmiTranslator struct {
+	paths []string
+	log   telegraf.Logger
 }
 
 func (*gosmiTranslator) lookup(oid string) (snmp.MibEntry, error) {
 	return snmp.TrapLookup(oid)
 }
 
+// reload loads the MIB files added to the paths since the last load
+func (g *gosmiTranslator) reload() (int, error) {
+	return snmp.ReloadMibsFromPath(g.paths, g.log, &snmp.GosmiMibLoader{})
+}
+
 func newGosmiTranslator(paths []string, log telegraf.Logger) (*gosmiTranslator, error) {
 	err := snmp.LoadMibsFromPath(paths, log, &snmp.GosmiMibLoader{})
 	if err == nil {
-		return &gosmiTranslator{}, nil
+		return &gosmiTranslator{paths: paths, log: log}, nil
 	}
 	return nil, err
 }
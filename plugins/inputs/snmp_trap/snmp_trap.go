@@ -9,6 +9,7 @@ import (
 	"net"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"unicode/utf8"
 
@@ -41,6 +42,10 @@ type SnmpTrap struct {
 	// Values: "DES", "AES", "". Default: ""
 	PrivProtocol string        `toml:"priv_protocol"`
 	PrivPassword config.Secret `toml:"priv_password"`
+	// Authoritative engine ID of the receiver for SNMPv3 informs
+	EngineID string `toml:"engine_id"`
+
+	MibReloadInterval config.Duration `toml:"mib_reload_interval"`
 
 	Translator string          `toml:"-"`
 	Log        telegraf.Logger `toml:"-"`
@@ -49,6 +54,9 @@ type SnmpTrap struct {
 	listener *gosnmp.TrapListener
 	timeFunc func() time.Time
 	errCh    chan error
+	engineID string
+	done     chan struct{}
+	wg       sync.WaitGroup
 
 	makeHandlerWrapper func(gosnmp.TrapHandlerFunc) gosnmp.TrapHandlerFunc
 	transl             translator
@@ -62,6 +70,11 @@ type translator interface {
 	lookup(oid string) (snmp.MibEntry, error)
 }
 
+// reloader is implemented by translators able to load new MIBs at runtime
+type reloader interface {
+	reload() (int, error)
+}
+
 func (*SnmpTrap) SampleConfig() string {
 	return sampleConfig
 }
@@ -87,6 +100,29 @@ func (s *SnmpTrap) Init() error {
 	if err != nil {
 		s.Log.Errorf("Could not get path %v", err)
 	}
+
+	if s.MibReloadInterval > 0 {
+		if _, ok := s.transl.(reloader); !ok {
+			s.Log.Warnf("Reloading MIBs is not supported by the %q translator, ignoring 'mib_reload_interval'", s.Translator)
+		}
+	}
+
+	// SNMPv3 informs are sent to the receiver being the authoritative engine
+	// so the sender has to discover the engine ID before sending the inform
+	if s.EngineID != "" {
+		if s.Version != "3" {
+			return errors.New("'engine_id' requires version 3")
+		}
+		id, err := hex.DecodeString(strings.TrimPrefix(strings.ToLower(s.EngineID), "0x"))
+		if err != nil {
+			return fmt.Errorf("decoding 'engine_id' failed: %w", err)
+		}
+		// RFC 3411 section 5 limits the engine ID to 5 to 32 octets
+		if len(id) < 5 || len(id) > 32 {
+			return fmt.Errorf("invalid length %d of 'engine_id', expected 5 to 32 octets", len(id))
+		}
+		s.engineID = string(id)
+	}
 	return nil
 }
 
@@ -188,6 +224,7 @@ func (s *SnmpTrap) Start(acc telegraf.Accumulator) error {
 		authPasswdSecret.Destroy()
 
 		s.listener.Params.SecurityParameters = &gosnmp.UsmSecurityParameters{
+			AuthoritativeEngineID:    s.engineID,
 			UserName:                 secname,
 			PrivacyProtocol:          privacyProtocol,
 			PrivacyPassphrase:        privPasswd,
@@ -231,14 +268,51 @@ func (s *SnmpTrap) Start(acc telegraf.Accumulator) error {
 		return err
 	}
 
+	s.done = make(chan struct{})
+	if r, ok := s.transl.(reloader); ok && s.MibReloadInterval > 0 {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.reloadMibs(r)
+		}()
+	}
+
 	return nil
 }
 
+// reloadMibs periodically loads the MIBs added to the paths until the plugin
+// is stopped
+func (s *SnmpTrap) reloadMibs(r reloader) {
+	ticker := time.NewTicker(time.Duration(s.MibReloadInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			n, err := r.reload()
+			if err != nil {
+				s.Log.Errorf("Reloading MIBs failed: %v", err)
+				continue
+			}
+			if n > 0 {
+				s.Log.Infof("Loaded %d new MIB module(s)", n)
+			}
+		}
+	}
+}
+
 func (*SnmpTrap) Gather(telegraf.Accumulator) error {
 	return nil
 }
 
 func (s *SnmpTrap) Stop() {
+	if s.done != nil {
+		close(s.done)
+		s.wg.Wait()
+	}
+
 	s.listener.Close()
 	err := <-s.errCh
 	if nil != err {
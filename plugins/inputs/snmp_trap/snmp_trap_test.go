@@ -1,9 +1,11 @@
 package snmp_trap
 
 import (
+	"encoding/hex"
 	"errors"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -1307,6 +1309,186 @@ func TestReceiveTrapV3(t *testing.T) {
 	}
 }
 
+func TestReceiveInformV3(t *testing.T) {
+	now := uint32(123123123)
+	const port = 12399
+
+	engineID := "80001f8880e9630000d61ff449"
+	plugin := &SnmpTrap{
+		ServiceAddress: "udp://:" + strconv.Itoa(port),
+		Version:        "3",
+		Translator:     "netsnmp",
+		SecName:        config.NewSecret([]byte("informuser")),
+		SecLevel:       "authPriv",
+		AuthProtocol:   "sha",
+		AuthPassword:   config.NewSecret([]byte("Abcd1234")),
+		PrivProtocol:   "aes",
+		PrivPassword:   config.NewSecret([]byte("Abcd1234")),
+		EngineID:       engineID,
+		Log:            testutil.Logger{},
+		timeFunc:       time.Now,
+	}
+	require.NoError(t, plugin.Init())
+
+	// inject test translator
+	plugin.transl = &testTranslator{
+		entries: []entry{
+			{
+				oid: ".1.3.6.1.6.3.1.1.4.1.0",
+				e:   snmp.MibEntry{MibName: "SNMPv2-MIB", OidText: "snmpTrapOID.0"},
+			},
+			{
+				oid: ".1.3.6.1.6.3.1.1.5.1",
+				e:   snmp.MibEntry{MibName: "SNMPv2-MIB", OidText: "coldStart"},
+			},
+			{
+				oid: ".1.3.6.1.2.1.1.3.0",
+				e:   snmp.MibEntry{MibName: "UNUSED_MIB_NAME", OidText: "sysUpTimeInstance"},
+			},
+		},
+	}
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	// The sender doesn't know the engine ID of the receiver and has to
+	// discover it before sending the inform
+	security := createSecurityParameters("sha", "aes", "informuser", "Abcd1234", "Abcd1234")
+	security.AuthoritativeEngineID = ""
+	security.AuthoritativeEngineBoots = 0
+	security.AuthoritativeEngineTime = 0
+
+	client := &gosnmp.GoSNMP{
+		Port:               port,
+		Version:            gosnmp.Version3,
+		Timeout:            2 * time.Second,
+		Retries:            1,
+		MaxOids:            gosnmp.MaxOids,
+		Target:             "127.0.0.1",
+		SecurityParameters: security,
+		SecurityModel:      gosnmp.UserSecurityModel,
+		MsgFlags:           gosnmp.AuthPriv,
+	}
+	require.NoError(t, client.Connect(), "connecting failed")
+	defer client.Conn.Close()
+
+	inform := gosnmp.SnmpTrap{
+		Variables: []gosnmp.SnmpPDU{
+			{
+				Name:  ".1.3.6.1.2.1.1.3.0",
+				Type:  gosnmp.TimeTicks,
+				Value: now,
+			},
+			{
+				Name:  ".1.3.6.1.6.3.1.1.4.1.0", // SNMPv2-MIB::snmpTrapOID.0
+				Type:  gosnmp.ObjectIdentifier,
+				Value: ".1.3.6.1.6.3.1.1.5.1", // coldStart
+			},
+		},
+		IsInform: true,
+	}
+	resp, err := client.SendTrap(inform)
+	require.NoError(t, err, "sending failed")
+	require.Equal(t, gosnmp.GetResponse, resp.PDUType)
+	require.Equal(t, gosnmp.NoError, resp.Error)
+
+	// The engine ID was discovered from the report of the receiver
+	discovered, ok := client.SecurityParameters.(*gosnmp.UsmSecurityParameters)
+	require.True(t, ok)
+	require.Equal(t, engineID, hex.EncodeToString([]byte(discovered.AuthoritativeEngineID)))
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"snmp_trap",
+			map[string]string{
+				"oid":       ".1.3.6.1.6.3.1.1.5.1",
+				"name":      "coldStart",
+				"mib":       "SNMPv2-MIB",
+				"version":   "3",
+				"source":    "127.0.0.1",
+				"engine_id": engineID,
+			},
+			map[string]interface{}{
+				"sysUpTimeInstance": now,
+			},
+			time.Unix(0, 0),
+		),
+	}
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() >= uint64(len(expected))
+	}, 3*time.Second, 100*time.Millisecond, "timed out waiting for inform to be received")
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics(), testutil.IgnoreTime())
+}
+
+func TestInitEngineIDFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		version  string
+		engineID string
+		expected string
+	}{
+		{
+			name:     "wrong version",
+			version:  "2c",
+			engineID: "80001f8880e9630000d61ff449",
+			expected: "'engine_id' requires version 3",
+		},
+		{
+			name:     "invalid hex",
+			version:  "3",
+			engineID: "80001f88zz",
+			expected: "decoding 'engine_id' failed",
+		},
+		{
+			name:     "too short",
+			version:  "3",
+			engineID: "0x80001f88",
+			expected: "invalid length 4 of 'engine_id', expected 5 to 32 octets",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &SnmpTrap{
+				Version:    tt.version,
+				Translator: "netsnmp",
+				EngineID:   tt.engineID,
+				Log:        testutil.Logger{},
+			}
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestReloadMibs(t *testing.T) {
+	plugin := &SnmpTrap{
+		ServiceAddress:    "udp://127.0.0.1:0",
+		Version:           "2c",
+		Translator:        "netsnmp",
+		MibReloadInterval: config.Duration(10 * time.Millisecond),
+		Log:               testutil.Logger{},
+		timeFunc:          time.Now,
+	}
+	require.NoError(t, plugin.Init())
+
+	// inject a translator able to reload
+	transl := &reloadingTranslator{}
+	plugin.transl = transl
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	require.Eventually(t, func() bool {
+		return transl.reloads.Load() >= 2
+	}, 3*time.Second, 10*time.Millisecond)
+	plugin.Stop()
+
+	// No reloads after stopping
+	reloads := transl.reloads.Load()
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, reloads, transl.reloads.Load())
+}
+
 func TestOidLookupFail(t *testing.T) {
 	now := uint32(123123123)
 
@@ -1412,6 +1594,16 @@ func (t *testTranslator) lookup(input string) (snmp.MibEntry, error) {
 	return snmp.MibEntry{}, errors.New("unexpected oid")
 }
 
+type reloadingTranslator struct {
+	testTranslator
+	reloads atomic.Int32
+}
+
+func (t *reloadingTranslator) reload() (int, error) {
+	t.reloads.Add(1)
+	return 0, nil
+}
+
 func createSecurityParameters(authProto, privProto, username, privPass, authPass string) *gosnmp.UsmSecurityParameters {
 	var authenticationProtocol gosnmp.SnmpV3AuthProtocol
 	switch strings.ToLower(authProto) {
@@ -3,6 +3,7 @@ package statsd
 import (
 	"fmt"
 	"net"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
@@ -41,10 +42,11 @@ func newTestStatsd() *Statsd {
 
 // Test that MaxTCPConnections is respected
 func TestConcurrentConns(t *testing.T) {
+	addr := "127.0.0.1:" + strconv.Itoa(testutil.FreeTCPPort(t))
 	listener := Statsd{
 		Log:                    testutil.Logger{},
 		Protocol:               "tcp",
-		ServiceAddress:         "localhost:8125",
+		ServiceAddress:         addr,
 		AllowedPendingMessages: 10000,
 		MaxTCPConnections:      2,
 		NumberWorkerThreads:    5,
@@ -54,16 +56,16 @@ func TestConcurrentConns(t *testing.T) {
 	require.NoError(t, listener.Start(acc))
 	defer listener.Stop()
 
-	time.Sleep(time.Millisecond * 250)
-	_, err := net.Dial("tcp", "127.0.0.1:8125")
+	testutil.WaitForListener(t, "tcp", addr, 5*time.Second)
+	_, err := net.Dial("tcp", addr)
 	require.NoError(t, err)
-	_, err = net.Dial("tcp", "127.0.0.1:8125")
+	_, err = net.Dial("tcp", addr)
 	require.NoError(t, err)
 
 	// Connection over the limit:
-	conn, err := net.Dial("tcp", "127.0.0.1:8125")
+	conn, err := net.Dial("tcp", addr)
 	require.NoError(t, err)
-	_, err = net.Dial("tcp", "127.0.0.1:8125")
+	_, err = net.Dial("tcp", addr)
 	require.NoError(t, err)
 	_, err = conn.Write([]byte(testMsg))
 	require.NoError(t, err)
@@ -73,10 +75,11 @@ func TestConcurrentConns(t *testing.T) {
 
 // Test that MaxTCPConnections is respected when max==1
 func TestConcurrentConns1(t *testing.T) {
+	addr := "127.0.0.1:" + strconv.Itoa(testutil.FreeTCPPort(t))
 	listener := Statsd{
 		Log:                    testutil.Logger{},
 		Protocol:               "tcp",
-		ServiceAddress:         "localhost:8125",
+		ServiceAddress:         addr,
 		AllowedPendingMessages: 10000,
 		MaxTCPConnections:      1,
 		NumberWorkerThreads:    5,
@@ -86,14 +89,14 @@ func TestConcurrentConns1(t *testing.T) {
 	require.NoError(t, listener.Start(acc))
 	defer listener.Stop()
 
-	time.Sleep(time.Millisecond * 250)
-	_, err := net.Dial("tcp", "127.0.0.1:8125")
+	testutil.WaitForListener(t, "tcp", addr, 5*time.Second)
+	_, err := net.Dial("tcp", addr)
 	require.NoError(t, err)
 
 	// Connection over the limit:
-	conn, err := net.Dial("tcp", "127.0.0.1:8125")
+	conn, err := net.Dial("tcp", addr)
 	require.NoError(t, err)
-	_, err = net.Dial("tcp", "127.0.0.1:8125")
+	_, err = net.Dial("tcp", addr)
 	require.NoError(t, err)
 	_, err = conn.Write([]byte(testMsg))
 	require.NoError(t, err)
@@ -103,10 +106,11 @@ func TestConcurrentConns1(t *testing.T) {
 
 // Test that MaxTCPConnections is respected
 func TestCloseConcurrentConns(t *testing.T) {
+	addr := "127.0.0.1:" + strconv.Itoa(testutil.FreeTCPPort(t))
 	listener := Statsd{
 		Log:                    testutil.Logger{},
 		Protocol:               "tcp",
-		ServiceAddress:         "localhost:8125",
+		ServiceAddress:         addr,
 		AllowedPendingMessages: 10000,
 		MaxTCPConnections:      2,
 		NumberWorkerThreads:    5,
@@ -115,10 +119,10 @@ func TestCloseConcurrentConns(t *testing.T) {
 	acc := &testutil.Accumulator{}
 	require.NoError(t, listener.Start(acc))
 
-	time.Sleep(time.Millisecond * 250)
-	_, err := net.Dial("tcp", "127.0.0.1:8125")
+	testutil.WaitForListener(t, "tcp", addr, 5*time.Second)
+	_, err := net.Dial("tcp", addr)
 	require.NoError(t, err)
-	_, err = net.Dial("tcp", "127.0.0.1:8125")
+	_, err = net.Dial("tcp", addr)
 	require.NoError(t, err)
 
 	listener.Stop()
@@ -0,0 +1,5 @@
+//go:build !custom || outputs || outputs.azure_iot_operations
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/outputs/azure_iot_operations" // register plugin
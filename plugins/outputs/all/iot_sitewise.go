@@ -0,0 +1,5 @@
+//go:build !custom || outputs || outputs.iot_sitewise
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/outputs/iot_sitewise" // register plugin
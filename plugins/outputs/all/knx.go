@@ -0,0 +1,5 @@
+//go:build !custom || outputs || outputs.knx
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/outputs/knx" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || outputs || outputs.lakehouse
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/outputs/lakehouse" // register plugin
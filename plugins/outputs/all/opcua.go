@@ -0,0 +1,5 @@
+//go:build !custom || outputs || outputs.opcua
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/outputs/opcua" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || outputs || outputs.opcua_server
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/outputs/opcua_server" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || outputs || outputs.parquet_upload
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/outputs/parquet_upload" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || outputs || outputs.sparkplug_b
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/outputs/sparkplug_b" // register plugin
@@ -0,0 +1,397 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package azure_iot_operations
+
+import (
+	"bytes"
+	"context"
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/mqtt"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Name of the payload property holding the metric time
+const timestampKey = "timestamp"
+
+// Authentication methods of the MQTT enhanced authentication
+var authMethods = map[string]string{
+	"k8s-sat":    "K8S-SAT",
+	"oauth2-jwt": "OAUTH2-JWT",
+}
+
+type AzureIoTOperations struct {
+	Topic             string          `toml:"topic"`
+	AuthMethod        string          `toml:"auth_method"`
+	TokenFile         string          `toml:"token_file"`
+	TokenScope        string          `toml:"token_scope"`
+	AzureTenantID     string          `toml:"azure_tenant_id"`
+	AzureClientID     string          `toml:"azure_client_id"`
+	AzureClientSecret config.Secret   `toml:"azure_client_secret"`
+	SchemaRegistry    *SchemaRegistry `toml:"schema_registry"`
+	Log               telegraf.Logger `toml:"-"`
+	mqtt.MqttConfig
+
+	client     mqtt.Client
+	newClient  func(*mqtt.MqttConfig) (mqtt.Client, error)
+	credential azcore.TokenCredential
+	topic      *template.Template
+	schemaName *template.Template
+	registry   *registry
+	schemas    map[string]*schema
+}
+
+type SchemaRegistry struct {
+	SubscriptionID string `toml:"subscription_id"`
+	ResourceGroup  string `toml:"resource_group"`
+	Name           string `toml:"name"`
+	SchemaName     string `toml:"schema_name"`
+	Endpoint       string `toml:"endpoint"`
+}
+
+// templateData provides the metric data to the topic and schema name templates
+type templateData struct {
+	Name string
+	Tags map[string]string
+}
+
+// Tag returns the value of the given tag or an empty string
+func (d *templateData) Tag(key string) string {
+	return d.Tags[key]
+}
+
+type message struct {
+	index   int
+	topic   string
+	payload []byte
+}
+
+func (*AzureIoTOperations) SampleConfig() string {
+	return sampleConfig
+}
+
+func (a *AzureIoTOperations) Init() error {
+	if len(a.Servers) == 0 {
+		return errors.New("no servers specified")
+	}
+
+	// Enhanced authentication requires MQTT v5
+	switch a.Protocol {
+	case "", "5":
+		a.Protocol = "5"
+	default:
+		return fmt.Errorf("unsupported protocol %q, only \"5\" is supported", a.Protocol)
+	}
+
+	if a.Topic == "" {
+		return errors.New("missing 'topic'")
+	}
+	tmpl, err := template.New("topic").Parse(a.Topic)
+	if err != nil {
+		return fmt.Errorf("parsing 'topic' template failed: %w", err)
+	}
+	a.topic = tmpl
+
+	switch a.AuthMethod {
+	case "", "none":
+		a.AuthMethod = "none"
+	case "k8s-sat":
+		if a.TokenFile == "" {
+			return errors.New("missing 'token_file'")
+		}
+	case "oauth2-jwt":
+		if a.TokenScope == "" {
+			return errors.New("missing 'token_scope'")
+		}
+	default:
+		return fmt.Errorf("invalid 'auth_method' %q", a.AuthMethod)
+	}
+
+	if a.SchemaRegistry != nil {
+		if a.SchemaRegistry.SubscriptionID == "" || a.SchemaRegistry.ResourceGroup == "" || a.SchemaRegistry.Name == "" {
+			return errors.New("schema registry requires 'subscription_id', 'resource_group' and 'name'")
+		}
+		if a.SchemaRegistry.SchemaName == "" {
+			a.SchemaRegistry.SchemaName = "{{.Name}}"
+		}
+		tmpl, err := template.New("schema_name").Parse(a.SchemaRegistry.SchemaName)
+		if err != nil {
+			return fmt.Errorf("parsing 'schema_name' template failed: %w", err)
+		}
+		a.schemaName = tmpl
+		if a.SchemaRegistry.Endpoint == "" {
+			a.SchemaRegistry.Endpoint = "https://management.azure.com"
+		}
+	}
+
+	if !a.AzureClientSecret.Empty() && (a.AzureTenantID == "" || a.AzureClientID == "") {
+		return errors.New("'azure_client_secret' requires 'azure_tenant_id' and 'azure_client_id'")
+	}
+
+	// Create the credential for getting Microsoft Entra ID tokens
+	if a.credential == nil && (a.AuthMethod == "oauth2-jwt" || a.SchemaRegistry != nil) {
+		cred, err := a.createCredential()
+		if err != nil {
+			return fmt.Errorf("creating credential failed: %w", err)
+		}
+		a.credential = cred
+	}
+
+	// Identify the payload format for consumers
+	if a.PublishPropertiesV5 == nil {
+		a.PublishPropertiesV5 = &mqtt.PublishProperties{ContentType: "application/json"}
+	}
+
+	if a.newClient == nil {
+		a.newClient = mqtt.NewClient
+	}
+	a.schemas = make(map[string]*schema)
+
+	return nil
+}
+
+func (a *AzureIoTOperations) createCredential() (azcore.TokenCredential, error) {
+	// Fall back to the environment, workload or managed identity if no
+	// client secret is given
+	if a.AzureClientSecret.Empty() {
+		options := &azidentity.DefaultAzureCredentialOptions{TenantID: a.AzureTenantID}
+		return azidentity.NewDefaultAzureCredential(options)
+	}
+
+	secret, err := a.AzureClientSecret.Get()
+	if err != nil {
+		return nil, fmt.Errorf("getting client secret failed: %w", err)
+	}
+	defer secret.Destroy()
+	return azidentity.NewClientSecretCredential(a.AzureTenantID, a.AzureClientID, secret.String(), nil)
+}
+
+func (a *AzureIoTOperations) Connect() error {
+	cfg := a.MqttConfig
+	if method, found := authMethods[a.AuthMethod]; found {
+		cfg.AuthMethod = method
+		cfg.AuthData = a.authData
+	}
+
+	client, err := a.newClient(&cfg)
+	if err != nil {
+		return err
+	}
+	a.client = client
+	if _, err := a.client.Connect(); err != nil {
+		return err
+	}
+
+	if a.SchemaRegistry != nil {
+		a.registry = &registry{
+			endpoint:       strings.TrimSuffix(a.SchemaRegistry.Endpoint, "/"),
+			subscriptionID: a.SchemaRegistry.SubscriptionID,
+			resourceGroup:  a.SchemaRegistry.ResourceGroup,
+			name:           a.SchemaRegistry.Name,
+			credential:     a.credential,
+			client:         &http.Client{Timeout: time.Duration(a.Timeout)},
+		}
+	}
+
+	return nil
+}
+
+func (a *AzureIoTOperations) Close() error {
+	if a.registry != nil {
+		a.registry.client.CloseIdleConnections()
+	}
+	if a.client == nil {
+		return nil
+	}
+	err := a.client.Close()
+	a.client = nil
+	return err
+}
+
+func (a *AzureIoTOperations) Write(metrics []telegraf.Metric) error {
+	rejected := make(map[int]error)
+	messages := make([]message, 0, len(metrics))
+	changed := make(map[string]bool)
+	for i, m := range metrics {
+		data := &templateData{Name: m.Name(), Tags: m.Tags()}
+		topic, err := execute(a.topic, data)
+		if err != nil {
+			rejected[i] = fmt.Errorf("creating topic failed: %w", err)
+			continue
+		}
+		if strings.ContainsAny(topic, "+#") {
+			rejected[i] = fmt.Errorf("invalid topic %q containing wildcards", topic)
+			continue
+		}
+
+		payload, err := serialize(m)
+		if err != nil {
+			rejected[i] = fmt.Errorf("serializing metric failed: %w", err)
+			continue
+		}
+
+		if a.registry != nil {
+			name, err := execute(a.schemaName, data)
+			if err != nil {
+				rejected[i] = fmt.Errorf("creating schema name failed: %w", err)
+				continue
+			}
+			s, found := a.schemas[name]
+			if !found {
+				s = newSchema()
+				a.schemas[name] = s
+			}
+			s.add(m)
+			changed[name] = true
+		}
+
+		messages = append(messages, message{index: i, topic: topic, payload: payload})
+	}
+
+	// Make sure the schemas are available in the registry before publishing
+	// data conforming to new schema versions
+	for name := range changed {
+		if err := a.register(name); err != nil {
+			return fmt.Errorf("registering schema %q failed: %w", name, err)
+		}
+	}
+
+	accepted := make(map[int]bool, len(messages))
+	for _, msg := range messages {
+		if err := a.client.Publish(msg.topic, msg.payload); err != nil {
+			// Keep the unpublished metrics for the next write
+			if len(accepted) == 0 && len(rejected) == 0 {
+				return fmt.Errorf("publishing to %q failed: %w", msg.topic, err)
+			}
+			a.Log.Debugf("Publishing to %q failed: %v, retrying with next flush...", msg.topic, err)
+			return a.partialWriteError(metrics, accepted, rejected)
+		}
+		accepted[msg.index] = true
+	}
+
+	if len(rejected) == 0 {
+		return nil
+	}
+	return a.partialWriteError(metrics, accepted, rejected)
+}
+
+func (a *AzureIoTOperations) partialWriteError(metrics []telegraf.Metric, accepted map[int]bool, rejected map[int]error) error {
+	werr := &internal.PartialWriteError{
+		Err: errors.New("writing some metrics failed"),
+	}
+	for i := range metrics {
+		if err, found := rejected[i]; found {
+			a.Log.Errorf("Dropping metric %q: %v", metrics[i].Name(), err)
+			werr.MetricsReject = append(werr.MetricsReject, i)
+			werr.MetricsRejectErrors = append(werr.MetricsRejectErrors, err)
+			continue
+		}
+		if accepted[i] {
+			werr.MetricsAccept = append(werr.MetricsAccept, i)
+		}
+	}
+	return werr
+}
+
+// register adds the current schema as new version if its content changed
+func (a *AzureIoTOperations) register(name string) error {
+	s := a.schemas[name]
+	content, err := s.content(name)
+	if err != nil {
+		return err
+	}
+	if content == s.registered {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(a.Timeout))
+	defer cancel()
+	version, err := a.registry.register(ctx, name, content)
+	if err != nil {
+		return err
+	}
+	a.Log.Debugf("Using version %s of schema %q", version, name)
+	s.registered = content
+
+	return nil
+}
+
+// authData returns the authentication data sent to the broker on connect
+func (a *AzureIoTOperations) authData() ([]byte, error) {
+	switch a.AuthMethod {
+	case "k8s-sat":
+		buf, err := os.ReadFile(a.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading token file failed: %w", err)
+		}
+		return bytes.TrimSpace(buf), nil
+	case "oauth2-jwt":
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(a.Timeout))
+		defer cancel()
+		token, err := a.credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{a.TokenScope}})
+		if err != nil {
+			return nil, fmt.Errorf("getting token failed: %w", err)
+		}
+		return []byte(token.Token), nil
+	}
+	return nil, fmt.Errorf("unsupported authentication method %q", a.AuthMethod)
+}
+
+func execute(tmpl *template.Template, data *templateData) (string, error) {
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	if buf.Len() == 0 {
+		return "", errors.New("empty result")
+	}
+	return buf.String(), nil
+}
+
+// serialize creates a flat JSON object containing the tags, fields and the
+// time of the metric
+func serialize(m telegraf.Metric) ([]byte, error) {
+	obj := make(map[string]interface{}, len(m.TagList())+len(m.FieldList())+1)
+	for _, tag := range m.TagList() {
+		obj[tag.Key] = tag.Value
+	}
+	for _, field := range m.FieldList() {
+		obj[field.Key] = field.Value
+	}
+	obj[timestampKey] = m.Time().UTC().Format(time.RFC3339Nano)
+	return json.Marshal(obj)
+}
+
+func init() {
+	outputs.Add("azure_iot_operations", func() telegraf.Output {
+		return &AzureIoTOperations{
+			Topic:      "azure-iot-operations/data/{{.Name}}",
+			TokenFile:  "/var/run/secrets/tokens/broker-sat",
+			TokenScope: "https://eventgrid.azure.net/.default",
+			MqttConfig: mqtt.MqttConfig{
+				Protocol:  "5",
+				KeepAlive: 30,
+				QoS:       1,
+				Timeout:   config.Duration(5 * time.Second),
+			},
+		}
+	})
+}
@@ -0,0 +1,497 @@
+package azure_iot_operations
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/common/mqtt"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+type published struct {
+	topic   string
+	payload string
+}
+
+type fakeClient struct {
+	cfg      *mqtt.MqttConfig
+	messages []published
+	failAt   int
+	closed   bool
+}
+
+func (*fakeClient) Connect() (bool, error) {
+	return false, nil
+}
+
+func (c *fakeClient) Publish(topic string, data []byte) error {
+	if c.failAt > 0 && len(c.messages)+1 >= c.failAt {
+		return errors.New("connection lost")
+	}
+	c.messages = append(c.messages, published{topic, string(data)})
+	return nil
+}
+
+func (*fakeClient) SubscribeMultiple(map[string]byte, paho.MessageHandler) error {
+	panic("not implemented")
+}
+
+func (*fakeClient) AddRoute(string, paho.MessageHandler) {
+	panic("not implemented")
+}
+
+func (c *fakeClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+type fakeCredential struct {
+	scopes []string
+}
+
+func (c *fakeCredential) GetToken(_ context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.scopes = append(c.scopes, options.Scopes...)
+	return azcore.AccessToken{Token: "entra-token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestInitFail(t *testing.T) {
+	servers := []string{"tcp://localhost:1883"}
+	tests := []struct {
+		name     string
+		plugin   *AzureIoTOperations
+		expected string
+	}{
+		{
+			name:     "no servers",
+			plugin:   &AzureIoTOperations{Topic: "data"},
+			expected: "no servers specified",
+		},
+		{
+			name: "unsupported protocol",
+			plugin: &AzureIoTOperations{
+				Topic:      "data",
+				MqttConfig: mqtt.MqttConfig{Servers: servers, Protocol: "3.1.1"},
+			},
+			expected: "unsupported protocol \"3.1.1\"",
+		},
+		{
+			name: "invalid topic",
+			plugin: &AzureIoTOperations{
+				Topic:      "data/{{.Name",
+				MqttConfig: mqtt.MqttConfig{Servers: servers},
+			},
+			expected: "parsing 'topic' template failed",
+		},
+		{
+			name: "invalid auth method",
+			plugin: &AzureIoTOperations{
+				Topic:      "data",
+				AuthMethod: "x509",
+				MqttConfig: mqtt.MqttConfig{Servers: servers},
+			},
+			expected: "invalid 'auth_method' \"x509\"",
+		},
+		{
+			name: "no token file",
+			plugin: &AzureIoTOperations{
+				Topic:      "data",
+				AuthMethod: "k8s-sat",
+				MqttConfig: mqtt.MqttConfig{Servers: servers},
+			},
+			expected: "missing 'token_file'",
+		},
+		{
+			name: "incomplete client credentials",
+			plugin: &AzureIoTOperations{
+				Topic:             "data",
+				AuthMethod:        "oauth2-jwt",
+				TokenScope:        "https://eventgrid.azure.net/.default",
+				AzureClientSecret: config.NewSecret([]byte("secret")),
+				MqttConfig:        mqtt.MqttConfig{Servers: servers},
+			},
+			expected: "'azure_client_secret' requires 'azure_tenant_id' and 'azure_client_id'",
+		},
+		{
+			name: "incomplete schema registry",
+			plugin: &AzureIoTOperations{
+				Topic:          "data",
+				SchemaRegistry: &SchemaRegistry{SubscriptionID: "sub"},
+				MqttConfig:     mqtt.MqttConfig{Servers: servers},
+			},
+			expected: "schema registry requires 'subscription_id', 'resource_group' and 'name'",
+		},
+		{
+			name: "invalid schema name",
+			plugin: &AzureIoTOperations{
+				Topic: "data",
+				SchemaRegistry: &SchemaRegistry{
+					SubscriptionID: "sub",
+					ResourceGroup:  "rg",
+					Name:           "registry",
+					SchemaName:     "{{.Tag",
+				},
+				MqttConfig: mqtt.MqttConfig{Servers: servers},
+			},
+			expected: "parsing 'schema_name' template failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var client *fakeClient
+	plugin := newPlugin(&client)
+	plugin.Topic = `azure-iot-operations/data/{{.Tag "asset"}}`
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	// The content type identifies the payload format
+	require.Equal(t, "5", client.cfg.Protocol)
+	require.Equal(t, "application/json", client.cfg.PublishPropertiesV5.ContentType)
+	require.Empty(t, client.cfg.AuthMethod)
+
+	ts := time.Unix(1700000000, 500000000)
+	metrics := []telegraf.Metric{
+		metric.New("boiler",
+			map[string]string{"asset": "boiler-1", "site": "plant1"},
+			map[string]interface{}{"temperature": 71.5, "running": true, "cycles": int64(42)},
+			ts,
+		),
+		metric.New("boiler",
+			map[string]string{"asset": "boiler-2"},
+			map[string]interface{}{"temperature": math.NaN()},
+			ts,
+		),
+		metric.New("boiler",
+			map[string]string{"asset": "boiler-#"},
+			map[string]interface{}{"temperature": 72.0},
+			ts,
+		),
+		metric.New("pump",
+			map[string]string{"asset": "pump-1"},
+			map[string]interface{}{"state": "running"},
+			ts,
+		),
+	}
+
+	var werr *internal.PartialWriteError
+	require.ErrorAs(t, plugin.Write(metrics), &werr)
+	require.Equal(t, []int{0, 3}, werr.MetricsAccept)
+	require.Equal(t, []int{1, 2}, werr.MetricsReject)
+
+	require.Len(t, client.messages, 2)
+	require.Equal(t, "azure-iot-operations/data/boiler-1", client.messages[0].topic)
+	require.JSONEq(t,
+		`{"asset":"boiler-1","site":"plant1","temperature":71.5,"running":true,"cycles":42,"timestamp":"2023-11-14T22:13:20.5Z"}`,
+		client.messages[0].payload,
+	)
+	require.Equal(t, "azure-iot-operations/data/pump-1", client.messages[1].topic)
+	require.JSONEq(t, `{"asset":"pump-1","state":"running","timestamp":"2023-11-14T22:13:20.5Z"}`, client.messages[1].payload)
+}
+
+func TestWritePublishFailure(t *testing.T) {
+	var client *fakeClient
+	plugin := newPlugin(&client)
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	ts := time.Unix(1700000000, 0)
+	metrics := []telegraf.Metric{
+		metric.New("a", map[string]string{}, map[string]interface{}{"value": 1.0}, ts),
+		metric.New("b", map[string]string{}, map[string]interface{}{"value": 2.0}, ts),
+		metric.New("c", map[string]string{}, map[string]interface{}{"value": 3.0}, ts),
+	}
+
+	// Nothing was published so the whole batch is retried
+	client.failAt = 1
+	require.ErrorContains(t, plugin.Write(metrics), "publishing to \"azure-iot-operations/data/a\" failed: connection lost")
+
+	// The published metrics are accepted and the remaining ones are kept
+	client.failAt = 2
+	var werr *internal.PartialWriteError
+	require.ErrorAs(t, plugin.Write(metrics), &werr)
+	require.Equal(t, []int{0}, werr.MetricsAccept)
+	require.Empty(t, werr.MetricsReject)
+}
+
+func TestAuthentication(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "broker-sat")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("sat-token\n"), 0600))
+
+	// Service account tokens are read from file on each connection attempt
+	var client *fakeClient
+	plugin := newPlugin(&client)
+	plugin.AuthMethod = "k8s-sat"
+	plugin.TokenFile = tokenFile
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	require.Equal(t, "K8S-SAT", client.cfg.AuthMethod)
+	data, err := client.cfg.AuthData()
+	require.NoError(t, err)
+	require.Equal(t, "sat-token", string(data))
+
+	require.NoError(t, os.WriteFile(tokenFile, []byte("rotated-token"), 0600))
+	data, err = client.cfg.AuthData()
+	require.NoError(t, err)
+	require.Equal(t, "rotated-token", string(data))
+	require.NoError(t, plugin.Close())
+
+	// Microsoft Entra ID tokens are requested for the configured scope
+	credential := &fakeCredential{}
+	plugin = newPlugin(&client)
+	plugin.AuthMethod = "oauth2-jwt"
+	plugin.TokenScope = "https://eventgrid.azure.net/.default"
+	plugin.credential = credential
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	require.Equal(t, "OAUTH2-JWT", client.cfg.AuthMethod)
+	data, err = client.cfg.AuthData()
+	require.NoError(t, err)
+	require.Equal(t, "entra-token", string(data))
+	require.Equal(t, []string{"https://eventgrid.azure.net/.default"}, credential.scopes)
+	require.NoError(t, plugin.Close())
+}
+
+func TestSchemaRegistry(t *testing.T) {
+	const base = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.DeviceRegistry/schemaRegistries/registry/schemas/"
+
+	var mu sync.Mutex
+	var requests []string
+	schemas := make(map[string]map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer entra-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("api-version") != registryAPIVersion {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"code":"InvalidApiVersion","message":"unsupported"}}`))
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+strings.TrimPrefix(r.URL.Path, base))
+
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, base), "/")
+		switch {
+		case r.Method == http.MethodPut && len(parts) == 1:
+			var resource schemaResource
+			if err := json.NewDecoder(r.Body).Decode(&resource); err != nil || resource.Properties.Format != "JsonSchema/draft-07" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			if _, found := schemas[parts[0]]; !found {
+				schemas[parts[0]] = make(map[string]string)
+			}
+			_, _ = w.Write([]byte(`{}`))
+		case r.Method == http.MethodGet && len(parts) == 2:
+			var list schemaVersionList
+			for name, content := range schemas[parts[0]] {
+				var v schemaVersion
+				v.Name = name
+				v.Properties.SchemaContent = content
+				list.Value = append(list.Value, v)
+			}
+			_ = json.NewEncoder(w).Encode(&list)
+		case r.Method == http.MethodPut && len(parts) == 3:
+			var v schemaVersion
+			if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			schemas[parts[0]][parts[2]] = v.Properties.SchemaContent
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	create := func() *AzureIoTOperations {
+		var client *fakeClient
+		plugin := newPlugin(&client)
+		plugin.credential = &fakeCredential{}
+		plugin.SchemaRegistry = &SchemaRegistry{
+			SubscriptionID: "sub",
+			ResourceGroup:  "rg",
+			Name:           "registry",
+			Endpoint:       server.URL,
+		}
+		return plugin
+	}
+
+	ts := time.Unix(1700000000, 0)
+	metrics := []telegraf.Metric{
+		metric.New("boiler", map[string]string{"site": "plant1"}, map[string]interface{}{"temperature": 71.5}, ts),
+		metric.New("boiler", map[string]string{"site": "plant1"}, map[string]interface{}{"temperature": int64(71)}, ts),
+	}
+	extended := []telegraf.Metric{
+		metric.New("boiler", map[string]string{"site": "plant1"}, map[string]interface{}{"running": true}, ts),
+	}
+
+	plugin := create()
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+
+	// The first write creates the schema and the initial version
+	require.NoError(t, plugin.Write(metrics))
+	expected := `{
+		"$schema": "http://json-schema.org/draft-07/schema#",
+		"title": "boiler",
+		"type": "object",
+		"properties": {
+			"site": {"type": "string"},
+			"temperature": {"type": ["integer", "number"]},
+			"timestamp": {"type": "string", "format": "date-time"}
+		},
+		"required": ["timestamp"]
+	}`
+	mu.Lock()
+	require.Equal(t, []string{"PUT boiler", "GET boiler/schemaVersions", "PUT boiler/schemaVersions/1"}, requests)
+	require.JSONEq(t, expected, schemas["boiler"]["1"])
+	requests = nil
+	mu.Unlock()
+
+	// Unchanged schemas are not registered again
+	require.NoError(t, plugin.Write(metrics))
+	mu.Lock()
+	require.Empty(t, requests)
+	mu.Unlock()
+
+	// New fields result in a new version
+	require.NoError(t, plugin.Write(extended))
+	mu.Lock()
+	require.Equal(t, []string{"PUT boiler", "GET boiler/schemaVersions", "PUT boiler/schemaVersions/2"}, requests)
+	require.Contains(t, schemas["boiler"]["2"], `"running":{"type":"boolean"}`)
+	requests = nil
+	mu.Unlock()
+	require.NoError(t, plugin.Close())
+
+	// Existing versions with identical content are reused after a restart
+	plugin = create()
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+	require.NoError(t, plugin.Write(metrics))
+	mu.Lock()
+	require.Equal(t, []string{"PUT boiler", "GET boiler/schemaVersions"}, requests)
+	require.Len(t, schemas["boiler"], 2)
+	mu.Unlock()
+}
+
+func TestSchemaRegistryFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"error":{"code":"AuthorizationFailed","message":"no write access"}}`))
+	}))
+	defer server.Close()
+
+	var client *fakeClient
+	plugin := newPlugin(&client)
+	plugin.credential = &fakeCredential{}
+	plugin.SchemaRegistry = &SchemaRegistry{
+		SubscriptionID: "sub",
+		ResourceGroup:  "rg",
+		Name:           "registry",
+		Endpoint:       server.URL,
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	// No data is published without a registered schema
+	metrics := []telegraf.Metric{
+		metric.New("boiler", map[string]string{}, map[string]interface{}{"temperature": 71.5}, time.Unix(1700000000, 0)),
+	}
+	require.ErrorContains(t, plugin.Write(metrics),
+		`registering schema "boiler" failed: creating schema failed: request failed with status 403 (AuthorizationFailed): no write access`)
+	require.Empty(t, client.messages)
+}
+
+// Make sure the version names are parsed as numbers when determining the
+// next version
+func TestNextVersion(t *testing.T) {
+	var mu sync.Mutex
+	var created string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == http.MethodGet:
+			var list schemaVersionList
+			for _, name := range []string{"2", "10", "9"} {
+				var v schemaVersion
+				v.Name = name
+				v.Properties.SchemaContent = `{"version":` + strconv.Quote(name) + `}`
+				list.Value = append(list.Value, v)
+			}
+			_ = json.NewEncoder(w).Encode(&list)
+		case strings.Contains(r.URL.Path, "/schemaVersions/"):
+			created = r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer server.Close()
+
+	r := &registry{
+		endpoint:       server.URL,
+		subscriptionID: "sub",
+		resourceGroup:  "rg",
+		name:           "registry",
+		credential:     &fakeCredential{},
+		client:         server.Client(),
+	}
+	version, err := r.register(t.Context(), "boiler", `{"type":"object"}`)
+	require.NoError(t, err)
+	require.Equal(t, "11", version)
+	mu.Lock()
+	require.Equal(t, "11", created)
+	mu.Unlock()
+}
+
+func newPlugin(client **fakeClient) *AzureIoTOperations {
+	return &AzureIoTOperations{
+		Topic:      "azure-iot-operations/data/{{.Name}}",
+		TokenScope: "https://eventgrid.azure.net/.default",
+		Log:        &testutil.Logger{},
+		MqttConfig: mqtt.MqttConfig{
+			Servers: []string{"tcp://localhost:1883"},
+			Timeout: config.Duration(5 * time.Second),
+		},
+		newClient: func(cfg *mqtt.MqttConfig) (mqtt.Client, error) {
+			*client = &fakeClient{cfg: cfg}
+			return *client, nil
+		},
+	}
+}
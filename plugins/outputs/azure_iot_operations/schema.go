@@ -0,0 +1,249 @@
+package azure_iot_operations
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+
+	"github.com/influxdata/telegraf"
+)
+
+// Version of the Azure Device Registry management API
+const registryAPIVersion = "2024-09-01-preview"
+
+// Scope of the token used to access the management API
+const registryScope = "https://management.azure.com/.default"
+
+// schema collects the JSON types of all properties published with a schema
+// name over the lifetime of the plugin
+type schema struct {
+	properties map[string]map[string]bool
+	registered string
+}
+
+func newSchema() *schema {
+	return &schema{properties: make(map[string]map[string]bool)}
+}
+
+// add merges the properties of the payload created for the metric
+func (s *schema) add(m telegraf.Metric) {
+	for _, tag := range m.TagList() {
+		s.addProperty(tag.Key, "string")
+	}
+	for _, field := range m.FieldList() {
+		s.addProperty(field.Key, jsonType(field.Value))
+	}
+}
+
+func (s *schema) addProperty(name, typ string) {
+	if name == timestampKey {
+		return
+	}
+	types, found := s.properties[name]
+	if !found {
+		types = make(map[string]bool, 1)
+		s.properties[name] = types
+	}
+	types[typ] = true
+}
+
+// content returns the JSON schema (draft-07) describing the payload
+func (s *schema) content(name string) (string, error) {
+	properties := make(map[string]interface{}, len(s.properties)+1)
+	properties[timestampKey] = map[string]string{"type": "string", "format": "date-time"}
+	for property, types := range s.properties {
+		keys := make([]string, 0, len(types))
+		for t := range types {
+			keys = append(keys, t)
+		}
+		slices.Sort(keys)
+		if len(keys) == 1 {
+			properties[property] = map[string]interface{}{"type": keys[0]}
+		} else {
+			properties[property] = map[string]interface{}{"type": keys}
+		}
+	}
+
+	doc := map[string]interface{}{
+		"$schema":    "http://json-schema.org/draft-07/schema#",
+		"title":      name,
+		"type":       "object",
+		"properties": properties,
+		"required":   []string{timestampKey},
+	}
+	buf, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case int64, uint64:
+		return "integer"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	}
+	return "string"
+}
+
+// registry registers the schemas in the schema registry of the Azure Device
+// Registry using the management API
+type registry struct {
+	endpoint       string
+	subscriptionID string
+	resourceGroup  string
+	name           string
+	credential     azcore.TokenCredential
+	client         *http.Client
+}
+
+type schemaResource struct {
+	Properties struct {
+		Format     string `json:"format,omitempty"`
+		SchemaType string `json:"schemaType,omitempty"`
+	} `json:"properties"`
+}
+
+type schemaVersion struct {
+	Name       string `json:"name,omitempty"`
+	Properties struct {
+		SchemaContent string `json:"schemaContent"`
+	} `json:"properties"`
+}
+
+type schemaVersionList struct {
+	Value    []schemaVersion `json:"value"`
+	NextLink string          `json:"nextLink"`
+}
+
+// register creates the schema if necessary and returns the version with the
+// given content, a new version is added if no such version exists
+func (r *registry) register(ctx context.Context, name, content string) (string, error) {
+	base := r.endpoint + "/subscriptions/" + url.PathEscape(r.subscriptionID) +
+		"/resourceGroups/" + url.PathEscape(r.resourceGroup) +
+		"/providers/Microsoft.DeviceRegistry/schemaRegistries/" + url.PathEscape(r.name) +
+		"/schemas/" + url.PathEscape(name)
+
+	var resource schemaResource
+	resource.Properties.Format = "JsonSchema/draft-07"
+	resource.Properties.SchemaType = "MessageSchema"
+	if err := r.do(ctx, http.MethodPut, base+"?api-version="+registryAPIVersion, &resource, nil); err != nil {
+		return "", fmt.Errorf("creating schema failed: %w", err)
+	}
+
+	// Reuse an existing version with identical content
+	var latest int
+	next := base + "/schemaVersions?api-version=" + registryAPIVersion
+	for next != "" {
+		var list schemaVersionList
+		if err := r.do(ctx, http.MethodGet, next, nil, &list); err != nil {
+			return "", fmt.Errorf("listing schema versions failed: %w", err)
+		}
+		for _, v := range list.Value {
+			if equalJSON(v.Properties.SchemaContent, content) {
+				return v.Name, nil
+			}
+			if n, err := strconv.Atoi(v.Name); err == nil && n > latest {
+				latest = n
+			}
+		}
+		next = list.NextLink
+	}
+
+	version := strconv.Itoa(latest + 1)
+	var v schemaVersion
+	v.Properties.SchemaContent = content
+	u := base + "/schemaVersions/" + version + "?api-version=" + registryAPIVersion
+	if err := r.do(ctx, http.MethodPut, u, &v, nil); err != nil {
+		return "", fmt.Errorf("creating schema version %s failed: %w", version, err)
+	}
+	return version, nil
+}
+
+func (r *registry) do(ctx context.Context, method, u string, body, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("encoding request failed: %w", err)
+		}
+		reader = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u, reader)
+	if err != nil {
+		return fmt.Errorf("creating request failed: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	token, err := r.credential.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{registryScope}})
+	if err != nil {
+		return fmt.Errorf("getting token failed: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token.Token)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return newAPIError(resp.StatusCode, data)
+	}
+
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("decoding response failed: %w", err)
+	}
+	return nil
+}
+
+// newAPIError extracts the message from the error response of the
+// management API
+func newAPIError(status int, body []byte) error {
+	var resp struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &resp); err == nil && resp.Error.Code != "" {
+		return fmt.Errorf("request failed with status %d (%s): %s", status, resp.Error.Code, resp.Error.Message)
+	}
+	return fmt.Errorf("request failed with status %d: %s", status, strings.TrimSpace(string(body)))
+}
+
+// equalJSON checks if both documents are equal ignoring the formatting
+func equalJSON(a, b string) bool {
+	var bufA, bufB bytes.Buffer
+	if err := json.Compact(&bufA, []byte(a)); err != nil {
+		return false
+	}
+	if err := json.Compact(&bufB, []byte(b)); err != nil {
+		return false
+	}
+	return bytes.Equal(bufA.Bytes(), bufB.Bytes())
+}
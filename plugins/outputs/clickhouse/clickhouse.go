@@ -0,0 +1,497 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package clickhouse
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	common_tls "github.com/influxdata/telegraf/plugins/common/tls"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+const defaultTableTemplate = "CREATE TABLE IF NOT EXISTS {TABLE} ({COLUMNS}) ENGINE = MergeTree " +
+	"PARTITION BY toYYYYMM({TIMESTAMP_COLUMN_NAME}) ORDER BY ({KEY_COLUMN_NAMES})"
+
+const (
+	tagColumnType       = "LowCardinality(String)"
+	timestampColumnType = "DateTime64(9, 'UTC')"
+)
+
+type ClickHouse struct {
+	Servers            []string        `toml:"servers"`
+	Database           string          `toml:"database"`
+	Username           config.Secret   `toml:"username"`
+	Password           config.Secret   `toml:"password"`
+	Compression        string          `toml:"compression"`
+	TimestampColumn    string          `toml:"timestamp_column"`
+	TableTemplate      string          `toml:"table_template"`
+	AsyncInsert        bool            `toml:"async_insert"`
+	WaitForAsyncInsert bool            `toml:"wait_for_async_insert"`
+	Timeout            config.Duration `toml:"timeout"`
+	Log                telegraf.Logger `toml:"-"`
+	common_tls.ClientConfig
+
+	compression clickhouse.CompressionMethod
+	conn        driver.Conn
+
+	// Cache of the known columns and their ClickHouse types per table
+	tables map[string]map[string]string
+}
+
+func (*ClickHouse) SampleConfig() string {
+	return sampleConfig
+}
+
+func (c *ClickHouse) Init() error {
+	if len(c.Servers) == 0 {
+		return errors.New("no servers specified")
+	}
+	if c.Database == "" {
+		return errors.New("database must not be empty")
+	}
+	if c.TimestampColumn == "" {
+		return errors.New("timestamp column must not be empty")
+	}
+
+	switch c.Compression {
+	case "", "lz4":
+		c.compression = clickhouse.CompressionLZ4
+	case "zstd":
+		c.compression = clickhouse.CompressionZSTD
+	case "none":
+		c.compression = clickhouse.CompressionNone
+	default:
+		return fmt.Errorf("invalid compression %q", c.Compression)
+	}
+
+	if c.TableTemplate == "" {
+		c.TableTemplate = defaultTableTemplate
+	}
+	if !strings.Contains(c.TableTemplate, "{TABLE}") || !strings.Contains(c.TableTemplate, "{COLUMNS}") {
+		return errors.New("table template must contain the {TABLE} and {COLUMNS} placeholders")
+	}
+
+	c.tables = make(map[string]map[string]string)
+
+	return nil
+}
+
+func (c *ClickHouse) Connect() error {
+	tlsCfg, err := c.ClientConfig.TLSConfig()
+	if err != nil {
+		return fmt.Errorf("creating TLS config failed: %w", err)
+	}
+
+	username, err := c.Username.Get()
+	if err != nil {
+		return fmt.Errorf("getting username failed: %w", err)
+	}
+	defer username.Destroy()
+	password, err := c.Password.Get()
+	if err != nil {
+		return fmt.Errorf("getting password failed: %w", err)
+	}
+	defer password.Destroy()
+
+	conn, err := clickhouse.Open(&clickhouse.Options{
+		Addr: c.Servers,
+		Auth: clickhouse.Auth{
+			Database: c.Database,
+			Username: username.String(),
+			Password: password.String(),
+		},
+		TLS:         tlsCfg,
+		Compression: &clickhouse.Compression{Method: c.compression},
+		DialTimeout: time.Duration(c.Timeout),
+		ReadTimeout: time.Duration(c.Timeout),
+		ClientInfo: clickhouse.ClientInfo{
+			Products: []struct{ Name, Version string }{
+				{Name: "telegraf", Version: internal.Version},
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("opening connection failed: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(c.Timeout))
+	defer cancel()
+	if err := conn.Ping(ctx); err != nil {
+		conn.Close()
+		return fmt.Errorf("connecting to server failed: %w", err)
+	}
+	c.conn = conn
+
+	return nil
+}
+
+func (c *ClickHouse) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func (c *ClickHouse) Write(metrics []telegraf.Metric) error {
+	// Group the metrics per table keeping the metric indices for reporting
+	// partial writes
+	var tables []string
+	batches := make(map[string][]int)
+	for i, m := range metrics {
+		if _, found := batches[m.Name()]; !found {
+			tables = append(tables, m.Name())
+		}
+		batches[m.Name()] = append(batches[m.Name()], i)
+	}
+
+	ctx := context.Background()
+	if c.AsyncInsert {
+		ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+			"async_insert":          1,
+			"wait_for_async_insert": boolSetting(c.WaitForAsyncInsert),
+		}))
+	}
+
+	accepted := make([]int, 0, len(metrics))
+	var errs []error
+	for _, table := range tables {
+		batch := make([]telegraf.Metric, 0, len(batches[table]))
+		for _, idx := range batches[table] {
+			batch = append(batch, metrics[idx])
+		}
+		if err := c.writeTable(ctx, table, batch); err != nil {
+			errs = append(errs, fmt.Errorf("writing to table %q failed: %w", table, err))
+			continue
+		}
+		accepted = append(accepted, batches[table]...)
+	}
+
+	switch {
+	case len(errs) == 0:
+		return nil
+	case len(accepted) == 0:
+		return errors.Join(errs...)
+	}
+	slices.Sort(accepted)
+
+	return &internal.PartialWriteError{
+		Err:           errors.Join(errs...),
+		MetricsAccept: accepted,
+	}
+}
+
+func (c *ClickHouse) writeTable(ctx context.Context, table string, metrics []telegraf.Metric) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(c.Timeout))
+	defer cancel()
+
+	columns, wanted, err := c.ensureTable(ctx, table, metrics)
+	if err != nil {
+		return err
+	}
+
+	// Insert all columns present in the given metrics in the order collected
+	quoted := make([]string, 0, len(wanted))
+	for _, col := range wanted {
+		quoted = append(quoted, quoteIdent(col.name))
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s)", quoteIdent(table), strings.Join(quoted, ", "))
+
+	batch, err := c.conn.PrepareBatch(ctx, query)
+	if err != nil {
+		return fmt.Errorf("preparing batch failed: %w", err)
+	}
+	defer batch.Abort() //nolint:errcheck // no-op if the batch was sent
+
+	row := make([]any, len(wanted))
+	for _, m := range metrics {
+		for i, col := range wanted {
+			var v any
+			var found bool
+			switch {
+			case col.name == c.TimestampColumn:
+				v, found = m.Time(), true
+			case col.tag:
+				v, found = m.GetTag(col.name)
+			default:
+				v, found = m.GetField(col.name)
+			}
+			if !found {
+				row[i] = nil
+				continue
+			}
+			cv, err := convert(v, columns[col.name])
+			if err != nil {
+				c.Log.Debugf("Cannot convert %q of metric %q for column %q: %v", col.name, m.Name(), columns[col.name], err)
+				cv = nil
+			}
+			row[i] = cv
+		}
+		if err := batch.Append(row...); err != nil {
+			return fmt.Errorf("appending metric failed: %w", err)
+		}
+	}
+
+	if err := batch.Send(); err != nil {
+		return fmt.Errorf("sending batch failed: %w", err)
+	}
+	return nil
+}
+
+// column describes a column required for storing a set of metrics
+type column struct {
+	name    string
+	tag     bool
+	sqlType string
+}
+
+// ensureTable makes sure the table exists and contains all columns required
+// for the given metrics. The function returns the types of all columns of the
+// table and the columns required by the metrics in insertion order.
+func (c *ClickHouse) ensureTable(ctx context.Context, table string, metrics []telegraf.Metric) (map[string]string, []column, error) {
+	wanted := c.columns(metrics)
+
+	existing, found := c.tables[table]
+	if !found {
+		var err error
+		if existing, err = c.loadColumns(ctx, table); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if len(existing) == 0 {
+		if err := c.conn.Exec(ctx, c.createTableQuery(table, wanted)); err != nil {
+			return nil, nil, fmt.Errorf("creating table failed: %w", err)
+		}
+		c.Log.Debugf("Created table %q", table)
+
+		var err error
+		if existing, err = c.loadColumns(ctx, table); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var altered bool
+	for _, col := range wanted {
+		if _, found := existing[col.name]; found {
+			continue
+		}
+		query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS %s %s", quoteIdent(table), quoteIdent(col.name), col.sqlType)
+		if err := c.conn.Exec(ctx, query); err != nil {
+			return nil, nil, fmt.Errorf("adding column %q failed: %w", col.name, err)
+		}
+		c.Log.Debugf("Added column %q to table %q", col.name, table)
+		altered = true
+	}
+	if altered {
+		var err error
+		if existing, err = c.loadColumns(ctx, table); err != nil {
+			return nil, nil, err
+		}
+	}
+	c.tables[table] = existing
+
+	// Skip columns still missing e.g. due to eventually consistent replicas,
+	// they will be picked up in the next write
+	filtered := make([]column, 0, len(wanted))
+	for _, col := range wanted {
+		if _, found := existing[col.name]; found {
+			filtered = append(filtered, col)
+		}
+	}
+
+	return existing, filtered, nil
+}
+
+func (c *ClickHouse) loadColumns(ctx context.Context, table string) (map[string]string, error) {
+	rows, err := c.conn.Query(ctx, "SELECT name, type FROM system.columns WHERE database = ? AND table = ?", c.Database, table)
+	if err != nil {
+		return nil, fmt.Errorf("querying columns failed: %w", err)
+	}
+	defer rows.Close()
+
+	columns := make(map[string]string)
+	for rows.Next() {
+		var name, sqlType string
+		if err := rows.Scan(&name, &sqlType); err != nil {
+			return nil, fmt.Errorf("reading columns failed: %w", err)
+		}
+		columns[name] = sqlType
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading columns failed: %w", err)
+	}
+	return columns, nil
+}
+
+// columns collects the timestamp, tag and field columns of the given metrics in
+// this order with tags and fields sorted by name. Fields with unsupported types
+// are ignored and the first type seen wins for fields with conflicting types.
+func (c *ClickHouse) columns(metrics []telegraf.Metric) []column {
+	seen := map[string]bool{c.TimestampColumn: true}
+
+	var tags []column
+	for _, m := range metrics {
+		for _, tag := range m.TagList() {
+			if seen[tag.Key] {
+				continue
+			}
+			seen[tag.Key] = true
+			tags = append(tags, column{name: tag.Key, tag: true, sqlType: tagColumnType})
+		}
+	}
+
+	var fields []column
+	for _, m := range metrics {
+		for _, field := range m.FieldList() {
+			if seen[field.Key] {
+				continue
+			}
+			sqlType := fieldType(field.Value)
+			if sqlType == "" {
+				c.Log.Debugf("Ignoring field %q of metric %q with unsupported type %T", field.Key, m.Name(), field.Value)
+				continue
+			}
+			seen[field.Key] = true
+			fields = append(fields, column{name: field.Key, sqlType: sqlType})
+		}
+	}
+
+	byName := func(a, b column) int { return strings.Compare(a.name, b.name) }
+	slices.SortFunc(tags, byName)
+	slices.SortFunc(fields, byName)
+
+	columns := make([]column, 0, 1+len(tags)+len(fields))
+	columns = append(columns, column{name: c.TimestampColumn, sqlType: timestampColumnType})
+	columns = append(columns, tags...)
+	return append(columns, fields...)
+}
+
+func (c *ClickHouse) createTableQuery(table string, columns []column) string {
+	definitions := make([]string, 0, len(columns))
+	keys := make([]string, 0, len(columns))
+	tags := make([]string, 0, len(columns))
+	for _, col := range columns {
+		definitions = append(definitions, quoteIdent(col.name)+" "+col.sqlType)
+		if col.tag {
+			tags = append(tags, quoteIdent(col.name))
+			keys = append(keys, quoteIdent(col.name))
+		}
+	}
+	keys = append(keys, quoteIdent(c.TimestampColumn))
+
+	query := c.TableTemplate
+	query = strings.ReplaceAll(query, "{TABLE}", quoteIdent(table))
+	query = strings.ReplaceAll(query, "{COLUMNS}", strings.Join(definitions, ", "))
+	query = strings.ReplaceAll(query, "{TAG_COLUMN_NAMES}", strings.Join(tags, ", "))
+	query = strings.ReplaceAll(query, "{KEY_COLUMN_NAMES}", strings.Join(keys, ", "))
+	query = strings.ReplaceAll(query, "{TIMESTAMP_COLUMN_NAME}", quoteIdent(c.TimestampColumn))
+	return query
+}
+
+func fieldType(v interface{}) string {
+	switch v.(type) {
+	case int64:
+		return "Nullable(Int64)"
+	case uint64:
+		return "Nullable(UInt64)"
+	case float64:
+		return "Nullable(Float64)"
+	case string:
+		return "Nullable(String)"
+	case bool:
+		return "Nullable(Bool)"
+	}
+	return ""
+}
+
+// convert the given value to the Go type matching the given ClickHouse type
+func convert(v interface{}, sqlType string) (interface{}, error) {
+	base := unwrapType(sqlType)
+	switch {
+	case base == "String":
+		return internal.ToString(v)
+	case base == "Bool":
+		return internal.ToBool(v)
+	case base == "Float64":
+		return internal.ToFloat64(v)
+	case base == "Float32":
+		return internal.ToFloat32(v)
+	case base == "Int64":
+		return internal.ToInt64(v)
+	case base == "Int32":
+		return internal.ToInt32(v)
+	case base == "Int16":
+		return internal.ToInt16(v)
+	case base == "Int8":
+		return internal.ToInt8(v)
+	case base == "UInt64":
+		return internal.ToUint64(v)
+	case base == "UInt32":
+		return internal.ToUint32(v)
+	case base == "UInt16":
+		return internal.ToUint16(v)
+	case base == "UInt8":
+		return internal.ToUint8(v)
+	case strings.HasPrefix(base, "DateTime"):
+		if t, ok := v.(time.Time); ok {
+			return t, nil
+		}
+		return nil, fmt.Errorf("cannot convert %T to time", v)
+	}
+	return nil, fmt.Errorf("unsupported column type %q", sqlType)
+}
+
+// unwrapType strips the LowCardinality and Nullable modifiers from the type
+func unwrapType(sqlType string) string {
+	for {
+		switch {
+		case strings.HasPrefix(sqlType, "LowCardinality(") && strings.HasSuffix(sqlType, ")"):
+			sqlType = strings.TrimSuffix(strings.TrimPrefix(sqlType, "LowCardinality("), ")")
+		case strings.HasPrefix(sqlType, "Nullable(") && strings.HasSuffix(sqlType, ")"):
+			sqlType = strings.TrimSuffix(strings.TrimPrefix(sqlType, "Nullable("), ")")
+		default:
+			return sqlType
+		}
+	}
+}
+
+func quoteIdent(name string) string {
+	r := strings.NewReplacer("\\", "\\\\", "`", "\\`")
+	return "`" + r.Replace(name) + "`"
+}
+
+func boolSetting(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func init() {
+	outputs.Add("clickhouse", func() telegraf.Output {
+		return &ClickHouse{
+			Servers:            []string{"localhost:9000"},
+			Database:           "default",
+			TimestampColumn:    "timestamp",
+			AsyncInsert:        true,
+			WaitForAsyncInsert: true,
+			Timeout:            config.Duration(5 * time.Second),
+		}
+	})
+}
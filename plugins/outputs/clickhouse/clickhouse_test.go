@@ -0,0 +1,393 @@
+package clickhouse
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/docker/go-connections/nat"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/outputs"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *ClickHouse
+		expected string
+	}{
+		{
+			name:     "no servers",
+			plugin:   &ClickHouse{Database: "default", TimestampColumn: "timestamp"},
+			expected: "no servers specified",
+		},
+		{
+			name:     "empty database",
+			plugin:   &ClickHouse{Servers: []string{"localhost:9000"}, TimestampColumn: "timestamp"},
+			expected: "database must not be empty",
+		},
+		{
+			name:     "empty timestamp column",
+			plugin:   &ClickHouse{Servers: []string{"localhost:9000"}, Database: "default"},
+			expected: "timestamp column must not be empty",
+		},
+		{
+			name: "invalid compression",
+			plugin: &ClickHouse{
+				Servers:         []string{"localhost:9000"},
+				Database:        "default",
+				TimestampColumn: "timestamp",
+				Compression:     "gzip",
+			},
+			expected: `invalid compression "gzip"`,
+		},
+		{
+			name: "template without columns",
+			plugin: &ClickHouse{
+				Servers:         []string{"localhost:9000"},
+				Database:        "default",
+				TimestampColumn: "timestamp",
+				TableTemplate:   "CREATE TABLE {TABLE}",
+			},
+			expected: "table template must contain the {TABLE} and {COLUMNS} placeholders",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	creator, found := outputs.Outputs["clickhouse"]
+	require.True(t, found)
+
+	plugin := creator().(*ClickHouse)
+	plugin.Log = testutil.Logger{}
+	require.NoError(t, plugin.Init())
+	require.Equal(t, []string{"localhost:9000"}, plugin.Servers)
+	require.Equal(t, "default", plugin.Database)
+	require.Equal(t, defaultTableTemplate, plugin.TableTemplate)
+	require.True(t, plugin.AsyncInsert)
+	require.True(t, plugin.WaitForAsyncInsert)
+}
+
+func TestWriteCreateTable(t *testing.T) {
+	conn := newFakeConn()
+	plugin := newPlugin(conn)
+
+	metrics := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "a", "cpu": "cpu0"},
+			map[string]interface{}{"usage": 42.5, "count": int64(3)},
+			time.Unix(1700000000, 0),
+		),
+		metric.New(
+			"cpu",
+			map[string]string{"host": "b"},
+			map[string]interface{}{"usage": 23.0, "ok": true},
+			time.Unix(1700000010, 0),
+		),
+	}
+	require.NoError(t, plugin.Write(metrics))
+
+	expectedQueries := []string{
+		"CREATE TABLE IF NOT EXISTS `cpu` (`timestamp` DateTime64(9, 'UTC'), `cpu` LowCardinality(String), " +
+			"`host` LowCardinality(String), `count` Nullable(Int64), `ok` Nullable(Bool), `usage` Nullable(Float64)) " +
+			"ENGINE = MergeTree PARTITION BY toYYYYMM(`timestamp`) ORDER BY (`cpu`, `host`, `timestamp`)",
+	}
+	require.Equal(t, expectedQueries, conn.execs)
+
+	require.Len(t, conn.batches, 1)
+	batch := conn.batches[0]
+	require.Equal(t, "INSERT INTO `cpu` (`timestamp`, `cpu`, `host`, `count`, `ok`, `usage`)", batch.query)
+	require.True(t, batch.sent)
+	expectedRows := [][]any{
+		{time.Unix(1700000000, 0), "cpu0", "a", int64(3), nil, 42.5},
+		{time.Unix(1700000010, 0), nil, "b", nil, true, 23.0},
+	}
+	require.Equal(t, expectedRows, batch.rows)
+
+	// A second write must use the cached schema
+	require.NoError(t, plugin.Write(metrics[:1]))
+	require.Len(t, conn.execs, 1)
+	require.Equal(t, 2, conn.queries)
+}
+
+func TestWriteAddColumns(t *testing.T) {
+	conn := newFakeConn()
+	conn.tables["mem"] = map[string]string{
+		"timestamp": "DateTime64(3)",
+		"host":      "String",
+		"used":      "Nullable(Float32)",
+	}
+	plugin := newPlugin(conn)
+
+	metrics := []telegraf.Metric{
+		metric.New(
+			"mem",
+			map[string]string{"host": "a", "region": "eu"},
+			map[string]interface{}{"used": int64(1024), "free": uint64(2048)},
+			time.Unix(1700000000, 0),
+		),
+	}
+	require.NoError(t, plugin.Write(metrics))
+
+	expectedQueries := []string{
+		"ALTER TABLE `mem` ADD COLUMN IF NOT EXISTS `region` LowCardinality(String)",
+		"ALTER TABLE `mem` ADD COLUMN IF NOT EXISTS `free` Nullable(UInt64)",
+	}
+	require.Equal(t, expectedQueries, conn.execs)
+
+	require.Len(t, conn.batches, 1)
+	batch := conn.batches[0]
+	require.Equal(t, "INSERT INTO `mem` (`timestamp`, `host`, `region`, `free`, `used`)", batch.query)
+	expectedRows := [][]any{
+		{time.Unix(1700000000, 0), "a", "eu", uint64(2048), float32(1024)},
+	}
+	require.Equal(t, expectedRows, batch.rows)
+}
+
+func TestWritePartial(t *testing.T) {
+	conn := newFakeConn()
+	conn.failing = map[string]bool{"mem": true}
+	plugin := newPlugin(conn)
+
+	metrics := []telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(0, 0)),
+		metric.New("mem", map[string]string{}, map[string]interface{}{"value": 2.0}, time.Unix(0, 0)),
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 3.0}, time.Unix(0, 0)),
+	}
+	err := plugin.Write(metrics)
+	require.ErrorContains(t, err, `writing to table "mem" failed`)
+
+	var werr *internal.PartialWriteError
+	require.ErrorAs(t, err, &werr)
+	require.Equal(t, []int{0, 2}, werr.MetricsAccept)
+	require.Empty(t, werr.MetricsReject)
+
+	// All tables failing should result in a plain error
+	conn.failing["cpu"] = true
+	err = plugin.Write(metrics)
+	require.Error(t, err)
+	require.NotErrorAs(t, err, &werr)
+}
+
+func TestIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	password := testutil.GetRandomString(32)
+	servicePort := "9000"
+	container := testutil.Container{
+		Image:        "clickhouse",
+		ExposedPorts: []string{servicePort, "8123"},
+		Env: map[string]string{
+			"CLICKHOUSE_USER":     "telegraf",
+			"CLICKHOUSE_PASSWORD": password,
+		},
+		WaitingFor: wait.ForAll(
+			wait.NewHTTPStrategy("/").WithPort(nat.Port("8123")),
+			wait.ForListeningPort(nat.Port(servicePort)),
+			wait.ForLog("Ready for connections"),
+		),
+	}
+	require.NoError(t, container.Start(), "failed to start container")
+	defer container.Terminate()
+
+	plugin := &ClickHouse{
+		Servers:            []string{container.Address + ":" + container.Ports[servicePort]},
+		Database:           "default",
+		Username:           config.NewSecret([]byte("telegraf")),
+		Password:           config.NewSecret([]byte(password)),
+		TimestampColumn:    "timestamp",
+		AsyncInsert:        true,
+		WaitForAsyncInsert: true,
+		Timeout:            config.Duration(10 * time.Second),
+		Log:                testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	metrics := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "a"},
+			map[string]interface{}{"usage": 42.5, "count": int64(3), "state": "ok"},
+			time.Unix(1700000000, 123456789),
+		),
+		metric.New(
+			"cpu",
+			map[string]string{"host": "b", "cpu": "cpu0"},
+			map[string]interface{}{"usage": 23.0, "healthy": true},
+			time.Unix(1700000010, 0),
+		),
+	}
+	require.NoError(t, plugin.Write(metrics[:1]))
+	require.NoError(t, plugin.Write(metrics[1:]))
+
+	rows, err := plugin.conn.Query(context.Background(),
+		"SELECT timestamp, host, cpu, usage, count, state, healthy FROM cpu ORDER BY timestamp")
+	require.NoError(t, err)
+	defer rows.Close()
+
+	var actual []string
+	for rows.Next() {
+		var ts time.Time
+		var host, cpu string
+		var usage *float64
+		var count *int64
+		var state *string
+		var healthy *bool
+		require.NoError(t, rows.Scan(&ts, &host, &cpu, &usage, &count, &state, &healthy))
+		actual = append(actual, fmt.Sprintf("%d %s %q %v %v %v %v",
+			ts.UnixNano(), host, cpu, deref(usage), deref(count), deref(state), deref(healthy)))
+	}
+	require.NoError(t, rows.Err())
+
+	expected := []string{
+		`1700000000123456789 a "" 42.5 3 ok <nil>`,
+		`1700000010000000000 b "cpu0" 23 <nil> <nil> true`,
+	}
+	require.Equal(t, expected, actual)
+}
+
+func newPlugin(conn driver.Conn) *ClickHouse {
+	plugin := &ClickHouse{
+		Servers:         []string{"localhost:9000"},
+		Database:        "default",
+		TimestampColumn: "timestamp",
+		Timeout:         config.Duration(time.Second),
+		Log:             testutil.Logger{},
+	}
+	if err := plugin.Init(); err != nil {
+		panic(err)
+	}
+	plugin.conn = conn
+	return plugin
+}
+
+func deref[T any](v *T) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+var columnDefinition = regexp.MustCompile("`([^`]+)` (LowCardinality\\(String\\)|Nullable\\(\\w+\\)|DateTime64\\(9, 'UTC'\\))")
+
+// fakeConn emulates the schema handling of a ClickHouse server and records
+// the executed statements and inserted batches
+type fakeConn struct {
+	driver.Conn
+	tables  map[string]map[string]string
+	failing map[string]bool
+	execs   []string
+	queries int
+	batches []*fakeBatch
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{tables: make(map[string]map[string]string)}
+}
+
+func (c *fakeConn) Exec(_ context.Context, query string, _ ...any) error {
+	c.execs = append(c.execs, query)
+
+	// Strip the statement up to the table name and collect the column
+	// definitions from the remainder
+	parts := strings.SplitN(query, "`", 3)
+	table := parts[1]
+	if c.tables[table] == nil {
+		c.tables[table] = make(map[string]string)
+	}
+	for _, match := range columnDefinition.FindAllStringSubmatch(parts[2], -1) {
+		c.tables[table][match[1]] = match[2]
+	}
+	return nil
+}
+
+func (c *fakeConn) Query(_ context.Context, _ string, args ...any) (driver.Rows, error) {
+	c.queries++
+
+	table := args[1].(string)
+	rows := &fakeRows{}
+	for name, sqlType := range c.tables[table] {
+		rows.values = append(rows.values, [2]string{name, sqlType})
+	}
+	return rows, nil
+}
+
+func (c *fakeConn) PrepareBatch(_ context.Context, query string, _ ...driver.PrepareBatchOption) (driver.Batch, error) {
+	table := strings.Trim(strings.Fields(query)[2], "`")
+	if c.failing[table] {
+		return nil, errors.New("table is read-only")
+	}
+	batch := &fakeBatch{query: query}
+	c.batches = append(c.batches, batch)
+	return batch, nil
+}
+
+type fakeRows struct {
+	driver.Rows
+	values [][2]string
+	idx    int
+}
+
+func (r *fakeRows) Next() bool {
+	r.idx++
+	return r.idx <= len(r.values)
+}
+
+func (r *fakeRows) Scan(dest ...any) error {
+	*dest[0].(*string) = r.values[r.idx-1][0]
+	*dest[1].(*string) = r.values[r.idx-1][1]
+	return nil
+}
+
+func (*fakeRows) Err() error {
+	return nil
+}
+
+func (*fakeRows) Close() error {
+	return nil
+}
+
+type fakeBatch struct {
+	driver.Batch
+	query string
+	rows  [][]any
+	sent  bool
+}
+
+func (b *fakeBatch) Append(v ...any) error {
+	b.rows = append(b.rows, append([]any(nil), v...))
+	return nil
+}
+
+func (b *fakeBatch) Send() error {
+	b.sent = true
+	return nil
+}
+
+func (*fakeBatch) Abort() error {
+	return nil
+}
@@ -0,0 +1,171 @@
+package iot_sitewise
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// Service name used for signing the requests
+const signingName = "iotsitewise"
+
+// Error codes indicating a temporary problem, the affected values are kept
+// for retrying the write
+var retryableErrorCodes = []string{
+	"ConflictingOperationException",
+	"InternalFailureException",
+	"LimitExceededException",
+	"ServiceUnavailableException",
+	"ThrottlingException",
+}
+
+// Request and response of the BatchPutAssetPropertyValue API, see
+// https://docs.aws.amazon.com/iot-sitewise/latest/APIReference/API_BatchPutAssetPropertyValue.html
+type batchRequest struct {
+	Entries []*entry `json:"entries"`
+}
+
+type entry struct {
+	EntryID        string           `json:"entryId"`
+	PropertyAlias  string           `json:"propertyAlias"`
+	PropertyValues []*propertyValue `json:"propertyValues"`
+
+	// Index of the originating metric for each value
+	origin []int
+}
+
+type propertyValue struct {
+	Value     variant   `json:"value"`
+	Timestamp timestamp `json:"timestamp"`
+	Quality   string    `json:"quality,omitempty"`
+}
+
+type variant struct {
+	DoubleValue  *float64 `json:"doubleValue,omitempty"`
+	IntegerValue *int32   `json:"integerValue,omitempty"`
+	BooleanValue *bool    `json:"booleanValue,omitempty"`
+	StringValue  *string  `json:"stringValue,omitempty"`
+}
+
+type timestamp struct {
+	TimeInSeconds int64 `json:"timeInSeconds"`
+	OffsetInNanos int64 `json:"offsetInNanos"`
+}
+
+type batchResponse struct {
+	ErrorEntries []errorEntry `json:"errorEntries"`
+}
+
+type errorEntry struct {
+	EntryID string `json:"entryId"`
+	Errors  []struct {
+		ErrorCode    string `json:"errorCode"`
+		ErrorMessage string `json:"errorMessage"`
+	} `json:"errors"`
+}
+
+// apiError is returned if the service rejected the whole request
+type apiError struct {
+	StatusCode int
+	Code       string
+	Message    string
+}
+
+func (e *apiError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("request failed with status %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("request failed with status %d (%s): %s", e.StatusCode, e.Code, e.Message)
+}
+
+// retryable checks if the request failed due to throttling or a temporary
+// problem of the service
+func (e *apiError) retryable() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500 || slices.Contains(retryableErrorCodes, e.Code)
+}
+
+// invalid checks if the content of the request was rejected, e.g. due to an
+// unknown property alias, so sending the same values again will fail as well
+func (e *apiError) invalid() bool {
+	return e.StatusCode == http.StatusBadRequest || e.StatusCode == http.StatusNotFound
+}
+
+// client sends signed requests to the IoT SiteWise data plane.
+// TODO: Replace by the client of github.com/aws/aws-sdk-go-v2/service/iotsitewise
+// as done for the other AWS plugins once the module is added as dependency.
+type client struct {
+	endpoint    string
+	region      string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	client      *http.Client
+}
+
+// put sends the entries using the BatchPutAssetPropertyValue API
+func (c *client) put(ctx context.Context, entries []*entry) (*batchResponse, error) {
+	body, err := json.Marshal(&batchRequest{Entries: entries})
+	if err != nil {
+		return nil, fmt.Errorf("encoding request failed: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.endpoint+"/properties", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("creating request failed: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	creds, err := c.credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving credentials failed: %w", err)
+	}
+	hash := sha256.Sum256(body)
+	if err := c.signer.SignHTTP(ctx, creds, req, hex.EncodeToString(hash[:]), signingName, c.region, time.Now().UTC()); err != nil {
+		return nil, fmt.Errorf("signing request failed: %w", err)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, newAPIError(resp, data)
+	}
+
+	var result batchResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("decoding response failed: %w", err)
+	}
+	return &result, nil
+}
+
+func newAPIError(resp *http.Response, body []byte) *apiError {
+	// The error type header has the form "<code>:<documentation url>"
+	code, _, _ := strings.Cut(resp.Header.Get("X-Amzn-ErrorType"), ":")
+	// The message key is matched case-insensitive as the capitalization
+	// differs between the error types
+	var msg struct {
+		Message string `json:"message"`
+	}
+	message := strings.TrimSpace(string(body))
+	if err := json.Unmarshal(body, &msg); err == nil && msg.Message != "" {
+		message = msg.Message
+	}
+	return &apiError{StatusCode: resp.StatusCode, Code: code, Message: message}
+}
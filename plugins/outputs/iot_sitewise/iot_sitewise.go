@@ -0,0 +1,313 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package iot_sitewise
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/choice"
+	common_aws "github.com/influxdata/telegraf/plugins/common/aws"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Limits of the BatchPutAssetPropertyValue API
+const (
+	maxEntriesPerRequest = 10
+	maxValuesPerEntry    = 10
+)
+
+var qualities = []string{"GOOD", "BAD", "UNCERTAIN"}
+
+type IoTSiteWise struct {
+	PropertyAlias string          `toml:"property_alias"`
+	QualityTag    string          `toml:"quality_tag"`
+	Quality       string          `toml:"quality"`
+	MaxRetries    int             `toml:"max_retries"`
+	RetryDelay    config.Duration `toml:"retry_delay"`
+	Timeout       config.Duration `toml:"timeout"`
+	Log           telegraf.Logger `toml:"-"`
+	common_aws.CredentialConfig
+
+	template *template.Template
+	client   *client
+}
+
+// alias provides the metric data to the property alias template
+type alias struct {
+	Name  string
+	Field string
+	Tags  map[string]string
+}
+
+// Tag returns the value of the given tag or an empty string
+func (a *alias) Tag(key string) string {
+	return a.Tags[key]
+}
+
+func (*IoTSiteWise) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *IoTSiteWise) Init() error {
+	if s.Region == "" {
+		return errors.New("missing 'region'")
+	}
+	if s.PropertyAlias == "" {
+		return errors.New("missing 'property_alias'")
+	}
+	tmpl, err := template.New("property_alias").Parse(s.PropertyAlias)
+	if err != nil {
+		return fmt.Errorf("parsing 'property_alias' template failed: %w", err)
+	}
+	s.template = tmpl
+
+	if s.Quality == "" {
+		s.Quality = "GOOD"
+	}
+	if err := choice.Check(s.Quality, qualities); err != nil {
+		return fmt.Errorf("invalid 'quality': %w", err)
+	}
+	if s.MaxRetries < 0 {
+		return errors.New("'max_retries' must not be negative")
+	}
+
+	return nil
+}
+
+func (s *IoTSiteWise) Connect() error {
+	cfg, err := s.CredentialConfig.Credentials()
+	if err != nil {
+		return fmt.Errorf("loading credentials failed: %w", err)
+	}
+
+	endpoint := s.EndpointURL
+	if endpoint == "" {
+		endpoint = "https://data.iotsitewise." + s.Region + ".amazonaws.com"
+	}
+	s.client = &client{
+		endpoint:    strings.TrimSuffix(endpoint, "/"),
+		region:      s.Region,
+		credentials: cfg.Credentials,
+		signer:      v4.NewSigner(),
+		client:      &http.Client{Timeout: time.Duration(s.Timeout)},
+	}
+
+	return nil
+}
+
+func (s *IoTSiteWise) Close() error {
+	if s.client != nil {
+		s.client.client.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (s *IoTSiteWise) Write(metrics []telegraf.Metric) error {
+	rejected := make(map[int]error)
+	entries := s.entries(metrics, rejected)
+
+	retry := make(map[int]bool)
+	for start := 0; start < len(entries); start += maxEntriesPerRequest {
+		batch := entries[start:min(start+maxEntriesPerRequest, len(entries))]
+		for i, e := range batch {
+			e.EntryID = strconv.Itoa(i)
+		}
+
+		resp, err := s.send(batch)
+		if err != nil {
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) || !(apiErr.retryable() || apiErr.invalid()) {
+				return fmt.Errorf("writing to SiteWise failed: %w", err)
+			}
+			if apiErr.retryable() {
+				s.Log.Debugf("Writing properties failed: %v, retrying with next flush...", err)
+			}
+			for _, e := range batch {
+				for _, idx := range e.origin {
+					if apiErr.retryable() {
+						retry[idx] = true
+					} else {
+						rejected[idx] = fmt.Errorf("writing property %q failed: %w", e.PropertyAlias, err)
+					}
+				}
+			}
+			continue
+		}
+
+		for _, ee := range resp.ErrorEntries {
+			i, err := strconv.Atoi(ee.EntryID)
+			if err != nil || i < 0 || i >= len(batch) {
+				s.Log.Warnf("Ignoring error for unknown entry %q", ee.EntryID)
+				continue
+			}
+			e := batch[i]
+			for _, eerr := range ee.Errors {
+				if slices.Contains(retryableErrorCodes, eerr.ErrorCode) {
+					s.Log.Debugf("Writing property %q failed with %s, retrying...", e.PropertyAlias, eerr.ErrorCode)
+					for _, idx := range e.origin {
+						retry[idx] = true
+					}
+					continue
+				}
+				err := fmt.Errorf("writing property %q failed with %s: %s", e.PropertyAlias, eerr.ErrorCode, eerr.ErrorMessage)
+				for _, idx := range e.origin {
+					rejected[idx] = err
+				}
+			}
+		}
+	}
+
+	if len(rejected) == 0 && len(retry) == 0 {
+		return nil
+	}
+
+	werr := &internal.PartialWriteError{
+		Err: errors.New("writing some values failed"),
+	}
+	for i := range metrics {
+		if err, found := rejected[i]; found {
+			s.Log.Errorf("Dropping metric %q: %v", metrics[i].Name(), err)
+			werr.MetricsReject = append(werr.MetricsReject, i)
+			werr.MetricsRejectErrors = append(werr.MetricsRejectErrors, err)
+			continue
+		}
+		if retry[i] {
+			continue
+		}
+		werr.MetricsAccept = append(werr.MetricsAccept, i)
+	}
+
+	return werr
+}
+
+// send writes the entries retrying with exponential backoff if the request
+// was throttled or failed temporarily
+func (s *IoTSiteWise) send(entries []*entry) (*batchResponse, error) {
+	delay := time.Duration(s.RetryDelay)
+	for attempt := 0; ; attempt++ {
+		resp, err := s.client.put(context.Background(), entries)
+		var apiErr *apiError
+		if err == nil || !errors.As(err, &apiErr) || !apiErr.retryable() || attempt >= s.MaxRetries {
+			return resp, err
+		}
+		s.Log.Debugf("Request failed: %v, retrying in %s...", err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// entries converts the metric fields to property values grouped by property
+// alias and split into entries according to the API limits
+func (s *IoTSiteWise) entries(metrics []telegraf.Metric, rejected map[int]error) []*entry {
+	var entries []*entry
+	current := make(map[string]*entry)
+	for i, m := range metrics {
+		quality := s.Quality
+		if s.QualityTag != "" {
+			if q, found := m.GetTag(s.QualityTag); found {
+				q = strings.ToUpper(q)
+				if choice.Contains(q, qualities) {
+					quality = q
+				} else {
+					s.Log.Debugf("Using default quality for invalid quality %q", q)
+				}
+			}
+		}
+		tags := m.Tags()
+		ts := timestamp{
+			TimeInSeconds: m.Time().Unix(),
+			OffsetInNanos: int64(m.Time().Nanosecond()),
+		}
+
+		for _, field := range m.FieldList() {
+			value, err := convert(field.Value)
+			if err != nil {
+				rejected[i] = fmt.Errorf("converting field %q failed: %w", field.Key, err)
+				continue
+			}
+
+			var buf strings.Builder
+			data := &alias{Name: m.Name(), Field: field.Key, Tags: tags}
+			if err := s.template.Execute(&buf, data); err != nil {
+				rejected[i] = fmt.Errorf("creating property alias for field %q failed: %w", field.Key, err)
+				continue
+			}
+			name := buf.String()
+			if name == "" {
+				rejected[i] = fmt.Errorf("empty property alias for field %q", field.Key)
+				continue
+			}
+
+			e, found := current[name]
+			if !found || len(e.PropertyValues) >= maxValuesPerEntry {
+				e = &entry{PropertyAlias: name}
+				current[name] = e
+				entries = append(entries, e)
+			}
+			e.PropertyValues = append(e.PropertyValues, &propertyValue{
+				Value:     value,
+				Timestamp: ts,
+				Quality:   quality,
+			})
+			e.origin = append(e.origin, i)
+		}
+	}
+	return entries
+}
+
+// convert returns the field value as property value, integers must fit into
+// the 32-bit integer type of SiteWise
+func convert(value interface{}) (variant, error) {
+	switch v := value.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return variant{}, fmt.Errorf("unsupported value %v", v)
+		}
+		return variant{DoubleValue: &v}, nil
+	case int64:
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return variant{}, fmt.Errorf("integer %d out of range", v)
+		}
+		x := int32(v)
+		return variant{IntegerValue: &x}, nil
+	case uint64:
+		if v > math.MaxInt32 {
+			return variant{}, fmt.Errorf("integer %d out of range", v)
+		}
+		x := int32(v)
+		return variant{IntegerValue: &x}, nil
+	case bool:
+		return variant{BooleanValue: &v}, nil
+	case string:
+		return variant{StringValue: &v}, nil
+	}
+	return variant{}, fmt.Errorf("unsupported type %T", value)
+}
+
+func init() {
+	outputs.Add("iot_sitewise", func() telegraf.Output {
+		return &IoTSiteWise{
+			Quality:    "GOOD",
+			MaxRetries: 3,
+			RetryDelay: config.Duration(time.Second),
+			Timeout:    config.Duration(30 * time.Second),
+		}
+	})
+}
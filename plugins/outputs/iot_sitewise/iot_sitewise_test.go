@@ -0,0 +1,303 @@
+package iot_sitewise
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	common_aws "github.com/influxdata/telegraf/plugins/common/aws"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *IoTSiteWise
+		expected string
+	}{
+		{
+			name:     "no region",
+			plugin:   &IoTSiteWise{PropertyAlias: "/{{.Name}}"},
+			expected: "missing 'region'",
+		},
+		{
+			name:     "no property alias",
+			plugin:   &IoTSiteWise{CredentialConfig: common_aws.CredentialConfig{Region: "us-east-1"}},
+			expected: "missing 'property_alias'",
+		},
+		{
+			name: "invalid template",
+			plugin: &IoTSiteWise{
+				PropertyAlias:    "/{{.Name",
+				CredentialConfig: common_aws.CredentialConfig{Region: "us-east-1"},
+			},
+			expected: "parsing 'property_alias' template failed",
+		},
+		{
+			name: "invalid quality",
+			plugin: &IoTSiteWise{
+				PropertyAlias:    "/{{.Name}}",
+				Quality:          "OK",
+				CredentialConfig: common_aws.CredentialConfig{Region: "us-east-1"},
+			},
+			expected: "invalid 'quality'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var mu sync.Mutex
+	var requests []*batchRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/properties" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") ||
+			!strings.Contains(r.Header.Get("Authorization"), "/us-east-1/iotsitewise/aws4_request") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		var req batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, &req)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"errorEntries":[]}`))
+	}))
+	defer server.Close()
+
+	plugin := newPlugin(server.URL)
+	plugin.QualityTag = "quality"
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	ts := time.Unix(1700000000, 123456789)
+	metrics := []telegraf.Metric{
+		metric.New("boiler",
+			map[string]string{"site": "plant1"},
+			map[string]interface{}{"temperature": 71.5, "running": true},
+			ts,
+		),
+		metric.New("boiler",
+			map[string]string{"site": "plant1", "quality": "uncertain"},
+			map[string]interface{}{"temperature": 72.0, "cycles": int64(42), "state": "heating"},
+			ts.Add(time.Second),
+		),
+	}
+	require.NoError(t, plugin.Write(metrics))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, requests, 1)
+	entries := requests[0].Entries
+	require.Len(t, entries, 4)
+
+	require.Equal(t, "/plant1/boiler/temperature", entries[0].PropertyAlias)
+	require.Len(t, entries[0].PropertyValues, 2)
+	first, second := entries[0].PropertyValues[0], entries[0].PropertyValues[1]
+	require.Equal(t, 71.5, *first.Value.DoubleValue)
+	require.Equal(t, timestamp{TimeInSeconds: 1700000000, OffsetInNanos: 123456789}, first.Timestamp)
+	require.Equal(t, "GOOD", first.Quality)
+	require.Equal(t, 72.0, *second.Value.DoubleValue)
+	require.Equal(t, "UNCERTAIN", second.Quality)
+
+	require.Equal(t, "/plant1/boiler/running", entries[1].PropertyAlias)
+	require.True(t, *entries[1].PropertyValues[0].Value.BooleanValue)
+	require.Equal(t, "/plant1/boiler/cycles", entries[2].PropertyAlias)
+	require.Equal(t, int32(42), *entries[2].PropertyValues[0].Value.IntegerValue)
+	require.Equal(t, "/plant1/boiler/state", entries[3].PropertyAlias)
+	require.Equal(t, "heating", *entries[3].PropertyValues[0].Value.StringValue)
+}
+
+func TestWriteBatching(t *testing.T) {
+	var mu sync.Mutex
+	var requests []*batchRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, &req)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"errorEntries":[]}`))
+	}))
+	defer server.Close()
+
+	plugin := newPlugin(server.URL)
+	plugin.PropertyAlias = "/{{.Tag \"id\"}}"
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	// 25 values of one property and one value of ten further properties
+	// require three entries for the first property and 13 entries in total
+	ts := time.Unix(1700000000, 0)
+	metrics := make([]telegraf.Metric, 0, 35)
+	for i := range 25 {
+		metrics = append(metrics, metric.New("m", map[string]string{"id": "a"}, map[string]interface{}{"value": float64(i)}, ts.Add(time.Duration(i)*time.Second)))
+	}
+	for _, id := range []string{"b", "c", "d", "e", "f", "g", "h", "i", "j", "k"} {
+		metrics = append(metrics, metric.New("m", map[string]string{"id": id}, map[string]interface{}{"value": 1.0}, ts))
+	}
+	require.NoError(t, plugin.Write(metrics))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, requests, 2)
+	require.Len(t, requests[0].Entries, 10)
+	require.Len(t, requests[1].Entries, 3)
+	var values int
+	for _, req := range requests {
+		for i, e := range req.Entries {
+			require.LessOrEqual(t, len(e.PropertyValues), 10)
+			require.Equal(t, strconv.Itoa(i), e.EntryID)
+			values += len(e.PropertyValues)
+		}
+	}
+	require.Equal(t, 35, values)
+}
+
+func TestWriteEntryErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req batchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var errs []string
+		for _, e := range req.Entries {
+			switch e.PropertyAlias {
+			case "/unknown":
+				errs = append(errs, `{"entryId":"`+e.EntryID+`","errors":[{"errorCode":"ResourceNotFoundException","errorMessage":"not found"}]}`)
+			case "/busy":
+				errs = append(errs, `{"entryId":"`+e.EntryID+`","errors":[{"errorCode":"ThrottlingException","errorMessage":"slow down"}]}`)
+			}
+		}
+		_, _ = w.Write([]byte(`{"errorEntries":[` + strings.Join(errs, ",") + `]}`))
+	}))
+	defer server.Close()
+
+	plugin := newPlugin(server.URL)
+	plugin.PropertyAlias = "/{{.Name}}"
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	ts := time.Unix(1700000000, 0)
+	metrics := []telegraf.Metric{
+		metric.New("ok", map[string]string{}, map[string]interface{}{"value": 1.0}, ts),
+		metric.New("unknown", map[string]string{}, map[string]interface{}{"value": 1.0}, ts),
+		metric.New("busy", map[string]string{}, map[string]interface{}{"value": 1.0}, ts),
+		metric.New("ok", map[string]string{}, map[string]interface{}{"value": int64(1) << 40}, ts),
+		metric.New("ok", map[string]string{}, map[string]interface{}{"value": 2.0}, ts),
+	}
+
+	var werr *internal.PartialWriteError
+	require.ErrorAs(t, plugin.Write(metrics), &werr)
+	require.Equal(t, []int{0, 4}, werr.MetricsAccept)
+	require.Equal(t, []int{1, 3}, werr.MetricsReject)
+}
+
+func TestWriteThrottling(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls <= 2 {
+			w.Header().Set("X-Amzn-ErrorType", "ThrottlingException:http://internal.amazon.com/coral/com.amazonaws.iotsitewise/")
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write([]byte(`{"message":"Rate exceeded"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"errorEntries":[]}`))
+	}))
+	defer server.Close()
+
+	metrics := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(1700000000, 0)),
+	}
+
+	// The request succeeds within the retries
+	plugin := newPlugin(server.URL)
+	plugin.PropertyAlias = "/{{.Name}}"
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+	require.NoError(t, plugin.Write(metrics))
+	mu.Lock()
+	require.Equal(t, 3, calls)
+	calls = 0
+	mu.Unlock()
+
+	// The metrics are kept if the retries are exhausted
+	plugin.MaxRetries = 1
+	var werr *internal.PartialWriteError
+	require.ErrorAs(t, plugin.Write(metrics), &werr)
+	require.Empty(t, werr.MetricsAccept)
+	require.Empty(t, werr.MetricsReject)
+	mu.Lock()
+	require.Equal(t, 2, calls)
+	mu.Unlock()
+}
+
+func TestWriteRequestFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Amzn-ErrorType", "AccessDeniedException")
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"Message":"not authorized"}`))
+	}))
+	defer server.Close()
+
+	plugin := newPlugin(server.URL)
+	plugin.PropertyAlias = "/{{.Name}}"
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	metrics := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(1700000000, 0)),
+	}
+	require.ErrorContains(t, plugin.Write(metrics), "request failed with status 403 (AccessDeniedException): not authorized")
+}
+
+func newPlugin(endpoint string) *IoTSiteWise {
+	return &IoTSiteWise{
+		PropertyAlias: `/{{.Tag "site"}}/{{.Name}}/{{.Field}}`,
+		MaxRetries:    3,
+		RetryDelay:    config.Duration(time.Millisecond),
+		Timeout:       config.Duration(5 * time.Second),
+		CredentialConfig: common_aws.CredentialConfig{
+			Region:      "us-east-1",
+			AccessKey:   "AKID",
+			SecretKey:   "SECRET",
+			EndpointURL: endpoint,
+		},
+		Log: &testutil.Logger{},
+	}
+}
@@ -39,6 +39,7 @@ type Kafka struct {
 	RoutingKey        string          `toml:"routing_key"`
 	ProducerTimestamp string          `toml:"producer_timestamp"`
 	MetricNameHeader  string          `toml:"metric_name_header"`
+	TransactionalID   string          `toml:"transactional_id"`
 	Log               telegraf.Logger `toml:"-"`
 	proxy.Socks5ProxyConfig
 	kafka.WriteConfig
@@ -129,6 +130,13 @@ func (k *Kafka) Init() error {
 		return err
 	}
 
+	if k.TransactionalID != "" {
+		if !k.IdempotentWrites {
+			return errors.New("transactional_id requires idempotent_writes to be enabled")
+		}
+		config.Producer.Transaction.ID = k.TransactionalID
+	}
+
 	// Legacy support ssl config
 	if k.Certificate != "" {
 		k.TLSCert = k.Certificate
@@ -234,7 +242,7 @@ func (k *Kafka) Write(metrics []telegraf.Metric) error {
 		msgs = append(msgs, m)
 	}
 
-	err := k.producer.SendMessages(msgs)
+	err := k.send(msgs)
 	if err != nil {
 		// We could have many errors, return only the first encountered.
 		var errs sarama.ProducerErrors
@@ -260,6 +268,66 @@ func (k *Kafka) Write(metrics []telegraf.Metric) error {
 	return nil
 }
 
+// send the given messages, wrapped into a transaction if a transactional id is
+// configured. Failed transactions are aborted so the messages are not visible
+// to consumers reading committed messages only.
+func (k *Kafka) send(msgs []*sarama.ProducerMessage) error {
+	if k.TransactionalID == "" {
+		return k.producer.SendMessages(msgs)
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	// Recreate the producer if the previous one was discarded due to a fatal
+	// transaction error
+	if k.producer == nil {
+		producer, err := k.producerFunc(k.Brokers, k.saramaConfig)
+		if err != nil {
+			return fmt.Errorf("recreating producer failed: %w", err)
+		}
+		k.producer = producer
+	}
+
+	if err := k.producer.BeginTxn(); err != nil {
+		k.abortTxn()
+		return fmt.Errorf("beginning transaction failed: %w", err)
+	}
+
+	if err := k.producer.SendMessages(msgs); err != nil {
+		k.abortTxn()
+		return err
+	}
+
+	if err := k.producer.CommitTxn(); err != nil {
+		k.abortTxn()
+		return fmt.Errorf("committing transaction failed: %w", err)
+	}
+
+	return nil
+}
+
+// abortTxn aborts the current transaction if possible. A producer in fatal
+// state cannot be used for further transactions and is discarded.
+func (k *Kafka) abortTxn() {
+	status := k.producer.TxnStatus()
+	if status&sarama.ProducerTxnFlagFatalError != 0 {
+		k.Log.Warn("Transactional producer in fatal state, recreating producer")
+		if err := k.producer.Close(); err != nil {
+			k.Log.Debugf("Closing producer failed: %v", err)
+		}
+		k.producer = nil
+		return
+	}
+
+	if status&(sarama.ProducerTxnFlagInTransaction|sarama.ProducerTxnFlagAbortableError) == 0 {
+		return
+	}
+	if err := k.producer.AbortTxn(); err != nil {
+		k.Log.Errorf("Aborting transaction failed: %v", err)
+	}
+}
+
 func init() {
 	outputs.Add("kafka", func() telegraf.Output {
 		return &Kafka{
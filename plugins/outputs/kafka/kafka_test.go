@@ -1,6 +1,7 @@
 package kafka
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/common/kafka"
 	"github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/influxdata/telegraf/testutil"
 )
@@ -310,3 +312,174 @@ func TestTopicTag(t *testing.T) {
 		})
 	}
 }
+
+func TestTransactionalInitFail(t *testing.T) {
+	plugin := &Kafka{
+		Brokers:         []string{"127.0.0.1"},
+		Topic:           "telegraf",
+		TransactionalID: "telegraf-1",
+		WriteConfig: kafka.WriteConfig{
+			MaxRetry:     3,
+			RequiredAcks: -1,
+		},
+		Log: testutil.Logger{},
+	}
+	require.ErrorContains(t, plugin.Init(), "transactional_id requires idempotent_writes to be enabled")
+}
+
+func TestTransactionalInit(t *testing.T) {
+	plugin := &Kafka{
+		Brokers:         []string{"127.0.0.1"},
+		Topic:           "telegraf",
+		TransactionalID: "telegraf-1",
+		WriteConfig: kafka.WriteConfig{
+			MaxRetry:         3,
+			RequiredAcks:     -1,
+			IdempotentWrites: true,
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.Equal(t, "telegraf-1", plugin.saramaConfig.Producer.Transaction.ID)
+	require.True(t, plugin.saramaConfig.Producer.Idempotent)
+	require.NoError(t, plugin.saramaConfig.Validate())
+}
+
+func TestTransactionalWrite(t *testing.T) {
+	tests := []struct {
+		name      string
+		producer  *MockTxnProducer
+		expected  []string
+		err       string
+		recreated bool
+	}{
+		{
+			name:     "success",
+			producer: &MockTxnProducer{},
+			expected: []string{"begin", "send", "commit"},
+		},
+		{
+			name: "send failure",
+			producer: &MockTxnProducer{
+				sendErr: errors.New("broker not available"),
+			},
+			expected: []string{"begin", "send", "abort"},
+			err:      "broker not available",
+		},
+		{
+			name: "commit failure",
+			producer: &MockTxnProducer{
+				commitErr: errors.New("coordinator not available"),
+				failState: sarama.ProducerTxnFlagInError | sarama.ProducerTxnFlagAbortableError,
+			},
+			expected: []string{"begin", "send", "commit", "abort"},
+			err:      "committing transaction failed: coordinator not available",
+		},
+		{
+			name: "fatal failure",
+			producer: &MockTxnProducer{
+				commitErr: sarama.ErrProducerFenced,
+				failState: sarama.ProducerTxnFlagInError | sarama.ProducerTxnFlagFatalError,
+			},
+			expected:  []string{"begin", "send", "commit", "close"},
+			err:       "committing transaction failed",
+			recreated: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			replacement := &MockTxnProducer{}
+			plugin := &Kafka{
+				Brokers:         []string{"127.0.0.1"},
+				Topic:           "telegraf",
+				TransactionalID: "telegraf-1",
+				WriteConfig: kafka.WriteConfig{
+					MaxRetry:         3,
+					RequiredAcks:     -1,
+					IdempotentWrites: true,
+				},
+				Log: testutil.Logger{},
+				producerFunc: func([]string, *sarama.Config) (sarama.SyncProducer, error) {
+					return replacement, nil
+				},
+			}
+			require.NoError(t, plugin.Init())
+
+			s := &influx.Serializer{}
+			require.NoError(t, s.Init())
+			plugin.SetSerializer(s)
+			plugin.producer = tt.producer
+
+			input := []telegraf.Metric{
+				metric.New("cpu", map[string]string{}, map[string]interface{}{"time_idle": 42.0}, time.Unix(0, 0)),
+				metric.New("cpu", map[string]string{}, map[string]interface{}{"time_idle": 23.0}, time.Unix(1, 0)),
+			}
+			err := plugin.Write(input)
+			if tt.err != "" {
+				require.ErrorContains(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tt.expected, tt.producer.calls)
+
+			// The next write must use a new producer after fatal errors
+			if tt.recreated {
+				require.Nil(t, plugin.producer)
+				require.NoError(t, plugin.Write(input))
+				require.Same(t, replacement, plugin.producer)
+				require.Equal(t, []string{"begin", "send", "commit"}, replacement.calls)
+				require.Len(t, replacement.sent, 2)
+			}
+		})
+	}
+}
+
+type MockTxnProducer struct {
+	MockProducer
+	calls     []string
+	status    sarama.ProducerTxnStatusFlag
+	sendErr   error
+	commitErr error
+	failState sarama.ProducerTxnStatusFlag
+}
+
+func (p *MockTxnProducer) SendMessages(msgs []*sarama.ProducerMessage) error {
+	p.calls = append(p.calls, "send")
+	if p.sendErr != nil {
+		p.status = sarama.ProducerTxnFlagInTransaction | sarama.ProducerTxnFlagAbortableError
+		return p.sendErr
+	}
+	return p.MockProducer.SendMessages(msgs)
+}
+
+func (p *MockTxnProducer) BeginTxn() error {
+	p.calls = append(p.calls, "begin")
+	p.status = sarama.ProducerTxnFlagInTransaction
+	return nil
+}
+
+func (p *MockTxnProducer) CommitTxn() error {
+	p.calls = append(p.calls, "commit")
+	if p.commitErr != nil {
+		p.status = p.failState
+		return p.commitErr
+	}
+	p.status = sarama.ProducerTxnFlagReady
+	return nil
+}
+
+func (p *MockTxnProducer) AbortTxn() error {
+	p.calls = append(p.calls, "abort")
+	p.status = sarama.ProducerTxnFlagReady
+	return nil
+}
+
+func (p *MockTxnProducer) TxnStatus() sarama.ProducerTxnStatusFlag {
+	return p.status
+}
+
+func (p *MockTxnProducer) Close() error {
+	p.calls = append(p.calls, "close")
+	return nil
+}
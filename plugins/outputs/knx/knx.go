@@ -0,0 +1,318 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package knx
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/cemi"
+	"github.com/vapourismo/knx-go/knx/dpt"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/plugins/common/ets"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// AddressSettings describes the mapping of a metric field to a KNX
+// group-address
+type AddressSettings struct {
+	Metric  string            `toml:"metric"`
+	Field   string            `toml:"field"`
+	Tags    map[string]string `toml:"tags"`
+	Address string            `toml:"address"`
+	Dpt     string            `toml:"dpt"`
+
+	groupAddress cemi.GroupAddr
+}
+
+func (a *AddressSettings) matches(m telegraf.Metric) bool {
+	if a.Metric != m.Name() {
+		return false
+	}
+	for k, v := range a.Tags {
+		if tv, found := m.GetTag(k); !found || tv != v {
+			return false
+		}
+	}
+	return true
+}
+
+type KNX struct {
+	ServiceType    string            `toml:"service_type"`
+	ServiceAddress string            `toml:"service_address"`
+	ETSFile        string            `toml:"ets_file"`
+	Addresses      []AddressSettings `toml:"address"`
+	Log            telegraf.Logger   `toml:"-"`
+
+	client knxInterface
+}
+
+type knxInterface interface {
+	Send(event knx.GroupEvent) error
+	Close()
+}
+
+func (*KNX) SampleConfig() string {
+	return sampleConfig
+}
+
+func (k *KNX) Init() error {
+	if k.ServiceAddress == "" {
+		return errors.New("'service_address' must be specified")
+	}
+	if err := choice.Check(k.ServiceType, []string{"tunnel", "tunnel_udp", "tunnel_tcp", "router"}); err != nil {
+		return fmt.Errorf("invalid 'service_type': %w", err)
+	}
+	if len(k.Addresses) == 0 {
+		return errors.New("no addresses configured")
+	}
+
+	// Lookup addresses and datapoint-types in the ETS project, addresses
+	// can be specified by their name in the project
+	byAddress := make(map[string]ets.GroupAddress)
+	byName := make(map[string]ets.GroupAddress)
+	if k.ETSFile != "" {
+		addresses, err := ets.Load(k.ETSFile)
+		if err != nil {
+			return fmt.Errorf("importing ETS file %q failed: %w", k.ETSFile, err)
+		}
+		for _, a := range addresses {
+			byAddress[a.Address] = a
+			byName[a.Name] = a
+		}
+	}
+
+	for i := range k.Addresses {
+		a := &k.Addresses[i]
+		if a.Metric == "" {
+			return fmt.Errorf("empty metric name for address %q", a.Address)
+		}
+		if a.Field == "" {
+			return fmt.Errorf("empty field name for address %q", a.Address)
+		}
+
+		ga, err := cemi.NewGroupAddrString(a.Address)
+		if err != nil {
+			imported, found := byName[a.Address]
+			if !found {
+				return fmt.Errorf("invalid address %q for field %q", a.Address, a.Field)
+			}
+			if ga, err = cemi.NewGroupAddrString(imported.Address); err != nil {
+				return fmt.Errorf("invalid address %q for field %q: %w", imported.Address, a.Field, err)
+			}
+		}
+		a.groupAddress = ga
+
+		if a.Dpt == "" {
+			a.Dpt = byAddress[ga.String()].DPT
+		}
+		if a.Dpt == "" {
+			return fmt.Errorf("no datapoint-type for address %q", a.Address)
+		}
+		if _, ok := dpt.Produce(a.Dpt); !ok {
+			return fmt.Errorf("cannot create datapoint-type %q for address %q", a.Dpt, a.Address)
+		}
+	}
+
+	return nil
+}
+
+func (k *KNX) Connect() error {
+	switch k.ServiceType {
+	case "tunnel", "tunnel_udp", "tunnel_tcp":
+		tunnelconfig := knx.DefaultTunnelConfig
+		tunnelconfig.UseTCP = k.ServiceType == "tunnel_tcp"
+		c, err := knx.NewGroupTunnel(k.ServiceAddress, tunnelconfig)
+		if err != nil {
+			return err
+		}
+		k.client = &c
+	case "router":
+		c, err := knx.NewGroupRouter(k.ServiceAddress, knx.DefaultRouterConfig)
+		if err != nil {
+			return err
+		}
+		k.client = &c
+	}
+	return nil
+}
+
+func (k *KNX) Close() error {
+	if k.client != nil {
+		k.client.Close()
+		k.client = nil
+	}
+	return nil
+}
+
+func (k *KNX) Write(metrics []telegraf.Metric) error {
+	// Reconnect in case the connection was lost
+	if k.client == nil {
+		if err := k.Connect(); err != nil {
+			return fmt.Errorf("reconnecting failed: %w", err)
+		}
+	}
+
+	rejected := make(map[int]error)
+	for i, m := range metrics {
+		for j := range k.Addresses {
+			a := &k.Addresses[j]
+			if !a.matches(m) {
+				continue
+			}
+			raw, found := m.GetField(a.Field)
+			if !found {
+				continue
+			}
+
+			data, err := pack(a.Dpt, raw)
+			if err != nil {
+				rejected[i] = fmt.Errorf("converting field %q failed: %w", a.Field, err)
+				continue
+			}
+			event := knx.GroupEvent{
+				Command:     knx.GroupWrite,
+				Destination: a.groupAddress,
+				Data:        data,
+			}
+			if err := k.client.Send(event); err != nil {
+				// Drop the connection to reconnect with the next write and
+				// keep the metrics for retrying
+				k.client.Close()
+				k.client = nil
+				return fmt.Errorf("writing to %q failed: %w", a.groupAddress, err)
+			}
+		}
+	}
+
+	if len(rejected) == 0 {
+		return nil
+	}
+
+	werr := &internal.PartialWriteError{
+		Err: errors.New("writing some values failed"),
+	}
+	for i := range metrics {
+		if err, found := rejected[i]; found {
+			k.Log.Errorf("Dropping metric %q: %v", metrics[i].Name(), err)
+			werr.MetricsReject = append(werr.MetricsReject, i)
+			werr.MetricsRejectErrors = append(werr.MetricsRejectErrors, err)
+			continue
+		}
+		werr.MetricsAccept = append(werr.MetricsAccept, i)
+	}
+
+	return werr
+}
+
+// pack encodes the value as the given datapoint-type. Only datapoint-types
+// with a basic underlying type are supported.
+func pack(name string, value interface{}) ([]byte, error) {
+	d, ok := dpt.Produce(name)
+	if !ok {
+		return nil, fmt.Errorf("unknown datapoint-type %q", name)
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(d))
+	switch v.Kind() {
+	case reflect.Bool:
+		switch x := value.(type) {
+		case bool:
+			v.SetBool(x)
+		case int64:
+			v.SetBool(x != 0)
+		case uint64:
+			v.SetBool(x != 0)
+		case float64:
+			v.SetBool(x != 0)
+		default:
+			return nil, fmt.Errorf("cannot convert %T to boolean", value)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var x int64
+		switch raw := value.(type) {
+		case int64:
+			x = raw
+		case uint64:
+			if raw > math.MaxInt64 {
+				return nil, fmt.Errorf("value %d out of range", raw)
+			}
+			x = int64(raw)
+		case float64:
+			x = int64(math.Round(raw))
+		case bool:
+			if raw {
+				x = 1
+			}
+		default:
+			return nil, fmt.Errorf("cannot convert %T to integer", value)
+		}
+		if v.OverflowInt(x) {
+			return nil, fmt.Errorf("value %d out of range", x)
+		}
+		v.SetInt(x)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var x uint64
+		switch raw := value.(type) {
+		case uint64:
+			x = raw
+		case int64:
+			if raw < 0 {
+				return nil, fmt.Errorf("value %d out of range", raw)
+			}
+			x = uint64(raw)
+		case float64:
+			if raw < 0 {
+				return nil, fmt.Errorf("value %v out of range", raw)
+			}
+			x = uint64(math.Round(raw))
+		case bool:
+			if raw {
+				x = 1
+			}
+		default:
+			return nil, fmt.Errorf("cannot convert %T to unsigned integer", value)
+		}
+		if v.OverflowUint(x) {
+			return nil, fmt.Errorf("value %d out of range", x)
+		}
+		v.SetUint(x)
+	case reflect.Float32, reflect.Float64:
+		var x float64
+		switch raw := value.(type) {
+		case float64:
+			x = raw
+		case int64:
+			x = float64(raw)
+		case uint64:
+			x = float64(raw)
+		default:
+			return nil, fmt.Errorf("cannot convert %T to float", value)
+		}
+		v.SetFloat(x)
+	case reflect.String:
+		x, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot convert %T to string", value)
+		}
+		v.SetString(x)
+	default:
+		return nil, fmt.Errorf("datapoint-type %q not supported", name)
+	}
+	return d.Pack(), nil
+}
+
+func init() {
+	outputs.Add("knx", func() telegraf.Output {
+		return &KNX{ServiceType: "tunnel"}
+	})
+}
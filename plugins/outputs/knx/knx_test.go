@@ -0,0 +1,215 @@
+package knx
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vapourismo/knx-go/knx"
+	"github.com/vapourismo/knx-go/knx/dpt"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *KNX
+		expected string
+	}{
+		{
+			name:     "no service address",
+			plugin:   &KNX{ServiceType: "tunnel"},
+			expected: "'service_address' must be specified",
+		},
+		{
+			name:     "invalid service type",
+			plugin:   &KNX{ServiceType: "usb", ServiceAddress: "localhost:3671"},
+			expected: "invalid 'service_type'",
+		},
+		{
+			name:     "no addresses",
+			plugin:   &KNX{ServiceType: "tunnel", ServiceAddress: "localhost:3671"},
+			expected: "no addresses configured",
+		},
+		{
+			name: "invalid address",
+			plugin: &KNX{
+				ServiceType:    "tunnel",
+				ServiceAddress: "localhost:3671",
+				Addresses:      []AddressSettings{{Metric: "heating", Field: "setpoint", Address: "Setpoint", Dpt: "9.001"}},
+			},
+			expected: `invalid address "Setpoint" for field "setpoint"`,
+		},
+		{
+			name: "missing datapoint-type",
+			plugin: &KNX{
+				ServiceType:    "tunnel",
+				ServiceAddress: "localhost:3671",
+				Addresses:      []AddressSettings{{Metric: "heating", Field: "setpoint", Address: "2/0/1"}},
+			},
+			expected: `no datapoint-type for address "2/0/1"`,
+		},
+		{
+			name: "missing datapoint-type in project",
+			plugin: &KNX{
+				ServiceType:    "tunnel",
+				ServiceAddress: "localhost:3671",
+				ETSFile:        "testdata/project.xml",
+				Addresses:      []AddressSettings{{Metric: "heating", Field: "valve", Address: "Valve position"}},
+			},
+			expected: `no datapoint-type for address "Valve position"`,
+		},
+		{
+			name: "unknown datapoint-type",
+			plugin: &KNX{
+				ServiceType:    "tunnel",
+				ServiceAddress: "localhost:3671",
+				Addresses:      []AddressSettings{{Metric: "heating", Field: "setpoint", Address: "2/0/1", Dpt: "9.999"}},
+			},
+			expected: `cannot create datapoint-type "9.999" for address "2/0/1"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestPack(t *testing.T) {
+	tests := []struct {
+		name     string
+		dpt      string
+		value    interface{}
+		expected []byte
+	}{
+		{name: "bool", dpt: "1.001", value: true, expected: dpt.DPT_1001(true).Pack()},
+		{name: "bool from integer", dpt: "1.001", value: int64(0), expected: dpt.DPT_1001(false).Pack()},
+		{name: "unsigned from float", dpt: "5.004", value: float64(41.6), expected: dpt.DPT_5004(42).Pack()},
+		{name: "signed", dpt: "13.001", value: int64(-15), expected: dpt.DPT_13001(-15).Pack()},
+		{name: "float", dpt: "9.001", value: float64(21.5), expected: dpt.DPT_9001(21.5).Pack()},
+		{name: "float from integer", dpt: "14.000", value: int64(3), expected: dpt.DPT_14000(3).Pack()},
+		{name: "string", dpt: "16.000", value: "hello", expected: dpt.DPT_16000("hello").Pack()},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := pack(tt.dpt, tt.value)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, data)
+		})
+	}
+
+	_, err := pack("5.004", int64(256))
+	require.EqualError(t, err, "value 256 out of range")
+	_, err = pack("5.004", int64(-1))
+	require.EqualError(t, err, "value -1 out of range")
+	_, err = pack("9.001", "warm")
+	require.EqualError(t, err, "cannot convert string to float")
+	_, err = pack("10.001", int64(1))
+	require.EqualError(t, err, `datapoint-type "10.001" not supported`)
+}
+
+func TestWrite(t *testing.T) {
+	plugin := &KNX{
+		ServiceType:    "tunnel",
+		ServiceAddress: "localhost:3671",
+		ETSFile:        "testdata/project.xml",
+		Addresses: []AddressSettings{
+			{Metric: "heating", Field: "setpoint", Tags: map[string]string{"room": "living"}, Address: "2/0/1", Dpt: "9.001"},
+			{Metric: "heating", Field: "temperature", Address: "Temperature"},
+			{Metric: "window", Field: "open", Address: "1/0/1"},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	client := &fakeClient{}
+	plugin.client = client
+
+	metrics := []telegraf.Metric{
+		metric.New(
+			"heating",
+			map[string]string{"room": "living"},
+			map[string]interface{}{"setpoint": 21.5, "temperature": 20.0},
+			time.Unix(0, 0),
+		),
+		// Metrics not matching the tags are only written for the unrestricted
+		// addresses
+		metric.New(
+			"heating",
+			map[string]string{"room": "kitchen"},
+			map[string]interface{}{"setpoint": 19.0},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"window",
+			map[string]string{},
+			map[string]interface{}{"open": "yes"},
+			time.Unix(0, 0),
+		),
+		metric.New(
+			"window",
+			map[string]string{},
+			map[string]interface{}{"open": true},
+			time.Unix(0, 0),
+		),
+	}
+
+	var werr *internal.PartialWriteError
+	require.ErrorAs(t, plugin.Write(metrics), &werr)
+	require.Equal(t, []int{0, 1, 3}, werr.MetricsAccept)
+	require.Equal(t, []int{2}, werr.MetricsReject)
+
+	expected := []knx.GroupEvent{
+		{Command: knx.GroupWrite, Data: dpt.DPT_9001(21.5).Pack()},
+		{Command: knx.GroupWrite, Data: dpt.DPT_9001(20.0).Pack()},
+		{Command: knx.GroupWrite, Data: dpt.DPT_1019(true).Pack()},
+	}
+	for i, addr := range []string{"2/0/1", "1/0/0", "1/0/1"} {
+		require.Equal(t, addr, client.sent[i].Destination.String())
+		expected[i].Destination = client.sent[i].Destination
+	}
+	require.Equal(t, expected, client.sent)
+}
+
+func TestWriteReconnect(t *testing.T) {
+	plugin := &KNX{
+		ServiceType:    "tunnel",
+		ServiceAddress: "localhost:3671",
+		Addresses:      []AddressSettings{{Metric: "heating", Field: "setpoint", Address: "2/0/1", Dpt: "9.001"}},
+		Log:            testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	client := &fakeClient{err: errors.New("connection lost")}
+	plugin.client = client
+
+	m := metric.New("heating", map[string]string{}, map[string]interface{}{"setpoint": 21.5}, time.Unix(0, 0))
+	require.ErrorContains(t, plugin.Write([]telegraf.Metric{m}), `writing to "2/0/1" failed: connection lost`)
+	require.True(t, client.closed)
+	require.Nil(t, plugin.client)
+}
+
+type fakeClient struct {
+	sent   []knx.GroupEvent
+	err    error
+	closed bool
+}
+
+func (c *fakeClient) Send(event knx.GroupEvent) error {
+	if c.err != nil {
+		return c.err
+	}
+	c.sent = append(c.sent, event)
+	return nil
+}
+
+func (c *fakeClient) Close() {
+	c.closed = true
+}
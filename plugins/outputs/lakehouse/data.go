@@ -0,0 +1,127 @@
+package lakehouse
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/common/columnar"
+)
+
+// Sources of the partition values
+const (
+	sourceMeasurement = "measurement"
+	sourceDate        = "date"
+	sourceTag         = "tag"
+)
+
+// column of the table schema, the ID is only used by Iceberg tables
+type column struct {
+	name string
+	typ  string
+	id   int
+}
+
+// partition describes how the partition values are derived from a metric
+type partition struct {
+	name   string
+	column string
+	source string
+	id     int
+}
+
+// dataFile is a Parquet file written to the table location
+type dataFile struct {
+	key        string
+	size       int64
+	records    int64
+	partitions []*string
+	minTime    time.Time
+	maxTime    time.Time
+}
+
+// group holds the metrics written to a single data file
+type group struct {
+	values  []*string
+	metrics []telegraf.Metric
+	used    map[string]bool
+}
+
+// encoder creates Parquet files from metrics
+type encoder struct {
+	measurementColumn string
+	timestampColumn   string
+	compression       compress.Compression
+	fieldIDs          bool
+}
+
+// encode writes the metrics with the given columns to a Parquet file
+func (e *encoder) encode(metrics []telegraf.Metric, columns []*column) ([]byte, error) {
+	fields := make([]arrow.Field, 0, len(columns))
+	for _, c := range columns {
+		f := arrow.Field{Name: c.name, Type: columnar.ArrowType(c.typ), Nullable: true}
+		if e.fieldIDs {
+			f.Metadata = arrow.NewMetadata([]string{"PARQUET:field_id"}, []string{strconv.Itoa(c.id)})
+		}
+		fields = append(fields, f)
+	}
+	schema := arrow.NewSchema(fields, nil)
+
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer builder.Release()
+	for i, c := range columns {
+		for _, m := range metrics {
+			e.append(builder.Field(i), c, m)
+		}
+	}
+	record := builder.NewRecord()
+	defer record.Release()
+
+	var buf bytes.Buffer
+	props := parquet.NewWriterProperties(parquet.WithCompression(e.compression))
+	writer, err := pqarrow.NewFileWriter(schema, &buf, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		return nil, fmt.Errorf("creating writer failed: %w", err)
+	}
+	if err := writer.Write(record); err != nil {
+		writer.Close()
+		return nil, fmt.Errorf("writing record failed: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("closing writer failed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *encoder) append(b array.Builder, c *column, m telegraf.Metric) {
+	switch c.name {
+	case e.measurementColumn:
+		b.(*array.StringBuilder).Append(m.Name())
+		return
+	case e.timestampColumn:
+		b.(*array.TimestampBuilder).Append(arrow.Timestamp(m.Time().UnixMicro()))
+		return
+	}
+
+	// Fields take precedence over tags of the same name
+	raw, found := m.GetField(c.name)
+	if !found {
+		if v, ok := m.GetTag(c.name); ok {
+			raw, found = v, true
+		}
+	}
+	if !found {
+		b.AppendNull()
+		return
+	}
+	columnar.Append(b, c.typ, raw)
+}
@@ -0,0 +1,495 @@
+package lakehouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/columnar"
+	"github.com/influxdata/telegraf/plugins/common/objectstore"
+)
+
+// Directory of the Delta transaction log
+const deltaLogDir = "_delta_log/"
+
+// Name of the date partition column of Delta tables
+const deltaDateColumn = "date"
+
+// Value of null partitions in the partition directory names
+const hiveDefaultPartition = "__HIVE_DEFAULT_PARTITION__"
+
+// Actions of the Delta transaction log, see
+// https://github.com/delta-io/delta/blob/master/PROTOCOL.md#actions
+type deltaAction struct {
+	Protocol   *deltaProtocol   `json:"protocol,omitempty"`
+	MetaData   *deltaMetadata   `json:"metaData,omitempty"`
+	Add        *deltaAdd        `json:"add,omitempty"`
+	CommitInfo *deltaCommitInfo `json:"commitInfo,omitempty"`
+}
+
+type deltaProtocol struct {
+	MinReaderVersion int      `json:"minReaderVersion"`
+	MinWriterVersion int      `json:"minWriterVersion"`
+	ReaderFeatures   []string `json:"readerFeatures,omitempty"`
+	WriterFeatures   []string `json:"writerFeatures,omitempty"`
+}
+
+type deltaMetadata struct {
+	ID               string            `json:"id"`
+	Name             string            `json:"name,omitempty"`
+	Description      string            `json:"description,omitempty"`
+	Format           deltaFormat       `json:"format"`
+	SchemaString     string            `json:"schemaString"`
+	PartitionColumns []string          `json:"partitionColumns"`
+	Configuration    map[string]string `json:"configuration"`
+	CreatedTime      *int64            `json:"createdTime,omitempty"`
+}
+
+type deltaFormat struct {
+	Provider string            `json:"provider"`
+	Options  map[string]string `json:"options"`
+}
+
+type deltaAdd struct {
+	Path             string             `json:"path"`
+	PartitionValues  map[string]*string `json:"partitionValues"`
+	Size             int64              `json:"size"`
+	ModificationTime int64              `json:"modificationTime"`
+	DataChange       bool               `json:"dataChange"`
+	Stats            string             `json:"stats,omitempty"`
+}
+
+type deltaCommitInfo struct {
+	Timestamp           int64             `json:"timestamp"`
+	Operation           string            `json:"operation"`
+	OperationParameters map[string]string `json:"operationParameters"`
+	IsBlindAppend       bool              `json:"isBlindAppend"`
+	EngineInfo          string            `json:"engineInfo"`
+}
+
+type deltaSchema struct {
+	Type   string        `json:"type"`
+	Fields []*deltaField `json:"fields"`
+}
+
+type deltaField struct {
+	Name     string                 `json:"name"`
+	Type     json.RawMessage        `json:"type"`
+	Nullable bool                   `json:"nullable"`
+	Metadata map[string]interface{} `json:"metadata"`
+}
+
+type deltaStats struct {
+	NumRecords int64             `json:"numRecords"`
+	MinValues  map[string]string `json:"minValues,omitempty"`
+	MaxValues  map[string]string `json:"maxValues,omitempty"`
+	NullCount  map[string]int64  `json:"nullCount,omitempty"`
+}
+
+type deltaTable struct {
+	store   objectstore.Store
+	layout  *layout
+	retries int
+	log     telegraf.Logger
+
+	// State of the table, the version is negative for new tables
+	version  int64
+	metadata *deltaMetadata
+	fields   []*deltaField
+	cols     []*column
+	pending  []*column
+	parts    []*partition
+}
+
+func newDeltaTable(store objectstore.Store, tl *layout, retries int, log telegraf.Logger) *deltaTable {
+	return &deltaTable{store: store, layout: tl, retries: retries, log: log}
+}
+
+func (t *deltaTable) load(ctx context.Context) error {
+	keys, err := t.store.List(ctx, deltaLogDir)
+	if err != nil {
+		return fmt.Errorf("listing transaction log failed: %w", err)
+	}
+	latest := int64(-1)
+	for _, key := range keys {
+		name := path.Base(key)
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		v, err := strconv.ParseInt(strings.TrimSuffix(name, ".json"), 10, 64)
+		if err == nil && v > latest {
+			latest = v
+		}
+	}
+
+	if latest < 0 {
+		t.create()
+		return nil
+	}
+
+	// Find the most recent protocol and metadata actions
+	var protocol *deltaProtocol
+	var metadata *deltaMetadata
+	for v := latest; v >= 0 && (protocol == nil || metadata == nil); v-- {
+		data, err := t.store.Get(ctx, deltaCommitKey(v))
+		if errors.Is(err, objectstore.ErrNotFound) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("reading commit %d failed: %w", v, err)
+		}
+		for _, line := range bytes.Split(data, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var action deltaAction
+			if err := json.Unmarshal(line, &action); err != nil {
+				return fmt.Errorf("decoding commit %d failed: %w", v, err)
+			}
+			if protocol == nil && action.Protocol != nil {
+				protocol = action.Protocol
+			}
+			if metadata == nil && action.MetaData != nil {
+				metadata = action.MetaData
+			}
+		}
+	}
+	if protocol == nil || metadata == nil {
+		return errors.New("no protocol or metadata found in the transaction log, reading checkpoints is not supported")
+	}
+	if protocol.MinWriterVersion > 2 {
+		return fmt.Errorf("unsupported writer version %d", protocol.MinWriterVersion)
+	}
+
+	var schema deltaSchema
+	if err := json.Unmarshal([]byte(metadata.SchemaString), &schema); err != nil {
+		return fmt.Errorf("decoding schema failed: %w", err)
+	}
+	cols := make([]*column, 0, len(schema.Fields))
+	for _, f := range schema.Fields {
+		var typ string
+		if err := json.Unmarshal(f.Type, &typ); err != nil {
+			// Nested types are not supported
+			typ = ""
+		}
+		cols = append(cols, &column{name: f.Name, typ: typ})
+	}
+
+	parts := make([]*partition, 0, len(metadata.PartitionColumns))
+	for _, name := range metadata.PartitionColumns {
+		var typ string
+		for _, c := range cols {
+			if c.name == name {
+				typ = c.typ
+			}
+		}
+		p := &partition{name: name, column: name}
+		switch {
+		case name == t.layout.measurementColumn && typ == columnar.TypeString:
+			p.source = sourceMeasurement
+		case typ == columnar.TypeDate:
+			p.source = sourceDate
+		case typ == columnar.TypeString:
+			p.source = sourceTag
+		default:
+			return fmt.Errorf("unsupported partition column %q of type %q", name, typ)
+		}
+		parts = append(parts, p)
+	}
+
+	t.version = latest
+	t.metadata = metadata
+	t.fields = schema.Fields
+	t.cols = cols
+	t.parts = parts
+	return nil
+}
+
+// create initializes the state of a new table
+func (t *deltaTable) create() {
+	t.version = -1
+	t.metadata = nil
+	t.fields = nil
+	t.cols = []*column{
+		{name: t.layout.measurementColumn, typ: columnar.TypeString},
+		{name: t.layout.timestampColumn, typ: columnar.TypeTimestamp},
+	}
+	t.parts = make([]*partition, 0, len(t.layout.partitionBy))
+	for _, p := range t.layout.partitionBy {
+		switch p {
+		case "measurement":
+			t.parts = append(t.parts, &partition{name: t.layout.measurementColumn, column: t.layout.measurementColumn, source: sourceMeasurement})
+		case "date":
+			t.cols = append(t.cols, &column{name: deltaDateColumn, typ: columnar.TypeDate})
+			t.parts = append(t.parts, &partition{name: deltaDateColumn, column: deltaDateColumn, source: sourceDate})
+		default:
+			t.cols = append(t.cols, &column{name: p, typ: columnar.TypeString})
+			t.parts = append(t.parts, &partition{name: p, column: p, source: sourceTag})
+		}
+	}
+}
+
+func (t *deltaTable) columns() []*column {
+	return append(append([]*column{}, t.cols...), t.pending...)
+}
+
+func (t *deltaTable) partitions() []*partition {
+	return t.parts
+}
+
+func (t *deltaTable) addColumns(columns []*column) {
+	t.pending = append(t.pending, columns...)
+}
+
+func (t *deltaTable) dataColumns(used map[string]bool) []*column {
+	// Partition values are only stored in the transaction log
+	partitioned := make(map[string]bool, len(t.parts))
+	for _, p := range t.parts {
+		partitioned[p.column] = true
+	}
+
+	columns := make([]*column, 0, len(used)+2)
+	for _, c := range t.columns() {
+		if partitioned[c.name] || columnar.ArrowType(c.typ) == nil {
+			continue
+		}
+		if c.name == t.layout.measurementColumn || c.name == t.layout.timestampColumn || used[c.name] {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+func (t *deltaTable) dataKey(values []*string) string {
+	segments := make([]string, 0, len(values)+1)
+	for i, p := range t.parts {
+		value := hiveDefaultPartition
+		if values[i] != nil {
+			value = columnar.EscapePartition(*values[i])
+		}
+		segments = append(segments, columnar.EscapePartition(p.name)+"="+value)
+	}
+	segments = append(segments, "part-00000-"+uuid.NewString()+".c000.parquet")
+	return strings.Join(segments, "/")
+}
+
+func (t *deltaTable) commit(ctx context.Context, files []*dataFile) error {
+	for attempt := 0; ; attempt++ {
+		version := t.version + 1
+		data, metadata, err := t.actions(files)
+		if err != nil {
+			return err
+		}
+
+		err = t.store.PutIfAbsent(ctx, deltaCommitKey(version), data)
+		if err == nil {
+			t.version = version
+			t.metadata = metadata
+			t.cols = append(t.cols, t.pending...)
+			t.pending = nil
+			return nil
+		}
+		if !errors.Is(err, objectstore.ErrExists) {
+			return fmt.Errorf("writing commit %d failed: %w", version, err)
+		}
+		if attempt >= t.retries {
+			return fmt.Errorf("commit %d conflicts with concurrent commit", version)
+		}
+
+		// Another writer committed the version, so retry on top of it
+		t.log.Debugf("Commit %d conflicts with concurrent commit, retrying...", version)
+		if err := t.reload(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// reload reads the state committed concurrently and keeps the columns added
+// by the pending commit
+func (t *deltaTable) reload(ctx context.Context) error {
+	parts := t.parts
+	pending := t.pending
+	t.pending = nil
+	if err := t.load(ctx); err != nil {
+		return fmt.Errorf("reloading table failed: %w", err)
+	}
+	if len(parts) != len(t.parts) {
+		return errors.New("partitioning changed concurrently")
+	}
+	for i, p := range parts {
+		if p.name != t.parts[i].name || p.source != t.parts[i].source {
+			return errors.New("partitioning changed concurrently")
+		}
+	}
+
+	existing := make(map[string]*column, len(t.cols))
+	for _, c := range t.cols {
+		existing[c.name] = c
+	}
+	for _, c := range pending {
+		if x, found := existing[c.name]; found && x.typ != c.typ {
+			return fmt.Errorf("column %q was added concurrently with type %q", c.name, x.typ)
+		}
+	}
+	for _, c := range pending {
+		if _, found := existing[c.name]; !found {
+			t.pending = append(t.pending, c)
+		}
+	}
+	return nil
+}
+
+// actions returns the content of the commit and the resulting metadata
+func (t *deltaTable) actions(files []*dataFile) ([]byte, *deltaMetadata, error) {
+	now := time.Now()
+	var actions []*deltaAction
+
+	// Create the table with the first commit and update the schema if
+	// columns were added
+	metadata := t.metadata
+	if t.version < 0 || len(t.pending) > 0 {
+		schema, err := t.schema()
+		if err != nil {
+			return nil, nil, err
+		}
+		if t.version < 0 {
+			created := now.UnixMilli()
+			metadata = &deltaMetadata{
+				ID:            uuid.NewString(),
+				Format:        deltaFormat{Provider: "parquet", Options: make(map[string]string)},
+				Configuration: make(map[string]string),
+				CreatedTime:   &created,
+			}
+			metadata.PartitionColumns = make([]string, 0, len(t.parts))
+			for _, p := range t.parts {
+				metadata.PartitionColumns = append(metadata.PartitionColumns, p.name)
+			}
+			actions = append(actions, &deltaAction{Protocol: &deltaProtocol{MinReaderVersion: 1, MinWriterVersion: 2}})
+		} else {
+			m := *metadata
+			metadata = &m
+		}
+		metadata.SchemaString = schema
+		actions = append(actions, &deltaAction{MetaData: metadata})
+	}
+
+	for _, f := range files {
+		values := make(map[string]*string, len(t.parts))
+		for i, p := range t.parts {
+			values[p.name] = f.partitions[i]
+		}
+		stats, err := json.Marshal(&deltaStats{
+			NumRecords: f.records,
+			MinValues:  map[string]string{t.layout.timestampColumn: f.minTime.UTC().Truncate(time.Millisecond).Format(deltaTimeFormat)},
+			MaxValues:  map[string]string{t.layout.timestampColumn: ceilMillisecond(f.maxTime).UTC().Format(deltaTimeFormat)},
+			NullCount:  map[string]int64{t.layout.timestampColumn: 0},
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("encoding statistics failed: %w", err)
+		}
+
+		// The path is a relative URI so the escaped partition values need
+		// to be escaped again
+		segments := strings.Split(f.key, "/")
+		for i, s := range segments {
+			segments[i] = url.PathEscape(s)
+		}
+		actions = append(actions, &deltaAction{Add: &deltaAdd{
+			Path:             strings.Join(segments, "/"),
+			PartitionValues:  values,
+			Size:             f.size,
+			ModificationTime: now.UnixMilli(),
+			DataChange:       true,
+			Stats:            string(stats),
+		}})
+	}
+
+	partitionBy, err := json.Marshal(metadataPartitionColumns(metadata))
+	if err != nil {
+		return nil, nil, err
+	}
+	actions = append(actions, &deltaAction{CommitInfo: &deltaCommitInfo{
+		Timestamp:           now.UnixMilli(),
+		Operation:           "WRITE",
+		OperationParameters: map[string]string{"mode": "Append", "partitionBy": string(partitionBy)},
+		IsBlindAppend:       true,
+		EngineInfo:          internal.ProductToken(),
+	}})
+
+	var buf bytes.Buffer
+	for _, action := range actions {
+		line, err := json.Marshal(action)
+		if err != nil {
+			return nil, nil, fmt.Errorf("encoding action failed: %w", err)
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), metadata, nil
+}
+
+// schema returns the schema string of the table including pending columns,
+// existing fields are kept as is
+func (t *deltaTable) schema() (string, error) {
+	schema := deltaSchema{Type: "struct", Fields: append([]*deltaField{}, t.fields...)}
+	existing := make(map[string]bool, len(t.fields))
+	for _, f := range t.fields {
+		existing[f.Name] = true
+	}
+	for _, c := range t.columns() {
+		if existing[c.name] {
+			continue
+		}
+		typ, err := json.Marshal(c.typ)
+		if err != nil {
+			return "", err
+		}
+		schema.Fields = append(schema.Fields, &deltaField{
+			Name:     c.name,
+			Type:     typ,
+			Nullable: true,
+			Metadata: make(map[string]interface{}),
+		})
+	}
+
+	buf, err := json.Marshal(&schema)
+	if err != nil {
+		return "", fmt.Errorf("encoding schema failed: %w", err)
+	}
+	return string(buf), nil
+}
+
+// Format of timestamps in the file statistics
+const deltaTimeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+func metadataPartitionColumns(metadata *deltaMetadata) []string {
+	if metadata == nil || metadata.PartitionColumns == nil {
+		return []string{}
+	}
+	return metadata.PartitionColumns
+}
+
+func deltaCommitKey(version int64) string {
+	return fmt.Sprintf("%s%020d.json", deltaLogDir, version)
+}
+
+// ceilMillisecond rounds the time up to the next millisecond to keep the
+// time within the maximum of the statistics
+func ceilMillisecond(t time.Time) time.Time {
+	truncated := t.Truncate(time.Millisecond)
+	if truncated.Equal(t) {
+		return t
+	}
+	return truncated.Add(time.Millisecond)
+}
@@ -0,0 +1,944 @@
+package lakehouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/linkedin/goavro/v2"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/common/columnar"
+	"github.com/influxdata/telegraf/plugins/common/objectstore"
+)
+
+// Directories of the Iceberg table
+const (
+	icebergMetadataDir = "metadata/"
+	icebergDataDir     = "data/"
+	icebergVersionHint = icebergMetadataDir + "version-hint.text"
+)
+
+// First ID of partition fields as used by the reference implementation
+const icebergPartitionFieldID = 1000
+
+// Mapping of Iceberg primitive types to column types
+var icebergTypes = map[string]string{
+	"string":      columnar.TypeString,
+	"long":        columnar.TypeLong,
+	"double":      columnar.TypeDouble,
+	"boolean":     columnar.TypeBoolean,
+	"timestamptz": columnar.TypeTimestamp,
+	"date":        columnar.TypeDate,
+}
+
+// Table metadata as far as required for appending data files, see
+// https://iceberg.apache.org/spec/#table-metadata-fields
+type icebergMetadata struct {
+	FormatVersion      int                `json:"format-version"`
+	Location           string             `json:"location"`
+	LastSequenceNumber int64              `json:"last-sequence-number"`
+	LastUpdatedMS      int64              `json:"last-updated-ms"`
+	LastColumnID       int                `json:"last-column-id"`
+	CurrentSchemaID    int                `json:"current-schema-id"`
+	Schemas            []*icebergSchema   `json:"schemas"`
+	DefaultSpecID      int                `json:"default-spec-id"`
+	PartitionSpecs     []*icebergSpec     `json:"partition-specs"`
+	CurrentSnapshotID  *int64             `json:"current-snapshot-id"`
+	Snapshots          []*icebergSnapshot `json:"snapshots"`
+}
+
+type icebergSchema struct {
+	SchemaID int             `json:"schema-id"`
+	Fields   []*icebergField `json:"fields"`
+}
+
+type icebergField struct {
+	ID       int             `json:"id"`
+	Name     string          `json:"name"`
+	Required bool            `json:"required"`
+	Type     json.RawMessage `json:"type"`
+}
+
+type icebergSpec struct {
+	SpecID int                 `json:"spec-id"`
+	Fields []*icebergSpecField `json:"fields"`
+}
+
+type icebergSpecField struct {
+	Name      string `json:"name"`
+	Transform string `json:"transform"`
+	SourceID  int    `json:"source-id"`
+	FieldID   int    `json:"field-id"`
+}
+
+type icebergSnapshot struct {
+	SnapshotID       int64             `json:"snapshot-id"`
+	ParentSnapshotID *int64            `json:"parent-snapshot-id,omitempty"`
+	SequenceNumber   int64             `json:"sequence-number"`
+	TimestampMS      int64             `json:"timestamp-ms"`
+	ManifestList     string            `json:"manifest-list"`
+	Summary          map[string]string `json:"summary"`
+	SchemaID         *int              `json:"schema-id,omitempty"`
+}
+
+type icebergTable struct {
+	store   objectstore.Store
+	layout  *layout
+	retries int
+	log     telegraf.Logger
+
+	// State of the table, the version is zero for new tables and the raw
+	// metadata keeps all properties not handled by the plugin
+	version  int
+	raw      map[string]json.RawMessage
+	metadata *icebergMetadata
+	cols     []*column
+	pending  []*column
+	parts    []*partition
+	lastID   int
+}
+
+func newIcebergTable(store objectstore.Store, tl *layout, retries int, log telegraf.Logger) *icebergTable {
+	return &icebergTable{store: store, layout: tl, retries: retries, log: log}
+}
+
+func (t *icebergTable) load(ctx context.Context) error {
+	version, err := t.currentVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		t.create()
+		return nil
+	}
+
+	data, err := t.store.Get(ctx, icebergMetadataKey(version))
+	if err != nil {
+		return fmt.Errorf("reading metadata version %d failed: %w", version, err)
+	}
+	return t.parse(version, data)
+}
+
+// currentVersion returns the version of the current metadata file using the
+// version hint and falls back to listing the metadata files
+func (t *icebergTable) currentVersion(ctx context.Context) (int, error) {
+	hint, err := t.store.Get(ctx, icebergVersionHint)
+	if err == nil {
+		if v, err := strconv.Atoi(strings.TrimSpace(string(hint))); err == nil && v > 0 {
+			// The hint might be outdated if a writer failed after the commit
+			for {
+				_, err := t.store.Get(ctx, icebergMetadataKey(v+1))
+				if errors.Is(err, objectstore.ErrNotFound) {
+					return v, nil
+				}
+				if err != nil {
+					return 0, fmt.Errorf("reading metadata version %d failed: %w", v+1, err)
+				}
+				v++
+			}
+		}
+	} else if !errors.Is(err, objectstore.ErrNotFound) {
+		return 0, fmt.Errorf("reading version hint failed: %w", err)
+	}
+
+	keys, err := t.store.List(ctx, icebergMetadataDir)
+	if err != nil {
+		return 0, fmt.Errorf("listing metadata failed: %w", err)
+	}
+	var version int
+	for _, key := range keys {
+		name := path.Base(key)
+		if !strings.HasPrefix(name, "v") || !strings.HasSuffix(name, ".metadata.json") {
+			continue
+		}
+		v, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(name, "v"), ".metadata.json"))
+		if err == nil && v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// parse sets the state of the table from the given metadata file
+func (t *icebergTable) parse(version int, data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("decoding metadata failed: %w", err)
+	}
+	var metadata icebergMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return fmt.Errorf("decoding metadata failed: %w", err)
+	}
+	if metadata.FormatVersion != 2 {
+		return fmt.Errorf("unsupported format version %d", metadata.FormatVersion)
+	}
+
+	var schema *icebergSchema
+	for _, s := range metadata.Schemas {
+		if s.SchemaID == metadata.CurrentSchemaID {
+			schema = s
+		}
+	}
+	if schema == nil {
+		return fmt.Errorf("current schema %d not found", metadata.CurrentSchemaID)
+	}
+	cols := make([]*column, 0, len(schema.Fields))
+	for _, f := range schema.Fields {
+		var typ string
+		if err := json.Unmarshal(f.Type, &typ); err == nil {
+			typ = icebergTypes[typ]
+		}
+		cols = append(cols, &column{name: f.Name, typ: typ, id: f.ID})
+	}
+
+	var spec *icebergSpec
+	for _, s := range metadata.PartitionSpecs {
+		if s.SpecID == metadata.DefaultSpecID {
+			spec = s
+		}
+	}
+	if spec == nil {
+		return fmt.Errorf("default partition spec %d not found", metadata.DefaultSpecID)
+	}
+	parts := make([]*partition, 0, len(spec.Fields))
+	for _, f := range spec.Fields {
+		var source *column
+		for _, c := range cols {
+			if c.id == f.SourceID {
+				source = c
+			}
+		}
+		if source == nil {
+			return fmt.Errorf("source column %d of partition %q not found", f.SourceID, f.Name)
+		}
+		p := &partition{name: f.Name, column: source.name, id: f.FieldID}
+		switch {
+		case f.Transform == "identity" && source.name == t.layout.measurementColumn && source.typ == columnar.TypeString:
+			p.source = sourceMeasurement
+		case f.Transform == "identity" && source.typ == columnar.TypeString:
+			p.source = sourceTag
+		case f.Transform == "day" && source.name == t.layout.timestampColumn && source.typ == columnar.TypeTimestamp:
+			p.source = sourceDate
+		default:
+			return fmt.Errorf("unsupported partition %q with transform %q of column %q", f.Name, f.Transform, source.name)
+		}
+		parts = append(parts, p)
+	}
+
+	t.version = version
+	t.raw = raw
+	t.metadata = &metadata
+	t.cols = cols
+	t.parts = parts
+	t.lastID = metadata.LastColumnID
+	return nil
+}
+
+// create initializes the state of a new table
+func (t *icebergTable) create() {
+	t.version = 0
+	t.raw = nil
+	t.metadata = nil
+	t.cols = []*column{
+		{name: t.layout.measurementColumn, typ: columnar.TypeString, id: 1},
+		{name: t.layout.timestampColumn, typ: columnar.TypeTimestamp, id: 2},
+	}
+	t.parts = make([]*partition, 0, len(t.layout.partitionBy))
+	for i, p := range t.layout.partitionBy {
+		id := icebergPartitionFieldID + i
+		switch p {
+		case "measurement":
+			t.parts = append(t.parts, &partition{name: t.layout.measurementColumn, column: t.layout.measurementColumn, source: sourceMeasurement, id: id})
+		case "date":
+			t.parts = append(t.parts, &partition{name: t.layout.timestampColumn + "_day", column: t.layout.timestampColumn, source: sourceDate, id: id})
+		default:
+			t.cols = append(t.cols, &column{name: p, typ: columnar.TypeString, id: len(t.cols) + 1})
+			t.parts = append(t.parts, &partition{name: p, column: p, source: sourceTag, id: id})
+		}
+	}
+	t.lastID = len(t.cols)
+}
+
+func (t *icebergTable) columns() []*column {
+	return append(append([]*column{}, t.cols...), t.pending...)
+}
+
+func (t *icebergTable) partitions() []*partition {
+	return t.parts
+}
+
+func (t *icebergTable) addColumns(columns []*column) {
+	// Field IDs are part of the data files so assign them immediately
+	for _, c := range columns {
+		t.lastID++
+		c.id = t.lastID
+	}
+	t.pending = append(t.pending, columns...)
+}
+
+func (t *icebergTable) dataColumns(used map[string]bool) []*column {
+	// Iceberg stores the source columns of partitions in the data files
+	columns := make([]*column, 0, len(used)+2)
+	for _, c := range t.columns() {
+		if columnar.ArrowType(c.typ) == nil {
+			continue
+		}
+		if c.name == t.layout.measurementColumn || c.name == t.layout.timestampColumn || used[c.name] {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+func (t *icebergTable) dataKey(values []*string) string {
+	segments := make([]string, 0, len(values)+2)
+	segments = append(segments, strings.TrimSuffix(icebergDataDir, "/"))
+	for i, p := range t.parts {
+		value := "null"
+		if values[i] != nil {
+			value = columnar.EscapePartition(*values[i])
+		}
+		segments = append(segments, columnar.EscapePartition(p.name)+"="+value)
+	}
+	segments = append(segments, uuid.NewString()+".parquet")
+	return strings.Join(segments, "/")
+}
+
+func (t *icebergTable) commit(ctx context.Context, files []*dataFile) error {
+	snapshotID := newSnapshotID()
+	for attempt := 0; ; attempt++ {
+		version := t.version + 1
+		data, err := t.snapshot(ctx, snapshotID, files)
+		if err != nil {
+			return err
+		}
+
+		err = t.store.PutIfAbsent(ctx, icebergMetadataKey(version), data)
+		if err == nil {
+			if err := t.parse(version, data); err != nil {
+				return err
+			}
+			t.pending = nil
+
+			// The hint is only an optimization for readers so failing to
+			// update it does not fail the commit
+			if err := t.store.Put(ctx, icebergVersionHint, []byte(strconv.Itoa(version))); err != nil {
+				t.log.Warnf("Updating version hint failed: %v", err)
+			}
+			return nil
+		}
+		if !errors.Is(err, objectstore.ErrExists) {
+			return fmt.Errorf("writing metadata version %d failed: %w", version, err)
+		}
+		if attempt >= t.retries {
+			return fmt.Errorf("metadata version %d conflicts with concurrent commit", version)
+		}
+
+		// Another writer committed the version, so retry on top of it
+		t.log.Debugf("Metadata version %d conflicts with concurrent commit, retrying...", version)
+		if err := t.reload(ctx); err != nil {
+			return err
+		}
+	}
+}
+
+// reload reads the state committed concurrently and keeps the columns added
+// by the pending commit as long as the field IDs of the written data files
+// are still valid
+func (t *icebergTable) reload(ctx context.Context) error {
+	cols := t.cols
+	parts := t.parts
+	pending := t.pending
+	t.pending = nil
+	if err := t.load(ctx); err != nil {
+		return fmt.Errorf("reloading table failed: %w", err)
+	}
+	if len(parts) != len(t.parts) {
+		return errors.New("partitioning changed concurrently")
+	}
+	for i, p := range parts {
+		if p.name != t.parts[i].name || p.source != t.parts[i].source || p.id != t.parts[i].id {
+			return errors.New("partitioning changed concurrently")
+		}
+	}
+
+	existing := make(map[string]*column, len(t.cols))
+	for _, c := range t.cols {
+		existing[c.name] = c
+	}
+	for _, c := range cols {
+		if x, found := existing[c.name]; !found || x.id != c.id || x.typ != c.typ {
+			return fmt.Errorf("column %q changed concurrently", c.name)
+		}
+	}
+	for _, c := range pending {
+		x, found := existing[c.name]
+		if found && (x.id != c.id || x.typ != c.typ) || !found && c.id <= t.lastID {
+			return fmt.Errorf("column %q conflicts with concurrent schema change", c.name)
+		}
+	}
+	for _, c := range pending {
+		if _, found := existing[c.name]; !found {
+			t.pending = append(t.pending, c)
+			t.lastID = c.id
+		}
+	}
+	return nil
+}
+
+// snapshot writes the manifest and manifest list for the data files and
+// returns the resulting table metadata
+func (t *icebergTable) snapshot(ctx context.Context, snapshotID int64, files []*dataFile) ([]byte, error) {
+	now := time.Now().UnixMilli()
+
+	metadata := t.metadata
+	if metadata == nil {
+		metadata = &icebergMetadata{
+			FormatVersion: 2,
+			Location:      strings.TrimSuffix(t.store.URL(""), "/"),
+		}
+	}
+	sequence := metadata.LastSequenceNumber + 1
+
+	// Determine the schema of the snapshot
+	raw := make(map[string]json.RawMessage, len(t.raw))
+	for k, v := range t.raw {
+		raw[k] = v
+	}
+	schemaID := metadata.CurrentSchemaID
+	if t.metadata == nil || len(t.pending) > 0 {
+		for _, s := range metadata.Schemas {
+			schemaID = max(schemaID, s.SchemaID+1)
+		}
+	}
+	schema, err := t.schema(schemaID)
+	if err != nil {
+		return nil, err
+	}
+	if schemaID != metadata.CurrentSchemaID || t.metadata == nil {
+		if err := appendRaw(raw, "schemas", schema); err != nil {
+			return nil, err
+		}
+	}
+	specID := metadata.DefaultSpecID
+	spec := t.spec(specID)
+
+	// Write the manifest of the new data files
+	manifest, err := t.manifest(schema, spec, snapshotID, files)
+	if err != nil {
+		return nil, err
+	}
+	manifestKey := icebergMetadataDir + uuid.NewString() + "-m0.avro"
+	if err := t.store.Put(ctx, manifestKey, manifest); err != nil {
+		return nil, fmt.Errorf("writing manifest failed: %w", err)
+	}
+
+	// Write the manifest list containing the manifests of the parent
+	// snapshot and the new manifest
+	var parent *icebergSnapshot
+	if metadata.CurrentSnapshotID != nil {
+		for _, s := range metadata.Snapshots {
+			if s.SnapshotID == *metadata.CurrentSnapshotID {
+				parent = s
+			}
+		}
+	}
+	var entries []interface{}
+	if parent != nil {
+		if entries, err = t.manifests(ctx, parent.ManifestList); err != nil {
+			return nil, err
+		}
+	}
+	var records, size int64
+	for _, f := range files {
+		records += f.records
+		size += f.size
+	}
+	entries = append(entries, map[string]interface{}{
+		"manifest_path":        t.store.URL(manifestKey),
+		"manifest_length":      int64(len(manifest)),
+		"partition_spec_id":    int32(specID),
+		"content":              int32(0),
+		"sequence_number":      sequence,
+		"min_sequence_number":  sequence,
+		"added_snapshot_id":    snapshotID,
+		"added_files_count":    int32(len(files)),
+		"existing_files_count": int32(0),
+		"deleted_files_count":  int32(0),
+		"added_rows_count":     records,
+		"existing_rows_count":  int64(0),
+		"deleted_rows_count":   int64(0),
+		"partitions":           map[string]interface{}{"array": t.summaries(files)},
+	})
+	var buf bytes.Buffer
+	writer, err := goavro.NewOCFWriter(goavro.OCFConfig{
+		W:      &buf,
+		Schema: icebergManifestListSchema,
+		MetaData: map[string][]byte{
+			"snapshot-id":        []byte(strconv.FormatInt(snapshotID, 10)),
+			"parent-snapshot-id": []byte(parentSnapshotID(parent)),
+			"sequence-number":    []byte(strconv.FormatInt(sequence, 10)),
+			"format-version":     []byte("2"),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating manifest list writer failed: %w", err)
+	}
+	if err := writer.Append(entries); err != nil {
+		return nil, fmt.Errorf("encoding manifest list failed: %w", err)
+	}
+	listKey := fmt.Sprintf("%ssnap-%d-%s.avro", icebergMetadataDir, snapshotID, uuid.NewString())
+	if err := t.store.Put(ctx, listKey, buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("writing manifest list failed: %w", err)
+	}
+
+	// Create the metadata referencing the new snapshot
+	summary := map[string]string{
+		"operation":        "append",
+		"added-data-files": strconv.Itoa(len(files)),
+		"added-records":    strconv.FormatInt(records, 10),
+		"added-files-size": strconv.FormatInt(size, 10),
+	}
+	totals := map[string]int64{
+		"total-data-files": int64(len(files)),
+		"total-records":    records,
+		"total-files-size": size,
+	}
+	for k, v := range totals {
+		if parent == nil {
+			summary[k] = strconv.FormatInt(v, 10)
+		} else if total, err := strconv.ParseInt(parent.Summary[k], 10, 64); err == nil {
+			summary[k] = strconv.FormatInt(total+v, 10)
+		}
+	}
+	snapshot := &icebergSnapshot{
+		SnapshotID:     snapshotID,
+		SequenceNumber: sequence,
+		TimestampMS:    now,
+		ManifestList:   t.store.URL(listKey),
+		Summary:        summary,
+		SchemaID:       &schemaID,
+	}
+	if parent != nil {
+		snapshot.ParentSnapshotID = &parent.SnapshotID
+	}
+
+	if t.metadata == nil {
+		// Properties of a new table
+		values := map[string]interface{}{
+			"format-version":        2,
+			"table-uuid":            uuid.NewString(),
+			"location":              metadata.Location,
+			"default-spec-id":       specID,
+			"partition-specs":       []interface{}{spec},
+			"last-partition-id":     icebergPartitionFieldID + len(t.parts) - 1,
+			"default-sort-order-id": 0,
+			"sort-orders":           []interface{}{map[string]interface{}{"order-id": 0, "fields": []interface{}{}}},
+			"properties":            map[string]string{},
+		}
+		for k, v := range values {
+			if err := setRaw(raw, k, v); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		entry := map[string]interface{}{
+			"metadata-file": t.store.URL(icebergMetadataKey(t.version)),
+			"timestamp-ms":  metadata.LastUpdatedMS,
+		}
+		if err := appendRaw(raw, "metadata-log", entry); err != nil {
+			return nil, err
+		}
+	}
+
+	var refs map[string]json.RawMessage
+	if r, found := raw["refs"]; found {
+		if err := json.Unmarshal(r, &refs); err != nil {
+			return nil, fmt.Errorf("decoding refs failed: %w", err)
+		}
+	}
+	if refs == nil {
+		refs = make(map[string]json.RawMessage)
+	}
+	main, err := json.Marshal(map[string]interface{}{"snapshot-id": snapshotID, "type": "branch"})
+	if err != nil {
+		return nil, err
+	}
+	refs["main"] = main
+
+	values := map[string]interface{}{
+		"last-sequence-number": sequence,
+		"last-updated-ms":      now,
+		"last-column-id":       max(t.lastID, metadata.LastColumnID),
+		"current-schema-id":    schemaID,
+		"current-snapshot-id":  snapshotID,
+		"refs":                 refs,
+	}
+	for k, v := range values {
+		if err := setRaw(raw, k, v); err != nil {
+			return nil, err
+		}
+	}
+	if err := appendRaw(raw, "snapshots", snapshot); err != nil {
+		return nil, err
+	}
+	if err := appendRaw(raw, "snapshot-log", map[string]interface{}{"snapshot-id": snapshotID, "timestamp-ms": now}); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("encoding metadata failed: %w", err)
+	}
+	return data, nil
+}
+
+// schema returns the JSON representation of the table schema including the
+// pending columns
+func (t *icebergTable) schema(id int) (map[string]interface{}, error) {
+	fields := make([]interface{}, 0, len(t.cols)+len(t.pending))
+
+	// Keep the existing fields as is to preserve all properties
+	if t.metadata != nil {
+		current := make(map[string]json.RawMessage)
+		if err := unmarshalSchema(t.raw, t.metadata.CurrentSchemaID, current); err != nil {
+			return nil, err
+		}
+		var existing []json.RawMessage
+		if err := json.Unmarshal(current["fields"], &existing); err != nil {
+			return nil, fmt.Errorf("decoding schema fields failed: %w", err)
+		}
+		for _, f := range existing {
+			fields = append(fields, f)
+		}
+	} else {
+		for _, c := range t.cols {
+			fields = append(fields, icebergNewField(c))
+		}
+	}
+	for _, c := range t.pending {
+		fields = append(fields, icebergNewField(c))
+	}
+
+	return map[string]interface{}{
+		"type":      "struct",
+		"schema-id": id,
+		"fields":    fields,
+	}, nil
+}
+
+// spec returns the JSON representation of the partition spec
+func (t *icebergTable) spec(id int) map[string]interface{} {
+	ids := make(map[string]int, len(t.cols))
+	for _, c := range t.cols {
+		ids[c.name] = c.id
+	}
+	fields := make([]interface{}, 0, len(t.parts))
+	for _, p := range t.parts {
+		transform := "identity"
+		if p.source == sourceDate {
+			transform = "day"
+		}
+		fields = append(fields, &icebergSpecField{
+			Name:      p.name,
+			Transform: transform,
+			SourceID:  ids[p.column],
+			FieldID:   p.id,
+		})
+	}
+	return map[string]interface{}{"spec-id": id, "fields": fields}
+}
+
+// manifest returns the Avro encoded manifest for the data files
+func (t *icebergTable) manifest(schema, spec map[string]interface{}, snapshotID int64, files []*dataFile) ([]byte, error) {
+	partitionFields := make([]string, 0, len(t.parts))
+	for _, p := range t.parts {
+		typ := `"string"`
+		if p.source == sourceDate {
+			typ = `{"type":"int","logicalType":"date"}`
+		}
+		partitionFields = append(partitionFields, fmt.Sprintf(`{"name":%q,"type":["null",%s],"default":null,"field-id":%d}`, avroName(p.name), typ, p.id))
+	}
+	avroSchema := fmt.Sprintf(icebergManifestSchema, strings.Join(partitionFields, ","))
+
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	specJSON, err := json.Marshal(spec["fields"])
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]interface{}, 0, len(files))
+	for _, f := range files {
+		values := make(map[string]interface{}, len(t.parts))
+		for i, p := range t.parts {
+			v := f.partitions[i]
+			switch {
+			case v == nil:
+				values[avroName(p.name)] = nil
+			case p.source == sourceDate:
+				day, err := time.Parse("2006-01-02", *v)
+				if err != nil {
+					return nil, err
+				}
+				values[avroName(p.name)] = map[string]interface{}{"int.date": day}
+			default:
+				values[avroName(p.name)] = map[string]interface{}{"string": *v}
+			}
+		}
+		entries = append(entries, map[string]interface{}{
+			"status":               int32(1),
+			"snapshot_id":          map[string]interface{}{"long": snapshotID},
+			"sequence_number":      nil,
+			"file_sequence_number": nil,
+			"data_file": map[string]interface{}{
+				"content":            int32(0),
+				"file_path":          t.store.URL(f.key),
+				"file_format":        "PARQUET",
+				"partition":          values,
+				"record_count":       f.records,
+				"file_size_in_bytes": f.size,
+			},
+		})
+	}
+
+	var buf bytes.Buffer
+	writer, err := goavro.NewOCFWriter(goavro.OCFConfig{
+		W:      &buf,
+		Schema: avroSchema,
+		MetaData: map[string][]byte{
+			"schema":            schemaJSON,
+			"schema-id":         []byte(strconv.Itoa(schema["schema-id"].(int))),
+			"partition-spec":    specJSON,
+			"partition-spec-id": []byte(strconv.Itoa(spec["spec-id"].(int))),
+			"format-version":    []byte("2"),
+			"content":           []byte("data"),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating manifest writer failed: %w", err)
+	}
+	if err := writer.Append(entries); err != nil {
+		return nil, fmt.Errorf("encoding manifest failed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// summaries returns the partition field summaries of the data files
+func (t *icebergTable) summaries(files []*dataFile) []interface{} {
+	summaries := make([]interface{}, 0, len(t.parts))
+	for i, p := range t.parts {
+		var hasNull bool
+		var lower, upper *string
+		for _, f := range files {
+			v := f.partitions[i]
+			if v == nil {
+				hasNull = true
+				continue
+			}
+			if lower == nil || *v < *lower {
+				lower = v
+			}
+			if upper == nil || *v > *upper {
+				upper = v
+			}
+		}
+		summary := map[string]interface{}{
+			"contains_null": hasNull,
+			"contains_nan":  nil,
+			"lower_bound":   nil,
+			"upper_bound":   nil,
+		}
+		if lower != nil {
+			summary["lower_bound"] = map[string]interface{}{"bytes": icebergBound(p, *lower)}
+			summary["upper_bound"] = map[string]interface{}{"bytes": icebergBound(p, *upper)}
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries
+}
+
+// manifests returns the entries of the given manifest list
+func (t *icebergTable) manifests(ctx context.Context, location string) ([]interface{}, error) {
+	key, found := t.key(location)
+	if !found {
+		return nil, fmt.Errorf("manifest list %q outside of the table location", location)
+	}
+	data, err := t.store.Get(ctx, key)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest list failed: %w", err)
+	}
+	reader, err := goavro.NewOCFReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decoding manifest list failed: %w", err)
+	}
+	var entries []interface{}
+	for reader.Scan() {
+		entry, err := reader.Read()
+		if err != nil {
+			return nil, fmt.Errorf("decoding manifest list failed: %w", err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, reader.Err()
+}
+
+// key returns the store key of the given location
+func (t *icebergTable) key(location string) (string, bool) {
+	root := t.store.URL("")
+	if !strings.HasSuffix(root, "/") {
+		root += "/"
+	}
+	if !strings.HasPrefix(location, root) {
+		return "", false
+	}
+	return strings.TrimPrefix(location, root), true
+}
+
+func icebergMetadataKey(version int) string {
+	return fmt.Sprintf("%sv%d.metadata.json", icebergMetadataDir, version)
+}
+
+func icebergNewField(c *column) *icebergField {
+	typ := c.typ
+	if typ == columnar.TypeTimestamp {
+		typ = "timestamptz"
+	}
+	raw, _ := json.Marshal(typ)
+	return &icebergField{ID: c.id, Name: c.name, Type: raw}
+}
+
+// icebergBound returns the single-value serialization of the partition value
+func icebergBound(p *partition, value string) []byte {
+	if p.source != sourceDate {
+		return []byte(value)
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return nil
+	}
+	return binary.LittleEndian.AppendUint32(nil, uint32(int32(day.Unix()/86400)))
+}
+
+// newSnapshotID returns a random positive snapshot ID
+func newSnapshotID() int64 {
+	id := uuid.New()
+	msb := binary.BigEndian.Uint64(id[:8])
+	lsb := binary.BigEndian.Uint64(id[8:])
+	return int64((msb ^ lsb) & math.MaxInt64)
+}
+
+func parentSnapshotID(parent *icebergSnapshot) string {
+	if parent == nil {
+		return "null"
+	}
+	return strconv.FormatInt(parent.SnapshotID, 10)
+}
+
+// avroName replaces characters not allowed in Avro names the same way as
+// the reference implementation
+func avroName(name string) string {
+	var buf strings.Builder
+	for i, r := range name {
+		valid := r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || i > 0 && r >= '0' && r <= '9'
+		switch {
+		case valid:
+			buf.WriteRune(r)
+		case r >= '0' && r <= '9':
+			buf.WriteString("_" + string(r))
+		default:
+			fmt.Fprintf(&buf, "_x%X", r)
+		}
+	}
+	return buf.String()
+}
+
+func unmarshalSchema(raw map[string]json.RawMessage, id int, schema map[string]json.RawMessage) error {
+	var schemas []map[string]json.RawMessage
+	if err := json.Unmarshal(raw["schemas"], &schemas); err != nil {
+		return fmt.Errorf("decoding schemas failed: %w", err)
+	}
+	for _, s := range schemas {
+		var sid int
+		if err := json.Unmarshal(s["schema-id"], &sid); err == nil && sid == id {
+			for k, v := range s {
+				schema[k] = v
+			}
+			return nil
+		}
+	}
+	return fmt.Errorf("schema %d not found", id)
+}
+
+func setRaw(raw map[string]json.RawMessage, key string, value interface{}) error {
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encoding %q failed: %w", key, err)
+	}
+	raw[key] = buf
+	return nil
+}
+
+func appendRaw(raw map[string]json.RawMessage, key string, value interface{}) error {
+	var list []json.RawMessage
+	if r, found := raw[key]; found && string(r) != "null" {
+		if err := json.Unmarshal(r, &list); err != nil {
+			return fmt.Errorf("decoding %q failed: %w", key, err)
+		}
+	}
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("encoding %q failed: %w", key, err)
+	}
+	return setRaw(raw, key, append(list, buf))
+}
+
+// Avro schema of manifest files with the partition fields as parameter, see
+// https://iceberg.apache.org/spec/#manifests
+const icebergManifestSchema = `{"type":"record","name":"manifest_entry","fields":[` +
+	`{"name":"status","type":"int","field-id":0},` +
+	`{"name":"snapshot_id","type":["null","long"],"default":null,"field-id":1},` +
+	`{"name":"sequence_number","type":["null","long"],"default":null,"field-id":3},` +
+	`{"name":"file_sequence_number","type":["null","long"],"default":null,"field-id":4},` +
+	`{"name":"data_file","type":{"type":"record","name":"r2","fields":[` +
+	`{"name":"content","type":"int","field-id":134},` +
+	`{"name":"file_path","type":"string","field-id":100},` +
+	`{"name":"file_format","type":"string","field-id":101},` +
+	`{"name":"partition","type":{"type":"record","name":"r102","fields":[%s]},"field-id":102},` +
+	`{"name":"record_count","type":"long","field-id":103},` +
+	`{"name":"file_size_in_bytes","type":"long","field-id":104}` +
+	`]},"field-id":2}]}`
+
+// Avro schema of manifest lists, see https://iceberg.apache.org/spec/#manifest-lists
+const icebergManifestListSchema = `{"type":"record","name":"manifest_file","fields":[` +
+	`{"name":"manifest_path","type":"string","field-id":500},` +
+	`{"name":"manifest_length","type":"long","field-id":501},` +
+	`{"name":"partition_spec_id","type":"int","field-id":502},` +
+	`{"name":"content","type":"int","field-id":517},` +
+	`{"name":"sequence_number","type":"long","field-id":515},` +
+	`{"name":"min_sequence_number","type":"long","field-id":516},` +
+	`{"name":"added_snapshot_id","type":"long","field-id":503},` +
+	`{"name":"added_files_count","type":"int","field-id":504},` +
+	`{"name":"existing_files_count","type":"int","field-id":505},` +
+	`{"name":"deleted_files_count","type":"int","field-id":506},` +
+	`{"name":"added_rows_count","type":"long","field-id":512},` +
+	`{"name":"existing_rows_count","type":"long","field-id":513},` +
+	`{"name":"deleted_rows_count","type":"long","field-id":514},` +
+	`{"name":"partitions","type":["null",{"type":"array","items":{"type":"record","name":"r508","fields":[` +
+	`{"name":"contains_null","type":"boolean","field-id":509},` +
+	`{"name":"contains_nan","type":["null","boolean"],"default":null,"field-id":518},` +
+	`{"name":"lower_bound","type":["null","bytes"],"default":null,"field-id":510},` +
+	`{"name":"upper_bound","type":["null","bytes"],"default":null,"field-id":511}` +
+	`]},"element-id":508}],"default":null,"field-id":507}]}`
@@ -0,0 +1,318 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package lakehouse
+
+import (
+	"context"
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/common/columnar"
+	"github.com/influxdata/telegraf/plugins/common/objectstore"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// table is the format specific part of a lakehouse table
+type table interface {
+	// load reads the current state of the table, the table is created on
+	// the first commit if it does not exist
+	load(ctx context.Context) error
+
+	// columns returns the schema of the table including added columns
+	columns() []*column
+
+	// partitions returns the partitioning of the table
+	partitions() []*partition
+
+	// addColumns extends the schema with the given columns on the next commit
+	addColumns(columns []*column)
+
+	// dataColumns returns the columns stored in the data files
+	dataColumns(used map[string]bool) []*column
+
+	// dataKey returns the key of a new data file for the partition values
+	dataKey(values []*string) string
+
+	// commit adds the data files to the table
+	commit(ctx context.Context, files []*dataFile) error
+}
+
+// layout defines the columns and partitioning of new tables
+type layout struct {
+	measurementColumn string
+	timestampColumn   string
+	partitionBy       []string
+}
+
+type Lakehouse struct {
+	Location          string          `toml:"location"`
+	Format            string          `toml:"format"`
+	PartitionBy       []string        `toml:"partition_by"`
+	MeasurementColumn string          `toml:"measurement_column"`
+	TimestampColumn   string          `toml:"timestamp_column"`
+	Compression       string          `toml:"compression"`
+	CommitRetries     int             `toml:"commit_retries"`
+	Timeout           config.Duration `toml:"timeout"`
+	Log               telegraf.Logger `toml:"-"`
+	objectstore.Config
+
+	store   objectstore.Store
+	table   table
+	encoder *encoder
+}
+
+func (*Lakehouse) SampleConfig() string {
+	return sampleConfig
+}
+
+func (l *Lakehouse) Init() error {
+	if l.Location == "" {
+		return errors.New("missing 'location'")
+	}
+
+	switch l.Format {
+	case "":
+		l.Format = "delta"
+	case "delta", "iceberg":
+	default:
+		return fmt.Errorf("invalid 'format' %q", l.Format)
+	}
+
+	if l.MeasurementColumn == "" {
+		l.MeasurementColumn = "measurement"
+	}
+	if l.TimestampColumn == "" {
+		l.TimestampColumn = "timestamp"
+	}
+	if l.MeasurementColumn == l.TimestampColumn {
+		return errors.New("'measurement_column' and 'timestamp_column' must differ")
+	}
+	for i, p := range l.PartitionBy {
+		if p == "" || slices.Contains(l.PartitionBy[:i], p) {
+			return fmt.Errorf("invalid or duplicate partition %q", p)
+		}
+		if p == l.TimestampColumn {
+			return fmt.Errorf("partitioning by %q is not supported, use \"date\" instead", p)
+		}
+	}
+
+	if l.Compression == "" {
+		l.Compression = "snappy"
+	}
+	codec, found := columnar.Compressions[l.Compression]
+	if !found {
+		return fmt.Errorf("invalid 'compression' %q", l.Compression)
+	}
+	if l.CommitRetries < 0 {
+		return errors.New("'commit_retries' must not be negative")
+	}
+
+	l.encoder = &encoder{
+		measurementColumn: l.MeasurementColumn,
+		timestampColumn:   l.TimestampColumn,
+		compression:       codec,
+		fieldIDs:          l.Format == "iceberg",
+	}
+
+	return nil
+}
+
+func (l *Lakehouse) Connect() error {
+	store, err := l.Config.NewStore(l.Location)
+	if err != nil {
+		return fmt.Errorf("creating store failed: %w", err)
+	}
+	l.store = store
+
+	tl := &layout{
+		measurementColumn: l.MeasurementColumn,
+		timestampColumn:   l.TimestampColumn,
+		partitionBy:       l.PartitionBy,
+	}
+	switch l.Format {
+	case "delta":
+		l.table = newDeltaTable(store, tl, l.CommitRetries, l.Log)
+	case "iceberg":
+		l.table = newIcebergTable(store, tl, l.CommitRetries, l.Log)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(l.Timeout))
+	defer cancel()
+	if err := l.table.load(ctx); err != nil {
+		return fmt.Errorf("loading table failed: %w", err)
+	}
+
+	return nil
+}
+
+func (l *Lakehouse) Close() error {
+	if l.store == nil {
+		return nil
+	}
+	return l.store.Close()
+}
+
+func (l *Lakehouse) Write(metrics []telegraf.Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	// Group the metrics by partition and extend the schema by new tags and
+	// fields, the type of a new column is determined by the first value
+	partitions := l.table.partitions()
+	known := make(map[string]bool)
+	for _, c := range l.table.columns() {
+		known[c.name] = true
+	}
+	var added []*column
+	add := func(name, typ string) {
+		if known[name] {
+			return
+		}
+		known[name] = true
+		added = append(added, &column{name: name, typ: typ})
+	}
+
+	groups := make(map[string]*group)
+	order := make([]string, 0)
+	for _, m := range metrics {
+		values := partitionValues(m, partitions)
+		key := partitionKey(values)
+		g, found := groups[key]
+		if !found {
+			g = &group{values: values, used: make(map[string]bool)}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.metrics = append(g.metrics, m)
+
+		for _, tag := range m.TagList() {
+			if tag.Key == l.MeasurementColumn || tag.Key == l.TimestampColumn {
+				continue
+			}
+			add(tag.Key, columnar.TypeString)
+			g.used[tag.Key] = true
+		}
+		for _, field := range m.FieldList() {
+			if field.Key == l.MeasurementColumn || field.Key == l.TimestampColumn {
+				continue
+			}
+			typ, ok := columnar.ColumnType(field.Value)
+			if !ok {
+				l.Log.Debugf("Ignoring field %q of unsupported type %T", field.Key, field.Value)
+				continue
+			}
+			// The table formats do not support unsigned integers
+			if typ == columnar.TypeUnsigned {
+				typ = columnar.TypeLong
+			}
+			add(field.Key, typ)
+			g.used[field.Key] = true
+		}
+	}
+	if len(added) > 0 {
+		// Sort new columns to get a deterministic schema
+		slices.SortStableFunc(added, func(a, b *column) int { return strings.Compare(a.name, b.name) })
+		l.table.addColumns(added)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(l.Timeout))
+	defer cancel()
+
+	// Write one data file per partition
+	files := make([]*dataFile, 0, len(order))
+	for _, key := range order {
+		g := groups[key]
+		columns := l.table.dataColumns(g.used)
+		data, err := l.encoder.encode(g.metrics, columns)
+		if err != nil {
+			return fmt.Errorf("encoding data file failed: %w", err)
+		}
+
+		f := &dataFile{
+			key:        l.table.dataKey(g.values),
+			size:       int64(len(data)),
+			records:    int64(len(g.metrics)),
+			partitions: g.values,
+		}
+		for i, m := range g.metrics {
+			if i == 0 || m.Time().Before(f.minTime) {
+				f.minTime = m.Time()
+			}
+			if i == 0 || m.Time().After(f.maxTime) {
+				f.maxTime = m.Time()
+			}
+		}
+		if err := l.store.Put(ctx, f.key, data); err != nil {
+			return fmt.Errorf("uploading data file %q failed: %w", f.key, err)
+		}
+		files = append(files, f)
+	}
+
+	if err := l.table.commit(ctx, files); err != nil {
+		return fmt.Errorf("committing data files failed: %w", err)
+	}
+	l.Log.Debugf("Committed %d data files with %d metrics", len(files), len(metrics))
+
+	return nil
+}
+
+// partitionValues returns the values of the partitions for the metric,
+// missing values are represented as nil
+func partitionValues(m telegraf.Metric, partitions []*partition) []*string {
+	values := make([]*string, 0, len(partitions))
+	for _, p := range partitions {
+		var value string
+		switch p.source {
+		case sourceMeasurement:
+			value = m.Name()
+		case sourceDate:
+			value = m.Time().UTC().Format("2006-01-02")
+		case sourceTag:
+			v, found := m.GetTag(p.column)
+			if !found {
+				values = append(values, nil)
+				continue
+			}
+			value = v
+		}
+		values = append(values, &value)
+	}
+	return values
+}
+
+func partitionKey(values []*string) string {
+	var key strings.Builder
+	for _, v := range values {
+		if v == nil {
+			key.WriteString("\x01")
+		} else {
+			key.WriteString("\x02" + *v)
+		}
+		key.WriteString("\x00")
+	}
+	return key.String()
+}
+
+func init() {
+	outputs.Add("lakehouse", func() telegraf.Output {
+		return &Lakehouse{
+			Format:            "delta",
+			PartitionBy:       []string{"measurement", "date"},
+			MeasurementColumn: "measurement",
+			TimestampColumn:   "timestamp",
+			Compression:       "snappy",
+			CommitRetries:     5,
+			Timeout:           config.Duration(time.Minute),
+		}
+	})
+}
@@ -0,0 +1,492 @@
+package lakehouse
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/linkedin/goavro/v2"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Lakehouse
+		expected string
+	}{
+		{
+			name:     "missing location",
+			plugin:   &Lakehouse{},
+			expected: "missing 'location'",
+		},
+		{
+			name:     "invalid format",
+			plugin:   &Lakehouse{Location: "/tmp/table", Format: "hudi"},
+			expected: "invalid 'format' \"hudi\"",
+		},
+		{
+			name: "same columns",
+			plugin: &Lakehouse{
+				Location:          "/tmp/table",
+				MeasurementColumn: "name",
+				TimestampColumn:   "name",
+			},
+			expected: "must differ",
+		},
+		{
+			name:     "duplicate partition",
+			plugin:   &Lakehouse{Location: "/tmp/table", PartitionBy: []string{"date", "host", "date"}},
+			expected: "invalid or duplicate partition \"date\"",
+		},
+		{
+			name:     "timestamp partition",
+			plugin:   &Lakehouse{Location: "/tmp/table", PartitionBy: []string{"timestamp"}},
+			expected: "partitioning by \"timestamp\" is not supported",
+		},
+		{
+			name:     "invalid compression",
+			plugin:   &Lakehouse{Location: "/tmp/table", Compression: "lz4"},
+			expected: "invalid 'compression' \"lz4\"",
+		},
+		{
+			name:     "negative retries",
+			plugin:   &Lakehouse{Location: "/tmp/table", CommitRetries: -1},
+			expected: "'commit_retries' must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestDelta(t *testing.T) {
+	dir := t.TempDir()
+
+	plugin := newPlugin(dir, "delta")
+	plugin.PartitionBy = []string{"measurement", "date", "host"}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	ts := time.Date(2024, 6, 1, 12, 0, 0, 123456789, time.UTC)
+	require.NoError(t, plugin.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{"host": "a/b"}, map[string]interface{}{"usage": 42.5}, ts),
+		metric.New("cpu", map[string]string{"host": "c"}, map[string]interface{}{"usage": 1.5}, ts),
+		metric.New("mem", map[string]string{}, map[string]interface{}{"used": int64(7)}, ts.Add(24*time.Hour)),
+	}))
+
+	actions := readDeltaCommit(t, dir, 0)
+	require.Len(t, actions, 6)
+	require.Equal(t, 1, actions[0].Protocol.MinReaderVersion)
+	require.Equal(t, 2, actions[0].Protocol.MinWriterVersion)
+	require.Equal(t, []string{"measurement", "date", "host"}, actions[1].MetaData.PartitionColumns)
+	require.JSONEq(t,
+		`{"type":"struct","fields":[
+			{"name":"measurement","type":"string","nullable":true,"metadata":{}},
+			{"name":"timestamp","type":"timestamp","nullable":true,"metadata":{}},
+			{"name":"date","type":"date","nullable":true,"metadata":{}},
+			{"name":"host","type":"string","nullable":true,"metadata":{}},
+			{"name":"usage","type":"double","nullable":true,"metadata":{}},
+			{"name":"used","type":"long","nullable":true,"metadata":{}}
+		]}`,
+		actions[1].MetaData.SchemaString,
+	)
+	require.Equal(t, "WRITE", actions[5].CommitInfo.Operation)
+
+	// Check the data files including the escaping of partition values, the
+	// partition columns are not part of the files
+	add := actions[2].Add
+	require.True(t, strings.HasPrefix(add.Path, "measurement=cpu/date=2024-06-01/host=a%252Fb/part-00000-"), add.Path)
+	require.Equal(t, "a/b", *add.PartitionValues["host"])
+	require.JSONEq(t,
+		`{
+			"numRecords":1,
+			"minValues":{"timestamp":"2024-06-01T12:00:00.123Z"},
+			"maxValues":{"timestamp":"2024-06-01T12:00:00.124Z"},
+			"nullCount":{"timestamp":0}
+		}`,
+		add.Stats,
+	)
+	require.Nil(t, actions[4].Add.PartitionValues["host"])
+	require.Contains(t, actions[4].Add.Path, "/host=__HIVE_DEFAULT_PARTITION__/")
+
+	record := readParquet(t, filepath.Join(dir, deltaFilePath(t, add.Path)))
+	require.Equal(t, []string{"timestamp", "usage"}, fieldNames(record.Schema()))
+	require.Equal(t, `[{"timestamp":"2024-06-01 12:00:00.123456Z","usage":42.5}]`, rowsJSON(t, record))
+
+	// Add a new field and a field with conflicting type
+	require.NoError(t, plugin.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{"host": "c"}, map[string]interface{}{"usage": "high", "idle": true}, ts),
+	}))
+	actions = readDeltaCommit(t, dir, 1)
+	require.Len(t, actions, 3)
+	require.NotNil(t, actions[0].MetaData)
+	require.Equal(t, actions[0].MetaData.ID, readDeltaCommit(t, dir, 0)[1].MetaData.ID)
+	require.Contains(t, actions[0].MetaData.SchemaString, `{"name":"idle","type":"boolean","nullable":true,"metadata":{}}`)
+
+	record = readParquet(t, filepath.Join(dir, deltaFilePath(t, actions[1].Add.Path)))
+	require.Equal(t, []string{"timestamp", "usage", "idle"}, fieldNames(record.Schema()))
+	require.Equal(t, `[{"idle":true,"timestamp":"2024-06-01 12:00:00.123456Z","usage":null}]`, rowsJSON(t, record))
+
+	// A new instance continues the existing table without changing the schema
+	other := newPlugin(dir, "delta")
+	require.NoError(t, other.Init())
+	require.NoError(t, other.Connect())
+	defer other.Close()
+	require.NoError(t, other.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{"host": "c"}, map[string]interface{}{"idle": false}, ts),
+	}))
+	actions = readDeltaCommit(t, dir, 2)
+	require.Len(t, actions, 2)
+	require.NotNil(t, actions[0].Add)
+	require.Equal(t, []string{"measurement", "date", "host"}, partitionNames(other.table.partitions()))
+}
+
+func TestDeltaConcurrentCommit(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Unix(1717243200, 0)
+
+	first := newPlugin(dir, "delta")
+	require.NoError(t, first.Init())
+	require.NoError(t, first.Connect())
+	defer first.Close()
+
+	second := newPlugin(dir, "delta")
+	require.NoError(t, second.Init())
+	require.NoError(t, second.Connect())
+	defer second.Close()
+
+	require.NoError(t, first.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 42.5}, ts),
+	}))
+
+	// The second writer retries on top of the first commit and only adds the
+	// columns not added concurrently
+	require.NoError(t, second.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 1.5, "idle": int64(3)}, ts),
+	}))
+	actions := readDeltaCommit(t, dir, 1)
+	require.Len(t, actions, 3)
+	require.Nil(t, actions[0].Protocol)
+	var schema deltaSchema
+	require.NoError(t, json.Unmarshal([]byte(actions[0].MetaData.SchemaString), &schema))
+	names := make([]string, 0, len(schema.Fields))
+	for _, f := range schema.Fields {
+		names = append(names, f.Name)
+	}
+	require.Equal(t, []string{"measurement", "timestamp", "date", "usage", "idle"}, names)
+
+	// Conflicting column types fail the commit
+	require.NoError(t, first.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"state": "ok"}, ts),
+	}))
+	require.ErrorContains(t, second.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"state": int64(1)}, ts),
+	}), `column "state" was added concurrently with type "string"`)
+
+	// Without retries the commit fails on conflicts
+	second.table.(*deltaTable).retries = 0
+	require.NoError(t, first.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 1.0}, ts),
+	}))
+	require.ErrorContains(t, second.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 2.0}, ts),
+	}), "conflicts with concurrent commit")
+}
+
+func TestDeltaUnsupportedTable(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "_delta_log"), 0750))
+	commit := `{"protocol":{"minReaderVersion":3,"minWriterVersion":7}}` + "\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "_delta_log", "00000000000000000000.json"), []byte(commit), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "_delta_log", "00000000000000000001.json"), []byte("{}\n"), 0600))
+
+	plugin := newPlugin(dir, "delta")
+	require.NoError(t, plugin.Init())
+	require.ErrorContains(t, plugin.Connect(), "no protocol or metadata found")
+}
+
+func TestIceberg(t *testing.T) {
+	dir := t.TempDir()
+
+	plugin := newPlugin(dir, "iceberg")
+	plugin.PartitionBy = []string{"measurement", "date", "host"}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	ts := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, plugin.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{"host": "a"}, map[string]interface{}{"usage": 42.5}, ts),
+		metric.New("mem", map[string]string{}, map[string]interface{}{"used": uint64(7)}, ts.Add(24*time.Hour)),
+	}))
+
+	hint, err := os.ReadFile(filepath.Join(dir, "metadata", "version-hint.text"))
+	require.NoError(t, err)
+	require.Equal(t, "1", string(hint))
+
+	metadata := readIcebergMetadata(t, dir, 1)
+	require.Equal(t, float64(2), metadata["format-version"])
+	require.Equal(t, "file://"+filepath.ToSlash(dir), metadata["location"])
+	require.Equal(t, float64(5), metadata["last-column-id"])
+	require.Equal(t, float64(1002), metadata["last-partition-id"])
+	require.Equal(t, float64(1), metadata["last-sequence-number"])
+	schema, err := json.Marshal(metadata["schemas"])
+	require.NoError(t, err)
+	require.JSONEq(t,
+		`[{"type":"struct","schema-id":0,"fields":[
+			{"id":1,"name":"measurement","required":false,"type":"string"},
+			{"id":2,"name":"timestamp","required":false,"type":"timestamptz"},
+			{"id":3,"name":"host","required":false,"type":"string"},
+			{"id":4,"name":"usage","required":false,"type":"double"},
+			{"id":5,"name":"used","required":false,"type":"long"}
+		]}]`,
+		string(schema),
+	)
+	specs, err := json.Marshal(metadata["partition-specs"])
+	require.NoError(t, err)
+	require.JSONEq(t,
+		`[{"spec-id":0,"fields":[
+			{"name":"measurement","transform":"identity","source-id":1,"field-id":1000},
+			{"name":"timestamp_day","transform":"day","source-id":2,"field-id":1001},
+			{"name":"host","transform":"identity","source-id":3,"field-id":1002}
+		]}]`,
+		string(specs),
+	)
+
+	// Check the manifest list and the manifest
+	snapshots := metadata["snapshots"].([]interface{})
+	require.Len(t, snapshots, 1)
+	snapshot := snapshots[0].(map[string]interface{})
+	require.Equal(t, "2", snapshot["summary"].(map[string]interface{})["total-records"])
+	manifests := readAvro(t, snapshot["manifest-list"].(string))
+	require.Len(t, manifests, 1)
+	require.Equal(t, int32(2), manifests[0]["added_files_count"])
+	require.Equal(t, int64(2), manifests[0]["added_rows_count"])
+	summaries := manifests[0]["partitions"].(map[string]interface{})["array"].([]interface{})
+	require.Len(t, summaries, 3)
+	require.Equal(t, true, summaries[2].(map[string]interface{})["contains_null"])
+	require.Equal(t, []byte("cpu"), summaries[0].(map[string]interface{})["lower_bound"].(map[string]interface{})["bytes"])
+	require.Equal(t, []byte("mem"), summaries[0].(map[string]interface{})["upper_bound"].(map[string]interface{})["bytes"])
+
+	entries := readAvro(t, manifests[0]["manifest_path"].(string))
+	require.Len(t, entries, 2)
+	file := entries[0]["data_file"].(map[string]interface{})
+	require.Equal(t, "PARQUET", file["file_format"])
+	require.Equal(t, int64(1), file["record_count"])
+	require.Equal(t,
+		map[string]interface{}{
+			"measurement":   map[string]interface{}{"string": "cpu"},
+			"timestamp_day": map[string]interface{}{"int.date": time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)},
+			"host":          map[string]interface{}{"string": "a"},
+		},
+		file["partition"],
+	)
+	path := strings.TrimPrefix(file["file_path"].(string), "file://")
+	require.Contains(t, path, "/data/measurement=cpu/timestamp_day=2024-06-01/host=a/")
+	require.Contains(t, entries[1]["data_file"].(map[string]interface{})["file_path"], "/host=null/")
+
+	record := readParquet(t, filepath.FromSlash(path))
+	require.Equal(t, []string{"measurement", "timestamp", "host", "usage"}, fieldNames(record.Schema()))
+	for i, id := range []string{"1", "2", "3", "4"} {
+		v, found := record.Schema().Field(i).Metadata.GetValue("PARQUET:field_id")
+		require.True(t, found)
+		require.Equal(t, id, v)
+	}
+	require.Equal(t, `[{"host":"a","measurement":"cpu","timestamp":"2024-06-01 12:00:00Z","usage":42.5}]`, rowsJSON(t, record))
+
+	// A new instance continues the table and evolves the schema
+	other := newPlugin(dir, "iceberg")
+	require.NoError(t, other.Init())
+	require.NoError(t, other.Connect())
+	defer other.Close()
+	require.Equal(t, []string{"measurement", "timestamp_day", "host"}, partitionNames(other.table.partitions()))
+	require.NoError(t, other.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{"host": "a"}, map[string]interface{}{"idle": true}, ts),
+	}))
+
+	metadata = readIcebergMetadata(t, dir, 2)
+	require.Equal(t, float64(1), metadata["current-schema-id"])
+	require.Equal(t, float64(6), metadata["last-column-id"])
+	require.Equal(t, float64(2), metadata["last-sequence-number"])
+	require.Len(t, metadata["schemas"], 2)
+	require.Len(t, metadata["metadata-log"], 1)
+	snapshots = metadata["snapshots"].([]interface{})
+	require.Len(t, snapshots, 2)
+	snapshot = snapshots[1].(map[string]interface{})
+	require.Equal(t, snapshots[0].(map[string]interface{})["snapshot-id"], snapshot["parent-snapshot-id"])
+	require.Equal(t, snapshot["snapshot-id"], metadata["current-snapshot-id"])
+	require.Equal(t, "3", snapshot["summary"].(map[string]interface{})["total-records"])
+	manifests = readAvro(t, snapshot["manifest-list"].(string))
+	require.Len(t, manifests, 2)
+	require.Equal(t, int64(1), manifests[0]["sequence_number"])
+	require.Equal(t, int64(2), manifests[1]["sequence_number"])
+}
+
+func TestIcebergConcurrentCommit(t *testing.T) {
+	dir := t.TempDir()
+	ts := time.Unix(1717243200, 0)
+
+	first := newPlugin(dir, "iceberg")
+	require.NoError(t, first.Init())
+	require.NoError(t, first.Connect())
+	defer first.Close()
+	require.NoError(t, first.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 42.5}, ts),
+	}))
+
+	second := newPlugin(dir, "iceberg")
+	require.NoError(t, second.Init())
+	require.NoError(t, second.Connect())
+	defer second.Close()
+
+	// Appending to the same schema is retried on top of the concurrent commit
+	require.NoError(t, first.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 1.5}, ts),
+	}))
+	require.NoError(t, second.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 2.5}, ts),
+	}))
+	metadata := readIcebergMetadata(t, dir, 3)
+	require.Len(t, metadata["snapshots"], 3)
+	snapshot := metadata["snapshots"].([]interface{})[2].(map[string]interface{})
+	require.Len(t, readAvro(t, snapshot["manifest-list"].(string)), 3)
+
+	// Concurrently added columns invalidate the field IDs of the data files
+	require.NoError(t, first.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"idle": 1.5}, ts),
+	}))
+	require.ErrorContains(t, second.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"state": "ok"}, ts),
+	}), `column "state" conflicts with concurrent schema change`)
+
+	// The next write uses the current schema
+	require.NoError(t, second.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"state": "ok"}, ts),
+	}))
+	metadata = readIcebergMetadata(t, dir, 5)
+	require.Equal(t, float64(5), metadata["last-column-id"])
+}
+
+func newPlugin(dir, format string) *Lakehouse {
+	return &Lakehouse{
+		Location:          dir,
+		Format:            format,
+		PartitionBy:       []string{"measurement", "date"},
+		MeasurementColumn: "measurement",
+		TimestampColumn:   "timestamp",
+		Compression:       "snappy",
+		CommitRetries:     5,
+		Log:               testutil.Logger{},
+	}
+}
+
+func readDeltaCommit(t *testing.T, dir string, version int64) []*deltaAction {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(deltaCommitKey(version))))
+	require.NoError(t, err)
+
+	var actions []*deltaAction
+	for _, line := range bytes.Split(bytes.TrimSpace(data), []byte("\n")) {
+		var action deltaAction
+		require.NoError(t, json.Unmarshal(line, &action))
+		actions = append(actions, &action)
+	}
+	return actions
+}
+
+func deltaFilePath(t *testing.T, p string) string {
+	t.Helper()
+	unescaped, err := url.PathUnescape(p)
+	require.NoError(t, err)
+	return filepath.FromSlash(unescaped)
+}
+
+func readIcebergMetadata(t *testing.T, dir string, version int) map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(icebergMetadataKey(version))))
+	require.NoError(t, err)
+
+	var metadata map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &metadata))
+	return metadata
+}
+
+func readAvro(t *testing.T, location string) []map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(filepath.FromSlash(strings.TrimPrefix(location, "file://")))
+	require.NoError(t, err)
+
+	reader, err := goavro.NewOCFReader(bytes.NewReader(data))
+	require.NoError(t, err)
+	var records []map[string]interface{}
+	for reader.Scan() {
+		record, err := reader.Read()
+		require.NoError(t, err)
+		records = append(records, record.(map[string]interface{}))
+	}
+	require.NoError(t, reader.Err())
+	return records
+}
+
+func readParquet(t *testing.T, filename string) arrow.Table {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	table, err := pqarrow.ReadTable(t.Context(), bytes.NewReader(data), parquet.NewReaderProperties(nil), pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	require.NoError(t, err)
+	t.Cleanup(table.Release)
+	return table
+}
+
+func rowsJSON(t *testing.T, table arrow.Table) string {
+	t.Helper()
+	reader := array.NewTableReader(table, 0)
+	defer reader.Release()
+	var rows []json.RawMessage
+	for reader.Next() {
+		buf, err := reader.Record().MarshalJSON()
+		require.NoError(t, err)
+		var batch []json.RawMessage
+		require.NoError(t, json.Unmarshal(buf, &batch))
+		rows = append(rows, batch...)
+	}
+	buf, err := json.Marshal(rows)
+	require.NoError(t, err)
+	return string(buf)
+}
+
+func fieldNames(schema *arrow.Schema) []string {
+	names := make([]string, 0, schema.NumFields())
+	for _, f := range schema.Fields() {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func partitionNames(partitions []*partition) []string {
+	names := make([]string, 0, len(partitions))
+	for _, p := range partitions {
+		names = append(names, p.name)
+	}
+	return names
+}
@@ -0,0 +1,267 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package opcua
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/plugins/common/opcua"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Status codes indicating a temporary problem, metrics are kept for retrying
+// the write with the next flush
+var retryableStatusCodes = []ua.StatusCode{
+	ua.StatusBadResourceUnavailable,
+	ua.StatusBadCommunicationError,
+	ua.StatusBadTimeout,
+	ua.StatusBadServerNotConnected,
+	ua.StatusBadServerHalted,
+	ua.StatusBadTooManyOperations,
+}
+
+// NodeSettings describes the mapping of a metric field to a OPC UA node
+type NodeSettings struct {
+	Metric         string            `toml:"metric"`
+	Field          string            `toml:"field"`
+	Tags           map[string]string `toml:"tags"`
+	Namespace      string            `toml:"namespace"`
+	IdentifierType string            `toml:"identifier_type"`
+	Identifier     string            `toml:"identifier"`
+	DataType       string            `toml:"data_type"`
+
+	nodeID *ua.NodeID
+}
+
+// NodeID returns the OPC UA node id
+func (n *NodeSettings) NodeID() string {
+	return "ns=" + n.Namespace + ";" + n.IdentifierType + "=" + n.Identifier
+}
+
+func (n *NodeSettings) matches(m telegraf.Metric) bool {
+	if n.Metric != m.Name() {
+		return false
+	}
+	for k, v := range n.Tags {
+		if tv, found := m.GetTag(k); !found || tv != v {
+			return false
+		}
+	}
+	return true
+}
+
+type OpcUA struct {
+	opcua.OpcUAClientConfig
+	SourceTimestamp bool            `toml:"source_timestamp"`
+	Nodes           []NodeSettings  `toml:"nodes"`
+	Log             telegraf.Logger `toml:"-"`
+
+	client                *opcua.OpcUAClient
+	unregisterDiagnostics func()
+}
+
+func (*OpcUA) SampleConfig() string {
+	return sampleConfig
+}
+
+func (o *OpcUA) Init() error {
+	if len(o.Nodes) == 0 {
+		return errors.New("no nodes configured")
+	}
+
+	for i := range o.Nodes {
+		n := &o.Nodes[i]
+		if n.Metric == "" {
+			return fmt.Errorf("empty metric name for node %q", n.NodeID())
+		}
+		if n.Field == "" {
+			return fmt.Errorf("empty field name for node %q", n.NodeID())
+		}
+		if n.Namespace == "" {
+			return fmt.Errorf("empty namespace for field %q", n.Field)
+		}
+		if n.Identifier == "" {
+			return fmt.Errorf("empty identifier for field %q", n.Field)
+		}
+		if err := choice.Check(n.IdentifierType, []string{"s", "i", "g", "b"}); err != nil {
+			return fmt.Errorf("invalid identifier type for field %q: %w", n.Field, err)
+		}
+		if n.DataType != "" {
+			if err := choice.Check(n.DataType, opcua.DataTypes); err != nil {
+				return fmt.Errorf("invalid data type for field %q: %w", n.Field, err)
+			}
+		}
+
+		nid, err := ua.ParseNodeID(n.NodeID())
+		if err != nil {
+			return fmt.Errorf("parsing node ID for field %q failed: %w", n.Field, err)
+		}
+		n.nodeID = nid
+	}
+
+	client, err := o.OpcUAClientConfig.CreateClient(o.Log)
+	if err != nil {
+		return err
+	}
+	o.client = client
+
+	return nil
+}
+
+func (o *OpcUA) Connect() error {
+	// Connect is retried on failures so only register once
+	if o.unregisterDiagnostics == nil {
+		unregister, err := opcua.RegisterDiagnostics(o.DiagnosticsAddress, "outputs.opcua", func() interface{} {
+			return o.client.Diagnostics()
+		})
+		if err != nil {
+			return err
+		}
+		o.unregisterDiagnostics = unregister
+	}
+	return o.client.Connect(context.Background())
+}
+
+func (o *OpcUA) Close() error {
+	if o.unregisterDiagnostics != nil {
+		o.unregisterDiagnostics()
+		o.unregisterDiagnostics = nil
+	}
+	if o.client.State() == opcua.Disconnected {
+		return nil
+	}
+	return o.client.Disconnect(context.Background())
+}
+
+func (o *OpcUA) Write(metrics []telegraf.Metric) error {
+	// Reconnect in case the connection was lost
+	if state := o.client.State(); state == opcua.Disconnected || state == opcua.Closed {
+		if err := o.client.Connect(context.Background()); err != nil {
+			return fmt.Errorf("reconnecting failed: %w", err)
+		}
+	}
+
+	// Collect the values to write and remember the originating metric for
+	// each value to be able to handle write failures
+	var origin []int
+	req := &ua.WriteRequest{}
+	rejected := make(map[int]error)
+	for i, m := range metrics {
+		for j := range o.Nodes {
+			n := &o.Nodes[j]
+			if !n.matches(m) {
+				continue
+			}
+			raw, found := m.GetField(n.Field)
+			if !found {
+				continue
+			}
+
+			value, err := opcua.ConvertTo(raw, n.DataType)
+			if err != nil {
+				rejected[i] = fmt.Errorf("converting field %q failed: %w", n.Field, err)
+				continue
+			}
+			variant, err := ua.NewVariant(value)
+			if err != nil {
+				rejected[i] = fmt.Errorf("creating variant for field %q failed: %w", n.Field, err)
+				continue
+			}
+
+			dv := &ua.DataValue{
+				EncodingMask: ua.DataValueValue,
+				Value:        variant,
+			}
+			if o.SourceTimestamp {
+				dv.EncodingMask |= ua.DataValueSourceTimestamp
+				dv.SourceTimestamp = m.Time()
+			}
+			req.NodesToWrite = append(req.NodesToWrite, &ua.WriteValue{
+				NodeID:      n.nodeID,
+				AttributeID: ua.AttributeIDValue,
+				Value:       dv,
+			})
+			origin = append(origin, i)
+		}
+	}
+
+	retry := make(map[int]bool)
+	if len(req.NodesToWrite) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(o.RequestTimeout))
+		defer cancel()
+		resp, err := o.client.Client.Write(ctx, req)
+		if err != nil {
+			// We do not return the disconnect error, as this would mask the
+			// original problem, but we do log it
+			if derr := o.client.Disconnect(context.Background()); derr != nil {
+				o.Log.Debug("Error while disconnecting: ", derr)
+			}
+			return fmt.Errorf("writing values failed: %w", err)
+		}
+
+		for k, code := range resp.Results {
+			if o.client.StatusCodeOK(code) {
+				continue
+			}
+
+			n := req.NodesToWrite[k]
+			idx := origin[k]
+			if slices.Contains(retryableStatusCodes, code) {
+				o.Log.Debugf("Writing node %q failed with %v, retrying...", n.NodeID, code)
+				retry[idx] = true
+				continue
+			}
+			rejected[idx] = fmt.Errorf("writing node %q failed: %w", n.NodeID, code)
+		}
+	}
+
+	if len(rejected) == 0 && len(retry) == 0 {
+		return nil
+	}
+
+	werr := &internal.PartialWriteError{
+		Err: errors.New("writing some values failed"),
+	}
+	for i := range metrics {
+		if err, found := rejected[i]; found {
+			o.Log.Errorf("Dropping metric %q: %v", metrics[i].Name(), err)
+			werr.MetricsReject = append(werr.MetricsReject, i)
+			werr.MetricsRejectErrors = append(werr.MetricsRejectErrors, err)
+			continue
+		}
+		if retry[i] {
+			continue
+		}
+		werr.MetricsAccept = append(werr.MetricsAccept, i)
+	}
+
+	return werr
+}
+
+func init() {
+	outputs.Add("opcua", func() telegraf.Output {
+		return &OpcUA{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       "opc.tcp://localhost:4840",
+				SecurityPolicy: "auto",
+				SecurityMode:   "auto",
+				AuthMethod:     "Anonymous",
+				ConnectTimeout: config.Duration(5 * time.Second),
+				RequestTimeout: config.Duration(10 * time.Second),
+			},
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package opcua
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/common/opcua"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+const servicePort = "4840"
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		nodes    []NodeSettings
+		expected string
+	}{
+		{
+			name:     "no nodes",
+			expected: "no nodes configured",
+		},
+		{
+			name:     "no metric",
+			nodes:    []NodeSettings{{Field: "value", Namespace: "1", IdentifierType: "i", Identifier: "1"}},
+			expected: "empty metric name",
+		},
+		{
+			name:     "no field",
+			nodes:    []NodeSettings{{Metric: "test", Namespace: "1", IdentifierType: "i", Identifier: "1"}},
+			expected: "empty field name",
+		},
+		{
+			name:     "no namespace",
+			nodes:    []NodeSettings{{Metric: "test", Field: "value", IdentifierType: "i", Identifier: "1"}},
+			expected: "empty namespace",
+		},
+		{
+			name:     "invalid identifier type",
+			nodes:    []NodeSettings{{Metric: "test", Field: "value", Namespace: "1", IdentifierType: "x", Identifier: "1"}},
+			expected: "invalid identifier type",
+		},
+		{
+			name: "invalid data type",
+			nodes: []NodeSettings{{
+				Metric:         "test",
+				Field:          "value",
+				Namespace:      "1",
+				IdentifierType: "i",
+				Identifier:     "1",
+				DataType:       "Decimal",
+			}},
+			expected: "invalid data type",
+		},
+		{
+			name:     "invalid identifier",
+			nodes:    []NodeSettings{{Metric: "test", Field: "value", Namespace: "1", IdentifierType: "i", Identifier: "abc"}},
+			expected: "parsing node ID",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &OpcUA{
+				OpcUAClientConfig: opcua.OpcUAClientConfig{
+					Endpoint:       "opc.tcp://localhost:4840",
+					SecurityPolicy: "None",
+					SecurityMode:   "None",
+					AuthMethod:     "Anonymous",
+				},
+				Nodes: tt.nodes,
+				Log:   testutil.Logger{},
+			}
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestNodeMatches(t *testing.T) {
+	n := &NodeSettings{Metric: "kpi", Tags: map[string]string{"line": "1"}}
+
+	tests := []struct {
+		name     string
+		metric   telegraf.Metric
+		expected bool
+	}{
+		{
+			name:     "match",
+			metric:   metric.New("kpi", map[string]string{"line": "1", "site": "a"}, map[string]interface{}{"oee": 0.8}, time.Unix(0, 0)),
+			expected: true,
+		},
+		{
+			name:   "different name",
+			metric: metric.New("other", map[string]string{"line": "1"}, map[string]interface{}{"oee": 0.8}, time.Unix(0, 0)),
+		},
+		{
+			name:   "different tag",
+			metric: metric.New("kpi", map[string]string{"line": "2"}, map[string]interface{}{"oee": 0.8}, time.Unix(0, 0)),
+		},
+		{
+			name:   "missing tag",
+			metric: metric.New("kpi", map[string]string{}, map[string]interface{}{"oee": 0.8}, time.Unix(0, 0)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, n.matches(tt.metric))
+		})
+	}
+}
+
+func TestWriteIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	container := testutil.Container{
+		Image:        "open62541/open62541",
+		ExposedPorts: []string{servicePort},
+		WaitingFor: wait.ForAll(
+			wait.ForListeningPort(nat.Port(servicePort)),
+			wait.ForLog("TCP network layer listening on opc.tcp://"),
+		),
+	}
+	require.NoError(t, container.Start(), "failed to start container")
+	defer container.Terminate()
+
+	endpoint := fmt.Sprintf("opc.tcp://%s:%s", container.Address, container.Ports[servicePort])
+	plugin := &OpcUA{
+		OpcUAClientConfig: opcua.OpcUAClientConfig{
+			Endpoint:       endpoint,
+			SecurityPolicy: "None",
+			SecurityMode:   "None",
+			AuthMethod:     "Anonymous",
+			ConnectTimeout: config.Duration(10 * time.Second),
+			RequestTimeout: config.Duration(1 * time.Second),
+		},
+		Nodes: []NodeSettings{
+			{
+				Metric:         "kpi",
+				Field:          "answer",
+				Namespace:      "1",
+				IdentifierType: "s",
+				Identifier:     "the.answer",
+				DataType:       "Int32",
+			},
+			{
+				Metric:         "kpi",
+				Field:          "readonly",
+				Namespace:      "0",
+				IdentifierType: "i",
+				Identifier:     "2261",
+				DataType:       "String",
+			},
+		},
+		Log: testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	metrics := []telegraf.Metric{
+		metric.New("kpi", map[string]string{}, map[string]interface{}{"answer": int64(23)}, time.Now()),
+		metric.New("kpi", map[string]string{}, map[string]interface{}{"readonly": "foo"}, time.Now()),
+		metric.New("other", map[string]string{}, map[string]interface{}{"answer": int64(0)}, time.Now()),
+	}
+	err := plugin.Write(metrics)
+	var werr *internal.PartialWriteError
+	require.ErrorAs(t, err, &werr)
+	require.Equal(t, []int{0, 2}, werr.MetricsAccept)
+	require.Equal(t, []int{1}, werr.MetricsReject)
+
+	// Read back the written value
+	client, err := plugin.OpcUAClientConfig.CreateClient(testutil.Logger{})
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(t.Context()))
+	defer client.Disconnect(t.Context()) //nolint:errcheck // ignore error on cleanup
+
+	resp, err := client.Client.Read(t.Context(), &ua.ReadRequest{
+		NodesToRead: []*ua.ReadValueID{{NodeID: plugin.Nodes[0].nodeID, AttributeID: ua.AttributeIDValue}},
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Results, 1)
+	require.Equal(t, ua.StatusOK, resp.Results[0].Status)
+	require.Equal(t, int32(23), resp.Results[0].Value.Value())
+}
@@ -0,0 +1,23 @@
+package opcua_server
+
+// serverDiagnostics is the state of the server reported by the diagnostics
+// endpoint
+type serverDiagnostics struct {
+	Endpoint  string   `json:"endpoint"`
+	URLs      []string `json:"urls"`
+	State     string   `json:"state"`
+	Folders   int64    `json:"folders"`
+	Variables int64    `json:"variables"`
+}
+
+// diagnostics returns the current state of the server
+func (o *OpcUAServer) diagnostics() interface{} {
+	status := o.server.Status()
+	return &serverDiagnostics{
+		Endpoint:  o.Endpoint,
+		URLs:      o.server.URLs(),
+		State:     status.State.String(),
+		Folders:   o.folderCount.Load(),
+		Variables: o.variableCount.Load(),
+	}
+}
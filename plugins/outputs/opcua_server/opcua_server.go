@@ -0,0 +1,319 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package opcua_server
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/server"
+	"github.com/gopcua/opcua/server/attrs"
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/opcua"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type OpcUAServer struct {
+	Endpoint           string          `toml:"endpoint"`
+	ServerName         string          `toml:"server_name"`
+	Namespace          string          `toml:"namespace"`
+	TagKeys            []string        `toml:"tag_keys"`
+	DiagnosticsAddress string          `toml:"diagnostics_address"`
+	Log                telegraf.Logger `toml:"-"`
+
+	host string
+	port int
+
+	server    *server.Server
+	ns        *server.NodeNameSpace
+	folders   map[string]*server.Node
+	variables map[string]*variable
+
+	// number of nodes created, read by the diagnostics endpoint
+	folderCount   atomic.Int64
+	variableCount atomic.Int64
+
+	unregisterDiagnostics func()
+}
+
+// variable holds the current value of a node exposing a metric field
+type variable struct {
+	nodeID *ua.NodeID
+
+	value *ua.DataValue
+	sync.Mutex
+}
+
+func (v *variable) get() *ua.DataValue {
+	v.Lock()
+	defer v.Unlock()
+	if v.value == nil {
+		return &ua.DataValue{
+			EncodingMask:    ua.DataValueStatusCode | ua.DataValueServerTimestamp,
+			Status:          ua.StatusBadWaitingForInitialData,
+			ServerTimestamp: time.Now(),
+		}
+	}
+	return v.value
+}
+
+func (v *variable) set(value *ua.DataValue) {
+	v.Lock()
+	defer v.Unlock()
+	v.value = value
+}
+
+func (*OpcUAServer) SampleConfig() string {
+	return sampleConfig
+}
+
+func (o *OpcUAServer) Init() error {
+	if o.Endpoint == "" {
+		return errors.New("endpoint must be set")
+	}
+	if o.Namespace == "" {
+		return errors.New("namespace must be set")
+	}
+	if o.ServerName == "" {
+		return errors.New("server_name must be set")
+	}
+
+	u, err := url.Parse(o.Endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint %q: %w", o.Endpoint, err)
+	}
+	if u.Scheme != "opc.tcp" {
+		return fmt.Errorf("invalid scheme %q, should be 'opc.tcp'", u.Scheme)
+	}
+	o.host = u.Hostname()
+	if o.host == "" {
+		return errors.New("endpoint must contain a host")
+	}
+	o.port = 4840
+	if p := u.Port(); p != "" {
+		port, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return fmt.Errorf("invalid port %q: %w", p, err)
+		}
+		o.port = int(port)
+	}
+
+	return nil
+}
+
+func (o *OpcUAServer) Connect() error {
+	o.server = server.New(
+		server.EndPoint(o.host, o.port),
+		server.ServerName(o.ServerName),
+		server.ManufacturerName("InfluxData"),
+		server.ProductName("Telegraf"),
+		server.SoftwareVersion(internal.Version),
+		server.EnableSecurity("None", ua.MessageSecurityModeNone),
+		server.EnableAuthMode(ua.UserTokenTypeAnonymous),
+		server.SetLogger(&logger{o.Log}),
+	)
+
+	// Make the namespace browsable by referencing it from the global
+	// objects folder
+	o.ns = server.NewNodeNameSpace(o.server, o.Namespace)
+	root, err := o.server.Namespace(0)
+	if err != nil {
+		return err
+	}
+	objects := o.ns.Objects()
+	objects.SetBrowseName(o.ServerName)
+	objects.SetDisplayName(o.ServerName, "")
+	root.Objects().AddRef(objects, id.Organizes, true)
+
+	o.folders = make(map[string]*server.Node)
+	o.variables = make(map[string]*variable)
+
+	// Do not cancel the context passed to the server as the library
+	// dereferences the nil message returned on cancellation
+	if err := o.server.Start(context.Background()); err != nil {
+		return fmt.Errorf("starting server failed: %w", err)
+	}
+	o.Log.Infof("Listening on %s", o.Endpoint)
+
+	unregister, err := opcua.RegisterDiagnostics(o.DiagnosticsAddress, "outputs.opcua_server", o.diagnostics)
+	if err != nil {
+		return errors.Join(err, o.server.Close())
+	}
+	o.unregisterDiagnostics = unregister
+
+	return nil
+}
+
+func (o *OpcUAServer) Close() error {
+	if o.unregisterDiagnostics != nil {
+		o.unregisterDiagnostics()
+		o.unregisterDiagnostics = nil
+	}
+	if o.server == nil {
+		return nil
+	}
+	return o.server.Close()
+}
+
+func (o *OpcUAServer) Write(metrics []telegraf.Metric) error {
+	now := time.Now()
+	for _, m := range metrics {
+		// Construct the folder hierarchy from the measurement and the
+		// configured tags in the given order
+		path := make([]string, 0, len(o.TagKeys)+2)
+		path = append(path, m.Name())
+		for _, key := range o.TagKeys {
+			if value, found := m.GetTag(key); found {
+				path = append(path, value)
+			}
+		}
+		parent := o.folder(path)
+
+		for _, field := range m.FieldList() {
+			value, err := ua.NewVariant(field.Value)
+			if err != nil {
+				o.Log.Debugf("Cannot convert field %q of metric %q: %v", field.Key, m.Name(), err)
+				continue
+			}
+
+			v := o.variable(parent, append(path, field.Key), value.Type())
+			if v == nil {
+				continue
+			}
+			v.set(&ua.DataValue{
+				EncodingMask:    ua.DataValueValue | ua.DataValueSourceTimestamp | ua.DataValueServerTimestamp,
+				Value:           value,
+				SourceTimestamp: m.Time(),
+				ServerTimestamp: now,
+			})
+			o.ns.ChangeNotification(v.nodeID)
+		}
+	}
+	return nil
+}
+
+// folder returns the folder node for the given path creating the node and
+// its parents if necessary
+func (o *OpcUAServer) folder(path []string) *server.Node {
+	key := strings.Join(path, "/")
+	if node, found := o.folders[key]; found {
+		return node
+	}
+
+	parent := o.ns.Objects()
+	if len(path) > 1 {
+		parent = o.folder(path[:len(path)-1])
+	}
+
+	name := path[len(path)-1]
+	node := server.NewNode(
+		ua.NewStringNodeID(o.ns.ID(), key),
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDNodeClass:   server.DataValueFromValue(uint32(ua.NodeClassObject)),
+			ua.AttributeIDBrowseName:  server.DataValueFromValue(&ua.QualifiedName{NamespaceIndex: o.ns.ID(), Name: name}),
+			ua.AttributeIDDisplayName: server.DataValueFromValue(attrs.DisplayName(name, "")),
+		},
+		[]*ua.ReferenceDescription{typeDefinition(id.FolderType, "FolderType")},
+		nil,
+	)
+	o.ns.AddNode(node)
+	parent.AddRef(node, id.Organizes, true)
+	o.folders[key] = node
+	o.folderCount.Add(1)
+
+	return node
+}
+
+// variable returns the variable for the given path creating the node if
+// necessary; nil is returned if the path is already used by a folder
+func (o *OpcUAServer) variable(parent *server.Node, path []string, typeID ua.TypeID) *variable {
+	key := strings.Join(path, "/")
+	if v, found := o.variables[key]; found {
+		return v
+	}
+	if _, found := o.folders[key]; found {
+		o.Log.Debugf("Node %q is already used by a folder", key)
+		return nil
+	}
+
+	name := path[len(path)-1]
+	v := &variable{nodeID: ua.NewStringNodeID(o.ns.ID(), key)}
+	node := server.NewNode(
+		v.nodeID,
+		map[ua.AttributeID]*ua.DataValue{
+			ua.AttributeIDNodeClass:       server.DataValueFromValue(uint32(ua.NodeClassVariable)),
+			ua.AttributeIDBrowseName:      server.DataValueFromValue(&ua.QualifiedName{NamespaceIndex: o.ns.ID(), Name: name}),
+			ua.AttributeIDDisplayName:     server.DataValueFromValue(attrs.DisplayName(name, "")),
+			ua.AttributeIDDataType:        server.DataValueFromValue(ua.NewNumericExpandedNodeID(0, uint32(typeID))),
+			ua.AttributeIDValueRank:       server.DataValueFromValue(int32(-1)),
+			ua.AttributeIDAccessLevel:     server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead)),
+			ua.AttributeIDUserAccessLevel: server.DataValueFromValue(byte(ua.AccessLevelTypeCurrentRead)),
+		},
+		[]*ua.ReferenceDescription{typeDefinition(id.BaseDataVariableType, "BaseDataVariableType")},
+		v.get,
+	)
+	o.ns.AddNode(node)
+	parent.AddRef(node, id.HasComponent, true)
+	o.variables[key] = v
+	o.variableCount.Add(1)
+
+	return v
+}
+
+// typeDefinition creates a reference to the given type definition node
+func typeDefinition(typeID uint32, name string) *ua.ReferenceDescription {
+	return &ua.ReferenceDescription{
+		ReferenceTypeID: ua.NewNumericNodeID(0, id.HasTypeDefinition),
+		IsForward:       true,
+		NodeID:          ua.NewNumericExpandedNodeID(0, typeID),
+		BrowseName:      attrs.BrowseName(name),
+		DisplayName:     attrs.DisplayName(name, ""),
+		NodeClass:       ua.NodeClassObjectType,
+		TypeDefinition:  ua.NewNumericExpandedNodeID(0, 0),
+	}
+}
+
+// logger adapts the Telegraf logger to the logger interface of the server
+type logger struct {
+	telegraf.Logger
+}
+
+func (l *logger) Debug(msg string, args ...any) {
+	l.Tracef(msg, args...)
+}
+
+func (l *logger) Info(msg string, args ...any) {
+	l.Debugf(msg, args...)
+}
+
+func (l *logger) Warn(msg string, args ...any) {
+	l.Warnf(msg, args...)
+}
+
+func (l *logger) Error(msg string, args ...any) {
+	l.Errorf(msg, args...)
+}
+
+func init() {
+	outputs.Add("opcua_server", func() telegraf.Output {
+		return &OpcUAServer{
+			Endpoint:   "opc.tcp://localhost:4840",
+			ServerName: "Telegraf",
+			Namespace:  "urn:influxdata:telegraf",
+		}
+	})
+}
@@ -0,0 +1,164 @@
+package opcua_server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua"
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		expected string
+	}{
+		{
+			name:     "empty endpoint",
+			expected: "endpoint must be set",
+		},
+		{
+			name:     "invalid scheme",
+			endpoint: "tcp://localhost:4840",
+			expected: "invalid scheme",
+		},
+		{
+			name:     "no host",
+			endpoint: "opc.tcp://:4840",
+			expected: "endpoint must contain a host",
+		},
+		{
+			name:     "invalid port",
+			endpoint: "opc.tcp://localhost:123456",
+			expected: "invalid port",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &OpcUAServer{
+				Endpoint:   tt.endpoint,
+				ServerName: "Telegraf",
+				Namespace:  "urn:influxdata:telegraf",
+				Log:        testutil.Logger{},
+			}
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	// Determine a free port for the server
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+	endpoint := fmt.Sprintf("opc.tcp://127.0.0.1:%d", port)
+
+	plugin := &OpcUAServer{
+		Endpoint:   endpoint,
+		ServerName: "Telegraf",
+		Namespace:  "urn:influxdata:telegraf",
+		TagKeys:    []string{"host"},
+		Log:        testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	ts := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	metrics := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"host": "server01", "cpu": "cpu0"},
+			map[string]interface{}{"usage_idle": 98.5, "count": int64(4)},
+			ts,
+		),
+		metric.New(
+			"system",
+			map[string]string{},
+			map[string]interface{}{"uptime": uint64(42)},
+			ts,
+		),
+	}
+	require.NoError(t, plugin.Write(metrics))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	client, err := opcua.NewClient(endpoint, opcua.SecurityMode(ua.MessageSecurityModeNone))
+	require.NoError(t, err)
+	require.NoError(t, client.Connect(ctx))
+	defer client.Close(ctx)
+
+	// Check the namespace is registered
+	namespaces, err := client.NamespaceArray(ctx)
+	require.NoError(t, err)
+	require.Contains(t, namespaces, "urn:influxdata:telegraf")
+	ns := plugin.ns.ID()
+
+	// Check the values
+	req := &ua.ReadRequest{
+		NodesToRead: []*ua.ReadValueID{
+			{NodeID: ua.NewStringNodeID(ns, "cpu/server01/usage_idle"), AttributeID: ua.AttributeIDValue},
+			{NodeID: ua.NewStringNodeID(ns, "cpu/server01/count"), AttributeID: ua.AttributeIDValue},
+			{NodeID: ua.NewStringNodeID(ns, "system/uptime"), AttributeID: ua.AttributeIDValue},
+		},
+		TimestampsToReturn: ua.TimestampsToReturnBoth,
+	}
+	resp, err := client.Read(ctx, req)
+	require.NoError(t, err)
+	require.Len(t, resp.Results, 3)
+	require.InDelta(t, 98.5, resp.Results[0].Value.Value(), testutil.DefaultDelta)
+	require.Equal(t, ts, resp.Results[0].SourceTimestamp)
+	require.Equal(t, int64(4), resp.Results[1].Value.Value())
+	require.Equal(t, uint64(42), resp.Results[2].Value.Value())
+
+	// Check the folder hierarchy
+	refs, err := client.Node(ua.NewNumericNodeID(0, id.ObjectsFolder)).ReferencedNodes(ctx, id.Organizes, ua.BrowseDirectionForward, ua.NodeClassAll, true)
+	require.NoError(t, err)
+	var found bool
+	for _, ref := range refs {
+		if ref.ID.Namespace() == ns {
+			found = true
+		}
+	}
+	require.True(t, found, "telegraf folder not referenced from objects folder")
+
+	refs, err = client.Node(ua.NewStringNodeID(ns, "cpu")).ReferencedNodes(ctx, id.Organizes, ua.BrowseDirectionForward, ua.NodeClassAll, true)
+	require.NoError(t, err)
+	require.Len(t, refs, 1)
+	require.Equal(t, "cpu/server01", refs[0].ID.StringID())
+
+	// Updates are reflected
+	metrics = []telegraf.Metric{
+		metric.New(
+			"system",
+			map[string]string{},
+			map[string]interface{}{"uptime": uint64(43)},
+			ts.Add(time.Second),
+		),
+	}
+	require.NoError(t, plugin.Write(metrics))
+	value, err := client.Node(ua.NewStringNodeID(ns, "system/uptime")).Value(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(43), value.Value())
+
+	// Check the diagnostics
+	diag, ok := plugin.diagnostics().(*serverDiagnostics)
+	require.True(t, ok)
+	require.Equal(t, endpoint, diag.Endpoint)
+	require.Equal(t, "ServerStateRunning", diag.State)
+	require.Equal(t, int64(3), diag.Folders)
+	require.Equal(t, int64(3), diag.Variables)
+}
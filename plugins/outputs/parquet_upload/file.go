@@ -0,0 +1,332 @@
+package parquet_upload
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/google/uuid"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/common/columnar"
+)
+
+// Suffixes of the files in the buffer directory
+const (
+	suffixOpen = ".parquet.tmp"
+	suffixData = ".parquet"
+	suffixInfo = ".json"
+)
+
+type column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// fileInfo describes a completed data file, it is stored next to the data
+// file in the buffer directory to resume uploads after a restart
+type fileInfo struct {
+	ID          string            `json:"id"`
+	Key         string            `json:"key"`
+	Location    string            `json:"location,omitempty"`
+	Measurement string            `json:"measurement"`
+	Partition   map[string]string `json:"partition"`
+	Records     int64             `json:"records"`
+	Size        int64             `json:"size"`
+	MinTime     time.Time         `json:"min_time"`
+	MaxTime     time.Time         `json:"max_time"`
+	Columns     []column          `json:"columns"`
+	Uploaded    bool              `json:"uploaded"`
+
+	// Time of the next upload attempt after failures
+	retryAt time.Time
+}
+
+// bufferFile is a data file currently written in the buffer directory
+type bufferFile struct {
+	info    *fileInfo
+	created time.Time
+	file    *os.File
+	counter *countingWriter
+	writer  *pqarrow.FileWriter
+	schema  *arrow.Schema
+}
+
+// countingWriter keeps track of the size of the data file
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// open creates a new data file for the partition in the buffer directory
+func (p *ParquetUpload) open(measurement string, start time.Time, columns []column) (*bufferFile, error) {
+	id := uuid.NewString()
+	info := &fileInfo{
+		ID:          id,
+		Measurement: measurement,
+		Partition:   map[string]string{"date": start.Format("2006-01-02")},
+		Columns:     columns,
+	}
+	if p.PartitionInterval == "hour" {
+		info.Partition["hour"] = start.Format("15")
+	}
+	info.Key = p.dataKey(info)
+
+	fields := make([]arrow.Field, 0, len(columns)+1)
+	fields = append(fields, arrow.Field{
+		Name: p.TimestampColumn,
+		Type: &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"},
+	})
+	for _, c := range columns {
+		fields = append(fields, arrow.Field{Name: c.Name, Type: columnar.ArrowType(c.Type), Nullable: true})
+	}
+	schema := arrow.NewSchema(fields, nil)
+
+	filename := filepath.Join(p.BufferDirectory, id+suffixOpen)
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, fmt.Errorf("creating file failed: %w", err)
+	}
+	counter := &countingWriter{w: file}
+	props := parquet.NewWriterProperties(parquet.WithCompression(p.compression))
+	writer, err := pqarrow.NewFileWriter(schema, counter, props, pqarrow.DefaultWriterProps())
+	if err != nil {
+		file.Close()
+		os.Remove(filename)
+		return nil, fmt.Errorf("creating writer failed: %w", err)
+	}
+
+	return &bufferFile{
+		info:    info,
+		created: time.Now(),
+		file:    file,
+		counter: counter,
+		writer:  writer,
+		schema:  schema,
+	}, nil
+}
+
+// write appends the metrics as a row group to the data file
+func (f *bufferFile) write(metrics []telegraf.Metric) error {
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, f.schema)
+	defer builder.Release()
+
+	for _, m := range metrics {
+		t := m.Time()
+		builder.Field(0).(*array.TimestampBuilder).Append(arrow.Timestamp(t.UnixMicro()))
+		for i, c := range f.info.Columns {
+			appendValue(builder.Field(i+1), c.Type, m, c.Name)
+		}
+
+		if f.info.Records == 0 || t.Before(f.info.MinTime) {
+			f.info.MinTime = t
+		}
+		if f.info.Records == 0 || t.After(f.info.MaxTime) {
+			f.info.MaxTime = t
+		}
+		f.info.Records++
+	}
+
+	record := builder.NewRecord()
+	defer record.Release()
+	return f.writer.Write(record)
+}
+
+// finish completes the data file and queues it for upload
+func (p *ParquetUpload) finish(f *bufferFile) error {
+	base := filepath.Join(p.BufferDirectory, f.info.ID)
+	if err := f.writer.Close(); err != nil {
+		f.file.Close()
+		return fmt.Errorf("closing writer failed: %w", err)
+	}
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("closing file failed: %w", err)
+	}
+	f.info.Size = f.counter.n
+
+	// Store the description before marking the data file as complete
+	if err := p.saveInfo(f.info); err != nil {
+		return err
+	}
+	if err := os.Rename(base+suffixOpen, base+suffixData); err != nil {
+		return fmt.Errorf("renaming file failed: %w", err)
+	}
+	p.pending = append(p.pending, f.info)
+	return nil
+}
+
+// saveInfo atomically writes the file description to the buffer directory
+func (p *ParquetUpload) saveInfo(info *fileInfo) error {
+	buf, err := json.Marshal(info)
+	if err != nil {
+		return fmt.Errorf("encoding file info failed: %w", err)
+	}
+	filename := filepath.Join(p.BufferDirectory, info.ID+suffixInfo)
+	if err := os.WriteFile(filename+".tmp", buf, 0640); err != nil {
+		return fmt.Errorf("writing file info failed: %w", err)
+	}
+	if err := os.Rename(filename+".tmp", filename); err != nil {
+		return fmt.Errorf("renaming file info failed: %w", err)
+	}
+	return nil
+}
+
+// restore queues the files remaining in the buffer directory from a
+// previous run
+func (p *ParquetUpload) restore() error {
+	entries, err := os.ReadDir(p.BufferDirectory)
+	if err != nil {
+		return fmt.Errorf("reading buffer directory failed: %w", err)
+	}
+
+	infos := make(map[string]bool)
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, suffixInfo) {
+			continue
+		}
+		base := filepath.Join(p.BufferDirectory, strings.TrimSuffix(name, suffixInfo))
+		buf, err := os.ReadFile(base + suffixInfo)
+		if err != nil {
+			return fmt.Errorf("reading file info failed: %w", err)
+		}
+		var info fileInfo
+		if err := json.Unmarshal(buf, &info); err != nil {
+			p.Log.Errorf("Ignoring invalid file info %q: %v", name, err)
+			continue
+		}
+		infos[info.ID] = true
+
+		if info.Uploaded {
+			p.uploaded = append(p.uploaded, &info)
+			continue
+		}
+
+		// The data file is complete if the description exists, so finish
+		// the renaming if interrupted
+		if _, err := os.Stat(base + suffixOpen); err == nil {
+			if err := os.Rename(base+suffixOpen, base+suffixData); err != nil {
+				return fmt.Errorf("renaming file failed: %w", err)
+			}
+		}
+		if _, err := os.Stat(base + suffixData); err != nil {
+			p.Log.Errorf("Data file of %q is missing: %v", name, err)
+			os.Remove(base + suffixInfo)
+			continue
+		}
+		p.pending = append(p.pending, &info)
+	}
+
+	// Files without description were not completed and cannot be read
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasSuffix(name, suffixOpen) && !infos[strings.TrimSuffix(name, suffixOpen)] {
+			p.Log.Warnf("Removing incomplete data file %q", name)
+			if err := os.Remove(filepath.Join(p.BufferDirectory, name)); err != nil {
+				return fmt.Errorf("removing incomplete file failed: %w", err)
+			}
+		}
+	}
+
+	// Upload the oldest data first
+	slices.SortFunc(p.pending, func(a, b *fileInfo) int { return a.MinTime.Compare(b.MinTime) })
+	return nil
+}
+
+// dataKey returns the key of the data file in the object store using
+// Hive-style partitioning
+func (p *ParquetUpload) dataKey(info *fileInfo) string {
+	segments := []string{
+		"measurement=" + columnar.EscapePartition(info.Measurement),
+		"date=" + info.Partition["date"],
+	}
+	if hour, found := info.Partition["hour"]; found {
+		segments = append(segments, "hour="+hour)
+	}
+	segments = append(segments, fmt.Sprintf("part-%d-%s.parquet", time.Now().Unix(), info.ID))
+	return strings.Join(segments, "/")
+}
+
+// mergeColumns returns the columns required for the metrics in addition to
+// the given columns and whether the columns changed; columns with
+// incompatible types replace the existing column
+func mergeColumns(columns []column, metrics []telegraf.Metric, timestampColumn string) ([]column, bool) {
+	result := slices.Clone(columns)
+	index := make(map[string]int, len(columns))
+	for i, c := range columns {
+		index[c.Name] = i
+	}
+
+	var added []column
+	var changed bool
+	add := func(name, typ string) {
+		if name == timestampColumn {
+			return
+		}
+		if i, found := index[name]; found {
+			if i >= 0 && !compatible(result[i].Type, typ) {
+				result[i].Type = typ
+				changed = true
+			}
+			return
+		}
+		index[name] = -1
+		added = append(added, column{Name: name, Type: typ})
+	}
+	for _, m := range metrics {
+		// Fields take precedence over tags of the same name
+		for _, field := range m.FieldList() {
+			if typ, ok := columnar.ColumnType(field.Value); ok {
+				add(field.Key, typ)
+			}
+		}
+		for _, tag := range m.TagList() {
+			if !m.HasField(tag.Key) {
+				add(tag.Key, columnar.TypeString)
+			}
+		}
+	}
+	if len(added) == 0 {
+		return result, changed
+	}
+
+	// Sort new columns to get a deterministic schema
+	slices.SortStableFunc(added, func(a, b column) int { return strings.Compare(a.Name, b.Name) })
+	return append(result, added...), true
+}
+
+// compatible returns true if values of the given type can be stored in the
+// column without loss
+func compatible(columnType, typ string) bool {
+	return columnType == typ || columnType == columnar.TypeDouble && (typ == columnar.TypeLong || typ == columnar.TypeUnsigned)
+}
+
+func appendValue(b array.Builder, typ string, m telegraf.Metric, name string) {
+	value, found := m.GetField(name)
+	if !found {
+		if v, ok := m.GetTag(name); ok {
+			value, found = v, true
+		}
+	}
+	if !found {
+		b.AppendNull()
+		return
+	}
+	columnar.Append(b, typ, value)
+}
@@ -0,0 +1,405 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package parquet_upload
+
+import (
+	"context"
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/google/uuid"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/columnar"
+	"github.com/influxdata/telegraf/plugins/common/objectstore"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Interval for checking the age of data files and pending uploads
+const checkInterval = time.Second
+
+// Directory of the manifests in the object store
+const manifestDir = "_manifests/"
+
+// manifest lists the data files uploaded in one upload cycle
+type manifest struct {
+	Created time.Time   `json:"created"`
+	Writer  string      `json:"writer"`
+	Files   []*fileInfo `json:"files"`
+}
+
+type ParquetUpload struct {
+	Location          string          `toml:"location"`
+	BufferDirectory   string          `toml:"buffer_directory"`
+	PartitionInterval string          `toml:"partition_interval"`
+	TimestampColumn   string          `toml:"timestamp_column"`
+	Compression       string          `toml:"compression"`
+	RotationInterval  config.Duration `toml:"rotation_interval"`
+	RotationMaxSize   config.Size     `toml:"rotation_max_size"`
+	UploadRetries     int             `toml:"upload_retries"`
+	RetryInterval     config.Duration `toml:"retry_interval"`
+	UploadTimeout     config.Duration `toml:"upload_timeout"`
+	Manifest          bool            `toml:"manifest"`
+	Log               telegraf.Logger `toml:"-"`
+	objectstore.Config
+
+	store       objectstore.Store
+	compression compress.Compression
+
+	// Open data files by partition and completed files, protected by the
+	// mutex as the upload runs in the background
+	files    map[string]*bufferFile
+	pending  []*fileInfo
+	uploaded []*fileInfo
+	mu       sync.Mutex
+
+	// Serializes the upload cycles of the background worker and Close
+	uploadMu sync.Mutex
+	cancel   context.CancelFunc
+	wg       sync.WaitGroup
+}
+
+func (*ParquetUpload) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *ParquetUpload) Init() error {
+	if p.Location == "" {
+		return errors.New("missing 'location'")
+	}
+	if p.BufferDirectory == "" {
+		return errors.New("missing 'buffer_directory'")
+	}
+
+	switch p.PartitionInterval {
+	case "":
+		p.PartitionInterval = "hour"
+	case "hour", "day":
+	default:
+		return fmt.Errorf("invalid 'partition_interval' %q", p.PartitionInterval)
+	}
+	if p.TimestampColumn == "" {
+		p.TimestampColumn = "timestamp"
+	}
+
+	if p.Compression == "" {
+		p.Compression = "snappy"
+	}
+	codec, found := columnar.Compressions[p.Compression]
+	if !found {
+		return fmt.Errorf("invalid 'compression' %q", p.Compression)
+	}
+	p.compression = codec
+
+	if p.RotationInterval <= 0 {
+		return errors.New("'rotation_interval' must be positive")
+	}
+	if p.RotationMaxSize <= 0 {
+		return errors.New("'rotation_max_size' must be positive")
+	}
+	if p.UploadRetries < 0 {
+		return errors.New("'upload_retries' must not be negative")
+	}
+
+	if err := os.MkdirAll(p.BufferDirectory, 0750); err != nil {
+		return fmt.Errorf("creating buffer directory failed: %w", err)
+	}
+
+	return nil
+}
+
+func (p *ParquetUpload) Connect() error {
+	store, err := p.Config.NewStore(p.Location)
+	if err != nil {
+		return fmt.Errorf("creating store failed: %w", err)
+	}
+	p.store = store
+
+	// Resume uploading the files of a previous run
+	p.files = make(map[string]*bufferFile)
+	p.pending = nil
+	p.uploaded = nil
+	if err := p.restore(); err != nil {
+		return err
+	}
+	if n := len(p.pending); n > 0 {
+		p.Log.Infof("Resuming upload of %d data files", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.rotate(false)
+				p.upload(ctx, false)
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (p *ParquetUpload) Close() error {
+	if p.cancel != nil {
+		p.cancel()
+		p.wg.Wait()
+	}
+
+	// Complete all open files and try to upload everything
+	if p.files != nil {
+		p.rotate(true)
+	}
+	if p.store == nil {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.UploadTimeout))
+	p.upload(ctx, true)
+	cancel()
+
+	p.mu.Lock()
+	if n := len(p.pending) + len(p.uploaded); n > 0 {
+		p.Log.Warnf("%d data files not uploaded, remaining in %q for the next start", n, p.BufferDirectory)
+	}
+	p.mu.Unlock()
+
+	return p.store.Close()
+}
+
+func (p *ParquetUpload) Write(metrics []telegraf.Metric) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Group the metrics by measurement and time partition
+	groups := make(map[string][]telegraf.Metric)
+	starts := make(map[string]time.Time)
+	order := make([]string, 0)
+	for _, m := range metrics {
+		start := p.partitionStart(m.Time())
+		key := m.Name() + "\x00" + start.Format(time.RFC3339)
+		if _, found := groups[key]; !found {
+			starts[key] = start
+			order = append(order, key)
+		}
+		groups[key] = append(groups[key], m)
+	}
+
+	for _, key := range order {
+		group := groups[key]
+
+		// Start a new file if the schema changes as the schema of a
+		// Parquet file cannot be extended
+		f := p.files[key]
+		var current []column
+		if f != nil {
+			current = f.info.Columns
+		}
+		columns, changed := mergeColumns(current, group, p.TimestampColumn)
+		if f != nil && changed {
+			delete(p.files, key)
+			if err := p.finish(f); err != nil {
+				return fmt.Errorf("completing data file %q failed: %w", f.info.ID, err)
+			}
+			f = nil
+		}
+		if f == nil {
+			var err error
+			if f, err = p.open(group[0].Name(), starts[key], columns); err != nil {
+				return fmt.Errorf("creating data file failed: %w", err)
+			}
+			p.files[key] = f
+		}
+
+		if err := f.write(group); err != nil {
+			return fmt.Errorf("writing data file %q failed: %w", f.info.ID, err)
+		}
+		if f.counter.n >= int64(p.RotationMaxSize) {
+			delete(p.files, key)
+			if err := p.finish(f); err != nil {
+				return fmt.Errorf("completing data file %q failed: %w", f.info.ID, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// rotate completes the files exceeding the rotation interval or all files
+func (p *ParquetUpload) rotate(all bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, f := range p.files {
+		if !all && time.Since(f.created) < time.Duration(p.RotationInterval) {
+			continue
+		}
+		delete(p.files, key)
+		if err := p.finish(f); err != nil {
+			p.Log.Errorf("Completing data file %q failed: %v", f.info.ID, err)
+		}
+	}
+}
+
+// upload transfers the completed files to the object store and writes a
+// manifest for the uploaded files, failed uploads are retried in the
+// next cycle
+func (p *ParquetUpload) upload(ctx context.Context, force bool) {
+	p.uploadMu.Lock()
+	defer p.uploadMu.Unlock()
+
+	p.mu.Lock()
+	pending := slices.Clone(p.pending)
+	p.mu.Unlock()
+
+	now := time.Now()
+	for _, info := range pending {
+		if !force && now.Before(info.retryAt) {
+			continue
+		}
+		if err := p.uploadFile(ctx, info); err != nil {
+			p.Log.Errorf("Uploading data file %q failed: %v", info.Key, err)
+			info.retryAt = time.Now().Add(time.Duration(p.RotationInterval))
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		p.mu.Lock()
+		p.pending = slices.DeleteFunc(p.pending, func(i *fileInfo) bool { return i == info })
+		p.uploaded = append(p.uploaded, info)
+		p.mu.Unlock()
+	}
+
+	p.mu.Lock()
+	uploaded := slices.Clone(p.uploaded)
+	p.mu.Unlock()
+	if len(uploaded) == 0 {
+		return
+	}
+	if p.Manifest {
+		if err := p.writeManifest(ctx, uploaded); err != nil {
+			p.Log.Errorf("Writing manifest failed: %v", err)
+			return
+		}
+	}
+
+	// The files are completely processed so forget about them
+	for _, info := range uploaded {
+		if err := os.Remove(filepath.Join(p.BufferDirectory, info.ID+suffixInfo)); err != nil {
+			p.Log.Errorf("Removing file info of %q failed: %v", info.Key, err)
+		}
+	}
+	p.mu.Lock()
+	p.uploaded = slices.DeleteFunc(p.uploaded, func(i *fileInfo) bool { return slices.Contains(uploaded, i) })
+	p.mu.Unlock()
+}
+
+// uploadFile transfers the data file to the object store and removes the
+// local file afterwards
+func (p *ParquetUpload) uploadFile(ctx context.Context, info *fileInfo) error {
+	filename := filepath.Join(p.BufferDirectory, info.ID+suffixData)
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("reading file failed: %w", err)
+	}
+	if err := p.retry(ctx, func(ctx context.Context) error { return p.store.Put(ctx, info.Key, data) }); err != nil {
+		return err
+	}
+	p.Log.Debugf("Uploaded data file %q with %d metrics", info.Key, info.Records)
+
+	// Remember the upload before removing the data file to include the file
+	// into the manifest even after a restart
+	info.Uploaded = true
+	info.Location = p.store.URL(info.Key)
+	if err := p.saveInfo(info); err != nil {
+		return err
+	}
+	if err := os.Remove(filename); err != nil {
+		p.Log.Errorf("Removing uploaded data file %q failed: %v", filename, err)
+	}
+	return nil
+}
+
+func (p *ParquetUpload) writeManifest(ctx context.Context, files []*fileInfo) error {
+	now := time.Now().UTC()
+	data, err := json.Marshal(&manifest{
+		Created: now,
+		Writer:  internal.ProductToken(),
+		Files:   files,
+	})
+	if err != nil {
+		return fmt.Errorf("encoding manifest failed: %w", err)
+	}
+
+	key := manifestDir + now.Format("20060102T150405Z") + "-" + uuid.NewString() + ".json"
+	if err := p.retry(ctx, func(ctx context.Context) error { return p.store.Put(ctx, key, data) }); err != nil {
+		return err
+	}
+	p.Log.Debugf("Wrote manifest %q for %d data files", key, len(files))
+	return nil
+}
+
+// retry calls the function until it succeeds using an exponential backoff
+func (p *ParquetUpload) retry(ctx context.Context, f func(context.Context) error) error {
+	delay := time.Duration(p.RetryInterval)
+	for attempt := 0; ; attempt++ {
+		err := f(ctx)
+		if err == nil || attempt >= p.UploadRetries {
+			return err
+		}
+		p.Log.Debugf("Attempt %d failed: %v", attempt+1, err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// partitionStart returns the start of the time partition of the metric
+func (p *ParquetUpload) partitionStart(t time.Time) time.Time {
+	t = t.UTC()
+	if p.PartitionInterval == "day" {
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	return t.Truncate(time.Hour)
+}
+
+func init() {
+	outputs.Add("parquet_upload", func() telegraf.Output {
+		return &ParquetUpload{
+			PartitionInterval: "hour",
+			TimestampColumn:   "timestamp",
+			Compression:       "snappy",
+			RotationInterval:  config.Duration(15 * time.Minute),
+			RotationMaxSize:   config.Size(128 * 1024 * 1024),
+			UploadRetries:     3,
+			RetryInterval:     config.Duration(time.Second),
+			UploadTimeout:     config.Duration(time.Minute),
+			Manifest:          true,
+		}
+	})
+}
@@ -0,0 +1,412 @@
+package parquet_upload
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/common/objectstore"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+type failingStore struct {
+	objectstore.Store
+	failures int
+	calls    int
+	sync.Mutex
+}
+
+func (s *failingStore) Put(ctx context.Context, key string, data []byte) error {
+	s.Lock()
+	defer s.Unlock()
+	s.calls++
+	if s.failures != 0 {
+		s.failures--
+		return errors.New("connection refused")
+	}
+	return s.Store.Put(ctx, key, data)
+}
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*ParquetUpload)
+		expected string
+	}{
+		{
+			name:     "missing location",
+			modify:   func(p *ParquetUpload) { p.Location = "" },
+			expected: "missing 'location'",
+		},
+		{
+			name:     "missing buffer directory",
+			modify:   func(p *ParquetUpload) { p.BufferDirectory = "" },
+			expected: "missing 'buffer_directory'",
+		},
+		{
+			name:     "invalid partition interval",
+			modify:   func(p *ParquetUpload) { p.PartitionInterval = "minute" },
+			expected: "invalid 'partition_interval' \"minute\"",
+		},
+		{
+			name:     "invalid compression",
+			modify:   func(p *ParquetUpload) { p.Compression = "lz4" },
+			expected: "invalid 'compression' \"lz4\"",
+		},
+		{
+			name:     "zero rotation interval",
+			modify:   func(p *ParquetUpload) { p.RotationInterval = 0 },
+			expected: "'rotation_interval' must be positive",
+		},
+		{
+			name:     "zero rotation size",
+			modify:   func(p *ParquetUpload) { p.RotationMaxSize = 0 },
+			expected: "'rotation_max_size' must be positive",
+		},
+		{
+			name:     "negative retries",
+			modify:   func(p *ParquetUpload) { p.UploadRetries = -1 },
+			expected: "'upload_retries' must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := newPlugin(t.TempDir(), t.TempDir())
+			tt.modify(plugin)
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	location := t.TempDir()
+	buffer := t.TempDir()
+
+	plugin := newPlugin(location, buffer)
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+
+	ts := time.Date(2024, 6, 1, 12, 30, 0, 123456789, time.UTC)
+	require.NoError(t, plugin.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{"host": "a"}, map[string]interface{}{"usage": 42.5, "count": int64(3)}, ts),
+		metric.New("cpu", map[string]string{"host": "b"}, map[string]interface{}{"usage": 1.5}, ts.Add(time.Hour)),
+		metric.New("disk/io", map[string]string{}, map[string]interface{}{"reads": uint64(7), "ok": true}, ts),
+	}))
+	require.NoError(t, plugin.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{"host": "c"}, map[string]interface{}{"usage": int64(2)}, ts.Add(time.Minute)),
+	}))
+
+	// Files are only uploaded after completion
+	require.Empty(t, listFiles(t, location))
+	require.NoError(t, plugin.Close())
+
+	require.ElementsMatch(t,
+		[]string{
+			"measurement=cpu/date=2024-06-01/hour=12",
+			"measurement=cpu/date=2024-06-01/hour=13",
+			"measurement=disk%2Fio/date=2024-06-01/hour=12",
+		},
+		dataDirectories(t, location),
+	)
+	files, err := filepath.Glob(filepath.Join(location, "measurement=cpu", "date=2024-06-01", "hour=12", "*.parquet"))
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	record := readParquet(t, files[0])
+	require.Equal(t, []string{"timestamp", "count", "host", "usage"}, fieldNames(record.Schema()))
+	require.Equal(t,
+		`[{"count":3,"host":"a","timestamp":"2024-06-01 12:30:00.123456Z","usage":42.5},`+
+			`{"count":null,"host":"c","timestamp":"2024-06-01 12:31:00.123456Z","usage":2}]`,
+		rowsJSON(t, record),
+	)
+
+	// Check the manifest
+	manifests := readManifests(t, location)
+	require.Len(t, manifests, 1)
+	require.Len(t, manifests[0].Files, 3)
+	var info *fileInfo
+	for _, f := range manifests[0].Files {
+		if f.Measurement == "disk/io" {
+			info = f
+		}
+	}
+	require.NotNil(t, info)
+	require.Equal(t, map[string]string{"date": "2024-06-01", "hour": "12"}, info.Partition)
+	require.Equal(t, int64(1), info.Records)
+	require.Equal(t, []column{{Name: "ok", Type: "boolean"}, {Name: "reads", Type: "unsigned"}}, info.Columns)
+	require.Equal(t, "file://"+filepath.ToSlash(filepath.Join(location, filepath.FromSlash(info.Key))), info.Location)
+	stat, err := os.Stat(filepath.Join(location, filepath.FromSlash(info.Key)))
+	require.NoError(t, err)
+	require.Equal(t, stat.Size(), info.Size)
+
+	// The buffer is empty after uploading everything
+	require.Empty(t, listFiles(t, buffer))
+}
+
+func TestRotation(t *testing.T) {
+	location := t.TempDir()
+
+	plugin := newPlugin(location, t.TempDir())
+	plugin.PartitionInterval = "day"
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	// Stop the background upload to check the completed files
+	plugin.cancel()
+	plugin.wg.Wait()
+
+	ts := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	require.NoError(t, plugin.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 42.5}, ts),
+	}))
+
+	// Changing the schema completes the current file
+	require.NoError(t, plugin.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 1.5, "idle": 2.5}, ts),
+	}))
+	require.Len(t, plugin.pending, 1)
+	require.Len(t, plugin.files, 1)
+
+	// Incompatible types complete the current file, compatible ones do not
+	require.NoError(t, plugin.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": int64(1), "idle": "high"}, ts),
+	}))
+	require.Len(t, plugin.pending, 2)
+	require.Len(t, plugin.files, 1)
+	for _, f := range plugin.files {
+		require.Equal(t, []column{{Name: "usage", Type: "double"}, {Name: "idle", Type: "string"}}, f.info.Columns)
+	}
+
+	// Exceeding the size completes the file
+	plugin.RotationMaxSize = config.Size(1)
+	require.NoError(t, plugin.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 1.5, "idle": "low"}, ts),
+	}))
+	require.Len(t, plugin.pending, 3)
+	require.Empty(t, plugin.files)
+
+	plugin.upload(t.Context(), false)
+	require.Len(t, dataFiles(t, location), 3)
+	require.Len(t, readManifests(t, location), 1)
+	for _, f := range dataFiles(t, location) {
+		require.True(t, strings.HasPrefix(f, "measurement=cpu/date=2024-06-01/part-"), f)
+	}
+}
+
+func TestUploadRetry(t *testing.T) {
+	location := t.TempDir()
+	buffer := t.TempDir()
+
+	plugin := newPlugin(location, buffer)
+	plugin.UploadRetries = 2
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	plugin.cancel()
+	plugin.wg.Wait()
+
+	// Transient errors are retried
+	store := &failingStore{Store: plugin.store, failures: 2}
+	plugin.store = store
+	ts := time.Date(2024, 6, 1, 12, 30, 0, 0, time.UTC)
+	require.NoError(t, plugin.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 42.5}, ts),
+	}))
+	plugin.rotate(true)
+	plugin.upload(t.Context(), false)
+	require.Equal(t, 4, store.calls)
+	require.Len(t, dataFiles(t, location), 1)
+	require.Len(t, readManifests(t, location), 1)
+
+	// Files failing all retries remain in the buffer
+	store.failures = -1
+	store.calls = 0
+	require.NoError(t, plugin.Write([]telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 1.5}, ts),
+	}))
+	plugin.rotate(true)
+	plugin.upload(t.Context(), false)
+	require.Equal(t, 3, store.calls)
+	require.Len(t, plugin.pending, 1)
+	require.False(t, plugin.pending[0].retryAt.IsZero())
+
+	// The file is not retried before the retry time
+	plugin.upload(t.Context(), false)
+	require.Equal(t, 3, store.calls)
+	require.Len(t, dataFiles(t, location), 1)
+
+	plugin.UploadTimeout = config.Duration(100 * time.Millisecond)
+	require.NoError(t, plugin.Close())
+	require.Len(t, listFiles(t, buffer), 2)
+
+	// Restarting the plugin resumes the upload
+	plugin = newPlugin(location, buffer)
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	require.NoError(t, plugin.Close())
+	require.Len(t, dataFiles(t, location), 2)
+	require.Len(t, readManifests(t, location), 2)
+	require.Empty(t, listFiles(t, buffer))
+}
+
+func TestRestore(t *testing.T) {
+	location := t.TempDir()
+	buffer := t.TempDir()
+
+	// Simulate files left by a previous run, an incomplete file, an uploaded
+	// file not yet in a manifest and a completed file before renaming
+	incomplete := filepath.Join(buffer, "incomplete"+suffixOpen)
+	require.NoError(t, os.WriteFile(incomplete, []byte("PAR1"), 0600))
+	uploaded := &fileInfo{ID: "uploaded", Key: "measurement=cpu/date=2024-06-01/part-1-uploaded.parquet", Uploaded: true}
+	buf, err := json.Marshal(uploaded)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(buffer, "uploaded"+suffixInfo), buf, 0600))
+	completed := &fileInfo{ID: "completed", Key: "measurement=cpu/date=2024-06-01/part-1-completed.parquet"}
+	buf, err = json.Marshal(completed)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(buffer, "completed"+suffixInfo), buf, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(buffer, "completed"+suffixOpen), []byte("data"), 0600))
+
+	plugin := newPlugin(location, buffer)
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	require.NoError(t, plugin.Close())
+
+	require.NoFileExists(t, incomplete)
+	require.Equal(t, []string{completed.Key}, dataFiles(t, location))
+	manifests := readManifests(t, location)
+	require.Len(t, manifests, 1)
+	keys := make([]string, 0, len(manifests[0].Files))
+	for _, f := range manifests[0].Files {
+		keys = append(keys, f.Key)
+	}
+	require.ElementsMatch(t, []string{completed.Key, uploaded.Key}, keys)
+	require.Empty(t, listFiles(t, buffer))
+}
+
+func newPlugin(location, buffer string) *ParquetUpload {
+	return &ParquetUpload{
+		Location:          location,
+		BufferDirectory:   buffer,
+		PartitionInterval: "hour",
+		TimestampColumn:   "timestamp",
+		Compression:       "snappy",
+		RotationInterval:  config.Duration(time.Hour),
+		RotationMaxSize:   config.Size(128 * 1024 * 1024),
+		UploadRetries:     3,
+		RetryInterval:     config.Duration(time.Millisecond),
+		UploadTimeout:     config.Duration(5 * time.Second),
+		Manifest:          true,
+		Log:               testutil.Logger{},
+	}
+}
+
+// listFiles returns all files below the directory
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	require.NoError(t, filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, filepath.ToSlash(rel))
+		return nil
+	}))
+	sort.Strings(files)
+	return files
+}
+
+// dataFiles returns the uploaded Parquet files
+func dataFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	for _, f := range listFiles(t, dir) {
+		if strings.HasSuffix(f, ".parquet") {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+func dataDirectories(t *testing.T, dir string) []string {
+	t.Helper()
+	var dirs []string
+	for _, f := range dataFiles(t, dir) {
+		dirs = append(dirs, f[:strings.LastIndex(f, "/")])
+	}
+	return dirs
+}
+
+func readManifests(t *testing.T, dir string) []*manifest {
+	t.Helper()
+	var manifests []*manifest
+	for _, f := range listFiles(t, dir) {
+		if !strings.HasPrefix(f, manifestDir) {
+			continue
+		}
+		buf, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(f)))
+		require.NoError(t, err)
+		var m manifest
+		require.NoError(t, json.Unmarshal(buf, &m))
+		manifests = append(manifests, &m)
+	}
+	return manifests
+}
+
+func readParquet(t *testing.T, filename string) arrow.Table {
+	t.Helper()
+	data, err := os.ReadFile(filename)
+	require.NoError(t, err)
+
+	table, err := pqarrow.ReadTable(t.Context(), bytes.NewReader(data), parquet.NewReaderProperties(nil), pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	require.NoError(t, err)
+	t.Cleanup(table.Release)
+	return table
+}
+
+func rowsJSON(t *testing.T, table arrow.Table) string {
+	t.Helper()
+	reader := array.NewTableReader(table, 0)
+	defer reader.Release()
+	var rows []json.RawMessage
+	for reader.Next() {
+		buf, err := reader.Record().MarshalJSON()
+		require.NoError(t, err)
+		var batch []json.RawMessage
+		require.NoError(t, json.Unmarshal(buf, &batch))
+		rows = append(rows, batch...)
+	}
+	buf, err := json.Marshal(rows)
+	require.NoError(t, err)
+	return string(buf)
+}
+
+func fieldNames(schema *arrow.Schema) []string {
+	names := make([]string, 0, schema.NumFields())
+	for _, f := range schema.Fields() {
+		names = append(names, f.Name)
+	}
+	return names
+}
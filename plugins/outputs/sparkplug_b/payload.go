@@ -0,0 +1,166 @@
+package sparkplug_b
+
+import (
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/influxdata/telegraf/plugins/common/sparkplug"
+)
+
+type payload struct {
+	timestamp uint64
+	seq       uint64
+	hasSeq    bool
+	metrics   []sparkplugMetric
+}
+
+// sparkplugMetric is a single metric of a payload. Birth messages announce
+// the name, alias and datatype while data messages only refer to the alias.
+type sparkplugMetric struct {
+	name      string
+	alias     uint64
+	hasAlias  bool
+	timestamp uint64
+	datatype  uint32
+	value     interface{}
+}
+
+func (p *payload) encode() []byte {
+	var buf []byte
+	buf = protowire.AppendTag(buf, sparkplug.PayloadTimestamp, protowire.VarintType)
+	buf = protowire.AppendVarint(buf, p.timestamp)
+	for i := range p.metrics {
+		buf = protowire.AppendTag(buf, sparkplug.PayloadMetrics, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, p.metrics[i].encode())
+	}
+	if p.hasSeq {
+		buf = protowire.AppendTag(buf, sparkplug.PayloadSeq, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, p.seq)
+	}
+	return buf
+}
+
+func (m *sparkplugMetric) encode() []byte {
+	var buf []byte
+	if m.name != "" {
+		buf = protowire.AppendTag(buf, sparkplug.MetricName, protowire.BytesType)
+		buf = protowire.AppendString(buf, m.name)
+	}
+	if m.hasAlias {
+		buf = protowire.AppendTag(buf, sparkplug.MetricAlias, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, m.alias)
+	}
+	if m.timestamp > 0 {
+		buf = protowire.AppendTag(buf, sparkplug.MetricTimestamp, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, m.timestamp)
+	}
+	if m.datatype != 0 {
+		buf = protowire.AppendTag(buf, sparkplug.MetricDatatype, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, uint64(m.datatype))
+	}
+
+	switch v := m.value.(type) {
+	case int64:
+		buf = protowire.AppendTag(buf, sparkplug.MetricLongValue, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, uint64(v))
+	case uint64:
+		buf = protowire.AppendTag(buf, sparkplug.MetricLongValue, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, v)
+	case float64:
+		buf = protowire.AppendTag(buf, sparkplug.MetricDoubleValue, protowire.Fixed64Type)
+		buf = protowire.AppendFixed64(buf, math.Float64bits(v))
+	case bool:
+		buf = protowire.AppendTag(buf, sparkplug.MetricBooleanValue, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, protowire.EncodeBool(v))
+	case string:
+		buf = protowire.AppendTag(buf, sparkplug.MetricStringValue, protowire.BytesType)
+		buf = protowire.AppendString(buf, v)
+	}
+	return buf
+}
+
+// datatypeOf returns the Sparkplug datatype for the given field value
+func datatypeOf(value interface{}) (uint32, bool) {
+	switch value.(type) {
+	case int64:
+		return sparkplug.TypeInt64, true
+	case uint64:
+		return sparkplug.TypeUInt64, true
+	case float64:
+		return sparkplug.TypeDouble, true
+	case bool:
+		return sparkplug.TypeBoolean, true
+	case string:
+		return sparkplug.TypeString, true
+	}
+	return 0, false
+}
+
+// rebirthRequested checks if the given command payload contains a
+// "Node Control/Rebirth" metric set to true
+func rebirthRequested(buf []byte) (bool, error) {
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return false, protowire.ParseError(n)
+		}
+		buf = buf[n:]
+
+		if num != sparkplug.PayloadMetrics || typ != protowire.BytesType {
+			n = protowire.ConsumeFieldValue(num, typ, buf)
+			if n < 0 {
+				return false, protowire.ParseError(n)
+			}
+			buf = buf[n:]
+			continue
+		}
+
+		raw, n := protowire.ConsumeBytes(buf)
+		if n < 0 {
+			return false, protowire.ParseError(n)
+		}
+		buf = buf[n:]
+
+		name, value, err := decodeBooleanMetric(raw)
+		if err != nil {
+			return false, err
+		}
+		if name == rebirthMetric && value {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// decodeBooleanMetric returns the name and boolean value of a metric ignoring
+// all other fields
+func decodeBooleanMetric(buf []byte) (string, bool, error) {
+	var name string
+	var value bool
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return "", false, protowire.ParseError(n)
+		}
+		buf = buf[n:]
+
+		switch {
+		case num == sparkplug.MetricName && typ == protowire.BytesType:
+			var b []byte
+			b, n = protowire.ConsumeBytes(buf)
+			name = string(b)
+		case num == sparkplug.MetricBooleanValue && typ == protowire.VarintType:
+			var v uint64
+			v, n = protowire.ConsumeVarint(buf)
+			value = v != 0
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, buf)
+		}
+		if n < 0 {
+			return "", false, protowire.ParseError(n)
+		}
+		buf = buf[n:]
+	}
+	return name, value, nil
+}
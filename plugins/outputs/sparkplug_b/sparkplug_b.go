@@ -0,0 +1,457 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package sparkplug_b
+
+import (
+	"cmp"
+	// Blank import to support go:embed compile directive
+	_ "embed"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/common/mqtt"
+	"github.com/influxdata/telegraf/plugins/common/sparkplug"
+	"github.com/influxdata/telegraf/plugins/outputs"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+const (
+	bdSeqMetric   = "bdSeq"
+	rebirthMetric = "Node Control/Rebirth"
+)
+
+type SparkplugB struct {
+	GroupID    string          `toml:"group_id"`
+	EdgeNodeID string          `toml:"edge_node_id"`
+	DeviceTag  string          `toml:"device_tag"`
+	NameTags   []string        `toml:"name_tags"`
+	Log        telegraf.Logger `toml:"-"`
+	mqtt.MqttConfig
+
+	client    mqtt.Client
+	newClient func(*mqtt.MqttConfig) (mqtt.Client, error)
+	connected atomic.Bool
+
+	// Sparkplug session state, the birth-death sequence number is
+	// incremented for each new MQTT session while the message sequence
+	// number wraps around at 256
+	bdSeq     uint64
+	seq       uint64
+	nodeBorn  bool
+	devices   map[string]*device
+	nextAlias uint64
+
+	wg sync.WaitGroup
+	sync.Mutex
+}
+
+// device holds the metric definitions of a device, the empty device ID
+// denotes the metrics of the edge node itself
+type device struct {
+	born        bool
+	definitions map[string]*definition
+}
+
+type definition struct {
+	name      string
+	alias     uint64
+	datatype  uint32
+	value     interface{}
+	timestamp time.Time
+}
+
+type sample struct {
+	def       *definition
+	value     interface{}
+	timestamp time.Time
+}
+
+func (*SparkplugB) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *SparkplugB) Init() error {
+	if s.GroupID == "" {
+		return errors.New("missing 'group_id'")
+	}
+	if s.EdgeNodeID == "" {
+		return errors.New("missing 'edge_node_id'")
+	}
+	for _, id := range []string{s.GroupID, s.EdgeNodeID} {
+		if strings.ContainsAny(id, "/+#") {
+			return fmt.Errorf("invalid identifier %q, must not contain '/', '+' or '#'", id)
+		}
+	}
+
+	// Subscribing to node commands is only supported for MQTT 3.1.1
+	switch s.Protocol {
+	case "", "3.1.1":
+	default:
+		return fmt.Errorf("unsupported protocol %q, only \"3.1.1\" is supported", s.Protocol)
+	}
+	if s.Retain {
+		return errors.New("retained messages are not allowed by the Sparkplug specification")
+	}
+
+	if s.newClient == nil {
+		s.newClient = mqtt.NewClient
+	}
+	s.devices = make(map[string]*device)
+
+	return nil
+}
+
+func (s *SparkplugB) Connect() error {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.connect()
+}
+
+func (s *SparkplugB) Close() error {
+	s.Lock()
+	if s.client == nil {
+		s.Unlock()
+		return nil
+	}
+
+	// Announce the end of the session. The broker publishes the death
+	// certificate registered as will message if this fails.
+	if s.connected.Load() {
+		for _, id := range slices.Sorted(maps.Keys(s.devices)) {
+			if id == "" || !s.devices[id].born {
+				continue
+			}
+			msg := &payload{timestamp: uint64(time.Now().UnixMilli()), seq: s.nextSeq(), hasSeq: true}
+			if err := s.client.Publish(s.topic("DDEATH", id), msg.encode()); err != nil {
+				s.Log.Warnf("Publishing death certificate of device %q failed: %v", id, err)
+			}
+		}
+		if err := s.client.Publish(s.topic("NDEATH", ""), s.nodeDeath()); err != nil {
+			s.Log.Warnf("Publishing death certificate failed: %v", err)
+		}
+	}
+	err := s.client.Close()
+	s.client = nil
+	s.connected.Store(false)
+	s.Unlock()
+
+	// Wait for pending rebirths triggered by node commands
+	s.wg.Wait()
+
+	return err
+}
+
+func (s *SparkplugB) Write(metrics []telegraf.Metric) error {
+	s.Lock()
+	defer s.Unlock()
+
+	if !s.connected.Load() {
+		if s.client != nil {
+			//nolint:errcheck // The connection is already broken
+			s.client.Close()
+		}
+		if err := s.connect(); err != nil {
+			return fmt.Errorf("reconnecting failed: %w", err)
+		}
+	}
+
+	// Collect the values per device and register new metrics which require
+	// a rebirth to announce the definitions
+	samples := make(map[string][]sample)
+	for _, m := range metrics {
+		id := ""
+		if s.DeviceTag != "" {
+			id, _ = m.GetTag(s.DeviceTag)
+		}
+		// The device identifier is part of the topic so it must not contain
+		// topic separators or wildcards
+		if strings.ContainsAny(id, "/+#") {
+			s.Log.Errorf("Ignoring metric %q with invalid device identifier %q, must not contain '/', '+' or '#'", m.Name(), id)
+			continue
+		}
+		for _, field := range m.FieldList() {
+			name := s.metricName(m, field.Key)
+			datatype, ok := datatypeOf(field.Value)
+			if !ok {
+				s.Log.Debugf("Ignoring field %q of unsupported type %T", name, field.Value)
+				continue
+			}
+
+			dev, found := s.devices[id]
+			if !found {
+				dev = &device{definitions: make(map[string]*definition)}
+				s.devices[id] = dev
+			}
+			def, found := dev.definitions[name]
+			if !found {
+				def = &definition{
+					name:      name,
+					alias:     s.nextAlias,
+					datatype:  datatype,
+					value:     field.Value,
+					timestamp: m.Time(),
+				}
+				s.nextAlias++
+				dev.definitions[name] = def
+				dev.born = false
+				if id == "" {
+					s.nodeBorn = false
+				}
+			} else if def.datatype != datatype {
+				s.Log.Warnf("Ignoring value of %q with type %T not matching the announced datatype", name, field.Value)
+				continue
+			}
+			samples[id] = append(samples[id], sample{def: def, value: field.Value, timestamp: m.Time()})
+		}
+	}
+
+	if err := s.publishBirths(); err != nil {
+		return err
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(samples)) {
+		msg := &payload{
+			timestamp: uint64(time.Now().UnixMilli()),
+			seq:       s.nextSeq(),
+			hasSeq:    true,
+			metrics:   make([]sparkplugMetric, 0, len(samples[id])),
+		}
+		for _, smpl := range samples[id] {
+			msg.metrics = append(msg.metrics, sparkplugMetric{
+				alias:     smpl.def.alias,
+				hasAlias:  true,
+				timestamp: uint64(smpl.timestamp.UnixMilli()),
+				value:     smpl.value,
+			})
+			smpl.def.value = smpl.value
+			smpl.def.timestamp = smpl.timestamp
+		}
+
+		messageType := "DDATA"
+		if id == "" {
+			messageType = "NDATA"
+		}
+		if err := s.publish(s.topic(messageType, id), msg.encode()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// connect starts a new Sparkplug session registering the death certificate
+// as will message and publishes the birth certificates of the edge node
+func (s *SparkplugB) connect() error {
+	s.bdSeq++
+	s.nodeBorn = false
+	for _, dev := range s.devices {
+		dev.born = false
+	}
+
+	cfg := s.MqttConfig
+	cfg.Will = &mqtt.WillMessage{
+		Topic:   s.topic("NDEATH", ""),
+		Payload: s.nodeDeath(),
+		QoS:     1,
+	}
+	cfg.OnConnectionLost = func(err error) {
+		s.Log.Errorf("Connection lost: %v", err)
+		s.connected.Store(false)
+	}
+
+	client, err := s.newClient(&cfg)
+	if err != nil {
+		return err
+	}
+	s.client = client
+	if _, err := s.client.Connect(); err != nil {
+		return err
+	}
+	s.connected.Store(true)
+
+	topic := s.topic("NCMD", "")
+	if err := s.client.SubscribeMultiple(map[string]byte{topic: byte(s.QoS)}, s.onCommand); err != nil {
+		return fmt.Errorf("subscribing to %q failed: %w", topic, err)
+	}
+
+	return s.publishBirths()
+}
+
+// onCommand handles node commands and triggers the rebirth of the edge node
+// if requested by the host application
+func (s *SparkplugB) onCommand(_ paho.Client, msg paho.Message) {
+	requested, err := rebirthRequested(msg.Payload())
+	if err != nil {
+		s.Log.Errorf("Decoding command on %q failed: %v", msg.Topic(), err)
+		return
+	}
+	if !requested {
+		return
+	}
+
+	// Do not block the message handler of the client while publishing
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		s.Lock()
+		defer s.Unlock()
+		if s.client == nil || !s.connected.Load() {
+			return
+		}
+		s.Log.Debug("Rebirth requested")
+		s.nodeBorn = false
+		if err := s.publishBirths(); err != nil {
+			s.Log.Errorf("Rebirth failed: %v", err)
+		}
+	}()
+}
+
+// publishBirths publishes the birth certificate of the edge node, if
+// required, followed by the birth certificates of all devices not announced
+// in the current session
+func (s *SparkplugB) publishBirths() error {
+	if !s.nodeBorn {
+		// The node birth resets the message sequence number and requires
+		// all devices to be announced again
+		s.seq = 0
+		msg := &payload{
+			timestamp: uint64(time.Now().UnixMilli()),
+			seq:       s.nextSeq(),
+			hasSeq:    true,
+			metrics: []sparkplugMetric{
+				{name: bdSeqMetric, datatype: sparkplug.TypeUInt64, value: s.bdSeq},
+				{name: rebirthMetric, datatype: sparkplug.TypeBoolean, value: false},
+			},
+		}
+		if dev, found := s.devices[""]; found {
+			msg.metrics = append(msg.metrics, dev.birthMetrics()...)
+		}
+		if err := s.publish(s.topic("NBIRTH", ""), msg.encode()); err != nil {
+			return err
+		}
+		s.nodeBorn = true
+		for _, dev := range s.devices {
+			dev.born = false
+		}
+		if dev, found := s.devices[""]; found {
+			dev.born = true
+		}
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(s.devices)) {
+		dev := s.devices[id]
+		if id == "" || dev.born {
+			continue
+		}
+		msg := &payload{
+			timestamp: uint64(time.Now().UnixMilli()),
+			seq:       s.nextSeq(),
+			hasSeq:    true,
+			metrics:   dev.birthMetrics(),
+		}
+		if err := s.publish(s.topic("DBIRTH", id), msg.encode()); err != nil {
+			return err
+		}
+		dev.born = true
+	}
+
+	return nil
+}
+
+func (s *SparkplugB) publish(topic string, buf []byte) error {
+	if err := s.client.Publish(topic, buf); err != nil {
+		// A timeout indicates a broken connection, so start a new session
+		// with the next write
+		if errors.Is(err, internal.ErrTimeout) {
+			s.connected.Store(false)
+		}
+		return fmt.Errorf("publishing to %q failed: %w", topic, err)
+	}
+	return nil
+}
+
+// nodeDeath returns the payload of the death certificate of the edge node
+// for the current session
+func (s *SparkplugB) nodeDeath() []byte {
+	msg := &payload{
+		timestamp: uint64(time.Now().UnixMilli()),
+		metrics: []sparkplugMetric{
+			{name: bdSeqMetric, datatype: sparkplug.TypeUInt64, value: s.bdSeq},
+		},
+	}
+	return msg.encode()
+}
+
+func (s *SparkplugB) nextSeq() uint64 {
+	seq := s.seq
+	s.seq = (s.seq + 1) % 256
+	return seq
+}
+
+func (s *SparkplugB) topic(messageType, id string) string {
+	topic := sparkplug.Namespace + "/" + s.GroupID + "/" + messageType + "/" + s.EdgeNodeID
+	if id != "" {
+		topic += "/" + id
+	}
+	return topic
+}
+
+// metricName constructs the Sparkplug metric name from the measurement, the
+// values of the configured name tags and the field name
+func (s *SparkplugB) metricName(m telegraf.Metric, field string) string {
+	parts := make([]string, 0, len(s.NameTags)+2)
+	parts = append(parts, m.Name())
+	for _, key := range s.NameTags {
+		if value, found := m.GetTag(key); found {
+			parts = append(parts, value)
+		}
+	}
+	parts = append(parts, field)
+	return strings.Join(parts, "/")
+}
+
+// birthMetrics returns the definitions of the device with their latest values
+// ordered by alias
+func (d *device) birthMetrics() []sparkplugMetric {
+	defs := slices.SortedFunc(maps.Values(d.definitions), func(a, b *definition) int {
+		return cmp.Compare(a.alias, b.alias)
+	})
+
+	metrics := make([]sparkplugMetric, 0, len(defs))
+	for _, def := range defs {
+		metrics = append(metrics, sparkplugMetric{
+			name:      def.name,
+			alias:     def.alias,
+			hasAlias:  true,
+			timestamp: uint64(def.timestamp.UnixMilli()),
+			datatype:  def.datatype,
+			value:     def.value,
+		})
+	}
+	return metrics
+}
+
+func init() {
+	outputs.Add("sparkplug_b", func() telegraf.Output {
+		return &SparkplugB{
+			MqttConfig: mqtt.MqttConfig{
+				KeepAlive: 30,
+				Timeout:   config.Duration(5 * time.Second),
+			},
+		}
+	})
+}
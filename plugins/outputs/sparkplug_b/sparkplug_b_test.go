@@ -0,0 +1,467 @@
+package sparkplug_b
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/common/mqtt"
+	"github.com/influxdata/telegraf/plugins/common/sparkplug"
+	parser "github.com/influxdata/telegraf/plugins/parsers/sparkplug_b"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+type published struct {
+	topic   string
+	payload []byte
+}
+
+type fakeClient struct {
+	cfg       *mqtt.MqttConfig
+	messages  []published
+	onCommand paho.MessageHandler
+	closed    bool
+	sync.Mutex
+}
+
+func (*fakeClient) Connect() (bool, error) {
+	return false, nil
+}
+
+func (c *fakeClient) Publish(topic string, data []byte) error {
+	c.Lock()
+	defer c.Unlock()
+	c.messages = append(c.messages, published{topic, data})
+	return nil
+}
+
+func (c *fakeClient) SubscribeMultiple(_ map[string]byte, callback paho.MessageHandler) error {
+	c.onCommand = callback
+	return nil
+}
+
+func (*fakeClient) AddRoute(string, paho.MessageHandler) {
+	panic("not implemented")
+}
+
+func (c *fakeClient) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *fakeClient) topics() []string {
+	c.Lock()
+	defer c.Unlock()
+	topics := make([]string, 0, len(c.messages))
+	for _, msg := range c.messages {
+		topics = append(topics, msg.topic)
+	}
+	return topics
+}
+
+type command struct {
+	topic   string
+	payload []byte
+}
+
+func (*command) Duplicate() bool {
+	panic("not implemented")
+}
+
+func (*command) Qos() byte {
+	panic("not implemented")
+}
+
+func (*command) Retained() bool {
+	panic("not implemented")
+}
+
+func (c *command) Topic() string {
+	return c.topic
+}
+
+func (*command) MessageID() uint16 {
+	panic("not implemented")
+}
+
+func (c *command) Payload() []byte {
+	return c.payload
+}
+
+func (*command) Ack() {
+	panic("not implemented")
+}
+
+func newPlugin(clients *[]*fakeClient) *SparkplugB {
+	return &SparkplugB{
+		GroupID:    "plant",
+		EdgeNodeID: "telegraf",
+		DeviceTag:  "device",
+		Log:        testutil.Logger{},
+		MqttConfig: mqtt.MqttConfig{Servers: []string{"tcp://localhost:1883"}},
+		newClient: func(cfg *mqtt.MqttConfig) (mqtt.Client, error) {
+			c := &fakeClient{cfg: cfg}
+			*clients = append(*clients, c)
+			return c, nil
+		},
+	}
+}
+
+// decodeSequences returns the message sequence number and the value of the
+// bdSeq metric of the payload, -1 if not present
+func decodeSequences(t *testing.T, buf []byte) (seq, bdSeq int64) {
+	seq, bdSeq = -1, -1
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		require.Positive(t, n)
+		buf = buf[n:]
+
+		switch {
+		case num == sparkplug.PayloadSeq:
+			v, n := protowire.ConsumeVarint(buf)
+			require.Positive(t, n)
+			seq = int64(v)
+		case num == sparkplug.PayloadMetrics:
+			raw, n := protowire.ConsumeBytes(buf)
+			require.Positive(t, n)
+			var name string
+			var value uint64
+			for len(raw) > 0 {
+				num, typ, n := protowire.ConsumeTag(raw)
+				require.Positive(t, n)
+				raw = raw[n:]
+				switch num {
+				case sparkplug.MetricName:
+					b, n := protowire.ConsumeBytes(raw)
+					require.Positive(t, n)
+					name = string(b)
+				case sparkplug.MetricLongValue:
+					value, _ = protowire.ConsumeVarint(raw)
+				}
+				n = protowire.ConsumeFieldValue(num, typ, raw)
+				require.Positive(t, n)
+				raw = raw[n:]
+			}
+			if name == bdSeqMetric {
+				bdSeq = int64(value)
+			}
+		}
+		n = protowire.ConsumeFieldValue(num, typ, buf)
+		require.Positive(t, n)
+		buf = buf[n:]
+	}
+	return seq, bdSeq
+}
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *SparkplugB
+		expected string
+	}{
+		{
+			name:     "missing group",
+			plugin:   &SparkplugB{EdgeNodeID: "node"},
+			expected: "missing 'group_id'",
+		},
+		{
+			name:     "missing edge node",
+			plugin:   &SparkplugB{GroupID: "group"},
+			expected: "missing 'edge_node_id'",
+		},
+		{
+			name:     "invalid edge node",
+			plugin:   &SparkplugB{GroupID: "group", EdgeNodeID: "node/1"},
+			expected: "invalid identifier",
+		},
+		{
+			name: "unsupported protocol",
+			plugin: &SparkplugB{
+				GroupID:    "group",
+				EdgeNodeID: "node",
+				MqttConfig: mqtt.MqttConfig{Protocol: "5"},
+			},
+			expected: "unsupported protocol",
+		},
+		{
+			name: "retain",
+			plugin: &SparkplugB{
+				GroupID:    "group",
+				EdgeNodeID: "node",
+				MqttConfig: mqtt.MqttConfig{Retain: true},
+			},
+			expected: "retained messages are not allowed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var clients []*fakeClient
+	plugin := newPlugin(&clients)
+	plugin.NameTags = []string{"cpu"}
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	require.Len(t, clients, 1)
+
+	// The death certificate of the session is registered as will message
+	client := clients[0]
+	require.NotNil(t, client.cfg.Will)
+	require.Equal(t, "spBv1.0/plant/NDEATH/telegraf", client.cfg.Will.Topic)
+	seq, bdSeq := decodeSequences(t, client.cfg.Will.Payload)
+	require.Equal(t, int64(-1), seq)
+	require.Equal(t, int64(1), bdSeq)
+
+	input := []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"cpu": "cpu0"},
+			map[string]interface{}{"usage": 42.5, "count": int64(3)},
+			time.Unix(1700000000, 0),
+		),
+		metric.New(
+			"sensor",
+			map[string]string{"device": "line1"},
+			map[string]interface{}{"running": true, "state": "ok", "counter": uint64(7)},
+			time.Unix(1700000000, 0),
+		),
+	}
+	require.NoError(t, plugin.Write(input))
+
+	// Data messages of known metrics only refer to the aliases
+	input = []telegraf.Metric{
+		metric.New(
+			"cpu",
+			map[string]string{"cpu": "cpu0"},
+			map[string]interface{}{"usage": 43.0},
+			time.Unix(1700000010, 0),
+		),
+		metric.New(
+			"sensor",
+			map[string]string{"device": "line1"},
+			map[string]interface{}{"counter": uint64(8)},
+			time.Unix(1700000010, 0),
+		),
+	}
+	require.NoError(t, plugin.Write(input))
+
+	require.Equal(t, []string{
+		"spBv1.0/plant/NBIRTH/telegraf",
+		"spBv1.0/plant/NBIRTH/telegraf",
+		"spBv1.0/plant/DBIRTH/telegraf/line1",
+		"spBv1.0/plant/NDATA/telegraf",
+		"spBv1.0/plant/DDATA/telegraf/line1",
+		"spBv1.0/plant/NDATA/telegraf",
+		"spBv1.0/plant/DDATA/telegraf/line1",
+	}, client.topics())
+
+	// Check the sequence numbers restarting with each node birth
+	expectedSeq := []int64{0, 0, 1, 2, 3, 4, 5}
+	for i, msg := range client.messages {
+		seq, _ := decodeSequences(t, msg.payload)
+		require.Equalf(t, expectedSeq[i], seq, "message %d", i)
+	}
+
+	// Decode the messages using the parser to check the alias handling
+	p := &parser.Parser{Log: testutil.Logger{}}
+	require.NoError(t, p.Init())
+	var actual []telegraf.Metric
+	for _, msg := range client.messages[1:] {
+		metrics, err := p.ParseWithTopic(msg.topic, msg.payload)
+		require.NoError(t, err)
+		// Skip the session control metrics of the node birth
+		for _, m := range metrics {
+			m.RemoveField(bdSeqMetric)
+			m.RemoveField(rebirthMetric)
+			if len(m.FieldList()) > 0 {
+				actual = append(actual, m)
+			}
+		}
+	}
+
+	nodeTags := map[string]string{"group_id": "plant", "edge_node_id": "telegraf"}
+	deviceTags := map[string]string{"group_id": "plant", "edge_node_id": "telegraf", "device_id": "line1"}
+	expected := []telegraf.Metric{
+		metric.New("", nodeTags, map[string]interface{}{"cpu/cpu0/usage": 42.5, "cpu/cpu0/count": int64(3)}, time.Unix(1700000000, 0)),
+		metric.New("", deviceTags, map[string]interface{}{"sensor/running": true, "sensor/state": "ok", "sensor/counter": uint64(7)}, time.Unix(1700000000, 0)),
+		metric.New("", nodeTags, map[string]interface{}{"cpu/cpu0/usage": 42.5, "cpu/cpu0/count": int64(3)}, time.Unix(1700000000, 0)),
+		metric.New("", deviceTags, map[string]interface{}{"sensor/running": true, "sensor/state": "ok", "sensor/counter": uint64(7)}, time.Unix(1700000000, 0)),
+		metric.New("", nodeTags, map[string]interface{}{"cpu/cpu0/usage": 43.0}, time.Unix(1700000010, 0)),
+		metric.New("", deviceTags, map[string]interface{}{"sensor/counter": uint64(8)}, time.Unix(1700000010, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, actual, testutil.SortMetrics())
+
+	// Stopping the plugin publishes the death certificates
+	require.NoError(t, plugin.Close())
+	require.True(t, client.closed)
+	topics := client.topics()
+	require.Equal(t, []string{"spBv1.0/plant/DDEATH/telegraf/line1", "spBv1.0/plant/NDEATH/telegraf"}, topics[len(topics)-2:])
+	_, bdSeq = decodeSequences(t, client.messages[len(client.messages)-1].payload)
+	require.Equal(t, int64(1), bdSeq)
+}
+
+func TestNewDeviceMetric(t *testing.T) {
+	var clients []*fakeClient
+	plugin := newPlugin(&clients)
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	m := metric.New(
+		"sensor",
+		map[string]string{"device": "line1"},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(1700000000, 0),
+	)
+	require.NoError(t, plugin.Write([]telegraf.Metric{m}))
+
+	// A new device metric requires a new birth of the device only while a
+	// mismatching datatype is dropped
+	m = metric.New(
+		"sensor",
+		map[string]string{"device": "line1"},
+		map[string]interface{}{"value": "invalid", "other": false},
+		time.Unix(1700000010, 0),
+	)
+	require.NoError(t, plugin.Write([]telegraf.Metric{m}))
+
+	require.Equal(t, []string{
+		"spBv1.0/plant/NBIRTH/telegraf",
+		"spBv1.0/plant/DBIRTH/telegraf/line1",
+		"spBv1.0/plant/DDATA/telegraf/line1",
+		"spBv1.0/plant/DBIRTH/telegraf/line1",
+		"spBv1.0/plant/DDATA/telegraf/line1",
+	}, clients[0].topics())
+
+	p := &parser.Parser{Log: testutil.Logger{}}
+	require.NoError(t, p.Init())
+	msg := clients[0].messages[4]
+	actual, err := p.ParseWithTopic(msg.topic, msg.payload)
+	require.NoError(t, err)
+	require.Empty(t, actual, "alias unknown without birth")
+
+	msg = clients[0].messages[3]
+	actual, err = p.ParseWithTopic(msg.topic, msg.payload)
+	require.NoError(t, err)
+	require.Len(t, actual, 2)
+	msg = clients[0].messages[4]
+	actual, err = p.ParseWithTopic(msg.topic, msg.payload)
+	require.NoError(t, err)
+	require.Len(t, actual, 1)
+	require.Equal(t, map[string]interface{}{"sensor/other": false}, actual[0].Fields())
+}
+
+func TestInvalidDeviceID(t *testing.T) {
+	var clients []*fakeClient
+	logger := &testutil.CaptureLogger{}
+	plugin := newPlugin(&clients)
+	plugin.Log = logger
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	input := make([]telegraf.Metric, 0, 4)
+	for _, id := range []string{"line/1", "line+", "#", "line1"} {
+		input = append(input, metric.New(
+			"sensor",
+			map[string]string{"device": id},
+			map[string]interface{}{"value": int64(1)},
+			time.Unix(1700000000, 0),
+		))
+	}
+	require.NoError(t, plugin.Write(input))
+
+	// Only the metric with a valid device identifier is published
+	require.Equal(t, []string{
+		"spBv1.0/plant/NBIRTH/telegraf",
+		"spBv1.0/plant/DBIRTH/telegraf/line1",
+		"spBv1.0/plant/DDATA/telegraf/line1",
+	}, clients[0].topics())
+	require.Len(t, logger.Errors(), 3)
+	require.Contains(t, logger.Errors()[0], `invalid device identifier "line/1"`)
+}
+
+func TestRebirthCommand(t *testing.T) {
+	var clients []*fakeClient
+	plugin := newPlugin(&clients)
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	m := metric.New(
+		"sensor",
+		map[string]string{"device": "line1"},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(1700000000, 0),
+	)
+	require.NoError(t, plugin.Write([]telegraf.Metric{m}))
+
+	client := clients[0]
+	require.NotNil(t, client.onCommand)
+
+	// Other commands are ignored
+	other := &payload{metrics: []sparkplugMetric{{name: "Node Control/Reboot", datatype: sparkplug.TypeBoolean, value: true}}}
+	client.onCommand(nil, &command{topic: "spBv1.0/plant/NCMD/telegraf", payload: other.encode()})
+
+	rebirth := &payload{metrics: []sparkplugMetric{{name: rebirthMetric, datatype: sparkplug.TypeBoolean, value: true}}}
+	client.onCommand(nil, &command{topic: "spBv1.0/plant/NCMD/telegraf", payload: rebirth.encode()})
+
+	require.Eventually(t, func() bool {
+		return len(client.topics()) == 5
+	}, 3*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{
+		"spBv1.0/plant/NBIRTH/telegraf",
+		"spBv1.0/plant/DBIRTH/telegraf/line1",
+		"spBv1.0/plant/DDATA/telegraf/line1",
+		"spBv1.0/plant/NBIRTH/telegraf",
+		"spBv1.0/plant/DBIRTH/telegraf/line1",
+	}, client.topics())
+
+	// The rebirth keeps the session
+	_, bdSeq := decodeSequences(t, client.messages[3].payload)
+	require.Equal(t, int64(1), bdSeq)
+}
+
+func TestReconnect(t *testing.T) {
+	var clients []*fakeClient
+	plugin := newPlugin(&clients)
+	require.NoError(t, plugin.Init())
+	require.NoError(t, plugin.Connect())
+	defer plugin.Close()
+
+	m := metric.New(
+		"sensor",
+		map[string]string{"device": "line1"},
+		map[string]interface{}{"value": int64(1)},
+		time.Unix(1700000000, 0),
+	)
+	require.NoError(t, plugin.Write([]telegraf.Metric{m}))
+
+	// Losing the connection starts a new session with the next write
+	clients[0].cfg.OnConnectionLost(nil)
+	require.NoError(t, plugin.Write([]telegraf.Metric{m}))
+	require.Len(t, clients, 2)
+	require.True(t, clients[0].closed)
+
+	client := clients[1]
+	_, bdSeq := decodeSequences(t, client.cfg.Will.Payload)
+	require.Equal(t, int64(2), bdSeq)
+	require.Equal(t, []string{
+		"spBv1.0/plant/NBIRTH/telegraf",
+		"spBv1.0/plant/DBIRTH/telegraf/line1",
+		"spBv1.0/plant/DDATA/telegraf/line1",
+	}, client.topics())
+	_, bdSeq = decodeSequences(t, client.messages[0].payload)
+	require.Equal(t, int64(2), bdSeq)
+}
@@ -0,0 +1,5 @@
+//go:build !custom || parsers || parsers.opcua_pubsub
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/parsers/opcua_pubsub" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || parsers || parsers.sparkplug_b
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/parsers/sparkplug_b" // register plugin
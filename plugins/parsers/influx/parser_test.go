@@ -13,7 +13,9 @@ import (
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal/fuzz"
 	"github.com/influxdata/telegraf/metric"
+	serializers_influx "github.com/influxdata/telegraf/plugins/serializers/influx"
 	"github.com/influxdata/telegraf/testutil"
 )
 
@@ -1038,3 +1040,29 @@ func BenchmarkParsing(b *testing.B) {
 		plugin.Parse([]byte(benchmarkData))
 	}
 }
+
+func BenchmarkParsingCorpora(b *testing.B) {
+	plugin := &Parser{}
+	require.NoError(b, plugin.Init())
+
+	serializer := &serializers_influx.Serializer{UintSupport: true}
+	require.NoError(b, serializer.Init())
+
+	testutil.RunParserBenchmarks(b, plugin, serializer)
+}
+
+func FuzzParserInflux(f *testing.F) {
+	testutil.AddFuzzPayloads(f, fuzz.LineProtocolDictionary,
+		[]byte("cpu,host=localhost value=42.0 1700000000000000000\n"),
+		[]byte("cpu,host=a\\ b,cpu=cpu0 idle=98.5,user=1i,ok=true,msg=\"a \\\"quoted\\\" text\" 1\n"),
+		[]byte("# comment\nmem free=123u,used=-45i\n"),
+	)
+
+	parser := &Parser{}
+	require.NoError(f, parser.Init())
+
+	f.Fuzz(func(_ *testing.T, input []byte) {
+		//nolint:errcheck // fuzz testing can give lots of errors, but we just want to test for crashes
+		parser.Parse(input)
+	})
+}
@@ -0,0 +1,70 @@
+package opcua_pubsub
+
+import (
+	"fmt"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/common/opcua/pubsub"
+	"github.com/influxdata/telegraf/plugins/parsers"
+)
+
+// Parser decodes OPC UA PubSub messages in JSON encoding. Metadata messages
+// are kept in the parser and used when decoding subsequent data messages.
+type Parser struct {
+	metricName  string
+	defaultTags map[string]string
+	decoder     *pubsub.JSONDecoder
+}
+
+func (p *Parser) Init() error {
+	p.decoder = pubsub.NewJSONDecoder()
+	return nil
+}
+
+func (p *Parser) Parse(data []byte) ([]telegraf.Metric, error) {
+	datasets, err := p.decoder.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+
+	metrics := make([]telegraf.Metric, 0, len(datasets))
+	for _, ds := range datasets {
+		m := ds.Metric(p.metricName)
+		for k, v := range p.defaultTags {
+			if !m.HasTag(k) {
+				m.AddTag(k, v)
+			}
+		}
+		metrics = append(metrics, m)
+	}
+
+	return metrics, nil
+}
+
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(metrics) {
+	case 0:
+		return nil, nil
+	case 1:
+		return metrics[0], nil
+	default:
+		return metrics[0], fmt.Errorf("cannot parse line with multiple (%d) metrics", len(metrics))
+	}
+}
+
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.defaultTags = tags
+}
+
+func init() {
+	parsers.Add("opcua_pubsub",
+		func(defaultMetricName string) telegraf.Parser {
+			return &Parser{metricName: defaultMetricName}
+		},
+	)
+}
@@ -0,0 +1,107 @@
+package opcua_pubsub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestParse(t *testing.T) {
+	metadata := `{
+		"MessageId": "1",
+		"MessageType": "ua-metadata",
+		"PublisherId": "MyPublisher",
+		"DataSetWriterId": 1,
+		"MetaData": {
+			"Name": "Boiler",
+			"Fields": [
+				{"Name": "Temperature", "BuiltInType": 10},
+				{"Name": "Counter", "BuiltInType": 7}
+			]
+		}
+	}`
+	data := `{
+		"MessageId": "2",
+		"MessageType": "ua-data",
+		"PublisherId": "MyPublisher",
+		"Messages": [
+			{"DataSetWriterId": 1, "Timestamp": "2024-01-01T12:00:00Z", "Payload": {"Temperature": 21.5, "Counter": 42}},
+			{"DataSetWriterId": 2, "Timestamp": "2024-01-01T12:00:01Z", "Payload": {"Level": 3}}
+		]
+	}`
+
+	parser := &Parser{metricName: "mqtt_consumer"}
+	require.NoError(t, parser.Init())
+	parser.SetDefaultTags(map[string]string{"site": "plant1", "dataset": "default"})
+
+	metrics, err := parser.Parse([]byte(metadata))
+	require.NoError(t, err)
+	require.Empty(t, metrics)
+
+	expected := []telegraf.Metric{
+		metric.New(
+			"mqtt_consumer",
+			map[string]string{
+				"publisher_id": "MyPublisher",
+				"writer_id":    "1",
+				"dataset":      "Boiler",
+				"site":         "plant1",
+			},
+			map[string]interface{}{
+				"Temperature": float32(21.5),
+				"Counter":     uint32(42),
+			},
+			time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+		),
+		metric.New(
+			"mqtt_consumer",
+			map[string]string{
+				"publisher_id": "MyPublisher",
+				"writer_id":    "2",
+				"dataset":      "default",
+				"site":         "plant1",
+			},
+			map[string]interface{}{
+				"Level": int64(3),
+			},
+			time.Date(2024, 1, 1, 12, 0, 1, 0, time.UTC),
+		),
+	}
+	metrics, err = parser.Parse([]byte(data))
+	require.NoError(t, err)
+	testutil.RequireMetricsEqual(t, expected, metrics)
+}
+
+func TestParseLine(t *testing.T) {
+	parser := &Parser{metricName: "opcua"}
+	require.NoError(t, parser.Init())
+
+	m, err := parser.ParseLine(`{"DataSetWriterId": 3, "PublisherId": "pub", "Timestamp": "2024-01-01T12:00:00Z", "Payload": {"value": 1}}`)
+	require.NoError(t, err)
+	expected := metric.New(
+		"opcua",
+		map[string]string{"publisher_id": "pub", "writer_id": "3"},
+		map[string]interface{}{"value": int64(1)},
+		time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC),
+	)
+	testutil.RequireMetricEqual(t, expected, m)
+
+	_, err = parser.ParseLine(`[
+		{"DataSetWriterId": 3, "Payload": {"value": 1}},
+		{"DataSetWriterId": 4, "Payload": {"value": 2}}
+	]`)
+	require.ErrorContains(t, err, "cannot parse line with multiple (2) metrics")
+}
+
+func TestParseInvalid(t *testing.T) {
+	parser := &Parser{metricName: "opcua"}
+	require.NoError(t, parser.Init())
+
+	_, err := parser.Parse([]byte(`{"MessageType": "ua-foo"}`))
+	require.ErrorContains(t, err, `unknown message type "ua-foo"`)
+}
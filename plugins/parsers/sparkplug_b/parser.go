@@ -0,0 +1,239 @@
+package sparkplug_b
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/common/sparkplug"
+	"github.com/influxdata/telegraf/plugins/parsers"
+)
+
+// Parser decodes Sparkplug B payloads. The definitions announced in birth
+// messages are kept per edge node to resolve the aliases and datatypes of
+// subsequent data messages. The edge node is determined from the MQTT topic,
+// so use an input passing the topic to the parser like mqtt_consumer.
+type Parser struct {
+	Log telegraf.Logger `toml:"-"`
+
+	metricName  string
+	defaultTags map[string]string
+	nodes       map[string]*edgeNode
+	sync.Mutex
+}
+
+// edgeNode contains the definitions of the metrics of an edge node and its
+// devices. Aliases are unique across the node and its devices.
+type edgeNode struct {
+	aliases   map[uint64]definition
+	datatypes map[string]uint32
+}
+
+type definition struct {
+	name     string
+	device   string
+	datatype uint32
+}
+
+// topic is the decoded Sparkplug topic of a message in the form
+// spBv1.0/<group>/<message type>/<edge node>[/<device>]
+type topic struct {
+	group       string
+	messageType string
+	node        string
+	device      string
+}
+
+func (p *Parser) Init() error {
+	p.nodes = make(map[string]*edgeNode)
+	return nil
+}
+
+// Parse decodes a payload without topic information. All payloads are
+// assumed to originate from the same edge node, so only use this function
+// for a single node.
+func (p *Parser) Parse(buf []byte) ([]telegraf.Metric, error) {
+	return p.parse(&topic{}, buf)
+}
+
+// ParseWithTopic decodes a payload received on the given Sparkplug topic
+func (p *Parser) ParseWithTopic(t string, buf []byte) ([]telegraf.Metric, error) {
+	parts := strings.Split(t, "/")
+	if len(parts) < 2 || parts[0] != sparkplug.Namespace {
+		return nil, fmt.Errorf("invalid Sparkplug B topic %q", t)
+	}
+
+	// Ignore the state messages of host applications which are not encoded
+	// as protobuf payload
+	if parts[1] == "STATE" {
+		return nil, nil
+	}
+	if len(parts) != 4 && len(parts) != 5 {
+		return nil, fmt.Errorf("invalid Sparkplug B topic %q", t)
+	}
+
+	tp := &topic{group: parts[1], messageType: parts[2], node: parts[3]}
+	if len(parts) == 5 {
+		tp.device = parts[4]
+	}
+	return p.parse(tp, buf)
+}
+
+func (p *Parser) ParseLine(line string) (telegraf.Metric, error) {
+	metrics, err := p.Parse([]byte(line))
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(metrics) {
+	case 0:
+		return nil, nil
+	case 1:
+		return metrics[0], nil
+	default:
+		return metrics[0], fmt.Errorf("cannot parse line with multiple (%d) metrics", len(metrics))
+	}
+}
+
+func (p *Parser) SetDefaultTags(tags map[string]string) {
+	p.defaultTags = tags
+}
+
+func (p *Parser) parse(t *topic, buf []byte) ([]telegraf.Metric, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	key := t.group + "/" + t.node
+	switch t.messageType {
+	case "NDEATH":
+		delete(p.nodes, key)
+		return nil, nil
+	case "DDEATH":
+		if node, found := p.nodes[key]; found {
+			node.forget(t.device)
+		}
+		return nil, nil
+	}
+
+	payload, err := decodePayload(buf)
+	if err != nil {
+		return nil, fmt.Errorf("decoding payload failed: %w", err)
+	}
+
+	// Birth messages announce all metrics so forget previous definitions
+	node, found := p.nodes[key]
+	if !found || t.messageType == "NBIRTH" {
+		node = &edgeNode{
+			aliases:   make(map[uint64]definition),
+			datatypes: make(map[string]uint32),
+		}
+		p.nodes[key] = node
+	} else if t.messageType == "DBIRTH" {
+		node.forget(t.device)
+	}
+
+	tags := make(map[string]string, len(p.defaultTags)+3)
+	for k, v := range p.defaultTags {
+		tags[k] = v
+	}
+	if t.group != "" {
+		tags["group_id"] = t.group
+	}
+	if t.node != "" {
+		tags["edge_node_id"] = t.node
+	}
+	if t.device != "" {
+		tags["device_id"] = t.device
+	}
+
+	fallback := time.Now()
+	if payload.timestamp > 0 {
+		fallback = time.UnixMilli(int64(payload.timestamp))
+	}
+
+	grouper := metric.NewSeriesGrouper()
+	for _, m := range payload.metrics {
+		def, err := node.resolve(t.device, &m)
+		if err != nil {
+			p.Log.Warnf("Ignoring metric of edge node %q: %v", key, err)
+			continue
+		}
+		if m.isNull {
+			continue
+		}
+
+		value, err := m.fieldValue(def.datatype)
+		if err != nil {
+			if errors.Is(err, errUnsupportedType) {
+				p.Log.Debugf("Ignoring metric %q of edge node %q with unsupported datatype %d", def.name, key, def.datatype)
+			} else {
+				p.Log.Warnf("Ignoring metric %q of edge node %q: %v", def.name, key, err)
+			}
+			continue
+		}
+
+		ts := fallback
+		if m.timestamp > 0 {
+			ts = time.UnixMilli(int64(m.timestamp))
+		}
+		grouper.Add(p.metricName, tags, ts, def.name, value)
+	}
+
+	return grouper.Metrics(), nil
+}
+
+// resolve returns the definition of the metric, registering the name and
+// datatype of the metric if given and looking them up otherwise
+func (n *edgeNode) resolve(device string, m *sparkplugMetric) (definition, error) {
+	if !m.hasName {
+		if !m.hasAlias {
+			return definition{}, errors.New("neither name nor alias given")
+		}
+		def, found := n.aliases[m.alias]
+		if !found {
+			return definition{}, fmt.Errorf("unknown alias %d, waiting for a birth message", m.alias)
+		}
+		if m.datatype != sparkplug.TypeUnknown {
+			def.datatype = m.datatype
+		}
+		return def, nil
+	}
+
+	def := definition{name: m.name, device: device, datatype: m.datatype}
+	if def.datatype == sparkplug.TypeUnknown {
+		def.datatype = n.datatypes[device+"/"+m.name]
+	} else {
+		n.datatypes[device+"/"+m.name] = def.datatype
+	}
+	if m.hasAlias {
+		n.aliases[m.alias] = def
+	}
+	return def, nil
+}
+
+// forget removes the definitions of the given device
+func (n *edgeNode) forget(device string) {
+	for alias, def := range n.aliases {
+		if def.device == device {
+			delete(n.aliases, alias)
+		}
+	}
+	prefix := device + "/"
+	for k := range n.datatypes {
+		if strings.HasPrefix(k, prefix) {
+			delete(n.datatypes, k)
+		}
+	}
+}
+
+func init() {
+	parsers.Add("sparkplug_b",
+		func(defaultMetricName string) telegraf.Parser {
+			return &Parser{metricName: defaultMetricName}
+		},
+	)
+}
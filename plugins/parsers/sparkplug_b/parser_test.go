@@ -0,0 +1,324 @@
+package sparkplug_b
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/common/sparkplug"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+// testMetric describes a Sparkplug metric encoded using encodePayload, the
+// protobuf value field is determined by the type of the value
+type testMetric struct {
+	name      string
+	alias     uint64
+	timestamp uint64
+	datatype  uint32
+	isNull    bool
+	value     interface{}
+}
+
+func encodePayload(timestamp uint64, metrics ...testMetric) []byte {
+	var buf []byte
+	if timestamp > 0 {
+		buf = protowire.AppendTag(buf, sparkplug.PayloadTimestamp, protowire.VarintType)
+		buf = protowire.AppendVarint(buf, timestamp)
+	}
+	for _, m := range metrics {
+		var raw []byte
+		if m.name != "" {
+			raw = protowire.AppendTag(raw, sparkplug.MetricName, protowire.BytesType)
+			raw = protowire.AppendString(raw, m.name)
+		}
+		if m.alias > 0 {
+			raw = protowire.AppendTag(raw, sparkplug.MetricAlias, protowire.VarintType)
+			raw = protowire.AppendVarint(raw, m.alias)
+		}
+		if m.timestamp > 0 {
+			raw = protowire.AppendTag(raw, sparkplug.MetricTimestamp, protowire.VarintType)
+			raw = protowire.AppendVarint(raw, m.timestamp)
+		}
+		if m.datatype > 0 {
+			raw = protowire.AppendTag(raw, sparkplug.MetricDatatype, protowire.VarintType)
+			raw = protowire.AppendVarint(raw, uint64(m.datatype))
+		}
+		if m.isNull {
+			raw = protowire.AppendTag(raw, sparkplug.MetricIsNull, protowire.VarintType)
+			raw = protowire.AppendVarint(raw, 1)
+		}
+		switch v := m.value.(type) {
+		case uint32:
+			raw = protowire.AppendTag(raw, sparkplug.MetricIntValue, protowire.VarintType)
+			raw = protowire.AppendVarint(raw, uint64(v))
+		case uint64:
+			raw = protowire.AppendTag(raw, sparkplug.MetricLongValue, protowire.VarintType)
+			raw = protowire.AppendVarint(raw, v)
+		case float32:
+			raw = protowire.AppendTag(raw, sparkplug.MetricFloatValue, protowire.Fixed32Type)
+			raw = protowire.AppendFixed32(raw, math.Float32bits(v))
+		case float64:
+			raw = protowire.AppendTag(raw, sparkplug.MetricDoubleValue, protowire.Fixed64Type)
+			raw = protowire.AppendFixed64(raw, math.Float64bits(v))
+		case bool:
+			raw = protowire.AppendTag(raw, sparkplug.MetricBooleanValue, protowire.VarintType)
+			raw = protowire.AppendVarint(raw, protowire.EncodeBool(v))
+		case string:
+			raw = protowire.AppendTag(raw, sparkplug.MetricStringValue, protowire.BytesType)
+			raw = protowire.AppendString(raw, v)
+		case []byte:
+			raw = protowire.AppendTag(raw, sparkplug.MetricBytesValue, protowire.BytesType)
+			raw = protowire.AppendBytes(raw, v)
+		}
+		buf = protowire.AppendTag(buf, sparkplug.PayloadMetrics, protowire.BytesType)
+		buf = protowire.AppendBytes(buf, raw)
+	}
+	return buf
+}
+
+func TestBirthAndData(t *testing.T) {
+	plugin := &Parser{metricName: "sparkplug", Log: &testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+
+	birth := encodePayload(1700000000000,
+		testMetric{name: "bdSeq", datatype: sparkplug.TypeUInt64, value: uint64(0)},
+		testMetric{name: "Inputs/Temperature", alias: 1, datatype: sparkplug.TypeFloat, value: float32(21.5)},
+		testMetric{name: "Inputs/Offset", alias: 2, datatype: sparkplug.TypeInt8, value: uint32(0xFFFFFFFE)},
+		testMetric{name: "Outputs/Running", alias: 3, datatype: sparkplug.TypeBoolean, value: true},
+		testMetric{name: "Properties/Serial", alias: 4, datatype: sparkplug.TypeString, value: "SN-42"},
+		testMetric{name: "Counters/Total", alias: 5, datatype: sparkplug.TypeInt64, value: uint64(math.MaxUint64)},
+	)
+	actual, err := plugin.ParseWithTopic("spBv1.0/plant/NBIRTH/line1", birth)
+	require.NoError(t, err)
+
+	tags := map[string]string{"group_id": "plant", "edge_node_id": "line1"}
+	expected := []telegraf.Metric{
+		metric.New("sparkplug", tags,
+			map[string]interface{}{
+				"bdSeq":              uint64(0),
+				"Inputs/Temperature": 21.5,
+				"Inputs/Offset":      int64(-2),
+				"Outputs/Running":    true,
+				"Properties/Serial":  "SN-42",
+				"Counters/Total":     int64(-1),
+			},
+			time.UnixMilli(1700000000000),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Data messages only contain the aliases and use the datatypes of the
+	// birth message, metrics with different timestamps are not merged
+	data := encodePayload(1700000001000,
+		testMetric{alias: 1, value: float32(22.25)},
+		testMetric{alias: 2, timestamp: 1700000000500, value: uint32(3)},
+		testMetric{alias: 5, timestamp: 1700000000500, value: uint64(100)},
+	)
+	actual, err = plugin.ParseWithTopic("spBv1.0/plant/NDATA/line1", data)
+	require.NoError(t, err)
+
+	expected = []telegraf.Metric{
+		metric.New("sparkplug", tags,
+			map[string]interface{}{"Inputs/Temperature": 22.25},
+			time.UnixMilli(1700000001000),
+		),
+		metric.New("sparkplug", tags,
+			map[string]interface{}{"Inputs/Offset": int64(3), "Counters/Total": int64(100)},
+			time.UnixMilli(1700000000500),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, actual, testutil.SortMetrics())
+}
+
+func TestDevices(t *testing.T) {
+	logger := &testutil.CaptureLogger{}
+	plugin := &Parser{metricName: "sparkplug", Log: logger}
+	require.NoError(t, plugin.Init())
+
+	nbirth := encodePayload(1700000000000,
+		testMetric{name: "Node/Uptime", alias: 1, datatype: sparkplug.TypeUInt32, value: uint32(10)},
+	)
+	_, err := plugin.ParseWithTopic("spBv1.0/plant/NBIRTH/line1", nbirth)
+	require.NoError(t, err)
+
+	dbirth := encodePayload(1700000000000,
+		testMetric{name: "Pressure", alias: 10, datatype: sparkplug.TypeDouble, value: 1.5},
+	)
+	_, err = plugin.ParseWithTopic("spBv1.0/plant/DBIRTH/line1/pump1", dbirth)
+	require.NoError(t, err)
+
+	// Aliases of the node and the device are resolved using the same map
+	data := encodePayload(1700000001000, testMetric{alias: 10, value: 2.5})
+	actual, err := plugin.ParseWithTopic("spBv1.0/plant/DDATA/line1/pump1", data)
+	require.NoError(t, err)
+	expected := []telegraf.Metric{
+		metric.New("sparkplug",
+			map[string]string{"group_id": "plant", "edge_node_id": "line1", "device_id": "pump1"},
+			map[string]interface{}{"Pressure": 2.5},
+			time.UnixMilli(1700000001000),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// After the death of the device its aliases are unknown while the ones
+	// of the node are kept
+	_, err = plugin.ParseWithTopic("spBv1.0/plant/DDEATH/line1/pump1", encodePayload(1700000002000))
+	require.NoError(t, err)
+	actual, err = plugin.ParseWithTopic("spBv1.0/plant/DDATA/line1/pump1", data)
+	require.NoError(t, err)
+	require.Empty(t, actual)
+	logger.RequireWarnContains(t, "unknown alias 10")
+
+	actual, err = plugin.ParseWithTopic("spBv1.0/plant/NDATA/line1",
+		encodePayload(1700000003000, testMetric{alias: 1, value: uint32(13)}),
+	)
+	require.NoError(t, err)
+	require.Len(t, actual, 1)
+	require.Equal(t, map[string]interface{}{"Node/Uptime": uint64(13)}, actual[0].Fields())
+}
+
+func TestRebirth(t *testing.T) {
+	logger := &testutil.CaptureLogger{}
+	plugin := &Parser{metricName: "sparkplug", Log: logger}
+	require.NoError(t, plugin.Init())
+
+	for _, node := range []string{"line1", "line2"} {
+		birth := encodePayload(1700000000000,
+			testMetric{name: node + "/Speed", alias: 1, datatype: sparkplug.TypeInt32, value: uint32(1)},
+		)
+		_, err := plugin.ParseWithTopic("spBv1.0/plant/NBIRTH/"+node, birth)
+		require.NoError(t, err)
+	}
+
+	// A new birth replaces all definitions of the node
+	birth := encodePayload(1700000000000,
+		testMetric{name: "line1/Level", alias: 2, datatype: sparkplug.TypeUInt16, value: uint32(5)},
+	)
+	_, err := plugin.ParseWithTopic("spBv1.0/plant/NBIRTH/line1", birth)
+	require.NoError(t, err)
+
+	data := encodePayload(1700000001000,
+		testMetric{alias: 1, value: uint32(7)},
+		testMetric{alias: 2, value: uint32(8)},
+	)
+	actual, err := plugin.ParseWithTopic("spBv1.0/plant/NDATA/line1", data)
+	require.NoError(t, err)
+	require.Len(t, actual, 1)
+	require.Equal(t, map[string]interface{}{"line1/Level": uint64(8)}, actual[0].Fields())
+	logger.RequireWarnContains(t, "unknown alias 1")
+
+	// Aliases are resolved per edge node
+	actual, err = plugin.ParseWithTopic("spBv1.0/plant/NDATA/line2", data)
+	require.NoError(t, err)
+	require.Len(t, actual, 1)
+	require.Equal(t, map[string]interface{}{"line2/Speed": int64(7)}, actual[0].Fields())
+
+	// After the death of the node no aliases are known
+	_, err = plugin.ParseWithTopic("spBv1.0/plant/NDEATH/line2", encodePayload(0))
+	require.NoError(t, err)
+	actual, err = plugin.ParseWithTopic("spBv1.0/plant/NDATA/line2", data)
+	require.NoError(t, err)
+	require.Empty(t, actual)
+}
+
+func TestSkippedValues(t *testing.T) {
+	logger := &testutil.CaptureLogger{}
+	plugin := &Parser{metricName: "sparkplug", Log: logger}
+	require.NoError(t, plugin.Init())
+
+	payload := encodePayload(1700000000000,
+		testMetric{name: "Null", datatype: sparkplug.TypeDouble, isNull: true},
+		testMetric{name: "Blob", datatype: 17, value: []byte{0x01, 0x02}},
+		testMetric{name: "Mismatch", datatype: sparkplug.TypeBoolean, value: "true"},
+		testMetric{name: "Untyped", value: 1.0},
+		testMetric{value: 1.0},
+	)
+	actual, err := plugin.ParseWithTopic("spBv1.0/plant/NBIRTH/line1", payload)
+	require.NoError(t, err)
+	require.Len(t, actual, 1)
+	require.Equal(t, map[string]interface{}{"Untyped": 1.0}, actual[0].Fields())
+	logger.RequireWarnContains(t, `"Mismatch"`)
+	logger.RequireWarnContains(t, "neither name nor alias given")
+	logger.RequireDebugContains(t, `"Blob"`)
+}
+
+func TestParseWithoutTopic(t *testing.T) {
+	plugin := &Parser{metricName: "sparkplug", Log: &testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+	plugin.SetDefaultTags(map[string]string{"source": "test"})
+
+	birth := encodePayload(1700000000000,
+		testMetric{name: "Level", alias: 1, datatype: sparkplug.TypeInt16, value: uint32(0xFFFF)},
+	)
+	actual, err := plugin.Parse(birth)
+	require.NoError(t, err)
+	testutil.RequireMetricsEqual(t,
+		[]telegraf.Metric{
+			metric.New("sparkplug",
+				map[string]string{"source": "test"},
+				map[string]interface{}{"Level": int64(-1)},
+				time.UnixMilli(1700000000000),
+			),
+		},
+		actual,
+	)
+
+	m, err := plugin.ParseLine(string(encodePayload(1700000001000, testMetric{alias: 1, value: uint32(3)})))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"Level": int64(3)}, m.Fields())
+}
+
+func TestTopics(t *testing.T) {
+	plugin := &Parser{metricName: "sparkplug", Log: &testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+
+	// State messages of host applications are JSON and ignored
+	actual, err := plugin.ParseWithTopic("spBv1.0/STATE/scada", []byte(`{"online": true}`))
+	require.NoError(t, err)
+	require.Empty(t, actual)
+
+	for _, topic := range []string{"telegraf/test", "spBv1.0", "spBv1.0/plant/NDATA", "spBv1.0/plant/DDATA/line1/pump1/extra"} {
+		_, err := plugin.ParseWithTopic(topic, nil)
+		require.ErrorContains(t, err, "invalid Sparkplug B topic")
+	}
+}
+
+func TestInvalidPayload(t *testing.T) {
+	plugin := &Parser{metricName: "sparkplug", Log: &testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+
+	_, err := plugin.ParseWithTopic("spBv1.0/plant/NDATA/line1", []byte{0x12, 0x05, 0x0a})
+	require.ErrorContains(t, err, "decoding payload failed")
+
+	// Wrong wire type for the name field
+	payload := protowire.AppendTag(nil, sparkplug.PayloadMetrics, protowire.BytesType)
+	payload = protowire.AppendBytes(payload, protowire.AppendVarint(protowire.AppendTag(nil, sparkplug.MetricName, protowire.VarintType), 1))
+	_, err = plugin.ParseWithTopic("spBv1.0/plant/NDATA/line1", payload)
+	require.ErrorContains(t, err, "invalid wire type")
+}
+
+func BenchmarkParsing(b *testing.B) {
+	plugin := &Parser{metricName: "sparkplug", Log: &testutil.Logger{}}
+	require.NoError(b, plugin.Init())
+
+	birth := make([]testMetric, 0, 20)
+	data := make([]testMetric, 0, 20)
+	for i := range uint64(20) {
+		birth = append(birth, testMetric{name: "Inputs/Value" + string(rune('A'+i)), alias: i + 1, datatype: sparkplug.TypeDouble, value: 1.0})
+		data = append(data, testMetric{alias: i + 1, value: float64(i)})
+	}
+	_, err := plugin.ParseWithTopic("spBv1.0/plant/NBIRTH/line1", encodePayload(1700000000000, birth...))
+	require.NoError(b, err)
+	payload := encodePayload(1700000001000, data...)
+
+	for range b.N {
+		//nolint:errcheck // Benchmarking so skip the error check to avoid the unnecessary operations
+		plugin.ParseWithTopic("spBv1.0/plant/NDATA/line1", payload)
+	}
+}
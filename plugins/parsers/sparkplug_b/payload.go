@@ -0,0 +1,202 @@
+package sparkplug_b
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/influxdata/telegraf/plugins/common/sparkplug"
+)
+
+type payload struct {
+	timestamp uint64
+	seq       uint64
+	metrics   []sparkplugMetric
+}
+
+type sparkplugMetric struct {
+	name      string
+	hasName   bool
+	alias     uint64
+	hasAlias  bool
+	timestamp uint64
+	datatype  uint32
+	isNull    bool
+
+	// Raw value as decoded, nil if the value is missing or of an unsupported
+	// complex type like a dataset or template
+	value interface{}
+}
+
+func decodePayload(buf []byte) (*payload, error) {
+	var p payload
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		buf = buf[n:]
+
+		switch {
+		case num == sparkplug.PayloadTimestamp && typ == protowire.VarintType:
+			p.timestamp, n = protowire.ConsumeVarint(buf)
+		case num == sparkplug.PayloadSeq && typ == protowire.VarintType:
+			p.seq, n = protowire.ConsumeVarint(buf)
+		case num == sparkplug.PayloadMetrics && typ == protowire.BytesType:
+			var raw []byte
+			raw, n = protowire.ConsumeBytes(buf)
+			if n < 0 {
+				break
+			}
+			m, err := decodeMetric(raw)
+			if err != nil {
+				return nil, fmt.Errorf("decoding metric %d failed: %w", len(p.metrics)+1, err)
+			}
+			p.metrics = append(p.metrics, *m)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, buf)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		buf = buf[n:]
+	}
+	return &p, nil
+}
+
+func decodeMetric(buf []byte) (*sparkplugMetric, error) {
+	var m sparkplugMetric
+	for len(buf) > 0 {
+		num, typ, n := protowire.ConsumeTag(buf)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		buf = buf[n:]
+
+		// Check the wire type of the known fields to avoid misinterpreting
+		// corrupted data
+		var expected protowire.Type
+		switch num {
+		case sparkplug.MetricName, sparkplug.MetricStringValue, sparkplug.MetricBytesValue:
+			expected = protowire.BytesType
+		case sparkplug.MetricFloatValue:
+			expected = protowire.Fixed32Type
+		case sparkplug.MetricDoubleValue:
+			expected = protowire.Fixed64Type
+		case sparkplug.MetricAlias, sparkplug.MetricTimestamp, sparkplug.MetricDatatype, sparkplug.MetricIsNull,
+			sparkplug.MetricIntValue, sparkplug.MetricLongValue, sparkplug.MetricBooleanValue:
+			expected = protowire.VarintType
+		default:
+			expected = typ
+		}
+		if typ != expected {
+			return nil, fmt.Errorf("invalid wire type %d for field %d", typ, num)
+		}
+
+		var v uint64
+		var b []byte
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(buf)
+		case protowire.Fixed32Type:
+			var v32 uint32
+			v32, n = protowire.ConsumeFixed32(buf)
+			v = uint64(v32)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(buf)
+		case protowire.BytesType:
+			b, n = protowire.ConsumeBytes(buf)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, buf)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		buf = buf[n:]
+
+		switch num {
+		case sparkplug.MetricName:
+			m.name, m.hasName = string(b), true
+		case sparkplug.MetricAlias:
+			m.alias, m.hasAlias = v, true
+		case sparkplug.MetricTimestamp:
+			m.timestamp = v
+		case sparkplug.MetricDatatype:
+			m.datatype = uint32(v)
+		case sparkplug.MetricIsNull:
+			m.isNull = v != 0
+		case sparkplug.MetricIntValue:
+			m.value = uint32(v)
+		case sparkplug.MetricLongValue:
+			m.value = v
+		case sparkplug.MetricFloatValue:
+			m.value = math.Float32frombits(uint32(v))
+		case sparkplug.MetricDoubleValue:
+			m.value = math.Float64frombits(v)
+		case sparkplug.MetricBooleanValue:
+			m.value = v != 0
+		case sparkplug.MetricStringValue:
+			m.value = string(b)
+		case sparkplug.MetricBytesValue:
+			m.value = b
+		}
+	}
+	return &m, nil
+}
+
+var errUnsupportedType = errors.New("unsupported datatype")
+
+// fieldValue converts the raw value according to the datatype to a field
+// value. The type of the raw value is used if the datatype is unknown.
+func (m *sparkplugMetric) fieldValue(datatype uint32) (interface{}, error) {
+	switch v := m.value.(type) {
+	case uint32:
+		switch datatype {
+		case sparkplug.TypeInt8:
+			return int64(int8(v)), nil
+		case sparkplug.TypeInt16:
+			return int64(int16(v)), nil
+		case sparkplug.TypeInt32:
+			return int64(int32(v)), nil
+		case sparkplug.TypeInt64:
+			return int64(int32(v)), nil
+		case sparkplug.TypeUnknown, sparkplug.TypeUInt8, sparkplug.TypeUInt16, sparkplug.TypeUInt32, sparkplug.TypeUInt64:
+			return uint64(v), nil
+		}
+	case uint64:
+		switch datatype {
+		case sparkplug.TypeInt8:
+			return int64(int8(v)), nil
+		case sparkplug.TypeInt16:
+			return int64(int16(v)), nil
+		case sparkplug.TypeInt32:
+			return int64(int32(v)), nil
+		case sparkplug.TypeInt64, sparkplug.TypeDateTime:
+			return int64(v), nil
+		case sparkplug.TypeUnknown, sparkplug.TypeUInt8, sparkplug.TypeUInt16, sparkplug.TypeUInt32, sparkplug.TypeUInt64:
+			return v, nil
+		}
+	case float32:
+		if datatype == sparkplug.TypeUnknown || datatype == sparkplug.TypeFloat || datatype == sparkplug.TypeDouble {
+			return float64(v), nil
+		}
+	case float64:
+		if datatype == sparkplug.TypeUnknown || datatype == sparkplug.TypeFloat || datatype == sparkplug.TypeDouble {
+			return v, nil
+		}
+	case bool:
+		if datatype == sparkplug.TypeUnknown || datatype == sparkplug.TypeBoolean {
+			return v, nil
+		}
+	case string:
+		switch datatype {
+		case sparkplug.TypeUnknown, sparkplug.TypeString, sparkplug.TypeText, sparkplug.TypeUUID:
+			return v, nil
+		}
+	case []byte, nil:
+		return nil, errUnsupportedType
+	}
+	return nil, fmt.Errorf("value of type %T does not match datatype %d", m.value, datatype)
+}
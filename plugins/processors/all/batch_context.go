@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.batch_context
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/batch_context" // register plugin
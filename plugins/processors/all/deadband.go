@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.deadband
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/deadband" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.debounce
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/debounce" // register plugin
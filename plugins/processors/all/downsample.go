@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.downsample
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/downsample" // register plugin
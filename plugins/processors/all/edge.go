@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.edge
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/edge" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.expr
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/expr" // register plugin
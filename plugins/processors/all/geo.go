@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.geo
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/geo" // register plugin
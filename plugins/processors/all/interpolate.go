@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.interpolate
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/interpolate" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.limit_check
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/limit_check" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.lookup_sql
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/lookup_sql" // register plugin
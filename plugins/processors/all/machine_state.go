@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.machine_state
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/machine_state" // register plugin
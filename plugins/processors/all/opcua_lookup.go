@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.opcua_lookup
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/opcua_lookup" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.schema_enforce
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/schema_enforce" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.sparkplug_alias
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/sparkplug_alias" // register plugin
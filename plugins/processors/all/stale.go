@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.stale
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/stale" // register plugin
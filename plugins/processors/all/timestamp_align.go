@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.timestamp_align
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/timestamp_align" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.totalizer
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/totalizer" // register plugin
@@ -0,0 +1,5 @@
+//go:build !custom || processors || processors.transpose
+
+package all
+
+import _ "github.com/influxdata/telegraf/plugins/processors/transpose" // register plugin
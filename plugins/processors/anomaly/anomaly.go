@@ -0,0 +1,210 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package anomaly
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Anomaly struct {
+	Fields      []string        `toml:"fields"`
+	Method      string          `toml:"method"`
+	Threshold   float64         `toml:"threshold"`
+	Warmup      int             `toml:"warmup"`
+	Alpha       float64         `toml:"alpha"`
+	Period      int             `toml:"period"`
+	Window      int             `toml:"window"`
+	ScoreSuffix string          `toml:"score_suffix"`
+	FlagSuffix  string          `toml:"flag_suffix"`
+	Expiry      config.Duration `toml:"expiry"`
+	Series      []*detector     `toml:"series"`
+	Log         telegraf.Logger `toml:"-"`
+
+	detectors  []*detector
+	states     map[string]*state
+	seen       map[string]time.Time
+	lastExpiry time.Time
+}
+
+func (*Anomaly) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *Anomaly) Init() error {
+	if p.ScoreSuffix == "" {
+		p.ScoreSuffix = "_anomaly_score"
+	}
+	if p.FlagSuffix == "" {
+		p.FlagSuffix = "_anomaly"
+	}
+	if p.ScoreSuffix == p.FlagSuffix {
+		return errors.New("'score_suffix' and 'flag_suffix' must differ")
+	}
+	if p.Expiry < 0 {
+		return errors.New("'expiry' must not be negative")
+	}
+
+	// The series specific detectors take precedence over the default one
+	// using the plugin settings for all unset parameters
+	defaults := &detector{
+		Fields:    p.Fields,
+		Method:    p.Method,
+		Threshold: p.Threshold,
+		Warmup:    p.Warmup,
+		Alpha:     p.Alpha,
+		Period:    p.Period,
+		Window:    p.Window,
+	}
+	if len(defaults.Fields) == 0 {
+		defaults.Fields = []string{"*"}
+	}
+	if defaults.Method == "" {
+		defaults.Method = "ewma"
+	}
+
+	p.detectors = make([]*detector, 0, len(p.Series)+1)
+	for i, d := range p.Series {
+		d.inherit(defaults)
+		if err := d.init(); err != nil {
+			return fmt.Errorf("initializing series %d failed: %w", i+1, err)
+		}
+		p.detectors = append(p.detectors, d)
+	}
+	if err := defaults.init(); err != nil {
+		return err
+	}
+	p.detectors = append(p.detectors, defaults)
+
+	p.states = make(map[string]*state)
+	p.seen = make(map[string]time.Time)
+
+	return nil
+}
+
+func (p *Anomaly) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	now := time.Now()
+	p.expire(now)
+
+	for _, m := range in {
+		id := strconv.FormatUint(m.HashID(), 16)
+		for _, field := range m.FieldList() {
+			value, ok := toFloat(field.Value)
+			if !ok {
+				continue
+			}
+			d := p.detector(m, field.Key)
+			if d == nil {
+				continue
+			}
+
+			// Start over if the detection method of the series changed
+			key := id + "/" + field.Key
+			s, found := p.states[key]
+			if !found || s.Method != d.Method {
+				s = &state{Method: d.Method}
+				p.states[key] = s
+			}
+			p.seen[key] = now
+
+			score, valid, ready := d.process(s, value)
+			if !ready {
+				continue
+			}
+			if valid {
+				m.AddField(field.Key+p.ScoreSuffix, score)
+				m.AddField(field.Key+p.FlagSuffix, score > d.Threshold)
+			} else {
+				m.AddField(field.Key+p.FlagSuffix, score != 0)
+			}
+		}
+	}
+	return in
+}
+
+func (p *Anomaly) GetState() interface{} {
+	return p.states
+}
+
+func (p *Anomaly) SetState(s interface{}) error {
+	states, ok := s.(map[string]*state)
+	if !ok {
+		return fmt.Errorf("state has wrong type %T", s)
+	}
+	if states == nil {
+		states = make(map[string]*state)
+	}
+	p.states = states
+
+	// Restored states expire as if they just received a value
+	now := time.Now()
+	p.seen = make(map[string]time.Time, len(states))
+	for key := range states {
+		p.seen[key] = now
+	}
+	return nil
+}
+
+// expire removes the learned states of fields without values within the
+// expiry
+func (p *Anomaly) expire(now time.Time) {
+	// No need to check the states too often
+	if p.Expiry <= 0 || now.Sub(p.lastExpiry) < time.Duration(p.Expiry) {
+		return
+	}
+	p.lastExpiry = now
+
+	for key, seen := range p.seen {
+		if now.Sub(seen) >= time.Duration(p.Expiry) {
+			delete(p.states, key)
+			delete(p.seen, key)
+		}
+	}
+}
+
+// detector returns the first detector applying to the given metric field
+func (p *Anomaly) detector(m telegraf.Metric, field string) *detector {
+	for _, d := range p.detectors {
+		if d.matches(m, field) {
+			return d
+		}
+	}
+	return nil
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return 0, false
+		}
+		return v, true
+	}
+	return 0, false
+}
+
+func init() {
+	processors.Add("anomaly", func() telegraf.Processor {
+		return &Anomaly{
+			Fields: []string{"*"},
+			Method: "ewma",
+			Warmup: 10,
+			Alpha:  0.1,
+			Window: 30,
+		}
+	})
+}
@@ -0,0 +1,408 @@
+package anomaly
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestSampleConfig(t *testing.T) {
+	cfg := config.NewConfig()
+	require.NoError(t, cfg.LoadConfigData(testutil.DefaultSampleConfig((&Anomaly{}).SampleConfig()), config.EmptySourcePath))
+	require.Len(t, cfg.Processors, 1)
+
+	proc := cfg.Processors[0].Processor.(processors.HasUnwrap)
+	plugin := proc.Unwrap().(*Anomaly)
+	require.Equal(t, "ewma", plugin.Method)
+	require.Len(t, plugin.Series, 1)
+	require.Equal(t, []string{"boiler"}, plugin.Series[0].Name)
+	require.Equal(t, "mad", plugin.Series[0].Method)
+}
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Anomaly
+		expected string
+	}{
+		{
+			name:     "invalid method",
+			plugin:   &Anomaly{Method: "arima"},
+			expected: "invalid 'method'",
+		},
+		{
+			name:     "same suffixes",
+			plugin:   &Anomaly{ScoreSuffix: "_anomaly"},
+			expected: "'score_suffix' and 'flag_suffix' must differ",
+		},
+		{
+			name:     "negative expiry",
+			plugin:   &Anomaly{Expiry: config.Duration(-time.Second)},
+			expected: "'expiry' must not be negative",
+		},
+		{
+			name:     "negative threshold",
+			plugin:   &Anomaly{Threshold: -1, Alpha: 0.1},
+			expected: "'threshold' must not be negative",
+		},
+		{
+			name:     "negative warmup",
+			plugin:   &Anomaly{Warmup: -1, Alpha: 0.1},
+			expected: "'warmup' must not be negative",
+		},
+		{
+			name:     "invalid alpha",
+			plugin:   &Anomaly{Alpha: 1.5},
+			expected: "'alpha' must be within (0, 1]",
+		},
+		{
+			name:     "seasonal without period",
+			plugin:   &Anomaly{Method: "seasonal", Alpha: 0.1},
+			expected: "'period' must be positive for the seasonal method",
+		},
+		{
+			name:     "window too small",
+			plugin:   &Anomaly{Method: "mad", Window: 2},
+			expected: "'window' must be at least 3 for the mad method",
+		},
+		{
+			name:     "warmup exceeding window",
+			plugin:   &Anomaly{Method: "mad", Window: 5, Warmup: 10},
+			expected: "'warmup' must not exceed 'window'",
+		},
+		{
+			name: "invalid series",
+			plugin: &Anomaly{
+				Alpha:  0.1,
+				Series: []*detector{{Name: []string{"boiler"}, Method: "seasonal"}},
+			},
+			expected: "initializing series 1 failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	value := func(tags map[string]string, fields map[string]interface{}) telegraf.Metric {
+		return metric.New("m", tags, fields, now)
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *Anomaly
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name:   "ewma",
+			plugin: &Anomaly{Method: "ewma", Alpha: 0.5, Warmup: 2},
+			input: []telegraf.Metric{
+				value(map[string]string{}, map[string]interface{}{"value": int64(10), "state": "ok"}),
+				value(map[string]string{}, map[string]interface{}{"value": int64(12), "state": "ok"}),
+				value(map[string]string{}, map[string]interface{}{"value": int64(10), "state": "ok"}),
+				value(map[string]string{}, map[string]interface{}{"value": int64(30), "state": "ok"}),
+			},
+			expected: []telegraf.Metric{
+				value(map[string]string{}, map[string]interface{}{"value": int64(10), "state": "ok"}),
+				value(map[string]string{}, map[string]interface{}{"value": int64(12), "state": "ok"}),
+				value(map[string]string{}, map[string]interface{}{
+					"value":               int64(10),
+					"state":               "ok",
+					"value_anomaly_score": 1.0,
+					"value_anomaly":       false,
+				}),
+				value(map[string]string{}, map[string]interface{}{
+					"value":               int64(30),
+					"state":               "ok",
+					"value_anomaly_score": 19.5 / math.Sqrt(0.75),
+					"value_anomaly":       true,
+				}),
+			},
+		},
+		{
+			name:   "constant values",
+			plugin: &Anomaly{Method: "ewma", Alpha: 0.5, Warmup: 1, FlagSuffix: "_outlier"},
+			input: []telegraf.Metric{
+				value(map[string]string{}, map[string]interface{}{"value": 5.0}),
+				value(map[string]string{}, map[string]interface{}{"value": 5.0}),
+				value(map[string]string{}, map[string]interface{}{"value": 6.0}),
+			},
+			expected: []telegraf.Metric{
+				value(map[string]string{}, map[string]interface{}{"value": 5.0}),
+				value(map[string]string{}, map[string]interface{}{"value": 5.0, "value_outlier": false}),
+				value(map[string]string{}, map[string]interface{}{"value": 6.0, "value_outlier": true}),
+			},
+		},
+		{
+			name:   "seasonal",
+			plugin: &Anomaly{Method: "seasonal", Alpha: 0.5, Warmup: 2, Period: 2},
+			input: []telegraf.Metric{
+				value(map[string]string{}, map[string]interface{}{"value": 1.0}),
+				value(map[string]string{}, map[string]interface{}{"value": 5.0}),
+				value(map[string]string{}, map[string]interface{}{"value": 2.0}),
+				value(map[string]string{}, map[string]interface{}{"value": 7.0}),
+				value(map[string]string{}, map[string]interface{}{"value": 3.0}),
+				value(map[string]string{}, map[string]interface{}{"value": 8.0}),
+				value(map[string]string{}, map[string]interface{}{"value": 10.0}),
+				value(map[string]string{}, map[string]interface{}{"value": 8.0}),
+			},
+			expected: []telegraf.Metric{
+				value(map[string]string{}, map[string]interface{}{"value": 1.0}),
+				value(map[string]string{}, map[string]interface{}{"value": 5.0}),
+				value(map[string]string{}, map[string]interface{}{"value": 2.0}),
+				value(map[string]string{}, map[string]interface{}{"value": 7.0}),
+				value(map[string]string{}, map[string]interface{}{
+					"value":               3.0,
+					"value_anomaly_score": 1.0,
+					"value_anomaly":       false,
+				}),
+				value(map[string]string{}, map[string]interface{}{
+					"value":               8.0,
+					"value_anomaly_score": 0.25 / math.Sqrt(0.1875),
+					"value_anomaly":       false,
+				}),
+				value(map[string]string{}, map[string]interface{}{
+					"value":               10.0,
+					"value_anomaly_score": 5.875 / math.Sqrt(0.109375),
+					"value_anomaly":       true,
+				}),
+				value(map[string]string{}, map[string]interface{}{
+					"value":               8.0,
+					"value_anomaly_score": 4.0625 / math.Sqrt(8.68359375),
+					"value_anomaly":       false,
+				}),
+			},
+		},
+		{
+			name:   "mad",
+			plugin: &Anomaly{Method: "mad", Warmup: 3, Window: 5},
+			input: []telegraf.Metric{
+				value(map[string]string{}, map[string]interface{}{"value": uint64(10)}),
+				value(map[string]string{}, map[string]interface{}{"value": uint64(11)}),
+				value(map[string]string{}, map[string]interface{}{"value": uint64(9)}),
+				value(map[string]string{}, map[string]interface{}{"value": uint64(10)}),
+				value(map[string]string{}, map[string]interface{}{"value": uint64(30)}),
+				value(map[string]string{}, map[string]interface{}{"value": uint64(10)}),
+			},
+			expected: []telegraf.Metric{
+				value(map[string]string{}, map[string]interface{}{"value": uint64(10)}),
+				value(map[string]string{}, map[string]interface{}{"value": uint64(11)}),
+				value(map[string]string{}, map[string]interface{}{"value": uint64(9)}),
+				value(map[string]string{}, map[string]interface{}{
+					"value":               uint64(10),
+					"value_anomaly_score": 0.0,
+					"value_anomaly":       false,
+				}),
+				value(map[string]string{}, map[string]interface{}{
+					"value":               uint64(30),
+					"value_anomaly_score": 20 * madScale / 0.5,
+					"value_anomaly":       true,
+				}),
+				value(map[string]string{}, map[string]interface{}{
+					"value":               uint64(10),
+					"value_anomaly_score": 0.0,
+					"value_anomaly":       false,
+				}),
+			},
+		},
+		{
+			name: "series settings",
+			plugin: &Anomaly{
+				Fields: []string{"value"},
+				Method: "ewma",
+				Alpha:  0.5,
+				Warmup: 2,
+				Series: []*detector{
+					{Tags: map[string][]string{"site": {"b*"}}, Threshold: 30},
+				},
+			},
+			input: []telegraf.Metric{
+				value(map[string]string{"site": "a"}, map[string]interface{}{"value": 10.0, "other": 1.0}),
+				value(map[string]string{"site": "b1"}, map[string]interface{}{"value": 10.0}),
+				value(map[string]string{"site": "a"}, map[string]interface{}{"value": 12.0, "other": 1.0}),
+				value(map[string]string{"site": "b1"}, map[string]interface{}{"value": 12.0}),
+				value(map[string]string{"site": "a"}, map[string]interface{}{"value": 10.0, "other": 1.0}),
+				value(map[string]string{"site": "b1"}, map[string]interface{}{"value": 10.0}),
+				value(map[string]string{"site": "a"}, map[string]interface{}{"value": 30.0, "other": 1.0}),
+				value(map[string]string{"site": "b1"}, map[string]interface{}{"value": 30.0}),
+			},
+			expected: []telegraf.Metric{
+				value(map[string]string{"site": "a"}, map[string]interface{}{"value": 10.0, "other": 1.0}),
+				value(map[string]string{"site": "b1"}, map[string]interface{}{"value": 10.0}),
+				value(map[string]string{"site": "a"}, map[string]interface{}{"value": 12.0, "other": 1.0}),
+				value(map[string]string{"site": "b1"}, map[string]interface{}{"value": 12.0}),
+				value(map[string]string{"site": "a"}, map[string]interface{}{
+					"value":               10.0,
+					"other":               1.0,
+					"value_anomaly_score": 1.0,
+					"value_anomaly":       false,
+				}),
+				value(map[string]string{"site": "b1"}, map[string]interface{}{
+					"value":               10.0,
+					"value_anomaly_score": 1.0,
+					"value_anomaly":       false,
+				}),
+				value(map[string]string{"site": "a"}, map[string]interface{}{
+					"value":               30.0,
+					"other":               1.0,
+					"value_anomaly_score": 19.5 / math.Sqrt(0.75),
+					"value_anomaly":       true,
+				}),
+				value(map[string]string{"site": "b1"}, map[string]interface{}{
+					"value":               30.0,
+					"value_anomaly_score": 19.5 / math.Sqrt(0.75),
+					"value_anomaly":       false,
+				}),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			require.NoError(t, tt.plugin.Init())
+
+			var actual []telegraf.Metric
+			for _, m := range tt.input {
+				actual = append(actual, tt.plugin.Apply(m)...)
+			}
+			testutil.RequireMetricsEqual(t, tt.expected, actual, cmpopts.EquateApprox(0, 1e-9))
+		})
+	}
+}
+
+func TestStatePersistence(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	plugin := &Anomaly{Method: "ewma", Alpha: 0.5, Warmup: 2, Log: &testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+	plugin.Apply(
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 10.0}, now),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 12.0}, now),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 10.0}, now),
+	)
+
+	// Serialize the state as done by the persister
+	var pi telegraf.StatefulPlugin = plugin
+	serialized, err := json.Marshal(pi.GetState())
+	require.NoError(t, err)
+
+	// Restore the state in a new instance, the restored states must not
+	// expire before receiving new values
+	restored := &Anomaly{Method: "ewma", Alpha: 0.5, Warmup: 2, Expiry: config.Duration(time.Hour), Log: &testutil.Logger{}}
+	require.NoError(t, restored.Init())
+	var states map[string]*state
+	require.NoError(t, json.Unmarshal(serialized, &states))
+	require.NoError(t, restored.SetState(states))
+
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{
+			"value":               30.0,
+			"value_anomaly_score": 19.5 / math.Sqrt(0.75),
+			"value_anomaly":       true,
+		}, now),
+	}
+	actual := restored.Apply(metric.New("m", map[string]string{}, map[string]interface{}{"value": 30.0}, now))
+	testutil.RequireMetricsEqual(t, expected, actual, cmpopts.EquateApprox(0, 1e-9))
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	expired := metric.New("m", map[string]string{"host": "a"}, map[string]interface{}{"value": 10.0}, now)
+	active := metric.New("m", map[string]string{"host": "b"}, map[string]interface{}{"value": 10.0}, now)
+
+	plugin := &Anomaly{Method: "ewma", Alpha: 0.1, Warmup: 1, Expiry: config.Duration(time.Hour), Log: &testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+	plugin.Apply(expired, active)
+	require.Len(t, plugin.states, 2)
+
+	// Pretend the field of the first series did not receive values for
+	// longer than the expiry and the last check is due
+	plugin.seen[strconv.FormatUint(expired.HashID(), 16)+"/value"] = time.Now().Add(-2 * time.Hour)
+	plugin.lastExpiry = time.Now().Add(-2 * time.Hour)
+
+	input := []telegraf.Metric{
+		metric.New("m", map[string]string{"host": "b"}, map[string]interface{}{"value": 10.0}, now),
+	}
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{"host": "b"}, map[string]interface{}{"value": 10.0, "value_anomaly": false}, now),
+	}
+	testutil.RequireMetricsEqual(t, expected, plugin.Apply(input...))
+	require.Len(t, plugin.states, 1)
+	require.Len(t, plugin.seen, 1)
+
+	// The expired field starts learning again
+	input = []telegraf.Metric{
+		metric.New("m", map[string]string{"host": "a"}, map[string]interface{}{"value": 10.0}, now),
+	}
+	expected = []telegraf.Metric{
+		metric.New("m", map[string]string{"host": "a"}, map[string]interface{}{"value": 10.0}, now),
+	}
+	testutil.RequireMetricsEqual(t, expected, plugin.Apply(input...))
+	require.Len(t, plugin.states, 2)
+}
+
+func TestTracking(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 5.0}, now),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 7.0}, now),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 5.0}, now),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 7.0, "value_anomaly": true}, now),
+	}
+
+	plugin := &Anomaly{Method: "ewma", Alpha: 0.1, Warmup: 1, Log: &testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+
+	// Process expected metrics and compare with resulting metrics
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
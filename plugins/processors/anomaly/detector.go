@@ -0,0 +1,210 @@
+package anomaly
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal/choice"
+)
+
+// madScale converts the median absolute deviation to the modified z-score
+// as proposed by Iglewicz and Hoaglin
+const madScale = 0.6745
+
+type detector struct {
+	Name      []string            `toml:"name"`
+	Tags      map[string][]string `toml:"tags"`
+	Fields    []string            `toml:"fields"`
+	Method    string              `toml:"method"`
+	Threshold float64             `toml:"threshold"`
+	Warmup    int                 `toml:"warmup"`
+	Alpha     float64             `toml:"alpha"`
+	Period    int                 `toml:"period"`
+	Window    int                 `toml:"window"`
+
+	nameFilter  filter.Filter
+	tagFilters  map[string]filter.Filter
+	fieldFilter filter.Filter
+}
+
+// state contains the learned behavior of a single field of a series, the
+// fields are exported to allow persisting the state
+type state struct {
+	Method   string    `json:"method"`
+	Count    int       `json:"count"`
+	Mean     float64   `json:"mean"`
+	Variance float64   `json:"variance"`
+	History  []float64 `json:"history,omitempty"`
+}
+
+// inherit fills all unset parameters from the given detector
+func (d *detector) inherit(defaults *detector) {
+	if len(d.Fields) == 0 {
+		d.Fields = defaults.Fields
+	}
+	if d.Method == "" {
+		d.Method = defaults.Method
+	}
+	if d.Threshold == 0 {
+		d.Threshold = defaults.Threshold
+	}
+	if d.Warmup == 0 {
+		d.Warmup = defaults.Warmup
+	}
+	if d.Alpha == 0 {
+		d.Alpha = defaults.Alpha
+	}
+	if d.Period == 0 {
+		d.Period = defaults.Period
+	}
+	if d.Window == 0 {
+		d.Window = defaults.Window
+	}
+}
+
+func (d *detector) init() error {
+	if err := choice.Check(d.Method, []string{"ewma", "seasonal", "mad"}); err != nil {
+		return fmt.Errorf("invalid 'method': %w", err)
+	}
+	if d.Threshold < 0 {
+		return errors.New("'threshold' must not be negative")
+	}
+	if d.Threshold == 0 {
+		d.Threshold = 3.0
+		if d.Method == "mad" {
+			d.Threshold = 3.5
+		}
+	}
+	if d.Warmup < 0 {
+		return errors.New("'warmup' must not be negative")
+	}
+
+	switch d.Method {
+	case "ewma", "seasonal":
+		if d.Alpha <= 0 || d.Alpha > 1 {
+			return errors.New("'alpha' must be within (0, 1]")
+		}
+		if d.Method == "seasonal" && d.Period < 1 {
+			return errors.New("'period' must be positive for the seasonal method")
+		}
+	case "mad":
+		if d.Window < 3 {
+			return errors.New("'window' must be at least 3 for the mad method")
+		}
+		if d.Warmup > d.Window {
+			return errors.New("'warmup' must not exceed 'window'")
+		}
+	}
+
+	var err error
+	if d.nameFilter, err = filter.Compile(d.Name); err != nil {
+		return fmt.Errorf("creating name filter failed: %w", err)
+	}
+	d.tagFilters = make(map[string]filter.Filter, len(d.Tags))
+	for k, values := range d.Tags {
+		if d.tagFilters[k], err = filter.Compile(values); err != nil {
+			return fmt.Errorf("creating tag filter for tag %q failed: %w", k, err)
+		}
+	}
+	if d.fieldFilter, err = filter.Compile(d.Fields); err != nil {
+		return fmt.Errorf("creating field filter failed: %w", err)
+	}
+	return nil
+}
+
+// matches checks if the detector applies to the given metric field
+func (d *detector) matches(m telegraf.Metric, field string) bool {
+	if d.nameFilter != nil && !d.nameFilter.Match(m.Name()) {
+		return false
+	}
+	for k, f := range d.tagFilters {
+		if value, found := m.GetTag(k); !found || !f.Match(value) {
+			return false
+		}
+	}
+	return d.fieldFilter != nil && d.fieldFilter.Match(field)
+}
+
+// process scores the value and updates the state afterwards. The score is
+// invalid if all previous values were identical, in this case the returned
+// score is the absolute deviation. No score is returned during warmup.
+func (d *detector) process(s *state, value float64) (score float64, valid, ready bool) {
+	switch d.Method {
+	case "ewma":
+		score, valid, ready = d.ewma(s, value)
+	case "seasonal":
+		// Score the difference to the value one period ago
+		if len(s.History) >= d.Period {
+			s.History = s.History[len(s.History)-d.Period:]
+			score, valid, ready = d.ewma(s, value-s.History[0])
+			s.History = s.History[1:]
+		}
+		s.History = append(s.History, value)
+	case "mad":
+		if len(s.History) >= d.Warmup && len(s.History) > 0 {
+			median, deviation := mad(s.History)
+			score, valid = normalize(math.Abs(value-median), deviation/madScale)
+			ready = true
+		}
+		s.History = append(s.History, value)
+		if len(s.History) > d.Window {
+			s.History = s.History[len(s.History)-d.Window:]
+		}
+	}
+	return score, valid, ready
+}
+
+// ewma scores the value using the exponentially weighted moving average and
+// variance of the previous values and updates them afterwards
+func (d *detector) ewma(s *state, value float64) (score float64, valid, ready bool) {
+	if s.Count > 0 && s.Count >= d.Warmup {
+		score, valid = normalize(math.Abs(value-s.Mean), math.Sqrt(s.Variance))
+		ready = true
+	}
+
+	if s.Count == 0 {
+		s.Mean = value
+	} else {
+		diff := value - s.Mean
+		incr := d.Alpha * diff
+		s.Mean += incr
+		s.Variance = (1 - d.Alpha) * (s.Variance + diff*incr)
+	}
+	s.Count++
+
+	return score, valid, ready
+}
+
+// normalize returns the deviation in units of the scale, the result is only
+// valid for a non-zero scale
+func normalize(deviation, scale float64) (float64, bool) {
+	if scale == 0 {
+		return deviation, false
+	}
+	return deviation / scale, true
+}
+
+// mad returns the median and the median absolute deviation of the values
+func mad(values []float64) (median, deviation float64) {
+	buf := make([]float64, len(values))
+	copy(buf, values)
+	median = medianOf(buf)
+	for i, v := range values {
+		buf[i] = math.Abs(v - median)
+	}
+	return median, medianOf(buf)
+}
+
+// medianOf returns the median of the values, the values are sorted in place
+func medianOf(values []float64) float64 {
+	sort.Float64s(values)
+	n := len(values)
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
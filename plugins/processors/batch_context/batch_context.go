@@ -0,0 +1,138 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package batch_context
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type BatchContext struct {
+	ContextMeasurements []string        `toml:"context_measurements"`
+	ContextKeys         []string        `toml:"context_keys"`
+	ScopeTags           []string        `toml:"scope_tags"`
+	Measurements        []string        `toml:"measurements"`
+	Overwrite           bool            `toml:"overwrite"`
+	DropContext         bool            `toml:"drop_context"`
+	Log                 telegraf.Logger `toml:"-"`
+
+	contextFilter filter.Filter
+	filter        filter.Filter
+	contexts      map[string]map[string]string
+}
+
+func (*BatchContext) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *BatchContext) Init() error {
+	if len(p.ContextMeasurements) == 0 {
+		return errors.New("no context measurements configured")
+	}
+	f, err := filter.Compile(p.ContextMeasurements)
+	if err != nil {
+		return fmt.Errorf("creating context measurement filter failed: %w", err)
+	}
+	p.contextFilter = f
+
+	if len(p.ContextKeys) == 0 {
+		return errors.New("no context keys configured")
+	}
+
+	if len(p.Measurements) == 0 {
+		p.Measurements = []string{"*"}
+	}
+	f, err = filter.Compile(p.Measurements)
+	if err != nil {
+		return fmt.Errorf("creating measurement filter failed: %w", err)
+	}
+	p.filter = f
+
+	p.contexts = make(map[string]map[string]string)
+
+	return nil
+}
+
+func (p *BatchContext) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	out := make([]telegraf.Metric, 0, len(in))
+	for _, m := range in {
+		scope, found := p.scope(m)
+
+		if p.contextFilter.Match(m.Name()) {
+			if found {
+				p.capture(scope, m)
+			} else {
+				p.Log.Debugf("Ignoring context metric %q without scope tags", m.Name())
+			}
+			if p.DropContext {
+				m.Drop()
+			} else {
+				out = append(out, m)
+			}
+			continue
+		}
+
+		if found && p.filter.Match(m.Name()) {
+			for k, v := range p.contexts[scope] {
+				if !p.Overwrite && m.HasTag(k) {
+					continue
+				}
+				m.AddTag(k, v)
+			}
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// capture replaces the context of the scope by the keys of the context
+// metric
+func (p *BatchContext) capture(scope string, m telegraf.Metric) {
+	ctx := make(map[string]string, len(p.ContextKeys))
+	for _, key := range p.ContextKeys {
+		v, found := m.GetTag(key)
+		if !found {
+			raw, found := m.GetField(key)
+			if !found {
+				continue
+			}
+			v = fmt.Sprint(raw)
+		}
+		if v != "" {
+			ctx[key] = v
+		}
+	}
+
+	if len(ctx) == 0 {
+		delete(p.contexts, scope)
+		return
+	}
+	p.contexts[scope] = ctx
+}
+
+// scope returns the values of the scope tags of the metric
+func (p *BatchContext) scope(m telegraf.Metric) (string, bool) {
+	values := make([]string, 0, len(p.ScopeTags))
+	for _, k := range p.ScopeTags {
+		v, found := m.GetTag(k)
+		if !found {
+			return "", false
+		}
+		values = append(values, v)
+	}
+	return strings.Join(values, "\x00"), true
+}
+
+func init() {
+	processors.Add("batch_context", func() telegraf.Processor {
+		return &BatchContext{}
+	})
+}
@@ -0,0 +1,208 @@
+package batch_context
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *BatchContext
+		expected string
+	}{
+		{
+			name:     "no context measurements",
+			plugin:   &BatchContext{ContextKeys: []string{"batch"}},
+			expected: "no context measurements configured",
+		},
+		{
+			name:     "invalid context measurement filter",
+			plugin:   &BatchContext{ContextMeasurements: []string{"a[b"}, ContextKeys: []string{"batch"}},
+			expected: "creating context measurement filter failed",
+		},
+		{
+			name:     "no context keys",
+			plugin:   &BatchContext{ContextMeasurements: []string{"mes"}},
+			expected: "no context keys configured",
+		},
+		{
+			name: "invalid measurement filter",
+			plugin: &BatchContext{
+				ContextMeasurements: []string{"mes"},
+				ContextKeys:         []string{"batch"},
+				Measurements:        []string{"a[b"},
+			},
+			expected: "creating measurement filter failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		plugin   *BatchContext
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name: "context changes",
+			plugin: &BatchContext{
+				ContextMeasurements: []string{"mes"},
+				ContextKeys:         []string{"batch", "recipe"},
+			},
+			input: []telegraf.Metric{
+				metric.New("temp", map[string]string{}, map[string]interface{}{"value": 20.0}, now),
+				metric.New("mes", map[string]string{"recipe": "R1"}, map[string]interface{}{"batch": "B1", "count": int64(1)}, now),
+				metric.New("temp", map[string]string{}, map[string]interface{}{"value": 21.0}, now),
+				metric.New("mes", map[string]string{}, map[string]interface{}{"batch": int64(2)}, now),
+				metric.New("temp", map[string]string{}, map[string]interface{}{"value": 22.0}, now),
+				metric.New("mes", map[string]string{}, map[string]interface{}{"batch": ""}, now),
+				metric.New("temp", map[string]string{}, map[string]interface{}{"value": 23.0}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("temp", map[string]string{}, map[string]interface{}{"value": 20.0}, now),
+				metric.New("mes", map[string]string{"recipe": "R1"}, map[string]interface{}{"batch": "B1", "count": int64(1)}, now),
+				metric.New("temp", map[string]string{"batch": "B1", "recipe": "R1"}, map[string]interface{}{"value": 21.0}, now),
+				metric.New("mes", map[string]string{}, map[string]interface{}{"batch": int64(2)}, now),
+				metric.New("temp", map[string]string{"batch": "2"}, map[string]interface{}{"value": 22.0}, now),
+				metric.New("mes", map[string]string{}, map[string]interface{}{"batch": ""}, now),
+				metric.New("temp", map[string]string{}, map[string]interface{}{"value": 23.0}, now),
+			},
+		},
+		{
+			name: "scope tags",
+			plugin: &BatchContext{
+				ContextMeasurements: []string{"mes"},
+				ContextKeys:         []string{"order"},
+				ScopeTags:           []string{"line"},
+				DropContext:         true,
+			},
+			input: []telegraf.Metric{
+				metric.New("mes", map[string]string{"line": "1", "order": "O1"}, map[string]interface{}{"qty": int64(10)}, now),
+				metric.New("mes", map[string]string{"line": "2", "order": "O2"}, map[string]interface{}{"qty": int64(20)}, now),
+				metric.New("mes", map[string]string{"order": "O3"}, map[string]interface{}{"qty": int64(30)}, now),
+				metric.New("temp", map[string]string{"line": "1"}, map[string]interface{}{"value": 20.0}, now),
+				metric.New("temp", map[string]string{"line": "2"}, map[string]interface{}{"value": 21.0}, now),
+				metric.New("temp", map[string]string{"line": "3"}, map[string]interface{}{"value": 22.0}, now),
+				metric.New("temp", map[string]string{}, map[string]interface{}{"value": 23.0}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("temp", map[string]string{"line": "1", "order": "O1"}, map[string]interface{}{"value": 20.0}, now),
+				metric.New("temp", map[string]string{"line": "2", "order": "O2"}, map[string]interface{}{"value": 21.0}, now),
+				metric.New("temp", map[string]string{"line": "3"}, map[string]interface{}{"value": 22.0}, now),
+				metric.New("temp", map[string]string{}, map[string]interface{}{"value": 23.0}, now),
+			},
+		},
+		{
+			name: "measurements and overwrite",
+			plugin: &BatchContext{
+				ContextMeasurements: []string{"mes_*"},
+				ContextKeys:         []string{"batch"},
+				Measurements:        []string{"process_*"},
+				Overwrite:           true,
+			},
+			input: []telegraf.Metric{
+				metric.New("mes_batch", map[string]string{"batch": "B1"}, map[string]interface{}{"active": true}, now),
+				metric.New("process_temp", map[string]string{"batch": "old"}, map[string]interface{}{"value": 20.0}, now),
+				metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 5.0}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("mes_batch", map[string]string{"batch": "B1"}, map[string]interface{}{"active": true}, now),
+				metric.New("process_temp", map[string]string{"batch": "B1"}, map[string]interface{}{"value": 20.0}, now),
+				metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 5.0}, now),
+			},
+		},
+		{
+			name: "keep existing tags",
+			plugin: &BatchContext{
+				ContextMeasurements: []string{"mes"},
+				ContextKeys:         []string{"batch", "recipe"},
+			},
+			input: []telegraf.Metric{
+				metric.New("mes", map[string]string{"batch": "B1", "recipe": "R1"}, map[string]interface{}{"active": true}, now),
+				metric.New("temp", map[string]string{"batch": "B0"}, map[string]interface{}{"value": 20.0}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("mes", map[string]string{"batch": "B1", "recipe": "R1"}, map[string]interface{}{"active": true}, now),
+				metric.New("temp", map[string]string{"batch": "B0", "recipe": "R1"}, map[string]interface{}{"value": 20.0}, now),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			require.NoError(t, tt.plugin.Init())
+
+			actual := tt.plugin.Apply(tt.input...)
+			testutil.RequireMetricsEqual(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestTracking(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("mes", map[string]string{"batch": "B1"}, map[string]interface{}{"active": true}, now),
+		metric.New("temp", map[string]string{}, map[string]interface{}{"value": 20.0}, now),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("temp", map[string]string{"batch": "B1"}, map[string]interface{}{"value": 20.0}, now),
+	}
+
+	plugin := &BatchContext{
+		ContextMeasurements: []string{"mes"},
+		ContextKeys:         []string{"batch"},
+		DropContext:         true,
+		Log:                 &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// Process expected metrics and compare with resulting metrics
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
@@ -0,0 +1,187 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package deadband
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Deadband struct {
+	Fields   []string        `toml:"fields"`
+	Absolute float64         `toml:"absolute"`
+	Percent  float64         `toml:"percent"`
+	MaxAge   config.Duration `toml:"max_age"`
+	Log      telegraf.Logger `toml:"-"`
+
+	filter    filter.Filter
+	cache     map[uint64]*entry
+	lastClean time.Time
+}
+
+// entry holds the field values of the last emitted metric of a series with
+// numeric values converted to float
+type entry struct {
+	values  map[string]interface{}
+	emitted time.Time
+}
+
+func (*Deadband) SampleConfig() string {
+	return sampleConfig
+}
+
+func (d *Deadband) Init() error {
+	if d.Absolute < 0 {
+		return errors.New("'absolute' must not be negative")
+	}
+	if d.Percent < 0 {
+		return errors.New("'percent' must not be negative")
+	}
+	if d.Absolute == 0 && d.Percent == 0 {
+		return errors.New("either 'absolute' or 'percent' must be set")
+	}
+	if d.MaxAge < 0 {
+		return errors.New("'max_age' must not be negative")
+	}
+
+	if len(d.Fields) == 0 {
+		d.Fields = []string{"*"}
+	}
+	f, err := filter.Compile(d.Fields)
+	if err != nil {
+		return fmt.Errorf("creating field filter failed: %w", err)
+	}
+	d.filter = f
+
+	d.cache = make(map[uint64]*entry)
+	d.lastClean = time.Now()
+
+	return nil
+}
+
+func (d *Deadband) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	out := in[:0]
+	for _, m := range in {
+		values := d.values(m)
+
+		// Metrics without any of the fields are not subject to the deadband
+		if len(values) == 0 {
+			out = append(out, m)
+			continue
+		}
+
+		id := m.HashID()
+		e, found := d.cache[id]
+		if !found || d.expired(e, m.Time()) || d.changed(e.values, values) {
+			d.cache[id] = &entry{values: values, emitted: m.Time()}
+			out = append(out, m)
+			continue
+		}
+
+		m.Drop()
+	}
+	d.cleanup()
+
+	return out
+}
+
+// values returns the fields of the metric the deadband applies to
+func (d *Deadband) values(m telegraf.Metric) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, field := range m.FieldList() {
+		if !d.filter.Match(field.Key) {
+			continue
+		}
+		if v, ok := toFloat(field.Value); ok {
+			values[field.Key] = v
+		} else {
+			values[field.Key] = field.Value
+		}
+	}
+	return values
+}
+
+// expired checks if the series was suppressed for longer than the maximum
+// age at the given time
+func (d *Deadband) expired(e *entry, t time.Time) bool {
+	return d.MaxAge > 0 && t.Sub(e.emitted) >= time.Duration(d.MaxAge)
+}
+
+// changed checks if any of the values changed by at least one of the
+// thresholds compared to the last emitted values. New fields and changes of
+// non-numeric values always count as a change.
+func (d *Deadband) changed(last, current map[string]interface{}) bool {
+	for key, value := range current {
+		prev, found := last[key]
+		if !found {
+			return true
+		}
+
+		v, vok := value.(float64)
+		p, pok := prev.(float64)
+		if !vok || !pok {
+			if value != prev {
+				return true
+			}
+			continue
+		}
+
+		diff := math.Abs(v - p)
+		if d.Absolute > 0 && diff >= d.Absolute {
+			return true
+		}
+		if d.Percent > 0 {
+			// Any change of a zero value exceeds the relative threshold
+			if p == 0 {
+				if diff > 0 {
+					return true
+				}
+			} else if diff/math.Abs(p)*100 >= d.Percent {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cleanup removes the series that pass anyway due to the maximum age
+func (d *Deadband) cleanup() {
+	// No need to cleanup the cache too often
+	if d.MaxAge == 0 || time.Since(d.lastClean) < time.Duration(d.MaxAge) {
+		return
+	}
+	d.lastClean = time.Now()
+	for id, e := range d.cache {
+		if time.Since(e.emitted) >= time.Duration(d.MaxAge) {
+			delete(d.cache, id)
+		}
+	}
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func init() {
+	processors.Add("deadband", func() telegraf.Processor {
+		return &Deadband{}
+	})
+}
@@ -0,0 +1,265 @@
+package deadband
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Deadband
+		expected string
+	}{
+		{
+			name:     "no threshold",
+			plugin:   &Deadband{},
+			expected: "either 'absolute' or 'percent' must be set",
+		},
+		{
+			name:     "negative absolute",
+			plugin:   &Deadband{Absolute: -1},
+			expected: "'absolute' must not be negative",
+		},
+		{
+			name:     "negative percent",
+			plugin:   &Deadband{Percent: -5},
+			expected: "'percent' must not be negative",
+		},
+		{
+			name:     "negative max age",
+			plugin:   &Deadband{Absolute: 1, MaxAge: config.Duration(-time.Second)},
+			expected: "'max_age' must not be negative",
+		},
+		{
+			name:     "invalid field filter",
+			plugin:   &Deadband{Absolute: 1, Fields: []string{"a[b"}},
+			expected: "creating field filter failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		plugin   *Deadband
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name:   "absolute",
+			plugin: &Deadband{Absolute: 0.5},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 10.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 10.3}, now.Add(1*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 9.6}, now.Add(2*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 10.5}, now.Add(3*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 10.9}, now.Add(4*time.Second)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 10.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 10.5}, now.Add(3*time.Second)),
+			},
+		},
+		{
+			name:   "percent",
+			plugin: &Deadband{Percent: 10},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(100)}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(109)}, now.Add(1*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(90)}, now.Add(2*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(95)}, now.Add(3*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(0)}, now.Add(4*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(0)}, now.Add(5*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(1)}, now.Add(6*time.Second)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(100)}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(90)}, now.Add(2*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(0)}, now.Add(4*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(1)}, now.Add(6*time.Second)),
+			},
+		},
+		{
+			name:   "either threshold",
+			plugin: &Deadband{Absolute: 5, Percent: 1},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": uint64(1000)}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": uint64(1004)}, now.Add(1*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": uint64(1005)}, now.Add(2*time.Second)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": uint64(1000)}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": uint64(1005)}, now.Add(2*time.Second)),
+			},
+		},
+		{
+			name:   "any field exceeding",
+			plugin: &Deadband{Absolute: 1},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"a": 1.0, "b": 1.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"a": 1.5, "b": 1.5}, now.Add(1*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"a": 1.5, "b": 2.0}, now.Add(2*time.Second)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"a": 1.0, "b": 1.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"a": 1.5, "b": 2.0}, now.Add(2*time.Second)),
+			},
+		},
+		{
+			name:   "per series",
+			plugin: &Deadband{Absolute: 1},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"value": 1.0}, now),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"value": 1.5}, now),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"value": 1.5}, now.Add(1*time.Second)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"value": 2.5}, now.Add(1*time.Second)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"value": 1.0}, now),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"value": 1.5}, now),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"value": 2.5}, now.Add(1*time.Second)),
+			},
+		},
+		{
+			name:   "non-numeric and new fields",
+			plugin: &Deadband{Absolute: 10},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0, "state": "run"}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0, "state": "run"}, now.Add(1*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0, "state": "stop"}, now.Add(2*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0, "state": "stop", "alarm": true}, now.Add(3*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 3.0, "state": "stop", "alarm": true}, now.Add(4*time.Second)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0, "state": "run"}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0, "state": "stop"}, now.Add(2*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0, "state": "stop", "alarm": true}, now.Add(3*time.Second)),
+			},
+		},
+		{
+			name:   "field filter",
+			plugin: &Deadband{Absolute: 10, Fields: []string{"temp*"}},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"temperature": 20.0, "counter": int64(1)}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"temperature": 21.0, "counter": int64(100)}, now.Add(1*time.Second)),
+				metric.New("other", map[string]string{}, map[string]interface{}{"counter": int64(1)}, now.Add(1*time.Second)),
+				metric.New("other", map[string]string{}, map[string]interface{}{"counter": int64(1)}, now.Add(2*time.Second)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"temperature": 20.0, "counter": int64(1)}, now),
+				metric.New("other", map[string]string{}, map[string]interface{}{"counter": int64(1)}, now.Add(1*time.Second)),
+				metric.New("other", map[string]string{}, map[string]interface{}{"counter": int64(1)}, now.Add(2*time.Second)),
+			},
+		},
+		{
+			name:   "max age",
+			plugin: &Deadband{Absolute: 1, MaxAge: config.Duration(3 * time.Second)},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now.Add(1*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now.Add(2*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now.Add(3*time.Second)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now.Add(4*time.Second)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now.Add(3*time.Second)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.plugin.Init())
+
+			// Apply the metrics one by one to simulate consecutive batches
+			var actual []telegraf.Metric
+			for _, m := range tt.input {
+				actual = append(actual, tt.plugin.Apply(m)...)
+			}
+			testutil.RequireMetricsEqual(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	plugin := &Deadband{Absolute: 1, MaxAge: config.Duration(time.Minute)}
+	require.NoError(t, plugin.Init())
+
+	now := time.Now()
+	plugin.Apply(
+		metric.New("m", map[string]string{"id": "old"}, map[string]interface{}{"value": 1.0}, now.Add(-2*time.Minute)),
+		metric.New("m", map[string]string{"id": "new"}, map[string]interface{}{"value": 1.0}, now),
+	)
+	require.Len(t, plugin.cache, 2)
+
+	// Force the cleanup and check the expired series is removed
+	plugin.lastClean = now.Add(-time.Minute)
+	plugin.Apply()
+	require.Len(t, plugin.cache, 1)
+}
+
+func TestTracking(t *testing.T) {
+	now := time.Now()
+
+	inputRaw := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.1}, now.Add(1*time.Second)),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 5.0}, now.Add(2*time.Second)),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 5.0}, now.Add(2*time.Second)),
+	}
+
+	plugin := &Deadband{Absolute: 1}
+	require.NoError(t, plugin.Init())
+
+	// Process expected metrics and compare with resulting metrics
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
@@ -0,0 +1,176 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package debounce
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Debounce struct {
+	Expiry config.Duration `toml:"expiry"`
+	Rules  []*rule         `toml:"rule"`
+	Log    telegraf.Logger `toml:"-"`
+
+	states     map[uint64]map[string]*state
+	seen       map[uint64]time.Time
+	lastExpiry time.Time
+}
+
+type rule struct {
+	Fields     []string        `toml:"fields"`
+	StableTime config.Duration `toml:"stable_time"`
+	Samples    int             `toml:"samples"`
+
+	filter filter.Filter
+}
+
+// state holds the accepted value of a field and the changed value waiting
+// to become stable
+type state struct {
+	accepted interface{}
+
+	candidate interface{}
+	since     time.Time
+	count     int
+}
+
+func (*Debounce) SampleConfig() string {
+	return sampleConfig
+}
+
+func (d *Debounce) Init() error {
+	if len(d.Rules) == 0 {
+		return errors.New("no rules configured")
+	}
+	if d.Expiry < 0 {
+		return errors.New("'expiry' must not be negative")
+	}
+	for i, r := range d.Rules {
+		if len(r.Fields) == 0 {
+			return fmt.Errorf("no fields configured for rule %d", i+1)
+		}
+		f, err := filter.Compile(r.Fields)
+		if err != nil {
+			return fmt.Errorf("creating field filter for rule %d failed: %w", i+1, err)
+		}
+		r.filter = f
+
+		if r.StableTime < 0 {
+			return fmt.Errorf("'stable_time' of rule %d must not be negative", i+1)
+		}
+		if r.Samples < 0 {
+			return fmt.Errorf("'samples' of rule %d must not be negative", i+1)
+		}
+		if r.Samples == 0 {
+			r.Samples = 1
+		}
+		if r.StableTime == 0 && r.Samples == 1 {
+			return fmt.Errorf("either 'stable_time' or 'samples' of rule %d must be set", i+1)
+		}
+	}
+
+	d.states = make(map[uint64]map[string]*state)
+	d.seen = make(map[uint64]time.Time)
+
+	return nil
+}
+
+func (d *Debounce) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	now := time.Now()
+	d.expire(now)
+
+	for _, m := range in {
+		id := m.HashID()
+		ts := m.Time()
+
+		for _, field := range m.FieldList() {
+			r := d.rule(field.Key)
+			if r == nil {
+				continue
+			}
+
+			states := d.states[id]
+			if states == nil {
+				states = make(map[string]*state)
+				d.states[id] = states
+			}
+			d.seen[id] = now
+
+			// The first value of a field is accepted immediately
+			s, found := states[field.Key]
+			if !found {
+				states[field.Key] = &state{accepted: field.Value}
+				continue
+			}
+
+			// Replace changes which are not stable yet by the accepted value
+			if value := s.update(r, field.Value, ts); value != field.Value {
+				m.AddField(field.Key, value)
+			}
+		}
+	}
+	return in
+}
+
+// expire removes the states of series without metrics within the expiry
+func (d *Debounce) expire(now time.Time) {
+	// No need to check the series too often
+	if d.Expiry <= 0 || now.Sub(d.lastExpiry) < time.Duration(d.Expiry) {
+		return
+	}
+	d.lastExpiry = now
+
+	for id, seen := range d.seen {
+		if now.Sub(seen) >= time.Duration(d.Expiry) {
+			delete(d.states, id)
+			delete(d.seen, id)
+		}
+	}
+}
+
+// rule returns the first rule matching the field or nil if none matches
+func (d *Debounce) rule(key string) *rule {
+	for _, r := range d.Rules {
+		if r.filter.Match(key) {
+			return r
+		}
+	}
+	return nil
+}
+
+// update processes a new value of the field and returns the debounced value
+func (s *state) update(r *rule, value interface{}, ts time.Time) interface{} {
+	// Changes reverting to the accepted value are dropped
+	if value == s.accepted {
+		s.candidate, s.count = nil, 0
+		return value
+	}
+
+	if s.count > 0 && value == s.candidate {
+		s.count++
+	} else {
+		s.candidate, s.since, s.count = value, ts, 1
+	}
+
+	if s.count >= r.Samples && ts.Sub(s.since) >= time.Duration(r.StableTime) {
+		s.accepted = value
+		s.candidate, s.count = nil, 0
+	}
+	return s.accepted
+}
+
+func init() {
+	processors.Add("debounce", func() telegraf.Processor {
+		return &Debounce{Expiry: config.Duration(time.Hour)}
+	})
+}
@@ -0,0 +1,277 @@
+package debounce
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Debounce
+		expected string
+	}{
+		{
+			name:     "no rules",
+			plugin:   &Debounce{},
+			expected: "no rules configured",
+		},
+		{
+			name:     "negative expiry",
+			plugin:   &Debounce{Expiry: config.Duration(-time.Second), Rules: []*rule{{Fields: []string{"a"}, Samples: 2}}},
+			expected: "'expiry' must not be negative",
+		},
+		{
+			name:     "no fields",
+			plugin:   &Debounce{Rules: []*rule{{Samples: 2}}},
+			expected: "no fields configured for rule 1",
+		},
+		{
+			name:     "invalid field filter",
+			plugin:   &Debounce{Rules: []*rule{{Fields: []string{"a[b"}, Samples: 2}}},
+			expected: "creating field filter for rule 1 failed",
+		},
+		{
+			name:     "negative stable time",
+			plugin:   &Debounce{Rules: []*rule{{Fields: []string{"a"}, StableTime: config.Duration(-time.Second)}}},
+			expected: "'stable_time' of rule 1 must not be negative",
+		},
+		{
+			name:     "negative samples",
+			plugin:   &Debounce{Rules: []*rule{{Fields: []string{"a"}, Samples: -1}}},
+			expected: "'samples' of rule 1 must not be negative",
+		},
+		{
+			name: "no debouncing",
+			plugin: &Debounce{Rules: []*rule{
+				{Fields: []string{"a"}, Samples: 2},
+				{Fields: []string{"b"}, Samples: 1},
+			}},
+			expected: "either 'stable_time' or 'samples' of rule 2 must be set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	at := func(sec int) time.Time {
+		return base.Add(time.Duration(sec) * time.Second)
+	}
+
+	tests := []struct {
+		name     string
+		rules    []*rule
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name:  "stable time",
+			rules: []*rule{{Fields: []string{"door"}, StableTime: config.Duration(5 * time.Second)}},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"door": false}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door": true}, at(1)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door": false}, at(2)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door": true}, at(10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door": true}, at(14)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door": true}, at(15)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door": false}, at(16)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"door": false}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door": false}, at(1)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door": false}, at(2)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door": false}, at(10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door": false}, at(14)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door": true}, at(15)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door": true}, at(16)),
+			},
+		},
+		{
+			name:  "consecutive samples",
+			rules: []*rule{{Fields: []string{"state"}, Samples: 3}},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "idle"}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "run"}, at(1)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "run"}, at(2)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "fault"}, at(3)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "run"}, at(4)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "run"}, at(5)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "run"}, at(6)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "idle"}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "idle"}, at(1)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "idle"}, at(2)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "idle"}, at(3)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "idle"}, at(4)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "idle"}, at(5)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "run"}, at(6)),
+			},
+		},
+		{
+			name:  "samples and stable time",
+			rules: []*rule{{Fields: []string{"alarm"}, Samples: 2, StableTime: config.Duration(10 * time.Second)}},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"alarm": int64(0)}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"alarm": int64(1)}, at(1)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"alarm": int64(1)}, at(2)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"alarm": int64(1)}, at(11)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"alarm": int64(0)}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"alarm": int64(0)}, at(1)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"alarm": int64(0)}, at(2)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"alarm": int64(1)}, at(11)),
+			},
+		},
+		{
+			name: "first matching rule",
+			rules: []*rule{
+				{Fields: []string{"door_main"}, Samples: 2},
+				{Fields: []string{"door_*"}, Samples: 3},
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"door_main": false, "door_back": false, "value": 1.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door_main": true, "door_back": true, "value": 2.0}, at(1)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door_main": true, "door_back": true, "value": 3.0}, at(2)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door_main": true, "door_back": true, "value": 4.0}, at(3)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"door_main": false, "door_back": false, "value": 1.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door_main": false, "door_back": false, "value": 2.0}, at(1)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door_main": true, "door_back": false, "value": 3.0}, at(2)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"door_main": true, "door_back": true, "value": 4.0}, at(3)),
+			},
+		},
+		{
+			name:  "independent series",
+			rules: []*rule{{Fields: []string{"running"}, Samples: 2}},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"running": false}, at(0)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"running": true}, at(0)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"running": true}, at(1)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"running": true}, at(1)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"running": false}, at(0)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"running": true}, at(0)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"running": false}, at(1)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"running": true}, at(1)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &Debounce{Rules: tt.rules, Log: &testutil.Logger{}}
+			require.NoError(t, plugin.Init())
+
+			actual := plugin.Apply(tt.input...)
+			testutil.RequireMetricsEqual(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	expired := metric.New("m", map[string]string{"line": "a"}, map[string]interface{}{"running": false}, base)
+	active := metric.New("m", map[string]string{"line": "b"}, map[string]interface{}{"running": false}, base)
+
+	plugin := &Debounce{
+		Expiry: config.Duration(time.Hour),
+		Rules:  []*rule{{Fields: []string{"running"}, Samples: 2}},
+		Log:    &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	plugin.Apply(expired, active)
+	require.Len(t, plugin.states, 2)
+
+	// Pretend the first series did not receive metrics for longer than the
+	// expiry and the last check is due
+	plugin.seen[expired.HashID()] = time.Now().Add(-2 * time.Hour)
+	plugin.lastExpiry = time.Now().Add(-2 * time.Hour)
+
+	input := []telegraf.Metric{
+		metric.New("m", map[string]string{"line": "b"}, map[string]interface{}{"running": true}, base.Add(time.Second)),
+	}
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{"line": "b"}, map[string]interface{}{"running": false}, base.Add(time.Second)),
+	}
+	testutil.RequireMetricsEqual(t, expected, plugin.Apply(input...))
+	require.Len(t, plugin.states, 1)
+	require.Len(t, plugin.seen, 1)
+
+	// The first value of the expired series is accepted immediately
+	input = []telegraf.Metric{
+		metric.New("m", map[string]string{"line": "a"}, map[string]interface{}{"running": true}, base.Add(time.Second)),
+	}
+	expected = []telegraf.Metric{
+		metric.New("m", map[string]string{"line": "a"}, map[string]interface{}{"running": true}, base.Add(time.Second)),
+	}
+	testutil.RequireMetricsEqual(t, expected, plugin.Apply(input...))
+	require.Len(t, plugin.states, 2)
+}
+
+func TestTracking(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"running": false}, base),
+		metric.New("m", map[string]string{}, map[string]interface{}{"running": true}, base.Add(time.Second)),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"running": false}, base),
+		metric.New("m", map[string]string{}, map[string]interface{}{"running": false}, base.Add(time.Second)),
+	}
+
+	plugin := &Debounce{
+		Rules: []*rule{{Fields: []string{"running"}, Samples: 2}},
+		Log:   &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// Process expected metrics and compare with resulting metrics
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
@@ -4,10 +4,12 @@ package dedup
 import (
 	_ "embed"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/influxdata/telegraf"
 	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
 	"github.com/influxdata/telegraf/plugins/parsers/influx"
 	"github.com/influxdata/telegraf/plugins/processors"
 	serializers_influx "github.com/influxdata/telegraf/plugins/serializers/influx"
@@ -18,11 +20,22 @@ var sampleConfig string
 
 type Dedup struct {
 	DedupInterval config.Duration `toml:"dedup_interval"`
+	Tolerances    []*tolerance    `toml:"tolerance"`
 	FlushTime     time.Time
 	Cache         map[uint64]telegraf.Metric
 	Log           telegraf.Logger `toml:"-"`
 }
 
+// tolerance defines the numeric difference of field values still considered
+// unchanged and the maximum time to suppress such values
+type tolerance struct {
+	Fields         []string        `toml:"fields"`
+	Delta          float64         `toml:"delta"`
+	MaxSuppression config.Duration `toml:"max_suppression"`
+
+	filter filter.Filter
+}
+
 // Remove expired items from cache
 func (d *Dedup) cleanup() {
 	// No need to cleanup cache too often. Lets save some CPU
@@ -49,6 +62,27 @@ func (*Dedup) SampleConfig() string {
 	return sampleConfig
 }
 
+func (d *Dedup) Init() error {
+	for i, t := range d.Tolerances {
+		if len(t.Fields) == 0 {
+			return fmt.Errorf("no fields configured for tolerance %d", i+1)
+		}
+		f, err := filter.Compile(t.Fields)
+		if err != nil {
+			return fmt.Errorf("creating field filter for tolerance %d failed: %w", i+1, err)
+		}
+		t.filter = f
+
+		if t.Delta < 0 {
+			return fmt.Errorf("'delta' of tolerance %d must not be negative", i+1)
+		}
+		if t.MaxSuppression < 0 {
+			return fmt.Errorf("'max_suppression' of tolerance %d must not be negative", i+1)
+		}
+	}
+	return nil
+}
+
 // main processing method
 func (d *Dedup) Apply(metrics ...telegraf.Metric) []telegraf.Metric {
 	idx := 0
@@ -78,7 +112,7 @@ func (d *Dedup) Apply(metrics ...telegraf.Metric) []telegraf.Metric {
 		sametime := metric.Time() == m.Time()
 		for _, f := range metric.FieldList() {
 			if value, ok := m.GetField(f.Key); ok {
-				if value != f.Value {
+				if !d.unchanged(f.Key, value, f.Value, m.Time()) {
 					changed = true
 					break
 				}
@@ -121,6 +155,39 @@ func (d *Dedup) Apply(metrics ...telegraf.Metric) []telegraf.Metric {
 	return metrics
 }
 
+// unchanged checks if the value of the field is considered equal to the
+// cached value, i.e. the values are identical or within the tolerance of the
+// field if the cached value is not older than the suppression time
+func (d *Dedup) unchanged(key string, cached, value interface{}, ts time.Time) bool {
+	if cached == value {
+		return true
+	}
+	for _, t := range d.Tolerances {
+		if !t.filter.Match(key) {
+			continue
+		}
+		if t.MaxSuppression > 0 && time.Since(ts) >= time.Duration(t.MaxSuppression) {
+			return false
+		}
+		c, cok := toFloat(cached)
+		v, vok := toFloat(value)
+		return cok && vok && math.Abs(v-c) <= t.Delta
+	}
+	return false
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
 func (d *Dedup) GetState() interface{} {
 	s := &serializers_influx.Serializer{}
 	v := make([]telegraf.Metric, 0, len(d.Cache))
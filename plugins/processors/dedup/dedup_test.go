@@ -530,3 +530,80 @@ func TestStatePersistence(t *testing.T) {
 	}
 	require.Len(t, actualState, expectedLen)
 }
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Dedup
+		expected string
+	}{
+		{
+			name:     "no fields",
+			plugin:   &Dedup{Tolerances: []*tolerance{{Delta: 1}}},
+			expected: "no fields configured for tolerance 1",
+		},
+		{
+			name:     "invalid field filter",
+			plugin:   &Dedup{Tolerances: []*tolerance{{Fields: []string{"a[b"}, Delta: 1}}},
+			expected: "creating field filter for tolerance 1 failed",
+		},
+		{
+			name:     "negative delta",
+			plugin:   &Dedup{Tolerances: []*tolerance{{Fields: []string{"a"}, Delta: -1}}},
+			expected: "'delta' of tolerance 1 must not be negative",
+		},
+		{
+			name: "negative max suppression",
+			plugin: &Dedup{Tolerances: []*tolerance{
+				{Fields: []string{"a"}, Delta: 1},
+				{Fields: []string{"b"}, Delta: 1, MaxSuppression: config.Duration(-time.Second)},
+			}},
+			expected: "'max_suppression' of tolerance 2 must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestTolerance(t *testing.T) {
+	now := time.Now()
+
+	input := []telegraf.Metric{
+		metric.New("m", map[string]string{"sensor": "a"}, map[string]interface{}{"temp": 20.0, "state": "run"}, now.Add(-4*time.Minute)),
+		metric.New("m", map[string]string{"sensor": "a"}, map[string]interface{}{"temp": 20.4, "state": "run"}, now.Add(-3*time.Minute)),
+		metric.New("m", map[string]string{"sensor": "a"}, map[string]interface{}{"temp": 20.8, "state": "run"}, now.Add(-2*time.Minute)),
+		metric.New("m", map[string]string{"sensor": "a"}, map[string]interface{}{"temp": 21.0, "state": "stop"}, now.Add(-time.Minute)),
+		metric.New("m", map[string]string{"sensor": "a"}, map[string]interface{}{"temp": int64(21), "state": "stop"}, now.Add(-30*time.Second)),
+		metric.New("m", map[string]string{"sensor": "b"}, map[string]interface{}{"temp": 20.0}, now.Add(-8*time.Minute)),
+		metric.New("m", map[string]string{"sensor": "b"}, map[string]interface{}{"temp": 20.1}, now.Add(-7*time.Minute)),
+		metric.New("m", map[string]string{"sensor": "c"}, map[string]interface{}{"pressure": 1.01}, now.Add(-2*time.Minute)),
+		metric.New("m", map[string]string{"sensor": "c"}, map[string]interface{}{"pressure": 1.02}, now.Add(-time.Minute)),
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{"sensor": "a"}, map[string]interface{}{"temp": 20.0, "state": "run"}, now.Add(-4*time.Minute)),
+		metric.New("m", map[string]string{"sensor": "a"}, map[string]interface{}{"temp": 20.8, "state": "run"}, now.Add(-2*time.Minute)),
+		metric.New("m", map[string]string{"sensor": "a"}, map[string]interface{}{"temp": 21.0, "state": "stop"}, now.Add(-time.Minute)),
+		metric.New("m", map[string]string{"sensor": "b"}, map[string]interface{}{"temp": 20.0}, now.Add(-8*time.Minute)),
+		metric.New("m", map[string]string{"sensor": "b"}, map[string]interface{}{"temp": 20.1}, now.Add(-7*time.Minute)),
+		metric.New("m", map[string]string{"sensor": "c"}, map[string]interface{}{"pressure": 1.01}, now.Add(-2*time.Minute)),
+		metric.New("m", map[string]string{"sensor": "c"}, map[string]interface{}{"pressure": 1.02}, now.Add(-time.Minute)),
+	}
+
+	plugin := &Dedup{
+		DedupInterval: config.Duration(time.Hour),
+		Tolerances: []*tolerance{
+			{Fields: []string{"temp*"}, Delta: 0.5, MaxSuppression: config.Duration(5 * time.Minute)},
+		},
+		FlushTime: now,
+		Cache:     make(map[uint64]telegraf.Metric),
+	}
+	require.NoError(t, plugin.Init())
+
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+}
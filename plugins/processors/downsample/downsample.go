@@ -0,0 +1,269 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package downsample
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+var availableStats = []string{"min", "max", "avg", "first", "last", "count"}
+
+type Downsample struct {
+	Window config.Duration `toml:"window"`
+	Stats  []string        `toml:"stats"`
+	Fields []string        `toml:"fields"`
+	Grace  config.Duration `toml:"grace"`
+	Log    telegraf.Logger `toml:"-"`
+
+	filter filter.Filter
+	acc    telegraf.Accumulator
+	cache  map[uint64]*window
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	sync.Mutex
+}
+
+// window holds the statistics of the fields of a series within a window
+type window struct {
+	name   string
+	tags   map[string]string
+	tp     telegraf.ValueType
+	start  time.Time
+	fields map[string]*statistics
+}
+
+type statistics struct {
+	count int64
+	sum   float64
+
+	min, max      float64
+	minV, maxV    interface{}
+	firstT, lastT time.Time
+	firstV, lastV interface{}
+}
+
+func (*Downsample) SampleConfig() string {
+	return sampleConfig
+}
+
+func (d *Downsample) Init() error {
+	if d.Window <= 0 {
+		return errors.New("'window' must be positive")
+	}
+	if d.Grace < 0 {
+		return errors.New("'grace' must not be negative")
+	}
+
+	if len(d.Stats) == 0 {
+		d.Stats = []string{"min", "max", "avg", "first", "last"}
+	}
+	if err := choice.CheckSlice(d.Stats, availableStats); err != nil {
+		return fmt.Errorf("invalid 'stats': %w", err)
+	}
+
+	if len(d.Fields) == 0 {
+		d.Fields = []string{"*"}
+	}
+	f, err := filter.Compile(d.Fields)
+	if err != nil {
+		return fmt.Errorf("creating field filter failed: %w", err)
+	}
+	d.filter = f
+
+	d.cache = make(map[uint64]*window)
+
+	return nil
+}
+
+func (d *Downsample) Start(acc telegraf.Accumulator) error {
+	d.acc = acc
+
+	// Check for windows without new metrics regularly but at least once per
+	// window to emit them in a timely manner
+	interval := min(time.Duration(d.Window), time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.cancel = cancel
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				d.flush(time.Now())
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (d *Downsample) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
+	values := d.values(m)
+	if len(values) == 0 {
+		acc.AddMetric(m)
+		return nil
+	}
+
+	start := m.Time().Truncate(time.Duration(d.Window))
+
+	d.Lock()
+	defer d.Unlock()
+
+	id := m.HashID()
+	w, found := d.cache[id]
+	if found && !w.start.Equal(start) {
+		if start.Before(w.start) {
+			d.Log.Debugf("Dropping late metric %q at %v of already emitted window", m.Name(), m.Time())
+			m.Drop()
+			return nil
+		}
+		acc.AddMetric(w.metric(d.Stats))
+		found = false
+	}
+	if !found {
+		w = &window{
+			name:   m.Name(),
+			tags:   m.Tags(),
+			tp:     m.Type(),
+			start:  start,
+			fields: make(map[string]*statistics, len(values)),
+		}
+		d.cache[id] = w
+	}
+
+	ts := m.Time()
+	for key, value := range values {
+		s, found := w.fields[key]
+		if !found {
+			s = &statistics{}
+			w.fields[key] = s
+		}
+		s.add(ts, value)
+	}
+	m.Drop()
+
+	return nil
+}
+
+func (d *Downsample) Stop() {
+	if d.cancel != nil {
+		d.cancel()
+	}
+	d.wg.Wait()
+
+	// Emit all pending windows
+	d.Lock()
+	defer d.Unlock()
+	for id, w := range d.cache {
+		d.acc.AddMetric(w.metric(d.Stats))
+		delete(d.cache, id)
+	}
+}
+
+// flush emits the windows ended before the given time minus the grace period
+func (d *Downsample) flush(now time.Time) {
+	d.Lock()
+	defer d.Unlock()
+
+	deadline := now.Add(-time.Duration(d.Window) - time.Duration(d.Grace))
+	for id, w := range d.cache {
+		if w.start.After(deadline) {
+			continue
+		}
+		d.acc.AddMetric(w.metric(d.Stats))
+		delete(d.cache, id)
+	}
+}
+
+// values returns the numeric fields of the metric to downsample
+func (d *Downsample) values(m telegraf.Metric) map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, field := range m.FieldList() {
+		if !d.filter.Match(field.Key) {
+			continue
+		}
+		switch field.Value.(type) {
+		case int64, uint64, float64:
+			values[field.Key] = field.Value
+		}
+	}
+	return values
+}
+
+// metric creates the downsampled metric of the window
+func (w *window) metric(stats []string) telegraf.Metric {
+	fields := make(map[string]interface{}, len(w.fields)*len(stats))
+	for key, s := range w.fields {
+		for _, stat := range stats {
+			switch stat {
+			case "min":
+				fields[key+"_min"] = s.minV
+			case "max":
+				fields[key+"_max"] = s.maxV
+			case "avg":
+				fields[key+"_avg"] = s.sum / float64(s.count)
+			case "first":
+				fields[key+"_first"] = s.firstV
+			case "last":
+				fields[key+"_last"] = s.lastV
+			case "count":
+				fields[key+"_count"] = s.count
+			}
+		}
+	}
+	return metric.New(w.name, w.tags, fields, w.start, w.tp)
+}
+
+// add adds the value to the statistics keeping the original values for the
+// extremes as well as the first and last value in time
+func (s *statistics) add(ts time.Time, value interface{}) {
+	var v float64
+	switch x := value.(type) {
+	case int64:
+		v = float64(x)
+	case uint64:
+		v = float64(x)
+	case float64:
+		v = x
+	}
+
+	if s.count == 0 || v < s.min {
+		s.min, s.minV = v, value
+	}
+	if s.count == 0 || v > s.max {
+		s.max, s.maxV = v, value
+	}
+	if s.count == 0 || ts.Before(s.firstT) {
+		s.firstT, s.firstV = ts, value
+	}
+	if s.count == 0 || !ts.Before(s.lastT) {
+		s.lastT, s.lastV = ts, value
+	}
+	s.count++
+	s.sum += v
+}
+
+func init() {
+	processors.AddStreaming("downsample", func() telegraf.StreamingProcessor {
+		return &Downsample{}
+	})
+}
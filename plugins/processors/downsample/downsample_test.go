@@ -0,0 +1,276 @@
+package downsample
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Downsample
+		expected string
+	}{
+		{
+			name:     "missing window",
+			plugin:   &Downsample{},
+			expected: "'window' must be positive",
+		},
+		{
+			name:     "negative grace",
+			plugin:   &Downsample{Window: config.Duration(time.Second), Grace: config.Duration(-time.Second)},
+			expected: "'grace' must not be negative",
+		},
+		{
+			name:     "invalid stats",
+			plugin:   &Downsample{Window: config.Duration(time.Second), Stats: []string{"min", "median"}},
+			expected: "invalid 'stats'",
+		},
+		{
+			name:     "invalid field filter",
+			plugin:   &Downsample{Window: config.Duration(time.Second), Fields: []string{"a[b"}},
+			expected: "creating field filter failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	// Use windows in the future to avoid emitting windows due to timeouts
+	base := time.Now().Add(24 * time.Hour).Truncate(time.Minute)
+	at := func(sec int) time.Time {
+		return base.Add(time.Duration(sec) * time.Second)
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *Downsample
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name:   "default stats",
+			plugin: &Downsample{},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"value": 3.0}, at(0)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"value": 1.0}, at(10)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"value": 50.0}, at(20)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"value": 2.0}, at(30)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"value": 4.0}, at(60)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{
+					"value_min":   1.0,
+					"value_max":   50.0,
+					"value_avg":   14.0,
+					"value_first": 3.0,
+					"value_last":  2.0,
+				}, at(0)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{
+					"value_min":   4.0,
+					"value_max":   4.0,
+					"value_avg":   4.0,
+					"value_first": 4.0,
+					"value_last":  4.0,
+				}, at(60)),
+			},
+		},
+		{
+			name:   "integer values",
+			plugin: &Downsample{Stats: []string{"min", "max", "avg", "count"}},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(5)}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(-2)}, at(1)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": uint64(10)}, at(2)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{
+					"value_min":   int64(-2),
+					"value_max":   uint64(10),
+					"value_avg":   float64(13) / 3,
+					"value_count": int64(3),
+				}, at(0)),
+			},
+		},
+		{
+			name:   "first and last by time",
+			plugin: &Downsample{Stats: []string{"first", "last"}},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0}, at(20)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, at(10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 3.0}, at(30)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 0.0}, at(5)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{
+					"value_first": 0.0,
+					"value_last":  3.0,
+				}, at(0)),
+			},
+		},
+		{
+			name:   "multiple series and fields",
+			plugin: &Downsample{Stats: []string{"min", "max"}},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"a": 1.0, "b": 10.0}, at(0)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"a": 5.0}, at(0)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"a": 2.0}, at(1)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"a": 6.0}, at(2)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{
+					"a_min": 1.0,
+					"a_max": 2.0,
+					"b_min": 10.0,
+					"b_max": 10.0,
+				}, at(0)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{
+					"a_min": 5.0,
+					"a_max": 6.0,
+				}, at(0)),
+			},
+		},
+		{
+			name:   "field filter and non-numeric fields",
+			plugin: &Downsample{Stats: []string{"max"}, Fields: []string{"temp*"}},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"temperature": 20.0, "status": "ok"}, at(0)),
+				metric.New("other", map[string]string{}, map[string]interface{}{"counter": int64(1)}, at(1)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"temperature": 22.0, "status": "ok"}, at(2)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"temperature": "n/a"}, at(3)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("other", map[string]string{}, map[string]interface{}{"counter": int64(1)}, at(1)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"temperature": "n/a"}, at(3)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"temperature_max": 22.0}, at(0)),
+			},
+		},
+		{
+			name:   "late metric",
+			plugin: &Downsample{Stats: []string{"count"}},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, at(61)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, at(59)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value_count": int64(1)}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value_count": int64(1)}, at(60)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			if tt.plugin.Window == 0 {
+				tt.plugin.Window = config.Duration(time.Minute)
+			}
+			require.NoError(t, tt.plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, tt.plugin.Start(&acc))
+			for _, m := range tt.input {
+				require.NoError(t, tt.plugin.Add(m, &acc))
+			}
+			tt.plugin.Stop()
+
+			testutil.RequireMetricsEqual(t, tt.expected, acc.GetTelegrafMetrics(), testutil.SortMetrics())
+		})
+	}
+}
+
+func TestEmitIdleWindow(t *testing.T) {
+	plugin := &Downsample{
+		Window: config.Duration(100 * time.Millisecond),
+		Stats:  []string{"count"},
+		Log:    &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	now := time.Now()
+	require.NoError(t, plugin.Add(metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now), &acc))
+	require.NoError(t, plugin.Add(metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0}, now), &acc))
+
+	// The window is emitted without further metrics after it ended
+	acc.Wait(1)
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value_count": int64(2)}, now.Truncate(100*time.Millisecond)),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestTracking(t *testing.T) {
+	base := time.Now().Add(24 * time.Hour).Truncate(time.Minute)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, base),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 3.0}, base.Add(time.Second)),
+		metric.New("other", map[string]string{}, map[string]interface{}{"status": "ok"}, base),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("other", map[string]string{}, map[string]interface{}{"status": "ok"}, base),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value_avg": 2.0}, base),
+	}
+
+	plugin := &Downsample{
+		Window: config.Duration(time.Minute),
+		Stats:  []string{"avg"},
+		Log:    &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	for _, m := range input {
+		require.NoError(t, plugin.Add(m, &acc))
+	}
+	plugin.Stop()
+
+	actual := acc.GetTelegrafMetrics()
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
@@ -0,0 +1,203 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package edge
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Edge struct {
+	Fields       []string        `toml:"fields"`
+	Edge         string          `toml:"edge"`
+	Measurement  string          `toml:"measurement"`
+	DropOriginal bool            `toml:"drop_original"`
+	Expiry       config.Duration `toml:"expiry"`
+	Log          telegraf.Logger `toml:"-"`
+
+	filter     filter.Filter
+	states     map[uint64]map[string]*state
+	seen       map[uint64]time.Time
+	lastExpiry time.Time
+}
+
+// state is the current value of a field of a series and the time the field
+// entered the value
+type state struct {
+	value interface{}
+	since time.Time
+}
+
+func (*Edge) SampleConfig() string {
+	return sampleConfig
+}
+
+func (e *Edge) Init() error {
+	if len(e.Fields) == 0 {
+		return errors.New("no fields configured")
+	}
+	f, err := filter.Compile(e.Fields)
+	if err != nil {
+		return fmt.Errorf("creating field filter failed: %w", err)
+	}
+	e.filter = f
+
+	if e.Edge == "" {
+		e.Edge = "any"
+	}
+	if err := choice.Check(e.Edge, []string{"rising", "falling", "any"}); err != nil {
+		return fmt.Errorf("invalid 'edge': %w", err)
+	}
+	if e.Expiry < 0 {
+		return errors.New("'expiry' must not be negative")
+	}
+
+	e.states = make(map[uint64]map[string]*state)
+	e.seen = make(map[uint64]time.Time)
+
+	return nil
+}
+
+func (e *Edge) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	now := time.Now()
+	e.expire(now)
+
+	out := make([]telegraf.Metric, 0, len(in))
+	for _, m := range in {
+		id := m.HashID()
+		ts := m.Time()
+
+		var events []telegraf.Metric
+		for _, field := range m.FieldList() {
+			if !e.filter.Match(field.Key) {
+				continue
+			}
+
+			states := e.states[id]
+			if states == nil {
+				states = make(map[string]*state)
+				e.states[id] = states
+			}
+			e.seen[id] = now
+
+			// The first value of a field has no known previous state
+			s, found := states[field.Key]
+			if !found {
+				states[field.Key] = &state{value: field.Value, since: ts}
+				continue
+			}
+			if ts.Before(s.since) {
+				e.Log.Debugf("Ignoring out-of-order value of field %q of %q at %v", field.Key, m.Name(), ts)
+				continue
+			}
+			if field.Value == s.value {
+				continue
+			}
+
+			direction := direction(s.value, field.Value)
+			if e.Edge == "any" || e.Edge == direction {
+				events = append(events, e.event(m, field.Key, direction, s, field.Value))
+			}
+			s.value, s.since = field.Value, ts
+		}
+
+		if e.DropOriginal {
+			m.Drop()
+		} else {
+			out = append(out, m)
+		}
+		out = append(out, events...)
+	}
+	return out
+}
+
+// expire removes the states of series without metrics within the expiry
+func (e *Edge) expire(now time.Time) {
+	// No need to check the series too often
+	if e.Expiry <= 0 || now.Sub(e.lastExpiry) < time.Duration(e.Expiry) {
+		return
+	}
+	e.lastExpiry = now
+
+	for id, seen := range e.seen {
+		if now.Sub(seen) >= time.Duration(e.Expiry) {
+			delete(e.states, id)
+			delete(e.seen, id)
+		}
+	}
+}
+
+// event creates the transition event of the field
+func (e *Edge) event(m telegraf.Metric, key, direction string, s *state, value interface{}) telegraf.Metric {
+	name := e.Measurement
+	if name == "" {
+		name = m.Name() + "_edge"
+	}
+
+	tags := m.Tags()
+	tags["field"] = key
+	tags["edge"] = direction
+
+	fields := map[string]interface{}{
+		"previous": s.value,
+		"value":    value,
+		"duration": m.Time().Sub(s.since).Seconds(),
+	}
+	return metric.New(name, tags, fields, m.Time())
+}
+
+// direction returns the direction of the transition, i.e. 'rising' for
+// booleans changing to true or increasing numbers, 'falling' for the
+// opposite and 'change' if the values cannot be ordered
+func direction(previous, current interface{}) string {
+	if p, ok := previous.(bool); ok {
+		if c, ok := current.(bool); ok {
+			if c && !p {
+				return "rising"
+			}
+			return "falling"
+		}
+		return "change"
+	}
+
+	p, pok := toFloat(previous)
+	c, cok := toFloat(current)
+	switch {
+	case !pok || !cok:
+		return "change"
+	case c > p:
+		return "rising"
+	case c < p:
+		return "falling"
+	}
+	return "change"
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func init() {
+	processors.Add("edge", func() telegraf.Processor {
+		return &Edge{Expiry: config.Duration(time.Hour)}
+	})
+}
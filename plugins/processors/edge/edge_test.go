@@ -0,0 +1,279 @@
+package edge
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Edge
+		expected string
+	}{
+		{
+			name:     "no fields",
+			plugin:   &Edge{},
+			expected: "no fields configured",
+		},
+		{
+			name:     "invalid field filter",
+			plugin:   &Edge{Fields: []string{"a[b"}},
+			expected: "creating field filter failed",
+		},
+		{
+			name:     "invalid edge",
+			plugin:   &Edge{Fields: []string{"running"}, Edge: "both"},
+			expected: "invalid 'edge'",
+		},
+		{
+			name:     "negative expiry",
+			plugin:   &Edge{Fields: []string{"running"}, Expiry: config.Duration(-time.Second)},
+			expected: "'expiry' must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	at := func(sec int) time.Time {
+		return base.Add(time.Duration(sec) * time.Second)
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *Edge
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name:   "any edge",
+			plugin: &Edge{Fields: []string{"running"}},
+			input: []telegraf.Metric{
+				metric.New("machine", map[string]string{"id": "1"}, map[string]interface{}{"running": false}, at(0)),
+				metric.New("machine", map[string]string{"id": "1"}, map[string]interface{}{"running": false}, at(5)),
+				metric.New("machine", map[string]string{"id": "1"}, map[string]interface{}{"running": true}, at(10)),
+				metric.New("machine", map[string]string{"id": "1"}, map[string]interface{}{"running": false}, at(40)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("machine", map[string]string{"id": "1"}, map[string]interface{}{"running": false}, at(0)),
+				metric.New("machine", map[string]string{"id": "1"}, map[string]interface{}{"running": false}, at(5)),
+				metric.New("machine", map[string]string{"id": "1"}, map[string]interface{}{"running": true}, at(10)),
+				metric.New("machine_edge",
+					map[string]string{"id": "1", "field": "running", "edge": "rising"},
+					map[string]interface{}{"previous": false, "value": true, "duration": 10.0},
+					at(10),
+				),
+				metric.New("machine", map[string]string{"id": "1"}, map[string]interface{}{"running": false}, at(40)),
+				metric.New("machine_edge",
+					map[string]string{"id": "1", "field": "running", "edge": "falling"},
+					map[string]interface{}{"previous": true, "value": false, "duration": 30.0},
+					at(40),
+				),
+			},
+		},
+		{
+			name:   "rising edge only",
+			plugin: &Edge{Fields: []string{"running"}, Edge: "rising", DropOriginal: true},
+			input: []telegraf.Metric{
+				metric.New("machine", map[string]string{}, map[string]interface{}{"running": true}, at(0)),
+				metric.New("machine", map[string]string{}, map[string]interface{}{"running": false}, at(10)),
+				metric.New("machine", map[string]string{}, map[string]interface{}{"running": true}, at(15)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("machine_edge",
+					map[string]string{"field": "running", "edge": "rising"},
+					map[string]interface{}{"previous": false, "value": true, "duration": 5.0},
+					at(15),
+				),
+			},
+		},
+		{
+			name:   "falling edge of numbers",
+			plugin: &Edge{Fields: []string{"mode"}, Edge: "falling", DropOriginal: true, Measurement: "transitions"},
+			input: []telegraf.Metric{
+				metric.New("machine", map[string]string{}, map[string]interface{}{"mode": int64(1)}, at(0)),
+				metric.New("machine", map[string]string{}, map[string]interface{}{"mode": int64(3)}, at(1)),
+				metric.New("machine", map[string]string{}, map[string]interface{}{"mode": int64(2)}, at(3)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("transitions",
+					map[string]string{"field": "mode", "edge": "falling"},
+					map[string]interface{}{"previous": int64(3), "value": int64(2), "duration": 2.0},
+					at(3),
+				),
+			},
+		},
+		{
+			name:   "state strings",
+			plugin: &Edge{Fields: []string{"state"}, DropOriginal: true},
+			input: []telegraf.Metric{
+				metric.New("machine", map[string]string{}, map[string]interface{}{"state": "idle"}, at(0)),
+				metric.New("machine", map[string]string{}, map[string]interface{}{"state": "running"}, at(60)),
+				metric.New("machine", map[string]string{}, map[string]interface{}{"state": "running"}, at(90)),
+				metric.New("machine", map[string]string{}, map[string]interface{}{"state": "fault"}, at(120)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("machine_edge",
+					map[string]string{"field": "state", "edge": "change"},
+					map[string]interface{}{"previous": "idle", "value": "running", "duration": 60.0},
+					at(60),
+				),
+				metric.New("machine_edge",
+					map[string]string{"field": "state", "edge": "change"},
+					map[string]interface{}{"previous": "running", "value": "fault", "duration": 60.0},
+					at(120),
+				),
+			},
+		},
+		{
+			name:   "independent series and fields",
+			plugin: &Edge{Fields: []string{"a", "b"}, DropOriginal: true},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"a": true, "b": true, "c": 1.0}, at(0)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"a": false}, at(1)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"a": true, "b": false, "c": 2.0}, at(2)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m_edge",
+					map[string]string{"id": "1", "field": "b", "edge": "falling"},
+					map[string]interface{}{"previous": true, "value": false, "duration": 2.0},
+					at(2),
+				),
+			},
+		},
+		{
+			name:   "out of order",
+			plugin: &Edge{Fields: []string{"running"}, DropOriginal: true},
+			input: []telegraf.Metric{
+				metric.New("machine", map[string]string{}, map[string]interface{}{"running": false}, at(10)),
+				metric.New("machine", map[string]string{}, map[string]interface{}{"running": true}, at(5)),
+				metric.New("machine", map[string]string{}, map[string]interface{}{"running": true}, at(20)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("machine_edge",
+					map[string]string{"field": "running", "edge": "rising"},
+					map[string]interface{}{"previous": false, "value": true, "duration": 10.0},
+					at(20),
+				),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			require.NoError(t, tt.plugin.Init())
+
+			actual := tt.plugin.Apply(tt.input...)
+			testutil.RequireMetricsEqual(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	expired := metric.New("machine", map[string]string{"line": "a"}, map[string]interface{}{"running": false}, base)
+	active := metric.New("machine", map[string]string{"line": "b"}, map[string]interface{}{"running": false}, base)
+
+	plugin := &Edge{Fields: []string{"running"}, Expiry: config.Duration(time.Hour), Log: &testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+	plugin.Apply(expired, active)
+	require.Len(t, plugin.states, 2)
+
+	// Pretend the first series did not receive metrics for longer than the
+	// expiry and the last check is due
+	plugin.seen[expired.HashID()] = time.Now().Add(-2 * time.Hour)
+	plugin.lastExpiry = time.Now().Add(-2 * time.Hour)
+
+	input := []telegraf.Metric{
+		metric.New("machine", map[string]string{"line": "b"}, map[string]interface{}{"running": true}, base.Add(time.Second)),
+	}
+	expected := []telegraf.Metric{
+		metric.New("machine", map[string]string{"line": "b"}, map[string]interface{}{"running": true}, base.Add(time.Second)),
+		metric.New("machine_edge",
+			map[string]string{"line": "b", "field": "running", "edge": "rising"},
+			map[string]interface{}{"previous": false, "value": true, "duration": 1.0},
+			base.Add(time.Second),
+		),
+	}
+	testutil.RequireMetricsEqual(t, expected, plugin.Apply(input...))
+	require.Len(t, plugin.states, 1)
+	require.Len(t, plugin.seen, 1)
+
+	// The expired series starts over without an event
+	input = []telegraf.Metric{
+		metric.New("machine", map[string]string{"line": "a"}, map[string]interface{}{"running": true}, base.Add(time.Second)),
+	}
+	expected = []telegraf.Metric{
+		metric.New("machine", map[string]string{"line": "a"}, map[string]interface{}{"running": true}, base.Add(time.Second)),
+	}
+	testutil.RequireMetricsEqual(t, expected, plugin.Apply(input...))
+	require.Len(t, plugin.states, 2)
+}
+
+func TestTracking(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("machine", map[string]string{}, map[string]interface{}{"running": false}, base),
+		metric.New("machine", map[string]string{}, map[string]interface{}{"running": true}, base.Add(time.Second)),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("machine", map[string]string{}, map[string]interface{}{"running": false}, base),
+		metric.New("machine", map[string]string{}, map[string]interface{}{"running": true}, base.Add(time.Second)),
+		metric.New("machine_edge",
+			map[string]string{"field": "running", "edge": "rising"},
+			map[string]interface{}{"previous": false, "value": true, "duration": 1.0},
+			base.Add(time.Second),
+		),
+	}
+
+	plugin := &Edge{Fields: []string{"running"}, Log: &testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+
+	// Process expected metrics and compare with resulting metrics
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
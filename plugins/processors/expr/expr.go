@@ -0,0 +1,304 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package expr
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/ext"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Expr struct {
+	Condition string            `toml:"condition"`
+	Drop      string            `toml:"drop"`
+	Name      string            `toml:"name"`
+	Tags      map[string]string `toml:"tags"`
+	Fields    map[string]string `toml:"fields"`
+	OnError   string            `toml:"on_error"`
+	Log       telegraf.Logger   `toml:"-"`
+
+	env       *cel.Env
+	condition cel.Program
+	drop      cel.Program
+	name      cel.Program
+	tags      []*assignment
+	fields    []*assignment
+}
+
+// assignment is a compiled expression for the value of a tag or field
+type assignment struct {
+	key     string
+	program cel.Program
+}
+
+func (*Expr) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *Expr) Init() error {
+	if p.OnError == "" {
+		p.OnError = "log"
+	}
+	if err := choice.Check(p.OnError, []string{"log", "ignore", "drop"}); err != nil {
+		return fmt.Errorf("invalid 'on_error': %w", err)
+	}
+	if p.Drop == "" && p.Name == "" && len(p.Tags) == 0 && len(p.Fields) == 0 {
+		return errors.New("no drop, name, tags or fields expressions configured")
+	}
+
+	// Declare the computation environment for the expressions
+	env, err := cel.NewEnv(
+		cel.VariableDecls(
+			decls.NewVariable("name", types.StringType),
+			decls.NewVariable("tags", types.NewMapType(types.StringType, types.StringType)),
+			decls.NewVariable("fields", types.NewMapType(types.StringType, types.DynType)),
+			decls.NewVariable("time", types.TimestampType),
+		),
+		cel.Function(
+			"now",
+			cel.Overload("now", nil, cel.TimestampType),
+			cel.SingletonFunctionBinding(func(_ ...ref.Val) ref.Val { return types.Timestamp{Time: time.Now()} }),
+		),
+		ext.Encoders(),
+		ext.Math(),
+		ext.Strings(),
+	)
+	if err != nil {
+		return fmt.Errorf("creating environment failed: %w", err)
+	}
+	p.env = env
+
+	if p.condition, err = p.compile(p.Condition, cel.BoolType); err != nil {
+		return fmt.Errorf("compiling 'condition' failed: %w", err)
+	}
+	if p.drop, err = p.compile(p.Drop, cel.BoolType); err != nil {
+		return fmt.Errorf("compiling 'drop' failed: %w", err)
+	}
+	if p.name, err = p.compile(p.Name, cel.StringType); err != nil {
+		return fmt.Errorf("compiling 'name' failed: %w", err)
+	}
+
+	// Sort the assignments to get a deterministic order of errors
+	p.tags = make([]*assignment, 0, len(p.Tags))
+	for _, key := range sortedKeys(p.Tags) {
+		prog, err := p.compile(p.Tags[key], cel.StringType)
+		if err != nil {
+			return fmt.Errorf("compiling expression of tag %q failed: %w", key, err)
+		}
+		p.tags = append(p.tags, &assignment{key: key, program: prog})
+	}
+	p.fields = make([]*assignment, 0, len(p.Fields))
+	for _, key := range sortedKeys(p.Fields) {
+		prog, err := p.compile(p.Fields[key], nil)
+		if err != nil {
+			return fmt.Errorf("compiling expression of field %q failed: %w", key, err)
+		}
+		p.fields = append(p.fields, &assignment{key: key, program: prog})
+	}
+
+	return nil
+}
+
+func (p *Expr) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	out := in[:0]
+	for _, m := range in {
+		if p.process(m) {
+			out = append(out, m)
+		} else {
+			m.Drop()
+		}
+	}
+	return out
+}
+
+// process evaluates the expressions on the metric and modifies the metric
+// accordingly, returns false if the metric should be dropped
+func (p *Expr) process(m telegraf.Metric) bool {
+	// All expressions are evaluated on the original metric
+	vars := map[string]interface{}{
+		"name":   m.Name(),
+		"tags":   m.Tags(),
+		"fields": m.Fields(),
+		"time":   m.Time(),
+	}
+
+	if p.condition != nil {
+		selected, err := evalBool(p.condition, vars)
+		if err != nil {
+			return p.handleError(m, "condition", err)
+		}
+		if !selected {
+			return true
+		}
+	}
+	if p.drop != nil {
+		drop, err := evalBool(p.drop, vars)
+		if err != nil {
+			if !p.handleError(m, "drop", err) {
+				return false
+			}
+		} else if drop {
+			return false
+		}
+	}
+
+	var name string
+	if p.name != nil {
+		v, err := eval(p.name, vars)
+		if err == nil {
+			name, err = toString(v)
+		}
+		if err != nil && !p.handleError(m, "name", err) {
+			return false
+		}
+	}
+
+	tags := make(map[string]interface{}, len(p.tags))
+	for _, a := range p.tags {
+		v, err := eval(a.program, vars)
+		if err == nil && v != nil {
+			v, err = toString(v)
+		}
+		if err != nil {
+			if !p.handleError(m, "tag "+a.key, err) {
+				return false
+			}
+			continue
+		}
+		tags[a.key] = v
+	}
+
+	fields := make(map[string]interface{}, len(p.fields))
+	for _, a := range p.fields {
+		v, err := eval(a.program, vars)
+		if err == nil && v != nil {
+			v, err = toField(v)
+		}
+		if err != nil {
+			if !p.handleError(m, "field "+a.key, err) {
+				return false
+			}
+			continue
+		}
+		fields[a.key] = v
+	}
+
+	// Modify the metric only after all expressions are evaluated
+	if name != "" {
+		m.SetName(name)
+	}
+	for k, v := range tags {
+		if v == nil {
+			m.RemoveTag(k)
+		} else {
+			m.AddTag(k, v.(string))
+		}
+	}
+	for k, v := range fields {
+		if v == nil {
+			m.RemoveField(k)
+		} else {
+			m.AddField(k, v)
+		}
+	}
+	return true
+}
+
+// handleError handles an evaluation error according to the error behavior,
+// returns false if the metric should be dropped
+func (p *Expr) handleError(m telegraf.Metric, expression string, err error) bool {
+	switch p.OnError {
+	case "log":
+		p.Log.Errorf("Evaluating %s for metric %q failed: %v", expression, m.Name(), err)
+	case "drop":
+		return false
+	}
+	return true
+}
+
+// compile returns the program of the expression checking the result type if
+// given, dynamic results are checked at runtime
+func (p *Expr) compile(expression string, expected *cel.Type) (cel.Program, error) {
+	if expression == "" {
+		return nil, nil
+	}
+	ast, issues := p.env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if expected != nil {
+		output := ast.OutputType()
+		if !output.IsExactType(expected) && !output.IsExactType(cel.DynType) && !output.IsExactType(cel.NullType) {
+			return nil, fmt.Errorf("expression needs to return a %s but returns %s", expected, output)
+		}
+	}
+	return p.env.Program(ast, cel.EvalOptions(cel.OptOptimize))
+}
+
+// eval evaluates the program and returns its result, 'null' is returned as nil
+func eval(prog cel.Program, vars map[string]interface{}) (interface{}, error) {
+	result, _, err := prog.Eval(vars)
+	if err != nil {
+		return nil, err
+	}
+	if result.Type() == types.NullType {
+		return nil, nil
+	}
+	return result.Value(), nil
+}
+
+func evalBool(prog cel.Program, vars map[string]interface{}) (bool, error) {
+	v, err := eval(prog, vars)
+	if err != nil {
+		return false, err
+	}
+	if b, ok := v.(bool); ok {
+		return b, nil
+	}
+	return false, fmt.Errorf("invalid result type %T", v)
+}
+
+func toString(v interface{}) (string, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("invalid result type %T", v)
+}
+
+// toField checks the result of a field expression for supported types
+func toField(v interface{}) (interface{}, error) {
+	switch v.(type) {
+	case int64, uint64, float64, string, bool:
+		return v, nil
+	}
+	return nil, fmt.Errorf("invalid result type %T", v)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	processors.Add("expr", func() telegraf.Processor {
+		return &Expr{}
+	})
+}
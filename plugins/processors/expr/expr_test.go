@@ -0,0 +1,248 @@
+package expr
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Expr
+		expected string
+	}{
+		{
+			name:     "nothing configured",
+			plugin:   &Expr{},
+			expected: "no drop, name, tags or fields expressions configured",
+		},
+		{
+			name:     "invalid error behavior",
+			plugin:   &Expr{Name: "'foo'", OnError: "panic"},
+			expected: "invalid 'on_error'",
+		},
+		{
+			name:     "invalid syntax",
+			plugin:   &Expr{Fields: map[string]string{"a": "fields.b +"}},
+			expected: `compiling expression of field "a" failed`,
+		},
+		{
+			name:     "non-boolean condition",
+			plugin:   &Expr{Condition: "name", Name: "'foo'"},
+			expected: "compiling 'condition' failed: expression needs to return a bool",
+		},
+		{
+			name:     "non-boolean drop",
+			plugin:   &Expr{Drop: "1"},
+			expected: "compiling 'drop' failed: expression needs to return a bool",
+		},
+		{
+			name:     "non-string name",
+			plugin:   &Expr{Name: "1.0"},
+			expected: "compiling 'name' failed: expression needs to return a string",
+		},
+		{
+			name:     "non-string tag",
+			plugin:   &Expr{Tags: map[string]string{"a": "fields.b > 1"}},
+			expected: `compiling expression of tag "a" failed: expression needs to return a string`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		plugin   *Expr
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name: "field math",
+			plugin: &Expr{
+				Fields: map[string]string{
+					"power_kw": "double(fields.power) / 1000.0",
+					"count":    "fields.count + 1u",
+					"ok":       "fields.temp < 80.0",
+					"power":    "null",
+				},
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"power": int64(1500), "count": uint64(1), "temp": 20.5}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"power_kw": 1.5, "count": uint64(2), "temp": 20.5, "ok": true}, now),
+			},
+		},
+		{
+			name: "tags and name",
+			plugin: &Expr{
+				Name: "tags.line + '_' + name",
+				Tags: map[string]string{
+					"state": "fields.temp > 80.0 ? 'hot' : 'ok'",
+					"line":  "null",
+					"unit":  "tags.unit.upperAscii()",
+				},
+			},
+			input: []telegraf.Metric{
+				metric.New("temp", map[string]string{"line": "l1", "unit": "c"}, map[string]interface{}{"temp": 90.0}, now),
+				metric.New("temp", map[string]string{"line": "l2", "unit": "c"}, map[string]interface{}{"temp": 20.0}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("l1_temp", map[string]string{"state": "hot", "unit": "C"}, map[string]interface{}{"temp": 90.0}, now),
+				metric.New("l2_temp", map[string]string{"state": "ok", "unit": "C"}, map[string]interface{}{"temp": 20.0}, now),
+			},
+		},
+		{
+			name: "condition and drop",
+			plugin: &Expr{
+				Condition: "name == 'temp'",
+				Drop:      "fields.value < -50.0",
+				Fields:    map[string]string{"value_f": "fields.value * 1.8 + 32.0"},
+			},
+			input: []telegraf.Metric{
+				metric.New("temp", map[string]string{}, map[string]interface{}{"value": 100.0}, now),
+				metric.New("temp", map[string]string{}, map[string]interface{}{"value": -273.15}, now),
+				metric.New("cpu", map[string]string{}, map[string]interface{}{"value": -100.0}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("temp", map[string]string{}, map[string]interface{}{"value": 100.0, "value_f": 212.0}, now),
+				metric.New("cpu", map[string]string{}, map[string]interface{}{"value": -100.0}, now),
+			},
+		},
+		{
+			name: "time",
+			plugin: &Expr{
+				Fields: map[string]string{"hour": "time.getHours()"},
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0, "hour": int64(22)}, now),
+			},
+		},
+		{
+			name: "skip failing expressions",
+			plugin: &Expr{
+				Fields: map[string]string{
+					"a": "fields.missing + 1",
+					"b": "fields.value * 2.0",
+					"c": "[1, 2]",
+				},
+				OnError: "ignore",
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0, "b": 2.0}, now),
+			},
+		},
+		{
+			name: "drop on error",
+			plugin: &Expr{
+				Fields:  map[string]string{"a": "fields.value + 1.0"},
+				OnError: "drop",
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"other": 1.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": "text"}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0, "a": 2.0}, now),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			require.NoError(t, tt.plugin.Init())
+
+			actual := tt.plugin.Apply(tt.input...)
+			testutil.RequireMetricsEqual(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestTracking(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": -1.0}, now),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0, "double": 2.0}, now),
+	}
+
+	plugin := &Expr{
+		Drop:   "fields.value < 0.0",
+		Fields: map[string]string{"double": "fields.value * 2.0"},
+		Log:    &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// Process expected metrics and compare with resulting metrics
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
+
+func BenchmarkApply(b *testing.B) {
+	plugin := &Expr{
+		Condition: "name == 'power'",
+		Tags:      map[string]string{"state": "fields.value > 1000.0 ? 'high' : 'normal'"},
+		Fields:    map[string]string{"value_kw": "fields.value / 1000.0"},
+		Log:       &testutil.Logger{},
+	}
+	require.NoError(b, plugin.Init())
+
+	m := metric.New("power", map[string]string{"machine": "m1"}, map[string]interface{}{"value": 1500.0}, time.Unix(1700000000, 0))
+	for n := 0; n < b.N; n++ {
+		plugin.Apply(m.Copy())
+	}
+}
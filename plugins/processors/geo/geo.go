@@ -0,0 +1,236 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package geo
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang/geo/s2"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Mean earth radius in meters
+const earthRadius = 6371008.8
+
+type Geo struct {
+	LatField    string          `toml:"lat_field"`
+	LonField    string          `toml:"lon_field"`
+	SpeedField  string          `toml:"speed_field"`
+	GeofenceTag string          `toml:"geofence_tag"`
+	References  []*reference    `toml:"reference"`
+	Geofences   []*geofence     `toml:"geofence"`
+	Log         telegraf.Logger `toml:"-"`
+
+	positions map[uint64]*position
+}
+
+type reference struct {
+	Name string  `toml:"name"`
+	Lat  float64 `toml:"lat"`
+	Lon  float64 `toml:"lon"`
+
+	point s2.LatLng
+}
+
+type geofence struct {
+	Name    string      `toml:"name"`
+	Lat     float64     `toml:"lat"`
+	Lon     float64     `toml:"lon"`
+	Radius  float64     `toml:"radius"`
+	Polygon [][]float64 `toml:"polygon"`
+
+	center s2.LatLng
+	loop   *s2.Loop
+}
+
+// position is the last position of a series used to compute the speed
+type position struct {
+	point s2.LatLng
+	time  time.Time
+}
+
+func (*Geo) SampleConfig() string {
+	return sampleConfig
+}
+
+func (g *Geo) Init() error {
+	if g.LatField == "" {
+		g.LatField = "lat"
+	}
+	if g.LonField == "" {
+		g.LonField = "lon"
+	}
+	if g.GeofenceTag == "" {
+		g.GeofenceTag = "geofence"
+	}
+	if len(g.References) == 0 && len(g.Geofences) == 0 && g.SpeedField == "" {
+		return errors.New("no references, geofences or speed field configured")
+	}
+
+	for i, r := range g.References {
+		if r.Name == "" {
+			return fmt.Errorf("reference %d: missing name", i+1)
+		}
+		p, err := latLng(r.Lat, r.Lon)
+		if err != nil {
+			return fmt.Errorf("reference %q: %w", r.Name, err)
+		}
+		r.point = p
+	}
+	for i, f := range g.Geofences {
+		if f.Name == "" {
+			return fmt.Errorf("geofence %d: missing name", i+1)
+		}
+		if err := f.init(); err != nil {
+			return fmt.Errorf("geofence %q: %w", f.Name, err)
+		}
+	}
+
+	g.positions = make(map[uint64]*position)
+
+	return nil
+}
+
+func (g *Geo) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	for _, m := range in {
+		lat, latOk := field(m, g.LatField)
+		lon, lonOk := field(m, g.LonField)
+		if !latOk || !lonOk {
+			continue
+		}
+		p, err := latLng(lat, lon)
+		if err != nil {
+			g.Log.Debugf("Ignoring position of metric %q: %v", m.Name(), err)
+			continue
+		}
+		// Identify the series before adding the geofence tag
+		id := m.HashID()
+
+		for _, r := range g.References {
+			m.AddField(r.Name+"_distance", distance(p, r.point))
+		}
+
+		var fences []string
+		for _, f := range g.Geofences {
+			if f.contains(p) {
+				fences = append(fences, f.Name)
+			}
+		}
+		if len(fences) > 0 {
+			m.AddTag(g.GeofenceTag, strings.Join(fences, ","))
+		}
+
+		if g.SpeedField != "" {
+			g.speed(m, id, p)
+		}
+	}
+	return in
+}
+
+// speed adds the speed since the last position of the series to the metric
+func (g *Geo) speed(m telegraf.Metric, id uint64, p s2.LatLng) {
+	ts := m.Time()
+
+	last, found := g.positions[id]
+	if !found {
+		g.positions[id] = &position{point: p, time: ts}
+		return
+	}
+	// Ignore metrics older than the last position of the series
+	elapsed := ts.Sub(last.time).Seconds()
+	if elapsed <= 0 {
+		return
+	}
+	m.AddField(g.SpeedField, distance(p, last.point)/elapsed)
+	last.point = p
+	last.time = ts
+}
+
+func (f *geofence) init() error {
+	switch {
+	case f.Radius > 0 && len(f.Polygon) > 0:
+		return errors.New("either radius or polygon must be configured")
+	case f.Radius > 0:
+		p, err := latLng(f.Lat, f.Lon)
+		if err != nil {
+			return err
+		}
+		f.center = p
+	case len(f.Polygon) > 0:
+		if len(f.Polygon) < 3 {
+			return errors.New("polygon requires at least three vertices")
+		}
+		points := make([]s2.Point, 0, len(f.Polygon))
+		for i, v := range f.Polygon {
+			if len(v) != 2 {
+				return fmt.Errorf("vertex %d must be a [lat, lon] pair", i+1)
+			}
+			p, err := latLng(v[0], v[1])
+			if err != nil {
+				return fmt.Errorf("vertex %d: %w", i+1, err)
+			}
+			points = append(points, s2.PointFromLatLng(p))
+		}
+		// Normalize the loop to enclose the smaller area independent of
+		// the orientation of the vertices
+		f.loop = s2.LoopFromPoints(points)
+		f.loop.Normalize()
+	default:
+		return errors.New("positive radius or polygon required")
+	}
+	return nil
+}
+
+// contains checks if the position is inside the geofence
+func (f *geofence) contains(p s2.LatLng) bool {
+	if f.loop != nil {
+		return f.loop.ContainsPoint(s2.PointFromLatLng(p))
+	}
+	return distance(p, f.center) <= f.Radius
+}
+
+// latLng returns the point of the coordinates given in degrees
+func latLng(lat, lon float64) (s2.LatLng, error) {
+	if lat < -90 || lat > 90 {
+		return s2.LatLng{}, fmt.Errorf("latitude %v out of range", lat)
+	}
+	if lon < -180 || lon > 180 {
+		return s2.LatLng{}, fmt.Errorf("longitude %v out of range", lon)
+	}
+	return s2.LatLngFromDegrees(lat, lon), nil
+}
+
+// distance returns the great-circle distance of the points in meters
+func distance(a, b s2.LatLng) float64 {
+	return a.Distance(b).Radians() * earthRadius
+}
+
+func field(m telegraf.Metric, key string) (float64, bool) {
+	value, found := m.GetField(key)
+	if !found {
+		return 0, false
+	}
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func init() {
+	processors.Add("geo", func() telegraf.Processor {
+		return &Geo{}
+	})
+}
@@ -0,0 +1,229 @@
+package geo
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+// Length of one degree on a great circle in meters
+const degree = earthRadius * math.Pi / 180
+
+func TestSampleConfig(t *testing.T) {
+	cfg := config.NewConfig()
+	require.NoError(t, cfg.LoadConfigData(testutil.DefaultSampleConfig((&Geo{}).SampleConfig()), config.EmptySourcePath))
+	require.Len(t, cfg.Processors, 1)
+
+	proc := cfg.Processors[0].Processor.(processors.HasUnwrap)
+	plugin := proc.Unwrap().(*Geo)
+	require.Len(t, plugin.References, 1)
+	require.Len(t, plugin.Geofences, 2)
+	require.Len(t, plugin.Geofences[1].Polygon, 4)
+}
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Geo
+		expected string
+	}{
+		{
+			name:     "nothing configured",
+			plugin:   &Geo{},
+			expected: "no references, geofences or speed field configured",
+		},
+		{
+			name:     "reference without name",
+			plugin:   &Geo{References: []*reference{{Lat: 1}}},
+			expected: "reference 1: missing name",
+		},
+		{
+			name:     "reference out of range",
+			plugin:   &Geo{References: []*reference{{Name: "depot", Lat: 91}}},
+			expected: `reference "depot": latitude 91 out of range`,
+		},
+		{
+			name:     "geofence without name",
+			plugin:   &Geo{Geofences: []*geofence{{Radius: 1}}},
+			expected: "geofence 1: missing name",
+		},
+		{
+			name:     "geofence without shape",
+			plugin:   &Geo{Geofences: []*geofence{{Name: "yard"}}},
+			expected: `geofence "yard": positive radius or polygon required`,
+		},
+		{
+			name: "geofence with both shapes",
+			plugin: &Geo{Geofences: []*geofence{
+				{Name: "yard", Radius: 1, Polygon: [][]float64{{0, 0}, {0, 1}, {1, 1}}},
+			}},
+			expected: `geofence "yard": either radius or polygon must be configured`,
+		},
+		{
+			name:     "polygon too short",
+			plugin:   &Geo{Geofences: []*geofence{{Name: "yard", Polygon: [][]float64{{0, 0}, {0, 1}}}}},
+			expected: `geofence "yard": polygon requires at least three vertices`,
+		},
+		{
+			name:     "invalid vertex",
+			plugin:   &Geo{Geofences: []*geofence{{Name: "yard", Polygon: [][]float64{{0, 0}, {0, 1}, {1}}}}},
+			expected: `geofence "yard": vertex 3 must be a [lat, lon] pair`,
+		},
+		{
+			name:     "vertex out of range",
+			plugin:   &Geo{Geofences: []*geofence{{Name: "yard", Polygon: [][]float64{{0, 0}, {0, 181}, {1, 1}}}}},
+			expected: `geofence "yard": vertex 2: longitude 181 out of range`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		plugin   *Geo
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name:   "distance",
+			plugin: &Geo{References: []*reference{{Name: "origin"}, {Name: "north", Lat: 1}}},
+			input: []telegraf.Metric{
+				metric.New("gps", map[string]string{}, map[string]interface{}{"lat": 0.0, "lon": int64(1)}, now),
+				metric.New("gps", map[string]string{}, map[string]interface{}{"lat": 91.0, "lon": 0.0}, now),
+				metric.New("gps", map[string]string{}, map[string]interface{}{"lat": 0.0}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("gps", map[string]string{}, map[string]interface{}{
+					"lat":             0.0,
+					"lon":             int64(1),
+					"origin_distance": degree,
+					"north_distance":  math.Acos(math.Cos(math.Pi/180)*math.Cos(math.Pi/180)) * earthRadius,
+				}, now),
+				metric.New("gps", map[string]string{}, map[string]interface{}{"lat": 91.0, "lon": 0.0}, now),
+				metric.New("gps", map[string]string{}, map[string]interface{}{"lat": 0.0}, now),
+			},
+		},
+		{
+			name: "geofences",
+			plugin: &Geo{
+				Geofences: []*geofence{
+					{Name: "circle", Radius: 2 * degree},
+					// Vertices in clockwise order
+					{Name: "square", Polygon: [][]float64{{-1, -1}, {1, -1}, {1, 1}, {-1, 1}}},
+				},
+			},
+			input: []telegraf.Metric{
+				metric.New("gps", map[string]string{"truck": "a"}, map[string]interface{}{"lat": 0.0, "lon": 0.5}, now),
+				metric.New("gps", map[string]string{"truck": "a"}, map[string]interface{}{"lat": 0.0, "lon": 1.5}, now),
+				metric.New("gps", map[string]string{"truck": "a"}, map[string]interface{}{"lat": 0.0, "lon": 3.0}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("gps", map[string]string{"truck": "a", "geofence": "circle,square"}, map[string]interface{}{"lat": 0.0, "lon": 0.5}, now),
+				metric.New("gps", map[string]string{"truck": "a", "geofence": "circle"}, map[string]interface{}{"lat": 0.0, "lon": 1.5}, now),
+				metric.New("gps", map[string]string{"truck": "a"}, map[string]interface{}{"lat": 0.0, "lon": 3.0}, now),
+			},
+		},
+		{
+			name: "speed",
+			plugin: &Geo{
+				SpeedField:  "speed",
+				GeofenceTag: "zone",
+				Geofences:   []*geofence{{Name: "yard", Radius: 200}},
+			},
+			input: []telegraf.Metric{
+				metric.New("gps", map[string]string{"truck": "a"}, map[string]interface{}{"lat": 0.0, "lon": 0.0}, now),
+				metric.New("gps", map[string]string{"truck": "b"}, map[string]interface{}{"lat": 0.0, "lon": 0.0}, now),
+				metric.New("gps", map[string]string{"truck": "a"}, map[string]interface{}{"lat": 0.0, "lon": 0.01}, now.Add(100*time.Second)),
+				metric.New("gps", map[string]string{"truck": "a"}, map[string]interface{}{"lat": 0.0, "lon": 0.0}, now.Add(50*time.Second)),
+				metric.New("gps", map[string]string{"truck": "b"}, map[string]interface{}{"lat": 0.0, "lon": 0.0}, now.Add(10*time.Second)),
+				metric.New("gps", map[string]string{"truck": "a"}, map[string]interface{}{"lat": 0.0, "lon": 0.03}, now.Add(200*time.Second)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("gps", map[string]string{"truck": "a", "zone": "yard"}, map[string]interface{}{"lat": 0.0, "lon": 0.0}, now),
+				metric.New("gps", map[string]string{"truck": "b", "zone": "yard"}, map[string]interface{}{"lat": 0.0, "lon": 0.0}, now),
+				metric.New("gps", map[string]string{"truck": "a"}, map[string]interface{}{"lat": 0.0, "lon": 0.01, "speed": 0.01 * degree / 100}, now.Add(100*time.Second)),
+				metric.New("gps", map[string]string{"truck": "a", "zone": "yard"}, map[string]interface{}{"lat": 0.0, "lon": 0.0}, now.Add(50*time.Second)),
+				metric.New("gps", map[string]string{"truck": "b", "zone": "yard"}, map[string]interface{}{"lat": 0.0, "lon": 0.0, "speed": 0.0}, now.Add(10*time.Second)),
+				metric.New("gps", map[string]string{"truck": "a"}, map[string]interface{}{"lat": 0.0, "lon": 0.03, "speed": 0.02 * degree / 100}, now.Add(200*time.Second)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			require.NoError(t, tt.plugin.Init())
+
+			actual := tt.plugin.Apply(tt.input...)
+			testutil.RequireMetricsEqual(t, tt.expected, actual, cmpopts.EquateApprox(1e-9, 1e-6))
+		})
+	}
+}
+
+func TestTracking(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("gps", map[string]string{}, map[string]interface{}{"lat": 0.0, "lon": 0.0}, now),
+		metric.New("gps", map[string]string{}, map[string]interface{}{"lat": 0.0, "lon": 3.0}, now),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("gps", map[string]string{"geofence": "yard"}, map[string]interface{}{"lat": 0.0, "lon": 0.0}, now),
+		metric.New("gps", map[string]string{}, map[string]interface{}{"lat": 0.0, "lon": 3.0}, now),
+	}
+
+	plugin := &Geo{
+		Geofences: []*geofence{{Name: "yard", Radius: 200}},
+		Log:       &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// Process expected metrics and compare with resulting metrics
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
@@ -0,0 +1,258 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package interpolate
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Interpolate struct {
+	Interval config.Duration `toml:"interval"`
+	Method   string          `toml:"method"`
+	Fields   []string        `toml:"fields"`
+	MaxGap   config.Duration `toml:"max_gap"`
+	GapField string          `toml:"gap_field"`
+	Expiry   config.Duration `toml:"expiry"`
+	Log      telegraf.Logger `toml:"-"`
+
+	interval   int64
+	filter     filter.Filter
+	series     map[uint64]map[string]*sample
+	seen       map[uint64]time.Time
+	lastExpiry time.Time
+}
+
+// sample is the last sample of a field of a series
+type sample struct {
+	timestamp int64
+	value     interface{}
+
+	// next grid point to emit
+	next int64
+}
+
+// point is a resampled grid point of a series
+type point struct {
+	fields map[string]interface{}
+	gap    bool
+}
+
+func (*Interpolate) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *Interpolate) Init() error {
+	if p.Interval <= 0 {
+		return errors.New("'interval' must be positive")
+	}
+	if p.MaxGap < 0 {
+		return errors.New("'max_gap' must not be negative")
+	}
+	if p.Expiry < 0 {
+		return errors.New("'expiry' must not be negative")
+	}
+
+	if p.Method == "" {
+		p.Method = "linear"
+	}
+	if err := choice.Check(p.Method, []string{"previous", "linear", "none"}); err != nil {
+		return fmt.Errorf("invalid 'method': %w", err)
+	}
+
+	if len(p.Fields) == 0 {
+		p.Fields = []string{"*"}
+	}
+	f, err := filter.Compile(p.Fields)
+	if err != nil {
+		return fmt.Errorf("creating field filter failed: %w", err)
+	}
+	p.filter = f
+
+	p.interval = int64(p.Interval)
+	p.series = make(map[uint64]map[string]*sample)
+	p.seen = make(map[uint64]time.Time)
+
+	return nil
+}
+
+func (p *Interpolate) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	now := time.Now()
+	p.expire(now)
+
+	out := make([]telegraf.Metric, 0, len(in))
+	for _, m := range in {
+		points, found := p.resample(m, now)
+		if !found {
+			out = append(out, m)
+			continue
+		}
+
+		for _, ts := range slices.Sorted(maps.Keys(points)) {
+			pt := points[ts]
+			if p.GapField != "" {
+				pt.fields[p.GapField] = pt.gap
+			}
+			if len(pt.fields) == 0 {
+				continue
+			}
+			out = append(out, metric.New(m.Name(), m.Tags(), pt.fields, time.Unix(0, ts), m.Type()))
+		}
+		m.Drop()
+	}
+	return out
+}
+
+// resample updates the state of the fields of the series and returns the
+// grid points that became available up to the time of the metric. The
+// function returns false if the metric does not contain any of the fields.
+func (p *Interpolate) resample(m telegraf.Metric, now time.Time) (map[int64]*point, bool) {
+	id := m.HashID()
+	samples := p.series[id]
+
+	ts := m.Time().UnixNano()
+	points := make(map[int64]*point)
+	add := func(grid int64, key string, value interface{}) {
+		pt, found := points[grid]
+		if !found {
+			pt = &point{fields: make(map[string]interface{})}
+			points[grid] = pt
+		}
+		if value == nil {
+			pt.gap = true
+		} else {
+			pt.fields[key] = value
+		}
+	}
+
+	var found bool
+	for _, field := range m.FieldList() {
+		if !p.filter.Match(field.Key) {
+			continue
+		}
+		found = true
+
+		if samples == nil {
+			samples = make(map[string]*sample)
+			p.series[id] = samples
+		}
+		p.seen[id] = now
+
+		last := samples[field.Key]
+		if last == nil {
+			if ts%p.interval == 0 {
+				add(ts, field.Key, field.Value)
+			}
+			samples[field.Key] = &sample{timestamp: ts, value: field.Value, next: p.truncate(ts) + p.interval}
+			continue
+		}
+		if ts <= last.timestamp {
+			p.Log.Debugf("Ignoring out-of-order sample of field %q of %q at %v", field.Key, m.Name(), m.Time())
+			continue
+		}
+
+		for grid := last.next; grid <= ts; grid += p.interval {
+			if grid == ts {
+				add(grid, field.Key, field.Value)
+				break
+			}
+			if v := p.interpolate(last, ts, field.Value, grid); v != nil {
+				add(grid, field.Key, v)
+				continue
+			}
+			add(grid, field.Key, nil)
+
+			// All remaining grid points before the sample are within the
+			// gap, so skip them to avoid iterating over long gaps and only
+			// mark the first grid point of the gap
+			if floor := p.truncate(ts - 1); floor > grid {
+				grid = floor
+			}
+		}
+		last.timestamp, last.value, last.next = ts, field.Value, p.truncate(ts)+p.interval
+	}
+	return points, found
+}
+
+// expire removes the samples of series without metrics within the expiry
+func (p *Interpolate) expire(now time.Time) {
+	// No need to check the series too often
+	if p.Expiry <= 0 || now.Sub(p.lastExpiry) < time.Duration(p.Expiry) {
+		return
+	}
+	p.lastExpiry = now
+
+	for id, seen := range p.seen {
+		if now.Sub(seen) >= time.Duration(p.Expiry) {
+			delete(p.series, id)
+			delete(p.seen, id)
+		}
+	}
+}
+
+// interpolate returns the value at the grid point between the last sample
+// and the current sample or nil if the grid point is within a gap
+func (p *Interpolate) interpolate(last *sample, ts int64, value interface{}, grid int64) interface{} {
+	if p.Method == "none" {
+		if grid-last.timestamp < p.interval {
+			return last.value
+		}
+		return nil
+	}
+
+	if p.MaxGap > 0 && ts-last.timestamp > int64(p.MaxGap) {
+		return nil
+	}
+	if p.Method == "previous" {
+		return last.value
+	}
+
+	// Non-numeric values cannot be interpolated linearly
+	v0, ok0 := toFloat(last.value)
+	v1, ok1 := toFloat(value)
+	if !ok0 || !ok1 {
+		return last.value
+	}
+	ratio := float64(grid-last.timestamp) / float64(ts-last.timestamp)
+	return v0 + (v1-v0)*ratio
+}
+
+// truncate returns the grid point at or before the given timestamp
+func (p *Interpolate) truncate(ts int64) int64 {
+	rem := ts % p.interval
+	if rem < 0 {
+		rem += p.interval
+	}
+	return ts - rem
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func init() {
+	processors.Add("interpolate", func() telegraf.Processor {
+		return &Interpolate{Expiry: config.Duration(time.Hour)}
+	})
+}
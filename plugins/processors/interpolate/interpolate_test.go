@@ -0,0 +1,336 @@
+package interpolate
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Interpolate
+		expected string
+	}{
+		{
+			name:     "missing interval",
+			plugin:   &Interpolate{},
+			expected: "'interval' must be positive",
+		},
+		{
+			name:     "negative max gap",
+			plugin:   &Interpolate{Interval: config.Duration(time.Second), MaxGap: config.Duration(-time.Second)},
+			expected: "'max_gap' must not be negative",
+		},
+		{
+			name:     "negative expiry",
+			plugin:   &Interpolate{Interval: config.Duration(time.Second), Expiry: config.Duration(-time.Second)},
+			expected: "'expiry' must not be negative",
+		},
+		{
+			name:     "invalid method",
+			plugin:   &Interpolate{Interval: config.Duration(time.Second), Method: "spline"},
+			expected: "invalid 'method'",
+		},
+		{
+			name:     "invalid field filter",
+			plugin:   &Interpolate{Interval: config.Duration(time.Second), Fields: []string{"a[b"}},
+			expected: "creating field filter failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	at := func(ms int) time.Time {
+		return base.Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *Interpolate
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name:   "linear",
+			plugin: &Interpolate{Method: "linear"},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"value": 10.0}, at(500)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"value": int64(40)}, at(3500)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"value": 0.0}, at(4000)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"value": 15.0}, at(1000)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"value": 25.0}, at(2000)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"value": 35.0}, at(3000)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"value": 0.0}, at(4000)),
+			},
+		},
+		{
+			name:   "previous",
+			plugin: &Interpolate{Method: "previous"},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(1)}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(2)}, at(200)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(3)}, at(2500)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(4)}, at(3100)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(1)}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(2)}, at(1000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(2)}, at(2000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": int64(3)}, at(3000)),
+			},
+		},
+		{
+			name:   "none",
+			plugin: &Interpolate{Method: "none"},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, at(100)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0}, at(700)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 3.0}, at(3200)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 4.0}, at(4000)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0}, at(1000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 4.0}, at(4000)),
+			},
+		},
+		{
+			name:   "none with gap marking",
+			plugin: &Interpolate{Method: "none", GapField: "gap"},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, at(100)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 3.0}, at(3200)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 4.0}, at(4000)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0, "gap": false}, at(1000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"gap": true}, at(2000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 4.0, "gap": false}, at(4000)),
+			},
+		},
+		{
+			name:   "max gap",
+			plugin: &Interpolate{Method: "linear", MaxGap: config.Duration(2 * time.Second)},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 0.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0}, at(2000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 8.0}, at(500000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 9.0}, at(501000)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 0.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, at(1000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0}, at(2000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 8.0}, at(500000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 9.0}, at(501000)),
+			},
+		},
+		{
+			name:   "max gap with gap marking",
+			plugin: &Interpolate{Method: "previous", MaxGap: config.Duration(1500 * time.Millisecond), GapField: "gap"},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0}, at(2500)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0, "gap": false}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"gap": true}, at(1000)),
+			},
+		},
+		{
+			name:   "large jump with gap marking",
+			plugin: &Interpolate{Method: "linear", MaxGap: config.Duration(5 * time.Second), GapField: "gap"},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, time.Unix(0, 0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 3.0}, at(1000)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0, "gap": false}, time.Unix(0, 0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"gap": true}, time.Unix(1, 0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0, "gap": false}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 3.0, "gap": false}, at(1000)),
+			},
+		},
+		{
+			name:   "non-numeric values",
+			plugin: &Interpolate{Method: "linear"},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "run", "value": 0.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "stop", "value": 4.0}, at(2000)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "run", "value": 0.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "run", "value": 2.0}, at(1000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "stop", "value": 4.0}, at(2000)),
+			},
+		},
+		{
+			name:   "independent fields",
+			plugin: &Interpolate{Method: "previous"},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"a": 1.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"b": 5.0}, at(500)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"a": 2.0, "b": 6.0}, at(2000)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"a": 1.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"a": 1.0, "b": 5.0}, at(1000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"a": 2.0, "b": 6.0}, at(2000)),
+			},
+		},
+		{
+			name:   "field filter",
+			plugin: &Interpolate{Method: "linear", Fields: []string{"temp*"}},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"temperature": 20.0, "status": "ok"}, at(0)),
+				metric.New("other", map[string]string{}, map[string]interface{}{"status": "ok"}, at(300)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"temperature": 22.0, "status": "ok"}, at(1000)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"temperature": 20.0}, at(0)),
+				metric.New("other", map[string]string{}, map[string]interface{}{"status": "ok"}, at(300)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"temperature": 22.0}, at(1000)),
+			},
+		},
+		{
+			name:   "out of order",
+			plugin: &Interpolate{Method: "previous"},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0}, at(1500)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 3.0}, at(1200)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 4.0}, at(2000)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, at(1000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 4.0}, at(2000)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			if tt.plugin.Interval == 0 {
+				tt.plugin.Interval = config.Duration(time.Second)
+			}
+			require.NoError(t, tt.plugin.Init())
+
+			actual := tt.plugin.Apply(tt.input...)
+			testutil.RequireMetricsEqual(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	expired := metric.New("m", map[string]string{"host": "a"}, map[string]interface{}{"value": 1.0}, base)
+	active := metric.New("m", map[string]string{"host": "b"}, map[string]interface{}{"value": 1.0}, base)
+
+	plugin := &Interpolate{
+		Interval: config.Duration(time.Second),
+		Expiry:   config.Duration(time.Hour),
+		Log:      &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	plugin.Apply(expired, active)
+	require.Len(t, plugin.series, 2)
+
+	// Pretend the first series did not receive metrics for longer than the
+	// expiry and the last check is due
+	plugin.seen[expired.HashID()] = time.Now().Add(-2 * time.Hour)
+	plugin.lastExpiry = time.Now().Add(-2 * time.Hour)
+
+	input := []telegraf.Metric{
+		metric.New("m", map[string]string{"host": "b"}, map[string]interface{}{"value": 3.0}, base.Add(2*time.Second)),
+	}
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{"host": "b"}, map[string]interface{}{"value": 2.0}, base.Add(time.Second)),
+		metric.New("m", map[string]string{"host": "b"}, map[string]interface{}{"value": 3.0}, base.Add(2*time.Second)),
+	}
+	testutil.RequireMetricsEqual(t, expected, plugin.Apply(input...))
+	require.Len(t, plugin.series, 1)
+	require.Len(t, plugin.seen, 1)
+
+	// The expired series starts over without the grid points in between
+	input = []telegraf.Metric{
+		metric.New("m", map[string]string{"host": "a"}, map[string]interface{}{"value": 3.0}, base.Add(2*time.Second)),
+	}
+	expected = []telegraf.Metric{
+		metric.New("m", map[string]string{"host": "a"}, map[string]interface{}{"value": 3.0}, base.Add(2*time.Second)),
+	}
+	testutil.RequireMetricsEqual(t, expected, plugin.Apply(input...))
+	require.Len(t, plugin.series, 2)
+}
+
+func TestTracking(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, base),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 3.0}, base.Add(2*time.Second)),
+		metric.New("other", map[string]string{}, map[string]interface{}{"value": 1.0}, base),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, base),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0}, base.Add(time.Second)),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 3.0}, base.Add(2*time.Second)),
+		metric.New("other", map[string]string{}, map[string]interface{}{"value": 1.0}, base),
+	}
+
+	plugin := &Interpolate{
+		Interval: config.Duration(time.Second),
+		Fields:   []string{"value"},
+		Log:      &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// Process expected metrics and compare with resulting metrics
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
@@ -0,0 +1,276 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package join
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Join struct {
+	Measurements   []string        `toml:"measurements"`
+	Tags           []string        `toml:"tags"`
+	Window         config.Duration `toml:"window"`
+	Measurement    string          `toml:"measurement"`
+	PrefixFields   bool            `toml:"prefix_fields"`
+	Timeout        config.Duration `toml:"timeout"`
+	EmitIncomplete bool            `toml:"emit_incomplete"`
+	KeepOriginal   bool            `toml:"keep_original"`
+	Log            telegraf.Logger `toml:"-"`
+
+	measurements map[string]bool
+	acc          telegraf.Accumulator
+	groups       map[string]*group
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+	sync.Mutex
+}
+
+// part is the data of a metric of one of the joined measurements
+type part struct {
+	tags   map[string]string
+	fields map[string]interface{}
+	time   time.Time
+}
+
+// group collects the metrics of the joined measurements with the same
+// values of the join tags
+type group struct {
+	parts    map[string]*part
+	earliest time.Time
+	latest   time.Time
+	expires  time.Time
+}
+
+func (*Join) SampleConfig() string {
+	return sampleConfig
+}
+
+func (j *Join) Init() error {
+	if len(j.Measurements) < 2 {
+		return errors.New("at least two measurements required")
+	}
+	j.measurements = make(map[string]bool, len(j.Measurements))
+	for _, name := range j.Measurements {
+		if j.measurements[name] {
+			return fmt.Errorf("duplicate measurement %q", name)
+		}
+		j.measurements[name] = true
+	}
+	if len(j.Tags) == 0 {
+		return errors.New("no tags configured")
+	}
+	if j.Window < 0 {
+		return errors.New("'window' must not be negative")
+	}
+	if j.Timeout < 0 {
+		return errors.New("'timeout' must not be negative")
+	}
+	if j.Timeout == 0 {
+		j.Timeout = j.Window
+	}
+	if j.Measurement == "" {
+		j.Measurement = "join"
+	}
+
+	j.groups = make(map[string]*group)
+
+	return nil
+}
+
+func (j *Join) Start(acc telegraf.Accumulator) error {
+	j.acc = acc
+
+	// Check for expired groups regularly but at least once per timeout
+	interval := time.Second
+	if j.Timeout > 0 {
+		interval = min(time.Duration(j.Timeout), interval)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				j.expire(time.Now())
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (j *Join) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
+	if !j.measurements[m.Name()] {
+		acc.AddMetric(m)
+		return nil
+	}
+	key, found := j.key(m)
+	if !found {
+		acc.AddMetric(m)
+		return nil
+	}
+
+	p := &part{
+		tags:   m.Tags(),
+		fields: m.Fields(),
+		time:   m.Time(),
+	}
+	if j.KeepOriginal {
+		acc.AddMetric(m)
+	} else {
+		m.Drop()
+	}
+
+	j.Lock()
+	defer j.Unlock()
+
+	// Metrics which do not fit into the pending group start a new group
+	g, found := j.groups[key]
+	if found && !j.fits(g, m.Name(), p.time) {
+		j.complete(acc, g)
+		found = false
+	}
+	if !found {
+		g = &group{
+			parts:    make(map[string]*part, len(j.Measurements)),
+			earliest: p.time,
+			latest:   p.time,
+			expires:  time.Now().Add(time.Duration(j.Timeout)),
+		}
+		j.groups[key] = g
+	}
+	g.parts[m.Name()] = p
+	if p.time.Before(g.earliest) {
+		g.earliest = p.time
+	}
+	if p.time.After(g.latest) {
+		g.latest = p.time
+	}
+
+	if len(g.parts) == len(j.Measurements) {
+		acc.AddMetric(j.join(g))
+		delete(j.groups, key)
+	}
+
+	return nil
+}
+
+func (j *Join) Stop() {
+	if j.cancel != nil {
+		j.cancel()
+	}
+	j.wg.Wait()
+
+	j.Lock()
+	defer j.Unlock()
+	for key, g := range j.groups {
+		j.complete(j.acc, g)
+		delete(j.groups, key)
+	}
+}
+
+// expire handles the groups not completed within the timeout
+func (j *Join) expire(now time.Time) {
+	j.Lock()
+	defer j.Unlock()
+	for key, g := range j.groups {
+		if now.Before(g.expires) {
+			continue
+		}
+		j.complete(j.acc, g)
+		delete(j.groups, key)
+	}
+}
+
+// complete handles a group which will not receive further metrics
+func (j *Join) complete(acc telegraf.Accumulator, g *group) {
+	if len(g.parts) == len(j.Measurements) || j.EmitIncomplete {
+		acc.AddMetric(j.join(g))
+		return
+	}
+	j.Log.Debugf("Discarding incomplete group with %d of %d measurements at %v", len(g.parts), len(j.Measurements), g.latest)
+}
+
+// key returns the values of the join tags of the metric
+func (j *Join) key(m telegraf.Metric) (string, bool) {
+	values := make([]string, 0, len(j.Tags))
+	for _, k := range j.Tags {
+		v, found := m.GetTag(k)
+		if !found {
+			return "", false
+		}
+		values = append(values, v)
+	}
+	return strings.Join(values, "\x00"), true
+}
+
+// fits checks if the metric can be added to the group, i.e. the group does
+// not already contain a metric of the measurement and the timestamps are
+// within the window
+func (j *Join) fits(g *group, name string, ts time.Time) bool {
+	if _, found := g.parts[name]; found {
+		return false
+	}
+	window := time.Duration(j.Window)
+	return ts.Sub(g.earliest) <= window && g.latest.Sub(ts) <= window
+}
+
+// join creates the joined metric of the group containing the tags with
+// identical values in all metrics and the fields of all metrics
+func (j *Join) join(g *group) telegraf.Metric {
+	var tags map[string]string
+	fields := make(map[string]interface{})
+	for _, name := range j.Measurements {
+		p, found := g.parts[name]
+		if !found {
+			continue
+		}
+
+		if tags == nil {
+			tags = p.tags
+		} else {
+			for k, v := range tags {
+				if p.tags[k] != v {
+					delete(tags, k)
+				}
+			}
+		}
+
+		for k, v := range p.fields {
+			if j.PrefixFields {
+				k = name + "_" + k
+			}
+			fields[k] = v
+		}
+	}
+	return metric.New(j.Measurement, tags, fields, g.latest)
+}
+
+func init() {
+	processors.AddStreaming("join", func() telegraf.StreamingProcessor {
+		return &Join{
+			Window:       config.Duration(time.Second),
+			PrefixFields: true,
+		}
+	})
+}
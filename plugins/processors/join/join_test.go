@@ -0,0 +1,305 @@
+package join
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Join
+		expected string
+	}{
+		{
+			name:     "single measurement",
+			plugin:   &Join{Measurements: []string{"power"}, Tags: []string{"machine"}},
+			expected: "at least two measurements required",
+		},
+		{
+			name:     "duplicate measurement",
+			plugin:   &Join{Measurements: []string{"power", "power"}, Tags: []string{"machine"}},
+			expected: `duplicate measurement "power"`,
+		},
+		{
+			name:     "no tags",
+			plugin:   &Join{Measurements: []string{"power", "flow"}},
+			expected: "no tags configured",
+		},
+		{
+			name:     "negative window",
+			plugin:   &Join{Measurements: []string{"power", "flow"}, Tags: []string{"machine"}, Window: config.Duration(-time.Second)},
+			expected: "'window' must not be negative",
+		},
+		{
+			name:     "negative timeout",
+			plugin:   &Join{Measurements: []string{"power", "flow"}, Tags: []string{"machine"}, Timeout: config.Duration(-time.Second)},
+			expected: "'timeout' must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	at := func(ms int) time.Time {
+		return base.Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *Join
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name: "join",
+			plugin: &Join{
+				Measurements: []string{"power", "flow"},
+				Tags:         []string{"machine"},
+				PrefixFields: true,
+			},
+			input: []telegraf.Metric{
+				metric.New("power", map[string]string{"machine": "m1", "unit": "kW"}, map[string]interface{}{"value": 12.5}, at(0)),
+				metric.New("power", map[string]string{"machine": "m2", "unit": "kW"}, map[string]interface{}{"value": 3.0}, at(100)),
+				metric.New("cpu", map[string]string{"machine": "m1"}, map[string]interface{}{"usage": 5.0}, at(150)),
+				metric.New("flow", map[string]string{"machine": "m1", "unit": "m3/h"}, map[string]interface{}{"value": 2.5}, at(300)),
+				metric.New("flow", map[string]string{"machine": "m2", "unit": "m3/h"}, map[string]interface{}{"value": 1.5}, at(500)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("cpu", map[string]string{"machine": "m1"}, map[string]interface{}{"usage": 5.0}, at(150)),
+				metric.New("join", map[string]string{"machine": "m1"}, map[string]interface{}{"power_value": 12.5, "flow_value": 2.5}, at(300)),
+				metric.New("join", map[string]string{"machine": "m2"}, map[string]interface{}{"power_value": 3.0, "flow_value": 1.5}, at(500)),
+			},
+		},
+		{
+			name: "without prefix and with common tags",
+			plugin: &Join{
+				Measurements: []string{"power", "flow"},
+				Tags:         []string{"machine"},
+				Measurement:  "energy",
+			},
+			input: []telegraf.Metric{
+				metric.New("power", map[string]string{"machine": "m1", "site": "a"}, map[string]interface{}{"power": 12.5}, at(0)),
+				metric.New("flow", map[string]string{"machine": "m1", "site": "a"}, map[string]interface{}{"flow": 2.5}, at(300)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("energy", map[string]string{"machine": "m1", "site": "a"}, map[string]interface{}{"power": 12.5, "flow": 2.5}, at(300)),
+			},
+		},
+		{
+			name: "outside window",
+			plugin: &Join{
+				Measurements: []string{"power", "flow"},
+				Tags:         []string{"machine"},
+				PrefixFields: true,
+			},
+			input: []telegraf.Metric{
+				metric.New("power", map[string]string{"machine": "m1"}, map[string]interface{}{"value": 1.0}, at(0)),
+				metric.New("flow", map[string]string{"machine": "m1"}, map[string]interface{}{"value": 2.0}, at(1500)),
+				metric.New("power", map[string]string{"machine": "m1"}, map[string]interface{}{"value": 3.0}, at(2000)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("join", map[string]string{"machine": "m1"}, map[string]interface{}{"power_value": 3.0, "flow_value": 2.0}, at(2000)),
+			},
+		},
+		{
+			name: "repeated measurement",
+			plugin: &Join{
+				Measurements:   []string{"power", "flow"},
+				Tags:           []string{"machine"},
+				PrefixFields:   true,
+				EmitIncomplete: true,
+			},
+			input: []telegraf.Metric{
+				metric.New("power", map[string]string{"machine": "m1"}, map[string]interface{}{"value": 1.0}, at(0)),
+				metric.New("power", map[string]string{"machine": "m1"}, map[string]interface{}{"value": 2.0}, at(100)),
+				metric.New("flow", map[string]string{"machine": "m1"}, map[string]interface{}{"value": 3.0}, at(200)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("join", map[string]string{"machine": "m1"}, map[string]interface{}{"power_value": 1.0}, at(0)),
+				metric.New("join", map[string]string{"machine": "m1"}, map[string]interface{}{"power_value": 2.0, "flow_value": 3.0}, at(200)),
+			},
+		},
+		{
+			name: "multiple tags",
+			plugin: &Join{
+				Measurements: []string{"a", "b", "c"},
+				Tags:         []string{"line", "station"},
+				PrefixFields: true,
+			},
+			input: []telegraf.Metric{
+				metric.New("a", map[string]string{"line": "1", "station": "1"}, map[string]interface{}{"v": 1.0}, at(0)),
+				metric.New("b", map[string]string{"line": "1", "station": "2"}, map[string]interface{}{"v": 2.0}, at(0)),
+				metric.New("b", map[string]string{"line": "1", "station": "1"}, map[string]interface{}{"v": 3.0}, at(0)),
+				metric.New("c", map[string]string{"line": "1"}, map[string]interface{}{"v": 4.0}, at(0)),
+				metric.New("c", map[string]string{"line": "1", "station": "1"}, map[string]interface{}{"v": 5.0}, at(0)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("c", map[string]string{"line": "1"}, map[string]interface{}{"v": 4.0}, at(0)),
+				metric.New("join", map[string]string{"line": "1", "station": "1"}, map[string]interface{}{"a_v": 1.0, "b_v": 3.0, "c_v": 5.0}, at(0)),
+			},
+		},
+		{
+			name: "keep original",
+			plugin: &Join{
+				Measurements: []string{"power", "flow"},
+				Tags:         []string{"machine"},
+				PrefixFields: true,
+				KeepOriginal: true,
+			},
+			input: []telegraf.Metric{
+				metric.New("power", map[string]string{"machine": "m1"}, map[string]interface{}{"value": 1.0}, at(0)),
+				metric.New("flow", map[string]string{"machine": "m1"}, map[string]interface{}{"value": 2.0}, at(0)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("power", map[string]string{"machine": "m1"}, map[string]interface{}{"value": 1.0}, at(0)),
+				metric.New("flow", map[string]string{"machine": "m1"}, map[string]interface{}{"value": 2.0}, at(0)),
+				metric.New("join", map[string]string{"machine": "m1"}, map[string]interface{}{"power_value": 1.0, "flow_value": 2.0}, at(0)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			if tt.plugin.Window == 0 {
+				tt.plugin.Window = config.Duration(time.Second)
+			}
+			// Avoid timeouts during the test
+			tt.plugin.Timeout = config.Duration(time.Hour)
+			require.NoError(t, tt.plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, tt.plugin.Start(&acc))
+			for _, m := range tt.input {
+				require.NoError(t, tt.plugin.Add(m, &acc))
+			}
+			tt.plugin.Stop()
+
+			testutil.RequireMetricsEqual(t, tt.expected, acc.GetTelegrafMetrics())
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	tests := []struct {
+		name           string
+		emitIncomplete bool
+		expected       []telegraf.Metric
+	}{
+		{
+			name: "discard incomplete",
+		},
+		{
+			name:           "emit incomplete",
+			emitIncomplete: true,
+			expected: []telegraf.Metric{
+				metric.New("join", map[string]string{"machine": "m1"}, map[string]interface{}{"power_value": 1.0}, time.Unix(1700000000, 0)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &Join{
+				Measurements:   []string{"power", "flow"},
+				Tags:           []string{"machine"},
+				Window:         config.Duration(time.Second),
+				Timeout:        config.Duration(50 * time.Millisecond),
+				PrefixFields:   true,
+				EmitIncomplete: tt.emitIncomplete,
+				Log:            &testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, plugin.Start(&acc))
+			defer plugin.Stop()
+
+			m := metric.New("power", map[string]string{"machine": "m1"}, map[string]interface{}{"value": 1.0}, time.Unix(1700000000, 0))
+			require.NoError(t, plugin.Add(m, &acc))
+
+			// The group must be removed after the timeout
+			require.Eventually(t, func() bool {
+				plugin.Lock()
+				defer plugin.Unlock()
+				return len(plugin.groups) == 0
+			}, time.Second, 10*time.Millisecond)
+			testutil.RequireMetricsEqual(t, tt.expected, acc.GetTelegrafMetrics())
+		})
+	}
+}
+
+func TestTracking(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("power", map[string]string{"machine": "m1"}, map[string]interface{}{"value": 1.0}, base),
+		metric.New("cpu", map[string]string{"machine": "m1"}, map[string]interface{}{"usage": 5.0}, base),
+		metric.New("flow", map[string]string{"machine": "m1"}, map[string]interface{}{"value": 2.0}, base),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("cpu", map[string]string{"machine": "m1"}, map[string]interface{}{"usage": 5.0}, base),
+		metric.New("join", map[string]string{"machine": "m1"}, map[string]interface{}{"power_value": 1.0, "flow_value": 2.0}, base),
+	}
+
+	plugin := &Join{
+		Measurements: []string{"power", "flow"},
+		Tags:         []string{"machine"},
+		Window:       config.Duration(time.Second),
+		PrefixFields: true,
+		Log:          &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	for _, m := range input {
+		require.NoError(t, plugin.Add(m, &acc))
+	}
+	plugin.Stop()
+
+	actual := acc.GetTelegrafMetrics()
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
@@ -0,0 +1,281 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package limit_check
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Severities ordered from low to high values
+const (
+	lowLow   = -2
+	low      = -1
+	normal   = 0
+	high     = 1
+	highHigh = 2
+)
+
+var severities = map[int]string{
+	lowLow:   "low_low",
+	low:      "low",
+	normal:   "normal",
+	high:     "high",
+	highHigh: "high_high",
+}
+
+type LimitCheck struct {
+	Mode             string          `toml:"mode"`
+	AlarmMeasurement string          `toml:"alarm_measurement"`
+	LimitsFile       string          `toml:"limits_file"`
+	Limits           []*limit        `toml:"limit"`
+	Log              telegraf.Logger `toml:"-"`
+
+	severities map[uint64]map[string]int
+}
+
+type limit struct {
+	Measurements []string `toml:"measurements"`
+	Fields       []string `toml:"fields"`
+	LowLow       *float64 `toml:"low_low"`
+	Low          *float64 `toml:"low"`
+	High         *float64 `toml:"high"`
+	HighHigh     *float64 `toml:"high_high"`
+	Hysteresis   float64  `toml:"hysteresis"`
+
+	measurementFilter filter.Filter
+	fieldFilter       filter.Filter
+}
+
+func (*LimitCheck) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *LimitCheck) Init() error {
+	if p.Mode == "" {
+		p.Mode = "tag"
+	}
+	if err := choice.Check(p.Mode, []string{"tag", "alarm", "both"}); err != nil {
+		return fmt.Errorf("invalid 'mode': %w", err)
+	}
+	if p.AlarmMeasurement == "" {
+		p.AlarmMeasurement = "limit_alarm"
+	}
+
+	if p.LimitsFile != "" {
+		buf, err := os.ReadFile(p.LimitsFile)
+		if err != nil {
+			return fmt.Errorf("reading limits file failed: %w", err)
+		}
+		var file struct {
+			Limits []*limit `toml:"limit"`
+		}
+		if err := toml.Unmarshal(buf, &file); err != nil {
+			return fmt.Errorf("parsing limits file failed: %w", err)
+		}
+		p.Limits = append(p.Limits, file.Limits...)
+	}
+	if len(p.Limits) == 0 {
+		return errors.New("no limits configured")
+	}
+	for i, l := range p.Limits {
+		if err := l.init(); err != nil {
+			return fmt.Errorf("limit %d: %w", i+1, err)
+		}
+	}
+
+	p.severities = make(map[uint64]map[string]int)
+
+	return nil
+}
+
+func (p *LimitCheck) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	out := make([]telegraf.Metric, 0, len(in))
+	for _, m := range in {
+		id := m.HashID()
+
+		tags := make(map[string]string)
+		var alarms []telegraf.Metric
+		for _, field := range m.FieldList() {
+			value, ok := toFloat(field.Value)
+			if !ok {
+				continue
+			}
+			l := p.limit(m.Name(), field.Key)
+			if l == nil {
+				continue
+			}
+
+			states := p.severities[id]
+			if states == nil {
+				states = make(map[string]int)
+				p.severities[id] = states
+			}
+			previous, found := states[field.Key]
+			severity := l.evaluate(value, previous)
+			states[field.Key] = severity
+
+			if p.Mode != "alarm" {
+				tags[field.Key+"_severity"] = severities[severity]
+			}
+			if p.Mode != "tag" && severity != previous {
+				alarms = append(alarms, p.alarm(m, field.Key, value, l, severity, previous, found))
+			}
+		}
+
+		for k, v := range tags {
+			m.AddTag(k, v)
+		}
+		out = append(out, m)
+		out = append(out, alarms...)
+	}
+	return out
+}
+
+// limit returns the first limit applying to the field of the measurement
+func (p *LimitCheck) limit(name, field string) *limit {
+	for _, l := range p.Limits {
+		if l.measurementFilter.Match(name) && l.fieldFilter.Match(field) {
+			return l
+		}
+	}
+	return nil
+}
+
+// alarm creates the alarm metric for the severity change of the field
+func (p *LimitCheck) alarm(m telegraf.Metric, key string, value float64, l *limit, severity, previous int, known bool) telegraf.Metric {
+	tags := m.Tags()
+	tags["measurement"] = m.Name()
+	tags["field"] = key
+	tags["severity"] = severities[severity]
+
+	fields := map[string]interface{}{"value": value}
+	if known {
+		fields["previous_severity"] = severities[previous]
+	}
+	if threshold := l.threshold(severity); threshold != nil {
+		fields["limit"] = *threshold
+	}
+	return metric.New(p.AlarmMeasurement, tags, fields, m.Time())
+}
+
+func (l *limit) init() error {
+	if len(l.Fields) == 0 {
+		return errors.New("no fields configured")
+	}
+	f, err := filter.Compile(l.Fields)
+	if err != nil {
+		return fmt.Errorf("creating field filter failed: %w", err)
+	}
+	l.fieldFilter = f
+
+	if len(l.Measurements) == 0 {
+		l.Measurements = []string{"*"}
+	}
+	f, err = filter.Compile(l.Measurements)
+	if err != nil {
+		return fmt.Errorf("creating measurement filter failed: %w", err)
+	}
+	l.measurementFilter = f
+
+	if l.LowLow == nil && l.Low == nil && l.High == nil && l.HighHigh == nil {
+		return errors.New("no thresholds configured")
+	}
+	if l.Hysteresis < 0 {
+		return errors.New("'hysteresis' must not be negative")
+	}
+
+	// Thresholds must be ordered from low-low to high-high
+	var last *float64
+	for _, v := range []*float64{l.LowLow, l.Low, l.High, l.HighHigh} {
+		if v == nil {
+			continue
+		}
+		if last != nil && *v <= *last {
+			return errors.New("thresholds must be increasing from 'low_low' to 'high_high'")
+		}
+		last = v
+	}
+
+	return nil
+}
+
+// evaluate returns the severity of the value, thresholds of the current
+// severity are shifted by the hysteresis to avoid flapping
+func (l *limit) evaluate(value float64, current int) int {
+	exceeds := func(threshold *float64, severity int) bool {
+		if threshold == nil {
+			return false
+		}
+		if current >= severity {
+			return value >= *threshold-l.Hysteresis
+		}
+		return value >= *threshold
+	}
+	falls := func(threshold *float64, severity int) bool {
+		if threshold == nil {
+			return false
+		}
+		if current <= severity {
+			return value <= *threshold+l.Hysteresis
+		}
+		return value <= *threshold
+	}
+
+	switch {
+	case exceeds(l.HighHigh, highHigh):
+		return highHigh
+	case exceeds(l.High, high):
+		return high
+	case falls(l.LowLow, lowLow):
+		return lowLow
+	case falls(l.Low, low):
+		return low
+	}
+	return normal
+}
+
+// threshold returns the threshold of the severity
+func (l *limit) threshold(severity int) *float64 {
+	switch severity {
+	case lowLow:
+		return l.LowLow
+	case low:
+		return l.Low
+	case high:
+		return l.High
+	case highHigh:
+		return l.HighHigh
+	}
+	return nil
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func init() {
+	processors.Add("limit_check", func() telegraf.Processor {
+		return &LimitCheck{}
+	})
+}
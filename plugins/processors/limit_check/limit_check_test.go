@@ -0,0 +1,299 @@
+package limit_check
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestSampleConfig(t *testing.T) {
+	cfg := config.NewConfig()
+	require.NoError(t, cfg.LoadConfigData(testutil.DefaultSampleConfig((&LimitCheck{}).SampleConfig()), config.EmptySourcePath))
+	require.Len(t, cfg.Processors, 1)
+
+	proc := cfg.Processors[0].Processor.(processors.HasUnwrap)
+	plugin := proc.Unwrap().(*LimitCheck)
+	require.Len(t, plugin.Limits, 1)
+	require.NotNil(t, plugin.Limits[0].High)
+	require.InDelta(t, 80.0, *plugin.Limits[0].High, 1e-9)
+}
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *LimitCheck
+		expected string
+	}{
+		{
+			name:     "invalid mode",
+			plugin:   &LimitCheck{Mode: "log"},
+			expected: "invalid 'mode'",
+		},
+		{
+			name:     "missing limits file",
+			plugin:   &LimitCheck{LimitsFile: "testdata/non_existing.toml"},
+			expected: "reading limits file failed",
+		},
+		{
+			name:     "no limits",
+			plugin:   &LimitCheck{},
+			expected: "no limits configured",
+		},
+		{
+			name:     "no fields",
+			plugin:   &LimitCheck{Limits: []*limit{{High: ptr(1.0)}}},
+			expected: "limit 1: no fields configured",
+		},
+		{
+			name:     "invalid field filter",
+			plugin:   &LimitCheck{Limits: []*limit{{Fields: []string{"a[b"}, High: ptr(1.0)}}},
+			expected: "limit 1: creating field filter failed",
+		},
+		{
+			name:     "invalid measurement filter",
+			plugin:   &LimitCheck{Limits: []*limit{{Measurements: []string{"a[b"}, Fields: []string{"a"}, High: ptr(1.0)}}},
+			expected: "limit 1: creating measurement filter failed",
+		},
+		{
+			name:     "no thresholds",
+			plugin:   &LimitCheck{Limits: []*limit{{Fields: []string{"a"}}}},
+			expected: "limit 1: no thresholds configured",
+		},
+		{
+			name:     "negative hysteresis",
+			plugin:   &LimitCheck{Limits: []*limit{{Fields: []string{"a"}, High: ptr(1.0), Hysteresis: -1}}},
+			expected: "limit 1: 'hysteresis' must not be negative",
+		},
+		{
+			name: "unordered thresholds",
+			plugin: &LimitCheck{Limits: []*limit{
+				{Fields: []string{"a"}, High: ptr(1.0)},
+				{Fields: []string{"b"}, Low: ptr(5.0), High: ptr(1.0)},
+			}},
+			expected: "limit 2: thresholds must be increasing from 'low_low' to 'high_high'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name     string
+		plugin   *LimitCheck
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name: "severity tags",
+			plugin: &LimitCheck{
+				Limits: []*limit{{
+					Fields:   []string{"temp"},
+					LowLow:   ptr(0.0),
+					Low:      ptr(10.0),
+					High:     ptr(80.0),
+					HighHigh: ptr(95.0),
+				}},
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"temp": -5.0, "state": "run"}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"temp": int64(10)}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"temp": 50.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"temp": uint64(80)}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"temp": 99.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"temp": "n/a"}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"pressure": 99.0}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{"temp_severity": "low_low"}, map[string]interface{}{"temp": -5.0, "state": "run"}, now),
+				metric.New("m", map[string]string{"temp_severity": "low"}, map[string]interface{}{"temp": int64(10)}, now),
+				metric.New("m", map[string]string{"temp_severity": "normal"}, map[string]interface{}{"temp": 50.0}, now),
+				metric.New("m", map[string]string{"temp_severity": "high"}, map[string]interface{}{"temp": uint64(80)}, now),
+				metric.New("m", map[string]string{"temp_severity": "high_high"}, map[string]interface{}{"temp": 99.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"temp": "n/a"}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"pressure": 99.0}, now),
+			},
+		},
+		{
+			name: "hysteresis",
+			plugin: &LimitCheck{
+				Limits: []*limit{{
+					Fields:     []string{"level"},
+					Low:        ptr(20.0),
+					High:       ptr(80.0),
+					Hysteresis: 5,
+				}},
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"level": 81.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"level": 79.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"level": 74.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"level": 79.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"level": 19.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"level": 25.0}, now),
+				metric.New("m", map[string]string{}, map[string]interface{}{"level": 26.0}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{"level_severity": "high"}, map[string]interface{}{"level": 81.0}, now),
+				metric.New("m", map[string]string{"level_severity": "high"}, map[string]interface{}{"level": 79.0}, now),
+				metric.New("m", map[string]string{"level_severity": "normal"}, map[string]interface{}{"level": 74.0}, now),
+				metric.New("m", map[string]string{"level_severity": "normal"}, map[string]interface{}{"level": 79.0}, now),
+				metric.New("m", map[string]string{"level_severity": "low"}, map[string]interface{}{"level": 19.0}, now),
+				metric.New("m", map[string]string{"level_severity": "low"}, map[string]interface{}{"level": 25.0}, now),
+				metric.New("m", map[string]string{"level_severity": "normal"}, map[string]interface{}{"level": 26.0}, now),
+			},
+		},
+		{
+			name: "alarms",
+			plugin: &LimitCheck{
+				Mode: "alarm",
+				Limits: []*limit{{
+					Fields:   []string{"temp"},
+					High:     ptr(80.0),
+					HighHigh: ptr(95.0),
+				}},
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"temp": 50.0}, now),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"temp": 85.0}, now),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"temp": 96.0}, now.Add(time.Second)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"temp": 86.0}, now.Add(time.Second)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"temp": 60.0}, now.Add(2*time.Second)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"temp": 50.0}, now),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"temp": 85.0}, now),
+				metric.New("limit_alarm",
+					map[string]string{"id": "2", "measurement": "m", "field": "temp", "severity": "high"},
+					map[string]interface{}{"value": 85.0, "limit": 80.0},
+					now,
+				),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"temp": 96.0}, now.Add(time.Second)),
+				metric.New("limit_alarm",
+					map[string]string{"id": "1", "measurement": "m", "field": "temp", "severity": "high_high"},
+					map[string]interface{}{"value": 96.0, "limit": 95.0, "previous_severity": "normal"},
+					now.Add(time.Second),
+				),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"temp": 86.0}, now.Add(time.Second)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"temp": 60.0}, now.Add(2*time.Second)),
+				metric.New("limit_alarm",
+					map[string]string{"id": "1", "measurement": "m", "field": "temp", "severity": "normal"},
+					map[string]interface{}{"value": 60.0, "previous_severity": "high_high"},
+					now.Add(2*time.Second),
+				),
+			},
+		},
+		{
+			name: "limits file",
+			plugin: &LimitCheck{
+				Mode:             "both",
+				AlarmMeasurement: "alarm",
+				LimitsFile:       "testdata/limits.toml",
+				Limits: []*limit{{
+					Measurements: []string{"tank"},
+					Fields:       []string{"*"},
+					High:         ptr(10.0),
+				}},
+			},
+			input: []telegraf.Metric{
+				metric.New("tank", map[string]string{}, map[string]interface{}{"pressure": 7.0}, now),
+				metric.New("boiler", map[string]string{}, map[string]interface{}{"pressure": 7.0, "temp": 200.0}, now),
+				metric.New("boiler", map[string]string{}, map[string]interface{}{"pressure": 5.6}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("tank", map[string]string{"pressure_severity": "normal"}, map[string]interface{}{"pressure": 7.0}, now),
+				metric.New("boiler", map[string]string{"pressure_severity": "high"}, map[string]interface{}{"pressure": 7.0, "temp": 200.0}, now),
+				metric.New("alarm",
+					map[string]string{"measurement": "boiler", "field": "pressure", "severity": "high"},
+					map[string]interface{}{"value": 7.0, "limit": 6.0},
+					now,
+				),
+				metric.New("boiler", map[string]string{"pressure_severity": "high"}, map[string]interface{}{"pressure": 5.6}, now),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			require.NoError(t, tt.plugin.Init())
+
+			actual := tt.plugin.Apply(tt.input...)
+			testutil.RequireMetricsEqual(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestTracking(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"temp": 50.0}, now),
+		metric.New("m", map[string]string{}, map[string]interface{}{"temp": 90.0}, now.Add(time.Second)),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{"temp_severity": "normal"}, map[string]interface{}{"temp": 50.0}, now),
+		metric.New("m", map[string]string{"temp_severity": "high"}, map[string]interface{}{"temp": 90.0}, now.Add(time.Second)),
+		metric.New("limit_alarm",
+			map[string]string{"measurement": "m", "field": "temp", "severity": "high"},
+			map[string]interface{}{"value": 90.0, "limit": 80.0, "previous_severity": "normal"},
+			now.Add(time.Second),
+		),
+	}
+
+	plugin := &LimitCheck{
+		Mode:   "both",
+		Limits: []*limit{{Fields: []string{"temp"}, High: ptr(80.0)}},
+		Log:    &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// Process expected metrics and compare with resulting metrics
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,7 @@
+package lookup_sql
+
+import (
+	// Blank imports to register the drivers
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/jackc/pgx/v4/stdlib"
+)
@@ -0,0 +1,232 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package lookup_sql
+
+import (
+	"context"
+	dbsql "database/sql"
+	_ "embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// Aliases of the supported database types to the names of the drivers
+var drivers = map[string]string{
+	"pgx":      "pgx",
+	"postgres": "pgx",
+	"mysql":    "mysql",
+	"maria":    "mysql",
+	"sqlite":   "sqlite",
+}
+
+type LookupSQL struct {
+	Driver        string          `toml:"driver"`
+	Dsn           config.Secret   `toml:"dsn"`
+	Query         string          `toml:"query"`
+	KeyTag        string          `toml:"key_tag"`
+	ResultAs      string          `toml:"result_as"`
+	CacheTTL      config.Duration `toml:"cache_ttl"`
+	RetryInterval config.Duration `toml:"retry_interval"`
+	Timeout       config.Duration `toml:"timeout"`
+	Log           telegraf.Logger `toml:"-"`
+
+	driverName string
+	db         *dbsql.DB
+	cache      map[string]*entry
+}
+
+// entry is the cached result of a lookup, a nil result denotes a lookup
+// without matching row. Failed entries keep the previous result, if any,
+// and are retried once expired.
+type entry struct {
+	result  []column
+	expires time.Time
+	failed  bool
+}
+
+type column struct {
+	name  string
+	value interface{}
+}
+
+func (*LookupSQL) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *LookupSQL) Init() error {
+	if p.Driver == "" {
+		return errors.New("missing 'driver'")
+	}
+	driverName, found := drivers[p.Driver]
+	if !found {
+		return fmt.Errorf("unsupported driver %q", p.Driver)
+	}
+	if !choice.Contains(driverName, dbsql.Drivers()) {
+		return fmt.Errorf("driver %q not available on this platform", p.Driver)
+	}
+	p.driverName = driverName
+
+	if p.Dsn.Empty() {
+		return errors.New("missing 'dsn'")
+	}
+	if p.Query == "" {
+		return errors.New("missing 'query'")
+	}
+	if p.KeyTag == "" {
+		return errors.New("missing 'key_tag'")
+	}
+
+	if p.ResultAs == "" {
+		p.ResultAs = "tags"
+	}
+	if err := choice.Check(p.ResultAs, []string{"tags", "fields"}); err != nil {
+		return fmt.Errorf("invalid 'result_as': %w", err)
+	}
+
+	if p.CacheTTL < 0 {
+		return errors.New("'cache_ttl' must not be negative")
+	}
+	if p.RetryInterval < 0 {
+		return errors.New("'retry_interval' must not be negative")
+	}
+	if p.Timeout <= 0 {
+		return errors.New("'timeout' must be positive")
+	}
+
+	p.cache = make(map[string]*entry)
+
+	return nil
+}
+
+func (p *LookupSQL) Start(telegraf.Accumulator) error {
+	dsn, err := p.Dsn.Get()
+	if err != nil {
+		return fmt.Errorf("getting DSN failed: %w", err)
+	}
+	defer dsn.Destroy()
+
+	// Opening the database does not connect, so unavailable servers do not
+	// prevent Telegraf from starting
+	db, err := dbsql.Open(p.driverName, dsn.String())
+	if err != nil {
+		return fmt.Errorf("opening database failed: %w", err)
+	}
+	p.db = db
+
+	return nil
+}
+
+func (p *LookupSQL) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
+	key, found := m.GetTag(p.KeyTag)
+	if !found {
+		acc.AddMetric(m)
+		return nil
+	}
+
+	now := time.Now()
+	e, found := p.cache[key]
+	if !found || ((p.CacheTTL > 0 || e.failed) && !now.Before(e.expires)) {
+		result, err := p.lookup(key)
+		if err != nil {
+			// Keep using the stale result, if any, and remember the failure
+			// to not block the metric flow by querying an unavailable
+			// database for each metric until the retry interval passed
+			p.Log.Errorf("Looking up %q failed: %v", key, err)
+			if e == nil {
+				e = &entry{}
+				p.cache[key] = e
+			}
+			e.failed = true
+			e.expires = now.Add(time.Duration(p.RetryInterval))
+		} else {
+			e = &entry{result: result, expires: now.Add(time.Duration(p.CacheTTL))}
+			p.cache[key] = e
+		}
+	}
+
+	if e != nil {
+		for _, c := range e.result {
+			if p.ResultAs == "fields" {
+				m.AddField(c.name, c.value)
+			} else {
+				m.AddTag(c.name, fmt.Sprint(c.value))
+			}
+		}
+	}
+	acc.AddMetric(m)
+
+	return nil
+}
+
+func (p *LookupSQL) Stop() {
+	if p.db == nil {
+		return
+	}
+	if err := p.db.Close(); err != nil {
+		p.Log.Errorf("Closing database failed: %v", err)
+	}
+}
+
+// lookup queries the columns for the key returning nil if no row matches
+func (p *LookupSQL) lookup(key string) ([]column, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(p.Timeout))
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, p.Query, key)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	if !rows.Next() {
+		return nil, rows.Err()
+	}
+
+	values := make([]interface{}, len(names))
+	ptrs := make([]interface{}, len(names))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	if err := rows.Scan(ptrs...); err != nil {
+		return nil, err
+	}
+
+	result := make([]column, 0, len(names))
+	for i, name := range names {
+		var v interface{}
+		switch x := values[i].(type) {
+		case nil:
+			continue
+		case []byte:
+			v = string(x)
+		case time.Time:
+			v = x.Format(time.RFC3339Nano)
+		default:
+			v = x
+		}
+		result = append(result, column{name: name, value: v})
+	}
+	return result, nil
+}
+
+func init() {
+	processors.AddStreaming("lookup_sql", func() telegraf.StreamingProcessor {
+		return &LookupSQL{
+			CacheTTL:      config.Duration(5 * time.Minute),
+			RetryInterval: config.Duration(30 * time.Second),
+			Timeout:       config.Duration(5 * time.Second),
+		}
+	})
+}
@@ -0,0 +1,381 @@
+//go:build !mips && !mipsle && !mips64 && !ppc64 && !riscv64 && !loong64 && !mips64le && !(windows && (386 || arm))
+
+package lookup_sql
+
+import (
+	dbsql "database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *LookupSQL
+		expected string
+	}{
+		{
+			name:     "missing driver",
+			plugin:   &LookupSQL{},
+			expected: "missing 'driver'",
+		},
+		{
+			name:     "unsupported driver",
+			plugin:   &LookupSQL{Driver: "oracle"},
+			expected: `unsupported driver "oracle"`,
+		},
+		{
+			name:     "missing dsn",
+			plugin:   &LookupSQL{Driver: "sqlite"},
+			expected: "missing 'dsn'",
+		},
+		{
+			name: "missing query",
+			plugin: &LookupSQL{
+				Driver: "sqlite",
+				Dsn:    config.NewSecret([]byte("file::memory:")),
+			},
+			expected: "missing 'query'",
+		},
+		{
+			name: "missing key tag",
+			plugin: &LookupSQL{
+				Driver: "sqlite",
+				Dsn:    config.NewSecret([]byte("file::memory:")),
+				Query:  "SELECT line FROM machines WHERE id = ?",
+			},
+			expected: "missing 'key_tag'",
+		},
+		{
+			name: "invalid result_as",
+			plugin: &LookupSQL{
+				Driver:   "sqlite",
+				Dsn:      config.NewSecret([]byte("file::memory:")),
+				Query:    "SELECT line FROM machines WHERE id = ?",
+				KeyTag:   "machine",
+				ResultAs: "columns",
+			},
+			expected: "invalid 'result_as'",
+		},
+		{
+			name: "negative cache ttl",
+			plugin: &LookupSQL{
+				Driver:   "sqlite",
+				Dsn:      config.NewSecret([]byte("file::memory:")),
+				Query:    "SELECT line FROM machines WHERE id = ?",
+				KeyTag:   "machine",
+				CacheTTL: config.Duration(-time.Second),
+			},
+			expected: "'cache_ttl' must not be negative",
+		},
+		{
+			name: "negative retry interval",
+			plugin: &LookupSQL{
+				Driver:        "sqlite",
+				Dsn:           config.NewSecret([]byte("file::memory:")),
+				Query:         "SELECT line FROM machines WHERE id = ?",
+				KeyTag:        "machine",
+				RetryInterval: config.Duration(-time.Second),
+			},
+			expected: "'retry_interval' must not be negative",
+		},
+		{
+			name: "invalid timeout",
+			plugin: &LookupSQL{
+				Driver: "sqlite",
+				Dsn:    config.NewSecret([]byte("file::memory:")),
+				Query:  "SELECT line FROM machines WHERE id = ?",
+				KeyTag: "machine",
+			},
+			expected: "'timeout' must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	dsn, db := createDatabase(t)
+
+	tests := []struct {
+		name     string
+		resultAs string
+		expected []telegraf.Metric
+	}{
+		{
+			name:     "tags",
+			resultAs: "tags",
+			expected: []telegraf.Metric{
+				metric.New("m",
+					map[string]string{"machine_id": "m1", "line": "L1", "plant": "Berlin", "capacity": "120"},
+					map[string]interface{}{"value": 1.0},
+					time.Unix(0, 0),
+				),
+				metric.New("m",
+					map[string]string{"machine_id": "m2", "line": "L2", "capacity": "80"},
+					map[string]interface{}{"value": 2.0},
+					time.Unix(0, 0),
+				),
+				metric.New("m",
+					map[string]string{"machine_id": "unknown"},
+					map[string]interface{}{"value": 3.0},
+					time.Unix(0, 0),
+				),
+				metric.New("m",
+					map[string]string{},
+					map[string]interface{}{"value": 4.0},
+					time.Unix(0, 0),
+				),
+			},
+		},
+		{
+			name:     "fields",
+			resultAs: "fields",
+			expected: []telegraf.Metric{
+				metric.New("m",
+					map[string]string{"machine_id": "m1"},
+					map[string]interface{}{"value": 1.0, "line": "L1", "plant": "Berlin", "capacity": int64(120)},
+					time.Unix(0, 0),
+				),
+				metric.New("m",
+					map[string]string{"machine_id": "m2"},
+					map[string]interface{}{"value": 2.0, "line": "L2", "capacity": int64(80)},
+					time.Unix(0, 0),
+				),
+				metric.New("m",
+					map[string]string{"machine_id": "unknown"},
+					map[string]interface{}{"value": 3.0},
+					time.Unix(0, 0),
+				),
+				metric.New("m",
+					map[string]string{},
+					map[string]interface{}{"value": 4.0},
+					time.Unix(0, 0),
+				),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &LookupSQL{
+				Driver:   "sqlite",
+				Dsn:      config.NewSecret([]byte(dsn)),
+				Query:    "SELECT line, plant, capacity FROM machines WHERE machine_id = ?",
+				KeyTag:   "machine_id",
+				ResultAs: tt.resultAs,
+				Timeout:  config.Duration(5 * time.Second),
+				Log:      &testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, plugin.Start(&acc))
+			defer plugin.Stop()
+
+			input := []telegraf.Metric{
+				metric.New("m", map[string]string{"machine_id": "m1"}, map[string]interface{}{"value": 1.0}, time.Unix(0, 0)),
+				metric.New("m", map[string]string{"machine_id": "m2"}, map[string]interface{}{"value": 2.0}, time.Unix(0, 0)),
+				metric.New("m", map[string]string{"machine_id": "unknown"}, map[string]interface{}{"value": 3.0}, time.Unix(0, 0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 4.0}, time.Unix(0, 0)),
+			}
+			for _, m := range input {
+				require.NoError(t, plugin.Add(m, &acc))
+			}
+			testutil.RequireMetricsEqual(t, tt.expected, acc.GetTelegrafMetrics())
+		})
+	}
+
+	require.NoError(t, db.Close())
+}
+
+func TestCacheRefresh(t *testing.T) {
+	dsn, db := createDatabase(t)
+	defer db.Close()
+
+	plugin := &LookupSQL{
+		Driver:        "sqlite",
+		Dsn:           config.NewSecret([]byte(dsn)),
+		Query:         "SELECT line FROM machines WHERE machine_id = ?",
+		KeyTag:        "machine_id",
+		CacheTTL:      config.Duration(time.Hour),
+		RetryInterval: config.Duration(time.Minute),
+		Timeout:       config.Duration(5 * time.Second),
+		Log:           &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	lookup := func() string {
+		acc.ClearMetrics()
+		m := metric.New("m", map[string]string{"machine_id": "m1"}, map[string]interface{}{"value": 1.0}, time.Unix(0, 0))
+		require.NoError(t, plugin.Add(m, &acc))
+		metrics := acc.GetTelegrafMetrics()
+		require.Len(t, metrics, 1)
+		line, _ := metrics[0].GetTag("line")
+		return line
+	}
+	require.Equal(t, "L1", lookup())
+
+	// Changes are not visible as long as the cached result is valid
+	_, err := db.Exec("UPDATE machines SET line = 'L3' WHERE machine_id = 'm1'")
+	require.NoError(t, err)
+	require.Equal(t, "L1", lookup())
+
+	// Expired results are refreshed
+	plugin.cache["m1"].expires = time.Now().Add(-time.Second)
+	require.Equal(t, "L3", lookup())
+
+	// The stale result is used if the refresh fails
+	_, err = db.Exec("DROP TABLE machines")
+	require.NoError(t, err)
+	plugin.cache["m1"].expires = time.Now().Add(-time.Second)
+	require.Equal(t, "L3", lookup())
+	require.True(t, plugin.cache["m1"].failed)
+	require.True(t, plugin.cache["m1"].expires.After(time.Now()))
+	require.True(t, plugin.cache["m1"].expires.Before(time.Now().Add(time.Hour)))
+}
+
+func TestLookupFailure(t *testing.T) {
+	dsn, db := createDatabase(t)
+	defer db.Close()
+	_, err := db.Exec("DROP TABLE machines")
+	require.NoError(t, err)
+
+	logger := &testutil.CaptureLogger{}
+	plugin := &LookupSQL{
+		Driver:        "sqlite",
+		Dsn:           config.NewSecret([]byte(dsn)),
+		Query:         "SELECT line FROM machines WHERE machine_id = ?",
+		KeyTag:        "machine_id",
+		RetryInterval: config.Duration(time.Minute),
+		Timeout:       config.Duration(5 * time.Second),
+		Log:           logger,
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	add := func() {
+		m := metric.New("m", map[string]string{"machine_id": "m1"}, map[string]interface{}{"value": 1.0}, time.Unix(0, 0))
+		require.NoError(t, plugin.Add(m, &acc))
+	}
+
+	// Failed lookups are not retried for each metric but metrics pass
+	for range 3 {
+		add()
+	}
+	require.Len(t, acc.GetTelegrafMetrics(), 3)
+	require.Len(t, logger.Errors(), 1)
+	require.True(t, plugin.cache["m1"].failed)
+
+	// The lookup is retried after the retry interval and succeeds once the
+	// database is available again even though results are cached forever
+	_, err = db.Exec("CREATE TABLE machines (machine_id TEXT PRIMARY KEY, line TEXT); INSERT INTO machines VALUES ('m1', 'L1');")
+	require.NoError(t, err)
+	add()
+	require.Len(t, logger.Errors(), 1)
+	plugin.cache["m1"].expires = time.Now().Add(-time.Second)
+	acc.ClearMetrics()
+	add()
+	require.Len(t, logger.Errors(), 1)
+	metrics := acc.GetTelegrafMetrics()
+	require.Len(t, metrics, 1)
+	line, found := metrics[0].GetTag("line")
+	require.True(t, found)
+	require.Equal(t, "L1", line)
+	require.False(t, plugin.cache["m1"].failed)
+}
+
+func TestTracking(t *testing.T) {
+	dsn, db := createDatabase(t)
+	defer db.Close()
+
+	inputRaw := []telegraf.Metric{
+		metric.New("m", map[string]string{"machine_id": "m1"}, map[string]interface{}{"value": 1.0}, time.Unix(0, 0)),
+		metric.New("m", map[string]string{"machine_id": "unknown"}, map[string]interface{}{"value": 2.0}, time.Unix(0, 0)),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{"machine_id": "m1", "line": "L1"}, map[string]interface{}{"value": 1.0}, time.Unix(0, 0)),
+		metric.New("m", map[string]string{"machine_id": "unknown"}, map[string]interface{}{"value": 2.0}, time.Unix(0, 0)),
+	}
+
+	plugin := &LookupSQL{
+		Driver:  "sqlite",
+		Dsn:     config.NewSecret([]byte(dsn)),
+		Query:   "SELECT line FROM machines WHERE machine_id = ?",
+		KeyTag:  "machine_id",
+		Timeout: config.Duration(5 * time.Second),
+		Log:     &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	for _, m := range input {
+		require.NoError(t, plugin.Add(m, &acc))
+	}
+	plugin.Stop()
+
+	actual := acc.GetTelegrafMetrics()
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
+
+func createDatabase(t *testing.T) (string, *dbsql.DB) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "lookup.db")
+	db, err := dbsql.Open("sqlite", dsn)
+	require.NoError(t, err)
+
+	_, err = db.Exec(`
+		CREATE TABLE machines (machine_id TEXT PRIMARY KEY, line TEXT, plant TEXT, capacity INTEGER);
+		INSERT INTO machines VALUES ('m1', 'L1', 'Berlin', 120);
+		INSERT INTO machines VALUES ('m2', 'L2', NULL, 80);
+	`)
+	require.NoError(t, err)
+	return dsn, db
+}
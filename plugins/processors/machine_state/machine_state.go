@@ -0,0 +1,213 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package machine_state
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type MachineState struct {
+	Field        string          `toml:"field"`
+	Model        string          `toml:"model"`
+	EquipmentTag string          `toml:"equipment_tag"`
+	StateTag     string          `toml:"state_tag"`
+	DropField    bool            `toml:"drop_field"`
+	Profiles     []*profile      `toml:"profile"`
+	Log          telegraf.Logger `toml:"-"`
+
+	fallback  *profile
+	equipment map[string]*profile
+	states    map[uint64]*state
+}
+
+type profile struct {
+	Equipment string            `toml:"equipment"`
+	Default   string            `toml:"default"`
+	Values    map[string]string `toml:"values"`
+	Bits      []*bit            `toml:"bit"`
+}
+
+type bit struct {
+	Bit   int    `toml:"bit"`
+	State string `toml:"state"`
+}
+
+// state is the current state of a series, the time the series entered the
+// state and the number of transitions seen so far
+type state struct {
+	name        string
+	since       time.Time
+	transitions int64
+}
+
+func (*MachineState) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *MachineState) Init() error {
+	if p.Field == "" {
+		return errors.New("missing 'field'")
+	}
+
+	if p.Model == "" {
+		p.Model = "packml"
+	}
+	names, found := models[p.Model]
+	if !found {
+		return fmt.Errorf("invalid 'model' %q", p.Model)
+	}
+	canonical := make(map[string]string, len(names))
+	for _, name := range names {
+		canonical[strings.ToLower(name)] = name
+	}
+	normalize := func(name string, i int) (string, error) {
+		n, found := canonical[strings.ToLower(name)]
+		if !found {
+			return "", fmt.Errorf("unknown %s state %q in profile %d", p.Model, name, i+1)
+		}
+		return n, nil
+	}
+
+	if p.StateTag == "" {
+		p.StateTag = "state"
+	}
+
+	if len(p.Profiles) == 0 {
+		return errors.New("no profiles configured")
+	}
+	p.equipment = make(map[string]*profile, len(p.Profiles))
+	for i, prof := range p.Profiles {
+		if len(prof.Values) == 0 && len(prof.Bits) == 0 && prof.Default == "" {
+			return fmt.Errorf("no mapping configured in profile %d", i+1)
+		}
+
+		// Use the canonical spelling of all states
+		var err error
+		if prof.Default != "" {
+			if prof.Default, err = normalize(prof.Default, i); err != nil {
+				return err
+			}
+		}
+		for k, v := range prof.Values {
+			if prof.Values[k], err = normalize(v, i); err != nil {
+				return err
+			}
+		}
+		for _, b := range prof.Bits {
+			if b.Bit < 0 || b.Bit > 63 {
+				return fmt.Errorf("bit %d in profile %d out of range", b.Bit, i+1)
+			}
+			if b.State, err = normalize(b.State, i); err != nil {
+				return err
+			}
+		}
+
+		if prof.Equipment == "" {
+			if p.fallback != nil {
+				return fmt.Errorf("duplicate default profile %d", i+1)
+			}
+			p.fallback = prof
+			continue
+		}
+		if p.EquipmentTag == "" {
+			return fmt.Errorf("profile %d requires 'equipment_tag'", i+1)
+		}
+		if _, found := p.equipment[prof.Equipment]; found {
+			return fmt.Errorf("duplicate profile for equipment %q", prof.Equipment)
+		}
+		p.equipment[prof.Equipment] = prof
+	}
+
+	p.states = make(map[uint64]*state)
+
+	return nil
+}
+
+func (p *MachineState) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	for _, m := range in {
+		raw, found := m.GetField(p.Field)
+		if !found {
+			continue
+		}
+		prof := p.profile(m)
+		if prof == nil {
+			continue
+		}
+		name := prof.match(raw)
+		if name == "" {
+			p.Log.Debugf("No state for value %v of %q", raw, m.Name())
+			continue
+		}
+
+		// Track the state of the series identified by the original metric
+		id := m.HashID()
+		ts := m.Time()
+		s, found := p.states[id]
+		switch {
+		case !found:
+			s = &state{name: name, since: ts}
+			p.states[id] = s
+		case ts.Before(s.since):
+			p.Log.Debugf("Ignoring out-of-order status of %q at %v", m.Name(), ts)
+			continue
+		case s.name != name:
+			s.name = name
+			s.since = ts
+			s.transitions++
+		}
+
+		if p.DropField {
+			m.RemoveField(p.Field)
+		}
+		m.AddTag(p.StateTag, name)
+		m.AddField("state_duration", ts.Sub(s.since).Seconds())
+		m.AddField("state_transitions", s.transitions)
+	}
+	return in
+}
+
+// profile returns the profile for the equipment of the metric
+func (p *MachineState) profile(m telegraf.Metric) *profile {
+	if p.EquipmentTag != "" {
+		if equipment, found := m.GetTag(p.EquipmentTag); found {
+			if prof, found := p.equipment[equipment]; found {
+				return prof
+			}
+		}
+	}
+	return p.fallback
+}
+
+// match returns the state for the raw value or an empty string if the value
+// cannot be mapped
+func (prof *profile) match(raw interface{}) string {
+	if name, found := prof.Values[fmt.Sprint(raw)]; found {
+		return name
+	}
+	if len(prof.Bits) > 0 {
+		if word, err := internal.ToUint64(raw); err == nil {
+			for _, b := range prof.Bits {
+				if word&(1<<b.Bit) != 0 {
+					return b.State
+				}
+			}
+		}
+	}
+	return prof.Default
+}
+
+func init() {
+	processors.Add("machine_state", func() telegraf.Processor {
+		return &MachineState{}
+	})
+}
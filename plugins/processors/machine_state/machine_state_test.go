@@ -0,0 +1,367 @@
+package machine_state
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestSampleConfig(t *testing.T) {
+	cfg := config.NewConfig()
+	require.NoError(t, cfg.LoadConfigData(testutil.DefaultSampleConfig((&MachineState{}).SampleConfig()), config.EmptySourcePath))
+	require.Len(t, cfg.Processors, 1)
+
+	proc := cfg.Processors[0].Processor.(processors.HasUnwrap)
+	plugin := proc.Unwrap().(*MachineState)
+	require.Len(t, plugin.Profiles, 1)
+	require.Len(t, plugin.Profiles[0].Values, 4)
+}
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *MachineState
+		expected string
+	}{
+		{
+			name:     "missing field",
+			plugin:   &MachineState{},
+			expected: "missing 'field'",
+		},
+		{
+			name:     "invalid model",
+			plugin:   &MachineState{Field: "status", Model: "omac"},
+			expected: `invalid 'model' "omac"`,
+		},
+		{
+			name:     "no profiles",
+			plugin:   &MachineState{Field: "status"},
+			expected: "no profiles configured",
+		},
+		{
+			name:     "empty profile",
+			plugin:   &MachineState{Field: "status", Profiles: []*profile{{}}},
+			expected: "no mapping configured in profile 1",
+		},
+		{
+			name: "unknown state",
+			plugin: &MachineState{
+				Field:    "status",
+				Profiles: []*profile{{Values: map[string]string{"1": "Running"}}},
+			},
+			expected: `unknown packml state "Running" in profile 1`,
+		},
+		{
+			name: "unknown isa88 state",
+			plugin: &MachineState{
+				Field:    "status",
+				Model:    "isa88",
+				Profiles: []*profile{{Default: "Execute"}},
+			},
+			expected: `unknown isa88 state "Execute" in profile 1`,
+		},
+		{
+			name: "bit out of range",
+			plugin: &MachineState{
+				Field:    "status",
+				Profiles: []*profile{{Bits: []*bit{{Bit: 64, State: "Aborted"}}}},
+			},
+			expected: "bit 64 in profile 1 out of range",
+		},
+		{
+			name: "duplicate default profile",
+			plugin: &MachineState{
+				Field:    "status",
+				Profiles: []*profile{{Default: "Idle"}, {Default: "Stopped"}},
+			},
+			expected: "duplicate default profile 2",
+		},
+		{
+			name: "missing equipment tag",
+			plugin: &MachineState{
+				Field:    "status",
+				Profiles: []*profile{{Equipment: "filler", Default: "Idle"}},
+			},
+			expected: "profile 1 requires 'equipment_tag'",
+		},
+		{
+			name: "duplicate equipment",
+			plugin: &MachineState{
+				Field:        "status",
+				EquipmentTag: "type",
+				Profiles: []*profile{
+					{Equipment: "filler", Default: "Idle"},
+					{Equipment: "filler", Default: "Stopped"},
+				},
+			},
+			expected: `duplicate profile for equipment "filler"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	at := func(sec int) time.Time {
+		return base.Add(time.Duration(sec) * time.Second)
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *MachineState
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name: "state numbers",
+			plugin: &MachineState{
+				Field: "status",
+				Profiles: []*profile{{
+					Values: map[string]string{"2": "stopped", "4": "Idle", "6": "EXECUTE"},
+				}},
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"status": int64(2)}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"status": int64(4)}, at(10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"status": int64(6)}, at(15)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"status": int64(6)}, at(45)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"status": int64(99)}, at(50)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, at(55)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m",
+					map[string]string{"state": "Stopped"},
+					map[string]interface{}{"status": int64(2), "state_duration": 0.0, "state_transitions": int64(0)},
+					at(0),
+				),
+				metric.New("m",
+					map[string]string{"state": "Idle"},
+					map[string]interface{}{"status": int64(4), "state_duration": 0.0, "state_transitions": int64(1)},
+					at(10),
+				),
+				metric.New("m",
+					map[string]string{"state": "Execute"},
+					map[string]interface{}{"status": int64(6), "state_duration": 0.0, "state_transitions": int64(2)},
+					at(15),
+				),
+				metric.New("m",
+					map[string]string{"state": "Execute"},
+					map[string]interface{}{"status": int64(6), "state_duration": 30.0, "state_transitions": int64(2)},
+					at(45),
+				),
+				metric.New("m", map[string]string{}, map[string]interface{}{"status": int64(99)}, at(50)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, at(55)),
+			},
+		},
+		{
+			name: "status bits",
+			plugin: &MachineState{
+				Field:     "status_word",
+				DropField: true,
+				Profiles: []*profile{{
+					Default: "Idle",
+					Bits: []*bit{
+						{Bit: 3, State: "Aborted"},
+						{Bit: 2, State: "Held"},
+						{Bit: 0, State: "Execute"},
+					},
+				}},
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"status_word": uint64(0)}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"status_word": uint64(0b0001)}, at(5)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"status_word": uint64(0b0101)}, at(8)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"status_word": uint64(0b1101)}, at(10)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m",
+					map[string]string{"state": "Idle"},
+					map[string]interface{}{"state_duration": 0.0, "state_transitions": int64(0)},
+					at(0),
+				),
+				metric.New("m",
+					map[string]string{"state": "Execute"},
+					map[string]interface{}{"state_duration": 0.0, "state_transitions": int64(1)},
+					at(5),
+				),
+				metric.New("m",
+					map[string]string{"state": "Held"},
+					map[string]interface{}{"state_duration": 0.0, "state_transitions": int64(2)},
+					at(8),
+				),
+				metric.New("m",
+					map[string]string{"state": "Aborted"},
+					map[string]interface{}{"state_duration": 0.0, "state_transitions": int64(3)},
+					at(10),
+				),
+			},
+		},
+		{
+			name: "equipment profiles",
+			plugin: &MachineState{
+				Field:        "status",
+				Model:        "isa88",
+				EquipmentTag: "type",
+				StateTag:     "procedure_state",
+				Profiles: []*profile{
+					{Values: map[string]string{"run": "Running"}},
+					{Equipment: "filler", Values: map[string]string{"1": "Running", "0": "Idle"}},
+					{Equipment: "capper", Values: map[string]string{"true": "Running", "false": "Stopped"}},
+				},
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{"type": "filler"}, map[string]interface{}{"status": int64(1)}, at(0)),
+				metric.New("m", map[string]string{"type": "capper"}, map[string]interface{}{"status": false}, at(0)),
+				metric.New("m", map[string]string{"type": "labeler"}, map[string]interface{}{"status": "run"}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"status": "run"}, at(0)),
+				metric.New("m", map[string]string{"type": "filler"}, map[string]interface{}{"status": int64(0)}, at(20)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m",
+					map[string]string{"type": "filler", "procedure_state": "Running"},
+					map[string]interface{}{"status": int64(1), "state_duration": 0.0, "state_transitions": int64(0)},
+					at(0),
+				),
+				metric.New("m",
+					map[string]string{"type": "capper", "procedure_state": "Stopped"},
+					map[string]interface{}{"status": false, "state_duration": 0.0, "state_transitions": int64(0)},
+					at(0),
+				),
+				metric.New("m",
+					map[string]string{"type": "labeler", "procedure_state": "Running"},
+					map[string]interface{}{"status": "run", "state_duration": 0.0, "state_transitions": int64(0)},
+					at(0),
+				),
+				metric.New("m",
+					map[string]string{"procedure_state": "Running"},
+					map[string]interface{}{"status": "run", "state_duration": 0.0, "state_transitions": int64(0)},
+					at(0),
+				),
+				metric.New("m",
+					map[string]string{"type": "filler", "procedure_state": "Idle"},
+					map[string]interface{}{"status": int64(0), "state_duration": 0.0, "state_transitions": int64(1)},
+					at(20),
+				),
+			},
+		},
+		{
+			name: "independent series",
+			plugin: &MachineState{
+				Field:    "status",
+				Profiles: []*profile{{Values: map[string]string{"4": "Idle", "6": "Execute"}}},
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"status": int64(4)}, at(0)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"status": int64(6)}, at(0)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"status": int64(6)}, at(10)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"status": int64(6)}, at(10)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"status": int64(4)}, at(5)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m",
+					map[string]string{"id": "1", "state": "Idle"},
+					map[string]interface{}{"status": int64(4), "state_duration": 0.0, "state_transitions": int64(0)},
+					at(0),
+				),
+				metric.New("m",
+					map[string]string{"id": "2", "state": "Execute"},
+					map[string]interface{}{"status": int64(6), "state_duration": 0.0, "state_transitions": int64(0)},
+					at(0),
+				),
+				metric.New("m",
+					map[string]string{"id": "1", "state": "Execute"},
+					map[string]interface{}{"status": int64(6), "state_duration": 0.0, "state_transitions": int64(1)},
+					at(10),
+				),
+				metric.New("m",
+					map[string]string{"id": "2", "state": "Execute"},
+					map[string]interface{}{"status": int64(6), "state_duration": 10.0, "state_transitions": int64(0)},
+					at(10),
+				),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"status": int64(4)}, at(5)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			require.NoError(t, tt.plugin.Init())
+
+			actual := tt.plugin.Apply(tt.input...)
+			testutil.RequireMetricsEqual(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestTracking(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"status": int64(4)}, base),
+		metric.New("m", map[string]string{}, map[string]interface{}{"status": int64(6)}, base.Add(time.Second)),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("m",
+			map[string]string{"state": "Idle"},
+			map[string]interface{}{"status": int64(4), "state_duration": 0.0, "state_transitions": int64(0)},
+			base,
+		),
+		metric.New("m",
+			map[string]string{"state": "Execute"},
+			map[string]interface{}{"status": int64(6), "state_duration": 0.0, "state_transitions": int64(1)},
+			base.Add(time.Second),
+		),
+	}
+
+	plugin := &MachineState{
+		Field:    "status",
+		Profiles: []*profile{{Values: map[string]string{"4": "Idle", "6": "Execute"}}},
+		Log:      &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// Process expected metrics and compare with resulting metrics
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
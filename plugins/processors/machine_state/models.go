@@ -0,0 +1,38 @@
+package machine_state
+
+// States of the supported state models in their canonical spelling
+var models = map[string][]string{
+	"packml": {
+		"Clearing",
+		"Stopped",
+		"Starting",
+		"Idle",
+		"Suspended",
+		"Execute",
+		"Stopping",
+		"Aborting",
+		"Aborted",
+		"Holding",
+		"Held",
+		"Unholding",
+		"Suspending",
+		"Unsuspending",
+		"Resetting",
+		"Completing",
+		"Complete",
+	},
+	"isa88": {
+		"Idle",
+		"Running",
+		"Complete",
+		"Pausing",
+		"Paused",
+		"Holding",
+		"Held",
+		"Restarting",
+		"Stopping",
+		"Stopped",
+		"Aborting",
+		"Aborted",
+	},
+}
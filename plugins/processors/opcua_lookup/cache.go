@@ -0,0 +1,63 @@
+package opcua_lookup
+
+import (
+	"container/list"
+	"time"
+)
+
+type cacheEntry struct {
+	key     string
+	attrs   map[string]string
+	created time.Time
+}
+
+// cache is a LRU cache with a maximum number of entries where entries
+// expire after the given time-to-live. The cache is not safe for concurrent
+// use.
+type cache struct {
+	capacity int
+	ttl      time.Duration
+	order    *list.List
+	entries  map[string]*list.Element
+}
+
+func newCache(capacity int, ttl time.Duration) *cache {
+	return &cache{
+		capacity: capacity,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element, capacity),
+	}
+}
+
+func (c *cache) get(key string) (map[string]string, bool) {
+	elem, found := c.entries[key]
+	if !found {
+		return nil, false
+	}
+
+	entry := elem.Value.(*cacheEntry)
+	if c.ttl > 0 && time.Since(entry.created) > c.ttl {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+
+	return entry.attrs, true
+}
+
+func (c *cache) put(key string, attrs map[string]string) {
+	if elem, found := c.entries[key]; found {
+		c.order.MoveToFront(elem)
+		elem.Value = &cacheEntry{key: key, attrs: attrs, created: time.Now()}
+		return
+	}
+
+	if c.order.Len() >= c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, attrs: attrs, created: time.Now()})
+}
@@ -0,0 +1,375 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package opcua_lookup
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gopcua/opcua/id"
+	"github.com/gopcua/opcua/ua"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/plugins/common/opcua"
+	"github.com/influxdata/telegraf/plugins/common/parallel"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+// maxPathDepth limits the number of parents resolved for the path
+const maxPathDepth = 32
+
+// attributeTags maps the supported attributes to the name of the tag
+var attributeTags = map[string]string{
+	"DisplayName":      "display_name",
+	"Description":      "description",
+	"EngineeringUnits": "engineering_units",
+	"Path":             "path",
+}
+
+type lookupFunc func(nodeID *ua.NodeID) (map[string]string, error)
+
+type OpcUALookup struct {
+	opcua.OpcUAClientConfig
+	NodeIDTag          string          `toml:"tag"`
+	Attributes         []string        `toml:"attributes"`
+	TagPrefix          string          `toml:"tag_prefix"`
+	PathSeparator      string          `toml:"path_separator"`
+	CacheSize          int             `toml:"max_cache_entries"`
+	CacheTTL           config.Duration `toml:"cache_ttl"`
+	MaxParallelLookups int             `toml:"max_parallel_lookups"`
+	Ordered            bool            `toml:"ordered"`
+	Log                telegraf.Logger `toml:"-"`
+
+	client   *opcua.OpcUAClient
+	connLock sync.Mutex
+
+	cache    *cache
+	lock     sync.Mutex
+	sigs     map[string]chan struct{}
+	parallel parallel.Parallel
+
+	lookupRemote lookupFunc
+
+	unregisterDiagnostics func()
+}
+
+func (*OpcUALookup) SampleConfig() string {
+	return sampleConfig
+}
+
+func (o *OpcUALookup) Init() error {
+	if o.NodeIDTag == "" {
+		return errors.New("tag must be set")
+	}
+	if len(o.Attributes) == 0 {
+		return errors.New("no attributes configured")
+	}
+	for _, attr := range o.Attributes {
+		if _, found := attributeTags[attr]; !found {
+			return fmt.Errorf("invalid attribute %q", attr)
+		}
+	}
+	if o.CacheSize < 1 {
+		return errors.New("max_cache_entries must be positive")
+	}
+	if o.MaxParallelLookups < 1 {
+		return errors.New("max_parallel_lookups must be positive")
+	}
+
+	client, err := o.OpcUAClientConfig.CreateClient(o.Log)
+	if err != nil {
+		return err
+	}
+	o.client = client
+
+	o.cache = newCache(o.CacheSize, time.Duration(o.CacheTTL))
+	o.sigs = make(map[string]chan struct{})
+	if o.lookupRemote == nil {
+		o.lookupRemote = o.lookupRemoteNoMock
+	}
+
+	return nil
+}
+
+func (o *OpcUALookup) Start(acc telegraf.Accumulator) error {
+	unregister, err := opcua.RegisterDiagnostics(o.DiagnosticsAddress, "processors.opcua_lookup", func() interface{} {
+		return o.client.Diagnostics()
+	})
+	if err != nil {
+		return err
+	}
+	o.unregisterDiagnostics = unregister
+
+	fn := func(m telegraf.Metric) []telegraf.Metric {
+		if err := o.addTags(m); err != nil {
+			o.Log.Debugf("Error adding tags: %v", err)
+		}
+		return []telegraf.Metric{m}
+	}
+
+	if o.Ordered {
+		o.parallel = parallel.NewOrdered(acc, fn, 10000, o.MaxParallelLookups)
+	} else {
+		o.parallel = parallel.NewUnordered(acc, fn, o.MaxParallelLookups)
+	}
+	return nil
+}
+
+func (o *OpcUALookup) Add(metric telegraf.Metric, _ telegraf.Accumulator) error {
+	o.parallel.Enqueue(metric)
+	return nil
+}
+
+func (o *OpcUALookup) Stop() {
+	o.parallel.Stop()
+	if o.unregisterDiagnostics != nil {
+		o.unregisterDiagnostics()
+	}
+
+	o.connLock.Lock()
+	defer o.connLock.Unlock()
+	if o.client.State() == opcua.Connected {
+		if err := o.client.Disconnect(context.Background()); err != nil {
+			o.Log.Errorf("Disconnecting failed: %v", err)
+		}
+	}
+}
+
+func (o *OpcUALookup) addTags(metric telegraf.Metric) error {
+	raw, found := metric.GetTag(o.NodeIDTag)
+	if !found {
+		return nil
+	}
+
+	attrs, err := o.lookup(raw)
+	if err != nil {
+		return fmt.Errorf("looking up node %q failed: %w", raw, err)
+	}
+
+	for _, attr := range o.Attributes {
+		if v, found := attrs[attr]; found && v != "" {
+			metric.AddTag(o.TagPrefix+attributeTags[attr], v)
+		}
+	}
+	return nil
+}
+
+// lookup gets the attributes of the node either from cache or from the server
+// making sure only one request per node is in flight
+func (o *OpcUALookup) lookup(raw string) (map[string]string, error) {
+	o.lock.Lock()
+	if attrs, found := o.cache.get(raw); found {
+		o.lock.Unlock()
+		return attrs, nil
+	}
+
+	// Wait for a running request of the same node to finish
+	if sig, found := o.sigs[raw]; found {
+		o.lock.Unlock()
+		<-sig
+
+		o.lock.Lock()
+		defer o.lock.Unlock()
+		if attrs, found := o.cache.get(raw); found {
+			return attrs, nil
+		}
+		return nil, errors.New("concurrent lookup failed")
+	}
+	sig := make(chan struct{})
+	o.sigs[raw] = sig
+	o.lock.Unlock()
+
+	var attrs map[string]string
+	nodeID, err := ua.ParseNodeID(raw)
+	if err == nil {
+		attrs, err = o.lookupRemote(nodeID)
+	}
+
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	if err == nil {
+		o.cache.put(raw, attrs)
+	}
+	close(sig)
+	delete(o.sigs, raw)
+
+	return attrs, err
+}
+
+func (o *OpcUALookup) connect() error {
+	o.connLock.Lock()
+	defer o.connLock.Unlock()
+
+	// Will (re)connect if the client is disconnected
+	if state := o.client.State(); state == opcua.Disconnected || state == opcua.Closed {
+		if err := o.client.Connect(context.Background()); err != nil {
+			return fmt.Errorf("connect failed: %w", err)
+		}
+	}
+	return nil
+}
+
+func (o *OpcUALookup) lookupRemoteNoMock(nodeID *ua.NodeID) (map[string]string, error) {
+	if err := o.connect(); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(o.RequestTimeout))
+	defer cancel()
+
+	attrs := make(map[string]string, len(o.Attributes))
+	if slices.Contains(o.Attributes, "DisplayName") || slices.Contains(o.Attributes, "Description") {
+		resp, err := o.client.Client.Read(ctx, &ua.ReadRequest{
+			NodesToRead: []*ua.ReadValueID{
+				{NodeID: nodeID, AttributeID: ua.AttributeIDDisplayName},
+				{NodeID: nodeID, AttributeID: ua.AttributeIDDescription},
+			},
+			TimestampsToReturn: ua.TimestampsToReturnNeither,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("reading attributes failed: %w", err)
+		}
+		for i, name := range []string{"DisplayName", "Description"} {
+			if i >= len(resp.Results) || !o.client.StatusCodeOK(resp.Results[i].Status) || resp.Results[i].Value == nil {
+				continue
+			}
+			if text, ok := resp.Results[i].Value.Value().(*ua.LocalizedText); ok && text != nil {
+				attrs[name] = text.Text
+			}
+		}
+	}
+
+	if slices.Contains(o.Attributes, "EngineeringUnits") {
+		unit, err := o.engineeringUnits(ctx, nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("reading engineering units failed: %w", err)
+		}
+		attrs["EngineeringUnits"] = unit
+	}
+
+	if slices.Contains(o.Attributes, "Path") {
+		path, err := o.path(ctx, nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("resolving path failed: %w", err)
+		}
+		attrs["Path"] = path
+	}
+
+	return attrs, nil
+}
+
+// engineeringUnits reads the "EngineeringUnits" property of analog items
+// returning an empty string if the node does not have the property
+func (o *OpcUALookup) engineeringUnits(ctx context.Context, nodeID *ua.NodeID) (string, error) {
+	var propertyID *ua.NodeID
+	req := &ua.TranslateBrowsePathsToNodeIDsRequest{
+		BrowsePaths: []*ua.BrowsePath{
+			{
+				StartingNode: nodeID,
+				RelativePath: &ua.RelativePath{
+					Elements: []*ua.RelativePathElement{
+						{
+							ReferenceTypeID: ua.NewNumericNodeID(0, id.HasProperty),
+							TargetName:      &ua.QualifiedName{Name: "EngineeringUnits"},
+						},
+					},
+				},
+			},
+		},
+	}
+	err := o.client.Client.Send(ctx, req, func(r ua.Response) error {
+		resp, ok := r.(*ua.TranslateBrowsePathsToNodeIDsResponse)
+		if !ok || len(resp.Results) == 0 {
+			return ua.StatusBadUnexpectedError
+		}
+		if len(resp.Results[0].Targets) > 0 && resp.Results[0].Targets[0].TargetID != nil {
+			propertyID = resp.Results[0].Targets[0].TargetID.NodeID
+		}
+		return nil
+	})
+	if err != nil || propertyID == nil {
+		return "", err
+	}
+
+	value, err := o.client.Client.Node(propertyID).Value(ctx)
+	if err != nil {
+		return "", err
+	}
+	if value == nil {
+		return "", nil
+	}
+	eo, ok := value.Value().(*ua.ExtensionObject)
+	if !ok || eo == nil {
+		return "", nil
+	}
+	eu, ok := eo.Value.(*ua.EUInformation)
+	if !ok || eu == nil {
+		return "", nil
+	}
+	if eu.DisplayName != nil && eu.DisplayName.Text != "" {
+		return eu.DisplayName.Text, nil
+	}
+	if eu.Description != nil {
+		return eu.Description.Text, nil
+	}
+	return "", nil
+}
+
+// path resolves the browse names of the node's parents up to the objects
+// folder by following inverse hierarchical references
+func (o *OpcUALookup) path(ctx context.Context, nodeID *ua.NodeID) (string, error) {
+	var elements []string
+
+	current := nodeID
+	for range maxPathDepth {
+		refs, err := o.client.Client.Node(current).References(ctx, id.HierarchicalReferences, ua.BrowseDirectionInverse, ua.NodeClassAll, true)
+		if err != nil {
+			return "", err
+		}
+		if len(refs) == 0 || refs[0].NodeID == nil || refs[0].NodeID.NodeID == nil {
+			break
+		}
+
+		parent := refs[0].NodeID.NodeID
+		if parent.Namespace() == 0 && (parent.IntID() == id.ObjectsFolder || parent.IntID() == id.RootFolder) {
+			break
+		}
+		if refs[0].BrowseName != nil {
+			elements = append(elements, refs[0].BrowseName.Name)
+		}
+		current = parent
+	}
+	slices.Reverse(elements)
+
+	return strings.Join(elements, o.PathSeparator), nil
+}
+
+func init() {
+	processors.AddStreaming("opcua_lookup", func() telegraf.StreamingProcessor {
+		return &OpcUALookup{
+			OpcUAClientConfig: opcua.OpcUAClientConfig{
+				Endpoint:       "opc.tcp://localhost:4840",
+				SecurityPolicy: "auto",
+				SecurityMode:   "auto",
+				AuthMethod:     "Anonymous",
+				ConnectTimeout: config.Duration(5 * time.Second),
+				RequestTimeout: config.Duration(10 * time.Second),
+			},
+			NodeIDTag:          "id",
+			Attributes:         []string{"DisplayName"},
+			PathSeparator:      "/",
+			CacheSize:          1000,
+			CacheTTL:           config.Duration(time.Hour),
+			MaxParallelLookups: 10,
+		}
+	})
+}
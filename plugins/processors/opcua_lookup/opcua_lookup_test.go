@@ -0,0 +1,160 @@
+package opcua_lookup
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gopcua/opcua/ua"
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/common/opcua"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func newPlugin() *OpcUALookup {
+	return &OpcUALookup{
+		OpcUAClientConfig: opcua.OpcUAClientConfig{
+			Endpoint:       "opc.tcp://localhost:4840",
+			SecurityPolicy: "None",
+			SecurityMode:   "None",
+			AuthMethod:     "Anonymous",
+			ConnectTimeout: config.Duration(5 * time.Second),
+			RequestTimeout: config.Duration(10 * time.Second),
+		},
+		NodeIDTag:          "id",
+		Attributes:         []string{"DisplayName"},
+		PathSeparator:      "/",
+		CacheSize:          10,
+		CacheTTL:           config.Duration(time.Hour),
+		MaxParallelLookups: 2,
+		Ordered:            true,
+		Log:                testutil.Logger{},
+	}
+}
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(*OpcUALookup)
+		expected string
+	}{
+		{
+			name:     "no tag",
+			modify:   func(o *OpcUALookup) { o.NodeIDTag = "" },
+			expected: "tag must be set",
+		},
+		{
+			name:     "no attributes",
+			modify:   func(o *OpcUALookup) { o.Attributes = nil },
+			expected: "no attributes configured",
+		},
+		{
+			name:     "invalid attribute",
+			modify:   func(o *OpcUALookup) { o.Attributes = []string{"DisplayName", "Color"} },
+			expected: `invalid attribute "Color"`,
+		},
+		{
+			name:     "invalid cache size",
+			modify:   func(o *OpcUALookup) { o.CacheSize = 0 },
+			expected: "max_cache_entries must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := newPlugin()
+			tt.modify(plugin)
+			require.ErrorContains(t, plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestLookup(t *testing.T) {
+	var calls atomic.Int32
+	plugin := newPlugin()
+	plugin.Attributes = []string{"DisplayName", "EngineeringUnits", "Path"}
+	plugin.TagPrefix = "opcua_"
+	plugin.lookupRemote = func(nodeID *ua.NodeID) (map[string]string, error) {
+		calls.Add(1)
+		switch nodeID.String() {
+		case "ns=2;s=Boiler.Temperature":
+			return map[string]string{
+				"DisplayName":      "Temperature",
+				"Description":      "not requested",
+				"EngineeringUnits": "°C",
+				"Path":             "Plant/Boiler",
+			}, nil
+		case "ns=2;i=5":
+			return map[string]string{"DisplayName": "Level", "EngineeringUnits": ""}, nil
+		}
+		return nil, errors.New("unknown node")
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+
+	now := time.Now()
+	input := []telegraf.Metric{
+		metric.New("mqtt", map[string]string{"id": "ns=2;s=Boiler.Temperature"}, map[string]interface{}{"value": 21.5}, now),
+		metric.New("mqtt", map[string]string{"id": "ns=2;i=5"}, map[string]interface{}{"value": 3}, now),
+		metric.New("mqtt", map[string]string{"id": "ns=2;s=Boiler.Temperature"}, map[string]interface{}{"value": 22.0}, now),
+		metric.New("mqtt", map[string]string{"id": "ns=2;s=Unknown"}, map[string]interface{}{"value": 1}, now),
+		metric.New("mqtt", map[string]string{"id": "ns=foo;i=1"}, map[string]interface{}{"value": 1}, now),
+		metric.New("mqtt", map[string]string{}, map[string]interface{}{"value": 1}, now),
+	}
+	for _, m := range input {
+		require.NoError(t, plugin.Add(m, &acc))
+	}
+	plugin.Stop()
+
+	tags := map[string]string{
+		"id":                      "ns=2;s=Boiler.Temperature",
+		"opcua_display_name":      "Temperature",
+		"opcua_engineering_units": "°C",
+		"opcua_path":              "Plant/Boiler",
+	}
+	expected := []telegraf.Metric{
+		metric.New("mqtt", tags, map[string]interface{}{"value": 21.5}, now),
+		metric.New("mqtt", map[string]string{"id": "ns=2;i=5", "opcua_display_name": "Level"}, map[string]interface{}{"value": 3}, now),
+		metric.New("mqtt", tags, map[string]interface{}{"value": 22.0}, now),
+		metric.New("mqtt", map[string]string{"id": "ns=2;s=Unknown"}, map[string]interface{}{"value": 1}, now),
+		metric.New("mqtt", map[string]string{"id": "ns=foo;i=1"}, map[string]interface{}{"value": 1}, now),
+		metric.New("mqtt", map[string]string{}, map[string]interface{}{"value": 1}, now),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+
+	// The second lookup of a node must be served from cache while failed
+	// lookups are not cached
+	require.Equal(t, int32(3), calls.Load())
+}
+
+func TestCache(t *testing.T) {
+	c := newCache(2, time.Hour)
+	c.put("a", map[string]string{"DisplayName": "a"})
+	c.put("b", map[string]string{"DisplayName": "b"})
+
+	// Access "a" so "b" becomes the least recently used entry
+	_, found := c.get("a")
+	require.True(t, found)
+	c.put("c", map[string]string{"DisplayName": "c"})
+
+	_, found = c.get("b")
+	require.False(t, found)
+	v, found := c.get("a")
+	require.True(t, found)
+	require.Equal(t, "a", v["DisplayName"])
+	_, found = c.get("c")
+	require.True(t, found)
+
+	// Expired entries are not returned
+	c.ttl = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	_, found = c.get("a")
+	require.False(t, found)
+}
@@ -0,0 +1,213 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package rate
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Rate struct {
+	Fields       []string        `toml:"fields"`
+	Suffix       string          `toml:"suffix"`
+	RateUnit     config.Duration `toml:"rate_unit"`
+	Rollover     string          `toml:"rollover"`
+	DropOriginal bool            `toml:"drop_original"`
+	Expiry       config.Duration `toml:"expiry"`
+	Log          telegraf.Logger `toml:"-"`
+
+	filter     filter.Filter
+	samples    map[uint64]map[string]*sample
+	seen       map[uint64]time.Time
+	lastExpiry time.Time
+}
+
+// sample is the last value of a counter, integer values are kept in their
+// original form to compute exact differences and rollovers
+type sample struct {
+	value   float64
+	integer bool
+	raw     uint64
+	time    time.Time
+}
+
+func (*Rate) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *Rate) Init() error {
+	if len(p.Fields) == 0 {
+		return errors.New("no fields configured")
+	}
+	f, err := filter.Compile(p.Fields)
+	if err != nil {
+		return fmt.Errorf("creating field filter failed: %w", err)
+	}
+	p.filter = f
+
+	if p.Suffix == "" {
+		return errors.New("'suffix' must not be empty")
+	}
+	if p.RateUnit <= 0 {
+		return errors.New("'rate_unit' must be positive")
+	}
+
+	if p.Rollover == "" {
+		p.Rollover = "auto"
+	}
+	if err := choice.Check(p.Rollover, []string{"none", "32bit", "64bit", "auto"}); err != nil {
+		return fmt.Errorf("invalid 'rollover': %w", err)
+	}
+	if p.Expiry < 0 {
+		return errors.New("'expiry' must not be negative")
+	}
+
+	p.samples = make(map[uint64]map[string]*sample)
+	p.seen = make(map[uint64]time.Time)
+
+	return nil
+}
+
+func (p *Rate) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	now := time.Now()
+	p.expire(now)
+
+	for _, m := range in {
+		id := m.HashID()
+		ts := m.Time()
+
+		rates := make(map[string]interface{})
+		var counters []string
+		for _, field := range m.FieldList() {
+			if !p.filter.Match(field.Key) {
+				continue
+			}
+			current, ok := newSample(field.Value, ts)
+			if !ok {
+				continue
+			}
+			counters = append(counters, field.Key)
+
+			samples := p.samples[id]
+			if samples == nil {
+				samples = make(map[string]*sample)
+				p.samples[id] = samples
+			}
+			p.seen[id] = now
+
+			// The first value of a counter has no rate
+			previous, found := samples[field.Key]
+			if !found {
+				samples[field.Key] = current
+				continue
+			}
+			elapsed := ts.Sub(previous.time)
+			if elapsed <= 0 {
+				p.Log.Debugf("Ignoring out-of-order value of field %q of %q at %v", field.Key, m.Name(), ts)
+				continue
+			}
+			samples[field.Key] = current
+
+			delta, ok := p.delta(previous, current)
+			if !ok {
+				p.Log.Debugf("Counter reset of field %q of %q at %v", field.Key, m.Name(), ts)
+				continue
+			}
+			rates[field.Key+p.Suffix] = delta / elapsed.Seconds() * time.Duration(p.RateUnit).Seconds()
+		}
+
+		if p.DropOriginal {
+			for _, key := range counters {
+				m.RemoveField(key)
+			}
+		}
+		for k, v := range rates {
+			m.AddField(k, v)
+		}
+	}
+	return in
+}
+
+// expire removes the samples of series without metrics within the expiry
+func (p *Rate) expire(now time.Time) {
+	// No need to check the series too often
+	if p.Expiry <= 0 || now.Sub(p.lastExpiry) < time.Duration(p.Expiry) {
+		return
+	}
+	p.lastExpiry = now
+
+	for id, seen := range p.seen {
+		if now.Sub(seen) >= time.Duration(p.Expiry) {
+			delete(p.samples, id)
+			delete(p.seen, id)
+		}
+	}
+}
+
+// delta returns the increase of the counter between the samples taking
+// rollovers into account, counter resets are reported as invalid
+func (p *Rate) delta(previous, current *sample) (float64, bool) {
+	if previous.integer && current.integer {
+		if current.raw >= previous.raw {
+			return float64(current.raw - previous.raw), true
+		}
+	} else if current.value >= previous.value {
+		return current.value - previous.value, true
+	}
+
+	if !previous.integer || !current.integer || p.Rollover == "none" {
+		return 0, false
+	}
+
+	// Only decreases from the upper half of the range are rollovers as
+	// counter resets usually restart from small values
+	limit := uint64(math.MaxUint64)
+	switch p.Rollover {
+	case "32bit":
+		limit = math.MaxUint32
+	case "auto":
+		if previous.raw <= math.MaxUint32 {
+			limit = math.MaxUint32
+		}
+	}
+	if previous.raw > limit || previous.raw <= limit/2 {
+		return 0, false
+	}
+	return float64(limit-previous.raw+current.raw) + 1, true
+}
+
+func newSample(value interface{}, ts time.Time) (*sample, bool) {
+	switch v := value.(type) {
+	case int64:
+		if v < 0 {
+			return &sample{value: float64(v), time: ts}, true
+		}
+		return &sample{value: float64(v), integer: true, raw: uint64(v), time: ts}, true
+	case uint64:
+		return &sample{value: float64(v), integer: true, raw: v, time: ts}, true
+	case float64:
+		return &sample{value: v, time: ts}, true
+	}
+	return nil, false
+}
+
+func init() {
+	processors.Add("rate", func() telegraf.Processor {
+		return &Rate{
+			Suffix:   "_rate",
+			RateUnit: config.Duration(time.Second),
+			Expiry:   config.Duration(time.Hour),
+		}
+	})
+}
@@ -0,0 +1,273 @@
+package rate
+
+import (
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Rate
+		expected string
+	}{
+		{
+			name:     "no fields",
+			plugin:   &Rate{},
+			expected: "no fields configured",
+		},
+		{
+			name:     "invalid field filter",
+			plugin:   &Rate{Fields: []string{"a[b"}},
+			expected: "creating field filter failed",
+		},
+		{
+			name:     "empty suffix",
+			plugin:   &Rate{Fields: []string{"bytes"}},
+			expected: "'suffix' must not be empty",
+		},
+		{
+			name:     "invalid rate unit",
+			plugin:   &Rate{Fields: []string{"bytes"}, Suffix: "_rate"},
+			expected: "'rate_unit' must be positive",
+		},
+		{
+			name:     "invalid rollover",
+			plugin:   &Rate{Fields: []string{"bytes"}, Suffix: "_rate", RateUnit: config.Duration(time.Second), Rollover: "16bit"},
+			expected: "invalid 'rollover'",
+		},
+		{
+			name:     "negative expiry",
+			plugin:   &Rate{Fields: []string{"bytes"}, Suffix: "_rate", RateUnit: config.Duration(time.Second), Expiry: config.Duration(-time.Second)},
+			expected: "'expiry' must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	at := func(sec int) time.Time {
+		return base.Add(time.Duration(sec) * time.Second)
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *Rate
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name:   "rates",
+			plugin: &Rate{Fields: []string{"*_count"}},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"part_count": int64(100), "energy_count": 10.5, "state": "run"}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"part_count": int64(150), "energy_count": 30.5, "state": "run"}, at(10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"part_count": int64(150)}, at(5)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"part_count": int64(150)}, at(20)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"part_count": int64(100), "energy_count": 10.5, "state": "run"}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"part_count": int64(150), "energy_count": 30.5, "state": "run", "part_count_rate": 5.0, "energy_count_rate": 2.0}, at(10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"part_count": int64(150)}, at(5)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"part_count": int64(150), "part_count_rate": 0.0}, at(20)),
+			},
+		},
+		{
+			name:   "rate unit and drop original",
+			plugin: &Rate{Fields: []string{"parts"}, Suffix: "_per_minute", RateUnit: config.Duration(time.Minute), DropOriginal: true},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"parts": uint64(10), "temp": 20.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"parts": uint64(20), "temp": 21.0}, at(30)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"temp": 20.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"temp": 21.0, "parts_per_minute": 20.0}, at(30)),
+			},
+		},
+		{
+			name:   "rollover 32bit",
+			plugin: &Rate{Fields: []string{"bytes"}, Rollover: "32bit"},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"bytes": uint64(math.MaxUint32 - 9)}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"bytes": uint64(10)}, at(10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"bytes": uint64(5)}, at(20)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"bytes": uint64(25)}, at(30)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"bytes": uint64(math.MaxUint32 - 9)}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"bytes": uint64(10), "bytes_rate": 2.0}, at(10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"bytes": uint64(5)}, at(20)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"bytes": uint64(25), "bytes_rate": 2.0}, at(30)),
+			},
+		},
+		{
+			name:   "rollover auto",
+			plugin: &Rate{Fields: []string{"bytes"}},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{"if": "1"}, map[string]interface{}{"bytes": int64(math.MaxUint32)}, at(0)),
+				metric.New("m", map[string]string{"if": "2"}, map[string]interface{}{"bytes": uint64(math.MaxUint64 - 4)}, at(0)),
+				metric.New("m", map[string]string{"if": "1"}, map[string]interface{}{"bytes": int64(9)}, at(10)),
+				metric.New("m", map[string]string{"if": "2"}, map[string]interface{}{"bytes": uint64(15)}, at(10)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{"if": "1"}, map[string]interface{}{"bytes": int64(math.MaxUint32)}, at(0)),
+				metric.New("m", map[string]string{"if": "2"}, map[string]interface{}{"bytes": uint64(math.MaxUint64 - 4)}, at(0)),
+				metric.New("m", map[string]string{"if": "1"}, map[string]interface{}{"bytes": int64(9), "bytes_rate": 1.0}, at(10)),
+				metric.New("m", map[string]string{"if": "2"}, map[string]interface{}{"bytes": uint64(15), "bytes_rate": 2.0}, at(10)),
+			},
+		},
+		{
+			name:   "no rollover",
+			plugin: &Rate{Fields: []string{"bytes"}, Rollover: "none"},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"bytes": uint64(math.MaxUint32 - 9)}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"bytes": uint64(10)}, at(10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"bytes": uint64(30)}, at(20)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"bytes": uint64(math.MaxUint32 - 9)}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"bytes": uint64(10)}, at(10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"bytes": uint64(30), "bytes_rate": 2.0}, at(20)),
+			},
+		},
+		{
+			name:   "float counter reset",
+			plugin: &Rate{Fields: []string{"energy"}},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"energy": 4e9}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"energy": 5.0}, at(10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"energy": 25.0}, at(20)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"energy": 4e9}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"energy": 5.0}, at(10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"energy": 25.0, "energy_rate": 2.0}, at(20)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.plugin.Suffix == "" {
+				tt.plugin.Suffix = "_rate"
+			}
+			if tt.plugin.RateUnit == 0 {
+				tt.plugin.RateUnit = config.Duration(time.Second)
+			}
+			tt.plugin.Log = &testutil.Logger{}
+			require.NoError(t, tt.plugin.Init())
+
+			actual := tt.plugin.Apply(tt.input...)
+			testutil.RequireMetricsEqual(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	expired := metric.New("m", map[string]string{"host": "a"}, map[string]interface{}{"count": int64(10)}, base)
+	active := metric.New("m", map[string]string{"host": "b"}, map[string]interface{}{"count": int64(10)}, base)
+
+	plugin := &Rate{
+		Fields:   []string{"count"},
+		Suffix:   "_rate",
+		RateUnit: config.Duration(time.Second),
+		Expiry:   config.Duration(time.Hour),
+		Log:      &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	plugin.Apply(expired, active)
+	require.Len(t, plugin.samples, 2)
+
+	// Pretend the first series did not receive metrics for longer than the
+	// expiry and the last check is due
+	plugin.seen[expired.HashID()] = time.Now().Add(-2 * time.Hour)
+	plugin.lastExpiry = time.Now().Add(-2 * time.Hour)
+
+	input := []telegraf.Metric{
+		metric.New("m", map[string]string{"host": "b"}, map[string]interface{}{"count": int64(20)}, base.Add(10*time.Second)),
+	}
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{"host": "b"}, map[string]interface{}{"count": int64(20), "count_rate": 1.0}, base.Add(10*time.Second)),
+	}
+	testutil.RequireMetricsEqual(t, expected, plugin.Apply(input...))
+	require.Len(t, plugin.samples, 1)
+	require.Len(t, plugin.seen, 1)
+
+	// The expired series starts over
+	input = []telegraf.Metric{
+		metric.New("m", map[string]string{"host": "a"}, map[string]interface{}{"count": int64(20)}, base.Add(10*time.Second)),
+	}
+	expected = []telegraf.Metric{
+		metric.New("m", map[string]string{"host": "a"}, map[string]interface{}{"count": int64(20)}, base.Add(10*time.Second)),
+	}
+	testutil.RequireMetricsEqual(t, expected, plugin.Apply(input...))
+	require.Len(t, plugin.samples, 2)
+}
+
+func TestTracking(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"count": int64(10)}, base),
+		metric.New("m", map[string]string{}, map[string]interface{}{"count": int64(20)}, base.Add(time.Second)),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"count": int64(10)}, base),
+		metric.New("m", map[string]string{}, map[string]interface{}{"count": int64(20), "count_rate": 10.0}, base.Add(time.Second)),
+	}
+
+	plugin := &Rate{
+		Fields:   []string{"count"},
+		Suffix:   "_rate",
+		RateUnit: config.Duration(time.Second),
+		Log:      &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// Process expected metrics and compare with resulting metrics
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
@@ -0,0 +1,258 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package redact
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+var builtinDetectors = map[string]string{
+	"credentials":  `(?i)(?:password|passwd|pwd|secret|token|api[_-]?key)\s*[=:]\s*([^\s,;&]+)`,
+	"email":        `[a-zA-Z0-9._%+\-]+@[a-zA-Z0-9.\-]+\.[a-zA-Z]{2,}`,
+	"ipv4":         `\b(?:(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1[0-9]{2}|[1-9]?[0-9])\b`,
+	"ipv6":         `(?i)\b(?:(?:[0-9a-f]{1,4}:){7}[0-9a-f]{1,4}|(?:[0-9a-f]{1,4}:){1,6}:(?:[0-9a-f]{1,4}:){0,5}[0-9a-f]{1,4})\b`,
+	"mac":          `(?i)\b(?:[0-9a-f]{2}[:-]){5}[0-9a-f]{2}\b`,
+	"url_userinfo": `[a-zA-Z][a-zA-Z0-9+.\-]*://([^/\s:@]+(?::[^/\s@]*)?)@`,
+}
+
+type Redact struct {
+	Detectors   []string        `toml:"detectors"`
+	Tags        []string        `toml:"tags"`
+	Fields      []string        `toml:"fields"`
+	Mode        string          `toml:"mode"`
+	Replacement string          `toml:"replacement"`
+	Salt        config.Secret   `toml:"salt"`
+	HashLength  int             `toml:"hash_length"`
+	Custom      []*detector     `toml:"detector"`
+	Log         telegraf.Logger `toml:"-"`
+
+	detectors []*detector
+	tokens    *tokens
+}
+
+type detector struct {
+	Name    string   `toml:"name"`
+	Pattern string   `toml:"pattern"`
+	Tags    []string `toml:"tags"`
+	Fields  []string `toml:"fields"`
+
+	re          *regexp.Regexp
+	tagFilter   filter.Filter
+	fieldFilter filter.Filter
+}
+
+// tokens contains the tokens assigned to the redacted values, the fields are
+// exported to allow persisting the state
+type tokens struct {
+	Values   map[string]string `json:"values"`
+	Counters map[string]uint64 `json:"counters"`
+}
+
+func (*Redact) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *Redact) Init() error {
+	if p.Mode == "" {
+		p.Mode = "mask"
+	}
+	if err := choice.Check(p.Mode, []string{"mask", "hash", "tokenize"}); err != nil {
+		return fmt.Errorf("invalid 'mode': %w", err)
+	}
+	if p.Mode == "hash" && (p.HashLength < 1 || p.HashLength > 2*sha256.Size) {
+		return fmt.Errorf("'hash_length' must be between 1 and %d", 2*sha256.Size)
+	}
+
+	p.detectors = make([]*detector, 0, len(p.Detectors)+len(p.Custom))
+	for _, name := range p.Detectors {
+		pattern, found := builtinDetectors[name]
+		if !found {
+			return fmt.Errorf("unknown detector %q", name)
+		}
+		p.detectors = append(p.detectors, &detector{Name: name, Pattern: pattern})
+	}
+	for _, d := range p.Custom {
+		if d.Name == "" {
+			return errors.New("detector without name")
+		}
+		if d.Pattern == "" {
+			return fmt.Errorf("detector %q without pattern", d.Name)
+		}
+		p.detectors = append(p.detectors, d)
+	}
+	if len(p.detectors) == 0 {
+		return errors.New("no detectors configured")
+	}
+
+	for _, d := range p.detectors {
+		if err := d.init(p.Tags, p.Fields); err != nil {
+			return fmt.Errorf("initializing detector %q failed: %w", d.Name, err)
+		}
+	}
+
+	p.tokens = &tokens{
+		Values:   make(map[string]string),
+		Counters: make(map[string]uint64),
+	}
+
+	return nil
+}
+
+func (p *Redact) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	var mac hash.Hash
+	if p.Mode == "hash" {
+		salt, err := p.Salt.Get()
+		if err != nil {
+			p.Log.Errorf("Getting salt failed: %v", err)
+			return in
+		}
+		mac = hmac.New(sha256.New, salt.Bytes())
+		salt.Destroy()
+	}
+
+	for _, m := range in {
+		for _, tag := range m.TagList() {
+			if v := p.redact(mac, tag.Value, func(d *detector) bool { return d.tagFilter.Match(tag.Key) }); v != tag.Value {
+				m.AddTag(tag.Key, v)
+			}
+		}
+		for _, field := range m.FieldList() {
+			s, ok := field.Value.(string)
+			if !ok {
+				continue
+			}
+			if v := p.redact(mac, s, func(d *detector) bool { return d.fieldFilter.Match(field.Key) }); v != s {
+				m.AddField(field.Key, v)
+			}
+		}
+	}
+	return in
+}
+
+func (p *Redact) GetState() interface{} {
+	return p.tokens
+}
+
+func (p *Redact) SetState(state interface{}) error {
+	t, ok := state.(*tokens)
+	if !ok {
+		return fmt.Errorf("state has wrong type %T", state)
+	}
+	if t.Values == nil {
+		t.Values = make(map[string]string)
+	}
+	if t.Counters == nil {
+		t.Counters = make(map[string]uint64)
+	}
+	p.tokens = t
+	return nil
+}
+
+// redact applies all matching detectors to the given value
+func (p *Redact) redact(mac hash.Hash, value string, matches func(*detector) bool) string {
+	for _, d := range p.detectors {
+		if !matches(d) {
+			continue
+		}
+		value = d.replace(value, func(s string) string {
+			return p.replacement(mac, d.Name, s)
+		})
+	}
+	return value
+}
+
+// replacement returns the redacted form of the given sensitive value
+func (p *Redact) replacement(mac hash.Hash, name, value string) string {
+	switch p.Mode {
+	case "hash":
+		mac.Reset()
+		mac.Write([]byte(value))
+		return hex.EncodeToString(mac.Sum(nil))[:p.HashLength]
+	case "tokenize":
+		key := name + "\x00" + value
+		if token, found := p.tokens.Values[key]; found {
+			return token
+		}
+		p.tokens.Counters[name]++
+		token := name + "-" + strconv.FormatUint(p.tokens.Counters[name], 10)
+		p.tokens.Values[key] = token
+		return token
+	}
+	return p.Replacement
+}
+
+func (d *detector) init(tags, fields []string) error {
+	re, err := regexp.Compile(d.Pattern)
+	if err != nil {
+		return fmt.Errorf("compiling pattern failed: %w", err)
+	}
+	d.re = re
+
+	if len(d.Tags) == 0 {
+		d.Tags = tags
+	}
+	if d.tagFilter, err = filter.Compile(d.Tags); err != nil {
+		return fmt.Errorf("creating tag filter failed: %w", err)
+	}
+	if len(d.Fields) == 0 {
+		d.Fields = fields
+	}
+	if d.fieldFilter, err = filter.Compile(d.Fields); err != nil {
+		return fmt.Errorf("creating field filter failed: %w", err)
+	}
+	return nil
+}
+
+// replace substitutes all matches of the pattern in the given value, if the
+// pattern contains a capturing group only the first group is replaced
+func (d *detector) replace(value string, replacement func(string) string) string {
+	matches := d.re.FindAllStringSubmatchIndex(value, -1)
+	if len(matches) == 0 {
+		return value
+	}
+
+	var result []byte
+	var last int
+	for _, match := range matches {
+		start, end := match[0], match[1]
+		if len(match) > 2 {
+			start, end = match[2], match[3]
+		}
+		if start < 0 || start < last {
+			continue
+		}
+		result = append(result, value[last:start]...)
+		result = append(result, replacement(value[start:end])...)
+		last = end
+	}
+	result = append(result, value[last:]...)
+	return string(result)
+}
+
+func init() {
+	processors.Add("redact", func() telegraf.Processor {
+		return &Redact{
+			Detectors:   []string{"credentials", "url_userinfo"},
+			Tags:        []string{"*"},
+			Fields:      []string{"*"},
+			Replacement: "[REDACTED]",
+			HashLength:  16,
+		}
+	})
+}
@@ -0,0 +1,294 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package schema_enforce
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type SchemaEnforce struct {
+	Action                string          `toml:"action"`
+	Fallback              string          `toml:"fallback"`
+	QuarantineMeasurement string          `toml:"quarantine_measurement"`
+	ReasonTag             string          `toml:"reason_tag"`
+	Schemas               []*schema       `toml:"schema"`
+	Log                   telegraf.Logger `toml:"-"`
+}
+
+type schema struct {
+	Measurements []string `toml:"measurements"`
+	RequiredTags []string `toml:"required_tags"`
+	StrictFields bool     `toml:"strict_fields"`
+	Fields       []*field `toml:"field"`
+
+	filter filter.Filter
+	fields map[string]*field
+}
+
+type field struct {
+	Name     string   `toml:"name"`
+	Type     string   `toml:"type"`
+	Required bool     `toml:"required"`
+	Min      *float64 `toml:"min"`
+	Max      *float64 `toml:"max"`
+}
+
+// violation describes why a metric does not match its schema and whether
+// the violation can be fixed
+type violation struct {
+	reason  string
+	fixable bool
+}
+
+func (*SchemaEnforce) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *SchemaEnforce) Init() error {
+	if p.Action == "" {
+		p.Action = "quarantine"
+	}
+	if err := choice.Check(p.Action, []string{"drop", "fix", "quarantine"}); err != nil {
+		return fmt.Errorf("invalid 'action': %w", err)
+	}
+	if p.Fallback == "" {
+		p.Fallback = "quarantine"
+	}
+	if err := choice.Check(p.Fallback, []string{"drop", "quarantine"}); err != nil {
+		return fmt.Errorf("invalid 'fallback': %w", err)
+	}
+	if p.QuarantineMeasurement == "" {
+		p.QuarantineMeasurement = "quarantine"
+	}
+	if p.ReasonTag == "" {
+		p.ReasonTag = "reason"
+	}
+
+	if len(p.Schemas) == 0 {
+		return errors.New("no schemas configured")
+	}
+	for i, s := range p.Schemas {
+		if err := s.init(); err != nil {
+			return fmt.Errorf("schema %d: %w", i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *SchemaEnforce) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	out := make([]telegraf.Metric, 0, len(in))
+	for _, m := range in {
+		s := p.schema(m.Name())
+		if s == nil {
+			out = append(out, m)
+			continue
+		}
+
+		violations := s.validate(m)
+		if len(violations) == 0 {
+			out = append(out, m)
+			continue
+		}
+		p.Log.Debugf("Metric %q violates its schema: %s", m.Name(), violations[0].reason)
+
+		action := p.Action
+		if action == "fix" {
+			for _, v := range violations {
+				if !v.fixable {
+					action = p.Fallback
+					break
+				}
+			}
+		}
+
+		switch action {
+		case "drop":
+			m.Drop()
+			continue
+		case "fix":
+			s.fix(m)
+		case "quarantine":
+			m.AddTag("measurement", m.Name())
+			m.AddTag(p.ReasonTag, violations[0].reason)
+			m.SetName(p.QuarantineMeasurement)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// schema returns the first schema applying to the measurement
+func (p *SchemaEnforce) schema(name string) *schema {
+	for _, s := range p.Schemas {
+		if s.filter.Match(name) {
+			return s
+		}
+	}
+	return nil
+}
+
+func (s *schema) init() error {
+	if len(s.Measurements) == 0 {
+		return errors.New("no measurements configured")
+	}
+	f, err := filter.Compile(s.Measurements)
+	if err != nil {
+		return fmt.Errorf("creating measurement filter failed: %w", err)
+	}
+	s.filter = f
+
+	s.fields = make(map[string]*field, len(s.Fields))
+	for _, fd := range s.Fields {
+		if fd.Name == "" {
+			return errors.New("missing field name")
+		}
+		if _, found := s.fields[fd.Name]; found {
+			return fmt.Errorf("duplicate field %q", fd.Name)
+		}
+		if err := choice.Check(fd.Type, []string{"float", "integer", "unsigned", "string", "boolean"}); err != nil {
+			return fmt.Errorf("invalid type of field %q: %w", fd.Name, err)
+		}
+		if (fd.Min != nil || fd.Max != nil) && (fd.Type == "string" || fd.Type == "boolean") {
+			return fmt.Errorf("range of non-numeric field %q", fd.Name)
+		}
+		if fd.Type == "unsigned" && fd.Max != nil && *fd.Max < 0 {
+			return fmt.Errorf("negative 'max' of unsigned field %q", fd.Name)
+		}
+		if fd.Min != nil && fd.Max != nil && *fd.Min > *fd.Max {
+			return fmt.Errorf("'min' of field %q exceeds 'max'", fd.Name)
+		}
+		s.fields[fd.Name] = fd
+	}
+
+	return nil
+}
+
+// validate returns the violations of the schema in the order of the checks
+func (s *schema) validate(m telegraf.Metric) []violation {
+	var violations []violation
+	for _, key := range s.RequiredTags {
+		if !m.HasTag(key) {
+			violations = append(violations, violation{reason: "missing_tag:" + key})
+		}
+	}
+	for _, fd := range s.Fields {
+		value, found := m.GetField(fd.Name)
+		if !found {
+			if fd.Required {
+				violations = append(violations, violation{reason: "missing_field:" + fd.Name})
+			}
+			continue
+		}
+		converted, err := fd.convert(value)
+		if err != nil {
+			violations = append(violations, violation{reason: "field_type:" + fd.Name})
+			continue
+		}
+		if converted != value {
+			violations = append(violations, violation{reason: "field_type:" + fd.Name, fixable: true})
+		}
+		if fd.clamp(converted) != converted {
+			violations = append(violations, violation{reason: "field_range:" + fd.Name, fixable: true})
+		}
+	}
+	if s.StrictFields {
+		for _, f := range m.FieldList() {
+			if _, found := s.fields[f.Key]; !found {
+				violations = append(violations, violation{reason: "unknown_field:" + f.Key, fixable: true})
+			}
+		}
+	}
+	return violations
+}
+
+// fix converts and clamps the declared fields and removes unknown fields,
+// the metric must not contain unfixable violations
+func (s *schema) fix(m telegraf.Metric) {
+	if s.StrictFields {
+		var unknown []string
+		for _, f := range m.FieldList() {
+			if _, found := s.fields[f.Key]; !found {
+				unknown = append(unknown, f.Key)
+			}
+		}
+		for _, key := range unknown {
+			m.RemoveField(key)
+		}
+	}
+	for _, fd := range s.Fields {
+		value, found := m.GetField(fd.Name)
+		if !found {
+			continue
+		}
+		converted, err := fd.convert(value)
+		if err != nil {
+			continue
+		}
+		if fixed := fd.clamp(converted); fixed != value {
+			m.AddField(fd.Name, fixed)
+		}
+	}
+}
+
+// convert returns the value in the declared type of the field
+func (fd *field) convert(value interface{}) (interface{}, error) {
+	switch fd.Type {
+	case "float":
+		return internal.ToFloat64(value)
+	case "integer":
+		return internal.ToInt64(value)
+	case "unsigned":
+		return internal.ToUint64(value)
+	case "string":
+		return internal.ToString(value)
+	case "boolean":
+		return internal.ToBool(value)
+	}
+	return nil, fmt.Errorf("invalid type %q", fd.Type)
+}
+
+// clamp limits the numeric value to the allowed range of the field
+func (fd *field) clamp(value interface{}) interface{} {
+	switch v := value.(type) {
+	case float64:
+		if fd.Min != nil && v < *fd.Min {
+			return *fd.Min
+		}
+		if fd.Max != nil && v > *fd.Max {
+			return *fd.Max
+		}
+	case int64:
+		if fd.Min != nil && float64(v) < *fd.Min {
+			return int64(math.Ceil(*fd.Min))
+		}
+		if fd.Max != nil && float64(v) > *fd.Max {
+			return int64(math.Floor(*fd.Max))
+		}
+	case uint64:
+		if fd.Min != nil && float64(v) < *fd.Min {
+			return uint64(math.Ceil(*fd.Min))
+		}
+		if fd.Max != nil && float64(v) > *fd.Max {
+			return uint64(math.Floor(*fd.Max))
+		}
+	}
+	return value
+}
+
+func init() {
+	processors.Add("schema_enforce", func() telegraf.Processor {
+		return &SchemaEnforce{}
+	})
+}
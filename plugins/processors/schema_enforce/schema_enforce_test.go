@@ -0,0 +1,296 @@
+package schema_enforce
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestSampleConfig(t *testing.T) {
+	cfg := config.NewConfig()
+	require.NoError(t, cfg.LoadConfigData(testutil.DefaultSampleConfig((&SchemaEnforce{}).SampleConfig()), config.EmptySourcePath))
+	require.Len(t, cfg.Processors, 1)
+
+	proc := cfg.Processors[0].Processor.(processors.HasUnwrap)
+	plugin := proc.Unwrap().(*SchemaEnforce)
+	require.Len(t, plugin.Schemas, 1)
+	require.Len(t, plugin.Schemas[0].Fields, 1)
+}
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *SchemaEnforce
+		expected string
+	}{
+		{
+			name:     "invalid action",
+			plugin:   &SchemaEnforce{Action: "log"},
+			expected: "invalid 'action'",
+		},
+		{
+			name:     "invalid fallback",
+			plugin:   &SchemaEnforce{Action: "fix", Fallback: "fix"},
+			expected: "invalid 'fallback'",
+		},
+		{
+			name:     "no schemas",
+			plugin:   &SchemaEnforce{},
+			expected: "no schemas configured",
+		},
+		{
+			name:     "no measurements",
+			plugin:   &SchemaEnforce{Schemas: []*schema{{}}},
+			expected: "schema 1: no measurements configured",
+		},
+		{
+			name:     "invalid measurement filter",
+			plugin:   &SchemaEnforce{Schemas: []*schema{{Measurements: []string{"a[b"}}}},
+			expected: "schema 1: creating measurement filter failed",
+		},
+		{
+			name: "missing field name",
+			plugin: &SchemaEnforce{Schemas: []*schema{
+				{Measurements: []string{"a"}, Fields: []*field{{Type: "float"}}},
+			}},
+			expected: "schema 1: missing field name",
+		},
+		{
+			name: "duplicate field",
+			plugin: &SchemaEnforce{Schemas: []*schema{
+				{Measurements: []string{"a"}, Fields: []*field{{Name: "v", Type: "float"}, {Name: "v", Type: "integer"}}},
+			}},
+			expected: `schema 1: duplicate field "v"`,
+		},
+		{
+			name: "invalid type",
+			plugin: &SchemaEnforce{Schemas: []*schema{
+				{Measurements: []string{"a"}, Fields: []*field{{Name: "v", Type: "double"}}},
+			}},
+			expected: `schema 1: invalid type of field "v"`,
+		},
+		{
+			name: "range of string",
+			plugin: &SchemaEnforce{Schemas: []*schema{
+				{Measurements: []string{"a"}, Fields: []*field{{Name: "v", Type: "string", Min: ptr(0)}}},
+			}},
+			expected: `schema 1: range of non-numeric field "v"`,
+		},
+		{
+			name: "negative max of unsigned",
+			plugin: &SchemaEnforce{Schemas: []*schema{
+				{Measurements: []string{"a"}, Fields: []*field{{Name: "v", Type: "unsigned", Max: ptr(-1)}}},
+			}},
+			expected: `schema 1: negative 'max' of unsigned field "v"`,
+		},
+		{
+			name: "min exceeds max",
+			plugin: &SchemaEnforce{Schemas: []*schema{
+				{Measurements: []string{"a"}},
+				{Measurements: []string{"b"}, Fields: []*field{{Name: "v", Type: "float", Min: ptr(10), Max: ptr(0)}}},
+			}},
+			expected: `schema 2: 'min' of field "v" exceeds 'max'`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	schemas := func() []*schema {
+		return []*schema{
+			{
+				Measurements: []string{"temperature"},
+				RequiredTags: []string{"sensor"},
+				StrictFields: true,
+				Fields: []*field{
+					{Name: "value", Type: "float", Required: true, Min: ptr(-50), Max: ptr(150)},
+					{Name: "status", Type: "integer", Min: ptr(0), Max: ptr(9)},
+				},
+			},
+			{
+				Measurements: []string{"counter*"},
+				Fields: []*field{
+					{Name: "count", Type: "unsigned", Required: true},
+					{Name: "ok", Type: "boolean"},
+				},
+			},
+		}
+	}
+
+	input := []telegraf.Metric{
+		metric.New("temperature", map[string]string{"sensor": "t1"}, map[string]interface{}{"value": 21.5, "status": int64(1)}, now),
+		metric.New("temperature", map[string]string{}, map[string]interface{}{"value": 21.5}, now),
+		metric.New("temperature", map[string]string{"sensor": "t1"}, map[string]interface{}{"status": int64(1)}, now),
+		metric.New("temperature", map[string]string{"sensor": "t1"}, map[string]interface{}{"value": "n/a"}, now),
+		metric.New("temperature", map[string]string{"sensor": "t1"}, map[string]interface{}{"value": int64(20), "status": int64(12)}, now),
+		metric.New("temperature", map[string]string{"sensor": "t1"}, map[string]interface{}{"value": 200.0, "raw": int64(4711)}, now),
+		metric.New("counter_a", map[string]string{}, map[string]interface{}{"count": int64(5), "ok": "true", "extra": 1.0}, now),
+		metric.New("counter_b", map[string]string{}, map[string]interface{}{"count": int64(-5)}, now),
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": "high"}, now),
+	}
+
+	tests := []struct {
+		name     string
+		action   string
+		fallback string
+		expected []telegraf.Metric
+	}{
+		{
+			name:   "drop",
+			action: "drop",
+			expected: []telegraf.Metric{
+				metric.New("temperature", map[string]string{"sensor": "t1"}, map[string]interface{}{"value": 21.5, "status": int64(1)}, now),
+				metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": "high"}, now),
+			},
+		},
+		{
+			name:   "quarantine",
+			action: "quarantine",
+			expected: []telegraf.Metric{
+				metric.New("temperature", map[string]string{"sensor": "t1"}, map[string]interface{}{"value": 21.5, "status": int64(1)}, now),
+				metric.New("quarantine",
+					map[string]string{"measurement": "temperature", "reason": "missing_tag:sensor"},
+					map[string]interface{}{"value": 21.5},
+					now,
+				),
+				metric.New("quarantine",
+					map[string]string{"measurement": "temperature", "reason": "missing_field:value", "sensor": "t1"},
+					map[string]interface{}{"status": int64(1)},
+					now,
+				),
+				metric.New("quarantine",
+					map[string]string{"measurement": "temperature", "reason": "field_type:value", "sensor": "t1"},
+					map[string]interface{}{"value": "n/a"},
+					now,
+				),
+				metric.New("quarantine",
+					map[string]string{"measurement": "temperature", "reason": "field_type:value", "sensor": "t1"},
+					map[string]interface{}{"value": int64(20), "status": int64(12)},
+					now,
+				),
+				metric.New("quarantine",
+					map[string]string{"measurement": "temperature", "reason": "field_range:value", "sensor": "t1"},
+					map[string]interface{}{"value": 200.0, "raw": int64(4711)},
+					now,
+				),
+				metric.New("quarantine",
+					map[string]string{"measurement": "counter_a", "reason": "field_type:count"},
+					map[string]interface{}{"count": int64(5), "ok": "true", "extra": 1.0},
+					now,
+				),
+				metric.New("quarantine",
+					map[string]string{"measurement": "counter_b", "reason": "field_type:count"},
+					map[string]interface{}{"count": int64(-5)},
+					now,
+				),
+				metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": "high"}, now),
+			},
+		},
+		{
+			name:     "fix",
+			action:   "fix",
+			fallback: "drop",
+			expected: []telegraf.Metric{
+				metric.New("temperature", map[string]string{"sensor": "t1"}, map[string]interface{}{"value": 21.5, "status": int64(1)}, now),
+				metric.New("temperature", map[string]string{"sensor": "t1"}, map[string]interface{}{"value": 20.0, "status": int64(9)}, now),
+				metric.New("temperature", map[string]string{"sensor": "t1"}, map[string]interface{}{"value": 150.0}, now),
+				metric.New("counter_a", map[string]string{}, map[string]interface{}{"count": uint64(5), "ok": true, "extra": 1.0}, now),
+				metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": "high"}, now),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plugin := &SchemaEnforce{
+				Action:   tt.action,
+				Fallback: tt.fallback,
+				Schemas:  schemas(),
+				Log:      &testutil.Logger{},
+			}
+			require.NoError(t, plugin.Init())
+
+			metrics := make([]telegraf.Metric, 0, len(input))
+			for _, m := range input {
+				metrics = append(metrics, m.Copy())
+			}
+			actual := plugin.Apply(metrics...)
+			testutil.RequireMetricsEqual(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestTracking(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("temperature", map[string]string{}, map[string]interface{}{"value": 21.5}, now),
+		metric.New("temperature", map[string]string{}, map[string]interface{}{"value": "n/a"}, now),
+		metric.New("temperature", map[string]string{}, map[string]interface{}{"value": 200.0}, now),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("temperature", map[string]string{}, map[string]interface{}{"value": 21.5}, now),
+		metric.New("temperature", map[string]string{}, map[string]interface{}{"value": 150.0}, now),
+	}
+
+	plugin := &SchemaEnforce{
+		Action:   "fix",
+		Fallback: "drop",
+		Schemas: []*schema{{
+			Measurements: []string{"temperature"},
+			Fields:       []*field{{Name: "value", Type: "float", Max: ptr(150)}},
+		}},
+		Log: &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// Process expected metrics and compare with resulting metrics
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
+
+func ptr(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,273 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package sparkplug_alias
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+const (
+	namespace     = "spBv1.0"
+	rebirthMetric = "Node Control/Rebirth"
+)
+
+type SparkplugAlias struct {
+	TopicTag           string          `toml:"topic_tag"`
+	NameKey            string          `toml:"name_key"`
+	AliasKey           string          `toml:"alias_key"`
+	UnknownAlias       string          `toml:"unknown_alias"`
+	RebirthMeasurement string          `toml:"rebirth_measurement"`
+	RebirthInterval    config.Duration `toml:"rebirth_interval"`
+	Log                telegraf.Logger `toml:"-"`
+
+	nodes map[string]*edgeNode
+}
+
+// edgeNode contains the aliases of the metrics of an edge node and its
+// devices. Aliases are unique across the node and its devices.
+type edgeNode struct {
+	aliases     map[uint64]definition
+	lastRebirth time.Time
+}
+
+type definition struct {
+	name   string
+	device string
+}
+
+// topic is the decoded Sparkplug topic of a message
+type topic struct {
+	group       string
+	messageType string
+	node        string
+	device      string
+}
+
+func (*SparkplugAlias) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *SparkplugAlias) Init() error {
+	if p.TopicTag == "" {
+		p.TopicTag = "topic"
+	}
+	if p.NameKey == "" {
+		p.NameKey = "name"
+	}
+	if p.AliasKey == "" {
+		p.AliasKey = "alias"
+	}
+	if p.UnknownAlias == "" {
+		p.UnknownAlias = "drop"
+	}
+	if err := choice.Check(p.UnknownAlias, []string{"drop", "keep"}); err != nil {
+		return fmt.Errorf("invalid 'unknown_alias': %w", err)
+	}
+	if p.RebirthInterval < 0 {
+		return errors.New("'rebirth_interval' must not be negative")
+	}
+
+	p.nodes = make(map[string]*edgeNode)
+
+	return nil
+}
+
+func (p *SparkplugAlias) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	out := make([]telegraf.Metric, 0, len(in))
+	for _, m := range in {
+		value, found := m.GetTag(p.TopicTag)
+		if !found {
+			out = append(out, m)
+			continue
+		}
+		t, err := parseTopic(value)
+		if err != nil {
+			out = append(out, m)
+			continue
+		}
+
+		key := t.group + "/" + t.node
+		switch t.messageType {
+		case "NBIRTH", "DBIRTH":
+			// The metrics of a birth message might arrive as separate metrics
+			// so overwrite the aliases instead of forgetting the known ones
+			p.register(p.node(key), t, m)
+		case "NDEATH":
+			delete(p.nodes, key)
+		case "DDEATH":
+			if node, found := p.nodes[key]; found {
+				node.forget(t.device)
+			}
+		case "NDATA", "DDATA", "NCMD", "DCMD":
+			if !p.resolve(key, m) {
+				if rebirth := p.rebirth(t); rebirth != nil {
+					out = append(out, rebirth)
+				}
+				if p.UnknownAlias == "drop" {
+					m.Drop()
+					continue
+				}
+			}
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// node returns the edge node creating it if necessary
+func (p *SparkplugAlias) node(key string) *edgeNode {
+	node, found := p.nodes[key]
+	if !found {
+		node = &edgeNode{aliases: make(map[uint64]definition)}
+		p.nodes[key] = node
+	}
+	return node
+}
+
+// register stores the alias announced by the birth metric
+func (p *SparkplugAlias) register(node *edgeNode, t *topic, m telegraf.Metric) {
+	name, found := p.name(m)
+	if !found {
+		return
+	}
+	alias, found, err := p.alias(m)
+	if err != nil {
+		p.Log.Warnf("Ignoring alias of metric %q of edge node %q: %v", name, t.group+"/"+t.node, err)
+		return
+	}
+	if found {
+		node.aliases[alias] = definition{name: name, device: t.device}
+	}
+}
+
+// resolve adds the name of metrics only containing an alias, returns false
+// if the alias is unknown
+func (p *SparkplugAlias) resolve(key string, m telegraf.Metric) bool {
+	if _, found := p.name(m); found {
+		return true
+	}
+	alias, found, err := p.alias(m)
+	if err != nil {
+		p.Log.Warnf("Invalid alias in metric of edge node %q: %v", key, err)
+		return false
+	}
+	if !found {
+		return true
+	}
+
+	var def definition
+	node, found := p.nodes[key]
+	if found {
+		def, found = node.aliases[alias]
+	}
+	if !found {
+		p.Log.Debugf("Unknown alias %d of edge node %q", alias, key)
+		return false
+	}
+	m.AddTag(p.NameKey, def.name)
+	return true
+}
+
+// rebirth returns a rebirth request for the edge node if enabled and the
+// last request is older than the rebirth interval
+func (p *SparkplugAlias) rebirth(t *topic) telegraf.Metric {
+	if p.RebirthMeasurement == "" {
+		return nil
+	}
+	node := p.node(t.group + "/" + t.node)
+	now := time.Now()
+	if !node.lastRebirth.IsZero() && now.Sub(node.lastRebirth) < time.Duration(p.RebirthInterval) {
+		return nil
+	}
+	node.lastRebirth = now
+
+	tags := map[string]string{
+		"group_id":     t.group,
+		"edge_node_id": t.node,
+		p.TopicTag:     namespace + "/" + t.group + "/NCMD/" + t.node,
+	}
+	fields := map[string]interface{}{rebirthMetric: true}
+	return metric.New(p.RebirthMeasurement, tags, fields, now)
+}
+
+// name returns the name of the Sparkplug metric from the tag or field
+func (p *SparkplugAlias) name(m telegraf.Metric) (string, bool) {
+	if v, found := m.GetTag(p.NameKey); found {
+		return v, true
+	}
+	if v, found := m.GetField(p.NameKey); found {
+		if s, ok := v.(string); ok {
+			return s, true
+		}
+	}
+	return "", false
+}
+
+// alias returns the alias of the Sparkplug metric from the tag or field
+func (p *SparkplugAlias) alias(m telegraf.Metric) (uint64, bool, error) {
+	if v, found := m.GetTag(p.AliasKey); found {
+		alias, err := strconv.ParseUint(v, 10, 64)
+		return alias, true, err
+	}
+	v, found := m.GetField(p.AliasKey)
+	if !found {
+		return 0, false, nil
+	}
+	switch v := v.(type) {
+	case uint64:
+		return v, true, nil
+	case int64:
+		if v < 0 {
+			return 0, true, fmt.Errorf("negative alias %d", v)
+		}
+		return uint64(v), true, nil
+	case string:
+		alias, err := strconv.ParseUint(v, 10, 64)
+		return alias, true, err
+	}
+	return 0, true, fmt.Errorf("invalid alias type %T", v)
+}
+
+// forget removes the aliases of the given device
+func (n *edgeNode) forget(device string) {
+	for alias, def := range n.aliases {
+		if def.device == device {
+			delete(n.aliases, alias)
+		}
+	}
+}
+
+// parseTopic decodes a Sparkplug topic of the form
+// spBv1.0/<group>/<message type>/<edge node>[/<device>]
+func parseTopic(t string) (*topic, error) {
+	parts := strings.Split(t, "/")
+	if (len(parts) != 4 && len(parts) != 5) || parts[0] != namespace {
+		return nil, fmt.Errorf("invalid Sparkplug B topic %q", t)
+	}
+	tp := &topic{group: parts[1], messageType: parts[2], node: parts[3]}
+	if len(parts) == 5 {
+		tp.device = parts[4]
+	}
+	return tp, nil
+}
+
+func init() {
+	processors.Add("sparkplug_alias", func() telegraf.Processor {
+		return &SparkplugAlias{
+			RebirthInterval: config.Duration(30 * time.Second),
+		}
+	})
+}
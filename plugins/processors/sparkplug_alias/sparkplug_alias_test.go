@@ -0,0 +1,205 @@
+package sparkplug_alias
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *SparkplugAlias
+		expected string
+	}{
+		{
+			name:     "invalid unknown alias handling",
+			plugin:   &SparkplugAlias{UnknownAlias: "warn"},
+			expected: "invalid 'unknown_alias'",
+		},
+		{
+			name:     "negative rebirth interval",
+			plugin:   &SparkplugAlias{RebirthInterval: config.Duration(-time.Second)},
+			expected: "'rebirth_interval' must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	sp := func(tp string, tags map[string]string, fields map[string]interface{}) telegraf.Metric {
+		tags["topic"] = "spBv1.0/plant/" + tp
+		return metric.New("mqtt_consumer", tags, fields, now)
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *SparkplugAlias
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name:   "resolve",
+			plugin: &SparkplugAlias{},
+			input: []telegraf.Metric{
+				sp("NBIRTH/line1", map[string]string{}, map[string]interface{}{"name": "Temperature", "alias": int64(1), "value": 21.5}),
+				sp("NBIRTH/line1", map[string]string{}, map[string]interface{}{"name": "Running", "alias": int64(2), "value": true}),
+				sp("DBIRTH/line1/press", map[string]string{"name": "Pressure", "alias": "3"}, map[string]interface{}{"value": 1.2}),
+				sp("NDATA/line1", map[string]string{}, map[string]interface{}{"alias": int64(1), "value": 22.0}),
+				sp("NDATA/line1", map[string]string{}, map[string]interface{}{"alias": uint64(2), "value": false}),
+				sp("DDATA/line1/press", map[string]string{"alias": "3"}, map[string]interface{}{"value": 1.3}),
+				sp("NDATA/line1", map[string]string{}, map[string]interface{}{"name": "Counter", "value": int64(5)}),
+				sp("NDATA/line2", map[string]string{}, map[string]interface{}{"alias": int64(1), "value": 5.0}),
+				metric.New("cpu", map[string]string{"topic": "sensors/cpu"}, map[string]interface{}{"alias": int64(1)}, now),
+			},
+			expected: []telegraf.Metric{
+				sp("NBIRTH/line1", map[string]string{}, map[string]interface{}{"name": "Temperature", "alias": int64(1), "value": 21.5}),
+				sp("NBIRTH/line1", map[string]string{}, map[string]interface{}{"name": "Running", "alias": int64(2), "value": true}),
+				sp("DBIRTH/line1/press", map[string]string{"name": "Pressure", "alias": "3"}, map[string]interface{}{"value": 1.2}),
+				sp("NDATA/line1", map[string]string{"name": "Temperature"}, map[string]interface{}{"alias": int64(1), "value": 22.0}),
+				sp("NDATA/line1", map[string]string{"name": "Running"}, map[string]interface{}{"alias": uint64(2), "value": false}),
+				sp("DDATA/line1/press", map[string]string{"name": "Pressure", "alias": "3"}, map[string]interface{}{"value": 1.3}),
+				sp("NDATA/line1", map[string]string{}, map[string]interface{}{"name": "Counter", "value": int64(5)}),
+				metric.New("cpu", map[string]string{"topic": "sensors/cpu"}, map[string]interface{}{"alias": int64(1)}, now),
+			},
+		},
+		{
+			name:   "death",
+			plugin: &SparkplugAlias{UnknownAlias: "keep"},
+			input: []telegraf.Metric{
+				sp("NBIRTH/line1", map[string]string{}, map[string]interface{}{"name": "Temperature", "alias": int64(1)}),
+				sp("DBIRTH/line1/press", map[string]string{}, map[string]interface{}{"name": "Pressure", "alias": int64(3)}),
+				sp("DDEATH/line1/press", map[string]string{}, map[string]interface{}{"seq": int64(4)}),
+				sp("DDATA/line1/press", map[string]string{}, map[string]interface{}{"alias": int64(3), "value": 1.3}),
+				sp("NDATA/line1", map[string]string{}, map[string]interface{}{"alias": int64(1), "value": 22.0}),
+				sp("NDEATH/line1", map[string]string{}, map[string]interface{}{"seq": int64(5)}),
+				sp("NDATA/line1", map[string]string{}, map[string]interface{}{"alias": int64(1), "value": 23.0}),
+			},
+			expected: []telegraf.Metric{
+				sp("NBIRTH/line1", map[string]string{}, map[string]interface{}{"name": "Temperature", "alias": int64(1)}),
+				sp("DBIRTH/line1/press", map[string]string{}, map[string]interface{}{"name": "Pressure", "alias": int64(3)}),
+				sp("DDEATH/line1/press", map[string]string{}, map[string]interface{}{"seq": int64(4)}),
+				sp("DDATA/line1/press", map[string]string{}, map[string]interface{}{"alias": int64(3), "value": 1.3}),
+				sp("NDATA/line1", map[string]string{"name": "Temperature"}, map[string]interface{}{"alias": int64(1), "value": 22.0}),
+				sp("NDEATH/line1", map[string]string{}, map[string]interface{}{"seq": int64(5)}),
+				sp("NDATA/line1", map[string]string{}, map[string]interface{}{"alias": int64(1), "value": 23.0}),
+			},
+		},
+		{
+			name:   "custom keys",
+			plugin: &SparkplugAlias{TopicTag: "mqtt_topic", NameKey: "metric", AliasKey: "id"},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{"mqtt_topic": "spBv1.0/plant/NBIRTH/line1"}, map[string]interface{}{"metric": "Speed", "id": int64(7)}, now),
+				metric.New("m", map[string]string{"mqtt_topic": "spBv1.0/plant/NDATA/line1"}, map[string]interface{}{"id": int64(7), "value": 3.0}, now),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{"mqtt_topic": "spBv1.0/plant/NBIRTH/line1"}, map[string]interface{}{"metric": "Speed", "id": int64(7)}, now),
+				metric.New("m", map[string]string{"mqtt_topic": "spBv1.0/plant/NDATA/line1", "metric": "Speed"}, map[string]interface{}{"id": int64(7), "value": 3.0}, now),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			require.NoError(t, tt.plugin.Init())
+
+			actual := tt.plugin.Apply(tt.input...)
+			testutil.RequireMetricsEqual(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestRebirth(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	plugin := &SparkplugAlias{
+		RebirthMeasurement: "sparkplug_rebirth",
+		RebirthInterval:    config.Duration(time.Hour),
+		Log:                &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	input := []telegraf.Metric{
+		metric.New("mqtt_consumer", map[string]string{"topic": "spBv1.0/plant/NDATA/line1"}, map[string]interface{}{"alias": int64(1), "value": 1.0}, now),
+		metric.New("mqtt_consumer", map[string]string{"topic": "spBv1.0/plant/DDATA/line1/press"}, map[string]interface{}{"alias": int64(2), "value": 2.0}, now),
+		metric.New("mqtt_consumer", map[string]string{"topic": "spBv1.0/plant/NDATA/line2"}, map[string]interface{}{"alias": int64(1), "value": 3.0}, now),
+	}
+	expected := []telegraf.Metric{
+		metric.New("sparkplug_rebirth",
+			map[string]string{"group_id": "plant", "edge_node_id": "line1", "topic": "spBv1.0/plant/NCMD/line1"},
+			map[string]interface{}{"Node Control/Rebirth": true},
+			time.Unix(0, 0),
+		),
+		metric.New("sparkplug_rebirth",
+			map[string]string{"group_id": "plant", "edge_node_id": "line2", "topic": "spBv1.0/plant/NCMD/line2"},
+			map[string]interface{}{"Node Control/Rebirth": true},
+			time.Unix(0, 0),
+		),
+	}
+
+	// Only a single request must be emitted per node within the interval
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual, testutil.IgnoreTime())
+}
+
+func TestTracking(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("mqtt_consumer", map[string]string{"topic": "spBv1.0/plant/NBIRTH/line1"}, map[string]interface{}{"name": "Speed", "alias": int64(1)}, now),
+		metric.New("mqtt_consumer", map[string]string{"topic": "spBv1.0/plant/NDATA/line1"}, map[string]interface{}{"alias": int64(1), "value": 1.0}, now),
+		metric.New("mqtt_consumer", map[string]string{"topic": "spBv1.0/plant/NDATA/line1"}, map[string]interface{}{"alias": int64(2), "value": 2.0}, now),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("mqtt_consumer", map[string]string{"topic": "spBv1.0/plant/NBIRTH/line1"}, map[string]interface{}{"name": "Speed", "alias": int64(1)}, now),
+		metric.New("mqtt_consumer", map[string]string{"topic": "spBv1.0/plant/NDATA/line1", "name": "Speed"}, map[string]interface{}{"alias": int64(1), "value": 1.0}, now),
+	}
+
+	plugin := &SparkplugAlias{Log: &testutil.Logger{}}
+	require.NoError(t, plugin.Init())
+
+	// Process expected metrics and compare with resulting metrics
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
@@ -0,0 +1,173 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package stale
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Stale struct {
+	Heartbeat        config.Duration `toml:"heartbeat"`
+	MaxAge           config.Duration `toml:"max_age"`
+	HeldField        string          `toml:"held_field"`
+	StaleMeasurement string          `toml:"stale_measurement"`
+	StaleField       string          `toml:"stale_field"`
+	Log              telegraf.Logger `toml:"-"`
+
+	now    func() time.Time
+	acc    telegraf.Accumulator
+	cache  map[uint64]*series
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	sync.Mutex
+}
+
+// series holds the last value of each field of a series
+type series struct {
+	name     string
+	tags     map[string]string
+	tp       telegraf.ValueType
+	fields   map[string]interface{}
+	received time.Time
+	emitted  time.Time
+}
+
+func (*Stale) SampleConfig() string {
+	return sampleConfig
+}
+
+func (s *Stale) Init() error {
+	if s.Heartbeat <= 0 {
+		return errors.New("'heartbeat' must be positive")
+	}
+	if s.MaxAge < s.Heartbeat {
+		return errors.New("'max_age' must not be less than 'heartbeat'")
+	}
+	if s.StaleField == "" {
+		s.StaleField = "stale"
+	}
+
+	if s.now == nil {
+		s.now = time.Now
+	}
+	s.cache = make(map[uint64]*series)
+
+	return nil
+}
+
+func (s *Stale) Start(acc telegraf.Accumulator) error {
+	s.acc = acc
+
+	// Check for due heartbeats regularly but at least once per heartbeat
+	interval := min(time.Duration(s.Heartbeat), time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				s.emit(s.now())
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (s *Stale) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
+	now := s.now()
+
+	s.Lock()
+	id := m.HashID()
+	entry, found := s.cache[id]
+	if !found {
+		entry = &series{
+			name:   m.Name(),
+			tags:   m.Tags(),
+			fields: make(map[string]interface{}),
+		}
+		s.cache[id] = entry
+	}
+	entry.tp = m.Type()
+	for _, field := range m.FieldList() {
+		entry.fields[field.Key] = field.Value
+	}
+	entry.received = now
+	entry.emitted = now
+	s.Unlock()
+
+	acc.AddMetric(m)
+	return nil
+}
+
+func (s *Stale) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// emit re-emits the last values of all series without a metric within the
+// heartbeat and marks the series exceeding the maximum age as stale
+func (s *Stale) emit(now time.Time) {
+	s.Lock()
+	defer s.Unlock()
+
+	for id, entry := range s.cache {
+		if now.Sub(entry.received) >= time.Duration(s.MaxAge) {
+			s.acc.AddMetric(s.marker(entry, now))
+			delete(s.cache, id)
+			continue
+		}
+		if now.Sub(entry.emitted) < time.Duration(s.Heartbeat) {
+			continue
+		}
+
+		fields := make(map[string]interface{}, len(entry.fields)+1)
+		for k, v := range entry.fields {
+			fields[k] = v
+		}
+		if s.HeldField != "" {
+			fields[s.HeldField] = true
+		}
+		s.acc.AddMetric(metric.New(entry.name, entry.tags, fields, now, entry.tp))
+		entry.emitted = now
+	}
+}
+
+// marker creates the metric marking the series as stale
+func (s *Stale) marker(entry *series, now time.Time) telegraf.Metric {
+	name := entry.name
+	if s.StaleMeasurement != "" {
+		name = s.StaleMeasurement
+	}
+	fields := map[string]interface{}{s.StaleField: true}
+	return metric.New(name, entry.tags, fields, now)
+}
+
+func init() {
+	processors.AddStreaming("stale", func() telegraf.StreamingProcessor {
+		return &Stale{
+			Heartbeat: config.Duration(10 * time.Second),
+			MaxAge:    config.Duration(5 * time.Minute),
+		}
+	})
+}
@@ -0,0 +1,225 @@
+package stale
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Stale
+		expected string
+	}{
+		{
+			name:     "no heartbeat",
+			plugin:   &Stale{MaxAge: config.Duration(time.Minute)},
+			expected: "'heartbeat' must be positive",
+		},
+		{
+			name: "max age below heartbeat",
+			plugin: &Stale{
+				Heartbeat: config.Duration(time.Minute),
+				MaxAge:    config.Duration(time.Second),
+			},
+			expected: "'max_age' must not be less than 'heartbeat'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	at := func(sec int) time.Time {
+		return base.Add(time.Duration(sec) * time.Second)
+	}
+
+	type step struct {
+		now   int
+		input []telegraf.Metric
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *Stale
+		steps    []step
+		expected []telegraf.Metric
+	}{
+		{
+			name:   "hold and mark stale",
+			plugin: &Stale{},
+			steps: []step{
+				{now: 0, input: []telegraf.Metric{
+					metric.New("valve", map[string]string{"id": "1"}, map[string]interface{}{"open": true, "position": 80.0}, at(0)),
+				}},
+				{now: 5},
+				{now: 10},
+				{now: 15, input: []telegraf.Metric{
+					metric.New("valve", map[string]string{"id": "1"}, map[string]interface{}{"position": 60.0}, at(15)),
+				}},
+				{now: 20},
+				{now: 25},
+				{now: 35},
+				{now: 45},
+				{now: 50},
+			},
+			expected: []telegraf.Metric{
+				metric.New("valve", map[string]string{"id": "1"}, map[string]interface{}{"open": true, "position": 80.0}, at(0)),
+				metric.New("valve", map[string]string{"id": "1"}, map[string]interface{}{"open": true, "position": 80.0}, at(10)),
+				metric.New("valve", map[string]string{"id": "1"}, map[string]interface{}{"position": 60.0}, at(15)),
+				metric.New("valve", map[string]string{"id": "1"}, map[string]interface{}{"open": true, "position": 60.0}, at(25)),
+				metric.New("valve", map[string]string{"id": "1"}, map[string]interface{}{"open": true, "position": 60.0}, at(35)),
+				metric.New("valve", map[string]string{"id": "1"}, map[string]interface{}{"stale": true}, at(45)),
+			},
+		},
+		{
+			name: "custom fields",
+			plugin: &Stale{
+				HeldField:        "held",
+				StaleMeasurement: "status",
+				StaleField:       "expired",
+			},
+			steps: []step{
+				{now: 0, input: []telegraf.Metric{
+					metric.New("pump", map[string]string{"id": "a"}, map[string]interface{}{"speed": int64(1200)}, at(0)),
+					metric.New("pump", map[string]string{"id": "b"}, map[string]interface{}{"speed": int64(900)}, at(0)),
+				}},
+				{now: 20, input: []telegraf.Metric{
+					metric.New("pump", map[string]string{"id": "b"}, map[string]interface{}{"speed": int64(950)}, at(20)),
+				}},
+				{now: 30},
+				{now: 40},
+			},
+			expected: []telegraf.Metric{
+				metric.New("pump", map[string]string{"id": "a"}, map[string]interface{}{"speed": int64(1200)}, at(0)),
+				metric.New("pump", map[string]string{"id": "b"}, map[string]interface{}{"speed": int64(900)}, at(0)),
+				metric.New("pump", map[string]string{"id": "b"}, map[string]interface{}{"speed": int64(950)}, at(20)),
+				metric.New("pump", map[string]string{"id": "a"}, map[string]interface{}{"speed": int64(1200), "held": true}, at(20)),
+				metric.New("status", map[string]string{"id": "a"}, map[string]interface{}{"expired": true}, at(30)),
+				metric.New("pump", map[string]string{"id": "b"}, map[string]interface{}{"speed": int64(950), "held": true}, at(30)),
+				metric.New("pump", map[string]string{"id": "b"}, map[string]interface{}{"speed": int64(950), "held": true}, at(40)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var now time.Time
+			tt.plugin.Heartbeat = config.Duration(10 * time.Second)
+			tt.plugin.MaxAge = config.Duration(30 * time.Second)
+			tt.plugin.Log = &testutil.Logger{}
+			tt.plugin.now = func() time.Time { return now }
+			require.NoError(t, tt.plugin.Init())
+
+			// Drive the heartbeats manually instead of starting the plugin
+			var acc testutil.Accumulator
+			tt.plugin.acc = &acc
+			for _, s := range tt.steps {
+				now = at(s.now)
+				for _, m := range s.input {
+					require.NoError(t, tt.plugin.Add(m, &acc))
+				}
+				tt.plugin.emit(now)
+			}
+
+			testutil.RequireMetricsEqual(t, tt.expected, acc.GetTelegrafMetrics(), testutil.SortMetrics())
+		})
+	}
+}
+
+func TestEmitHeartbeat(t *testing.T) {
+	plugin := &Stale{
+		Heartbeat: config.Duration(100 * time.Millisecond),
+		MaxAge:    config.Duration(time.Minute),
+		HeldField: "held",
+		Log:       &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	now := time.Now()
+	require.NoError(t, plugin.Add(metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now), &acc))
+
+	// The value is re-emitted without further metrics
+	acc.Wait(2)
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0, "held": true}, now),
+	}
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics()[:2], testutil.IgnoreTime())
+}
+
+func TestTracking(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0}, now),
+		metric.New("other", map[string]string{}, map[string]interface{}{"status": "ok"}, now),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1.0}, now),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 2.0}, now),
+		metric.New("other", map[string]string{}, map[string]interface{}{"status": "ok"}, now),
+	}
+
+	plugin := &Stale{
+		Heartbeat: config.Duration(time.Hour),
+		MaxAge:    config.Duration(time.Hour),
+		Log:       &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	for _, m := range input {
+		require.NoError(t, plugin.Add(m, &acc))
+	}
+	plugin.Stop()
+
+	actual := acc.GetTelegrafMetrics()
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
@@ -0,0 +1,107 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package timestamp_align
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type TimestampAlign struct {
+	Interval               config.Duration `toml:"interval"`
+	Offset                 config.Duration `toml:"offset"`
+	Mode                   string          `toml:"mode"`
+	Deduplicate            bool            `toml:"deduplicate"`
+	OriginalTimestampField string          `toml:"original_timestamp_field"`
+
+	last map[uint64]time.Time
+}
+
+func (*TimestampAlign) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *TimestampAlign) Init() error {
+	if p.Interval <= 0 {
+		return errors.New("'interval' must be positive")
+	}
+	if p.Mode == "" {
+		p.Mode = "round"
+	}
+	if err := choice.Check(p.Mode, []string{"round", "floor", "ceil"}); err != nil {
+		return fmt.Errorf("invalid 'mode': %w", err)
+	}
+
+	p.last = make(map[uint64]time.Time)
+
+	return nil
+}
+
+func (p *TimestampAlign) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	out := in[:0]
+	for _, m := range in {
+		ts := p.align(m.Time())
+
+		if p.Deduplicate {
+			id := m.HashID()
+			if last, found := p.last[id]; found && last.Equal(ts) {
+				m.Drop()
+				continue
+			}
+			p.last[id] = ts
+		}
+
+		if p.OriginalTimestampField != "" {
+			m.AddField(p.OriginalTimestampField, m.Time().UnixNano())
+		}
+		m.SetTime(ts)
+		out = append(out, m)
+	}
+	return out
+}
+
+// align returns the boundary of the timestamp according to the mode
+func (p *TimestampAlign) align(ts time.Time) time.Time {
+	interval := int64(p.Interval)
+	offset := int64(p.Offset)
+
+	// Compute the remainder relative to the boundaries, also for timestamps
+	// before the Unix epoch
+	ns := ts.UnixNano()
+	remainder := (ns - offset) % interval
+	if remainder < 0 {
+		remainder += interval
+	}
+	if remainder == 0 {
+		return ts
+	}
+
+	floor := ns - remainder
+	switch p.Mode {
+	case "floor":
+		return time.Unix(0, floor)
+	case "ceil":
+		return time.Unix(0, floor+interval)
+	}
+	if remainder >= interval-remainder {
+		return time.Unix(0, floor+interval)
+	}
+	return time.Unix(0, floor)
+}
+
+func init() {
+	processors.Add("timestamp_align", func() telegraf.Processor {
+		return &TimestampAlign{
+			Interval: config.Duration(time.Second),
+		}
+	})
+}
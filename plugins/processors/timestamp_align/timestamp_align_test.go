@@ -0,0 +1,219 @@
+package timestamp_align
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *TimestampAlign
+		expected string
+	}{
+		{
+			name:     "no interval",
+			plugin:   &TimestampAlign{},
+			expected: "'interval' must be positive",
+		},
+		{
+			name:     "invalid mode",
+			plugin:   &TimestampAlign{Interval: config.Duration(time.Second), Mode: "trunc"},
+			expected: "invalid 'mode'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	at := func(ms int) time.Time {
+		return base.Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	input := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1}, at(0)),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 2}, at(980)),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 3}, at(1500)),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 4}, at(2020)),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 5}, at(2490)),
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *TimestampAlign
+		expected []telegraf.Metric
+	}{
+		{
+			name:   "round",
+			plugin: &TimestampAlign{},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2}, at(1000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 3}, at(2000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 4}, at(2000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 5}, at(2000)),
+			},
+		},
+		{
+			name:   "floor",
+			plugin: &TimestampAlign{Mode: "floor"},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 3}, at(1000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 4}, at(2000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 5}, at(2000)),
+			},
+		},
+		{
+			name:   "ceil",
+			plugin: &TimestampAlign{Mode: "ceil"},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2}, at(1000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 3}, at(2000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 4}, at(3000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 5}, at(3000)),
+			},
+		},
+		{
+			name:   "offset",
+			plugin: &TimestampAlign{Interval: config.Duration(time.Second), Offset: config.Duration(500 * time.Millisecond)},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1}, at(500)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2}, at(500)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 3}, at(1500)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 4}, at(2500)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 5}, at(2500)),
+			},
+		},
+		{
+			name:   "deduplicate",
+			plugin: &TimestampAlign{Deduplicate: true},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2}, at(1000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 3}, at(2000)),
+			},
+		},
+		{
+			name:   "original timestamp",
+			plugin: &TimestampAlign{Interval: config.Duration(2 * time.Second), OriginalTimestampField: "ts"},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 1, "ts": at(0).UnixNano()}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 2, "ts": at(980).UnixNano()}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 3, "ts": at(1500).UnixNano()}, at(2000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 4, "ts": at(2020).UnixNano()}, at(2000)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"value": 5, "ts": at(2490).UnixNano()}, at(2000)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.plugin.Interval == 0 {
+				tt.plugin.Interval = config.Duration(time.Second)
+			}
+			require.NoError(t, tt.plugin.Init())
+
+			metrics := make([]telegraf.Metric, 0, len(input))
+			for _, m := range input {
+				metrics = append(metrics, m.Copy())
+			}
+			actual := tt.plugin.Apply(metrics...)
+			testutil.RequireMetricsEqual(t, tt.expected, actual)
+		})
+	}
+}
+
+func TestDeduplicateSeries(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	plugin := &TimestampAlign{
+		Interval:    config.Duration(time.Minute),
+		Deduplicate: true,
+	}
+	require.NoError(t, plugin.Init())
+
+	// Metrics are passed one by one as in the processor pipeline
+	input := []telegraf.Metric{
+		metric.New("m", map[string]string{"device": "a"}, map[string]interface{}{"value": 1}, now.Add(-time.Second)),
+		metric.New("m", map[string]string{"device": "b"}, map[string]interface{}{"value": 2}, now.Add(time.Second)),
+		metric.New("m", map[string]string{"device": "a"}, map[string]interface{}{"value": 3}, now.Add(2*time.Second)),
+		metric.New("m", map[string]string{"device": "a"}, map[string]interface{}{"value": 4}, now.Add(59*time.Second)),
+	}
+	var actual []telegraf.Metric
+	for _, m := range input {
+		actual = append(actual, plugin.Apply(m)...)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{"device": "a"}, map[string]interface{}{"value": 1}, time.Unix(1699999980, 0)),
+		metric.New("m", map[string]string{"device": "b"}, map[string]interface{}{"value": 2}, time.Unix(1699999980, 0)),
+		metric.New("m", map[string]string{"device": "a"}, map[string]interface{}{"value": 4}, time.Unix(1700000040, 0)),
+	}
+	testutil.RequireMetricsEqual(t, expected, actual)
+}
+
+func TestTracking(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1}, base.Add(10*time.Millisecond)),
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 2}, base.Add(20*time.Millisecond)),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"value": 1}, base),
+	}
+
+	plugin := &TimestampAlign{
+		Interval:    config.Duration(time.Second),
+		Deduplicate: true,
+	}
+	require.NoError(t, plugin.Init())
+
+	// Process expected metrics and compare with resulting metrics
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
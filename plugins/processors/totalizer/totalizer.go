@@ -0,0 +1,299 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package totalizer
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/filter"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Totalizer struct {
+	Fields           []string        `toml:"fields"`
+	RateUnit         config.Duration `toml:"rate_unit"`
+	Suffix           string          `toml:"suffix"`
+	MaxGap           config.Duration `toml:"max_gap"`
+	ResetPeriod      string          `toml:"reset_period"`
+	Timezone         string          `toml:"timezone"`
+	ResetTags        []string        `toml:"reset_tags"`
+	ResetMeasurement string          `toml:"reset_measurement"`
+	Expiry           config.Duration `toml:"expiry"`
+	Log              telegraf.Logger `toml:"-"`
+
+	filter     filter.Filter
+	location   *time.Location
+	series     map[string]*series
+	seen       map[string]time.Time
+	lastExpiry time.Time
+}
+
+// series holds the totals of the fields of a series, the fields are exported
+// to allow persisting the state
+type series struct {
+	Tags    map[string]string    `json:"tags"`
+	Context map[string]string    `json:"context,omitempty"`
+	Fields  map[string]*integral `json:"fields"`
+}
+
+// integral is the running total of a field together with the last sample
+type integral struct {
+	Total float64   `json:"total"`
+	Rate  float64   `json:"rate"`
+	Time  time.Time `json:"time"`
+}
+
+func (*Totalizer) SampleConfig() string {
+	return sampleConfig
+}
+
+func (p *Totalizer) Init() error {
+	if len(p.Fields) == 0 {
+		return errors.New("no fields configured")
+	}
+	f, err := filter.Compile(p.Fields)
+	if err != nil {
+		return fmt.Errorf("creating field filter failed: %w", err)
+	}
+	p.filter = f
+
+	if p.RateUnit <= 0 {
+		return errors.New("'rate_unit' must be positive")
+	}
+	if p.Suffix == "" {
+		return errors.New("'suffix' must not be empty")
+	}
+	if p.MaxGap < 0 {
+		return errors.New("'max_gap' must not be negative")
+	}
+	if p.Expiry < 0 {
+		return errors.New("'expiry' must not be negative")
+	}
+
+	if p.ResetPeriod == "" {
+		p.ResetPeriod = "none"
+	}
+	if err := choice.Check(p.ResetPeriod, []string{"none", "hourly", "daily", "monthly"}); err != nil {
+		return fmt.Errorf("invalid 'reset_period': %w", err)
+	}
+	p.location, err = time.LoadLocation(p.Timezone)
+	if err != nil {
+		return fmt.Errorf("invalid 'timezone': %w", err)
+	}
+
+	p.series = make(map[string]*series)
+	p.seen = make(map[string]time.Time)
+
+	return nil
+}
+
+func (p *Totalizer) Apply(in ...telegraf.Metric) []telegraf.Metric {
+	now := time.Now()
+	p.expire(now)
+
+	out := make([]telegraf.Metric, 0, len(in))
+	for _, m := range in {
+		if p.ResetMeasurement != "" && m.Name() == p.ResetMeasurement {
+			p.reset(m)
+			m.Drop()
+			continue
+		}
+
+		key, tags, context := p.identify(m)
+		s, found := p.series[key]
+		if !found {
+			s = &series{Tags: tags, Fields: make(map[string]*integral)}
+			p.series[key] = s
+		}
+		p.seen[key] = now
+		if !maps.Equal(s.Context, context) {
+			// Start new totals for the new context
+			s.Context = context
+			s.Fields = make(map[string]*integral)
+		}
+
+		ts := m.Time()
+		totals := make(map[string]interface{})
+		for _, field := range m.FieldList() {
+			if !p.filter.Match(field.Key) {
+				continue
+			}
+			rate, ok := toFloat(field.Value)
+			if !ok {
+				continue
+			}
+
+			acc, found := s.Fields[field.Key]
+			if !found {
+				acc = &integral{Rate: rate, Time: ts}
+				s.Fields[field.Key] = acc
+			} else if ts.Before(acc.Time) {
+				p.Log.Debugf("Ignoring out-of-order value of field %q of %q at %v", field.Key, m.Name(), ts)
+				continue
+			} else {
+				p.integrate(acc, rate, ts)
+			}
+			totals[field.Key+p.Suffix] = acc.Total
+		}
+		for k, v := range totals {
+			m.AddField(k, v)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func (p *Totalizer) GetState() interface{} {
+	return p.series
+}
+
+func (p *Totalizer) SetState(state interface{}) error {
+	s, ok := state.(map[string]*series)
+	if !ok {
+		return fmt.Errorf("state has wrong type %T", state)
+	}
+	p.series = s
+
+	// Restored series expire as if they just received a metric
+	now := time.Now()
+	p.seen = make(map[string]time.Time, len(s))
+	for key := range s {
+		p.seen[key] = now
+	}
+	return nil
+}
+
+// expire removes the totals of series without metrics within the expiry
+func (p *Totalizer) expire(now time.Time) {
+	// No need to check the series too often
+	if p.Expiry <= 0 || now.Sub(p.lastExpiry) < time.Duration(p.Expiry) {
+		return
+	}
+	p.lastExpiry = now
+
+	for key, seen := range p.seen {
+		if now.Sub(seen) >= time.Duration(p.Expiry) {
+			delete(p.series, key)
+			delete(p.seen, key)
+		}
+	}
+}
+
+// integrate adds the area under the rate between the last and the current
+// sample to the total using the trapezoidal rule
+func (p *Totalizer) integrate(acc *integral, rate float64, ts time.Time) {
+	start, previous := acc.Time, acc.Rate
+	acc.Rate, acc.Time = rate, ts
+
+	gap := p.MaxGap > 0 && ts.Sub(start) > time.Duration(p.MaxGap)
+
+	// Only the part after the start of a new period counts for the new total
+	// using the interpolated rate at the period start
+	if boundary := p.periodStart(ts); boundary.After(start) {
+		acc.Total = 0
+		if !gap {
+			fraction := boundary.Sub(start).Seconds() / ts.Sub(start).Seconds()
+			previous += (rate - previous) * fraction
+			start = boundary
+		}
+	}
+	if gap {
+		return
+	}
+
+	acc.Total += (previous + rate) / 2 * ts.Sub(start).Seconds() / time.Duration(p.RateUnit).Seconds()
+}
+
+// periodStart returns the start of the reset period containing the given
+// time or the zero time if the totals are not reset periodically
+func (p *Totalizer) periodStart(ts time.Time) time.Time {
+	t := ts.In(p.location)
+	switch p.ResetPeriod {
+	case "hourly":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, p.location)
+	case "daily":
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, p.location)
+	case "monthly":
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, p.location)
+	}
+	return time.Time{}
+}
+
+// reset zeroes the totals of all series containing the tags of the reset
+// metric, integration continues from the time of the reset
+func (p *Totalizer) reset(m telegraf.Metric) {
+	ts := m.Time()
+	for _, s := range p.series {
+		matches := true
+		for _, tag := range m.TagList() {
+			if v, found := s.Tags[tag.Key]; !found || v != tag.Value {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+		for _, acc := range s.Fields {
+			acc.Total = 0
+			if ts.After(acc.Time) {
+				acc.Time = ts
+			}
+		}
+	}
+}
+
+// identify returns the key and the tags of the series the metric belongs to
+// as well as the values of the reset tags
+func (p *Totalizer) identify(m telegraf.Metric) (string, map[string]string, map[string]string) {
+	var key strings.Builder
+	key.WriteString(m.Name())
+
+	tags := make(map[string]string)
+	var context map[string]string
+	for _, tag := range m.TagList() {
+		if slices.Contains(p.ResetTags, tag.Key) {
+			if context == nil {
+				context = make(map[string]string, len(p.ResetTags))
+			}
+			context[tag.Key] = tag.Value
+			continue
+		}
+		tags[tag.Key] = tag.Value
+		key.WriteString("\x00" + tag.Key + "=" + tag.Value)
+	}
+	return key.String(), tags, context
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func init() {
+	processors.Add("totalizer", func() telegraf.Processor {
+		return &Totalizer{
+			RateUnit: config.Duration(time.Second),
+			Suffix:   "_total",
+			Timezone: "Local",
+		}
+	})
+}
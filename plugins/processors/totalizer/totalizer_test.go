@@ -0,0 +1,407 @@
+package totalizer
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Totalizer
+		expected string
+	}{
+		{
+			name:     "no fields",
+			plugin:   &Totalizer{},
+			expected: "no fields configured",
+		},
+		{
+			name:     "invalid field filter",
+			plugin:   &Totalizer{Fields: []string{"a[b"}},
+			expected: "creating field filter failed",
+		},
+		{
+			name:     "invalid rate unit",
+			plugin:   &Totalizer{Fields: []string{"flow"}, Suffix: "_total"},
+			expected: "'rate_unit' must be positive",
+		},
+		{
+			name:     "empty suffix",
+			plugin:   &Totalizer{Fields: []string{"flow"}, RateUnit: config.Duration(time.Second)},
+			expected: "'suffix' must not be empty",
+		},
+		{
+			name: "negative max gap",
+			plugin: &Totalizer{
+				Fields:   []string{"flow"},
+				RateUnit: config.Duration(time.Second),
+				Suffix:   "_total",
+				MaxGap:   config.Duration(-time.Second),
+			},
+			expected: "'max_gap' must not be negative",
+		},
+		{
+			name: "negative expiry",
+			plugin: &Totalizer{
+				Fields:   []string{"flow"},
+				RateUnit: config.Duration(time.Second),
+				Suffix:   "_total",
+				Expiry:   config.Duration(-time.Second),
+			},
+			expected: "'expiry' must not be negative",
+		},
+		{
+			name: "invalid reset period",
+			plugin: &Totalizer{
+				Fields:      []string{"flow"},
+				RateUnit:    config.Duration(time.Second),
+				Suffix:      "_total",
+				ResetPeriod: "weekly",
+			},
+			expected: "invalid 'reset_period'",
+		},
+		{
+			name: "invalid timezone",
+			plugin: &Totalizer{
+				Fields:   []string{"flow"},
+				RateUnit: config.Duration(time.Second),
+				Suffix:   "_total",
+				Timezone: "Mars/Olympus_Mons",
+			},
+			expected: "invalid 'timezone'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	base := time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return base.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *Totalizer
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name: "trapezoidal",
+			plugin: &Totalizer{
+				Fields:   []string{"flow"},
+				RateUnit: config.Duration(time.Hour),
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 10.0, "state": "run"}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 20.0, "state": "run"}, at(30)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": int64(20), "state": "run"}, at(45)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 50.0}, at(40)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "stop"}, at(50)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 10.0, "flow_total": 0.0, "state": "run"}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 20.0, "flow_total": 7.5, "state": "run"}, at(30)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": int64(20), "flow_total": 12.5, "state": "run"}, at(45)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 50.0}, at(40)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"state": "stop"}, at(50)),
+			},
+		},
+		{
+			name: "independent series and fields",
+			plugin: &Totalizer{
+				Fields:   []string{"flow_*"},
+				RateUnit: config.Duration(time.Minute),
+				Suffix:   "_sum",
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"flow_in": 1.0, "flow_out": 2.0}, at(0)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"flow_in": 3.0}, at(0)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"flow_in": 1.0, "flow_out": 2.0}, at(10)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"flow_in": 3.0}, at(10)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"flow_in": 1.0, "flow_out": 2.0, "flow_in_sum": 0.0, "flow_out_sum": 0.0}, at(0)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"flow_in": 3.0, "flow_in_sum": 0.0}, at(0)),
+				metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"flow_in": 1.0, "flow_out": 2.0, "flow_in_sum": 10.0, "flow_out_sum": 20.0}, at(10)),
+				metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"flow_in": 3.0, "flow_in_sum": 30.0}, at(10)),
+			},
+		},
+		{
+			name: "max gap",
+			plugin: &Totalizer{
+				Fields:   []string{"flow"},
+				RateUnit: config.Duration(time.Minute),
+				MaxGap:   config.Duration(15 * time.Minute),
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0}, at(10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0}, at(30)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0}, at(35)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0, "flow_total": 0.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0, "flow_total": 10.0}, at(10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0, "flow_total": 10.0}, at(30)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0, "flow_total": 15.0}, at(35)),
+			},
+		},
+		{
+			name: "daily reset",
+			plugin: &Totalizer{
+				Fields:      []string{"flow"},
+				RateUnit:    config.Duration(time.Minute),
+				ResetPeriod: "daily",
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0}, at(50)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 3.0}, at(70)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 3.0}, at(80)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0, "flow_total": 0.0}, at(0)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0, "flow_total": 50.0}, at(50)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 3.0, "flow_total": 25.0}, at(70)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 3.0, "flow_total": 55.0}, at(80)),
+			},
+		},
+		{
+			name: "daily reset in timezone",
+			plugin: &Totalizer{
+				Fields:      []string{"flow"},
+				RateUnit:    config.Duration(time.Minute),
+				ResetPeriod: "daily",
+				Timezone:    "Europe/Berlin",
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0}, at(-10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0}, at(30)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0}, at(70)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0, "flow_total": 0.0}, at(-10)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0, "flow_total": 30.0}, at(30)),
+				metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0, "flow_total": 70.0}, at(70)),
+			},
+		},
+		{
+			name: "reset tags",
+			plugin: &Totalizer{
+				Fields:    []string{"flow"},
+				RateUnit:  config.Duration(time.Minute),
+				ResetTags: []string{"batch"},
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{"batch": "1", "line": "a"}, map[string]interface{}{"flow": 1.0}, at(0)),
+				metric.New("m", map[string]string{"batch": "1", "line": "a"}, map[string]interface{}{"flow": 1.0}, at(10)),
+				metric.New("m", map[string]string{"batch": "2", "line": "a"}, map[string]interface{}{"flow": 1.0}, at(20)),
+				metric.New("m", map[string]string{"batch": "2", "line": "a"}, map[string]interface{}{"flow": 1.0}, at(25)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{"batch": "1", "line": "a"}, map[string]interface{}{"flow": 1.0, "flow_total": 0.0}, at(0)),
+				metric.New("m", map[string]string{"batch": "1", "line": "a"}, map[string]interface{}{"flow": 1.0, "flow_total": 10.0}, at(10)),
+				metric.New("m", map[string]string{"batch": "2", "line": "a"}, map[string]interface{}{"flow": 1.0, "flow_total": 0.0}, at(20)),
+				metric.New("m", map[string]string{"batch": "2", "line": "a"}, map[string]interface{}{"flow": 1.0, "flow_total": 5.0}, at(25)),
+			},
+		},
+		{
+			name: "reset measurement",
+			plugin: &Totalizer{
+				Fields:           []string{"flow"},
+				RateUnit:         config.Duration(time.Minute),
+				ResetMeasurement: "reset",
+			},
+			input: []telegraf.Metric{
+				metric.New("m", map[string]string{"line": "a"}, map[string]interface{}{"flow": 1.0}, at(0)),
+				metric.New("m", map[string]string{"line": "b"}, map[string]interface{}{"flow": 1.0}, at(0)),
+				metric.New("m", map[string]string{"line": "a"}, map[string]interface{}{"flow": 1.0}, at(10)),
+				metric.New("m", map[string]string{"line": "b"}, map[string]interface{}{"flow": 1.0}, at(10)),
+				metric.New("reset", map[string]string{"line": "a"}, map[string]interface{}{"reset": true}, at(15)),
+				metric.New("m", map[string]string{"line": "a"}, map[string]interface{}{"flow": 1.0}, at(20)),
+				metric.New("m", map[string]string{"line": "b"}, map[string]interface{}{"flow": 1.0}, at(20)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("m", map[string]string{"line": "a"}, map[string]interface{}{"flow": 1.0, "flow_total": 0.0}, at(0)),
+				metric.New("m", map[string]string{"line": "b"}, map[string]interface{}{"flow": 1.0, "flow_total": 0.0}, at(0)),
+				metric.New("m", map[string]string{"line": "a"}, map[string]interface{}{"flow": 1.0, "flow_total": 10.0}, at(10)),
+				metric.New("m", map[string]string{"line": "b"}, map[string]interface{}{"flow": 1.0, "flow_total": 10.0}, at(10)),
+				metric.New("m", map[string]string{"line": "a"}, map[string]interface{}{"flow": 1.0, "flow_total": 5.0}, at(20)),
+				metric.New("m", map[string]string{"line": "b"}, map[string]interface{}{"flow": 1.0, "flow_total": 20.0}, at(20)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.plugin.Suffix == "" {
+				tt.plugin.Suffix = "_total"
+			}
+			tt.plugin.Log = &testutil.Logger{}
+			require.NoError(t, tt.plugin.Init())
+
+			actual := tt.plugin.Apply(tt.input...)
+			testutil.RequireMetricsEqual(t, tt.expected, actual, testutil.SortMetrics())
+		})
+	}
+}
+
+func TestStatePersistence(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	plugin := &Totalizer{
+		Fields:   []string{"flow"},
+		RateUnit: config.Duration(time.Second),
+		Suffix:   "_total",
+		Log:      &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	input := []telegraf.Metric{
+		metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"flow": 1.0}, now),
+		metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"flow": 1.0}, now.Add(10*time.Second)),
+	}
+	plugin.Apply(input...)
+
+	// Serialize the state as done by the persister
+	var pi telegraf.StatefulPlugin = plugin
+	serialized, err := json.Marshal(pi.GetState())
+	require.NoError(t, err)
+
+	// Restore the state in a new instance, the restored series must not
+	// expire before receiving new metrics
+	restored := &Totalizer{
+		Fields:   []string{"flow"},
+		RateUnit: config.Duration(time.Second),
+		Suffix:   "_total",
+		Expiry:   config.Duration(time.Hour),
+		Log:      &testutil.Logger{},
+	}
+	require.NoError(t, restored.Init())
+	var state map[string]*series
+	require.NoError(t, json.Unmarshal(serialized, &state))
+	require.NoError(t, restored.SetState(state))
+
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"flow": 1.0, "flow_total": 15.0}, now.Add(15*time.Second)),
+	}
+	actual := restored.Apply(
+		metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"flow": 1.0}, now.Add(15*time.Second)),
+	)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	require.ErrorContains(t, restored.SetState("foo"), "state has wrong type string")
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	expired := metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"flow": 1.0}, now)
+	active := metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"flow": 1.0}, now)
+
+	plugin := &Totalizer{
+		Fields:   []string{"flow"},
+		RateUnit: config.Duration(time.Second),
+		Suffix:   "_total",
+		Expiry:   config.Duration(time.Hour),
+		Log:      &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+	plugin.Apply(expired, active)
+	require.Len(t, plugin.series, 2)
+
+	// Pretend the first series did not receive metrics for longer than the
+	// expiry and the last check is due
+	key, _, _ := plugin.identify(expired)
+	plugin.seen[key] = time.Now().Add(-2 * time.Hour)
+	plugin.lastExpiry = time.Now().Add(-2 * time.Hour)
+
+	input := []telegraf.Metric{
+		metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"flow": 1.0}, now.Add(10*time.Second)),
+	}
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{"id": "2"}, map[string]interface{}{"flow": 1.0, "flow_total": 10.0}, now.Add(10*time.Second)),
+	}
+	testutil.RequireMetricsEqual(t, expected, plugin.Apply(input...))
+	require.Len(t, plugin.series, 1)
+	require.Len(t, plugin.seen, 1)
+
+	// The expired series starts a new total
+	input = []telegraf.Metric{
+		metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"flow": 1.0}, now.Add(10*time.Second)),
+	}
+	expected = []telegraf.Metric{
+		metric.New("m", map[string]string{"id": "1"}, map[string]interface{}{"flow": 1.0, "flow_total": 0.0}, now.Add(10*time.Second)),
+	}
+	testutil.RequireMetricsEqual(t, expected, plugin.Apply(input...))
+	require.Len(t, plugin.series, 2)
+}
+
+func TestTracking(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0}, now),
+		metric.New("reset", map[string]string{}, map[string]interface{}{"reset": true}, now),
+		metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0}, now.Add(time.Second)),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0, "flow_total": 0.0}, now),
+		metric.New("m", map[string]string{}, map[string]interface{}{"flow": 1.0, "flow_total": 1.0}, now.Add(time.Second)),
+	}
+
+	plugin := &Totalizer{
+		Fields:           []string{"flow"},
+		RateUnit:         config.Duration(time.Second),
+		Suffix:           "_total",
+		ResetMeasurement: "reset",
+		Log:              &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	// Process expected metrics and compare with resulting metrics
+	actual := plugin.Apply(input...)
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
@@ -0,0 +1,310 @@
+//go:generate ../../../tools/readme_config_includer/generator
+package transpose
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/internal/choice"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/plugins/processors"
+)
+
+//go:embed sample.conf
+var sampleConfig string
+
+type Transpose struct {
+	Mode        string          `toml:"mode"`
+	TagKey      string          `toml:"tag_key"`
+	ValueFields []string        `toml:"value_fields"`
+	Separator   string          `toml:"separator"`
+	Window      config.Duration `toml:"window"`
+	Timestamp   string          `toml:"timestamp"`
+	Timeout     config.Duration `toml:"timeout"`
+	Log         telegraf.Logger `toml:"-"`
+
+	acc    telegraf.Accumulator
+	groups map[groupID]*group
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	sync.Mutex
+}
+
+// groupID identifies the group by the series and the start of the window
+type groupID struct {
+	series uint64
+	start  int64
+}
+
+// group collects the long metrics of a series within a window
+type group struct {
+	name    string
+	tags    map[string]string
+	fields  map[string]interface{}
+	start   time.Time
+	first   time.Time
+	last    time.Time
+	expires time.Time
+}
+
+func (*Transpose) SampleConfig() string {
+	return sampleConfig
+}
+
+func (t *Transpose) Init() error {
+	if t.Mode == "" {
+		t.Mode = "wide"
+	}
+	if err := choice.Check(t.Mode, []string{"wide", "long"}); err != nil {
+		return fmt.Errorf("invalid 'mode': %w", err)
+	}
+	if t.TagKey == "" {
+		t.TagKey = "name"
+	}
+	if len(t.ValueFields) == 0 {
+		t.ValueFields = []string{"value"}
+	}
+	seen := make(map[string]bool, len(t.ValueFields))
+	for _, f := range t.ValueFields {
+		if f == "" {
+			return errors.New("empty value field")
+		}
+		if seen[f] {
+			return fmt.Errorf("duplicate value field %q", f)
+		}
+		seen[f] = true
+	}
+	if t.Window < 0 {
+		return errors.New("'window' must not be negative")
+	}
+	if t.Timestamp == "" {
+		t.Timestamp = "window_start"
+	}
+	if err := choice.Check(t.Timestamp, []string{"window_start", "window_end", "first", "last"}); err != nil {
+		return fmt.Errorf("invalid 'timestamp': %w", err)
+	}
+	if t.Timeout <= 0 {
+		return errors.New("'timeout' must be positive")
+	}
+
+	t.groups = make(map[groupID]*group)
+
+	return nil
+}
+
+func (t *Transpose) Start(acc telegraf.Accumulator) error {
+	t.acc = acc
+	if t.Mode != "wide" {
+		return nil
+	}
+
+	// Check for expired groups regularly but at least once per timeout
+	interval := min(time.Duration(t.Timeout), time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.cancel = cancel
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				t.expire(time.Now())
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (t *Transpose) Add(m telegraf.Metric, acc telegraf.Accumulator) error {
+	if t.Mode == "long" {
+		t.toLong(m, acc)
+		return nil
+	}
+
+	key, found := m.GetTag(t.TagKey)
+	if !found {
+		acc.AddMetric(m)
+		return nil
+	}
+	fields := make(map[string]interface{}, len(t.ValueFields))
+	for _, f := range t.ValueFields {
+		if v, found := m.GetField(f); found {
+			fields[t.fieldName(key, f)] = v
+		}
+	}
+	if len(fields) == 0 {
+		acc.AddMetric(m)
+		return nil
+	}
+
+	// Identify the series without the transposed tag
+	name := m.Name()
+	tags := m.Tags()
+	delete(tags, t.TagKey)
+	series := metric.New(name, tags, nil, time.Time{})
+	ts := m.Time()
+	start := ts
+	if t.Window > 0 {
+		start = ts.Truncate(time.Duration(t.Window))
+	}
+	id := groupID{series: series.HashID(), start: start.UnixNano()}
+	m.Drop()
+
+	t.Lock()
+	defer t.Unlock()
+
+	g, found := t.groups[id]
+	if !found {
+		g = &group{
+			name:    name,
+			tags:    tags,
+			fields:  make(map[string]interface{}),
+			start:   start,
+			first:   ts,
+			last:    ts,
+			expires: time.Now().Add(time.Duration(t.Timeout)),
+		}
+		t.groups[id] = g
+	}
+	for k, v := range fields {
+		g.fields[k] = v
+	}
+	if ts.Before(g.first) {
+		g.first = ts
+	}
+	if ts.After(g.last) {
+		g.last = ts
+	}
+
+	return nil
+}
+
+func (t *Transpose) Stop() {
+	if t.cancel != nil {
+		t.cancel()
+	}
+	t.wg.Wait()
+
+	t.Lock()
+	defer t.Unlock()
+	for id, g := range t.groups {
+		t.acc.AddMetric(t.wide(g))
+		delete(t.groups, id)
+	}
+}
+
+// expire emits the groups not receiving further metrics within the timeout
+func (t *Transpose) expire(now time.Time) {
+	t.Lock()
+	defer t.Unlock()
+	for id, g := range t.groups {
+		if now.Before(g.expires) {
+			continue
+		}
+		t.acc.AddMetric(t.wide(g))
+		delete(t.groups, id)
+	}
+}
+
+// wide creates the wide metric of the group
+func (t *Transpose) wide(g *group) telegraf.Metric {
+	var ts time.Time
+	switch t.Timestamp {
+	case "window_start":
+		ts = g.start
+	case "window_end":
+		ts = g.start.Add(time.Duration(t.Window))
+	case "first":
+		ts = g.first
+	case "last":
+		ts = g.last
+	}
+	return metric.New(g.name, g.tags, g.fields, ts)
+}
+
+// toLong splits the metric into one metric per transposed field
+func (t *Transpose) toLong(src telegraf.Metric, acc telegraf.Accumulator) {
+	// Create a copy without fields and tracking information
+	base := metric.New(src.Name(), make(map[string]string), make(map[string]interface{}), src.Time())
+	for _, tag := range src.TagList() {
+		base.AddTag(tag.Key, tag.Value)
+	}
+
+	var rest telegraf.Metric
+	parts := make(map[string]telegraf.Metric)
+	for _, field := range src.FieldList() {
+		key, valueField, found := t.splitFieldName(field.Key)
+		if !found {
+			if rest == nil {
+				rest = base.Copy()
+			}
+			rest.AddField(field.Key, field.Value)
+			continue
+		}
+		m, found := parts[key]
+		if !found {
+			m = base.Copy()
+			m.AddTag(t.TagKey, key)
+			parts[key] = m
+		}
+		m.AddField(valueField, field.Value)
+	}
+
+	keys := make([]string, 0, len(parts))
+	for k := range parts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		acc.AddMetric(parts[k])
+	}
+	if rest != nil {
+		acc.AddMetric(rest)
+	}
+	src.Accept()
+}
+
+// fieldName returns the name of the wide field for the tag value and value
+// field of a long metric
+func (t *Transpose) fieldName(key, valueField string) string {
+	if len(t.ValueFields) == 1 {
+		return key
+	}
+	return key + t.Separator + valueField
+}
+
+// splitFieldName returns the tag value and value field of the wide field name
+func (t *Transpose) splitFieldName(name string) (key, valueField string, found bool) {
+	if len(t.ValueFields) == 1 {
+		return name, t.ValueFields[0], true
+	}
+	for _, f := range t.ValueFields {
+		if key, found := strings.CutSuffix(name, t.Separator+f); found && key != "" {
+			return key, f, true
+		}
+	}
+	return "", "", false
+}
+
+func init() {
+	processors.AddStreaming("transpose", func() telegraf.StreamingProcessor {
+		return &Transpose{
+			Separator: "_",
+			Timeout:   config.Duration(time.Second),
+		}
+	})
+}
@@ -0,0 +1,274 @@
+package transpose
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/config"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func TestInitFail(t *testing.T) {
+	tests := []struct {
+		name     string
+		plugin   *Transpose
+		expected string
+	}{
+		{
+			name:     "invalid mode",
+			plugin:   &Transpose{Mode: "pivot"},
+			expected: "invalid 'mode'",
+		},
+		{
+			name:     "empty value field",
+			plugin:   &Transpose{ValueFields: []string{"value", ""}},
+			expected: "empty value field",
+		},
+		{
+			name:     "duplicate value field",
+			plugin:   &Transpose{ValueFields: []string{"value", "value"}},
+			expected: `duplicate value field "value"`,
+		},
+		{
+			name:     "negative window",
+			plugin:   &Transpose{Window: config.Duration(-time.Second)},
+			expected: "'window' must not be negative",
+		},
+		{
+			name:     "invalid timestamp",
+			plugin:   &Transpose{Timestamp: "now"},
+			expected: "invalid 'timestamp'",
+		},
+		{
+			name:     "no timeout",
+			plugin:   &Transpose{},
+			expected: "'timeout' must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorContains(t, tt.plugin.Init(), tt.expected)
+		})
+	}
+}
+
+func TestCases(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+	at := func(ms int) time.Time {
+		return base.Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	tests := []struct {
+		name     string
+		plugin   *Transpose
+		input    []telegraf.Metric
+		expected []telegraf.Metric
+	}{
+		{
+			name:   "wide",
+			plugin: &Transpose{},
+			input: []telegraf.Metric{
+				metric.New("plc", map[string]string{"line": "1", "name": "speed"}, map[string]interface{}{"value": 12.5}, at(0)),
+				metric.New("plc", map[string]string{"line": "1", "name": "temp"}, map[string]interface{}{"value": 80.0}, at(0)),
+				metric.New("plc", map[string]string{"line": "2", "name": "speed"}, map[string]interface{}{"value": 10.0}, at(0)),
+				metric.New("plc", map[string]string{"line": "1", "name": "speed"}, map[string]interface{}{"value": 13.0}, at(100)),
+				metric.New("plc", map[string]string{"line": "1"}, map[string]interface{}{"value": 1.0}, at(0)),
+				metric.New("plc", map[string]string{"line": "1", "name": "status"}, map[string]interface{}{"text": "ok"}, at(0)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("plc", map[string]string{"line": "1"}, map[string]interface{}{"value": 1.0}, at(0)),
+				metric.New("plc", map[string]string{"line": "1", "name": "status"}, map[string]interface{}{"text": "ok"}, at(0)),
+				metric.New("plc", map[string]string{"line": "1"}, map[string]interface{}{"speed": 12.5, "temp": 80.0}, at(0)),
+				metric.New("plc", map[string]string{"line": "2"}, map[string]interface{}{"speed": 10.0}, at(0)),
+				metric.New("plc", map[string]string{"line": "1"}, map[string]interface{}{"speed": 13.0}, at(100)),
+			},
+		},
+		{
+			name: "wide with window",
+			plugin: &Transpose{
+				ValueFields: []string{"value", "quality"},
+				Window:      config.Duration(time.Second),
+			},
+			input: []telegraf.Metric{
+				metric.New("plc", map[string]string{"name": "speed"}, map[string]interface{}{"value": 12.5, "quality": int64(192)}, at(100)),
+				metric.New("plc", map[string]string{"name": "temp"}, map[string]interface{}{"value": 80.0}, at(700)),
+				metric.New("plc", map[string]string{"name": "speed"}, map[string]interface{}{"value": 13.0, "quality": int64(0)}, at(1200)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("plc", map[string]string{}, map[string]interface{}{"speed_value": 12.5, "speed_quality": int64(192), "temp_value": 80.0}, at(0)),
+				metric.New("plc", map[string]string{}, map[string]interface{}{"speed_value": 13.0, "speed_quality": int64(0)}, at(1000)),
+			},
+		},
+		{
+			name: "wide with window end timestamp",
+			plugin: &Transpose{
+				Window:    config.Duration(time.Second),
+				Timestamp: "window_end",
+			},
+			input: []telegraf.Metric{
+				metric.New("plc", map[string]string{"name": "speed"}, map[string]interface{}{"value": 12.5}, at(100)),
+				metric.New("plc", map[string]string{"name": "temp"}, map[string]interface{}{"value": 80.0}, at(700)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("plc", map[string]string{}, map[string]interface{}{"speed": 12.5, "temp": 80.0}, at(1000)),
+			},
+		},
+		{
+			name: "wide with last timestamp",
+			plugin: &Transpose{
+				Window:    config.Duration(time.Second),
+				Timestamp: "last",
+			},
+			input: []telegraf.Metric{
+				metric.New("plc", map[string]string{"name": "speed"}, map[string]interface{}{"value": 12.5}, at(700)),
+				metric.New("plc", map[string]string{"name": "temp"}, map[string]interface{}{"value": 80.0}, at(100)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("plc", map[string]string{}, map[string]interface{}{"speed": 12.5, "temp": 80.0}, at(700)),
+			},
+		},
+		{
+			name:   "long",
+			plugin: &Transpose{Mode: "long"},
+			input: []telegraf.Metric{
+				metric.New("plc", map[string]string{"line": "1"}, map[string]interface{}{"speed": 12.5, "temp": 80.0}, at(0)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("plc", map[string]string{"line": "1", "name": "speed"}, map[string]interface{}{"value": 12.5}, at(0)),
+				metric.New("plc", map[string]string{"line": "1", "name": "temp"}, map[string]interface{}{"value": 80.0}, at(0)),
+			},
+		},
+		{
+			name: "long with multiple value fields",
+			plugin: &Transpose{
+				Mode:        "long",
+				TagKey:      "signal",
+				ValueFields: []string{"value", "quality"},
+			},
+			input: []telegraf.Metric{
+				metric.New("plc", map[string]string{}, map[string]interface{}{
+					"speed_value":   12.5,
+					"speed_quality": int64(192),
+					"temp_value":    80.0,
+					"status":        "ok",
+				}, at(0)),
+			},
+			expected: []telegraf.Metric{
+				metric.New("plc", map[string]string{"signal": "speed"}, map[string]interface{}{"value": 12.5, "quality": int64(192)}, at(0)),
+				metric.New("plc", map[string]string{"signal": "temp"}, map[string]interface{}{"value": 80.0}, at(0)),
+				metric.New("plc", map[string]string{}, map[string]interface{}{"status": "ok"}, at(0)),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.plugin.Log = &testutil.Logger{}
+			if tt.plugin.Separator == "" {
+				tt.plugin.Separator = "_"
+			}
+			// Avoid timeouts during the test
+			tt.plugin.Timeout = config.Duration(time.Hour)
+			require.NoError(t, tt.plugin.Init())
+
+			var acc testutil.Accumulator
+			require.NoError(t, tt.plugin.Start(&acc))
+			for _, m := range tt.input {
+				require.NoError(t, tt.plugin.Add(m, &acc))
+			}
+			tt.plugin.Stop()
+
+			testutil.RequireMetricsEqual(t, tt.expected, acc.GetTelegrafMetrics(), testutil.SortMetrics())
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	plugin := &Transpose{
+		Separator: "_",
+		Timeout:   config.Duration(50 * time.Millisecond),
+		Log:       &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	defer plugin.Stop()
+
+	ts := time.Unix(1700000000, 0)
+	require.NoError(t, plugin.Add(metric.New("plc", map[string]string{"name": "speed"}, map[string]interface{}{"value": 12.5}, ts), &acc))
+	require.NoError(t, plugin.Add(metric.New("plc", map[string]string{"name": "temp"}, map[string]interface{}{"value": 80.0}, ts), &acc))
+
+	// The wide metric must be emitted after the timeout
+	expected := []telegraf.Metric{
+		metric.New("plc", map[string]string{}, map[string]interface{}{"speed": 12.5, "temp": 80.0}, ts),
+	}
+	require.Eventually(t, func() bool {
+		return acc.NMetrics() == 1
+	}, time.Second, 10*time.Millisecond)
+	testutil.RequireMetricsEqual(t, expected, acc.GetTelegrafMetrics())
+}
+
+func TestTracking(t *testing.T) {
+	base := time.Unix(1700000000, 0)
+
+	inputRaw := []telegraf.Metric{
+		metric.New("plc", map[string]string{"name": "speed"}, map[string]interface{}{"value": 12.5}, base),
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 5.0}, base),
+		metric.New("plc", map[string]string{"name": "temp"}, map[string]interface{}{"value": 80.0}, base),
+	}
+
+	var mu sync.Mutex
+	delivered := make([]telegraf.DeliveryInfo, 0, len(inputRaw))
+	notify := func(di telegraf.DeliveryInfo) {
+		mu.Lock()
+		defer mu.Unlock()
+		delivered = append(delivered, di)
+	}
+
+	input := make([]telegraf.Metric, 0, len(inputRaw))
+	for _, m := range inputRaw {
+		tm, _ := metric.WithTracking(m, notify)
+		input = append(input, tm)
+	}
+
+	expected := []telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"usage": 5.0}, base),
+		metric.New("plc", map[string]string{}, map[string]interface{}{"speed": 12.5, "temp": 80.0}, base),
+	}
+
+	plugin := &Transpose{
+		Separator: "_",
+		Timeout:   config.Duration(time.Hour),
+		Log:       &testutil.Logger{},
+	}
+	require.NoError(t, plugin.Init())
+
+	var acc testutil.Accumulator
+	require.NoError(t, plugin.Start(&acc))
+	for _, m := range input {
+		require.NoError(t, plugin.Add(m, &acc))
+	}
+	plugin.Stop()
+
+	actual := acc.GetTelegrafMetrics()
+	testutil.RequireMetricsEqual(t, expected, actual)
+
+	// Simulate output acknowledging delivery
+	for _, m := range actual {
+		m.Accept()
+	}
+
+	// Check delivery
+	require.Eventuallyf(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(input) == len(delivered)
+	}, time.Second, 100*time.Millisecond, "%d delivered but %d expected", len(delivered), len(input))
+}
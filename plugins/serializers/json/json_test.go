@@ -512,3 +512,40 @@ func BenchmarkSerializeBatch(b *testing.B) {
 		require.NoError(b, err)
 	}
 }
+
+func BenchmarkSerializeCorpora(b *testing.B) {
+	s := &Serializer{}
+	require.NoError(b, s.Init())
+	testutil.RunSerializerBenchmarks(b, s)
+}
+
+func TestSerializeFuzzMetrics(t *testing.T) {
+	s := Serializer{}
+	require.NoError(t, s.Init())
+
+	for _, m := range testutil.FuzzMetrics() {
+		// Errors are fine, but the output must always be valid JSON
+		buf, err := s.Serialize(m)
+		if err != nil {
+			continue
+		}
+		require.True(t, json.Valid(buf), "invalid JSON for metric %v: %q", m, buf)
+	}
+}
+
+func FuzzSerializerJSON(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte("\x80name\x01\x05\x80abc\x00\x01\x01"))
+	f.Add([]byte{0x03, 0x10, 0x20, 0x00, 0x03, 0x00, 0x08, 0x05, 0x10, 0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xef, 0x7f})
+
+	s := Serializer{}
+	require.NoError(f, s.Init())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		buf, err := s.Serialize(testutil.MetricFromFuzzInput(data))
+		if err != nil {
+			return
+		}
+		require.True(t, json.Valid(buf), "invalid JSON %q", buf)
+	})
+}
@@ -38,6 +38,18 @@ type Accumulator struct {
 	deliverChan chan telegraf.DeliveryInfo
 	delivered   []telegraf.DeliveryInfo
 
+	// Tracking metrics in the order of arrival and the number of metrics
+	// finalized using Deliver
+	tracked     []telegraf.Metric
+	trackingIDs []telegraf.TrackingID
+	finalized   int
+
+	// Delivery notifications delayed or held back for simulating slow or
+	// out-of-order deliveries
+	deliveryDelay  time.Duration
+	holdDeliveries bool
+	held           []telegraf.DeliveryInfo
+
 	TimeFunc func() time.Time
 
 	trackingMutex sync.Mutex
@@ -224,12 +236,22 @@ func (a *Accumulator) WithTracking(maxTracked int) telegraf.TrackingAccumulator
 
 func (a *Accumulator) AddTrackingMetric(m telegraf.Metric) telegraf.TrackingID {
 	dm, id := metric.WithTracking(m, a.onDelivery)
+	a.trackingMutex.Lock()
+	a.tracked = append(a.tracked, dm)
+	a.trackingIDs = append(a.trackingIDs, id)
+	a.trackingMutex.Unlock()
+
 	a.AddMetric(dm)
 	return id
 }
 
 func (a *Accumulator) AddTrackingMetricGroup(group []telegraf.Metric) telegraf.TrackingID {
 	db, id := metric.WithGroupTracking(group, a.onDelivery)
+	a.trackingMutex.Lock()
+	a.tracked = append(a.tracked, db...)
+	a.trackingIDs = append(a.trackingIDs, id)
+	a.trackingMutex.Unlock()
+
 	for _, m := range db {
 		a.AddMetric(m)
 	}
@@ -237,6 +259,30 @@ func (a *Accumulator) AddTrackingMetricGroup(group []telegraf.Metric) telegraf.T
 }
 
 func (a *Accumulator) onDelivery(info telegraf.DeliveryInfo) {
+	a.trackingMutex.Lock()
+	if a.holdDeliveries {
+		a.held = append(a.held, info)
+		a.trackingMutex.Unlock()
+		return
+	}
+	delay := a.deliveryDelay
+	a.trackingMutex.Unlock()
+
+	if delay > 0 {
+		time.AfterFunc(delay, func() { a.publishDelivery(info) })
+		return
+	}
+	a.publishDelivery(info)
+}
+
+func (a *Accumulator) publishDelivery(info telegraf.DeliveryInfo) {
+	a.Lock()
+	a.delivered = append(a.delivered, info)
+	if a.Cond != nil {
+		a.Cond.Broadcast()
+	}
+	a.Unlock()
+
 	select {
 	case a.deliverChan <- info:
 	default:
@@ -252,6 +298,173 @@ func (a *Accumulator) Delivered() <-chan telegraf.DeliveryInfo {
 	return a.deliverChan
 }
 
+// DeliveryOutcome is the way an output finalizes a tracking metric
+type DeliveryOutcome int
+
+const (
+	// DeliveryAccept accepts the metric, e.g. after a successful write
+	DeliveryAccept DeliveryOutcome = iota
+	// DeliveryReject rejects the metric, e.g. after a failed serialization
+	DeliveryReject
+	// DeliveryDrop drops the metric, e.g. in a filtering processor
+	DeliveryDrop
+)
+
+func (o DeliveryOutcome) String() string {
+	switch o {
+	case DeliveryAccept:
+		return "accept"
+	case DeliveryReject:
+		return "reject"
+	case DeliveryDrop:
+		return "drop"
+	}
+	return fmt.Sprintf("unknown(%d)", int(o))
+}
+
+// Delivery is an expected delivery notification
+type Delivery struct {
+	ID        telegraf.TrackingID
+	Delivered bool
+}
+
+// TrackingIDs returns the IDs of the tracking metrics and metric groups in
+// the order they were added
+func (a *Accumulator) TrackingIDs() []telegraf.TrackingID {
+	a.trackingMutex.Lock()
+	defer a.trackingMutex.Unlock()
+	return append([]telegraf.TrackingID(nil), a.trackingIDs...)
+}
+
+// NPending returns the number of tracking metrics not yet finalized using
+// Deliver
+func (a *Accumulator) NPending() int {
+	a.trackingMutex.Lock()
+	defer a.trackingMutex.Unlock()
+	return len(a.tracked) - a.finalized
+}
+
+// Deliver finalizes the pending tracking metrics in the order of arrival
+// using the given outcomes, like outputs would do. Metrics of a group are
+// finalized individually so the group is only notified after all of its
+// metrics are finalized. Do not finalize the metrics returned by
+// GetTelegrafMetrics in addition. Deliver panics if there are less pending
+// metrics than outcomes.
+func (a *Accumulator) Deliver(outcomes ...DeliveryOutcome) {
+	a.trackingMutex.Lock()
+	if len(a.tracked)-a.finalized < len(outcomes) {
+		n := len(a.tracked) - a.finalized
+		a.trackingMutex.Unlock()
+		panic(fmt.Sprintf("%d outcomes for %d pending tracking metrics", len(outcomes), n))
+	}
+	pending := a.tracked[a.finalized : a.finalized+len(outcomes)]
+	a.finalized += len(outcomes)
+	a.trackingMutex.Unlock()
+
+	// Finalize the metrics outside the lock as this triggers the notifications
+	for i, m := range pending {
+		switch outcomes[i] {
+		case DeliveryAccept:
+			m.Accept()
+		case DeliveryReject:
+			m.Reject()
+		case DeliveryDrop:
+			m.Drop()
+		default:
+			panic("invalid delivery outcome " + outcomes[i].String())
+		}
+	}
+}
+
+// DeliverAll finalizes all pending tracking metrics using the given outcome
+func (a *Accumulator) DeliverAll(outcome DeliveryOutcome) {
+	outcomes := make([]DeliveryOutcome, a.NPending())
+	for i := range outcomes {
+		outcomes[i] = outcome
+	}
+	a.Deliver(outcomes...)
+}
+
+// DelayDeliveries delays each following delivery notification by the given
+// duration to simulate slow outputs. Notifications are sent concurrently so
+// their order is not guaranteed, use HoldDeliveries for a defined order.
+func (a *Accumulator) DelayDeliveries(d time.Duration) {
+	a.trackingMutex.Lock()
+	defer a.trackingMutex.Unlock()
+	a.deliveryDelay = d
+}
+
+// HoldDeliveries holds back all following delivery notifications until
+// ReleaseDeliveries is called
+func (a *Accumulator) HoldDeliveries() {
+	a.trackingMutex.Lock()
+	defer a.trackingMutex.Unlock()
+	a.holdDeliveries = true
+}
+
+// NHeld returns the number of delivery notifications held back
+func (a *Accumulator) NHeld() int {
+	a.trackingMutex.Lock()
+	defer a.trackingMutex.Unlock()
+	return len(a.held)
+}
+
+// ReleaseDeliveries stops holding back notifications and sends the held
+// ones. The notifications with the given indices, counting in the order the
+// notifications were held, are sent first in the given order followed by the
+// remaining ones in their original order. This allows to simulate
+// out-of-order deliveries, e.g. ReleaseDeliveries(2, 1, 0) reverses three
+// notifications.
+func (a *Accumulator) ReleaseDeliveries(order ...int) {
+	a.trackingMutex.Lock()
+	held := a.held
+	a.held = nil
+	a.holdDeliveries = false
+	a.trackingMutex.Unlock()
+
+	released := make([]bool, len(held))
+	for _, i := range order {
+		if released[i] {
+			panic(fmt.Sprintf("delivery %d released twice", i))
+		}
+		released[i] = true
+		a.publishDelivery(held[i])
+	}
+	for i, info := range held {
+		if !released[i] {
+			a.publishDelivery(info)
+		}
+	}
+}
+
+// WaitDelivered waits for the given number of delivery notifications to be
+// sent to the input.
+func (a *Accumulator) WaitDelivered(n int) {
+	a.Lock()
+	defer a.Unlock()
+	if a.Cond == nil {
+		a.Cond = sync.NewCond(&a.Mutex)
+	}
+	for len(a.delivered) < n {
+		a.Cond.Wait()
+	}
+}
+
+// RequireDeliveries halts the test with an error if the delivery
+// notifications sent to the input so far do not match the expected ones
+// including their order
+func (a *Accumulator) RequireDeliveries(t testing.TB, expected ...Delivery) {
+	if x, ok := t.(helper); ok {
+		x.Helper()
+	}
+
+	var actual []Delivery
+	for _, info := range a.GetDeliveries() {
+		actual = append(actual, Delivery{ID: info.ID(), Delivered: info.Delivered()})
+	}
+	require.Equal(t, expected, actual, "delivery notifications differ")
+}
+
 // AddError appends the given error to Accumulator.Errors.
 func (a *Accumulator) AddError(err error) {
 	if err == nil {
@@ -376,6 +589,71 @@ func (a *Accumulator) WaitError(n int) {
 	a.Unlock()
 }
 
+// WaitFor waits until the given predicate is satisfied for the metrics
+// collected by the accumulator or the timeout expires. The predicate is
+// called whenever metrics or errors are added and returns true if the
+// predicate was satisfied. The predicate must not call any method of the
+// accumulator.
+func (a *Accumulator) WaitFor(predicate func([]telegraf.Metric) bool, timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+
+	a.Lock()
+	defer a.Unlock()
+	if a.Cond == nil {
+		a.Cond = sync.NewCond(&a.Mutex)
+	}
+
+	// Wake up the waiting loop when the timeout expires
+	timer := time.AfterFunc(timeout, func() {
+		a.Lock()
+		defer a.Unlock()
+		a.Cond.Broadcast()
+	})
+	defer timer.Stop()
+
+	for !predicate(a.accumulated[:len(a.accumulated):len(a.accumulated)]) {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		a.Cond.Wait()
+	}
+	return true
+}
+
+// WaitForTagValue waits until a metric with the given measurement name and
+// tag value was added or the timeout expires. It returns true if such a
+// metric was found.
+func (a *Accumulator) WaitForTagValue(measurement, key, value string, timeout time.Duration) bool {
+	return a.WaitFor(func(metrics []telegraf.Metric) bool {
+		for _, m := range metrics {
+			if m.Name() != measurement {
+				continue
+			}
+			if v, found := m.GetTag(key); found && v == value {
+				return true
+			}
+		}
+		return false
+	}, timeout)
+}
+
+// WaitForFieldValue waits until a metric with the given measurement name and
+// field value was added or the timeout expires. The values are compared
+// including their type. It returns true if such a metric was found.
+func (a *Accumulator) WaitForFieldValue(measurement, field string, value interface{}, timeout time.Duration) bool {
+	return a.WaitFor(func(metrics []telegraf.Metric) bool {
+		for _, m := range metrics {
+			if m.Name() != measurement {
+				continue
+			}
+			if v, found := m.GetField(field); found && reflect.DeepEqual(v, value) {
+				return true
+			}
+		}
+		return false
+	}, timeout)
+}
+
 func (a *Accumulator) AssertContainsTaggedFields(
 	t *testing.T,
 	measurement string,
@@ -0,0 +1,119 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+func TestWaitFor(t *testing.T) {
+	var acc Accumulator
+
+	go func() {
+		for i := range 5 {
+			acc.AddFields("test", map[string]interface{}{"value": int64(i)}, map[string]string{"index": string(rune('a' + i))})
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	require.True(t, acc.WaitFor(func(metrics []telegraf.Metric) bool {
+		return len(metrics) >= 3
+	}, 5*time.Second))
+	require.True(t, acc.WaitForTagValue("test", "index", "e", 5*time.Second))
+	require.True(t, acc.WaitForFieldValue("test", "value", int64(4), 5*time.Second))
+	require.Len(t, acc.GetTelegrafMetrics(), 5)
+}
+
+func TestWaitForTimeout(t *testing.T) {
+	var acc Accumulator
+	acc.AddMetric(metric.New("test", map[string]string{"index": "a"}, map[string]interface{}{"value": int64(1)}, time.Unix(0, 0)))
+
+	start := time.Now()
+	require.False(t, acc.WaitFor(func([]telegraf.Metric) bool { return false }, 50*time.Millisecond))
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+
+	// Values of a different type or measurement do not match
+	require.False(t, acc.WaitForFieldValue("test", "value", 1, 10*time.Millisecond))
+	require.False(t, acc.WaitForTagValue("other", "index", "a", 10*time.Millisecond))
+	require.True(t, acc.WaitForTagValue("test", "index", "a", 10*time.Millisecond))
+}
+
+func TestDeliver(t *testing.T) {
+	var acc Accumulator
+	tacc := acc.WithTracking(10)
+
+	m := metric.New("test", map[string]string{}, map[string]interface{}{"value": int64(1)}, time.Unix(0, 0))
+	id1 := tacc.AddTrackingMetric(m.Copy())
+	id2 := tacc.AddTrackingMetricGroup([]telegraf.Metric{m.Copy(), m.Copy()})
+	id3 := tacc.AddTrackingMetric(m.Copy())
+	require.Equal(t, []telegraf.TrackingID{id1, id2, id3}, acc.TrackingIDs())
+	require.Equal(t, 4, acc.NPending())
+
+	// The group is only notified after all of its metrics are finalized and
+	// a dropped metric counts as delivered
+	acc.Deliver(DeliveryAccept, DeliveryDrop)
+	acc.RequireDeliveries(t, Delivery{ID: id1, Delivered: true})
+	acc.Deliver(DeliveryReject)
+	acc.DeliverAll(DeliveryDrop)
+	require.Zero(t, acc.NPending())
+	acc.RequireDeliveries(t,
+		Delivery{ID: id1, Delivered: true},
+		Delivery{ID: id2, Delivered: false},
+		Delivery{ID: id3, Delivered: true},
+	)
+
+	for _, id := range []telegraf.TrackingID{id1, id2, id3} {
+		info := <-tacc.Delivered()
+		require.Equal(t, id, info.ID())
+	}
+
+	require.Panics(t, func() { acc.Deliver(DeliveryAccept) })
+}
+
+func TestDeliveriesOutOfOrder(t *testing.T) {
+	var acc Accumulator
+	tacc := acc.WithTracking(10)
+
+	m := metric.New("test", map[string]string{}, map[string]interface{}{"value": int64(1)}, time.Unix(0, 0))
+	ids := make([]telegraf.TrackingID, 0, 4)
+	for range 4 {
+		ids = append(ids, tacc.AddTrackingMetric(m.Copy()))
+	}
+
+	acc.HoldDeliveries()
+	acc.Deliver(DeliveryAccept, DeliveryReject, DeliveryAccept)
+	require.Equal(t, 3, acc.NHeld())
+	require.Zero(t, acc.NDelivered())
+
+	acc.ReleaseDeliveries(2, 0)
+	acc.RequireDeliveries(t,
+		Delivery{ID: ids[2], Delivered: true},
+		Delivery{ID: ids[0], Delivered: true},
+		Delivery{ID: ids[1], Delivered: false},
+	)
+
+	// Notifications are not held anymore after releasing
+	acc.Deliver(DeliveryAccept)
+	require.Equal(t, 4, acc.NDelivered())
+}
+
+func TestDeliveriesDelayed(t *testing.T) {
+	var acc Accumulator
+	tacc := acc.WithTracking(10)
+
+	m := metric.New("test", map[string]string{}, map[string]interface{}{"value": int64(1)}, time.Unix(0, 0))
+	id := tacc.AddTrackingMetric(m)
+
+	acc.DelayDeliveries(50 * time.Millisecond)
+	start := time.Now()
+	acc.DeliverAll(DeliveryAccept)
+	require.Zero(t, acc.NDelivered())
+
+	acc.WaitDelivered(1)
+	require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	acc.RequireDeliveries(t, Delivery{ID: id, Delivered: true})
+}
@@ -0,0 +1,123 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// BenchmarkCorpus is a named set of metrics for benchmarks
+type BenchmarkCorpus struct {
+	Name    string
+	Metrics []telegraf.Metric
+}
+
+// BenchmarkCorpora returns the standard corpora for benchmarking parsers and
+// serializers, i.e. small and large batches of typical metrics, wide metrics
+// with many tags and fields and long metrics with long names and string
+// values. The corpora are deterministic to allow comparing results.
+func BenchmarkCorpora() []BenchmarkCorpus {
+	start := time.Unix(1700000000, 0)
+	mixed := []FieldType{FieldFloat, FieldInt, FieldUint, FieldBool, FieldString}
+
+	small := &MetricGenerator{Series: 10, Tags: 2, Fields: 2, Start: start}
+	large := &MetricGenerator{Series: 1000, Tags: 3, Fields: 5, FieldTypes: mixed, Start: start}
+	wide := &MetricGenerator{Series: 10, Tags: 20, Fields: 100, FieldTypes: mixed, Start: start}
+	long := &MetricGenerator{
+		Series:       10,
+		Tags:         2,
+		Fields:       4,
+		FieldTypes:   []FieldType{FieldString},
+		StringLength: 1024,
+		Start:        start,
+	}
+
+	return []BenchmarkCorpus{
+		{Name: "small", Metrics: small.Generate(10)},
+		{Name: "large", Metrics: large.Generate(10000)},
+		{Name: "wide", Metrics: wide.Generate(10)},
+		{Name: "long", Metrics: long.Generate(10)},
+	}
+}
+
+// RunSerializerBenchmarks benchmarks serializing the metrics of each corpus
+// one by one and as batch. Besides the allocations, the throughput and the
+// time per metric are reported to allow comparing corpora of different size.
+func RunSerializerBenchmarks(b *testing.B, serializer telegraf.Serializer) {
+	b.Helper()
+
+	for _, corpus := range BenchmarkCorpora() {
+		b.Run(corpus.Name+"/single", func(b *testing.B) {
+			b.ReportAllocs()
+			var size int64
+			for _, m := range corpus.Metrics {
+				buf, err := serializer.Serialize(m)
+				if err != nil {
+					b.Fatalf("serializing metric failed: %v", err)
+				}
+				size += int64(len(buf))
+			}
+			b.SetBytes(size)
+
+			b.ResetTimer()
+			for range b.N {
+				for _, m := range corpus.Metrics {
+					if _, err := serializer.Serialize(m); err != nil {
+						b.Fatal(err)
+					}
+				}
+			}
+			reportPerMetric(b, len(corpus.Metrics))
+		})
+
+		b.Run(corpus.Name+"/batch", func(b *testing.B) {
+			b.ReportAllocs()
+			buf, err := serializer.SerializeBatch(corpus.Metrics)
+			if err != nil {
+				b.Fatalf("serializing batch failed: %v", err)
+			}
+			b.SetBytes(int64(len(buf)))
+
+			b.ResetTimer()
+			for range b.N {
+				if _, err := serializer.SerializeBatch(corpus.Metrics); err != nil {
+					b.Fatal(err)
+				}
+			}
+			reportPerMetric(b, len(corpus.Metrics))
+		})
+	}
+}
+
+// RunParserBenchmarks benchmarks parsing each corpus. The payloads are
+// created by serializing the corpora in batches using the given serializer
+// which has to produce the format of the parser.
+func RunParserBenchmarks(b *testing.B, parser telegraf.Parser, serializer telegraf.Serializer) {
+	b.Helper()
+
+	for _, corpus := range BenchmarkCorpora() {
+		payload, err := serializer.SerializeBatch(corpus.Metrics)
+		if err != nil {
+			b.Fatalf("serializing corpus %q failed: %v", corpus.Name, err)
+		}
+
+		b.Run(corpus.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(payload)))
+			for range b.N {
+				if _, err := parser.Parse(payload); err != nil {
+					b.Fatal(err)
+				}
+			}
+			reportPerMetric(b, len(corpus.Metrics))
+		})
+	}
+}
+
+func reportPerMetric(b *testing.B, n int) {
+	if b.N == 0 || n == 0 {
+		return
+	}
+	b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/metric")
+}
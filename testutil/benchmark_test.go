@@ -0,0 +1,22 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBenchmarkCorpora(t *testing.T) {
+	corpora := BenchmarkCorpora()
+	names := make([]string, 0, len(corpora))
+	for _, c := range corpora {
+		names = append(names, c.Name)
+		require.NotEmpty(t, c.Metrics, c.Name)
+	}
+	require.Equal(t, []string{"small", "large", "wide", "long"}, names)
+	require.Len(t, corpora[2].Metrics[0].FieldList(), 100)
+	require.Len(t, corpora[2].Metrics[0].TagList(), 20)
+
+	// The corpora are deterministic
+	RequireMetricsEqual(t, corpora[1].Metrics, BenchmarkCorpora()[1].Metrics)
+}
@@ -0,0 +1,123 @@
+package testutil
+
+import (
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// MetricBuilder allows to construct metrics step by step, e.g.
+//
+//	NewMetric("cpu").Tag("cpu", "cpu0").Field("usage_idle", 98.5).Time(t).Build()
+//
+// Metrics without explicit time get the Unix epoch as timestamp to keep
+// tests deterministic.
+type MetricBuilder struct {
+	name   string
+	tags   map[string]string
+	fields map[string]interface{}
+	tm     time.Time
+	tp     telegraf.ValueType
+}
+
+// NewMetric starts building a metric with the given name
+func NewMetric(name string) *MetricBuilder {
+	return &MetricBuilder{
+		name:   name,
+		tags:   make(map[string]string),
+		fields: make(map[string]interface{}),
+		tm:     time.Unix(0, 0),
+		tp:     telegraf.Untyped,
+	}
+}
+
+// Tag sets the given tag
+func (b *MetricBuilder) Tag(key, value string) *MetricBuilder {
+	b.tags[key] = value
+	return b
+}
+
+// Tags sets all given tags
+func (b *MetricBuilder) Tags(tags map[string]string) *MetricBuilder {
+	for k, v := range tags {
+		b.tags[k] = v
+	}
+	return b
+}
+
+// Field sets the given field
+func (b *MetricBuilder) Field(key string, value interface{}) *MetricBuilder {
+	b.fields[key] = value
+	return b
+}
+
+// Fields sets all given fields
+func (b *MetricBuilder) Fields(fields map[string]interface{}) *MetricBuilder {
+	for k, v := range fields {
+		b.fields[k] = v
+	}
+	return b
+}
+
+// Time sets the timestamp of the metric
+func (b *MetricBuilder) Time(t time.Time) *MetricBuilder {
+	b.tm = t
+	return b
+}
+
+// Type sets the value type of the metric
+func (b *MetricBuilder) Type(tp telegraf.ValueType) *MetricBuilder {
+	b.tp = tp
+	return b
+}
+
+// Build returns the metric. The builder can be modified and built again
+// without affecting the returned metric.
+func (b *MetricBuilder) Build() telegraf.Metric {
+	return metric.New(b.name, b.tags, b.fields, b.tm, b.tp)
+}
+
+// BatchBuilder constructs a series of metrics with evenly spaced timestamps
+// starting at the given time, e.g.
+//
+//	NewBatch(time.Unix(0, 0), time.Second).Add(NewMetric("a").Field("x", 1)).AddN(3, func(i int) *MetricBuilder {
+//		return NewMetric("b").Field("x", i)
+//	}).Build()
+//
+// The timestamps set on the metric builders are overridden.
+type BatchBuilder struct {
+	start    time.Time
+	interval time.Duration
+	metrics  []telegraf.Metric
+}
+
+// NewBatch starts building a batch of metrics with the first metric at start
+// and each following metric interval later
+func NewBatch(start time.Time, interval time.Duration) *BatchBuilder {
+	return &BatchBuilder{start: start, interval: interval}
+}
+
+// Add appends the metrics built by the given builders
+func (b *BatchBuilder) Add(builders ...*MetricBuilder) *BatchBuilder {
+	for _, mb := range builders {
+		t := b.start.Add(time.Duration(len(b.metrics)) * b.interval)
+		b.metrics = append(b.metrics, mb.Time(t).Build())
+	}
+	return b
+}
+
+// AddN appends n metrics built by the given function for the indices
+// 0 to n-1
+func (b *BatchBuilder) AddN(n int, f func(i int) *MetricBuilder) *BatchBuilder {
+	for i := 0; i < n; i++ {
+		b.Add(f(i))
+	}
+	return b
+}
+
+// Build returns the metrics of the batch
+func (b *BatchBuilder) Build() []telegraf.Metric {
+	metrics := make([]telegraf.Metric, 0, len(b.metrics))
+	return append(metrics, b.metrics...)
+}
@@ -0,0 +1,56 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+func TestMetricBuilder(t *testing.T) {
+	ts := time.Unix(1700000000, 0)
+	b := NewMetric("cpu").
+		Tag("cpu", "cpu0").
+		Tags(map[string]string{"host": "localhost"}).
+		Field("usage_idle", 98.5).
+		Fields(map[string]interface{}{"usage_user": 1.25}).
+		Time(ts).
+		Type(telegraf.Gauge)
+
+	expected := metric.New("cpu",
+		map[string]string{"cpu": "cpu0", "host": "localhost"},
+		map[string]interface{}{"usage_idle": 98.5, "usage_user": 1.25},
+		ts,
+		telegraf.Gauge,
+	)
+	actual := b.Build()
+	RequireMetricEqual(t, expected, actual)
+
+	// Modifying the builder does not change already built metrics
+	b.Tag("cpu", "cpu1").Field("usage_idle", 50.0)
+	RequireMetricEqual(t, expected, actual)
+
+	// Metrics without explicit time use the epoch
+	require.Equal(t, time.Unix(0, 0), NewMetric("m").Field("x", 1).Build().Time())
+}
+
+func TestBatchBuilder(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	actual := NewBatch(start, time.Second).
+		Add(NewMetric("a").Field("x", 1), NewMetric("a").Field("x", 2)).
+		AddN(2, func(i int) *MetricBuilder {
+			return NewMetric("b").Tag("index", string(rune('0'+i))).Field("x", i).Time(time.Unix(42, 0))
+		}).
+		Build()
+
+	expected := []telegraf.Metric{
+		metric.New("a", map[string]string{}, map[string]interface{}{"x": 1}, start),
+		metric.New("a", map[string]string{}, map[string]interface{}{"x": 2}, start.Add(time.Second)),
+		metric.New("b", map[string]string{"index": "0"}, map[string]interface{}{"x": 0}, start.Add(2*time.Second)),
+		metric.New("b", map[string]string{"index": "1"}, map[string]interface{}{"x": 1}, start.Add(3*time.Second)),
+	}
+	RequireMetricsEqual(t, expected, actual)
+}
@@ -3,7 +3,10 @@ package testutil
 import (
 	"fmt"
 	"log"
+	"sort"
+	"strings"
 	"sync"
+	"testing"
 
 	"github.com/influxdata/telegraf"
 )
@@ -22,40 +25,71 @@ type Entry struct {
 	Level byte
 	Name  string
 	Text  string
+	// Attributes added to the logger at the time of logging
+	Attributes map[string]interface{}
 }
 
 func (e *Entry) String() string {
-	return fmt.Sprintf("%c! [%s] %s", e.Level, e.Name, e.Text)
+	s := fmt.Sprintf("%c! [%s] %s", e.Level, e.Name, e.Text)
+	if len(e.Attributes) == 0 {
+		return s
+	}
+
+	keys := make([]string, 0, len(e.Attributes))
+	for k := range e.Attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	attrs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		attrs = append(attrs, fmt.Sprintf("%s=%v", k, e.Attributes[k]))
+	}
+	return s + " (" + strings.Join(attrs, ", ") + ")"
 }
 
 // CaptureLogger defines a logging structure for plugins.
 type CaptureLogger struct {
-	Name     string // Name is the plugin name, will be printed in the `[]`.
-	messages []Entry
+	Name       string // Name is the plugin name, will be printed in the `[]`.
+	messages   []Entry
+	attributes map[string]interface{}
 	sync.Mutex
 }
 
-func (l *CaptureLogger) print(msg Entry) {
+func (l *CaptureLogger) print(level byte, text string) {
 	l.Lock()
+	msg := Entry{Level: level, Name: l.Name, Text: text}
+	if len(l.attributes) > 0 {
+		msg.Attributes = make(map[string]interface{}, len(l.attributes))
+		for k, v := range l.attributes {
+			msg.Attributes[k] = v
+		}
+	}
 	l.messages = append(l.messages, msg)
 	l.Unlock()
 	log.Print(msg.String())
 }
 
 func (l *CaptureLogger) logf(level byte, format string, args ...any) {
-	l.print(Entry{level, l.Name, fmt.Sprintf(format, args...)})
+	l.print(level, fmt.Sprintf(format, args...))
 }
 
 func (l *CaptureLogger) loga(level byte, args ...any) {
-	l.print(Entry{level, l.Name, fmt.Sprint(args...)})
+	l.print(level, fmt.Sprint(args...))
 }
 
 func (*CaptureLogger) Level() telegraf.LogLevel {
 	return telegraf.Trace
 }
 
-// Adding attributes is not supported by the test-logger
-func (*CaptureLogger) AddAttribute(string, interface{}) {}
+// AddAttribute adds the attribute to all following entries
+func (l *CaptureLogger) AddAttribute(key string, value interface{}) {
+	l.Lock()
+	defer l.Unlock()
+	if l.attributes == nil {
+		l.attributes = make(map[string]interface{})
+	}
+	l.attributes[key] = value
+}
 
 func (l *CaptureLogger) Errorf(format string, args ...interface{}) {
 	l.logf(LevelError, format, args...)
@@ -147,3 +181,87 @@ func (l *CaptureLogger) Clear() {
 	defer l.Unlock()
 	l.messages = make([]Entry, 0)
 }
+
+// Count returns the number of entries with the given level
+func (l *CaptureLogger) Count(level byte) int {
+	l.Lock()
+	defer l.Unlock()
+	var n int
+	for _, m := range l.messages {
+		if m.Level == level {
+			n++
+		}
+	}
+	return n
+}
+
+// Contains checks if there is an entry with the given level containing the
+// given text in its message
+func (l *CaptureLogger) Contains(level byte, text string) bool {
+	l.Lock()
+	defer l.Unlock()
+	for _, m := range l.messages {
+		if m.Level == level && strings.Contains(m.Text, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireErrorContains halts the test if there is no error entry containing
+// the given text
+func (l *CaptureLogger) RequireErrorContains(t testing.TB, text string) {
+	t.Helper()
+	l.requireContains(t, LevelError, text)
+}
+
+// RequireWarnContains halts the test if there is no warning entry containing
+// the given text
+func (l *CaptureLogger) RequireWarnContains(t testing.TB, text string) {
+	t.Helper()
+	l.requireContains(t, LevelWarn, text)
+}
+
+// RequireInfoContains halts the test if there is no info entry containing
+// the given text
+func (l *CaptureLogger) RequireInfoContains(t testing.TB, text string) {
+	t.Helper()
+	l.requireContains(t, LevelInfo, text)
+}
+
+// RequireDebugContains halts the test if there is no debug entry containing
+// the given text
+func (l *CaptureLogger) RequireDebugContains(t testing.TB, text string) {
+	t.Helper()
+	l.requireContains(t, LevelDebug, text)
+}
+
+// RequireNoErrors halts the test if any error was logged
+func (l *CaptureLogger) RequireNoErrors(t testing.TB) {
+	t.Helper()
+	if errs := l.Errors(); len(errs) > 0 {
+		t.Fatalf("unexpected errors logged:\n%s", strings.Join(errs, "\n"))
+	}
+}
+
+// RequireNoWarnings halts the test if any warning was logged
+func (l *CaptureLogger) RequireNoWarnings(t testing.TB) {
+	t.Helper()
+	if warnings := l.Warnings(); len(warnings) > 0 {
+		t.Fatalf("unexpected warnings logged:\n%s", strings.Join(warnings, "\n"))
+	}
+}
+
+func (l *CaptureLogger) requireContains(t testing.TB, level byte, text string) {
+	t.Helper()
+	if l.Contains(level, text) {
+		return
+	}
+
+	msgs := l.Messages()
+	logged := make([]string, 0, len(msgs))
+	for _, m := range msgs {
+		logged = append(logged, m.String())
+	}
+	t.Fatalf("no %c! entry containing %q, logged entries:\n%s", level, text, strings.Join(logged, "\n"))
+}
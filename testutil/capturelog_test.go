@@ -0,0 +1,37 @@
+package testutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureLoggerEntries(t *testing.T) {
+	logger := &CaptureLogger{Name: "inputs.test"}
+	logger.Infof("starting %d clients", 2)
+	logger.AddAttribute("source", "opc.tcp://localhost:4840")
+	logger.Warn("dropped ", 3, " notifications")
+	logger.Warnf("item %q degraded", "ns=3;i=1")
+	logger.Error("connection lost")
+
+	require.Equal(t, 1, logger.Count(LevelInfo))
+	require.Equal(t, 2, logger.Count(LevelWarn))
+	require.Equal(t, 1, logger.Count(LevelError))
+	require.Zero(t, logger.Count(LevelDebug))
+
+	entries := logger.Messages()
+	require.Len(t, entries, 4)
+	require.Nil(t, entries[0].Attributes)
+	require.Equal(t, map[string]interface{}{"source": "opc.tcp://localhost:4840"}, entries[1].Attributes)
+	require.Equal(t, "W! [inputs.test] dropped 3 notifications (source=opc.tcp://localhost:4840)", entries[1].String())
+
+	require.True(t, logger.Contains(LevelWarn, "degraded"))
+	require.False(t, logger.Contains(LevelError, "degraded"))
+	logger.RequireInfoContains(t, "starting 2 clients")
+	logger.RequireWarnContains(t, "dropped 3")
+	logger.RequireErrorContains(t, "connection lost")
+
+	logger.Clear()
+	logger.RequireNoErrors(t)
+	logger.RequireNoWarnings(t)
+}
@@ -0,0 +1,198 @@
+package testutil
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf/plugins/common/tls"
+)
+
+// KeyType is the key algorithm of generated certificates
+type KeyType int
+
+const (
+	KeyTypeRSA KeyType = iota
+	KeyTypeECDSA
+)
+
+// CertificateOptions define the properties of a generated certificate
+type CertificateOptions struct {
+	KeyType KeyType
+	// Size of RSA keys, defaults to 2048 bits
+	RSABits    int
+	CommonName string
+	// Subject alternative names, URIs can be used for OPC UA application URIs
+	DNSNames    []string
+	IPAddresses []net.IP
+	URIs        []string
+	// Validity period, defaults to starting one hour ago and ending in 24 hours
+	NotBefore time.Time
+	NotAfter  time.Time
+}
+
+// Certificate is a generated certificate and key together with the paths of
+// the PEM files
+type Certificate struct {
+	Cert     *x509.Certificate
+	Key      crypto.Signer
+	CertPath string
+	KeyPath  string
+}
+
+// CertificateAuthority issues certificates for tests. All files are written
+// to a temporary directory removed at the end of the test.
+type CertificateAuthority struct {
+	Certificate
+
+	t   testing.TB
+	dir string
+}
+
+// NewCertificateAuthority creates a self-signed CA with the given key type
+func NewCertificateAuthority(t testing.TB, keyType KeyType) *CertificateAuthority {
+	t.Helper()
+
+	ca := &CertificateAuthority{t: t, dir: t.TempDir()}
+	template := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Telegraf Test CA", Organization: []string{"Telegraf"}},
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	ca.Certificate = ca.create("ca", template, CertificateOptions{KeyType: keyType}, nil)
+	return ca
+}
+
+// Issue creates a certificate signed by the CA usable for both server and
+// client authentication
+func (ca *CertificateAuthority) Issue(name string, opts CertificateOptions) *Certificate {
+	ca.t.Helper()
+
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: opts.CommonName, Organization: []string{"Telegraf"}},
+		DNSNames:    opts.DNSNames,
+		IPAddresses: opts.IPAddresses,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		KeyUsage:    x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+	}
+	if opts.KeyType == KeyTypeRSA {
+		template.KeyUsage |= x509.KeyUsageKeyEncipherment | x509.KeyUsageDataEncipherment
+	} else {
+		template.KeyUsage |= x509.KeyUsageKeyAgreement
+	}
+	for _, u := range opts.URIs {
+		uri, err := url.Parse(u)
+		if err != nil {
+			ca.t.Fatalf("parsing URI %q failed: %v", u, err)
+		}
+		template.URIs = append(template.URIs, uri)
+	}
+
+	cert := ca.create(name, template, opts, &ca.Certificate)
+	return &cert
+}
+
+// TLSServerConfig returns a server configuration using the given
+// certificate and requiring client certificates issued by the CA
+func (ca *CertificateAuthority) TLSServerConfig(server *Certificate) *tls.ServerConfig {
+	return &tls.ServerConfig{
+		TLSAllowedCACerts: []string{ca.CertPath},
+		TLSCert:           server.CertPath,
+		TLSKey:            server.KeyPath,
+	}
+}
+
+// TLSClientConfig returns a client configuration using the given
+// certificate and trusting the CA
+func (ca *CertificateAuthority) TLSClientConfig(client *Certificate) *tls.ClientConfig {
+	return &tls.ClientConfig{
+		TLSCA:   ca.CertPath,
+		TLSCert: client.CertPath,
+		TLSKey:  client.KeyPath,
+	}
+}
+
+func (ca *CertificateAuthority) create(name string, template *x509.Certificate, opts CertificateOptions, parent *Certificate) Certificate {
+	ca.t.Helper()
+
+	var key crypto.Signer
+	var err error
+	switch opts.KeyType {
+	case KeyTypeRSA:
+		bits := opts.RSABits
+		if bits == 0 {
+			bits = 2048
+		}
+		key, err = rsa.GenerateKey(rand.Reader, bits)
+	case KeyTypeECDSA:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		ca.t.Fatalf("unknown key type %d", opts.KeyType)
+	}
+	if err != nil {
+		ca.t.Fatalf("generating key for %q failed: %v", name, err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		ca.t.Fatalf("generating serial number failed: %v", err)
+	}
+	template.SerialNumber = serial
+	template.NotBefore = opts.NotBefore
+	if template.NotBefore.IsZero() {
+		template.NotBefore = time.Now().Add(-time.Hour)
+	}
+	template.NotAfter = opts.NotAfter
+	if template.NotAfter.IsZero() {
+		template.NotAfter = time.Now().Add(24 * time.Hour)
+	}
+
+	// Self-sign the CA
+	issuer, signer := template, key
+	if parent != nil {
+		issuer, signer = parent.Cert, parent.Key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, issuer, key.Public(), signer)
+	if err != nil {
+		ca.t.Fatalf("creating certificate %q failed: %v", name, err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		ca.t.Fatalf("parsing certificate %q failed: %v", name, err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		ca.t.Fatalf("encoding key of %q failed: %v", name, err)
+	}
+
+	c := Certificate{
+		Cert:     cert,
+		Key:      key,
+		CertPath: filepath.Join(ca.dir, name+"cert.pem"),
+		KeyPath:  filepath.Join(ca.dir, name+"key.pem"),
+	}
+	ca.writePEM(c.CertPath, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	ca.writePEM(c.KeyPath, &pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return c
+}
+
+func (ca *CertificateAuthority) writePEM(filename string, block *pem.Block) {
+	ca.t.Helper()
+
+	if err := os.WriteFile(filename, pem.EncodeToMemory(block), 0600); err != nil {
+		ca.t.Fatalf("writing %q failed: %v", filename, err)
+	}
+}
@@ -0,0 +1,83 @@
+package testutil
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	cryptotls "crypto/tls"
+	"crypto/x509"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateAuthority(t *testing.T) {
+	for _, keyType := range []KeyType{KeyTypeRSA, KeyTypeECDSA} {
+		ca := NewCertificateAuthority(t, keyType)
+		require.True(t, ca.Cert.IsCA)
+
+		server := ca.Issue("server", CertificateOptions{
+			KeyType:     keyType,
+			CommonName:  "server",
+			DNSNames:    []string{"localhost"},
+			IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+			URIs:        []string{"urn:telegraf:test:server"},
+		})
+		require.Equal(t, "urn:telegraf:test:server", server.Cert.URIs[0].String())
+
+		roots := x509.NewCertPool()
+		roots.AddCert(ca.Cert)
+		_, err := server.Cert.Verify(x509.VerifyOptions{DNSName: "localhost", Roots: roots})
+		require.NoError(t, err)
+
+		pair, err := cryptotls.LoadX509KeyPair(server.CertPath, server.KeyPath)
+		require.NoError(t, err)
+		switch keyType {
+		case KeyTypeRSA:
+			require.IsType(t, &rsa.PrivateKey{}, pair.PrivateKey)
+		case KeyTypeECDSA:
+			require.IsType(t, &ecdsa.PrivateKey{}, pair.PrivateKey)
+		}
+	}
+}
+
+func TestCertificateAuthorityExpired(t *testing.T) {
+	ca := NewCertificateAuthority(t, KeyTypeRSA)
+	expired := ca.Issue("expired", CertificateOptions{
+		DNSNames:  []string{"localhost"},
+		NotBefore: time.Now().Add(-48 * time.Hour),
+		NotAfter:  time.Now().Add(-24 * time.Hour),
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	_, err := expired.Cert.Verify(x509.VerifyOptions{DNSName: "localhost", Roots: roots})
+	require.ErrorContains(t, err, "expired")
+}
+
+func TestCertificateAuthorityTLSHandshake(t *testing.T) {
+	ca := NewCertificateAuthority(t, KeyTypeECDSA)
+	server := ca.Issue("server", CertificateOptions{KeyType: KeyTypeECDSA, DNSNames: []string{"localhost"}})
+	client := ca.Issue("client", CertificateOptions{KeyType: KeyTypeRSA, CommonName: "client"})
+
+	serverCfg, err := ca.TLSServerConfig(server).TLSConfig()
+	require.NoError(t, err)
+	clientCfg, err := ca.TLSClientConfig(client).TLSConfig()
+	require.NoError(t, err)
+	clientCfg.ServerName = "localhost"
+
+	lhs, rhs := net.Pipe()
+	defer lhs.Close()
+	defer rhs.Close()
+
+	errs := make(chan error, 1)
+	go func() {
+		conn := cryptotls.Server(lhs, serverCfg)
+		errs <- conn.Handshake()
+	}()
+	conn := cryptotls.Client(rhs, clientCfg)
+	require.NoError(t, conn.Handshake())
+	require.NoError(t, <-errs)
+	require.Equal(t, "localhost", conn.ConnectionState().PeerCertificates[0].DNSNames[0])
+}
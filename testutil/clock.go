@@ -0,0 +1,17 @@
+package testutil
+
+import (
+	"time"
+
+	"github.com/benbjohnson/clock"
+)
+
+// NewFakeClock returns a mock clock set to the given time for injecting into
+// plugins using a 'clock.Clock'. Timers and tickers created from the clock
+// only fire when advancing the clock using 'Add' or 'Set', so interval and
+// timeout behavior can be tested deterministically without sleeping.
+func NewFakeClock(start time.Time) *clock.Mock {
+	c := clock.NewMock()
+	c.Set(start)
+	return c
+}
@@ -0,0 +1,30 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	clock := NewFakeClock(start)
+	require.Equal(t, start, clock.Now())
+
+	ticker := clock.Ticker(time.Second)
+	defer ticker.Stop()
+	select {
+	case <-ticker.C:
+		require.Fail(t, "ticker fired without advancing the clock")
+	default:
+	}
+
+	clock.Add(time.Second)
+	select {
+	case tick := <-ticker.C:
+		require.Equal(t, start.Add(time.Second), tick)
+	default:
+		require.Fail(t, "ticker did not fire after advancing the clock")
+	}
+}
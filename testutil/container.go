@@ -41,8 +41,9 @@ type Container struct {
 	Ports   map[string]string
 	Logs    TestLogConsumer
 
-	container testcontainers.Container
-	ctx       context.Context
+	container      testcontainers.Container
+	ctx            context.Context
+	networkAliases map[string][]string
 }
 
 func (c *Container) Start() error {
@@ -70,6 +71,7 @@ func (c *Container) Start() error {
 			Name:               c.Name,
 			Hostname:           c.Hostname,
 			Networks:           c.Networks,
+			NetworkAliases:     c.networkAliases,
 			WaitingFor:         c.WaitingFor,
 		},
 		Started: true,
@@ -0,0 +1,131 @@
+//go:build !freebsd
+
+package testutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/network"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// ReadinessProbe declares the conditions for a container to be ready. All
+// given conditions must be met within the timeout.
+type ReadinessProbe struct {
+	// Regular expression to find in the container output
+	LogPattern string
+	// Container port accepting TCP connections, e.g. "1883/tcp"
+	TCPPort string
+	// Path and container port of an HTTP health endpoint which has to return
+	// a 2xx status code
+	HTTPPath string
+	HTTPPort string
+	// Timeout for the container to become ready, defaults to one minute
+	Timeout time.Duration
+}
+
+// Strategy returns the wait strategy for the conditions of the probe
+func (p *ReadinessProbe) Strategy() (wait.Strategy, error) {
+	timeout := p.Timeout
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+
+	strategies := make([]wait.Strategy, 0, 3)
+	if p.LogPattern != "" {
+		strategies = append(strategies, wait.ForLog(p.LogPattern).AsRegexp().WithStartupTimeout(timeout))
+	}
+	if p.TCPPort != "" {
+		strategies = append(strategies, wait.ForListeningPort(nat.Port(p.TCPPort)).WithStartupTimeout(timeout))
+	}
+	if p.HTTPPath != "" {
+		if p.HTTPPort == "" {
+			return nil, errors.New("HTTP probe requires a port")
+		}
+		strategies = append(strategies, wait.ForHTTP(p.HTTPPath).
+			WithPort(nat.Port(p.HTTPPort)).
+			WithStatusCodeMatcher(func(status int) bool { return status >= 200 && status < 300 }).
+			WithStartupTimeout(timeout),
+		)
+	}
+	if len(strategies) == 0 {
+		return nil, errors.New("probe without any condition")
+	}
+	return wait.ForAll(strategies...).WithDeadline(timeout), nil
+}
+
+// ContainerGroup starts dependent containers, e.g. a broker and a simulator
+// publishing to the broker, on a shared network. The containers are started
+// in order, each one after the previous one is ready, and can reach each
+// other using their aliases as hostnames.
+type ContainerGroup struct {
+	Members []*GroupMember
+
+	network *testcontainers.DockerNetwork
+	started []*Container
+}
+
+// GroupMember is a container of a group
+type GroupMember struct {
+	*Container
+	// Hostnames of the container on the shared network
+	Aliases []string
+	// Readiness conditions replacing the 'WaitingFor' setting of the
+	// container if set
+	Probe *ReadinessProbe
+}
+
+// Start creates the shared network and starts all containers. In case of an
+// error, the already started containers are terminated.
+func (g *ContainerGroup) Start() error {
+	ctx := context.Background()
+
+	net, err := network.New(ctx)
+	if err != nil {
+		return fmt.Errorf("creating network failed: %w", err)
+	}
+	g.network = net
+
+	for i, m := range g.Members {
+		if m.Probe != nil {
+			strategy, err := m.Probe.Strategy()
+			if err != nil {
+				g.Terminate()
+				return fmt.Errorf("invalid readiness probe of container %d (%s): %w", i, m.Image, err)
+			}
+			m.WaitingFor = strategy
+		}
+		m.Networks = append(m.Networks, net.Name)
+		if len(m.Aliases) > 0 {
+			m.networkAliases = map[string][]string{net.Name: m.Aliases}
+		}
+
+		if err := m.Start(); err != nil {
+			g.Terminate()
+			return fmt.Errorf("starting container %d (%s) failed: %w", i, m.Image, err)
+		}
+		g.started = append(g.started, m.Container)
+	}
+	return nil
+}
+
+// Terminate stops all started containers in reverse order and removes the
+// shared network
+func (g *ContainerGroup) Terminate() {
+	for i := len(g.started) - 1; i >= 0; i-- {
+		g.started[i].Terminate()
+	}
+	g.started = nil
+
+	if g.network != nil {
+		if err := g.network.Remove(context.Background()); err != nil {
+			fmt.Printf("failed to remove the network: %s", err)
+		}
+		g.network = nil
+	}
+}
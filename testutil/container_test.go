@@ -2,6 +2,7 @@ package testutil
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -70,3 +71,50 @@ func TestBadImageNameIntegration(t *testing.T) {
 	err := container.Start()
 	require.Error(t, err)
 }
+
+func TestReadinessProbeStrategy(t *testing.T) {
+	_, err := (&ReadinessProbe{}).Strategy()
+	require.ErrorContains(t, err, "without any condition")
+
+	_, err = (&ReadinessProbe{HTTPPath: "/health"}).Strategy()
+	require.ErrorContains(t, err, "requires a port")
+
+	strategy, err := (&ReadinessProbe{
+		LogPattern: "ready",
+		TCPPort:    "1883/tcp",
+		HTTPPath:   "/health",
+		HTTPPort:   "8080/tcp",
+		Timeout:    10 * time.Second,
+	}).Strategy()
+	require.NoError(t, err)
+	require.NotNil(t, strategy)
+}
+
+func TestContainerGroupIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	broker := &GroupMember{
+		Container: &Container{
+			Image:        "eclipse-mosquitto:2",
+			Cmd:          []string{"mosquitto", "-c", "/mosquitto-no-auth.conf"},
+			ExposedPorts: []string{"1883"},
+		},
+		Aliases: []string{"broker"},
+		Probe:   &ReadinessProbe{LogPattern: "mosquitto version .* running", TCPPort: "1883/tcp"},
+	}
+	publisher := &GroupMember{
+		Container: &Container{
+			Image: "eclipse-mosquitto:2",
+			Cmd:   []string{"mosquitto_pub", "-h", "broker", "-t", "test", "-m", "hello", "-d"},
+		},
+		Probe: &ReadinessProbe{LogPattern: "Client .* sending PUBLISH"},
+	}
+
+	group := &ContainerGroup{Members: []*GroupMember{broker, publisher}}
+	require.NoError(t, group.Start())
+	defer group.Terminate()
+
+	require.NotEmpty(t, broker.Ports["1883"])
+}
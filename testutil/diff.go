@@ -0,0 +1,148 @@
+package testutil
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/pmezard/go-difflib/difflib"
+
+	"github.com/influxdata/telegraf"
+)
+
+// FieldTolerance allows the float values of the fields with the given names
+// to differ by the given fraction of their magnitude or by the given absolute
+// margin, whichever is greater. The field-names are case-sensitive!
+func FieldTolerance(fraction, margin float64, names ...string) cmp.Option {
+	return cmp.FilterPath(func(p cmp.Path) bool {
+		key, found := parentKey(p, reflect.TypeOf(telegraf.Field{}))
+		return found && slices.Contains(names, key)
+	}, cmpopts.EquateApprox(fraction, margin))
+}
+
+// IgnoreTagValues disables comparison of the values of the tags with the
+// given names, e.g. for volatile tags like a hostname or a random ID. In
+// contrast to IgnoreTags the tags still have to exist in both metrics.
+// The tag-names are case-sensitive!
+func IgnoreTagValues(names ...string) cmp.Option {
+	return cmp.FilterPath(func(p cmp.Path) bool {
+		key, found := parentKey(p, reflect.TypeOf(telegraf.Tag{}))
+		return found && slices.Contains(names, key)
+	}, cmp.Ignore())
+}
+
+// parentKey returns the key of the tag or field of the given type the value
+// at the path belongs to
+func parentKey(p cmp.Path, parent reflect.Type) (string, bool) {
+	for i := len(p) - 1; i > 0; i-- {
+		sf, ok := p[i].(cmp.StructField)
+		if !ok || sf.Name() != "Value" || p[i-1].Type() != parent {
+			continue
+		}
+		vx, vy := p[i-1].Values()
+		if !vx.IsValid() || !vy.IsValid() {
+			return "", false
+		}
+		kx, ky := vx.FieldByName("Key").String(), vy.FieldByName("Key").String()
+		return kx, kx == ky
+	}
+	return "", false
+}
+
+// diffMetrics returns a unified diff of the metrics in a line-protocol like
+// representation or an empty string if the metrics are equal. Metrics equal
+// within the tolerances of the options are shown as unchanged.
+func diffMetrics(lhs, rhs []*metricDiff, opts ...cmp.Option) string {
+	if cmp.Equal(lhs, rhs, opts...) {
+		return ""
+	}
+
+	expected := make([]string, 0, len(lhs))
+	for _, m := range lhs {
+		expected = append(expected, m.String()+"\n")
+	}
+	matched := make([]bool, len(lhs))
+	actual := make([]string, 0, len(rhs))
+	for _, r := range rhs {
+		line := r.String() + "\n"
+		for i, l := range lhs {
+			if !matched[i] && cmp.Equal(l, r, opts...) {
+				matched[i] = true
+				line = expected[i]
+				break
+			}
+		}
+		actual = append(actual, line)
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        expected,
+		B:        actual,
+		FromFile: "expected",
+		ToFile:   "actual",
+		Context:  3,
+		Eol:      "\n",
+	})
+	if err != nil || diff == "" {
+		// The difference cannot be shown in the line representation, e.g.
+		// for differences in the order of sorted metrics
+		return cmp.Diff(lhs, rhs, opts...)
+	}
+	return diff
+}
+
+// String returns a line-protocol like representation of the metric
+func (m *metricDiff) String() string {
+	if m == nil {
+		return "<nil>"
+	}
+
+	var b strings.Builder
+	b.WriteString(m.Measurement)
+	for _, t := range m.Tags {
+		b.WriteString("," + t.Key + "=" + t.Value)
+	}
+	for i, f := range m.Fields {
+		if i == 0 {
+			b.WriteString(" ")
+		} else {
+			b.WriteString(",")
+		}
+		b.WriteString(f.Key + "=" + formatValue(f.Value))
+	}
+	b.WriteString(" " + m.Time.UTC().Format(time.RFC3339Nano))
+
+	switch m.Type {
+	case telegraf.Counter:
+		b.WriteString(" (counter)")
+	case telegraf.Gauge:
+		b.WriteString(" (gauge)")
+	case telegraf.Summary:
+		b.WriteString(" (summary)")
+	case telegraf.Histogram:
+		b.WriteString(" (histogram)")
+	}
+	return b.String()
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case int64:
+		return strconv.FormatInt(v, 10) + "i"
+	case uint64:
+		return strconv.FormatUint(v, 10) + "u"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case string:
+		return strconv.Quote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return fmt.Sprintf("%T(%v)", value, value)
+	}
+}
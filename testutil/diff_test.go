@@ -0,0 +1,86 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+func TestFieldTolerance(t *testing.T) {
+	expected := metric.New("test",
+		map[string]string{"host": "localhost"},
+		map[string]interface{}{"load": 1.0, "usage": 50.0},
+		time.Unix(0, 0),
+	)
+	actual := metric.New("test",
+		map[string]string{"host": "localhost"},
+		map[string]interface{}{"load": 1.05, "usage": 50.0},
+		time.Unix(0, 0),
+	)
+
+	require.False(t, MetricEqual(expected, actual))
+	require.True(t, MetricEqual(expected, actual, FieldTolerance(0, 0.1, "load")))
+	require.False(t, MetricEqual(expected, actual, FieldTolerance(0, 0.1, "usage")))
+	require.False(t, MetricEqual(expected, actual, FieldTolerance(0, 0.01, "load")))
+	require.True(t, MetricEqual(expected, actual, FloatTolerance(0.1, 0)))
+}
+
+func TestTimeTolerance(t *testing.T) {
+	expected := metric.New("test", map[string]string{}, map[string]interface{}{"value": 1}, time.Unix(10, 0))
+	actual := metric.New("test", map[string]string{}, map[string]interface{}{"value": 1}, time.Unix(10, int64(500*time.Millisecond)))
+
+	require.False(t, MetricEqual(expected, actual))
+	require.True(t, MetricEqual(expected, actual, TimeTolerance(time.Second)))
+	require.False(t, MetricEqual(expected, actual, TimeTolerance(100*time.Millisecond)))
+}
+
+func TestIgnoreTagValues(t *testing.T) {
+	expected := metric.New("test", map[string]string{"host": "a", "id": "1"}, map[string]interface{}{"value": 1}, time.Unix(0, 0))
+	actual := metric.New("test", map[string]string{"host": "b", "id": "1"}, map[string]interface{}{"value": 1}, time.Unix(0, 0))
+
+	require.False(t, MetricEqual(expected, actual))
+	require.True(t, MetricEqual(expected, actual, IgnoreTagValues("host")))
+	require.False(t, MetricEqual(expected, actual, IgnoreTagValues("id")))
+
+	// The tag still has to exist
+	missing := metric.New("test", map[string]string{"id": "1"}, map[string]interface{}{"value": 1}, time.Unix(0, 0))
+	require.False(t, MetricEqual(expected, missing, IgnoreTagValues("host")))
+}
+
+func TestDiffMetrics(t *testing.T) {
+	expected := NewBatch(time.Unix(0, 0), time.Second).AddN(5, func(i int) *MetricBuilder {
+		return NewMetric("test").Tag("index", string(rune('0'+i))).Field("value", float64(i)).Field("count", i)
+	}).Build()
+	actual := NewBatch(time.Unix(0, 0), time.Second).AddN(5, func(i int) *MetricBuilder {
+		value := float64(i) + 0.001
+		if i == 3 {
+			value = 42
+		}
+		return NewMetric("test").Tag("index", string(rune('0'+i))).Field("value", value).Field("count", i)
+	}).Build()
+	actual[4].SetType(telegraf.Gauge)
+
+	lhs := make([]*metricDiff, 0, len(expected))
+	for _, m := range expected {
+		lhs = append(lhs, newMetricDiff(m))
+	}
+	rhs := make([]*metricDiff, 0, len(actual))
+	for _, m := range actual {
+		rhs = append(rhs, newMetricDiff(m))
+	}
+
+	// Metrics within the tolerance are shown as unchanged
+	diff := diffMetrics(lhs, rhs, FieldTolerance(0, 0.01, "value"))
+	require.Contains(t, diff, "--- expected\n+++ actual\n")
+	require.Contains(t, diff, "\n test,index=0 count=0i,value=0 1970-01-01T00:00:00Z\n")
+	require.Contains(t, diff, "\n-test,index=3 count=3i,value=3 1970-01-01T00:00:03Z\n")
+	require.Contains(t, diff, "\n+test,index=3 count=3i,value=42 1970-01-01T00:00:03Z\n")
+	require.Contains(t, diff, "\n+test,index=4 count=4i,value=4.001 1970-01-01T00:00:04Z (gauge)\n")
+	require.NotContains(t, diff, "0.001")
+
+	require.Empty(t, diffMetrics(lhs, lhs))
+}
@@ -0,0 +1,205 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// FuzzStrings are edge cases for metric names, tag keys and values and
+// field keys and string values covering escaping, unicode and invalid data
+var FuzzStrings = []string{
+	"",
+	" ",
+	"a b",
+	"a,b",
+	"a=b",
+	`a"b`,
+	`a\`,
+	`\`,
+	`\\`,
+	`\"`,
+	"a\nb",
+	"a\tb",
+	"\r\n",
+	"#",
+	"-",
+	"0",
+	"1e3",
+	"true",
+	"null",
+	"ä",
+	"日本語",
+	"🚀",
+	"a​b",
+	"\x00",
+	"a\x00b",
+	"\xff\xfe",
+	"\xc3",
+	strings.Repeat("x", 65536),
+}
+
+// FuzzFieldValues are edge cases for field values covering special floats,
+// integer limits and all supported types
+var FuzzFieldValues = []interface{}{
+	math.NaN(),
+	math.Inf(1),
+	math.Inf(-1),
+	math.Copysign(0, -1),
+	math.MaxFloat64,
+	-math.MaxFloat64,
+	math.SmallestNonzeroFloat64,
+	float64(1 << 53),
+	int64(0),
+	int64(math.MaxInt64),
+	int64(math.MinInt64),
+	uint64(0),
+	uint64(math.MaxUint64),
+	true,
+	false,
+	"",
+	`"quoted" \escaped\`,
+	"line\nbreak",
+	"\xff",
+	strings.Repeat("x", 65536),
+}
+
+// FuzzMetrics returns metrics using each of the FuzzStrings as name, tag key,
+// tag value, field key and field value and each of the FuzzFieldValues as
+// field value. Serializers are expected to either serialize the metrics or
+// to return an error, but never to panic.
+func FuzzMetrics() []telegraf.Metric {
+	tm := time.Unix(1700000000, 0)
+	metrics := make([]telegraf.Metric, 0, 5*len(FuzzStrings)+len(FuzzFieldValues))
+	for _, s := range FuzzStrings {
+		metrics = append(metrics,
+			metric.New(s, map[string]string{"tag": "value"}, map[string]interface{}{"value": 42.0}, tm),
+			metric.New("fuzz", map[string]string{s: "value"}, map[string]interface{}{"value": 42.0}, tm),
+			metric.New("fuzz", map[string]string{"tag": s}, map[string]interface{}{"value": 42.0}, tm),
+			metric.New("fuzz", map[string]string{"tag": "value"}, map[string]interface{}{s: 42.0}, tm),
+			metric.New("fuzz", map[string]string{"tag": "value"}, map[string]interface{}{"value": s}, tm),
+		)
+	}
+	for _, v := range FuzzFieldValues {
+		metrics = append(metrics, metric.New("fuzz", map[string]string{"tag": "value"}, map[string]interface{}{"value": v}, tm))
+	}
+	return metrics
+}
+
+// MetricFromFuzzInput builds a metric from arbitrary input of a fuzz test,
+// e.g. to fuzz serializers using 'f.Fuzz(func(t *testing.T, data []byte))'.
+// The input selects the edge cases from FuzzStrings and FuzzFieldValues and
+// provides raw strings and values, so every input results in a metric.
+func MetricFromFuzzInput(data []byte) telegraf.Metric {
+	in := &fuzzInput{data: data}
+
+	name := in.string()
+	tags := make(map[string]string)
+	for n := in.byte() % 4; n > 0; n-- {
+		tags[in.string()] = in.string()
+	}
+	fields := make(map[string]interface{})
+	for n := in.byte()%4 + 1; n > 0; n-- {
+		fields[in.string()] = in.value()
+	}
+	tm := time.Unix(0, int64(in.uint64()))
+
+	return metric.New(name, tags, fields, tm)
+}
+
+// AddFuzzPayloads adds the given payloads, the tokens of the dictionary and
+// mutations of the payloads to the seed corpus of the fuzz test. Mutations
+// include truncations, dictionary tokens inserted at various positions,
+// invalid UTF-8 and null bytes.
+func AddFuzzPayloads(f *testing.F, dictionary []string, payloads ...[]byte) {
+	f.Helper()
+
+	for _, token := range dictionary {
+		f.Add([]byte(token))
+	}
+	for _, payload := range payloads {
+		for _, p := range mutatePayload(payload, dictionary) {
+			f.Add(p)
+		}
+	}
+}
+
+func mutatePayload(payload []byte, dictionary []string) [][]byte {
+	mutations := [][]byte{
+		bytes.Clone(payload),
+		payload[:len(payload)/2],
+		payload[:max(len(payload)-1, 0)],
+		append(bytes.Clone(payload), payload...),
+		append(bytes.Clone(payload), 0xff, 0xfe),
+		append([]byte{0}, payload...),
+		bytes.ReplaceAll(payload, []byte("\n"), []byte("\r\n")),
+	}
+	for _, token := range dictionary {
+		for _, pos := range []int{0, len(payload) / 2, len(payload)} {
+			m := make([]byte, 0, len(payload)+len(token))
+			m = append(m, payload[:pos]...)
+			m = append(m, token...)
+			m = append(m, payload[pos:]...)
+			mutations = append(mutations, m)
+		}
+	}
+	return mutations
+}
+
+// fuzzInput consumes fuzz data returning zero values once exhausted
+type fuzzInput struct {
+	data []byte
+}
+
+func (in *fuzzInput) byte() byte {
+	if len(in.data) == 0 {
+		return 0
+	}
+	b := in.data[0]
+	in.data = in.data[1:]
+	return b
+}
+
+func (in *fuzzInput) uint64() uint64 {
+	var buf [8]byte
+	n := copy(buf[:], in.data)
+	in.data = in.data[n:]
+	return binary.LittleEndian.Uint64(buf[:])
+}
+
+// string returns one of the edge cases or a raw string of up to 31 bytes
+func (in *fuzzInput) string() string {
+	selector := in.byte()
+	if selector&0x80 == 0 {
+		return FuzzStrings[int(selector)%len(FuzzStrings)]
+	}
+	n := min(int(selector&0x1f), len(in.data))
+	s := string(in.data[:n])
+	in.data = in.data[n:]
+	return s
+}
+
+// value returns one of the edge cases or a raw value of any field type
+func (in *fuzzInput) value() interface{} {
+	selector := in.byte()
+	switch selector % 8 {
+	case 0:
+		return FuzzFieldValues[int(selector/8)%len(FuzzFieldValues)]
+	case 1:
+		return math.Float64frombits(in.uint64())
+	case 2:
+		return int64(in.uint64())
+	case 3:
+		return in.uint64()
+	case 4:
+		return in.byte()&1 == 1
+	default:
+		return in.string()
+	}
+}
@@ -0,0 +1,57 @@
+package testutil
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFuzzMetrics(t *testing.T) {
+	metrics := FuzzMetrics()
+	require.Len(t, metrics, 5*len(FuzzStrings)+len(FuzzFieldValues))
+
+	var nan, inf bool
+	for _, m := range metrics {
+		v, found := m.GetField("value")
+		if !found {
+			continue
+		}
+		if f, ok := v.(float64); ok {
+			nan = nan || math.IsNaN(f)
+			inf = inf || math.IsInf(f, 0)
+		}
+	}
+	require.True(t, nan, "no NaN value")
+	require.True(t, inf, "no Inf value")
+}
+
+func TestMetricFromFuzzInput(t *testing.T) {
+	inputs := [][]byte{
+		nil,
+		{0xff},
+		[]byte("\x84name\x02\x81a\x81b\x82cd\x00\x00\x85field\x01\x00\x00\x00\x00\x00\x00\xf0\x3f"),
+	}
+	for _, in := range inputs {
+		m := MetricFromFuzzInput(in)
+		require.NotNil(t, m)
+		require.NotEmpty(t, m.FieldList())
+	}
+
+	m := MetricFromFuzzInput(inputs[2])
+	require.Equal(t, "name", m.Name())
+	require.Equal(t, map[string]string{"a": "b", "cd": ""}, m.Tags())
+	require.Equal(t, map[string]interface{}{"field": 1.0}, m.Fields())
+
+	// The same input results in the same metric
+	RequireMetricEqual(t, m, MetricFromFuzzInput(inputs[2]))
+}
+
+func TestMutatePayload(t *testing.T) {
+	mutations := mutatePayload([]byte("cpu value=1\n"), []string{","})
+	require.Contains(t, mutations, []byte("cpu value=1\n"))
+	require.Contains(t, mutations, []byte("cpu va"))
+	require.Contains(t, mutations, []byte(",cpu value=1\n"))
+	require.Contains(t, mutations, []byte("cpu value=1\n,"))
+	require.Contains(t, mutations, []byte("cpu value=1\r\n"))
+}
@@ -0,0 +1,181 @@
+package testutil
+
+import (
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// FieldType is the type of generated field values
+type FieldType int
+
+const (
+	FieldFloat FieldType = iota
+	FieldInt
+	FieldUint
+	FieldString
+	FieldBool
+)
+
+// TimestampPattern defines the timestamps of generated metrics
+type TimestampPattern int
+
+const (
+	// All series are sampled at the same, regular points in time
+	TimestampsRegular TimestampPattern = iota
+	// Regular timestamps shifted by a random offset of up to 'Jitter'
+	TimestampsJitter
+	// Regular timestamps shifted by a random offset of up to 'Jitter' in
+	// both directions, so metrics arrive out of order
+	TimestampsOutOfOrder
+	// All metrics have the start time
+	TimestampsIdentical
+)
+
+// MetricGenerator produces metrics for load tests and benchmarks of
+// processors, serializers and outputs. The metrics are spread over the given
+// number of series, i.e. unique combinations of measurement and tags, with
+// one metric per series and interval. The generated metrics are
+// deterministic for a given seed.
+type MetricGenerator struct {
+	// Number of measurement names, defaults to one
+	Measurements int
+	// Number of unique series, defaults to one
+	Series int
+	// Number of tags per metric, defaults to one
+	Tags int
+	// Number of distinct values per tag, defaults to the number of series so
+	// the first tag alone is unique per series
+	TagCardinality int
+	// Number of fields per metric, defaults to one
+	Fields int
+	// Types of the fields cycled over the fields, defaults to float only
+	FieldTypes []FieldType
+	// Length of generated string values, defaults to 16 characters
+	StringLength int
+
+	// Time of the first metric, defaults to the Unix epoch
+	Start time.Time
+	// Time between two metrics of the same series, defaults to one second
+	Interval   time.Duration
+	Timestamps TimestampPattern
+	// Maximum random offset of the timestamps for the jitter patterns,
+	// defaults to half the interval
+	Jitter time.Duration
+
+	Seed int64
+}
+
+// Generate returns n metrics. Consecutive metrics belong to consecutive
+// series, i.e. the first round of metrics contains one metric for each
+// series before the second round starts.
+func (g *MetricGenerator) Generate(n int) []telegraf.Metric {
+	metrics := make([]telegraf.Metric, 0, n)
+	g.Each(n, func(m telegraf.Metric) {
+		metrics = append(metrics, m)
+	})
+	return metrics
+}
+
+// Each generates n metrics and passes them to the given function one by one
+// to produce large volumes without keeping all metrics in memory
+func (g *MetricGenerator) Each(n int, f func(telegraf.Metric)) {
+	measurements := max(g.Measurements, 1)
+	series := max(g.Series, 1)
+	numTags := max(g.Tags, 1)
+	cardinality := g.TagCardinality
+	if cardinality <= 0 {
+		cardinality = series
+	}
+	numFields := max(g.Fields, 1)
+	fieldTypes := g.FieldTypes
+	if len(fieldTypes) == 0 {
+		fieldTypes = []FieldType{FieldFloat}
+	}
+	start := g.Start
+	if start.IsZero() {
+		start = time.Unix(0, 0)
+	}
+	interval := g.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	jitter := g.Jitter
+	if jitter <= 0 {
+		jitter = interval / 2
+	}
+
+	// Names are created upfront as they repeat for every series
+	names := make([]string, measurements)
+	for i := range names {
+		names[i] = "metric" + strconv.Itoa(i)
+	}
+	tagKeys := make([]string, numTags)
+	for i := range tagKeys {
+		tagKeys[i] = "tag" + strconv.Itoa(i)
+	}
+	fieldKeys := make([]string, numFields)
+	for i := range fieldKeys {
+		fieldKeys[i] = "field" + strconv.Itoa(i)
+	}
+
+	//nolint:gosec // G404: not used for security purposes
+	rng := rand.New(rand.NewSource(g.Seed))
+	for i := 0; i < n; i++ {
+		s := i % series
+		round := i / series
+
+		// Derive the tag values from the series index as digits to the
+		// base of the cardinality
+		tags := make(map[string]string, numTags)
+		v := s
+		for _, key := range tagKeys {
+			tags[key] = "value" + strconv.Itoa(v%cardinality)
+			v /= cardinality
+		}
+
+		fields := make(map[string]interface{}, numFields)
+		for k, key := range fieldKeys {
+			fields[key] = g.value(rng, fieldTypes[k%len(fieldTypes)])
+		}
+
+		t := start.Add(time.Duration(round) * interval)
+		switch g.Timestamps {
+		case TimestampsJitter:
+			t = t.Add(time.Duration(rng.Int63n(int64(jitter) + 1)))
+		case TimestampsOutOfOrder:
+			t = t.Add(time.Duration(rng.Int63n(2*int64(jitter)+1) - int64(jitter)))
+		case TimestampsIdentical:
+			t = start
+		}
+
+		f(metric.New(names[s%measurements], tags, fields, t))
+	}
+}
+
+func (g *MetricGenerator) value(rng *rand.Rand, ft FieldType) interface{} {
+	switch ft {
+	case FieldInt:
+		return rng.Int63() - rng.Int63()
+	case FieldUint:
+		return rng.Uint64()
+	case FieldString:
+		length := g.StringLength
+		if length <= 0 {
+			length = 16
+		}
+		const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+		buf := make([]byte, length)
+		for i := range buf {
+			buf[i] = letters[rng.Intn(len(letters))]
+		}
+		return string(buf)
+	case FieldBool:
+		return rng.Intn(2) == 1
+	default:
+		return rng.NormFloat64() * 100
+	}
+}
@@ -0,0 +1,90 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+)
+
+func TestMetricGeneratorSeries(t *testing.T) {
+	g := &MetricGenerator{
+		Measurements:   2,
+		Series:         6,
+		Tags:           2,
+		TagCardinality: 3,
+		Fields:         5,
+		FieldTypes:     []FieldType{FieldFloat, FieldInt, FieldUint, FieldString, FieldBool},
+		StringLength:   4,
+		Start:          time.Unix(1000, 0),
+		Interval:       10 * time.Second,
+	}
+	metrics := g.Generate(12)
+	require.Len(t, metrics, 12)
+
+	series := make(map[uint64]bool)
+	for i, m := range metrics {
+		series[m.HashID()] = true
+		require.Len(t, m.TagList(), 2)
+		require.IsType(t, float64(0), m.Fields()["field0"])
+		require.IsType(t, int64(0), m.Fields()["field1"])
+		require.IsType(t, uint64(0), m.Fields()["field2"])
+		require.Len(t, m.Fields()["field3"], 4)
+		require.IsType(t, false, m.Fields()["field4"])
+		require.Equal(t, time.Unix(1000+int64(i/6)*10, 0), m.Time())
+	}
+	require.Len(t, series, 6)
+
+	require.Equal(t, "metric1", metrics[1].Name())
+	require.Equal(t, map[string]string{"tag0": "value1", "tag1": "value1"}, metrics[4].Tags())
+
+	// The same seed produces the same metrics
+	RequireMetricsEqual(t, metrics, g.Generate(12))
+}
+
+func TestMetricGeneratorTimestamps(t *testing.T) {
+	start := time.Unix(1000, 0)
+	tests := []struct {
+		name    string
+		pattern TimestampPattern
+		check   func(t *testing.T, i int, m telegraf.Metric)
+	}{
+		{
+			name:    "jitter",
+			pattern: TimestampsJitter,
+			check: func(t *testing.T, i int, m telegraf.Metric) {
+				regular := start.Add(time.Duration(i) * time.Second)
+				require.False(t, m.Time().Before(regular))
+				require.LessOrEqual(t, m.Time().Sub(regular), 100*time.Millisecond)
+			},
+		},
+		{
+			name:    "out of order",
+			pattern: TimestampsOutOfOrder,
+			check: func(t *testing.T, i int, m telegraf.Metric) {
+				regular := start.Add(time.Duration(i) * time.Second)
+				require.LessOrEqual(t, m.Time().Sub(regular).Abs(), 100*time.Millisecond)
+			},
+		},
+		{
+			name:    "identical",
+			pattern: TimestampsIdentical,
+			check: func(t *testing.T, _ int, m telegraf.Metric) {
+				require.Equal(t, start, m.Time())
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &MetricGenerator{Start: start, Timestamps: tt.pattern, Jitter: 100 * time.Millisecond, Seed: 42}
+			var i int
+			g.Each(100, func(m telegraf.Metric) {
+				tt.check(t, i, m)
+				i++
+			})
+			require.Equal(t, 100, i)
+		})
+	}
+}
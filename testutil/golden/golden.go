@@ -0,0 +1,88 @@
+// Package golden allows to compare metrics against expected metrics stored
+// in line-protocol files. Running the tests with the '-update' flag
+// (re)writes the files with the actual metrics instead of comparing them,
+// e.g. 'go test ./plugins/inputs/example/... -update'.
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/plugins/parsers/influx"
+	serializer "github.com/influxdata/telegraf/plugins/serializers/influx"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+var update = flag.Bool("update", false, "update the golden files with the actual metrics")
+
+// Load reads the metrics from the given golden file. Lines starting with
+// '#' are ignored. Metrics without timestamp get the zero time.
+func Load(filename string) ([]telegraf.Metric, error) {
+	parser := &influx.Parser{}
+	if err := parser.Init(); err != nil {
+		return nil, err
+	}
+	parser.SetTimeFunc(func() time.Time { return time.Time{} })
+
+	return testutil.ParseMetricsFromFile(filename, parser)
+}
+
+// Write stores the metrics in the given golden file creating missing
+// directories. Metrics with the zero time are written without timestamp.
+func Write(filename string, metrics []telegraf.Metric) error {
+	s := &serializer.Serializer{SortFields: true, UintSupport: true}
+	if err := s.Init(); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		line, err := s.Serialize(m)
+		if err != nil {
+			return fmt.Errorf("serializing metric %v failed: %w", m, err)
+		}
+		if m.Time().IsZero() {
+			// Strip the timestamp and keep the newline
+			line = append(line[:bytes.LastIndexByte(line, ' ')], '\n')
+		}
+		buf.Write(line)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), 0750); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0640)
+}
+
+// RequireMetrics halts the test with an error if the actual metrics do not
+// match the metrics in the given golden file. Types are not compared as
+// line-protocol does not contain the metric type, use the options of
+// testutil, e.g. testutil.FloatTolerance, to further relax the comparison.
+// With the '-update' flag the golden file is written instead.
+func RequireMetrics(t testing.TB, filename string, actual []telegraf.Metric, opts ...cmp.Option) {
+	t.Helper()
+
+	if *update {
+		if err := Write(filename, actual); err != nil {
+			t.Fatalf("updating golden file %q failed: %v", filename, err)
+		}
+		t.Logf("Updated golden file %q with %d metrics", filename, len(actual))
+		return
+	}
+
+	expected, err := Load(filename)
+	if err != nil {
+		t.Fatalf("loading golden file %q failed: %v (run the test with '-update' to create it)", filename, err)
+	}
+
+	opts = append(opts, testutil.IgnoreType())
+	testutil.RequireMetricsEqual(t, expected, actual, opts...)
+}
@@ -0,0 +1,63 @@
+package golden
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+	"github.com/influxdata/telegraf/testutil"
+)
+
+func actualMetrics() []telegraf.Metric {
+	return []telegraf.Metric{
+		metric.New("cpu",
+			map[string]string{"cpu": "cpu0", "host": "localhost"},
+			map[string]interface{}{"usage_idle": 98.5, "usage_user": 1.25},
+			time.Unix(1700000000, 0),
+			telegraf.Gauge,
+		),
+		metric.New("disk",
+			map[string]string{"device": "sda", "host": "localhost"},
+			map[string]interface{}{"free": uint64(1024), "used": int64(2048), "mounted": true},
+			time.Unix(1700000000, 0),
+		),
+		metric.New("status",
+			map[string]string{"host": "localhost"},
+			map[string]interface{}{"message": "all good"},
+			time.Time{},
+		),
+	}
+}
+
+func TestRequireMetrics(t *testing.T) {
+	RequireMetrics(t, filepath.Join("testdata", "metrics.golden"), actualMetrics())
+}
+
+func TestRequireMetricsTolerance(t *testing.T) {
+	actual := actualMetrics()
+	actual[0].AddField("usage_idle", 98.5001)
+	actual[0].SetTime(actual[0].Time().Add(time.Millisecond))
+
+	RequireMetrics(t, filepath.Join("testdata", "metrics.golden"), actual,
+		testutil.FloatTolerance(0, 0.001),
+		testutil.TimeTolerance(time.Second),
+	)
+}
+
+func TestWriteLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "subdir", "metrics.golden")
+	require.NoError(t, Write(filename, actualMetrics()))
+
+	expected, err := Load(filename)
+	require.NoError(t, err)
+	testutil.RequireMetricsEqual(t, expected, actualMetrics(), testutil.IgnoreType())
+}
+
+func TestLoadMissingFile(t *testing.T) {
+	_, err := Load(filepath.Join("testdata", "non-existing.golden"))
+	require.Error(t, err)
+}
@@ -0,0 +1,214 @@
+package testutil
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/influxdata/telegraf"
+)
+
+// MetricMatcher describes the expected properties of a metric. Only the
+// given tags and fields are checked, additional tags and fields of the
+// actual metric are ignored. The expected values of tags and string fields
+// can be regular expressions, e.g. for endpoints, UUIDs or versions:
+//
+//	testutil.MetricMatcher{
+//		Name: "opcua",
+//		Tags: map[string]interface{}{"id": testutil.Regex(`^ns=\d+;s=`)},
+//		Fields: map[string]interface{}{"value": 42.0},
+//	}
+type MetricMatcher struct {
+	// Name of the metric, matches any name if empty
+	Name string
+	// Expected tag values, either as string or as *regexp.Regexp
+	Tags map[string]interface{}
+	// Expected field values, either as value or as *regexp.Regexp matching
+	// string fields
+	Fields map[string]interface{}
+	// Time of the metric, matches any time if zero
+	Time time.Time
+}
+
+// Regex compiles the given regular expression for use in a MetricMatcher
+// and panics if the expression is invalid
+func Regex(expr string) *regexp.Regexp {
+	return regexp.MustCompile(expr)
+}
+
+// Match checks if the metric matches all properties of the matcher. The
+// options are used for comparing field values, e.g. FloatTolerance.
+func (mm *MetricMatcher) Match(m telegraf.Metric, opts ...cmp.Option) bool {
+	return len(mm.mismatches(m, opts...)) == 0
+}
+
+// mismatches returns a description of all properties not matching the
+// metric
+func (mm *MetricMatcher) mismatches(m telegraf.Metric, opts ...cmp.Option) []string {
+	var mismatches []string
+	if mm.Name != "" && mm.Name != m.Name() {
+		mismatches = append(mismatches, fmt.Sprintf("name %q != %q", m.Name(), mm.Name))
+	}
+	if !mm.Time.IsZero() && !cmp.Equal(mm.Time, m.Time(), opts...) {
+		mismatches = append(mismatches, fmt.Sprintf("time %s != %s", m.Time(), mm.Time))
+	}
+
+	for _, key := range sortedKeys(mm.Tags) {
+		actual, found := m.GetTag(key)
+		if !found {
+			mismatches = append(mismatches, fmt.Sprintf("tag %q missing", key))
+			continue
+		}
+		switch expected := mm.Tags[key].(type) {
+		case *regexp.Regexp:
+			if !expected.MatchString(actual) {
+				mismatches = append(mismatches, fmt.Sprintf("tag %q = %q does not match %q", key, actual, expected))
+			}
+		case string:
+			if actual != expected {
+				mismatches = append(mismatches, fmt.Sprintf("tag %q = %q != %q", key, actual, expected))
+			}
+		default:
+			mismatches = append(mismatches, fmt.Sprintf("tag %q has invalid expected value of type %T", key, expected))
+		}
+	}
+
+	for _, key := range sortedKeys(mm.Fields) {
+		actual, found := m.GetField(key)
+		if !found {
+			mismatches = append(mismatches, fmt.Sprintf("field %q missing", key))
+			continue
+		}
+		switch expected := mm.Fields[key].(type) {
+		case *regexp.Regexp:
+			s, ok := actual.(string)
+			if !ok || !expected.MatchString(s) {
+				mismatches = append(mismatches, fmt.Sprintf("field %q = %s does not match %q", key, formatValue(actual), expected))
+			}
+		default:
+			if !cmp.Equal(expected, actual, opts...) {
+				mismatches = append(mismatches, fmt.Sprintf("field %q = %s != %s", key, formatValue(actual), formatValue(expected)))
+			}
+		}
+	}
+	return mismatches
+}
+
+// String returns a line-protocol like representation of the matcher with
+// regular expressions enclosed in slashes
+func (mm *MetricMatcher) String() string {
+	var b strings.Builder
+	if mm.Name == "" {
+		b.WriteString("*")
+	} else {
+		b.WriteString(mm.Name)
+	}
+	for _, key := range sortedKeys(mm.Tags) {
+		b.WriteString("," + key + "=")
+		if re, ok := mm.Tags[key].(*regexp.Regexp); ok {
+			b.WriteString("/" + re.String() + "/")
+		} else {
+			fmt.Fprint(&b, mm.Tags[key])
+		}
+	}
+	for i, key := range sortedKeys(mm.Fields) {
+		if i == 0 {
+			b.WriteString(" ")
+		} else {
+			b.WriteString(",")
+		}
+		b.WriteString(key + "=")
+		if re, ok := mm.Fields[key].(*regexp.Regexp); ok {
+			b.WriteString("/" + re.String() + "/")
+		} else {
+			b.WriteString(formatValue(mm.Fields[key]))
+		}
+	}
+	if !mm.Time.IsZero() {
+		b.WriteString(" " + mm.Time.UTC().Format(time.RFC3339Nano))
+	}
+	return b.String()
+}
+
+// RequireMetricMatch halts the test with an error if the metric does not
+// match the matcher
+func RequireMetricMatch(t testing.TB, expected MetricMatcher, actual telegraf.Metric, opts ...cmp.Option) {
+	if x, ok := t.(helper); ok {
+		x.Helper()
+	}
+
+	if actual == nil {
+		t.Fatalf("metric %s not found", expected.String())
+	}
+	if mismatches := expected.mismatches(actual, opts...); len(mismatches) > 0 {
+		t.Fatalf("metric does not match %s\nactual: %s\n%s", expected.String(), newMetricDiff(actual).String(), strings.Join(mismatches, "\n"))
+	}
+}
+
+// RequireMetricsMatch halts the test with an error if not every matcher
+// matches a different one of the actual metrics. Additional metrics as well
+// as the order of the metrics are ignored.
+func RequireMetricsMatch(t testing.TB, expected []MetricMatcher, actual []telegraf.Metric, opts ...cmp.Option) {
+	if x, ok := t.(helper); ok {
+		x.Helper()
+	}
+
+	// Assign the metrics to the matchers such that as many matchers as
+	// possible are satisfied, a matcher might match multiple metrics
+	candidates := make([][]int, len(expected))
+	for i := range expected {
+		for j, m := range actual {
+			if expected[i].Match(m, opts...) {
+				candidates[i] = append(candidates[i], j)
+			}
+		}
+	}
+	assigned := make([]int, len(actual))
+	for j := range assigned {
+		assigned[j] = -1
+	}
+	var assign func(i int, visited []bool) bool
+	assign = func(i int, visited []bool) bool {
+		for _, j := range candidates[i] {
+			if visited[j] {
+				continue
+			}
+			visited[j] = true
+			if assigned[j] < 0 || assign(assigned[j], visited) {
+				assigned[j] = i
+				return true
+			}
+		}
+		return false
+	}
+
+	var unmatched []string
+	for i := range expected {
+		if !assign(i, make([]bool, len(actual))) {
+			unmatched = append(unmatched, expected[i].String())
+		}
+	}
+	if len(unmatched) == 0 {
+		return
+	}
+
+	lines := make([]string, 0, len(actual))
+	for _, m := range actual {
+		lines = append(lines, newMetricDiff(m).String())
+	}
+	t.Fatalf("no matching metrics for\n%s\nactual metrics:\n%s", strings.Join(unmatched, "\n"), strings.Join(lines, "\n"))
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
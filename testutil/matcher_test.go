@@ -0,0 +1,122 @@
+package testutil
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+// failureRecorder records fatal failures instead of halting the test
+type failureRecorder struct {
+	testing.TB
+	failure string
+}
+
+func (r *failureRecorder) Fatalf(format string, args ...interface{}) {
+	r.failure = fmt.Sprintf(format, args...)
+}
+
+func TestMetricMatcher(t *testing.T) {
+	m := metric.New("opcua",
+		map[string]string{"id": "ns=3;s=speed", "source": "opc.tcp://10.0.0.1:4840", "host": "a"},
+		map[string]interface{}{"value": 42.01, "version": "v1.2.3", "Quality": "OK"},
+		time.Unix(10, 0),
+	)
+
+	tests := []struct {
+		name     string
+		matcher  MetricMatcher
+		expected bool
+	}{
+		{
+			name:     "empty",
+			expected: true,
+		},
+		{
+			name: "exact subset",
+			matcher: MetricMatcher{
+				Name:   "opcua",
+				Tags:   map[string]interface{}{"host": "a"},
+				Fields: map[string]interface{}{"Quality": "OK"},
+				Time:   time.Unix(10, 0),
+			},
+			expected: true,
+		},
+		{
+			name: "regex",
+			matcher: MetricMatcher{
+				Tags:   map[string]interface{}{"id": Regex(`^ns=\d+;s=`), "source": Regex(`^opc\.tcp://[\d.]+:\d+$`)},
+				Fields: map[string]interface{}{"version": Regex(`^v\d+\.\d+\.\d+$`)},
+			},
+			expected: true,
+		},
+		{
+			name:    "wrong name",
+			matcher: MetricMatcher{Name: "other"},
+		},
+		{
+			name:    "wrong time",
+			matcher: MetricMatcher{Time: time.Unix(11, 0)},
+		},
+		{
+			name:    "missing tag",
+			matcher: MetricMatcher{Tags: map[string]interface{}{"line": "1"}},
+		},
+		{
+			name:    "tag mismatch",
+			matcher: MetricMatcher{Tags: map[string]interface{}{"id": Regex(`^ns=2;`)}},
+		},
+		{
+			name:    "regex on numeric field",
+			matcher: MetricMatcher{Fields: map[string]interface{}{"value": Regex(`42`)}},
+		},
+		{
+			name:    "field mismatch",
+			matcher: MetricMatcher{Fields: map[string]interface{}{"value": 42.0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, tt.matcher.Match(m))
+		})
+	}
+
+	// Options apply to the field comparison
+	matcher := MetricMatcher{Fields: map[string]interface{}{"value": 42.0}}
+	require.True(t, matcher.Match(m, FloatTolerance(0, 0.1)))
+	RequireMetricMatch(t, matcher, m, FloatTolerance(0, 0.1))
+}
+
+func TestRequireMetricsMatch(t *testing.T) {
+	actual := []telegraf.Metric{
+		metric.New("cpu", map[string]string{"cpu": "cpu0"}, map[string]interface{}{"idle": 90.0}, time.Unix(0, 0)),
+		metric.New("cpu", map[string]string{"cpu": "cpu1"}, map[string]interface{}{"idle": 80.0}, time.Unix(0, 0)),
+		metric.New("mem", map[string]string{}, map[string]interface{}{"free": int64(1)}, time.Unix(0, 0)),
+	}
+
+	// The generic matcher must not consume the metric required by the
+	// specific one
+	RequireMetricsMatch(t, []MetricMatcher{
+		{Name: "cpu", Tags: map[string]interface{}{"cpu": Regex(`^cpu\d$`)}},
+		{Name: "cpu", Tags: map[string]interface{}{"cpu": "cpu0"}},
+	}, actual)
+
+	// Each matcher needs a different metric
+	recorder := &failureRecorder{TB: t}
+	RequireMetricsMatch(recorder, []MetricMatcher{
+		{Name: "mem"},
+		{Name: "mem"},
+	}, actual)
+	require.Contains(t, recorder.failure, "no matching metrics for\nmem\n")
+	require.Contains(t, recorder.failure, "mem free=1i")
+
+	recorder = &failureRecorder{TB: t}
+	RequireMetricMatch(recorder, MetricMatcher{Name: "cpu", Tags: map[string]interface{}{"cpu": Regex(`^cpu[2-9]$`)}}, actual[0])
+	require.Contains(t, recorder.failure, "metric does not match cpu,cpu=/^cpu[2-9]$/")
+	require.Contains(t, recorder.failure, `tag "cpu" = "cpu0" does not match "^cpu[2-9]$"`)
+}
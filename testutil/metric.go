@@ -162,6 +162,17 @@ func IgnoreType() cmp.Option {
 	return cmpopts.IgnoreFields(metricDiff{}, "Type")
 }
 
+// FloatTolerance allows float values to differ by the given fraction of
+// their magnitude or by the given absolute margin, whichever is greater.
+func FloatTolerance(fraction, margin float64) cmp.Option {
+	return cmpopts.EquateApprox(fraction, margin)
+}
+
+// TimeTolerance allows timestamps to differ by the given duration.
+func TimeTolerance(margin time.Duration) cmp.Option {
+	return cmpopts.EquateApproxTime(margin)
+}
+
 // IgnoreFields disables comparison of the fields with the given names.
 // The field-names are case-sensitive!
 func IgnoreFields(names ...string) cmp.Option {
@@ -222,8 +233,8 @@ func RequireMetricEqual(t testing.TB, expected, actual telegraf.Metric, opts ...
 	}
 
 	opts = append(opts, cmpopts.EquateNaNs())
-	if diff := cmp.Diff(lhs, rhs, opts...); diff != "" {
-		t.Fatalf("telegraf.Metric\n--- expected\n+++ actual\n%s", diff)
+	if diff := diffMetrics([]*metricDiff{lhs}, []*metricDiff{rhs}, opts...); diff != "" {
+		t.Fatalf("telegraf.Metric differs\n%s", diff)
 	}
 }
 
@@ -244,8 +255,8 @@ func RequireMetricsEqual(t testing.TB, expected, actual []telegraf.Metric, opts
 	}
 
 	opts = append(opts, cmpopts.EquateNaNs())
-	if diff := cmp.Diff(lhs, rhs, opts...); diff != "" {
-		t.Fatalf("[]telegraf.Metric\n--- expected\n+++ actual\n%s", diff)
+	if diff := diffMetrics(lhs, rhs, opts...); diff != "" {
+		t.Fatalf("[]telegraf.Metric differs\n%s", diff)
 	}
 }
 
@@ -287,8 +298,8 @@ func RequireMetricsSubset(t testing.TB, expected, actual []telegraf.Metric, opts
 	}
 
 	opts = append(opts, cmpopts.EquateNaNs())
-	if diff := cmp.Diff(lhs, rhsFiltered, opts...); diff != "" {
-		t.Fatalf("[]telegraf.Metric\n--- expected\n+++ actual\n%s", diff)
+	if diff := diffMetrics(lhs, rhsFiltered, opts...); diff != "" {
+		t.Fatalf("[]telegraf.Metric differs\n%s", diff)
 	}
 }
 
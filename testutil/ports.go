@@ -0,0 +1,119 @@
+package testutil
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+var (
+	reservedPorts   = make(map[string]map[int]bool)
+	reservedPortsMu sync.Mutex
+)
+
+// FreeTCPPort returns a free local TCP port. The port is reserved for the
+// test until its end, so parallel tests of the same package never get the
+// same port even if the port is not bound yet.
+func FreeTCPPort(tb testing.TB) int {
+	tb.Helper()
+	return freePort(tb, "tcp")
+}
+
+// FreeUDPPort returns a free local UDP port reserved for the test, see
+// FreeTCPPort
+func FreeUDPPort(tb testing.TB) int {
+	tb.Helper()
+	return freePort(tb, "udp")
+}
+
+func freePort(tb testing.TB, network string) int {
+	tb.Helper()
+
+	reservedPortsMu.Lock()
+	defer reservedPortsMu.Unlock()
+	if reservedPorts[network] == nil {
+		reservedPorts[network] = make(map[int]bool)
+	}
+
+	// The kernel might hand out a recently released port again, so retry
+	// until getting a port not reserved by another test
+	for range 100 {
+		var port int
+		switch network {
+		case "tcp":
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				tb.Fatalf("finding free TCP port failed: %v", err)
+			}
+			port = listener.Addr().(*net.TCPAddr).Port
+			listener.Close()
+		case "udp":
+			conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+			if err != nil {
+				tb.Fatalf("finding free UDP port failed: %v", err)
+			}
+			port = conn.LocalAddr().(*net.UDPAddr).Port
+			conn.Close()
+		}
+		if reservedPorts[network][port] {
+			continue
+		}
+
+		reservedPorts[network][port] = true
+		tb.Cleanup(func() {
+			reservedPortsMu.Lock()
+			defer reservedPortsMu.Unlock()
+			delete(reservedPorts[network], port)
+		})
+		return port
+	}
+	tb.Fatalf("no free %s port found", network)
+	return 0
+}
+
+// WaitForListener waits until a socket is listening on the given address,
+// e.g. after starting a service input. Supported networks are "tcp", "udp",
+// "unix" and "unixgram". The test fails if the socket is not ready within
+// the timeout.
+func WaitForListener(tb testing.TB, network, address string, timeout time.Duration) {
+	tb.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for {
+		ready, err := listening(network, address)
+		if err != nil {
+			tb.Fatalf("checking %s socket %q failed: %v", network, address, err)
+		}
+		if ready {
+			return
+		}
+		if time.Now().After(deadline) {
+			tb.Fatalf("%s socket %q not ready within %s", network, address, timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func listening(network, address string) (bool, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		conn, err := net.DialTimeout(network, address, 100*time.Millisecond)
+		if err != nil {
+			return false, nil
+		}
+		conn.Close()
+		return true, nil
+	case "udp", "udp4", "udp6", "unixgram":
+		// Connection-less sockets cannot be probed by connecting, but binding
+		// the address fails as long as the socket is bound
+		conn, err := net.ListenPacket(network, address)
+		if err != nil {
+			return true, nil
+		}
+		conn.Close()
+		return false, nil
+	}
+	return false, errors.New("unsupported network")
+}
@@ -0,0 +1,51 @@
+package testutil
+
+import (
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreePortsUnique(t *testing.T) {
+	tcp := make(map[int]bool)
+	udp := make(map[int]bool)
+	for range 50 {
+		port := FreeTCPPort(t)
+		require.False(t, tcp[port], "TCP port %d handed out twice", port)
+		tcp[port] = true
+
+		port = FreeUDPPort(t)
+		require.False(t, udp[port], "UDP port %d handed out twice", port)
+		udp[port] = true
+	}
+}
+
+func TestWaitForListenerTCP(t *testing.T) {
+	addr := "127.0.0.1:" + strconv.Itoa(FreeTCPPort(t))
+
+	// Start listening delayed to simulate a slow service
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return
+		}
+		t.Cleanup(func() { listener.Close() })
+	}()
+	WaitForListener(t, "tcp", addr, 5*time.Second)
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	conn.Close()
+}
+
+func TestWaitForListenerUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:"+strconv.Itoa(FreeUDPPort(t)))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	WaitForListener(t, "udp", conn.LocalAddr().String(), 5*time.Second)
+}
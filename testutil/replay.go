@@ -0,0 +1,349 @@
+package testutil
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Directions of the recorded traffic
+const (
+	FromClient = "client"
+	FromServer = "server"
+)
+
+// Chunk is a block of data sent in one direction. Consecutive reads in the
+// same direction are merged into one chunk.
+type Chunk struct {
+	From string
+	Data []byte
+}
+
+type chunkJSON struct {
+	From string `json:"from"`
+	Data string `json:"data"`
+}
+
+func (c Chunk) MarshalJSON() ([]byte, error) {
+	return json.Marshal(chunkJSON{From: c.From, Data: hex.EncodeToString(c.Data)})
+}
+
+func (c *Chunk) UnmarshalJSON(buf []byte) error {
+	var raw chunkJSON
+	if err := json.Unmarshal(buf, &raw); err != nil {
+		return err
+	}
+	if raw.From != FromClient && raw.From != FromServer {
+		return fmt.Errorf("invalid direction %q", raw.From)
+	}
+	data, err := hex.DecodeString(raw.Data)
+	if err != nil {
+		return fmt.Errorf("decoding data failed: %w", err)
+	}
+	c.From, c.Data = raw.From, data
+	return nil
+}
+
+// Recording contains the traffic of all connections between a client and a
+// server in the order of the connections
+type Recording struct {
+	Target   string    `json:"target"`
+	Sessions [][]Chunk `json:"sessions"`
+}
+
+// LoadRecording reads a recording from the given file
+func LoadRecording(filename string) (*Recording, error) {
+	buf, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var r Recording
+	if err := json.Unmarshal(buf, &r); err != nil {
+		return nil, fmt.Errorf("parsing recording %q failed: %w", filename, err)
+	}
+	return &r, nil
+}
+
+// Save writes the recording to the given file creating missing directories
+func (r *Recording) Save(filename string) error {
+	buf, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0750); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(buf, '\n'), 0640)
+}
+
+// RecordingProxy forwards TCP connections to a real server, e.g. a device
+// or a simulator, and records the exchanged data. Point the plugin to the
+// address of the proxy and replay the recording using a ReplayServer later.
+type RecordingProxy struct {
+	// Address of the real server
+	Target string
+	// File to store the recording on close
+	Filename string
+
+	listener  net.Listener
+	recording Recording
+	conns     []net.Conn
+	closed    bool
+	wg        sync.WaitGroup
+	sync.Mutex
+}
+
+// Start listens on a random local port and forwards all connections
+func (p *RecordingProxy) Start() error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	p.listener = listener
+	p.recording = Recording{Target: p.Target}
+
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			p.forward(conn)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address of the proxy
+func (p *RecordingProxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+// Close terminates all connections and writes the recording to the file
+func (p *RecordingProxy) Close() error {
+	p.Lock()
+	if p.closed {
+		p.Unlock()
+		return nil
+	}
+	p.closed = true
+	for _, c := range p.conns {
+		c.Close()
+	}
+	p.Unlock()
+
+	p.listener.Close()
+	p.wg.Wait()
+
+	return p.recording.Save(p.Filename)
+}
+
+// Recording returns a copy of the traffic recorded so far
+func (p *RecordingProxy) Recording() *Recording {
+	p.Lock()
+	defer p.Unlock()
+
+	r := &Recording{Target: p.recording.Target, Sessions: make([][]Chunk, 0, len(p.recording.Sessions))}
+	for _, s := range p.recording.Sessions {
+		r.Sessions = append(r.Sessions, append([]Chunk(nil), s...))
+	}
+	return r
+}
+
+func (p *RecordingProxy) forward(client net.Conn) {
+	server, err := net.Dial("tcp", p.Target)
+	if err != nil {
+		client.Close()
+		return
+	}
+
+	p.Lock()
+	if p.closed {
+		p.Unlock()
+		client.Close()
+		server.Close()
+		return
+	}
+	p.conns = append(p.conns, client, server)
+	session := len(p.recording.Sessions)
+	p.recording.Sessions = append(p.recording.Sessions, nil)
+	p.Unlock()
+
+	pipe := func(dst, src net.Conn, from string) {
+		defer p.wg.Done()
+		// Terminate both directions if one side closes the connection
+		defer dst.Close()
+		defer src.Close()
+
+		buf := make([]byte, 64*1024)
+		for {
+			n, err := src.Read(buf)
+			if n > 0 {
+				p.record(session, from, buf[:n])
+				if _, werr := dst.Write(buf[:n]); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+	p.wg.Add(2)
+	go pipe(server, client, FromClient)
+	go pipe(client, server, FromServer)
+}
+
+func (p *RecordingProxy) record(session int, from string, data []byte) {
+	p.Lock()
+	defer p.Unlock()
+
+	chunks := p.recording.Sessions[session]
+	if n := len(chunks); n > 0 && chunks[n-1].From == from {
+		chunks[n-1].Data = append(chunks[n-1].Data, data...)
+		return
+	}
+	p.recording.Sessions[session] = append(chunks, Chunk{From: from, Data: bytes.Clone(data)})
+}
+
+// ReplayServer acts as the server of a recording. The n-th connection
+// replays the n-th recorded session, i.e. the recorded server data is sent
+// as soon as the preceding client data was received.
+type ReplayServer struct {
+	// File containing the recording
+	Filename string
+	// Require the client data to match the recording byte by byte. If
+	// disabled, only the amount of data is checked which allows the client
+	// to send e.g. different timestamps or nonces.
+	Strict bool
+
+	listener  net.Listener
+	recording *Recording
+	conns     []net.Conn
+	sessions  int
+	errs      []error
+	closed    bool
+	wg        sync.WaitGroup
+	sync.Mutex
+}
+
+// Start loads the recording and listens on a random local port
+func (s *ReplayServer) Start() error {
+	recording, err := LoadRecording(s.Filename)
+	if err != nil {
+		return err
+	}
+	s.recording = recording
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.Lock()
+			if s.closed {
+				s.Unlock()
+				conn.Close()
+				return
+			}
+			s.conns = append(s.conns, conn)
+			session := s.sessions
+			s.sessions++
+			s.Unlock()
+
+			s.wg.Add(1)
+			go s.replay(conn, session)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address of the server
+func (s *ReplayServer) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close terminates all connections and returns the deviations of the
+// clients from the recording
+func (s *ReplayServer) Close() error {
+	s.Lock()
+	if s.closed {
+		s.Unlock()
+		return nil
+	}
+	s.closed = true
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.Unlock()
+
+	s.listener.Close()
+	s.wg.Wait()
+
+	s.Lock()
+	defer s.Unlock()
+	return errors.Join(s.errs...)
+}
+
+func (s *ReplayServer) replay(conn net.Conn, session int) {
+	defer s.wg.Done()
+	defer conn.Close()
+
+	if session >= len(s.recording.Sessions) {
+		s.fail(fmt.Errorf("unexpected connection %d, only %d sessions recorded", session+1, len(s.recording.Sessions)))
+		return
+	}
+
+	for i, chunk := range s.recording.Sessions[session] {
+		if chunk.From == FromServer {
+			if _, err := conn.Write(chunk.Data); err != nil {
+				s.fail(fmt.Errorf("session %d, chunk %d: sending data failed: %w", session, i, err))
+				return
+			}
+			continue
+		}
+
+		buf := make([]byte, len(chunk.Data))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			s.fail(fmt.Errorf("session %d, chunk %d: receiving %d bytes failed: %w", session, i, len(buf), err))
+			return
+		}
+		if s.Strict && !bytes.Equal(buf, chunk.Data) {
+			s.fail(fmt.Errorf("session %d, chunk %d: client data differs from recording\nexpected: %x\nactual:   %x", session, i, chunk.Data, buf))
+			return
+		}
+	}
+
+	// The client must not send anything beyond the recording
+	var extra [1]byte
+	if n, _ := conn.Read(extra[:]); n > 0 {
+		s.fail(fmt.Errorf("session %d: client sent more data than recorded", session))
+	}
+}
+
+// fail records the error unless it is caused by closing the server
+func (s *ReplayServer) fail(err error) {
+	s.Lock()
+	defer s.Unlock()
+	if !s.closed {
+		s.errs = append(s.errs, err)
+	}
+}
@@ -0,0 +1,117 @@
+package testutil
+
+import (
+	"bufio"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// startUppercaseServer starts a server answering each line with the
+// uppercase line
+func startUppercaseServer(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				scanner := bufio.NewScanner(conn)
+				for scanner.Scan() {
+					if _, err := conn.Write([]byte(strings.ToUpper(scanner.Text()) + "\n")); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+// exchange sends the lines to the given address and returns the responses
+func exchange(t *testing.T, addr string, lines ...string) []string {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	responses := make([]string, 0, len(lines))
+	for _, line := range lines {
+		_, err := conn.Write([]byte(line + "\n"))
+		require.NoError(t, err)
+		response, err := reader.ReadString('\n')
+		require.NoError(t, err)
+		responses = append(responses, strings.TrimSpace(response))
+	}
+	return responses
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "recording.json")
+
+	// Record two connections to the real server
+	proxy := &RecordingProxy{Target: startUppercaseServer(t), Filename: filename}
+	require.NoError(t, proxy.Start())
+	require.Equal(t, []string{"HELLO", "WORLD"}, exchange(t, proxy.Addr(), "hello", "world"))
+	require.Equal(t, []string{"AGAIN"}, exchange(t, proxy.Addr(), "again"))
+	require.NoError(t, proxy.Close())
+
+	recording, err := LoadRecording(filename)
+	require.NoError(t, err)
+	require.Len(t, recording.Sessions, 2)
+	require.Equal(t, []Chunk{
+		{From: FromClient, Data: []byte("hello\n")},
+		{From: FromServer, Data: []byte("HELLO\n")},
+		{From: FromClient, Data: []byte("world\n")},
+		{From: FromServer, Data: []byte("WORLD\n")},
+	}, recording.Sessions[0])
+
+	// Replay without the real server
+	server := &ReplayServer{Filename: filename, Strict: true}
+	require.NoError(t, server.Start())
+	require.Equal(t, []string{"HELLO", "WORLD"}, exchange(t, server.Addr(), "hello", "world"))
+	require.Equal(t, []string{"AGAIN"}, exchange(t, server.Addr(), "again"))
+	require.NoError(t, server.Close())
+}
+
+func TestReplayMismatch(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "recording.json")
+	recording := &Recording{
+		Sessions: [][]Chunk{{
+			{From: FromClient, Data: []byte("hello\n")},
+			{From: FromServer, Data: []byte("HELLO\n")},
+		}},
+	}
+	require.NoError(t, recording.Save(filename))
+
+	// Data of the same length is accepted if not strict
+	lax := &ReplayServer{Filename: filename}
+	require.NoError(t, lax.Start())
+	require.Equal(t, []string{"HELLO"}, exchange(t, lax.Addr(), "jello"))
+	require.NoError(t, lax.Close())
+
+	strict := &ReplayServer{Filename: filename, Strict: true}
+	require.NoError(t, strict.Start())
+	conn, err := net.Dial("tcp", strict.Addr())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("jello\n"))
+	require.NoError(t, err)
+	// The server terminates the connection on mismatch
+	_, err = bufio.NewReader(conn).ReadString('\n')
+	require.Error(t, err)
+	conn.Close()
+	require.ErrorContains(t, strict.Close(), "client data differs from recording")
+}
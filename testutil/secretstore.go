@@ -0,0 +1,188 @@
+package testutil
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/influxdata/telegraf"
+)
+
+var _ telegraf.SecretStore = &SecretStore{}
+
+var secretReferencePattern = regexp.MustCompile(`^@\{(\w+):(\w+)\}$`)
+
+// LinkableSecret is a secret referencing secret-stores, e.g. a config.Secret
+type LinkableSecret interface {
+	GetUnlinked() []string
+	Link(resolvers map[string]telegraf.ResolveFunc) error
+}
+
+// SecretStore is an in-memory secret-store for testing the resolution of
+// secrets in plugins. Secrets can be changed at any time to test rotation
+// and resolving can be forced to fail to test error paths, e.g.
+//
+//	store := testutil.NewSecretStore("mock", map[string]string{"password": "secret"})
+//	store.Dynamic = true
+//	plugin.Password = config.NewSecret([]byte("@{mock:password}"))
+//	require.NoError(t, store.Link(&plugin.Password))
+//	...
+//	store.Fail("password", errors.New("store unavailable"))
+type SecretStore struct {
+	// ID used in secret references
+	ID string
+	// Resolve the secrets on each access instead of only once when linking
+	Dynamic bool
+
+	secrets     map[string][]byte
+	failures    map[string]error
+	failAll     error
+	resolutions map[string]int
+	sync.Mutex
+}
+
+// NewSecretStore creates a store with the given ID and initial secrets
+func NewSecretStore(id string, secrets map[string]string) *SecretStore {
+	s := &SecretStore{
+		ID:          id,
+		secrets:     make(map[string][]byte, len(secrets)),
+		failures:    make(map[string]error),
+		resolutions: make(map[string]int),
+	}
+	for k, v := range secrets {
+		s.secrets[k] = []byte(v)
+	}
+	return s
+}
+
+func (*SecretStore) SampleConfig() string {
+	return "In-memory test secret-store"
+}
+
+func (*SecretStore) Init() error {
+	return nil
+}
+
+// Get returns the secret for the given key or the injected error
+func (s *SecretStore) Get(key string) ([]byte, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.resolutions[key]++
+	if s.failAll != nil {
+		return nil, s.failAll
+	}
+	if err, found := s.failures[key]; found {
+		return nil, err
+	}
+	v, found := s.secrets[key]
+	if !found {
+		return nil, fmt.Errorf("secret %q not found", key)
+	}
+	return append([]byte(nil), v...), nil
+}
+
+// Set adds or changes the secret for the given key, e.g. to simulate the
+// rotation of a password
+func (s *SecretStore) Set(key, value string) error {
+	s.Lock()
+	defer s.Unlock()
+
+	s.secrets[key] = []byte(value)
+	return nil
+}
+
+// Delete removes the secret for the given key
+func (s *SecretStore) Delete(key string) {
+	s.Lock()
+	defer s.Unlock()
+
+	delete(s.secrets, key)
+}
+
+// List returns the sorted keys of all secrets
+func (s *SecretStore) List() ([]string, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.failAll != nil {
+		return nil, s.failAll
+	}
+	keys := make([]string, 0, len(s.secrets))
+	for k := range s.secrets {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// GetResolver returns a resolver for the given key. The key does not have
+// to exist yet, resolving fails as long as it is missing.
+func (s *SecretStore) GetResolver(key string) (telegraf.ResolveFunc, error) {
+	return func() ([]byte, bool, error) {
+		v, err := s.Get(key)
+		return v, s.Dynamic, err
+	}, nil
+}
+
+// Fail makes resolving the given key fail with the given error until Recover
+// is called
+func (s *SecretStore) Fail(key string, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.failures[key] = err
+}
+
+// FailAll makes resolving any key and listing the keys fail with the given
+// error until Recover is called
+func (s *SecretStore) FailAll(err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.failAll = err
+}
+
+// Recover removes all injected errors
+func (s *SecretStore) Recover() {
+	s.Lock()
+	defer s.Unlock()
+
+	s.failures = make(map[string]error)
+	s.failAll = nil
+}
+
+// Resolutions returns how often the given key was resolved
+func (s *SecretStore) Resolutions(key string) int {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.resolutions[key]
+}
+
+// Link links the references to the store in the given secrets like Telegraf
+// does on startup. References to other stores are an error.
+func (s *SecretStore) Link(secrets ...LinkableSecret) error {
+	for _, secret := range secrets {
+		resolvers := make(map[string]telegraf.ResolveFunc)
+		for _, ref := range secret.GetUnlinked() {
+			match := secretReferencePattern.FindStringSubmatch(ref)
+			if match == nil {
+				return fmt.Errorf("invalid secret reference %q", ref)
+			}
+			if match[1] != s.ID {
+				return fmt.Errorf("unknown secret-store for %q", ref)
+			}
+			resolver, err := s.GetResolver(match[2])
+			if err != nil {
+				return err
+			}
+			resolvers[ref] = resolver
+		}
+		if err := secret.Link(resolvers); err != nil {
+			return fmt.Errorf("linking secret failed: %w", err)
+		}
+	}
+	return nil
+}
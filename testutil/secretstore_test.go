@@ -0,0 +1,80 @@
+package testutil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf/config"
+)
+
+func TestSecretStoreStatic(t *testing.T) {
+	store := NewSecretStore("mock", map[string]string{"user": "admin", "password": "secret"})
+	require.NoError(t, store.Init())
+
+	secret := config.NewSecret([]byte("@{mock:user}:@{mock:password}"))
+	defer secret.Destroy()
+	require.NoError(t, store.Link(&secret))
+	require.Equal(t, 1, store.Resolutions("password"))
+
+	// Static secrets are resolved once when linking
+	require.NoError(t, store.Set("password", "rotated"))
+	store.FailAll(errors.New("store unavailable"))
+	buf, err := secret.Get()
+	require.NoError(t, err)
+	require.Equal(t, "admin:secret", buf.String())
+	buf.Destroy()
+	require.Equal(t, 1, store.Resolutions("password"))
+}
+
+func TestSecretStoreDynamic(t *testing.T) {
+	store := NewSecretStore("mock", map[string]string{"token": "first"})
+	store.Dynamic = true
+
+	secret := config.NewSecret([]byte("Bearer @{mock:token}"))
+	defer secret.Destroy()
+	require.NoError(t, store.Link(&secret))
+
+	buf, err := secret.Get()
+	require.NoError(t, err)
+	require.Equal(t, "Bearer first", buf.String())
+	buf.Destroy()
+
+	// Rotation is picked up on the next access
+	require.NoError(t, store.Set("token", "second"))
+	buf, err = secret.Get()
+	require.NoError(t, err)
+	require.Equal(t, "Bearer second", buf.String())
+	buf.Destroy()
+
+	// Injected failures
+	store.Fail("token", errors.New("token expired"))
+	_, err = secret.Get()
+	require.ErrorContains(t, err, "token expired")
+
+	store.Recover()
+	store.Delete("token")
+	_, err = secret.Get()
+	require.ErrorContains(t, err, `secret "token" not found`)
+	require.Equal(t, 5, store.Resolutions("token"))
+}
+
+func TestSecretStoreLinkErrors(t *testing.T) {
+	store := NewSecretStore("mock", map[string]string{"password": "secret"})
+
+	other := config.NewSecret([]byte("@{other:password}"))
+	defer other.Destroy()
+	require.ErrorContains(t, store.Link(&other), `unknown secret-store for "@{other:password}"`)
+
+	missing := config.NewSecret([]byte("@{mock:missing}"))
+	defer missing.Destroy()
+	require.ErrorContains(t, store.Link(&missing), "linking secret failed")
+
+	keys, err := store.List()
+	require.NoError(t, err)
+	require.Equal(t, []string{"password"}, keys)
+	store.FailAll(errors.New("store unavailable"))
+	_, err = store.List()
+	require.Error(t, err)
+}
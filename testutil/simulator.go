@@ -0,0 +1,148 @@
+package testutil
+
+import (
+	"net"
+	"sync"
+)
+
+// SimulatorFault is a fault injected into the request handling of a protocol
+// simulator
+type SimulatorFault int
+
+const (
+	// FaultNone handles the requests normally
+	FaultNone SimulatorFault = iota
+	// FaultError replies with a protocol-level error, e.g. a Modbus
+	// "server device busy" exception
+	FaultError
+	// FaultNoResponse silently drops the request to provoke a timeout
+	FaultNoResponse
+	// FaultDisconnect closes the connection upon the request
+	FaultDisconnect
+)
+
+// simulator contains the connection handling and fault injection shared by
+// the protocol simulators
+type simulator struct {
+	listener   net.Listener
+	conns      map[net.Conn]bool
+	requests   int
+	fault      SimulatorFault
+	faultCount int
+	closed     bool
+	wg         sync.WaitGroup
+	mu         sync.Mutex
+}
+
+func (s *simulator) start(serve func(net.Conn)) error {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	s.conns = make(map[net.Conn]bool)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			if s.closed {
+				s.mu.Unlock()
+				conn.Close()
+				return
+			}
+			s.conns[conn] = true
+			s.mu.Unlock()
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				defer s.drop(conn)
+				serve(conn)
+			}()
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the simulator is listening on
+func (s *simulator) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Close stops the simulator and terminates all connections
+func (s *simulator) Close() {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.closed = true
+	s.mu.Unlock()
+
+	s.listener.Close()
+	s.DisconnectAll()
+	s.wg.Wait()
+}
+
+// DisconnectAll terminates all client connections while the simulator keeps
+// accepting new ones, e.g. to simulate a network interruption
+func (s *simulator) DisconnectAll() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+// Connections returns the number of open client connections
+func (s *simulator) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+// Requests returns the number of requests received so far including the
+// ones affected by faults
+func (s *simulator) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// InjectFault applies the fault to the next count requests or to all
+// following requests if count is zero. Injecting FaultNone clears the fault.
+func (s *simulator) InjectFault(fault SimulatorFault, count int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fault = fault
+	s.faultCount = count
+}
+
+// nextFault registers a request and returns the fault to apply to it
+func (s *simulator) nextFault() SimulatorFault {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests++
+	fault := s.fault
+	if fault != FaultNone && s.faultCount > 0 {
+		s.faultCount--
+		if s.faultCount == 0 {
+			s.fault = FaultNone
+		}
+	}
+	return fault
+}
+
+func (s *simulator) drop(conn net.Conn) {
+	conn.Close()
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+}
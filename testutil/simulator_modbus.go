@@ -0,0 +1,257 @@
+package testutil
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+)
+
+// Modbus exception codes
+const (
+	ModbusIllegalFunction       byte = 0x01
+	ModbusIllegalDataAddress    byte = 0x02
+	ModbusIllegalDataValue      byte = 0x03
+	ModbusServerDeviceBusy      byte = 0x06
+	ModbusGatewayTargetNoAnswer byte = 0x0B
+)
+
+// ModbusServer is an in-process Modbus TCP server simulating devices with
+// the given unit (slave) IDs. The registers and coils of the units can be
+// changed at any time, e.g. in the OnRequest hook, and faults can be
+// injected using InjectFault:
+//
+//	sim := testutil.NewModbusServer(1)
+//	require.NoError(t, sim.Start())
+//	defer sim.Close()
+//	sim.SetHoldingRegisters(1, 0, 0x4248, 0x0000)
+//	plugin.Controller = "tcp://" + sim.Addr()
+type ModbusServer struct {
+	simulator
+
+	// OnRequest is called before handling each request with the unit ID and
+	// the function code of the request
+	OnRequest func(unit, function byte)
+
+	units map[byte]*modbusUnit
+	data  sync.Mutex
+}
+
+type modbusUnit struct {
+	coils            []bool
+	discreteInputs   []bool
+	holdingRegisters []uint16
+	inputRegisters   []uint16
+}
+
+// NewModbusServer creates a simulator for the given unit IDs with all
+// registers and coils set to zero. Requests for other units are answered
+// with a gateway exception.
+func NewModbusServer(units ...byte) *ModbusServer {
+	s := &ModbusServer{units: make(map[byte]*modbusUnit, len(units))}
+	for _, id := range units {
+		s.units[id] = &modbusUnit{
+			coils:            make([]bool, 65536),
+			discreteInputs:   make([]bool, 65536),
+			holdingRegisters: make([]uint16, 65536),
+			inputRegisters:   make([]uint16, 65536),
+		}
+	}
+	return s
+}
+
+// Start listens on a random local port
+func (s *ModbusServer) Start() error {
+	return s.start(s.serve)
+}
+
+// SetCoils sets the coils of the unit starting at the given address
+func (s *ModbusServer) SetCoils(unit byte, address uint16, values ...bool) {
+	s.data.Lock()
+	defer s.data.Unlock()
+	copy(s.unit(unit).coils[address:], values)
+}
+
+// SetDiscreteInputs sets the discrete inputs of the unit starting at the
+// given address
+func (s *ModbusServer) SetDiscreteInputs(unit byte, address uint16, values ...bool) {
+	s.data.Lock()
+	defer s.data.Unlock()
+	copy(s.unit(unit).discreteInputs[address:], values)
+}
+
+// SetHoldingRegisters sets the holding registers of the unit starting at the
+// given address
+func (s *ModbusServer) SetHoldingRegisters(unit byte, address uint16, values ...uint16) {
+	s.data.Lock()
+	defer s.data.Unlock()
+	copy(s.unit(unit).holdingRegisters[address:], values)
+}
+
+// SetInputRegisters sets the input registers of the unit starting at the
+// given address
+func (s *ModbusServer) SetInputRegisters(unit byte, address uint16, values ...uint16) {
+	s.data.Lock()
+	defer s.data.Unlock()
+	copy(s.unit(unit).inputRegisters[address:], values)
+}
+
+// Coils returns n coils of the unit starting at the given address, e.g. to
+// check values written by a client
+func (s *ModbusServer) Coils(unit byte, address uint16, n int) []bool {
+	s.data.Lock()
+	defer s.data.Unlock()
+	return append([]bool(nil), s.unit(unit).coils[int(address):int(address)+n]...)
+}
+
+// HoldingRegisters returns n holding registers of the unit starting at the
+// given address, e.g. to check values written by a client
+func (s *ModbusServer) HoldingRegisters(unit byte, address uint16, n int) []uint16 {
+	s.data.Lock()
+	defer s.data.Unlock()
+	return append([]uint16(nil), s.unit(unit).holdingRegisters[int(address):int(address)+n]...)
+}
+
+func (s *ModbusServer) unit(id byte) *modbusUnit {
+	u, found := s.units[id]
+	if !found {
+		panic("unknown modbus unit")
+	}
+	return u
+}
+
+func (s *ModbusServer) serve(conn net.Conn) {
+	header := make([]byte, 7)
+	for {
+		// MBAP header: transaction ID, protocol ID, length and unit ID
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(header[4:6]))
+		if length < 2 || length > 254 {
+			return
+		}
+		pdu := make([]byte, length-1)
+		if _, err := io.ReadFull(conn, pdu); err != nil {
+			return
+		}
+		unit, function := header[6], pdu[0]
+
+		var response []byte
+		switch s.nextFault() {
+		case FaultNoResponse:
+			continue
+		case FaultDisconnect:
+			return
+		case FaultError:
+			response = []byte{function | 0x80, ModbusServerDeviceBusy}
+		default:
+			if s.OnRequest != nil {
+				s.OnRequest(unit, function)
+			}
+			response = s.handle(unit, pdu)
+		}
+
+		frame := make([]byte, 7, 7+len(response))
+		copy(frame, header[:4])
+		binary.BigEndian.PutUint16(frame[4:6], uint16(len(response)+1))
+		frame[6] = unit
+		if _, err := conn.Write(append(frame, response...)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *ModbusServer) handle(unitID byte, pdu []byte) []byte {
+	function := pdu[0]
+	exception := func(code byte) []byte {
+		return []byte{function | 0x80, code}
+	}
+
+	s.data.Lock()
+	defer s.data.Unlock()
+
+	unit, found := s.units[unitID]
+	if !found {
+		return exception(ModbusGatewayTargetNoAnswer)
+	}
+	if len(pdu) < 5 {
+		return exception(ModbusIllegalDataValue)
+	}
+	address := int(binary.BigEndian.Uint16(pdu[1:3]))
+	value := binary.BigEndian.Uint16(pdu[3:5])
+
+	switch function {
+	case 0x01, 0x02: // read coils, read discrete inputs
+		quantity := int(value)
+		if quantity < 1 || quantity > 2000 {
+			return exception(ModbusIllegalDataValue)
+		}
+		if address+quantity > 65536 {
+			return exception(ModbusIllegalDataAddress)
+		}
+		bits := unit.coils
+		if function == 0x02 {
+			bits = unit.discreteInputs
+		}
+		data := make([]byte, (quantity+7)/8)
+		for i, v := range bits[address : address+quantity] {
+			if v {
+				data[i/8] |= 1 << (i % 8)
+			}
+		}
+		return append([]byte{function, byte(len(data))}, data...)
+	case 0x03, 0x04: // read holding registers, read input registers
+		quantity := int(value)
+		if quantity < 1 || quantity > 125 {
+			return exception(ModbusIllegalDataValue)
+		}
+		if address+quantity > 65536 {
+			return exception(ModbusIllegalDataAddress)
+		}
+		registers := unit.holdingRegisters
+		if function == 0x04 {
+			registers = unit.inputRegisters
+		}
+		response := []byte{function, byte(2 * quantity)}
+		for _, v := range registers[address : address+quantity] {
+			response = binary.BigEndian.AppendUint16(response, v)
+		}
+		return response
+	case 0x05: // write single coil
+		if value != 0xFF00 && value != 0x0000 {
+			return exception(ModbusIllegalDataValue)
+		}
+		unit.coils[address] = value == 0xFF00
+		return pdu[:5]
+	case 0x06: // write single register
+		unit.holdingRegisters[address] = value
+		return pdu[:5]
+	case 0x0F, 0x10: // write multiple coils, write multiple registers
+		quantity := int(value)
+		if len(pdu) < 6 || len(pdu) < 6+int(pdu[5]) {
+			return exception(ModbusIllegalDataValue)
+		}
+		if address+quantity > 65536 {
+			return exception(ModbusIllegalDataAddress)
+		}
+		data := pdu[6 : 6+int(pdu[5])]
+		if function == 0x0F {
+			if quantity < 1 || len(data) != (quantity+7)/8 {
+				return exception(ModbusIllegalDataValue)
+			}
+			for i := range quantity {
+				unit.coils[address+i] = data[i/8]&(1<<(i%8)) != 0
+			}
+		} else {
+			if quantity < 1 || len(data) != 2*quantity {
+				return exception(ModbusIllegalDataValue)
+			}
+			for i := range quantity {
+				unit.holdingRegisters[address+i] = binary.BigEndian.Uint16(data[2*i:])
+			}
+		}
+		return pdu[:5]
+	}
+	return exception(ModbusIllegalFunction)
+}
@@ -0,0 +1,285 @@
+package testutil
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// S7 return codes of read items
+const (
+	S7ItemAddressOutOfRange byte = 0x05
+	S7ItemObjectNotExisting byte = 0x0A
+)
+
+// Error class and code of S7 responses when injecting FaultError
+const s7FaultError uint16 = 0x8104
+
+var s7Areas = map[string]byte{
+	"PE": 0x81,
+	"PA": 0x82,
+	"MK": 0x83,
+	"C":  0x1C,
+	"T":  0x1D,
+}
+
+// S7Server is an in-process S7comm (ISO-on-TCP) server simulating a PLC. It
+// supports the connection setup and reading multiple variables which is
+// sufficient for the gos7 client. Data blocks and other memory areas can be
+// changed at any time, e.g. in the OnRequest hook, and faults can be
+// injected using InjectFault:
+//
+//	sim := testutil.NewS7Server()
+//	require.NoError(t, sim.Start())
+//	defer sim.Close()
+//	sim.SetDB(1, 0, []byte{0x00, 0x2A})
+//	plugin.Server = sim.Addr()
+type S7Server struct {
+	simulator
+
+	// PDULength is the maximum PDU length negotiated with the client,
+	// defaults to 480 bytes
+	PDULength int
+
+	// OnRequest is called before handling each S7 request
+	OnRequest func()
+
+	dbs   map[int][]byte
+	areas map[byte][]byte
+	data  sync.Mutex
+}
+
+// NewS7Server creates a simulator without data blocks and with all inputs,
+// outputs, merkers, counters and timers set to zero
+func NewS7Server() *S7Server {
+	s := &S7Server{
+		PDULength: 480,
+		dbs:       make(map[int][]byte),
+		areas:     make(map[byte][]byte, len(s7Areas)),
+	}
+	for _, code := range s7Areas {
+		s.areas[code] = make([]byte, 65536)
+	}
+	return s
+}
+
+// Start listens on a random local port
+func (s *S7Server) Start() error {
+	return s.start(s.serve)
+}
+
+// SetDB sets the content of the data block starting at the given byte
+// offset. The data block is created or extended if necessary.
+func (s *S7Server) SetDB(db, offset int, data []byte) {
+	s.data.Lock()
+	defer s.data.Unlock()
+
+	block := s.dbs[db]
+	if len(block) < offset+len(data) {
+		block = append(block, make([]byte, offset+len(data)-len(block))...)
+	}
+	copy(block[offset:], data)
+	s.dbs[db] = block
+}
+
+// DB returns n bytes of the data block starting at the given byte offset or
+// nil if the data block does not exist or is too short
+func (s *S7Server) DB(db, offset, n int) []byte {
+	s.data.Lock()
+	defer s.data.Unlock()
+
+	block := s.dbs[db]
+	if len(block) < offset+n {
+		return nil
+	}
+	return append([]byte(nil), block[offset:offset+n]...)
+}
+
+// SetArea sets the content of the "PE", "PA", "MK", "C" or "T" area starting
+// at the given offset. The offset is given in bytes except for counters and
+// timers where it is the number of the first counter or timer, each taking
+// two bytes of data.
+func (s *S7Server) SetArea(area string, offset int, data []byte) {
+	code, found := s7Areas[area]
+	if !found {
+		panic(fmt.Sprintf("invalid S7 area %q", area))
+	}
+	if code == 0x1C || code == 0x1D {
+		offset *= 2
+	}
+
+	s.data.Lock()
+	defer s.data.Unlock()
+	copy(s.areas[code][offset:], data)
+}
+
+func (s *S7Server) serve(conn net.Conn) {
+	for {
+		// TPKT header with the length of the whole telegram
+		frame := make([]byte, 4)
+		if _, err := io.ReadFull(conn, frame); err != nil {
+			return
+		}
+		length := int(binary.BigEndian.Uint16(frame[2:4]))
+		if length < 7 {
+			return
+		}
+		frame = append(frame, make([]byte, length-4)...)
+		if _, err := io.ReadFull(conn, frame[4:]); err != nil {
+			return
+		}
+
+		var response []byte
+		switch frame[5] {
+		case 0xE0: // COTP connection request
+			response = append([]byte(nil), frame...)
+			response[5] = 0xD0
+			response[6], response[7] = frame[8], frame[9]
+			response[8], response[9] = 0x00, 0x01
+		case 0xF0: // COTP data carrying an S7 PDU
+			if len(frame) < 19 || frame[7] != 0x32 {
+				return
+			}
+			switch s.nextFault() {
+			case FaultNoResponse:
+				continue
+			case FaultDisconnect:
+				return
+			case FaultError:
+				response = s7Response(frame, s7FaultError, nil, nil)
+			default:
+				if s.OnRequest != nil {
+					s.OnRequest()
+				}
+				response = s.handle(frame)
+			}
+		default:
+			return
+		}
+
+		if _, err := conn.Write(response); err != nil {
+			return
+		}
+	}
+}
+
+func (s *S7Server) handle(frame []byte) []byte {
+	params := frame[17:]
+	switch params[0] {
+	case 0xF0: // setup communication
+		if len(params) < 8 {
+			return s7Response(frame, 0x8500, nil, nil)
+		}
+		pduLength := min(int(binary.BigEndian.Uint16(params[6:8])), s.PDULength)
+		ack := append([]byte(nil), params[:8]...)
+		binary.BigEndian.PutUint16(ack[6:8], uint16(pduLength))
+		return s7Response(frame, 0, ack, nil)
+	case 0x04: // read variables
+		count := int(params[1])
+		if len(params) < 2+12*count {
+			return s7Response(frame, 0x8500, nil, nil)
+		}
+		var data []byte
+		for i := range count {
+			item := params[2+12*i : 2+12*(i+1)]
+			if i > 0 && len(data)%2 != 0 {
+				data = append(data, 0x00)
+			}
+			data = append(data, s.read(item)...)
+		}
+		return s7Response(frame, 0, []byte{0x04, byte(count)}, data)
+	}
+	return s7Response(frame, 0x8404, nil, nil)
+}
+
+// read returns the data item for the given item request
+func (s *S7Server) read(item []byte) []byte {
+	wordlen := item[3]
+	amount := int(binary.BigEndian.Uint16(item[4:6]))
+	db := int(binary.BigEndian.Uint16(item[6:8]))
+	area := item[8]
+	address := int(item[9])<<16 | int(item[10])<<8 | int(item[11])
+
+	// Determine the size of the elements and the transport size of the
+	// response. The length of the data is given in bits except for bits,
+	// reals and octet-strings.
+	var size int
+	transport := byte(0x04)
+	switch wordlen {
+	case 0x01: // bit
+		size, transport = 1, 0x03
+	case 0x02, 0x03: // byte, char
+		size = 1
+	case 0x04, 0x05: // word, int
+		size = 2
+	case 0x06, 0x07: // dword, dint
+		size = 4
+	case 0x08: // real
+		size, transport = 4, 0x07
+	case 0x0F: // date and time
+		size, transport = 8, 0x09
+	case 0x1C, 0x1D: // counter, timer
+		size, transport = 2, 0x09
+	default:
+		return []byte{S7ItemObjectNotExisting, 0x00, 0x00, 0x00}
+	}
+	offset := address >> 3
+	if area == 0x1C || area == 0x1D {
+		offset = 2 * address
+	}
+	n := size * amount
+
+	s.data.Lock()
+	defer s.data.Unlock()
+
+	var memory []byte
+	if area == 0x84 {
+		block, found := s.dbs[db]
+		if !found {
+			return []byte{S7ItemObjectNotExisting, 0x00, 0x00, 0x00}
+		}
+		memory = block
+	} else {
+		block, found := s.areas[area]
+		if !found {
+			return []byte{S7ItemObjectNotExisting, 0x00, 0x00, 0x00}
+		}
+		memory = block
+	}
+	if offset+n > len(memory) {
+		return []byte{S7ItemAddressOutOfRange, 0x00, 0x00, 0x00}
+	}
+
+	value := append([]byte(nil), memory[offset:offset+n]...)
+	if wordlen == 0x01 {
+		value[0] = (value[0] >> (address & 0x07)) & 0x01
+	}
+
+	length := n
+	if transport == 0x04 {
+		length *= 8
+	}
+	response := []byte{0xFF, transport, 0x00, 0x00}
+	binary.BigEndian.PutUint16(response[2:4], uint16(length))
+	return append(response, value...)
+}
+
+// s7Response creates an ack-data telegram answering the request with the
+// given error, parameters and data
+func s7Response(request []byte, status uint16, params, data []byte) []byte {
+	response := make([]byte, 19, 19+len(params)+len(data))
+	copy(response[4:7], request[4:7])
+	response[7], response[8] = 0x32, 0x03
+	copy(response[11:13], request[11:13])
+	binary.BigEndian.PutUint16(response[13:15], uint16(len(params)))
+	binary.BigEndian.PutUint16(response[15:17], uint16(len(data)))
+	binary.BigEndian.PutUint16(response[17:19], status)
+	response = append(response, params...)
+	response = append(response, data...)
+
+	response[0] = 0x03
+	binary.BigEndian.PutUint16(response[2:4], uint16(len(response)))
+	return response
+}
@@ -0,0 +1,198 @@
+package testutil
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	mb "github.com/grid-x/modbus"
+	"github.com/robinson/gos7"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModbusServerRegisters(t *testing.T) {
+	sim := NewModbusServer(1)
+	require.NoError(t, sim.Start())
+	defer sim.Close()
+
+	sim.SetHoldingRegisters(1, 10, 0x0102, 0x0304)
+	sim.SetInputRegisters(1, 0, 0xABCD)
+	sim.SetCoils(1, 3, true, false, true)
+	sim.SetDiscreteInputs(1, 0, true)
+
+	handler := mb.NewTCPClientHandler(sim.Addr())
+	handler.SlaveID = 1
+	handler.Timeout = time.Second
+	require.NoError(t, handler.Connect())
+	defer handler.Close()
+	client := mb.NewClient(handler)
+
+	buf, err := client.ReadHoldingRegisters(10, 2)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x01, 0x02, 0x03, 0x04}, buf)
+
+	buf, err = client.ReadInputRegisters(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xAB, 0xCD}, buf)
+
+	buf, err = client.ReadCoils(3, 3)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x05}, buf)
+
+	buf, err = client.ReadDiscreteInputs(0, 2)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x01}, buf)
+
+	_, err = client.WriteMultipleRegisters(20, 2, []byte{0x00, 0x2A, 0x00, 0x2B})
+	require.NoError(t, err)
+	require.Equal(t, []uint16{42, 43}, sim.HoldingRegisters(1, 20, 2))
+
+	_, err = client.WriteSingleCoil(7, 0xFF00)
+	require.NoError(t, err)
+	require.Equal(t, []bool{true}, sim.Coils(1, 7, 1))
+
+	_, err = client.ReadHoldingRegisters(65535, 2)
+	require.ErrorContains(t, err, "illegal data address")
+	require.Equal(t, 7, sim.Requests())
+}
+
+func TestModbusServerUnknownUnit(t *testing.T) {
+	sim := NewModbusServer(1)
+	require.NoError(t, sim.Start())
+	defer sim.Close()
+
+	handler := mb.NewTCPClientHandler(sim.Addr())
+	handler.SlaveID = 2
+	handler.Timeout = time.Second
+	require.NoError(t, handler.Connect())
+	defer handler.Close()
+
+	_, err := mb.NewClient(handler).ReadHoldingRegisters(0, 1)
+	require.ErrorContains(t, err, "gateway target device failed to respond")
+}
+
+func TestModbusServerFaults(t *testing.T) {
+	sim := NewModbusServer(1)
+	require.NoError(t, sim.Start())
+	defer sim.Close()
+
+	var calls int
+	sim.OnRequest = func(_, _ byte) {
+		calls++
+		sim.SetHoldingRegisters(1, 0, uint16(calls))
+	}
+
+	handler := mb.NewTCPClientHandler(sim.Addr())
+	handler.SlaveID = 1
+	handler.Timeout = 100 * time.Millisecond
+	require.NoError(t, handler.Connect())
+	defer handler.Close()
+	client := mb.NewClient(handler)
+
+	sim.InjectFault(FaultError, 1)
+	_, err := client.ReadHoldingRegisters(0, 1)
+	require.ErrorContains(t, err, "server device busy")
+
+	sim.InjectFault(FaultNoResponse, 1)
+	_, err = client.ReadHoldingRegisters(0, 1)
+	require.Error(t, err)
+
+	// The fault is cleared after the given number of requests and the hook
+	// is only called for the requests handled normally
+	require.NoError(t, handler.Connect())
+	buf, err := client.ReadHoldingRegisters(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x00, 0x01}, buf)
+
+	sim.InjectFault(FaultDisconnect, 0)
+	_, err = client.ReadHoldingRegisters(0, 1)
+	require.Error(t, err)
+	require.Eventually(t, func() bool {
+		return sim.Connections() == 0
+	}, time.Second, 10*time.Millisecond)
+
+	sim.InjectFault(FaultNone, 0)
+	handler.Close()
+	require.NoError(t, handler.Connect())
+	buf, err = client.ReadHoldingRegisters(0, 1)
+	require.NoError(t, err)
+	require.Equal(t, []byte{0x00, 0x02}, buf)
+	require.Equal(t, 5, sim.Requests())
+}
+
+func TestS7ServerRead(t *testing.T) {
+	sim := NewS7Server()
+	require.NoError(t, sim.Start())
+	defer sim.Close()
+
+	sim.SetDB(1, 0, []byte{0x00, 0x2A, 0x42, 0x28, 0x00, 0x00})
+	sim.SetDB(1, 10, []byte{0x04})
+	sim.SetArea("MK", 5, []byte{0x7F})
+	sim.SetArea("C", 3, []byte{0x01, 0x02})
+
+	handler := gos7.NewTCPClientHandler(sim.Addr(), 0, 1)
+	handler.Timeout = time.Second
+	require.NoError(t, handler.Connect())
+	defer handler.Close()
+	client := gos7.NewClient(handler)
+	require.Equal(t, 480, handler.PDULength)
+
+	items := []gos7.S7DataItem{
+		{Area: 0x84, WordLen: 0x04, DBNumber: 1, Start: 0, Amount: 1, Data: make([]byte, 2)},
+		{Area: 0x84, WordLen: 0x08, DBNumber: 1, Start: 2, Amount: 1, Data: make([]byte, 4)},
+		{Area: 0x84, WordLen: 0x01, DBNumber: 1, Start: 10, Bit: 2, Amount: 1, Data: make([]byte, 1)},
+		{Area: 0x83, WordLen: 0x02, Start: 5, Amount: 1, Data: make([]byte, 1)},
+		{Area: 0x1C, WordLen: 0x1C, Start: 3, Amount: 1, Data: make([]byte, 2)},
+		{Area: 0x84, WordLen: 0x04, DBNumber: 2, Start: 0, Amount: 1, Data: make([]byte, 2)},
+		{Area: 0x84, WordLen: 0x06, DBNumber: 1, Start: 10, Amount: 1, Data: make([]byte, 4)},
+	}
+	require.NoError(t, client.AGReadMulti(items, len(items)))
+
+	require.Equal(t, uint16(42), binary.BigEndian.Uint16(items[0].Data))
+	require.Equal(t, []byte{0x42, 0x28, 0x00, 0x00}, items[1].Data)
+	require.Equal(t, []byte{0x01}, items[2].Data)
+	require.Equal(t, []byte{0x7F}, items[3].Data)
+	require.Equal(t, []byte{0x01, 0x02}, items[4].Data)
+	require.NotEmpty(t, items[5].Error)
+	require.NotEmpty(t, items[6].Error)
+	for _, item := range items[:5] {
+		require.Empty(t, item.Error)
+	}
+	require.Equal(t, 2, sim.Requests())
+}
+
+func TestS7ServerFaults(t *testing.T) {
+	sim := NewS7Server()
+	require.NoError(t, sim.Start())
+	defer sim.Close()
+	sim.SetDB(1, 0, []byte{0x00, 0x01})
+
+	handler := gos7.NewTCPClientHandler(sim.Addr(), 0, 1)
+	handler.Timeout = 100 * time.Millisecond
+	require.NoError(t, handler.Connect())
+	defer handler.Close()
+	client := gos7.NewClient(handler)
+
+	read := func() error {
+		items := []gos7.S7DataItem{
+			{Area: 0x84, WordLen: 0x04, DBNumber: 1, Amount: 1, Data: make([]byte, 2)},
+		}
+		return client.AGReadMulti(items, len(items))
+	}
+
+	sim.InjectFault(FaultError, 2)
+	require.Error(t, read())
+	require.Error(t, read())
+	require.NoError(t, read())
+
+	sim.InjectFault(FaultNoResponse, 1)
+	require.Error(t, read())
+
+	handler.Close()
+	require.NoError(t, handler.Connect())
+	sim.InjectFault(FaultDisconnect, 1)
+	require.Error(t, read())
+	require.Eventually(t, func() bool {
+		return sim.Connections() == 0
+	}, time.Second, 10*time.Millisecond)
+}
@@ -62,7 +62,8 @@ func GetRandomString(chars int) string {
 }
 
 // MockMetrics returns a mock []telegraf.Metric object for using in unit tests
-// of telegraf output sinks.
+// of telegraf output sinks. Use a MetricGenerator for realistic volumes of
+// metrics, e.g. in benchmarks.
 func MockMetrics() []telegraf.Metric {
 	metrics := make([]telegraf.Metric, 0)
 	// Create a new point batch
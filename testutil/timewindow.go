@@ -0,0 +1,101 @@
+package testutil
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/influxdata/telegraf"
+)
+
+// TimeWindow is the time range, including both ends, in which a plugin is
+// expected to create timestamps, e.g. the duration of a gather cycle or of a
+// subscription
+type TimeWindow struct {
+	Start time.Time
+	End   time.Time
+}
+
+// MeasureWindow returns the window spanning the execution of the given
+// function, e.g. a call to the plugin's Gather function
+func MeasureWindow(f func()) TimeWindow {
+	start := time.Now()
+	f()
+	return TimeWindow{Start: start, End: time.Now()}
+}
+
+// Contains checks if the timestamp is within the window
+func (w TimeWindow) Contains(ts time.Time) bool {
+	return !ts.Before(w.Start) && !ts.After(w.End)
+}
+
+// Extend returns the window widened by the given duration on both ends, e.g.
+// to account for the precision of the timestamps or for clock skew between
+// Telegraf and a device
+func (w TimeWindow) Extend(d time.Duration) TimeWindow {
+	return TimeWindow{Start: w.Start.Add(-d), End: w.End.Add(d)}
+}
+
+func (w TimeWindow) String() string {
+	return "[" + w.Start.Format(time.RFC3339Nano) + ", " + w.End.Format(time.RFC3339Nano) + "]"
+}
+
+// RequireTimestampsInWindow halts the test with an error if any of the
+// metrics has a timestamp outside the window, e.g. because a timestamp
+// provided by the device is used instead of the gather time
+func RequireTimestampsInWindow(t testing.TB, window TimeWindow, metrics []telegraf.Metric) {
+	if x, ok := t.(helper); ok {
+		x.Helper()
+	}
+
+	var outside []string
+	for _, m := range metrics {
+		if !window.Contains(m.Time()) {
+			outside = append(outside, newMetricDiff(m).String())
+		}
+	}
+	if len(outside) > 0 {
+		t.Fatalf("timestamps outside window %s:\n%s", window.String(), strings.Join(outside, "\n"))
+	}
+}
+
+// RequireTimestampsNotInWindow halts the test with an error if any of the
+// metrics has a timestamp inside the window, e.g. because the gather time is
+// used instead of a timestamp provided by the device
+func RequireTimestampsNotInWindow(t testing.TB, window TimeWindow, metrics []telegraf.Metric) {
+	if x, ok := t.(helper); ok {
+		x.Helper()
+	}
+
+	var inside []string
+	for _, m := range metrics {
+		if window.Contains(m.Time()) {
+			inside = append(inside, newMetricDiff(m).String())
+		}
+	}
+	if len(inside) > 0 {
+		t.Fatalf("timestamps inside window %s:\n%s", window.String(), strings.Join(inside, "\n"))
+	}
+}
+
+// RequireTimestampsMonotonic halts the test with an error if the timestamps
+// of any series, i.e. metrics with the same name and tags, decrease in the
+// order of the given metrics. Equal timestamps are allowed.
+func RequireTimestampsMonotonic(t testing.TB, metrics []telegraf.Metric) {
+	if x, ok := t.(helper); ok {
+		x.Helper()
+	}
+
+	last := make(map[uint64]telegraf.Metric)
+	var violations []string
+	for _, m := range metrics {
+		id := m.HashID()
+		if prev, found := last[id]; found && m.Time().Before(prev.Time()) {
+			violations = append(violations, newMetricDiff(m).String()+"\n  after "+newMetricDiff(prev).String())
+		}
+		last[id] = m
+	}
+	if len(violations) > 0 {
+		t.Fatalf("timestamps decreasing within series:\n%s", strings.Join(violations, "\n"))
+	}
+}
@@ -0,0 +1,67 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/influxdata/telegraf"
+	"github.com/influxdata/telegraf/metric"
+)
+
+func TestTimeWindow(t *testing.T) {
+	window := TimeWindow{Start: time.Unix(10, 0), End: time.Unix(20, 0)}
+	require.True(t, window.Contains(time.Unix(10, 0)))
+	require.True(t, window.Contains(time.Unix(20, 0)))
+	require.False(t, window.Contains(time.Unix(9, 0)))
+	require.False(t, window.Contains(time.Unix(21, 0)))
+
+	extended := window.Extend(time.Second)
+	require.True(t, extended.Contains(time.Unix(9, 0)))
+	require.True(t, extended.Contains(time.Unix(21, 0)))
+
+	var ts time.Time
+	measured := MeasureWindow(func() { ts = time.Now() })
+	require.True(t, measured.Contains(ts))
+}
+
+func TestRequireTimestampsInWindow(t *testing.T) {
+	window := TimeWindow{Start: time.Unix(10, 0), End: time.Unix(20, 0)}
+	metrics := []telegraf.Metric{
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 1}, time.Unix(15, 0)),
+		metric.New("cpu", map[string]string{}, map[string]interface{}{"value": 2}, time.Unix(25, 0)),
+	}
+
+	RequireTimestampsInWindow(t, window, metrics[:1])
+	RequireTimestampsNotInWindow(t, window, metrics[1:])
+
+	recorder := &failureRecorder{TB: t}
+	RequireTimestampsInWindow(recorder, window, metrics)
+	require.Contains(t, recorder.failure, "timestamps outside window")
+	require.Contains(t, recorder.failure, "cpu value=2i 1970-01-01T00:00:25Z")
+	require.NotContains(t, recorder.failure, "value=1i")
+
+	recorder = &failureRecorder{TB: t}
+	RequireTimestampsNotInWindow(recorder, window, metrics)
+	require.Contains(t, recorder.failure, "timestamps inside window")
+	require.Contains(t, recorder.failure, "cpu value=1i 1970-01-01T00:00:15Z")
+}
+
+func TestRequireTimestampsMonotonic(t *testing.T) {
+	metrics := []telegraf.Metric{
+		metric.New("cpu", map[string]string{"cpu": "0"}, map[string]interface{}{"value": 1}, time.Unix(10, 0)),
+		metric.New("cpu", map[string]string{"cpu": "1"}, map[string]interface{}{"value": 2}, time.Unix(5, 0)),
+		metric.New("cpu", map[string]string{"cpu": "0"}, map[string]interface{}{"value": 3}, time.Unix(10, 0)),
+		metric.New("cpu", map[string]string{"cpu": "1"}, map[string]interface{}{"value": 4}, time.Unix(6, 0)),
+	}
+	RequireTimestampsMonotonic(t, metrics)
+
+	metrics = append(metrics,
+		metric.New("cpu", map[string]string{"cpu": "0"}, map[string]interface{}{"value": 5}, time.Unix(9, 0)),
+	)
+	recorder := &failureRecorder{TB: t}
+	RequireTimestampsMonotonic(recorder, metrics)
+	require.Contains(t, recorder.failure, "timestamps decreasing within series")
+	require.Contains(t, recorder.failure, "cpu,cpu=0 value=5i 1970-01-01T00:00:09Z\n  after cpu,cpu=0 value=3i 1970-01-01T00:00:10Z")
+}